@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/duckonomy/noture/internal/backup"
+	"github.com/duckonomy/noture/internal/config"
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const backupUsage = `usage: noture-server backup <command> [flags]
+
+commands:
+  run                          store a new differential backup
+  list                         list backups, oldest first
+  verify <id>                  check every blob a backup references
+  restore <id|time> <out-dir>  write a backup (or the state at an RFC3339 time) to out-dir
+
+flags:
+  -dir string        backup directory (default $BACKUP_DIR or ./backups)
+  -workspace string  restore only this workspace ID
+`
+
+func runBackupCommand(log *logger.Logger, cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, backupUsage) }
+
+	defaultDir := os.Getenv("BACKUP_DIR")
+	if defaultDir == "" {
+		defaultDir = "./backups"
+	}
+	dir := fs.String("dir", defaultDir, "backup directory")
+	workspace := fs.String("workspace", "", "restore only this workspace ID")
+
+	if len(args) == 0 {
+		fs.Usage()
+		return 2
+	}
+	command := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	store, err := backup.NewStore(*dir)
+	if err != nil {
+		log.Error("Failed to open backup store", "error", err)
+		return 1
+	}
+
+	switch command {
+	case "run":
+		ctx := context.Background()
+		conn, err := pgx.Connect(ctx, cfg.DatabaseURL)
+		if err != nil {
+			log.Error("Failed to connect to database", "error", err)
+			return 1
+		}
+		defer conn.Close(ctx)
+
+		queries := db.New(conn)
+		blobs, err := storage.New(storage.ConfigFromEnv(queries))
+		if err != nil {
+			log.Error("Failed to open blob storage", "error", err)
+			return 1
+		}
+
+		manifest, err := backup.NewBackupper(queries, blobs, store).Run(ctx)
+		if err != nil {
+			log.Error("Backup failed", "error", err)
+			return 1
+		}
+		fmt.Println(manifest.ID)
+
+	case "list":
+		ids, err := store.ListManifests()
+		if err != nil {
+			log.Error("Failed to list backups", "error", err)
+			return 1
+		}
+		for _, id := range ids {
+			fmt.Println(id)
+		}
+
+	case "verify":
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return 2
+		}
+		manifest, err := store.LoadManifest(fs.Arg(0))
+		if err != nil {
+			log.Error("Failed to load backup", "error", err)
+			return 1
+		}
+		report := backup.Verify(store, manifest)
+		json.NewEncoder(os.Stdout).Encode(report)
+		if !report.OK() {
+			return 1
+		}
+
+	case "restore":
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return 2
+		}
+
+		var manifest *backup.Manifest
+		if at, err := time.Parse(time.RFC3339, fs.Arg(0)); err == nil {
+			manifest, err = store.ManifestAt(at)
+			if err != nil {
+				log.Error("Failed to find backup", "error", err)
+				return 1
+			}
+		} else {
+			manifest, err = store.LoadManifest(fs.Arg(0))
+			if err != nil {
+				log.Error("Failed to load backup", "error", err)
+				return 1
+			}
+		}
+
+		var workspaceID *uuid.UUID
+		if *workspace != "" {
+			id, err := uuid.Parse(*workspace)
+			if err != nil {
+				log.Error("Invalid workspace ID", "workspace", *workspace)
+				return 2
+			}
+			workspaceID = &id
+		}
+
+		restored, err := backup.Restore(store, manifest, fs.Arg(1), workspaceID)
+		if err != nil {
+			log.Error("Restore failed", "error", err, "files_restored", restored)
+			return 1
+		}
+		log.Info("Restore completed", "manifest_id", manifest.ID, "files_restored", restored)
+
+	default:
+		fs.Usage()
+		return 2
+	}
+
+	return 0
+}
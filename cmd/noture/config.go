@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/duckonomy/noture/pkg/client"
+	"github.com/google/uuid"
+)
+
+// credentials are what login stores: the server and the device's token.
+// NOTURE_SERVER and NOTURE_TOKEN override them.
+type credentials struct {
+	Server string `json:"server"`
+	Token  string `json:"token"`
+}
+
+func credentialsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config directory: %w", err)
+	}
+	return filepath.Join(dir, "noture", "credentials.json"), nil
+}
+
+func loadCredentials() (*credentials, error) {
+	var creds credentials
+	path, err := credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read credentials: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &creds); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+	if server := os.Getenv("NOTURE_SERVER"); server != "" {
+		creds.Server = server
+	}
+	if token := os.Getenv("NOTURE_TOKEN"); token != "" {
+		creds.Token = token
+	}
+	return &creds, nil
+}
+
+func saveCredentials(creds *credentials) error {
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0o600)
+}
+
+// newClient returns a client signed in with the stored credentials.
+func newClient() (*client.Client, error) {
+	creds, err := loadCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if creds.Server == "" || creds.Token == "" {
+		return nil, errors.New("not signed in; run noture login first")
+	}
+	c, err := client.New(creds.Server)
+	if err != nil {
+		return nil, err
+	}
+	return c.WithToken(creds.Token).WithClientVersion("noture-cli", version), nil
+}
+
+// stateDir holds a synced directory's state. It is never synced itself.
+const stateDir = ".noture"
+
+// dirState is what a synced directory remembers between runs. Files maps
+// each path to its content hash when local and remote last agreed, which
+// is the base sync compares both sides with.
+type dirState struct {
+	WorkspaceID uuid.UUID         `json:"workspace_id"`
+	LastEventID int64             `json:"last_event_id"`
+	Files       map[string]string `json:"files"`
+}
+
+func statePath(root string) string {
+	return filepath.Join(root, stateDir, "state.json")
+}
+
+// loadState reads root's state. A directory that was never synced has
+// none and gets an empty state for workspaceID, which must then be set.
+// A workspaceID that differs from the recorded one is refused, since the
+// recorded bases belong to the other workspace.
+func loadState(root string, workspaceID uuid.UUID) (*dirState, error) {
+	data, err := os.ReadFile(statePath(root))
+	if errors.Is(err, os.ErrNotExist) {
+		if workspaceID == uuid.Nil {
+			return nil, fmt.Errorf("%s is not synced yet; pass -workspace", root)
+		}
+		return &dirState{WorkspaceID: workspaceID, Files: map[string]string{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	var state dirState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", statePath(root), err)
+	}
+	if workspaceID != uuid.Nil && workspaceID != state.WorkspaceID {
+		return nil, fmt.Errorf("%s is synced with workspace %s", root, state.WorkspaceID)
+	}
+	if state.Files == nil {
+		state.Files = map[string]string{}
+	}
+	return &state, nil
+}
+
+func saveState(root string, state *dirState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(statePath(root), data, 0o644)
+}
+
+// writeFileAtomic replaces path through a temporary file so that readers
+// and crashes never see it half written.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
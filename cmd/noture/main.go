@@ -0,0 +1,204 @@
+// Command noture syncs a local directory of notes with a noture workspace.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/duckonomy/noture/pkg/client"
+	"github.com/google/uuid"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+const usage = `usage: noture <command> [flags] [dir]
+
+commands:
+  login        sign this device in with the device flow
+  workspaces   list the workspaces you can sync
+  push         upload local changes to the workspace
+  pull         download the workspace's changes
+  sync         push and pull; with -watch, keep syncing as files change
+
+push, pull and sync work on dir, the current directory by default. The
+first run in a directory needs -workspace; the workspace and the sync
+state are then kept in dir/.noture. Dotfiles are not synced.
+
+A file changed on one side only is copied to the other, deletions
+included. When both sides changed it, the local file is kept and the
+workspace's version is saved beside it as name.conflict-<hash>.ext;
+the workspace also keeps every version a push replaces.
+
+NOTURE_SERVER and NOTURE_TOKEN override the credentials saved by login.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	command, args := os.Args[1], os.Args[2:]
+	switch command {
+	case "login":
+		err = runLogin(ctx, args)
+	case "workspaces":
+		err = runWorkspaces(ctx, args)
+	case "push":
+		err = runSync(ctx, "push", push, args)
+	case "pull":
+		err = runSync(ctx, "pull", pull, args)
+	case "sync":
+		err = runSync(ctx, "sync", both, args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return
+	case "version":
+		fmt.Println("noture", version)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "noture: unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "noture:", err)
+		os.Exit(1)
+	}
+}
+
+func runLogin(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	server := fs.String("server", "", "server URL, e.g. https://notes.example.com")
+	hostname, _ := os.Hostname()
+	deviceName := fs.String("device", hostname, "name shown in your device list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+	if *server != "" {
+		creds.Server = *server
+	}
+	if creds.Server == "" {
+		return errors.New("no server; pass -server")
+	}
+	c, err := client.New(creds.Server)
+	if err != nil {
+		return err
+	}
+	c = c.WithClientVersion("noture-cli", version)
+
+	auth, err := c.StartDeviceAuth(ctx, *deviceName)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Open %s and enter the code %s\n", auth.VerificationURL, auth.UserCode)
+	fmt.Println("Waiting for sign-in...")
+
+	token, err := c.WaitForDeviceToken(ctx, auth)
+	if err != nil {
+		return err
+	}
+	creds.Token = token.Token
+	if err := saveCredentials(creds); err != nil {
+		return fmt.Errorf("failed to save credentials: %w", err)
+	}
+	fmt.Println("Signed in.")
+	return nil
+}
+
+func runWorkspaces(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("workspaces", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	workspaces, err := c.ListWorkspaces(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ws := range workspaces {
+		fmt.Printf("%s  %-8s %6d files  %s\n", ws.ID, ws.Role, ws.FileCount, ws.Name)
+	}
+	return nil
+}
+
+func runSync(ctx context.Context, name string, dir direction, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	workspace := fs.String("workspace", "", "workspace ID to sync with; needed on the first run in a directory")
+	var watch *bool
+	var interval *time.Duration
+	if dir == both {
+		watch = fs.Bool("watch", false, "keep running and sync whenever either side changes")
+		interval = fs.Duration("interval", 10*time.Second, "how often -watch checks the workspace for changes")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("%s takes one directory", name)
+	}
+
+	root := "."
+	if fs.NArg() == 1 {
+		root = fs.Arg(0)
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", root)
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	var workspaceID uuid.UUID
+	if *workspace != "" {
+		if workspaceID, err = uuid.Parse(*workspace); err != nil {
+			return fmt.Errorf("invalid -workspace: %w", err)
+		}
+	}
+	state, err := loadState(root, workspaceID)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	s := &syncer{client: c, root: root, state: state, out: os.Stdout}
+	if watch != nil && *watch {
+		fmt.Printf("Watching %s, press Ctrl-C to stop.\n", root)
+		return s.watch(ctx, *interval)
+	}
+	changed, err := s.run(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if changed == 0 {
+		fmt.Println("Up to date.")
+	}
+	return nil
+}
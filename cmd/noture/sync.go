@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/duckonomy/noture/pkg/client"
+)
+
+// direction says which side a run may change: push changes the
+// workspace, pull the directory, sync both.
+type direction int
+
+const (
+	push direction = 1 << iota
+	pull
+	both = push | pull
+)
+
+type actionKind int
+
+const (
+	// actionRecord remembers a hash both sides already have as the base.
+	actionRecord actionKind = iota
+	// actionForget drops the base of a file gone from both sides.
+	actionForget
+	actionUpload
+	actionDownload
+	actionDeleteRemote
+	actionDeleteLocal
+	// actionConflictCopy saves the remote version next to the local file,
+	// which is kept.
+	actionConflictCopy
+)
+
+type action struct {
+	kind actionKind
+	path string
+	hash string
+}
+
+// plan compares the local and remote hash of every path with its base,
+// the hash both sides had when they last agreed, and decides what to do.
+// A missing path has the empty hash. A side that still has the base is
+// unchanged, so the other side's change is copied over it, deletions
+// included. When both sides changed, a deletion loses to the edit, and two
+// edits keep the local file with the remote version saved as a conflict
+// copy beside it; the workspace keeps every version it replaces. Actions
+// that would change a side dir does not allow are left out.
+func plan(local, remote, base map[string]string, dir direction) []action {
+	paths := make(map[string]struct{}, len(local)+len(remote)+len(base))
+	for _, m := range []map[string]string{local, remote, base} {
+		for p := range m {
+			paths[p] = struct{}{}
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	slices.Sort(sorted)
+
+	var actions []action
+	add := func(allowed direction, kind actionKind, p, hash string) {
+		if dir&allowed != 0 {
+			actions = append(actions, action{kind: kind, path: p, hash: hash})
+		}
+	}
+	for _, p := range sorted {
+		l, r, b := local[p], remote[p], base[p]
+		switch {
+		case l == r && l == "":
+			add(both, actionForget, p, "")
+		case l == r:
+			if b != l {
+				add(both, actionRecord, p, l)
+			}
+		case l == b && r == "":
+			add(pull, actionDeleteLocal, p, "")
+		case r == b && l == "":
+			add(push, actionDeleteRemote, p, "")
+		case l == b || l == "":
+			add(pull, actionDownload, p, r)
+		case r == b || r == "":
+			add(push, actionUpload, p, l)
+		default:
+			add(pull, actionConflictCopy, p, r)
+			add(push, actionUpload, p, l)
+		}
+	}
+	return actions
+}
+
+// conflictPath names the copy of a conflicting remote version, e.g.
+// notes/todo.conflict-1a2b3c4d.md.
+func conflictPath(p, hash string) string {
+	ext := path.Ext(p)
+	return strings.TrimSuffix(p, ext) + ".conflict-" + hash[:min(8, len(hash))] + ext
+}
+
+// scanLocal hashes every file below root, keyed by slash-separated path.
+// Dotfiles and dot directories, the state directory among them, are not
+// synced.
+func scanLocal(root string) (map[string]string, error) {
+	files := map[string]string{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		if ignored(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = client.ContentHash(content)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+	return files, nil
+}
+
+func ignored(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// syncable reports whether a remote path can be synced: it stays below
+// the root and no part of it is ignored locally.
+func syncable(p string) bool {
+	if !filepath.IsLocal(filepath.FromSlash(p)) {
+		return false
+	}
+	for _, name := range strings.Split(p, "/") {
+		if ignored(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// syncer reconciles one directory with its workspace.
+type syncer struct {
+	client *client.Client
+	root   string
+	state  *dirState
+	out    io.Writer
+
+	// scanned holds the local hashes the current pass planned with.
+	scanned map[string]string
+}
+
+// run makes one pass in dir and saves the state, also after a failed
+// action so that the actions before it are not repeated. It returns how
+// many files it changed.
+func (s *syncer) run(ctx context.Context, dir direction) (int, error) {
+	local, err := scanLocal(s.root)
+	if err != nil {
+		return 0, err
+	}
+	manifest, err := s.client.GetManifest(ctx, s.state.WorkspaceID, client.ManifestOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	remote := make(map[string]string, len(manifest.Files))
+	for _, entry := range manifest.Files {
+		if syncable(entry.Path) {
+			remote[entry.Path] = entry.ContentHash
+		}
+	}
+
+	s.scanned = local
+	changed := 0
+	for _, a := range plan(local, remote, s.state.Files, dir) {
+		if err = s.apply(ctx, a); err != nil {
+			break
+		}
+		if a.kind != actionRecord && a.kind != actionForget {
+			changed++
+		}
+	}
+	if saveErr := saveState(s.root, s.state); saveErr != nil && err == nil {
+		err = fmt.Errorf("failed to save sync state: %w", saveErr)
+	}
+	return changed, err
+}
+
+func (s *syncer) apply(ctx context.Context, a action) error {
+	workspaceID := s.state.WorkspaceID
+	switch a.kind {
+	case actionRecord:
+		s.state.Files[a.path] = a.hash
+	case actionForget:
+		delete(s.state.Files, a.path)
+	case actionUpload:
+		content, err := os.ReadFile(s.localPath(a.path))
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(s.localPath(a.path))
+		if err != nil {
+			return err
+		}
+		result, err := s.client.Upload(ctx, client.Upload{
+			WorkspaceID:  workspaceID,
+			FilePath:     a.path,
+			Content:      content,
+			LastModified: info.ModTime(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %w", a.path, err)
+		}
+		s.state.Files[a.path] = result.ContentHash
+		s.report("uploaded", a.path)
+	case actionDeleteRemote:
+		if err := s.client.DeleteFile(ctx, workspaceID, a.path); err != nil && !client.IsNotFound(err) {
+			return fmt.Errorf("failed to delete %s: %w", a.path, err)
+		}
+		delete(s.state.Files, a.path)
+		s.report("deleted", a.path+" (remote)")
+	case actionDownload:
+		if !s.localUnchanged(a.path) {
+			return nil
+		}
+		hash, err := s.download(ctx, a.path, a.path)
+		if err != nil {
+			return err
+		}
+		s.state.Files[a.path] = hash
+		s.report("downloaded", a.path)
+	case actionDeleteLocal:
+		if !s.localUnchanged(a.path) {
+			return nil
+		}
+		if err := os.Remove(s.localPath(a.path)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		delete(s.state.Files, a.path)
+		s.report("deleted", a.path+" (local)")
+	case actionConflictCopy:
+		copyPath := conflictPath(a.path, a.hash)
+		if _, err := s.download(ctx, a.path, copyPath); err != nil {
+			return err
+		}
+		s.report("conflict", a.path+": remote version saved as "+copyPath)
+	}
+	return nil
+}
+
+// localUnchanged reports whether the local file is as the pass found it,
+// so that edits made while it runs are not overwritten. They are picked
+// up by the next pass.
+func (s *syncer) localUnchanged(p string) bool {
+	content, err := os.ReadFile(s.localPath(p))
+	if os.IsNotExist(err) {
+		return s.scanned[p] == ""
+	}
+	return err == nil && client.ContentHash(content) == s.scanned[p]
+}
+
+func (s *syncer) report(verb, detail string) {
+	fmt.Fprintf(s.out, "%-10s %s\n", verb, detail)
+}
+
+// download writes the remote file at p to the local path dest and returns
+// the hash of what it wrote.
+func (s *syncer) download(ctx context.Context, p, dest string) (string, error) {
+	body, err := s.client.Download(ctx, s.state.WorkspaceID, p)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", p, err)
+	}
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", p, err)
+	}
+	if err := writeFileAtomic(s.localPath(dest), content, 0o644); err != nil {
+		return "", err
+	}
+	return client.ContentHash(content), nil
+}
+
+func (s *syncer) localPath(p string) string {
+	return filepath.Join(s.root, filepath.FromSlash(p))
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/duckonomy/noture/pkg/client"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name                string
+		local, remote, base string
+		dir                 direction
+		want                []action
+	}{
+		{name: "unchanged", local: "a", remote: "a", base: "a", dir: both},
+		{name: "same on both sides", local: "b", remote: "b", base: "a", dir: both, want: []action{{actionRecord, "f", "b"}}},
+		{name: "gone on both sides", base: "a", dir: both, want: []action{{actionForget, "f", ""}}},
+		{name: "new local file", local: "a", dir: both, want: []action{{actionUpload, "f", "a"}}},
+		{name: "new remote file", remote: "a", dir: both, want: []action{{actionDownload, "f", "a"}}},
+		{name: "local edit", local: "b", remote: "a", base: "a", dir: both, want: []action{{actionUpload, "f", "b"}}},
+		{name: "remote edit", local: "a", remote: "b", base: "a", dir: both, want: []action{{actionDownload, "f", "b"}}},
+		{name: "local delete", remote: "a", base: "a", dir: both, want: []action{{actionDeleteRemote, "f", ""}}},
+		{name: "remote delete", local: "a", base: "a", dir: both, want: []action{{actionDeleteLocal, "f", ""}}},
+		{name: "local delete, remote edit", remote: "b", base: "a", dir: both, want: []action{{actionDownload, "f", "b"}}},
+		{name: "remote delete, local edit", local: "b", base: "a", dir: both, want: []action{{actionUpload, "f", "b"}}},
+		{name: "both edited", local: "b", remote: "c", base: "a", dir: both, want: []action{{actionConflictCopy, "f", "c"}, {actionUpload, "f", "b"}}},
+		{name: "both created", local: "b", remote: "c", dir: both, want: []action{{actionConflictCopy, "f", "c"}, {actionUpload, "f", "b"}}},
+		{name: "push skips remote edit", local: "a", remote: "b", base: "a", dir: push},
+		{name: "push overwrites conflict", local: "b", remote: "c", base: "a", dir: push, want: []action{{actionUpload, "f", "b"}}},
+		{name: "pull skips local edit", local: "b", remote: "a", base: "a", dir: pull},
+		{name: "pull keeps local file in conflict", local: "b", remote: "c", base: "a", dir: pull, want: []action{{actionConflictCopy, "f", "c"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hashes := func(h string) map[string]string {
+				if h == "" {
+					return map[string]string{}
+				}
+				return map[string]string{"f": h}
+			}
+			got := plan(hashes(tt.local), hashes(tt.remote), hashes(tt.base), tt.dir)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSyncable(t *testing.T) {
+	assert.True(t, syncable("notes/todo.md"))
+	assert.False(t, syncable("../outside.md"))
+	assert.False(t, syncable("/etc/passwd"))
+	assert.False(t, syncable("notes/.obsidian/app.json"))
+	assert.False(t, syncable(".noture/state.json"))
+}
+
+func TestConflictPath(t *testing.T) {
+	assert.Equal(t, "notes/todo.conflict-1a2b3c4d.md", conflictPath("notes/todo.md", "1a2b3c4d5e6f"))
+	assert.Equal(t, "Makefile.conflict-1a2b3c4d", conflictPath("Makefile", "1a2b3c4d5e6f"))
+}
+
+// fakeWorkspace serves the file and manifest routes sync uses from memory.
+type fakeWorkspace struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func newFakeServer(t *testing.T, ws *fakeWorkspace) *client.Client {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/workspaces/{workspace_id}/manifest", func(w http.ResponseWriter, r *http.Request) {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		var manifest client.Manifest
+		for p, content := range ws.files {
+			manifest.Files = append(manifest.Files, client.ManifestEntry{Path: p, ContentHash: client.ContentHash(content)})
+		}
+		json.NewEncoder(w).Encode(manifest)
+	})
+	mux.HandleFunc("POST /api/files/upload", func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("file")
+		require.NoError(t, err)
+		content, _ := io.ReadAll(file)
+		ws.mu.Lock()
+		ws.files[r.FormValue("file_path")] = content
+		ws.mu.Unlock()
+		var result client.UploadResult
+		result.FilePath = r.FormValue("file_path")
+		result.ContentHash = client.ContentHash(content)
+		json.NewEncoder(w).Encode(result)
+	})
+	mux.HandleFunc("GET /api/files/{workspace_id}/{file_path...}", func(w http.ResponseWriter, r *http.Request) {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		content, ok := ws.files[r.PathValue("file_path")]
+		if !ok {
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		}
+		w.Write(content)
+	})
+	mux.HandleFunc("DELETE /api/files/{workspace_id}/{file_path...}", func(w http.ResponseWriter, r *http.Request) {
+		ws.mu.Lock()
+		delete(ws.files, r.PathValue("file_path"))
+		ws.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	c, err := client.New(server.URL)
+	require.NoError(t, err)
+	return c.WithToken("secret")
+}
+
+func TestSyncer_Run(t *testing.T) {
+	root := t.TempDir()
+	ws := &fakeWorkspace{files: map[string][]byte{
+		"remote.md":      []byte("from the server"),
+		"shared/todo.md": []byte("- [ ] server"),
+	}}
+	c := newFakeServer(t, ws)
+	write := func(p, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(root, p)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, p), []byte(content), 0o644))
+	}
+	read := func(p string) string {
+		content, err := os.ReadFile(filepath.Join(root, p))
+		require.NoError(t, err)
+		return string(content)
+	}
+	write("local.md", "from the laptop")
+	write("shared/todo.md", "- [ ] laptop")
+	write(".hidden", "not synced")
+
+	state, err := loadState(root, uuid.New())
+	require.NoError(t, err)
+	s := &syncer{client: c, root: root, state: state, out: io.Discard}
+
+	changed, err := s.run(t.Context(), both)
+	require.NoError(t, err)
+	assert.Equal(t, 4, changed)
+	assert.Equal(t, "from the server", read("remote.md"))
+	assert.Equal(t, "- [ ] server", read(conflictPath("shared/todo.md", client.ContentHash([]byte("- [ ] server")))))
+	assert.Equal(t, "- [ ] laptop", read("shared/todo.md"))
+	assert.Equal(t, "from the laptop", string(ws.files["local.md"]))
+	assert.Equal(t, "- [ ] laptop", string(ws.files["shared/todo.md"]))
+	assert.NotContains(t, ws.files, ".hidden")
+
+	// The conflict copy is a new local file and goes up on the next pass.
+	changed, err = s.run(t.Context(), both)
+	require.NoError(t, err)
+	assert.Equal(t, 1, changed)
+
+	// Deletions travel both ways once both sides have agreed on a file.
+	require.NoError(t, os.Remove(filepath.Join(root, "local.md")))
+	delete(ws.files, "remote.md")
+	_, err = s.run(t.Context(), both)
+	require.NoError(t, err)
+	assert.NotContains(t, ws.files, "local.md")
+	assert.NoFileExists(t, filepath.Join(root, "remote.md"))
+
+	// The state survives the run and leaves nothing to do.
+	state, err = loadState(root, uuid.Nil)
+	require.NoError(t, err)
+	s.state = state
+	changed, err = s.run(t.Context(), both)
+	require.NoError(t, err)
+	assert.Zero(t, changed)
+
+	_, err = loadState(root, uuid.New())
+	assert.ErrorContains(t, err, "is synced with workspace")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/duckonomy/noture/pkg/client"
+	"github.com/fsnotify/fsnotify"
+)
+
+// settleDelay is how long the directory must be quiet before a pass, so
+// that an editor saving several files, or one file in steps, causes one.
+const settleDelay = 500 * time.Millisecond
+
+var fileEvents = client.EventOptions{Types: []string{"file.*"}}
+
+// watch syncs, then syncs again whenever files below the root change or
+// the workspace's change feed, polled every interval, has new file events.
+// Failed passes are reported and retried on the next change or poll. It
+// returns when ctx is done.
+func (s *syncer) watch(ctx context.Context, interval time.Duration) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", s.root, err)
+	}
+	defer watcher.Close()
+	if err := addWatches(watcher, s.root); err != nil {
+		return err
+	}
+
+	// The first pass compares every file, so the feed's history is skipped.
+	last, err := s.client.Changes(ctx, s.state.WorkspaceID, s.state.LastEventID, fileEvents, func(client.Event) error { return nil })
+	if err != nil {
+		return fmt.Errorf("failed to read change feed: %w", err)
+	}
+	s.state.LastEventID = last
+	s.pass(ctx)
+
+	settle := time.NewTimer(settleDelay)
+	settle.Stop()
+	poll := time.NewTicker(interval)
+	defer poll.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if ignored(filepath.Base(event.Name)) {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := addWatches(watcher, event.Name); err != nil {
+						s.report("error", err.Error())
+					}
+				}
+			}
+			settle.Reset(settleDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			s.report("error", err.Error())
+		case <-poll.C:
+			events := 0
+			last, err := s.client.Changes(ctx, s.state.WorkspaceID, s.state.LastEventID, fileEvents, func(client.Event) error {
+				events++
+				return nil
+			})
+			s.state.LastEventID = last
+			if err != nil {
+				s.report("error", fmt.Sprintf("failed to read change feed: %v", err))
+			}
+			if events > 0 {
+				s.pass(ctx)
+			}
+		case <-settle.C:
+			s.pass(ctx)
+		}
+	}
+}
+
+// pass runs one sync pass in both directions and reports its outcome.
+func (s *syncer) pass(ctx context.Context) {
+	changed, err := s.run(ctx, both)
+	if err != nil && ctx.Err() == nil {
+		s.report("error", err.Error())
+		return
+	}
+	if changed > 0 {
+		s.report("synced", fmt.Sprintf("%d file(s) at %s", changed, time.Now().Format(time.TimeOnly)))
+	}
+}
+
+// addWatches watches dir and every directory below it that is synced;
+// fsnotify does not watch recursively.
+func addWatches(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != dir && ignored(d.Name()) {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(p); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", p, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,10 @@
+// Package docs embeds the API description served by the server.
+package docs
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI 3 description of every /api and /auth route, in
+// YAML. It is maintained by hand alongside the handlers.
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
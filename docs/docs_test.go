@@ -0,0 +1,62 @@
+package docs
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+var routePattern = regexp.MustCompile(`"(GET|POST|PUT|PATCH|DELETE) (/(?:api|auth)/[^"]*)"`)
+
+// TestOpenAPI_DocumentsEveryRoute keeps the description in step with the
+// routes registered in main.go and the handlers.
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	var spec struct {
+		OpenAPI string                          `yaml:"openapi"`
+		Paths   map[string]map[string]yaml.Node `yaml:"paths"`
+	}
+	require.NoError(t, yaml.Unmarshal(OpenAPI, &spec))
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+
+	sources, err := filepath.Glob("../internal/api/*.go")
+	require.NoError(t, err)
+	sources = append(sources, "../main.go")
+
+	routes := 0
+	for _, source := range sources {
+		if strings.HasSuffix(source, "_test.go") {
+			continue
+		}
+		content, err := os.ReadFile(source)
+		require.NoError(t, err)
+		for _, match := range routePattern.FindAllStringSubmatch(string(content), -1) {
+			routes++
+			method, route := strings.ToLower(match[1]), match[2]
+			assert.True(t, documented(spec.Paths, method, route), "%s %s is not in openapi.yaml", match[1], route)
+		}
+	}
+	assert.NotZero(t, routes)
+}
+
+// documented matches a mux pattern against the spec's paths. A trailing
+// {name...} wildcard is written {name} in the spec and may be followed by
+// more segments, as in POST /api/files/{workspace_id}/{file_path}/share.
+func documented(paths map[string]map[string]yaml.Node, method, route string) bool {
+	wildcard := strings.HasSuffix(route, "...}")
+	route = strings.ReplaceAll(route, "...}", "}")
+	for path, operations := range paths {
+		if _, ok := operations[method]; !ok {
+			continue
+		}
+		if path == route || (wildcard && strings.HasPrefix(path, route+"/")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/pkg/logger"
+)
+
+type AccountHandler struct {
+	userService    *services.UserService
+	accountService *services.AccountService
+	log            *logger.Logger
+}
+
+func NewAccountHandler(userService *services.UserService, accountService *services.AccountService) *AccountHandler {
+	return &AccountHandler{
+		userService:    userService,
+		accountService: accountService,
+		log:            logger.New(),
+	}
+}
+
+// DeleteAccount schedules the caller's account for deletion and signs it
+// out everywhere. The body must repeat the account's email.
+func (h *AccountHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	var req domain.DeleteAccountRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	deletion, err := h.userService.DeleteAccount(r.Context(), authCtx.UserID, req.Email)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to delete account", "user_id", authCtx.UserID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(deletion)
+}
+
+// RestoreAccount cancels the caller's scheduled account deletion.
+func (h *AccountHandler) RestoreAccount(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.RestoreAccount(r.Context(), authCtx.UserID); err != nil {
+		if err.Error() == "account deletion not scheduled" {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to restore account", "user_id", authCtx.UserID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ExportAccount streams a zip archive of everything the caller's account
+// holds.
+func (h *AccountHandler) ExportAccount(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	filename := fmt.Sprintf("noture-export-%s.zip", time.Now().UTC().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if err := h.accountService.ExportAccount(r.Context(), authCtx.UserID, w); err != nil {
+		h.log.WithContext(r.Context()).WithError(err).Error("Account export failed", "user_id", authCtx.UserID)
+	}
+}
+
+// GetPreferences returns the settings the caller's clients share.
+func (h *AccountHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	prefs, err := h.userService.GetPreferences(r.Context(), authCtx.UserID)
+	if err != nil {
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to get preferences", "user_id", authCtx.UserID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+// UpdatePreferences changes the fields of the caller's preferences that
+// the body sets.
+func (h *AccountHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	var patch domain.PreferencesPatch
+	if !decodeRequest(w, http.MaxBytesReader(w, r.Body, 2*domain.MaxEditorPreferencesBytes), &patch) {
+		return
+	}
+
+	prefs, err := h.userService.UpdatePreferences(r.Context(), authCtx.UserID, patch)
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.HasPrefix(err.Error(), "workspace not found"):
+			http.Error(w, "Workspace not found", http.StatusNotFound)
+		default:
+			h.log.WithContext(r.Context()).WithError(err).Error("Failed to update preferences", "user_id", authCtx.UserID)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+func (h *AccountHandler) RegisterRoutes(r *Router) {
+	r.Account("DELETE /api/account", h.DeleteAccount)
+	r.Account("POST /api/account/restore", h.RestoreAccount)
+	r.Account("GET /api/account/export", h.ExportAccount)
+	r.User("GET /api/account/preferences", h.GetPreferences)
+	r.User("PATCH /api/account/preferences", h.UpdatePreferences)
+}
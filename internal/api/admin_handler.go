@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/loadshed"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/internal/slo"
+	"github.com/google/uuid"
+)
+
+type AdminHandler struct {
+	adminService     *services.AdminService
+	retentionService *services.RetentionService
+	sloTracker       *slo.Tracker
+	clientGate       *ClientGate
+	shedder          *loadshed.Shedder
+}
+
+func NewAdminHandler(adminService *services.AdminService, retentionService *services.RetentionService, sloTracker *slo.Tracker, clientGate *ClientGate, shedder *loadshed.Shedder) *AdminHandler {
+	return &AdminHandler{
+		adminService:     adminService,
+		retentionService: retentionService,
+		sloTracker:       sloTracker,
+		clientGate:       clientGate,
+		shedder:          shedder,
+	}
+}
+
+// TableGrowth reports table sizes and retained sync operations so operators
+// can see whether the retention policy keeps the hot tables small.
+func (h *AdminHandler) TableGrowth(w http.ResponseWriter, r *http.Request) {
+	report, err := h.retentionService.TableGrowth(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// SLO reports per-endpoint success rates, latency percentiles and error
+// budget burn over the last hour.
+func (h *AdminHandler) SLO(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.sloTracker.Report())
+}
+
+// Retries reports how often each database operation was retried after a
+// transient error, and how often it gave up.
+func (h *AdminHandler) Retries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(services.DBRetries.Snapshot())
+}
+
+// Compression reports bytes before and after content coding, in both
+// directions, since the server started.
+func (h *AdminHandler) Compression(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CompressionStats.Snapshot())
+}
+
+// Clients reports the configured minimum client versions and how many
+// requests each client version made, and how many were turned away, since
+// the server started.
+func (h *AdminHandler) Clients(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"minimum_versions": h.clientGate.Minimums(),
+		"versions":         h.clientGate.Distribution(),
+	})
+}
+
+// Load reports the load shedding signals against their thresholds, and
+// how many requests were shed for each reason since the server started.
+func (h *AdminHandler) Load(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.shedder.Status())
+}
+
+// ListUsers pages through users by email with their workspace usage. q
+// narrows the list to emails starting with it.
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.adminService.ListUsers(r.Context(), query.Get("after"), query.Get("q"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID format", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.adminService.GetUser(r.Context(), userID)
+	if err != nil {
+		writeAdminUserError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// UpdateUser changes a user's tier, storage limit, admin role or whether
+// the account is disabled.
+func (h *AdminHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID format", http.StatusBadRequest)
+		return
+	}
+
+	var update domain.AdminUserUpdate
+	if !decodeRequest(w, r.Body, &update) {
+		return
+	}
+
+	user, err := h.adminService.UpdateUser(r.Context(), authCtx.UserID, userID, update)
+	if err != nil {
+		writeAdminUserError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func writeAdminUserError(w http.ResponseWriter, err error) {
+	switch {
+	case err.Error() == "user not found":
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case strings.HasPrefix(err.Error(), "invalid"):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *AdminHandler) RegisterRoutes(r *Router) {
+	r.Admin("GET /api/admin/tables", h.TableGrowth)
+	r.Admin("GET /api/admin/slo", h.SLO)
+	r.Admin("GET /api/admin/retries", h.Retries)
+	r.Admin("GET /api/admin/compression", h.Compression)
+	r.Admin("GET /api/admin/clients", h.Clients)
+	r.Admin("GET /api/admin/load", h.Load)
+	r.Admin("GET /api/admin/users", h.ListUsers)
+	r.Admin("GET /api/admin/users/{id}", h.GetUser)
+	r.Admin("PATCH /api/admin/users/{id}", h.UpdateUser)
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminEmails_RequireVerifiedEmail(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	ctx := context.Background()
+
+	// Anyone can register an admin address that has not signed up yet.
+	user, err := services.NewUserService(testDB.Queries()).Register(ctx, "ops@example.com", "correct horse battery")
+	require.NoError(t, err)
+	token, err := services.NewTokenService(testDB.Queries()).CreateToken(ctx, user.ID, domain.CreateTokenRequest{Name: "cli"})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	router := NewRouter(mux, auth.NewAuthMiddleware(testDB.Queries(), []string{"ops@example.com"}), NewCapabilities("test"))
+	NewAdminHandler(services.NewAdminService(testDB.Queries(), testDB.Conn()), nil, nil, nil, nil).RegisterRoutes(router)
+
+	listUsers := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/users", nil)
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, listUsers())
+
+	_, err = testDB.Queries().MarkEmailVerified(ctx, db.MarkEmailVerifiedParams{ID: pgconv.UUIDToPg(user.ID), Email: user.Email})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, listUsers())
+}
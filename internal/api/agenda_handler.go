@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/google/uuid"
+)
+
+type AgendaHandler struct {
+	agendaService *services.AgendaService
+}
+
+func NewAgendaHandler(agendaService *services.AgendaService) *AgendaHandler {
+	return &AgendaHandler{
+		agendaService: agendaService,
+	}
+}
+
+// GetAgenda returns the scheduled items, deadlines and timestamps of the
+// workspace's notes between the from and to query parameters, by day.
+func (h *AgendaHandler) GetAgenda(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	agenda, err := h.agendaService.GetAgenda(r.Context(), workspaceID, authCtx.UserID, query.Get("from"), query.Get("to"))
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, "Workspace not found", status)
+			return
+		}
+		switch {
+		case strings.HasPrefix(err.Error(), "workspace not found"):
+			http.Error(w, "Workspace not found", http.StatusNotFound)
+		case strings.HasPrefix(err.Error(), "invalid"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(agenda)
+}
+
+func (h *AgendaHandler) RegisterRoutes(r *Router) {
+	r.User("GET /api/workspaces/{id}/agenda", h.GetAgenda)
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/auth"
+)
+
+// requireAuthContext returns the caller set by the auth middleware. A
+// route registered without RequireAuth gets a 401 here rather than a
+// panic, and the handler should return when ok is false.
+func requireAuthContext(w http.ResponseWriter, r *http.Request) (authCtx *domain.AuthContext, ok bool) {
+	authCtx, ok = auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+	}
+	return authCtx, ok
+}
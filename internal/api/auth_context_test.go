@@ -0,0 +1,19 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerWithoutAuthMiddleware(t *testing.T) {
+	handler := NewDeviceHandler(nil)
+	recorder := httptest.NewRecorder()
+
+	assert.NotPanics(t, func() {
+		handler.ListDevices(recorder, httptest.NewRequest(http.MethodGet, "/api/devices", nil))
+	})
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/google/uuid"
+)
+
+type AuthHandler struct {
+	userService   *services.UserService
+	deviceService *services.DeviceService
+	passkeys      *services.PasskeyService
+	queries       *db.Queries
+	guard         loginGuard
+	log           *logger.Logger
+}
+
+// PasswordAuthRequest logs in with an email and password. A client that
+// sends a device_name is registered as a device, and the token is revoked
+// with it.
+type PasswordAuthRequest struct {
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	DeviceName string `json:"device_name,omitempty"`
+}
+
+func NewAuthHandler(userService *services.UserService, deviceService *services.DeviceService, queries *db.Queries) *AuthHandler {
+	return &AuthHandler{
+		userService:   userService,
+		deviceService: deviceService,
+		queries:       queries,
+		log:           logger.New(),
+	}
+}
+
+// WithPasskeys makes password logins of accounts with a passkey wait
+// for it as a second factor.
+func (h *AuthHandler) WithPasskeys(passkeys *services.PasskeyService) *AuthHandler {
+	h.passkeys = passkeys
+	return h
+}
+
+// WithThrottle makes accounts and client IPs that keep failing to log in
+// wait before trying again. clientIP tells the client's IP from a
+// request; nil uses the connection's address.
+func (h *AuthHandler) WithThrottle(throttle *services.LoginThrottle, clientIP func(*http.Request) string) *AuthHandler {
+	h.guard = newLoginGuard(throttle, clientIP)
+	return h
+}
+
+// PasswordResetRequest asks for a reset code to be emailed to Email.
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+// PasswordResetConfirmRequest sets a new password with an emailed code.
+type PasswordResetConfirmRequest struct {
+	Code        string `json:"code"`
+	NewPassword string `json:"new_password"`
+}
+
+func (h *AuthHandler) RegisterRoutes(r *Router) {
+	r.Public("POST /auth/register", h.Register)
+	r.Public("POST /auth/login", h.Login)
+	r.Public("POST /auth/password-reset", h.RequestPasswordReset)
+	r.Public("POST /auth/password-reset/confirm", h.ResetPassword)
+	r.Public("GET /auth/verify-email", h.VerifyEmail)
+	r.User("POST /auth/verify-email/resend", h.ResendVerification)
+}
+
+func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var req PasswordAuthRequest
+	if !decodeRequest(w, http.MaxBytesReader(w, r.Body, 1<<16), &req) {
+		return
+	}
+
+	user, err := h.userService.Register(r.Context(), req.Email, req.Password)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "already registered"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case strings.Contains(err.Error(), "invalid email"), strings.Contains(err.Error(), "password must"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			h.log.WithContext(r.Context()).WithError(err).Error("Failed to register user")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.sendToken(w, r, user, req.DeviceName, "Password Login", http.StatusCreated, "Registration successful")
+}
+
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req PasswordAuthRequest
+	if !decodeRequest(w, http.MaxBytesReader(w, r.Body, 1<<16), &req) {
+		return
+	}
+
+	keys := h.guard.keys(r, req.Email)
+	if h.guard.blocked(w, r, keys) {
+		return
+	}
+
+	user, err := h.userService.Authenticate(r.Context(), req.Email, req.Password)
+	if err != nil {
+		h.log.WithContext(r.Context()).Warn("Password login failed", "email", req.Email)
+		h.guard.fail(r, "password", keys)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	h.guard.succeed(r, req.Email)
+
+	if h.passkeys != nil {
+		has, err := h.passkeys.HasPasskeys(r.Context(), user.ID)
+		if err != nil {
+			h.log.WithContext(r.Context()).WithError(err).Error("Failed to check passkeys", "user_id", user.ID)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if has {
+			h.requireSecondFactor(w, r, user)
+			return
+		}
+	}
+
+	h.log.WithContext(r.Context()).LogAuthEvent("login_success", user.ID.String(), "password")
+	h.sendToken(w, r, user, req.DeviceName, "Password Login", http.StatusOK, "Authentication successful")
+}
+
+// requireSecondFactor answers a correct password of an account with a
+// passkey. The client signs the returned options with one of the
+// account's passkeys and sends the result to POST /auth/passkey, which
+// then issues the token. Clients unaware of this see a failed login.
+func (h *AuthHandler) requireSecondFactor(w http.ResponseWriter, r *http.Request, user *domain.User) {
+	options, err := h.passkeys.BeginSecondFactor(r.Context(), user.ID)
+	if err != nil {
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to start second factor", "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":       false,
+		"message":       "second factor required",
+		"second_factor": "passkey",
+		"options":       options,
+	})
+}
+
+// RequestPasswordReset emails a reset code. It answers the same whether
+// or not the address is registered.
+func (h *AuthHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetRequest
+	if !decodeRequest(w, http.MaxBytesReader(w, r.Body, 1<<16), &req) {
+		return
+	}
+
+	if err := h.userService.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to request password reset")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword sets a new password with an emailed code and signs the
+// account out everywhere.
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetConfirmRequest
+	if !decodeRequest(w, http.MaxBytesReader(w, r.Body, 1<<16), &req) {
+		return
+	}
+
+	if err := h.userService.ResetPassword(r.Context(), req.Code, req.NewPassword); err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid"), strings.HasPrefix(err.Error(), "password must"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			h.log.WithContext(r.Context()).WithError(err).Error("Failed to reset password")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail is where emailed verification links lead. It is opened in
+// a browser, so it answers in plain text.
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := h.userService.VerifyEmail(r.Context(), r.URL.Query().Get("token")); err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			http.Error(w, "This verification link is invalid or has expired. Sign in to request a new one.", http.StatusBadRequest)
+			return
+		}
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to verify email")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte("Your email address is verified. You can close this page.\n"))
+}
+
+// ResendVerification emails the caller a new verification link.
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.ResendVerification(r.Context(), authCtx.UserID); err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.HasSuffix(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			h.log.WithContext(r.Context()).WithError(err).Error("Failed to resend verification email")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendToken responds with a fresh API token in the same shape as the OAuth
+// callbacks, so clients handle every login method alike.
+func (h *AuthHandler) sendToken(w http.ResponseWriter, r *http.Request, user *domain.User, deviceName, tokenName string, status int, message string) {
+	var deviceID *uuid.UUID
+	if deviceName != "" {
+		device, err := h.deviceService.RegisterDevice(r.Context(), user.ID, deviceName)
+		if err != nil {
+			if strings.HasPrefix(err.Error(), "invalid") {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			h.log.WithContext(r.Context()).WithError(err).Error("Failed to register device", "user_id", user.ID)
+			http.Error(w, "Failed to register device", http.StatusInternalServerError)
+			return
+		}
+		deviceID = &device.ID
+	}
+
+	token, err := issueAPIToken(r.Context(), h.queries, user.ID, tokenName, deviceID)
+	if err != nil {
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to generate API token", "user_id", user.ID)
+		http.Error(w, "Failed to generate authentication token", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": message,
+		"token":   token,
+		"user": map[string]interface{}{
+			"id":    user.ID,
+			"email": user.Email,
+			"tier":  user.Tier,
+		},
+	}
+	if deviceID != nil {
+		response["device_id"] = *deviceID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CachePolicy says how long responses that anyone may fetch, such as
+// public share links, can be reused before revalidating. Cached copies
+// outlive a revoked link by up to the longer of the two ages, so they are
+// kept short; revalidation is cheap because the ETag is checked before
+// any content is read.
+type CachePolicy struct {
+	// MaxAge applies to browsers and, unless SharedMaxAge is set, to
+	// shared caches too. Zero makes every reuse revalidate.
+	MaxAge time.Duration
+	// SharedMaxAge, when set, is sent as s-maxage so CDNs and proxies can
+	// keep a popular page for a different time than browsers.
+	SharedMaxAge time.Duration
+}
+
+// header returns the Cache-Control value for a response that stops being
+// valid at expiresAt, if set. Ages are cut so nothing is served from a
+// cache after that.
+func (p CachePolicy) header(now time.Time, expiresAt *time.Time) string {
+	maxAge, sharedMaxAge := p.MaxAge, p.SharedMaxAge
+	if expiresAt != nil {
+		remaining := expiresAt.Sub(now)
+		maxAge, sharedMaxAge = min(maxAge, remaining), min(sharedMaxAge, remaining)
+	}
+	if maxAge <= 0 {
+		return "public, no-cache"
+	}
+
+	value := fmt.Sprintf("public, max-age=%d", int(maxAge/time.Second))
+	if sharedMaxAge > 0 {
+		value += fmt.Sprintf(", s-maxage=%d", int(sharedMaxAge/time.Second))
+	}
+	return value
+}
+
+// notModified reports whether a GET or HEAD request's validators show the
+// client already holds the representation identified by etag and
+// lastModified. As in RFC 9110, If-Modified-Since is only consulted when
+// If-None-Match is absent, and entity tags compare weakly, since the
+// compression middleware weakens them.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		return etagListMatches(match, etag)
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified.IsZero() {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+func etagListMatches(list, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotModified answers a conditional request. The validators and
+// Cache-Control set so far are kept, as a 304 must repeat them.
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+}
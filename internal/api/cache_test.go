@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachePolicyHeader(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name      string
+		policy    CachePolicy
+		expiresAt *time.Time
+		want      string
+	}{
+		{"browser only", CachePolicy{MaxAge: time.Minute}, nil, "public, max-age=60"},
+		{"with s-maxage", CachePolicy{MaxAge: time.Minute, SharedMaxAge: 10 * time.Minute}, nil, "public, max-age=60, s-maxage=600"},
+		{"zero revalidates", CachePolicy{}, nil, "public, no-cache"},
+		{"cut at expiry", CachePolicy{MaxAge: time.Minute, SharedMaxAge: 10 * time.Minute}, at(30 * time.Second), "public, max-age=30, s-maxage=30"},
+		{"expiry beyond ages", CachePolicy{MaxAge: time.Minute}, at(time.Hour), "public, max-age=60"},
+		{"already expired", CachePolicy{MaxAge: time.Minute}, at(-time.Second), "public, no-cache"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.policy.header(now, tt.expiresAt))
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+	etag := `"abc"`
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    bool
+	}{
+		{"no validators", http.MethodGet, nil, false},
+		{"matching etag", http.MethodGet, map[string]string{"If-None-Match": `"abc"`}, true},
+		{"weak etag from compression", http.MethodGet, map[string]string{"If-None-Match": `W/"abc"`}, true},
+		{"etag in list", http.MethodGet, map[string]string{"If-None-Match": `"xyz", "abc"`}, true},
+		{"wildcard", http.MethodGet, map[string]string{"If-None-Match": "*"}, true},
+		{"other etag", http.MethodGet, map[string]string{"If-None-Match": `"xyz"`}, false},
+		{"not modified since", http.MethodGet, map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, true},
+		{"modified since", http.MethodGet, map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}, false},
+		{"etag wins over date", http.MethodGet, map[string]string{"If-None-Match": `"xyz"`, "If-Modified-Since": modified.Format(http.TimeFormat)}, false},
+		{"head", http.MethodHead, map[string]string{"If-None-Match": `"abc"`}, true},
+		{"post ignores validators", http.MethodPost, map[string]string{"If-None-Match": `"abc"`}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/s/token", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.want, notModified(r, etag, modified))
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/pkg/ical"
+	"github.com/google/uuid"
+)
+
+type CalendarHandler struct {
+	calendarService *services.CalendarService
+	baseURL         string
+}
+
+// NewCalendarHandler builds the calendar feed handlers. baseURL is the
+// server's public URL, used for the feed URLs returned to clients.
+func NewCalendarHandler(calendarService *services.CalendarService, baseURL string) *CalendarHandler {
+	return &CalendarHandler{
+		calendarService: calendarService,
+		baseURL:         baseURL,
+	}
+}
+
+func (h *CalendarHandler) CreateFeed(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	feed, err := h.calendarService.CreateFeed(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		writeCalendarError(w, err)
+		return
+	}
+	feed.URL = h.baseURL + "/calendar/" + feed.Token + ".ics"
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(feed)
+}
+
+func (h *CalendarHandler) ListFeeds(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	feeds, err := h.calendarService.ListFeeds(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		writeCalendarError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"feeds": feeds,
+		"count": len(feeds),
+	})
+}
+
+func (h *CalendarHandler) DeleteFeed(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+	feedID, err := uuid.Parse(r.PathValue("feed_id"))
+	if err != nil {
+		http.Error(w, "Invalid feed ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.calendarService.DeleteFeed(r.Context(), workspaceID, feedID, authCtx.UserID); err != nil {
+		writeCalendarError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Feed serves a calendar feed without authentication; the token in the
+// URL is the credential. The mux cannot match the ".ics" suffix within a
+// segment, so it is stripped here, and URLs without it work as well.
+func (h *CalendarHandler) Feed(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSuffix(r.PathValue("feed"), ".ics")
+
+	cal, err := h.calendarService.Feed(r.Context(), token)
+	if err != nil {
+		w.Header().Set("Cache-Control", "no-store")
+		if err.Error() == "calendar feed not found" {
+			http.Error(w, "Calendar feed not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to build calendar feed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="calendar.ics"`)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	ical.Encode(w, *cal, time.Now())
+}
+
+func writeCalendarError(w http.ResponseWriter, err error) {
+	if status, ok := workspaceAccessStatus(err); ok {
+		http.Error(w, "Workspace not found", status)
+		return
+	}
+	switch {
+	case strings.HasPrefix(err.Error(), "workspace not found"):
+		http.Error(w, "Workspace not found", http.StatusNotFound)
+	case err.Error() == "calendar feed not found":
+		http.Error(w, "Calendar feed not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *CalendarHandler) RegisterRoutes(r *Router) {
+	r.User("POST /api/workspaces/{id}/calendar-feeds", h.CreateFeed)
+	r.User("GET /api/workspaces/{id}/calendar-feeds", h.ListFeeds)
+	r.User("DELETE /api/workspaces/{id}/calendar-feeds/{feed_id}", h.DeleteFeed)
+	r.Public("GET /calendar/{feed}", h.Feed)
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/duckonomy/noture/internal/domain"
+)
+
+// maxTrackedClientVersions bounds the version distribution, since client
+// names and versions are whatever the client sends. Versions seen after
+// the limit is reached are counted under "other".
+const maxTrackedClientVersions = 1000
+
+type clientKey struct {
+	name, version string
+}
+
+// ClientGate enforces minimum versions per client name. Requests naming a
+// gated client with an older, missing or unparseable X-Client-Version get
+// 426 Upgrade Required, so known-bad sync clients stop before they write.
+// Requests without X-Client-Name, such as browsers, always pass.
+type ClientGate struct {
+	minimums map[string]domain.ClientVersion
+
+	mu     sync.Mutex
+	counts map[clientKey]*domain.ClientVersionCount
+}
+
+// NewClientGate takes the minimum version per client name.
+func NewClientGate(minimums map[string]string) (*ClientGate, error) {
+	g := &ClientGate{
+		minimums: make(map[string]domain.ClientVersion, len(minimums)),
+		counts:   make(map[clientKey]*domain.ClientVersionCount),
+	}
+	for name, version := range minimums {
+		if !domain.ValidClientName(name) {
+			return nil, fmt.Errorf("invalid client name %q", name)
+		}
+		v, err := domain.ParseClientVersion(version)
+		if err != nil {
+			return nil, err
+		}
+		g.minimums[name] = v
+	}
+	return g, nil
+}
+
+func (g *ClientGate) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.Header.Get(domain.ClientNameHeader)
+		if name == "" || r.URL.Path == "/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		version := r.Header.Get(domain.ClientVersionHeader)
+
+		minimum, gated := g.minimums[name]
+		rejected := false
+		if gated {
+			v, err := domain.ParseClientVersion(version)
+			rejected = err != nil || v.Less(minimum)
+		}
+		g.observe(name, version, rejected)
+
+		if rejected {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUpgradeRequired)
+			json.NewEncoder(w).Encode(domain.ClientUpgradeRequired{
+				Error:          "client_upgrade_required",
+				Message:        fmt.Sprintf("%s %s is no longer supported; upgrade to %s or later", name, version, minimum),
+				ClientName:     name,
+				ClientVersion:  version,
+				MinimumVersion: minimum.String(),
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (g *ClientGate) observe(name, version string, rejected bool) {
+	if !domain.ValidClientName(name) {
+		name = "other"
+	}
+	if _, err := domain.ParseClientVersion(version); err != nil {
+		version = "invalid"
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	key := clientKey{name, version}
+	count, ok := g.counts[key]
+	if !ok {
+		if len(g.counts) >= maxTrackedClientVersions {
+			key = clientKey{"other", "other"}
+			count = g.counts[key]
+		}
+		if count == nil {
+			count = &domain.ClientVersionCount{ClientName: key.name, ClientVersion: key.version}
+			g.counts[key] = count
+		}
+	}
+	count.Requests++
+	if rejected {
+		count.Rejected++
+	}
+}
+
+// Distribution returns the requests seen per client version since the
+// server started, ordered by client name and version.
+func (g *ClientGate) Distribution() []domain.ClientVersionCount {
+	g.mu.Lock()
+	counts := make([]domain.ClientVersionCount, 0, len(g.counts))
+	for _, count := range g.counts {
+		counts = append(counts, *count)
+	}
+	g.mu.Unlock()
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].ClientName != counts[j].ClientName {
+			return counts[i].ClientName < counts[j].ClientName
+		}
+		return counts[i].ClientVersion < counts[j].ClientVersion
+	})
+	return counts
+}
+
+// Minimums returns the configured minimum version per client name.
+func (g *ClientGate) Minimums() map[string]string {
+	minimums := make(map[string]string, len(g.minimums))
+	for name, v := range g.minimums {
+		minimums[name] = v.String()
+	}
+	return minimums
+}
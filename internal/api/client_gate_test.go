@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientGate(t *testing.T) {
+	gate, err := NewClientGate(map[string]string{"noture-cli": "1.4.0"})
+	require.NoError(t, err)
+	handler := gate.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	request := func(path, name, version string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if name != "" {
+			req.Header.Set(domain.ClientNameHeader, name)
+		}
+		if version != "" {
+			req.Header.Set(domain.ClientVersionHeader, version)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		client  string
+		version string
+		want    int
+	}{
+		{"anonymous client", "/api/workspaces", "", "", http.StatusNoContent},
+		{"ungated client", "/api/workspaces", "noture-obsidian", "0.1.0", http.StatusNoContent},
+		{"current version", "/api/workspaces", "noture-cli", "1.4.0", http.StatusNoContent},
+		{"newer version", "/api/workspaces", "noture-cli", "v1.10.2", http.StatusNoContent},
+		{"old version", "/api/workspaces", "noture-cli", "1.3.9", http.StatusUpgradeRequired},
+		{"pre-release of the minimum", "/api/workspaces", "noture-cli", "1.4.0-rc.1", http.StatusUpgradeRequired},
+		{"missing version", "/api/workspaces", "noture-cli", "", http.StatusUpgradeRequired},
+		{"health is exempt", "/health", "noture-cli", "1.0.0", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, request(tt.path, tt.client, tt.version).Code)
+		})
+	}
+
+	rec := request("/api/workspaces", "noture-cli", "1.3.9")
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var body domain.ClientUpgradeRequired
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, "client_upgrade_required", body.Error)
+	assert.Equal(t, "1.4.0", body.MinimumVersion)
+	assert.Equal(t, "1.3.9", body.ClientVersion)
+
+	assert.Equal(t, map[string]string{"noture-cli": "1.4.0"}, gate.Minimums())
+	assert.Equal(t, []domain.ClientVersionCount{
+		{ClientName: "noture-cli", ClientVersion: "1.3.9", Requests: 2, Rejected: 2},
+		{ClientName: "noture-cli", ClientVersion: "1.4.0", Requests: 1},
+		{ClientName: "noture-cli", ClientVersion: "1.4.0-rc.1", Requests: 1, Rejected: 1},
+		{ClientName: "noture-cli", ClientVersion: "invalid", Requests: 1, Rejected: 1},
+		{ClientName: "noture-cli", ClientVersion: "v1.10.2", Requests: 1},
+		{ClientName: "noture-obsidian", ClientVersion: "0.1.0", Requests: 1},
+	}, gate.Distribution())
+}
+
+func TestClientGate_BoundsDistribution(t *testing.T) {
+	gate, err := NewClientGate(nil)
+	require.NoError(t, err)
+	for i := 0; i < maxTrackedClientVersions+5; i++ {
+		gate.observe("noture-cli", "1.0."+strconv.Itoa(i), false)
+	}
+	counts := gate.Distribution()
+	assert.Len(t, counts, maxTrackedClientVersions+1)
+	for _, count := range counts {
+		if count.ClientName == "other" {
+			assert.Equal(t, int64(5), count.Requests)
+		}
+	}
+}
+
+func TestNewClientGate_Invalid(t *testing.T) {
+	_, err := NewClientGate(map[string]string{"noture-cli": "latest"})
+	assert.Error(t, err)
+	_, err = NewClientGate(map[string]string{"my client": "1.0"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,340 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/duckonomy/noture/internal/metrics"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// minCompressSize is the smallest response worth compressing; below
+	// it the encoding overhead outweighs the savings.
+	minCompressSize = 1024
+
+	// MaxDecodedBodySize caps a decompressed request body so a small
+	// compressed upload cannot expand without bound.
+	MaxDecodedBodySize = 64 << 20
+
+	// zstdWindowSize is the largest zstd window used or accepted. RFC 8878
+	// has HTTP clients decode windows up to 8 MiB; a larger one in a
+	// request would let a small body claim that much memory.
+	zstdWindowSize = 8 << 20
+)
+
+// CompressionStats counts compressed traffic: "<direction>_bytes_raw" is
+// the size before encoding and "<direction>_bytes_encoded" what crossed
+// the wire, for direction "request" or "response".
+var CompressionStats = metrics.NewCounters()
+
+type codec struct {
+	newReader func(io.Reader) (io.ReadCloser, error)
+	writers   *sync.Pool
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// codecNames lists the content codings understood in both directions, in
+// order of server preference. Each has an entry in codecs. zstd comes
+// first: it compresses notes about as well as gzip at a fraction of the
+// CPU.
+var codecNames = []string{"zstd", "gzip"}
+
+var codecs = map[string]codec{
+	"gzip": {
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+		writers: &sync.Pool{New: func() any {
+			w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+			return w
+		}},
+	},
+	"zstd": {
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdWindowSize))
+			if err != nil {
+				return nil, err
+			}
+			return d.IOReadCloser(), nil
+		},
+		writers: &sync.Pool{New: func() any {
+			w, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(zstdWindowSize))
+			return w
+		}},
+	},
+}
+
+// Compress decodes request bodies sent with a Content-Encoding, so handlers
+// always hash and store the plain content, and encodes responses with the
+// best coding the client's Accept-Encoding allows. Small, already
+// compressed and partial responses are sent as is.
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if coding := r.Header.Get("Content-Encoding"); coding != "" && coding != "identity" {
+			c, ok := codecs[strings.ToLower(coding)]
+			if !ok {
+				w.Header().Set("Accept-Encoding", strings.Join(codecNames, ", "))
+				http.Error(w, "Unsupported Content-Encoding: "+coding, http.StatusUnsupportedMediaType)
+				return
+			}
+			body, err := c.newReader(&countingReader{r: r.Body, counter: "request_bytes_encoded"})
+			if err != nil {
+				http.Error(w, "Invalid compressed request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, &decodedBody{
+				Reader:  &countingReader{r: body, counter: "request_bytes_raw"},
+				decoder: body,
+				closer:  r.Body,
+			}, MaxDecodedBodySize)
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		coding := negotiateEncoding(r.Header.Get("Accept-Encoding"), codecNames...)
+		if coding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, coding: coding, status: http.StatusOK}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks the offer with the highest q-value in an
+// Accept-Encoding header, ties going to the earlier offer. "*" matches
+// offers not listed explicitly. It returns "" when the client wants no
+// encoding.
+func negotiateEncoding(header string, offers ...string) string {
+	qs := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		qs[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, ok := qs[offer]
+		if !ok {
+			q = qs["*"]
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether a media type is text-like enough to gain
+// from compression.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/x-ndjson", "application/yaml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// compressWriter buffers the first minCompressSize bytes of a response
+// before deciding whether to encode it, so the headers still reflect the
+// choice.
+type compressWriter struct {
+	http.ResponseWriter
+	coding  string
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+	wire    *countingWriter
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided {
+		return
+	}
+	w.status = status
+	if !w.eligible(-1) {
+		w.start(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < minCompressSize {
+			return len(p), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	return w.write(p)
+}
+
+// decide starts the response based on what has been buffered and sends
+// the buffer.
+func (w *compressWriter) decide() error {
+	w.start(w.eligible(len(w.buf)))
+	buffered := w.buf
+	w.buf = nil
+	_, err := w.write(buffered)
+	return err
+}
+
+func (w *compressWriter) write(p []byte) (int, error) {
+	if w.enc == nil {
+		return w.ResponseWriter.Write(p)
+	}
+	CompressionStats.Add("response_bytes_raw", int64(len(p)))
+	return w.enc.Write(p)
+}
+
+// eligible decides from the headers and the first n buffered bytes (n < 0
+// when nothing is buffered yet) whether the response should be encoded.
+func (w *compressWriter) eligible(n int) bool {
+	h := w.Header()
+	switch {
+	case w.status < 200, w.status == http.StatusNoContent,
+		w.status == http.StatusNotModified, w.status == http.StatusPartialContent:
+		return false
+	case h.Get("Content-Encoding") != "", h.Get("Content-Range") != "":
+		return false
+	}
+	if ct := h.Get("Content-Type"); ct != "" && !compressible(ct) {
+		return false
+	}
+	if cl, err := strconv.Atoi(h.Get("Content-Length")); err == nil && cl < minCompressSize {
+		return false
+	}
+	return n < 0 || n >= minCompressSize
+}
+
+// start writes the headers, switching to the negotiated encoding when
+// compress is set.
+func (w *compressWriter) start(compress bool) {
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+		compress = compress && compressible(h.Get("Content-Type"))
+	}
+
+	if compress {
+		h.Set("Content-Encoding", w.coding)
+		h.Del("Content-Length")
+		// The encoded representation differs byte for byte, so a strong
+		// validator would be wrong. Conditional requests compare weakly.
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.wire = &countingWriter{w: w.ResponseWriter}
+		w.enc = codecs[w.coding].writers.Get().(encoder)
+		w.enc.Reset(w.wire)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// Flush sends what is buffered so far. A response flushed before reaching
+// minCompressSize is sent unencoded.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			return err
+		}
+	}
+	if w.enc == nil {
+		return nil
+	}
+
+	err := w.enc.Close()
+	codecs[w.coding].writers.Put(w.enc)
+	w.enc = nil
+	CompressionStats.Add("response_bytes_encoded", w.wire.n)
+	return err
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type countingReader struct {
+	r       io.Reader
+	counter string
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	CompressionStats.Add(c.counter, int64(n))
+	return n, err
+}
+
+type decodedBody struct {
+	io.Reader
+	decoder io.Closer
+	closer  io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	b.decoder.Close()
+	return b.closer.Close()
+}
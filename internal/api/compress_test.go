@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"no header", "", ""},
+		{"gzip", "gzip, deflate, br", "gzip"},
+		{"zstd is preferred", "gzip, deflate, br, zstd", "zstd"},
+		{"q-values win over preference", "zstd;q=0.5, gzip", "gzip"},
+		{"wildcard", "*", "zstd"},
+		{"q=0 refuses", "zstd;q=0, gzip;q=0, *", ""},
+		{"identity only", "identity", ""},
+		{"case insensitive", "GZIP", "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, negotiateEncoding(tt.header, codecNames...))
+		})
+	}
+}
+
+func TestCompress_Responses(t *testing.T) {
+	note := strings.Repeat("* TODO write more notes\n", 200)
+
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/note":
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			w.Header().Set("ETag", `"abc"`)
+			w.Write([]byte(note))
+		case "/small":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ok":true}`))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(bytes.Repeat([]byte{0}, 4096))
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("text is gzipped when accepted", func(t *testing.T) {
+		rec := get("/note", "gzip")
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, `W/"abc"`, rec.Header().Get("ETag"))
+		assert.Contains(t, rec.Header().Values("Vary"), "Accept-Encoding")
+		assert.Less(t, rec.Body.Len(), len(note)/5)
+
+		zr, err := gzip.NewReader(rec.Body)
+		require.NoError(t, err)
+		decoded, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		assert.Equal(t, note, string(decoded))
+	})
+
+	t.Run("text is zstd-encoded when accepted", func(t *testing.T) {
+		rec := get("/note", "gzip, zstd")
+		assert.Equal(t, "zstd", rec.Header().Get("Content-Encoding"))
+		assert.Less(t, rec.Body.Len(), len(note)/5)
+
+		zr, err := zstd.NewReader(rec.Body)
+		require.NoError(t, err)
+		defer zr.Close()
+		decoded, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		assert.Equal(t, note, string(decoded))
+	})
+
+	t.Run("identity without Accept-Encoding", func(t *testing.T) {
+		rec := get("/note", "")
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, `"abc"`, rec.Header().Get("ETag"))
+		assert.Equal(t, note, rec.Body.String())
+	})
+
+	t.Run("small, binary and empty responses are not encoded", func(t *testing.T) {
+		for _, path := range []string{"/small", "/image", "/empty"} {
+			rec := get(path, "gzip")
+			assert.Empty(t, rec.Header().Get("Content-Encoding"), path)
+		}
+		assert.Equal(t, `{"ok":true}`, get("/small", "gzip").Body.String())
+		assert.Equal(t, http.StatusNoContent, get("/empty", "gzip").Code)
+	})
+}
+
+func TestCompress_Requests(t *testing.T) {
+	var received string
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received = string(body)
+	}))
+
+	t.Run("gzip bodies are decoded", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte("# Notes"))
+		zw.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/upload", &buf)
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "# Notes", received)
+	})
+
+	t.Run("zstd bodies are decoded", func(t *testing.T) {
+		zw, err := zstd.NewWriter(nil)
+		require.NoError(t, err)
+		encoded := zw.EncodeAll([]byte("# Notes"), nil)
+		zw.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(encoded))
+		req.Header.Set("Content-Encoding", "zstd")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "# Notes", received)
+	})
+
+	t.Run("corrupt bodies are rejected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("not gzip"))
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("unknown codings are unsupported", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("x"))
+		req.Header.Set("Content-Encoding", "compress")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		assert.Equal(t, "zstd, gzip", rec.Header().Get("Accept-Encoding"))
+	})
+}
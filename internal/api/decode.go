@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/duckonomy/noture/pkg/validate"
+)
+
+// validationFailed is the 400 response body for a request whose fields
+// break the rules in their validate tags.
+type validationFailed struct {
+	Error   string                `json:"error"`
+	Message string                `json:"message"`
+	Fields  []validate.FieldError `json:"fields"`
+}
+
+// decodeRequest decodes the JSON in body into v and checks v's validate
+// tags. When either fails it answers 400, with the fields at fault for
+// the latter, and returns false. Callers bound body with
+// http.MaxBytesReader when the request has a size limit.
+func decodeRequest(w http.ResponseWriter, body io.Reader, v any) bool {
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return false
+	}
+	if err := validate.Struct(v); err != nil {
+		writeValidationError(w, err)
+		return false
+	}
+	return true
+}
+
+// decodeOptionalRequest is decodeRequest for endpoints whose body may be
+// left out: an empty body leaves v as it is, any other is decoded and
+// checked.
+func decodeOptionalRequest(w http.ResponseWriter, body io.Reader, v any) bool {
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return true
+		}
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return false
+	}
+	if err := validate.Struct(v); err != nil {
+		writeValidationError(w, err)
+		return false
+	}
+	return true
+}
+
+func writeValidationError(w http.ResponseWriter, err error) {
+	var errs validate.Errors
+	errors.As(err, &errs)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(validationFailed{
+		Error:   "validation_failed",
+		Message: err.Error(),
+		Fields:  errs,
+	})
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/validate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeRequest(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		var req domain.CreateWorkspaceRequest
+		w := httptest.NewRecorder()
+		assert.True(t, decodeRequest(w, strings.NewReader(`{"name":"Notes"}`), &req))
+		assert.Equal(t, "Notes", req.Name)
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		var req domain.CreateWorkspaceRequest
+		w := httptest.NewRecorder()
+		assert.False(t, decodeRequest(w, strings.NewReader(`{"name":`), &req))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "Invalid JSON\n", w.Body.String())
+	})
+
+	t.Run("field errors", func(t *testing.T) {
+		var req domain.AddMemberRequest
+		w := httptest.NewRecorder()
+		assert.False(t, decodeRequest(w, strings.NewReader(`{"email":"not an address"}`), &req))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var body validationFailed
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		assert.Equal(t, "validation_failed", body.Error)
+		assert.Equal(t, "invalid request: email must be an email address", body.Message)
+		assert.Equal(t, []validate.FieldError{{Field: "email", Rule: "email", Message: "must be an email address"}}, body.Fields)
+	})
+}
+
+func TestDecodeOptionalRequest(t *testing.T) {
+	t.Run("empty body", func(t *testing.T) {
+		var req domain.CloneWorkspaceRequest
+		w := httptest.NewRecorder()
+		assert.True(t, decodeOptionalRequest(w, strings.NewReader(""), &req))
+		assert.Empty(t, req.Name)
+	})
+
+	t.Run("body is checked", func(t *testing.T) {
+		var req domain.CloneWorkspaceRequest
+		w := httptest.NewRecorder()
+		assert.False(t, decodeOptionalRequest(w, strings.NewReader(`{"name":"`+strings.Repeat("x", 256)+`"}`), &req))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "name must be at most 255 characters")
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		var req domain.TemplateWorkspaceRequest
+		w := httptest.NewRecorder()
+		assert.False(t, decodeOptionalRequest(w, strings.NewReader(`{"name":`), &req))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
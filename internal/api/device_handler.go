@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/google/uuid"
+)
+
+type DeviceHandler struct {
+	deviceService *services.DeviceService
+}
+
+func NewDeviceHandler(deviceService *services.DeviceService) *DeviceHandler {
+	return &DeviceHandler{
+		deviceService: deviceService,
+	}
+}
+
+// ListDevices lists the signed-in devices, marking the one making the
+// request as current.
+func (h *DeviceHandler) ListDevices(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	devices, err := h.deviceService.ListDevices(r.Context(), authCtx.UserID, authCtx.Token.DeviceID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"devices": devices,
+		"count":   len(devices),
+	})
+}
+
+// RevokeDevice signs a device out by deleting it and its tokens.
+func (h *DeviceHandler) RevokeDevice(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	deviceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid device ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.deviceService.RevokeDevice(r.Context(), authCtx.UserID, deviceID); err != nil {
+		if err.Error() == "device not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSessions lists the live tokens of the user, marking the one making
+// the request as current, so that a lost or compromised one can be found.
+func (h *DeviceHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.deviceService.ListSessions(r.Context(), authCtx.UserID, authCtx.Token.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessions": sessions,
+		"count":    len(sessions),
+	})
+}
+
+// RevokeSession signs out one token.
+func (h *DeviceHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	tokenID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid session ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.deviceService.RevokeSession(r.Context(), authCtx.UserID, tokenID); err != nil {
+		if err.Error() == "session not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *DeviceHandler) RegisterRoutes(r *Router) {
+	r.Account("GET /api/devices", h.ListDevices)
+	r.Account("DELETE /api/devices/{id}", h.RevokeDevice)
+	r.Account("GET /api/sessions", h.ListSessions)
+	r.Account("DELETE /api/sessions/{id}", h.RevokeSession)
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/google/uuid"
+)
+
+type EventHandler struct {
+	eventService *services.EventService
+	log          *logger.Logger
+	streams      streamHub
+	closing      chan struct{}
+	closeOnce    sync.Once
+}
+
+func NewEventHandler(eventService *services.EventService) *EventHandler {
+	return &EventHandler{
+		eventService: eventService,
+		log:          logger.New(),
+		closing:      make(chan struct{}),
+	}
+}
+
+// ListEvents pages through a workspace's event stream. Clients pass the
+// last_id of one page as after to get the next; type may repeat and
+// accepts wildcards such as task.*. Clients that accept text/event-stream
+// get the events as Server-Sent Events instead, for as long as they stay
+// connected, resuming after the Last-Event-ID header when it is set.
+func (h *EventHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	var afterID int64
+	if afterStr := query.Get("after"); afterStr != "" {
+		afterID, err = strconv.ParseInt(afterStr, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid after", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if negotiate(r.Header.Get("Accept"), "application/json", "text/event-stream") == "text/event-stream" {
+		if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+			afterID, err = strconv.ParseInt(lastID, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+				return
+			}
+		}
+		wake, unsubscribe := h.streams.subscribe(workspaceID)
+		defer unsubscribe()
+		h.serveEventStream(w, r, afterID, wake, func(ctx context.Context, afterID int64) (*domain.EventPage, error) {
+			return h.eventService.ListEvents(ctx, workspaceID, authCtx.UserID, afterID, query["type"], 0)
+		})
+		return
+	}
+
+	page, err := h.eventService.ListEvents(r.Context(), workspaceID, authCtx.UserID, afterID, query["type"], limit)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// ReplayEvents streams a workspace's events and policy audit entries
+// between from and to (RFC 3339) as newline-delimited JSON, for audits and
+// for clients rebuilding their local state. Once streaming has begun a
+// failure can only cut the stream short; clients that need every event
+// should resume from the created_at of the last line they got.
+func (h *EventHandler) ReplayEvents(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	from, err := time.Parse(time.RFC3339, query.Get("from"))
+	if err != nil {
+		http.Error(w, "Invalid from: must be an RFC 3339 time", http.StatusBadRequest)
+		return
+	}
+	to, err := time.Parse(time.RFC3339, query.Get("to"))
+	if err != nil {
+		http.Error(w, "Invalid to: must be an RFC 3339 time", http.StatusBadRequest)
+		return
+	}
+
+	started := false
+	enc := json.NewEncoder(w)
+	err = h.eventService.ReplayEvents(r.Context(), workspaceID, authCtx.UserID, authCtx.UserTier, from, to, func(event domain.ReplayedEvent) error {
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			started = true
+		}
+		return enc.Encode(event)
+	})
+	switch {
+	case err != nil && !started:
+		writeEventError(w, err)
+	case err != nil:
+		h.log.WithContext(r.Context()).WithError(err).Error("Event replay failed", "workspace_id", workspaceID)
+	case !started:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+}
+
+// CreateWebhook subscribes a URL to the workspace's events. The response
+// is the only place the signing secret appears.
+func (h *EventHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.CreateWebhookRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	hook, err := h.eventService.CreateWebhook(r.Context(), workspaceID, authCtx.UserID, req)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hook)
+}
+
+func (h *EventHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	hooks, err := h.eventService.ListWebhooks(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"webhooks": hooks,
+		"count":    len(hooks),
+	})
+}
+
+func (h *EventHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+	webhookID, err := uuid.Parse(r.PathValue("webhook_id"))
+	if err != nil {
+		http.Error(w, "Invalid webhook_id format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.eventService.DeleteWebhook(r.Context(), workspaceID, authCtx.UserID, webhookID); err != nil {
+		writeEventError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeEventError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case strings.HasPrefix(err.Error(), "invalid"):
+		status = http.StatusBadRequest
+	case strings.HasPrefix(err.Error(), "workspace not found"), err.Error() == "webhook not found":
+		status = http.StatusNotFound
+	case err.Error() == "workspace is archived":
+		status = http.StatusConflict
+	default:
+		if s, ok := workspaceAccessStatus(err); ok {
+			status = s
+		}
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func (h *EventHandler) RegisterRoutes(r *Router) {
+	r.User("GET /api/workspaces/{workspace_id}/events", h.ListEvents)
+	r.User("GET /api/workspaces/{workspace_id}/events/replay", h.ReplayEvents)
+	r.User("POST /api/workspaces/{workspace_id}/webhooks", h.CreateWebhook)
+	r.User("GET /api/workspaces/{workspace_id}/webhooks", h.ListWebhooks)
+	r.User("DELETE /api/workspaces/{workspace_id}/webhooks/{webhook_id}", h.DeleteWebhook)
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/events"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/google/uuid"
+)
+
+const (
+	// eventStreamPollInterval is how often an idle event stream checks for
+	// new events. Changes published on the bus wake it sooner.
+	eventStreamPollInterval = 2 * time.Second
+
+	// eventStreamKeepAlive is how long a stream may go without writing
+	// before it sends a comment, so proxies don't close it as idle.
+	eventStreamKeepAlive = 15 * time.Second
+
+	// eventStreamMaxAge ends streams after a while so clients reconnect,
+	// possibly to another instance, and resume with Last-Event-ID.
+	eventStreamMaxAge = 30 * time.Minute
+
+	// eventStreamRetry is the reconnection delay suggested to clients.
+	eventStreamRetry = 3 * time.Second
+)
+
+// eventPager returns the events after afterID, as EventService.ListEvents
+// does for one workspace and filter.
+type eventPager func(ctx context.Context, afterID int64) (*domain.EventPage, error)
+
+// serveEventStream sends the events after afterID as Server-Sent Events,
+// then new ones as they are recorded, until the client disconnects, the
+// stream reaches eventStreamMaxAge or the server shuts down. Each event's
+// id is its event ID, so a reconnecting client resumes with Last-Event-ID.
+// The first page is fetched before the response starts, so its errors get
+// their usual status. A signal on wake checks for new events at once.
+func (h *EventHandler) serveEventStream(w http.ResponseWriter, r *http.Request, afterID int64, wake <-chan struct{}, next eventPager) {
+	ctx, cancel := context.WithTimeout(r.Context(), eventStreamMaxAge)
+	defer cancel()
+
+	page, err := next(ctx, afterID)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	// Flushing before the response reaches Compress's threshold also keeps
+	// the stream unencoded, so every event reaches the client as it is
+	// written.
+	rc := http.NewResponseController(w)
+	fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry.Milliseconds())
+	rc.Flush()
+
+	log := h.log.WithContext(r.Context())
+	poll := time.NewTicker(eventStreamPollInterval)
+	defer poll.Stop()
+	lastWrite := time.Now()
+	for {
+		for _, event := range page.Events {
+			if err := writeServerSentEvent(w, event); err != nil {
+				return
+			}
+		}
+		if len(page.Events) > 0 {
+			afterID = page.LastID
+			lastWrite = time.Now()
+			rc.Flush()
+		}
+		if len(page.Events) < services.MaxEventPageSize {
+			select {
+			case <-ctx.Done():
+				return
+			case <-h.closing:
+				return
+			case <-wake:
+			case <-poll.C:
+			}
+		}
+		if time.Since(lastWrite) >= eventStreamKeepAlive {
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			lastWrite = time.Now()
+			rc.Flush()
+		}
+
+		if page, err = next(ctx, afterID); err != nil {
+			if ctx.Err() == nil {
+				log.WithError(err).Warn("Event stream ended", "after_id", afterID)
+			}
+			return
+		}
+	}
+}
+
+// CloseStreams ends the open event streams, for shutdown: the server waits
+// for requests in flight, and streams would otherwise never finish.
+func (h *EventHandler) CloseStreams() {
+	h.closeOnce.Do(func() { close(h.closing) })
+}
+
+// WithEvents wakes the streams of a workspace when the bus reports a file
+// change in it, instead of leaving them to their next poll.
+func (h *EventHandler) WithEvents(bus *events.Bus) *EventHandler {
+	events.Subscribe(bus, func(ctx context.Context, e events.FileUploaded) {
+		h.streams.notify(e.WorkspaceID)
+	})
+	events.Subscribe(bus, func(ctx context.Context, e events.FileDeleted) {
+		h.streams.notify(e.WorkspaceID)
+	})
+	return h
+}
+
+// streamHub tracks the open event streams of each workspace so they can be
+// woken. The zero value is ready to use.
+type streamHub struct {
+	mu      sync.Mutex
+	streams map[uuid.UUID]map[chan struct{}]struct{}
+}
+
+// subscribe returns a channel signalled when the workspace changes, and a
+// function to call when the stream ends.
+func (hub *streamHub) subscribe(workspaceID uuid.UUID) (<-chan struct{}, func()) {
+	wake := make(chan struct{}, 1)
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.streams == nil {
+		hub.streams = make(map[uuid.UUID]map[chan struct{}]struct{})
+	}
+	if hub.streams[workspaceID] == nil {
+		hub.streams[workspaceID] = make(map[chan struct{}]struct{})
+	}
+	hub.streams[workspaceID][wake] = struct{}{}
+
+	return wake, func() {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		delete(hub.streams[workspaceID], wake)
+		if len(hub.streams[workspaceID]) == 0 {
+			delete(hub.streams, workspaceID)
+		}
+	}
+}
+
+// notify wakes the workspace's streams. Streams already due to check are
+// not signalled twice.
+func (hub *streamHub) notify(workspaceID uuid.UUID) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for wake := range hub.streams[workspaceID] {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// writeServerSentEvent writes event as one Server-Sent Event whose data is
+// the event's JSON.
+func writeServerSentEvent(w io.Writer, event domain.WorkspaceEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.ID, data)
+	return err
+}
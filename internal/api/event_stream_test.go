@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/events"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEventPage(from, to int64) *domain.EventPage {
+	page := &domain.EventPage{LastID: from - 1}
+	for id := from; id <= to; id++ {
+		page.Events = append(page.Events, domain.WorkspaceEvent{ID: id, Type: domain.EventFileCreated, Data: []byte(`{}`)})
+		page.LastID = id
+	}
+	return page
+}
+
+func TestEventHandler_ServeEventStream(t *testing.T) {
+	h := &EventHandler{log: logger.New(), closing: make(chan struct{})}
+
+	var after []int64
+	pager := func(ctx context.Context, afterID int64) (*domain.EventPage, error) {
+		after = append(after, afterID)
+		if len(after) == 1 {
+			// A full page is followed by the next one at once.
+			return newEventPage(afterID+1, afterID+services.MaxEventPageSize), nil
+		}
+		h.CloseStreams()
+		return newEventPage(afterID+1, afterID+1), nil
+	}
+
+	w := httptest.NewRecorder()
+	h.serveEventStream(w, httptest.NewRequest(http.MethodGet, "/", nil), 7, nil, pager)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, []int64{7, 7 + services.MaxEventPageSize}, after)
+
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, "retry: 3000\n\n"))
+	assert.Equal(t, services.MaxEventPageSize+1, strings.Count(body, "\ndata: "))
+	last := 8 + services.MaxEventPageSize
+	assert.Contains(t, body, fmt.Sprintf("id: %d\ndata: {\"id\":%d,", last, last))
+	assert.True(t, strings.HasSuffix(body, "\n\n"))
+}
+
+func TestEventHandler_ServeEventStream_FirstPageError(t *testing.T) {
+	h := &EventHandler{log: logger.New(), closing: make(chan struct{})}
+	pager := func(ctx context.Context, afterID int64) (*domain.EventPage, error) {
+		return nil, errors.New("workspace not found")
+	}
+
+	w := httptest.NewRecorder()
+	h.serveEventStream(w, httptest.NewRequest(http.MethodGet, "/", nil), 0, nil, pager)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NotEqual(t, "text/event-stream", w.Header().Get("Content-Type"))
+}
+
+func TestEventHandler_WakesStreams(t *testing.T) {
+	bus := events.NewBus()
+	h := (&EventHandler{log: logger.New(), closing: make(chan struct{})}).WithEvents(bus)
+	workspaceID := uuid.New()
+
+	calls := 0
+	pager := func(ctx context.Context, afterID int64) (*domain.EventPage, error) {
+		calls++
+		if calls == 1 {
+			go bus.Publish(ctx, events.FileUploaded{WorkspaceID: uuid.New()})
+			go bus.Publish(ctx, events.FileDeleted{WorkspaceID: workspaceID})
+		} else {
+			h.CloseStreams()
+		}
+		return &domain.EventPage{LastID: afterID}, nil
+	}
+
+	wake, unsubscribe := h.streams.subscribe(workspaceID)
+	done := make(chan struct{})
+	go func() {
+		h.serveEventStream(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), 0, wake, pager)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(eventStreamPollInterval / 2):
+		t.Fatal("the stream was not woken")
+	}
+	assert.Equal(t, 2, calls)
+
+	unsubscribe()
+	assert.Empty(t, h.streams.streams)
+}
+
+func TestWriteServerSentEvent(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, writeServerSentEvent(&b, domain.WorkspaceEvent{
+		ID:       42,
+		Type:     domain.EventFileUpdated,
+		FilePath: "notes/a\nb.md",
+		Data:     []byte(`{}`),
+	}))
+
+	lines := strings.Split(b.String(), "\n")
+	require.Len(t, lines, 4, "newlines in fields stay escaped in the JSON")
+	assert.Equal(t, "id: 42", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], `data: {"id":42,`))
+	assert.Equal(t, []string{"", ""}, lines[2:])
+}
@@ -1,29 +1,43 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/ratelimit"
 	"github.com/duckonomy/noture/internal/services"
 	"github.com/google/uuid"
 )
 
 type FileHandler struct {
 	fileService *services.FileService
+	shares      *ShareHandler
 }
 
-func NewFileHandler(fileService *services.FileService) *FileHandler {
+// NewFileHandler builds the file handlers. shares serves the share links
+// created through POST /api/files/{workspace_id}/{file_path}/share, which
+// shares its route with the other actions on a file.
+func NewFileHandler(fileService *services.FileService, shares *ShareHandler) *FileHandler {
 	return &FileHandler{
 		fileService: fileService,
+		shares:      shares,
 	}
 }
 
 func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
-	authCtx := r.Context().Value("auth").(*domain.AuthContext)
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
 
 	err := r.ParseMultipartForm(32 << 20) // 32MB limit
 	if err != nil {
@@ -35,6 +49,7 @@ func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 	filePath := r.FormValue("file_path")
 	lastModifiedStr := r.FormValue("last_modified")
 	clientID := r.FormValue("client_id")
+	noteID := r.FormValue("note_id")
 
 	if workspaceIDStr == "" || filePath == "" {
 		http.Error(w, "Missing required fields: workspace_id, file_path", http.StatusBadRequest)
@@ -77,25 +92,131 @@ func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 		Content:      content,
 		LastModified: lastModified,
 		ClientID:     clientID,
+		NoteID:       noteID,
 	}
 
-	fileInfo, err := h.fileService.UploadFile(r.Context(), req, authCtx.UserID)
+	result, err := h.fileService.UploadFile(r.Context(), req, authCtx.UserID)
 	if err != nil {
-		if err.Error() == "storage limit exceeded" {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.HasPrefix(err.Error(), "storage limit exceeded") || strings.HasPrefix(err.Error(), "file too large") {
 			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
 			return
 		}
+		if strings.HasPrefix(err.Error(), "file limit reached") {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if err.Error() == "workspace is archived" {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	status := http.StatusOK
+	if result.Created {
+		status = http.StatusCreated
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(fileInfo)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+// PatchFile updates a file with a unified diff against the version named
+// by If-Match, which takes the ETag (the quoted content hash) a read
+// returned. Without If-Match the request is refused with 428; if the file
+// has changed since, with 412, and the client should fetch it and rebase.
+func (h *FileHandler) PatchFile(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	// As with GetFile, a "/meta" suffix is told apart here.
+	if metaPath, ok := strings.CutSuffix(r.PathValue("file_path"), "/meta"); ok && metaPath != "" {
+		h.patchFileMeta(w, r, workspaceID, metaPath, authCtx.UserID)
+		return
+	}
+
+	baseHash := strings.Trim(strings.TrimPrefix(r.Header.Get("If-Match"), "W/"), `"`)
+	if baseHash == "" {
+		http.Error(w, "If-Match with the base content hash is required", http.StatusPreconditionRequired)
+		return
+	}
+
+	lastModified := time.Now()
+	if s := r.URL.Query().Get("last_modified"); s != "" {
+		lastModified, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "Invalid last_modified format (use RFC3339)", http.StatusBadRequest)
+			return
+		}
+	}
+
+	diff, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 32<<20))
+	if err != nil {
+		http.Error(w, "Failed to read patch", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.fileService.PatchFile(r.Context(), domain.FilePatchRequest{
+		WorkspaceID:  workspaceID,
+		FilePath:     r.PathValue("file_path"),
+		BaseHash:     baseHash,
+		Patch:        diff,
+		LastModified: lastModified,
+		ClientID:     r.URL.Query().Get("client_id"),
+		NoteID:       r.URL.Query().Get("note_id"),
+	}, authCtx.UserID)
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err.Error() == "file not found":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err.Error() == "file changed":
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case strings.HasPrefix(err.Error(), "storage limit exceeded"), strings.HasPrefix(err.Error(), "file too large"):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		case strings.HasPrefix(err.Error(), "file limit reached"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case err.Error() == "workspace is archived":
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", `"`+result.ContentHash+`"`)
+	json.NewEncoder(w).Encode(result)
 }
 
 func (h *FileHandler) GetFile(w http.ResponseWriter, r *http.Request) {
-	authCtx := r.Context().Value("auth").(*domain.AuthContext)
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
 
 	workspaceIDStr := r.PathValue("workspace_id")
 	filePath := r.PathValue("file_path")
@@ -111,76 +232,497 @@ func (h *FileHandler) GetFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	includeContent := r.URL.Query().Get("content") == "true"
-	isDownload := r.URL.Query().Get("download") == "true"
+	// As with shares, the mux cannot match a suffix after the trailing
+	// wildcard, so renders, related notes and thumbnails are told apart
+	// here.
+	if notePath, ok := strings.CutSuffix(filePath, "/render"); ok && notePath != "" {
+		h.renderFile(w, r, workspaceID, notePath, authCtx.UserID)
+		return
+	}
+	if notePath, ok := strings.CutSuffix(filePath, "/related"); ok && notePath != "" {
+		h.relatedNotes(w, r, workspaceID, notePath, authCtx.UserID)
+		return
+	}
+	if imagePath, ok := strings.CutSuffix(filePath, "/thumbnail"); ok && imagePath != "" {
+		h.thumbnailFile(w, r, workspaceID, imagePath, authCtx.UserID)
+		return
+	}
 
-	if isDownload {
+	// The content and download query parameters predate content
+	// negotiation and are kept as aliases.
+	switch {
+	case r.URL.Query().Get("download") == "true":
+		h.writeRawFile(w, r, workspaceID, filePath, authCtx.UserID, "attachment")
+		return
+	case r.URL.Query().Get("content") == "true":
 		fileWithContent, err := h.fileService.GetFileContent(r.Context(), workspaceID, filePath, authCtx.UserID)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
 
-		w.Header().Set("Content-Type", fileWithContent.MimeType)
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filePath))
-		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(fileWithContent.Content)))
-		w.Header().Set("Last-Modified", fileWithContent.LastModified.Format(http.TimeFormat))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(fileWithContent)
+		return
+	}
 
-		w.Write(fileWithContent.Content)
-	} else if includeContent {
-		fileWithContent, err := h.fileService.GetFileContent(r.Context(), workspaceID, filePath, authCtx.UserID)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+	getFile := h.fileService.GetFile
+	if r.URL.Query().Get("include") == "meta" {
+		getFile = h.fileService.GetFileWithMeta
+	}
+	fileInfo, err := getFile(r.Context(), workspaceID, filePath, authCtx.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	// Without an Accept header clients get metadata, as they always have.
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		accept = "application/json"
+	}
+
+	switch negotiate(accept, fileInfo.MimeType, "application/json") {
+	case "application/json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(fileInfo)
+	case "":
+		http.Error(w, fmt.Sprintf("Not acceptable: file is %s, metadata is application/json", fileInfo.MimeType), http.StatusNotAcceptable)
+	default:
+		h.writeRawFile(w, r, workspaceID, filePath, authCtx.UserID, "inline")
+	}
+}
+
+// patchFileMeta handles PATCH /api/files/{workspace_id}/{file_path}/meta.
+// The body is a JSON object merged into the file's metadata: null removes
+// a key, any other value replaces it.
+func (h *FileHandler) patchFileMeta(w http.ResponseWriter, r *http.Request, workspaceID uuid.UUID, filePath string, userID uuid.UUID) {
+	var patch domain.FileMeta
+	if !decodeRequest(w, http.MaxBytesReader(w, r.Body, 2*domain.MaxFileMetaBytes), &patch) {
+		return
+	}
+
+	fileInfo, err := h.fileService.PatchFileMeta(r.Context(), workspaceID, filePath, userID, patch)
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
 			return
 		}
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.HasPrefix(err.Error(), "file not found"), strings.HasPrefix(err.Error(), "workspace not found"):
+			http.Error(w, "File not found", http.StatusNotFound)
+		case err.Error() == "workspace is archived":
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(fileWithContent)
-	} else {
-		fileInfo, err := h.fileService.GetFile(r.Context(), workspaceID, filePath, authCtx.UserID)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fileInfo)
+}
+
+// renderFile handles GET /api/files/{workspace_id}/{file_path}/render. It
+// returns the note as a sanitized HTML fragment, or as JSON with the
+// fragment and its unresolved wiki-links when the client prefers that.
+// link_template sets where resolved wiki-links point.
+func (h *FileHandler) renderFile(w http.ResponseWriter, r *http.Request, workspaceID uuid.UUID, filePath string, userID uuid.UUID) {
+	opts := domain.RenderOptions{LinkTemplate: r.URL.Query().Get("link_template")}
+	rendered, err := h.fileService.RenderFile(r.Context(), workspaceID, filePath, userID, opts)
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
 			return
 		}
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.HasPrefix(err.Error(), "file not found"), strings.HasPrefix(err.Error(), "workspace not found"):
+			http.Error(w, "File not found", http.StatusNotFound)
+		case strings.HasPrefix(err.Error(), "unsupported media type"):
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
 
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if negotiate(r.Header.Get("Accept"), "text/html", "application/json") == "application/json" {
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(fileInfo)
+		json.NewEncoder(w).Encode(rendered)
+		return
 	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src https: data:")
+	io.WriteString(w, rendered.HTML)
 }
 
-func (h *FileHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
-	authCtx := r.Context().Value("auth").(*domain.AuthContext)
+// relatedNotes handles GET /api/files/{workspace_id}/{file_path}/related,
+// ranking the notes connected to the note by links and tags.
+func (h *FileHandler) relatedNotes(w http.ResponseWriter, r *http.Request, workspaceID uuid.UUID, filePath string, userID uuid.UUID) {
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	related, err := h.fileService.RelatedNotes(r.Context(), workspaceID, filePath, userID, limit)
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.HasPrefix(err.Error(), "file not found"), strings.HasPrefix(err.Error(), "workspace not found"):
+			http.Error(w, "File not found", http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"file_path": filePath,
+		"related":   related,
+	})
+}
+
+// BrokenLinks handles GET /api/workspaces/{workspace_id}/links/broken.
+func (h *FileHandler) BrokenLinks(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.fileService.BrokenLinks(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if strings.HasPrefix(err.Error(), "workspace not found") {
+			http.Error(w, "Workspace not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// RecentFiles handles GET /api/workspaces/{workspace_id}/recent. by is
+// modified, the default, or opened.
+func (h *FileHandler) RecentFiles(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	limit := 0
+	if raw := query.Get("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	files, err := h.fileService.RecentFiles(r.Context(), workspaceID, authCtx.UserID, query.Get("by"), limit)
+	if err != nil {
+		writeRecentFilesError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"files": files,
+		"count": len(files),
+	})
+}
+
+// RecordFileOpen handles POST /api/workspaces/{workspace_id}/recent, which
+// clients call when the user opens a file.
+func (h *FileHandler) RecordFileOpen(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.FileOpenRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	if err := h.fileService.RecordFileOpen(r.Context(), workspaceID, authCtx.UserID, req); err != nil {
+		writeRecentFilesError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// FileAction handles POST /api/files/{workspace_id}/{file_path}/{action}.
+// The mux cannot match a suffix after a trailing wildcard, so pins and
+// shares are told apart here.
+func (h *FileHandler) FileAction(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
 
-	workspaceIDStr := r.PathValue("workspace_id")
 	filePath := r.PathValue("file_path")
+	if pinnedPath, ok := strings.CutSuffix(filePath, "/pin"); ok && pinnedPath != "" {
+		workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+		if err != nil {
+			http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+			return
+		}
+		h.pinFile(w, r, workspaceID, pinnedPath, authCtx.UserID)
+		return
+	}
+	if strings.HasSuffix(filePath, "/share") && h.shares != nil {
+		h.shares.CreateShare(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
 
-	if workspaceIDStr == "" || filePath == "" {
-		http.Error(w, "Missing workspace_id or file_path", http.StatusBadRequest)
+func (h *FileHandler) pinFile(w http.ResponseWriter, r *http.Request, workspaceID uuid.UUID, filePath string, userID uuid.UUID) {
+	if err := h.fileService.PinFile(r.Context(), workspaceID, userID, filePath); err != nil {
+		writeRecentFilesError(w, err)
 		return
 	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	workspaceID, err := uuid.Parse(workspaceIDStr)
+func (h *FileHandler) unpinFile(w http.ResponseWriter, r *http.Request, workspaceID uuid.UUID, filePath string, userID uuid.UUID) {
+	if err := h.fileService.UnpinFile(r.Context(), workspaceID, userID, filePath); err != nil {
+		writeRecentFilesError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PinnedFiles handles GET /api/workspaces/{workspace_id}/pinned, the
+// files the user pinned.
+func (h *FileHandler) PinnedFiles(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
 	if err != nil {
 		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
 		return
 	}
 
-	fileWithContent, err := h.fileService.GetFileContent(r.Context(), workspaceID, filePath, authCtx.UserID)
+	files, err := h.fileService.ListPinnedFiles(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		writeRecentFilesError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"files": files,
+		"count": len(files),
+	})
+}
+
+func writeRecentFilesError(w http.ResponseWriter, err error) {
+	if status, ok := workspaceAccessStatus(err); ok {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	switch {
+	case strings.HasPrefix(err.Error(), "invalid"):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case strings.HasPrefix(err.Error(), "file not found"):
+		http.Error(w, "File not found", http.StatusNotFound)
+	case strings.HasPrefix(err.Error(), "workspace not found"):
+		http.Error(w, "Workspace not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// RebuildMetadata handles POST /api/workspaces/{workspace_id}/metadata/rebuild.
+// The files are reparsed in the background; the response is the status
+// to poll.
+func (h *FileHandler) RebuildMetadata(w http.ResponseWriter, r *http.Request) {
+	h.metadataStatus(w, r, h.fileService.RebuildMetadata, http.StatusAccepted)
+}
+
+// MetadataStatus handles GET /api/workspaces/{workspace_id}/metadata/status.
+func (h *FileHandler) MetadataStatus(w http.ResponseWriter, r *http.Request) {
+	h.metadataStatus(w, r, h.fileService.MetadataStatus, http.StatusOK)
+}
+
+func (h *FileHandler) metadataStatus(w http.ResponseWriter, r *http.Request, get func(context.Context, uuid.UUID, uuid.UUID) (*domain.MetadataStatus, error), code int) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	status, err := get(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		if s, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), s)
+			return
+		}
+		if strings.HasPrefix(err.Error(), "workspace not found") {
+			http.Error(w, "Workspace not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
+
+// thumbnailFile handles GET /api/files/{workspace_id}/{file_path}/thumbnail.
+// size sets the longer side in pixels. The ETag is known from the
+// metadata, so revalidations skip decoding the image.
+func (h *FileHandler) thumbnailFile(w http.ResponseWriter, r *http.Request, workspaceID uuid.UUID, filePath string, userID uuid.UUID) {
+	size := services.DefaultThumbnailSize
+	if value := r.URL.Query().Get("size"); value != "" {
+		var err error
+		if size, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid size", http.StatusBadRequest)
+			return
+		}
+	}
+
+	fileInfo, err := h.fileService.GetFile(r.Context(), workspaceID, filePath, userID)
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s-thumb%d"`, fileInfo.ContentHash, size)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if notModified(r, etag, time.Time{}) {
+		writeNotModified(w)
+		return
+	}
+
+	thumb, err := h.fileService.Thumbnail(r.Context(), fileInfo, size)
+	if err != nil {
+		w.Header().Del("ETag")
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.HasPrefix(err.Error(), "unsupported media type"):
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", thumb.MimeType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(thumb.Content)
+}
+
+// writeRawFile streams the file's bytes with its stored MIME type.
+// disposition is "inline" for negotiated reads and "attachment" for
+// downloads. Range and conditional requests are answered by
+// http.ServeContent, keyed on the content hash.
+func (h *FileHandler) writeRawFile(w http.ResponseWriter, r *http.Request, workspaceID uuid.UUID, filePath string, userID uuid.UUID, disposition string) {
+	fileInfo, content, err := h.fileService.OpenFileContent(r.Context(), workspaceID, filePath, userID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
+	defer content.Close()
 
-	w.Header().Set("Content-Type", fileWithContent.MimeType)
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filePath))
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(fileWithContent.Content)))
-	w.Header().Set("Last-Modified", fileWithContent.LastModified.Format(http.TimeFormat))
+	w.Header().Set("Content-Type", fileInfo.MimeType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, path.Base(filePath)))
+	w.Header().Set("ETag", `"`+fileInfo.ContentHash+`"`)
+	// Attachments keep their own types, so uploaded HTML or SVG must not
+	// run as part of the API's origin.
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
 
-	w.Write(fileWithContent.Content)
+	http.ServeContent(w, r, path.Base(filePath), fileInfo.LastModified, content)
+}
+
+func (h *FileHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceIDStr := r.PathValue("workspace_id")
+	filePath := r.PathValue("file_path")
+
+	if workspaceIDStr == "" || filePath == "" {
+		http.Error(w, "Missing workspace_id or file_path", http.StatusBadRequest)
+		return
+	}
+
+	workspaceID, err := uuid.Parse(workspaceIDStr)
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	h.writeRawFile(w, r, workspaceID, filePath, authCtx.UserID, "attachment")
 }
 
 func (h *FileHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
-	authCtx := r.Context().Value("auth").(*domain.AuthContext)
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
 
 	workspaceIDStr := r.PathValue("workspace_id")
 	if workspaceIDStr == "" {
@@ -194,6 +736,61 @@ func (h *FileHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	query := r.URL.Query()
+	paged := false
+	for _, param := range []string{"limit", "cursor", "after", "sort", "path_prefix", "mime_type"} {
+		paged = paged || query.Has(param)
+	}
+	if paged {
+		opts := domain.FileListOptions{
+			Cursor:     query.Get("cursor"),
+			PathPrefix: query.Get("path_prefix"),
+		}
+		// after is the original name of the path-order cursor.
+		if opts.Cursor == "" {
+			opts.Cursor = query.Get("after")
+		}
+		if limitStr := query.Get("limit"); limitStr != "" {
+			opts.Limit, err = strconv.Atoi(limitStr)
+			if err != nil || opts.Limit < 1 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+		opts.Sort, err = domain.ParseFileSort(query.Get("sort"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, value := range query["mime_type"] {
+			for _, mimeType := range strings.Split(value, ",") {
+				if mimeType = strings.TrimSpace(mimeType); mimeType != "" {
+					opts.MimeTypes = append(opts.MimeTypes, mimeType)
+				}
+			}
+		}
+
+		page, err := h.fileService.ListFilesPage(r.Context(), workspaceID, authCtx.UserID, opts)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if s, ok := workspaceAccessStatus(err); ok {
+				status = s
+			} else if strings.HasPrefix(err.Error(), "invalid") {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"files":       page.Files,
+			"count":       len(page.Files),
+			"next_cursor": page.NextCursor,
+		})
+		return
+	}
+
 	files, err := h.fileService.ListFiles(r.Context(), workspaceID, authCtx.UserID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -207,8 +804,145 @@ func (h *FileHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// LookupFiles returns info for many paths of one workspace at once, with a
+// found flag per path.
+func (h *FileHandler) LookupFiles(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.FileLookupRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	results, err := h.fileService.LookupFiles(r.Context(), workspaceID, authCtx.UserID, req)
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if strings.HasPrefix(err.Error(), "no paths") || strings.HasPrefix(err.Error(), "too many paths") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	found := 0
+	for _, result := range results {
+		if result.Found {
+			found++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results": results,
+		"found":   found,
+	})
+}
+
+// GetNote returns the file holding a stable note ID.
+func (h *FileHandler) GetNote(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	fileInfo, err := h.fileService.GetFileByNoteID(r.Context(), workspaceID, authCtx.UserID, r.PathValue("note_id"))
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if err.Error() == "note not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fileInfo)
+}
+
+// SaveSet applies several writes, moves and deletes atomically. Conflicts
+// with the operations' base hashes are answered with 409 and every
+// conflicting operation, and nothing is applied.
+func (h *FileHandler) SaveSet(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	// Content is base64 in JSON; this admits about as much as an upload.
+	var req domain.SaveSetRequest
+	if !decodeRequest(w, http.MaxBytesReader(w, r.Body, 48<<20), &req) {
+		return
+	}
+
+	result, err := h.fileService.ApplySaveSet(r.Context(), workspaceID, authCtx.UserID, req)
+	if err != nil {
+		var conflict *domain.SaveSetConflictError
+		if errors.As(err, &conflict) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":     "save_set_conflict",
+				"conflicts": conflict.Conflicts,
+			})
+			return
+		}
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.HasPrefix(err.Error(), "storage limit exceeded"), strings.HasPrefix(err.Error(), "file too large"):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		case strings.HasPrefix(err.Error(), "file limit reached"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case err.Error() == "workspace is archived":
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
 func (h *FileHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
-	authCtx := r.Context().Value("auth").(*domain.AuthContext)
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
 
 	workspaceIDStr := r.PathValue("workspace_id")
 	filePath := r.PathValue("file_path")
@@ -224,8 +958,22 @@ func (h *FileHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// As with GetFile, a "/pin" suffix is told apart here.
+	if pinnedPath, ok := strings.CutSuffix(filePath, "/pin"); ok && pinnedPath != "" {
+		h.unpinFile(w, r, workspaceID, pinnedPath, authCtx.UserID)
+		return
+	}
+
 	err = h.fileService.DeleteFile(r.Context(), workspaceID, filePath, authCtx.UserID)
 	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if err.Error() == "workspace is archived" {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -233,9 +981,210 @@ func (h *FileHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *FileHandler) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("POST /api/files/upload", h.UploadFile)
-	mux.HandleFunc("GET /api/files/{workspace_id}/{file_path...}", h.GetFile)
-	mux.HandleFunc("GET /api/workspaces/{workspace_id}/files", h.ListFiles)
-	mux.HandleFunc("DELETE /api/files/{workspace_id}/{file_path...}", h.DeleteFile)
+// GetTree returns the workspace's folder hierarchy, or the subtree below
+// the folder query parameter.
+func (h *FileHandler) GetTree(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	tree, err := h.fileService.GetFileTree(r.Context(), workspaceID, authCtx.UserID, r.URL.Query().Get("folder"))
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tree)
+}
+
+// DeleteFolder deletes every file below a folder.
+func (h *FileHandler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.fileService.DeleteFolder(r.Context(), workspaceID, r.PathValue("folder"), authCtx.UserID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid folder"):
+			status = http.StatusBadRequest
+		case strings.HasPrefix(err.Error(), "folder not found"):
+			status = http.StatusNotFound
+		case err.Error() == "workspace is archived":
+			status = http.StatusConflict
+		default:
+			if s, ok := workspaceAccessStatus(err); ok {
+				status = s
+			}
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ListSyncOperations lists the workspace's sync operations, newest first.
+// status, operation_type and client_id filter exactly; since and until
+// take RFC 3339 timestamps or dates, until being exclusive.
+func (h *FileHandler) ListSyncOperations(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := domain.SyncOperationFilter{
+		Status:        query.Get("status"),
+		OperationType: query.Get("operation_type"),
+		ClientID:      query.Get("client_id"),
+		Cursor:        query.Get("cursor"),
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		filter.Limit, err = strconv.Atoi(limitStr)
+		if err != nil || filter.Limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	for param, dst := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := parseTimeParam(value)
+		if err != nil {
+			http.Error(w, "Invalid "+param, http.StatusBadRequest)
+			return
+		}
+		*dst = &t
+	}
+
+	page, err := h.fileService.ListSyncOperations(r.Context(), workspaceID, authCtx.UserID, filter)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if s, ok := workspaceAccessStatus(err); ok {
+			status = s
+		} else if strings.HasPrefix(err.Error(), "invalid") {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// GetManifest returns the path, hash, size and mtime of every file below
+// folder, so clients reconcile in one request. view=digest returns only
+// digests, to find what differs in large workspaces. The digest doubles as
+// the ETag.
+func (h *FileHandler) GetManifest(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	digestOnly := false
+	switch view := r.URL.Query().Get("view"); view {
+	case "", "files":
+	case "digest":
+		digestOnly = true
+	default:
+		http.Error(w, "Invalid view: must be files or digest", http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := h.fileService.GetManifest(r.Context(), workspaceID, authCtx.UserID, r.URL.Query().Get("folder"), digestOnly)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if s, ok := workspaceAccessStatus(err); ok {
+			status = s
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	// A 304 means the files are unchanged; the revision may have moved.
+	etag := `"` + manifest.Digest + `"`
+	if digestOnly {
+		etag = `"digest-` + manifest.Digest + `"`
+	}
+	w.Header().Set("ETag", etag)
+	if match := r.Header.Get("If-None-Match"); match != "" && strings.TrimPrefix(match, "W/") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifest)
+}
+
+// parseTimeParam accepts an RFC 3339 timestamp or a date, read as midnight
+// UTC.
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+func (h *FileHandler) RegisterRoutes(r *Router) {
+	// Sync clients retry writes on timeouts, so those honor
+	// Idempotency-Key. Writes of file content take from the upload budget.
+	idempotent := r.Idempotent()
+	uploads := idempotent.RateGroup(ratelimit.GroupUpload)
+	uploads.User("POST /api/files/upload", h.UploadFile)
+	r.User("GET /api/files/{workspace_id}/{file_path...}", h.GetFile)
+	r.User("GET /api/workspaces/{workspace_id}/files", h.ListFiles)
+	r.User("POST /api/workspaces/{workspace_id}/files/lookup", h.LookupFiles)
+	r.User("GET /api/workspaces/{workspace_id}/notes/{note_id}", h.GetNote)
+	uploads.User("POST /api/workspaces/{workspace_id}/save-set", h.SaveSet)
+	uploads.User("PATCH /api/files/{workspace_id}/{file_path...}", h.PatchFile)
+	idempotent.User("DELETE /api/files/{workspace_id}/{file_path...}", h.DeleteFile)
+	r.Experimental().User("GET /api/workspaces/{workspace_id}/tree", h.GetTree)
+	idempotent.Experimental().User("DELETE /api/workspaces/{workspace_id}/folders/{folder...}", h.DeleteFolder)
+	r.User("GET /api/workspaces/{workspace_id}/manifest", h.GetManifest)
+	r.User("GET /api/workspaces/{workspace_id}/sync-operations", h.ListSyncOperations)
+	r.User("POST /api/files/{workspace_id}/{file_path...}", h.FileAction)
+	r.User("GET /api/workspaces/{workspace_id}/pinned", h.PinnedFiles)
+	r.User("GET /api/workspaces/{workspace_id}/recent", h.RecentFiles)
+	r.User("POST /api/workspaces/{workspace_id}/recent", h.RecordFileOpen)
+	r.User("GET /api/workspaces/{workspace_id}/links/broken", h.BrokenLinks)
+	r.User("POST /api/workspaces/{workspace_id}/metadata/rebuild", h.RebuildMetadata)
+	r.User("GET /api/workspaces/{workspace_id}/metadata/status", h.MetadataStatus)
 }
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/google/uuid"
+)
+
+type GalleryHandler struct {
+	galleryService *services.GalleryService
+}
+
+func NewGalleryHandler(galleryService *services.GalleryService) *GalleryHandler {
+	return &GalleryHandler{
+		galleryService: galleryService,
+	}
+}
+
+func (h *GalleryHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	templates := h.galleryService.ListTemplates()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"templates": templates,
+		"count":     len(templates),
+	})
+}
+
+// CreateWorkspace handles POST /api/workspaces/from-template?template=<id>.
+// The body is optional; its name overrides the template's. The workspace
+// exists on return; its files are copied by the operation returned with it.
+func (h *GalleryHandler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	templateID := r.URL.Query().Get("template")
+	if templateID == "" {
+		http.Error(w, "Missing required parameter: template", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.TemplateWorkspaceRequest
+	if !decodeOptionalRequest(w, r.Body, &req) {
+		return
+	}
+
+	workspace, operation, err := h.galleryService.CreateWorkspace(r.Context(), templateID, domain.CreateWorkspaceRequest{Name: req.Name}, authCtx.UserID, authCtx.UserTier)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case err.Error() == "template not found":
+			status = http.StatusNotFound
+		case strings.HasPrefix(err.Error(), "workspace limit reached"):
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/operations/"+operation.ID.String())
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workspace": workspace,
+		"operation": operation,
+	})
+}
+
+// CloneWorkspace handles POST /api/workspaces/{id}/clone. The body is
+// optional. As with templates, the new workspace exists on return and its
+// files are copied by the operation returned with it.
+func (h *GalleryHandler) CloneWorkspace(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	sourceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.CloneWorkspaceRequest
+	if !decodeOptionalRequest(w, r.Body, &req) {
+		return
+	}
+
+	workspace, operation, err := h.galleryService.CloneWorkspace(r.Context(), sourceID, req, authCtx.UserID, authCtx.UserTier)
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		status := http.StatusInternalServerError
+		switch {
+		case strings.HasPrefix(err.Error(), "workspace not found"):
+			status = http.StatusNotFound
+		case strings.HasPrefix(err.Error(), "workspace limit reached"), strings.HasPrefix(err.Error(), "file limit reached"):
+			status = http.StatusForbidden
+		case strings.HasPrefix(err.Error(), "storage limit exceeded"), strings.HasPrefix(err.Error(), "file too large"):
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/operations/"+operation.ID.String())
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workspace": workspace,
+		"operation": operation,
+	})
+}
+
+func (h *GalleryHandler) RegisterRoutes(r *Router) {
+	r.User("GET /api/gallery", h.ListTemplates)
+	r.User("POST /api/workspaces/from-template", h.CreateWorkspace)
+	r.User("POST /api/workspaces/{id}/clone", h.CloneWorkspace)
+}
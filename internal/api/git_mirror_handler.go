@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/pkg/webhook"
+	"github.com/google/uuid"
+)
+
+// GitMirrorHandler lets workspace owners mirror their workspace to a Git
+// repository.
+type GitMirrorHandler struct {
+	gitMirrorService *services.GitMirrorService
+	pushes           *webhook.Verifier
+}
+
+func NewGitMirrorHandler(gitMirrorService *services.GitMirrorService) *GitMirrorHandler {
+	return &GitMirrorHandler{
+		gitMirrorService: gitMirrorService,
+	}
+}
+
+// WithPushVerifier serves POST /api/webhooks/git, through which Git hosts
+// report pushes, checking each request's signature with pushes.
+func (h *GitMirrorHandler) WithPushVerifier(pushes *webhook.Verifier) *GitMirrorHandler {
+	h.pushes = pushes
+	return h
+}
+
+func (h *GitMirrorHandler) GetMirror(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	mirror, err := h.gitMirrorService.GetMirror(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		writeGitMirrorError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mirror)
+}
+
+// ConfigureMirror sets up the workspace's mirror or changes it. The
+// response carries the deploy key to add to the repository.
+func (h *GitMirrorHandler) ConfigureMirror(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.ConfigureGitMirrorRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	mirror, err := h.gitMirrorService.ConfigureMirror(r.Context(), workspaceID, authCtx.UserID, req)
+	if err != nil {
+		writeGitMirrorError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mirror)
+}
+
+func (h *GitMirrorHandler) DeleteMirror(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.gitMirrorService.DeleteMirror(r.Context(), workspaceID, authCtx.UserID); err != nil {
+		writeGitMirrorError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SyncMirror has the mirror synced shortly, for after the deploy key was
+// added or the repository fixed. It answers before the sync runs.
+func (h *GitMirrorHandler) SyncMirror(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	mirror, err := h.gitMirrorService.RequestSync(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		writeGitMirrorError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(mirror)
+}
+
+// NotifyPush handles a signed push notification. The signature has been
+// checked by the time it runs.
+func (h *GitMirrorHandler) NotifyPush(w http.ResponseWriter, r *http.Request) {
+	var req domain.GitPushNotification
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	requested, err := h.gitMirrorService.NotifyPush(r.Context(), req)
+	if err != nil {
+		writeGitMirrorError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mirrors": requested,
+	})
+}
+
+func writeGitMirrorError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case strings.HasPrefix(err.Error(), "invalid"):
+		status = http.StatusBadRequest
+	case strings.HasPrefix(err.Error(), "workspace not found"), err.Error() == "git mirror not found":
+		status = http.StatusNotFound
+	case strings.HasPrefix(err.Error(), "git mirroring is not enabled"):
+		status = http.StatusNotImplemented
+	default:
+		if s, ok := workspaceAccessStatus(err); ok {
+			status = s
+		}
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func (h *GitMirrorHandler) RegisterRoutes(r *Router) {
+	r.User("GET /api/workspaces/{workspace_id}/git-mirror", h.GetMirror)
+	r.User("PUT /api/workspaces/{workspace_id}/git-mirror", h.ConfigureMirror)
+	r.User("DELETE /api/workspaces/{workspace_id}/git-mirror", h.DeleteMirror)
+	r.User("POST /api/workspaces/{workspace_id}/git-mirror/sync", h.SyncMirror)
+	if h.pushes != nil {
+		r.Experimental().Public("POST /api/webhooks/git", h.pushes.Middleware(h.NotifyPush))
+	}
+}
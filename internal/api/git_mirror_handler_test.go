@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/duckonomy/noture/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitMirrorHandler_NotifyPush(t *testing.T) {
+	mux := http.NewServeMux()
+	router := NewRouter(mux, auth.NewAuthMiddleware(nil, nil), NewCapabilities("test"))
+	verifier := webhook.NewVerifier("git", "secret")
+	(&GitMirrorHandler{}).WithPushVerifier(verifier).RegisterRoutes(router)
+
+	post := func(body, signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/webhooks/git", strings.NewReader(body))
+		if signature != "" {
+			req.Header.Set(webhook.DefaultHeader, signature)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	body := `{"branch": "main"}`
+	assert.Equal(t, http.StatusUnauthorized, post(body, ""))
+	assert.Equal(t, http.StatusUnauthorized, post(body, webhook.NewVerifier("git", "other").Sign([]byte(body), time.Now())))
+	assert.Equal(t, http.StatusBadRequest, post(body, verifier.Sign([]byte(body), time.Now())), "signed requests reach the handler")
+
+	t.Run("not served without a secret", func(t *testing.T) {
+		mux := http.NewServeMux()
+		(&GitMirrorHandler{}).RegisterRoutes(NewRouter(mux, auth.NewAuthMiddleware(nil, nil), NewCapabilities("test")))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/webhooks/git", strings.NewReader(body)))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
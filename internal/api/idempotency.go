@@ -0,0 +1,175 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// maxIdempotentResponseSize caps a stored response. Larger responses are
+// sent but not stored, so a retry runs the request again.
+const maxIdempotentResponseSize = 1 << 20
+
+// replayedHeaders are the response headers stored with an idempotent
+// response and sent again on replay.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// IdempotencyStore keeps the responses of idempotent requests. It is
+// implemented by services.IdempotencyService.
+type IdempotencyStore interface {
+	Claim(ctx context.Context, userID uuid.UUID, key string) (*domain.IdempotentResponse, error)
+	Complete(ctx context.Context, userID uuid.UUID, key string, response domain.IdempotentResponse) error
+	Release(ctx context.Context, userID uuid.UUID, key string) error
+}
+
+// Idempotency lets clients retry mutating requests safely. A request sent
+// with an Idempotency-Key header runs once per user and key; retries get
+// the first response again, marked with Idempotent-Replayed. Reusing a key
+// for a different request (method, URL or body) is refused with 422, and a
+// retry arriving while the first request runs with 409. Server errors are
+// not stored, so those can be retried with the same key.
+type Idempotency struct {
+	store IdempotencyStore
+	log   *logger.Logger
+}
+
+func NewIdempotency(store IdempotencyStore) *Idempotency {
+	return &Idempotency{
+		store: store,
+		log:   logger.New(),
+	}
+}
+
+// Middleware must run after authentication, since keys are per user.
+func (i *Idempotency) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(domain.IdempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > domain.MaxIdempotencyKeyLength {
+			http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+		authCtx, ok := requireAuthContext(w, r)
+		if !ok {
+			return
+		}
+		log := i.log.WithContext(r.Context()).WithUser(authCtx.UserID.String(), "")
+
+		// The body is hashed as the handler reads it, so large uploads
+		// are not buffered.
+		fingerprint := sha256.New()
+		io.WriteString(fingerprint, r.Method+" "+r.URL.RequestURI()+"\n")
+		body := &fingerprintBody{ReadCloser: r.Body, hash: fingerprint}
+		r.Body = body
+
+		stored, err := i.store.Claim(r.Context(), authCtx.UserID, key)
+		if err != nil {
+			log.WithError(err).Error("Failed to claim idempotency key")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if stored != nil {
+			switch {
+			case stored.Pending:
+				http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+			case stored.Fingerprint != body.sum():
+				http.Error(w, "Idempotency-Key was used for a different request", http.StatusUnprocessableEntity)
+			default:
+				for name, value := range stored.Header {
+					w.Header().Set(name, value)
+				}
+				w.Header().Set(domain.IdempotentReplayedHeader, "true")
+				w.WriteHeader(stored.StatusCode)
+				w.Write(stored.Body)
+			}
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		// The request's context may be canceled once the client has its
+		// response, which must not lose the stored copy.
+		ctx := logger.Detach(r.Context())
+		if rec.status >= http.StatusInternalServerError || rec.overflow {
+			if err := i.store.Release(ctx, authCtx.UserID, key); err != nil {
+				log.WithError(err).Error("Failed to release idempotency key")
+			}
+			return
+		}
+		response := domain.IdempotentResponse{
+			Fingerprint: body.sum(),
+			StatusCode:  rec.status,
+			Header:      make(map[string]string),
+			Body:        rec.body.Bytes(),
+		}
+		for _, name := range replayedHeaders {
+			if value := rec.Header().Get(name); value != "" {
+				response.Header[name] = value
+			}
+		}
+		if err := i.store.Complete(ctx, authCtx.UserID, key, response); err != nil {
+			log.WithError(err).Error("Failed to store idempotent response")
+		}
+	}
+}
+
+// fingerprintBody hashes a request body as it is read. sum drains what
+// the handler left unread, so the hash always covers the whole body.
+type fingerprintBody struct {
+	io.ReadCloser
+	hash hash.Hash
+}
+
+func (b *fingerprintBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	return n, err
+}
+
+func (b *fingerprintBody) sum() string {
+	io.Copy(io.Discard, b)
+	return hex.EncodeToString(b.hash.Sum(nil))
+}
+
+// responseRecorder passes a response through while keeping a copy of up to
+// maxIdempotentResponseSize bytes.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	overflow    bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	if !r.overflow {
+		if r.body.Len()+len(p) > maxIdempotentResponseSize {
+			r.overflow = true
+			r.body.Reset()
+		} else {
+			r.body.Write(p)
+		}
+	}
+	return r.ResponseWriter.Write(p)
+}
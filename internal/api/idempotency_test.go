@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryIdempotencyStore struct {
+	mu        sync.Mutex
+	responses map[string]*domain.IdempotentResponse
+}
+
+func (s *memoryIdempotencyStore) Claim(ctx context.Context, userID uuid.UUID, key string) (*domain.IdempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored, ok := s.responses[userID.String()+key]; ok {
+		return stored, nil
+	}
+	s.responses[userID.String()+key] = &domain.IdempotentResponse{Pending: true}
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, userID uuid.UUID, key string, response domain.IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[userID.String()+key] = &response
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, userID uuid.UUID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.responses, userID.String()+key)
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	store := &memoryIdempotencyStore{responses: make(map[string]*domain.IdempotentResponse)}
+	userID := uuid.New()
+
+	calls := 0
+	status := http.StatusCreated
+	handler := NewIdempotency(store).Middleware(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Other", "not replayed")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"call":%d,"body":%q}`, calls, body)
+	})
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/files/upload", strings.NewReader(body))
+		req = req.WithContext(auth.NewContext(req.Context(), &domain.AuthContext{UserID: userID}))
+		if key != "" {
+			req.Header.Set(domain.IdempotencyKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	t.Run("requests without a key always run", func(t *testing.T) {
+		send("", "a")
+		send("", "a")
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("a retry replays the first response", func(t *testing.T) {
+		first := send("k1", "a")
+		require.Equal(t, http.StatusCreated, first.Code)
+
+		retry := send("k1", "a")
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+		assert.Empty(t, retry.Header().Get("X-Other"))
+		assert.Equal(t, "true", retry.Header().Get(domain.IdempotentReplayedHeader))
+	})
+
+	t.Run("a key reused for another request is refused", func(t *testing.T) {
+		rec := send("k1", "b")
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("a key in use is refused", func(t *testing.T) {
+		store.responses[userID.String()+"k2"] = &domain.IdempotentResponse{Pending: true}
+		assert.Equal(t, http.StatusConflict, send("k2", "a").Code)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("server errors are not stored", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		send("k3", "a")
+		status = http.StatusOK
+		rec := send("k3", "a")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 5, calls)
+	})
+
+	t.Run("overlong keys are refused", func(t *testing.T) {
+		rec := send(strings.Repeat("k", domain.MaxIdempotencyKeyLength+1), "a")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestRouter_Idempotent(t *testing.T) {
+	router := NewRouter(http.NewServeMux(), auth.NewAuthMiddleware(nil, nil), NewCapabilities("test"))
+	(&FileHandler{}).RegisterRoutes(router)
+
+	idempotent := make(map[string]bool)
+	for _, route := range router.Routes() {
+		idempotent[route.Pattern] = route.Idempotent
+	}
+	assert.True(t, idempotent["POST /api/files/upload"])
+	assert.True(t, idempotent["DELETE /api/files/{workspace_id}/{file_path...}"])
+	assert.True(t, idempotent["POST /api/workspaces/{workspace_id}/save-set"])
+	assert.False(t, idempotent["GET /api/files/{workspace_id}/{file_path...}"])
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/ratelimit"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/google/uuid"
+)
+
+// ImportHandler imports notes exported from other apps.
+type ImportHandler struct {
+	importService *services.ImportService
+}
+
+func NewImportHandler(importService *services.ImportService) *ImportHandler {
+	return &ImportHandler{
+		importService: importService,
+	}
+}
+
+// ImportNotion takes a Notion export zip as the multipart field file and
+// an optional target folder, and queues the import. Clients poll the
+// operation for its result.
+func (h *ImportHandler) ImportNotion(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	// Leave room for the rest of the form next to the archive. Archives
+	// larger than what is kept in memory are spooled to disk.
+	r.Body = http.MaxBytesReader(w, r.Body, domain.MaxNotionImportBytes+1<<20)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("archive too large: limit %d bytes", domain.MaxNotionImportBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file in form data", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	operation, err := h.importService.ImportNotion(r.Context(), workspaceID, authCtx.UserID, file, header.Size, r.FormValue("folder"))
+	if err != nil {
+		writeImportError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/operations/"+operation.ID.String())
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"operation": operation,
+	})
+}
+
+func writeImportError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case strings.HasPrefix(err.Error(), "invalid"):
+		status = http.StatusBadRequest
+	case strings.HasPrefix(err.Error(), "workspace not found"):
+		status = http.StatusNotFound
+	case err.Error() == "workspace is archived":
+		status = http.StatusConflict
+	default:
+		if s, ok := workspaceAccessStatus(err); ok {
+			status = s
+		}
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func (h *ImportHandler) RegisterRoutes(r *Router) {
+	r.Experimental().RateGroup(ratelimit.GroupUpload).User("POST /api/workspaces/{workspace_id}/import/notion", h.ImportNotion)
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/google/uuid"
+)
+
+type InviteHandler struct {
+	inviteService *services.InviteService
+}
+
+func NewInviteHandler(inviteService *services.InviteService) *InviteHandler {
+	return &InviteHandler{
+		inviteService: inviteService,
+	}
+}
+
+// CreateInvite emails an invitation to join the workspace.
+func (h *InviteHandler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.CreateInviteRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+	if req.Role == "" {
+		req.Role = domain.RoleViewer
+	}
+
+	invite, err := h.inviteService.CreateInvite(r.Context(), workspaceID, authCtx.UserID, req)
+	if err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invite)
+}
+
+func (h *InviteHandler) ListInvites(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	invites, err := h.inviteService.ListInvites(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"invites": invites,
+		"count":   len(invites),
+	})
+}
+
+func (h *InviteHandler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	inviteID, err := uuid.Parse(r.PathValue("invite_id"))
+	if err != nil {
+		http.Error(w, "Invalid invite ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.inviteService.RevokeInvite(r.Context(), workspaceID, authCtx.UserID, inviteID); err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PreviewInvite shows what an invite link is for. It needs no sign-in:
+// the token in the path is the credential.
+func (h *InviteHandler) PreviewInvite(w http.ResponseWriter, r *http.Request) {
+	preview, err := h.inviteService.PreviewInvite(r.Context(), r.PathValue("token"))
+	if err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(preview)
+}
+
+// AcceptInvite adds the signed-in user to the invite's workspace.
+func (h *InviteHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	member, err := h.inviteService.AcceptInvite(r.Context(), r.PathValue("token"), authCtx.UserID)
+	if err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(member)
+}
+
+func (h *InviteHandler) RegisterRoutes(r *Router) {
+	r.User("GET /api/workspaces/{id}/invites", h.ListInvites)
+	r.User("POST /api/workspaces/{id}/invites", h.CreateInvite)
+	r.User("DELETE /api/workspaces/{id}/invites/{invite_id}", h.RevokeInvite)
+	r.Public("GET /api/invites/{token}", h.PreviewInvite)
+	r.Account("POST /api/invites/{token}/accept", h.AcceptInvite)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/pkg/logger"
+)
+
+// loginGuard applies a LoginThrottle to the sign-in handlers. Its zero
+// value lets everything through, so handlers built without a throttle
+// behave as before.
+type loginGuard struct {
+	throttle *services.LoginThrottle
+	clientIP func(*http.Request) string
+	log      *logger.Logger
+}
+
+func newLoginGuard(throttle *services.LoginThrottle, clientIP func(*http.Request) string) loginGuard {
+	if clientIP == nil {
+		clientIP = remoteIP
+	}
+	return loginGuard{
+		throttle: throttle,
+		clientIP: clientIP,
+		log:      logger.New(),
+	}
+}
+
+// keys returns the throttle keys of a sign-in attempt: the client's IP,
+// and the account of email when one is named.
+func (g loginGuard) keys(r *http.Request, email string) []string {
+	if g.throttle == nil {
+		return nil
+	}
+	keys := []string{services.IPThrottleKey(g.clientIP(r))}
+	if email != "" {
+		keys = append(keys, services.AccountThrottleKey(email))
+	}
+	return keys
+}
+
+// blocked answers 429 with Retry-After and reports true when any of keys
+// must still wait. Errors checking are logged and let the attempt
+// through, so that a database hiccup does not lock everyone out.
+func (g loginGuard) blocked(w http.ResponseWriter, r *http.Request, keys []string) bool {
+	if g.throttle == nil {
+		return false
+	}
+	wait, err := g.throttle.Wait(r.Context(), keys...)
+	if err != nil {
+		g.log.WithContext(r.Context()).WithError(err).Error("Failed to check sign-in throttle")
+		return false
+	}
+	if wait <= 0 {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(wait.Seconds())))))
+	http.Error(w, "Too many failed sign-in attempts; try again later", http.StatusTooManyRequests)
+	return true
+}
+
+// fail counts a failed attempt by method against keys.
+func (g loginGuard) fail(r *http.Request, method string, keys []string) {
+	if g.throttle == nil {
+		return
+	}
+	if err := g.throttle.Fail(r.Context(), method, keys...); err != nil {
+		g.log.WithContext(r.Context()).WithError(err).Error("Failed to record sign-in failure")
+	}
+}
+
+// succeed forgets the failures of the account of email.
+func (g loginGuard) succeed(r *http.Request, email string) {
+	if g.throttle == nil {
+		return
+	}
+	if err := g.throttle.Succeed(r.Context(), services.AccountThrottleKey(email)); err != nil {
+		g.log.WithContext(r.Context()).WithError(err).Error("Failed to clear sign-in failures")
+	}
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/google/uuid"
+)
+
+type MemberHandler struct {
+	memberService *services.MemberService
+}
+
+func NewMemberHandler(memberService *services.MemberService) *MemberHandler {
+	return &MemberHandler{
+		memberService: memberService,
+	}
+}
+
+func (h *MemberHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	members, err := h.memberService.ListMembers(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"members": members,
+		"count":   len(members),
+	})
+}
+
+// AddMember invites a registered user to the workspace by email.
+func (h *MemberHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.AddMemberRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+	if req.Role == "" {
+		req.Role = domain.RoleViewer
+	}
+
+	member, err := h.memberService.AddMember(r.Context(), workspaceID, authCtx.UserID, req)
+	if err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(member)
+}
+
+func (h *MemberHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	memberID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.UpdateMemberRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	if err := h.memberService.UpdateMemberRole(r.Context(), workspaceID, authCtx.UserID, memberID, req.Role); err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *MemberHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	memberID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.memberService.RemoveMember(r.Context(), workspaceID, authCtx.UserID, memberID); err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeMemberError(w http.ResponseWriter, err error) {
+	if status, ok := workspaceAccessStatus(err); ok {
+		if status == http.StatusNotFound {
+			http.Error(w, "Workspace not found", status)
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "workspace not found"), strings.HasPrefix(msg, "member not found"), strings.HasPrefix(msg, "user not found"), msg == "invite not found":
+		http.Error(w, msg, http.StatusNotFound)
+	case strings.HasPrefix(msg, "invalid role"), strings.HasPrefix(msg, "invalid email"):
+		http.Error(w, msg, http.StatusBadRequest)
+	case strings.HasPrefix(msg, "user is already a member"), strings.HasPrefix(msg, "cannot "):
+		http.Error(w, msg, http.StatusConflict)
+	default:
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+func (h *MemberHandler) RegisterRoutes(r *Router) {
+	r.User("GET /api/workspaces/{id}/members", h.ListMembers)
+	r.User("POST /api/workspaces/{id}/members", h.AddMember)
+	r.User("PATCH /api/workspaces/{id}/members/{user_id}", h.UpdateMember)
+	r.User("DELETE /api/workspaces/{id}/members/{user_id}", h.RemoveMember)
+}
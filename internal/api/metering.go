@@ -0,0 +1,78 @@
+package api
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/google/uuid"
+)
+
+// UsageRecorder counts the requests of signed-in users towards their
+// monthly usage.
+type UsageRecorder interface {
+	Record(userID uuid.UUID, uploadBytes, downloadBytes int64)
+}
+
+// WithUsageMeter counts every authenticated request, with its request and
+// response bodies, towards the caller's monthly usage. Bodies are counted
+// decoded, so compression does not change what a user is billed.
+func (rt *Router) WithUsageMeter(meter UsageRecorder) *Router {
+	rt.meter = meter
+	return rt
+}
+
+// metered wraps a handler that runs after authentication so it records the
+// caller's usage.
+func (rt *Router) metered(handler http.HandlerFunc) http.HandlerFunc {
+	if rt.meter == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		authCtx, ok := auth.FromContext(r.Context())
+		if !ok {
+			handler(w, r)
+			return
+		}
+
+		var body *meteredBody
+		if r.Body != nil && r.Body != http.NoBody {
+			body = &meteredBody{ReadCloser: r.Body}
+			r.Body = body
+		}
+		mw := &meteredWriter{ResponseWriter: w}
+		handler(mw, r)
+
+		var uploaded int64
+		if body != nil {
+			uploaded = body.n
+		}
+		rt.meter.Record(authCtx.UserID, uploaded, mw.n)
+	}
+}
+
+type meteredBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+type meteredWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (w *meteredWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *meteredWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
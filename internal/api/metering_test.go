@@ -0,0 +1,45 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type usageRecord struct {
+	userID                     uuid.UUID
+	uploadBytes, downloadBytes int64
+}
+
+type recordingMeter struct {
+	records []usageRecord
+}
+
+func (m *recordingMeter) Record(userID uuid.UUID, uploadBytes, downloadBytes int64) {
+	m.records = append(m.records, usageRecord{userID, uploadBytes, downloadBytes})
+}
+
+func TestRouter_Metered(t *testing.T) {
+	meter := &recordingMeter{}
+	router := NewRouter(http.NewServeMux(), auth.NewAuthMiddleware(nil, nil), NewCapabilities("test")).WithUsageMeter(meter)
+	handler := router.metered(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte("hello, world"))
+	})
+
+	userID := uuid.New()
+	r := httptest.NewRequest("POST", "/api/x", strings.NewReader("12345"))
+	r = r.WithContext(auth.NewContext(r.Context(), &domain.AuthContext{UserID: userID}))
+	handler(httptest.NewRecorder(), r)
+
+	handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/x", nil))
+
+	assert.Equal(t, []usageRecord{{userID, 5, 12}}, meter.records, "only signed-in requests count")
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"no header keeps the first offer", "", "text/markdown"},
+		{"wildcard", "*/*", "text/markdown"},
+		{"json", "application/json", "application/json"},
+		{"exact type", "text/markdown", "text/markdown"},
+		{"type wildcard", "text/*", "text/markdown"},
+		{"specific beats wildcard", "application/json, */*;q=0.1", "application/json"},
+		{"q-values", "application/json;q=0.5, text/markdown", "text/markdown"},
+		{"browser default", "text/html,application/xhtml+xml,*/*;q=0.8", "text/markdown"},
+		{"excluded by q=0", "text/markdown;q=0, application/json", "application/json"},
+		{"nothing acceptable", "image/png", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, negotiate(tt.accept, "text/markdown", "application/json"))
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/google/uuid"
+)
+
+type NoteTemplateHandler struct {
+	templateService *services.NoteTemplateService
+}
+
+func NewNoteTemplateHandler(templateService *services.NoteTemplateService) *NoteTemplateHandler {
+	return &NoteTemplateHandler{
+		templateService: templateService,
+	}
+}
+
+func (h *NoteTemplateHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	templates, err := h.templateService.ListTemplates(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		writeNoteTemplateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"templates": templates,
+		"count":     len(templates),
+	})
+}
+
+func (h *NoteTemplateHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.CreateNoteTemplateRequest
+	if !decodeRequest(w, http.MaxBytesReader(w, r.Body, 2*domain.MaxNoteTemplateSize), &req) {
+		return
+	}
+
+	template, err := h.templateService.CreateTemplate(r.Context(), workspaceID, authCtx.UserID, req)
+	if err != nil {
+		writeNoteTemplateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(template)
+}
+
+func (h *NoteTemplateHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	templateID, err := uuid.Parse(r.PathValue("template_id"))
+	if err != nil {
+		http.Error(w, "Invalid template ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.templateService.DeleteTemplate(r.Context(), workspaceID, authCtx.UserID, templateID); err != nil {
+		writeNoteTemplateError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateFileFromTemplate creates a file with a template's content, its
+// placeholders filled in.
+func (h *NoteTemplateHandler) CreateFileFromTemplate(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	var req domain.CreateFileFromTemplateRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	result, err := h.templateService.CreateFileFromTemplate(r.Context(), req, authCtx.UserID)
+	if err != nil {
+		writeNoteTemplateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetDailyNote returns the daily note of the date query parameter, or of
+// today in the user's timezone, so calendar clients can open any day.
+func (h *NoteTemplateHandler) GetDailyNote(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	note, err := h.templateService.GetDailyNote(r.Context(), workspaceID, authCtx.UserID, r.URL.Query().Get("date"))
+	if err != nil {
+		writeNoteTemplateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(note)
+}
+
+// CreateDailyNote returns the day's note, creating it first if needed:
+// 201 when it was created, 200 when it already existed.
+func (h *NoteTemplateHandler) CreateDailyNote(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.CreateDailyNoteRequest
+	if r.ContentLength != 0 {
+		if !decodeRequest(w, r.Body, &req) {
+			return
+		}
+	}
+
+	note, err := h.templateService.CreateDailyNote(r.Context(), workspaceID, authCtx.UserID, req)
+	if err != nil {
+		writeNoteTemplateError(w, err)
+		return
+	}
+
+	status := http.StatusOK
+	if note.Created {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(note)
+}
+
+func (h *NoteTemplateHandler) GetDailyNoteSettings(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := h.templateService.GetDailyNoteSettings(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		writeNoteTemplateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+func (h *NoteTemplateHandler) UpdateDailyNoteSettings(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.DailyNoteSettings
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	settings, err := h.templateService.UpdateDailyNoteSettings(r.Context(), workspaceID, authCtx.UserID, req)
+	if err != nil {
+		writeNoteTemplateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+func writeNoteTemplateError(w http.ResponseWriter, err error) {
+	if status, ok := workspaceAccessStatus(err); ok {
+		if status == http.StatusNotFound {
+			http.Error(w, "Workspace not found", status)
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "workspace not found"), msg == "template not found", msg == "daily note not found":
+		http.Error(w, msg, http.StatusNotFound)
+	case strings.HasPrefix(msg, "invalid"):
+		http.Error(w, msg, http.StatusBadRequest)
+	case strings.HasPrefix(msg, "storage limit exceeded"), strings.HasPrefix(msg, "file too large"):
+		http.Error(w, msg, http.StatusRequestEntityTooLarge)
+	case strings.HasPrefix(msg, "file limit reached"):
+		http.Error(w, msg, http.StatusForbidden)
+	case strings.HasPrefix(msg, "template already exists"), msg == "file already exists", msg == "workspace is archived":
+		http.Error(w, msg, http.StatusConflict)
+	default:
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+func (h *NoteTemplateHandler) RegisterRoutes(r *Router) {
+	r.User("GET /api/workspaces/{id}/templates", h.ListTemplates)
+	r.User("POST /api/workspaces/{id}/templates", h.CreateTemplate)
+	r.User("DELETE /api/workspaces/{id}/templates/{template_id}", h.DeleteTemplate)
+	r.Idempotent().User("POST /api/files/from-template", h.CreateFileFromTemplate)
+	r.User("GET /api/workspaces/{id}/daily", h.GetDailyNote)
+	r.User("POST /api/workspaces/{id}/daily", h.CreateDailyNote)
+	r.User("GET /api/workspaces/{id}/daily/settings", h.GetDailyNoteSettings)
+	r.User("PUT /api/workspaces/{id}/daily/settings", h.UpdateDailyNoteSettings)
+}
@@ -1,39 +1,40 @@
 package api
 
 import (
-	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/duckonomy/noture/internal/config"
 	"github.com/duckonomy/noture/internal/db"
 	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
 	"github.com/duckonomy/noture/pkg/logger"
 	"github.com/duckonomy/noture/pkg/oauth"
-	"github.com/duckonomy/noture/pkg/pgconv"
-	"github.com/google/uuid"
 )
 
 type OAuthHandler struct {
-	queries      *db.Queries
-	googleConfig *oauth.GoogleOAuthConfig
-	githubConfig *oauth.GitHubOAuthConfig
-	log          *logger.Logger
-	// TODO: Redis
-	pendingAuth map[string]*PendingAuthSession
+	queries    *db.Queries
+	providers  map[string]oauth.Provider
+	sessions   services.AuthSessionStore
+	devices    *services.DeviceService
+	identities *services.IdentityService
+	baseURL    string
+	guard      loginGuard
+	log        *logger.Logger
 }
 
-type PendingAuthSession struct {
-	State      string
-	DeviceCode string
-	CreatedAt  time.Time
-	ExpiresAt  time.Time
-}
+const (
+	oauthStateTTL = 10 * time.Minute
+	deviceCodeTTL = 10 * time.Minute
+	authCodeTTL   = 5 * time.Minute
+)
 
 type DeviceAuthRequest struct {
 	DeviceName string `json:"device_name,omitempty"`
@@ -51,103 +52,141 @@ type AuthCallbackResponse struct {
 	Success     bool   `json:"success"`
 	Message     string `json:"message"`
 	RedirectURL string `json:"redirect_url,omitempty"`
+	// Code is the authorization code a sign-in started with a
+	// code_challenge ends with, to redeem at /auth/token.
+	Code string `json:"code,omitempty"`
 }
 
-func NewOAuthHandler(queries *db.Queries) *OAuthHandler {
-	log := logger.New()
-
-	googleClientID := os.Getenv("GOOGLE_CLIENT_ID")
-	googleClientSecret := os.Getenv("GOOGLE_CLIENT_SECRET")
+// TokenRequest redeems the authorization code of a PKCE sign-in with the
+// verifier of its code_challenge.
+type TokenRequest struct {
+	Code         string `json:"code"`
+	CodeVerifier string `json:"code_verifier"`
+}
 
-	if googleClientID == "" || googleClientSecret == "" {
-		log.Warn("Google OAuth credentials not configured",
-			"client_id_set", googleClientID != "",
-			"client_secret_set", googleClientSecret != "")
+// NewOAuthHandler builds the sign-in and account linking handlers for the
+// providers configured in oauthConfig. baseURL is the server's public
+// URL, used for the callback and device verification links.
+func NewOAuthHandler(queries *db.Queries, sessions services.AuthSessionStore, devices *services.DeviceService, identities *services.IdentityService, oauthConfig config.OAuth, baseURL string) *OAuthHandler {
+	log := logger.New()
+	h := &OAuthHandler{
+		queries:    queries,
+		providers:  make(map[string]oauth.Provider),
+		sessions:   sessions,
+		devices:    devices,
+		identities: identities,
+		baseURL:    baseURL,
+		log:        log,
 	}
 
-	githubClientID := os.Getenv("GITHUB_CLIENT_ID")
-	githubClientSecret := os.Getenv("GITHUB_CLIENT_SECRET")
-
-	if githubClientID == "" || githubClientSecret == "" {
-		log.Warn("GitHub OAuth credentials not configured",
-			"client_id_set", githubClientID != "",
-			"client_secret_set", githubClientSecret != "")
+	if google := oauthConfig.Google; google.Configured() {
+		h.WithProvider(oauth.NewGoogleOAuthConfig(google.ClientID, google.ClientSecret, h.CallbackURL(domain.ProviderGoogle)))
+	} else {
+		log.Warn("Google OAuth credentials not configured")
+	}
+	if github := oauthConfig.GitHub; github.Configured() {
+		h.WithProvider(oauth.NewGitHubOAuthConfig(github.ClientID, github.ClientSecret, h.CallbackURL(domain.ProviderGitHub), log))
+	} else {
+		log.Warn("GitHub OAuth credentials not configured")
+	}
+	if microsoft := oauthConfig.Microsoft; microsoft.Configured() {
+		h.WithProvider(oauth.NewMicrosoftOAuthConfig(microsoft.ClientID, microsoft.ClientSecret, h.CallbackURL(domain.ProviderMicrosoft), log))
+	} else {
+		log.Warn("Microsoft OAuth credentials not configured")
+	}
+	if apple := oauthConfig.Apple; apple.Configured() {
+		// Load has already rejected keys that do not parse.
+		privateKey, _ := oauth.ParseApplePrivateKey(apple.PrivateKey)
+		h.WithProvider(oauth.NewAppleOAuthConfig(apple.ClientID, apple.TeamID, apple.KeyID, privateKey, h.CallbackURL(domain.ProviderApple), log))
+	} else {
+		log.Warn("Apple OAuth credentials not configured")
 	}
 
-	baseURL := os.Getenv("BASE_URL")
-	if baseURL == "" {
-		baseURL = "http://localhost:8090"
+	if oauthConfig.Dev {
+		log.Warn("Dev OAuth provider enabled: anyone can sign in as any user")
+		h.WithProvider(oauth.NewDevProvider(h.CallbackURL(domain.ProviderDev)))
 	}
 
-	googleRedirectURL := baseURL + "/auth/google/callback"
-	githubRedirectURL := baseURL + "/auth/github/callback"
+	return h
+}
 
-	return &OAuthHandler{
-		queries:      queries,
-		googleConfig: oauth.NewGoogleOAuthConfig(googleClientID, googleClientSecret, googleRedirectURL),
-		githubConfig: oauth.NewGitHubOAuthConfig(githubClientID, githubClientSecret, githubRedirectURL, log),
-		log:          log,
-		pendingAuth:  make(map[string]*PendingAuthSession),
-	}
+// WithProvider adds a sign-in provider, replacing any of the same name.
+// Its redirect URL must be CallbackURL of its name.
+func (h *OAuthHandler) WithProvider(provider oauth.Provider) *OAuthHandler {
+	h.providers[provider.Name()] = provider
+	return h
+}
+
+// CallbackURL is the redirect URL of the provider named name.
+func (h *OAuthHandler) CallbackURL(name string) string {
+	return h.baseURL + "/auth/" + name + "/callback"
+}
+
+// WithThrottle makes client IPs that keep polling with unknown device
+// codes wait before polling again. clientIP tells the client's IP from a
+// request; nil uses the connection's address.
+func (h *OAuthHandler) WithThrottle(throttle *services.LoginThrottle, clientIP func(*http.Request) string) *OAuthHandler {
+	h.guard = newLoginGuard(throttle, clientIP)
+	return h
 }
 
-func (h *OAuthHandler) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("POST /auth/device", h.StartDeviceAuth)
-	mux.HandleFunc("GET /auth/device/poll", h.PollDeviceAuth)
+func (h *OAuthHandler) RegisterRoutes(r *Router) {
+	r.Public("POST /auth/device", h.StartDeviceAuth)
+	r.Public("GET /auth/device/poll", h.PollDeviceAuth)
+	r.Experimental().Public("POST /auth/token", h.RedeemAuthCode)
 
-	mux.HandleFunc("GET /auth/google/login", h.GoogleLogin)
-	mux.HandleFunc("GET /auth/google/callback", h.GoogleCallback)
+	r.Public("GET /auth/{provider}/login", h.OAuthLogin)
+	r.Public("GET /auth/{provider}/callback", h.OAuthCallback)
+	r.Public("POST /auth/{provider}/callback", h.OAuthCallback)
 
-	mux.HandleFunc("GET /auth/github/login", h.GitHubLogin)
-	mux.HandleFunc("GET /auth/github/callback", h.GitHubCallback)
+	r.Account("GET /api/me/identities", h.ListIdentities)
+	r.Account("POST /api/me/identities/{provider}", h.LinkIdentity)
+	r.Account("DELETE /api/me/identities/{provider}", h.UnlinkIdentity)
 }
 
 func (h *OAuthHandler) StartDeviceAuth(w http.ResponseWriter, r *http.Request) {
-	h.log.Info("Starting device authentication flow")
+	h.log.WithContext(r.Context()).Info("Starting device authentication flow")
 
 	var req DeviceAuthRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.log.WithError(err).Error("Failed to decode device auth request")
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+	if utf8.RuneCountInString(req.DeviceName) > domain.MaxDeviceNameLength {
+		http.Error(w, "device_name is too long", http.StatusBadRequest)
 		return
 	}
 
 	deviceCode, err := generateRandomCode(32)
 	if err != nil {
-		h.log.WithError(err).Error("Failed to generate device code")
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to generate device code")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
 	userCode, err := generateUserCode()
 	if err != nil {
-		h.log.WithError(err).Error("Failed to generate user code")
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to generate user code")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	expiresAt := time.Now().Add(10 * time.Minute)
-	h.pendingAuth[deviceCode] = &PendingAuthSession{
-		DeviceCode: deviceCode,
-		CreatedAt:  time.Now(),
-		ExpiresAt:  expiresAt,
+	if err := h.sessions.CreateDeviceSession(r.Context(), deviceCode, userCode, req.DeviceName, deviceCodeTTL); err != nil {
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to store device session")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	baseURL := os.Getenv("BASE_URL")
-	if baseURL == "" {
-		baseURL = "http://localhost:8090"
-	}
-	verificationURL := fmt.Sprintf("%s/auth/verify?code=%s", baseURL, userCode)
+	verificationURL := fmt.Sprintf("%s/auth/verify?code=%s", h.baseURL, userCode)
 
 	response := DeviceAuthResponse{
 		DeviceCode:      deviceCode,
 		UserCode:        userCode,
 		VerificationURL: verificationURL,
-		ExpiresIn:       600,
+		ExpiresIn:       int(deviceCodeTTL.Seconds()),
 		Interval:        5,
 	}
 
-	h.log.Info("Device auth session created",
+	h.log.WithContext(r.Context()).Info("Device auth session created",
 		"device_code", deviceCode,
 		"user_code", userCode,
 		"device_name", req.DeviceName)
@@ -163,106 +202,255 @@ func (h *OAuthHandler) PollDeviceAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session, exists := h.pendingAuth[deviceCode]
-	if !exists {
-		http.Error(w, "Invalid device code", http.StatusBadRequest)
+	keys := h.guard.keys(r, "")
+	if h.guard.blocked(w, r, keys) {
 		return
 	}
 
-	if time.Now().After(session.ExpiresAt) {
-		delete(h.pendingAuth, deviceCode)
-		http.Error(w, "Device code expired", http.StatusBadRequest)
+	session, err := h.sessions.GetDeviceSession(r.Context(), deviceCode)
+	if err != nil {
+		if errors.Is(err, services.ErrAuthSessionNotFound) {
+			h.guard.fail(r, "device", keys)
+			http.Error(w, "Invalid or expired device code", http.StatusBadRequest)
+			return
+		}
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to load device session")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// TODO: check if the user has completed OAuth
-	response := map[string]interface{}{
-		"status": "pending",
-		"message": "Waiting for user to complete authentication",
+	if session.UserID == nil {
+		response := map[string]interface{}{
+			"status":  "pending",
+			"message": "Waiting for user to complete authentication",
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
+	// Consuming the session makes sure only one poll receives the token.
+	session, err = h.sessions.ConsumeDeviceSession(r.Context(), deviceCode)
+	if err != nil {
+		http.Error(w, "Invalid or expired device code", http.StatusBadRequest)
+		return
+	}
 
-func (h *OAuthHandler) GoogleLogin(w http.ResponseWriter, r *http.Request) {
-	h.log.Info("Initiating Google OAuth flow")
+	device, err := h.devices.RegisterDevice(r.Context(), *session.UserID, session.DeviceName)
+	if err != nil {
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to register device", "user_id", session.UserID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 
-	state, err := oauth.GenerateState()
+	token, err := issueAPIToken(r.Context(), h.queries, *session.UserID, "Device Token", &device.ID)
 	if err != nil {
-		h.log.WithError(err).Error("Failed to generate OAuth state")
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to generate API token", "user_id", session.UserID)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// TODO: Proper session-store
-	authURL := h.googleConfig.GetAuthURL(state)
-	h.log.Info("Redirecting to Google OAuth", "auth_url", authURL)
+	h.log.WithContext(r.Context()).LogAuthEvent("device_auth_success", session.UserID.String(), "device")
 
-	response := map[string]string{
-		"auth_url": authURL,
-		"state":    state,
+	response := map[string]interface{}{
+		"status":    "complete",
+		"message":   "Authentication successful",
+		"token":     token,
+		"user_id":   session.UserID,
+		"device_id": device.ID,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func (h *OAuthHandler) GoogleCallback(w http.ResponseWriter, r *http.Request) {
-	h.log.Info("Handling Google OAuth callback")
+// RedeemAuthCode trades the authorization code of a PKCE sign-in for a
+// token, given the verifier of the sign-in's code_challenge. A code is
+// spent by its first redemption, even one with the wrong verifier.
+func (h *OAuthHandler) RedeemAuthCode(w http.ResponseWriter, r *http.Request) {
+	var req TokenRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+	if req.Code == "" || req.CodeVerifier == "" {
+		http.Error(w, "code and code_verifier are required", http.StatusBadRequest)
+		return
+	}
 
-	code := r.URL.Query().Get("code")
-	errorParam := r.URL.Query().Get("error")
+	keys := h.guard.keys(r, "")
+	if h.guard.blocked(w, r, keys) {
+		return
+	}
 
-	if errorParam != "" {
-		h.log.Error("OAuth error returned from Google", "error", errorParam)
-		h.sendCallbackResponse(w, false, fmt.Sprintf("OAuth error: %s", errorParam), "")
+	session, err := h.sessions.ConsumeAuthCode(r.Context(), req.Code)
+	if err != nil && !errors.Is(err, services.ErrAuthSessionNotFound) {
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to load authorization code")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || session.UserID == nil || !oauth.VerifyCodeVerifier(req.CodeVerifier, session.CodeChallenge) {
+		h.guard.fail(r, "code", keys)
+		http.Error(w, "Invalid or expired code", http.StatusBadRequest)
 		return
 	}
 
-	if code == "" {
-		h.log.Error("No authorization code received")
-		h.sendCallbackResponse(w, false, "No authorization code received", "")
+	token, err := issueAPIToken(r.Context(), h.queries, *session.UserID, "OAuth Token", nil)
+	if err != nil {
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to generate API token", "user_id", session.UserID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	tokenResponse, err := h.googleConfig.ExchangeCodeForToken(r.Context(), code)
+	h.log.WithContext(r.Context()).LogAuthEvent("oauth_success", session.UserID.String(), "code")
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Authentication successful",
+		"token":   token,
+		"user_id": session.UserID,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// startOAuthState stores a fresh state for provider, with the PKCE
+// verifier the server exchanges the provider's code with. A user_code
+// query parameter ties the login to a pending device authorization, which
+// the callback then approves instead of returning a token to the
+// browser. A code_challenge parameter, from a client that cannot keep a
+// secret, makes the callback return an authorization code instead, which
+// only that client can redeem.
+func (h *OAuthHandler) startOAuthState(r *http.Request, provider string) (string, domain.PKCE, int, error) {
+	query := r.URL.Query()
+	deviceCode := ""
+	if userCode := query.Get("user_code"); userCode != "" {
+		session, err := h.sessions.GetDeviceSessionByUserCode(r.Context(), strings.ToUpper(userCode))
+		if err != nil {
+			return "", domain.PKCE{}, http.StatusBadRequest, fmt.Errorf("invalid or expired user code")
+		}
+		deviceCode = session.SessionKey
+	}
+
+	var pkce domain.PKCE
+	if challenge := query.Get("code_challenge"); challenge != "" {
+		if method := query.Get("code_challenge_method"); method != oauth.PKCEMethod {
+			return "", pkce, http.StatusBadRequest, fmt.Errorf("invalid code_challenge_method: only %s is supported", oauth.PKCEMethod)
+		}
+		if !oauth.ValidCodeChallenge(challenge) {
+			return "", pkce, http.StatusBadRequest, fmt.Errorf("invalid code_challenge")
+		}
+		if deviceCode != "" {
+			return "", pkce, http.StatusBadRequest, fmt.Errorf("invalid code_challenge: device logins finish on the device")
+		}
+		pkce.CodeChallenge = challenge
+	}
+
+	state, err := oauth.GenerateState()
 	if err != nil {
-		h.log.WithError(err).Error("Failed to exchange code for token")
-		h.sendCallbackResponse(w, false, "Failed to exchange authorization code", "")
+		return "", pkce, http.StatusInternalServerError, fmt.Errorf("failed to generate OAuth state: %w", err)
+	}
+	pkce.CodeVerifier, err = oauth.GenerateCodeVerifier()
+	if err != nil {
+		return "", pkce, http.StatusInternalServerError, err
+	}
+
+	if err := h.sessions.CreateOAuthState(r.Context(), state, provider, deviceCode, pkce, oauthStateTTL); err != nil {
+		return "", pkce, http.StatusInternalServerError, err
+	}
+
+	return state, pkce, http.StatusOK, nil
+}
+
+// completeOAuth finishes a verified callback for the provider account in
+// identity: a state started by LinkIdentity links it to that user, any
+// other signs in with it.
+func (h *OAuthHandler) completeOAuth(w http.ResponseWriter, r *http.Request, session *domain.AuthSession, identity domain.ExternalIdentity) {
+	if session.UserID != nil {
+		if _, err := h.identities.Link(r.Context(), *session.UserID, identity); err != nil {
+			h.log.WithContext(r.Context()).WithError(err).Warn("Failed to link identity", "user_id", session.UserID, "provider", identity.Provider)
+			h.sendCallbackResponse(w, false, identityErrorMessage(err, "Failed to link account"), "")
+			return
+		}
+		h.sendCallbackResponse(w, true, "Account linked", "")
 		return
 	}
 
-	userInfo, err := h.googleConfig.GetUserInfo(r.Context(), tokenResponse.AccessToken)
+	user, err := h.identities.SignIn(r.Context(), identity)
 	if err != nil {
-		h.log.WithError(err).Error("Failed to get user info from Google")
-		h.sendCallbackResponse(w, false, "Failed to retrieve user information", "")
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to create or get user", "provider", identity.Provider, "email", identity.Email)
+		h.sendCallbackResponse(w, false, identityErrorMessage(err, "Failed to process user account"), "")
 		return
 	}
+	h.completeOAuthLogin(w, r, session, user)
+}
 
-	if !userInfo.VerifiedEmail {
-		h.log.Warn("User email not verified", "email", userInfo.Email)
-		h.sendCallbackResponse(w, false, "Email address must be verified", "")
+// identityErrorMessage shows the user what they can act on and hides the
+// rest behind fallback.
+func identityErrorMessage(err error, fallback string) string {
+	message := err.Error()
+	switch {
+	case strings.HasPrefix(message, "email address"):
+		return "Email address" + strings.TrimPrefix(message, "email address")
+	case strings.HasPrefix(message, "identity already linked"), strings.HasPrefix(message, "provider already linked"),
+		message == "account disabled":
+		return message
+	}
+	return fallback
+}
+
+// completeOAuthLogin finishes a verified sign-in: a login started for a
+// device approves that device, a login started with a code challenge gets
+// an authorization code, any other login gets a token directly.
+func (h *OAuthHandler) completeOAuthLogin(w http.ResponseWriter, r *http.Request, session *domain.AuthSession, user *domain.User) {
+	if session.DeviceCode != "" {
+		if err := h.sessions.ApproveDeviceSession(r.Context(), session.DeviceCode, user.ID); err != nil {
+			if errors.Is(err, services.ErrDeviceSessionNotPending) {
+				h.log.WithContext(r.Context()).Warn("Rejected approval of a device session that is no longer pending", "user_id", user.ID)
+				h.sendCallbackResponse(w, false, "Device authorization already approved or expired", "")
+				return
+			}
+			h.log.WithContext(r.Context()).WithError(err).Error("Failed to approve device session", "user_id", user.ID)
+			h.sendCallbackResponse(w, false, "Failed to approve device", "")
+			return
+		}
+
+		h.log.WithContext(r.Context()).LogAuthEvent("device_auth_approved", user.ID.String(), session.Provider)
+		h.sendCallbackResponse(w, true, "Device authorized. You can return to your device.", "")
 		return
 	}
 
-	user, err := h.createOrGetUser(r.Context(), userInfo)
-	if err != nil {
-		h.log.WithError(err).Error("Failed to create or get user", "email", userInfo.Email)
-		h.sendCallbackResponse(w, false, "Failed to process user account", "")
+	if session.CodeChallenge != "" {
+		code, err := generateRandomCode(64)
+		if err == nil {
+			err = h.sessions.CreateAuthCode(r.Context(), code, user.ID, session.CodeChallenge, authCodeTTL)
+		}
+		if err != nil {
+			h.log.WithContext(r.Context()).WithError(err).Error("Failed to issue authorization code", "user_id", user.ID)
+			h.sendCallbackResponse(w, false, "Failed to generate authorization code", "")
+			return
+		}
+
+		h.log.WithContext(r.Context()).LogAuthEvent("oauth_code_issued", user.ID.String(), session.Provider)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AuthCallbackResponse{
+			Success: true,
+			Message: "Authentication successful. You can return to the app.",
+			Code:    code,
+		})
 		return
 	}
 
-	token, err := h.generateAPIToken(r.Context(), user.ID)
+	token, err := issueAPIToken(r.Context(), h.queries, user.ID, "OAuth Token", nil)
 	if err != nil {
-		h.log.WithError(err).Error("Failed to generate API token", "user_id", user.ID)
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to generate API token", "user_id", user.ID)
 		h.sendCallbackResponse(w, false, "Failed to generate authentication token", "")
 		return
 	}
 
-	h.log.LogAuthEvent("oauth_success", user.ID.String(), "google")
+	h.log.WithContext(r.Context()).LogAuthEvent("oauth_success", user.ID.String(), session.Provider)
 
-	// TODO: handle device flow completion
 	response := map[string]interface{}{
 		"success": true,
 		"message": "Authentication successful",
@@ -278,66 +466,93 @@ func (h *OAuthHandler) GoogleCallback(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func (h *OAuthHandler) createOrGetUser(ctx context.Context, userInfo *oauth.GoogleUserInfo) (*domain.User, error) {
-	existingUser, err := h.queries.GetUserByEmail(ctx, userInfo.Email)
-	if err == nil {
-		return &domain.User{
-			ID:               pgconv.PgToUUID(existingUser.ID),
-			Email:            existingUser.Email,
-			Tier:             domain.UserTier(existingUser.Tier),
-			StorageUsedBytes: pgconv.PgToInt64(existingUser.StorageUsedBytes),
-			CreatedAt:        pgconv.PgToTime(existingUser.CreatedAt),
-			UpdatedAt:        pgconv.PgToTime(existingUser.UpdatedAt),
-		}, nil
-	}
-
-	h.log.Info("Creating new user", "email", userInfo.Email)
-
-	newUser, err := h.queries.CreateUser(ctx, db.CreateUserParams{
-		Email:        userInfo.Email,
-		PasswordHash: "",
-		Tier:         db.UserTierFree,
-	})
+// OAuthLogin starts signing in with the provider in the path.
+func (h *OAuthHandler) OAuthLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.providers[r.PathValue("provider")]
+	if !ok {
+		http.Error(w, "provider not found", http.StatusNotFound)
+		return
+	}
+	h.log.WithContext(r.Context()).Info("Initiating OAuth flow", "provider", provider.Name())
+
+	state, pkce, status, err := h.startOAuthState(r, provider.Name())
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to start OAuth flow")
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	authURL := provider.AuthURL(state, oauth.CodeChallenge(pkce.CodeVerifier))
+	h.log.WithContext(r.Context()).Info("Redirecting to OAuth provider", "provider", provider.Name(), "auth_url", authURL)
+
+	response := map[string]string{
+		"auth_url": authURL,
+		"state":    state,
 	}
 
-	return &domain.User{
-		ID:               pgconv.PgToUUID(newUser.ID),
-		Email:            newUser.Email,
-		Tier:             domain.UserTier(newUser.Tier),
-		StorageUsedBytes: pgconv.PgToInt64(newUser.StorageUsedBytes),
-		CreatedAt:        pgconv.PgToTime(newUser.CreatedAt),
-		UpdatedAt:        pgconv.PgToTime(newUser.UpdatedAt),
-	}, nil
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
-func (h *OAuthHandler) generateAPIToken(ctx context.Context, userID uuid.UUID) (string, error) {
-	tokenBytes := make([]byte, 32)
-	if _, err := rand.Read(tokenBytes); err != nil {
-		return "", fmt.Errorf("failed to generate token: %w", err)
+// OAuthCallback completes signing in with the provider in the path. It
+// takes the result from the query, or from a posted form for providers,
+// such as Apple, that post it.
+func (h *OAuthHandler) OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.providers[r.PathValue("provider")]
+	if !ok {
+		http.Error(w, "provider not found", http.StatusNotFound)
+		return
 	}
+	h.log.WithContext(r.Context()).Info("Handling OAuth callback", "provider", provider.Name())
 
-	tokenString := hex.EncodeToString(tokenBytes)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<16)
+	if err := r.ParseForm(); err != nil {
+		h.sendCallbackResponse(w, false, "Invalid callback", "")
+		return
+	}
+	code := r.Form.Get("code")
+	errorParam := r.Form.Get("error")
 
-	hasher := func(data string) string {
-		// TODO: use proper crypto
-		return fmt.Sprintf("%x", data)
+	if errorParam != "" {
+		h.log.WithContext(r.Context()).Error("OAuth error returned from provider", "provider", provider.Name(), "error", errorParam)
+		h.sendCallbackResponse(w, false, fmt.Sprintf("OAuth error: %s", errorParam), "")
+		return
 	}
-	tokenHash := hasher(tokenString)
 
-	_, err := h.queries.CreateAPIToken(ctx, db.CreateAPITokenParams{
-		UserID:    pgconv.UUIDToPg(userID),
-		TokenHash: tokenHash,
-		Name:      "OAuth Token",
-		// TODO: set expiration
-		ExpiresAt: pgconv.TimePtrToPg(nil),
-	})
+	if code == "" {
+		h.log.WithContext(r.Context()).Error("No authorization code received")
+		h.sendCallbackResponse(w, false, "No authorization code received", "")
+		return
+	}
+
+	session, err := h.sessions.ConsumeOAuthState(r.Context(), r.Form.Get("state"), provider.Name())
 	if err != nil {
-		return "", fmt.Errorf("failed to store token: %w", err)
+		h.log.WithContext(r.Context()).WithError(err).Warn("Rejected OAuth callback with invalid state", "provider", provider.Name())
+		h.sendCallbackResponse(w, false, "Invalid or expired OAuth state", "")
+		return
 	}
 
-	return tokenString, nil
+	tokenResponse, err := provider.Exchange(r.Context(), code, session.CodeVerifier)
+	if err != nil {
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to exchange code for token", "provider", provider.Name())
+		h.sendCallbackResponse(w, false, "Failed to exchange authorization code", "")
+		return
+	}
+
+	identity, err := provider.UserInfo(r.Context(), tokenResponse, r.Form)
+	if err != nil {
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to get user info", "provider", provider.Name())
+		h.sendCallbackResponse(w, false, "Failed to retrieve user information", "")
+		return
+	}
+
+	h.completeOAuth(w, r, session, domain.ExternalIdentity{
+		Provider:       provider.Name(),
+		ProviderUserID: identity.ID,
+		Email:          identity.Email,
+		EmailVerified:  identity.EmailVerified,
+		Name:           identity.Name,
+	})
 }
 
 func (h *OAuthHandler) sendCallbackResponse(w http.ResponseWriter, success bool, message, redirectURL string) {
@@ -367,99 +582,86 @@ func generateUserCode() (string, error) {
 	return strings.ToUpper(code[:4] + "-" + code[4:8]), nil
 }
 
-func (h *OAuthHandler) GitHubLogin(w http.ResponseWriter, r *http.Request) {
-	h.log.Info("Initiating GitHub OAuth flow")
+// ListIdentities lists the provider accounts linked to the caller.
+func (h *OAuthHandler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
 
-	state, err := oauth.GenerateState()
+	identities, err := h.identities.ListIdentities(r.Context(), authCtx.UserID)
 	if err != nil {
-		h.log.WithError(err).Error("Failed to generate OAuth state")
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to list identities", "user_id", authCtx.UserID)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	authURL := h.githubConfig.GetAuthURL(state)
-	h.log.Info("Redirecting to GitHub OAuth", "auth_url", authURL)
-
-	response := map[string]string{
-		"auth_url": authURL,
-		"state":    state,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(identities)
 }
 
-func (h *OAuthHandler) GitHubCallback(w http.ResponseWriter, r *http.Request) {
-	h.log.Info("Handling GitHub OAuth callback")
-
-	code := r.URL.Query().Get("code")
-	errorParam := r.URL.Query().Get("error")
-
-	if errorParam != "" {
-		h.log.Error("OAuth error returned from GitHub", "error", errorParam)
-		h.sendCallbackResponse(w, false, fmt.Sprintf("OAuth error: %s", errorParam), "")
+// LinkIdentity starts an OAuth flow whose callback links the provider
+// account to the caller instead of signing in.
+func (h *OAuthHandler) LinkIdentity(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
 		return
 	}
 
-	if code == "" {
-		h.log.Error("No authorization code received")
-		h.sendCallbackResponse(w, false, "No authorization code received", "")
+	provider, ok := h.providers[r.PathValue("provider")]
+	if !ok {
+		http.Error(w, fmt.Sprintf("invalid provider: %q is not configured", r.PathValue("provider")), http.StatusBadRequest)
 		return
 	}
 
-	tokenResponse, err := h.githubConfig.ExchangeCodeForToken(r.Context(), code)
+	state, err := oauth.GenerateState()
 	if err != nil {
-		h.log.WithError(err).Error("Failed to exchange code for token")
-		h.sendCallbackResponse(w, false, "Failed to exchange authorization code", "")
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to generate OAuth state")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-
-	userInfo, err := h.githubConfig.GetUserInfo(r.Context(), tokenResponse.AccessToken)
+	verifier, err := oauth.GenerateCodeVerifier()
 	if err != nil {
-		h.log.WithError(err).Error("Failed to get user info from GitHub")
-		h.sendCallbackResponse(w, false, "Failed to retrieve user information", "")
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to generate code verifier")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-
-	if userInfo.Email == "" {
-		h.log.Warn("No email address found for GitHub user", "login", userInfo.Login)
-		h.sendCallbackResponse(w, false, "Email address is required for authentication", "")
+	if err := h.sessions.CreateLinkState(r.Context(), state, provider.Name(), authCtx.UserID, domain.PKCE{CodeVerifier: verifier}, oauthStateTTL); err != nil {
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to start OAuth flow")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	googleUserInfo := &oauth.GoogleUserInfo{
-		Email:         userInfo.Email,
-		Name:          userInfo.Name,
-		VerifiedEmail: true,
+	response := map[string]string{
+		"auth_url": provider.AuthURL(state, oauth.CodeChallenge(verifier)),
+		"state":    state,
 	}
 
-	user, err := h.createOrGetUser(r.Context(), googleUserInfo)
-	if err != nil {
-		h.log.WithError(err).Error("Failed to create or get user", "email", userInfo.Email)
-		h.sendCallbackResponse(w, false, "Failed to process user account", "")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UnlinkIdentity removes the caller's account at a provider and signs
+// them out everywhere but the device making the request.
+func (h *OAuthHandler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
 		return
 	}
 
-	token, err := h.generateAPIToken(r.Context(), user.ID)
+	err := h.identities.Unlink(r.Context(), authCtx.UserID, authCtx.Token.ID, r.PathValue("provider"))
 	if err != nil {
-		h.log.WithError(err).Error("Failed to generate API token", "user_id", user.ID)
-		h.sendCallbackResponse(w, false, "Failed to generate authentication token", "")
+		switch {
+		case err.Error() == "identity not found":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case strings.HasPrefix(err.Error(), "cannot unlink"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			h.log.WithContext(r.Context()).WithError(err).Error("Failed to unlink identity", "user_id", authCtx.UserID)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	h.log.LogAuthEvent("oauth_success", user.ID.String(), "github")
-
-	response := map[string]interface{}{
-		"success": true,
-		"message": "Authentication successful",
-		"token":   token,
-		"user": map[string]interface{}{
-			"id":    user.ID,
-			"email": user.Email,
-			"tier":  user.Tier,
-		},
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	w.WriteHeader(http.StatusNoContent)
 }
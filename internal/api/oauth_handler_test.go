@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/config"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/duckonomy/noture/pkg/oauth"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProvider struct {
+	callback  url.Values
+	challenge string
+	verifier  string
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) AuthURL(state, codeChallenge string) string {
+	p.challenge = codeChallenge
+	return "https://provider.example.com/authorize?state=" + state
+}
+
+func (p *fakeProvider) Exchange(ctx context.Context, code, codeVerifier string) (*oauth.TokenResponse, error) {
+	p.verifier = codeVerifier
+	return &oauth.TokenResponse{AccessToken: "access-" + code}, nil
+}
+
+func (p *fakeProvider) UserInfo(ctx context.Context, token *oauth.TokenResponse, callback url.Values) (*oauth.Identity, error) {
+	p.callback = callback
+	return nil, errors.New("no such account")
+}
+
+// stateStore keeps OAuth states and authorization codes in memory; the
+// handler under test uses no other part of the store.
+type stateStore struct {
+	services.AuthSessionStore
+	states map[string]*domain.AuthSession
+	codes  map[string]*domain.AuthSession
+}
+
+func (s *stateStore) CreateOAuthState(ctx context.Context, state, provider, deviceCode string, pkce domain.PKCE, ttl time.Duration) error {
+	s.states[state] = &domain.AuthSession{Provider: provider, PKCE: pkce}
+	return nil
+}
+
+func (s *stateStore) ConsumeOAuthState(ctx context.Context, state, provider string) (*domain.AuthSession, error) {
+	session, ok := s.states[state]
+	if !ok || session.Provider != provider {
+		return nil, services.ErrAuthSessionNotFound
+	}
+	delete(s.states, state)
+	return session, nil
+}
+
+func (s *stateStore) ConsumeAuthCode(ctx context.Context, code string) (*domain.AuthSession, error) {
+	session, ok := s.codes[code]
+	if !ok {
+		return nil, services.ErrAuthSessionNotFound
+	}
+	delete(s.codes, code)
+	return session, nil
+}
+
+func TestOAuthHandler_Providers(t *testing.T) {
+	provider := &fakeProvider{}
+	store := &stateStore{states: map[string]*domain.AuthSession{}}
+	handler := NewOAuthHandler(nil, store, nil, nil, config.OAuth{}, "https://notes.example.com").WithProvider(provider)
+	assert.Equal(t, "https://notes.example.com/auth/fake/callback", handler.CallbackURL("fake"))
+
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(NewRouter(mux, auth.NewAuthMiddleware(nil, nil), NewCapabilities("test")))
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(httptest.NewRequest(http.MethodGet, "/auth/google/login", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code, "providers without credentials are not offered")
+
+	rec = serve(httptest.NewRequest(http.MethodGet, "/auth/fake/login", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var started map[string]string
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&started))
+	assert.Equal(t, "https://provider.example.com/authorize?state="+started["state"], started["auth_url"])
+	assert.Equal(t, oauth.CodeChallenge(store.states[started["state"]].CodeVerifier), provider.challenge, "the provider is sent the challenge of the state's verifier")
+
+	callback := func(form url.Values) AuthCallbackResponse {
+		req := httptest.NewRequest(http.MethodPost, "/auth/fake/callback", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := serve(req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var response AuthCallbackResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+		return response
+	}
+
+	response := callback(url.Values{"code": {"abc"}, "state": {"forged"}})
+	assert.False(t, response.Success)
+	assert.Equal(t, "Invalid or expired OAuth state", response.Message)
+
+	response = callback(url.Values{"code": {"abc"}, "state": {started["state"]}, "user": {`{"name":{}}`}})
+	assert.False(t, response.Success)
+	assert.Equal(t, "Failed to retrieve user information", response.Message)
+	assert.Equal(t, `{"name":{}}`, provider.callback.Get("user"), "posted fields reach the provider")
+	assert.Equal(t, oauth.CodeChallenge(provider.verifier), provider.challenge, "the code is exchanged with the state's verifier")
+}
+
+func TestOAuthHandler_PKCE(t *testing.T) {
+	store := &stateStore{states: map[string]*domain.AuthSession{}, codes: map[string]*domain.AuthSession{}}
+	handler := NewOAuthHandler(nil, store, nil, nil, config.OAuth{}, "https://notes.example.com").WithProvider(&fakeProvider{})
+
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(NewRouter(mux, auth.NewAuthMiddleware(nil, nil), NewCapabilities("test")))
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	verifier, err := oauth.GenerateCodeVerifier()
+	require.NoError(t, err)
+	challenge := oauth.CodeChallenge(verifier)
+
+	rec := serve(httptest.NewRequest(http.MethodGet, "/auth/fake/login?code_challenge="+challenge+"&code_challenge_method=plain", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "only S256 is accepted")
+	rec = serve(httptest.NewRequest(http.MethodGet, "/auth/fake/login?code_challenge=short&code_challenge_method=S256", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serve(httptest.NewRequest(http.MethodGet, "/auth/fake/login?code_challenge="+challenge+"&code_challenge_method=S256", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var started map[string]string
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&started))
+	state := store.states[started["state"]]
+	assert.Equal(t, challenge, state.CodeChallenge)
+	assert.NotEmpty(t, state.CodeVerifier)
+	assert.NotEqual(t, verifier, state.CodeVerifier, "the server keeps a verifier of its own for the provider")
+
+	redeem := func(code, codeVerifier string) int {
+		body, err := json.Marshal(TokenRequest{Code: code, CodeVerifier: codeVerifier})
+		require.NoError(t, err)
+		return serve(httptest.NewRequest(http.MethodPost, "/auth/token", strings.NewReader(string(body)))).Code
+	}
+	userID := uuid.New()
+	store.codes["code-1"] = &domain.AuthSession{UserID: &userID, PKCE: domain.PKCE{CodeChallenge: challenge}}
+	other, err := oauth.GenerateCodeVerifier()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, redeem("code-1", other))
+	assert.Equal(t, http.StatusBadRequest, redeem("code-1", verifier), "a code is spent by a failed redemption")
+	assert.Equal(t, http.StatusBadRequest, redeem("code-1", ""))
+}
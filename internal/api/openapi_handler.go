@@ -0,0 +1,87 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPIHandler serves the API description for client generators, and a
+// Swagger UI page to browse it.
+type OpenAPIHandler struct {
+	document []byte
+	etag     string
+}
+
+// NewOpenAPIHandler converts the YAML description to JSON once, stamping
+// it with the server version.
+func NewOpenAPIHandler(spec []byte, version string) (*OpenAPIHandler, error) {
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(spec, &document); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	if info, ok := document["info"].(map[string]interface{}); ok {
+		info["version"] = version
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+
+	sum := sha256.Sum256(encoded)
+	return &OpenAPIHandler{
+		document: encoded,
+		etag:     `"` + hex.EncodeToString(sum[:8]) + `"`,
+	}, nil
+}
+
+// Document handles GET /openapi.json.
+func (h *OpenAPIHandler) Document(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, no-cache")
+	w.Header().Set("ETag", h.etag)
+	if notModified(r, h.etag, time.Time{}) {
+		writeNotModified(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.document)
+}
+
+// SwaggerUI handles GET /docs with a page rendering /openapi.json. The UI
+// itself is loaded from a CDN, so the page only works with internet access.
+func (h *OpenAPIHandler) SwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline' https://unpkg.com; style-src https://unpkg.com; img-src data: https://unpkg.com; connect-src 'self'")
+	fmt.Fprint(w, swaggerUIPage)
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Noture API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+// RegisterRoutes serves the description, and the page browsing it when
+// swaggerUI is set.
+func (h *OpenAPIHandler) RegisterRoutes(r *Router, swaggerUI bool) {
+	r.Public("GET /openapi.json", h.Document)
+	if swaggerUI {
+		r.Public("GET /docs", h.SwaggerUI)
+	}
+}
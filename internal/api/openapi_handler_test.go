@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/duckonomy/noture/docs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIHandler_Document(t *testing.T) {
+	h, err := NewOpenAPIHandler(docs.OpenAPI, "1.2.3")
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	h.Document(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var document struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &document))
+	assert.Equal(t, "3.0.3", document.OpenAPI)
+	assert.Equal(t, "1.2.3", document.Info.Version)
+	assert.Contains(t, document.Paths, "/api/workspaces")
+
+	t.Run("revalidates with the etag", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+		req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+		cached := httptest.NewRecorder()
+		h.Document(cached, req)
+		assert.Equal(t, http.StatusNotModified, cached.Code)
+		assert.Empty(t, cached.Body.Bytes())
+	})
+}
+
+func TestNewOpenAPIHandler_InvalidDocument(t *testing.T) {
+	_, err := NewOpenAPIHandler([]byte("openapi: [3"), "dev")
+	assert.ErrorContains(t, err, "invalid OpenAPI document")
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/google/uuid"
+)
+
+type OperationHandler struct {
+	operationService *services.OperationService
+}
+
+func NewOperationHandler(operationService *services.OperationService) *OperationHandler {
+	return &OperationHandler{
+		operationService: operationService,
+	}
+}
+
+// GetOperation reports the status and progress of a long-running operation
+// started by the caller. Clients poll it until status is succeeded or
+// failed.
+func (h *OperationHandler) GetOperation(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	operationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid operation ID format", http.StatusBadRequest)
+		return
+	}
+
+	operation, err := h.operationService.GetOperation(r.Context(), operationID, authCtx.UserID)
+	if err != nil {
+		if err.Error() == "operation not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get operation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !operation.Finished() {
+		w.Header().Set("Retry-After", "2")
+	}
+	json.NewEncoder(w).Encode(operation)
+}
+
+func (h *OperationHandler) RegisterRoutes(r *Router) {
+	r.User("GET /api/operations/{id}", h.GetOperation)
+}
@@ -0,0 +1,406 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/google/uuid"
+)
+
+// OrganizationHandler serves organizations to their members, and to
+// operators through the admin routes.
+type OrganizationHandler struct {
+	organizationService *services.OrganizationService
+}
+
+func NewOrganizationHandler(organizationService *services.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{
+		organizationService: organizationService,
+	}
+}
+
+func (h *OrganizationHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	var req domain.CreateOrganizationRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	organization, err := h.organizationService.CreateOrganization(r.Context(), authCtx.UserID, req)
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(organization)
+}
+
+func (h *OrganizationHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	organizations, err := h.organizationService.ListOrganizations(r.Context(), authCtx.UserID)
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"organizations": organizations,
+		"count":         len(organizations),
+	})
+}
+
+func (h *OrganizationHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	organizationID, ok := organizationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	organization, err := h.organizationService.GetOrganization(r.Context(), organizationID, authCtx.UserID)
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(organization)
+}
+
+func (h *OrganizationHandler) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	organizationID, ok := organizationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req domain.UpdateOrganizationRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	organization, err := h.organizationService.UpdateOrganization(r.Context(), organizationID, authCtx.UserID, req)
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(organization)
+}
+
+func (h *OrganizationHandler) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	organizationID, ok := organizationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.organizationService.DeleteOrganization(r.Context(), organizationID, authCtx.UserID); err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *OrganizationHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	organizationID, ok := organizationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := h.organizationService.ListMembers(r.Context(), organizationID, authCtx.UserID)
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"members": members,
+		"count":   len(members),
+	})
+}
+
+// AddMember adds a registered user to the organization by email.
+func (h *OrganizationHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	organizationID, ok := organizationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req domain.AddOrganizationMemberRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	if req.Role == "" {
+		req.Role = domain.OrgRoleMember
+	}
+
+	member, err := h.organizationService.AddMember(r.Context(), organizationID, authCtx.UserID, req)
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(member)
+}
+
+func (h *OrganizationHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	organizationID, ok := organizationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.UpdateOrganizationMemberRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	if err := h.organizationService.UpdateMemberRole(r.Context(), organizationID, authCtx.UserID, memberID, req.Role); err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *OrganizationHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	organizationID, ok := organizationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.organizationService.RemoveMember(r.Context(), organizationID, authCtx.UserID, memberID); err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *OrganizationHandler) ListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	organizationID, ok := organizationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	workspaces, err := h.organizationService.ListWorkspaces(r.Context(), organizationID, authCtx.UserID)
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workspaces": workspaces,
+		"count":      len(workspaces),
+	})
+}
+
+// AddWorkspace moves one of the caller's workspaces into the organization.
+func (h *OrganizationHandler) AddWorkspace(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	organizationID, ok := organizationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req domain.AddOrganizationWorkspaceRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	if err := h.organizationService.AddWorkspace(r.Context(), organizationID, authCtx.UserID, req.WorkspaceID); err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *OrganizationHandler) RemoveWorkspace(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	organizationID, ok := organizationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.organizationService.RemoveWorkspace(r.Context(), organizationID, authCtx.UserID, workspaceID); err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminListOrganizations lists every organization with its tier and pool,
+// for billing.
+func (h *OrganizationHandler) AdminListOrganizations(w http.ResponseWriter, r *http.Request) {
+	organizations, err := h.organizationService.AdminListOrganizations(r.Context())
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"organizations": organizations,
+		"count":         len(organizations),
+	})
+}
+
+func (h *OrganizationHandler) AdminUpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	organizationID, ok := organizationIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var update domain.AdminOrganizationUpdate
+	if !decodeRequest(w, r.Body, &update) {
+		return
+	}
+
+	organization, err := h.organizationService.AdminUpdateOrganization(r.Context(), authCtx.UserID, organizationID, update)
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(organization)
+}
+
+func organizationIDFromPath(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	organizationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID format", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	return organizationID, true
+}
+
+func writeOrganizationError(w http.ResponseWriter, err error) {
+	if status, ok := workspaceAccessStatus(err); ok {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "organization not found"), strings.HasPrefix(msg, "workspace not found"),
+		strings.HasPrefix(msg, "member not found"), strings.HasPrefix(msg, "user not found"):
+		http.Error(w, msg, http.StatusNotFound)
+	case strings.HasPrefix(msg, "invalid"):
+		http.Error(w, msg, http.StatusBadRequest)
+	case strings.HasPrefix(msg, "user is already a member"), strings.HasPrefix(msg, "workspace already belongs"),
+		strings.HasPrefix(msg, "cannot "):
+		http.Error(w, msg, http.StatusConflict)
+	case strings.HasPrefix(msg, "storage limit exceeded"):
+		http.Error(w, msg, http.StatusRequestEntityTooLarge)
+	default:
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+func (h *OrganizationHandler) RegisterRoutes(r *Router) {
+	r.User("POST /api/organizations", h.CreateOrganization)
+	r.User("GET /api/organizations", h.ListOrganizations)
+	r.User("GET /api/organizations/{id}", h.GetOrganization)
+	r.User("PATCH /api/organizations/{id}", h.UpdateOrganization)
+	r.User("DELETE /api/organizations/{id}", h.DeleteOrganization)
+	r.User("GET /api/organizations/{id}/members", h.ListMembers)
+	r.User("POST /api/organizations/{id}/members", h.AddMember)
+	r.User("PATCH /api/organizations/{id}/members/{user_id}", h.UpdateMember)
+	r.User("DELETE /api/organizations/{id}/members/{user_id}", h.RemoveMember)
+	r.User("GET /api/organizations/{id}/workspaces", h.ListWorkspaces)
+	r.User("POST /api/organizations/{id}/workspaces", h.AddWorkspace)
+	r.User("DELETE /api/organizations/{id}/workspaces/{workspace_id}", h.RemoveWorkspace)
+	r.Admin("GET /api/admin/organizations", h.AdminListOrganizations)
+	r.Admin("PATCH /api/admin/organizations/{id}", h.AdminUpdateOrganization)
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/webauthn"
+	"github.com/google/uuid"
+)
+
+// PasskeyHandler registers passkeys and signs in with them. Sign-ins get
+// their token from the AuthHandler, in the same shape as password logins.
+type PasskeyHandler struct {
+	passkeys *services.PasskeyService
+	auth     *AuthHandler
+	log      *logger.Logger
+}
+
+func NewPasskeyHandler(passkeys *services.PasskeyService, auth *AuthHandler) *PasskeyHandler {
+	return &PasskeyHandler{
+		passkeys: passkeys,
+		auth:     auth,
+		log:      logger.New(),
+	}
+}
+
+// PublicKeyCredentialJSON is a credential as PublicKeyCredential.toJSON
+// encodes it, with binary values base64url-encoded. Registrations fill in
+// the attestation object, sign-ins the authenticator data and signature.
+type PublicKeyCredentialJSON struct {
+	ID       string `json:"id"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject,omitempty"`
+		AuthenticatorData string `json:"authenticatorData,omitempty"`
+		Signature         string `json:"signature,omitempty"`
+	} `json:"response"`
+}
+
+// PasskeyRegisterRequest completes a registration, naming the passkey.
+type PasskeyRegisterRequest struct {
+	Name       string                  `json:"name,omitempty"`
+	Credential PublicKeyCredentialJSON `json:"credential"`
+}
+
+// PasskeyLoginRequest completes a sign-in. As with password logins, a
+// device_name registers the client as a device.
+type PasskeyLoginRequest struct {
+	Credential PublicKeyCredentialJSON `json:"credential"`
+	DeviceName string                  `json:"device_name,omitempty"`
+}
+
+func (h *PasskeyHandler) RegisterRoutes(r *Router) {
+	r.Account("GET /api/me/passkeys", h.ListPasskeys)
+	r.Account("POST /api/me/passkeys/options", h.BeginRegistration)
+	r.Account("POST /api/me/passkeys", h.FinishRegistration)
+	r.Account("DELETE /api/me/passkeys/{id}", h.DeletePasskey)
+
+	r.Public("POST /auth/passkey/options", h.BeginLogin)
+	r.Public("POST /auth/passkey", h.FinishLogin)
+}
+
+func (h *PasskeyHandler) ListPasskeys(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	passkeys, err := h.passkeys.ListPasskeys(r.Context(), authCtx.UserID)
+	if err != nil {
+		h.log.WithContext(r.Context()).WithError(err).Error("Failed to list passkeys", "user_id", authCtx.UserID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(passkeys)
+}
+
+// BeginRegistration returns the options to pass to
+// navigator.credentials.create.
+func (h *PasskeyHandler) BeginRegistration(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	options, err := h.passkeys.BeginRegistration(r.Context(), authCtx.UserID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(options)
+}
+
+// FinishRegistration stores the credential the browser created.
+func (h *PasskeyHandler) FinishRegistration(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	var req PasskeyRegisterRequest
+	if !decodeRequest(w, http.MaxBytesReader(w, r.Body, 1<<16), &req) {
+		return
+	}
+	var att webauthn.Attestation
+	err := decodeCredential(
+		credentialField{"clientDataJSON", req.Credential.Response.ClientDataJSON, &att.ClientDataJSON},
+		credentialField{"attestationObject", req.Credential.Response.AttestationObject, &att.AttestationObject},
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	passkey, err := h.passkeys.FinishRegistration(r.Context(), authCtx.UserID, req.Name, att)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(passkey)
+}
+
+func (h *PasskeyHandler) DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	passkeyID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid passkey ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.passkeys.DeletePasskey(r.Context(), authCtx.UserID, passkeyID); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BeginLogin returns the options to pass to navigator.credentials.get.
+// A user_code query parameter signs in to approve that pending device
+// authorization, as on the device verification page.
+func (h *PasskeyHandler) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	options, err := h.passkeys.BeginLogin(r.Context(), r.URL.Query().Get("user_code"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(options)
+}
+
+// FinishLogin checks the assertion the browser made, for a passkey
+// sign-in or as the second factor of a password login.
+func (h *PasskeyHandler) FinishLogin(w http.ResponseWriter, r *http.Request) {
+	var req PasskeyLoginRequest
+	if !decodeRequest(w, http.MaxBytesReader(w, r.Body, 1<<16), &req) {
+		return
+	}
+	var assertion webauthn.Assertion
+	resp := req.Credential.Response
+	err := decodeCredential(
+		credentialField{"id", req.Credential.ID, &assertion.CredentialID},
+		credentialField{"clientDataJSON", resp.ClientDataJSON, &assertion.ClientDataJSON},
+		credentialField{"authenticatorData", resp.AuthenticatorData, &assertion.AuthenticatorData},
+		credentialField{"signature", resp.Signature, &assertion.Signature},
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	login, err := h.passkeys.FinishLogin(r.Context(), assertion)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			h.log.WithContext(r.Context()).Warn("Passkey login failed", "error", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		h.writeError(w, r, err)
+		return
+	}
+
+	if login.DeviceApproved {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AuthCallbackResponse{
+			Success: true,
+			Message: "Device authorized. You can return to your device.",
+		})
+		return
+	}
+	h.auth.sendToken(w, r, login.User, req.DeviceName, "Passkey Login", http.StatusOK, "Authentication successful")
+}
+
+func (h *PasskeyHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "invalid"):
+		http.Error(w, msg, http.StatusBadRequest)
+	case strings.HasSuffix(msg, "not found"):
+		http.Error(w, msg, http.StatusNotFound)
+	case msg == "passkey already registered", strings.HasPrefix(msg, "cannot delete"):
+		http.Error(w, msg, http.StatusConflict)
+	case msg == "account disabled":
+		http.Error(w, msg, http.StatusForbidden)
+	case msg == "device authorization already approved or expired":
+		http.Error(w, msg, http.StatusGone)
+	default:
+		h.log.WithContext(r.Context()).WithError(err).Error("Passkey request failed")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+type credentialField struct {
+	name  string
+	value string
+	dest  *[]byte
+}
+
+// decodeCredential decodes the base64url values of a credential, all of
+// which are required.
+func decodeCredential(fields ...credentialField) error {
+	for _, f := range fields {
+		if f.value == "" {
+			return fmt.Errorf("invalid credential: %s is required", f.name)
+		}
+		// Some clients pad their base64url; accept both.
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(f.value, "="))
+		if err != nil {
+			return fmt.Errorf("invalid credential: %s is not base64url", f.name)
+		}
+		*f.dest = b
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/pkg/policy"
+	"github.com/google/uuid"
+)
+
+// PolicyHandler serves the admin API for content policy rules and their
+// violation audit log. Its routes must be behind RequireAdmin.
+type PolicyHandler struct {
+	policyService *services.PolicyService
+}
+
+func NewPolicyHandler(policyService *services.PolicyService) *PolicyHandler {
+	return &PolicyHandler{
+		policyService: policyService,
+	}
+}
+
+// ListRules lists the rules and the detectors new rules may use.
+func (h *PolicyHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.policyService.ListRules(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules":     rules,
+		"count":     len(rules),
+		"detectors": policy.Detectors(),
+	})
+}
+
+func (h *PolicyHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	var req domain.CreatePolicyRuleRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	rule, err := h.policyService.CreateRule(r.Context(), authCtx.UserID, req)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+func (h *PolicyHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	ruleID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid rule ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.policyService.DeleteRule(r.Context(), authCtx.UserID, ruleID); err != nil {
+		if err.Error() == "policy rule not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListViolations pages through the audit log, newest first.
+func (h *PolicyHandler) ListViolations(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var beforeID int64
+	var err error
+	if beforeStr := query.Get("before"); beforeStr != "" {
+		beforeID, err = strconv.ParseInt(beforeStr, 10, 64)
+		if err != nil || beforeID < 1 {
+			http.Error(w, "Invalid before", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.policyService.ListViolations(r.Context(), beforeID, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func (h *PolicyHandler) RegisterRoutes(r *Router) {
+	r.Admin("GET /api/admin/policies", h.ListRules)
+	r.Admin("POST /api/admin/policies", h.CreateRule)
+	r.Admin("DELETE /api/admin/policies/{id}", h.DeleteRule)
+	r.Admin("GET /api/admin/policy-violations", h.ListViolations)
+}
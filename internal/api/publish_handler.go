@@ -0,0 +1,224 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/google/uuid"
+)
+
+type PublishHandler struct {
+	publishService *services.PublishService
+	baseURL        string
+	cache          CachePolicy
+	log            *logger.Logger
+}
+
+// NewPublishHandler builds the publishing handlers. baseURL is the server's
+// public URL, used for the site links returned to clients; cache applies
+// to the published pages.
+func NewPublishHandler(publishService *services.PublishService, baseURL string, cache CachePolicy) *PublishHandler {
+	return &PublishHandler{
+		publishService: publishService,
+		baseURL:        baseURL,
+		cache:          cache,
+		log:            logger.New(),
+	}
+}
+
+// Publish handles POST /api/workspaces/{workspace_id}/publish. Publishing
+// again changes the slug or selection of the existing site.
+func (h *PublishHandler) Publish(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.PublishRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	site, err := h.publishService.Publish(r.Context(), workspaceID, authCtx.UserID, authCtx.UserTier, req)
+	if err != nil {
+		writePublishError(w, err)
+		return
+	}
+	site.URL = h.siteURL(site.Slug)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(site)
+}
+
+// GetSite handles GET /api/workspaces/{workspace_id}/publish.
+func (h *PublishHandler) GetSite(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	site, err := h.publishService.GetSite(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		writePublishError(w, err)
+		return
+	}
+	site.URL = h.siteURL(site.Slug)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(site)
+}
+
+// Unpublish handles DELETE /api/workspaces/{workspace_id}/publish.
+func (h *PublishHandler) Unpublish(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.publishService.Unpublish(r.Context(), workspaceID, authCtx.UserID); err != nil {
+		writePublishError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ExportBundle handles POST /api/workspaces/{workspace_id}/publish/bundle,
+// returning the selected notes as a static site in a zip archive. The body
+// takes the same selection as publishing, without a slug; an empty body
+// selects every note.
+func (h *PublishHandler) ExportBundle(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.PublishRequest
+	if r.ContentLength != 0 {
+		if !decodeRequest(w, r.Body, &req) {
+			return
+		}
+	}
+
+	site, err := h.publishService.BuildBundle(r.Context(), workspaceID, authCtx.UserID, authCtx.UserTier, req)
+	if err != nil {
+		writePublishError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", workspaceID.String()+"-site.zip"))
+	if err := site.WriteZip(w); err != nil {
+		h.log.WithContext(r.Context()).WithError(err).Error("Site bundle export failed", "workspace_id", workspaceID)
+	}
+}
+
+// RedirectSite sends /p/{slug} to the site root, so the pages' relative
+// links resolve under the slug.
+func (h *PublishHandler) RedirectSite(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/p/"+r.PathValue("slug")+"/", http.StatusMovedPermanently)
+}
+
+// ViewSite serves a page of a published site without authentication. Every
+// page of a site shares one ETag, derived from the workspace revision and
+// the site's settings, so conditional requests are answered before the
+// site is built.
+func (h *PublishHandler) ViewSite(w http.ResponseWriter, r *http.Request) {
+	public, err := h.publishService.LookupSite(r.Context(), r.PathValue("slug"))
+	if err != nil {
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, "Site not found", http.StatusNotFound)
+		return
+	}
+
+	etag := `"` + strconv.FormatInt(public.Revision, 10) + "-" + strconv.FormatInt(public.UpdatedAt.UnixMicro(), 36) + `"`
+	w.Header().Set("Cache-Control", h.cache.header(time.Now(), nil))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	if notModified(r, etag, time.Time{}) {
+		writeNotModified(w)
+		return
+	}
+
+	site, err := h.publishService.LoadSite(r.Context(), public)
+	if err != nil {
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, "Failed to build site", http.StatusInternalServerError)
+		return
+	}
+
+	var page bytes.Buffer
+	if !site.Render(&page, r.PathValue("page")) {
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, "Page not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src https: data:")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(page.Bytes())
+}
+
+func (h *PublishHandler) siteURL(slug string) string {
+	return h.baseURL + "/p/" + slug + "/"
+}
+
+func writePublishError(w http.ResponseWriter, err error) {
+	if status, ok := workspaceAccessStatus(err); ok {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	switch {
+	case strings.HasPrefix(err.Error(), "invalid"):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case strings.HasPrefix(err.Error(), "workspace not found"), strings.HasPrefix(err.Error(), "site not found"):
+		http.Error(w, "Site not found", http.StatusNotFound)
+	case err.Error() == "slug already taken", err.Error() == "workspace is archived":
+		http.Error(w, err.Error(), http.StatusConflict)
+	case strings.HasPrefix(err.Error(), "blocked by content policy"):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *PublishHandler) RegisterRoutes(r *Router) {
+	experimental := r.Experimental()
+	experimental.User("POST /api/workspaces/{workspace_id}/publish", h.Publish)
+	experimental.User("GET /api/workspaces/{workspace_id}/publish", h.GetSite)
+	experimental.User("DELETE /api/workspaces/{workspace_id}/publish", h.Unpublish)
+	experimental.User("POST /api/workspaces/{workspace_id}/publish/bundle", h.ExportBundle)
+	experimental.Public("GET /p/{slug}", h.RedirectSite)
+	experimental.Public("GET /p/{slug}/{page...}", h.ViewSite)
+}
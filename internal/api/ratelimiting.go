@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/duckonomy/noture/internal/ratelimit"
+	"github.com/duckonomy/noture/pkg/auth"
+)
+
+// WithRateLimiter limits authenticated requests by the budgets of their
+// route group as well as their tier's.
+func (rt *Router) WithRateLimiter(limiter *ratelimit.Limiter) *Router {
+	rt.limiter = limiter
+	return rt
+}
+
+// RateGroup returns a router whose routes take from the budgets of group
+// instead of those of their method.
+func (rt *Router) RateGroup(group ratelimit.Group) *Router {
+	grouped := *rt
+	grouped.rateGroup = group
+	return &grouped
+}
+
+// limited wraps a handler that runs after authentication so it takes from
+// the caller's budget for the route's group.
+func (rt *Router) limited(handler http.HandlerFunc) http.HandlerFunc {
+	if rt.limiter == nil {
+		return handler
+	}
+	group := rt.rateGroup
+	return func(w http.ResponseWriter, r *http.Request) {
+		authCtx, ok := auth.FromContext(r.Context())
+		if !ok {
+			handler(w, r)
+			return
+		}
+		group := group
+		if group == "" {
+			group = ratelimit.GroupOf(r.Method)
+		}
+		if !rt.limiter.AllowGroup(w, r, group, authCtx.Token.ID, authCtx.User.Tier) {
+			return
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/ratelimit"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter_Limited(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Limits{
+		Groups: map[ratelimit.Group]map[domain.UserTier]ratelimit.Limit{
+			ratelimit.GroupRead:   {domain.TierFree: {PerMinute: 2, Burst: 2}},
+			ratelimit.GroupUpload: {domain.TierFree: {PerMinute: 1, Burst: 1}},
+		},
+	}, false)
+	router := NewRouter(http.NewServeMux(), auth.NewAuthMiddleware(nil, nil), NewCapabilities("test")).WithRateLimiter(limiter)
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	read := router.limited(ok)
+	upload := router.RateGroup(ratelimit.GroupUpload).limited(ok)
+
+	authCtx := &domain.AuthContext{
+		User:  domain.User{Tier: domain.TierFree},
+		Token: domain.APIToken{ID: uuid.New()},
+	}
+	call := func(handler http.HandlerFunc, method string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/api/x", nil)
+		r = r.WithContext(auth.NewContext(r.Context(), authCtx))
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, call(upload, "POST").Code)
+	w := call(upload, "POST")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "uploads have their own budget")
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+
+	w = call(read, "GET")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"), "GET routes take from the read budget")
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, call(read, "POST").Code, "writes are not limited here")
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the ID of a request in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps the request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+// RequestID gives every request an ID, stored in its context for the
+// loggers and echoed in the response. A well-formed ID sent by the client
+// or a proxy is kept, so its logs and ours can be joined; otherwise a new
+// one is generated.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logger.ContextWithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID accepts printable ASCII without spaces, which is safe to
+// log and to echo in a header.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/duckonomy/noture/internal/ratelimit"
+	"github.com/duckonomy/noture/pkg/auth"
+)
+
+// Access is what a route requires of the caller.
+type Access string
+
+const (
+	// AccessPublic routes need no token. They either serve public content
+	// or check a credential of their own, such as a share token.
+	AccessPublic Access = "public"
+	// AccessUser routes need a valid API token.
+	AccessUser Access = "user"
+	// AccessAccount routes manage the account itself and need a
+	// full-access token, one from signing in rather than a scoped one.
+	AccessAccount Access = "account"
+	// AccessAdmin routes need the token of an administrator.
+	AccessAdmin Access = "admin"
+)
+
+// Route is one registered pattern and what it requires. Idempotent routes
+// honor the Idempotency-Key header.
+type Route struct {
+	Pattern    string
+	Access     Access
+	Stability  Stability
+	Idempotent bool
+}
+
+// Router registers handlers on a ServeMux together with the middleware
+// their access level needs, so each route states its requirements once,
+// in the handler's RegisterRoutes.
+type Router struct {
+	mux          *http.ServeMux
+	auth         *auth.AuthMiddleware
+	capabilities *Capabilities
+	idempotency  *Idempotency
+	meter        UsageRecorder
+	limiter      *ratelimit.Limiter
+	stability    Stability
+	rateGroup    ratelimit.Group
+	idempotent   bool
+	routes       *[]Route
+}
+
+func NewRouter(mux *http.ServeMux, authMiddleware *auth.AuthMiddleware, capabilities *Capabilities) *Router {
+	return &Router{
+		mux:          mux,
+		auth:         authMiddleware,
+		capabilities: capabilities,
+		stability:    StabilityStable,
+		routes:       &[]Route{},
+	}
+}
+
+// WithIdempotency sets the store behind routes registered through
+// Idempotent. Without it those routes ignore the Idempotency-Key header.
+func (rt *Router) WithIdempotency(idempotency *Idempotency) *Router {
+	rt.idempotency = idempotency
+	return rt
+}
+
+// Idempotent returns a router whose user routes replay their first
+// response to retries sent with the same Idempotency-Key.
+func (rt *Router) Idempotent() *Router {
+	idempotent := *rt
+	idempotent.idempotent = true
+	return &idempotent
+}
+
+// Experimental returns a router whose routes are labeled experimental and
+// listed by the capabilities endpoint.
+func (rt *Router) Experimental() *Router {
+	experimental := *rt
+	experimental.stability = StabilityExperimental
+	return &experimental
+}
+
+// Public registers a route anyone may call.
+func (rt *Router) Public(pattern string, handler http.HandlerFunc) {
+	rt.handle(pattern, AccessPublic, handler)
+}
+
+// User registers a route that requires a valid API token.
+func (rt *Router) User(pattern string, handler http.HandlerFunc) {
+	if rt.idempotent && rt.idempotency != nil {
+		handler = rt.idempotency.Middleware(handler)
+	}
+	rt.handle(pattern, AccessUser, rt.auth.RequireAuth(rt.limited(rt.metered(handler))))
+}
+
+// Account registers a route that requires a full-access API token.
+func (rt *Router) Account(pattern string, handler http.HandlerFunc) {
+	rt.handle(pattern, AccessAccount, rt.auth.RequireAuth(rt.auth.RequireFullAccess(rt.limited(rt.metered(handler)))))
+}
+
+// Admin registers a route that requires an administrator's token.
+func (rt *Router) Admin(pattern string, handler http.HandlerFunc) {
+	rt.handle(pattern, AccessAdmin, rt.auth.RequireAuth(rt.auth.RequireAdmin(rt.limited(rt.metered(handler)))))
+}
+
+func (rt *Router) handle(pattern string, access Access, handler http.HandlerFunc) {
+	*rt.routes = append(*rt.routes, Route{
+		Pattern:    pattern,
+		Access:     access,
+		Stability:  rt.stability,
+		Idempotent: rt.idempotent && access == AccessUser,
+	})
+	if rt.stability == StabilityExperimental {
+		rt.capabilities.Experimental(rt.mux, pattern, handler)
+		return
+	}
+	rt.mux.HandleFunc(pattern, handler)
+}
+
+// Routes lists the registered routes, sorted by pattern.
+func (rt *Router) Routes() []Route {
+	routes := append([]Route(nil), *rt.routes...)
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Pattern < routes[j].Pattern
+	})
+	return routes
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/duckonomy/noture/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// publicAPIRoutes are the /api routes that check a credential of their
+// own instead of an API token.
+var publicAPIRoutes = map[string]bool{
+	"GET /api/capabilities":    true,
+	"GET /api/invites/{token}": true,
+	"POST /api/webhooks/git":   true,
+}
+
+var wildcard = regexp.MustCompile(`\{[^}]+\}`)
+
+func registerAllRoutes(r *Router) {
+	r.Public("GET /api/capabilities", r.capabilities.Handle)
+
+	(&OAuthHandler{}).RegisterRoutes(r)
+	(&AuthHandler{}).RegisterRoutes(r)
+	(&PasskeyHandler{}).RegisterRoutes(r)
+	(&TokenHandler{}).RegisterRoutes(r)
+	(&FileHandler{}).RegisterRoutes(r)
+	(&SearchHandler{}).RegisterRoutes(r)
+	(&ShareHandler{}).RegisterRoutes(r)
+	(&PublishHandler{}).RegisterRoutes(r)
+	(&WorkspaceHandler{}).RegisterRoutes(r)
+	(&GalleryHandler{}).RegisterRoutes(r)
+	(&NoteTemplateHandler{}).RegisterRoutes(r)
+	(&AgendaHandler{}).RegisterRoutes(r)
+	(&CalendarHandler{}).RegisterRoutes(r)
+	(&OperationHandler{}).RegisterRoutes(r)
+	(&MemberHandler{}).RegisterRoutes(r)
+	(&InviteHandler{}).RegisterRoutes(r)
+	(&UserHandler{}).RegisterRoutes(r)
+	(&AccountHandler{}).RegisterRoutes(r)
+	(&UsageHandler{}).RegisterRoutes(r)
+	(&OrganizationHandler{}).RegisterRoutes(r)
+	(&NotificationHandler{}).RegisterRoutes(r)
+	(&GitMirrorHandler{}).WithPushVerifier(webhook.NewVerifier("git", "secret")).RegisterRoutes(r)
+	(&VaultHandler{}).RegisterRoutes(r)
+	(&ImportHandler{}).RegisterRoutes(r)
+	(&EventHandler{}).RegisterRoutes(r)
+	(&DeviceHandler{}).RegisterRoutes(r)
+	(&AdminHandler{}).RegisterRoutes(r)
+	(&TelemetryHandler{}).RegisterRoutes(r)
+	(&PolicyHandler{}).RegisterRoutes(r)
+	(&SuggestionHandler{}).RegisterRoutes(r)
+	(&OpenAPIHandler{}).RegisterRoutes(r, true)
+}
+
+func TestRouter_APIRoutesRequireToken(t *testing.T) {
+	mux := http.NewServeMux()
+	router := NewRouter(mux, auth.NewAuthMiddleware(nil, nil), NewCapabilities("test"))
+	registerAllRoutes(router)
+
+	checked := 0
+	for _, route := range router.Routes() {
+		method, path, _ := strings.Cut(route.Pattern, " ")
+		if !strings.HasPrefix(path, "/api/") {
+			continue
+		}
+		if publicAPIRoutes[route.Pattern] {
+			assert.Equal(t, AccessPublic, route.Access, route.Pattern)
+			continue
+		}
+		require.NotEqual(t, AccessPublic, route.Access, "%s is public; add it to publicAPIRoutes if that is intended", route.Pattern)
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(method, wildcard.ReplaceAllString(path, "x"), nil))
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code, route.Pattern)
+		checked++
+	}
+	assert.NotZero(t, checked)
+}
+
+func TestRouter_Experimental(t *testing.T) {
+	capabilities := NewCapabilities("test")
+	router := NewRouter(http.NewServeMux(), auth.NewAuthMiddleware(nil, nil), capabilities)
+
+	router.Experimental().User("GET /api/beta", func(w http.ResponseWriter, r *http.Request) {})
+	router.User("GET /api/stable", func(w http.ResponseWriter, r *http.Request) {})
+
+	assert.Equal(t, StabilityExperimental, capabilities.Stability("GET /api/beta"))
+	assert.Equal(t, StabilityStable, capabilities.Stability("GET /api/stable"))
+	assert.Equal(t, []Route{
+		{Pattern: "GET /api/beta", Access: AccessUser, Stability: StabilityExperimental},
+		{Pattern: "GET /api/stable", Access: AccessUser, Stability: StabilityStable},
+	}, router.Routes())
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/google/uuid"
+)
+
+type SearchHandler struct {
+	searchService *services.SearchService
+}
+
+func NewSearchHandler(searchService *services.SearchService) *SearchHandler {
+	return &SearchHandler{
+		searchService: searchService,
+	}
+}
+
+// Search returns one page of the notes matching q. Follow-up pages pass
+// the same q with the next_cursor of the previous page.
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	req := domain.SearchRequest{
+		Query:  query.Get("q"),
+		Cursor: query.Get("cursor"),
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		req.Limit, err = strconv.Atoi(limitStr)
+		if err != nil || req.Limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.searchService.Search(r.Context(), workspaceID, authCtx.UserID, req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if s, ok := workspaceAccessStatus(err); ok {
+			status = s
+		} else if strings.HasPrefix(err.Error(), "invalid") {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// IndexStatus reports how far the workspace's search index has been
+// built. Until it is ready, search results are partial.
+func (h *SearchHandler) IndexStatus(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	status, err := h.searchService.IndexStatus(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if s, ok := workspaceAccessStatus(err); ok {
+			code = s
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func (h *SearchHandler) RegisterRoutes(r *Router) {
+	r.User("GET /api/workspaces/{workspace_id}/search", h.Search)
+	r.User("GET /api/workspaces/{workspace_id}/index-status", h.IndexStatus)
+}
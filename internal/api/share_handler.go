@@ -0,0 +1,216 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/pkg/markdown"
+	"github.com/google/uuid"
+)
+
+type ShareHandler struct {
+	shareService *services.ShareService
+	baseURL      string
+	cache        CachePolicy
+}
+
+// NewShareHandler builds the share handlers. baseURL is the server's public
+// URL, used for the links returned to clients; cache applies to the public
+// share pages.
+func NewShareHandler(shareService *services.ShareService, baseURL string, cache CachePolicy) *ShareHandler {
+	return &ShareHandler{
+		shareService: shareService,
+		baseURL:      baseURL,
+		cache:        cache,
+	}
+}
+
+// CreateShare handles POST /api/files/{workspace_id}/{file_path}/share. The
+// route is registered by FileHandler.FileAction, which takes the whole
+// remainder of the path; the "/share" suffix is checked again here.
+func (h *ShareHandler) CreateShare(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	filePath, ok := strings.CutSuffix(r.PathValue("file_path"), "/share")
+	if !ok || filePath == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req domain.CreateShareRequest
+	if r.ContentLength != 0 {
+		if !decodeRequest(w, r.Body, &req) {
+			return
+		}
+	}
+
+	link, err := h.shareService.CreateShare(r.Context(), workspaceID, filePath, authCtx.UserID, req)
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		switch {
+		case strings.HasPrefix(err.Error(), "workspace not found"), strings.HasPrefix(err.Error(), "file not found"):
+			http.Error(w, "File not found", http.StatusNotFound)
+		case strings.HasPrefix(err.Error(), "expires_at"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.HasPrefix(err.Error(), "blocked by content policy"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	link.URL = h.baseURL + "/s/" + link.Token
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(link)
+}
+
+func (h *ShareHandler) ListShares(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	links, err := h.shareService.ListShares(r.Context(), authCtx.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"shares": links,
+		"count":  len(links),
+	})
+}
+
+func (h *ShareHandler) RevokeShare(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	shareID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid share ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.shareService.RevokeShare(r.Context(), shareID, authCtx.UserID); err != nil {
+		if err.Error() == "share link not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ViewShare serves a shared file without authentication. Text, Markdown
+// and Org included, is rendered to an HTML page for browsers; ?raw=true,
+// an Accept header that prefers the file's own type or a binary file
+// returns the stored bytes instead.
+// Responses carry an ETag per representation and may be cached publicly
+// for the handler's CachePolicy; conditional requests are answered before
+// the content is read.
+func (h *ShareHandler) ViewShare(w http.ResponseWriter, r *http.Request) {
+	file, err := h.shareService.LookupShare(r.Context(), r.PathValue("token"))
+	if err != nil {
+		// Keep caches from remembering the miss once the link exists.
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, "Share link not found", http.StatusNotFound)
+		return
+	}
+
+	// Only text is rendered; attachments are always served as they are.
+	raw := r.URL.Query().Get("raw") == "true" || !strings.HasPrefix(file.MimeType, "text/")
+	if !raw && negotiate(r.Header.Get("Accept"), "text/html", file.MimeType) != "text/html" {
+		raw = true
+	}
+	etag := `"` + file.ContentHash + `"`
+	if !raw {
+		etag = `"` + file.ContentHash + `-html"`
+	}
+
+	w.Header().Set("Cache-Control", h.cache.header(time.Now(), file.ExpiresAt))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", file.LastModified.UTC().Format(http.TimeFormat))
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Add("Vary", "Accept")
+	if notModified(r, etag, file.LastModified) {
+		writeNotModified(w)
+		return
+	}
+
+	if err := h.shareService.LoadContent(r.Context(), file); err != nil {
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, "Failed to read shared file", http.StatusInternalServerError)
+		return
+	}
+
+	if raw {
+		w.Header().Set("Content-Type", file.MimeType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", path.Base(file.FilePath)))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		http.ServeContent(w, r, path.Base(file.FilePath), file.LastModified, bytes.NewReader(file.Content))
+		return
+	}
+
+	var body string
+	switch file.MimeType {
+	case "text/markdown":
+		body = markdown.Render(file.Content)
+	case "text/org":
+		body = markdown.RenderOrg(file.Content, markdown.Options{})
+	default:
+		body = "<pre>" + html.EscapeString(string(file.Content)) + "</pre>\n"
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src https: data:")
+	fmt.Fprintf(w, sharePageTemplate, html.EscapeString(path.Base(file.FilePath)), body)
+}
+
+const sharePageTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>body{max-width:46rem;margin:2rem auto;padding:0 1rem;font:16px/1.6 system-ui,sans-serif}pre{overflow-x:auto;background:#f5f5f5;padding:.75rem}</style>
+</head>
+<body>
+%s</body>
+</html>
+`
+
+func (h *ShareHandler) RegisterRoutes(r *Router) {
+	r.User("GET /api/shares", h.ListShares)
+	r.User("DELETE /api/shares/{id}", h.RevokeShare)
+	r.Public("GET /s/{token}", h.ViewShare)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Stability tells client authors how much an endpoint may still change.
+type Stability string
+
+const (
+	// StabilityStable endpoints only change in backwards compatible ways.
+	StabilityStable Stability = "stable"
+	// StabilityExperimental endpoints may change or disappear without
+	// notice, usually because the feature behind them is still flagged.
+	StabilityExperimental Stability = "experimental"
+	// StabilityDeprecated endpoints still work but will be removed.
+	StabilityDeprecated Stability = "deprecated"
+)
+
+// StabilityHeader carries the stability level of every response from an
+// endpoint that is not stable.
+const StabilityHeader = "X-Noture-Stability"
+
+// EndpointStability describes one non-stable route. Sunset is the date,
+// in HTTP-date format, after which a deprecated route may be removed.
+type EndpointStability struct {
+	Route     string    `json:"route"`
+	Stability Stability `json:"stability"`
+	Sunset    string    `json:"sunset,omitempty"`
+}
+
+// CapabilitiesResponse is served by Capabilities.Handle. Routes not
+// listed are stable.
+type CapabilitiesResponse struct {
+	Version          string              `json:"version"`
+	DefaultStability Stability           `json:"default_stability"`
+	Endpoints        []EndpointStability `json:"endpoints"`
+}
+
+// Capabilities registers experimental and deprecated routes so their
+// responses are labeled and clients can list them up front.
+type Capabilities struct {
+	version string
+	mu      sync.RWMutex
+	routes  map[string]EndpointStability
+}
+
+func NewCapabilities(version string) *Capabilities {
+	return &Capabilities{
+		version: version,
+		routes:  make(map[string]EndpointStability),
+	}
+}
+
+// Experimental registers handler on mux, labeling its responses as
+// experimental.
+func (c *Capabilities) Experimental(mux *http.ServeMux, pattern string, handler http.HandlerFunc) {
+	c.register(mux, EndpointStability{Route: pattern, Stability: StabilityExperimental}, handler)
+}
+
+// Deprecated registers handler on mux, labeling its responses as
+// deprecated with the Deprecation header of RFC 9745 and, when sunset is
+// set, the Sunset header of RFC 8594.
+func (c *Capabilities) Deprecated(mux *http.ServeMux, pattern, sunset string, handler http.HandlerFunc) {
+	c.register(mux, EndpointStability{Route: pattern, Stability: StabilityDeprecated, Sunset: sunset}, handler)
+}
+
+func (c *Capabilities) register(mux *http.ServeMux, endpoint EndpointStability, handler http.HandlerFunc) {
+	c.mu.Lock()
+	c.routes[endpoint.Route] = endpoint
+	c.mu.Unlock()
+
+	mux.HandleFunc(endpoint.Route, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(StabilityHeader, string(endpoint.Stability))
+		if endpoint.Stability == StabilityDeprecated {
+			w.Header().Set("Deprecation", "true")
+			if endpoint.Sunset != "" {
+				w.Header().Set("Sunset", endpoint.Sunset)
+			}
+		}
+		handler(w, r)
+	})
+}
+
+// Stability returns the level registered for a route pattern.
+func (c *Capabilities) Stability(pattern string) Stability {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if endpoint, ok := c.routes[pattern]; ok {
+		return endpoint.Stability
+	}
+	return StabilityStable
+}
+
+// Handle lists the non-stable routes, sorted by route.
+func (c *Capabilities) Handle(w http.ResponseWriter, r *http.Request) {
+	c.mu.RLock()
+	response := CapabilitiesResponse{
+		Version:          c.version,
+		DefaultStability: StabilityStable,
+		Endpoints:        make([]EndpointStability, 0, len(c.routes)),
+	}
+	for _, endpoint := range c.routes {
+		response.Endpoints = append(response.Endpoints, endpoint)
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(response.Endpoints, func(i, j int) bool {
+		return response.Endpoints[i].Route < response.Endpoints[j].Route
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilities(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	mux := http.NewServeMux()
+	capabilities := NewCapabilities("dev")
+	mux.HandleFunc("GET /stable", ok)
+	capabilities.Experimental(mux, "GET /beta", ok)
+	capabilities.Deprecated(mux, "GET /old", "Wed, 01 Jul 2026 00:00:00 GMT", ok)
+	mux.HandleFunc("GET /api/capabilities", capabilities.Handle)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("stable routes carry no label", func(t *testing.T) {
+		rec := get("/stable")
+		assert.Empty(t, rec.Header().Get(StabilityHeader))
+		assert.Equal(t, StabilityStable, capabilities.Stability("GET /stable"))
+	})
+
+	t.Run("experimental routes are labeled", func(t *testing.T) {
+		rec := get("/beta")
+		assert.Equal(t, "experimental", rec.Header().Get(StabilityHeader))
+		assert.Empty(t, rec.Header().Get("Deprecation"))
+	})
+
+	t.Run("deprecated routes announce their sunset", func(t *testing.T) {
+		rec := get("/old")
+		assert.Equal(t, "deprecated", rec.Header().Get(StabilityHeader))
+		assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+		assert.Equal(t, "Wed, 01 Jul 2026 00:00:00 GMT", rec.Header().Get("Sunset"))
+	})
+
+	t.Run("capabilities list non-stable routes", func(t *testing.T) {
+		var response CapabilitiesResponse
+		require.NoError(t, json.NewDecoder(get("/api/capabilities").Body).Decode(&response))
+
+		assert.Equal(t, StabilityStable, response.DefaultStability)
+		assert.Equal(t, []EndpointStability{
+			{Route: "GET /beta", Stability: StabilityExperimental},
+			{Route: "GET /old", Stability: StabilityDeprecated, Sunset: "Wed, 01 Jul 2026 00:00:00 GMT"},
+		}, response.Endpoints)
+	})
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/google/uuid"
+)
+
+type SuggestionHandler struct {
+	suggestionService *services.SuggestionService
+	workspaceService  *services.WorkspaceService
+	log               *logger.Logger
+}
+
+func NewSuggestionHandler(suggestionService *services.SuggestionService, workspaceService *services.WorkspaceService) *SuggestionHandler {
+	return &SuggestionHandler{
+		suggestionService: suggestionService,
+		workspaceService:  workspaceService,
+		log:               logger.New(),
+	}
+}
+
+func (h *SuggestionHandler) ListSuggestions(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	suggestions, err := h.suggestionService.ListSuggestions(r.Context(), authCtx.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"suggestions": suggestions,
+		"count":       len(suggestions),
+	})
+}
+
+// ApplySuggestion performs one of the suggested actions on the flagged
+// workspace in a single call.
+func (h *SuggestionHandler) ApplySuggestion(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	suggestionID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid suggestion ID format", http.StatusBadRequest)
+		return
+	}
+
+	workspaceID, err := h.suggestionService.GetSuggestionWorkspace(r.Context(), suggestionID, authCtx.UserID)
+	if err != nil {
+		http.Error(w, "Suggestion not found", http.StatusNotFound)
+		return
+	}
+
+	switch domain.SuggestionAction(r.PathValue("action")) {
+	case domain.SuggestionArchive:
+		workspace, err := h.workspaceService.SetWorkspaceArchived(r.Context(), workspaceID, authCtx.UserID, true)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := h.suggestionService.ResolveSuggestion(r.Context(), suggestionID); err != nil {
+			h.log.WithContext(r.Context()).WithError(err).Warn("Failed to resolve suggestion after archive", "suggestion_id", suggestionID)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(workspace)
+
+	case domain.SuggestionExport:
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", workspaceID.String()+".zip"))
+		if err := h.workspaceService.ExportWorkspace(r.Context(), workspaceID, authCtx.UserID, w); err != nil {
+			h.log.WithContext(r.Context()).WithError(err).Error("Workspace export failed", "workspace_id", workspaceID)
+		}
+
+	case domain.SuggestionDelete:
+		if err := h.workspaceService.DeleteWorkspace(r.Context(), workspaceID, authCtx.UserID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case domain.SuggestionDismiss:
+		if err := h.suggestionService.DismissSuggestion(r.Context(), suggestionID, authCtx.UserID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Unknown action (use archive, export, delete or dismiss)", http.StatusBadRequest)
+	}
+}
+
+func (h *SuggestionHandler) RegisterRoutes(r *Router) {
+	r.Experimental().User("GET /api/me/suggestions", h.ListSuggestions)
+	r.Experimental().User("POST /api/me/suggestions/{id}/{action}", h.ApplySuggestion)
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/duckonomy/noture/internal/telemetry"
+)
+
+// TelemetryHandler lets operators inspect the anonymous usage report. Its
+// routes must be behind RequireAdmin.
+type TelemetryHandler struct {
+	reporter *telemetry.Reporter
+}
+
+func NewTelemetryHandler(reporter *telemetry.Reporter) *TelemetryHandler {
+	return &TelemetryHandler{
+		reporter: reporter,
+	}
+}
+
+// Preview shows whether telemetry is enabled, where it goes, and the exact
+// report that will be sent next. It works with telemetry disabled, so
+// operators can decide whether to opt in.
+func (h *TelemetryHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	report, err := h.reporter.Pending(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":     h.reporter.Enabled(),
+		"endpoint":    h.reporter.Endpoint(),
+		"next_report": report,
+	})
+}
+
+func (h *TelemetryHandler) RegisterRoutes(r *Router) {
+	r.Admin("GET /api/admin/telemetry", h.Preview)
+}
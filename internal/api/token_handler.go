@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// TokenHandler lets users mint scoped tokens for scripts and
+// integrations. Only full-access tokens may manage them, so a scoped
+// token cannot mint itself a broader one.
+type TokenHandler struct {
+	tokens *services.TokenService
+	log    *logger.Logger
+}
+
+func NewTokenHandler(tokens *services.TokenService) *TokenHandler {
+	return &TokenHandler{
+		tokens: tokens,
+		log:    logger.New(),
+	}
+}
+
+func (h *TokenHandler) RegisterRoutes(r *Router) {
+	r.Account("GET /api/me/tokens", h.ListTokens)
+	r.Account("POST /api/me/tokens", h.CreateToken)
+	r.Account("DELETE /api/me/tokens/{id}", h.DeleteToken)
+}
+
+func (h *TokenHandler) ListTokens(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.tokens.ListTokens(r.Context(), authCtx.UserID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tokens": tokens,
+		"count":  len(tokens),
+	})
+}
+
+// CreateToken mints a token and returns its plaintext, which is never
+// shown again.
+func (h *TokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	var req domain.CreateTokenRequest
+	if !decodeRequest(w, http.MaxBytesReader(w, r.Body, 1<<16), &req) {
+		return
+	}
+
+	token, err := h.tokens.CreateToken(r.Context(), authCtx.UserID, req)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(token)
+}
+
+func (h *TokenHandler) DeleteToken(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	tokenID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid token ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.tokens.DeleteToken(r.Context(), authCtx.UserID, tokenID); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TokenHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	msg := err.Error()
+	if status, ok := workspaceAccessStatus(err); ok {
+		http.Error(w, msg, status)
+		return
+	}
+	switch {
+	case strings.HasPrefix(msg, "invalid"):
+		http.Error(w, msg, http.StatusBadRequest)
+	case strings.HasSuffix(msg, "not found"):
+		http.Error(w, msg, http.StatusNotFound)
+	default:
+		h.log.WithContext(r.Context()).WithError(err).Error("Token request failed")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+)
+
+// issueAPIToken creates an API token for userID and returns its plaintext,
+// which is only ever shown once. Every login path issues tokens through here.
+// A token issued to a device is revoked with it.
+func issueAPIToken(ctx context.Context, queries *db.Queries, userID uuid.UUID, name string, deviceID *uuid.UUID) (string, error) {
+	tokenString, tokenHash, err := auth.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = queries.CreateAPIToken(ctx, db.CreateAPITokenParams{
+		UserID:    pgconv.UUIDToPg(userID),
+		TokenHash: tokenHash,
+		Name:      name,
+		// TODO: set expiration
+		ExpiresAt: pgconv.TimePtrToPg(nil),
+		DeviceID:  pgconv.UUIDPtrToPg(deviceID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+
+	return tokenString, nil
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/duckonomy/noture/pkg/logger"
+)
+
+// defaultUsageMonths is how many months a usage report covers when the
+// caller does not say.
+const defaultUsageMonths = 6
+
+// UsageHandler reports metered usage: to each user their own, and to
+// administrators everyone's, for billing.
+type UsageHandler struct {
+	usageService *services.UsageService
+	log          *logger.Logger
+}
+
+func NewUsageHandler(usageService *services.UsageService) *UsageHandler {
+	return &UsageHandler{
+		usageService: usageService,
+		log:          logger.New(),
+	}
+}
+
+// GetUsage reports the caller's usage over the last months months against
+// their tier's allowance.
+func (h *UsageHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	months := defaultUsageMonths
+	if raw := r.URL.Query().Get("months"); raw != "" {
+		var err error
+		if months, err = strconv.Atoi(raw); err != nil {
+			http.Error(w, "Invalid months", http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := h.usageService.GetUsage(r.Context(), authCtx.UserID, months)
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.HasSuffix(err.Error(), "not found"):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			h.log.WithContext(r.Context()).WithError(err).Error("Failed to get usage", "user_id", authCtx.UserID)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// ExportUsage sends every user's usage in one month, the current one by
+// default, as CSV.
+func (h *UsageHandler) ExportUsage(w http.ResponseWriter, r *http.Request) {
+	month := domain.UsageMonth(time.Now())
+	if raw := r.URL.Query().Get("month"); raw != "" {
+		var err error
+		if month, err = domain.ParseUsageMonth(raw); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	filename := fmt.Sprintf("noture-usage-%s.csv", month.Format(domain.UsageMonthLayout))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if err := h.usageService.ExportUsage(r.Context(), month, w); err != nil {
+		h.log.WithContext(r.Context()).WithError(err).Error("Usage export failed", "month", month.Format(domain.UsageMonthLayout))
+	}
+}
+
+func (h *UsageHandler) RegisterRoutes(r *Router) {
+	r.User("GET /api/account/usage", h.GetUsage)
+	r.Admin("GET /api/admin/usage", h.ExportUsage)
+}
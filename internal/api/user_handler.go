@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/google/uuid"
+)
+
+type UserHandler struct {
+	userService *services.UserService
+}
+
+func NewUserHandler(userService *services.UserService) *UserHandler {
+	return &UserHandler{
+		userService: userService,
+	}
+}
+
+func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.userService.GetUser(r.Context(), authCtx.UserID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// UpdateProfile changes the caller's profile. Only the timezone is
+// editable; it sets day boundaries for daily notes, digests and stats.
+func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	var req domain.UpdateProfileRequest
+	if !decodeRequest(w, http.MaxBytesReader(w, r.Body, 1<<16), &req) {
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(r.Context(), authCtx.UserID, req)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid timezone") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	// Code replaces CurrentPassword for accounts without a password; it
+	// is emailed by RequestPasswordCode.
+	Code        string `json:"code"`
+	NewPassword string `json:"new_password"`
+}
+
+// ChangePassword sets a new password and signs out every other device. The
+// token making the request stays valid.
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if !decodeRequest(w, http.MaxBytesReader(w, r.Body, 1<<16), &req) {
+		return
+	}
+
+	revoked, err := h.userService.ChangePassword(r.Context(), authCtx.UserID, authCtx.Token.ID, req.CurrentPassword, req.Code, req.NewPassword)
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid current password"), strings.HasPrefix(err.Error(), "invalid or expired code"),
+			strings.HasPrefix(err.Error(), "code required"):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.HasPrefix(err.Error(), "password must"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        "Password changed",
+		"revoked_tokens": revoked,
+	})
+}
+
+// RequestPasswordCode emails the code with which an account that has no
+// password, because it signs in through OAuth or with a passkey, sets its
+// first one.
+func (h *UserHandler) RequestPasswordCode(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.RequestPasswordCode(r.Context(), authCtx.UserID); err != nil {
+		if strings.HasPrefix(err.Error(), "cannot") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+type RevokeAllRequest struct {
+	// KeepCurrent leaves the token making the request valid.
+	KeepCurrent bool `json:"keep_current"`
+}
+
+// RevokeAllSessions signs the user out everywhere by revoking all of their
+// API tokens, including the current one unless keep_current is set.
+func (h *UserHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	var req RevokeAllRequest
+	if r.ContentLength != 0 {
+		if !decodeRequest(w, http.MaxBytesReader(w, r.Body, 1<<16), &req) {
+			return
+		}
+	}
+
+	var keep *uuid.UUID
+	if req.KeepCurrent {
+		keep = &authCtx.Token.ID
+	}
+
+	revoked, err := h.userService.RevokeAllTokens(r.Context(), authCtx.UserID, keep, "sign_out_everywhere")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"revoked_tokens": revoked,
+	})
+}
+
+// RevokeOtherSessions signs the user out everywhere but the device making
+// the request, as after noticing a session they do not recognize.
+func (h *UserHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	revoked, err := h.userService.RevokeAllTokens(r.Context(), authCtx.UserID, &authCtx.Token.ID, "sign_out_everywhere")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"revoked_tokens": revoked,
+	})
+}
+
+func (h *UserHandler) RegisterRoutes(r *Router) {
+	r.User("GET /api/me", h.GetProfile)
+	r.User("PATCH /api/me", h.UpdateProfile)
+	r.Account("PUT /api/me/password", h.ChangePassword)
+	r.Account("POST /api/me/password/code", h.RequestPasswordCode)
+	r.Account("POST /api/me/sessions/revoke-all", h.RevokeAllSessions)
+	r.Account("DELETE /api/sessions", h.RevokeOtherSessions)
+}
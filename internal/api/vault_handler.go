@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/ratelimit"
+	"github.com/duckonomy/noture/internal/services"
+	"github.com/google/uuid"
+)
+
+// VaultHandler serves the endpoints the Obsidian plugin syncs a vault
+// with.
+type VaultHandler struct {
+	vaultService *services.VaultService
+}
+
+func NewVaultHandler(vaultService *services.VaultService) *VaultHandler {
+	return &VaultHandler{
+		vaultService: vaultService,
+	}
+}
+
+func (h *VaultHandler) GetManifest(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := h.vaultService.GetManifest(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		writeVaultError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifest)
+}
+
+// GetChanges is the delta pull: the changes after the since cursor, a
+// page of at most limit events, with notes inlined up to inline_bytes.
+func (h *VaultHandler) GetChanges(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	var opts domain.VaultPullOptions
+	if since := query.Get("since"); since != "" {
+		opts.Since, err = strconv.ParseInt(since, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		opts.Limit, err = strconv.Atoi(limit)
+		if err != nil || opts.Limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	if inline := query.Get("inline_bytes"); inline != "" {
+		opts.InlineBytes, err = strconv.ParseInt(inline, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid inline_bytes", http.StatusBadRequest)
+			return
+		}
+	}
+
+	changes, err := h.vaultService.GetChanges(r.Context(), workspaceID, authCtx.UserID, opts)
+	if err != nil {
+		writeVaultError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(changes)
+}
+
+// Push applies a batch of local changes. Conflicts are settled per change
+// and reported in the 200 response, never as a 409.
+func (h *VaultHandler) Push(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("workspace_id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace_id format", http.StatusBadRequest)
+		return
+	}
+
+	// Content is base64 in JSON, a third larger than the bytes it holds.
+	var req domain.VaultPushRequest
+	if !decodeRequest(w, http.MaxBytesReader(w, r.Body, 2*domain.MaxVaultPushBytes), &req) {
+		return
+	}
+
+	response, err := h.vaultService.Push(r.Context(), workspaceID, authCtx.UserID, req)
+	if err != nil {
+		writeVaultError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeVaultError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case strings.HasPrefix(err.Error(), "invalid"):
+		status = http.StatusBadRequest
+	case strings.HasPrefix(err.Error(), "workspace not found"):
+		status = http.StatusNotFound
+	case err.Error() == "workspace is archived":
+		status = http.StatusConflict
+	default:
+		if s, ok := workspaceAccessStatus(err); ok {
+			status = s
+		}
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func (h *VaultHandler) RegisterRoutes(r *Router) {
+	experimental := r.Experimental()
+	experimental.User("GET /api/workspaces/{workspace_id}/vault/manifest", h.GetManifest)
+	experimental.User("GET /api/workspaces/{workspace_id}/vault/changes", h.GetChanges)
+	// Plugins retry pushes on flaky connections, so pushes honor
+	// Idempotency-Key, and take from the upload budget like other writes
+	// of file content.
+	experimental.Idempotent().RateGroup(ratelimit.GroupUpload).User("POST /api/workspaces/{workspace_id}/vault/push", h.Push)
+}
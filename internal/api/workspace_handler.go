@@ -2,7 +2,10 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/duckonomy/noture/internal/domain"
 	"github.com/duckonomy/noture/internal/services"
@@ -23,16 +26,13 @@ func NewWorkspaceHandler(workspaceService *services.WorkspaceService) *Workspace
 }
 
 func (h *WorkspaceHandler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
-	authCtx := r.Context().Value("auth").(*domain.AuthContext)
-
-	var req domain.CreateWorkspaceRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
 		return
 	}
 
-	if req.Name == "" {
-		http.Error(w, "Missing required field: name", http.StatusBadRequest)
+	var req domain.CreateWorkspaceRequest
+	if !decodeRequest(w, r.Body, &req) {
 		return
 	}
 
@@ -52,13 +52,26 @@ func (h *WorkspaceHandler) CreateWorkspace(w http.ResponseWriter, r *http.Reques
 }
 
 func (h *WorkspaceHandler) GetWorkspaces(w http.ResponseWriter, r *http.Request) {
-	authCtx := r.Context().Value("auth").(*domain.AuthContext)
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
 
 	workspaces, err := h.workspaceService.GetWorkspacesByUser(r.Context(), authCtx.UserID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	// Tokens limited to workspaces only see those.
+	if authCtx.Token.Scopes.Workspaces() != nil {
+		allowed := workspaces[:0]
+		for _, workspace := range workspaces {
+			if authCtx.Token.Scopes.AllowsWorkspace(workspace.ID) {
+				allowed = append(allowed, workspace)
+			}
+		}
+		workspaces = allowed
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -68,7 +81,10 @@ func (h *WorkspaceHandler) GetWorkspaces(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *WorkspaceHandler) GetWorkspace(w http.ResponseWriter, r *http.Request) {
-	authCtx := r.Context().Value("auth").(*domain.AuthContext)
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
 
 	workspaceIDStr := r.PathValue("id")
 	if workspaceIDStr == "" {
@@ -88,8 +104,8 @@ func (h *WorkspaceHandler) GetWorkspace(w http.ResponseWriter, r *http.Request)
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
-		if err.Error() == "access denied: workspace belongs to different user" {
-			http.Error(w, "Workspace not found", http.StatusNotFound)
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, "Workspace not found", status)
 			return
 		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -101,7 +117,10 @@ func (h *WorkspaceHandler) GetWorkspace(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *WorkspaceHandler) GetWorkspaceStorage(w http.ResponseWriter, r *http.Request) {
-	authCtx := r.Context().Value("auth").(*domain.AuthContext)
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
 
 	workspaceIDStr := r.PathValue("id")
 	if workspaceIDStr == "" {
@@ -121,8 +140,8 @@ func (h *WorkspaceHandler) GetWorkspaceStorage(w http.ResponseWriter, r *http.Re
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
-		if err.Error() == "access denied: workspace belongs to different user" {
-			http.Error(w, "Workspace not found", http.StatusNotFound)
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, "Workspace not found", status)
 			return
 		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -133,9 +152,195 @@ func (h *WorkspaceHandler) GetWorkspaceStorage(w http.ResponseWriter, r *http.Re
 	json.NewEncoder(w).Encode(storageInfo)
 }
 
-func (h *WorkspaceHandler) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("POST /api/workspaces", h.CreateWorkspace)
-	mux.HandleFunc("GET /api/workspaces", h.GetWorkspaces)
-	mux.HandleFunc("GET /api/workspaces/{id}", h.GetWorkspace)
-	mux.HandleFunc("GET /api/workspaces/{id}/storage", h.GetWorkspaceStorage)
+// GetWorkspaceStats handles GET /api/workspaces/{id}/stats. days sets how
+// far back growth goes.
+func (h *WorkspaceHandler) GetWorkspaceStats(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+	days := 0
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		if days, err = strconv.Atoi(raw); err != nil || days <= 0 {
+			http.Error(w, "Invalid days", http.StatusBadRequest)
+			return
+		}
+	}
+
+	stats, err := h.workspaceService.GetWorkspaceStats(r.Context(), workspaceID, authCtx.UserID, days)
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, "Workspace not found", status)
+			return
+		}
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.HasPrefix(err.Error(), "workspace not found"):
+			http.Error(w, "Workspace not found", http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+func (h *WorkspaceHandler) UpdateWorkspace(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.UpdateWorkspaceRequest
+	if !decodeRequest(w, r.Body, &req) {
+		return
+	}
+
+	workspace, err := h.workspaceService.UpdateWorkspace(r.Context(), workspaceID, authCtx.UserID, req)
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case strings.HasPrefix(err.Error(), "workspace not found"):
+			http.Error(w, "Workspace not found", http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(workspace)
+}
+
+func (h *WorkspaceHandler) ArchiveWorkspace(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, true)
+}
+
+func (h *WorkspaceHandler) UnarchiveWorkspace(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, false)
+}
+
+func (h *WorkspaceHandler) setArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	workspace, err := h.workspaceService.SetWorkspaceArchived(r.Context(), workspaceID, authCtx.UserID, archived)
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok && status == http.StatusForbidden {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		http.Error(w, "Workspace not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(workspace)
+}
+
+func (h *WorkspaceHandler) DeleteWorkspace(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	err = h.workspaceService.DeleteWorkspace(r.Context(), workspaceID, authCtx.UserID)
+	if err != nil {
+		if status, ok := workspaceAccessStatus(err); ok && status == http.StatusForbidden {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if strings.HasPrefix(err.Error(), "workspace not found") || strings.HasPrefix(err.Error(), "access denied") {
+			http.Error(w, "Workspace not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WorkspaceHandler) ExportWorkspace(w http.ResponseWriter, r *http.Request) {
+	authCtx, ok := requireAuthContext(w, r)
+	if !ok {
+		return
+	}
+
+	workspaceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID format", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.workspaceService.GetWorkspaceByID(r.Context(), workspaceID, authCtx.UserID); err != nil {
+		http.Error(w, "Workspace not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", workspaceID.String()+".zip"))
+	if err := h.workspaceService.ExportWorkspace(r.Context(), workspaceID, authCtx.UserID, w); err != nil {
+		h.log.WithContext(r.Context()).WithError(err).Error("Workspace export failed", "workspace_id", workspaceID)
+	}
+}
+
+// workspaceAccessStatus maps access errors from the services. Non-members
+// get 404 so workspace IDs don't leak; members without the needed role
+// get 403.
+func workspaceAccessStatus(err error) (int, bool) {
+	switch {
+	case strings.HasPrefix(err.Error(), "access denied: not a member"):
+		return http.StatusNotFound, true
+	case strings.HasPrefix(err.Error(), "access denied"):
+		return http.StatusForbidden, true
+	}
+	return 0, false
+}
+
+func (h *WorkspaceHandler) RegisterRoutes(r *Router) {
+	r.User("POST /api/workspaces", h.CreateWorkspace)
+	r.User("GET /api/workspaces", h.GetWorkspaces)
+	r.User("GET /api/workspaces/{id}", h.GetWorkspace)
+	r.User("GET /api/workspaces/{id}/storage", h.GetWorkspaceStorage)
+	r.User("GET /api/workspaces/{id}/stats", h.GetWorkspaceStats)
+	r.User("PATCH /api/workspaces/{id}", h.UpdateWorkspace)
+	r.User("DELETE /api/workspaces/{id}", h.DeleteWorkspace)
+	r.User("POST /api/workspaces/{id}/archive", h.ArchiveWorkspace)
+	r.User("POST /api/workspaces/{id}/unarchive", h.UnarchiveWorkspace)
+	r.User("GET /api/workspaces/{id}/export", h.ExportWorkspace)
 }
@@ -0,0 +1,194 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Source is the subset of *db.Queries a backup run reads from.
+type Source interface {
+	ListAllWorkspaces(ctx context.Context) ([]db.Workspace, error)
+	ListFiles(ctx context.Context, workspaceID pgtype.UUID) ([]db.ListFilesRow, error)
+}
+
+// BlobReader is the part of storage.Backend a backup run reads content from.
+type BlobReader interface {
+	Get(ctx context.Context, hash string) ([]byte, error)
+}
+
+type Backupper struct {
+	source Source
+	blobs  BlobReader
+	store  *Store
+	log    *logger.Logger
+	now    func() time.Time
+}
+
+func NewBackupper(source Source, blobs BlobReader, store *Store) *Backupper {
+	return &Backupper{
+		source: source,
+		blobs:  blobs,
+		store:  store,
+		log:    logger.New(),
+		now:    time.Now,
+	}
+}
+
+// Run records the current state of every workspace. Content is only loaded
+// and written for hashes the store has not seen in any earlier run.
+func (b *Backupper) Run(ctx context.Context) (*Manifest, error) {
+	createdAt := b.now().UTC()
+	manifest := &Manifest{
+		ID:        createdAt.Format(ManifestIDFormat),
+		CreatedAt: createdAt,
+	}
+
+	workspaces, err := b.source.ListAllWorkspaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+
+	for _, ws := range workspaces {
+		files, err := b.source.ListFiles(ctx, ws.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files of workspace %s: %w", pgconv.PgToUUID(ws.ID), err)
+		}
+
+		entry := WorkspaceEntry{
+			ID:     pgconv.PgToUUID(ws.ID),
+			UserID: pgconv.PgToUUID(ws.UserID),
+			Name:   ws.Name,
+			Files:  make([]FileEntry, 0, len(files)),
+		}
+
+		for _, file := range files {
+			if !b.store.HasBlob(file.ContentHash) {
+				content, err := b.blobs.Get(ctx, file.ContentHash)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read %s: %w", file.FilePath, err)
+				}
+				if err := b.store.PutBlob(file.ContentHash, content); err != nil {
+					return nil, fmt.Errorf("failed to store %s: %w", file.FilePath, err)
+				}
+				manifest.NewBlobs++
+				manifest.NewBytes += int64(len(content))
+			}
+
+			entry.Files = append(entry.Files, FileEntry{
+				Path:         file.FilePath,
+				ContentHash:  file.ContentHash,
+				SizeBytes:    file.SizeBytes,
+				MimeType:     pgconv.PgToString(file.MimeType),
+				LastModified: pgconv.PgToTime(file.LastModified),
+			})
+		}
+
+		manifest.Workspaces = append(manifest.Workspaces, entry)
+	}
+
+	if err := b.store.SaveManifest(manifest); err != nil {
+		return nil, err
+	}
+
+	b.log.Info("Backup completed",
+		"manifest_id", manifest.ID,
+		"workspaces", len(manifest.Workspaces),
+		"new_blobs", manifest.NewBlobs,
+		"new_bytes", manifest.NewBytes)
+
+	return manifest, nil
+}
+
+// VerifyReport lists every problem found while checking a manifest.
+type VerifyReport struct {
+	ManifestID   string   `json:"manifest_id"`
+	FilesChecked int      `json:"files_checked"`
+	Missing      []string `json:"missing,omitempty"`
+	Corrupt      []string `json:"corrupt,omitempty"`
+}
+
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Corrupt) == 0
+}
+
+// Verify checks that every blob a manifest references exists and still
+// hashes to its name.
+func Verify(store *Store, manifest *Manifest) *VerifyReport {
+	report := &VerifyReport{ManifestID: manifest.ID}
+	checked := make(map[string]bool)
+
+	for _, ws := range manifest.Workspaces {
+		for _, file := range ws.Files {
+			report.FilesChecked++
+			if checked[file.ContentHash] {
+				continue
+			}
+			checked[file.ContentHash] = true
+
+			ref := ws.ID.String() + "/" + file.Path
+			content, err := store.ReadBlob(file.ContentHash)
+			if err != nil {
+				report.Missing = append(report.Missing, ref)
+				continue
+			}
+			if fmt.Sprintf("%x", sha256.Sum256(content)) != file.ContentHash {
+				report.Corrupt = append(report.Corrupt, ref)
+			}
+		}
+	}
+
+	return report
+}
+
+// Restore writes the files recorded in manifest below outDir as
+// <workspace id>/<file path>. When workspaceID is not nil only that
+// workspace is restored.
+func Restore(store *Store, manifest *Manifest, outDir string, workspaceID *uuid.UUID) (int, error) {
+	restored := 0
+	root, err := filepath.Abs(outDir)
+	if err != nil {
+		return 0, fmt.Errorf("invalid output directory: %w", err)
+	}
+
+	for _, ws := range manifest.Workspaces {
+		if workspaceID != nil && ws.ID != *workspaceID {
+			continue
+		}
+
+		wsRoot := filepath.Join(root, ws.ID.String())
+		for _, file := range ws.Files {
+			target := filepath.Join(wsRoot, filepath.FromSlash(file.Path))
+			if !strings.HasPrefix(target, wsRoot+string(filepath.Separator)) {
+				return restored, fmt.Errorf("refusing to restore %q outside the output directory", file.Path)
+			}
+
+			content, err := store.ReadBlob(file.ContentHash)
+			if err != nil {
+				return restored, err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return restored, fmt.Errorf("failed to create directory for %s: %w", file.Path, err)
+			}
+			if err := os.WriteFile(target, content, 0o644); err != nil {
+				return restored, fmt.Errorf("failed to write %s: %w", file.Path, err)
+			}
+			if !file.LastModified.IsZero() {
+				os.Chtimes(target, file.LastModified, file.LastModified)
+			}
+			restored++
+		}
+	}
+
+	return restored, nil
+}
@@ -0,0 +1,127 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSource struct {
+	workspaceID uuid.UUID
+	files       map[string][]byte
+	reads       int
+}
+
+func (f *fakeSource) ListAllWorkspaces(ctx context.Context) ([]db.Workspace, error) {
+	return []db.Workspace{{ID: pgconv.UUIDToPg(f.workspaceID), Name: "notes"}}, nil
+}
+
+func (f *fakeSource) ListFiles(ctx context.Context, workspaceID pgtype.UUID) ([]db.ListFilesRow, error) {
+	var rows []db.ListFilesRow
+	for path, content := range f.files {
+		rows = append(rows, db.ListFilesRow{
+			WorkspaceID: workspaceID,
+			FilePath:    path,
+			ContentHash: fmt.Sprintf("%x", sha256.Sum256(content)),
+			SizeBytes:   int64(len(content)),
+		})
+	}
+	return rows, nil
+}
+
+func (f *fakeSource) Get(ctx context.Context, hash string) ([]byte, error) {
+	f.reads++
+	for _, content := range f.files {
+		if fmt.Sprintf("%x", sha256.Sum256(content)) == hash {
+			return content, nil
+		}
+	}
+	return nil, fmt.Errorf("no blob %s", hash)
+}
+
+func TestBackupper_Run(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	source := &fakeSource{
+		workspaceID: uuid.New(),
+		files: map[string][]byte{
+			"a.md":        []byte("alpha"),
+			"copy/a.md":   []byte("alpha"),
+			"journal.org": []byte("* day one"),
+		},
+	}
+
+	clock := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	backupper := NewBackupper(source, source, store)
+	backupper.now = func() time.Time { return clock }
+
+	first, err := backupper.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, first.NewBlobs, "identical content is stored once")
+	assert.Equal(t, "20250115T100000Z", first.ID)
+
+	t.Run("second run only stores changed content", func(t *testing.T) {
+		source.files["journal.org"] = []byte("* day one\n* day two")
+		source.reads = 0
+		clock = clock.Add(24 * time.Hour)
+
+		second, err := backupper.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, second.NewBlobs)
+		assert.Equal(t, 1, source.reads)
+	})
+
+	t.Run("verify detects missing and corrupt blobs", func(t *testing.T) {
+		report := Verify(store, first)
+		assert.True(t, report.OK())
+		assert.Equal(t, 3, report.FilesChecked)
+
+		hash := fmt.Sprintf("%x", sha256.Sum256([]byte("alpha")))
+		require.NoError(t, os.WriteFile(store.blobPath(hash), []byte("tampered"), 0o644))
+
+		report = Verify(store, first)
+		assert.False(t, report.OK())
+		assert.Len(t, report.Corrupt, 1)
+	})
+
+	t.Run("restore reconstructs a point in time", func(t *testing.T) {
+		manifest, err := store.ManifestAt(time.Date(2025, 1, 15, 23, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, manifest.ID)
+
+		out := t.TempDir()
+		restored, err := Restore(store, manifest, out, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 3, restored)
+
+		content, err := os.ReadFile(filepath.Join(out, source.workspaceID.String(), "journal.org"))
+		require.NoError(t, err)
+		assert.Equal(t, "* day one", string(content))
+	})
+
+	t.Run("no manifest before the first backup", func(t *testing.T) {
+		_, err := store.ManifestAt(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		assert.Error(t, err)
+	})
+}
+
+func TestStore_PutBlob(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	require.NoError(t, err)
+
+	err = store.PutBlob("0000000000000000000000000000000000000000000000000000000000000000", []byte("data"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "content hash mismatch")
+}
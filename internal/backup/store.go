@@ -0,0 +1,175 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ManifestIDFormat names manifests by their UTC creation time so they sort
+// chronologically.
+const ManifestIDFormat = "20060102T150405Z"
+
+// Manifest describes the complete state of every workspace at the time of a
+// backup run. File contents live in the shared blob store and are referenced
+// by content hash, so each run only adds blobs no earlier run stored.
+type Manifest struct {
+	ID         string           `json:"id"`
+	CreatedAt  time.Time        `json:"created_at"`
+	Workspaces []WorkspaceEntry `json:"workspaces"`
+	NewBlobs   int              `json:"new_blobs"`
+	NewBytes   int64            `json:"new_bytes"`
+}
+
+type WorkspaceEntry struct {
+	ID     uuid.UUID   `json:"id"`
+	UserID uuid.UUID   `json:"user_id"`
+	Name   string      `json:"name"`
+	Files  []FileEntry `json:"files"`
+}
+
+type FileEntry struct {
+	Path         string    `json:"path"`
+	ContentHash  string    `json:"content_hash"`
+	SizeBytes    int64     `json:"size_bytes"`
+	MimeType     string    `json:"mime_type"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Store is a backup directory laid out as
+//
+//	blobs/<first two hash chars>/<sha256>
+//	manifests/<manifest id>.json
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) (*Store, error) {
+	for _, sub := range []string{"blobs", "manifests"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create backup directory: %w", err)
+		}
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) blobPath(hash string) string {
+	return filepath.Join(s.dir, "blobs", hash[:2], hash)
+}
+
+func (s *Store) HasBlob(hash string) bool {
+	if len(hash) < 2 {
+		return false
+	}
+	_, err := os.Stat(s.blobPath(hash))
+	return err == nil
+}
+
+// PutBlob stores content under its hash, refusing content that does not
+// match. Writes go through a temporary file so a crash never leaves a
+// truncated blob behind.
+func (s *Store) PutBlob(hash string, content []byte) error {
+	if actual := fmt.Sprintf("%x", sha256.Sum256(content)); actual != hash {
+		return fmt.Errorf("content hash mismatch: expected %s, got %s", hash, actual)
+	}
+
+	path := s.blobPath(hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	return writeFileAtomic(path, content)
+}
+
+func (s *Store) ReadBlob(hash string) ([]byte, error) {
+	if len(hash) < 2 {
+		return nil, fmt.Errorf("invalid blob hash %q", hash)
+	}
+	content, err := os.ReadFile(s.blobPath(hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+	return content, nil
+}
+
+func (s *Store) SaveManifest(m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(s.dir, "manifests", m.ID+".json"), data)
+}
+
+func (s *Store) LoadManifest(id string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, "manifests", id+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("manifest %s not found: %w", id, err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", id, err)
+	}
+	return &m, nil
+}
+
+// ListManifests returns manifest IDs oldest first.
+func (s *Store) ListManifests() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "manifests"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list manifests: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// ManifestAt returns the latest manifest taken at or before t, which is the
+// state a point-in-time restore to t reconstructs.
+func (s *Store) ManifestAt(t time.Time) (*Manifest, error) {
+	ids, err := s.ListManifests()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := t.UTC().Format(ManifestIDFormat)
+	for i := len(ids) - 1; i >= 0; i-- {
+		if ids[i] <= cutoff {
+			return s.LoadManifest(ids[i])
+		}
+	}
+	return nil, fmt.Errorf("no backup exists at or before %s", t.Format(time.RFC3339))
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move %s into place: %w", path, err)
+	}
+	return nil
+}
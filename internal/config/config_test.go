@@ -0,0 +1,169 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/pkg/email"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func envFrom(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := load(nil, envFrom(nil))
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+	assert.Equal(t, time.Second, cfg.SLO.Objective().LatencyThreshold)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "noture.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+environment: staging
+port: 9000
+base_url: https://file.example.com
+admin_emails: [ops@example.com]
+oauth:
+  github:
+    client_id: file-id
+    client_secret: file-secret
+slo:
+  availability: 0.99
+webhooks:
+  git:
+    secret: file-secret-file-secret-file-secret
+`), 0o600))
+
+	cfg, err := load(
+		[]string{"-port", "9100"},
+		envFrom(map[string]string{
+			FileEnv:               path,
+			"PORT":                "9050",
+			"BASE_URL":            "https://env.example.com",
+			"ADMIN_EMAILS":        "a@example.com, b@example.com,",
+			"MIN_CLIENT_VERSIONS": "noture-cli=1.4.0, noture-obsidian = 0.9",
+		}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "staging", cfg.Environment, "file overrides defaults")
+	assert.Equal(t, "https://env.example.com", cfg.BaseURL, "environment overrides the file")
+	assert.Equal(t, 9100, cfg.Port, "flags override the environment")
+	assert.Equal(t, []string{"a@example.com", "b@example.com"}, cfg.AdminEmails)
+	assert.Equal(t, map[string]string{"noture-cli": "1.4.0", "noture-obsidian": "0.9"}, cfg.MinClientVersions)
+	assert.True(t, cfg.OAuth.GitHub.Configured())
+	assert.False(t, cfg.OAuth.Google.Configured())
+	assert.Equal(t, 0.99, cfg.SLO.Availability)
+	assert.Equal(t, 1000, cfg.SLO.LatencyMs, "unset file keys keep their default")
+	assert.NotNil(t, cfg.Webhooks.Git.Verifier("git"))
+}
+
+func TestLoad_ConfigFlagOverridesEnv(t *testing.T) {
+	dir := t.TempDir()
+	flagPath := filepath.Join(dir, "flag.yaml")
+	require.NoError(t, os.WriteFile(flagPath, []byte("environment: production\n"), 0o600))
+
+	cfg, err := load([]string{"-config", flagPath}, envFrom(map[string]string{FileEnv: filepath.Join(dir, "missing.yaml")}))
+	require.NoError(t, err)
+	assert.Equal(t, "production", cfg.Environment)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	unknown := filepath.Join(dir, "unknown.yaml")
+	require.NoError(t, os.WriteFile(unknown, []byte("prot: 80\n"), 0o600))
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{"unknown flag", []string{"-verbose"}, nil, "invalid arguments"},
+		{"stray argument", []string{"serve"}, nil, "invalid arguments"},
+		{"missing file", []string{"-config", filepath.Join(dir, "missing.yaml")}, nil, "failed to read config file"},
+		{"unknown file key", []string{"-config", unknown}, nil, "invalid config file"},
+		{"non-numeric port", nil, map[string]string{"PORT": "http"}, "invalid PORT"},
+		{"port out of range", []string{"-port", "70000"}, nil, "invalid port"},
+		{"sqlite database", []string{"-database-url", "sqlite:///var/lib/noture/notes.db"}, nil, "invalid database_url: SQLite"},
+		{"relative base url", nil, map[string]string{"BASE_URL": "example.com"}, "invalid base_url"},
+		{"trailing slash", nil, map[string]string{"BASE_URL": "https://example.com/"}, "invalid base_url"},
+		{"half oauth credentials", nil, map[string]string{"GOOGLE_CLIENT_ID": "id"}, "invalid oauth.google"},
+		{"oauth required", nil, map[string]string{"OAUTH_REQUIRED": "true"}, "invalid oauth: required"},
+		{"dev oauth in production", nil, map[string]string{"OAUTH_DEV": "true", "ENVIRONMENT": "production"}, "invalid oauth.dev"},
+		{"partial apple credentials", nil, map[string]string{"APPLE_CLIENT_ID": "com.example.web"}, "invalid oauth.apple"},
+		{"bad apple key", nil, map[string]string{"APPLE_CLIENT_ID": "com.example.web", "APPLE_TEAM_ID": "TEAM", "APPLE_KEY_ID": "KEY", "APPLE_PRIVATE_KEY": "not a key"}, "invalid oauth.apple.private_key"},
+		{"bad retention", nil, map[string]string{"SYNC_RETENTION": "forever"}, "invalid sync_retention"},
+		{"negative inactivity", nil, map[string]string{"INACTIVE_WORKSPACE_DAYS": "-1"}, "invalid inactive_workspace_days"},
+		{"negative token idle days", nil, map[string]string{"TOKEN_IDLE_DAYS": "-1"}, "invalid token_idle_days"},
+		{"negative login throttle", nil, map[string]string{"LOGIN_THROTTLE_IP_FREE_ATTEMPTS": "-1"}, "invalid login_throttle.ip_free_attempts"},
+		{"lockout below delay", nil, map[string]string{"LOGIN_THROTTLE_BASE_DELAY_SECONDS": "120", "LOGIN_THROTTLE_MAX_LOCKOUT_MINUTES": "1"}, "invalid login_throttle.max_lockout_minutes"},
+		{"availability of one", nil, map[string]string{"SLO_AVAILABILITY": "1"}, "invalid slo.availability"},
+		{"telemetry without endpoint", nil, map[string]string{"TELEMETRY_ENABLED": "true"}, "invalid telemetry.endpoint"},
+		{"telemetry epsilon", nil, map[string]string{"TELEMETRY_EPSILON": "0"}, "invalid telemetry.epsilon"},
+		{"client version pair", nil, map[string]string{"MIN_CLIENT_VERSIONS": "noture-cli"}, "invalid MIN_CLIENT_VERSIONS"},
+		{"client version", nil, map[string]string{"MIN_CLIENT_VERSIONS": "noture-cli=latest"}, "invalid min_client_versions"},
+		{"missing gallery", nil, map[string]string{"TEMPLATE_GALLERY_DIR": filepath.Join(dir, "gallery")}, "invalid template_gallery_dir"},
+		{"git mirror dir is a file", nil, map[string]string{"GIT_MIRROR_DIR": unknown}, "invalid git_mirror_dir"},
+		{"negative cache age", nil, map[string]string{"PUBLIC_CACHE_SHARED_MAX_AGE_SECONDS": "-1"}, "invalid public_cache"},
+		{"negative rate limit", nil, map[string]string{"RATE_LIMIT_FREE_PER_MINUTE": "-5"}, "invalid rate_limit.free_per_minute"},
+		{"negative group rate limit", nil, map[string]string{"RATE_LIMIT_UPLOAD_PREMIUM_PER_MINUTE": "-1"}, "invalid rate_limit.upload.premium_per_minute"},
+		{"load shedding threshold", nil, map[string]string{"LOAD_SHED_MAX_IN_FLIGHT": "-1"}, "invalid load_shedding.max_in_flight"},
+		{"load shedding route", nil, map[string]string{"LOAD_SHED_LOW_PRIORITY_ROUTES": "GET /api/{"}, "invalid load_shedding.low_priority_routes"},
+		{"note ids", nil, map[string]string{"NOTE_IDS": "uuid"}, "invalid note_ids"},
+		{"redis url", nil, map[string]string{"RATE_LIMIT_REDIS_URL": "localhost:6379"}, "invalid rate_limit.redis_url"},
+		{"email backend", nil, map[string]string{"EMAIL_BACKEND": "postfix"}, "invalid email.backend"},
+		{"smtp without host", nil, map[string]string{"EMAIL_BACKEND": "smtp", "EMAIL_FROM": "mail@example.com"}, "invalid email.smtp"},
+		{"sendgrid without key", nil, map[string]string{"EMAIL_BACKEND": "sendgrid", "EMAIL_FROM": "mail@example.com"}, "invalid email.sendgrid"},
+		{"email from", nil, map[string]string{"EMAIL_BACKEND": "sendgrid", "SENDGRID_API_KEY": "key"}, "invalid email.from"},
+		{"short signing key", nil, map[string]string{"SIGNING_KEY": "too-short"}, "invalid signing_key"},
+		{"short webhook secret", nil, map[string]string{"WEBHOOK_GIT_SECRET": "too-short"}, "invalid webhooks.git.secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(tt.args, envFrom(tt.env))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestLoad_OAuthRequiredWithProvider(t *testing.T) {
+	cfg, err := load(nil, envFrom(map[string]string{
+		"OAUTH_REQUIRED":       "true",
+		"GOOGLE_CLIENT_ID":     "id",
+		"GOOGLE_CLIENT_SECRET": "secret",
+	}))
+	require.NoError(t, err)
+	assert.True(t, cfg.OAuth.Google.Configured())
+}
+
+func TestLoad_TelemetryOffByDefault(t *testing.T) {
+	cfg, err := load(nil, envFrom(map[string]string{"TELEMETRY_ENDPOINT": "https://telemetry.example.com/v1"}))
+	require.NoError(t, err)
+	assert.False(t, cfg.Telemetry.Enabled, "an endpoint alone does not opt in")
+}
+
+func TestLoad_EmailSender(t *testing.T) {
+	cfg, err := load(nil, envFrom(nil))
+	require.NoError(t, err)
+	assert.IsType(t, &email.LogSender{}, cfg.Email.Sender())
+
+	cfg, err = load(nil, envFrom(map[string]string{
+		"EMAIL_BACKEND": "smtp",
+		"EMAIL_FROM":    "Noture <mail@example.com>",
+		"SMTP_HOST":     "smtp.example.com",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 587, cfg.Email.SMTP.Port)
+	assert.IsType(t, &email.SMTPSender{}, cfg.Email.Sender())
+}
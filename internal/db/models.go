@@ -11,6 +11,47 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type OrganizationRole string
+
+const (
+	OrganizationRoleOwner  OrganizationRole = "owner"
+	OrganizationRoleAdmin  OrganizationRole = "admin"
+	OrganizationRoleMember OrganizationRole = "member"
+)
+
+func (e *OrganizationRole) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = OrganizationRole(s)
+	case string:
+		*e = OrganizationRole(s)
+	default:
+		return fmt.Errorf("unsupported scan type for OrganizationRole: %T", src)
+	}
+	return nil
+}
+
+type NullOrganizationRole struct {
+	OrganizationRole OrganizationRole
+	Valid            bool // Valid is true if OrganizationRole is not NULL
+}
+
+func (ns *NullOrganizationRole) Scan(value interface{}) error {
+	if value == nil {
+		ns.OrganizationRole, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.OrganizationRole.Scan(value)
+}
+
+func (ns NullOrganizationRole) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.OrganizationRole), nil
+}
+
 type UserTier string
 
 const (
@@ -52,14 +93,130 @@ func (ns NullUserTier) Value() (driver.Value, error) {
 	return string(ns.UserTier), nil
 }
 
+type WorkspaceRole string
+
+const (
+	WorkspaceRoleOwner  WorkspaceRole = "owner"
+	WorkspaceRoleEditor WorkspaceRole = "editor"
+	WorkspaceRoleViewer WorkspaceRole = "viewer"
+)
+
+func (e *WorkspaceRole) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = WorkspaceRole(s)
+	case string:
+		*e = WorkspaceRole(s)
+	default:
+		return fmt.Errorf("unsupported scan type for WorkspaceRole: %T", src)
+	}
+	return nil
+}
+
+type NullWorkspaceRole struct {
+	WorkspaceRole WorkspaceRole
+	Valid         bool // Valid is true if WorkspaceRole is not NULL
+}
+
+func (ns *NullWorkspaceRole) Scan(value interface{}) error {
+	if value == nil {
+		ns.WorkspaceRole, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.WorkspaceRole.Scan(value)
+}
+
+func (ns NullWorkspaceRole) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.WorkspaceRole), nil
+}
+
+type AgendaEntry struct {
+	FileID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	Line        int32
+	Kind        string
+	Day         pgtype.Date
+	TimeOfDay   string
+	Title       string
+	Keyword     string
+	Done        bool
+}
+
 type ApiToken struct {
-	ID         pgtype.UUID
-	UserID     pgtype.UUID
-	TokenHash  string
-	Name       string
-	LastUsedAt pgtype.Timestamptz
-	ExpiresAt  pgtype.Timestamptz
-	CreatedAt  pgtype.Timestamptz
+	ID            pgtype.UUID
+	UserID        pgtype.UUID
+	TokenHash     string
+	Name          string
+	LastUsedAt    pgtype.Timestamptz
+	ExpiresAt     pgtype.Timestamptz
+	CreatedAt     pgtype.Timestamptz
+	DeviceID      pgtype.UUID
+	ClientName    pgtype.Text
+	ClientVersion pgtype.Text
+	Scopes        []string
+	LastIp        pgtype.Text
+	UserAgent     pgtype.Text
+}
+
+type AuthFailure struct {
+	Key          string
+	Failures     int32
+	LockedUntil  pgtype.Timestamptz
+	LastFailedAt pgtype.Timestamptz
+}
+
+type AuthSession struct {
+	ID            pgtype.UUID
+	Kind          string
+	SessionKey    string
+	UserCode      pgtype.Text
+	Provider      pgtype.Text
+	DeviceCode    pgtype.Text
+	DeviceName    pgtype.Text
+	UserID        pgtype.UUID
+	ExpiresAt     pgtype.Timestamptz
+	CreatedAt     pgtype.Timestamptz
+	CodeVerifier  pgtype.Text
+	CodeChallenge pgtype.Text
+}
+
+type Blob struct {
+	ContentHash string
+	Content     []byte
+	CreatedAt   pgtype.Timestamptz
+}
+
+type BlobRef struct {
+	ContentHash string
+	RefCount    int64
+	UpdatedAt   pgtype.Timestamptz
+}
+
+type CalendarFeed struct {
+	ID            pgtype.UUID
+	TokenHash     string
+	WorkspaceID   pgtype.UUID
+	UserID        pgtype.UUID
+	CreatedAt     pgtype.Timestamptz
+	LastFetchedAt pgtype.Timestamptz
+}
+
+type DailyNoteSetting struct {
+	WorkspaceID  pgtype.UUID
+	PathPattern  string
+	TemplateName string
+	UpdatedAt    pgtype.Timestamptz
+}
+
+type Device struct {
+	ID        pgtype.UUID
+	UserID    pgtype.UUID
+	Name      string
+	CreatedAt pgtype.Timestamptz
 }
 
 type File struct {
@@ -67,7 +224,6 @@ type File struct {
 	WorkspaceID  pgtype.UUID
 	FilePath     string
 	ContentHash  string
-	Content      []byte
 	SizeBytes    int64
 	MimeType     pgtype.Text
 	LastModified pgtype.Timestamptz
@@ -76,12 +232,64 @@ type File struct {
 }
 
 type FileMetadatum struct {
-	FileID       pgtype.UUID
-	Format       string
-	ParsedBlocks []byte
-	Properties   []byte
-	WordCount    pgtype.Int4
-	LastParsed   pgtype.Timestamptz
+	FileID        pgtype.UUID
+	Format        string
+	ParsedBlocks  []byte
+	Properties    []byte
+	WordCount     pgtype.Int4
+	LastParsed    pgtype.Timestamptz
+	ParserVersion int32
+}
+
+type FileMetum struct {
+	FileID    pgtype.UUID
+	Meta      []byte
+	UpdatedAt pgtype.Timestamptz
+}
+
+type FileNoteID struct {
+	FileID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	NoteID      string
+}
+
+type FileOpen struct {
+	UserID      pgtype.UUID
+	FileID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	OpenedAt    pgtype.Timestamptz
+}
+
+type FilePin struct {
+	UserID      pgtype.UUID
+	FileID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	PinnedAt    pgtype.Timestamptz
+}
+
+type FileSearch struct {
+	FileID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	Document    interface{}
+	ContentHash string
+}
+
+type FileSearchFailure struct {
+	FileID      pgtype.UUID
+	ContentHash string
+	Attempts    int32
+	LastError   string
+	UpdatedAt   pgtype.Timestamptz
+}
+
+type FileTask struct {
+	FileID      pgtype.UUID
+	TaskKey     string
+	WorkspaceID pgtype.UUID
+	Title       string
+	Done        bool
+	Deadline    pgtype.Timestamptz
+	RemindedAt  pgtype.Timestamptz
 }
 
 type FileVersion struct {
@@ -89,10 +297,193 @@ type FileVersion struct {
 	FileID        pgtype.UUID
 	VersionNumber int32
 	ContentHash   string
-	Content       []byte
 	CreatedAt     pgtype.Timestamptz
 }
 
+type IdempotencyKey struct {
+	UserID          pgtype.UUID
+	IdempotencyKey  string
+	Fingerprint     pgtype.Text
+	StatusCode      pgtype.Int4
+	ResponseHeaders []byte
+	ResponseBody    []byte
+	CreatedAt       pgtype.Timestamptz
+	CompletedAt     pgtype.Timestamptz
+}
+
+type NoteLink struct {
+	FileID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	Line        int32
+	Target      string
+	Candidates  []string
+}
+
+type NoteTag struct {
+	FileID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	Tag         string
+}
+
+type NoteTemplate struct {
+	ID          pgtype.UUID
+	WorkspaceID pgtype.UUID
+	Name        string
+	Description string
+	Content     string
+	CreatedBy   pgtype.UUID
+	CreatedAt   pgtype.Timestamptz
+}
+
+type OauthIdentity struct {
+	ID             pgtype.UUID
+	UserID         pgtype.UUID
+	Provider       string
+	ProviderUserID string
+	Email          pgtype.Text
+	CreatedAt      pgtype.Timestamptz
+	LastLoginAt    pgtype.Timestamptz
+}
+
+type Notification struct {
+	ID          pgtype.UUID
+	UserID      pgtype.UUID
+	Kind        string
+	WorkspaceID pgtype.UUID
+	Title       string
+	Body        string
+	Data        []byte
+	ReadAt      pgtype.Timestamptz
+	CreatedAt   pgtype.Timestamptz
+}
+
+type Operation struct {
+	ID             pgtype.UUID
+	Kind           string
+	UserID         pgtype.UUID
+	WorkspaceID    pgtype.UUID
+	Status         string
+	Params         []byte
+	State          []byte
+	StepsDone      int32
+	StepsTotal     int32
+	Result         []byte
+	Error          pgtype.Text
+	Attempts       int32
+	LeaseExpiresAt pgtype.Timestamptz
+	CreatedAt      pgtype.Timestamptz
+	UpdatedAt      pgtype.Timestamptz
+	FinishedAt     pgtype.Timestamptz
+}
+
+type OperationUpload struct {
+	OperationID pgtype.UUID
+	Content     []byte
+	CreatedAt   pgtype.Timestamptz
+}
+
+type Organization struct {
+	ID                pgtype.UUID
+	Name              string
+	BillingEmail      string
+	Tier              UserTier
+	SeatStorageBytes  int64
+	StorageLimitBytes pgtype.Int8
+	CreatedAt         pgtype.Timestamptz
+	UpdatedAt         pgtype.Timestamptz
+}
+
+type OrganizationMember struct {
+	OrganizationID pgtype.UUID
+	UserID         pgtype.UUID
+	Role           OrganizationRole
+	InvitedBy      pgtype.UUID
+	CreatedAt      pgtype.Timestamptz
+}
+
+type OrganizationWorkspace struct {
+	WorkspaceID    pgtype.UUID
+	OrganizationID pgtype.UUID
+	AddedBy        pgtype.UUID
+	CreatedAt      pgtype.Timestamptz
+}
+
+type Passkey struct {
+	ID           pgtype.UUID
+	UserID       pgtype.UUID
+	CredentialID []byte
+	PublicKey    []byte
+	SignCount    int64
+	Name         string
+	CreatedAt    pgtype.Timestamptz
+	LastUsedAt   pgtype.Timestamptz
+}
+
+type PasswordReset struct {
+	UserID    pgtype.UUID
+	TokenHash string
+	ExpiresAt pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+}
+
+type PolicyRule struct {
+	ID        pgtype.UUID
+	Name      string
+	Detector  string
+	Pattern   string
+	Action    string
+	CreatedBy pgtype.UUID
+	CreatedAt pgtype.Timestamptz
+}
+
+type PolicyViolation struct {
+	ID          int64
+	RuleID      pgtype.UUID
+	RuleName    string
+	WorkspaceID pgtype.UUID
+	UserID      pgtype.UUID
+	FilePath    string
+	ActionType  string
+	Blocked     bool
+	MatchCount  int32
+	CreatedAt   pgtype.Timestamptz
+}
+
+type PublishedSite struct {
+	WorkspaceID    pgtype.UUID
+	Slug           string
+	IncludeFolders []string
+	ExcludeFolders []string
+	RequireFlag    bool
+	PublishedBy    pgtype.UUID
+	CreatedAt      pgtype.Timestamptz
+	UpdatedAt      pgtype.Timestamptz
+}
+
+type SearchSnapshot struct {
+	ID          pgtype.UUID
+	WorkspaceID pgtype.UUID
+	UserID      pgtype.UUID
+	Query       string
+	Revision    int64
+	FileIds     []pgtype.UUID
+	CreatedAt   pgtype.Timestamptz
+	ExpiresAt   pgtype.Timestamptz
+}
+
+type ShareLink struct {
+	ID                pgtype.UUID
+	TokenHash         string
+	FileID            pgtype.UUID
+	WorkspaceID       pgtype.UUID
+	CreatedBy         pgtype.UUID
+	ExpiresAt         pgtype.Timestamptz
+	RevokedAt         pgtype.Timestamptz
+	CreatedAt         pgtype.Timestamptz
+	PolicyCheckedHash pgtype.Text
+	PolicyBlocked     bool
+}
+
 type SyncOperation struct {
 	ID            pgtype.UUID
 	WorkspaceID   pgtype.UUID
@@ -102,16 +493,41 @@ type SyncOperation struct {
 	Status        string
 	ErrorMessage  pgtype.Text
 	CreatedAt     pgtype.Timestamptz
+	FilePath      pgtype.Text
+}
+
+type UsageMonthly struct {
+	UserID        pgtype.UUID
+	Month         pgtype.Date
+	ApiRequests   int64
+	UploadBytes   int64
+	DownloadBytes int64
+	UpdatedAt     pgtype.Timestamptz
 }
 
 type User struct {
-	ID               pgtype.UUID
-	Email            string
-	PasswordHash     string
-	Tier             UserTier
-	StorageUsedBytes pgtype.Int8
-	CreatedAt        pgtype.Timestamptz
-	UpdatedAt        pgtype.Timestamptz
+	ID                pgtype.UUID
+	Email             string
+	PasswordHash      string
+	Tier              UserTier
+	StorageUsedBytes  pgtype.Int8
+	CreatedAt         pgtype.Timestamptz
+	UpdatedAt         pgtype.Timestamptz
+	Timezone          string
+	IsAdmin           bool
+	StorageLimitBytes pgtype.Int8
+	DisabledAt        pgtype.Timestamptz
+	DeleteAfter       pgtype.Timestamptz
+	EmailVerifiedAt   pgtype.Timestamptz
+}
+
+type UserPreference struct {
+	UserID             pgtype.UUID
+	Locale             string
+	DefaultWorkspaceID pgtype.UUID
+	Notifications      []byte
+	Editor             []byte
+	UpdatedAt          pgtype.Timestamptz
 }
 
 type Workspace struct {
@@ -122,4 +538,98 @@ type Workspace struct {
 	StorageUsedBytes  pgtype.Int8
 	CreatedAt         pgtype.Timestamptz
 	UpdatedAt         pgtype.Timestamptz
+	FileCount         int64
+	ArchivedAt        pgtype.Timestamptz
+	Icon              string
+	Color             string
+	Description       string
+	Revision          int64
+}
+
+type WorkspaceEvent struct {
+	ID          int64
+	WorkspaceID pgtype.UUID
+	EventType   string
+	FilePath    pgtype.Text
+	ActorID     pgtype.UUID
+	Payload     []byte
+	CreatedAt   pgtype.Timestamptz
+}
+
+type WorkspaceGitMirror struct {
+	WorkspaceID     pgtype.UUID
+	RemoteUrl       string
+	Branch          string
+	Pull            bool
+	PrivateKey      string
+	PublicKey       string
+	CreatedBy       pgtype.UUID
+	LastEventID     int64
+	LastCommit      pgtype.Text
+	LastSyncedAt    pgtype.Timestamptz
+	SyncRequestedAt pgtype.Timestamptz
+	FailureCount    int32
+	LastError       pgtype.Text
+	NextAttemptAt   pgtype.Timestamptz
+	CreatedAt       pgtype.Timestamptz
+	UpdatedAt       pgtype.Timestamptz
+}
+
+type WorkspaceInvite struct {
+	ID          pgtype.UUID
+	WorkspaceID pgtype.UUID
+	Email       string
+	Role        WorkspaceRole
+	TokenHash   string
+	InvitedBy   pgtype.UUID
+	ExpiresAt   pgtype.Timestamptz
+	AcceptedAt  pgtype.Timestamptz
+	AcceptedBy  pgtype.UUID
+	CreatedAt   pgtype.Timestamptz
+}
+
+type WorkspaceMember struct {
+	WorkspaceID pgtype.UUID
+	UserID      pgtype.UUID
+	Role        WorkspaceRole
+	InvitedBy   pgtype.UUID
+	CreatedAt   pgtype.Timestamptz
+	UpdatedAt   pgtype.Timestamptz
+}
+
+type WorkspaceSortOrder struct {
+	UserID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	SortOrder   int32
+}
+
+type WorkspaceStorageAlert struct {
+	WorkspaceID pgtype.UUID
+	Threshold   int32
+	UpdatedAt   pgtype.Timestamptz
+}
+
+type WorkspaceSuggestion struct {
+	ID             pgtype.UUID
+	WorkspaceID    pgtype.UUID
+	UserID         pgtype.UUID
+	Reason         string
+	LastActivityAt pgtype.Timestamptz
+	DismissedAt    pgtype.Timestamptz
+	CreatedAt      pgtype.Timestamptz
+}
+
+type WorkspaceWebhook struct {
+	ID            pgtype.UUID
+	WorkspaceID   pgtype.UUID
+	Url           string
+	Secret        string
+	EventTypes    []string
+	PathPrefix    string
+	CreatedBy     pgtype.UUID
+	LastEventID   int64
+	FailureCount  int32
+	LastError     pgtype.Text
+	NextAttemptAt pgtype.Timestamptz
+	CreatedAt     pgtype.Timestamptz
 }
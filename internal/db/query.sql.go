@@ -11,290 +11,324 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const createAPIToken = `-- name: CreateAPIToken :one
-INSERT INTO api_tokens (user_id, token_hash, name, expires_at)
+const acceptWorkspaceInvite = `-- name: AcceptWorkspaceInvite :execrows
+UPDATE workspace_invites SET accepted_at = NOW(), accepted_by = $2
+WHERE id = $1 AND accepted_at IS NULL AND expires_at > NOW()
+`
+
+type AcceptWorkspaceInviteParams struct {
+	ID         pgtype.UUID
+	AcceptedBy pgtype.UUID
+}
+
+func (q *Queries) AcceptWorkspaceInvite(ctx context.Context, arg AcceptWorkspaceInviteParams) (int64, error) {
+	result, err := q.db.Exec(ctx, acceptWorkspaceInvite, arg.ID, arg.AcceptedBy)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const addOrganizationMember = `-- name: AddOrganizationMember :one
+INSERT INTO organization_members (organization_id, user_id, role, invited_by)
 VALUES ($1, $2, $3, $4)
-RETURNING id, user_id, token_hash, name, last_used_at, expires_at, created_at
+RETURNING organization_id, user_id, role, invited_by, created_at
 `
 
-type CreateAPITokenParams struct {
-	UserID    pgtype.UUID
-	TokenHash string
-	Name      string
-	ExpiresAt pgtype.Timestamptz
+type AddOrganizationMemberParams struct {
+	OrganizationID pgtype.UUID
+	UserID         pgtype.UUID
+	Role           OrganizationRole
+	InvitedBy      pgtype.UUID
 }
 
-func (q *Queries) CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) (ApiToken, error) {
-	row := q.db.QueryRow(ctx, createAPIToken,
+func (q *Queries) AddOrganizationMember(ctx context.Context, arg AddOrganizationMemberParams) (OrganizationMember, error) {
+	row := q.db.QueryRow(ctx, addOrganizationMember,
+		arg.OrganizationID,
 		arg.UserID,
-		arg.TokenHash,
-		arg.Name,
-		arg.ExpiresAt,
+		arg.Role,
+		arg.InvitedBy,
 	)
-	var i ApiToken
+	var i OrganizationMember
 	err := row.Scan(
-		&i.ID,
+		&i.OrganizationID,
 		&i.UserID,
-		&i.TokenHash,
-		&i.Name,
-		&i.LastUsedAt,
-		&i.ExpiresAt,
+		&i.Role,
+		&i.InvitedBy,
 		&i.CreatedAt,
 	)
 	return i, err
 }
 
-const createFileVersion = `-- name: CreateFileVersion :exec
-INSERT INTO file_versions (file_id, version_number, content_hash, content)
-VALUES ($1, $2, $3, $4)
+const addOrganizationWorkspace = `-- name: AddOrganizationWorkspace :exec
+INSERT INTO organization_workspaces (workspace_id, organization_id, added_by)
+VALUES ($1, $2, $3)
 `
 
-type CreateFileVersionParams struct {
-	FileID        pgtype.UUID
-	VersionNumber int32
-	ContentHash   string
-	Content       []byte
+type AddOrganizationWorkspaceParams struct {
+	WorkspaceID    pgtype.UUID
+	OrganizationID pgtype.UUID
+	AddedBy        pgtype.UUID
 }
 
-func (q *Queries) CreateFileVersion(ctx context.Context, arg CreateFileVersionParams) error {
-	_, err := q.db.Exec(ctx, createFileVersion,
-		arg.FileID,
-		arg.VersionNumber,
-		arg.ContentHash,
-		arg.Content,
-	)
+func (q *Queries) AddOrganizationWorkspace(ctx context.Context, arg AddOrganizationWorkspaceParams) error {
+	_, err := q.db.Exec(ctx, addOrganizationWorkspace, arg.WorkspaceID, arg.OrganizationID, arg.AddedBy)
 	return err
 }
 
-const createSyncOperation = `-- name: CreateSyncOperation :one
-INSERT INTO sync_operations (workspace_id, file_id, operation_type, client_id, status)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, workspace_id, file_id, operation_type, client_id, status, error_message, created_at
+const addUsage = `-- name: AddUsage :exec
+INSERT INTO usage_monthly (user_id, month, api_requests, upload_bytes, download_bytes)
+SELECT u.id, $1::date, $2::bigint, $3::bigint, $4::bigint
+FROM users u
+WHERE u.id = $5
+ON CONFLICT (user_id, month) DO UPDATE SET
+    api_requests = usage_monthly.api_requests + EXCLUDED.api_requests,
+    upload_bytes = usage_monthly.upload_bytes + EXCLUDED.upload_bytes,
+    download_bytes = usage_monthly.download_bytes + EXCLUDED.download_bytes,
+    updated_at = NOW()
 `
 
-type CreateSyncOperationParams struct {
-	WorkspaceID   pgtype.UUID
-	FileID        pgtype.UUID
-	OperationType string
-	ClientID      pgtype.Text
-	Status        string
+type AddUsageParams struct {
+	Month         pgtype.Date
+	ApiRequests   int64
+	UploadBytes   int64
+	DownloadBytes int64
+	UserID        pgtype.UUID
 }
 
-func (q *Queries) CreateSyncOperation(ctx context.Context, arg CreateSyncOperationParams) (SyncOperation, error) {
-	row := q.db.QueryRow(ctx, createSyncOperation,
-		arg.WorkspaceID,
-		arg.FileID,
-		arg.OperationType,
-		arg.ClientID,
-		arg.Status,
-	)
-	var i SyncOperation
-	err := row.Scan(
-		&i.ID,
-		&i.WorkspaceID,
-		&i.FileID,
-		&i.OperationType,
-		&i.ClientID,
-		&i.Status,
-		&i.ErrorMessage,
-		&i.CreatedAt,
+// Adds counts to a user's month. Counts of users deleted meanwhile are
+// dropped.
+func (q *Queries) AddUsage(ctx context.Context, arg AddUsageParams) error {
+	_, err := q.db.Exec(ctx, addUsage,
+		arg.Month,
+		arg.ApiRequests,
+		arg.UploadBytes,
+		arg.DownloadBytes,
+		arg.UserID,
 	)
-	return i, err
+	return err
 }
 
-const createUser = `-- name: CreateUser :one
-INSERT INTO users (email, password_hash, tier)
-VALUES ($1, $2, $3)
-RETURNING id, email, password_hash, tier, storage_used_bytes, created_at, updated_at
+const addWorkspaceMember = `-- name: AddWorkspaceMember :one
+INSERT INTO workspace_members (workspace_id, user_id, role, invited_by)
+VALUES ($1, $2, $3, $4)
+RETURNING workspace_id, user_id, role, invited_by, created_at, updated_at
 `
 
-type CreateUserParams struct {
-	Email        string
-	PasswordHash string
-	Tier         UserTier
+type AddWorkspaceMemberParams struct {
+	WorkspaceID pgtype.UUID
+	UserID      pgtype.UUID
+	Role        WorkspaceRole
+	InvitedBy   pgtype.UUID
 }
 
-func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRow(ctx, createUser, arg.Email, arg.PasswordHash, arg.Tier)
-	var i User
+func (q *Queries) AddWorkspaceMember(ctx context.Context, arg AddWorkspaceMemberParams) (WorkspaceMember, error) {
+	row := q.db.QueryRow(ctx, addWorkspaceMember,
+		arg.WorkspaceID,
+		arg.UserID,
+		arg.Role,
+		arg.InvitedBy,
+	)
+	var i WorkspaceMember
 	err := row.Scan(
-		&i.ID,
-		&i.Email,
-		&i.PasswordHash,
-		&i.Tier,
-		&i.StorageUsedBytes,
+		&i.WorkspaceID,
+		&i.UserID,
+		&i.Role,
+		&i.InvitedBy,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
 	return i, err
 }
 
-const createWorkspace = `-- name: CreateWorkspace :one
-INSERT INTO workspaces (user_id, name, storage_limit_bytes)
-VALUES ($1, $2, $3)
-RETURNING id, user_id, name, storage_limit_bytes, storage_used_bytes, created_at, updated_at
+const adjustWorkspaceCounters = `-- name: AdjustWorkspaceCounters :one
+UPDATE workspaces
+SET file_count = file_count + $2,
+    storage_used_bytes = storage_used_bytes + $3,
+    revision = revision + 1,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING revision
 `
 
-type CreateWorkspaceParams struct {
-	UserID            pgtype.UUID
-	Name              string
-	StorageLimitBytes int64
+type AdjustWorkspaceCountersParams struct {
+	ID               pgtype.UUID
+	FileCount        int64
+	StorageUsedBytes pgtype.Int8
 }
 
-func (q *Queries) CreateWorkspace(ctx context.Context, arg CreateWorkspaceParams) (Workspace, error) {
-	row := q.db.QueryRow(ctx, createWorkspace, arg.UserID, arg.Name, arg.StorageLimitBytes)
-	var i Workspace
-	err := row.Scan(
-		&i.ID,
-		&i.UserID,
-		&i.Name,
-		&i.StorageLimitBytes,
-		&i.StorageUsedBytes,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-	)
-	return i, err
+func (q *Queries) AdjustWorkspaceCounters(ctx context.Context, arg AdjustWorkspaceCountersParams) (int64, error) {
+	row := q.db.QueryRow(ctx, adjustWorkspaceCounters, arg.ID, arg.FileCount, arg.StorageUsedBytes)
+	var revision int64
+	err := row.Scan(&revision)
+	return revision, err
 }
 
-const deleteAPIToken = `-- name: DeleteAPIToken :exec
-DELETE FROM api_tokens WHERE id = $1 AND user_id = $2
+const advanceWebhook = `-- name: AdvanceWebhook :exec
+UPDATE workspace_webhooks
+SET last_event_id = $2, failure_count = 0, last_error = NULL, next_attempt_at = NULL
+WHERE id = $1
 `
 
-type DeleteAPITokenParams struct {
-	ID     pgtype.UUID
-	UserID pgtype.UUID
+type AdvanceWebhookParams struct {
+	ID          pgtype.UUID
+	LastEventID int64
 }
 
-func (q *Queries) DeleteAPIToken(ctx context.Context, arg DeleteAPITokenParams) error {
-	_, err := q.db.Exec(ctx, deleteAPIToken, arg.ID, arg.UserID)
+func (q *Queries) AdvanceWebhook(ctx context.Context, arg AdvanceWebhookParams) error {
+	_, err := q.db.Exec(ctx, advanceWebhook, arg.ID, arg.LastEventID)
 	return err
 }
 
-const deleteFile = `-- name: DeleteFile :exec
-DELETE FROM files WHERE workspace_id = $1 AND file_path = $2
+const approveDeviceSession = `-- name: ApproveDeviceSession :execrows
+UPDATE auth_sessions SET user_id = $2
+WHERE kind = 'device' AND session_key = $1 AND user_id IS NULL AND expires_at > NOW()
 `
 
-type DeleteFileParams struct {
-	WorkspaceID pgtype.UUID
-	FilePath    string
+type ApproveDeviceSessionParams struct {
+	SessionKey string
+	UserID     pgtype.UUID
 }
 
-func (q *Queries) DeleteFile(ctx context.Context, arg DeleteFileParams) error {
-	_, err := q.db.Exec(ctx, deleteFile, arg.WorkspaceID, arg.FilePath)
-	return err
+func (q *Queries) ApproveDeviceSession(ctx context.Context, arg ApproveDeviceSessionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, approveDeviceSession, arg.SessionKey, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
-const getFile = `-- name: GetFile :one
-SELECT id, workspace_id, file_path, content_hash, content, size_bytes, mime_type, last_modified, created_at, updated_at FROM files WHERE workspace_id = $1 AND file_path = $2
+const blobRefExists = `-- name: BlobRefExists :one
+SELECT EXISTS(SELECT 1 FROM blob_refs WHERE content_hash = $1)
 `
 
-type GetFileParams struct {
-	WorkspaceID pgtype.UUID
-	FilePath    string
-}
-
-func (q *Queries) GetFile(ctx context.Context, arg GetFileParams) (File, error) {
-	row := q.db.QueryRow(ctx, getFile, arg.WorkspaceID, arg.FilePath)
-	var i File
-	err := row.Scan(
-		&i.ID,
-		&i.WorkspaceID,
-		&i.FilePath,
-		&i.ContentHash,
-		&i.Content,
-		&i.SizeBytes,
-		&i.MimeType,
-		&i.LastModified,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-	)
-	return i, err
+func (q *Queries) BlobRefExists(ctx context.Context, contentHash string) (bool, error) {
+	row := q.db.QueryRow(ctx, blobRefExists, contentHash)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
 }
 
-const getFileByID = `-- name: GetFileByID :one
-SELECT id, workspace_id, file_path, content_hash, content, size_bytes, mime_type, last_modified, created_at, updated_at FROM files WHERE id = $1
+const cancelUserDeletion = `-- name: CancelUserDeletion :execrows
+UPDATE users SET delete_after = NULL, updated_at = NOW()
+WHERE id = $1 AND delete_after IS NOT NULL
 `
 
-func (q *Queries) GetFileByID(ctx context.Context, id pgtype.UUID) (File, error) {
-	row := q.db.QueryRow(ctx, getFileByID, id)
-	var i File
-	err := row.Scan(
-		&i.ID,
-		&i.WorkspaceID,
-		&i.FilePath,
-		&i.ContentHash,
-		&i.Content,
-		&i.SizeBytes,
-		&i.MimeType,
-		&i.LastModified,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-	)
-	return i, err
+func (q *Queries) CancelUserDeletion(ctx context.Context, id pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, cancelUserDeletion, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
-const getFileContent = `-- name: GetFileContent :one
-SELECT content FROM files WHERE workspace_id = $1 AND file_path = $2
+const checkpointOperation = `-- name: CheckpointOperation :execrows
+UPDATE operations
+SET state = $2, steps_done = $3, steps_total = $4, lease_expires_at = $5, updated_at = NOW()
+WHERE id = $1 AND status = 'running' AND attempts = $6
 `
 
-type GetFileContentParams struct {
-	WorkspaceID pgtype.UUID
-	FilePath    string
+type CheckpointOperationParams struct {
+	ID             pgtype.UUID
+	State          []byte
+	StepsDone      int32
+	StepsTotal     int32
+	LeaseExpiresAt pgtype.Timestamptz
+	Attempts       int32
 }
 
-func (q *Queries) GetFileContent(ctx context.Context, arg GetFileContentParams) ([]byte, error) {
-	row := q.db.QueryRow(ctx, getFileContent, arg.WorkspaceID, arg.FilePath)
-	var content []byte
-	err := row.Scan(&content)
-	return content, err
+func (q *Queries) CheckpointOperation(ctx context.Context, arg CheckpointOperationParams) (int64, error) {
+	result, err := q.db.Exec(ctx, checkpointOperation,
+		arg.ID,
+		arg.State,
+		arg.StepsDone,
+		arg.StepsTotal,
+		arg.LeaseExpiresAt,
+		arg.Attempts,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
-const getFileMetadata = `-- name: GetFileMetadata :one
-SELECT file_id, format, parsed_blocks, properties, word_count, last_parsed FROM file_metadata WHERE file_id = $1
+const claimDueGitMirrors = `-- name: ClaimDueGitMirrors :many
+UPDATE workspace_git_mirrors m
+SET next_attempt_at = $1
+FROM workspaces w, users u
+WHERE w.id = m.workspace_id AND u.id = w.user_id
+  AND m.workspace_id IN (
+    SELECT d.workspace_id FROM workspace_git_mirrors d
+    WHERE (d.next_attempt_at IS NULL OR d.next_attempt_at <= NOW())
+      AND (d.last_synced_at IS NULL
+           OR d.sync_requested_at IS NOT NULL
+           OR (d.pull AND d.last_synced_at <= $2)
+           OR EXISTS (SELECT 1 FROM workspace_events e WHERE e.workspace_id = d.workspace_id AND e.id > d.last_event_id))
+    ORDER BY d.next_attempt_at NULLS FIRST, d.created_at
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED)
+RETURNING m.workspace_id, m.remote_url, m.branch, m.pull, m.private_key, m.public_key, m.created_by, m.last_event_id, m.last_commit, m.last_synced_at, m.sync_requested_at, m.failure_count, m.last_error, m.next_attempt_at, m.created_at, m.updated_at, w.user_id AS owner_id, u.email AS owner_email, w.archived_at
 `
 
-func (q *Queries) GetFileMetadata(ctx context.Context, fileID pgtype.UUID) (FileMetadatum, error) {
-	row := q.db.QueryRow(ctx, getFileMetadata, fileID)
-	var i FileMetadatum
-	err := row.Scan(
-		&i.FileID,
-		&i.Format,
-		&i.ParsedBlocks,
-		&i.Properties,
-		&i.WordCount,
-		&i.LastParsed,
-	)
-	return i, err
+type ClaimDueGitMirrorsParams struct {
+	LeaseUntil pgtype.Timestamptz
+	PullBefore pgtype.Timestamptz
+	BatchSize  int32
 }
 
-const getFileVersions = `-- name: GetFileVersions :many
-SELECT id, file_id, version_number, content_hash, content, created_at FROM file_versions 
-WHERE file_id = $1 
-ORDER BY version_number DESC 
-LIMIT $2
-`
-
-type GetFileVersionsParams struct {
-	FileID pgtype.UUID
-	Limit  int32
+type ClaimDueGitMirrorsRow struct {
+	WorkspaceID     pgtype.UUID
+	RemoteUrl       string
+	Branch          string
+	Pull            bool
+	PrivateKey      string
+	PublicKey       string
+	CreatedBy       pgtype.UUID
+	LastEventID     int64
+	LastCommit      pgtype.Text
+	LastSyncedAt    pgtype.Timestamptz
+	SyncRequestedAt pgtype.Timestamptz
+	FailureCount    int32
+	LastError       pgtype.Text
+	NextAttemptAt   pgtype.Timestamptz
+	CreatedAt       pgtype.Timestamptz
+	UpdatedAt       pgtype.Timestamptz
+	OwnerID         pgtype.UUID
+	OwnerEmail      string
+	ArchivedAt      pgtype.Timestamptz
 }
 
-func (q *Queries) GetFileVersions(ctx context.Context, arg GetFileVersionsParams) ([]FileVersion, error) {
-	rows, err := q.db.Query(ctx, getFileVersions, arg.FileID, arg.Limit)
+// Claiming a mirror leases it until lease_until, so that other instances
+// skip it while it syncs; finishing the sync, or failing it, ends the lease.
+func (q *Queries) ClaimDueGitMirrors(ctx context.Context, arg ClaimDueGitMirrorsParams) ([]ClaimDueGitMirrorsRow, error) {
+	rows, err := q.db.Query(ctx, claimDueGitMirrors, arg.LeaseUntil, arg.PullBefore, arg.BatchSize)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []FileVersion
+	var items []ClaimDueGitMirrorsRow
 	for rows.Next() {
-		var i FileVersion
+		var i ClaimDueGitMirrorsRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.FileID,
-			&i.VersionNumber,
-			&i.ContentHash,
-			&i.Content,
+			&i.WorkspaceID,
+			&i.RemoteUrl,
+			&i.Branch,
+			&i.Pull,
+			&i.PrivateKey,
+			&i.PublicKey,
+			&i.CreatedBy,
+			&i.LastEventID,
+			&i.LastCommit,
+			&i.LastSyncedAt,
+			&i.SyncRequestedAt,
+			&i.FailureCount,
+			&i.LastError,
+			&i.NextAttemptAt,
 			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OwnerID,
+			&i.OwnerEmail,
+			&i.ArchivedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -306,36 +340,50 @@ func (q *Queries) GetFileVersions(ctx context.Context, arg GetFileVersionsParams
 	return items, nil
 }
 
-const getSyncOperations = `-- name: GetSyncOperations :many
-SELECT id, workspace_id, file_id, operation_type, client_id, status, error_message, created_at FROM sync_operations 
-WHERE workspace_id = $1 
-ORDER BY created_at DESC 
-LIMIT $2
+const claimDueTasks = `-- name: ClaimDueTasks :many
+UPDATE file_tasks t SET reminded_at = NOW()
+FROM files f
+WHERE f.id = t.file_id
+  AND (t.file_id, t.task_key) IN (
+      SELECT d.file_id, d.task_key FROM file_tasks d
+      WHERE NOT d.done AND d.reminded_at IS NULL AND d.deadline <= $1
+      ORDER BY d.deadline
+      LIMIT $2
+      FOR UPDATE SKIP LOCKED
+  )
+RETURNING t.file_id, t.task_key, t.workspace_id, t.title, t.deadline, f.file_path
 `
 
-type GetSyncOperationsParams struct {
+type ClaimDueTasksParams struct {
+	DueBefore pgtype.Timestamptz
+	BatchSize int32
+}
+
+type ClaimDueTasksRow struct {
+	FileID      pgtype.UUID
+	TaskKey     string
 	WorkspaceID pgtype.UUID
-	Limit       int32
+	Title       string
+	Deadline    pgtype.Timestamptz
+	FilePath    string
 }
 
-func (q *Queries) GetSyncOperations(ctx context.Context, arg GetSyncOperationsParams) ([]SyncOperation, error) {
-	rows, err := q.db.Query(ctx, getSyncOperations, arg.WorkspaceID, arg.Limit)
+func (q *Queries) ClaimDueTasks(ctx context.Context, arg ClaimDueTasksParams) ([]ClaimDueTasksRow, error) {
+	rows, err := q.db.Query(ctx, claimDueTasks, arg.DueBefore, arg.BatchSize)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []SyncOperation
+	var items []ClaimDueTasksRow
 	for rows.Next() {
-		var i SyncOperation
+		var i ClaimDueTasksRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.WorkspaceID,
 			&i.FileID,
-			&i.OperationType,
-			&i.ClientID,
-			&i.Status,
-			&i.ErrorMessage,
-			&i.CreatedAt,
+			&i.TaskKey,
+			&i.WorkspaceID,
+			&i.Title,
+			&i.Deadline,
+			&i.FilePath,
 		); err != nil {
 			return nil, err
 		}
@@ -347,69 +395,6046 @@ func (q *Queries) GetSyncOperations(ctx context.Context, arg GetSyncOperationsPa
 	return items, nil
 }
 
-const getTokenByHash = `-- name: GetTokenByHash :one
-SELECT t.id, t.user_id, t.token_hash, t.name, t.last_used_at, t.expires_at, t.created_at, u.id as user_id, u.email, u.tier 
-FROM api_tokens t
-JOIN users u ON t.user_id = u.id
-WHERE t.token_hash = $1 AND (t.expires_at IS NULL OR t.expires_at > NOW())
+const claimIdempotencyKey = `-- name: ClaimIdempotencyKey :execrows
+INSERT INTO idempotency_keys (user_id, idempotency_key)
+VALUES ($1, $2)
+ON CONFLICT (user_id, idempotency_key) DO UPDATE SET
+    fingerprint = NULL,
+    status_code = NULL,
+    response_headers = NULL,
+    response_body = NULL,
+    created_at = NOW(),
+    completed_at = NULL
+WHERE (idempotency_keys.completed_at IS NULL AND idempotency_keys.created_at < $3)
+   OR idempotency_keys.created_at < $4
 `
 
-type GetTokenByHashRow struct {
-	ID         pgtype.UUID
-	UserID     pgtype.UUID
-	TokenHash  string
-	Name       string
-	LastUsedAt pgtype.Timestamptz
-	ExpiresAt  pgtype.Timestamptz
-	CreatedAt  pgtype.Timestamptz
-	UserID_2   pgtype.UUID
-	Email      string
-	Tier       UserTier
+type ClaimIdempotencyKeyParams struct {
+	UserID         pgtype.UUID
+	IdempotencyKey string
+	StaleBefore    pgtype.Timestamptz
+	ExpiredBefore  pgtype.Timestamptz
 }
 
-func (q *Queries) GetTokenByHash(ctx context.Context, tokenHash string) (GetTokenByHashRow, error) {
-	row := q.db.QueryRow(ctx, getTokenByHash, tokenHash)
-	var i GetTokenByHashRow
+func (q *Queries) ClaimIdempotencyKey(ctx context.Context, arg ClaimIdempotencyKeyParams) (int64, error) {
+	result, err := q.db.Exec(ctx, claimIdempotencyKey,
+		arg.UserID,
+		arg.IdempotencyKey,
+		arg.StaleBefore,
+		arg.ExpiredBefore,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const claimOperation = `-- name: ClaimOperation :one
+UPDATE operations
+SET status = 'running', attempts = attempts + 1,
+    lease_expires_at = $1, updated_at = NOW()
+WHERE id = (
+    SELECT id FROM operations
+    WHERE status = 'pending' OR (status = 'running' AND lease_expires_at < NOW())
+    ORDER BY created_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, kind, user_id, workspace_id, status, params, state, steps_done, steps_total, result, error, attempts, lease_expires_at, created_at, updated_at, finished_at
+`
+
+// Takes the oldest operation that is pending or whose worker's lease ran
+// out. attempts doubles as a fencing token for the checkpoints below.
+func (q *Queries) ClaimOperation(ctx context.Context, leaseExpiresAt pgtype.Timestamptz) (Operation, error) {
+	row := q.db.QueryRow(ctx, claimOperation, leaseExpiresAt)
+	var i Operation
 	err := row.Scan(
 		&i.ID,
+		&i.Kind,
 		&i.UserID,
-		&i.TokenHash,
-		&i.Name,
-		&i.LastUsedAt,
-		&i.ExpiresAt,
+		&i.WorkspaceID,
+		&i.Status,
+		&i.Params,
+		&i.State,
+		&i.StepsDone,
+		&i.StepsTotal,
+		&i.Result,
+		&i.Error,
+		&i.Attempts,
+		&i.LeaseExpiresAt,
 		&i.CreatedAt,
-		&i.UserID_2,
-		&i.Email,
-		&i.Tier,
+		&i.UpdatedAt,
+		&i.FinishedAt,
 	)
 	return i, err
 }
 
-const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, password_hash, tier, storage_used_bytes, created_at, updated_at FROM users WHERE email = $1
+const clearActiveWorkspaceSuggestions = `-- name: ClearActiveWorkspaceSuggestions :execrows
+DELETE FROM workspace_suggestions s
+USING workspaces w
+WHERE s.workspace_id = w.id
+  AND s.reason = 'inactive'
+  AND (w.archived_at IS NOT NULL OR w.updated_at >= $1)
 `
 
-func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
-	row := q.db.QueryRow(ctx, getUserByEmail, email)
-	var i User
+func (q *Queries) ClearActiveWorkspaceSuggestions(ctx context.Context, updatedAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, clearActiveWorkspaceSuggestions, updatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const clearAuthFailures = `-- name: ClearAuthFailures :exec
+DELETE FROM auth_failures WHERE key = $1
+`
+
+func (q *Queries) ClearAuthFailures(ctx context.Context, key string) error {
+	_, err := q.db.Exec(ctx, clearAuthFailures, key)
+	return err
+}
+
+const completeGitMirrorSync = `-- name: CompleteGitMirrorSync :exec
+UPDATE workspace_git_mirrors
+SET last_event_id = $1,
+    last_commit = $2,
+    last_synced_at = NOW(),
+    sync_requested_at = NULL,
+    failure_count = 0,
+    last_error = NULL,
+    next_attempt_at = NULL
+WHERE workspace_id = $3 AND remote_url = $4 AND branch = $5
+`
+
+type CompleteGitMirrorSyncParams struct {
+	LastEventID int64
+	LastCommit  pgtype.Text
+	WorkspaceID pgtype.UUID
+	RemoteUrl   string
+	Branch      string
+}
+
+// The remote is matched so that a sync finishing after the mirror was
+// pointed elsewhere does not record its commit for the new remote.
+func (q *Queries) CompleteGitMirrorSync(ctx context.Context, arg CompleteGitMirrorSyncParams) error {
+	_, err := q.db.Exec(ctx, completeGitMirrorSync,
+		arg.LastEventID,
+		arg.LastCommit,
+		arg.WorkspaceID,
+		arg.RemoteUrl,
+		arg.Branch,
+	)
+	return err
+}
+
+const completeIdempotencyKey = `-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys SET
+    fingerprint = $3,
+    status_code = $4,
+    response_headers = $5,
+    response_body = $6,
+    completed_at = NOW()
+WHERE user_id = $1 AND idempotency_key = $2
+`
+
+type CompleteIdempotencyKeyParams struct {
+	UserID          pgtype.UUID
+	IdempotencyKey  string
+	Fingerprint     pgtype.Text
+	StatusCode      pgtype.Int4
+	ResponseHeaders []byte
+	ResponseBody    []byte
+}
+
+func (q *Queries) CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams) error {
+	_, err := q.db.Exec(ctx, completeIdempotencyKey,
+		arg.UserID,
+		arg.IdempotencyKey,
+		arg.Fingerprint,
+		arg.StatusCode,
+		arg.ResponseHeaders,
+		arg.ResponseBody,
+	)
+	return err
+}
+
+const consumeAuthSession = `-- name: ConsumeAuthSession :one
+DELETE FROM auth_sessions
+WHERE kind = $1 AND session_key = $2 AND expires_at > NOW()
+RETURNING id, kind, session_key, user_code, provider, device_code, device_name, user_id, expires_at, created_at, code_verifier, code_challenge
+`
+
+type ConsumeAuthSessionParams struct {
+	Kind       string
+	SessionKey string
+}
+
+func (q *Queries) ConsumeAuthSession(ctx context.Context, arg ConsumeAuthSessionParams) (AuthSession, error) {
+	row := q.db.QueryRow(ctx, consumeAuthSession, arg.Kind, arg.SessionKey)
+	var i AuthSession
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.SessionKey,
+		&i.UserCode,
+		&i.Provider,
+		&i.DeviceCode,
+		&i.DeviceName,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.CodeVerifier,
+		&i.CodeChallenge,
+	)
+	return i, err
+}
+
+const consumePasswordReset = `-- name: ConsumePasswordReset :one
+DELETE FROM password_resets
+WHERE token_hash = $1 AND expires_at > NOW()
+RETURNING user_id
+`
+
+func (q *Queries) ConsumePasswordReset(ctx context.Context, tokenHash string) (pgtype.UUID, error) {
+	row := q.db.QueryRow(ctx, consumePasswordReset, tokenHash)
+	var user_id pgtype.UUID
+	err := row.Scan(&user_id)
+	return user_id, err
+}
+
+const countFilePins = `-- name: CountFilePins :one
+SELECT COUNT(*) FROM file_pins WHERE user_id = $1 AND workspace_id = $2
+`
+
+type CountFilePinsParams struct {
+	UserID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+}
+
+func (q *Queries) CountFilePins(ctx context.Context, arg CountFilePinsParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countFilePins, arg.UserID, arg.WorkspaceID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countOrganizationOwners = `-- name: CountOrganizationOwners :one
+SELECT COUNT(*) FROM organization_members WHERE organization_id = $1 AND role = 'owner'
+`
+
+func (q *Queries) CountOrganizationOwners(ctx context.Context, organizationID pgtype.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, countOrganizationOwners, organizationID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countPasskeys = `-- name: CountPasskeys :one
+SELECT COUNT(*) FROM passkeys WHERE user_id = $1
+`
+
+func (q *Queries) CountPasskeys(ctx context.Context, userID pgtype.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, countPasskeys, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countRunnableOperations = `-- name: CountRunnableOperations :one
+SELECT COUNT(*) FROM operations WHERE status IN ('pending', 'running')
+`
+
+func (q *Queries) CountRunnableOperations(ctx context.Context) (int64, error) {
+	row := q.db.QueryRow(ctx, countRunnableOperations)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUnreadNotifications = `-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL
+`
+
+func (q *Queries) CountUnreadNotifications(ctx context.Context, userID pgtype.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, countUnreadNotifications, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createAPIToken = `-- name: CreateAPIToken :one
+INSERT INTO api_tokens (user_id, token_hash, name, expires_at, device_id, scopes)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, user_id, token_hash, name, last_used_at, expires_at, created_at, device_id, client_name, client_version, scopes, last_ip, user_agent
+`
+
+type CreateAPITokenParams struct {
+	UserID    pgtype.UUID
+	TokenHash string
+	Name      string
+	ExpiresAt pgtype.Timestamptz
+	DeviceID  pgtype.UUID
+	Scopes    []string
+}
+
+func (q *Queries) CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) (ApiToken, error) {
+	row := q.db.QueryRow(ctx, createAPIToken,
+		arg.UserID,
+		arg.TokenHash,
+		arg.Name,
+		arg.ExpiresAt,
+		arg.DeviceID,
+		arg.Scopes,
+	)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.Name,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.DeviceID,
+		&i.ClientName,
+		&i.ClientVersion,
+		&i.Scopes,
+		&i.LastIp,
+		&i.UserAgent,
+	)
+	return i, err
+}
+
+const createAuthSession = `-- name: CreateAuthSession :one
+INSERT INTO auth_sessions (kind, session_key, user_code, provider, device_code, device_name, expires_at, user_id, code_verifier, code_challenge)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING id, kind, session_key, user_code, provider, device_code, device_name, user_id, expires_at, created_at, code_verifier, code_challenge
+`
+
+type CreateAuthSessionParams struct {
+	Kind          string
+	SessionKey    string
+	UserCode      pgtype.Text
+	Provider      pgtype.Text
+	DeviceCode    pgtype.Text
+	DeviceName    pgtype.Text
+	ExpiresAt     pgtype.Timestamptz
+	UserID        pgtype.UUID
+	CodeVerifier  pgtype.Text
+	CodeChallenge pgtype.Text
+}
+
+func (q *Queries) CreateAuthSession(ctx context.Context, arg CreateAuthSessionParams) (AuthSession, error) {
+	row := q.db.QueryRow(ctx, createAuthSession,
+		arg.Kind,
+		arg.SessionKey,
+		arg.UserCode,
+		arg.Provider,
+		arg.DeviceCode,
+		arg.DeviceName,
+		arg.ExpiresAt,
+		arg.UserID,
+		arg.CodeVerifier,
+		arg.CodeChallenge,
+	)
+	var i AuthSession
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.SessionKey,
+		&i.UserCode,
+		&i.Provider,
+		&i.DeviceCode,
+		&i.DeviceName,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.CodeVerifier,
+		&i.CodeChallenge,
+	)
+	return i, err
+}
+
+const createCalendarFeed = `-- name: CreateCalendarFeed :one
+INSERT INTO calendar_feeds (token_hash, workspace_id, user_id)
+VALUES ($1, $2, $3)
+RETURNING id, token_hash, workspace_id, user_id, created_at, last_fetched_at
+`
+
+type CreateCalendarFeedParams struct {
+	TokenHash   string
+	WorkspaceID pgtype.UUID
+	UserID      pgtype.UUID
+}
+
+func (q *Queries) CreateCalendarFeed(ctx context.Context, arg CreateCalendarFeedParams) (CalendarFeed, error) {
+	row := q.db.QueryRow(ctx, createCalendarFeed, arg.TokenHash, arg.WorkspaceID, arg.UserID)
+	var i CalendarFeed
+	err := row.Scan(
+		&i.ID,
+		&i.TokenHash,
+		&i.WorkspaceID,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.LastFetchedAt,
+	)
+	return i, err
+}
+
+const createDevice = `-- name: CreateDevice :one
+INSERT INTO devices (user_id, name)
+VALUES ($1, $2)
+RETURNING id, user_id, name, created_at
+`
+
+type CreateDeviceParams struct {
+	UserID pgtype.UUID
+	Name   string
+}
+
+func (q *Queries) CreateDevice(ctx context.Context, arg CreateDeviceParams) (Device, error) {
+	row := q.db.QueryRow(ctx, createDevice, arg.UserID, arg.Name)
+	var i Device
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createFileVersion = `-- name: CreateFileVersion :one
+INSERT INTO file_versions (file_id, version_number, content_hash)
+SELECT $1, COALESCE(MAX(version_number), 0) + 1, $2
+FROM file_versions
+WHERE file_id = $1
+RETURNING version_number
+`
+
+type CreateFileVersionParams struct {
+	FileID      pgtype.UUID
+	ContentHash string
+}
+
+func (q *Queries) CreateFileVersion(ctx context.Context, arg CreateFileVersionParams) (int32, error) {
+	row := q.db.QueryRow(ctx, createFileVersion, arg.FileID, arg.ContentHash)
+	var version_number int32
+	err := row.Scan(&version_number)
+	return version_number, err
+}
+
+const createNoteTemplate = `-- name: CreateNoteTemplate :one
+INSERT INTO note_templates (workspace_id, name, description, content, created_by)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, workspace_id, name, description, content, created_by, created_at
+`
+
+type CreateNoteTemplateParams struct {
+	WorkspaceID pgtype.UUID
+	Name        string
+	Description string
+	Content     string
+	CreatedBy   pgtype.UUID
+}
+
+func (q *Queries) CreateNoteTemplate(ctx context.Context, arg CreateNoteTemplateParams) (NoteTemplate, error) {
+	row := q.db.QueryRow(ctx, createNoteTemplate,
+		arg.WorkspaceID,
+		arg.Name,
+		arg.Description,
+		arg.Content,
+		arg.CreatedBy,
+	)
+	var i NoteTemplate
+	err := row.Scan(
+		&i.ID,
+		&i.WorkspaceID,
+		&i.Name,
+		&i.Description,
+		&i.Content,
+		&i.CreatedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createNotification = `-- name: CreateNotification :one
+INSERT INTO notifications (user_id, kind, workspace_id, title, body, data)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, user_id, kind, workspace_id, title, body, data, read_at, created_at
+`
+
+type CreateNotificationParams struct {
+	UserID      pgtype.UUID
+	Kind        string
+	WorkspaceID pgtype.UUID
+	Title       string
+	Body        string
+	Data        []byte
+}
+
+func (q *Queries) CreateNotification(ctx context.Context, arg CreateNotificationParams) (Notification, error) {
+	row := q.db.QueryRow(ctx, createNotification,
+		arg.UserID,
+		arg.Kind,
+		arg.WorkspaceID,
+		arg.Title,
+		arg.Body,
+		arg.Data,
+	)
+	var i Notification
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Kind,
+		&i.WorkspaceID,
+		&i.Title,
+		&i.Body,
+		&i.Data,
+		&i.ReadAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createOAuthIdentity = `-- name: CreateOAuthIdentity :one
+INSERT INTO oauth_identities (user_id, provider, provider_user_id, email, last_login_at)
+VALUES ($1, $2, $3, $4, NOW())
+RETURNING id, user_id, provider, provider_user_id, email, created_at, last_login_at
+`
+
+type CreateOAuthIdentityParams struct {
+	UserID         pgtype.UUID
+	Provider       string
+	ProviderUserID string
+	Email          pgtype.Text
+}
+
+func (q *Queries) CreateOAuthIdentity(ctx context.Context, arg CreateOAuthIdentityParams) (OauthIdentity, error) {
+	row := q.db.QueryRow(ctx, createOAuthIdentity,
+		arg.UserID,
+		arg.Provider,
+		arg.ProviderUserID,
+		arg.Email,
+	)
+	var i OauthIdentity
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Provider,
+		&i.ProviderUserID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.LastLoginAt,
+	)
+	return i, err
+}
+
+const createOperation = `-- name: CreateOperation :one
+INSERT INTO operations (kind, user_id, workspace_id, params, steps_total)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, kind, user_id, workspace_id, status, params, state, steps_done, steps_total, result, error, attempts, lease_expires_at, created_at, updated_at, finished_at
+`
+
+type CreateOperationParams struct {
+	Kind        string
+	UserID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	Params      []byte
+	StepsTotal  int32
+}
+
+func (q *Queries) CreateOperation(ctx context.Context, arg CreateOperationParams) (Operation, error) {
+	row := q.db.QueryRow(ctx, createOperation,
+		arg.Kind,
+		arg.UserID,
+		arg.WorkspaceID,
+		arg.Params,
+		arg.StepsTotal,
+	)
+	var i Operation
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.UserID,
+		&i.WorkspaceID,
+		&i.Status,
+		&i.Params,
+		&i.State,
+		&i.StepsDone,
+		&i.StepsTotal,
+		&i.Result,
+		&i.Error,
+		&i.Attempts,
+		&i.LeaseExpiresAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const createOperationUpload = `-- name: CreateOperationUpload :exec
+INSERT INTO operation_uploads (operation_id, content) VALUES ($1, $2)
+`
+
+type CreateOperationUploadParams struct {
+	OperationID pgtype.UUID
+	Content     []byte
+}
+
+func (q *Queries) CreateOperationUpload(ctx context.Context, arg CreateOperationUploadParams) error {
+	_, err := q.db.Exec(ctx, createOperationUpload, arg.OperationID, arg.Content)
+	return err
+}
+
+const createOrganization = `-- name: CreateOrganization :one
+INSERT INTO organizations (name, billing_email, tier, seat_storage_bytes)
+VALUES ($1, $2, $3, $4)
+RETURNING id, name, billing_email, tier, seat_storage_bytes, storage_limit_bytes, created_at, updated_at
+`
+
+type CreateOrganizationParams struct {
+	Name             string
+	BillingEmail     string
+	Tier             UserTier
+	SeatStorageBytes int64
+}
+
+func (q *Queries) CreateOrganization(ctx context.Context, arg CreateOrganizationParams) (Organization, error) {
+	row := q.db.QueryRow(ctx, createOrganization,
+		arg.Name,
+		arg.BillingEmail,
+		arg.Tier,
+		arg.SeatStorageBytes,
+	)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.BillingEmail,
+		&i.Tier,
+		&i.SeatStorageBytes,
+		&i.StorageLimitBytes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createPasskey = `-- name: CreatePasskey :one
+INSERT INTO passkeys (user_id, credential_id, public_key, sign_count, name)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, user_id, credential_id, public_key, sign_count, name, created_at, last_used_at
+`
+
+type CreatePasskeyParams struct {
+	UserID       pgtype.UUID
+	CredentialID []byte
+	PublicKey    []byte
+	SignCount    int64
+	Name         string
+}
+
+func (q *Queries) CreatePasskey(ctx context.Context, arg CreatePasskeyParams) (Passkey, error) {
+	row := q.db.QueryRow(ctx, createPasskey,
+		arg.UserID,
+		arg.CredentialID,
+		arg.PublicKey,
+		arg.SignCount,
+		arg.Name,
+	)
+	var i Passkey
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CredentialID,
+		&i.PublicKey,
+		&i.SignCount,
+		&i.Name,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+	)
+	return i, err
+}
+
+const createPasswordReset = `-- name: CreatePasswordReset :exec
+INSERT INTO password_resets (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE
+SET token_hash = EXCLUDED.token_hash, expires_at = EXCLUDED.expires_at, created_at = NOW()
+`
+
+type CreatePasswordResetParams struct {
+	UserID    pgtype.UUID
+	TokenHash string
+	ExpiresAt pgtype.Timestamptz
+}
+
+func (q *Queries) CreatePasswordReset(ctx context.Context, arg CreatePasswordResetParams) error {
+	_, err := q.db.Exec(ctx, createPasswordReset, arg.UserID, arg.TokenHash, arg.ExpiresAt)
+	return err
+}
+
+const createPolicyRule = `-- name: CreatePolicyRule :one
+INSERT INTO policy_rules (name, detector, pattern, action, created_by)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, name, detector, pattern, action, created_by, created_at
+`
+
+type CreatePolicyRuleParams struct {
+	Name      string
+	Detector  string
+	Pattern   string
+	Action    string
+	CreatedBy pgtype.UUID
+}
+
+func (q *Queries) CreatePolicyRule(ctx context.Context, arg CreatePolicyRuleParams) (PolicyRule, error) {
+	row := q.db.QueryRow(ctx, createPolicyRule,
+		arg.Name,
+		arg.Detector,
+		arg.Pattern,
+		arg.Action,
+		arg.CreatedBy,
+	)
+	var i PolicyRule
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Detector,
+		&i.Pattern,
+		&i.Action,
+		&i.CreatedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createSearchSnapshot = `-- name: CreateSearchSnapshot :one
+INSERT INTO search_snapshots (workspace_id, user_id, query, revision, file_ids, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, workspace_id, user_id, query, revision, file_ids, created_at, expires_at
+`
+
+type CreateSearchSnapshotParams struct {
+	WorkspaceID pgtype.UUID
+	UserID      pgtype.UUID
+	Query       string
+	Revision    int64
+	FileIds     []pgtype.UUID
+	ExpiresAt   pgtype.Timestamptz
+}
+
+func (q *Queries) CreateSearchSnapshot(ctx context.Context, arg CreateSearchSnapshotParams) (SearchSnapshot, error) {
+	row := q.db.QueryRow(ctx, createSearchSnapshot,
+		arg.WorkspaceID,
+		arg.UserID,
+		arg.Query,
+		arg.Revision,
+		arg.FileIds,
+		arg.ExpiresAt,
+	)
+	var i SearchSnapshot
+	err := row.Scan(
+		&i.ID,
+		&i.WorkspaceID,
+		&i.UserID,
+		&i.Query,
+		&i.Revision,
+		&i.FileIds,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const createShareLink = `-- name: CreateShareLink :one
+INSERT INTO share_links (token_hash, file_id, workspace_id, created_by, expires_at, policy_checked_hash)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, token_hash, file_id, workspace_id, created_by, expires_at, revoked_at, created_at, policy_checked_hash, policy_blocked
+`
+
+type CreateShareLinkParams struct {
+	TokenHash         string
+	FileID            pgtype.UUID
+	WorkspaceID       pgtype.UUID
+	CreatedBy         pgtype.UUID
+	ExpiresAt         pgtype.Timestamptz
+	PolicyCheckedHash pgtype.Text
+}
+
+func (q *Queries) CreateShareLink(ctx context.Context, arg CreateShareLinkParams) (ShareLink, error) {
+	row := q.db.QueryRow(ctx, createShareLink,
+		arg.TokenHash,
+		arg.FileID,
+		arg.WorkspaceID,
+		arg.CreatedBy,
+		arg.ExpiresAt,
+		arg.PolicyCheckedHash,
+	)
+	var i ShareLink
+	err := row.Scan(
+		&i.ID,
+		&i.TokenHash,
+		&i.FileID,
+		&i.WorkspaceID,
+		&i.CreatedBy,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.PolicyCheckedHash,
+		&i.PolicyBlocked,
+	)
+	return i, err
+}
+
+const createSyncOperation = `-- name: CreateSyncOperation :one
+INSERT INTO sync_operations (workspace_id, file_id, operation_type, client_id, status, file_path)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, workspace_id, file_id, operation_type, client_id, status, error_message, created_at, file_path
+`
+
+type CreateSyncOperationParams struct {
+	WorkspaceID   pgtype.UUID
+	FileID        pgtype.UUID
+	OperationType string
+	ClientID      pgtype.Text
+	Status        string
+	FilePath      pgtype.Text
+}
+
+func (q *Queries) CreateSyncOperation(ctx context.Context, arg CreateSyncOperationParams) (SyncOperation, error) {
+	row := q.db.QueryRow(ctx, createSyncOperation,
+		arg.WorkspaceID,
+		arg.FileID,
+		arg.OperationType,
+		arg.ClientID,
+		arg.Status,
+		arg.FilePath,
+	)
+	var i SyncOperation
+	err := row.Scan(
+		&i.ID,
+		&i.WorkspaceID,
+		&i.FileID,
+		&i.OperationType,
+		&i.ClientID,
+		&i.Status,
+		&i.ErrorMessage,
+		&i.CreatedAt,
+		&i.FilePath,
+	)
+	return i, err
+}
+
+const createUser = `-- name: CreateUser :one
+INSERT INTO users (email, password_hash, tier)
+VALUES ($1, $2, $3)
+RETURNING id, email, password_hash, tier, storage_used_bytes, created_at, updated_at, timezone, is_admin, storage_limit_bytes, disabled_at, delete_after, email_verified_at
+`
+
+type CreateUserParams struct {
+	Email        string
+	PasswordHash string
+	Tier         UserTier
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, createUser, arg.Email, arg.PasswordHash, arg.Tier)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Tier,
+		&i.StorageUsedBytes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Timezone,
+		&i.IsAdmin,
+		&i.StorageLimitBytes,
+		&i.DisabledAt,
+		&i.DeleteAfter,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const createWorkspace = `-- name: CreateWorkspace :one
+INSERT INTO workspaces (user_id, name, storage_limit_bytes)
+VALUES ($1, $2, $3)
+RETURNING id, user_id, name, storage_limit_bytes, storage_used_bytes, created_at, updated_at, file_count, archived_at, icon, color, description, revision
+`
+
+type CreateWorkspaceParams struct {
+	UserID            pgtype.UUID
+	Name              string
+	StorageLimitBytes int64
+}
+
+func (q *Queries) CreateWorkspace(ctx context.Context, arg CreateWorkspaceParams) (Workspace, error) {
+	row := q.db.QueryRow(ctx, createWorkspace, arg.UserID, arg.Name, arg.StorageLimitBytes)
+	var i Workspace
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.StorageLimitBytes,
+		&i.StorageUsedBytes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FileCount,
+		&i.ArchivedAt,
+		&i.Icon,
+		&i.Color,
+		&i.Description,
+		&i.Revision,
+	)
+	return i, err
+}
+
+const createWorkspaceInvite = `-- name: CreateWorkspaceInvite :one
+INSERT INTO workspace_invites (workspace_id, email, role, token_hash, invited_by, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (workspace_id, email) WHERE accepted_at IS NULL DO UPDATE
+SET role = EXCLUDED.role, token_hash = EXCLUDED.token_hash, invited_by = EXCLUDED.invited_by,
+    expires_at = EXCLUDED.expires_at, created_at = NOW()
+RETURNING id, workspace_id, email, role, token_hash, invited_by, expires_at, accepted_at, accepted_by, created_at
+`
+
+type CreateWorkspaceInviteParams struct {
+	WorkspaceID pgtype.UUID
+	Email       string
+	Role        WorkspaceRole
+	TokenHash   string
+	InvitedBy   pgtype.UUID
+	ExpiresAt   pgtype.Timestamptz
+}
+
+func (q *Queries) CreateWorkspaceInvite(ctx context.Context, arg CreateWorkspaceInviteParams) (WorkspaceInvite, error) {
+	row := q.db.QueryRow(ctx, createWorkspaceInvite,
+		arg.WorkspaceID,
+		arg.Email,
+		arg.Role,
+		arg.TokenHash,
+		arg.InvitedBy,
+		arg.ExpiresAt,
+	)
+	var i WorkspaceInvite
+	err := row.Scan(
+		&i.ID,
+		&i.WorkspaceID,
+		&i.Email,
+		&i.Role,
+		&i.TokenHash,
+		&i.InvitedBy,
+		&i.ExpiresAt,
+		&i.AcceptedAt,
+		&i.AcceptedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createWorkspaceWebhook = `-- name: CreateWorkspaceWebhook :one
+INSERT INTO workspace_webhooks (workspace_id, url, secret, event_types, path_prefix, created_by, last_event_id)
+VALUES ($1, $2, $3, $4, $5, $6,
+        (SELECT COALESCE(MAX(id), 0)::bigint FROM workspace_events WHERE workspace_id = $1))
+RETURNING id, workspace_id, url, secret, event_types, path_prefix, created_by, last_event_id, failure_count, last_error, next_attempt_at, created_at
+`
+
+type CreateWorkspaceWebhookParams struct {
+	WorkspaceID pgtype.UUID
+	Url         string
+	Secret      string
+	EventTypes  []string
+	PathPrefix  string
+	CreatedBy   pgtype.UUID
+}
+
+func (q *Queries) CreateWorkspaceWebhook(ctx context.Context, arg CreateWorkspaceWebhookParams) (WorkspaceWebhook, error) {
+	row := q.db.QueryRow(ctx, createWorkspaceWebhook,
+		arg.WorkspaceID,
+		arg.Url,
+		arg.Secret,
+		arg.EventTypes,
+		arg.PathPrefix,
+		arg.CreatedBy,
+	)
+	var i WorkspaceWebhook
+	err := row.Scan(
+		&i.ID,
+		&i.WorkspaceID,
+		&i.Url,
+		&i.Secret,
+		&i.EventTypes,
+		&i.PathPrefix,
+		&i.CreatedBy,
+		&i.LastEventID,
+		&i.FailureCount,
+		&i.LastError,
+		&i.NextAttemptAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteAPIToken = `-- name: DeleteAPIToken :execrows
+DELETE FROM api_tokens WHERE id = $1 AND user_id = $2
+`
+
+type DeleteAPITokenParams struct {
+	ID     pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) DeleteAPIToken(ctx context.Context, arg DeleteAPITokenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteAPIToken, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteBlob = `-- name: DeleteBlob :exec
+DELETE FROM blobs WHERE content_hash = $1
+`
+
+func (q *Queries) DeleteBlob(ctx context.Context, contentHash string) error {
+	_, err := q.db.Exec(ctx, deleteBlob, contentHash)
+	return err
+}
+
+const deleteBlobRef = `-- name: DeleteBlobRef :exec
+DELETE FROM blob_refs WHERE content_hash = $1
+`
+
+func (q *Queries) DeleteBlobRef(ctx context.Context, contentHash string) error {
+	_, err := q.db.Exec(ctx, deleteBlobRef, contentHash)
+	return err
+}
+
+const deleteCalendarFeed = `-- name: DeleteCalendarFeed :execrows
+DELETE FROM calendar_feeds
+WHERE id = $1 AND user_id = $2 AND workspace_id = $3
+`
+
+type DeleteCalendarFeedParams struct {
+	ID          pgtype.UUID
+	UserID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+}
+
+func (q *Queries) DeleteCalendarFeed(ctx context.Context, arg DeleteCalendarFeedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteCalendarFeed, arg.ID, arg.UserID, arg.WorkspaceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteDevice = `-- name: DeleteDevice :execrows
+DELETE FROM devices WHERE id = $1 AND user_id = $2
+`
+
+type DeleteDeviceParams struct {
+	ID     pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) DeleteDevice(ctx context.Context, arg DeleteDeviceParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteDevice, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteExpiredAuthSessions = `-- name: DeleteExpiredAuthSessions :execrows
+DELETE FROM auth_sessions WHERE expires_at <= NOW()
+`
+
+func (q *Queries) DeleteExpiredAuthSessions(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredAuthSessions)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteExpiredIdempotencyKeys = `-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys WHERE created_at < $1
+`
+
+func (q *Queries) DeleteExpiredIdempotencyKeys(ctx context.Context, createdAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredIdempotencyKeys, createdAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteExpiredSearchSnapshots = `-- name: DeleteExpiredSearchSnapshots :execrows
+DELETE FROM search_snapshots WHERE expires_at <= NOW()
+`
+
+func (q *Queries) DeleteExpiredSearchSnapshots(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredSearchSnapshots)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteFile = `-- name: DeleteFile :exec
+DELETE FROM files WHERE workspace_id = $1 AND file_path = $2
+`
+
+type DeleteFileParams struct {
+	WorkspaceID pgtype.UUID
+	FilePath    string
+}
+
+func (q *Queries) DeleteFile(ctx context.Context, arg DeleteFileParams) error {
+	_, err := q.db.Exec(ctx, deleteFile, arg.WorkspaceID, arg.FilePath)
+	return err
+}
+
+const deleteFileAgendaEntries = `-- name: DeleteFileAgendaEntries :exec
+DELETE FROM agenda_entries WHERE file_id = $1
+`
+
+func (q *Queries) DeleteFileAgendaEntries(ctx context.Context, fileID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteFileAgendaEntries, fileID)
+	return err
+}
+
+const deleteFileLinks = `-- name: DeleteFileLinks :exec
+DELETE FROM note_links WHERE file_id = $1
+`
+
+func (q *Queries) DeleteFileLinks(ctx context.Context, fileID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteFileLinks, fileID)
+	return err
+}
+
+const deleteFileMeta = `-- name: DeleteFileMeta :exec
+DELETE FROM file_meta WHERE file_id = $1
+`
+
+func (q *Queries) DeleteFileMeta(ctx context.Context, fileID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteFileMeta, fileID)
+	return err
+}
+
+const deleteFileNoteID = `-- name: DeleteFileNoteID :exec
+DELETE FROM file_note_ids WHERE file_id = $1
+`
+
+func (q *Queries) DeleteFileNoteID(ctx context.Context, fileID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteFileNoteID, fileID)
+	return err
+}
+
+const deleteFileSearchFailure = `-- name: DeleteFileSearchFailure :exec
+DELETE FROM file_search_failures WHERE file_id = $1
+`
+
+func (q *Queries) DeleteFileSearchFailure(ctx context.Context, fileID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteFileSearchFailure, fileID)
+	return err
+}
+
+const deleteFileTags = `-- name: DeleteFileTags :exec
+DELETE FROM note_tags WHERE file_id = $1
+`
+
+func (q *Queries) DeleteFileTags(ctx context.Context, fileID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteFileTags, fileID)
+	return err
+}
+
+const deleteFileTasksExcept = `-- name: DeleteFileTasksExcept :exec
+DELETE FROM file_tasks
+WHERE file_id = $1 AND NOT (task_key = ANY($2::text[]))
+`
+
+type DeleteFileTasksExceptParams struct {
+	FileID   pgtype.UUID
+	KeepKeys []string
+}
+
+func (q *Queries) DeleteFileTasksExcept(ctx context.Context, arg DeleteFileTasksExceptParams) error {
+	_, err := q.db.Exec(ctx, deleteFileTasksExcept, arg.FileID, arg.KeepKeys)
+	return err
+}
+
+const deleteFilesByPrefix = `-- name: DeleteFilesByPrefix :one
+WITH deleted AS (
+    DELETE FROM files
+    WHERE workspace_id = $1 AND starts_with(file_path, $2)
+    RETURNING size_bytes
+)
+SELECT COUNT(*)::bigint AS file_count, COALESCE(SUM(size_bytes), 0)::bigint AS size_bytes
+FROM deleted
+`
+
+type DeleteFilesByPrefixParams struct {
+	WorkspaceID pgtype.UUID
+	PathPrefix  string
+}
+
+type DeleteFilesByPrefixRow struct {
+	FileCount int64
+	SizeBytes int64
+}
+
+func (q *Queries) DeleteFilesByPrefix(ctx context.Context, arg DeleteFilesByPrefixParams) (DeleteFilesByPrefixRow, error) {
+	row := q.db.QueryRow(ctx, deleteFilesByPrefix, arg.WorkspaceID, arg.PathPrefix)
+	var i DeleteFilesByPrefixRow
+	err := row.Scan(&i.FileCount, &i.SizeBytes)
+	return i, err
+}
+
+const deleteFinishedOperations = `-- name: DeleteFinishedOperations :execrows
+DELETE FROM operations WHERE finished_at < $1
+`
+
+func (q *Queries) DeleteFinishedOperations(ctx context.Context, finishedAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteFinishedOperations, finishedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteGitMirror = `-- name: DeleteGitMirror :execrows
+DELETE FROM workspace_git_mirrors WHERE workspace_id = $1
+`
+
+func (q *Queries) DeleteGitMirror(ctx context.Context, workspaceID pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteGitMirror, workspaceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteIdempotencyKey = `-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2
+`
+
+type DeleteIdempotencyKeyParams struct {
+	UserID         pgtype.UUID
+	IdempotencyKey string
+}
+
+func (q *Queries) DeleteIdempotencyKey(ctx context.Context, arg DeleteIdempotencyKeyParams) error {
+	_, err := q.db.Exec(ctx, deleteIdempotencyKey, arg.UserID, arg.IdempotencyKey)
+	return err
+}
+
+const deleteNoteTemplate = `-- name: DeleteNoteTemplate :execrows
+DELETE FROM note_templates WHERE workspace_id = $1 AND id = $2
+`
+
+type DeleteNoteTemplateParams struct {
+	WorkspaceID pgtype.UUID
+	ID          pgtype.UUID
+}
+
+func (q *Queries) DeleteNoteTemplate(ctx context.Context, arg DeleteNoteTemplateParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteNoteTemplate, arg.WorkspaceID, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteOAuthIdentity = `-- name: DeleteOAuthIdentity :execrows
+DELETE FROM oauth_identities WHERE user_id = $1 AND provider = $2
+`
+
+type DeleteOAuthIdentityParams struct {
+	UserID   pgtype.UUID
+	Provider string
+}
+
+func (q *Queries) DeleteOAuthIdentity(ctx context.Context, arg DeleteOAuthIdentityParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOAuthIdentity, arg.UserID, arg.Provider)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteOperationUpload = `-- name: DeleteOperationUpload :exec
+DELETE FROM operation_uploads WHERE operation_id = $1
+`
+
+func (q *Queries) DeleteOperationUpload(ctx context.Context, operationID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteOperationUpload, operationID)
+	return err
+}
+
+const deleteOrganization = `-- name: DeleteOrganization :execrows
+DELETE FROM organizations WHERE id = $1
+`
+
+func (q *Queries) DeleteOrganization(ctx context.Context, id pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOrganization, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deletePasskey = `-- name: DeletePasskey :execrows
+DELETE FROM passkeys WHERE id = $1 AND user_id = $2
+`
+
+type DeletePasskeyParams struct {
+	ID     pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) DeletePasskey(ctx context.Context, arg DeletePasskeyParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deletePasskey, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deletePolicyRule = `-- name: DeletePolicyRule :execrows
+DELETE FROM policy_rules WHERE id = $1
+`
+
+func (q *Queries) DeletePolicyRule(ctx context.Context, id pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deletePolicyRule, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deletePublishedSite = `-- name: DeletePublishedSite :execrows
+DELETE FROM published_sites WHERE workspace_id = $1
+`
+
+func (q *Queries) DeletePublishedSite(ctx context.Context, workspaceID pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deletePublishedSite, workspaceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteScheduledUser = `-- name: DeleteScheduledUser :execrows
+DELETE FROM users WHERE id = $1 AND delete_after <= NOW()
+`
+
+func (q *Queries) DeleteScheduledUser(ctx context.Context, id pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteScheduledUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteScopedAPIToken = `-- name: DeleteScopedAPIToken :execrows
+DELETE FROM api_tokens WHERE id = $1 AND user_id = $2 AND scopes IS NOT NULL
+`
+
+type DeleteScopedAPITokenParams struct {
+	ID     pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) DeleteScopedAPIToken(ctx context.Context, arg DeleteScopedAPITokenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteScopedAPIToken, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteStaleAPITokens = `-- name: DeleteStaleAPITokens :execrows
+DELETE FROM api_tokens
+WHERE expires_at < $1
+   OR COALESCE(last_used_at, created_at) < $2
+`
+
+type DeleteStaleAPITokensParams struct {
+	ExpiredBefore pgtype.Timestamptz
+	UnusedSince   pgtype.Timestamptz
+}
+
+func (q *Queries) DeleteStaleAPITokens(ctx context.Context, arg DeleteStaleAPITokensParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteStaleAPITokens, arg.ExpiredBefore, arg.UnusedSince)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteStaleAuthFailures = `-- name: DeleteStaleAuthFailures :execrows
+DELETE FROM auth_failures
+WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < NOW())
+`
+
+func (q *Queries) DeleteStaleAuthFailures(ctx context.Context, lastFailedAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteStaleAuthFailures, lastFailedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteStorageAlert = `-- name: DeleteStorageAlert :exec
+DELETE FROM workspace_storage_alerts WHERE workspace_id = $1
+`
+
+func (q *Queries) DeleteStorageAlert(ctx context.Context, workspaceID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteStorageAlert, workspaceID)
+	return err
+}
+
+const deleteSuggestion = `-- name: DeleteSuggestion :exec
+DELETE FROM workspace_suggestions WHERE id = $1
+`
+
+func (q *Queries) DeleteSuggestion(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteSuggestion, id)
+	return err
+}
+
+const deleteUserOAuthIdentities = `-- name: DeleteUserOAuthIdentities :exec
+DELETE FROM oauth_identities WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserOAuthIdentities(ctx context.Context, userID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteUserOAuthIdentities, userID)
+	return err
+}
+
+const deleteUserPasskeys = `-- name: DeleteUserPasskeys :exec
+DELETE FROM passkeys WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserPasskeys(ctx context.Context, userID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteUserPasskeys, userID)
+	return err
+}
+
+const deleteWorkspace = `-- name: DeleteWorkspace :exec
+DELETE FROM workspaces WHERE id = $1
+`
+
+func (q *Queries) DeleteWorkspace(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteWorkspace, id)
+	return err
+}
+
+const deleteWorkspaceInvite = `-- name: DeleteWorkspaceInvite :execrows
+DELETE FROM workspace_invites WHERE workspace_id = $1 AND id = $2 AND accepted_at IS NULL
+`
+
+type DeleteWorkspaceInviteParams struct {
+	WorkspaceID pgtype.UUID
+	ID          pgtype.UUID
+}
+
+func (q *Queries) DeleteWorkspaceInvite(ctx context.Context, arg DeleteWorkspaceInviteParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteWorkspaceInvite, arg.WorkspaceID, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteWorkspaceWebhook = `-- name: DeleteWorkspaceWebhook :execrows
+DELETE FROM workspace_webhooks WHERE id = $1 AND workspace_id = $2
+`
+
+type DeleteWorkspaceWebhookParams struct {
+	ID          pgtype.UUID
+	WorkspaceID pgtype.UUID
+}
+
+func (q *Queries) DeleteWorkspaceWebhook(ctx context.Context, arg DeleteWorkspaceWebhookParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteWorkspaceWebhook, arg.ID, arg.WorkspaceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const dismissSuggestion = `-- name: DismissSuggestion :exec
+UPDATE workspace_suggestions SET dismissed_at = NOW() WHERE id = $1 AND user_id = $2
+`
+
+type DismissSuggestionParams struct {
+	ID     pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) DismissSuggestion(ctx context.Context, arg DismissSuggestionParams) error {
+	_, err := q.db.Exec(ctx, dismissSuggestion, arg.ID, arg.UserID)
+	return err
+}
+
+const findRecreatedNote = `-- name: FindRecreatedNote :one
+SELECT f.id, f.workspace_id, f.file_path, f.content_hash, f.size_bytes, f.mime_type, f.last_modified, f.created_at, f.updated_at FROM files f
+JOIN file_note_ids n ON n.file_id = f.id
+WHERE n.workspace_id = $1
+  AND n.note_id = $2
+  AND f.id <> $3
+  AND f.created_at >= $4
+ORDER BY f.created_at DESC
+LIMIT 1
+`
+
+type FindRecreatedNoteParams struct {
+	WorkspaceID  pgtype.UUID
+	NoteID       string
+	FileID       pgtype.UUID
+	CreatedAfter pgtype.Timestamptz
+}
+
+func (q *Queries) FindRecreatedNote(ctx context.Context, arg FindRecreatedNoteParams) (File, error) {
+	row := q.db.QueryRow(ctx, findRecreatedNote,
+		arg.WorkspaceID,
+		arg.NoteID,
+		arg.FileID,
+		arg.CreatedAfter,
+	)
+	var i File
+	err := row.Scan(
+		&i.ID,
+		&i.WorkspaceID,
+		&i.FilePath,
+		&i.ContentHash,
+		&i.SizeBytes,
+		&i.MimeType,
+		&i.LastModified,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const finishOperation = `-- name: FinishOperation :execrows
+UPDATE operations
+SET status = $2, result = $3, error = $4, lease_expires_at = NULL, finished_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status = 'running' AND attempts = $5
+`
+
+type FinishOperationParams struct {
+	ID       pgtype.UUID
+	Status   string
+	Result   []byte
+	Error    pgtype.Text
+	Attempts int32
+}
+
+func (q *Queries) FinishOperation(ctx context.Context, arg FinishOperationParams) (int64, error) {
+	result, err := q.db.Exec(ctx, finishOperation,
+		arg.ID,
+		arg.Status,
+		arg.Result,
+		arg.Error,
+		arg.Attempts,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const flagInactiveWorkspaces = `-- name: FlagInactiveWorkspaces :execrows
+INSERT INTO workspace_suggestions (workspace_id, user_id, reason, last_activity_at)
+SELECT id, user_id, 'inactive', updated_at
+FROM workspaces
+WHERE archived_at IS NULL AND updated_at < $1
+ON CONFLICT (workspace_id, reason) DO NOTHING
+`
+
+func (q *Queries) FlagInactiveWorkspaces(ctx context.Context, updatedAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, flagInactiveWorkspaces, updatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getAdminUser = `-- name: GetAdminUser :one
+SELECT u.id, u.email, u.tier, u.is_admin, u.storage_limit_bytes, u.disabled_at, u.created_at,
+       COUNT(w.id) AS workspace_count,
+       COALESCE(SUM(w.file_count), 0)::bigint AS file_count,
+       COALESCE(SUM(w.storage_used_bytes), 0)::bigint AS storage_used_bytes
+FROM users u
+LEFT JOIN workspaces w ON w.user_id = u.id
+WHERE u.id = $1
+GROUP BY u.id
+`
+
+type GetAdminUserRow struct {
+	ID                pgtype.UUID
+	Email             string
+	Tier              UserTier
+	IsAdmin           bool
+	StorageLimitBytes pgtype.Int8
+	DisabledAt        pgtype.Timestamptz
+	CreatedAt         pgtype.Timestamptz
+	WorkspaceCount    int64
+	FileCount         int64
+	StorageUsedBytes  int64
+}
+
+func (q *Queries) GetAdminUser(ctx context.Context, id pgtype.UUID) (GetAdminUserRow, error) {
+	row := q.db.QueryRow(ctx, getAdminUser, id)
+	var i GetAdminUserRow
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Tier,
+		&i.IsAdmin,
+		&i.StorageLimitBytes,
+		&i.DisabledAt,
+		&i.CreatedAt,
+		&i.WorkspaceCount,
+		&i.FileCount,
+		&i.StorageUsedBytes,
+	)
+	return i, err
+}
+
+const getAuthLockouts = `-- name: GetAuthLockouts :many
+SELECT key, failures, locked_until, last_failed_at FROM auth_failures
+WHERE key = ANY($1::text[]) AND locked_until > NOW()
+`
+
+func (q *Queries) GetAuthLockouts(ctx context.Context, keys []string) ([]AuthFailure, error) {
+	rows, err := q.db.Query(ctx, getAuthLockouts, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuthFailure
+	for rows.Next() {
+		var i AuthFailure
+		if err := rows.Scan(
+			&i.Key,
+			&i.Failures,
+			&i.LockedUntil,
+			&i.LastFailedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAuthSession = `-- name: GetAuthSession :one
+SELECT id, kind, session_key, user_code, provider, device_code, device_name, user_id, expires_at, created_at, code_verifier, code_challenge FROM auth_sessions
+WHERE kind = $1 AND session_key = $2 AND expires_at > NOW()
+`
+
+type GetAuthSessionParams struct {
+	Kind       string
+	SessionKey string
+}
+
+func (q *Queries) GetAuthSession(ctx context.Context, arg GetAuthSessionParams) (AuthSession, error) {
+	row := q.db.QueryRow(ctx, getAuthSession, arg.Kind, arg.SessionKey)
+	var i AuthSession
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.SessionKey,
+		&i.UserCode,
+		&i.Provider,
+		&i.DeviceCode,
+		&i.DeviceName,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.CodeVerifier,
+		&i.CodeChallenge,
+	)
+	return i, err
+}
+
+const getBlob = `-- name: GetBlob :one
+SELECT content FROM blobs WHERE content_hash = $1
+`
+
+func (q *Queries) GetBlob(ctx context.Context, contentHash string) ([]byte, error) {
+	row := q.db.QueryRow(ctx, getBlob, contentHash)
+	var content []byte
+	err := row.Scan(&content)
+	return content, err
+}
+
+const getBlobRange = `-- name: GetBlobRange :one
+SELECT substring(content FROM $1::int FOR $2::int) AS chunk
+FROM blobs WHERE content_hash = $3
+`
+
+type GetBlobRangeParams struct {
+	Start       int32
+	Length      int32
+	ContentHash string
+}
+
+func (q *Queries) GetBlobRange(ctx context.Context, arg GetBlobRangeParams) ([]byte, error) {
+	row := q.db.QueryRow(ctx, getBlobRange, arg.Start, arg.Length, arg.ContentHash)
+	var chunk []byte
+	err := row.Scan(&chunk)
+	return chunk, err
+}
+
+const getBlobSize = `-- name: GetBlobSize :one
+SELECT octet_length(content)::bigint AS size_bytes FROM blobs WHERE content_hash = $1
+`
+
+func (q *Queries) GetBlobSize(ctx context.Context, contentHash string) (int64, error) {
+	row := q.db.QueryRow(ctx, getBlobSize, contentHash)
+	var size_bytes int64
+	err := row.Scan(&size_bytes)
+	return size_bytes, err
+}
+
+const getCalendarFeedByToken = `-- name: GetCalendarFeedByToken :one
+SELECT id, token_hash, workspace_id, user_id, created_at, last_fetched_at FROM calendar_feeds WHERE token_hash = $1
+`
+
+func (q *Queries) GetCalendarFeedByToken(ctx context.Context, tokenHash string) (CalendarFeed, error) {
+	row := q.db.QueryRow(ctx, getCalendarFeedByToken, tokenHash)
+	var i CalendarFeed
+	err := row.Scan(
+		&i.ID,
+		&i.TokenHash,
+		&i.WorkspaceID,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.LastFetchedAt,
+	)
+	return i, err
+}
+
+const getDailyNoteSettings = `-- name: GetDailyNoteSettings :one
+SELECT workspace_id, path_pattern, template_name, updated_at FROM daily_note_settings WHERE workspace_id = $1
+`
+
+func (q *Queries) GetDailyNoteSettings(ctx context.Context, workspaceID pgtype.UUID) (DailyNoteSetting, error) {
+	row := q.db.QueryRow(ctx, getDailyNoteSettings, workspaceID)
+	var i DailyNoteSetting
+	err := row.Scan(
+		&i.WorkspaceID,
+		&i.PathPattern,
+		&i.TemplateName,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getDeviceSessionByUserCode = `-- name: GetDeviceSessionByUserCode :one
+SELECT id, kind, session_key, user_code, provider, device_code, device_name, user_id, expires_at, created_at, code_verifier, code_challenge FROM auth_sessions
+WHERE kind = 'device' AND user_code = $1 AND expires_at > NOW()
+`
+
+func (q *Queries) GetDeviceSessionByUserCode(ctx context.Context, userCode pgtype.Text) (AuthSession, error) {
+	row := q.db.QueryRow(ctx, getDeviceSessionByUserCode, userCode)
+	var i AuthSession
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.SessionKey,
+		&i.UserCode,
+		&i.Provider,
+		&i.DeviceCode,
+		&i.DeviceName,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.CodeVerifier,
+		&i.CodeChallenge,
+	)
+	return i, err
+}
+
+const getFile = `-- name: GetFile :one
+SELECT id, workspace_id, file_path, content_hash, size_bytes, mime_type, last_modified, created_at, updated_at FROM files WHERE workspace_id = $1 AND file_path = $2
+`
+
+type GetFileParams struct {
+	WorkspaceID pgtype.UUID
+	FilePath    string
+}
+
+func (q *Queries) GetFile(ctx context.Context, arg GetFileParams) (File, error) {
+	row := q.db.QueryRow(ctx, getFile, arg.WorkspaceID, arg.FilePath)
+	var i File
+	err := row.Scan(
+		&i.ID,
+		&i.WorkspaceID,
+		&i.FilePath,
+		&i.ContentHash,
+		&i.SizeBytes,
+		&i.MimeType,
+		&i.LastModified,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getFileByID = `-- name: GetFileByID :one
+SELECT id, workspace_id, file_path, content_hash, size_bytes, mime_type, last_modified, created_at, updated_at FROM files WHERE id = $1
+`
+
+func (q *Queries) GetFileByID(ctx context.Context, id pgtype.UUID) (File, error) {
+	row := q.db.QueryRow(ctx, getFileByID, id)
+	var i File
+	err := row.Scan(
+		&i.ID,
+		&i.WorkspaceID,
+		&i.FilePath,
+		&i.ContentHash,
+		&i.SizeBytes,
+		&i.MimeType,
+		&i.LastModified,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getFileByNoteID = `-- name: GetFileByNoteID :one
+SELECT f.id, f.workspace_id, f.file_path, f.content_hash, f.size_bytes, f.mime_type, f.last_modified, f.created_at, f.updated_at FROM files f
+JOIN file_note_ids n ON n.file_id = f.id
+WHERE n.workspace_id = $1 AND n.note_id = $2
+ORDER BY f.created_at
+LIMIT 1
+`
+
+type GetFileByNoteIDParams struct {
+	WorkspaceID pgtype.UUID
+	NoteID      string
+}
+
+func (q *Queries) GetFileByNoteID(ctx context.Context, arg GetFileByNoteIDParams) (File, error) {
+	row := q.db.QueryRow(ctx, getFileByNoteID, arg.WorkspaceID, arg.NoteID)
+	var i File
+	err := row.Scan(
+		&i.ID,
+		&i.WorkspaceID,
+		&i.FilePath,
+		&i.ContentHash,
+		&i.SizeBytes,
+		&i.MimeType,
+		&i.LastModified,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getFileMeta = `-- name: GetFileMeta :one
+SELECT meta FROM file_meta WHERE file_id = $1
+`
+
+func (q *Queries) GetFileMeta(ctx context.Context, fileID pgtype.UUID) ([]byte, error) {
+	row := q.db.QueryRow(ctx, getFileMeta, fileID)
+	var meta []byte
+	err := row.Scan(&meta)
+	return meta, err
+}
+
+const getFileMetadata = `-- name: GetFileMetadata :one
+SELECT file_id, format, parsed_blocks, properties, word_count, last_parsed, parser_version FROM file_metadata WHERE file_id = $1
+`
+
+func (q *Queries) GetFileMetadata(ctx context.Context, fileID pgtype.UUID) (FileMetadatum, error) {
+	row := q.db.QueryRow(ctx, getFileMetadata, fileID)
+	var i FileMetadatum
+	err := row.Scan(
+		&i.FileID,
+		&i.Format,
+		&i.ParsedBlocks,
+		&i.Properties,
+		&i.WordCount,
+		&i.LastParsed,
+		&i.ParserVersion,
+	)
+	return i, err
+}
+
+const getFileNoteID = `-- name: GetFileNoteID :one
+SELECT note_id FROM file_note_ids WHERE file_id = $1
+`
+
+func (q *Queries) GetFileNoteID(ctx context.Context, fileID pgtype.UUID) (string, error) {
+	row := q.db.QueryRow(ctx, getFileNoteID, fileID)
+	var note_id string
+	err := row.Scan(&note_id)
+	return note_id, err
+}
+
+const getFileVersions = `-- name: GetFileVersions :many
+SELECT id, file_id, version_number, content_hash, created_at FROM file_versions 
+WHERE file_id = $1 
+ORDER BY version_number DESC 
+LIMIT $2
+`
+
+type GetFileVersionsParams struct {
+	FileID pgtype.UUID
+	Limit  int32
+}
+
+func (q *Queries) GetFileVersions(ctx context.Context, arg GetFileVersionsParams) ([]FileVersion, error) {
+	rows, err := q.db.Query(ctx, getFileVersions, arg.FileID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FileVersion
+	for rows.Next() {
+		var i FileVersion
+		if err := rows.Scan(
+			&i.ID,
+			&i.FileID,
+			&i.VersionNumber,
+			&i.ContentHash,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFilesByIDs = `-- name: GetFilesByIDs :many
+SELECT f.id, f.workspace_id, f.file_path, f.content_hash, f.size_bytes, f.mime_type, f.last_modified, f.created_at, f.updated_at FROM unnest($1::uuid[]) WITH ORDINALITY AS r(id, position)
+JOIN files f ON f.id = r.id
+ORDER BY r.position
+`
+
+func (q *Queries) GetFilesByIDs(ctx context.Context, fileIds []pgtype.UUID) ([]File, error) {
+	rows, err := q.db.Query(ctx, getFilesByIDs, fileIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []File
+	for rows.Next() {
+		var i File
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.FilePath,
+			&i.ContentHash,
+			&i.SizeBytes,
+			&i.MimeType,
+			&i.LastModified,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getGitMirror = `-- name: GetGitMirror :one
+SELECT workspace_id, remote_url, branch, pull, private_key, public_key, created_by, last_event_id, last_commit, last_synced_at, sync_requested_at, failure_count, last_error, next_attempt_at, created_at, updated_at FROM workspace_git_mirrors WHERE workspace_id = $1
+`
+
+func (q *Queries) GetGitMirror(ctx context.Context, workspaceID pgtype.UUID) (WorkspaceGitMirror, error) {
+	row := q.db.QueryRow(ctx, getGitMirror, workspaceID)
+	var i WorkspaceGitMirror
+	err := row.Scan(
+		&i.WorkspaceID,
+		&i.RemoteUrl,
+		&i.Branch,
+		&i.Pull,
+		&i.PrivateKey,
+		&i.PublicKey,
+		&i.CreatedBy,
+		&i.LastEventID,
+		&i.LastCommit,
+		&i.LastSyncedAt,
+		&i.SyncRequestedAt,
+		&i.FailureCount,
+		&i.LastError,
+		&i.NextAttemptAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getIdempotencyKey = `-- name: GetIdempotencyKey :one
+SELECT user_id, idempotency_key, fingerprint, status_code, response_headers, response_body, created_at, completed_at FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2
+`
+
+type GetIdempotencyKeyParams struct {
+	UserID         pgtype.UUID
+	IdempotencyKey string
+}
+
+func (q *Queries) GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRow(ctx, getIdempotencyKey, arg.UserID, arg.IdempotencyKey)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.UserID,
+		&i.IdempotencyKey,
+		&i.Fingerprint,
+		&i.StatusCode,
+		&i.ResponseHeaders,
+		&i.ResponseBody,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const getLatestWorkspaceEventID = `-- name: GetLatestWorkspaceEventID :one
+SELECT COALESCE(MAX(id), 0)::bigint FROM workspace_events WHERE workspace_id = $1
+`
+
+func (q *Queries) GetLatestWorkspaceEventID(ctx context.Context, workspaceID pgtype.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, getLatestWorkspaceEventID, workspaceID)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const getMetadataStatus = `-- name: GetMetadataStatus :one
+SELECT COUNT(*)::bigint AS total_files,
+       COUNT(m.file_id) FILTER (WHERE m.parser_version >= $1)::bigint AS parsed_files
+FROM files f
+LEFT JOIN file_metadata m ON m.file_id = f.id
+WHERE f.workspace_id = $2
+`
+
+type GetMetadataStatusParams struct {
+	ParserVersion int32
+	WorkspaceID   pgtype.UUID
+}
+
+type GetMetadataStatusRow struct {
+	TotalFiles  int64
+	ParsedFiles int64
+}
+
+func (q *Queries) GetMetadataStatus(ctx context.Context, arg GetMetadataStatusParams) (GetMetadataStatusRow, error) {
+	row := q.db.QueryRow(ctx, getMetadataStatus, arg.ParserVersion, arg.WorkspaceID)
+	var i GetMetadataStatusRow
+	err := row.Scan(&i.TotalFiles, &i.ParsedFiles)
+	return i, err
+}
+
+const getNoteTemplateByName = `-- name: GetNoteTemplateByName :one
+SELECT id, workspace_id, name, description, content, created_by, created_at FROM note_templates WHERE workspace_id = $1 AND name = $2
+`
+
+type GetNoteTemplateByNameParams struct {
+	WorkspaceID pgtype.UUID
+	Name        string
+}
+
+func (q *Queries) GetNoteTemplateByName(ctx context.Context, arg GetNoteTemplateByNameParams) (NoteTemplate, error) {
+	row := q.db.QueryRow(ctx, getNoteTemplateByName, arg.WorkspaceID, arg.Name)
+	var i NoteTemplate
+	err := row.Scan(
+		&i.ID,
+		&i.WorkspaceID,
+		&i.Name,
+		&i.Description,
+		&i.Content,
+		&i.CreatedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getOAuthIdentity = `-- name: GetOAuthIdentity :one
+SELECT id, user_id, provider, provider_user_id, email, created_at, last_login_at FROM oauth_identities WHERE provider = $1 AND provider_user_id = $2
+`
+
+type GetOAuthIdentityParams struct {
+	Provider       string
+	ProviderUserID string
+}
+
+func (q *Queries) GetOAuthIdentity(ctx context.Context, arg GetOAuthIdentityParams) (OauthIdentity, error) {
+	row := q.db.QueryRow(ctx, getOAuthIdentity, arg.Provider, arg.ProviderUserID)
+	var i OauthIdentity
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Provider,
+		&i.ProviderUserID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.LastLoginAt,
+	)
+	return i, err
+}
+
+const getOperation = `-- name: GetOperation :one
+SELECT id, kind, user_id, workspace_id, status, params, state, steps_done, steps_total, result, error, attempts, lease_expires_at, created_at, updated_at, finished_at FROM operations WHERE id = $1
+`
+
+func (q *Queries) GetOperation(ctx context.Context, id pgtype.UUID) (Operation, error) {
+	row := q.db.QueryRow(ctx, getOperation, id)
+	var i Operation
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.UserID,
+		&i.WorkspaceID,
+		&i.Status,
+		&i.Params,
+		&i.State,
+		&i.StepsDone,
+		&i.StepsTotal,
+		&i.Result,
+		&i.Error,
+		&i.Attempts,
+		&i.LeaseExpiresAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const getOperationUpload = `-- name: GetOperationUpload :one
+SELECT content FROM operation_uploads WHERE operation_id = $1
+`
+
+func (q *Queries) GetOperationUpload(ctx context.Context, operationID pgtype.UUID) ([]byte, error) {
+	row := q.db.QueryRow(ctx, getOperationUpload, operationID)
+	var content []byte
+	err := row.Scan(&content)
+	return content, err
+}
+
+const getOrganization = `-- name: GetOrganization :one
+SELECT o.id, o.name, o.billing_email, o.tier, o.seat_storage_bytes, o.storage_limit_bytes, o.created_at, o.updated_at,
+       (SELECT COUNT(*) FROM organization_members m WHERE m.organization_id = o.id) AS member_count,
+       (SELECT COUNT(*) FROM organization_workspaces ow WHERE ow.organization_id = o.id) AS workspace_count,
+       (SELECT COALESCE(SUM(w.storage_used_bytes), 0)
+        FROM organization_workspaces ow
+        JOIN workspaces w ON w.id = ow.workspace_id
+        WHERE ow.organization_id = o.id)::bigint AS storage_used_bytes
+FROM organizations o
+WHERE o.id = $1
+`
+
+type GetOrganizationRow struct {
+	ID                pgtype.UUID
+	Name              string
+	BillingEmail      string
+	Tier              UserTier
+	SeatStorageBytes  int64
+	StorageLimitBytes pgtype.Int8
+	CreatedAt         pgtype.Timestamptz
+	UpdatedAt         pgtype.Timestamptz
+	MemberCount       int64
+	WorkspaceCount    int64
+	StorageUsedBytes  int64
+}
+
+func (q *Queries) GetOrganization(ctx context.Context, id pgtype.UUID) (GetOrganizationRow, error) {
+	row := q.db.QueryRow(ctx, getOrganization, id)
+	var i GetOrganizationRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.BillingEmail,
+		&i.Tier,
+		&i.SeatStorageBytes,
+		&i.StorageLimitBytes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.MemberCount,
+		&i.WorkspaceCount,
+		&i.StorageUsedBytes,
+	)
+	return i, err
+}
+
+const getOrganizationMember = `-- name: GetOrganizationMember :one
+SELECT organization_id, user_id, role, invited_by, created_at FROM organization_members WHERE organization_id = $1 AND user_id = $2
+`
+
+type GetOrganizationMemberParams struct {
+	OrganizationID pgtype.UUID
+	UserID         pgtype.UUID
+}
+
+func (q *Queries) GetOrganizationMember(ctx context.Context, arg GetOrganizationMemberParams) (OrganizationMember, error) {
+	row := q.db.QueryRow(ctx, getOrganizationMember, arg.OrganizationID, arg.UserID)
+	var i OrganizationMember
+	err := row.Scan(
+		&i.OrganizationID,
+		&i.UserID,
+		&i.Role,
+		&i.InvitedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getPasskeyByCredentialID = `-- name: GetPasskeyByCredentialID :one
+SELECT id, user_id, credential_id, public_key, sign_count, name, created_at, last_used_at FROM passkeys WHERE credential_id = $1
+`
+
+func (q *Queries) GetPasskeyByCredentialID(ctx context.Context, credentialID []byte) (Passkey, error) {
+	row := q.db.QueryRow(ctx, getPasskeyByCredentialID, credentialID)
+	var i Passkey
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CredentialID,
+		&i.PublicKey,
+		&i.SignCount,
+		&i.Name,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+	)
+	return i, err
+}
+
+const getPublishedSite = `-- name: GetPublishedSite :one
+SELECT workspace_id, slug, include_folders, exclude_folders, require_flag, published_by, created_at, updated_at FROM published_sites WHERE workspace_id = $1
+`
+
+func (q *Queries) GetPublishedSite(ctx context.Context, workspaceID pgtype.UUID) (PublishedSite, error) {
+	row := q.db.QueryRow(ctx, getPublishedSite, workspaceID)
+	var i PublishedSite
+	err := row.Scan(
+		&i.WorkspaceID,
+		&i.Slug,
+		&i.IncludeFolders,
+		&i.ExcludeFolders,
+		&i.RequireFlag,
+		&i.PublishedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getPublishedSiteBySlug = `-- name: GetPublishedSiteBySlug :one
+SELECT p.workspace_id, p.include_folders, p.exclude_folders, p.require_flag, p.updated_at,
+       w.name AS workspace_name, w.revision,
+       COALESCE(p.published_by, w.user_id)::uuid AS published_by
+FROM published_sites p
+JOIN workspaces w ON w.id = p.workspace_id
+WHERE p.slug = $1 AND w.archived_at IS NULL
+`
+
+type GetPublishedSiteBySlugRow struct {
+	WorkspaceID    pgtype.UUID
+	IncludeFolders []string
+	ExcludeFolders []string
+	RequireFlag    bool
+	UpdatedAt      pgtype.Timestamptz
+	WorkspaceName  string
+	Revision       int64
+	PublishedBy    pgtype.UUID
+}
+
+func (q *Queries) GetPublishedSiteBySlug(ctx context.Context, slug string) (GetPublishedSiteBySlugRow, error) {
+	row := q.db.QueryRow(ctx, getPublishedSiteBySlug, slug)
+	var i GetPublishedSiteBySlugRow
+	err := row.Scan(
+		&i.WorkspaceID,
+		&i.IncludeFolders,
+		&i.ExcludeFolders,
+		&i.RequireFlag,
+		&i.UpdatedAt,
+		&i.WorkspaceName,
+		&i.Revision,
+		&i.PublishedBy,
+	)
+	return i, err
+}
+
+const getSearchIndexStatus = `-- name: GetSearchIndexStatus :one
+SELECT COUNT(*)::bigint AS total_files,
+       COUNT(s.file_id) FILTER (WHERE s.content_hash = f.content_hash)::bigint AS indexed_files,
+       COUNT(x.file_id) FILTER (WHERE s.file_id IS NULL OR s.content_hash <> f.content_hash)::bigint AS failed_files
+FROM files f
+LEFT JOIN file_search s ON s.file_id = f.id
+LEFT JOIN file_search_failures x ON x.file_id = f.id AND x.content_hash = f.content_hash
+    AND x.attempts >= $1::integer
+WHERE f.workspace_id = $2
+`
+
+type GetSearchIndexStatusParams struct {
+	MaxAttempts int32
+	WorkspaceID pgtype.UUID
+}
+
+type GetSearchIndexStatusRow struct {
+	TotalFiles   int64
+	IndexedFiles int64
+	FailedFiles  int64
+}
+
+func (q *Queries) GetSearchIndexStatus(ctx context.Context, arg GetSearchIndexStatusParams) (GetSearchIndexStatusRow, error) {
+	row := q.db.QueryRow(ctx, getSearchIndexStatus, arg.MaxAttempts, arg.WorkspaceID)
+	var i GetSearchIndexStatusRow
+	err := row.Scan(&i.TotalFiles, &i.IndexedFiles, &i.FailedFiles)
+	return i, err
+}
+
+const getSearchSnapshot = `-- name: GetSearchSnapshot :one
+SELECT id, workspace_id, user_id, query, revision, file_ids, created_at, expires_at FROM search_snapshots
+WHERE id = $1 AND workspace_id = $2 AND user_id = $3 AND expires_at > NOW()
+`
+
+type GetSearchSnapshotParams struct {
+	ID          pgtype.UUID
+	WorkspaceID pgtype.UUID
+	UserID      pgtype.UUID
+}
+
+func (q *Queries) GetSearchSnapshot(ctx context.Context, arg GetSearchSnapshotParams) (SearchSnapshot, error) {
+	row := q.db.QueryRow(ctx, getSearchSnapshot, arg.ID, arg.WorkspaceID, arg.UserID)
+	var i SearchSnapshot
+	err := row.Scan(
+		&i.ID,
+		&i.WorkspaceID,
+		&i.UserID,
+		&i.Query,
+		&i.Revision,
+		&i.FileIds,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const getSharedFile = `-- name: GetSharedFile :one
+SELECT f.file_path, f.content_hash, f.mime_type, f.last_modified, s.expires_at,
+       s.id, s.workspace_id, s.created_by, s.policy_checked_hash, s.policy_blocked
+FROM share_links s
+JOIN files f ON f.id = s.file_id
+WHERE s.token_hash = $1
+  AND s.revoked_at IS NULL
+  AND (s.expires_at IS NULL OR s.expires_at > NOW())
+`
+
+type GetSharedFileRow struct {
+	FilePath          string
+	ContentHash       string
+	MimeType          pgtype.Text
+	LastModified      pgtype.Timestamptz
+	ExpiresAt         pgtype.Timestamptz
+	ID                pgtype.UUID
+	WorkspaceID       pgtype.UUID
+	CreatedBy         pgtype.UUID
+	PolicyCheckedHash pgtype.Text
+	PolicyBlocked     bool
+}
+
+func (q *Queries) GetSharedFile(ctx context.Context, tokenHash string) (GetSharedFileRow, error) {
+	row := q.db.QueryRow(ctx, getSharedFile, tokenHash)
+	var i GetSharedFileRow
+	err := row.Scan(
+		&i.FilePath,
+		&i.ContentHash,
+		&i.MimeType,
+		&i.LastModified,
+		&i.ExpiresAt,
+		&i.ID,
+		&i.WorkspaceID,
+		&i.CreatedBy,
+		&i.PolicyCheckedHash,
+		&i.PolicyBlocked,
+	)
+	return i, err
+}
+
+const getSuggestion = `-- name: GetSuggestion :one
+SELECT id, workspace_id, user_id, reason, last_activity_at, dismissed_at, created_at FROM workspace_suggestions WHERE id = $1 AND user_id = $2
+`
+
+type GetSuggestionParams struct {
+	ID     pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) GetSuggestion(ctx context.Context, arg GetSuggestionParams) (WorkspaceSuggestion, error) {
+	row := q.db.QueryRow(ctx, getSuggestion, arg.ID, arg.UserID)
+	var i WorkspaceSuggestion
+	err := row.Scan(
+		&i.ID,
+		&i.WorkspaceID,
+		&i.UserID,
+		&i.Reason,
+		&i.LastActivityAt,
+		&i.DismissedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getTelemetryCounts = `-- name: GetTelemetryCounts :one
+SELECT
+    (SELECT COUNT(*) FROM users) AS users,
+    (SELECT COUNT(*) FROM workspaces) AS workspaces,
+    (SELECT COUNT(*) FROM workspaces WHERE archived_at IS NOT NULL) AS archived_workspaces,
+    (SELECT COUNT(*) FROM files) AS files,
+    (SELECT COALESCE(SUM(storage_used_bytes), 0) FROM workspaces)::bigint AS storage_used_bytes,
+    (SELECT COUNT(DISTINCT workspace_id) FROM workspace_members WHERE role <> 'owner') AS shared_workspaces,
+    (SELECT COUNT(*) FROM share_links WHERE revoked_at IS NULL) AS share_links,
+    (SELECT COUNT(*) FROM devices) AS devices,
+    (SELECT COUNT(*) FROM workspace_webhooks) AS webhooks,
+    (SELECT COUNT(*) FROM file_tasks) AS tasks,
+    (SELECT COUNT(*) FROM policy_rules) AS policy_rules
+`
+
+type GetTelemetryCountsRow struct {
+	Users              int64
+	Workspaces         int64
+	ArchivedWorkspaces int64
+	Files              int64
+	StorageUsedBytes   int64
+	SharedWorkspaces   int64
+	ShareLinks         int64
+	Devices            int64
+	Webhooks           int64
+	Tasks              int64
+	PolicyRules        int64
+}
+
+func (q *Queries) GetTelemetryCounts(ctx context.Context) (GetTelemetryCountsRow, error) {
+	row := q.db.QueryRow(ctx, getTelemetryCounts)
+	var i GetTelemetryCountsRow
+	err := row.Scan(
+		&i.Users,
+		&i.Workspaces,
+		&i.ArchivedWorkspaces,
+		&i.Files,
+		&i.StorageUsedBytes,
+		&i.SharedWorkspaces,
+		&i.ShareLinks,
+		&i.Devices,
+		&i.Webhooks,
+		&i.Tasks,
+		&i.PolicyRules,
+	)
+	return i, err
+}
+
+const getTokenByHash = `-- name: GetTokenByHash :one
+SELECT t.id, t.user_id, t.token_hash, t.name, t.last_used_at, t.expires_at, t.created_at, t.device_id, t.client_name, t.client_version, t.scopes, t.last_ip, t.user_agent, u.id as user_id, u.email, u.tier, u.is_admin,
+       (u.email_verified_at IS NOT NULL)::boolean AS email_verified,
+       (t.expires_at IS NOT NULL AND t.expires_at <= NOW())::boolean AS expired
+FROM api_tokens t
+JOIN users u ON t.user_id = u.id
+WHERE t.token_hash = $1 AND u.disabled_at IS NULL
+`
+
+type GetTokenByHashRow struct {
+	ID            pgtype.UUID
+	UserID        pgtype.UUID
+	TokenHash     string
+	Name          string
+	LastUsedAt    pgtype.Timestamptz
+	ExpiresAt     pgtype.Timestamptz
+	CreatedAt     pgtype.Timestamptz
+	DeviceID      pgtype.UUID
+	ClientName    pgtype.Text
+	ClientVersion pgtype.Text
+	Scopes        []string
+	LastIp        pgtype.Text
+	UserAgent     pgtype.Text
+	UserID_2      pgtype.UUID
+	Email         string
+	Tier          UserTier
+	IsAdmin       bool
+	EmailVerified bool
+	Expired       bool
+}
+
+// Expired tokens are returned, flagged, so that callers can tell them
+// apart from unknown ones.
+func (q *Queries) GetTokenByHash(ctx context.Context, tokenHash string) (GetTokenByHashRow, error) {
+	row := q.db.QueryRow(ctx, getTokenByHash, tokenHash)
+	var i GetTokenByHashRow
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.Name,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.DeviceID,
+		&i.ClientName,
+		&i.ClientVersion,
+		&i.Scopes,
+		&i.LastIp,
+		&i.UserAgent,
+		&i.UserID_2,
+		&i.Email,
+		&i.Tier,
+		&i.IsAdmin,
+		&i.EmailVerified,
+		&i.Expired,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, email, password_hash, tier, storage_used_bytes, created_at, updated_at, timezone, is_admin, storage_limit_bytes, disabled_at, delete_after, email_verified_at FROM users WHERE email = $1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Tier,
+		&i.StorageUsedBytes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Timezone,
+		&i.IsAdmin,
+		&i.StorageLimitBytes,
+		&i.DisabledAt,
+		&i.DeleteAfter,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, email, password_hash, tier, storage_used_bytes, created_at, updated_at, timezone, is_admin, storage_limit_bytes, disabled_at, delete_after, email_verified_at FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id pgtype.UUID) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Tier,
+		&i.StorageUsedBytes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Timezone,
+		&i.IsAdmin,
+		&i.StorageLimitBytes,
+		&i.DisabledAt,
+		&i.DeleteAfter,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const getUserPreferences = `-- name: GetUserPreferences :one
+SELECT user_id, locale, default_workspace_id, notifications, editor, updated_at FROM user_preferences WHERE user_id = $1
+`
+
+func (q *Queries) GetUserPreferences(ctx context.Context, userID pgtype.UUID) (UserPreference, error) {
+	row := q.db.QueryRow(ctx, getUserPreferences, userID)
+	var i UserPreference
+	err := row.Scan(
+		&i.UserID,
+		&i.Locale,
+		&i.DefaultWorkspaceID,
+		&i.Notifications,
+		&i.Editor,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getWorkspaceByID = `-- name: GetWorkspaceByID :one
+SELECT id, user_id, name, storage_limit_bytes, storage_used_bytes, created_at, updated_at, file_count, archived_at, icon, color, description, revision FROM workspaces WHERE id = $1
+`
+
+func (q *Queries) GetWorkspaceByID(ctx context.Context, id pgtype.UUID) (Workspace, error) {
+	row := q.db.QueryRow(ctx, getWorkspaceByID, id)
+	var i Workspace
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.StorageLimitBytes,
+		&i.StorageUsedBytes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FileCount,
+		&i.ArchivedAt,
+		&i.Icon,
+		&i.Color,
+		&i.Description,
+		&i.Revision,
+	)
+	return i, err
+}
+
+const getWorkspaceInviteByToken = `-- name: GetWorkspaceInviteByToken :one
+SELECT i.id, i.workspace_id, i.email, i.role, i.invited_by, i.expires_at,
+       w.name AS workspace_name, u.email AS invited_by_email
+FROM workspace_invites i
+JOIN workspaces w ON w.id = i.workspace_id
+JOIN users u ON u.id = i.invited_by
+WHERE i.token_hash = $1 AND i.accepted_at IS NULL AND i.expires_at > NOW()
+`
+
+type GetWorkspaceInviteByTokenRow struct {
+	ID             pgtype.UUID
+	WorkspaceID    pgtype.UUID
+	Email          string
+	Role           WorkspaceRole
+	InvitedBy      pgtype.UUID
+	ExpiresAt      pgtype.Timestamptz
+	WorkspaceName  string
+	InvitedByEmail string
+}
+
+func (q *Queries) GetWorkspaceInviteByToken(ctx context.Context, tokenHash string) (GetWorkspaceInviteByTokenRow, error) {
+	row := q.db.QueryRow(ctx, getWorkspaceInviteByToken, tokenHash)
+	var i GetWorkspaceInviteByTokenRow
+	err := row.Scan(
+		&i.ID,
+		&i.WorkspaceID,
+		&i.Email,
+		&i.Role,
+		&i.InvitedBy,
+		&i.ExpiresAt,
+		&i.WorkspaceName,
+		&i.InvitedByEmail,
+	)
+	return i, err
+}
+
+const getWorkspaceMember = `-- name: GetWorkspaceMember :one
+SELECT workspace_id, user_id, role, invited_by, created_at, updated_at FROM workspace_members WHERE workspace_id = $1 AND user_id = $2
+`
+
+type GetWorkspaceMemberParams struct {
+	WorkspaceID pgtype.UUID
+	UserID      pgtype.UUID
+}
+
+func (q *Queries) GetWorkspaceMember(ctx context.Context, arg GetWorkspaceMemberParams) (WorkspaceMember, error) {
+	row := q.db.QueryRow(ctx, getWorkspaceMember, arg.WorkspaceID, arg.UserID)
+	var i WorkspaceMember
+	err := row.Scan(
+		&i.WorkspaceID,
+		&i.UserID,
+		&i.Role,
+		&i.InvitedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getWorkspaceSortOrder = `-- name: GetWorkspaceSortOrder :one
+SELECT COALESCE((
+    SELECT sort_order FROM workspace_sort_orders WHERE user_id = $1 AND workspace_id = $2
+), 0)::INTEGER
+`
+
+type GetWorkspaceSortOrderParams struct {
+	UserID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+}
+
+func (q *Queries) GetWorkspaceSortOrder(ctx context.Context, arg GetWorkspaceSortOrderParams) (int32, error) {
+	row := q.db.QueryRow(ctx, getWorkspaceSortOrder, arg.UserID, arg.WorkspaceID)
+	var column_1 int32
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const getWorkspaceStorageUsage = `-- name: GetWorkspaceStorageUsage :one
+SELECT ow.organization_id,
+       CASE WHEN ow.organization_id IS NULL THEN w.storage_limit_bytes
+            ELSE COALESCE(o.storage_limit_bytes, o.seat_storage_bytes *
+                (SELECT COUNT(*) FROM organization_members m WHERE m.organization_id = o.id))
+       END::bigint AS storage_limit_bytes,
+       CASE WHEN ow.organization_id IS NULL THEN COALESCE(w.storage_used_bytes, 0)
+            ELSE (SELECT COALESCE(SUM(pw.storage_used_bytes), 0)
+                  FROM organization_workspaces pow
+                  JOIN workspaces pw ON pw.id = pow.workspace_id
+                  WHERE pow.organization_id = o.id)
+       END::bigint AS storage_used_bytes,
+       w.file_count,
+       COALESCE(o.tier, u.tier)::user_tier AS tier
+FROM workspaces w
+JOIN users u ON u.id = w.user_id
+LEFT JOIN organization_workspaces ow ON ow.workspace_id = w.id
+LEFT JOIN organizations o ON o.id = ow.organization_id
+WHERE w.id = $1
+`
+
+type GetWorkspaceStorageUsageRow struct {
+	OrganizationID    pgtype.UUID
+	StorageLimitBytes int64
+	StorageUsedBytes  int64
+	FileCount         int64
+	Tier              UserTier
+}
+
+// The workspaces of an organization share its storage pool, so for them
+// the limit, usage and tier are the organization's.
+func (q *Queries) GetWorkspaceStorageUsage(ctx context.Context, id pgtype.UUID) (GetWorkspaceStorageUsageRow, error) {
+	row := q.db.QueryRow(ctx, getWorkspaceStorageUsage, id)
+	var i GetWorkspaceStorageUsageRow
+	err := row.Scan(
+		&i.OrganizationID,
+		&i.StorageLimitBytes,
+		&i.StorageUsedBytes,
+		&i.FileCount,
+		&i.Tier,
+	)
+	return i, err
+}
+
+const getWorkspacesByUser = `-- name: GetWorkspacesByUser :many
+SELECT id, user_id, name, storage_limit_bytes, storage_used_bytes, created_at, updated_at, file_count, archived_at, icon, color, description, revision FROM workspaces WHERE user_id = $1 ORDER BY created_at DESC
+`
+
+func (q *Queries) GetWorkspacesByUser(ctx context.Context, userID pgtype.UUID) ([]Workspace, error) {
+	rows, err := q.db.Query(ctx, getWorkspacesByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Workspace
+	for rows.Next() {
+		var i Workspace
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Name,
+			&i.StorageLimitBytes,
+			&i.StorageUsedBytes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FileCount,
+			&i.ArchivedAt,
+			&i.Icon,
+			&i.Color,
+			&i.Description,
+			&i.Revision,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertAgendaEntry = `-- name: InsertAgendaEntry :exec
+INSERT INTO agenda_entries (file_id, workspace_id, line, kind, day, time_of_day, title, keyword, done)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT DO NOTHING
+`
+
+type InsertAgendaEntryParams struct {
+	FileID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	Line        int32
+	Kind        string
+	Day         pgtype.Date
+	TimeOfDay   string
+	Title       string
+	Keyword     string
+	Done        bool
+}
+
+func (q *Queries) InsertAgendaEntry(ctx context.Context, arg InsertAgendaEntryParams) error {
+	_, err := q.db.Exec(ctx, insertAgendaEntry,
+		arg.FileID,
+		arg.WorkspaceID,
+		arg.Line,
+		arg.Kind,
+		arg.Day,
+		arg.TimeOfDay,
+		arg.Title,
+		arg.Keyword,
+		arg.Done,
+	)
+	return err
+}
+
+const insertNoteLink = `-- name: InsertNoteLink :exec
+INSERT INTO note_links (file_id, workspace_id, line, target, candidates)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT DO NOTHING
+`
+
+type InsertNoteLinkParams struct {
+	FileID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	Line        int32
+	Target      string
+	Candidates  []string
+}
+
+func (q *Queries) InsertNoteLink(ctx context.Context, arg InsertNoteLinkParams) error {
+	_, err := q.db.Exec(ctx, insertNoteLink,
+		arg.FileID,
+		arg.WorkspaceID,
+		arg.Line,
+		arg.Target,
+		arg.Candidates,
+	)
+	return err
+}
+
+const insertNoteTag = `-- name: InsertNoteTag :exec
+INSERT INTO note_tags (file_id, workspace_id, tag)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type InsertNoteTagParams struct {
+	FileID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	Tag         string
+}
+
+func (q *Queries) InsertNoteTag(ctx context.Context, arg InsertNoteTagParams) error {
+	_, err := q.db.Exec(ctx, insertNoteTag, arg.FileID, arg.WorkspaceID, arg.Tag)
+	return err
+}
+
+const insertPolicyViolation = `-- name: InsertPolicyViolation :exec
+INSERT INTO policy_violations (rule_id, rule_name, workspace_id, user_id, file_path, action_type, blocked, match_count)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+`
+
+type InsertPolicyViolationParams struct {
+	RuleID      pgtype.UUID
+	RuleName    string
+	WorkspaceID pgtype.UUID
+	UserID      pgtype.UUID
+	FilePath    string
+	ActionType  string
+	Blocked     bool
+	MatchCount  int32
+}
+
+func (q *Queries) InsertPolicyViolation(ctx context.Context, arg InsertPolicyViolationParams) error {
+	_, err := q.db.Exec(ctx, insertPolicyViolation,
+		arg.RuleID,
+		arg.RuleName,
+		arg.WorkspaceID,
+		arg.UserID,
+		arg.FilePath,
+		arg.ActionType,
+		arg.Blocked,
+		arg.MatchCount,
+	)
+	return err
+}
+
+const insertWorkspaceEvent = `-- name: InsertWorkspaceEvent :one
+INSERT INTO workspace_events (workspace_id, event_type, file_path, actor_id, payload)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id
+`
+
+type InsertWorkspaceEventParams struct {
+	WorkspaceID pgtype.UUID
+	EventType   string
+	FilePath    pgtype.Text
+	ActorID     pgtype.UUID
+	Payload     []byte
+}
+
+func (q *Queries) InsertWorkspaceEvent(ctx context.Context, arg InsertWorkspaceEventParams) (int64, error) {
+	row := q.db.QueryRow(ctx, insertWorkspaceEvent,
+		arg.WorkspaceID,
+		arg.EventType,
+		arg.FilePath,
+		arg.ActorID,
+		arg.Payload,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const listAdminUsers = `-- name: ListAdminUsers :many
+SELECT u.id, u.email, u.tier, u.is_admin, u.storage_limit_bytes, u.disabled_at, u.created_at,
+       COUNT(w.id) AS workspace_count,
+       COALESCE(SUM(w.file_count), 0)::bigint AS file_count,
+       COALESCE(SUM(w.storage_used_bytes), 0)::bigint AS storage_used_bytes
+FROM users u
+LEFT JOIN workspaces w ON w.user_id = u.id
+WHERE u.email > $1
+  AND starts_with(u.email, $2)
+GROUP BY u.id
+ORDER BY u.email
+LIMIT $3
+`
+
+type ListAdminUsersParams struct {
+	AfterEmail  string
+	EmailPrefix string
+	PageSize    int32
+}
+
+type ListAdminUsersRow struct {
+	ID                pgtype.UUID
+	Email             string
+	Tier              UserTier
+	IsAdmin           bool
+	StorageLimitBytes pgtype.Int8
+	DisabledAt        pgtype.Timestamptz
+	CreatedAt         pgtype.Timestamptz
+	WorkspaceCount    int64
+	FileCount         int64
+	StorageUsedBytes  int64
+}
+
+func (q *Queries) ListAdminUsers(ctx context.Context, arg ListAdminUsersParams) ([]ListAdminUsersRow, error) {
+	rows, err := q.db.Query(ctx, listAdminUsers, arg.AfterEmail, arg.EmailPrefix, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAdminUsersRow
+	for rows.Next() {
+		var i ListAdminUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.Tier,
+			&i.IsAdmin,
+			&i.StorageLimitBytes,
+			&i.DisabledAt,
+			&i.CreatedAt,
+			&i.WorkspaceCount,
+			&i.FileCount,
+			&i.StorageUsedBytes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAgendaEntries = `-- name: ListAgendaEntries :many
+SELECT a.kind, a.day, a.time_of_day, a.title, a.keyword, a.done, a.line, f.file_path
+FROM agenda_entries a
+JOIN files f ON f.id = a.file_id
+WHERE a.workspace_id = $1
+  AND a.day BETWEEN $2 AND $3
+ORDER BY a.day, a.time_of_day, f.file_path, a.line
+LIMIT $4
+`
+
+type ListAgendaEntriesParams struct {
+	WorkspaceID pgtype.UUID
+	FromDay     pgtype.Date
+	ToDay       pgtype.Date
+	MaxEntries  int32
+}
+
+type ListAgendaEntriesRow struct {
+	Kind      string
+	Day       pgtype.Date
+	TimeOfDay string
+	Title     string
+	Keyword   string
+	Done      bool
+	Line      int32
+	FilePath  string
+}
+
+func (q *Queries) ListAgendaEntries(ctx context.Context, arg ListAgendaEntriesParams) ([]ListAgendaEntriesRow, error) {
+	rows, err := q.db.Query(ctx, listAgendaEntries,
+		arg.WorkspaceID,
+		arg.FromDay,
+		arg.ToDay,
+		arg.MaxEntries,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAgendaEntriesRow
+	for rows.Next() {
+		var i ListAgendaEntriesRow
+		if err := rows.Scan(
+			&i.Kind,
+			&i.Day,
+			&i.TimeOfDay,
+			&i.Title,
+			&i.Keyword,
+			&i.Done,
+			&i.Line,
+			&i.FilePath,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllContentHashes = `-- name: ListAllContentHashes :many
+SELECT content_hash FROM blob_refs WHERE ref_count > 0
+`
+
+func (q *Queries) ListAllContentHashes(ctx context.Context) ([]string, error) {
+	rows, err := q.db.Query(ctx, listAllContentHashes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var content_hash string
+		if err := rows.Scan(&content_hash); err != nil {
+			return nil, err
+		}
+		items = append(items, content_hash)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllWorkspaces = `-- name: ListAllWorkspaces :many
+SELECT id, user_id, name, storage_limit_bytes, storage_used_bytes, created_at, updated_at, file_count, archived_at, icon, color, description, revision FROM workspaces ORDER BY created_at
+`
+
+func (q *Queries) ListAllWorkspaces(ctx context.Context) ([]Workspace, error) {
+	rows, err := q.db.Query(ctx, listAllWorkspaces)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Workspace
+	for rows.Next() {
+		var i Workspace
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Name,
+			&i.StorageLimitBytes,
+			&i.StorageUsedBytes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FileCount,
+			&i.ArchivedAt,
+			&i.Icon,
+			&i.Color,
+			&i.Description,
+			&i.Revision,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBrokenLinks = `-- name: ListBrokenLinks :many
+SELECT f.file_path, l.line, l.target
+FROM note_links l
+JOIN files f ON f.id = l.file_id
+WHERE l.workspace_id = $1
+  AND NOT EXISTS (
+    SELECT 1 FROM files t
+    WHERE t.workspace_id = l.workspace_id AND t.file_path = ANY(l.candidates)
+  )
+ORDER BY f.file_path, l.line, l.target
+LIMIT $2
+`
+
+type ListBrokenLinksParams struct {
+	WorkspaceID pgtype.UUID
+	MaxLinks    int32
+}
+
+type ListBrokenLinksRow struct {
+	FilePath string
+	Line     int32
+	Target   string
+}
+
+func (q *Queries) ListBrokenLinks(ctx context.Context, arg ListBrokenLinksParams) ([]ListBrokenLinksRow, error) {
+	rows, err := q.db.Query(ctx, listBrokenLinks, arg.WorkspaceID, arg.MaxLinks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBrokenLinksRow
+	for rows.Next() {
+		var i ListBrokenLinksRow
+		if err := rows.Scan(&i.FilePath, &i.Line, &i.Target); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCalendarFeeds = `-- name: ListCalendarFeeds :many
+SELECT id, token_hash, workspace_id, user_id, created_at, last_fetched_at FROM calendar_feeds
+WHERE user_id = $1 AND workspace_id = $2
+ORDER BY created_at
+`
+
+type ListCalendarFeedsParams struct {
+	UserID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+}
+
+func (q *Queries) ListCalendarFeeds(ctx context.Context, arg ListCalendarFeedsParams) ([]CalendarFeed, error) {
+	rows, err := q.db.Query(ctx, listCalendarFeeds, arg.UserID, arg.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CalendarFeed
+	for rows.Next() {
+		var i CalendarFeed
+		if err := rows.Scan(
+			&i.ID,
+			&i.TokenHash,
+			&i.WorkspaceID,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.LastFetchedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDevices = `-- name: ListDevices :many
+SELECT d.id, d.name, d.created_at,
+       MAX(t.last_used_at)::timestamptz AS last_seen_at,
+       COUNT(t.id) AS token_count,
+       (ARRAY_AGG(t.client_name ORDER BY t.last_used_at DESC NULLS LAST) FILTER (WHERE t.client_name IS NOT NULL))[1]::text AS client_name,
+       (ARRAY_AGG(t.client_version ORDER BY t.last_used_at DESC NULLS LAST) FILTER (WHERE t.client_version IS NOT NULL))[1]::text AS client_version
+FROM devices d
+LEFT JOIN api_tokens t ON t.device_id = d.id
+WHERE d.user_id = $1
+GROUP BY d.id
+ORDER BY d.created_at DESC
+`
+
+type ListDevicesRow struct {
+	ID            pgtype.UUID
+	Name          string
+	CreatedAt     pgtype.Timestamptz
+	LastSeenAt    pgtype.Timestamptz
+	TokenCount    int64
+	ClientName    pgtype.Text
+	ClientVersion pgtype.Text
+}
+
+func (q *Queries) ListDevices(ctx context.Context, userID pgtype.UUID) ([]ListDevicesRow, error) {
+	rows, err := q.db.Query(ctx, listDevices, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListDevicesRow
+	for rows.Next() {
+		var i ListDevicesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.LastSeenAt,
+			&i.TokenCount,
+			&i.ClientName,
+			&i.ClientVersion,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFileLinks = `-- name: ListFileLinks :many
+SELECT line, target, candidates FROM note_links
+WHERE file_id = $1
+ORDER BY line, target
+`
+
+type ListFileLinksRow struct {
+	Line       int32
+	Target     string
+	Candidates []string
+}
+
+func (q *Queries) ListFileLinks(ctx context.Context, fileID pgtype.UUID) ([]ListFileLinksRow, error) {
+	rows, err := q.db.Query(ctx, listFileLinks, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFileLinksRow
+	for rows.Next() {
+		var i ListFileLinksRow
+		if err := rows.Scan(&i.Line, &i.Target, &i.Candidates); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFileManifest = `-- name: ListFileManifest :many
+SELECT file_path, content_hash, size_bytes, last_modified, mime_type
+FROM files
+WHERE workspace_id = $1
+  AND file_path >= $2
+  AND starts_with(file_path, $2)
+ORDER BY file_path
+`
+
+type ListFileManifestParams struct {
+	WorkspaceID pgtype.UUID
+	PathPrefix  string
+}
+
+type ListFileManifestRow struct {
+	FilePath     string
+	ContentHash  string
+	SizeBytes    int64
+	LastModified pgtype.Timestamptz
+	MimeType     pgtype.Text
+}
+
+func (q *Queries) ListFileManifest(ctx context.Context, arg ListFileManifestParams) ([]ListFileManifestRow, error) {
+	rows, err := q.db.Query(ctx, listFileManifest, arg.WorkspaceID, arg.PathPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFileManifestRow
+	for rows.Next() {
+		var i ListFileManifestRow
+		if err := rows.Scan(
+			&i.FilePath,
+			&i.ContentHash,
+			&i.SizeBytes,
+			&i.LastModified,
+			&i.MimeType,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFileTags = `-- name: ListFileTags :many
+SELECT tag FROM note_tags WHERE file_id = $1 ORDER BY tag
+`
+
+func (q *Queries) ListFileTags(ctx context.Context, fileID pgtype.UUID) ([]string, error) {
+	rows, err := q.db.Query(ctx, listFileTags, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		items = append(items, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFileTasks = `-- name: ListFileTasks :many
+SELECT file_id, task_key, workspace_id, title, done, deadline, reminded_at FROM file_tasks WHERE file_id = $1
+`
+
+func (q *Queries) ListFileTasks(ctx context.Context, fileID pgtype.UUID) ([]FileTask, error) {
+	rows, err := q.db.Query(ctx, listFileTasks, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FileTask
+	for rows.Next() {
+		var i FileTask
+		if err := rows.Scan(
+			&i.FileID,
+			&i.TaskKey,
+			&i.WorkspaceID,
+			&i.Title,
+			&i.Done,
+			&i.Deadline,
+			&i.RemindedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFiles = `-- name: ListFiles :many
+SELECT id, workspace_id, file_path, content_hash, size_bytes, mime_type, last_modified, updated_at
+FROM files 
+WHERE workspace_id = $1 
+ORDER BY file_path
+`
+
+type ListFilesRow struct {
+	ID           pgtype.UUID
+	WorkspaceID  pgtype.UUID
+	FilePath     string
+	ContentHash  string
+	SizeBytes    int64
+	MimeType     pgtype.Text
+	LastModified pgtype.Timestamptz
+	UpdatedAt    pgtype.Timestamptz
+}
+
+func (q *Queries) ListFiles(ctx context.Context, workspaceID pgtype.UUID) ([]ListFilesRow, error) {
+	rows, err := q.db.Query(ctx, listFiles, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFilesRow
+	for rows.Next() {
+		var i ListFilesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.FilePath,
+			&i.ContentHash,
+			&i.SizeBytes,
+			&i.MimeType,
+			&i.LastModified,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFilesBySize = `-- name: ListFilesBySize :many
+SELECT id, workspace_id, file_path, content_hash, size_bytes, mime_type, last_modified, updated_at
+FROM files
+WHERE workspace_id = $1
+  AND (size_bytes, file_path) < ($2::bigint, $3::text)
+  AND starts_with(file_path, $4)
+  AND (cardinality($5::text[]) = 0 OR mime_type LIKE ANY($5::text[]))
+ORDER BY size_bytes DESC, file_path DESC
+LIMIT $6
+`
+
+type ListFilesBySizeParams struct {
+	WorkspaceID  pgtype.UUID
+	AfterSize    int64
+	AfterPath    string
+	PathPrefix   string
+	MimePatterns []string
+	PageSize     int32
+}
+
+type ListFilesBySizeRow struct {
+	ID           pgtype.UUID
+	WorkspaceID  pgtype.UUID
+	FilePath     string
+	ContentHash  string
+	SizeBytes    int64
+	MimeType     pgtype.Text
+	LastModified pgtype.Timestamptz
+	UpdatedAt    pgtype.Timestamptz
+}
+
+func (q *Queries) ListFilesBySize(ctx context.Context, arg ListFilesBySizeParams) ([]ListFilesBySizeRow, error) {
+	rows, err := q.db.Query(ctx, listFilesBySize, arg.WorkspaceID, arg.AfterSize, arg.AfterPath, arg.PathPrefix, arg.MimePatterns, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFilesBySizeRow
+	for rows.Next() {
+		var i ListFilesBySizeRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.FilePath,
+			&i.ContentHash,
+			&i.SizeBytes,
+			&i.MimeType,
+			&i.LastModified,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFilesByUpdatedAt = `-- name: ListFilesByUpdatedAt :many
+SELECT id, workspace_id, file_path, content_hash, size_bytes, mime_type, last_modified, updated_at
+FROM files
+WHERE workspace_id = $1
+  AND (updated_at, file_path) < ($2::timestamptz, $3::text)
+  AND starts_with(file_path, $4)
+  AND (cardinality($5::text[]) = 0 OR mime_type LIKE ANY($5::text[]))
+ORDER BY updated_at DESC, file_path DESC
+LIMIT $6
+`
+
+type ListFilesByUpdatedAtParams struct {
+	WorkspaceID    pgtype.UUID
+	AfterUpdatedAt pgtype.Timestamptz
+	AfterPath      string
+	PathPrefix     string
+	MimePatterns   []string
+	PageSize       int32
+}
+
+type ListFilesByUpdatedAtRow struct {
+	ID           pgtype.UUID
+	WorkspaceID  pgtype.UUID
+	FilePath     string
+	ContentHash  string
+	SizeBytes    int64
+	MimeType     pgtype.Text
+	LastModified pgtype.Timestamptz
+	UpdatedAt    pgtype.Timestamptz
+}
+
+func (q *Queries) ListFilesByUpdatedAt(ctx context.Context, arg ListFilesByUpdatedAtParams) ([]ListFilesByUpdatedAtRow, error) {
+	rows, err := q.db.Query(ctx, listFilesByUpdatedAt, arg.WorkspaceID, arg.AfterUpdatedAt, arg.AfterPath, arg.PathPrefix, arg.MimePatterns, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFilesByUpdatedAtRow
+	for rows.Next() {
+		var i ListFilesByUpdatedAtRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.FilePath,
+			&i.ContentHash,
+			&i.SizeBytes,
+			&i.MimeType,
+			&i.LastModified,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFilesPage = `-- name: ListFilesPage :many
+SELECT id, workspace_id, file_path, content_hash, size_bytes, mime_type, last_modified, updated_at
+FROM files
+WHERE workspace_id = $1
+  AND file_path > $2
+  AND file_path >= $3
+  AND starts_with(file_path, $3)
+  AND (cardinality($4::text[]) = 0 OR mime_type LIKE ANY($4::text[]))
+ORDER BY file_path
+LIMIT $5
+`
+
+type ListFilesPageParams struct {
+	WorkspaceID  pgtype.UUID
+	AfterPath    string
+	PathPrefix   string
+	MimePatterns []string
+	PageSize     int32
+}
+
+type ListFilesPageRow struct {
+	ID           pgtype.UUID
+	WorkspaceID  pgtype.UUID
+	FilePath     string
+	ContentHash  string
+	SizeBytes    int64
+	MimeType     pgtype.Text
+	LastModified pgtype.Timestamptz
+	UpdatedAt    pgtype.Timestamptz
+}
+
+func (q *Queries) ListFilesPage(ctx context.Context, arg ListFilesPageParams) ([]ListFilesPageRow, error) {
+	rows, err := q.db.Query(ctx, listFilesPage, arg.WorkspaceID, arg.AfterPath, arg.PathPrefix, arg.MimePatterns, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFilesPageRow
+	for rows.Next() {
+		var i ListFilesPageRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.FilePath,
+			&i.ContentHash,
+			&i.SizeBytes,
+			&i.MimeType,
+			&i.LastModified,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFilesSharingTags = `-- name: ListFilesSharingTags :many
+SELECT f.file_path, array_agg(t.tag ORDER BY t.tag)::text[] AS tags
+FROM note_tags t
+JOIN files f ON f.id = t.file_id
+WHERE t.workspace_id = $1
+  AND t.file_id <> $2
+  AND t.tag = ANY($3::text[])
+GROUP BY f.file_path
+ORDER BY COUNT(*) DESC, f.file_path
+LIMIT $4
+`
+
+type ListFilesSharingTagsParams struct {
+	WorkspaceID pgtype.UUID
+	FileID      pgtype.UUID
+	Tags        []string
+	MaxFiles    int32
+}
+
+type ListFilesSharingTagsRow struct {
+	FilePath string
+	Tags     []string
+}
+
+func (q *Queries) ListFilesSharingTags(ctx context.Context, arg ListFilesSharingTagsParams) ([]ListFilesSharingTagsRow, error) {
+	rows, err := q.db.Query(ctx, listFilesSharingTags,
+		arg.WorkspaceID,
+		arg.FileID,
+		arg.Tags,
+		arg.MaxFiles,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFilesSharingTagsRow
+	for rows.Next() {
+		var i ListFilesSharingTagsRow
+		if err := rows.Scan(&i.FilePath, &i.Tags); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLargestFiles = `-- name: ListLargestFiles :many
+SELECT f.file_path, f.size_bytes, m.word_count
+FROM files f
+LEFT JOIN file_metadata m ON m.file_id = f.id
+WHERE f.workspace_id = $1
+ORDER BY f.size_bytes DESC, f.file_path
+LIMIT $2
+`
+
+type ListLargestFilesParams struct {
+	WorkspaceID pgtype.UUID
+	MaxFiles    int32
+}
+
+type ListLargestFilesRow struct {
+	FilePath  string
+	SizeBytes int64
+	WordCount pgtype.Int4
+}
+
+func (q *Queries) ListLargestFiles(ctx context.Context, arg ListLargestFilesParams) ([]ListLargestFilesRow, error) {
+	rows, err := q.db.Query(ctx, listLargestFiles, arg.WorkspaceID, arg.MaxFiles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListLargestFilesRow
+	for rows.Next() {
+		var i ListLargestFilesRow
+		if err := rows.Scan(&i.FilePath, &i.SizeBytes, &i.WordCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLinksToPaths = `-- name: ListLinksToPaths :many
+SELECT f.file_path, l.line, l.target, l.candidates
+FROM note_links l
+JOIN files f ON f.id = l.file_id
+WHERE l.workspace_id = $1
+  AND l.candidates && $2::text[]
+ORDER BY f.file_path, l.line
+LIMIT $3
+`
+
+type ListLinksToPathsParams struct {
+	WorkspaceID pgtype.UUID
+	FilePaths   []string
+	MaxLinks    int32
+}
+
+type ListLinksToPathsRow struct {
+	FilePath   string
+	Line       int32
+	Target     string
+	Candidates []string
+}
+
+func (q *Queries) ListLinksToPaths(ctx context.Context, arg ListLinksToPathsParams) ([]ListLinksToPathsRow, error) {
+	rows, err := q.db.Query(ctx, listLinksToPaths, arg.WorkspaceID, arg.FilePaths, arg.MaxLinks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListLinksToPathsRow
+	for rows.Next() {
+		var i ListLinksToPathsRow
+		if err := rows.Scan(
+			&i.FilePath,
+			&i.Line,
+			&i.Target,
+			&i.Candidates,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMemberWorkspaces = `-- name: ListMemberWorkspaces :many
+SELECT w.id, w.user_id, w.name, w.storage_limit_bytes, w.storage_used_bytes, w.created_at, w.updated_at, w.file_count, w.archived_at, w.icon, w.color, w.description, w.revision, m.role, COALESCE(o.sort_order, 0)::INTEGER AS sort_order FROM workspaces w
+JOIN workspace_members m ON m.workspace_id = w.id
+LEFT JOIN workspace_sort_orders o ON o.workspace_id = w.id AND o.user_id = m.user_id
+WHERE m.user_id = $1
+ORDER BY COALESCE(o.sort_order, 0), w.created_at DESC
+`
+
+type ListMemberWorkspacesRow struct {
+	ID                pgtype.UUID
+	UserID            pgtype.UUID
+	Name              string
+	StorageLimitBytes int64
+	StorageUsedBytes  pgtype.Int8
+	CreatedAt         pgtype.Timestamptz
+	UpdatedAt         pgtype.Timestamptz
+	FileCount         int64
+	ArchivedAt        pgtype.Timestamptz
+	Icon              string
+	Color             string
+	Description       string
+	Revision          int64
+	Role              WorkspaceRole
+	SortOrder         int32
+}
+
+func (q *Queries) ListMemberWorkspaces(ctx context.Context, userID pgtype.UUID) ([]ListMemberWorkspacesRow, error) {
+	rows, err := q.db.Query(ctx, listMemberWorkspaces, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListMemberWorkspacesRow
+	for rows.Next() {
+		var i ListMemberWorkspacesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Name,
+			&i.StorageLimitBytes,
+			&i.StorageUsedBytes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FileCount,
+			&i.ArchivedAt,
+			&i.Icon,
+			&i.Color,
+			&i.Description,
+			&i.Revision,
+			&i.Role,
+			&i.SortOrder,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNoteTemplates = `-- name: ListNoteTemplates :many
+SELECT id, workspace_id, name, description, content, created_by, created_at FROM note_templates WHERE workspace_id = $1 ORDER BY name
+`
+
+func (q *Queries) ListNoteTemplates(ctx context.Context, workspaceID pgtype.UUID) ([]NoteTemplate, error) {
+	rows, err := q.db.Query(ctx, listNoteTemplates, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []NoteTemplate
+	for rows.Next() {
+		var i NoteTemplate
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.Name,
+			&i.Description,
+			&i.Content,
+			&i.CreatedBy,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNotifications = `-- name: ListNotifications :many
+SELECT id, user_id, kind, workspace_id, title, body, data, read_at, created_at FROM notifications
+WHERE user_id = $1
+  AND (NOT $2::boolean OR read_at IS NULL)
+ORDER BY created_at DESC
+LIMIT $3
+`
+
+type ListNotificationsParams struct {
+	UserID     pgtype.UUID
+	UnreadOnly bool
+	MaxResults int32
+}
+
+func (q *Queries) ListNotifications(ctx context.Context, arg ListNotificationsParams) ([]Notification, error) {
+	rows, err := q.db.Query(ctx, listNotifications, arg.UserID, arg.UnreadOnly, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Notification
+	for rows.Next() {
+		var i Notification
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Kind,
+			&i.WorkspaceID,
+			&i.Title,
+			&i.Body,
+			&i.Data,
+			&i.ReadAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOAuthIdentities = `-- name: ListOAuthIdentities :many
+SELECT id, user_id, provider, provider_user_id, email, created_at, last_login_at FROM oauth_identities WHERE user_id = $1 ORDER BY provider
+`
+
+func (q *Queries) ListOAuthIdentities(ctx context.Context, userID pgtype.UUID) ([]OauthIdentity, error) {
+	rows, err := q.db.Query(ctx, listOAuthIdentities, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OauthIdentity
+	for rows.Next() {
+		var i OauthIdentity
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Provider,
+			&i.ProviderUserID,
+			&i.Email,
+			&i.CreatedAt,
+			&i.LastLoginAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOrganizationMembers = `-- name: ListOrganizationMembers :many
+SELECT m.organization_id, m.user_id, m.role, m.invited_by, m.created_at, u.email
+FROM organization_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.organization_id = $1
+ORDER BY m.created_at, u.email
+`
+
+type ListOrganizationMembersRow struct {
+	OrganizationID pgtype.UUID
+	UserID         pgtype.UUID
+	Role           OrganizationRole
+	InvitedBy      pgtype.UUID
+	CreatedAt      pgtype.Timestamptz
+	Email          string
+}
+
+func (q *Queries) ListOrganizationMembers(ctx context.Context, organizationID pgtype.UUID) ([]ListOrganizationMembersRow, error) {
+	rows, err := q.db.Query(ctx, listOrganizationMembers, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListOrganizationMembersRow
+	for rows.Next() {
+		var i ListOrganizationMembersRow
+		if err := rows.Scan(
+			&i.OrganizationID,
+			&i.UserID,
+			&i.Role,
+			&i.InvitedBy,
+			&i.CreatedAt,
+			&i.Email,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOrganizationWorkspaces = `-- name: ListOrganizationWorkspaces :many
+SELECT w.id, w.user_id, u.email AS owner_email, w.name, w.storage_used_bytes, w.file_count, ow.added_by, ow.created_at AS added_at
+FROM organization_workspaces ow
+JOIN workspaces w ON w.id = ow.workspace_id
+JOIN users u ON u.id = w.user_id
+WHERE ow.organization_id = $1
+ORDER BY w.name, w.id
+`
+
+type ListOrganizationWorkspacesRow struct {
+	ID               pgtype.UUID
+	UserID           pgtype.UUID
+	OwnerEmail       string
+	Name             string
+	StorageUsedBytes pgtype.Int8
+	FileCount        int64
+	AddedBy          pgtype.UUID
+	AddedAt          pgtype.Timestamptz
+}
+
+func (q *Queries) ListOrganizationWorkspaces(ctx context.Context, organizationID pgtype.UUID) ([]ListOrganizationWorkspacesRow, error) {
+	rows, err := q.db.Query(ctx, listOrganizationWorkspaces, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListOrganizationWorkspacesRow
+	for rows.Next() {
+		var i ListOrganizationWorkspacesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.OwnerEmail,
+			&i.Name,
+			&i.StorageUsedBytes,
+			&i.FileCount,
+			&i.AddedBy,
+			&i.AddedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOrganizations = `-- name: ListOrganizations :many
+SELECT o.id, o.name, o.billing_email, o.tier, o.seat_storage_bytes, o.storage_limit_bytes, o.created_at, o.updated_at,
+       (SELECT COUNT(*) FROM organization_members m WHERE m.organization_id = o.id) AS member_count,
+       (SELECT COUNT(*) FROM organization_workspaces ow WHERE ow.organization_id = o.id) AS workspace_count,
+       (SELECT COALESCE(SUM(w.storage_used_bytes), 0)
+        FROM organization_workspaces ow
+        JOIN workspaces w ON w.id = ow.workspace_id
+        WHERE ow.organization_id = o.id)::bigint AS storage_used_bytes
+FROM organizations o
+ORDER BY o.name, o.id
+`
+
+type ListOrganizationsRow struct {
+	ID                pgtype.UUID
+	Name              string
+	BillingEmail      string
+	Tier              UserTier
+	SeatStorageBytes  int64
+	StorageLimitBytes pgtype.Int8
+	CreatedAt         pgtype.Timestamptz
+	UpdatedAt         pgtype.Timestamptz
+	MemberCount       int64
+	WorkspaceCount    int64
+	StorageUsedBytes  int64
+}
+
+func (q *Queries) ListOrganizations(ctx context.Context) ([]ListOrganizationsRow, error) {
+	rows, err := q.db.Query(ctx, listOrganizations)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListOrganizationsRow
+	for rows.Next() {
+		var i ListOrganizationsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.BillingEmail,
+			&i.Tier,
+			&i.SeatStorageBytes,
+			&i.StorageLimitBytes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.MemberCount,
+			&i.WorkspaceCount,
+			&i.StorageUsedBytes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPasskeys = `-- name: ListPasskeys :many
+SELECT id, user_id, credential_id, public_key, sign_count, name, created_at, last_used_at FROM passkeys WHERE user_id = $1 ORDER BY created_at
+`
+
+func (q *Queries) ListPasskeys(ctx context.Context, userID pgtype.UUID) ([]Passkey, error) {
+	rows, err := q.db.Query(ctx, listPasskeys, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Passkey
+	for rows.Next() {
+		var i Passkey
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.CredentialID,
+			&i.PublicKey,
+			&i.SignCount,
+			&i.Name,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPendingWebhooks = `-- name: ListPendingWebhooks :many
+SELECT w.id, w.workspace_id, w.url, w.secret, w.event_types, w.path_prefix, w.created_by, w.last_event_id, w.failure_count, w.last_error, w.next_attempt_at, w.created_at FROM workspace_webhooks w
+WHERE (w.next_attempt_at IS NULL OR w.next_attempt_at <= NOW())
+  AND EXISTS (SELECT 1 FROM workspace_events e WHERE e.workspace_id = w.workspace_id AND e.id > w.last_event_id)
+ORDER BY w.next_attempt_at NULLS FIRST, w.created_at
+LIMIT $1
+`
+
+func (q *Queries) ListPendingWebhooks(ctx context.Context, limit int32) ([]WorkspaceWebhook, error) {
+	rows, err := q.db.Query(ctx, listPendingWebhooks, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WorkspaceWebhook
+	for rows.Next() {
+		var i WorkspaceWebhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.Url,
+			&i.Secret,
+			&i.EventTypes,
+			&i.PathPrefix,
+			&i.CreatedBy,
+			&i.LastEventID,
+			&i.FailureCount,
+			&i.LastError,
+			&i.NextAttemptAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPinnedFiles = `-- name: ListPinnedFiles :many
+SELECT f.id, f.workspace_id, f.file_path, f.content_hash, f.size_bytes, f.mime_type, f.last_modified, f.updated_at, p.pinned_at
+FROM file_pins p
+JOIN files f ON f.id = p.file_id
+WHERE p.user_id = $1 AND p.workspace_id = $2
+ORDER BY p.pinned_at, f.file_path
+`
+
+type ListPinnedFilesParams struct {
+	UserID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+}
+
+type ListPinnedFilesRow struct {
+	ID           pgtype.UUID
+	WorkspaceID  pgtype.UUID
+	FilePath     string
+	ContentHash  string
+	SizeBytes    int64
+	MimeType     pgtype.Text
+	LastModified pgtype.Timestamptz
+	UpdatedAt    pgtype.Timestamptz
+	PinnedAt     pgtype.Timestamptz
+}
+
+func (q *Queries) ListPinnedFiles(ctx context.Context, arg ListPinnedFilesParams) ([]ListPinnedFilesRow, error) {
+	rows, err := q.db.Query(ctx, listPinnedFiles, arg.UserID, arg.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPinnedFilesRow
+	for rows.Next() {
+		var i ListPinnedFilesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.FilePath,
+			&i.ContentHash,
+			&i.SizeBytes,
+			&i.MimeType,
+			&i.LastModified,
+			&i.UpdatedAt,
+			&i.PinnedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPolicyRules = `-- name: ListPolicyRules :many
+SELECT id, name, detector, pattern, action, created_by, created_at FROM policy_rules ORDER BY created_at, id
+`
+
+func (q *Queries) ListPolicyRules(ctx context.Context) ([]PolicyRule, error) {
+	rows, err := q.db.Query(ctx, listPolicyRules)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PolicyRule
+	for rows.Next() {
+		var i PolicyRule
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Detector,
+			&i.Pattern,
+			&i.Action,
+			&i.CreatedBy,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPolicyViolations = `-- name: ListPolicyViolations :many
+SELECT id, rule_id, rule_name, workspace_id, user_id, file_path, action_type, blocked, match_count, created_at FROM policy_violations
+WHERE id < $1
+ORDER BY id DESC
+LIMIT $2
+`
+
+type ListPolicyViolationsParams struct {
+	BeforeID int64
+	PageSize int32
+}
+
+func (q *Queries) ListPolicyViolations(ctx context.Context, arg ListPolicyViolationsParams) ([]PolicyViolation, error) {
+	rows, err := q.db.Query(ctx, listPolicyViolations, arg.BeforeID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PolicyViolation
+	for rows.Next() {
+		var i PolicyViolation
+		if err := rows.Scan(
+			&i.ID,
+			&i.RuleID,
+			&i.RuleName,
+			&i.WorkspaceID,
+			&i.UserID,
+			&i.FilePath,
+			&i.ActionType,
+			&i.Blocked,
+			&i.MatchCount,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRecentFileOpens = `-- name: ListRecentFileOpens :many
+SELECT f.id, f.workspace_id, f.file_path, f.content_hash, f.size_bytes, f.mime_type, f.last_modified, f.updated_at, o.opened_at
+FROM file_opens o
+JOIN files f ON f.id = o.file_id
+WHERE o.user_id = $1 AND o.workspace_id = $2
+ORDER BY o.opened_at DESC, f.file_path
+LIMIT $3
+`
+
+type ListRecentFileOpensParams struct {
+	UserID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	MaxFiles    int32
+}
+
+type ListRecentFileOpensRow struct {
+	ID           pgtype.UUID
+	WorkspaceID  pgtype.UUID
+	FilePath     string
+	ContentHash  string
+	SizeBytes    int64
+	MimeType     pgtype.Text
+	LastModified pgtype.Timestamptz
+	UpdatedAt    pgtype.Timestamptz
+	OpenedAt     pgtype.Timestamptz
+}
+
+func (q *Queries) ListRecentFileOpens(ctx context.Context, arg ListRecentFileOpensParams) ([]ListRecentFileOpensRow, error) {
+	rows, err := q.db.Query(ctx, listRecentFileOpens, arg.UserID, arg.WorkspaceID, arg.MaxFiles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListRecentFileOpensRow
+	for rows.Next() {
+		var i ListRecentFileOpensRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.FilePath,
+			&i.ContentHash,
+			&i.SizeBytes,
+			&i.MimeType,
+			&i.LastModified,
+			&i.UpdatedAt,
+			&i.OpenedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScopedAPITokens = `-- name: ListScopedAPITokens :many
+SELECT id, user_id, token_hash, name, last_used_at, expires_at, created_at, device_id, client_name, client_version, scopes, last_ip, user_agent FROM api_tokens
+WHERE user_id = $1 AND scopes IS NOT NULL
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListScopedAPITokens(ctx context.Context, userID pgtype.UUID) ([]ApiToken, error) {
+	rows, err := q.db.Query(ctx, listScopedAPITokens, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiToken
+	for rows.Next() {
+		var i ApiToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.TokenHash,
+			&i.Name,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+			&i.DeviceID,
+			&i.ClientName,
+			&i.ClientVersion,
+			&i.Scopes,
+			&i.LastIp,
+			&i.UserAgent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSessions = `-- name: ListSessions :many
+SELECT t.id, t.name, t.device_id, d.name AS device_name,
+       t.client_name, t.client_version, t.last_ip, t.user_agent, t.scopes,
+       t.last_used_at, t.expires_at, t.created_at
+FROM api_tokens t
+LEFT JOIN devices d ON d.id = t.device_id
+WHERE t.user_id = $1 AND (t.expires_at IS NULL OR t.expires_at > NOW())
+ORDER BY COALESCE(t.last_used_at, t.created_at) DESC
+`
+
+type ListSessionsRow struct {
+	ID            pgtype.UUID
+	Name          string
+	DeviceID      pgtype.UUID
+	DeviceName    pgtype.Text
+	ClientName    pgtype.Text
+	ClientVersion pgtype.Text
+	LastIp        pgtype.Text
+	UserAgent     pgtype.Text
+	Scopes        []string
+	LastUsedAt    pgtype.Timestamptz
+	ExpiresAt     pgtype.Timestamptz
+	CreatedAt     pgtype.Timestamptz
+}
+
+func (q *Queries) ListSessions(ctx context.Context, userID pgtype.UUID) ([]ListSessionsRow, error) {
+	rows, err := q.db.Query(ctx, listSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSessionsRow
+	for rows.Next() {
+		var i ListSessionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.DeviceID,
+			&i.DeviceName,
+			&i.ClientName,
+			&i.ClientVersion,
+			&i.LastIp,
+			&i.UserAgent,
+			&i.Scopes,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listShareLinksByUser = `-- name: ListShareLinksByUser :many
+SELECT s.id, s.workspace_id, f.file_path, s.expires_at, s.created_at
+FROM share_links s
+JOIN files f ON f.id = s.file_id
+WHERE s.created_by = $1 AND s.revoked_at IS NULL
+ORDER BY s.created_at DESC
+`
+
+type ListShareLinksByUserRow struct {
+	ID          pgtype.UUID
+	WorkspaceID pgtype.UUID
+	FilePath    string
+	ExpiresAt   pgtype.Timestamptz
+	CreatedAt   pgtype.Timestamptz
+}
+
+func (q *Queries) ListShareLinksByUser(ctx context.Context, createdBy pgtype.UUID) ([]ListShareLinksByUserRow, error) {
+	rows, err := q.db.Query(ctx, listShareLinksByUser, createdBy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListShareLinksByUserRow
+	for rows.Next() {
+		var i ListShareLinksByUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.FilePath,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStaleMetadataFiles = `-- name: ListStaleMetadataFiles :many
+SELECT f.workspace_id, f.file_path
+FROM files f
+LEFT JOIN file_metadata m ON m.file_id = f.id
+WHERE m.file_id IS NULL OR m.parser_version < $1
+ORDER BY f.last_modified DESC
+LIMIT $2
+`
+
+type ListStaleMetadataFilesParams struct {
+	ParserVersion int32
+	MaxFiles      int32
+}
+
+type ListStaleMetadataFilesRow struct {
+	WorkspaceID pgtype.UUID
+	FilePath    string
+}
+
+func (q *Queries) ListStaleMetadataFiles(ctx context.Context, arg ListStaleMetadataFilesParams) ([]ListStaleMetadataFilesRow, error) {
+	rows, err := q.db.Query(ctx, listStaleMetadataFiles, arg.ParserVersion, arg.MaxFiles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListStaleMetadataFilesRow
+	for rows.Next() {
+		var i ListStaleMetadataFilesRow
+		if err := rows.Scan(&i.WorkspaceID, &i.FilePath); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStorageAlertCandidates = `-- name: ListStorageAlertCandidates :many
+SELECT c.workspace_id, c.workspace_name, c.owner_id, c.owner_email,
+       c.storage_used_bytes, c.storage_limit_bytes,
+       COALESCE(a.threshold, 0)::int AS alerted_threshold
+FROM (
+    SELECT w.id AS workspace_id, w.name AS workspace_name,
+           w.user_id AS owner_id, u.email AS owner_email,
+           CASE WHEN ow.organization_id IS NULL THEN COALESCE(w.storage_used_bytes, 0)
+                ELSE (SELECT COALESCE(SUM(pw.storage_used_bytes), 0)
+                      FROM organization_workspaces pow
+                      JOIN workspaces pw ON pw.id = pow.workspace_id
+                      WHERE pow.organization_id = o.id)
+           END::bigint AS storage_used_bytes,
+           CASE WHEN ow.organization_id IS NULL THEN w.storage_limit_bytes
+                ELSE COALESCE(o.storage_limit_bytes, o.seat_storage_bytes *
+                    (SELECT COUNT(*) FROM organization_members m WHERE m.organization_id = o.id))
+           END::bigint AS storage_limit_bytes
+    FROM workspaces w
+    JOIN users u ON u.id = w.user_id
+    LEFT JOIN organization_workspaces ow ON ow.workspace_id = w.id
+    LEFT JOIN organizations o ON o.id = ow.organization_id
+) c
+LEFT JOIN workspace_storage_alerts a ON a.workspace_id = c.workspace_id
+WHERE a.workspace_id IS NOT NULL
+   OR c.storage_used_bytes * 100 >= c.storage_limit_bytes * $1::bigint
+`
+
+type ListStorageAlertCandidatesRow struct {
+	WorkspaceID       pgtype.UUID
+	WorkspaceName     string
+	OwnerID           pgtype.UUID
+	OwnerEmail        string
+	StorageUsedBytes  int64
+	StorageLimitBytes int64
+	AlertedThreshold  int32
+}
+
+// Workspaces using min_percent of their limit or more, and those alerted
+// before, measured as GetWorkspaceStorageUsage measures them.
+func (q *Queries) ListStorageAlertCandidates(ctx context.Context, minPercent int64) ([]ListStorageAlertCandidatesRow, error) {
+	rows, err := q.db.Query(ctx, listStorageAlertCandidates, minPercent)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListStorageAlertCandidatesRow
+	for rows.Next() {
+		var i ListStorageAlertCandidatesRow
+		if err := rows.Scan(
+			&i.WorkspaceID,
+			&i.WorkspaceName,
+			&i.OwnerID,
+			&i.OwnerEmail,
+			&i.StorageUsedBytes,
+			&i.StorageLimitBytes,
+			&i.AlertedThreshold,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSuggestionsByUser = `-- name: ListSuggestionsByUser :many
+SELECT s.id, s.workspace_id, s.reason, s.last_activity_at, s.created_at,
+       w.name AS workspace_name, w.storage_used_bytes, w.file_count
+FROM workspace_suggestions s
+JOIN workspaces w ON w.id = s.workspace_id
+WHERE s.user_id = $1 AND s.dismissed_at IS NULL
+ORDER BY s.last_activity_at
+`
+
+type ListSuggestionsByUserRow struct {
+	ID               pgtype.UUID
+	WorkspaceID      pgtype.UUID
+	Reason           string
+	LastActivityAt   pgtype.Timestamptz
+	CreatedAt        pgtype.Timestamptz
+	WorkspaceName    string
+	StorageUsedBytes pgtype.Int8
+	FileCount        int64
+}
+
+func (q *Queries) ListSuggestionsByUser(ctx context.Context, userID pgtype.UUID) ([]ListSuggestionsByUserRow, error) {
+	rows, err := q.db.Query(ctx, listSuggestionsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSuggestionsByUserRow
+	for rows.Next() {
+		var i ListSuggestionsByUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.Reason,
+			&i.LastActivityAt,
+			&i.CreatedAt,
+			&i.WorkspaceName,
+			&i.StorageUsedBytes,
+			&i.FileCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSyncOperations = `-- name: ListSyncOperations :many
+SELECT id, workspace_id, file_id, operation_type, client_id, status, error_message, created_at, file_path FROM sync_operations
+WHERE workspace_id = $1
+  AND (created_at, id) < ($2::timestamptz, $3::uuid)
+  AND created_at >= $4::timestamptz
+  AND ($5::text IS NULL OR status = $5)
+  AND ($6::text IS NULL OR operation_type = $6)
+  AND ($7::text IS NULL OR client_id = $7)
+ORDER BY created_at DESC, id DESC
+LIMIT $8
+`
+
+type ListSyncOperationsParams struct {
+	WorkspaceID     pgtype.UUID
+	BeforeCreatedAt pgtype.Timestamptz
+	BeforeID        pgtype.UUID
+	Since           pgtype.Timestamptz
+	Status          pgtype.Text
+	OperationType   pgtype.Text
+	ClientID        pgtype.Text
+	PageSize        int32
+}
+
+func (q *Queries) ListSyncOperations(ctx context.Context, arg ListSyncOperationsParams) ([]SyncOperation, error) {
+	rows, err := q.db.Query(ctx, listSyncOperations,
+		arg.WorkspaceID,
+		arg.BeforeCreatedAt,
+		arg.BeforeID,
+		arg.Since,
+		arg.Status,
+		arg.OperationType,
+		arg.ClientID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SyncOperation
+	for rows.Next() {
+		var i SyncOperation
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.FileID,
+			&i.OperationType,
+			&i.ClientID,
+			&i.Status,
+			&i.ErrorMessage,
+			&i.CreatedAt,
+			&i.FilePath,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTableGrowth = `-- name: ListTableGrowth :many
+SELECT relname::text AS table_name,
+       n_live_tup AS live_rows,
+       n_dead_tup AS dead_rows,
+       pg_total_relation_size(relid) AS total_bytes,
+       last_autovacuum
+FROM pg_stat_user_tables
+ORDER BY pg_total_relation_size(relid) DESC
+`
+
+type ListTableGrowthRow struct {
+	TableName      string
+	LiveRows       int64
+	DeadRows       int64
+	TotalBytes     int64
+	LastAutovacuum pgtype.Timestamptz
+}
+
+func (q *Queries) ListTableGrowth(ctx context.Context) ([]ListTableGrowthRow, error) {
+	rows, err := q.db.Query(ctx, listTableGrowth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTableGrowthRow
+	for rows.Next() {
+		var i ListTableGrowthRow
+		if err := rows.Scan(
+			&i.TableName,
+			&i.LiveRows,
+			&i.DeadRows,
+			&i.TotalBytes,
+			&i.LastAutovacuum,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnindexedFiles = `-- name: ListUnindexedFiles :many
+SELECT f.id, f.workspace_id, f.file_path, f.content_hash
+FROM files f
+LEFT JOIN file_search s ON s.file_id = f.id
+LEFT JOIN file_search_failures x ON x.file_id = f.id AND x.content_hash = f.content_hash
+WHERE (s.file_id IS NULL OR s.content_hash <> f.content_hash)
+  AND COALESCE(x.attempts, 0) < $1::integer
+ORDER BY COALESCE(x.attempts, 0), f.last_modified DESC
+LIMIT $2
+`
+
+type ListUnindexedFilesParams struct {
+	MaxAttempts int32
+	BatchSize   int32
+}
+
+type ListUnindexedFilesRow struct {
+	ID          pgtype.UUID
+	WorkspaceID pgtype.UUID
+	FilePath    string
+	ContentHash string
+}
+
+func (q *Queries) ListUnindexedFiles(ctx context.Context, arg ListUnindexedFilesParams) ([]ListUnindexedFilesRow, error) {
+	rows, err := q.db.Query(ctx, listUnindexedFiles, arg.MaxAttempts, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUnindexedFilesRow
+	for rows.Next() {
+		var i ListUnindexedFilesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.FilePath,
+			&i.ContentHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnreferencedBlobs = `-- name: ListUnreferencedBlobs :many
+SELECT content_hash FROM blob_refs
+WHERE ref_count <= 0 AND updated_at < $1
+ORDER BY updated_at
+LIMIT $2
+`
+
+type ListUnreferencedBlobsParams struct {
+	UpdatedAt pgtype.Timestamptz
+	Limit     int32
+}
+
+func (q *Queries) ListUnreferencedBlobs(ctx context.Context, arg ListUnreferencedBlobsParams) ([]string, error) {
+	rows, err := q.db.Query(ctx, listUnreferencedBlobs, arg.UpdatedAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var content_hash string
+		if err := rows.Scan(&content_hash); err != nil {
+			return nil, err
+		}
+		items = append(items, content_hash)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsageByMonth = `-- name: ListUsageByMonth :many
+SELECT u.id AS user_id, u.email, u.tier, m.api_requests, m.upload_bytes, m.download_bytes
+FROM usage_monthly m
+JOIN users u ON u.id = m.user_id
+WHERE m.month = $1
+ORDER BY u.email
+`
+
+type ListUsageByMonthRow struct {
+	UserID        pgtype.UUID
+	Email         string
+	Tier          UserTier
+	ApiRequests   int64
+	UploadBytes   int64
+	DownloadBytes int64
+}
+
+func (q *Queries) ListUsageByMonth(ctx context.Context, month pgtype.Date) ([]ListUsageByMonthRow, error) {
+	rows, err := q.db.Query(ctx, listUsageByMonth, month)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUsageByMonthRow
+	for rows.Next() {
+		var i ListUsageByMonthRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Email,
+			&i.Tier,
+			&i.ApiRequests,
+			&i.UploadBytes,
+			&i.DownloadBytes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserOrganizations = `-- name: ListUserOrganizations :many
+SELECT o.id, o.name, o.billing_email, o.tier, o.seat_storage_bytes, o.storage_limit_bytes, o.created_at, o.updated_at,
+       (SELECT COUNT(*) FROM organization_members om WHERE om.organization_id = o.id) AS member_count,
+       (SELECT COUNT(*) FROM organization_workspaces ow WHERE ow.organization_id = o.id) AS workspace_count,
+       (SELECT COALESCE(SUM(w.storage_used_bytes), 0)
+        FROM organization_workspaces ow
+        JOIN workspaces w ON w.id = ow.workspace_id
+        WHERE ow.organization_id = o.id)::bigint AS storage_used_bytes,
+       m.role
+FROM organizations o
+JOIN organization_members m ON m.organization_id = o.id
+WHERE m.user_id = $1
+ORDER BY o.name, o.id
+`
+
+type ListUserOrganizationsRow struct {
+	ID                pgtype.UUID
+	Name              string
+	BillingEmail      string
+	Tier              UserTier
+	SeatStorageBytes  int64
+	StorageLimitBytes pgtype.Int8
+	CreatedAt         pgtype.Timestamptz
+	UpdatedAt         pgtype.Timestamptz
+	MemberCount       int64
+	WorkspaceCount    int64
+	StorageUsedBytes  int64
+	Role              OrganizationRole
+}
+
+func (q *Queries) ListUserOrganizations(ctx context.Context, userID pgtype.UUID) ([]ListUserOrganizationsRow, error) {
+	rows, err := q.db.Query(ctx, listUserOrganizations, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserOrganizationsRow
+	for rows.Next() {
+		var i ListUserOrganizationsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.BillingEmail,
+			&i.Tier,
+			&i.SeatStorageBytes,
+			&i.StorageLimitBytes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.MemberCount,
+			&i.WorkspaceCount,
+			&i.StorageUsedBytes,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserUsage = `-- name: ListUserUsage :many
+SELECT user_id, month, api_requests, upload_bytes, download_bytes, updated_at FROM usage_monthly
+WHERE user_id = $1 AND month >= $2
+ORDER BY month DESC
+`
+
+type ListUserUsageParams struct {
+	UserID pgtype.UUID
+	Since  pgtype.Date
+}
+
+func (q *Queries) ListUserUsage(ctx context.Context, arg ListUserUsageParams) ([]UsageMonthly, error) {
+	rows, err := q.db.Query(ctx, listUserUsage, arg.UserID, arg.Since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UsageMonthly
+	for rows.Next() {
+		var i UsageMonthly
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Month,
+			&i.ApiRequests,
+			&i.UploadBytes,
+			&i.DownloadBytes,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersDueForDeletion = `-- name: ListUsersDueForDeletion :many
+SELECT id FROM users
+WHERE delete_after <= NOW()
+ORDER BY delete_after
+LIMIT $1
+`
+
+func (q *Queries) ListUsersDueForDeletion(ctx context.Context, limit int32) ([]pgtype.UUID, error) {
+	rows, err := q.db.Query(ctx, listUsersDueForDeletion, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []pgtype.UUID
+	for rows.Next() {
+		var id pgtype.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWorkspaceEvents = `-- name: ListWorkspaceEvents :many
+SELECT id, workspace_id, event_type, file_path, actor_id, payload, created_at FROM workspace_events
+WHERE workspace_id = $1
+  AND id > $2
+  AND (cardinality($3::text[]) = 0 OR event_type LIKE ANY($3::text[]))
+ORDER BY id
+LIMIT $4
+`
+
+type ListWorkspaceEventsParams struct {
+	WorkspaceID  pgtype.UUID
+	AfterID      int64
+	TypePatterns []string
+	PageSize     int32
+}
+
+func (q *Queries) ListWorkspaceEvents(ctx context.Context, arg ListWorkspaceEventsParams) ([]WorkspaceEvent, error) {
+	rows, err := q.db.Query(ctx, listWorkspaceEvents,
+		arg.WorkspaceID,
+		arg.AfterID,
+		arg.TypePatterns,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WorkspaceEvent
+	for rows.Next() {
+		var i WorkspaceEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.EventType,
+			&i.FilePath,
+			&i.ActorID,
+			&i.Payload,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWorkspaceEventsBetween = `-- name: ListWorkspaceEventsBetween :many
+SELECT id, workspace_id, event_type, file_path, actor_id, payload, created_at FROM workspace_events
+WHERE workspace_id = $1
+  AND created_at >= $2
+  AND created_at < $3
+  AND id > $4
+ORDER BY id
+LIMIT $5
+`
+
+type ListWorkspaceEventsBetweenParams struct {
+	WorkspaceID pgtype.UUID
+	FromTime    pgtype.Timestamptz
+	ToTime      pgtype.Timestamptz
+	AfterID     int64
+	PageSize    int32
+}
+
+func (q *Queries) ListWorkspaceEventsBetween(ctx context.Context, arg ListWorkspaceEventsBetweenParams) ([]WorkspaceEvent, error) {
+	rows, err := q.db.Query(ctx, listWorkspaceEventsBetween,
+		arg.WorkspaceID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WorkspaceEvent
+	for rows.Next() {
+		var i WorkspaceEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.EventType,
+			&i.FilePath,
+			&i.ActorID,
+			&i.Payload,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWorkspaceFilesCreatedByDay = `-- name: ListWorkspaceFilesCreatedByDay :many
+SELECT (created_at AT TIME ZONE $1::text)::date AS day,
+       COUNT(*)::bigint AS files
+FROM files
+WHERE workspace_id = $2
+  AND created_at >= $3
+GROUP BY 1
+ORDER BY 1
+`
+
+type ListWorkspaceFilesCreatedByDayParams struct {
+	TimeZone    string
+	WorkspaceID pgtype.UUID
+	Since       pgtype.Timestamptz
+}
+
+type ListWorkspaceFilesCreatedByDayRow struct {
+	Day   pgtype.Date
+	Files int64
+}
+
+func (q *Queries) ListWorkspaceFilesCreatedByDay(ctx context.Context, arg ListWorkspaceFilesCreatedByDayParams) ([]ListWorkspaceFilesCreatedByDayRow, error) {
+	rows, err := q.db.Query(ctx, listWorkspaceFilesCreatedByDay, arg.TimeZone, arg.WorkspaceID, arg.Since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListWorkspaceFilesCreatedByDayRow
+	for rows.Next() {
+		var i ListWorkspaceFilesCreatedByDayRow
+		if err := rows.Scan(&i.Day, &i.Files); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWorkspaceFormatStats = `-- name: ListWorkspaceFormatStats :many
+SELECT COALESCE(m.format, 'unparsed')::text AS format,
+       COUNT(*)::bigint AS files,
+       COALESCE(SUM(f.size_bytes), 0)::bigint AS size_bytes,
+       COALESCE(SUM(m.word_count), 0)::bigint AS words
+FROM files f
+LEFT JOIN file_metadata m ON m.file_id = f.id
+WHERE f.workspace_id = $1
+GROUP BY 1
+ORDER BY files DESC, format
+`
+
+type ListWorkspaceFormatStatsRow struct {
+	Format    string
+	Files     int64
+	SizeBytes int64
+	Words     int64
+}
+
+func (q *Queries) ListWorkspaceFormatStats(ctx context.Context, workspaceID pgtype.UUID) ([]ListWorkspaceFormatStatsRow, error) {
+	rows, err := q.db.Query(ctx, listWorkspaceFormatStats, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListWorkspaceFormatStatsRow
+	for rows.Next() {
+		var i ListWorkspaceFormatStatsRow
+		if err := rows.Scan(
+			&i.Format,
+			&i.Files,
+			&i.SizeBytes,
+			&i.Words,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWorkspaceInvites = `-- name: ListWorkspaceInvites :many
+SELECT id, workspace_id, email, role, token_hash, invited_by, expires_at, accepted_at, accepted_by, created_at FROM workspace_invites
+WHERE workspace_id = $1 AND accepted_at IS NULL AND expires_at > NOW()
+ORDER BY created_at, email
+`
+
+func (q *Queries) ListWorkspaceInvites(ctx context.Context, workspaceID pgtype.UUID) ([]WorkspaceInvite, error) {
+	rows, err := q.db.Query(ctx, listWorkspaceInvites, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WorkspaceInvite
+	for rows.Next() {
+		var i WorkspaceInvite
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.Email,
+			&i.Role,
+			&i.TokenHash,
+			&i.InvitedBy,
+			&i.ExpiresAt,
+			&i.AcceptedAt,
+			&i.AcceptedBy,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWorkspaceMembers = `-- name: ListWorkspaceMembers :many
+SELECT m.workspace_id, m.user_id, m.role, m.invited_by, m.created_at, u.email
+FROM workspace_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.workspace_id = $1
+ORDER BY m.created_at, u.email
+`
+
+type ListWorkspaceMembersRow struct {
+	WorkspaceID pgtype.UUID
+	UserID      pgtype.UUID
+	Role        WorkspaceRole
+	InvitedBy   pgtype.UUID
+	CreatedAt   pgtype.Timestamptz
+	Email       string
+}
+
+func (q *Queries) ListWorkspaceMembers(ctx context.Context, workspaceID pgtype.UUID) ([]ListWorkspaceMembersRow, error) {
+	rows, err := q.db.Query(ctx, listWorkspaceMembers, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListWorkspaceMembersRow
+	for rows.Next() {
+		var i ListWorkspaceMembersRow
+		if err := rows.Scan(
+			&i.WorkspaceID,
+			&i.UserID,
+			&i.Role,
+			&i.InvitedBy,
+			&i.CreatedAt,
+			&i.Email,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWorkspaceVersionsByDay = `-- name: ListWorkspaceVersionsByDay :many
+SELECT (v.created_at AT TIME ZONE $1::text)::date AS day,
+       COUNT(*)::bigint AS versions
+FROM file_versions v
+JOIN files f ON f.id = v.file_id
+WHERE f.workspace_id = $2
+  AND v.created_at >= $3
+GROUP BY 1
+ORDER BY 1
+`
+
+type ListWorkspaceVersionsByDayParams struct {
+	TimeZone    string
+	WorkspaceID pgtype.UUID
+	Since       pgtype.Timestamptz
+}
+
+type ListWorkspaceVersionsByDayRow struct {
+	Day      pgtype.Date
+	Versions int64
+}
+
+func (q *Queries) ListWorkspaceVersionsByDay(ctx context.Context, arg ListWorkspaceVersionsByDayParams) ([]ListWorkspaceVersionsByDayRow, error) {
+	rows, err := q.db.Query(ctx, listWorkspaceVersionsByDay, arg.TimeZone, arg.WorkspaceID, arg.Since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListWorkspaceVersionsByDayRow
+	for rows.Next() {
+		var i ListWorkspaceVersionsByDayRow
+		if err := rows.Scan(&i.Day, &i.Versions); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWorkspaceViolationsBetween = `-- name: ListWorkspaceViolationsBetween :many
+SELECT id, rule_id, rule_name, workspace_id, user_id, file_path, action_type, blocked, match_count, created_at FROM policy_violations
+WHERE workspace_id = $1
+  AND created_at >= $2
+  AND created_at < $3
+  AND id > $4
+ORDER BY id
+LIMIT $5
+`
+
+type ListWorkspaceViolationsBetweenParams struct {
+	WorkspaceID pgtype.UUID
+	FromTime    pgtype.Timestamptz
+	ToTime      pgtype.Timestamptz
+	AfterID     int64
+	PageSize    int32
+}
+
+func (q *Queries) ListWorkspaceViolationsBetween(ctx context.Context, arg ListWorkspaceViolationsBetweenParams) ([]PolicyViolation, error) {
+	rows, err := q.db.Query(ctx, listWorkspaceViolationsBetween,
+		arg.WorkspaceID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PolicyViolation
+	for rows.Next() {
+		var i PolicyViolation
+		if err := rows.Scan(
+			&i.ID,
+			&i.RuleID,
+			&i.RuleName,
+			&i.WorkspaceID,
+			&i.UserID,
+			&i.FilePath,
+			&i.ActionType,
+			&i.Blocked,
+			&i.MatchCount,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWorkspaceWebhooks = `-- name: ListWorkspaceWebhooks :many
+SELECT id, workspace_id, url, secret, event_types, path_prefix, created_by, last_event_id, failure_count, last_error, next_attempt_at, created_at FROM workspace_webhooks WHERE workspace_id = $1 ORDER BY created_at
+`
+
+func (q *Queries) ListWorkspaceWebhooks(ctx context.Context, workspaceID pgtype.UUID) ([]WorkspaceWebhook, error) {
+	rows, err := q.db.Query(ctx, listWorkspaceWebhooks, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WorkspaceWebhook
+	for rows.Next() {
+		var i WorkspaceWebhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.Url,
+			&i.Secret,
+			&i.EventTypes,
+			&i.PathPrefix,
+			&i.CreatedBy,
+			&i.LastEventID,
+			&i.FailureCount,
+			&i.LastError,
+			&i.NextAttemptAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockAuthKey = `-- name: LockAuthKey :exec
+UPDATE auth_failures SET locked_until = $2 WHERE key = $1
+`
+
+type LockAuthKeyParams struct {
+	Key         string
+	LockedUntil pgtype.Timestamptz
+}
+
+func (q *Queries) LockAuthKey(ctx context.Context, arg LockAuthKeyParams) error {
+	_, err := q.db.Exec(ctx, lockAuthKey, arg.Key, arg.LockedUntil)
+	return err
+}
+
+const lockFilePath = `-- name: LockFilePath :exec
+SELECT pg_advisory_xact_lock(hashtextextended($1::uuid::text || '/' || $2::text, 0))
+`
+
+type LockFilePathParams struct {
+	WorkspaceID pgtype.UUID
+	FilePath    string
+}
+
+func (q *Queries) LockFilePath(ctx context.Context, arg LockFilePathParams) error {
+	_, err := q.db.Exec(ctx, lockFilePath, arg.WorkspaceID, arg.FilePath)
+	return err
+}
+
+const lockFilesByPrefix = `-- name: LockFilesByPrefix :exec
+SELECT pg_advisory_xact_lock(hashtextextended(workspace_id::text || '/' || file_path, 0))
+FROM files
+WHERE workspace_id = $1 AND starts_with(file_path, $2)
+ORDER BY file_path
+`
+
+type LockFilesByPrefixParams struct {
+	WorkspaceID pgtype.UUID
+	PathPrefix  string
+}
+
+func (q *Queries) LockFilesByPrefix(ctx context.Context, arg LockFilesByPrefixParams) error {
+	_, err := q.db.Exec(ctx, lockFilesByPrefix, arg.WorkspaceID, arg.PathPrefix)
+	return err
+}
+
+const lockUnreferencedBlob = `-- name: LockUnreferencedBlob :one
+SELECT content_hash FROM blob_refs
+WHERE content_hash = $1 AND ref_count <= 0
+FOR UPDATE SKIP LOCKED
+`
+
+func (q *Queries) LockUnreferencedBlob(ctx context.Context, contentHash string) (string, error) {
+	row := q.db.QueryRow(ctx, lockUnreferencedBlob, contentHash)
+	var content_hash string
+	err := row.Scan(&content_hash)
+	return content_hash, err
+}
+
+const lockUser = `-- name: LockUser :one
+SELECT id, email, password_hash, tier, storage_used_bytes, created_at, updated_at, timezone, is_admin, storage_limit_bytes, disabled_at, delete_after, email_verified_at FROM users WHERE id = $1 FOR UPDATE
+`
+
+func (q *Queries) LockUser(ctx context.Context, id pgtype.UUID) (User, error) {
+	row := q.db.QueryRow(ctx, lockUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Tier,
+		&i.StorageUsedBytes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Timezone,
+		&i.IsAdmin,
+		&i.StorageLimitBytes,
+		&i.DisabledAt,
+		&i.DeleteAfter,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const lookupFiles = `-- name: LookupFiles :many
+SELECT f.id, f.workspace_id, f.file_path, f.content_hash, f.size_bytes, f.mime_type, f.last_modified, f.updated_at,
+       m.format, m.properties, m.word_count, m.last_parsed
+FROM files f
+LEFT JOIN file_metadata m ON m.file_id = f.id
+WHERE f.workspace_id = $1 AND f.file_path = ANY($2::text[])
+`
+
+type LookupFilesParams struct {
+	WorkspaceID pgtype.UUID
+	FilePaths   []string
+}
+
+type LookupFilesRow struct {
+	ID           pgtype.UUID
+	WorkspaceID  pgtype.UUID
+	FilePath     string
+	ContentHash  string
+	SizeBytes    int64
+	MimeType     pgtype.Text
+	LastModified pgtype.Timestamptz
+	UpdatedAt    pgtype.Timestamptz
+	Format       pgtype.Text
+	Properties   []byte
+	WordCount    pgtype.Int4
+	LastParsed   pgtype.Timestamptz
+}
+
+func (q *Queries) LookupFiles(ctx context.Context, arg LookupFilesParams) ([]LookupFilesRow, error) {
+	rows, err := q.db.Query(ctx, lookupFiles, arg.WorkspaceID, arg.FilePaths)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LookupFilesRow
+	for rows.Next() {
+		var i LookupFilesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkspaceID,
+			&i.FilePath,
+			&i.ContentHash,
+			&i.SizeBytes,
+			&i.MimeType,
+			&i.LastModified,
+			&i.UpdatedAt,
+			&i.Format,
+			&i.Properties,
+			&i.WordCount,
+			&i.LastParsed,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lowerStorageAlert = `-- name: LowerStorageAlert :exec
+UPDATE workspace_storage_alerts SET threshold = $2, updated_at = NOW()
+WHERE workspace_id = $1 AND threshold > $2
+`
+
+type LowerStorageAlertParams struct {
+	WorkspaceID pgtype.UUID
+	Threshold   int32
+}
+
+func (q *Queries) LowerStorageAlert(ctx context.Context, arg LowerStorageAlertParams) error {
+	_, err := q.db.Exec(ctx, lowerStorageAlert, arg.WorkspaceID, arg.Threshold)
+	return err
+}
+
+const markAllNotificationsRead = `-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL
+`
+
+func (q *Queries) MarkAllNotificationsRead(ctx context.Context, userID pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, markAllNotificationsRead, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const markEmailVerified = `-- name: MarkEmailVerified :execrows
+UPDATE users SET email_verified_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND email = $2 AND email_verified_at IS NULL
+`
+
+type MarkEmailVerifiedParams struct {
+	ID    pgtype.UUID
+	Email string
+}
+
+func (q *Queries) MarkEmailVerified(ctx context.Context, arg MarkEmailVerifiedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, markEmailVerified, arg.ID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const markNotificationRead = `-- name: MarkNotificationRead :execrows
+UPDATE notifications SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1 AND user_id = $2
+`
+
+type MarkNotificationReadParams struct {
+	ID     pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) MarkNotificationRead(ctx context.Context, arg MarkNotificationReadParams) (int64, error) {
+	result, err := q.db.Exec(ctx, markNotificationRead, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const markWorkspaceMetadataStale = `-- name: MarkWorkspaceMetadataStale :execrows
+UPDATE file_metadata m
+SET parser_version = 0
+FROM files f
+WHERE f.id = m.file_id AND f.workspace_id = $1 AND m.parser_version > 0
+`
+
+func (q *Queries) MarkWorkspaceMetadataStale(ctx context.Context, workspaceID pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, markWorkspaceMetadataStale, workspaceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const moveFile = `-- name: MoveFile :one
+UPDATE files
+SET file_path = $1, updated_at = NOW()
+WHERE workspace_id = $2 AND file_path = $3
+RETURNING id, workspace_id, file_path, content_hash, size_bytes, mime_type, last_modified, created_at, updated_at
+`
+
+type MoveFileParams struct {
+	NewPath     string
+	WorkspaceID pgtype.UUID
+	FilePath    string
+}
+
+func (q *Queries) MoveFile(ctx context.Context, arg MoveFileParams) (File, error) {
+	row := q.db.QueryRow(ctx, moveFile, arg.NewPath, arg.WorkspaceID, arg.FilePath)
+	var i File
+	err := row.Scan(
+		&i.ID,
+		&i.WorkspaceID,
+		&i.FilePath,
+		&i.ContentHash,
+		&i.SizeBytes,
+		&i.MimeType,
+		&i.LastModified,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const pinFile = `-- name: PinFile :exec
+INSERT INTO file_pins (user_id, file_id, workspace_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, file_id) DO NOTHING
+`
+
+type PinFileParams struct {
+	UserID      pgtype.UUID
+	FileID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+}
+
+func (q *Queries) PinFile(ctx context.Context, arg PinFileParams) error {
+	_, err := q.db.Exec(ctx, pinFile, arg.UserID, arg.FileID, arg.WorkspaceID)
+	return err
+}
+
+const pruneFileOpens = `-- name: PruneFileOpens :exec
+DELETE FROM file_opens
+WHERE user_id = $1
+  AND workspace_id = $2
+  AND file_id NOT IN (
+    SELECT file_id FROM file_opens
+    WHERE user_id = $1 AND workspace_id = $2
+    ORDER BY opened_at DESC
+    LIMIT $3
+  )
+`
+
+type PruneFileOpensParams struct {
+	UserID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	Keep        int32
+}
+
+func (q *Queries) PruneFileOpens(ctx context.Context, arg PruneFileOpensParams) error {
+	_, err := q.db.Exec(ctx, pruneFileOpens, arg.UserID, arg.WorkspaceID, arg.Keep)
+	return err
+}
+
+const pruneFileVersions = `-- name: PruneFileVersions :execrows
+DELETE FROM file_versions
+WHERE id IN (
+    SELECT ranked.id FROM (
+        SELECT v.id, v.created_at,
+               ROW_NUMBER() OVER (PARTITION BY v.file_id ORDER BY v.version_number DESC) AS position
+        FROM file_versions v
+        JOIN files f ON f.id = v.file_id
+        JOIN workspaces w ON w.id = f.workspace_id
+        JOIN users u ON u.id = w.user_id
+        WHERE u.tier = $1
+    ) ranked
+    WHERE ranked.position > 1
+      AND (ranked.position > $2::int OR ranked.created_at < $3)
+    LIMIT $4
+)
+`
+
+type PruneFileVersionsParams struct {
+	Tier          UserTier
+	MaxVersions   int32
+	CreatedBefore pgtype.Timestamptz
+	BatchSize     int32
+}
+
+func (q *Queries) PruneFileVersions(ctx context.Context, arg PruneFileVersionsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, pruneFileVersions,
+		arg.Tier,
+		arg.MaxVersions,
+		arg.CreatedBefore,
+		arg.BatchSize,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const pruneSyncOperations = `-- name: PruneSyncOperations :execrows
+DELETE FROM sync_operations
+WHERE id IN (
+    SELECT s.id FROM sync_operations s
+    CROSS JOIN LATERAL (
+        SELECT r.max_age_seconds
+        FROM unnest($1::text[], $2::text[], $3::bigint[])
+            AS r(operation_type, status, max_age_seconds)
+        WHERE r.operation_type IN ('', s.operation_type)
+          AND r.status IN ('', s.status)
+        ORDER BY (r.status <> '')::int * 2 + (r.operation_type <> '')::int DESC
+        LIMIT 1
+    ) rule
+    WHERE s.created_at < $4
+      AND s.created_at < NOW() - make_interval(secs => rule.max_age_seconds)
+    ORDER BY s.created_at
+    LIMIT $5
+)
+`
+
+type PruneSyncOperationsParams struct {
+	OperationTypes []string
+	Statuses       []string
+	MaxAgeSeconds  []int64
+	OlderThan      pgtype.Timestamptz
+	BatchSize      int32
+}
+
+func (q *Queries) PruneSyncOperations(ctx context.Context, arg PruneSyncOperationsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, pruneSyncOperations,
+		arg.OperationTypes,
+		arg.Statuses,
+		arg.MaxAgeSeconds,
+		arg.OlderThan,
+		arg.BatchSize,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const putBlob = `-- name: PutBlob :exec
+INSERT INTO blobs (content_hash, content)
+VALUES ($1, $2)
+ON CONFLICT (content_hash) DO NOTHING
+`
+
+type PutBlobParams struct {
+	ContentHash string
+	Content     []byte
+}
+
+func (q *Queries) PutBlob(ctx context.Context, arg PutBlobParams) error {
+	_, err := q.db.Exec(ctx, putBlob, arg.ContentHash, arg.Content)
+	return err
+}
+
+const raiseStorageAlert = `-- name: RaiseStorageAlert :execrows
+INSERT INTO workspace_storage_alerts (workspace_id, threshold)
+VALUES ($1, $2)
+ON CONFLICT (workspace_id) DO UPDATE
+SET threshold = EXCLUDED.threshold, updated_at = NOW()
+WHERE workspace_storage_alerts.threshold < EXCLUDED.threshold
+`
+
+type RaiseStorageAlertParams struct {
+	WorkspaceID pgtype.UUID
+	Threshold   int32
+}
+
+// Records that a workspace was alerted about threshold, unless it already
+// was about that threshold or a higher one.
+func (q *Queries) RaiseStorageAlert(ctx context.Context, arg RaiseStorageAlertParams) (int64, error) {
+	result, err := q.db.Exec(ctx, raiseStorageAlert, arg.WorkspaceID, arg.Threshold)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const recordAuthFailure = `-- name: RecordAuthFailure :one
+INSERT INTO auth_failures (key, failures, last_failed_at)
+VALUES ($1, 1, NOW())
+ON CONFLICT (key) DO UPDATE SET
+    failures = CASE
+        WHEN auth_failures.last_failed_at < $2 THEN 1
+        ELSE auth_failures.failures + 1
+    END,
+    last_failed_at = NOW()
+RETURNING key, failures, locked_until, last_failed_at
+`
+
+type RecordAuthFailureParams struct {
+	Key         string
+	ResetBefore pgtype.Timestamptz
+}
+
+// Failures count again from one once none happened since reset_before.
+func (q *Queries) RecordAuthFailure(ctx context.Context, arg RecordAuthFailureParams) (AuthFailure, error) {
+	row := q.db.QueryRow(ctx, recordAuthFailure, arg.Key, arg.ResetBefore)
+	var i AuthFailure
 	err := row.Scan(
-		&i.ID,
-		&i.Email,
-		&i.PasswordHash,
-		&i.Tier,
-		&i.StorageUsedBytes,
+		&i.Key,
+		&i.Failures,
+		&i.LockedUntil,
+		&i.LastFailedAt,
+	)
+	return i, err
+}
+
+const recordFileOpen = `-- name: RecordFileOpen :exec
+INSERT INTO file_opens (user_id, file_id, workspace_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, file_id) DO UPDATE SET opened_at = NOW()
+`
+
+type RecordFileOpenParams struct {
+	UserID      pgtype.UUID
+	FileID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+}
+
+func (q *Queries) RecordFileOpen(ctx context.Context, arg RecordFileOpenParams) error {
+	_, err := q.db.Exec(ctx, recordFileOpen, arg.UserID, arg.FileID, arg.WorkspaceID)
+	return err
+}
+
+const recordFileSearchFailure = `-- name: RecordFileSearchFailure :exec
+INSERT INTO file_search_failures (file_id, content_hash, last_error)
+VALUES ($1, $2, $3)
+ON CONFLICT (file_id) DO UPDATE
+SET attempts = CASE WHEN file_search_failures.content_hash = EXCLUDED.content_hash
+                    THEN file_search_failures.attempts + 1 ELSE 1 END,
+    content_hash = EXCLUDED.content_hash,
+    last_error = EXCLUDED.last_error,
+    updated_at = NOW()
+`
+
+type RecordFileSearchFailureParams struct {
+	FileID      pgtype.UUID
+	ContentHash string
+	LastError   string
+}
+
+func (q *Queries) RecordFileSearchFailure(ctx context.Context, arg RecordFileSearchFailureParams) error {
+	_, err := q.db.Exec(ctx, recordFileSearchFailure, arg.FileID, arg.ContentHash, arg.LastError)
+	return err
+}
+
+const recordGitMirrorFailure = `-- name: RecordGitMirrorFailure :exec
+UPDATE workspace_git_mirrors
+SET failure_count = failure_count + 1, last_error = $2, next_attempt_at = $3
+WHERE workspace_id = $1
+`
+
+type RecordGitMirrorFailureParams struct {
+	WorkspaceID   pgtype.UUID
+	LastError     pgtype.Text
+	NextAttemptAt pgtype.Timestamptz
+}
+
+func (q *Queries) RecordGitMirrorFailure(ctx context.Context, arg RecordGitMirrorFailureParams) error {
+	_, err := q.db.Exec(ctx, recordGitMirrorFailure, arg.WorkspaceID, arg.LastError, arg.NextAttemptAt)
+	return err
+}
+
+const recordSharePolicyCheck = `-- name: RecordSharePolicyCheck :exec
+UPDATE share_links SET policy_checked_hash = $2, policy_blocked = $3 WHERE id = $1
+`
+
+type RecordSharePolicyCheckParams struct {
+	ID                pgtype.UUID
+	PolicyCheckedHash pgtype.Text
+	PolicyBlocked     bool
+}
+
+func (q *Queries) RecordSharePolicyCheck(ctx context.Context, arg RecordSharePolicyCheckParams) error {
+	_, err := q.db.Exec(ctx, recordSharePolicyCheck, arg.ID, arg.PolicyCheckedHash, arg.PolicyBlocked)
+	return err
+}
+
+const recordWebhookFailure = `-- name: RecordWebhookFailure :exec
+UPDATE workspace_webhooks
+SET failure_count = failure_count + 1, last_error = $2, next_attempt_at = $3
+WHERE id = $1
+`
+
+type RecordWebhookFailureParams struct {
+	ID            pgtype.UUID
+	LastError     pgtype.Text
+	NextAttemptAt pgtype.Timestamptz
+}
+
+func (q *Queries) RecordWebhookFailure(ctx context.Context, arg RecordWebhookFailureParams) error {
+	_, err := q.db.Exec(ctx, recordWebhookFailure, arg.ID, arg.LastError, arg.NextAttemptAt)
+	return err
+}
+
+const removeOrganizationMember = `-- name: RemoveOrganizationMember :execrows
+DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2
+`
+
+type RemoveOrganizationMemberParams struct {
+	OrganizationID pgtype.UUID
+	UserID         pgtype.UUID
+}
+
+func (q *Queries) RemoveOrganizationMember(ctx context.Context, arg RemoveOrganizationMemberParams) (int64, error) {
+	result, err := q.db.Exec(ctx, removeOrganizationMember, arg.OrganizationID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const removeOrganizationWorkspace = `-- name: RemoveOrganizationWorkspace :execrows
+DELETE FROM organization_workspaces WHERE workspace_id = $1 AND organization_id = $2
+`
+
+type RemoveOrganizationWorkspaceParams struct {
+	WorkspaceID    pgtype.UUID
+	OrganizationID pgtype.UUID
+}
+
+func (q *Queries) RemoveOrganizationWorkspace(ctx context.Context, arg RemoveOrganizationWorkspaceParams) (int64, error) {
+	result, err := q.db.Exec(ctx, removeOrganizationWorkspace, arg.WorkspaceID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const removeWorkspaceMember = `-- name: RemoveWorkspaceMember :execrows
+DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2
+`
+
+type RemoveWorkspaceMemberParams struct {
+	WorkspaceID pgtype.UUID
+	UserID      pgtype.UUID
+}
+
+func (q *Queries) RemoveWorkspaceMember(ctx context.Context, arg RemoveWorkspaceMemberParams) (int64, error) {
+	result, err := q.db.Exec(ctx, removeWorkspaceMember, arg.WorkspaceID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const requestGitMirrorSync = `-- name: RequestGitMirrorSync :one
+UPDATE workspace_git_mirrors
+SET sync_requested_at = NOW(),
+    next_attempt_at = CASE WHEN failure_count > 0 THEN NULL ELSE next_attempt_at END
+WHERE workspace_id = $1
+RETURNING workspace_id, remote_url, branch, pull, private_key, public_key, created_by, last_event_id, last_commit, last_synced_at, sync_requested_at, failure_count, last_error, next_attempt_at, created_at, updated_at
+`
+
+// A mirror that is backing off after failures is retried at once.
+func (q *Queries) RequestGitMirrorSync(ctx context.Context, workspaceID pgtype.UUID) (WorkspaceGitMirror, error) {
+	row := q.db.QueryRow(ctx, requestGitMirrorSync, workspaceID)
+	var i WorkspaceGitMirror
+	err := row.Scan(
+		&i.WorkspaceID,
+		&i.RemoteUrl,
+		&i.Branch,
+		&i.Pull,
+		&i.PrivateKey,
+		&i.PublicKey,
+		&i.CreatedBy,
+		&i.LastEventID,
+		&i.LastCommit,
+		&i.LastSyncedAt,
+		&i.SyncRequestedAt,
+		&i.FailureCount,
+		&i.LastError,
+		&i.NextAttemptAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
 	return i, err
 }
 
-const getUserByID = `-- name: GetUserByID :one
-SELECT id, email, password_hash, tier, storage_used_bytes, created_at, updated_at FROM users WHERE id = $1
+const requestGitMirrorSyncsForRemote = `-- name: RequestGitMirrorSyncsForRemote :execrows
+UPDATE workspace_git_mirrors
+SET sync_requested_at = NOW(),
+    next_attempt_at = CASE WHEN failure_count > 0 THEN NULL ELSE next_attempt_at END
+WHERE remote_url = $1 AND branch = $2 AND pull
 `
 
-func (q *Queries) GetUserByID(ctx context.Context, id pgtype.UUID) (User, error) {
-	row := q.db.QueryRow(ctx, getUserByID, id)
+type RequestGitMirrorSyncsForRemoteParams struct {
+	RemoteUrl string
+	Branch    string
+}
+
+// A push to a branch is news to the mirrors pulling from it.
+func (q *Queries) RequestGitMirrorSyncsForRemote(ctx context.Context, arg RequestGitMirrorSyncsForRemoteParams) (int64, error) {
+	result, err := q.db.Exec(ctx, requestGitMirrorSyncsForRemote, arg.RemoteUrl, arg.Branch)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeShareLink = `-- name: RevokeShareLink :execrows
+UPDATE share_links SET revoked_at = NOW()
+WHERE id = $1 AND created_by = $2 AND revoked_at IS NULL
+`
+
+type RevokeShareLinkParams struct {
+	ID        pgtype.UUID
+	CreatedBy pgtype.UUID
+}
+
+func (q *Queries) RevokeShareLink(ctx context.Context, arg RevokeShareLinkParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeShareLink, arg.ID, arg.CreatedBy)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeUserTokens = `-- name: RevokeUserTokens :execrows
+DELETE FROM api_tokens
+WHERE user_id = $1 AND id IS DISTINCT FROM $2
+`
+
+type RevokeUserTokensParams struct {
+	UserID pgtype.UUID
+	KeepID pgtype.UUID
+}
+
+func (q *Queries) RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeUserTokens, arg.UserID, arg.KeepID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const scheduleUserDeletion = `-- name: ScheduleUserDeletion :one
+UPDATE users SET delete_after = $2, updated_at = NOW() WHERE id = $1
+RETURNING id, email, password_hash, tier, storage_used_bytes, created_at, updated_at, timezone, is_admin, storage_limit_bytes, disabled_at, delete_after, email_verified_at
+`
+
+type ScheduleUserDeletionParams struct {
+	ID          pgtype.UUID
+	DeleteAfter pgtype.Timestamptz
+}
+
+func (q *Queries) ScheduleUserDeletion(ctx context.Context, arg ScheduleUserDeletionParams) (User, error) {
+	row := q.db.QueryRow(ctx, scheduleUserDeletion, arg.ID, arg.DeleteAfter)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -419,16 +6444,97 @@ func (q *Queries) GetUserByID(ctx context.Context, id pgtype.UUID) (User, error)
 		&i.StorageUsedBytes,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Timezone,
+		&i.IsAdmin,
+		&i.StorageLimitBytes,
+		&i.DisabledAt,
+		&i.DeleteAfter,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
-const getWorkspaceByID = `-- name: GetWorkspaceByID :one
-SELECT id, user_id, name, storage_limit_bytes, storage_used_bytes, created_at, updated_at FROM workspaces WHERE id = $1
+const searchFiles = `-- name: SearchFiles :many
+SELECT s.file_id FROM file_search s
+JOIN files f ON f.id = s.file_id
+WHERE s.workspace_id = $1
+  AND s.document @@ websearch_to_tsquery('simple', $2::text)
+ORDER BY ts_rank(s.document, websearch_to_tsquery('simple', $2::text)) DESC, f.file_path
+LIMIT $3
 `
 
-func (q *Queries) GetWorkspaceByID(ctx context.Context, id pgtype.UUID) (Workspace, error) {
-	row := q.db.QueryRow(ctx, getWorkspaceByID, id)
+type SearchFilesParams struct {
+	WorkspaceID pgtype.UUID
+	Query       string
+	MaxResults  int32
+}
+
+func (q *Queries) SearchFiles(ctx context.Context, arg SearchFilesParams) ([]pgtype.UUID, error) {
+	rows, err := q.db.Query(ctx, searchFiles, arg.WorkspaceID, arg.Query, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []pgtype.UUID
+	for rows.Next() {
+		var file_id pgtype.UUID
+		if err := rows.Scan(&file_id); err != nil {
+			return nil, err
+		}
+		items = append(items, file_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setFileMeta = `-- name: SetFileMeta :exec
+INSERT INTO file_meta (file_id, meta)
+VALUES ($1, $2)
+ON CONFLICT (file_id) DO UPDATE SET meta = EXCLUDED.meta, updated_at = NOW()
+`
+
+type SetFileMetaParams struct {
+	FileID pgtype.UUID
+	Meta   []byte
+}
+
+func (q *Queries) SetFileMeta(ctx context.Context, arg SetFileMetaParams) error {
+	_, err := q.db.Exec(ctx, setFileMeta, arg.FileID, arg.Meta)
+	return err
+}
+
+const setFileNoteID = `-- name: SetFileNoteID :exec
+INSERT INTO file_note_ids (file_id, workspace_id, note_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (file_id) DO UPDATE SET note_id = EXCLUDED.note_id
+`
+
+type SetFileNoteIDParams struct {
+	FileID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	NoteID      string
+}
+
+func (q *Queries) SetFileNoteID(ctx context.Context, arg SetFileNoteIDParams) error {
+	_, err := q.db.Exec(ctx, setFileNoteID, arg.FileID, arg.WorkspaceID, arg.NoteID)
+	return err
+}
+
+const setWorkspaceArchived = `-- name: SetWorkspaceArchived :one
+UPDATE workspaces SET archived_at = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, user_id, name, storage_limit_bytes, storage_used_bytes, created_at, updated_at, file_count, archived_at, icon, color, description, revision
+`
+
+type SetWorkspaceArchivedParams struct {
+	ID         pgtype.UUID
+	ArchivedAt pgtype.Timestamptz
+}
+
+func (q *Queries) SetWorkspaceArchived(ctx context.Context, arg SetWorkspaceArchivedParams) (Workspace, error) {
+	row := q.db.QueryRow(ctx, setWorkspaceArchived, arg.ID, arg.ArchivedAt)
 	var i Workspace
 	err := row.Scan(
 		&i.ID,
@@ -438,118 +6544,227 @@ func (q *Queries) GetWorkspaceByID(ctx context.Context, id pgtype.UUID) (Workspa
 		&i.StorageUsedBytes,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.FileCount,
+		&i.ArchivedAt,
+		&i.Icon,
+		&i.Color,
+		&i.Description,
+		&i.Revision,
 	)
 	return i, err
 }
 
-const getWorkspaceStorageUsage = `-- name: GetWorkspaceStorageUsage :one
-SELECT 
-    w.storage_limit_bytes,
-    w.storage_used_bytes,
-    COUNT(f.id) as file_count,
-    COALESCE(SUM(f.size_bytes), 0) as actual_storage_used
-FROM workspaces w
-LEFT JOIN files f ON w.id = f.workspace_id
-WHERE w.id = $1
-GROUP BY w.id, w.storage_limit_bytes, w.storage_used_bytes
+const setWorkspaceSortOrder = `-- name: SetWorkspaceSortOrder :exec
+INSERT INTO workspace_sort_orders (user_id, workspace_id, sort_order)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, workspace_id) DO UPDATE SET sort_order = EXCLUDED.sort_order
 `
 
-type GetWorkspaceStorageUsageRow struct {
+type SetWorkspaceSortOrderParams struct {
+	UserID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	SortOrder   int32
+}
+
+func (q *Queries) SetWorkspaceSortOrder(ctx context.Context, arg SetWorkspaceSortOrderParams) error {
+	_, err := q.db.Exec(ctx, setWorkspaceSortOrder, arg.UserID, arg.WorkspaceID, arg.SortOrder)
+	return err
+}
+
+const setWorkspaceStorageLimits = `-- name: SetWorkspaceStorageLimits :exec
+UPDATE workspaces SET storage_limit_bytes = $2 WHERE user_id = $1
+`
+
+type SetWorkspaceStorageLimitsParams struct {
+	UserID            pgtype.UUID
 	StorageLimitBytes int64
-	StorageUsedBytes  pgtype.Int8
-	FileCount         int64
-	ActualStorageUsed interface{}
 }
 
-func (q *Queries) GetWorkspaceStorageUsage(ctx context.Context, id pgtype.UUID) (GetWorkspaceStorageUsageRow, error) {
-	row := q.db.QueryRow(ctx, getWorkspaceStorageUsage, id)
-	var i GetWorkspaceStorageUsageRow
+func (q *Queries) SetWorkspaceStorageLimits(ctx context.Context, arg SetWorkspaceStorageLimitsParams) error {
+	_, err := q.db.Exec(ctx, setWorkspaceStorageLimits, arg.UserID, arg.StorageLimitBytes)
+	return err
+}
+
+const summarizeSyncOperations = `-- name: SummarizeSyncOperations :many
+SELECT operation_type, status, COUNT(*) AS operation_count, MIN(created_at)::timestamptz AS oldest
+FROM sync_operations
+GROUP BY operation_type, status
+ORDER BY operation_type, status
+`
+
+type SummarizeSyncOperationsRow struct {
+	OperationType  string
+	Status         string
+	OperationCount int64
+	Oldest         pgtype.Timestamptz
+}
+
+func (q *Queries) SummarizeSyncOperations(ctx context.Context) ([]SummarizeSyncOperationsRow, error) {
+	rows, err := q.db.Query(ctx, summarizeSyncOperations)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SummarizeSyncOperationsRow
+	for rows.Next() {
+		var i SummarizeSyncOperationsRow
+		if err := rows.Scan(
+			&i.OperationType,
+			&i.Status,
+			&i.OperationCount,
+			&i.Oldest,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const touchCalendarFeed = `-- name: TouchCalendarFeed :exec
+UPDATE calendar_feeds SET last_fetched_at = NOW() WHERE id = $1
+`
+
+func (q *Queries) TouchCalendarFeed(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, touchCalendarFeed, id)
+	return err
+}
+
+const touchOAuthIdentity = `-- name: TouchOAuthIdentity :exec
+UPDATE oauth_identities SET email = $2, last_login_at = NOW() WHERE id = $1
+`
+
+type TouchOAuthIdentityParams struct {
+	ID    pgtype.UUID
+	Email pgtype.Text
+}
+
+func (q *Queries) TouchOAuthIdentity(ctx context.Context, arg TouchOAuthIdentityParams) error {
+	_, err := q.db.Exec(ctx, touchOAuthIdentity, arg.ID, arg.Email)
+	return err
+}
+
+const touchPasskey = `-- name: TouchPasskey :exec
+UPDATE passkeys SET sign_count = $2, last_used_at = NOW() WHERE id = $1
+`
+
+type TouchPasskeyParams struct {
+	ID        pgtype.UUID
+	SignCount int64
+}
+
+func (q *Queries) TouchPasskey(ctx context.Context, arg TouchPasskeyParams) error {
+	_, err := q.db.Exec(ctx, touchPasskey, arg.ID, arg.SignCount)
+	return err
+}
+
+const unpinFile = `-- name: UnpinFile :execrows
+DELETE FROM file_pins WHERE user_id = $1 AND file_id = $2
+`
+
+type UnpinFileParams struct {
+	UserID pgtype.UUID
+	FileID pgtype.UUID
+}
+
+func (q *Queries) UnpinFile(ctx context.Context, arg UnpinFileParams) (int64, error) {
+	result, err := q.db.Exec(ctx, unpinFile, arg.UserID, arg.FileID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateOrganization = `-- name: UpdateOrganization :one
+UPDATE organizations SET
+    name = COALESCE($1, name),
+    billing_email = COALESCE($2, billing_email),
+    updated_at = NOW()
+WHERE id = $3
+RETURNING id, name, billing_email, tier, seat_storage_bytes, storage_limit_bytes, created_at, updated_at
+`
+
+type UpdateOrganizationParams struct {
+	Name         pgtype.Text
+	BillingEmail pgtype.Text
+	ID           pgtype.UUID
+}
+
+func (q *Queries) UpdateOrganization(ctx context.Context, arg UpdateOrganizationParams) (Organization, error) {
+	row := q.db.QueryRow(ctx, updateOrganization, arg.Name, arg.BillingEmail, arg.ID)
+	var i Organization
 	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.BillingEmail,
+		&i.Tier,
+		&i.SeatStorageBytes,
 		&i.StorageLimitBytes,
-		&i.StorageUsedBytes,
-		&i.FileCount,
-		&i.ActualStorageUsed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
 
-const getWorkspacesByUser = `-- name: GetWorkspacesByUser :many
-SELECT id, user_id, name, storage_limit_bytes, storage_used_bytes, created_at, updated_at FROM workspaces WHERE user_id = $1 ORDER BY created_at DESC
+const updateOrganizationAdmin = `-- name: UpdateOrganizationAdmin :one
+UPDATE organizations SET
+    tier = COALESCE($1, tier),
+    seat_storage_bytes = COALESCE($2, seat_storage_bytes),
+    storage_limit_bytes = CASE WHEN $3::boolean THEN $4 ELSE storage_limit_bytes END,
+    updated_at = NOW()
+WHERE id = $5
+RETURNING id, name, billing_email, tier, seat_storage_bytes, storage_limit_bytes, created_at, updated_at
 `
 
-func (q *Queries) GetWorkspacesByUser(ctx context.Context, userID pgtype.UUID) ([]Workspace, error) {
-	rows, err := q.db.Query(ctx, getWorkspacesByUser, userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []Workspace
-	for rows.Next() {
-		var i Workspace
-		if err := rows.Scan(
-			&i.ID,
-			&i.UserID,
-			&i.Name,
-			&i.StorageLimitBytes,
-			&i.StorageUsedBytes,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+type UpdateOrganizationAdminParams struct {
+	Tier              NullUserTier
+	SeatStorageBytes  pgtype.Int8
+	SetStorageLimit   bool
+	StorageLimitBytes pgtype.Int8
+	ID                pgtype.UUID
 }
 
-const listFiles = `-- name: ListFiles :many
-SELECT id, workspace_id, file_path, content_hash, size_bytes, mime_type, last_modified, updated_at
-FROM files 
-WHERE workspace_id = $1 
-ORDER BY file_path
+func (q *Queries) UpdateOrganizationAdmin(ctx context.Context, arg UpdateOrganizationAdminParams) (Organization, error) {
+	row := q.db.QueryRow(ctx, updateOrganizationAdmin,
+		arg.Tier,
+		arg.SeatStorageBytes,
+		arg.SetStorageLimit,
+		arg.StorageLimitBytes,
+		arg.ID,
+	)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.BillingEmail,
+		&i.Tier,
+		&i.SeatStorageBytes,
+		&i.StorageLimitBytes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updateOrganizationMemberRole = `-- name: UpdateOrganizationMemberRole :execrows
+UPDATE organization_members SET role = $3 WHERE organization_id = $1 AND user_id = $2
 `
 
-type ListFilesRow struct {
-	ID           pgtype.UUID
-	WorkspaceID  pgtype.UUID
-	FilePath     string
-	ContentHash  string
-	SizeBytes    int64
-	MimeType     pgtype.Text
-	LastModified pgtype.Timestamptz
-	UpdatedAt    pgtype.Timestamptz
+type UpdateOrganizationMemberRoleParams struct {
+	OrganizationID pgtype.UUID
+	UserID         pgtype.UUID
+	Role           OrganizationRole
 }
 
-func (q *Queries) ListFiles(ctx context.Context, workspaceID pgtype.UUID) ([]ListFilesRow, error) {
-	rows, err := q.db.Query(ctx, listFiles, workspaceID)
+func (q *Queries) UpdateOrganizationMemberRole(ctx context.Context, arg UpdateOrganizationMemberRoleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateOrganizationMemberRole, arg.OrganizationID, arg.UserID, arg.Role)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []ListFilesRow
-	for rows.Next() {
-		var i ListFilesRow
-		if err := rows.Scan(
-			&i.ID,
-			&i.WorkspaceID,
-			&i.FilePath,
-			&i.ContentHash,
-			&i.SizeBytes,
-			&i.MimeType,
-			&i.LastModified,
-			&i.UpdatedAt,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+		return 0, err
 	}
-	return items, nil
+	return result.RowsAffected(), nil
 }
 
 const updateSyncOperationStatus = `-- name: UpdateSyncOperationStatus :exec
@@ -570,14 +6785,150 @@ func (q *Queries) UpdateSyncOperationStatus(ctx context.Context, arg UpdateSyncO
 }
 
 const updateTokenLastUsed = `-- name: UpdateTokenLastUsed :exec
-UPDATE api_tokens SET last_used_at = NOW() WHERE id = $1
+UPDATE api_tokens
+SET last_used_at = NOW(),
+    client_name = COALESCE($1, client_name),
+    client_version = COALESCE($2, client_version),
+    last_ip = COALESCE($3, last_ip),
+    user_agent = COALESCE($4, user_agent)
+WHERE id = $5
+`
+
+type UpdateTokenLastUsedParams struct {
+	ClientName    pgtype.Text
+	ClientVersion pgtype.Text
+	LastIp        pgtype.Text
+	UserAgent     pgtype.Text
+	ID            pgtype.UUID
+}
+
+func (q *Queries) UpdateTokenLastUsed(ctx context.Context, arg UpdateTokenLastUsedParams) error {
+	_, err := q.db.Exec(ctx, updateTokenLastUsed,
+		arg.ClientName,
+		arg.ClientVersion,
+		arg.LastIp,
+		arg.UserAgent,
+		arg.ID,
+	)
+	return err
+}
+
+const updateUserAdmin = `-- name: UpdateUserAdmin :one
+UPDATE users SET
+    tier = COALESCE($1, tier),
+    is_admin = COALESCE($2, is_admin),
+    storage_limit_bytes = CASE WHEN $3::boolean THEN $4 ELSE storage_limit_bytes END,
+    disabled_at = CASE
+        WHEN $5::boolean IS NULL THEN disabled_at
+        WHEN $5::boolean THEN COALESCE(disabled_at, NOW())
+    END,
+    updated_at = NOW()
+WHERE id = $6
+RETURNING id, email, password_hash, tier, storage_used_bytes, created_at, updated_at, timezone, is_admin, storage_limit_bytes, disabled_at, delete_after, email_verified_at
+`
+
+type UpdateUserAdminParams struct {
+	Tier              NullUserTier
+	IsAdmin           pgtype.Bool
+	SetStorageLimit   bool
+	StorageLimitBytes pgtype.Int8
+	Disabled          pgtype.Bool
+	ID                pgtype.UUID
+}
+
+func (q *Queries) UpdateUserAdmin(ctx context.Context, arg UpdateUserAdminParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserAdmin,
+		arg.Tier,
+		arg.IsAdmin,
+		arg.SetStorageLimit,
+		arg.StorageLimitBytes,
+		arg.Disabled,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Tier,
+		&i.StorageUsedBytes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Timezone,
+		&i.IsAdmin,
+		&i.StorageLimitBytes,
+		&i.DisabledAt,
+		&i.DeleteAfter,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1
 `
 
-func (q *Queries) UpdateTokenLastUsed(ctx context.Context, id pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, updateTokenLastUsed, id)
+type UpdateUserPasswordParams struct {
+	ID           pgtype.UUID
+	PasswordHash string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, updateUserPassword, arg.ID, arg.PasswordHash)
 	return err
 }
 
+const updateUserPreferences = `-- name: UpdateUserPreferences :one
+INSERT INTO user_preferences (user_id, locale, default_workspace_id, notifications, editor)
+VALUES (
+    $1,
+    COALESCE($2, ''),
+    $3,
+    $4,
+    COALESCE($5, '{}')
+)
+ON CONFLICT (user_id) DO UPDATE SET
+    locale = COALESCE($2, user_preferences.locale),
+    default_workspace_id = CASE
+        WHEN $6::boolean THEN $3
+        ELSE user_preferences.default_workspace_id
+    END,
+    notifications = user_preferences.notifications || $4,
+    editor = COALESCE($5, user_preferences.editor),
+    updated_at = NOW()
+RETURNING user_id, locale, default_workspace_id, notifications, editor, updated_at
+`
+
+type UpdateUserPreferencesParams struct {
+	UserID              pgtype.UUID
+	Locale              pgtype.Text
+	DefaultWorkspaceID  pgtype.UUID
+	Notifications       []byte
+	Editor              []byte
+	SetDefaultWorkspace bool
+}
+
+func (q *Queries) UpdateUserPreferences(ctx context.Context, arg UpdateUserPreferencesParams) (UserPreference, error) {
+	row := q.db.QueryRow(ctx, updateUserPreferences,
+		arg.UserID,
+		arg.Locale,
+		arg.DefaultWorkspaceID,
+		arg.Notifications,
+		arg.Editor,
+		arg.SetDefaultWorkspace,
+	)
+	var i UserPreference
+	err := row.Scan(
+		&i.UserID,
+		&i.Locale,
+		&i.DefaultWorkspaceID,
+		&i.Notifications,
+		&i.Editor,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const updateUserStorageUsed = `-- name: UpdateUserStorageUsed :exec
 UPDATE users SET storage_used_bytes = $2, updated_at = NOW() WHERE id = $1
 `
@@ -592,39 +6943,150 @@ func (q *Queries) UpdateUserStorageUsed(ctx context.Context, arg UpdateUserStora
 	return err
 }
 
-const updateWorkspaceStorageUsed = `-- name: UpdateWorkspaceStorageUsed :exec
-UPDATE workspaces SET storage_used_bytes = $2, updated_at = NOW() WHERE id = $1
+const updateUserTimezone = `-- name: UpdateUserTimezone :one
+UPDATE users SET timezone = $2, updated_at = NOW() WHERE id = $1
+RETURNING id, email, password_hash, tier, storage_used_bytes, created_at, updated_at, timezone, is_admin, storage_limit_bytes, disabled_at, delete_after, email_verified_at
 `
 
-type UpdateWorkspaceStorageUsedParams struct {
-	ID               pgtype.UUID
-	StorageUsedBytes pgtype.Int8
+type UpdateUserTimezoneParams struct {
+	ID       pgtype.UUID
+	Timezone string
 }
 
-func (q *Queries) UpdateWorkspaceStorageUsed(ctx context.Context, arg UpdateWorkspaceStorageUsedParams) error {
-	_, err := q.db.Exec(ctx, updateWorkspaceStorageUsed, arg.ID, arg.StorageUsedBytes)
-	return err
+func (q *Queries) UpdateUserTimezone(ctx context.Context, arg UpdateUserTimezoneParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserTimezone, arg.ID, arg.Timezone)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Tier,
+		&i.StorageUsedBytes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Timezone,
+		&i.IsAdmin,
+		&i.StorageLimitBytes,
+		&i.DisabledAt,
+		&i.DeleteAfter,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const updateWorkspaceDisplay = `-- name: UpdateWorkspaceDisplay :one
+UPDATE workspaces
+SET icon = COALESCE($1, icon),
+    color = COALESCE($2, color),
+    description = COALESCE($3, description)
+WHERE id = $4
+RETURNING id, user_id, name, storage_limit_bytes, storage_used_bytes, created_at, updated_at, file_count, archived_at, icon, color, description, revision
+`
+
+type UpdateWorkspaceDisplayParams struct {
+	Icon        pgtype.Text
+	Color       pgtype.Text
+	Description pgtype.Text
+	ID          pgtype.UUID
+}
+
+func (q *Queries) UpdateWorkspaceDisplay(ctx context.Context, arg UpdateWorkspaceDisplayParams) (Workspace, error) {
+	row := q.db.QueryRow(ctx, updateWorkspaceDisplay,
+		arg.Icon,
+		arg.Color,
+		arg.Description,
+		arg.ID,
+	)
+	var i Workspace
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.StorageLimitBytes,
+		&i.StorageUsedBytes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FileCount,
+		&i.ArchivedAt,
+		&i.Icon,
+		&i.Color,
+		&i.Description,
+		&i.Revision,
+	)
+	return i, err
+}
+
+const updateWorkspaceMemberRole = `-- name: UpdateWorkspaceMemberRole :one
+UPDATE workspace_members SET role = $3, updated_at = NOW()
+WHERE workspace_id = $1 AND user_id = $2
+RETURNING workspace_id, user_id, role, invited_by, created_at, updated_at
+`
+
+type UpdateWorkspaceMemberRoleParams struct {
+	WorkspaceID pgtype.UUID
+	UserID      pgtype.UUID
+	Role        WorkspaceRole
+}
+
+func (q *Queries) UpdateWorkspaceMemberRole(ctx context.Context, arg UpdateWorkspaceMemberRoleParams) (WorkspaceMember, error) {
+	row := q.db.QueryRow(ctx, updateWorkspaceMemberRole, arg.WorkspaceID, arg.UserID, arg.Role)
+	var i WorkspaceMember
+	err := row.Scan(
+		&i.WorkspaceID,
+		&i.UserID,
+		&i.Role,
+		&i.InvitedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const upsertDailyNoteSettings = `-- name: UpsertDailyNoteSettings :one
+INSERT INTO daily_note_settings (workspace_id, path_pattern, template_name)
+VALUES ($1, $2, $3)
+ON CONFLICT (workspace_id) DO UPDATE
+SET path_pattern = EXCLUDED.path_pattern,
+    template_name = EXCLUDED.template_name,
+    updated_at = NOW()
+RETURNING workspace_id, path_pattern, template_name, updated_at
+`
+
+type UpsertDailyNoteSettingsParams struct {
+	WorkspaceID  pgtype.UUID
+	PathPattern  string
+	TemplateName string
+}
+
+func (q *Queries) UpsertDailyNoteSettings(ctx context.Context, arg UpsertDailyNoteSettingsParams) (DailyNoteSetting, error) {
+	row := q.db.QueryRow(ctx, upsertDailyNoteSettings, arg.WorkspaceID, arg.PathPattern, arg.TemplateName)
+	var i DailyNoteSetting
+	err := row.Scan(
+		&i.WorkspaceID,
+		&i.PathPattern,
+		&i.TemplateName,
+		&i.UpdatedAt,
+	)
+	return i, err
 }
 
 const upsertFile = `-- name: UpsertFile :one
-INSERT INTO files (workspace_id, file_path, content_hash, content, size_bytes, mime_type, last_modified)
-VALUES ($1, $2, $3, $4, $5, $6, $7)
+INSERT INTO files (workspace_id, file_path, content_hash, size_bytes, mime_type, last_modified)
+VALUES ($1, $2, $3, $4, $5, $6)
 ON CONFLICT (workspace_id, file_path) 
 DO UPDATE SET 
     content_hash = EXCLUDED.content_hash,
-    content = EXCLUDED.content,
     size_bytes = EXCLUDED.size_bytes,
     mime_type = EXCLUDED.mime_type,
     last_modified = EXCLUDED.last_modified,
     updated_at = NOW()
-RETURNING id, workspace_id, file_path, content_hash, content, size_bytes, mime_type, last_modified, created_at, updated_at
+RETURNING id, workspace_id, file_path, content_hash, size_bytes, mime_type, last_modified, created_at, updated_at
 `
 
 type UpsertFileParams struct {
 	WorkspaceID  pgtype.UUID
 	FilePath     string
 	ContentHash  string
-	Content      []byte
 	SizeBytes    int64
 	MimeType     pgtype.Text
 	LastModified pgtype.Timestamptz
@@ -635,7 +7097,6 @@ func (q *Queries) UpsertFile(ctx context.Context, arg UpsertFileParams) (File, e
 		arg.WorkspaceID,
 		arg.FilePath,
 		arg.ContentHash,
-		arg.Content,
 		arg.SizeBytes,
 		arg.MimeType,
 		arg.LastModified,
@@ -646,7 +7107,6 @@ func (q *Queries) UpsertFile(ctx context.Context, arg UpsertFileParams) (File, e
 		&i.WorkspaceID,
 		&i.FilePath,
 		&i.ContentHash,
-		&i.Content,
 		&i.SizeBytes,
 		&i.MimeType,
 		&i.LastModified,
@@ -657,23 +7117,25 @@ func (q *Queries) UpsertFile(ctx context.Context, arg UpsertFileParams) (File, e
 }
 
 const upsertFileMetadata = `-- name: UpsertFileMetadata :exec
-INSERT INTO file_metadata (file_id, format, parsed_blocks, properties, word_count)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO file_metadata (file_id, format, parsed_blocks, properties, word_count, parser_version)
+VALUES ($1, $2, $3, $4, $5, $6)
 ON CONFLICT (file_id) 
 DO UPDATE SET 
     format = EXCLUDED.format,
     parsed_blocks = EXCLUDED.parsed_blocks,
     properties = EXCLUDED.properties,
     word_count = EXCLUDED.word_count,
+    parser_version = EXCLUDED.parser_version,
     last_parsed = NOW()
 `
 
 type UpsertFileMetadataParams struct {
-	FileID       pgtype.UUID
-	Format       string
-	ParsedBlocks []byte
-	Properties   []byte
-	WordCount    pgtype.Int4
+	FileID        pgtype.UUID
+	Format        string
+	ParsedBlocks  []byte
+	Properties    []byte
+	WordCount     pgtype.Int4
+	ParserVersion int32
 }
 
 func (q *Queries) UpsertFileMetadata(ctx context.Context, arg UpsertFileMetadataParams) error {
@@ -683,6 +7145,172 @@ func (q *Queries) UpsertFileMetadata(ctx context.Context, arg UpsertFileMetadata
 		arg.ParsedBlocks,
 		arg.Properties,
 		arg.WordCount,
+		arg.ParserVersion,
+	)
+	return err
+}
+
+const upsertFileSearch = `-- name: UpsertFileSearch :exec
+INSERT INTO file_search (file_id, workspace_id, content_hash, document)
+VALUES ($1, $2, $3,
+        setweight(to_tsvector('simple', translate($4::text, '/._-', '    ')), 'A') ||
+        setweight(to_tsvector('simple', $5::text), 'B'))
+ON CONFLICT (file_id) DO UPDATE SET content_hash = EXCLUDED.content_hash, document = EXCLUDED.document
+`
+
+type UpsertFileSearchParams struct {
+	FileID      pgtype.UUID
+	WorkspaceID pgtype.UUID
+	ContentHash string
+	FilePath    string
+	Content     string
+}
+
+func (q *Queries) UpsertFileSearch(ctx context.Context, arg UpsertFileSearchParams) error {
+	_, err := q.db.Exec(ctx, upsertFileSearch,
+		arg.FileID,
+		arg.WorkspaceID,
+		arg.ContentHash,
+		arg.FilePath,
+		arg.Content,
+	)
+	return err
+}
+
+const upsertFileTask = `-- name: UpsertFileTask :exec
+INSERT INTO file_tasks (file_id, task_key, workspace_id, title, done, deadline)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (file_id, task_key) DO UPDATE SET
+    title = EXCLUDED.title,
+    done = EXCLUDED.done,
+    deadline = EXCLUDED.deadline,
+    reminded_at = CASE WHEN file_tasks.deadline IS DISTINCT FROM EXCLUDED.deadline
+                       THEN NULL ELSE file_tasks.reminded_at END
+`
+
+type UpsertFileTaskParams struct {
+	FileID      pgtype.UUID
+	TaskKey     string
+	WorkspaceID pgtype.UUID
+	Title       string
+	Done        bool
+	Deadline    pgtype.Timestamptz
+}
+
+func (q *Queries) UpsertFileTask(ctx context.Context, arg UpsertFileTaskParams) error {
+	_, err := q.db.Exec(ctx, upsertFileTask,
+		arg.FileID,
+		arg.TaskKey,
+		arg.WorkspaceID,
+		arg.Title,
+		arg.Done,
+		arg.Deadline,
 	)
 	return err
 }
+
+const upsertGitMirror = `-- name: UpsertGitMirror :one
+INSERT INTO workspace_git_mirrors (workspace_id, remote_url, branch, pull, private_key, public_key, created_by)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (workspace_id) DO UPDATE SET
+    remote_url = EXCLUDED.remote_url,
+    branch = EXCLUDED.branch,
+    pull = EXCLUDED.pull,
+    last_commit = CASE WHEN workspace_git_mirrors.remote_url = EXCLUDED.remote_url AND workspace_git_mirrors.branch = EXCLUDED.branch
+                       THEN workspace_git_mirrors.last_commit END,
+    sync_requested_at = NOW(),
+    failure_count = 0,
+    last_error = NULL,
+    next_attempt_at = NULL,
+    updated_at = NOW()
+RETURNING workspace_id, remote_url, branch, pull, private_key, public_key, created_by, last_event_id, last_commit, last_synced_at, sync_requested_at, failure_count, last_error, next_attempt_at, created_at, updated_at
+`
+
+type UpsertGitMirrorParams struct {
+	WorkspaceID pgtype.UUID
+	RemoteUrl   string
+	Branch      string
+	Pull        bool
+	PrivateKey  string
+	PublicKey   string
+	CreatedBy   pgtype.UUID
+}
+
+// Reconfiguring keeps the deploy key. Pointing the mirror elsewhere
+// forgets the last commit, so the next sync starts over with that remote.
+func (q *Queries) UpsertGitMirror(ctx context.Context, arg UpsertGitMirrorParams) (WorkspaceGitMirror, error) {
+	row := q.db.QueryRow(ctx, upsertGitMirror,
+		arg.WorkspaceID,
+		arg.RemoteUrl,
+		arg.Branch,
+		arg.Pull,
+		arg.PrivateKey,
+		arg.PublicKey,
+		arg.CreatedBy,
+	)
+	var i WorkspaceGitMirror
+	err := row.Scan(
+		&i.WorkspaceID,
+		&i.RemoteUrl,
+		&i.Branch,
+		&i.Pull,
+		&i.PrivateKey,
+		&i.PublicKey,
+		&i.CreatedBy,
+		&i.LastEventID,
+		&i.LastCommit,
+		&i.LastSyncedAt,
+		&i.SyncRequestedAt,
+		&i.FailureCount,
+		&i.LastError,
+		&i.NextAttemptAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const upsertPublishedSite = `-- name: UpsertPublishedSite :one
+INSERT INTO published_sites (workspace_id, slug, include_folders, exclude_folders, require_flag, published_by)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (workspace_id) DO UPDATE
+SET slug = EXCLUDED.slug,
+    include_folders = EXCLUDED.include_folders,
+    exclude_folders = EXCLUDED.exclude_folders,
+    require_flag = EXCLUDED.require_flag,
+    published_by = EXCLUDED.published_by,
+    updated_at = NOW()
+RETURNING workspace_id, slug, include_folders, exclude_folders, require_flag, published_by, created_at, updated_at
+`
+
+type UpsertPublishedSiteParams struct {
+	WorkspaceID    pgtype.UUID
+	Slug           string
+	IncludeFolders []string
+	ExcludeFolders []string
+	RequireFlag    bool
+	PublishedBy    pgtype.UUID
+}
+
+func (q *Queries) UpsertPublishedSite(ctx context.Context, arg UpsertPublishedSiteParams) (PublishedSite, error) {
+	row := q.db.QueryRow(ctx, upsertPublishedSite,
+		arg.WorkspaceID,
+		arg.Slug,
+		arg.IncludeFolders,
+		arg.ExcludeFolders,
+		arg.RequireFlag,
+		arg.PublishedBy,
+	)
+	var i PublishedSite
+	err := row.Scan(
+		&i.WorkspaceID,
+		&i.Slug,
+		&i.IncludeFolders,
+		&i.ExcludeFolders,
+		&i.RequireFlag,
+		&i.PublishedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
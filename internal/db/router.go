@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type replicaKey struct{}
+
+// PreferReplica marks ctx so that queries run with it may be answered by a
+// read replica. Only use it for reads whose result may lag the primary by
+// the replication delay: a client listing files right after an upload on
+// another connection can miss it for that long.
+func PreferReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaKey{}, true)
+}
+
+func prefersReplica(ctx context.Context) bool {
+	preferred, _ := ctx.Value(replicaKey{}).(bool)
+	return preferred
+}
+
+// Router is a DBTX that sends queries run with a PreferReplica context to
+// a read replica and everything else to the primary. Exec always goes to
+// the primary, so a write can never reach the replica, whatever its
+// context. Transactions begin on the primary connection and bypass the
+// router altogether.
+type Router struct {
+	primary DBTX
+	replica DBTX
+}
+
+// NewRouter routes between primary and replica. A nil replica sends every
+// query to the primary.
+func NewRouter(primary, replica DBTX) *Router {
+	if replica == nil {
+		replica = primary
+	}
+	return &Router{primary: primary, replica: replica}
+}
+
+func (r *Router) route(ctx context.Context) DBTX {
+	if prefersReplica(ctx) {
+		return r.replica
+	}
+	return r.primary
+}
+
+func (r *Router) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return r.primary.Exec(ctx, sql, args...)
+}
+
+func (r *Router) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return r.route(ctx).Query(ctx, sql, args...)
+}
+
+func (r *Router) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return r.route(ctx).QueryRow(ctx, sql, args...)
+}
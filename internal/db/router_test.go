@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingDB records how many statements reach it.
+type countingDB struct {
+	execs, queries int
+}
+
+func (c *countingDB) Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error) {
+	c.execs++
+	return pgconn.CommandTag{}, nil
+}
+
+func (c *countingDB) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	c.queries++
+	return nil, nil
+}
+
+func (c *countingDB) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	c.queries++
+	return nil
+}
+
+func TestRouter(t *testing.T) {
+	primary, replica := &countingDB{}, &countingDB{}
+	router := NewRouter(primary, replica)
+	ctx := context.Background()
+
+	router.QueryRow(ctx, "SELECT 1")
+	router.Query(PreferReplica(ctx), "SELECT 1")
+	router.QueryRow(PreferReplica(ctx), "SELECT 1")
+	router.Exec(PreferReplica(ctx), "DELETE FROM files")
+
+	assert.Equal(t, 1, primary.queries)
+	assert.Equal(t, 1, primary.execs, "writes never reach the replica")
+	assert.Equal(t, 2, replica.queries)
+	assert.Zero(t, replica.execs)
+}
+
+func TestRouter_WithoutReplica(t *testing.T) {
+	primary := &countingDB{}
+	router := NewRouter(primary, nil)
+
+	router.Query(PreferReplica(context.Background()), "SELECT 1")
+
+	assert.Equal(t, 1, primary.queries)
+}
@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+// DeleteAccountRequest confirms an account deletion with the account's
+// email address.
+type DeleteAccountRequest struct {
+	Email string `json:"email"`
+}
+
+// AccountDeletion is a scheduled account deletion.
+type AccountDeletion struct {
+	DeleteAfter time.Time `json:"delete_after"`
+}
+
+// AccountExport is account.json in an account export: the account and
+// what is attached to it. The content of the workspaces the user owns is
+// exported next to it.
+type AccountExport struct {
+	ExportedAt  time.Time       `json:"exported_at"`
+	User        User            `json:"user"`
+	Preferences *Preferences    `json:"preferences"`
+	Identities  []OAuthIdentity `json:"identities"`
+	Devices     []Device        `json:"devices"`
+	Workspaces  []Workspace     `json:"workspaces"`
+	ShareLinks  []ShareLink     `json:"share_links"`
+}
+
+// WorkspaceExport is workspace.json in an account export, next to the
+// workspace's files.
+type WorkspaceExport struct {
+	Workspace Workspace      `json:"workspace"`
+	Files     []ExportedFile `json:"files"`
+}
+
+type ExportedFile struct {
+	Path         string    `json:"path"`
+	ContentHash  string    `json:"content_hash"`
+	SizeBytes    int64     `json:"size_bytes"`
+	MimeType     string    `json:"mime_type"`
+	LastModified time.Time `json:"last_modified"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AdminUser is an account as operators see it, with the usage of the
+// workspaces it owns. StorageLimitBytes is the limit of each of those
+// workspaces: the tier's unless CustomStorageLimit.
+type AdminUser struct {
+	ID                 uuid.UUID  `json:"id"`
+	Email              string     `json:"email"`
+	Tier               UserTier   `json:"tier"`
+	IsAdmin            bool       `json:"is_admin"`
+	StorageLimitBytes  int64      `json:"storage_limit_bytes"`
+	CustomStorageLimit bool       `json:"custom_storage_limit"`
+	DisabledAt         *time.Time `json:"disabled_at,omitempty"`
+	WorkspaceCount     int64      `json:"workspace_count"`
+	FileCount          int64      `json:"file_count"`
+	StorageUsedBytes   int64      `json:"storage_used_bytes"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+// AdminUserPage is one page of users by email. Pass NextAfter as after to
+// get the next page.
+type AdminUserPage struct {
+	Users     []AdminUser `json:"users"`
+	NextAfter string      `json:"next_after,omitempty"`
+}
+
+// AdminUserUpdate changes the fields that are set. A StorageLimitBytes of
+// 0 returns the user to the tier's limit.
+type AdminUserUpdate struct {
+	Tier              *UserTier `json:"tier,omitempty"`
+	StorageLimitBytes *int64    `json:"storage_limit_bytes,omitempty"`
+	Disabled          *bool     `json:"disabled,omitempty"`
+	IsAdmin           *bool     `json:"is_admin,omitempty"`
+}
+
+func (u AdminUserUpdate) Validate() error {
+	if u.Tier != nil {
+		switch *u.Tier {
+		case TierFree, TierPremium, TierEnterprise:
+		default:
+			return fmt.Errorf("invalid tier: %q", *u.Tier)
+		}
+	}
+	if u.StorageLimitBytes != nil && *u.StorageLimitBytes < 0 {
+		return fmt.Errorf("invalid storage_limit_bytes: must not be negative")
+	}
+	if u.Tier == nil && u.StorageLimitBytes == nil && u.Disabled == nil && u.IsAdmin == nil {
+		return fmt.Errorf("invalid update: no fields to change")
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package domain
+
+const (
+	// DefaultAgendaDays is how many days an agenda covers when the request
+	// gives no end.
+	DefaultAgendaDays = 7
+	// MaxAgendaDays caps the range of one agenda request.
+	MaxAgendaDays = 366
+	// MaxAgendaItems caps the items of one agenda response; Truncated is
+	// set when there were more.
+	MaxAgendaItems = 5000
+)
+
+// AgendaItem is a scheduled item, deadline or plain timestamp found in a
+// note. Kind is "scheduled", "deadline" or "timestamp"; Time is "15:04",
+// absent for all-day items. Like the notes, times have no zone.
+type AgendaItem struct {
+	Kind     string `json:"kind"`
+	Title    string `json:"title"`
+	Keyword  string `json:"keyword,omitempty"`
+	Done     bool   `json:"done"`
+	Time     string `json:"time,omitempty"`
+	FilePath string `json:"file_path"`
+	Line     int32  `json:"line"`
+}
+
+type AgendaDay struct {
+	Date  string       `json:"date"`
+	Items []AgendaItem `json:"items"`
+}
+
+// Agenda lists the days from From to To, both YYYY-MM-DD and inclusive,
+// that have items, all-day items first.
+type Agenda struct {
+	From      string      `json:"from"`
+	To        string      `json:"to"`
+	Days      []AgendaDay `json:"days"`
+	Truncated bool        `json:"truncated,omitempty"`
+}
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AuthSessionOAuthState = "oauth_state"
+	AuthSessionDevice     = "device"
+	// AuthSessionCode is the authorization code a PKCE sign-in ends with,
+	// which the client redeems for a token.
+	AuthSessionCode = "auth_code"
+)
+
+// AuthSession is a short-lived record of an OAuth login or device
+// authorization that is still in progress. An OAuth state with a UserID
+// links the provider account to that user instead of signing in.
+type AuthSession struct {
+	ID         uuid.UUID
+	Kind       string
+	SessionKey string
+	UserCode   string
+	Provider   string
+	DeviceCode string
+	DeviceName string
+	UserID     *uuid.UUID
+	PKCE
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// PKCE holds the proof keys of an OAuth sign-in (RFC 7636).
+// CodeVerifier is the server's, proving to the provider that the code of
+// the callback was asked for by this server. CodeChallenge is the
+// client's: the sign-in then ends with an authorization code, which only
+// the holder of its verifier can redeem, instead of a token.
+type PKCE struct {
+	CodeVerifier  string
+	CodeChallenge string
+}
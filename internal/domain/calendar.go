@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// CalendarFeedPastDays and CalendarFeedFutureDays bound the items a
+	// calendar feed carries, counted from the feed owner's today.
+	CalendarFeedPastDays   = 90
+	CalendarFeedFutureDays = 365
+)
+
+// CalendarFeed is a secret URL serving a workspace's agenda as iCalendar,
+// for calendar apps that cannot authenticate. Only the token's hash is
+// stored, so URL is set only in the response that creates the feed.
+type CalendarFeed struct {
+	ID            uuid.UUID  `json:"id"`
+	WorkspaceID   uuid.UUID  `json:"workspace_id"`
+	Token         string     `json:"-"`
+	URL           string     `json:"url,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastFetchedAt *time.Time `json:"last_fetched_at,omitempty"`
+}
@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Headers sync clients identify themselves with.
+const (
+	ClientNameHeader    = "X-Client-Name"
+	ClientVersionHeader = "X-Client-Version"
+)
+
+// Limits matching the api_tokens client columns.
+const (
+	MaxClientNameLength    = 100
+	MaxClientVersionLength = 50
+)
+
+var clientNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidClientName accepts names such as "noture-cli" or "obsidian.noture".
+func ValidClientName(name string) bool {
+	return len(name) <= MaxClientNameLength && clientNamePattern.MatchString(name)
+}
+
+// ClientVersion is a MAJOR.MINOR.PATCH version with an optional
+// pre-release suffix. Missing minor and patch numbers are zero.
+type ClientVersion struct {
+	Major, Minor, Patch int
+	PreRelease          string
+}
+
+// ParseClientVersion parses versions such as "1.4", "v2.0.1" or
+// "2.1.0-beta.2". Build metadata after "+" is ignored.
+func ParseClientVersion(s string) (ClientVersion, error) {
+	if len(s) > MaxClientVersionLength {
+		return ClientVersion{}, fmt.Errorf("invalid client version: longer than %d characters", MaxClientVersionLength)
+	}
+	core, _, _ := strings.Cut(strings.TrimPrefix(s, "v"), "+")
+	core, pre, hasPre := strings.Cut(core, "-")
+	if hasPre && pre == "" {
+		return ClientVersion{}, fmt.Errorf("invalid client version %q", s)
+	}
+
+	parts := strings.Split(core, ".")
+	if len(parts) > 3 {
+		return ClientVersion{}, fmt.Errorf("invalid client version %q", s)
+	}
+	var numbers [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || strings.HasPrefix(part, "+") {
+			return ClientVersion{}, fmt.Errorf("invalid client version %q", s)
+		}
+		numbers[i] = n
+	}
+	return ClientVersion{Major: numbers[0], Minor: numbers[1], Patch: numbers[2], PreRelease: pre}, nil
+}
+
+// Less orders versions numerically; a pre-release sorts before the release
+// it precedes, and pre-releases of one version compare as strings.
+func (v ClientVersion) Less(other ClientVersion) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	if v.Patch != other.Patch {
+		return v.Patch < other.Patch
+	}
+	switch {
+	case v.PreRelease == other.PreRelease:
+		return false
+	case v.PreRelease == "":
+		return false
+	case other.PreRelease == "":
+		return true
+	}
+	return v.PreRelease < other.PreRelease
+}
+
+func (v ClientVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		s += "-" + v.PreRelease
+	}
+	return s
+}
+
+// ClientUpgradeRequired is the 426 response body sent to clients older
+// than the minimum version configured for their name.
+type ClientUpgradeRequired struct {
+	Error          string `json:"error"`
+	Message        string `json:"message"`
+	ClientName     string `json:"client_name"`
+	ClientVersion  string `json:"client_version,omitempty"`
+	MinimumVersion string `json:"minimum_version"`
+}
+
+// ClientVersionCount is how many requests one client version made.
+type ClientVersionCount struct {
+	ClientName    string `json:"client_name"`
+	ClientVersion string `json:"client_version"`
+	Requests      int64  `json:"requests"`
+	Rejected      int64  `json:"rejected"`
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClientVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "1.4.2", want: "1.4.2"},
+		{input: "v2", want: "2.0.0"},
+		{input: "1.4", want: "1.4.0"},
+		{input: "2.1.0-beta.2", want: "2.1.0-beta.2"},
+		{input: "1.0.0+build.7", want: "1.0.0"},
+		{input: "", wantErr: true},
+		{input: "latest", wantErr: true},
+		{input: "1.2.3.4", wantErr: true},
+		{input: "1.-2", wantErr: true},
+		{input: "1.+2", wantErr: true},
+		{input: "1.0-", wantErr: true},
+		{input: "1." + strings.Repeat("0", MaxClientVersionLength), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			v, err := ParseClientVersion(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, v.String())
+		})
+	}
+}
+
+func TestClientVersion_Less(t *testing.T) {
+	ordered := []string{"0.9.9", "1.0.0-alpha", "1.0.0-beta", "1.0.0", "1.0.1", "1.2.0", "1.10.0", "2.0.0"}
+	for i := 1; i < len(ordered); i++ {
+		lower, err := ParseClientVersion(ordered[i-1])
+		require.NoError(t, err)
+		higher, err := ParseClientVersion(ordered[i])
+		require.NoError(t, err)
+		assert.True(t, lower.Less(higher), "%s < %s", lower, higher)
+		assert.False(t, higher.Less(lower), "%s > %s", higher, lower)
+	}
+
+	v, _ := ParseClientVersion("1.4")
+	same, _ := ParseClientVersion("v1.4.0")
+	assert.False(t, v.Less(same))
+}
+
+func TestValidClientName(t *testing.T) {
+	assert.True(t, ValidClientName("noture-cli"))
+	assert.True(t, ValidClientName("obsidian.noture_plugin"))
+	assert.False(t, ValidClientName(""))
+	assert.False(t, ValidClientName("-cli"))
+	assert.False(t, ValidClientName("my client"))
+	assert.False(t, ValidClientName(strings.Repeat("a", MaxClientNameLength+1)))
+}
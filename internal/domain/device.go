@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxDeviceNameLength matches the devices.name column.
+const MaxDeviceNameLength = 100
+
+// DefaultDeviceName names devices whose client did not send one.
+const DefaultDeviceName = "Unnamed device"
+
+// Device is one client installation signed in to an account. LastSeenAt is
+// the last use of any of its tokens; ClientName and ClientVersion are what
+// the client last identified itself as.
+type Device struct {
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	LastSeenAt    *time.Time `json:"last_seen_at,omitempty"`
+	TokenCount    int64      `json:"token_count"`
+	ClientName    string     `json:"client_name,omitempty"`
+	ClientVersion string     `json:"client_version,omitempty"`
+	Current       bool       `json:"current"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// MaxUserAgentLength matches the api_tokens.user_agent column; longer
+// User-Agent headers are cut.
+const MaxUserAgentLength = 255
+
+// Session is one live API token: a sign-in, with its device if it has
+// one, or a token minted for a script. IPAddress and UserAgent are where
+// it was last used from.
+type Session struct {
+	ID            uuid.UUID   `json:"id"`
+	Name          string      `json:"name"`
+	DeviceID      *uuid.UUID  `json:"device_id,omitempty"`
+	DeviceName    string      `json:"device_name,omitempty"`
+	ClientName    string      `json:"client_name,omitempty"`
+	ClientVersion string      `json:"client_version,omitempty"`
+	IPAddress     string      `json:"ip_address,omitempty"`
+	UserAgent     string      `json:"user_agent,omitempty"`
+	Scopes        TokenScopes `json:"scopes,omitempty"`
+	Current       bool        `json:"current"`
+	LastUsedAt    *time.Time  `json:"last_used_at,omitempty"`
+	ExpiresAt     *time.Time  `json:"expires_at,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
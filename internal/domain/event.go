@@ -0,0 +1,171 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/pkg/netguard"
+	"github.com/google/uuid"
+)
+
+// Workspace event types. File events describe stored content; task and
+// reminder events are derived from the tasks inside notes, so automations
+// can react to them without diffing files. storage.threshold fires when
+// the workspace's storage crosses one of StorageAlertThresholds.
+const (
+	EventFileCreated   = "file.created"
+	EventFileUpdated   = "file.updated"
+	EventFileDeleted   = "file.deleted"
+	EventFileMoved     = "file.moved"
+	EventFolderDeleted = "folder.deleted"
+
+	EventTaskCreated   = "task.created"
+	EventTaskCompleted = "task.completed"
+	EventTaskReopened  = "task.reopened"
+
+	EventReminderDue = "reminder.due"
+
+	EventStorageThreshold = "storage.threshold"
+)
+
+// EventTypes lists every event type, in documentation order.
+var EventTypes = []string{
+	EventFileCreated, EventFileUpdated, EventFileDeleted, EventFileMoved, EventFolderDeleted,
+	EventTaskCreated, EventTaskCompleted, EventTaskReopened,
+	EventReminderDue,
+	EventStorageThreshold,
+}
+
+// ReminderLeadTime is how long before a task's deadline reminder.due fires.
+const ReminderLeadTime = 24 * time.Hour
+
+// WorkspaceEvent is one entry of a workspace's event stream. IDs increase
+// in commit order within a workspace, so a client resumes after the last ID
+// it saw.
+type WorkspaceEvent struct {
+	ID          int64           `json:"id"`
+	WorkspaceID uuid.UUID       `json:"workspace_id"`
+	Type        string          `json:"type"`
+	FilePath    string          `json:"file_path,omitempty"`
+	ActorID     *uuid.UUID      `json:"actor_id,omitempty"`
+	Data        json.RawMessage `json:"data"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// TaskEventData is the data of task and reminder events.
+type TaskEventData struct {
+	TaskKey  string     `json:"task_key"`
+	Title    string     `json:"title"`
+	Done     bool       `json:"done"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+// EventPage is one page of a workspace's event stream, oldest first.
+type EventPage struct {
+	Events []WorkspaceEvent `json:"events"`
+	LastID int64            `json:"last_id"`
+}
+
+// MaxReplayWindow bounds the time window of one event replay.
+const MaxReplayWindow = 31 * 24 * time.Hour
+
+// Sources of replayed events. Outbox entries are workspace events exactly
+// as the stream delivered them; audit entries are policy violations, which
+// the live stream never carries, replayed with type policy.violation and
+// the violation's own ID.
+const (
+	ReplaySourceOutbox = "outbox"
+	ReplaySourceAudit  = "audit"
+
+	EventPolicyViolation = "policy.violation"
+)
+
+// ReplayedEvent is one entry of an event replay.
+type ReplayedEvent struct {
+	Source string `json:"source"`
+	WorkspaceEvent
+}
+
+// PolicyViolationEventData is the data of replayed policy.violation
+// entries.
+type PolicyViolationEventData struct {
+	RuleID     *uuid.UUID `json:"rule_id,omitempty"`
+	RuleName   string     `json:"rule_name"`
+	Action     string     `json:"action"`
+	Blocked    bool       `json:"blocked"`
+	MatchCount int32      `json:"match_count"`
+}
+
+// WorkspaceWebhook posts a workspace's events to URL, signed with Secret.
+// EventTypes and PathPrefix filter what is sent; empty matches everything.
+// The secret is only returned when the webhook is created.
+type WorkspaceWebhook struct {
+	ID            uuid.UUID  `json:"id"`
+	WorkspaceID   uuid.UUID  `json:"workspace_id"`
+	URL           string     `json:"url"`
+	Secret        string     `json:"secret,omitempty"`
+	EventTypes    []string   `json:"event_types"`
+	PathPrefix    string     `json:"path_prefix,omitempty"`
+	LastEventID   int64      `json:"last_event_id"`
+	FailureCount  int32      `json:"failure_count"`
+	LastError     *string    `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types,omitempty"`
+	PathPrefix string   `json:"path_prefix,omitempty"`
+}
+
+func (r CreateWebhookRequest) Validate() error {
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url: must be an absolute http or https URL")
+	}
+	if err := netguard.CheckHostname(u.Hostname()); err != nil {
+		return fmt.Errorf("invalid webhook url: %w", err)
+	}
+	for _, pattern := range r.EventTypes {
+		if !ValidEventPattern(pattern) {
+			return fmt.Errorf("invalid event type %q", pattern)
+		}
+	}
+	return nil
+}
+
+// ValidEventPattern accepts an event type or a "<category>.*" wildcard
+// such as "task.*".
+func ValidEventPattern(pattern string) bool {
+	category, isWildcard := strings.CutSuffix(pattern, ".*")
+	for _, t := range EventTypes {
+		if t == pattern || (isWildcard && strings.HasPrefix(t, category+".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesEvent reports whether the webhook's filters select an event.
+func (w WorkspaceWebhook) MatchesEvent(event WorkspaceEvent) bool {
+	if w.PathPrefix != "" && !strings.HasPrefix(event.FilePath, w.PathPrefix) {
+		return false
+	}
+	if len(w.EventTypes) == 0 {
+		return true
+	}
+	for _, pattern := range w.EventTypes {
+		if category, ok := strings.CutSuffix(pattern, ".*"); ok {
+			if strings.HasPrefix(event.Type, category+".") {
+				return true
+			}
+		} else if pattern == event.Type {
+			return true
+		}
+	}
+	return false
+}
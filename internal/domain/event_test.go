@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkspaceWebhook_MatchesEvent(t *testing.T) {
+	taskDone := WorkspaceEvent{Type: EventTaskCompleted, FilePath: "projects/noture.org"}
+	fileSaved := WorkspaceEvent{Type: EventFileUpdated, FilePath: "journal/01.md"}
+
+	assert.True(t, WorkspaceWebhook{}.MatchesEvent(taskDone), "no filters match everything")
+
+	tasksOnly := WorkspaceWebhook{EventTypes: []string{"task.*", EventReminderDue}}
+	assert.True(t, tasksOnly.MatchesEvent(taskDone))
+	assert.True(t, tasksOnly.MatchesEvent(WorkspaceEvent{Type: EventReminderDue}))
+	assert.False(t, tasksOnly.MatchesEvent(fileSaved))
+
+	projects := WorkspaceWebhook{PathPrefix: "projects/"}
+	assert.True(t, projects.MatchesEvent(taskDone))
+	assert.False(t, projects.MatchesEvent(fileSaved))
+}
+
+func TestCreateWebhookRequest_Validate(t *testing.T) {
+	assert.NoError(t, CreateWebhookRequest{URL: "https://example.com/hook", EventTypes: []string{"task.*", "reminder.due"}}.Validate())
+	assert.Error(t, CreateWebhookRequest{URL: "example.com/hook"}.Validate())
+	assert.Error(t, CreateWebhookRequest{URL: "ftp://example.com/hook"}.Validate())
+	for _, internal := range []string{"http://127.0.0.1:8080/hook", "http://169.254.169.254/latest/meta-data", "http://10.0.0.5/", "http://[::1]/", "http://localhost:6379", "http://metadata.google.internal/"} {
+		assert.ErrorContains(t, CreateWebhookRequest{URL: internal}.Validate(), "invalid webhook url", internal)
+	}
+	assert.Error(t, CreateWebhookRequest{URL: "https://example.com", EventTypes: []string{"task.deleted"}}.Validate())
+	assert.Error(t, CreateWebhookRequest{URL: "https://example.com", EventTypes: []string{"*"}}.Validate())
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,6 +13,9 @@ const (
 	FormatPlainText FileFormat = "plaintext"
 	FormatMarkdown  FileFormat = "markdown"
 	FormatOrgMode   FileFormat = "orgmode"
+	// FormatBinary marks attachments such as images and PDFs, which are
+	// stored and served as they are but never parsed as text.
+	FormatBinary FileFormat = "binary"
 )
 
 type FileInfo struct {
@@ -23,6 +27,62 @@ type FileInfo struct {
 	MimeType     string    `json:"mime_type"`
 	LastModified time.Time `json:"last_modified"`
 	UpdatedAt    time.Time `json:"updated_at"`
+	// Meta is set only when the client asks for it.
+	Meta FileMeta `json:"meta,omitempty"`
+}
+
+// FileListPage is one keyset page of a workspace listing. NextCursor
+// resumes the listing and is empty once it is exhausted; in path order it
+// is simply the last path of the page.
+type FileListPage struct {
+	Files      []FileInfo `json:"files"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// FileSort orders a paged listing. Paths list A to Z; updated_at and size
+// list the newest and largest files first.
+type FileSort string
+
+const (
+	SortByPath      FileSort = "path"
+	SortByUpdatedAt FileSort = "updated_at"
+	SortBySize      FileSort = "size"
+)
+
+// ParseFileSort accepts the sort query parameter, defaulting to path order.
+func ParseFileSort(s string) (FileSort, error) {
+	switch sort := FileSort(s); sort {
+	case "":
+		return SortByPath, nil
+	case SortByPath, SortByUpdatedAt, SortBySize:
+		return sort, nil
+	}
+	return "", fmt.Errorf("invalid sort %q: use path, updated_at or size", s)
+}
+
+// FileListOptions selects one page of a workspace listing. Cursor is the
+// NextCursor of the previous page in the same sort. MimeTypes holds exact
+// types or "type/*" wildcards; any match keeps the file.
+type FileListOptions struct {
+	Sort       FileSort
+	Cursor     string
+	Limit      int
+	PathPrefix string
+	MimeTypes  []string
+}
+
+// FileLookupRequest asks for several paths of one workspace at once, e.g.
+// to resolve every wikilink of a note.
+type FileLookupRequest struct {
+	Paths           []string `json:"paths"`
+	IncludeMetadata bool     `json:"include_metadata,omitempty"`
+}
+
+type FileLookupResult struct {
+	FilePath string        `json:"file_path"`
+	Found    bool          `json:"found"`
+	File     *FileInfo     `json:"file,omitempty"`
+	Metadata *FileMetadata `json:"metadata,omitempty"`
 }
 
 type FileWithContent struct {
@@ -30,12 +90,44 @@ type FileWithContent struct {
 	Content []byte `json:"content"`
 }
 
+// FileUploadResult is the file as stored plus what a client needs to update
+// its sync state. Unchanged uploads matched the current content and created
+// no version; Deduplicated ones reused content the server already stored.
+// Revision is the workspace revision after the upload.
+type FileUploadResult struct {
+	FileInfo
+	VersionNumber int32 `json:"version_number"`
+	Revision      int64 `json:"workspace_revision"`
+	Created       bool  `json:"created"`
+	Unchanged     bool  `json:"unchanged"`
+	Deduplicated  bool  `json:"deduplicated"`
+}
+
 type FileUploadRequest struct {
 	WorkspaceID  uuid.UUID `json:"workspace_id"`
 	FilePath     string    `json:"file_path"`
 	Content      []byte    `json:"content"`
 	LastModified time.Time `json:"last_modified"`
 	ClientID     string    `json:"client_id,omitempty"`
+	// NoteID identifies the note across renames. Without it the server's
+	// note ID strategy may find one in the content.
+	NoteID string `json:"note_id,omitempty"`
+	// BaseHash, when set, is the content hash the file must still have
+	// for the upload to go ahead, as with save-sets.
+	BaseHash *string `json:"base_hash,omitempty"`
+}
+
+// FilePatchRequest updates a file by applying a unified diff to the
+// version whose content hash is BaseHash, so clients only send what
+// changed. The other fields are as in FileUploadRequest.
+type FilePatchRequest struct {
+	WorkspaceID  uuid.UUID
+	FilePath     string
+	BaseHash     string
+	Patch        []byte
+	LastModified time.Time
+	ClientID     string
+	NoteID       string
 }
 
 type FileMetadata struct {
@@ -51,6 +143,7 @@ type SyncOperation struct {
 	ID            uuid.UUID `json:"id"`
 	WorkspaceID   uuid.UUID `json:"workspace_id"`
 	FileID        *uuid.UUID `json:"file_id,omitempty"`
+	FilePath      string    `json:"file_path,omitempty"`
 	OperationType string    `json:"operation_type"`
 	ClientID      *string   `json:"client_id,omitempty"`
 	Status        string    `json:"status"`
@@ -58,9 +151,86 @@ type SyncOperation struct {
 	CreatedAt     time.Time `json:"created_at"`
 }
 
+// Sync operation types and statuses recorded in sync_operations.
+const (
+	SyncOpUpload   = "upload"
+	SyncOpDownload = "download"
+	SyncOpDelete   = "delete"
+	SyncOpConflict = "conflict"
+
+	SyncStatusPending = "pending"
+	SyncStatusSuccess = "success"
+	SyncStatusFailed  = "failed"
+)
+
+// SyncOperationFilter narrows a sync operation listing. Empty fields match
+// everything; Since and Until bound created_at, Until exclusively.
+type SyncOperationFilter struct {
+	Status        string
+	OperationType string
+	ClientID      string
+	Since         *time.Time
+	Until         *time.Time
+	Cursor        string
+	Limit         int
+}
+
+// Validate rejects statuses and operation types that are never recorded.
+func (f SyncOperationFilter) Validate() error {
+	switch f.Status {
+	case "", SyncStatusPending, SyncStatusSuccess, SyncStatusFailed:
+	default:
+		return fmt.Errorf("invalid status %q", f.Status)
+	}
+	switch f.OperationType {
+	case "", SyncOpUpload, SyncOpDownload, SyncOpDelete, SyncOpConflict:
+	default:
+		return fmt.Errorf("invalid operation type %q", f.OperationType)
+	}
+	if f.Since != nil && f.Until != nil && !f.Since.Before(*f.Until) {
+		return fmt.Errorf("invalid date range: since must be before until")
+	}
+	return nil
+}
+
+// SyncOperationPage is one page of sync operations, newest first.
+type SyncOperationPage struct {
+	Operations []SyncOperation `json:"operations"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
 type WorkspaceStorageInfo struct {
 	StorageLimitBytes   int64 `json:"storage_limit_bytes"`
 	StorageUsedBytes    int64 `json:"storage_used_bytes"`
 	FileCount           int64 `json:"file_count"`
 	ActualStorageUsed   int64 `json:"actual_storage_used"`
+	// VersionRetention is the history kept for the workspace's files,
+	// set by its owner's tier.
+	VersionRetention VersionRetention `json:"version_retention"`
+	// FileLimits are set by the tier of the workspace's organization, or
+	// of its owner outside one.
+	FileLimits FileLimits `json:"file_limits"`
+	// OrganizationID is set when the workspace belongs to an organization;
+	// the limit and usage are then those of the organization's pool.
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+}
+
+// DefaultRenderLinkTemplate points rendered wiki-links at the render
+// endpoint of the file they resolve to.
+const DefaultRenderLinkTemplate = "/api/files/{workspace_id}/{path}/render"
+
+// RenderOptions control how a note is rendered to HTML. LinkTemplate is
+// the URL resolved wiki-links point to, with {workspace_id} and {path}
+// replaced; it defaults to DefaultRenderLinkTemplate.
+type RenderOptions struct {
+	LinkTemplate string
+}
+
+// RenderedFile is a note rendered to sanitized HTML. Unresolved lists the
+// wiki-link targets that matched no file.
+type RenderedFile struct {
+	FileInfo
+	Format     FileFormat `json:"format"`
+	HTML       string     `json:"html"`
+	Unresolved []string   `json:"unresolved,omitempty"`
 }
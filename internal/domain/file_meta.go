@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Limits on the metadata clients attach to one file. It is meant for
+// small things such as color labels or where the editor left off, not
+// for content.
+const (
+	MaxFileMetaKeys      = 64
+	MaxFileMetaKeyLength = 128
+	MaxFileMetaBytes     = 16 << 10
+)
+
+// FileMeta is the key-value metadata clients attach to a file. Values are
+// any JSON and are returned as stored.
+type FileMeta map[string]json.RawMessage
+
+// Merge applies patch as a JSON merge patch on the top-level keys: null
+// removes a key, any other value replaces it.
+func (m FileMeta) Merge(patch FileMeta) FileMeta {
+	merged := make(FileMeta, len(m)+len(patch))
+	for key, value := range m {
+		merged[key] = value
+	}
+	for key, value := range patch {
+		if string(value) == "null" {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// Validate checks the metadata against the limits above.
+func (m FileMeta) Validate() error {
+	if len(m) > MaxFileMetaKeys {
+		return fmt.Errorf("invalid meta: at most %d keys", MaxFileMetaKeys)
+	}
+	for key := range m {
+		if key == "" || len(key) > MaxFileMetaKeyLength {
+			return fmt.Errorf("invalid meta key %q: use 1 to %d bytes", key, MaxFileMetaKeyLength)
+		}
+	}
+	encoded, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("invalid meta: %w", err)
+	}
+	if len(encoded) > MaxFileMetaBytes {
+		return fmt.Errorf("invalid meta: at most %d bytes", MaxFileMetaBytes)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileMeta_Merge(t *testing.T) {
+	meta := FileMeta{
+		"color":  json.RawMessage(`"red"`),
+		"cursor": json.RawMessage(`{"line":3}`),
+	}
+	merged := meta.Merge(FileMeta{
+		"color":  json.RawMessage(`null`),
+		"cursor": json.RawMessage(`{"line":9}`),
+		"pinned": json.RawMessage(`true`),
+	})
+
+	assert.Equal(t, FileMeta{
+		"cursor": json.RawMessage(`{"line":9}`),
+		"pinned": json.RawMessage(`true`),
+	}, merged)
+	assert.Len(t, meta, 2, "the original is left alone")
+}
+
+func TestFileMeta_Validate(t *testing.T) {
+	assert.NoError(t, FileMeta{"color": json.RawMessage(`"red"`)}.Validate())
+
+	tooMany := FileMeta{}
+	for i := 0; i <= MaxFileMetaKeys; i++ {
+		tooMany[strings.Repeat("k", i+1)] = json.RawMessage(`1`)
+	}
+	assert.ErrorContains(t, tooMany.Validate(), "invalid meta")
+	assert.ErrorContains(t, FileMeta{"": json.RawMessage(`1`)}.Validate(), "invalid meta key")
+	assert.ErrorContains(t, FileMeta{strings.Repeat("k", MaxFileMetaKeyLength+1): json.RawMessage(`1`)}.Validate(), "invalid meta key")
+	assert.ErrorContains(t, FileMeta{"note": json.RawMessage(`"` + strings.Repeat("x", MaxFileMetaBytes) + `"`)}.Validate(), "invalid meta")
+}
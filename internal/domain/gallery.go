@@ -0,0 +1,10 @@
+package domain
+
+// GalleryTemplate describes a starter workspace in the template gallery.
+type GalleryTemplate struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags,omitempty"`
+	FileCount   int      `json:"file_count"`
+}
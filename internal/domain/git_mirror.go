@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultGitMirrorBranch = "main"
+	// GitMirrorPullInterval is how often a mirror that pulls checks its
+	// remote while the workspace itself does not change.
+	GitMirrorPullInterval = 5 * time.Minute
+)
+
+// GitMirror mirrors a workspace to a branch of a Git repository. The
+// server pushes with a deploy key of its own; PublicKey is what users add
+// to the repository, with write access. With Pull, changes made on the
+// branch come back into the workspace, unless the workspace changed the
+// same file since the last sync. LastCommit is the commit of that sync.
+type GitMirror struct {
+	WorkspaceID   uuid.UUID  `json:"workspace_id"`
+	RemoteURL     string     `json:"remote_url"`
+	Branch        string     `json:"branch"`
+	Pull          bool       `json:"pull"`
+	PublicKey     string     `json:"public_key"`
+	LastCommit    *string    `json:"last_commit,omitempty"`
+	LastSyncedAt  *time.Time `json:"last_synced_at,omitempty"`
+	FailureCount  int32      `json:"failure_count"`
+	LastError     *string    `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ConfigureGitMirrorRequest sets up a workspace's mirror or changes it.
+// Branch defaults to DefaultGitMirrorBranch.
+type ConfigureGitMirrorRequest struct {
+	RemoteURL string `json:"remote_url" validate:"required,max=2048"`
+	Branch    string `json:"branch,omitempty" validate:"max=255"`
+	Pull      bool   `json:"pull,omitempty"`
+}
+
+func (r ConfigureGitMirrorRequest) Validate() error {
+	if !ValidGitRemoteURL(r.RemoteURL) {
+		return fmt.Errorf("invalid remote_url: must be an SSH URL such as git@github.com:user/notes.git")
+	}
+	if r.Branch != "" && !ValidGitBranch(r.Branch) {
+		return fmt.Errorf("invalid branch %q", r.Branch)
+	}
+	return nil
+}
+
+// GitPushNotification tells the mirrors pulling from a branch that it
+// changed, so they sync without waiting for GitMirrorPullInterval. Git
+// hosts, or CI jobs relaying their push events, send it signed with the
+// git webhook secret. RemoteURL must be written as the mirrors have it.
+type GitPushNotification struct {
+	RemoteURL string `json:"remote_url" validate:"required,max=2048"`
+	Branch    string `json:"branch" validate:"required,max=255"`
+}
+
+func (n GitPushNotification) Validate() error {
+	if !ValidGitRemoteURL(n.RemoteURL) {
+		return fmt.Errorf("invalid remote_url: must be an SSH URL such as git@github.com:user/notes.git")
+	}
+	if !ValidGitBranch(n.Branch) {
+		return fmt.Errorf("invalid branch %q", n.Branch)
+	}
+	return nil
+}
+
+// scpLikeURL is git's short form of SSH URLs, [user@]host:path.
+var scpLikeURL = regexp.MustCompile(`^(?:[A-Za-z0-9._-]+@)?[A-Za-z0-9][A-Za-z0-9.-]*:[^:\s][^\s]*$`)
+
+// ValidGitRemoteURL accepts the SSH URLs deploy keys work with, either
+// ssh://[user@]host[:port]/path or [user@]host:path. Other transports,
+// local paths among them, are refused.
+func ValidGitRemoteURL(remote string) bool {
+	if strings.ContainsFunc(remote, func(r rune) bool { return r < ' ' || r == 0x7f }) {
+		return false
+	}
+	if rest, ok := strings.CutPrefix(remote, "ssh://"); ok {
+		u, err := url.Parse(remote)
+		return err == nil && u.Hostname() != "" && !strings.HasPrefix(rest, "-") &&
+			strings.Trim(u.Path, "/") != "" && u.RawQuery == "" && u.Fragment == ""
+	}
+	return scpLikeURL.MatchString(remote) && !strings.Contains(remote, "://")
+}
+
+// ValidGitBranch reports whether name is a branch name git accepts, as
+// git check-ref-format --branch does.
+func ValidGitBranch(name string) bool {
+	if name == "" || name == "@" || strings.HasPrefix(name, "-") ||
+		strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") ||
+		strings.HasSuffix(name, ".") || strings.HasSuffix(name, ".lock") ||
+		strings.Contains(name, "..") || strings.Contains(name, "//") || strings.Contains(name, "@{") {
+		return false
+	}
+	if strings.ContainsFunc(name, func(r rune) bool {
+		return r <= ' ' || r == 0x7f || strings.ContainsRune("~^:?*[\\", r)
+	}) {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return true
+}
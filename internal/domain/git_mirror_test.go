@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidGitRemoteURL(t *testing.T) {
+	valid := []string{
+		"git@github.com:duckonomy/notes.git",
+		"github.com:notes",
+		"ssh://git@gitlab.example.com:2222/team/notes.git",
+		"ssh://host/notes",
+	}
+	for _, remote := range valid {
+		assert.True(t, ValidGitRemoteURL(remote), remote)
+	}
+
+	invalid := []string{
+		"",
+		"https://github.com/duckonomy/notes.git",
+		"file:///srv/notes.git",
+		"/srv/notes.git",
+		"../notes",
+		"ext::sh -c touch% /tmp/pwned",
+		"-oProxyCommand=touch:x",
+		"ssh://-oProxyCommand=x/notes",
+		"ssh://host/",
+		"git@github.com:",
+		"git@github.com:notes\n.git",
+	}
+	for _, remote := range invalid {
+		assert.False(t, ValidGitRemoteURL(remote), remote)
+	}
+}
+
+func TestValidGitBranch(t *testing.T) {
+	for _, name := range []string{"main", "notes/backup", "v1.2", "feature-x_y"} {
+		assert.True(t, ValidGitBranch(name), name)
+	}
+	for _, name := range []string{"", "@", "-main", "/main", "main/", "a..b", "a//b", "main.lock", ".hidden", "a/.b", "a b", "a~1", "a^", "a:b", "a@{1}", "a\\b"} {
+		assert.False(t, ValidGitBranch(name), name)
+	}
+}
+
+func TestConfigureGitMirrorRequest_Validate(t *testing.T) {
+	assert.NoError(t, ConfigureGitMirrorRequest{RemoteURL: "git@github.com:me/notes.git"}.Validate())
+	assert.ErrorContains(t, ConfigureGitMirrorRequest{RemoteURL: "https://github.com/me/notes"}.Validate(), "invalid remote_url")
+	assert.ErrorContains(t, ConfigureGitMirrorRequest{RemoteURL: "git@github.com:me/notes.git", Branch: "bad..name"}.Validate(), "invalid branch")
+}
@@ -0,0 +1,23 @@
+package domain
+
+// Headers of idempotent requests. A retry answered from a stored response
+// carries IdempotentReplayedHeader.
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// MaxIdempotencyKeyLength matches the idempotency_keys column.
+const MaxIdempotencyKeyLength = 255
+
+// IdempotentResponse is what a request sent with an Idempotency-Key
+// answered. Fingerprint identifies the request, so a key reused for a
+// different one is refused. Pending responses belong to a request still
+// in progress and have no other fields.
+type IdempotentResponse struct {
+	Pending     bool
+	Fingerprint string
+	StatusCode  int
+	Header      map[string]string
+	Body        []byte
+}
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuth providers users can sign in with.
+const (
+	ProviderGoogle    = "google"
+	ProviderGitHub    = "github"
+	ProviderMicrosoft = "microsoft"
+	ProviderApple     = "apple"
+	// ProviderDev signs in without credentials, in development only.
+	ProviderDev = "dev"
+)
+
+// ExternalIdentity is an account at an OAuth provider as the provider
+// reported it during a callback. ProviderUserID is the provider's stable
+// ID for the account; the email may change.
+type ExternalIdentity struct {
+	Provider       string
+	ProviderUserID string
+	Email          string
+	EmailVerified  bool
+	Name           string
+}
+
+// OAuthIdentity is a provider account linked to a user, which signs the
+// user in whatever email the provider reports.
+type OAuthIdentity struct {
+	ID             uuid.UUID  `json:"id"`
+	Provider       string     `json:"provider"`
+	ProviderUserID string     `json:"provider_user_id"`
+	Email          string     `json:"email,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastLoginAt    *time.Time `json:"last_login_at,omitempty"`
+}
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxNotionImportBytes caps the export archive an import uploads.
+	MaxNotionImportBytes = 256 << 20
+	// MaxNotionDownloadBytes caps the images an import downloads for the
+	// pages of one export, and MaxNotionAssetBytes each of them.
+	MaxNotionDownloadBytes = 256 << 20
+	MaxNotionAssetBytes    = 20 << 20
+	// DefaultNotionImportFolder is where an import puts the export
+	// unless told otherwise.
+	DefaultNotionImportFolder = "Notion"
+)
+
+// NotionImportResult reports an import of a Notion export into Folder:
+// the pages converted to notes, the files of the export kept as
+// attachments, and the images pages embedded from the web that were
+// downloaded. Skipped lists what could not be imported; an image that
+// failed to download stays linked to the web.
+type NotionImportResult struct {
+	Folder      string              `json:"folder"`
+	Pages       int                 `json:"pages"`
+	Attachments int                 `json:"attachments"`
+	Downloaded  int                 `json:"downloaded"`
+	Skipped     []NotionImportIssue `json:"skipped"`
+}
+
+type NotionImportIssue struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// NotionImportFolder returns the folder prefix an import into folder
+// writes under, DefaultNotionImportFolder when folder is empty.
+func NotionImportFolder(folder string) (string, error) {
+	folder = strings.Trim(folder, "/")
+	if folder == "" {
+		return FolderPrefix(DefaultNotionImportFolder), nil
+	}
+	for _, segment := range strings.Split(folder, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("invalid folder %q", folder)
+		}
+	}
+	return FolderPrefix(folder), nil
+}
@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotionImportFolder(t *testing.T) {
+	folder, err := NotionImportFolder("")
+	require.NoError(t, err)
+	assert.Equal(t, "Notion/", folder)
+
+	folder, err = NotionImportFolder("/Archive/Notion/")
+	require.NoError(t, err)
+	assert.Equal(t, "Archive/Notion/", folder)
+
+	for _, invalid := range []string{"../notes", "a//b", "a/./b"} {
+		_, err := NotionImportFolder(invalid)
+		assert.ErrorContains(t, err, "invalid folder", invalid)
+	}
+}
@@ -0,0 +1,41 @@
+package domain
+
+const (
+	// DefaultRelatedNotes is how many related notes are returned when the
+	// request sets no limit.
+	DefaultRelatedNotes = 10
+	// MaxRelatedNotes caps the limit of one related-notes request.
+	MaxRelatedNotes = 50
+	// MaxBrokenLinks caps the links of one broken-link report; Truncated
+	// is set when there were more.
+	MaxBrokenLinks = 5000
+)
+
+// RelatedNote is a note connected to the one asked about by tags or
+// links. LinksTo is set when the note asked about links to this one and
+// LinkedFrom when this one links to it; SharedLinks counts the notes both
+// link to. Score ranks the connections, a direct link weighing most.
+type RelatedNote struct {
+	FilePath    string   `json:"file_path"`
+	Score       int      `json:"score"`
+	SharedTags  []string `json:"shared_tags,omitempty"`
+	LinksTo     bool     `json:"links_to,omitempty"`
+	LinkedFrom  bool     `json:"linked_from,omitempty"`
+	SharedLinks int      `json:"shared_links,omitempty"`
+}
+
+// BrokenLink is a link in a note whose target names no file of the
+// workspace. Target is as written in the note, on the 1-based Line.
+type BrokenLink struct {
+	FilePath string `json:"file_path"`
+	Line     int32  `json:"line"`
+	Target   string `json:"target"`
+}
+
+// BrokenLinkReport lists the broken links of a workspace by note and
+// line.
+type BrokenLinkReport struct {
+	Links     []BrokenLink `json:"links"`
+	Count     int          `json:"count"`
+	Truncated bool         `json:"truncated,omitempty"`
+}
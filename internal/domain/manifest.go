@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestEntry is the sync state of one file: enough for a client to
+// decide whether to push or pull it.
+type ManifestEntry struct {
+	Path        string    `json:"path"`
+	ContentHash string    `json:"content_hash"`
+	Size        int64     `json:"size"`
+	MTime       time.Time `json:"mtime"`
+}
+
+// ManifestNode is a direct child of the manifest's folder in the digest
+// view. A file's digest is its content hash; a folder's covers everything
+// below it.
+type ManifestNode struct {
+	Path   string `json:"path"`
+	Type   string `json:"type"`
+	Digest string `json:"digest"`
+}
+
+// Manifest lists the files below Folder, or, in the digest view, the
+// folder's direct children with their digests. Two manifests have the
+// same Digest exactly when they list the same paths and contents, so
+// clients compare digests and descend only into folders that differ.
+type Manifest struct {
+	Folder   string          `json:"folder"`
+	Revision int64           `json:"workspace_revision"`
+	Digest   string          `json:"digest"`
+	Files    []ManifestEntry `json:"files,omitempty"`
+	Children []ManifestNode  `json:"children,omitempty"`
+}
+
+// ManifestDigest hashes the entries below root, Merkle style: each folder
+// hashes its children's names, types and digests in name order, so a
+// change anywhere changes the digest of every folder above it. It also
+// returns root's direct children. Entries outside root are ignored.
+func ManifestDigest(root string, entries []ManifestEntry) (string, []ManifestNode) {
+	files := make(map[string]string)
+	folders := make(map[string][]ManifestEntry)
+	for _, entry := range entries {
+		rel, ok := strings.CutPrefix(entry.Path, root)
+		if !ok || rel == "" {
+			continue
+		}
+		if name, _, isFolder := strings.Cut(rel, "/"); isFolder {
+			folders[name] = append(folders[name], entry)
+		} else {
+			files[name] = entry.ContentHash
+		}
+	}
+
+	children := make([]ManifestNode, 0, len(files)+len(folders))
+	for name, hash := range files {
+		children = append(children, ManifestNode{Path: root + name, Type: TreeNodeFile, Digest: hash})
+	}
+	for name, below := range folders {
+		path := root + name + "/"
+		digest, _ := ManifestDigest(path, below)
+		children = append(children, ManifestNode{Path: path, Type: TreeNodeFolder, Digest: digest})
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Path < children[j].Path })
+
+	h := sha256.New()
+	for _, child := range children {
+		h.Write([]byte(child.Type + " " + strings.TrimPrefix(child.Path, root) + "\x00" + child.Digest + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil)), children
+}
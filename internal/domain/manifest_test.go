@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestDigest(t *testing.T) {
+	entries := []ManifestEntry{
+		{Path: "index.md", ContentHash: "h1"},
+		{Path: "journal/01.md", ContentHash: "h2"},
+		{Path: "journal/02.md", ContentHash: "h3"},
+		{Path: "zettel/a.md", ContentHash: "h4"},
+	}
+
+	digest, children := ManifestDigest("", entries)
+	require.Len(t, children, 3)
+	assert.Equal(t, ManifestNode{Path: "index.md", Type: TreeNodeFile, Digest: "h1"}, children[0])
+	assert.Equal(t, "journal/", children[1].Path)
+
+	journal, _ := ManifestDigest("journal/", entries)
+	assert.Equal(t, journal, children[1].Digest, "folder digests match their own manifest")
+
+	t.Run("a change reaches every folder above it", func(t *testing.T) {
+		changed := append([]ManifestEntry{}, entries...)
+		changed[2].ContentHash = "h3b"
+
+		changedDigest, changedChildren := ManifestDigest("", changed)
+		assert.NotEqual(t, digest, changedDigest)
+		assert.NotEqual(t, children[1].Digest, changedChildren[1].Digest)
+		assert.Equal(t, children[2].Digest, changedChildren[2].Digest)
+	})
+
+	t.Run("renames change the digest", func(t *testing.T) {
+		renamed := append([]ManifestEntry{}, entries...)
+		renamed[0].Path = "readme.md"
+
+		renamedDigest, _ := ManifestDigest("", renamed)
+		assert.NotEqual(t, digest, renamedDigest)
+	})
+}
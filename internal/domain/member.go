@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WorkspaceRole string
+
+const (
+	RoleOwner  WorkspaceRole = "owner"
+	RoleEditor WorkspaceRole = "editor"
+	RoleViewer WorkspaceRole = "viewer"
+)
+
+func (r WorkspaceRole) rank() int {
+	switch r {
+	case RoleOwner:
+		return 3
+	case RoleEditor:
+		return 2
+	case RoleViewer:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (r WorkspaceRole) Valid() bool {
+	return r.rank() > 0
+}
+
+// Includes reports whether r grants everything other does: owners can do
+// what editors can, editors what viewers can.
+func (r WorkspaceRole) Includes(other WorkspaceRole) bool {
+	return r.Valid() && r.rank() >= other.rank()
+}
+
+type WorkspaceMember struct {
+	WorkspaceID uuid.UUID     `json:"workspace_id"`
+	UserID      uuid.UUID     `json:"user_id"`
+	Email       string        `json:"email"`
+	Role        WorkspaceRole `json:"role"`
+	InvitedBy   *uuid.UUID    `json:"invited_by,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+type AddMemberRequest struct {
+	Email string        `json:"email" validate:"required,email"`
+	Role  WorkspaceRole `json:"role"`
+}
+
+type UpdateMemberRequest struct {
+	Role WorkspaceRole `json:"role"`
+}
+
+// WorkspaceInvite is a pending invitation to join a workspace. The token
+// is only ever sent to the invited address.
+type WorkspaceInvite struct {
+	ID          uuid.UUID     `json:"id"`
+	WorkspaceID uuid.UUID     `json:"workspace_id"`
+	Email       string        `json:"email"`
+	Role        WorkspaceRole `json:"role"`
+	InvitedBy   uuid.UUID     `json:"invited_by"`
+	ExpiresAt   time.Time     `json:"expires_at"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+type CreateInviteRequest struct {
+	Email string        `json:"email" validate:"required,email"`
+	Role  WorkspaceRole `json:"role"`
+}
+
+// InvitePreview is what the holder of an invite token sees before
+// accepting it.
+type InvitePreview struct {
+	WorkspaceID    uuid.UUID     `json:"workspace_id"`
+	WorkspaceName  string        `json:"workspace_name"`
+	Email          string        `json:"email"`
+	Role           WorkspaceRole `json:"role"`
+	InvitedByEmail string        `json:"invited_by_email"`
+	ExpiresAt      time.Time     `json:"expires_at"`
+}
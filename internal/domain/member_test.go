@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkspaceRole_Includes(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     WorkspaceRole
+		need     WorkspaceRole
+		expected bool
+	}{
+		{name: "owner can edit", role: RoleOwner, need: RoleEditor, expected: true},
+		{name: "editor can view", role: RoleEditor, need: RoleViewer, expected: true},
+		{name: "editor cannot manage", role: RoleEditor, need: RoleOwner, expected: false},
+		{name: "viewer cannot edit", role: RoleViewer, need: RoleEditor, expected: false},
+		{name: "viewer can view", role: RoleViewer, need: RoleViewer, expected: true},
+		{name: "unknown role grants nothing", role: WorkspaceRole("admin"), need: RoleViewer, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.role.Includes(tt.need))
+		})
+	}
+}
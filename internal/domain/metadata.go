@@ -0,0 +1,32 @@
+package domain
+
+import "github.com/google/uuid"
+
+// MetadataStatus reports how much of a workspace has been parsed by the
+// server's current parser. A file's metadata, agenda entries, links, tags
+// and search entry are built from its content when it is written; files
+// parsed by an older parser, or marked for a rebuild, are reparsed in the
+// background, most recently modified first.
+type MetadataStatus struct {
+	WorkspaceID   uuid.UUID `json:"workspace_id"`
+	ParserVersion int32     `json:"parser_version"`
+	TotalFiles    int64     `json:"total_files"`
+	ParsedFiles   int64     `json:"parsed_files"`
+	Progress      float64   `json:"progress"`
+	Ready         bool      `json:"ready"`
+}
+
+func NewMetadataStatus(workspaceID uuid.UUID, parserVersion int32, total, parsed int64) MetadataStatus {
+	status := MetadataStatus{
+		WorkspaceID:   workspaceID,
+		ParserVersion: parserVersion,
+		TotalFiles:    total,
+		ParsedFiles:   parsed,
+		Progress:      1,
+		Ready:         parsed >= total,
+	}
+	if total > 0 {
+		status.Progress = float64(parsed) / float64(total)
+	}
+	return status
+}
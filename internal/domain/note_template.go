@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxNoteTemplateSize caps the content of a note template.
+const MaxNoteTemplateSize = 1 << 20
+
+// NoteTemplate is the skeleton of a note, kept in a workspace and filled in
+// when a file is created from it.
+type NoteTemplate struct {
+	ID          uuid.UUID  `json:"id"`
+	WorkspaceID uuid.UUID  `json:"workspace_id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Content     string     `json:"content"`
+	CreatedBy   *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type CreateNoteTemplateRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Content     string `json:"content"`
+}
+
+// CreateFileFromTemplateRequest names a template of the workspace and the
+// file to create from it. Title defaults to the file's name without its
+// extension; Variables fill in placeholders beyond the built-in ones and
+// take precedence over them.
+type CreateFileFromTemplateRequest struct {
+	WorkspaceID uuid.UUID         `json:"workspace_id" validate:"required"`
+	Template    string            `json:"template" validate:"required"`
+	FilePath    string            `json:"file_path" validate:"required"`
+	Title       string            `json:"title,omitempty"`
+	Variables   map[string]string `json:"variables,omitempty"`
+}
+
+// DefaultDailyNotePattern is where daily notes go in workspaces that have
+// not chosen a pattern.
+const DefaultDailyNotePattern = "journal/{{date}}.md"
+
+// DailyNoteSettings say where a workspace keeps its daily notes and which
+// template, if any, a new one starts from. PathPattern is a file path with
+// template placeholders, rendered for the note's day.
+type DailyNoteSettings struct {
+	PathPattern string `json:"path_pattern"`
+	Template    string `json:"template,omitempty"`
+}
+
+// CreateDailyNoteRequest picks the day, YYYY-MM-DD, defaulting to today in
+// the user's timezone, and a template that overrides the workspace's.
+type CreateDailyNoteRequest struct {
+	Date     string `json:"date,omitempty"`
+	Template string `json:"template,omitempty"`
+}
+
+type DailyNote struct {
+	Date     string           `json:"date"`
+	FilePath string           `json:"file_path"`
+	Created  bool             `json:"created"`
+	File     *FileWithContent `json:"file"`
+}
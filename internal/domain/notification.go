@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notification kinds. Users turn a kind's emails off in their preferences;
+// the in-app notification is kept either way.
+const (
+	NotificationStorageQuota = "storage_quota"
+)
+
+// StorageAlertThresholds are the percentages of its storage limit at which
+// a workspace's owner is warned, in increasing order. Each warns once
+// until usage drops back below it.
+var StorageAlertThresholds = []int{80, 95, 100}
+
+// MaxNotifications bounds one page of a user's notifications.
+const MaxNotifications = 100
+
+// StorageThreshold returns the highest threshold of StorageAlertThresholds
+// that used reaches out of limit, or 0 when it reaches none. Unlimited
+// storage reaches none.
+func StorageThreshold(used, limit int64) int {
+	if limit <= 0 {
+		return 0
+	}
+	reached := 0
+	for _, threshold := range StorageAlertThresholds {
+		if used*100 >= limit*int64(threshold) {
+			reached = threshold
+		}
+	}
+	return reached
+}
+
+// Notification is a message for one user, shown in their clients until
+// read. WorkspaceID is set when it concerns a workspace.
+type Notification struct {
+	ID          uuid.UUID       `json:"id"`
+	Kind        string          `json:"kind"`
+	WorkspaceID *uuid.UUID      `json:"workspace_id,omitempty"`
+	Title       string          `json:"title"`
+	Body        string          `json:"body"`
+	Data        json.RawMessage `json:"data"`
+	ReadAt      *time.Time      `json:"read_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// NotificationList is a page of a user's notifications, newest first.
+type NotificationList struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int64          `json:"unread_count"`
+}
+
+// StorageThresholdEventData is the data of storage.threshold events and of
+// storage_quota notifications.
+type StorageThresholdEventData struct {
+	Threshold         int   `json:"threshold"`
+	StorageUsedBytes  int64 `json:"storage_used_bytes"`
+	StorageLimitBytes int64 `json:"storage_limit_bytes"`
+}
+
+// NotificationEnabled reports whether the user wants emails of kind.
+func (p Preferences) NotificationEnabled(kind string) bool {
+	enabled, ok := p.Notifications[kind]
+	return !ok || enabled
+}
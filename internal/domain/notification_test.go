@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageThreshold(t *testing.T) {
+	tests := []struct {
+		used, limit int64
+		want        int
+	}{
+		{0, 1000, 0},
+		{799, 1000, 0},
+		{800, 1000, 80},
+		{949, 1000, 80},
+		{950, 1000, 95},
+		{1000, 1000, 100},
+		{1500, 1000, 100},
+		{1500, 0, 0},
+		{1500, -1, 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, StorageThreshold(tt.used, tt.limit), "%d of %d", tt.used, tt.limit)
+	}
+}
+
+func TestPreferences_NotificationEnabled(t *testing.T) {
+	prefs := Preferences{Notifications: map[string]bool{"invites": false, NotificationStorageQuota: true}}
+	assert.True(t, prefs.NotificationEnabled(NotificationStorageQuota))
+	assert.False(t, prefs.NotificationEnabled("invites"))
+	assert.True(t, prefs.NotificationEnabled("unlisted"), "kinds not listed are on")
+	assert.True(t, Preferences{}.NotificationEnabled(NotificationStorageQuota))
+}
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Operation statuses. Pending operations wait for the worker; running ones
+// resume from their last checkpoint if the worker stops.
+const (
+	OperationPending   = "pending"
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+)
+
+// Operation is a long-running, multi-step action carried out by the
+// background worker. Clients poll it until Status is succeeded or failed;
+// Result then holds what the operation produced.
+type Operation struct {
+	ID          uuid.UUID       `json:"id"`
+	Kind        string          `json:"kind"`
+	WorkspaceID *uuid.UUID      `json:"workspace_id,omitempty"`
+	Status      string          `json:"status"`
+	StepsDone   int32           `json:"steps_done"`
+	StepsTotal  int32           `json:"steps_total"`
+	Progress    float64         `json:"progress"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       *string         `json:"error,omitempty"`
+	Attempts    int32           `json:"attempts"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the operation will change no further.
+func (o Operation) Finished() bool {
+	return o.Status == OperationSucceeded || o.Status == OperationFailed
+}
@@ -0,0 +1,178 @@
+package domain
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrganizationRole is what a member may do in an organization. Owners
+// manage everything, billing and deletion included; admins manage members
+// and workspaces; members see the organization.
+type OrganizationRole string
+
+const (
+	OrgRoleOwner  OrganizationRole = "owner"
+	OrgRoleAdmin  OrganizationRole = "admin"
+	OrgRoleMember OrganizationRole = "member"
+)
+
+func (r OrganizationRole) rank() int {
+	switch r {
+	case OrgRoleOwner:
+		return 3
+	case OrgRoleAdmin:
+		return 2
+	case OrgRoleMember:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (r OrganizationRole) Valid() bool {
+	return r.rank() > 0
+}
+
+// Includes reports whether r grants everything other does.
+func (r OrganizationRole) Includes(other OrganizationRole) bool {
+	return r.Valid() && r.rank() >= other.rank()
+}
+
+// Organization owns workspaces and is billed as one. Its workspaces share
+// a storage pool of StorageLimitBytes: the tier's storage for each member,
+// unless CustomStorageLimit.
+type Organization struct {
+	ID                 uuid.UUID        `json:"id"`
+	Name               string           `json:"name"`
+	BillingEmail       string           `json:"billing_email"`
+	Tier               UserTier         `json:"tier"`
+	StorageLimitBytes  int64            `json:"storage_limit_bytes"`
+	CustomStorageLimit bool             `json:"custom_storage_limit"`
+	StorageUsedBytes   int64            `json:"storage_used_bytes"`
+	MemberCount        int64            `json:"member_count"`
+	WorkspaceCount     int64            `json:"workspace_count"`
+	Role               OrganizationRole `json:"role,omitempty"`
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+}
+
+// OrganizationStorageLimit is the pool of an organization: custom when
+// set, otherwise seatBytes for each member.
+func OrganizationStorageLimit(seatBytes int64, custom *int64, members int64) int64 {
+	if custom != nil {
+		return *custom
+	}
+	return seatBytes * members
+}
+
+type OrganizationMember struct {
+	OrganizationID uuid.UUID        `json:"organization_id"`
+	UserID         uuid.UUID        `json:"user_id"`
+	Email          string           `json:"email"`
+	Role           OrganizationRole `json:"role"`
+	InvitedBy      *uuid.UUID       `json:"invited_by,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+// OrganizationWorkspace is a workspace the organization owns. OwnerID is
+// still the user who created it, whose membership gives access to it.
+type OrganizationWorkspace struct {
+	ID               uuid.UUID  `json:"id"`
+	Name             string     `json:"name"`
+	OwnerID          uuid.UUID  `json:"owner_id"`
+	OwnerEmail       string     `json:"owner_email"`
+	StorageUsedBytes int64      `json:"storage_used_bytes"`
+	FileCount        int64      `json:"file_count"`
+	AddedBy          *uuid.UUID `json:"added_by,omitempty"`
+	AddedAt          time.Time  `json:"added_at"`
+}
+
+type CreateOrganizationRequest struct {
+	Name         string `json:"name" validate:"required,max=255"`
+	BillingEmail string `json:"billing_email"`
+}
+
+// UpdateOrganizationRequest changes the fields that are set.
+type UpdateOrganizationRequest struct {
+	Name         *string `json:"name,omitempty"`
+	BillingEmail *string `json:"billing_email,omitempty"`
+}
+
+func (r UpdateOrganizationRequest) Validate() error {
+	if r.Name != nil {
+		if err := validateOrganizationName(*r.Name); err != nil {
+			return err
+		}
+	}
+	if r.BillingEmail != nil {
+		if _, err := mail.ParseAddress(*r.BillingEmail); err != nil {
+			return fmt.Errorf("invalid billing_email: %q", *r.BillingEmail)
+		}
+	}
+	if r.Name == nil && r.BillingEmail == nil {
+		return fmt.Errorf("invalid update: no fields to change")
+	}
+	return nil
+}
+
+func (r CreateOrganizationRequest) Validate() error {
+	if err := validateOrganizationName(r.Name); err != nil {
+		return err
+	}
+	if r.BillingEmail != "" {
+		if _, err := mail.ParseAddress(r.BillingEmail); err != nil {
+			return fmt.Errorf("invalid billing_email: %q", r.BillingEmail)
+		}
+	}
+	return nil
+}
+
+func validateOrganizationName(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 255 {
+		return fmt.Errorf("invalid name: must be 1 to 255 characters")
+	}
+	return nil
+}
+
+type AddOrganizationMemberRequest struct {
+	Email string           `json:"email" validate:"required,email"`
+	Role  OrganizationRole `json:"role" validate:"oneof=owner admin member"`
+}
+
+type UpdateOrganizationMemberRequest struct {
+	Role OrganizationRole `json:"role"`
+}
+
+type AddOrganizationWorkspaceRequest struct {
+	WorkspaceID uuid.UUID `json:"workspace_id" validate:"required"`
+}
+
+// AdminOrganizationUpdate changes the fields that are set. A
+// StorageLimitBytes of 0 returns the organization to pooling the tier's
+// storage per member.
+type AdminOrganizationUpdate struct {
+	Tier              *UserTier `json:"tier,omitempty"`
+	StorageLimitBytes *int64    `json:"storage_limit_bytes,omitempty"`
+}
+
+func (u AdminOrganizationUpdate) Validate() error {
+	if u.Tier != nil {
+		switch *u.Tier {
+		case TierFree, TierPremium, TierEnterprise:
+		default:
+			return fmt.Errorf("invalid tier: %q", *u.Tier)
+		}
+	}
+	if u.StorageLimitBytes != nil && *u.StorageLimitBytes < 0 {
+		return fmt.Errorf("invalid storage_limit_bytes: must not be negative")
+	}
+	if u.Tier == nil && u.StorageLimitBytes == nil {
+		return fmt.Errorf("invalid update: no fields to change")
+	}
+	return nil
+}
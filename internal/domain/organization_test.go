@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrganizationRole_Includes(t *testing.T) {
+	assert.True(t, OrgRoleOwner.Includes(OrgRoleAdmin))
+	assert.True(t, OrgRoleAdmin.Includes(OrgRoleMember))
+	assert.False(t, OrgRoleAdmin.Includes(OrgRoleOwner))
+	assert.False(t, OrgRoleMember.Includes(OrgRoleAdmin))
+	assert.False(t, OrganizationRole("editor").Includes(OrgRoleMember), "unknown role grants nothing")
+}
+
+func TestOrganizationStorageLimit(t *testing.T) {
+	assert.Equal(t, int64(300), OrganizationStorageLimit(100, nil, 3), "each member brings a seat")
+	custom := int64(50)
+	assert.Equal(t, int64(50), OrganizationStorageLimit(100, &custom, 3))
+}
+
+func TestUpdateOrganizationRequest_Validate(t *testing.T) {
+	name, blank, email, badEmail := "Acme", " ", "billing@example.com", "nope"
+
+	assert.NoError(t, UpdateOrganizationRequest{Name: &name}.Validate())
+	assert.NoError(t, UpdateOrganizationRequest{BillingEmail: &email}.Validate())
+	assert.ErrorContains(t, UpdateOrganizationRequest{Name: &blank}.Validate(), "invalid name")
+	assert.ErrorContains(t, UpdateOrganizationRequest{BillingEmail: &badEmail}.Validate(), "invalid billing_email")
+	assert.ErrorContains(t, UpdateOrganizationRequest{}.Validate(), "no fields")
+}
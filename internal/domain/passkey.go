@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxPasskeysPerUser caps the passkeys one account registers.
+	MaxPasskeysPerUser = 20
+	// MaxPasskeyNameLength caps the label users give a passkey.
+	MaxPasskeyNameLength = 100
+	// PasskeyCeremonyTimeout is how long a registration or sign-in has to
+	// complete once its challenge is issued.
+	PasskeyCeremonyTimeout = 5 * time.Minute
+)
+
+// Passkey is a WebAuthn credential a user signs in with.
+type Passkey struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// The options below are shaped like the WebAuthn JSON encodings, with
+// binary values base64url-encoded, so clients can hand them to
+// PublicKeyCredential.parseCreationOptionsFromJSON and
+// parseRequestOptionsFromJSON unchanged. That is also why their fields
+// are camelCase, unlike the rest of the API.
+
+// PasskeyCreationOptions start registering a passkey.
+type PasskeyCreationOptions struct {
+	Challenge              string                  `json:"challenge"`
+	RP                     PasskeyRelyingParty     `json:"rp"`
+	User                   PasskeyUser             `json:"user"`
+	PubKeyCredParams       []PasskeyCredentialType `json:"pubKeyCredParams"`
+	Timeout                int64                   `json:"timeout"`
+	ExcludeCredentials     []PasskeyDescriptor     `json:"excludeCredentials"`
+	AuthenticatorSelection PasskeySelection        `json:"authenticatorSelection"`
+	Attestation            string                  `json:"attestation"`
+}
+
+// PasskeyRequestOptions start signing in with a passkey. Without
+// AllowCredentials any passkey of the site may answer.
+type PasskeyRequestOptions struct {
+	Challenge        string              `json:"challenge"`
+	RPID             string              `json:"rpId"`
+	Timeout          int64               `json:"timeout"`
+	AllowCredentials []PasskeyDescriptor `json:"allowCredentials,omitempty"`
+	UserVerification string              `json:"userVerification"`
+}
+
+type PasskeyRelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type PasskeyUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type PasskeyCredentialType struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type PasskeyDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type PasskeySelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// PasskeyLogin is a completed passkey sign-in. When it was started for a
+// device authorization, that device is approved instead of the caller
+// receiving a token.
+type PasskeyLogin struct {
+	User           *User
+	DeviceApproved bool
+}
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// MaxPinnedFiles is how many files one member may pin per workspace.
+const MaxPinnedFiles = 100
+
+// PinnedFile is a file the member pinned, listed in the order pinned.
+type PinnedFile struct {
+	FileInfo
+	PinnedAt time.Time `json:"pinned_at"`
+}
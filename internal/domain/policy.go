@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Actions content policies are evaluated on.
+const (
+	PolicyActionShare   = "share"
+	PolicyActionPublish = "publish"
+)
+
+const MaxPolicyRuleNameLength = 100
+
+// PolicyRule is a data loss prevention rule. Detector names a detector of
+// pkg/policy; Pattern configures it. Action is "block" or "audit".
+type PolicyRule struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Detector  string     `json:"detector"`
+	Pattern   string     `json:"pattern,omitempty"`
+	Action    string     `json:"action"`
+	CreatedBy *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type CreatePolicyRuleRequest struct {
+	Name     string `json:"name"`
+	Detector string `json:"detector"`
+	Pattern  string `json:"pattern,omitempty"`
+	Action   string `json:"action"`
+}
+
+func (r CreatePolicyRuleRequest) Validate() error {
+	name := strings.TrimSpace(r.Name)
+	if name == "" {
+		return fmt.Errorf("invalid rule name: must not be empty")
+	}
+	if utf8.RuneCountInString(name) > MaxPolicyRuleNameLength {
+		return fmt.Errorf("invalid rule name: longer than %d characters", MaxPolicyRuleNameLength)
+	}
+	return nil
+}
+
+// PolicyViolation records one rule matching content on an action, whether
+// or not the action was blocked.
+type PolicyViolation struct {
+	ID          int64      `json:"id"`
+	RuleID      *uuid.UUID `json:"rule_id,omitempty"`
+	RuleName    string     `json:"rule_name"`
+	WorkspaceID *uuid.UUID `json:"workspace_id,omitempty"`
+	UserID      *uuid.UUID `json:"user_id,omitempty"`
+	FilePath    string     `json:"file_path"`
+	Action      string     `json:"action"`
+	Blocked     bool       `json:"blocked"`
+	MatchCount  int32      `json:"match_count"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// PolicyViolationPage is one page of violations, newest first. Pass
+// NextBefore as before to get the next page.
+type PolicyViolationPage struct {
+	Violations []PolicyViolation `json:"violations"`
+	NextBefore int64             `json:"next_before,omitempty"`
+}
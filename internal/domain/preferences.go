@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxEditorPreferencesBytes caps the editor settings blob.
+const MaxEditorPreferencesBytes = 64 << 10
+
+// MaxNotificationSettings caps how many notification kinds a user can set.
+const MaxNotificationSettings = 64
+
+// Preferences are the settings a user's clients share. Locale is a BCP 47
+// tag such as "de-CH", empty to let each client decide. Notifications
+// turn kinds of notification on or off; kinds not listed are on. Editor is
+// stored as given for the clients to interpret.
+type Preferences struct {
+	Locale             string          `json:"locale"`
+	DefaultWorkspaceID *uuid.UUID      `json:"default_workspace_id"`
+	Notifications      map[string]bool `json:"notifications"`
+	Editor             json.RawMessage `json:"editor"`
+	UpdatedAt          *time.Time      `json:"updated_at,omitempty"`
+}
+
+// PreferencesPatch changes the fields it sets. An empty
+// default_workspace_id clears it, notifications are merged by kind and
+// editor replaces the stored blob; null resets it.
+type PreferencesPatch struct {
+	Locale             *string         `json:"locale,omitempty"`
+	DefaultWorkspaceID *string         `json:"default_workspace_id,omitempty"`
+	Notifications      map[string]bool `json:"notifications,omitempty"`
+	Editor             json.RawMessage `json:"editor,omitempty"`
+}
+
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+// Validate checks the fields set, except that the default workspace is
+// one the user may open.
+func (p PreferencesPatch) Validate() error {
+	if p.Locale != nil && *p.Locale != "" && (len(*p.Locale) > 35 || !localePattern.MatchString(*p.Locale)) {
+		return fmt.Errorf("invalid locale %q: use a BCP 47 tag such as en-US", *p.Locale)
+	}
+	if p.DefaultWorkspaceID != nil && *p.DefaultWorkspaceID != "" {
+		if _, err := uuid.Parse(*p.DefaultWorkspaceID); err != nil {
+			return fmt.Errorf("invalid default_workspace_id")
+		}
+	}
+	if len(p.Notifications) > MaxNotificationSettings {
+		return fmt.Errorf("invalid notifications: at most %d kinds", MaxNotificationSettings)
+	}
+	for kind := range p.Notifications {
+		if kind == "" || len(kind) > 64 {
+			return fmt.Errorf("invalid notification kind %q", kind)
+		}
+	}
+	if p.Editor != nil {
+		editor := bytes.TrimSpace(p.Editor)
+		if len(editor) > MaxEditorPreferencesBytes {
+			return fmt.Errorf("invalid editor: at most %d bytes", MaxEditorPreferencesBytes)
+		}
+		if !bytes.Equal(editor, []byte("null")) && (len(editor) == 0 || editor[0] != '{') {
+			return fmt.Errorf("invalid editor: expected an object")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreferencesPatch_Validate(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	valid := []PreferencesPatch{
+		{},
+		{Locale: str("en")},
+		{Locale: str("de-CH")},
+		{Locale: str("zh-Hant-TW")},
+		{Locale: str("")},
+		{DefaultWorkspaceID: str("")},
+		{DefaultWorkspaceID: str("0b6a4c9e-3f7d-4d2b-9a5e-1c2d3e4f5a6b")},
+		{Notifications: map[string]bool{"invites": false}},
+		{Editor: json.RawMessage(`{"vim": true}`)},
+		{Editor: json.RawMessage(`null`)},
+	}
+	for _, patch := range valid {
+		assert.NoError(t, patch.Validate(), "%+v", patch)
+	}
+
+	invalid := []PreferencesPatch{
+		{Locale: str("english")},
+		{Locale: str("en_US")},
+		{DefaultWorkspaceID: str("home")},
+		{Notifications: map[string]bool{"": true}},
+		{Editor: json.RawMessage(`[1, 2]`)},
+		{Editor: json.RawMessage(`"dark"`)},
+	}
+	for _, patch := range invalid {
+		assert.ErrorContains(t, patch.Validate(), "invalid", "%+v", patch)
+	}
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxPublishedFolders caps the include and exclude lists of a site.
+const MaxPublishedFolders = 50
+
+var siteSlugRe = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{0,62}[a-z0-9])?$`)
+
+// PublishedSite is a workspace published as a static site at URL. The
+// folders and RequireFlag select its notes as in PublishRequest.
+type PublishedSite struct {
+	WorkspaceID    uuid.UUID  `json:"workspace_id"`
+	Slug           string     `json:"slug"`
+	URL            string     `json:"url,omitempty"`
+	IncludeFolders []string   `json:"include_folders"`
+	ExcludeFolders []string   `json:"exclude_folders"`
+	RequireFlag    bool       `json:"require_flag"`
+	PageCount      int        `json:"page_count"`
+	PublishedBy    *uuid.UUID `json:"published_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// PublishRequest publishes a workspace, or exports it as a static bundle,
+// which needs no slug. Only Markdown and Org notes are published: those
+// below an included folder, or anywhere if none is given, and not below
+// an excluded one. A note's publish frontmatter field, or #+PUBLISH
+// keyword in Org, excludes it when false; with RequireFlag it must be
+// true for the note to be published.
+type PublishRequest struct {
+	Slug           string   `json:"slug"`
+	IncludeFolders []string `json:"include_folders,omitempty"`
+	ExcludeFolders []string `json:"exclude_folders,omitempty"`
+	RequireFlag    bool     `json:"require_flag,omitempty"`
+}
+
+// Normalize turns the folders into path prefixes and checks the request.
+// The slug is only checked when needSlug is set.
+func (r *PublishRequest) Normalize(needSlug bool) error {
+	if needSlug && !siteSlugRe.MatchString(r.Slug) {
+		return fmt.Errorf("invalid slug: use 1 to 64 lowercase letters, digits and inner hyphens")
+	}
+	if len(r.IncludeFolders)+len(r.ExcludeFolders) > MaxPublishedFolders {
+		return fmt.Errorf("invalid folders: at most %d", MaxPublishedFolders)
+	}
+	for _, folders := range []*[]string{&r.IncludeFolders, &r.ExcludeFolders} {
+		normalized := make([]string, 0, len(*folders))
+		for _, folder := range *folders {
+			if prefix := FolderPrefix(folder); prefix != "" {
+				normalized = append(normalized, prefix)
+			}
+		}
+		*folders = normalized
+	}
+	return nil
+}
+
+// PublicSite is what serving a published site needs before building it.
+// Revision and UpdatedAt identify its current build: the build changes
+// with the workspace and with the site's settings. PublishedBy is who
+// content policy violations found while building are recorded against:
+// the publisher, or the workspace's owner once the publisher is gone.
+type PublicSite struct {
+	WorkspaceID    uuid.UUID
+	Title          string
+	IncludeFolders []string
+	ExcludeFolders []string
+	RequireFlag    bool
+	Revision       int64
+	UpdatedAt      time.Time
+	PublishedBy    uuid.UUID
+}
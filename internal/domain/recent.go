@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+const (
+	// DefaultRecentFiles is how many recent files are returned when the
+	// request sets no limit.
+	DefaultRecentFiles = 20
+	// MaxRecentFiles caps the limit of one recent-files request, and is
+	// how many opens are kept per member and workspace.
+	MaxRecentFiles = 100
+)
+
+// Recent-file orders: files recently changed by anyone, or recently opened
+// by the member asking.
+const (
+	RecentModified = "modified"
+	RecentOpened   = "opened"
+)
+
+// RecentFile is a file of a recent-files list. OpenedAt is set when the
+// list is by opens.
+type RecentFile struct {
+	FileInfo
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+}
+
+// FileOpenRequest reports that the member opened a file.
+type FileOpenRequest struct {
+	FilePath string `json:"file_path" validate:"required"`
+}
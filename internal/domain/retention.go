@@ -0,0 +1,175 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSyncRetention keeps successful sync operations for a week and
+// everything else, including failures worth debugging, for a month.
+const DefaultSyncRetention = "*/success=7d,*/*=30d"
+
+var (
+	syncOperationTypes    = []string{"upload", "download", "delete", "conflict"}
+	syncOperationStatuses = []string{"pending", "success", "failed"}
+)
+
+// RetentionRule deletes sync operations older than MaxAge. An empty
+// OperationType or Status matches any value.
+type RetentionRule struct {
+	OperationType string
+	Status        string
+	MaxAge        time.Duration
+}
+
+// RetentionPolicy is a set of rules. For each operation the most specific
+// matching rule applies, with Status weighing more than OperationType;
+// operations no rule matches are kept.
+type RetentionPolicy []RetentionRule
+
+// ParseRetentionPolicy reads a comma separated list of
+// "<operation_type>/<status>=<max age>" entries, where either side of the
+// slash may be "*" and the age is a Go duration or a number of days such
+// as "30d".
+func ParseRetentionPolicy(s string) (RetentionPolicy, error) {
+	var policy RetentionPolicy
+	seen := make(map[string]bool)
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, age, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid retention rule %q: expected <type>/<status>=<age>", entry)
+		}
+		opType, status, ok := strings.Cut(strings.TrimSpace(key), "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid retention rule %q: expected <type>/<status>=<age>", entry)
+		}
+
+		rule := RetentionRule{OperationType: wildcard(opType), Status: wildcard(status)}
+		if rule.OperationType != "" && !slices.Contains(syncOperationTypes, rule.OperationType) {
+			return nil, fmt.Errorf("invalid retention rule %q: unknown operation type %q", entry, rule.OperationType)
+		}
+		if rule.Status != "" && !slices.Contains(syncOperationStatuses, rule.Status) {
+			return nil, fmt.Errorf("invalid retention rule %q: unknown status %q", entry, rule.Status)
+		}
+
+		maxAge, err := parseAge(strings.TrimSpace(age))
+		if err != nil {
+			return nil, fmt.Errorf("invalid retention rule %q: %w", entry, err)
+		}
+		rule.MaxAge = maxAge
+
+		if seen[rule.key()] {
+			return nil, fmt.Errorf("invalid retention rule %q: duplicate rule", entry)
+		}
+		seen[rule.key()] = true
+		policy = append(policy, rule)
+	}
+
+	if len(policy) == 0 {
+		return nil, fmt.Errorf("retention policy has no rules")
+	}
+	return policy, nil
+}
+
+// Shortest returns the smallest MaxAge in the policy. Nothing younger than
+// it can be deleted.
+func (p RetentionPolicy) Shortest() time.Duration {
+	var shortest time.Duration
+	for i, rule := range p {
+		if i == 0 || rule.MaxAge < shortest {
+			shortest = rule.MaxAge
+		}
+	}
+	return shortest
+}
+
+// String formats the policy in the syntax ParseRetentionPolicy accepts.
+func (p RetentionPolicy) String() string {
+	entries := make([]string, len(p))
+	for i, rule := range p {
+		entries[i] = rule.key() + "=" + formatAge(rule.MaxAge)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+func (r RetentionRule) key() string {
+	opType, status := r.OperationType, r.Status
+	if opType == "" {
+		opType = "*"
+	}
+	if status == "" {
+		status = "*"
+	}
+	return opType + "/" + status
+}
+
+func wildcard(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "*" {
+		return ""
+	}
+	return s
+}
+
+func parseAge(s string) (time.Duration, error) {
+	var age time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		age = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		age = d
+	}
+	if age <= 0 {
+		return 0, fmt.Errorf("age must be positive, got %q", s)
+	}
+	return age, nil
+}
+
+func formatAge(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return strconv.Itoa(int(d/(24*time.Hour))) + "d"
+	}
+	return d.String()
+}
+
+// TableGrowth is the size of one table as reported by Postgres statistics.
+type TableGrowth struct {
+	TableName      string     `json:"table_name"`
+	LiveRows       int64      `json:"live_rows"`
+	DeadRows       int64      `json:"dead_rows"`
+	TotalBytes     int64      `json:"total_bytes"`
+	LastAutovacuum *time.Time `json:"last_autovacuum,omitempty"`
+}
+
+// SyncOperationSummary counts retained sync operations of one type and
+// status.
+type SyncOperationSummary struct {
+	OperationType string    `json:"operation_type"`
+	Status        string    `json:"status"`
+	Count         int64     `json:"count"`
+	Oldest        time.Time `json:"oldest"`
+}
+
+type TableGrowthReport struct {
+	Tables         []TableGrowth          `json:"tables"`
+	SyncOperations []SyncOperationSummary `json:"sync_operations"`
+	SyncRetention  string                 `json:"sync_retention"`
+}
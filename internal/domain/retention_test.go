@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetentionPolicy(t *testing.T) {
+	t.Run("default policy parses", func(t *testing.T) {
+		policy, err := ParseRetentionPolicy(DefaultSyncRetention)
+		require.NoError(t, err)
+		assert.Equal(t, RetentionPolicy{
+			{Status: "success", MaxAge: 7 * 24 * time.Hour},
+			{MaxAge: 30 * 24 * time.Hour},
+		}, policy)
+		assert.Equal(t, 7*24*time.Hour, policy.Shortest())
+	})
+
+	t.Run("durations and wildcards", func(t *testing.T) {
+		policy, err := ParseRetentionPolicy(" download/* = 12h , upload/failed=90d")
+		require.NoError(t, err)
+		assert.Equal(t, RetentionPolicy{
+			{OperationType: "download", MaxAge: 12 * time.Hour},
+			{OperationType: "upload", Status: "failed", MaxAge: 90 * 24 * time.Hour},
+		}, policy)
+	})
+
+	t.Run("string round-trips", func(t *testing.T) {
+		policy, err := ParseRetentionPolicy("*/*=30d,download/*=12h")
+		require.NoError(t, err)
+		assert.Equal(t, "*/*=30d,download/*=12h0m0s", policy.String())
+
+		again, err := ParseRetentionPolicy(policy.String())
+		require.NoError(t, err)
+		assert.Equal(t, policy, again)
+	})
+
+	invalid := []string{
+		"",
+		"success=7d",
+		"*/success",
+		"sync/*=7d",
+		"*/done=7d",
+		"*/success=soon",
+		"*/success=0d",
+		"*/success=7d,*/success=1d",
+	}
+	for _, s := range invalid {
+		_, err := ParseRetentionPolicy(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MaxSaveSetOperations caps how many operations one save-set may carry.
+const MaxSaveSetOperations = 100
+
+// Save-set operation kinds.
+const (
+	SaveOpWrite  = "write"
+	SaveOpMove   = "move"
+	SaveOpDelete = "delete"
+)
+
+// SaveSetOperation is one change of a save-set. Write stores Content at
+// FilePath, move renames FilePath to NewPath keeping its history, delete
+// removes FilePath.
+//
+// BaseHash is the content hash the client last saw at FilePath; an empty
+// string means the client expects no file there. When set, the save-set
+// is refused if the file has changed since. Without it the operation
+// overwrites whatever is there. NoteID is as in FileUploadRequest.
+type SaveSetOperation struct {
+	Op           string    `json:"op"`
+	FilePath     string    `json:"file_path"`
+	NewPath      string    `json:"new_path,omitempty"`
+	Content      []byte    `json:"content,omitempty"`
+	LastModified time.Time `json:"last_modified,omitempty"`
+	BaseHash     *string   `json:"base_hash,omitempty"`
+	NoteID       string    `json:"note_id,omitempty"`
+}
+
+// SaveSetRequest applies several file changes at once: all of them or, on
+// any conflict or error, none. Each path may appear in one operation only,
+// so the outcome does not depend on their order. RewriteLinks points the
+// links in other notes to moved files at their new paths.
+type SaveSetRequest struct {
+	Operations   []SaveSetOperation `json:"operations"`
+	ClientID     string             `json:"client_id,omitempty"`
+	RewriteLinks bool               `json:"rewrite_links,omitempty"`
+}
+
+func (r SaveSetRequest) Validate() error {
+	if len(r.Operations) == 0 {
+		return fmt.Errorf("invalid save-set: no operations")
+	}
+	if len(r.Operations) > MaxSaveSetOperations {
+		return fmt.Errorf("invalid save-set: at most %d operations", MaxSaveSetOperations)
+	}
+
+	seen := make(map[string]bool)
+	for i, op := range r.Operations {
+		switch op.Op {
+		case SaveOpWrite, SaveOpDelete:
+			if op.NewPath != "" {
+				return fmt.Errorf("invalid save-set operation %d: new_path is only for move", i)
+			}
+		case SaveOpMove:
+			if op.NewPath == "" || op.NewPath == op.FilePath {
+				return fmt.Errorf("invalid save-set operation %d: move needs a different new_path", i)
+			}
+		default:
+			return fmt.Errorf("invalid save-set operation %d: op must be write, move or delete", i)
+		}
+		if op.FilePath == "" {
+			return fmt.Errorf("invalid save-set operation %d: file_path is required", i)
+		}
+		if op.Op != SaveOpWrite && op.Content != nil {
+			return fmt.Errorf("invalid save-set operation %d: content is only for write", i)
+		}
+
+		for _, path := range []string{op.FilePath, op.NewPath} {
+			if path == "" {
+				continue
+			}
+			if seen[path] {
+				return fmt.Errorf("invalid save-set: %s appears in more than one operation", path)
+			}
+			seen[path] = true
+		}
+	}
+	return nil
+}
+
+// Paths returns every path the save-set touches, sorted.
+func (r SaveSetRequest) Paths() []string {
+	var paths []string
+	for _, op := range r.Operations {
+		paths = append(paths, op.FilePath)
+		if op.NewPath != "" {
+			paths = append(paths, op.NewPath)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// SaveSetFileResult is the outcome of one operation, in request order.
+// FilePath is where the file is afterwards; deleted files report the path
+// they had.
+type SaveSetFileResult struct {
+	Op            string `json:"op"`
+	FilePath      string `json:"file_path"`
+	ContentHash   string `json:"content_hash,omitempty"`
+	VersionNumber int32  `json:"version_number,omitempty"`
+	Created       bool   `json:"created,omitempty"`
+	Unchanged     bool   `json:"unchanged,omitempty"`
+}
+
+// SaveSetResult is an applied save-set. All its changes share Revision.
+// Rewritten lists the notes whose links to moved files were rewritten.
+type SaveSetResult struct {
+	Revision  int64               `json:"workspace_revision"`
+	Files     []SaveSetFileResult `json:"files"`
+	Rewritten []SaveSetFileResult `json:"rewritten,omitempty"`
+}
+
+// SaveSetConflict is an operation that did not match the workspace.
+// CurrentHash is the content now at FilePath, empty if there is none.
+type SaveSetConflict struct {
+	Index       int    `json:"index"`
+	FilePath    string `json:"file_path"`
+	Reason      string `json:"reason"`
+	CurrentHash string `json:"current_hash,omitempty"`
+}
+
+// SaveSetConflictError refuses a save-set and lists every conflicting
+// operation, so the client can rebase them all before retrying.
+type SaveSetConflictError struct {
+	Conflicts []SaveSetConflict
+}
+
+func (e *SaveSetConflictError) Error() string {
+	return fmt.Sprintf("save-set conflict: %d operations do not match the workspace", len(e.Conflicts))
+}
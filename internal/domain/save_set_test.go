@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveSetRequest_Validate(t *testing.T) {
+	valid := SaveSetRequest{Operations: []SaveSetOperation{
+		{Op: SaveOpMove, FilePath: "old.md", NewPath: "new.md"},
+		{Op: SaveOpWrite, FilePath: "index.md", Content: []byte("[[new]]")},
+		{Op: SaveOpDelete, FilePath: "stale.md"},
+	}}
+	assert.NoError(t, valid.Validate())
+	assert.Equal(t, []string{"index.md", "new.md", "old.md", "stale.md"}, valid.Paths())
+
+	tests := []struct {
+		name string
+		ops  []SaveSetOperation
+	}{
+		{"empty", nil},
+		{"unknown op", []SaveSetOperation{{Op: "copy", FilePath: "a.md"}}},
+		{"missing path", []SaveSetOperation{{Op: SaveOpDelete}}},
+		{"move without target", []SaveSetOperation{{Op: SaveOpMove, FilePath: "a.md"}}},
+		{"move onto itself", []SaveSetOperation{{Op: SaveOpMove, FilePath: "a.md", NewPath: "a.md"}}},
+		{"delete with content", []SaveSetOperation{{Op: SaveOpDelete, FilePath: "a.md", Content: []byte("x")}}},
+		{"path twice", []SaveSetOperation{
+			{Op: SaveOpWrite, FilePath: "a.md"},
+			{Op: SaveOpMove, FilePath: "b.md", NewPath: "a.md"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, SaveSetRequest{Operations: tt.ops}.Validate(), "invalid save-set")
+		})
+	}
+}
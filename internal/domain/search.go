@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxSearchQueryLength bounds the query string, in characters.
+	MaxSearchQueryLength = 500
+	// MaxSearchResults caps how many files one search ranks. Pages beyond
+	// it are empty.
+	MaxSearchResults = 1000
+	// SearchSnapshotTTL is how long a search's cursor stays valid. A later
+	// cursor runs the search again; see SearchPage.Requeried.
+	SearchSnapshotTTL = 15 * time.Minute
+)
+
+// SearchRequest searches a workspace's notes. Query uses web search
+// syntax: words, "quoted phrases", OR and -excluded words. Cursor is the
+// next_cursor of the previous page and must come with the same query.
+type SearchRequest struct {
+	Query  string
+	Cursor string
+	Limit  int
+}
+
+func (r SearchRequest) Validate() error {
+	if strings.TrimSpace(r.Query) == "" {
+		return fmt.Errorf("invalid query: must not be empty")
+	}
+	if utf8.RuneCountInString(r.Query) > MaxSearchQueryLength {
+		return fmt.Errorf("invalid query: longer than %d characters", MaxSearchQueryLength)
+	}
+	return nil
+}
+
+// SearchPage is one page of search results, best match first. Every page
+// of a search comes from the ranking taken at Revision, so paging does not
+// repeat or skip files while the workspace changes; files deleted since
+// are left out. Stale reports that the workspace has changed since.
+//
+// While the index is still being built, files not indexed yet are missing:
+// Partial is set and IndexProgress tells how far along the index is.
+//
+// If the cursor has expired the search runs again at the current
+// revision, continuing from the same position, and Requeried is set:
+// results may then repeat or skip files.
+type SearchPage struct {
+	Files         []FileInfo `json:"files"`
+	Total         int        `json:"total"`
+	Revision      int64      `json:"workspace_revision"`
+	Stale         bool       `json:"stale"`
+	Requeried     bool       `json:"requeried,omitempty"`
+	Partial       bool       `json:"partial,omitempty"`
+	IndexProgress float64    `json:"index_progress,omitempty"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	NextCursor    string     `json:"next_cursor,omitempty"`
+}
+
+// IndexStatus reports how much of a workspace the search index covers.
+// Files are indexed as they are uploaded; those stored before the index
+// existed, or whose entry is behind, are indexed in the background, most
+// recently modified first. FailedFiles are files the background indexing
+// gave up on, such as those whose content cannot be read; they count as
+// done, so a workspace with some is still ready.
+type IndexStatus struct {
+	WorkspaceID  uuid.UUID `json:"workspace_id"`
+	TotalFiles   int64     `json:"total_files"`
+	IndexedFiles int64     `json:"indexed_files"`
+	FailedFiles  int64     `json:"failed_files"`
+	Progress     float64   `json:"progress"`
+	Ready        bool      `json:"ready"`
+}
+
+func NewIndexStatus(workspaceID uuid.UUID, total, indexed, failed int64) IndexStatus {
+	done := indexed + failed
+	status := IndexStatus{WorkspaceID: workspaceID, TotalFiles: total, IndexedFiles: indexed, FailedFiles: failed, Progress: 1, Ready: done >= total}
+	if total > 0 {
+		status.Progress = float64(done) / float64(total)
+	}
+	return status
+}
@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchRequest_Validate(t *testing.T) {
+	assert.NoError(t, SearchRequest{Query: `"garden plan" -weeds`}.Validate())
+	assert.Error(t, SearchRequest{Query: "  "}.Validate())
+	assert.Error(t, SearchRequest{Query: strings.Repeat("a", MaxSearchQueryLength+1)}.Validate())
+}
+
+func TestNewIndexStatus(t *testing.T) {
+	empty := NewIndexStatus(uuid.New(), 0, 0, 0)
+	assert.True(t, empty.Ready)
+	assert.Equal(t, 1.0, empty.Progress)
+
+	building := NewIndexStatus(uuid.New(), 4, 1, 0)
+	assert.False(t, building.Ready)
+	assert.Equal(t, 0.25, building.Progress)
+
+	failed := NewIndexStatus(uuid.New(), 4, 3, 1)
+	assert.True(t, failed.Ready, "files the backfill gave up on do not hold the index back")
+	assert.Equal(t, 1.0, failed.Progress)
+}
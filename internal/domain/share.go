@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CreateShareRequest struct {
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// ShareLink is a public, unauthenticated link to one file. Only the token's
+// hash is stored, so URL is set only in the response that creates the link.
+type ShareLink struct {
+	ID          uuid.UUID  `json:"id"`
+	WorkspaceID uuid.UUID  `json:"workspace_id"`
+	FilePath    string     `json:"file_path"`
+	Token       string     `json:"-"`
+	URL         string     `json:"url,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type SharedFile struct {
+	FilePath     string
+	ContentHash  string
+	Content      []byte
+	MimeType     string
+	LastModified time.Time
+	// ExpiresAt is when the share link stops working, if ever.
+	ExpiresAt *time.Time
+}
@@ -0,0 +1,49 @@
+package domain
+
+import "github.com/google/uuid"
+
+const (
+	// DefaultStatsDays is how many days of growth stats cover when the
+	// request sets none.
+	DefaultStatsDays = 30
+	// MaxStatsDays caps the growth range of one stats request.
+	MaxStatsDays = 366
+	// StatsLargestFiles is how many of the largest files stats list.
+	StatsLargestFiles = 10
+)
+
+// WorkspaceStats summarizes a workspace for dashboards. Word counts come
+// from file metadata, so files not yet parsed count no words and are
+// grouped under the "unparsed" format.
+type WorkspaceStats struct {
+	WorkspaceID  uuid.UUID       `json:"workspace_id"`
+	TotalFiles   int64           `json:"total_files"`
+	TotalBytes   int64           `json:"total_bytes"`
+	TotalWords   int64           `json:"total_words"`
+	Formats      []FormatStats   `json:"formats"`
+	Growth       []StatsDay      `json:"growth"`
+	LargestFiles []FileSizeStats `json:"largest_files"`
+}
+
+// FormatStats counts the files of one format.
+type FormatStats struct {
+	Format    string `json:"format"`
+	Files     int64  `json:"files"`
+	SizeBytes int64  `json:"size_bytes"`
+	Words     int64  `json:"words"`
+}
+
+// StatsDay is one day of growth, YYYY-MM-DD in the user's time zone:
+// the files created that day that still exist, and the versions saved
+// that retention has kept.
+type StatsDay struct {
+	Date         string `json:"date"`
+	FilesCreated int64  `json:"files_created"`
+	Versions     int64  `json:"versions"`
+}
+
+type FileSizeStats struct {
+	FilePath  string `json:"file_path"`
+	SizeBytes int64  `json:"size_bytes"`
+	Words     *int32 `json:"words,omitempty"`
+}
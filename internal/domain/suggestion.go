@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SuggestionAction string
+
+const (
+	SuggestionArchive SuggestionAction = "archive"
+	SuggestionExport  SuggestionAction = "export"
+	SuggestionDelete  SuggestionAction = "delete"
+	SuggestionDismiss SuggestionAction = "dismiss"
+)
+
+// SuggestionReasonInactive flags a workspace nobody has written to for the
+// configured inactivity period.
+const SuggestionReasonInactive = "inactive"
+
+type WorkspaceSuggestion struct {
+	ID               uuid.UUID          `json:"id"`
+	WorkspaceID      uuid.UUID          `json:"workspace_id"`
+	WorkspaceName    string             `json:"workspace_name"`
+	Reason           string             `json:"reason"`
+	LastActivityAt   time.Time          `json:"last_activity_at"`
+	StorageUsedBytes int64              `json:"storage_used_bytes"`
+	FileCount        int64              `json:"file_count"`
+	Actions          []SuggestionAction `json:"actions"`
+	CreatedAt        time.Time          `json:"created_at"`
+}
@@ -0,0 +1,38 @@
+package domain
+
+import "time"
+
+// LoginThrottlePolicy slows down guessing of passwords and device codes.
+// Each account and client IP may fail a number of times freely; after
+// that it must wait before its next attempt, BaseDelay doubling with each
+// further failure up to MaxLockout. Failures are forgotten once none
+// happened for ResetAfter. Client IPs get more free failures than
+// accounts, as many users may share one.
+type LoginThrottlePolicy struct {
+	AccountFreeAttempts int
+	IPFreeAttempts      int
+	BaseDelay           time.Duration
+	MaxLockout          time.Duration
+	ResetAfter          time.Duration
+}
+
+// Enabled reports whether the policy throttles anything.
+func (p LoginThrottlePolicy) Enabled() bool {
+	return p.BaseDelay > 0 && p.MaxLockout > 0
+}
+
+// Delay returns how long a key with failures consecutive failures must
+// wait before its next attempt, when freeAttempts failures are free.
+func (p LoginThrottlePolicy) Delay(failures, freeAttempts int) time.Duration {
+	if !p.Enabled() || failures <= freeAttempts {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := freeAttempts + 1; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxLockout {
+			return p.MaxLockout
+		}
+	}
+	return min(delay, p.MaxLockout)
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginThrottlePolicy_Delay(t *testing.T) {
+	policy := LoginThrottlePolicy{
+		BaseDelay:  time.Second,
+		MaxLockout: 10 * time.Second,
+		ResetAfter: time.Hour,
+	}
+
+	assert.Zero(t, policy.Delay(3, 3), "free attempts are not delayed")
+	assert.Equal(t, time.Second, policy.Delay(4, 3))
+	assert.Equal(t, 2*time.Second, policy.Delay(5, 3))
+	assert.Equal(t, 8*time.Second, policy.Delay(7, 3))
+	assert.Equal(t, 10*time.Second, policy.Delay(8, 3), "capped at the lockout")
+	assert.Equal(t, 10*time.Second, policy.Delay(1000, 3))
+
+	assert.Zero(t, LoginThrottlePolicy{}.Delay(1000, 0), "a zero policy never throttles")
+}
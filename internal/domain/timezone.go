@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultTimezone is used for users who have not picked a zone.
+const DefaultTimezone = "UTC"
+
+type UpdateProfileRequest struct {
+	Timezone *string `json:"timezone,omitempty"`
+}
+
+// ValidateTimezone accepts IANA zone names such as "Europe/Berlin". "Local"
+// is rejected because it would mean the server's zone.
+func ValidateTimezone(name string) error {
+	if name == "" || name == "Local" {
+		return fmt.Errorf("invalid timezone: %q", name)
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("invalid timezone: %q", name)
+	}
+	return nil
+}
+
+// Location returns the user's preferred zone, falling back to UTC when the
+// stored name cannot be loaded.
+func (u User) Location() *time.Location {
+	if u.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// DayBounds returns the start and end, in UTC, of the calendar day in loc
+// that contains t. Days are not always 24 hours long across DST changes,
+// so the end is computed on the calendar rather than by adding a duration.
+func DayBounds(t time.Time, loc *time.Location) (start, end time.Time) {
+	local := t.In(loc)
+	y, m, d := local.Date()
+	start = time.Date(y, m, d, 0, 0, 0, 0, loc)
+	end = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+	return start.UTC(), end.UTC()
+}
+
+// LocalDate formats the calendar day in loc that contains t as YYYY-MM-DD,
+// the form daily notes are named by.
+func LocalDate(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(time.DateOnly)
+}
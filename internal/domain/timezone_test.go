@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTimezone(t *testing.T) {
+	assert.NoError(t, ValidateTimezone("UTC"))
+	assert.NoError(t, ValidateTimezone("America/New_York"))
+	assert.Error(t, ValidateTimezone(""))
+	assert.Error(t, ValidateTimezone("Local"))
+	assert.Error(t, ValidateTimezone("Mars/Olympus_Mons"))
+}
+
+func TestUser_Location(t *testing.T) {
+	assert.Equal(t, time.UTC, User{}.Location())
+	assert.Equal(t, time.UTC, User{Timezone: "bogus"}.Location())
+	assert.Equal(t, "Asia/Tokyo", User{Timezone: "Asia/Tokyo"}.Location().String())
+}
+
+func TestDayBounds(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	t.Run("late evening belongs to the local day", func(t *testing.T) {
+		// 02:00 UTC on March 2nd is still March 1st in New York.
+		instant := time.Date(2024, 3, 2, 2, 0, 0, 0, time.UTC)
+
+		start, end := DayBounds(instant, newYork)
+		assert.Equal(t, time.Date(2024, 3, 1, 5, 0, 0, 0, time.UTC), start)
+		assert.Equal(t, time.Date(2024, 3, 2, 5, 0, 0, 0, time.UTC), end)
+		assert.Equal(t, "2024-03-01", LocalDate(instant, newYork))
+	})
+
+	t.Run("DST start gives a 23 hour day", func(t *testing.T) {
+		instant := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+		start, end := DayBounds(instant, newYork)
+		assert.Equal(t, 23*time.Hour, end.Sub(start))
+	})
+}
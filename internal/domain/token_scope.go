@@ -0,0 +1,133 @@
+package domain
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Scopes restrict what an API token may do. Tokens from signing in carry
+// none and have full access; tokens users mint for scripts and
+// integrations carry read or write, optionally narrowed to workspaces.
+const (
+	// ScopeRead allows GET and HEAD requests.
+	ScopeRead = "read"
+	// ScopeWrite allows requests of any method. It implies ScopeRead.
+	ScopeWrite = "write"
+	// ScopeAdmin allows the operator endpoints, for administrators.
+	ScopeAdmin = "admin"
+	// ScopeWorkspacePrefix starts a scope naming a workspace, as in
+	// "workspace:<id>". Tokens with such scopes reach only those
+	// workspaces.
+	ScopeWorkspacePrefix = "workspace:"
+
+	// MaxTokenScopes caps the scopes of one token.
+	MaxTokenScopes = 20
+	// MaxTokenNameLength caps the label users give a token.
+	MaxTokenNameLength = 100
+)
+
+// TokenScopes are the scopes of a token, sorted. Empty means full access.
+type TokenScopes []string
+
+// ParseTokenScopes validates the scopes of a token being minted and
+// returns them deduplicated and sorted. Minted tokens are always
+// restricted, so read or write is required.
+func ParseTokenScopes(scopes []string) (TokenScopes, error) {
+	if len(scopes) > MaxTokenScopes {
+		return nil, fmt.Errorf("invalid scopes: at most %d allowed", MaxTokenScopes)
+	}
+
+	seen := make(map[string]bool, len(scopes))
+	parsed := make(TokenScopes, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		switch {
+		case scope == ScopeRead, scope == ScopeWrite, scope == ScopeAdmin:
+		case strings.HasPrefix(scope, ScopeWorkspacePrefix):
+			id, err := uuid.Parse(strings.TrimPrefix(scope, ScopeWorkspacePrefix))
+			if err != nil {
+				return nil, fmt.Errorf("invalid scope %q: workspace ID is not a UUID", scope)
+			}
+			scope = ScopeWorkspacePrefix + id.String()
+		default:
+			return nil, fmt.Errorf("invalid scope %q", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			parsed = append(parsed, scope)
+		}
+	}
+
+	if !seen[ScopeRead] && !seen[ScopeWrite] {
+		return nil, fmt.Errorf("invalid scopes: read or write is required")
+	}
+	sort.Strings(parsed)
+	return parsed, nil
+}
+
+// Restricted reports whether the token is limited by its scopes.
+func (s TokenScopes) Restricted() bool {
+	return len(s) > 0
+}
+
+func (s TokenScopes) has(scope string) bool {
+	for _, have := range s {
+		if have == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsMethod reports whether the token may make requests of method.
+func (s TokenScopes) AllowsMethod(method string) bool {
+	if !s.Restricted() || s.has(ScopeWrite) {
+		return true
+	}
+	return s.has(ScopeRead) && (method == http.MethodGet || method == http.MethodHead)
+}
+
+// AllowsAdmin reports whether the token may use the operator endpoints.
+// Its user must still be an administrator.
+func (s TokenScopes) AllowsAdmin() bool {
+	return !s.Restricted() || s.has(ScopeAdmin)
+}
+
+// Workspaces returns the workspaces the token is limited to, or nil when
+// it may reach all of its user's.
+func (s TokenScopes) Workspaces() []uuid.UUID {
+	var ids []uuid.UUID
+	for _, scope := range s {
+		if id, ok := strings.CutPrefix(scope, ScopeWorkspacePrefix); ok {
+			if parsed, err := uuid.Parse(id); err == nil {
+				ids = append(ids, parsed)
+			}
+		}
+	}
+	return ids
+}
+
+// AllowsWorkspace reports whether the token may reach workspaceID.
+func (s TokenScopes) AllowsWorkspace(workspaceID uuid.UUID) bool {
+	workspaces := s.Workspaces()
+	if workspaces == nil {
+		return true
+	}
+	for _, id := range workspaces {
+		if id == workspaceID {
+			return true
+		}
+	}
+	return false
+}
+
+// CreatedToken is a token just minted. Token is its plaintext, which is
+// only ever shown once.
+type CreatedToken struct {
+	APIToken
+	Token string `json:"token"`
+}
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTokenScopes(t *testing.T) {
+	workspaceID := uuid.New()
+
+	scopes, err := ParseTokenScopes([]string{" Write", "read", "write", "workspace:" + workspaceID.String()})
+	require.NoError(t, err)
+	assert.Equal(t, TokenScopes{"read", "workspace:" + workspaceID.String(), "write"}, scopes)
+
+	for _, bad := range [][]string{
+		nil,
+		{"admin"},
+		{"workspace:" + workspaceID.String()},
+		{"read", "delete"},
+		{"read", "workspace:not-a-uuid"},
+	} {
+		_, err := ParseTokenScopes(bad)
+		assert.ErrorContains(t, err, "invalid scope", bad)
+	}
+}
+
+func TestTokenScopes(t *testing.T) {
+	mine, other := uuid.New(), uuid.New()
+
+	var full TokenScopes
+	assert.False(t, full.Restricted())
+	assert.True(t, full.AllowsMethod(http.MethodDelete))
+	assert.True(t, full.AllowsAdmin())
+	assert.True(t, full.AllowsWorkspace(other))
+
+	read := TokenScopes{ScopeRead}
+	assert.True(t, read.AllowsMethod(http.MethodGet))
+	assert.True(t, read.AllowsMethod(http.MethodHead))
+	assert.False(t, read.AllowsMethod(http.MethodPost))
+	assert.False(t, read.AllowsAdmin())
+	assert.Nil(t, read.Workspaces())
+
+	write := TokenScopes{ScopeAdmin, ScopeWrite, ScopeWorkspacePrefix + mine.String()}
+	assert.True(t, write.AllowsMethod(http.MethodGet), "write implies read")
+	assert.True(t, write.AllowsMethod(http.MethodPatch))
+	assert.True(t, write.AllowsAdmin())
+	assert.Equal(t, []uuid.UUID{mine}, write.Workspaces())
+	assert.True(t, write.AllowsWorkspace(mine))
+	assert.False(t, write.AllowsWorkspace(other))
+}
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	TreeNodeFolder = "folder"
+	TreeNodeFile   = "file"
+)
+
+// FileTreeNode is a folder or file in the hierarchy derived from file
+// paths. Folder paths end in "/" and the root's is the listed prefix. A
+// folder's FileCount and SizeBytes cover everything below it.
+type FileTreeNode struct {
+	Name      string          `json:"name"`
+	Path      string          `json:"path"`
+	Type      string          `json:"type"`
+	FileCount int64           `json:"file_count,omitempty"`
+	SizeBytes int64           `json:"size_bytes"`
+	File      *FileInfo       `json:"file,omitempty"`
+	Children  []*FileTreeNode `json:"children,omitempty"`
+}
+
+// FolderDeleteResult summarizes a recursive folder delete.
+type FolderDeleteResult struct {
+	Folder       string `json:"folder"`
+	DeletedFiles int64  `json:"deleted_files"`
+	FreedBytes   int64  `json:"freed_bytes"`
+	Revision     int64  `json:"workspace_revision"`
+}
+
+// FolderPrefix normalizes a folder path to the prefix its files share:
+// no leading slash and one trailing slash. The workspace root is "".
+func FolderPrefix(folder string) string {
+	folder = strings.Trim(folder, "/")
+	if folder == "" {
+		return ""
+	}
+	return folder + "/"
+}
+
+// BuildFileTree nests files under the folder prefix root. Files outside
+// root are ignored. Folders sort before files, each by name.
+func BuildFileTree(root string, files []FileInfo) *FileTreeNode {
+	rootNode := &FileTreeNode{Path: root, Type: TreeNodeFolder}
+	if root != "" {
+		rootNode.Name = path.Base(root)
+	}
+	folders := map[string]*FileTreeNode{root: rootNode}
+
+	for i := range files {
+		file := &files[i]
+		rel, ok := strings.CutPrefix(file.FilePath, root)
+		if !ok || rel == "" {
+			continue
+		}
+
+		parent := rootNode
+		parent.FileCount++
+		parent.SizeBytes += file.SizeBytes
+
+		segments := strings.Split(rel, "/")
+		folderPath := root
+		for _, name := range segments[:len(segments)-1] {
+			folderPath += name + "/"
+			folder, ok := folders[folderPath]
+			if !ok {
+				folder = &FileTreeNode{Name: name, Path: folderPath, Type: TreeNodeFolder}
+				folders[folderPath] = folder
+				parent.Children = append(parent.Children, folder)
+			}
+			folder.FileCount++
+			folder.SizeBytes += file.SizeBytes
+			parent = folder
+		}
+
+		parent.Children = append(parent.Children, &FileTreeNode{
+			Name:      segments[len(segments)-1],
+			Path:      file.FilePath,
+			Type:      TreeNodeFile,
+			SizeBytes: file.SizeBytes,
+			File:      file,
+		})
+	}
+
+	for _, folder := range folders {
+		sort.Slice(folder.Children, func(i, j int) bool {
+			a, b := folder.Children[i], folder.Children[j]
+			if a.Type != b.Type {
+				return a.Type == TreeNodeFolder
+			}
+			return a.Name < b.Name
+		})
+	}
+	return rootNode
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFolderPrefix(t *testing.T) {
+	assert.Equal(t, "", FolderPrefix(""))
+	assert.Equal(t, "", FolderPrefix("/"))
+	assert.Equal(t, "journal/", FolderPrefix("journal"))
+	assert.Equal(t, "journal/2026/", FolderPrefix("/journal/2026/"))
+}
+
+func TestBuildFileTree(t *testing.T) {
+	files := []FileInfo{
+		{FilePath: "index.md", SizeBytes: 1},
+		{FilePath: "journal/2026/01.org", SizeBytes: 10},
+		{FilePath: "journal/2026/02.org", SizeBytes: 20},
+		{FilePath: "journal/readme.md", SizeBytes: 5},
+		{FilePath: "zettel/a.md", SizeBytes: 3},
+	}
+
+	t.Run("whole workspace", func(t *testing.T) {
+		tree := BuildFileTree("", files)
+		assert.Equal(t, int64(5), tree.FileCount)
+		assert.Equal(t, int64(39), tree.SizeBytes)
+
+		require.Len(t, tree.Children, 3)
+		assert.Equal(t, "journal", tree.Children[0].Name, "folders come first")
+		assert.Equal(t, "zettel", tree.Children[1].Name)
+		assert.Equal(t, "index.md", tree.Children[2].Name)
+
+		journal := tree.Children[0]
+		assert.Equal(t, "journal/", journal.Path)
+		assert.Equal(t, int64(3), journal.FileCount)
+		assert.Equal(t, int64(35), journal.SizeBytes)
+		require.Len(t, journal.Children, 2)
+		assert.Equal(t, "journal/2026/", journal.Children[0].Path)
+		assert.Equal(t, TreeNodeFile, journal.Children[1].Type)
+		assert.Equal(t, "journal/readme.md", journal.Children[1].File.FilePath)
+	})
+
+	t.Run("subtree", func(t *testing.T) {
+		tree := BuildFileTree("journal/2026/", files)
+		assert.Equal(t, "2026", tree.Name)
+		assert.Equal(t, int64(2), tree.FileCount)
+		require.Len(t, tree.Children, 2)
+		assert.Equal(t, "01.org", tree.Children[0].Name)
+	})
+}
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// UsageMonthLayout formats the months of usage reports.
+	UsageMonthLayout = "2006-01"
+	// MaxUsageMonths caps how many months one usage report covers.
+	MaxUsageMonths = 24
+)
+
+// UsageAllowance is what a tier includes each month. Usage beyond it is
+// overage, reported for billing but not refused. Transfer counts request
+// and response bodies alike.
+type UsageAllowance struct {
+	APIRequests   int64 `json:"api_requests"`
+	TransferBytes int64 `json:"transfer_bytes"`
+}
+
+func (t UserTier) GetMonthlyAllowance() UsageAllowance {
+	switch t {
+	case TierPremium:
+		return UsageAllowance{APIRequests: 2_000_000, TransferBytes: 100 * 1024 * 1024 * 1024}
+	case TierEnterprise:
+		return UsageAllowance{APIRequests: 20_000_000, TransferBytes: 1024 * 1024 * 1024 * 1024}
+	default:
+		return UsageAllowance{APIRequests: 100_000, TransferBytes: 1024 * 1024 * 1024}
+	}
+}
+
+// MonthlyUsage is what a user used in one calendar month, UTC. Upload
+// bytes are request bodies and download bytes response bodies of the
+// user's API requests.
+type MonthlyUsage struct {
+	Month         string         `json:"month"`
+	APIRequests   int64          `json:"api_requests"`
+	UploadBytes   int64          `json:"upload_bytes"`
+	DownloadBytes int64          `json:"download_bytes"`
+	Allowance     UsageAllowance `json:"allowance"`
+	Overage       UsageAllowance `json:"overage"`
+}
+
+// NewMonthlyUsage reports usage in month against allowance.
+func NewMonthlyUsage(month time.Time, requests, uploadBytes, downloadBytes int64, allowance UsageAllowance) MonthlyUsage {
+	return MonthlyUsage{
+		Month:         month.Format(UsageMonthLayout),
+		APIRequests:   requests,
+		UploadBytes:   uploadBytes,
+		DownloadBytes: downloadBytes,
+		Allowance:     allowance,
+		Overage: UsageAllowance{
+			APIRequests:   max(0, requests-allowance.APIRequests),
+			TransferBytes: max(0, uploadBytes+downloadBytes-allowance.TransferBytes),
+		},
+	}
+}
+
+// UsageReport is a user's usage over recent months, newest first. Counts
+// reach the report within about a minute.
+type UsageReport struct {
+	Tier   UserTier       `json:"tier"`
+	Months []MonthlyUsage `json:"months"`
+}
+
+// UsageMonth returns the first instant of t's month, UTC.
+func UsageMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// ParseUsageMonth parses a month written YYYY-MM.
+func ParseUsageMonth(s string) (time.Time, error) {
+	month, err := time.Parse(UsageMonthLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid month %q: use YYYY-MM", s)
+	}
+	return month, nil
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMonthlyUsage(t *testing.T) {
+	month := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	allowance := UsageAllowance{APIRequests: 100, TransferBytes: 1000}
+
+	within := NewMonthlyUsage(month, 100, 400, 600, allowance)
+	assert.Equal(t, "2026-03", within.Month)
+	assert.Equal(t, UsageAllowance{}, within.Overage)
+
+	over := NewMonthlyUsage(month, 150, 700, 800, allowance)
+	assert.Equal(t, UsageAllowance{APIRequests: 50, TransferBytes: 500}, over.Overage)
+}
+
+func TestUserTier_GetMonthlyAllowance(t *testing.T) {
+	free, premium, enterprise := TierFree.GetMonthlyAllowance(), TierPremium.GetMonthlyAllowance(), TierEnterprise.GetMonthlyAllowance()
+	assert.Less(t, free.APIRequests, premium.APIRequests)
+	assert.Less(t, premium.APIRequests, enterprise.APIRequests)
+	assert.Less(t, free.TransferBytes, premium.TransferBytes)
+	assert.Less(t, premium.TransferBytes, enterprise.TransferBytes)
+	assert.Equal(t, free, UserTier("unknown").GetMonthlyAllowance())
+}
+
+func TestUsageMonth(t *testing.T) {
+	east := time.FixedZone("UTC+9", 9*60*60)
+	assert.Equal(t, time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC),
+		UsageMonth(time.Date(2026, time.March, 1, 5, 0, 0, 0, east)), "months are UTC")
+
+	month, err := ParseUsageMonth("2026-10")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC), month)
+
+	for _, bad := range []string{"", "2026-13", "2026-10-01", "October"} {
+		_, err := ParseUsageMonth(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,209 @@
+package domain
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Vault sync limits. They keep each request small enough for a phone on
+// a slow or metered connection to finish it, and are advertised in the
+// vault manifest so plugins size their batches without guessing.
+const (
+	MaxVaultPushChanges     = 50
+	MaxVaultPushBytes       = 8 << 20
+	DefaultVaultPullChanges = 200
+	MaxVaultPullChanges     = 1000
+	DefaultVaultInlineBytes = 1 << 20
+	MaxVaultInlineBytes     = 4 << 20
+)
+
+// VaultConflictCopyPattern describes how ConflictCopyPath names copies.
+const VaultConflictCopyPattern = "{name}.conflict-{hash8}{ext}"
+
+// VaultLimits are the batch sizes the vault endpoints accept: changes and
+// content bytes per push, changes per pull, and the content a pull inlines
+// by default and at most.
+type VaultLimits struct {
+	PushChanges    int   `json:"push_changes"`
+	PushBytes      int64 `json:"push_bytes"`
+	PullChanges    int   `json:"pull_changes"`
+	InlineBytes    int64 `json:"inline_bytes"`
+	MaxInlineBytes int64 `json:"max_inline_bytes"`
+}
+
+var DefaultVaultLimits = VaultLimits{
+	PushChanges:    MaxVaultPushChanges,
+	PushBytes:      MaxVaultPushBytes,
+	PullChanges:    MaxVaultPullChanges,
+	InlineBytes:    DefaultVaultInlineBytes,
+	MaxInlineBytes: MaxVaultInlineBytes,
+}
+
+// VaultFile is the sync state of one file of a vault. Attachments are
+// images, PDFs and other files that are not text; pulls never inline
+// them, and plugins download them from the file endpoint, which serves
+// byte ranges so large downloads can resume.
+type VaultFile struct {
+	Path        string    `json:"path"`
+	ContentHash string    `json:"content_hash"`
+	Size        int64     `json:"size"`
+	MTime       time.Time `json:"mtime"`
+	Attachment  bool      `json:"attachment,omitempty"`
+}
+
+// VaultManifest is a workspace as a vault: every file, and the cursor to
+// pull changes from once the plugin has them. The cursor is read before
+// the files, so pulling from it may repeat changes the files already
+// show, but never misses one.
+type VaultManifest struct {
+	Revision     int64       `json:"workspace_revision"`
+	Cursor       int64       `json:"cursor"`
+	Files        []VaultFile `json:"files"`
+	Limits       VaultLimits `json:"limits"`
+	ConflictCopy string      `json:"conflict_copy"`
+}
+
+// VaultChange is the current state of a path that changed after the
+// cursor: deleted, or a file whose content is inlined when it fit the
+// pull's budget. Empty files always count as inlined.
+type VaultChange struct {
+	Path        string     `json:"path"`
+	Deleted     bool       `json:"deleted,omitempty"`
+	ContentHash string     `json:"content_hash,omitempty"`
+	Size        int64      `json:"size,omitempty"`
+	MTime       *time.Time `json:"mtime,omitempty"`
+	Attachment  bool       `json:"attachment,omitempty"`
+	Content     []byte     `json:"content,omitempty"`
+}
+
+// VaultChanges is one page of a delta pull. A path appears once, with its
+// state now rather than each step on the way. DeletedFolders were deleted
+// with everything in them; plugins apply them before Changes, which
+// include files created in them afterwards. With HasMore, the next page
+// starts at Cursor.
+type VaultChanges struct {
+	Cursor         int64         `json:"cursor"`
+	HasMore        bool          `json:"has_more"`
+	DeletedFolders []string      `json:"deleted_folders,omitempty"`
+	Changes        []VaultChange `json:"changes"`
+}
+
+// VaultPullOptions pick the page of a delta pull: the changes after Since,
+// at most Limit of them, inlining up to InlineBytes of content. Zero
+// values take the defaults.
+type VaultPullOptions struct {
+	Since       int64
+	Limit       int
+	InlineBytes int64
+}
+
+func (o *VaultPullOptions) Normalize() error {
+	if o.Since < 0 {
+		return fmt.Errorf("invalid since: must not be negative")
+	}
+	switch {
+	case o.Limit < 0:
+		return fmt.Errorf("invalid limit: must be positive")
+	case o.Limit == 0:
+		o.Limit = DefaultVaultPullChanges
+	case o.Limit > MaxVaultPullChanges:
+		o.Limit = MaxVaultPullChanges
+	}
+	switch {
+	case o.InlineBytes < 0:
+		return fmt.Errorf("invalid inline_bytes: must not be negative")
+	case o.InlineBytes == 0:
+		o.InlineBytes = DefaultVaultInlineBytes
+	case o.InlineBytes > MaxVaultInlineBytes:
+		o.InlineBytes = MaxVaultInlineBytes
+	}
+	return nil
+}
+
+// VaultPushRequest pushes a vault's local changes, each a save-set
+// operation whose BaseHash is the hash the plugin last pulled. Unlike a
+// save-set, every change is applied or refused on its own, so one
+// conflict does not hold back the rest of the batch.
+type VaultPushRequest struct {
+	ClientID string             `json:"client_id,omitempty" validate:"max=255"`
+	Changes  []SaveSetOperation `json:"changes"`
+}
+
+func (r VaultPushRequest) Validate() error {
+	if len(r.Changes) == 0 {
+		return fmt.Errorf("invalid vault push: no changes")
+	}
+	if len(r.Changes) > MaxVaultPushChanges {
+		return fmt.Errorf("invalid vault push: at most %d changes", MaxVaultPushChanges)
+	}
+	var size int64
+	for _, change := range r.Changes {
+		size += int64(len(change.Content))
+	}
+	if size > MaxVaultPushBytes {
+		return fmt.Errorf("invalid vault push: content over %d bytes, split it into smaller pushes", MaxVaultPushBytes)
+	}
+	return SaveSetRequest{Operations: r.Changes}.Validate()
+}
+
+// Outcomes of a pushed change.
+const (
+	VaultPushApplied   = "applied"
+	VaultPushUnchanged = "unchanged"
+	VaultPushConflict  = "conflict"
+	VaultPushRejected  = "rejected"
+)
+
+// VaultPushResult is the outcome of one change, in request order.
+//
+// On conflict the workspace keeps its version, CurrentHash. A conflicting
+// write is saved beside it at ConflictPath, named by ConflictCopyPath, so
+// neither edit is lost; plugins pull both and let the user merge them. A
+// conflicting delete or move is dropped. Rejected changes broke a limit
+// of the workspace, which Error names.
+type VaultPushResult struct {
+	Op           string `json:"op"`
+	FilePath     string `json:"file_path"`
+	Status       string `json:"status"`
+	ContentHash  string `json:"content_hash,omitempty"`
+	CurrentHash  string `json:"current_hash,omitempty"`
+	ConflictPath string `json:"conflict_path,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// VaultPushResponse reports every change of a push and the workspace
+// revision after it.
+type VaultPushResponse struct {
+	Revision int64             `json:"workspace_revision"`
+	Results  []VaultPushResult `json:"results"`
+}
+
+// ConflictCopyPath names the copy of a conflicting version with content
+// hash hash, e.g. notes/todo.conflict-1a2b3c4d.md, as the noture CLI does.
+func ConflictCopyPath(p, hash string) string {
+	ext := path.Ext(p)
+	if ext == path.Base(p) {
+		ext = ""
+	}
+	return strings.TrimSuffix(p, ext) + ".conflict-" + hash[:min(8, len(hash))] + ext
+}
+
+// VaultAttachment reports whether a file of mimeType is an attachment
+// rather than a note: anything but text, JSON (Obsidian's canvases) and
+// other structured text.
+func VaultAttachment(mimeType string) bool {
+	mediaType, _, _ := strings.Cut(mimeType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	switch {
+	case mediaType == "":
+		return false
+	case strings.HasPrefix(mediaType, "text/"):
+		return false
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"),
+		mediaType == "application/xml", mediaType == "application/yaml":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConflictCopyPath(t *testing.T) {
+	assert.Equal(t, "notes/todo.conflict-1a2b3c4d.md", ConflictCopyPath("notes/todo.md", "1a2b3c4d5e6f"))
+	assert.Equal(t, "Makefile.conflict-1a2b3c4d", ConflictCopyPath("Makefile", "1a2b3c4d5e6f"))
+	assert.Equal(t, "v1.2/notes.conflict-1a2b3c4d", ConflictCopyPath("v1.2/notes", "1a2b3c4d5e6f"))
+	assert.Equal(t, ".obsidian/.hotkeys.conflict-1a2b3c4d", ConflictCopyPath(".obsidian/.hotkeys", "1a2b3c4d5e6f"))
+}
+
+func TestVaultAttachment(t *testing.T) {
+	for _, mimeType := range []string{"", "text/markdown", "text/plain; charset=utf-8", "application/json", "application/ld+json"} {
+		assert.False(t, VaultAttachment(mimeType), mimeType)
+	}
+	for _, mimeType := range []string{"image/png", "application/pdf", "audio/mpeg", "application/octet-stream"} {
+		assert.True(t, VaultAttachment(mimeType), mimeType)
+	}
+}
+
+func TestVaultPullOptions_Normalize(t *testing.T) {
+	opts := VaultPullOptions{}
+	require.NoError(t, opts.Normalize())
+	assert.Equal(t, VaultPullOptions{Limit: DefaultVaultPullChanges, InlineBytes: DefaultVaultInlineBytes}, opts)
+
+	opts = VaultPullOptions{Since: 7, Limit: 5000, InlineBytes: 1 << 30}
+	require.NoError(t, opts.Normalize())
+	assert.Equal(t, VaultPullOptions{Since: 7, Limit: MaxVaultPullChanges, InlineBytes: MaxVaultInlineBytes}, opts)
+
+	assert.ErrorContains(t, (&VaultPullOptions{Since: -1}).Normalize(), "invalid since")
+	assert.ErrorContains(t, (&VaultPullOptions{InlineBytes: -1}).Normalize(), "invalid inline_bytes")
+}
+
+func TestVaultPushRequest_Validate(t *testing.T) {
+	write := SaveSetOperation{Op: SaveOpWrite, FilePath: "a.md", Content: []byte("# A")}
+	assert.NoError(t, VaultPushRequest{Changes: []SaveSetOperation{write}}.Validate())
+	assert.ErrorContains(t, VaultPushRequest{}.Validate(), "no changes")
+
+	many := make([]SaveSetOperation, MaxVaultPushChanges+1)
+	assert.ErrorContains(t, VaultPushRequest{Changes: many}.Validate(), "at most")
+
+	large := write
+	large.Content = []byte(strings.Repeat("x", MaxVaultPushBytes+1))
+	assert.ErrorContains(t, VaultPushRequest{Changes: []SaveSetOperation{large}}.Validate(), "smaller pushes")
+
+	assert.ErrorContains(t, VaultPushRequest{Changes: []SaveSetOperation{write, write}}.Validate(), "more than one operation")
+}
@@ -1,7 +1,10 @@
 package domain
 
 import (
+	"fmt"
+	"regexp"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
@@ -40,29 +43,152 @@ func (t UserTier) GetMaxWorkspaces() int {
 	}
 }
 
+// FileLimits cap the files of a workspace by its tier: the size of each
+// file and how many files it holds. -1 means no limit.
+type FileLimits struct {
+	MaxFileSizeBytes int64 `json:"max_file_size_bytes"`
+	MaxFiles         int64 `json:"max_files"`
+}
+
+func (t UserTier) GetFileLimits() FileLimits {
+	switch t {
+	case TierPremium:
+		return FileLimits{MaxFileSizeBytes: 250 * 1024 * 1024, MaxFiles: 100000}
+	case TierEnterprise:
+		return FileLimits{MaxFileSizeBytes: 2 * 1024 * 1024 * 1024, MaxFiles: -1}
+	default:
+		return FileLimits{MaxFileSizeBytes: 10 * 1024 * 1024, MaxFiles: 1000}
+	}
+}
+
+// Check returns an error if a file of sizeBytes may not be stored in a
+// workspace holding fileCount files, added to them when newFile.
+func (l FileLimits) Check(sizeBytes, fileCount int64, newFile bool) error {
+	if l.MaxFileSizeBytes >= 0 && sizeBytes > l.MaxFileSizeBytes {
+		return fmt.Errorf("file too large: %d bytes, limit %d bytes", sizeBytes, l.MaxFileSizeBytes)
+	}
+	if newFile && l.MaxFiles >= 0 && fileCount >= l.MaxFiles {
+		return fmt.Errorf("file limit reached: workspace holds %d files, limit %d", fileCount, l.MaxFiles)
+	}
+	return nil
+}
+
+// VersionRetention is how much history of each file a tier keeps: the
+// newest MaxVersions versions, none older than MaxAgeDays. The newest
+// version, the file's current content, is always kept. -1 means no limit.
+type VersionRetention struct {
+	MaxVersions int `json:"max_versions"`
+	MaxAgeDays  int `json:"max_age_days"`
+}
+
+// Unlimited reports whether no version is ever pruned.
+func (r VersionRetention) Unlimited() bool {
+	return r.MaxVersions < 0 && r.MaxAgeDays < 0
+}
+
+func (t UserTier) GetVersionRetention() VersionRetention {
+	switch t {
+	case TierPremium:
+		return VersionRetention{MaxVersions: 100, MaxAgeDays: 365}
+	case TierEnterprise:
+		return VersionRetention{MaxVersions: -1, MaxAgeDays: -1}
+	default:
+		return VersionRetention{MaxVersions: 5, MaxAgeDays: 7}
+	}
+}
+
 type User struct {
 	ID               uuid.UUID `json:"id"`
 	Email            string    `json:"email"`
 	Tier             UserTier  `json:"tier"`
 	StorageUsedBytes int64     `json:"storage_used_bytes"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	Timezone         string    `json:"timezone"`
+	IsAdmin          bool      `json:"is_admin,omitempty"`
+	EmailVerified    bool      `json:"email_verified"`
+	// DeleteAfter is when the account will be purged, if its deletion
+	// was requested.
+	DeleteAfter *time.Time `json:"delete_after,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
 type Workspace struct {
-	ID                uuid.UUID `json:"id"`
-	UserID            uuid.UUID `json:"user_id"`
-	Name              string    `json:"name"`
-	StorageLimitBytes int64     `json:"storage_limit_bytes"`
-	StorageUsedBytes  int64     `json:"storage_used_bytes"`
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
+	ID                uuid.UUID  `json:"id"`
+	UserID            uuid.UUID  `json:"user_id"`
+	Name              string     `json:"name"`
+	StorageLimitBytes int64      `json:"storage_limit_bytes"`
+	StorageUsedBytes  int64      `json:"storage_used_bytes"`
+	FileCount         int64      `json:"file_count"`
+	ArchivedAt        *time.Time `json:"archived_at,omitempty"`
+	Icon              string     `json:"icon"`
+	Color             string     `json:"color"`
+	Description       string     `json:"description"`
+	Revision          int64      `json:"revision"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+
+	// Role is the requesting user's role in the workspace.
+	Role WorkspaceRole `json:"role,omitempty"`
+	// SortOrder is where the requesting user placed the workspace in their
+	// sidebar; each member orders workspaces for themselves.
+	SortOrder int32 `json:"sort_order"`
 }
 
 type CreateWorkspaceRequest struct {
 	Name string `json:"name" validate:"required,min=1,max=255"`
 }
 
+// TemplateWorkspaceRequest names a workspace created from a template.
+// Name defaults to the template's.
+type TemplateWorkspaceRequest struct {
+	Name string `json:"name,omitempty" validate:"max=255"`
+}
+
+// CloneWorkspaceRequest copies a workspace into a new one. Name defaults
+// to the source's name with " (copy)" appended. With IncludeVersions each
+// file's version history is copied too, not only its current content.
+type CloneWorkspaceRequest struct {
+	Name            string `json:"name,omitempty" validate:"max=255"`
+	IncludeVersions bool   `json:"include_versions,omitempty"`
+}
+
+// UpdateWorkspaceRequest changes a workspace's display metadata. Omitted
+// fields are left as they are; an empty string clears icon, color or
+// description. SortOrder is the caller's own and any member may set it;
+// the other fields are shared and only the owner may change them.
+type UpdateWorkspaceRequest struct {
+	Icon        *string `json:"icon,omitempty"`
+	Color       *string `json:"color,omitempty"`
+	Description *string `json:"description,omitempty"`
+	SortOrder   *int32  `json:"sort_order,omitempty"`
+}
+
+const (
+	MaxWorkspaceIconLength        = 64
+	MaxWorkspaceDescriptionLength = 1000
+)
+
+var workspaceColorRe = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// ChangesDisplay reports whether the request changes what every member
+// sees, rather than only the caller's sort order.
+func (r UpdateWorkspaceRequest) ChangesDisplay() bool {
+	return r.Icon != nil || r.Color != nil || r.Description != nil
+}
+
+func (r UpdateWorkspaceRequest) Validate() error {
+	if r.Icon != nil && utf8.RuneCountInString(*r.Icon) > MaxWorkspaceIconLength {
+		return fmt.Errorf("invalid icon: at most %d characters", MaxWorkspaceIconLength)
+	}
+	if r.Color != nil && *r.Color != "" && !workspaceColorRe.MatchString(*r.Color) {
+		return fmt.Errorf("invalid color: expected #rrggbb, got %q", *r.Color)
+	}
+	if r.Description != nil && utf8.RuneCountInString(*r.Description) > MaxWorkspaceDescriptionLength {
+		return fmt.Errorf("invalid description: at most %d characters", MaxWorkspaceDescriptionLength)
+	}
+	return nil
+}
+
 type APIToken struct {
 	ID          uuid.UUID  `json:"id"`
 	UserID      uuid.UUID  `json:"user_id"`
@@ -71,10 +197,15 @@ type APIToken struct {
 	LastUsedAt  *time.Time `json:"last_used_at"`
 	ExpiresAt   *time.Time `json:"expires_at"`
 	CreatedAt   time.Time  `json:"created_at"`
+	DeviceID    *uuid.UUID `json:"device_id,omitempty"`
+	// Scopes restrict what the token may do; see TokenScopes.
+	Scopes TokenScopes `json:"scopes,omitempty"`
 }
 
+// CreateTokenRequest mints a scoped token for a script or integration.
 type CreateTokenRequest struct {
 	Name      string     `json:"name" validate:"required,min=1,max=100"`
+	Scopes    []string   `json:"scopes"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
@@ -84,4 +215,6 @@ type AuthContext struct {
 	UserID    uuid.UUID `json:"user_id"`
 	UserEmail string    `json:"user_email"`
 	UserTier  UserTier  `json:"user_tier"`
+	// IsAdmin is the user's admin role; admin_emails may grant more.
+	IsAdmin bool `json:"is_admin"`
 }
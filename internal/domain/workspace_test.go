@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -77,3 +78,83 @@ func TestUserTier_GetMaxWorkspaces(t *testing.T) {
 		})
 	}
 }
+
+func TestUserTier_GetVersionRetention(t *testing.T) {
+	tests := []struct {
+		name      string
+		tier      UserTier
+		expected  VersionRetention
+		unlimited bool
+	}{
+		{
+			name:     "free tier keeps a week",
+			tier:     TierFree,
+			expected: VersionRetention{MaxVersions: 5, MaxAgeDays: 7},
+		},
+		{
+			name:     "premium tier keeps a year",
+			tier:     TierPremium,
+			expected: VersionRetention{MaxVersions: 100, MaxAgeDays: 365},
+		},
+		{
+			name:      "enterprise tier keeps everything",
+			tier:      TierEnterprise,
+			expected:  VersionRetention{MaxVersions: -1, MaxAgeDays: -1},
+			unlimited: true,
+		},
+		{
+			name:     "invalid tier defaults to free",
+			tier:     UserTier("invalid"),
+			expected: VersionRetention{MaxVersions: 5, MaxAgeDays: 7},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.tier.GetVersionRetention()
+			assert.Equal(t, tt.expected, result)
+			assert.Equal(t, tt.unlimited, result.Unlimited())
+		})
+	}
+}
+
+func TestUpdateWorkspaceRequest_Validate(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name    string
+		req     UpdateWorkspaceRequest
+		wantErr bool
+	}{
+		{name: "empty request", req: UpdateWorkspaceRequest{}},
+		{name: "valid color and icon", req: UpdateWorkspaceRequest{Color: str("#1a2B3c"), Icon: str("📓")}},
+		{name: "clearing color", req: UpdateWorkspaceRequest{Color: str("")}},
+		{name: "named color", req: UpdateWorkspaceRequest{Color: str("red")}, wantErr: true},
+		{name: "short hex color", req: UpdateWorkspaceRequest{Color: str("#fff")}, wantErr: true},
+		{name: "icon too long", req: UpdateWorkspaceRequest{Icon: str(strings.Repeat("x", MaxWorkspaceIconLength+1))}, wantErr: true},
+		{name: "description too long", req: UpdateWorkspaceRequest{Description: str(strings.Repeat("x", MaxWorkspaceDescriptionLength+1))}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFileLimits_Check(t *testing.T) {
+	limits := FileLimits{MaxFileSizeBytes: 10, MaxFiles: 2}
+	assert.NoError(t, limits.Check(10, 1, true))
+	assert.NoError(t, limits.Check(10, 2, false), "existing files may change at the file limit")
+	assert.ErrorContains(t, limits.Check(11, 0, true), "file too large")
+	assert.ErrorContains(t, limits.Check(1, 2, true), "file limit reached")
+
+	unlimited := TierEnterprise.GetFileLimits()
+	assert.NoError(t, unlimited.Check(1, 1<<40, true))
+	assert.Equal(t, TierFree.GetFileLimits(), UserTier("invalid").GetFileLimits(), "invalid tier defaults to free")
+}
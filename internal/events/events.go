@@ -0,0 +1,132 @@
+// Package events is an in-process bus for domain events. Services publish
+// what happened once it has committed, and the features that react to it,
+// such as event streams, webhook delivery, notifications and the audit
+// log, subscribe without the publishing service knowing about them.
+//
+// The bus is not durable and only reaches subscribers in the same process.
+// The workspace event outbox stays the record that streams and webhooks
+// deliver; the bus tells them to look now rather than at their next poll.
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// Event is something that happened in the domain. Name identifies the
+// event's type and is what subscribers are matched on.
+type Event interface {
+	Name() string
+}
+
+// FileUploaded is a new version of a file. StorageUsedBytes and
+// StorageLimitBytes are the workspace's, or its organization's pool's,
+// after the upload. Uploads of unchanged content are not published.
+type FileUploaded struct {
+	WorkspaceID       uuid.UUID `json:"workspace_id"`
+	ActorID           uuid.UUID `json:"actor_id"`
+	FilePath          string    `json:"file_path"`
+	SizeBytes         int64     `json:"size_bytes"`
+	Version           int32     `json:"version"`
+	Created           bool      `json:"created"`
+	StorageUsedBytes  int64     `json:"storage_used_bytes"`
+	StorageLimitBytes int64     `json:"storage_limit_bytes"`
+}
+
+func (FileUploaded) Name() string { return "file.uploaded" }
+
+// FileDeleted is a deleted file.
+type FileDeleted struct {
+	WorkspaceID uuid.UUID `json:"workspace_id"`
+	ActorID     uuid.UUID `json:"actor_id"`
+	FilePath    string    `json:"file_path"`
+	SizeBytes   int64     `json:"size_bytes"`
+}
+
+func (FileDeleted) Name() string { return "file.deleted" }
+
+// WorkspaceCreated is a new workspace.
+type WorkspaceCreated struct {
+	WorkspaceID   uuid.UUID `json:"workspace_id"`
+	OwnerID       uuid.UUID `json:"owner_id"`
+	WorkspaceName string    `json:"workspace_name"`
+}
+
+func (WorkspaceCreated) Name() string { return "workspace.created" }
+
+// Handler reacts to a published event.
+type Handler func(ctx context.Context, event Event)
+
+// Bus delivers published events to their subscribers. Handlers run on the
+// publisher's goroutine, in subscription order, so they must not block:
+// slow work belongs in a background job the handler wakes. A handler that
+// panics is logged and skipped. The zero value is not usable; a nil *Bus
+// drops what is published, so services work without one.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+	all      []Handler
+	log      *logger.Logger
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		handlers: make(map[string][]Handler),
+		log:      logger.New(),
+	}
+}
+
+// Subscribe calls handler with every published event of type E.
+func Subscribe[E Event](b *Bus, handler func(ctx context.Context, event E)) {
+	var zero E
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[zero.Name()] = append(b.handlers[zero.Name()], func(ctx context.Context, event Event) {
+		handler(ctx, event.(E))
+	})
+}
+
+// SubscribeAll calls handler with every published event.
+func (b *Bus) SubscribeAll(handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.all = append(b.all, handler)
+}
+
+// Publish delivers event to its subscribers and returns once they have
+// all run. Handlers get ctx without its cancellation, since the request
+// that published the event may finish before a handler's work does.
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	handlers := append(append([]Handler(nil), b.handlers[event.Name()]...), b.all...)
+	b.mu.RUnlock()
+
+	ctx = context.WithoutCancel(ctx)
+	for _, handler := range handlers {
+		b.run(ctx, handler, event)
+	}
+}
+
+func (b *Bus) run(ctx context.Context, handler Handler, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.log.WithContext(ctx).Error("Event handler panicked", "event", event.Name(), "panic", fmt.Sprint(r))
+		}
+	}()
+	handler(ctx, event)
+}
+
+// AuditLog returns a handler that logs every event it gets with its
+// fields, as an audit trail of what happened in the domain.
+func AuditLog(log *logger.Logger) Handler {
+	return func(ctx context.Context, event Event) {
+		log.WithContext(ctx).Info("Domain event", "event", event.Name(), "data", event)
+	}
+}
@@ -0,0 +1,54 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBus_Publish(t *testing.T) {
+	bus := NewBus()
+	workspaceID := uuid.New()
+
+	var uploaded []FileUploaded
+	var all []string
+	Subscribe(bus, func(ctx context.Context, e FileUploaded) {
+		uploaded = append(uploaded, e)
+	})
+	Subscribe(bus, func(ctx context.Context, e FileDeleted) {
+		panic("handler bug")
+	})
+	bus.SubscribeAll(func(ctx context.Context, e Event) {
+		all = append(all, e.Name())
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bus.Publish(ctx, FileUploaded{WorkspaceID: workspaceID, FilePath: "a.md"})
+	bus.Publish(ctx, FileDeleted{WorkspaceID: workspaceID, FilePath: "a.md"})
+	bus.Publish(ctx, WorkspaceCreated{WorkspaceID: workspaceID})
+
+	assert.Equal(t, []FileUploaded{{WorkspaceID: workspaceID, FilePath: "a.md"}}, uploaded)
+	assert.Equal(t, []string{"file.uploaded", "file.deleted", "workspace.created"}, all,
+		"a panicking handler does not stop the others")
+}
+
+func TestBus_PublishWithoutContextCancellation(t *testing.T) {
+	bus := NewBus()
+	var err error
+	bus.SubscribeAll(func(ctx context.Context, e Event) {
+		err = ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bus.Publish(ctx, WorkspaceCreated{})
+	assert.NoError(t, err)
+}
+
+func TestBus_NilDropsEvents(t *testing.T) {
+	var bus *Bus
+	assert.NotPanics(t, func() { bus.Publish(context.Background(), WorkspaceCreated{}) })
+}
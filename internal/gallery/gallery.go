@@ -0,0 +1,137 @@
+// Package gallery is the registry of starter workspaces users can clone.
+// Each template is a directory named by its ID: template.json describes it
+// and every other file becomes a file of the new workspace, at the same
+// path relative to the directory.
+package gallery
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/duckonomy/noture/internal/domain"
+)
+
+const manifestName = "template.json"
+
+//go:embed templates
+var builtin embed.FS
+
+var templateIDPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Template is a gallery entry with the content it clones.
+type Template struct {
+	domain.GalleryTemplate
+	Files []File
+}
+
+type File struct {
+	Path    string
+	Content []byte
+}
+
+type Gallery struct {
+	templates map[string]*Template
+	ids       []string
+}
+
+// Builtin returns the gallery shipped with the server.
+func Builtin() *Gallery {
+	sub, err := fs.Sub(builtin, "templates")
+	if err != nil {
+		panic(err)
+	}
+	g, err := Load(sub)
+	if err != nil {
+		panic(fmt.Sprintf("gallery: built-in templates: %v", err))
+	}
+	return g
+}
+
+// Load reads every template directory at the root of fsys. It fails on the
+// first malformed template so a broken gallery is caught at startup.
+func Load(fsys fs.FS) (*Gallery, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template gallery: %w", err)
+	}
+
+	g := &Gallery{templates: make(map[string]*Template)}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		tmpl, err := loadTemplate(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		g.templates[tmpl.ID] = tmpl
+		g.ids = append(g.ids, tmpl.ID)
+	}
+	sort.Strings(g.ids)
+	return g, nil
+}
+
+func loadTemplate(fsys fs.FS, id string) (*Template, error) {
+	if !templateIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid template id %q: use lowercase letters, digits and dashes", id)
+	}
+
+	manifest, err := fs.ReadFile(fsys, path.Join(id, manifestName))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %w", id, err)
+	}
+	tmpl := &Template{}
+	if err := json.Unmarshal(manifest, &tmpl.GalleryTemplate); err != nil {
+		return nil, fmt.Errorf("invalid template %s: %s: %w", id, manifestName, err)
+	}
+	if tmpl.Name == "" {
+		return nil, fmt.Errorf("invalid template %s: %s has no name", id, manifestName)
+	}
+	tmpl.ID = id
+
+	err = fs.WalkDir(fsys, id, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel := p[len(id)+1:]
+		if rel == manifestName {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		tmpl.Files = append(tmpl.Files, File{Path: rel, Content: content})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %w", id, err)
+	}
+	if len(tmpl.Files) == 0 {
+		return nil, fmt.Errorf("invalid template %s: no files", id)
+	}
+	tmpl.FileCount = len(tmpl.Files)
+	return tmpl, nil
+}
+
+// List describes every template, ordered by ID.
+func (g *Gallery) List() []domain.GalleryTemplate {
+	list := make([]domain.GalleryTemplate, 0, len(g.ids))
+	for _, id := range g.ids {
+		list = append(list, g.templates[id].GalleryTemplate)
+	}
+	return list
+}
+
+func (g *Gallery) Get(id string) (*Template, error) {
+	tmpl, ok := g.templates[id]
+	if !ok {
+		return nil, fmt.Errorf("template not found")
+	}
+	return tmpl, nil
+}
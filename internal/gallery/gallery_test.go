@@ -0,0 +1,72 @@
+package gallery
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltin(t *testing.T) {
+	g := Builtin()
+
+	list := g.List()
+	require.NotEmpty(t, list)
+	for i := 1; i < len(list); i++ {
+		assert.Less(t, list[i-1].ID, list[i].ID, "listed in ID order")
+	}
+
+	tmpl, err := g.Get("pkm-starter")
+	require.NoError(t, err)
+	assert.Equal(t, "PKM Starter", tmpl.Name)
+	assert.Equal(t, len(tmpl.Files), tmpl.FileCount)
+	for _, file := range tmpl.Files {
+		assert.NotEqual(t, manifestName, file.Path)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"journal/template.json": {Data: []byte(`{"name": "Journal", "tags": ["daily"]}`)},
+		"journal/index.md":      {Data: []byte("# Journal")},
+		"journal/2024/01/01.md": {Data: []byte("# Day one")},
+		"README.txt":            {Data: []byte("ignored: not a template directory")},
+	}
+
+	g, err := Load(fsys)
+	require.NoError(t, err)
+
+	tmpl, err := g.Get("journal")
+	require.NoError(t, err)
+	assert.Equal(t, "journal", tmpl.ID)
+	assert.Equal(t, []string{"daily"}, tmpl.Tags)
+	paths := make([]string, 0, len(tmpl.Files))
+	for _, file := range tmpl.Files {
+		paths = append(paths, file.Path)
+	}
+	assert.ElementsMatch(t, []string{"index.md", "2024/01/01.md"}, paths)
+
+	_, err = g.Get("missing")
+	assert.EqualError(t, err, "template not found")
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{"bad id", fstest.MapFS{"My Notes/template.json": {Data: []byte(`{"name": "x"}`)}, "My Notes/a.md": {}}, "invalid template id"},
+		{"no manifest", fstest.MapFS{"notes/a.md": {}}, "invalid template notes"},
+		{"bad manifest", fstest.MapFS{"notes/template.json": {Data: []byte(`{`)}, "notes/a.md": {}}, "invalid template notes"},
+		{"unnamed", fstest.MapFS{"notes/template.json": {Data: []byte(`{}`)}, "notes/a.md": {}}, "has no name"},
+		{"empty", fstest.MapFS{"notes/template.json": {Data: []byte(`{"name": "Notes"}`)}}, "no files"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.fsys)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,334 @@
+// Package gitmirror keeps a local bare repository per mirrored workspace
+// and moves trees of files between it and a Git remote, with the git
+// command-line tool. Remotes are reached over SSH with a deploy key the
+// server generates, so users grant access to one repository at a time.
+//
+// The package knows nothing of workspaces: callers fetch the remote,
+// compare trees, write blobs and commit, and decide what each side's
+// changes mean.
+package gitmirror
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// fetchedRef is where Fetch leaves the remote branch's head.
+const fetchedRef = "refs/mirror/head"
+
+// ErrGitNotFound is returned by Open when the git command is not installed.
+var ErrGitNotFound = errors.New("git command not found")
+
+// Remote is a branch of a Git repository. PrivateKey is the OpenSSH
+// private key the server authenticates with; remotes that need none, such
+// as local paths in tests, leave it nil.
+type Remote struct {
+	URL        string
+	Branch     string
+	PrivateKey []byte
+}
+
+// Signature is the author and committer of the commits a mirror makes.
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+// Repo is a local bare repository. Besides the objects, it remembers the
+// Git blob ID of each content hash it wrote, so unchanged files are not
+// read and hashed again on every sync.
+type Repo struct {
+	dir     string
+	blobIDs map[string]string
+}
+
+// GenerateKey makes an ed25519 deploy key. It returns the private key in
+// OpenSSH format and the public key in authorized_keys format, as Git
+// hosts expect deploy keys to be pasted.
+func GenerateKey(comment string) (privateKey []byte, publicKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, comment)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode private key: %w", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode public key: %w", err)
+	}
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+	if comment != "" {
+		authorized += " " + comment
+	}
+	return pem.EncodeToMemory(block), authorized, nil
+}
+
+// Prepare checks that the git command is installed and creates dir, the
+// directory repositories are kept in, so a server that cannot mirror
+// finds out at startup.
+func Prepare(dir string) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return ErrGitNotFound
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	return nil
+}
+
+// Open opens the bare repository at dir, creating it if it does not exist.
+func Open(ctx context.Context, dir string) (*Repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, ErrGitNotFound
+	}
+	r := &Repo{dir: dir, blobIDs: make(map[string]string)}
+
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create repository directory: %w", err)
+		}
+		if _, err := r.git(ctx, nil, nil, "init", "--quiet", "--bare"); err != nil {
+			return nil, err
+		}
+		// Blob IDs are remembered across syncs, so git must never prune
+		// the blobs no commit refers to yet.
+		if _, err := r.git(ctx, nil, nil, "config", "gc.auto", "0"); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := os.ReadFile(r.blobIDsPath())
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &r.blobIDs); err != nil {
+			return nil, fmt.Errorf("failed to read blob IDs: %w", err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("failed to read blob IDs: %w", err)
+	}
+	return r, nil
+}
+
+// Fetch fetches the remote's branch and returns the commit at its head,
+// or "" when the branch does not exist yet, as in a new empty repository.
+func (r *Repo) Fetch(ctx context.Context, remote Remote) (string, error) {
+	if _, err := r.git(ctx, nil, nil, "update-ref", "-d", fetchedRef); err != nil {
+		return "", err
+	}
+	_, err := r.remoteGit(ctx, remote, "fetch", "--quiet", "--no-tags", "--", remote.URL,
+		"+refs/heads/"+remote.Branch+":"+fetchedRef)
+	if err != nil {
+		if strings.Contains(err.Error(), "couldn't find remote ref") {
+			return "", nil
+		}
+		return "", err
+	}
+	out, err := r.git(ctx, nil, nil, "rev-parse", "--verify", fetchedRef+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Push makes commit the head of the remote's branch. It never forces: if
+// the branch moved since it was fetched, the push fails and the caller
+// fetches again.
+func (r *Repo) Push(ctx context.Context, remote Remote, commit string) error {
+	_, err := r.remoteGit(ctx, remote, "push", "--quiet", "--", remote.URL, commit+":refs/heads/"+remote.Branch)
+	return err
+}
+
+// Tree returns the regular files of commit, by path, with their blob IDs.
+// The tree of "" is empty.
+func (r *Repo) Tree(ctx context.Context, commit string) (map[string]string, error) {
+	tree := make(map[string]string)
+	if commit == "" {
+		return tree, nil
+	}
+	out, err := r.git(ctx, nil, nil, "ls-tree", "-r", "-z", commit)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range strings.Split(string(out), "\x00") {
+		// <mode> SP <type> SP <id> TAB <path>
+		info, path, ok := strings.Cut(entry, "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(info)
+		if len(fields) != 3 || fields[1] != "blob" || (fields[0] != "100644" && fields[0] != "100755") {
+			continue
+		}
+		tree[path] = fields[2]
+	}
+	return tree, nil
+}
+
+// ReadBlob returns the content of a blob.
+func (r *Repo) ReadBlob(ctx context.Context, id string) ([]byte, error) {
+	return r.git(ctx, nil, nil, "cat-file", "blob", id)
+}
+
+// BlobID returns the blob ID of content with the given hash, if it was
+// written before.
+func (r *Repo) BlobID(contentHash string) (string, bool) {
+	id, ok := r.blobIDs[contentHash]
+	return id, ok
+}
+
+// WriteBlob stores content and returns its blob ID, remembering it under
+// contentHash.
+func (r *Repo) WriteBlob(ctx context.Context, contentHash string, content []byte) (string, error) {
+	out, err := r.git(ctx, nil, bytes.NewReader(content), "hash-object", "-w", "--stdin")
+	if err != nil {
+		return "", err
+	}
+	id := strings.TrimSpace(string(out))
+	r.blobIDs[contentHash] = id
+	return id, nil
+}
+
+// Commit records tree, a map of paths to blob IDs, as a child of parent,
+// or as a root commit when parent is "". When the tree is parent's tree,
+// or empty without a parent, nothing is committed and parent is returned.
+func (r *Repo) Commit(ctx context.Context, parent string, tree map[string]string, message string, author Signature) (string, error) {
+	if parent == "" && len(tree) == 0 {
+		return "", nil
+	}
+
+	index, err := os.CreateTemp(r.dir, "index-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create index: %w", err)
+	}
+	index.Close()
+	os.Remove(index.Name())
+	defer os.Remove(index.Name())
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+
+	var entries bytes.Buffer
+	for path, id := range tree {
+		fmt.Fprintf(&entries, "100644 %s\t%s\x00", id, path)
+	}
+	if _, err := r.git(ctx, env, &entries, "update-index", "--add", "-z", "--index-info"); err != nil {
+		return "", err
+	}
+	out, err := r.git(ctx, env, nil, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	treeID := strings.TrimSpace(string(out))
+
+	args := []string{"commit-tree", treeID, "-m", message}
+	if parent != "" {
+		out, err := r.git(ctx, nil, nil, "rev-parse", parent+"^{tree}")
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(string(out)) == treeID {
+			return parent, nil
+		}
+		args = append(args, "-p", parent)
+	}
+
+	date := author.When.Format(time.RFC3339)
+	env = append(env,
+		"GIT_AUTHOR_NAME="+author.Name, "GIT_AUTHOR_EMAIL="+author.Email, "GIT_AUTHOR_DATE="+date,
+		"GIT_COMMITTER_NAME="+author.Name, "GIT_COMMITTER_EMAIL="+author.Email, "GIT_COMMITTER_DATE="+date)
+	out, err = r.git(ctx, env, nil, args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Save writes the remembered blob IDs, for the next sync.
+func (r *Repo) Save() error {
+	data, err := json.Marshal(r.blobIDs)
+	if err != nil {
+		return err
+	}
+	tmp := r.blobIDsPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to save blob IDs: %w", err)
+	}
+	if err := os.Rename(tmp, r.blobIDsPath()); err != nil {
+		return fmt.Errorf("failed to save blob IDs: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) blobIDsPath() string {
+	return filepath.Join(r.dir, "noture-blob-ids.json")
+}
+
+// remoteGit runs a git command that talks to remote, authenticating with
+// its key. Host keys are trusted on first use and remembered next to the
+// repository.
+func (r *Repo) remoteGit(ctx context.Context, remote Remote, args ...string) ([]byte, error) {
+	var env []string
+	if remote.PrivateKey != nil {
+		key, err := os.CreateTemp(r.dir, "key-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to write deploy key: %w", err)
+		}
+		defer os.Remove(key.Name())
+		_, err = key.Write(remote.PrivateKey)
+		if closeErr := key.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to write deploy key: %w", err)
+		}
+		env = append(env, "GIT_SSH_COMMAND="+strings.Join([]string{
+			"ssh", "-i", shellQuote(key.Name()),
+			"-o", "IdentitiesOnly=yes",
+			"-o", "BatchMode=yes",
+			"-o", "StrictHostKeyChecking=accept-new",
+			"-o", "UserKnownHostsFile=" + shellQuote(filepath.Join(r.dir, "known_hosts")),
+		}, " "))
+	}
+	return r.git(ctx, env, nil, args...)
+}
+
+// git runs a git command in the repository and returns its output. The
+// error carries what git printed to stderr.
+func (r *Repo) git(ctx context.Context, env []string, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--git-dir", r.dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_CONFIG_NOSYSTEM=1")
+	cmd.Env = append(cmd.Env, env...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,109 @@
+package gitmirror
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func newRemote(t *testing.T) Remote {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := filepath.Join(t.TempDir(), "remote.git")
+	require.NoError(t, exec.Command("git", "init", "--quiet", "--bare", dir).Run())
+	return Remote{URL: dir, Branch: "main"}
+}
+
+func TestRepo_PushAndFetch(t *testing.T) {
+	ctx := context.Background()
+	remote := newRemote(t)
+	author := Signature{Name: "Noture", Email: "mirror@noture.test", When: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+
+	repo, err := Open(ctx, filepath.Join(t.TempDir(), "mirror.git"))
+	require.NoError(t, err)
+
+	head, err := repo.Fetch(ctx, remote)
+	require.NoError(t, err)
+	assert.Empty(t, head, "a new repository has no branch")
+
+	commit, err := repo.Commit(ctx, "", map[string]string{}, "empty", author)
+	require.NoError(t, err)
+	assert.Empty(t, commit, "nothing to commit")
+
+	noteID, err := repo.WriteBlob(ctx, "hash-note", []byte("# Note\n"))
+	require.NoError(t, err)
+	imageID, err := repo.WriteBlob(ctx, "hash-image", []byte{0x89, 'P', 'N', 'G'})
+	require.NoError(t, err)
+	first, err := repo.Commit(ctx, "", map[string]string{"notes/a b.md": noteID, "image.png": imageID}, "Sync", author)
+	require.NoError(t, err)
+	require.NoError(t, repo.Push(ctx, remote, first))
+
+	again, err := repo.Commit(ctx, first, map[string]string{"notes/a b.md": noteID, "image.png": imageID}, "Sync", author)
+	require.NoError(t, err)
+	assert.Equal(t, first, again, "an unchanged tree makes no commit")
+
+	second, err := repo.Commit(ctx, first, map[string]string{"notes/a b.md": noteID}, "Sync", author)
+	require.NoError(t, err)
+	require.NoError(t, repo.Push(ctx, remote, second))
+
+	// Another clone sees what was pushed.
+	other, err := Open(ctx, filepath.Join(t.TempDir(), "other.git"))
+	require.NoError(t, err)
+	head, err = other.Fetch(ctx, remote)
+	require.NoError(t, err)
+	assert.Equal(t, second, head)
+	tree, err := other.Tree(ctx, head)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"notes/a b.md": noteID}, tree)
+	content, err := other.ReadBlob(ctx, noteID)
+	require.NoError(t, err)
+	assert.Equal(t, "# Note\n", string(content))
+
+	// Pushing a commit that does not descend from the branch fails.
+	stale, err := other.Commit(ctx, first, map[string]string{}, "Sync", author)
+	require.NoError(t, err)
+	assert.Error(t, other.Push(ctx, remote, stale))
+}
+
+func TestRepo_RemembersBlobIDs(t *testing.T) {
+	ctx := context.Background()
+	newRemote(t)
+	dir := filepath.Join(t.TempDir(), "mirror.git")
+
+	repo, err := Open(ctx, dir)
+	require.NoError(t, err)
+	id, err := repo.WriteBlob(ctx, "hash", []byte("content"))
+	require.NoError(t, err)
+	require.NoError(t, repo.Save())
+
+	reopened, err := Open(ctx, dir)
+	require.NoError(t, err)
+	got, ok := reopened.BlobID("hash")
+	assert.True(t, ok)
+	assert.Equal(t, id, got)
+	_, ok = reopened.BlobID("other")
+	assert.False(t, ok)
+}
+
+func TestGenerateKey(t *testing.T) {
+	private, public, err := GenerateKey("noture workspace")
+	require.NoError(t, err)
+
+	signer, err := ssh.ParsePrivateKey(private)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(public, "ssh-ed25519 "))
+	assert.True(t, strings.HasSuffix(public, " noture workspace"))
+
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(public))
+	require.NoError(t, err)
+	assert.Equal(t, signer.PublicKey().Marshal(), parsed.Marshal())
+}
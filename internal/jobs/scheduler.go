@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/duckonomy/noture/pkg/logger"
+)
+
+// Job is a unit of periodic background work.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs registered jobs on their intervals. Jobs run one at a time
+// so they can share a single database connection.
+type Scheduler struct {
+	jobs []Job
+	log  *logger.Logger
+	now  func() time.Time
+
+	mu   sync.Mutex
+	soon map[string]bool
+	wake chan struct{}
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		log:  logger.New(),
+		now:  time.Now,
+		soon: make(map[string]bool),
+		wake: make(chan struct{}, 1),
+	}
+}
+
+func (s *Scheduler) Register(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// RunSoon runs the job named name as soon as the job running now, if any,
+// is done, rather than when its interval elapses; its interval restarts
+// from then. Calls before the job gets to run are coalesced. It does not
+// block, so it can be called while serving requests.
+func (s *Scheduler) RunSoon(name string) {
+	s.mu.Lock()
+	s.soon[name] = true
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs every job immediately and then again each time its interval
+// elapses, until ctx is cancelled. It blocks, so callers usually run it in
+// its own goroutine.
+func (s *Scheduler) Start(ctx context.Context) {
+	if len(s.jobs) == 0 {
+		return
+	}
+
+	nextRun := make([]time.Time, len(s.jobs))
+	for i := range nextRun {
+		nextRun[i] = s.now()
+	}
+
+	for {
+		next := 0
+		for i := range s.jobs {
+			if nextRun[i].Before(nextRun[next]) {
+				next = i
+			}
+		}
+
+		timer := time.NewTimer(nextRun[next].Sub(s.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+			s.mu.Lock()
+			for i, job := range s.jobs {
+				if s.soon[job.Name] {
+					nextRun[i] = s.now()
+				}
+			}
+			clear(s.soon)
+			s.mu.Unlock()
+			continue
+		case <-timer.C:
+		}
+
+		s.runJob(ctx, s.jobs[next])
+		nextRun[next] = s.now().Add(s.jobs[next].Interval)
+	}
+}
+
+func (s *Scheduler) runJob(ctx context.Context, job Job) {
+	log := s.log.With("job", job.Name)
+	start := s.now()
+
+	if err := job.Run(ctx); err != nil {
+		log.Error("Background job failed", "error", err, "duration", s.now().Sub(start).String())
+		return
+	}
+
+	log.Debug("Background job completed", "duration", s.now().Sub(start).String())
+}
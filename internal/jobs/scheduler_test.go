@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler_Start(t *testing.T) {
+	t.Run("runs jobs immediately and on interval", func(t *testing.T) {
+		var runs atomic.Int32
+		ctx, cancel := context.WithCancel(context.Background())
+
+		scheduler := NewScheduler()
+		scheduler.Register(Job{
+			Name:     "counter",
+			Interval: 10 * time.Millisecond,
+			Run: func(ctx context.Context) error {
+				if runs.Add(1) == 3 {
+					cancel()
+				}
+				return nil
+			},
+		})
+
+		done := make(chan struct{})
+		go func() {
+			scheduler.Start(ctx)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("scheduler did not stop after cancellation")
+		}
+		assert.Equal(t, int32(3), runs.Load())
+	})
+
+	t.Run("failing job keeps being scheduled", func(t *testing.T) {
+		var runs atomic.Int32
+		ctx, cancel := context.WithCancel(context.Background())
+
+		scheduler := NewScheduler()
+		scheduler.Register(Job{
+			Name:     "failing",
+			Interval: time.Millisecond,
+			Run: func(ctx context.Context) error {
+				if runs.Add(1) == 2 {
+					cancel()
+				}
+				return errors.New("boom")
+			},
+		})
+
+		scheduler.Start(ctx)
+		assert.Equal(t, int32(2), runs.Load())
+	})
+
+	t.Run("RunSoon runs a job before its interval", func(t *testing.T) {
+		var runs atomic.Int32
+		ctx, cancel := context.WithCancel(context.Background())
+
+		scheduler := NewScheduler()
+		scheduler.Register(Job{
+			Name:     "hourly",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				if runs.Add(1) == 2 {
+					cancel()
+				}
+				return nil
+			},
+		})
+
+		done := make(chan struct{})
+		go func() {
+			scheduler.Start(ctx)
+			close(done)
+		}()
+		assert.Eventually(t, func() bool { return runs.Load() == 1 }, time.Second, time.Millisecond)
+		scheduler.RunSoon("unknown")
+		scheduler.RunSoon("hourly")
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("RunSoon did not run the job")
+		}
+		assert.Equal(t, int32(2), runs.Load())
+	})
+
+	t.Run("no jobs returns immediately", func(t *testing.T) {
+		NewScheduler().Start(context.Background())
+	})
+}
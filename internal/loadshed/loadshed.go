@@ -0,0 +1,170 @@
+// Package loadshed refuses low-priority requests while the server is
+// overloaded, so that sync keeps working through spikes. Search, exports
+// and statistics can wait; uploads and downloads should not.
+package loadshed
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/duckonomy/noture/internal/metrics"
+	"github.com/duckonomy/noture/pkg/logger"
+)
+
+// DefaultLowPriority are the route patterns shed by default: reads that
+// are expensive and that clients can retry later without losing work.
+var DefaultLowPriority = []string{
+	"GET /api/workspaces/{workspace_id}/search",
+	"GET /api/workspaces/{workspace_id}/index-status",
+	"GET /api/workspaces/{workspace_id}/events/replay",
+	"GET /api/workspaces/{id}/export",
+	"GET /api/account/export",
+	"POST /api/workspaces/{workspace_id}/publish/bundle",
+	"GET /api/workspaces/{id}/storage",
+	"GET /api/workspaces/{id}/stats",
+	"GET /api/me/suggestions",
+	"GET /api/admin/tables",
+	"GET /api/admin/telemetry",
+}
+
+// Reasons a request is shed.
+const (
+	ReasonInFlight   = "in_flight"
+	ReasonJobBacklog = "job_backlog"
+)
+
+// Stats counts shed requests as "shed_<reason>".
+var Stats = metrics.NewCounters()
+
+// Thresholds decide when the server is overloaded. Zero disables a check.
+type Thresholds struct {
+	// MaxInFlight caps concurrent requests. Request handlers share one
+	// database connection, so past this they mostly wait for it.
+	MaxInFlight int64
+	// MaxJobBacklog caps pending and running background operations.
+	MaxJobBacklog int64
+	// RetryAfter is sent to shed clients.
+	RetryAfter time.Duration
+}
+
+// Shedder tracks the load signals and sheds low-priority requests while
+// any of them is over its threshold.
+type Shedder struct {
+	thresholds  Thresholds
+	lowPriority *http.ServeMux
+	inFlight    atomic.Int64
+	jobBacklog  atomic.Int64
+	shedding    atomic.Bool
+	log         *logger.Logger
+}
+
+// New builds a shedder for the given route patterns, in http.ServeMux
+// syntax. Requests are matched against them the way the API mux matches
+// its routes.
+func New(thresholds Thresholds, lowPriority []string) (*Shedder, error) {
+	mux, err := routeMux(lowPriority)
+	if err != nil {
+		return nil, err
+	}
+	return &Shedder{
+		thresholds:  thresholds,
+		lowPriority: mux,
+		log:         logger.New(),
+	}, nil
+}
+
+// ValidateRoutes checks that patterns are valid and do not conflict.
+func ValidateRoutes(patterns []string) error {
+	_, err := routeMux(patterns)
+	return err
+}
+
+func routeMux(patterns []string) (mux *http.ServeMux, err error) {
+	mux = http.NewServeMux()
+	for _, pattern := range patterns {
+		func() {
+			// ServeMux panics on bad patterns.
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("route %q: %v", pattern, r)
+				}
+			}()
+			mux.Handle(pattern, http.NotFoundHandler())
+		}()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return mux, nil
+}
+
+// SetJobBacklog records the latest count of queued background operations.
+func (s *Shedder) SetJobBacklog(n int64) {
+	s.jobBacklog.Store(n)
+}
+
+// Overloaded returns why the server is overloaded, or "" if it is not.
+func (s *Shedder) Overloaded() string {
+	switch {
+	case s.thresholds.MaxInFlight > 0 && s.inFlight.Load() > s.thresholds.MaxInFlight:
+		return ReasonInFlight
+	case s.thresholds.MaxJobBacklog > 0 && s.jobBacklog.Load() > s.thresholds.MaxJobBacklog:
+		return ReasonJobBacklog
+	}
+	return ""
+}
+
+// Status is a snapshot of the load signals, for the admin API.
+type Status struct {
+	InFlight      int64            `json:"in_flight"`
+	MaxInFlight   int64            `json:"max_in_flight"`
+	JobBacklog    int64            `json:"job_backlog"`
+	MaxJobBacklog int64            `json:"max_job_backlog"`
+	Overloaded    string           `json:"overloaded,omitempty"`
+	Shed          map[string]int64 `json:"shed"`
+}
+
+func (s *Shedder) Status() Status {
+	return Status{
+		InFlight:      s.inFlight.Load(),
+		MaxInFlight:   s.thresholds.MaxInFlight,
+		JobBacklog:    s.jobBacklog.Load(),
+		MaxJobBacklog: s.thresholds.MaxJobBacklog,
+		Overloaded:    s.Overloaded(),
+		Shed:          Stats.Snapshot(),
+	}
+}
+
+// Middleware counts requests in flight and answers low-priority ones with
+// 503 and Retry-After while the server is overloaded. Other requests are
+// always served.
+func (s *Shedder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+
+		reason := s.Overloaded()
+		if s.shedding.Swap(reason != "") != (reason != "") {
+			if reason != "" {
+				s.log.Warn("Overloaded, shedding low-priority requests", "reason", reason,
+					"in_flight", s.inFlight.Load(), "job_backlog", s.jobBacklog.Load())
+			} else {
+				s.log.Info("Load back to normal, no longer shedding requests")
+			}
+		}
+
+		if reason != "" {
+			if _, pattern := s.lowPriority.Handler(r); pattern != "" {
+				Stats.Add("shed_"+reason, 1)
+				retryAfter := max(1, int(s.thresholds.RetryAfter/time.Second))
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				http.Error(w, "Server overloaded, retry later", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
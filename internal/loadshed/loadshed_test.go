@@ -0,0 +1,79 @@
+package loadshed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShedder_JobBacklog(t *testing.T) {
+	shedder, err := New(Thresholds{MaxJobBacklog: 10, RetryAfter: 30 * time.Second}, DefaultLowPriority)
+	require.NoError(t, err)
+	handler := shedder.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+	search := "/api/workspaces/8c5e2a4e-0000-4000-8000-000000000001/search?q=x"
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, search).Code)
+
+	shedder.SetJobBacklog(11)
+	before := Stats.Snapshot()["shed_"+ReasonJobBacklog]
+	rec := serve(http.MethodGet, search)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+	assert.Equal(t, before+1, Stats.Snapshot()["shed_"+ReasonJobBacklog])
+
+	// Sync keeps working.
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/files/upload").Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/files/8c5e2a4e-0000-4000-8000-000000000001/notes/a.md").Code)
+
+	shedder.SetJobBacklog(3)
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, search).Code)
+}
+
+func TestShedder_InFlight(t *testing.T) {
+	shedder, err := New(Thresholds{MaxInFlight: 2}, []string{"GET /api/workspaces/{id}/export"})
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	slow := shedder.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started.Done()
+		<-release
+	}))
+	var finished sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		started.Add(1)
+		finished.Add(1)
+		go func() {
+			defer finished.Done()
+			slow.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/files/upload", nil))
+		}()
+	}
+	started.Wait()
+
+	// This request is the third in flight.
+	rec := httptest.NewRecorder()
+	shedder.Middleware(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/workspaces/abc/export", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	close(release)
+	finished.Wait()
+	assert.Equal(t, "", shedder.Overloaded())
+}
+
+func TestValidateRoutes(t *testing.T) {
+	assert.NoError(t, ValidateRoutes(DefaultLowPriority))
+	assert.Error(t, ValidateRoutes([]string{"GET /api/{"}))
+	assert.Error(t, ValidateRoutes([]string{"GET /api/stats", "GET /api/stats"}))
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import "sync"
+
+// Counters is a set of named, monotonically increasing counts.
+type Counters struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func NewCounters() *Counters {
+	return &Counters{counts: make(map[string]int64)}
+}
+
+func (c *Counters) Add(name string, n int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[name] += n
+}
+
+// Snapshot returns a copy of the current counts.
+func (c *Counters) Snapshot() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot := make(map[string]int64, len(c.counts))
+	for name, n := range c.counts {
+		snapshot[name] = n
+	}
+	return snapshot
+}
@@ -0,0 +1,20 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounters(t *testing.T) {
+	c := NewCounters()
+	c.Add("a", 1)
+	c.Add("a", 2)
+	c.Add("b", 1)
+
+	snapshot := c.Snapshot()
+	assert.Equal(t, map[string]int64{"a": 3, "b": 1}, snapshot)
+
+	c.Add("a", 1)
+	assert.Equal(t, int64(3), snapshot["a"], "snapshots are copies")
+}
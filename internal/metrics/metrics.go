@@ -0,0 +1,229 @@
+// Package metrics keeps rolling per-endpoint request counts and latency
+// histograms in memory. Data is bucketed by minute and kept for MaxWindow,
+// which is enough for SLO burn-rate checks without an external TSDB.
+package metrics
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	bucketWidth = time.Minute
+	// MaxWindow is the longest window Window can report on.
+	MaxWindow  = time.Hour
+	numBuckets = int(MaxWindow / bucketWidth)
+)
+
+// LatencyBounds are the upper bounds of the latency histogram buckets. A
+// final overflow bucket holds everything slower.
+var LatencyBounds = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// UnmatchedRoute labels requests no route pattern matched.
+const UnmatchedRoute = "unmatched"
+
+// Stats aggregates the requests to one route over a window. Latency covers
+// successful (non-5xx) requests only, so failing fast does not make an
+// endpoint look healthy.
+type Stats struct {
+	Requests int64
+	Errors   int64
+	Latency  []int64
+}
+
+type bucket struct {
+	minute int64
+	stats  Stats
+}
+
+type series struct {
+	buckets [numBuckets]bucket
+}
+
+type Recorder struct {
+	mu     sync.Mutex
+	routes map[string]*series
+	now    func() time.Time
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		routes: make(map[string]*series),
+		now:    time.Now,
+	}
+}
+
+// Observe records one finished request. Responses with a 5xx status count
+// as errors.
+func (r *Recorder) Observe(route string, status int, duration time.Duration) {
+	minute := r.now().Unix() / int64(bucketWidth/time.Second)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.routes[route]
+	if !ok {
+		s = &series{}
+		r.routes[route] = s
+	}
+
+	b := &s.buckets[minute%int64(numBuckets)]
+	if b.minute != minute {
+		*b = bucket{minute: minute, stats: Stats{Latency: make([]int64, len(LatencyBounds)+1)}}
+	}
+
+	b.stats.Requests++
+	if status >= 500 {
+		b.stats.Errors++
+		return
+	}
+	b.stats.Latency[latencyBucket(duration)]++
+}
+
+// Routes lists every route that has been observed, sorted.
+func (r *Recorder) Routes() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	routes := make([]string, 0, len(r.routes))
+	for route := range r.routes {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// Window sums the route's buckets covering the last window, capped at
+// MaxWindow. The current, partial minute is included.
+func (r *Recorder) Window(route string, window time.Duration) Stats {
+	if window > MaxWindow {
+		window = MaxWindow
+	}
+	minutes := int64(window / bucketWidth)
+	if minutes < 1 {
+		minutes = 1
+	}
+	current := r.now().Unix() / int64(bucketWidth/time.Second)
+
+	total := Stats{Latency: make([]int64, len(LatencyBounds)+1)}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.routes[route]
+	if !ok {
+		return total
+	}
+	for _, b := range s.buckets {
+		if b.minute > current-minutes && b.minute <= current {
+			total.Requests += b.stats.Requests
+			total.Errors += b.stats.Errors
+			for i, n := range b.stats.Latency {
+				total.Latency[i] += n
+			}
+		}
+	}
+	return total
+}
+
+// Quantile estimates the q-th latency quantile as the upper bound of the
+// histogram bucket it falls in. Requests in the overflow bucket report the
+// largest bound.
+func (s Stats) Quantile(q float64) time.Duration {
+	var count int64
+	for _, n := range s.Latency {
+		count += n
+	}
+	if count == 0 {
+		return 0
+	}
+
+	rank := int64(q*float64(count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, n := range s.Latency {
+		seen += n
+		if seen >= rank {
+			if i < len(LatencyBounds) {
+				return LatencyBounds[i]
+			}
+			break
+		}
+	}
+	return LatencyBounds[len(LatencyBounds)-1]
+}
+
+// SlowerThan counts successful requests in buckets whose lower bound is at
+// least threshold. Thresholds between bounds round up to the next bound.
+func (s Stats) SlowerThan(threshold time.Duration) int64 {
+	var slow int64
+	for i, n := range s.Latency {
+		if i > 0 && LatencyBounds[i-1] >= threshold {
+			slow += n
+		}
+	}
+	return slow
+}
+
+func latencyBucket(d time.Duration) int {
+	for i, bound := range LatencyBounds {
+		if d <= bound {
+			return i
+		}
+	}
+	return len(LatencyBounds)
+}
+
+// Middleware serves mux and records every request under the route pattern
+// it matched, e.g. "GET /api/workspaces/{id}", so paths with IDs don't
+// explode into separate series. Event streams are not recorded: they last
+// as long as their clients stay connected, which says nothing about how
+// fast the server answers.
+func (r *Recorder) Middleware(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, route := mux.Handler(req)
+		if route == "" {
+			route = UnmatchedRoute
+		}
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(sw, req)
+
+		if strings.HasPrefix(sw.Header().Get("Content-Type"), "text/event-stream") {
+			return
+		}
+		r.Observe(route, sw.status, time.Since(start))
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
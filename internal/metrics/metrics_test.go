@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder_Window(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRecorder()
+	r.now = func() time.Time { return now }
+
+	r.Observe("GET /a", http.StatusOK, 20*time.Millisecond)
+	r.Observe("GET /a", http.StatusInternalServerError, time.Millisecond)
+
+	now = now.Add(10 * time.Minute)
+	r.Observe("GET /a", http.StatusOK, 2*time.Second)
+
+	t.Run("short window sees only recent buckets", func(t *testing.T) {
+		stats := r.Window("GET /a", 5*time.Minute)
+		assert.Equal(t, int64(1), stats.Requests)
+		assert.Equal(t, int64(0), stats.Errors)
+	})
+
+	t.Run("long window sums every bucket", func(t *testing.T) {
+		stats := r.Window("GET /a", time.Hour)
+		assert.Equal(t, int64(3), stats.Requests)
+		assert.Equal(t, int64(1), stats.Errors)
+	})
+
+	t.Run("buckets older than the maximum window are reused", func(t *testing.T) {
+		now = now.Add(MaxWindow)
+		r.Observe("GET /a", http.StatusOK, time.Millisecond)
+
+		stats := r.Window("GET /a", time.Hour)
+		assert.Equal(t, int64(1), stats.Requests)
+	})
+
+	t.Run("unknown routes are empty", func(t *testing.T) {
+		assert.Equal(t, int64(0), r.Window("GET /b", time.Hour).Requests)
+		assert.Equal(t, []string{"GET /a"}, r.Routes())
+	})
+}
+
+func TestStats_Quantile(t *testing.T) {
+	r := NewRecorder()
+	for i := 0; i < 90; i++ {
+		r.Observe("GET /a", http.StatusOK, 8*time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		r.Observe("GET /a", http.StatusOK, 700*time.Millisecond)
+	}
+
+	stats := r.Window("GET /a", time.Minute)
+	assert.Equal(t, 10*time.Millisecond, stats.Quantile(0.5))
+	assert.Equal(t, time.Second, stats.Quantile(0.99))
+	assert.Equal(t, int64(10), stats.SlowerThan(500*time.Millisecond))
+	assert.Equal(t, int64(0), stats.SlowerThan(time.Second))
+	assert.Equal(t, time.Duration(0), Stats{}.Quantile(0.5))
+}
+
+func TestRecorder_Middleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "broken" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("GET /stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(": hello\n\n"))
+	})
+
+	r := NewRecorder()
+	handler := r.Middleware(mux)
+
+	for _, path := range []string{"/items/1", "/items/2", "/items/broken", "/missing", "/stream"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	require.Equal(t, []string{"GET /items/{id}", UnmatchedRoute}, r.Routes())
+	stats := r.Window("GET /items/{id}", time.Minute)
+	assert.Equal(t, int64(3), stats.Requests)
+	assert.Equal(t, int64(1), stats.Errors)
+}
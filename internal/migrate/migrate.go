@@ -0,0 +1,324 @@
+// Package migrate applies the SQL migrations embedded in the server. It
+// reads goose-format files and keeps goose's goose_db_version table, so
+// databases migrated with the goose CLI carry on where it left off.
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.sql$`)
+
+// Load reads the migrations in the root of fsys, sorted by version. Other
+// files are ignored.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int64]string)
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid migration %s: bad version", entry.Name())
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("invalid migration %s: version %d is also used by %s", entry.Name(), version, other)
+		}
+		seen[version] = entry.Name()
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		up, down, err := parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: m[2], Up: up, Down: down})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// parse splits a goose file into its Up and Down sections. Statement
+// markers are dropped: each section runs as one multi-statement query, so
+// function bodies need no special handling.
+func parse(content string) (up, down string, err error) {
+	var sections [2]strings.Builder
+	current := -1
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		annotation, ok := strings.CutPrefix(strings.TrimSpace(line), "-- +goose ")
+		if ok {
+			switch strings.TrimSpace(annotation) {
+			case "Up":
+				current = 0
+			case "Down":
+				current = 1
+			case "StatementBegin", "StatementEnd":
+			default:
+				return "", "", fmt.Errorf("unsupported annotation %q", annotation)
+			}
+			continue
+		}
+		if current >= 0 {
+			sections[current].WriteString(line)
+			sections[current].WriteByte('\n')
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+
+	up = strings.TrimSpace(sections[0].String())
+	if up == "" {
+		return "", "", errors.New("no -- +goose Up section")
+	}
+	return up, strings.TrimSpace(sections[1].String()), nil
+}
+
+// lockID serializes migrations between servers starting at once.
+const lockID = 7_416_532_901
+
+// Migrator applies migrations to one database.
+type Migrator struct {
+	conn       *pgx.Conn
+	migrations []Migration
+	log        *logger.Logger
+}
+
+func New(conn *pgx.Conn, migrations []Migration) *Migrator {
+	return &Migrator{
+		conn:       conn,
+		migrations: migrations,
+		log:        logger.New(),
+	}
+}
+
+// Latest is the version the migrations bring the schema to.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// State is what the database has applied.
+type State struct {
+	// Current is the highest applied version, 0 for an empty database.
+	Current int64
+	// Pending are the known migrations not applied yet, in order.
+	Pending []Migration
+}
+
+// State reads the applied versions. A database never migrated has none.
+func (m *Migrator) State(ctx context.Context) (State, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return State{}, err
+	}
+
+	var state State
+	for version := range applied {
+		state.Current = max(state.Current, version)
+	}
+	for _, migration := range m.migrations {
+		if !applied[migration.Version] {
+			state.Pending = append(state.Pending, migration)
+		}
+	}
+	return state, nil
+}
+
+// Check returns an error if migrations are pending, so a server does not
+// run against a schema older than its queries. A schema newer than the
+// server is allowed, since migrations only add to it, but logged.
+func (m *Migrator) Check(ctx context.Context) error {
+	state, err := m.State(ctx)
+	if err != nil {
+		return err
+	}
+	if len(state.Pending) > 0 {
+		return fmt.Errorf("database schema is at version %d, %d migrations pending up to %d: run `noture-server migrate up`",
+			state.Current, len(state.Pending), m.Latest())
+	}
+	if state.Current > m.Latest() {
+		m.log.Warn("Database schema is newer than this server", "schema_version", state.Current, "server_version", m.Latest())
+	}
+	return nil
+}
+
+// Up applies every pending migration, each in its own transaction, and
+// returns how many it applied. A pending migration older than an applied
+// one is refused rather than run out of order.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	if err := m.ensureTable(ctx); err != nil {
+		return 0, err
+	}
+	state, err := m.State(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, migration := range state.Pending {
+		if migration.Version < state.Current {
+			return 0, fmt.Errorf("migration %d_%s was never applied but %d was: apply it by hand",
+				migration.Version, migration.Name, state.Current)
+		}
+	}
+
+	for i, migration := range state.Pending {
+		if err := m.apply(ctx, migration.Version, migration.Up, true); err != nil {
+			return i, fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		m.log.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+	}
+	return len(state.Pending), nil
+}
+
+// Down rolls back the latest applied migration and returns it, or nil if
+// none is applied.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	state, err := m.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if state.Current == 0 {
+		return nil, nil
+	}
+	for _, migration := range m.migrations {
+		if migration.Version != state.Current {
+			continue
+		}
+		if err := m.apply(ctx, migration.Version, migration.Down, false); err != nil {
+			return nil, fmt.Errorf("rolling back migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		m.log.Info("Rolled back migration", "version", migration.Version, "name", migration.Name)
+		return &migration, nil
+	}
+	return nil, fmt.Errorf("migration %d is applied but unknown to this server", state.Current)
+}
+
+func (m *Migrator) apply(ctx context.Context, version int64, sql string, up bool) error {
+	tx, err := m.conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if sql != "" {
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(ctx, "INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, $2)", version, up)
+	if err != nil {
+		return fmt.Errorf("failed to record version: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// applied returns the versions whose latest record says applied. goose
+// appends a row for every up and down.
+func (m *Migrator) applied(ctx context.Context) (map[int64]bool, error) {
+	rows, err := m.conn.Query(ctx, "SELECT version_id, is_applied FROM goose_db_version ORDER BY id DESC")
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "42P01" {
+			return map[int64]bool{}, nil
+		}
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]bool)
+	decided := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		var isApplied bool
+		if err := rows.Scan(&version, &isApplied); err != nil {
+			return nil, fmt.Errorf("failed to read schema version: %w", err)
+		}
+		if version == 0 || decided[version] {
+			continue
+		}
+		decided[version] = true
+		if isApplied {
+			applied[version] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return applied, nil
+}
+
+// ensureTable creates goose's version table as goose does, with its
+// version 0 row.
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.conn.Exec(ctx, `
+CREATE TABLE IF NOT EXISTS goose_db_version (
+    id SERIAL PRIMARY KEY,
+    version_id BIGINT NOT NULL,
+    is_applied BOOLEAN NOT NULL,
+    tstamp TIMESTAMP DEFAULT NOW()
+);
+INSERT INTO goose_db_version (version_id, is_applied)
+SELECT 0, TRUE WHERE NOT EXISTS (SELECT 1 FROM goose_db_version);`)
+	if err != nil {
+		return fmt.Errorf("failed to create version table: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) lock(ctx context.Context) (func(), error) {
+	if _, err := m.conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return nil, fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	return func() {
+		m.conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", lockID)
+	}, nil
+}
@@ -0,0 +1,57 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/duckonomy/noture/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"002_second.sql": {Data: []byte("-- +goose Up\n-- +goose StatementBegin\nCREATE FUNCTION f() RETURNS INT AS $$ SELECT 1; $$ LANGUAGE sql;\n-- +goose StatementEnd\n")},
+		"001_first.sql":  {Data: []byte("-- +goose Up\nCREATE TABLE a (id INT);\n\n-- +goose Down\nDROP TABLE a;\n")},
+		"README.md":      {Data: []byte("not a migration")},
+	}
+
+	got, err := Load(fsys)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, Migration{Version: 1, Name: "first", Up: "CREATE TABLE a (id INT);", Down: "DROP TABLE a;"}, got[0])
+	assert.Equal(t, "CREATE FUNCTION f() RETURNS INT AS $$ SELECT 1; $$ LANGUAGE sql;", got[1].Up)
+	assert.Empty(t, got[1].Down)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{"no up", fstest.MapFS{"001_a.sql": {Data: []byte("CREATE TABLE a (id INT);")}}, "no -- +goose Up section"},
+		{"duplicate version", fstest.MapFS{
+			"001_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;")},
+			"1_b.sql":   {Data: []byte("-- +goose Up\nSELECT 1;")},
+		}, "version 1 is also used"},
+		{"no transaction", fstest.MapFS{"001_a.sql": {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nSELECT 1;")}}, "unsupported annotation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.fsys)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+// TestLoad_Embedded keeps the shipped migrations loadable and numbered
+// without gaps.
+func TestLoad_Embedded(t *testing.T) {
+	got, err := Load(migrations.Files)
+	require.NoError(t, err)
+	require.NotEmpty(t, got)
+	for i, m := range got {
+		assert.Equal(t, int64(i+1), m.Version, m.Name)
+	}
+}
@@ -0,0 +1,558 @@
+package notion
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// node is an element or, with an empty tag, a run of text of the HTML
+// Notion exports. Notion writes well-formed markup, so the parser only
+// needs to tolerate stray end tags, not repair arbitrary HTML.
+type node struct {
+	tag      string
+	text     string
+	attrs    map[string]string
+	children []*node
+}
+
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+var tagNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*`)
+
+// parseHTML reads src into a tree under a root node. Scripts, styles and
+// comments are dropped.
+func parseHTML(src string) *node {
+	root := &node{tag: "#root"}
+	stack := []*node{root}
+	top := func() *node { return stack[len(stack)-1] }
+
+	for len(src) > 0 {
+		lt := strings.IndexByte(src, '<')
+		if lt < 0 {
+			lt = len(src)
+		}
+		if lt > 0 {
+			top().children = append(top().children, &node{text: html.UnescapeString(src[:lt])})
+			src = src[lt:]
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(src, "<!--"):
+			end := strings.Index(src, "-->")
+			if end < 0 {
+				return root
+			}
+			src = src[end+3:]
+		case strings.HasPrefix(src, "<!"), strings.HasPrefix(src, "<?"):
+			end := strings.IndexByte(src, '>')
+			if end < 0 {
+				return root
+			}
+			src = src[end+1:]
+		case strings.HasPrefix(src, "</"):
+			end := strings.IndexByte(src, '>')
+			if end < 0 {
+				return root
+			}
+			name := strings.ToLower(strings.TrimSpace(src[2:end]))
+			src = src[end+1:]
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].tag == name {
+					stack = stack[:i]
+					break
+				}
+			}
+		default:
+			name := tagNameRe.FindString(src[1:])
+			if name == "" {
+				top().children = append(top().children, &node{text: "<"})
+				src = src[1:]
+				continue
+			}
+			el := &node{tag: strings.ToLower(name), attrs: map[string]string{}}
+			var selfClosing bool
+			src, selfClosing = parseAttrs(src[1+len(name):], el.attrs)
+			top().children = append(top().children, el)
+
+			if el.tag == "script" || el.tag == "style" {
+				end := strings.Index(strings.ToLower(src), "</"+el.tag)
+				if end < 0 {
+					return root
+				}
+				src = src[end:]
+				continue
+			}
+			if !selfClosing && !voidElements[el.tag] {
+				stack = append(stack, el)
+			}
+		}
+	}
+	return root
+}
+
+// parseAttrs reads the attributes of a start tag into attrs and returns
+// the source after the tag, and whether it ended with "/>".
+func parseAttrs(src string, attrs map[string]string) (string, bool) {
+	for {
+		src = strings.TrimLeft(src, " \t\r\n")
+		switch {
+		case src == "":
+			return src, false
+		case src[0] == '>':
+			return src[1:], false
+		case strings.HasPrefix(src, "/>"):
+			return src[2:], true
+		case src[0] == '/':
+			src = src[1:]
+			continue
+		}
+
+		end := strings.IndexAny(src, " \t\r\n=>/")
+		if end < 0 {
+			return "", false
+		}
+		if end == 0 {
+			// A stray character such as a quote; skip it.
+			src = src[1:]
+			continue
+		}
+		name := strings.ToLower(src[:end])
+		src = strings.TrimLeft(src[end:], " \t\r\n")
+		if !strings.HasPrefix(src, "=") {
+			attrs[name] = ""
+			continue
+		}
+		src = strings.TrimLeft(src[1:], " \t\r\n")
+
+		var value string
+		if src != "" && (src[0] == '"' || src[0] == '\'') {
+			close := strings.IndexByte(src[1:], src[0])
+			if close < 0 {
+				return "", false
+			}
+			value, src = src[1:1+close], src[2+close:]
+		} else {
+			end := strings.IndexAny(src, " \t\r\n>")
+			if end < 0 {
+				end = len(src)
+			}
+			value, src = src[:end], src[end:]
+		}
+		attrs[name] = html.UnescapeString(value)
+	}
+}
+
+func (n *node) hasClass(class string) bool {
+	return slices.Contains(strings.Fields(n.attrs["class"]), class)
+}
+
+// find returns the first element below n, n included, that match accepts.
+func (n *node) find(match func(*node) bool) *node {
+	if n.tag != "" && match(n) {
+		return n
+	}
+	for _, child := range n.children {
+		if found := child.find(match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// findAll returns the elements below n that match accepts, without
+// descending into them.
+func (n *node) findAll(match func(*node) bool) []*node {
+	var found []*node
+	for _, child := range n.children {
+		if child.tag != "" && match(child) {
+			found = append(found, child)
+			continue
+		}
+		found = append(found, child.findAll(match)...)
+	}
+	return found
+}
+
+// textContent is the text below n with whitespace collapsed.
+func (n *node) textContent() string {
+	var b strings.Builder
+	var walk func(*node)
+	walk = func(n *node) {
+		if n.tag == "" {
+			b.WriteString(n.text)
+			b.WriteByte(' ')
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// rawText is the text below n as written, for code blocks.
+func (n *node) rawText() string {
+	var b strings.Builder
+	var walk func(*node)
+	walk = func(n *node) {
+		switch n.tag {
+		case "":
+			b.WriteString(n.text)
+		case "br":
+			b.WriteByte('\n')
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+func isTag(tags ...string) func(*node) bool {
+	return func(n *node) bool { return slices.Contains(tags, n.tag) }
+}
+
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "body": true, "details": true,
+	"div": true, "dl": true, "fieldset": true, "figcaption": true, "figure": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+	"html": true, "li": true, "main": true, "nav": true, "ol": true, "p": true, "pre": true,
+	"section": true, "summary": true, "table": true, "ul": true,
+}
+
+// A block is one Markdown block. Items of adjacent lists of the same kind
+// are joined into one list, as Notion writes each item as a list of its
+// own.
+type block struct {
+	text string
+	list string
+}
+
+// markdownWriter renders HTML as Markdown. link maps the target of each
+// link and image; elements in skip are left out.
+type markdownWriter struct {
+	link func(target string, image bool) string
+	skip map[*node]bool
+}
+
+// render returns the Markdown for the children of n.
+func (w *markdownWriter) render(n *node) string {
+	return joinBlocks(w.blocks(n.children))
+}
+
+func joinBlocks(blocks []block) string {
+	var b strings.Builder
+	for i, blk := range blocks {
+		if i > 0 {
+			if blk.list != "" && blk.list == blocks[i-1].list {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\n\n")
+			}
+		}
+		b.WriteString(blk.text)
+	}
+	return b.String()
+}
+
+func (w *markdownWriter) blocks(nodes []*node) []block {
+	var blocks []block
+	var inline strings.Builder
+	flush := func() {
+		if text := strings.TrimSpace(collapseSpaces(inline.String())); text != "" {
+			blocks = append(blocks, block{text: text})
+		}
+		inline.Reset()
+	}
+	for _, n := range nodes {
+		if w.skip[n] {
+			continue
+		}
+		if n.tag != "" && blockElements[n.tag] {
+			flush()
+			blocks = append(blocks, w.block(n)...)
+			continue
+		}
+		inline.WriteString(w.inline(n))
+	}
+	flush()
+	return blocks
+}
+
+func (w *markdownWriter) block(n *node) []block {
+	switch n.tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := strings.TrimSpace(collapseSpaces(w.inlineChildren(n)))
+		if text == "" {
+			return nil
+		}
+		level, _ := strconv.Atoi(n.tag[1:])
+		return []block{{text: strings.Repeat("#", level) + " " + text}}
+	case "hr":
+		return []block{{text: "---"}}
+	case "pre":
+		return []block{{text: codeBlock(n)}}
+	case "blockquote":
+		return []block{{text: quote(w.render(n))}}
+	case "figure":
+		if n.hasClass("callout") {
+			return []block{{text: quote(w.render(n))}}
+		}
+	case "ul", "ol":
+		return w.list(n)
+	case "table":
+		if text := w.table(n); text != "" {
+			return []block{{text: text}}
+		}
+		return nil
+	case "details":
+		// A toggle opens with its summary, as a list item when Notion
+		// writes it in one, and shows its content below.
+		var summary string
+		var rest []*node
+		for _, child := range n.children {
+			if child.tag == "summary" && summary == "" {
+				summary = strings.TrimSpace(collapseSpaces(w.inlineChildren(child)))
+				continue
+			}
+			rest = append(rest, child)
+		}
+		blocks := w.blocks(rest)
+		if summary != "" {
+			blocks = append([]block{{text: summary}}, blocks...)
+		}
+		return blocks
+	case "figcaption":
+		if text := strings.TrimSpace(collapseSpaces(w.inlineChildren(n))); text != "" {
+			return []block{{text: "*" + text + "*"}}
+		}
+		return nil
+	}
+	return w.blocks(n.children)
+}
+
+func (w *markdownWriter) list(n *node) []block {
+	ordered := n.tag == "ol"
+	number := 1
+	if start, err := strconv.Atoi(n.attrs["start"]); err == nil {
+		number = start
+	}
+	todo := n.hasClass("to-do-list")
+
+	var items []string
+	for _, li := range n.children {
+		if li.tag != "li" {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		if todo {
+			checked := li.find(func(n *node) bool { return n.hasClass("checkbox-on") }) != nil
+			if checked {
+				marker += "[x] "
+			} else {
+				marker += "[ ] "
+			}
+		}
+		items = append(items, listItem(marker, "", w.render(li)))
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	kind := "-"
+	if ordered {
+		kind = "1."
+	}
+	return []block{{text: strings.Join(items, "\n"), list: kind}}
+}
+
+// listItem writes head after marker and indents body below it; without
+// a head the body's first line takes its place.
+func listItem(marker, head, body string) string {
+	if head == "" {
+		head, body, _ = strings.Cut(body, "\n")
+		body = strings.TrimLeft(body, "\n")
+	}
+	if body == "" {
+		return marker + head
+	}
+	return marker + head + "\n" + indent(body, "    ")
+}
+
+func (w *markdownWriter) table(n *node) string {
+	var rows [][]string
+	for _, tr := range n.findAll(isTag("tr")) {
+		var row []string
+		for _, cell := range tr.children {
+			if cell.tag != "th" && cell.tag != "td" {
+				continue
+			}
+			text := strings.TrimSpace(collapseSpaces(strings.ReplaceAll(w.inlineChildren(cell), "\n", " ")))
+			row = append(row, strings.ReplaceAll(text, "|", `\|`))
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	var b strings.Builder
+	writeRow := func(row []string) {
+		b.WriteString("|")
+		for i := range width {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+	}
+	writeRow(rows[0])
+	b.WriteString("|" + strings.Repeat(" --- |", width) + "\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (w *markdownWriter) inlineChildren(n *node) string {
+	var b strings.Builder
+	for _, child := range n.children {
+		b.WriteString(w.inline(child))
+	}
+	return b.String()
+}
+
+func (w *markdownWriter) inline(n *node) string {
+	switch n.tag {
+	case "":
+		// Line breaks in the source are spaces; only <br> breaks lines.
+		return strings.ReplaceAll(n.text, "\n", " ")
+	case "br":
+		return "\n"
+	case "strong", "b":
+		return wrap(w.inlineChildren(n), "**")
+	case "em", "i":
+		return wrap(w.inlineChildren(n), "*")
+	case "del", "s", "strike":
+		return wrap(w.inlineChildren(n), "~~")
+	case "mark":
+		return wrap(w.inlineChildren(n), "==")
+	case "code":
+		text := n.rawText()
+		if text == "" {
+			return ""
+		}
+		fence := "`"
+		for strings.Contains(text, fence) {
+			fence += "`"
+		}
+		return fence + text + fence
+	case "a":
+		text := strings.TrimSpace(collapseSpaces(w.inlineChildren(n)))
+		href := n.attrs["href"]
+		if href == "" {
+			return text
+		}
+		target := w.link(href, false)
+		if text == "" {
+			text = target
+		}
+		if strings.HasPrefix(text, "![") {
+			// A linked image, as Notion writes images: the image will do.
+			return text
+		}
+		return "[" + text + "](" + target + ")"
+	case "img":
+		src := n.attrs["src"]
+		if src == "" {
+			return ""
+		}
+		return "![" + collapseSpaces(n.attrs["alt"]) + "](" + w.link(src, true) + ")"
+	case "input":
+		return ""
+	}
+	if blockElements[n.tag] {
+		return " " + strings.ReplaceAll(w.render(n), "\n\n", "\n") + " "
+	}
+	return w.inlineChildren(n)
+}
+
+// wrap puts marker around text, outside its leading and trailing spaces,
+// which Markdown emphasis may not hold.
+func wrap(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	start := strings.Index(text, trimmed)
+	return text[:start] + marker + trimmed + marker + text[start+len(trimmed):]
+}
+
+func codeBlock(pre *node) string {
+	code := pre.find(isTag("code"))
+	language := ""
+	if code != nil {
+		for _, class := range strings.Fields(code.attrs["class"]) {
+			if lang, ok := strings.CutPrefix(class, "language-"); ok {
+				language = strings.ToLower(strings.ReplaceAll(lang, " ", "-"))
+			}
+		}
+	}
+	text := strings.TrimRight(pre.rawText(), "\n")
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + language + "\n" + text + "\n" + fence
+}
+
+func quote(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func indent(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var spacesRe = regexp.MustCompile(`[ \t\r\f]+`)
+
+// collapseSpaces collapses runs of spaces as HTML displays them, keeping
+// the line breaks of <br> but not the spaces around them.
+func collapseSpaces(text string) string {
+	text = spacesRe.ReplaceAllString(text, " ")
+	return strings.ReplaceAll(strings.ReplaceAll(text, " \n", "\n"), "\n ", "\n")
+}
@@ -0,0 +1,671 @@
+// Package notion converts the exports Notion produces, in HTML or in
+// Markdown, into Markdown notes. Each page becomes a note with its title,
+// Notion ID and properties as frontmatter. Notion's IDs are dropped from
+// file and folder names, links between pages of the export are pointed at
+// the notes they became, and images a page embeds from the web can be
+// downloaded next to it. Every other file of the export, such as images
+// and database CSVs, is kept as an attachment.
+package notion
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// MaxFiles caps the files of an export, nested parts included.
+	MaxFiles = 20000
+	// maxPageBytes caps a page; larger ones are skipped.
+	maxPageBytes = 16 << 20
+	// maxPartBytes caps each part of an export Notion split into zips.
+	maxPartBytes = 256 << 20
+)
+
+// FetchFunc downloads an asset a page embeds from the web.
+type FetchFunc func(ctx context.Context, url string) ([]byte, error)
+
+// Options change how an export is converted.
+type Options struct {
+	// Folder is the folder prefix of every path, e.g. "Notion/".
+	Folder string
+	// Fetch downloads the images pages embed from the web. Without it,
+	// or when a download fails, they stay remote.
+	Fetch FetchFunc
+	// MaxDownloadBytes caps what Fetch downloads in all.
+	MaxDownloadBytes int64
+}
+
+// File is a converted page or a downloaded asset.
+type File struct {
+	Path    string
+	Content []byte
+}
+
+// Attachment is a file of the export kept as it is, read from the
+// archive on demand.
+type Attachment struct {
+	Path string
+	file *zip.File
+}
+
+// Size is the attachment's size as the archive declares it.
+func (a Attachment) Size() int64 {
+	return int64(a.file.UncompressedSize64)
+}
+
+// Read returns the attachment's content.
+func (a Attachment) Read() ([]byte, error) {
+	return readEntry(a.file, a.Size())
+}
+
+// Issue is a file or asset that could not be imported.
+type Issue struct {
+	Path   string
+	Reason string
+}
+
+// Export is a converted export. Pages and Downloads are in memory;
+// Attachments are read as they are stored.
+type Export struct {
+	Pages       []File
+	Attachments []Attachment
+	Downloads   []File
+	Issues      []Issue
+}
+
+type entry struct {
+	path string
+	file *zip.File
+}
+
+var (
+	notionIDRe     = regexp.MustCompile(`^(.*?)\s*([0-9a-f]{32})$`)
+	notionURLIDRe  = regexp.MustCompile(`([0-9a-f]{32})$`)
+	propertyLineRe = regexp.MustCompile(`^([\p{L}\p{N}][^:]{0,63}): (.*)$`)
+	markdownLinkRe = regexp.MustCompile(`(!?)\[([^\]]*)\]\(([^)\s]+)\)`)
+)
+
+// Convert reads the export in r. Exports Notion split into parts, a zip
+// of zips, are read part by part. An archive without a single page is not
+// a Notion export.
+func Convert(ctx context.Context, r *zip.Reader, opts Options) (*Export, error) {
+	entries, err := exportEntries(r)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &converter{
+		opts:   opts,
+		paths:  make(map[string]string),
+		byID:   make(map[string]string),
+		stems:  make(map[string]string),
+		used:   make(map[string]bool),
+		files:  make(map[string]bool),
+		remote: make(map[string]string),
+		export: &Export{},
+	}
+
+	var pages []entry
+	for _, e := range entries {
+		mapped := c.mapPath(e.path)
+		c.paths[e.path] = mapped
+		c.files[mapped] = true
+		if isPage(e.path) {
+			pages = append(pages, e)
+			if _, id := splitID(strings.TrimSuffix(path.Base(e.path), path.Ext(e.path))); id != "" {
+				c.byID[id] = mapped
+			}
+			continue
+		}
+		c.export.Attachments = append(c.export.Attachments, Attachment{Path: opts.Folder + mapped, file: e.file})
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("invalid notion export: no pages found")
+	}
+
+	for _, e := range pages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if e.file.UncompressedSize64 > maxPageBytes {
+			c.issue(c.paths[e.path], fmt.Sprintf("page too large: over %d bytes", maxPageBytes))
+			continue
+		}
+		content, err := readEntry(e.file, int64(e.file.UncompressedSize64))
+		if err != nil {
+			c.issue(c.paths[e.path], err.Error())
+			continue
+		}
+		c.export.Pages = append(c.export.Pages, File{
+			Path:    opts.Folder + c.paths[e.path],
+			Content: c.convertPage(ctx, e.path, content),
+		})
+	}
+	return c.export, nil
+}
+
+// exportEntries lists the files of the export, sorted by path, reading
+// nested parts into memory.
+func exportEntries(r *zip.Reader) ([]entry, error) {
+	var entries []entry
+	var add func(r *zip.Reader, nested bool) error
+	add = func(r *zip.Reader, nested bool) error {
+		for _, f := range r.File {
+			name := path.Clean(strings.ReplaceAll(f.Name, "\\", "/"))
+			if f.FileInfo().IsDir() || skippedEntry(name) {
+				continue
+			}
+			if !nested && !strings.Contains(name, "/") && strings.EqualFold(path.Ext(name), ".zip") {
+				if f.UncompressedSize64 > maxPartBytes {
+					return fmt.Errorf("invalid notion export: part %s is over %d bytes", name, maxPartBytes)
+				}
+				content, err := readEntry(f, int64(f.UncompressedSize64))
+				if err != nil {
+					return fmt.Errorf("invalid notion export: %w", err)
+				}
+				part, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+				if err != nil {
+					return fmt.Errorf("invalid notion export: part %s: %w", name, err)
+				}
+				if err := add(part, true); err != nil {
+					return err
+				}
+				continue
+			}
+			entries = append(entries, entry{path: name, file: f})
+			if len(entries) > MaxFiles {
+				return fmt.Errorf("invalid notion export: more than %d files", MaxFiles)
+			}
+		}
+		return nil
+	}
+	if err := add(r, false); err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+	return entries, nil
+}
+
+// skippedEntry reports whether an archive entry is not part of the
+// export: macOS metadata, or a path leaving the archive.
+func skippedEntry(name string) bool {
+	return name == "." || strings.HasPrefix(name, "/") || name == ".." || strings.HasPrefix(name, "../") ||
+		strings.HasPrefix(name, "__MACOSX/") || path.Base(name) == ".DS_Store"
+}
+
+// readEntry reads f, failing if it holds more than its declared size.
+func readEntry(f *zip.File, size int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(io.LimitReader(rc, size+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	if int64(len(content)) > size {
+		return nil, fmt.Errorf("failed to read %s: larger than declared", f.Name)
+	}
+	return content, nil
+}
+
+func isPage(p string) bool {
+	ext := strings.ToLower(path.Ext(p))
+	return ext == ".md" || ext == ".html"
+}
+
+// splitID splits the Notion ID off a file or folder name.
+func splitID(stem string) (name, id string) {
+	if m := notionIDRe.FindStringSubmatch(stem); m != nil {
+		return m[1], m[2]
+	}
+	return stem, ""
+}
+
+type converter struct {
+	opts Options
+	// paths maps each file of the export to its path in the workspace,
+	// without the folder prefix, and byID each page's Notion ID to it.
+	paths map[string]string
+	byID  map[string]string
+	// stems maps names, with their folder, to what they became, so a
+	// page and the folder of its subpages keep the same name; used holds
+	// the names taken and files the paths.
+	stems map[string]string
+	used  map[string]bool
+	files map[string]bool
+	// remote maps downloaded URLs to their paths, or "" when the
+	// download failed.
+	remote     map[string]string
+	downloaded int64
+	export     *Export
+}
+
+func (c *converter) issue(p, reason string) {
+	c.export.Issues = append(c.export.Issues, Issue{Path: c.opts.Folder + p, Reason: reason})
+}
+
+// mapPath names the file at p in the workspace: IDs are dropped from
+// every name, pages become Markdown notes, and names that would then
+// clash are numbered.
+func (c *converter) mapPath(p string) string {
+	segments := strings.Split(p, "/")
+	origDir, newDir := "", ""
+	for _, segment := range segments[:len(segments)-1] {
+		newDir = c.mapStem(origDir, newDir, segment)
+		origDir = path.Join(origDir, segment)
+	}
+	base := segments[len(segments)-1]
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	if isPage(base) {
+		ext = ".md"
+	}
+	if stem == "" {
+		// A dotfile keeps its name.
+		stem, ext = base, ""
+	}
+	return c.mapStem(origDir, newDir, stem) + ext
+}
+
+func (c *converter) mapStem(origDir, newDir, stem string) string {
+	key := path.Join(origDir, stem)
+	if mapped, ok := c.stems[key]; ok {
+		return mapped
+	}
+	name, _ := splitID(stem)
+	name = strings.TrimRight(strings.TrimSpace(name), ".")
+	if name == "" {
+		name = "Untitled"
+	}
+	mapped := path.Join(newDir, name)
+	for n := 2; c.used[mapped]; n++ {
+		mapped = path.Join(newDir, fmt.Sprintf("%s %d", name, n))
+	}
+	c.used[mapped] = true
+	c.stems[key] = mapped
+	return mapped
+}
+
+// property is a page property; List ones hold several values.
+type property struct {
+	key    string
+	values []string
+	list   bool
+}
+
+// convertPage turns the page at zipPath into a note.
+func (c *converter) convertPage(ctx context.Context, zipPath string, content []byte) []byte {
+	notePath := c.paths[zipPath]
+	link := func(target string, image bool) string {
+		return c.link(ctx, zipPath, notePath, target, image)
+	}
+
+	var title, body string
+	var props []property
+	if strings.EqualFold(path.Ext(zipPath), ".html") {
+		title, props, body = convertHTMLPage(string(content), link)
+	} else {
+		title, props, body = convertMarkdownPage(string(content), link)
+	}
+	stem := strings.TrimSuffix(path.Base(zipPath), path.Ext(zipPath))
+	name, id := splitID(stem)
+	if title == "" {
+		title = strings.TrimSpace(name)
+	}
+
+	var b strings.Builder
+	b.WriteString(frontmatter(title, id, props))
+	if title != "" {
+		b.WriteString("# " + title + "\n\n")
+	}
+	if body != "" {
+		b.WriteString(body + "\n")
+	}
+	return []byte(b.String())
+}
+
+// convertMarkdownPage splits a page of a Markdown export into its title,
+// the "Key: Value" lines Notion writes below it for properties, and the
+// rest, whose links are rewritten.
+func convertMarkdownPage(src string, link func(string, bool) string) (string, []property, string) {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var title string
+	i := 0
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "# ") {
+		title = strings.TrimSpace(lines[0][2:])
+		i = 1
+	}
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+
+	var props []property
+	j := i
+	for j < len(lines) && propertyLineRe.MatchString(lines[j]) {
+		j++
+	}
+	if title != "" && j > i && (j == len(lines) || strings.TrimSpace(lines[j]) == "") {
+		for _, line := range lines[i:j] {
+			m := propertyLineRe.FindStringSubmatch(line)
+			props = append(props, markdownProperty(m[1], m[2]))
+		}
+		i = j
+	}
+
+	body := strings.Trim(strings.Join(lines[i:], "\n"), "\n")
+	return title, props, rewriteMarkdownLinks(body, link)
+}
+
+// markdownProperty reads a property as a Markdown export writes it, where
+// multi-select values are only told apart by their commas. Of those, only
+// tags are split.
+func markdownProperty(key, value string) property {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(key, "tags") {
+		var values []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		return property{key: key, values: values, list: true}
+	}
+	return property{key: key, values: []string{value}}
+}
+
+// rewriteMarkdownLinks passes the target of every link and image outside
+// code blocks through link.
+func rewriteMarkdownLinks(body string, link func(string, bool) string) string {
+	lines := strings.Split(body, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		lines[i] = markdownLinkRe.ReplaceAllStringFunc(line, func(m string) string {
+			parts := markdownLinkRe.FindStringSubmatch(m)
+			return parts[1] + "[" + parts[2] + "](" + link(parts[3], parts[1] == "!") + ")"
+		})
+	}
+	return strings.Join(lines, "\n")
+}
+
+// convertHTMLPage reads the title and property table of a page of an
+// HTML export and renders its body as Markdown.
+func convertHTMLPage(src string, link func(string, bool) string) (string, []property, string) {
+	root := parseHTML(src)
+	writer := &markdownWriter{link: link, skip: make(map[*node]bool)}
+
+	var title string
+	if h1 := root.find(func(n *node) bool { return n.tag == "h1" && n.hasClass("page-title") }); h1 != nil {
+		title = h1.textContent()
+		writer.skip[h1] = true
+	} else if t := root.find(isTag("title")); t != nil {
+		title = t.textContent()
+	}
+	if head := root.find(isTag("head")); head != nil {
+		writer.skip[head] = true
+	}
+
+	var props []property
+	if table := root.find(func(n *node) bool { return n.tag == "table" && n.hasClass("properties") }); table != nil {
+		writer.skip[table] = true
+		for _, tr := range table.findAll(isTag("tr")) {
+			th, td := tr.find(isTag("th")), tr.find(isTag("td"))
+			if th == nil || td == nil {
+				continue
+			}
+			p := property{key: th.textContent()}
+			if selected := td.findAll(func(n *node) bool { return n.hasClass("selected-value") }); len(selected) > 0 {
+				p.list = true
+				for _, s := range selected {
+					p.values = append(p.values, s.textContent())
+				}
+			} else {
+				p.values = []string{td.textContent()}
+			}
+			props = append(props, p)
+		}
+	}
+
+	body := root.find(func(n *node) bool { return n.tag == "div" && n.hasClass("page-body") })
+	if body == nil {
+		body = root
+	}
+	return title, props, strings.TrimSpace(writer.render(body))
+}
+
+// link points a link or image target of the page at zipPath, which became
+// notePath, at what its target became: a note or attachment of the
+// export, or a downloaded image. Other targets are kept.
+func (c *converter) link(ctx context.Context, zipPath, notePath, target string, image bool) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	fragment := ""
+	if u.Fragment != "" {
+		fragment = "#" + u.EscapedFragment()
+	}
+
+	if u.Scheme == "http" || u.Scheme == "https" {
+		host := strings.ToLower(u.Hostname())
+		if host == "notion.so" || strings.HasSuffix(host, ".notion.so") || strings.HasSuffix(host, ".notion.site") {
+			if id := notionURLIDRe.FindString(strings.ReplaceAll(path.Base(u.Path), "-", "")); id != "" {
+				if mapped, ok := c.byID[id]; ok {
+					return relativeLink(notePath, mapped) + fragment
+				}
+			}
+		}
+		if image {
+			return c.download(ctx, notePath, target)
+		}
+		return target
+	}
+	if u.Scheme != "" || u.Host != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+		return target
+	}
+
+	mapped, ok := c.paths[path.Join(path.Dir(zipPath), u.Path)]
+	if !ok {
+		return target
+	}
+	return relativeLink(notePath, mapped) + fragment
+}
+
+// download saves an image embedded from rawURL in a folder named after
+// the note, as Notion does with uploaded images, and returns the link to
+// it. Each URL is downloaded once.
+func (c *converter) download(ctx context.Context, notePath, rawURL string) string {
+	if saved, ok := c.remote[rawURL]; ok {
+		if saved == "" {
+			return rawURL
+		}
+		return relativeLink(notePath, saved)
+	}
+	c.remote[rawURL] = ""
+	if c.opts.Fetch == nil {
+		return rawURL
+	}
+
+	u, _ := url.Parse(rawURL)
+	shown := u.Scheme + "://" + u.Host + u.EscapedPath()
+	if c.downloaded >= c.opts.MaxDownloadBytes {
+		c.issue(notePath, "not downloaded, import download limit reached: "+shown)
+		return rawURL
+	}
+	content, err := c.opts.Fetch(ctx, rawURL)
+	if err != nil {
+		c.issue(notePath, fmt.Sprintf("failed to download %s: %v", shown, err))
+		return rawURL
+	}
+	if c.downloaded+int64(len(content)) > c.opts.MaxDownloadBytes {
+		c.issue(notePath, "not downloaded, import download limit reached: "+shown)
+		return rawURL
+	}
+	c.downloaded += int64(len(content))
+
+	name := assetName(u.Path, content)
+	dir := strings.TrimSuffix(notePath, path.Ext(notePath))
+	ext := path.Ext(name)
+	saved := path.Join(dir, name)
+	for n := 2; c.files[saved]; n++ {
+		saved = path.Join(dir, fmt.Sprintf("%s %d%s", strings.TrimSuffix(name, ext), n, ext))
+	}
+	c.files[saved] = true
+	c.remote[rawURL] = saved
+	c.export.Downloads = append(c.export.Downloads, File{Path: c.opts.Folder + saved, Content: content})
+	return relativeLink(notePath, saved)
+}
+
+var assetExtensions = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/bmp":       ".bmp",
+	"application/pdf": ".pdf",
+}
+
+// assetName names a downloaded asset after the last segment of its URL
+// path, without characters links cannot hold, adding an extension from
+// its content when it has none.
+func assetName(urlPath string, content []byte) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`\/:*?"<>|#^[]`, r) || unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, path.Base(urlPath))
+	name = strings.Trim(name, " .")
+	if name == "" {
+		name = "image"
+	}
+	if path.Ext(name) == "" {
+		mediaType, _, _ := strings.Cut(http.DetectContentType(content), ";")
+		name += assetExtensions[mediaType]
+	}
+	return name
+}
+
+// relativeLink is the escaped relative link from the note at from to the
+// file at to.
+func relativeLink(from, to string) string {
+	fromDir := strings.Split(path.Dir(from), "/")
+	if fromDir[0] == "." {
+		fromDir = nil
+	}
+	target := strings.Split(to, "/")
+	common := 0
+	for common < len(fromDir) && common < len(target)-1 && fromDir[common] == target[common] {
+		common++
+	}
+
+	var segments []string
+	for range fromDir[common:] {
+		segments = append(segments, "..")
+	}
+	for _, segment := range target[common:] {
+		segments = append(segments, url.PathEscape(segment))
+	}
+	return strings.Join(segments, "/")
+}
+
+// frontmatter writes the page's title, Notion ID and properties as YAML
+// frontmatter. Property names become lowercase keys; tags lose their
+// spaces, which tags cannot hold.
+func frontmatter(title, id string, props []property) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	b.WriteString("title: " + yamlScalar(title) + "\n")
+	if id != "" {
+		b.WriteString("notion_id: " + yamlScalar(id) + "\n")
+	}
+	seen := map[string]bool{"title": true, "notion_id": true}
+	for _, p := range props {
+		key := propertyKey(p.key)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		values := p.values
+		if key == "tags" {
+			values = nil
+			for _, v := range p.values {
+				if tag := strings.Join(strings.Fields(strings.TrimPrefix(v, "#")), "-"); tag != "" {
+					values = append(values, tag)
+				}
+			}
+		}
+		if !p.list {
+			value := ""
+			if len(values) > 0 {
+				value = values[0]
+			}
+			b.WriteString(key + ": " + yamlScalar(value) + "\n")
+			continue
+		}
+		if len(values) == 0 {
+			b.WriteString(key + ": []\n")
+			continue
+		}
+		b.WriteString(key + ":\n")
+		for _, v := range values {
+			b.WriteString("  - " + yamlScalar(v) + "\n")
+		}
+	}
+	b.WriteString("---\n\n")
+	return b.String()
+}
+
+// propertyKey turns a property name such as "Due date" into due_date.
+func propertyKey(name string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+			if underscore && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			underscore = false
+			b.WriteRune(r)
+			continue
+		}
+		underscore = true
+	}
+	return b.String()
+}
+
+// yamlScalar writes s on one line, quoted when YAML would read it as
+// anything but that string.
+func yamlScalar(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return `""`
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
@@ -0,0 +1,188 @@
+package notion
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	projectsID = "0123456789abcdef0123456789abcdef"
+	launchID   = "fedcba9876543210fedcba9876543210"
+)
+
+func exportZip(t *testing.T, files map[string]string) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	return zr
+}
+
+func pages(export *Export) map[string]string {
+	byPath := make(map[string]string)
+	for _, page := range export.Pages {
+		byPath[page.Path] = string(page.Content)
+	}
+	return byPath
+}
+
+func TestConvert_Markdown(t *testing.T) {
+	zr := exportZip(t, map[string]string{
+		"Projects " + projectsID + ".md": "# Projects\n\nStatus: Active\nTags: work, side project\n\n" +
+			"See [Launch](Projects%20" + projectsID + "/Launch%20" + launchID + ".md) and [the board](Projects%20" + projectsID + "/Board.csv).\n\n" +
+			"```\n[kept](Projects%20" + projectsID + ".md)\n```\n",
+		"Projects " + projectsID + "/Launch " + launchID + ".md": "# Launch\n\n![Diagram](Untitled.png)\n\nBack to [Projects](https://www.notion.so/Projects-" + projectsID + ")",
+		"Projects " + projectsID + "/Untitled.png":               "png",
+		"Projects " + projectsID + "/Board.csv":                  "Name\n",
+		"__MACOSX/._Projects.md":                                 "",
+	})
+
+	export, err := Convert(context.Background(), zr, Options{Folder: "Notion/"})
+	require.NoError(t, err)
+	assert.Empty(t, export.Issues)
+
+	byPath := pages(export)
+	require.Len(t, byPath, 2)
+	assert.Equal(t, "---\ntitle: Projects\nnotion_id: "+projectsID+"\nstatus: Active\ntags:\n  - work\n  - side-project\n---\n\n"+
+		"# Projects\n\n"+
+		"See [Launch](Projects/Launch.md) and [the board](Projects/Board.csv).\n\n"+
+		"```\n[kept](Projects%20"+projectsID+".md)\n```\n", byPath["Notion/Projects.md"])
+	assert.Equal(t, "---\ntitle: Launch\nnotion_id: "+launchID+"\n---\n\n"+
+		"# Launch\n\n![Diagram](Untitled.png)\n\nBack to [Projects](../Projects.md)\n", byPath["Notion/Projects/Launch.md"])
+
+	var attachments []string
+	for _, a := range export.Attachments {
+		attachments = append(attachments, a.Path)
+	}
+	assert.ElementsMatch(t, []string{"Notion/Projects/Board.csv", "Notion/Projects/Untitled.png"}, attachments)
+}
+
+func TestConvert_HTML(t *testing.T) {
+	zr := exportZip(t, map[string]string{
+		"Launch " + launchID + ".html": `<html><head><title>Launch</title><style>p { color: red }</style></head><body>
+<article><header><h1 class="page-title">Launch</h1>
+<table class="properties"><tbody>
+<tr><th>Due date</th><td>October 1, 2026</td></tr>
+<tr><th>Tags</th><td><span class="selected-value">launch</span><span class="selected-value">q4</span></td></tr>
+</tbody></table></header>
+<div class="page-body"><h2>Plan</h2><p>Ship <strong>it</strong>, see <a href="Notes%20` + projectsID + `.html">Notes</a>.</p>
+<ul class="to-do-list"><li><div class="checkbox checkbox-on"></div><span class="to-do-children-checked">Write</span></li></ul>
+<figure class="image"><a href="https://example.com/assets/chart.png"><img src="https://example.com/assets/chart.png"></a></figure>
+<pre class="code"><code class="language-go">fmt.Println("hi")</code></pre></div></article></body></html>`,
+		"Notes " + projectsID + ".html": `<html><body><p>Loose page</p></body></html>`,
+	})
+
+	var fetched []string
+	fetch := func(_ context.Context, url string) ([]byte, error) {
+		fetched = append(fetched, url)
+		return []byte("\x89PNG\r\n\x1a\n"), nil
+	}
+	export, err := Convert(context.Background(), zr, Options{Fetch: fetch, MaxDownloadBytes: 1 << 20})
+	require.NoError(t, err)
+	assert.Empty(t, export.Issues)
+	assert.Equal(t, []string{"https://example.com/assets/chart.png"}, fetched)
+	require.Len(t, export.Downloads, 1)
+	assert.Equal(t, "Launch/chart.png", export.Downloads[0].Path)
+
+	byPath := pages(export)
+	assert.Equal(t, "---\ntitle: Launch\nnotion_id: "+launchID+"\ndue_date: October 1, 2026\ntags:\n  - launch\n  - q4\n---\n\n"+
+		"# Launch\n\n"+
+		"## Plan\n\nShip **it**, see [Notes](Notes.md).\n\n"+
+		"- [x] Write\n\n"+
+		"![](Launch/chart.png)\n\n"+
+		"```go\nfmt.Println(\"hi\")\n```\n", byPath["Launch.md"])
+	assert.Equal(t, "---\ntitle: Notes\nnotion_id: "+projectsID+"\n---\n\n# Notes\n\nLoose page\n", byPath["Notes.md"])
+}
+
+func TestConvert_Downloads(t *testing.T) {
+	page := "# Gallery\n\n![a](https://example.com/a.png) ![b](https://example.com/b) ![a again](https://example.com/a.png)\n"
+
+	t.Run("failures keep the remote image", func(t *testing.T) {
+		fetch := func(_ context.Context, url string) ([]byte, error) {
+			if url == "https://example.com/a.png" {
+				return nil, errors.New("unexpected status 404 Not Found")
+			}
+			return []byte("GIF89a"), nil
+		}
+		export, err := Convert(context.Background(), exportZip(t, map[string]string{"Gallery.md": page}), Options{Fetch: fetch, MaxDownloadBytes: 1 << 20})
+		require.NoError(t, err)
+		require.Len(t, export.Downloads, 1)
+		assert.Equal(t, "Gallery/b.gif", export.Downloads[0].Path, "an extension is added from the content")
+		assert.Contains(t, pages(export)["Gallery.md"], "![a](https://example.com/a.png) ![b](Gallery/b.gif) ![a again](https://example.com/a.png)")
+		require.Len(t, export.Issues, 1, "each URL is fetched once")
+		assert.Equal(t, "Gallery.md", export.Issues[0].Path)
+		assert.Contains(t, export.Issues[0].Reason, "failed to download https://example.com/a.png")
+	})
+
+	t.Run("the download limit is shared", func(t *testing.T) {
+		fetch := func(context.Context, string) ([]byte, error) { return []byte("GIF89a"), nil }
+		export, err := Convert(context.Background(), exportZip(t, map[string]string{"Gallery.md": page}), Options{Fetch: fetch, MaxDownloadBytes: 10})
+		require.NoError(t, err)
+		require.Len(t, export.Downloads, 1)
+		assert.Equal(t, "Gallery/a.png", export.Downloads[0].Path)
+		require.Len(t, export.Issues, 1)
+		assert.Contains(t, export.Issues[0].Reason, "download limit reached: https://example.com/b")
+	})
+}
+
+func TestConvert_Names(t *testing.T) {
+	zr := exportZip(t, map[string]string{
+		"Ideas " + projectsID + ".md":        "# Ideas\n\n[Other](Ideas%20" + launchID + ".md)",
+		"Ideas " + launchID + ".md":          "# Ideas\n",
+		"Ideas " + launchID + "/Untitled.md": "",
+	})
+	export, err := Convert(context.Background(), zr, Options{})
+	require.NoError(t, err)
+
+	byPath := pages(export)
+	require.Len(t, byPath, 3)
+	assert.Contains(t, byPath["Ideas.md"], "[Other](Ideas%202.md)")
+	assert.Contains(t, byPath, "Ideas 2/Untitled.md", "a page and the folder of its subpages keep the same name")
+}
+
+func TestConvert_Parts(t *testing.T) {
+	var part bytes.Buffer
+	zw := zip.NewWriter(&part)
+	w, err := zw.Create("Home " + projectsID + ".md")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("# Home\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	export, err := Convert(context.Background(), exportZip(t, map[string]string{"Export-Part-1.zip": part.String()}), Options{})
+	require.NoError(t, err)
+	assert.Contains(t, pages(export), "Home.md")
+
+	_, err = Convert(context.Background(), exportZip(t, map[string]string{"notes.txt": "x"}), Options{})
+	assert.ErrorContains(t, err, "invalid notion export")
+}
+
+func TestNewFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	fetch := NewFetcher(5*time.Second, 1<<20)
+	_, err := fetch(context.Background(), server.URL)
+	assert.ErrorContains(t, err, "non-public address")
+	_, err = fetch(context.Background(), "file:///etc/passwd")
+	assert.ErrorContains(t, err, "unsupported scheme")
+}
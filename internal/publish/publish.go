@@ -0,0 +1,333 @@
+// Package publish builds a static site from a workspace's notes: a page
+// per Markdown or Org note with breadcrumbs, its folder's other pages and
+// its backlinks, plus an index of every page. Wiki-links between published
+// notes become links between their pages; links to anything else are not
+// followed, so unpublished notes stay private.
+package publish
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/pkg/markdown"
+)
+
+// IndexPage is the path of the generated index.
+const IndexPage = "index.html"
+
+// Note is a note offered for publishing.
+type Note struct {
+	Path    string
+	Content []byte
+	ModTime time.Time
+}
+
+// Selection chooses the notes of a site. Folders are path prefixes; an
+// empty IncludeFolders includes every folder. A note's own flag, publish
+// in its frontmatter or #+PUBLISH in Org, overrides the folders when it is
+// false, and with RequireFlag it must be true.
+type Selection struct {
+	IncludeFolders []string
+	ExcludeFolders []string
+	RequireFlag    bool
+}
+
+// Publishable reports whether a file is a note a site can show.
+func Publishable(filePath string) bool {
+	switch strings.ToLower(path.Ext(filePath)) {
+	case ".md", ".markdown", ".org":
+		return true
+	}
+	return false
+}
+
+// InFolders reports whether the folders of s include filePath. Notes that
+// are not may be skipped before their content is read.
+func (s Selection) InFolders(filePath string) bool {
+	for _, folder := range s.ExcludeFolders {
+		if strings.HasPrefix(filePath, folder) {
+			return false
+		}
+	}
+	if len(s.IncludeFolders) == 0 {
+		return true
+	}
+	for _, folder := range s.IncludeFolders {
+		if strings.HasPrefix(filePath, folder) {
+			return true
+		}
+	}
+	return false
+}
+
+// Selected reports whether note is on the site.
+func (s Selection) Selected(note Note) bool {
+	if !Publishable(note.Path) || !s.InFolders(note.Path) {
+		return false
+	}
+	flag, set := publishFlag(note)
+	if set {
+		return flag
+	}
+	return !s.RequireFlag
+}
+
+func publishFlag(note Note) (value, set bool) {
+	var raw string
+	if strings.EqualFold(path.Ext(note.Path), ".org") {
+		raw, set = markdown.OrgKeywords(note.Content)["publish"]
+	} else {
+		fields, _ := markdown.Frontmatter(note.Content)
+		raw, set = fields["publish"]
+	}
+	switch strings.ToLower(raw) {
+	case "true", "yes", "t", "1":
+		return true, set
+	}
+	return false, set
+}
+
+// Page is one note rendered for the site. Path is where the site serves
+// it; Body is the rendered note, without the surrounding layout.
+type Page struct {
+	Path      string
+	Source    string
+	Title     string
+	Body      string
+	ModTime   time.Time
+	Backlinks []string
+}
+
+// Site is a built site. Pages are keyed by path and do not include the
+// index, which is generated when served.
+type Site struct {
+	Title string
+	Pages map[string]*Page
+	order []string
+}
+
+// Build renders notes, which must already be selected, into a site.
+func Build(title string, notes []Note) *Site {
+	notes = slices.Clone(notes)
+	slices.SortFunc(notes, func(a, b Note) int { return strings.Compare(a.Path, b.Path) })
+
+	site := &Site{Title: title, Pages: make(map[string]*Page, len(notes))}
+	bySource := make(map[string]*Page, len(notes))
+	for _, note := range notes {
+		p := &Page{Path: pagePath(note.Path), Source: note.Path, Title: noteTitle(note), ModTime: note.ModTime}
+		if _, taken := site.Pages[p.Path]; taken || p.Path == IndexPage {
+			p.Path = note.Path + ".html"
+		}
+		site.Pages[p.Path] = p
+		bySource[note.Path] = p
+		site.order = append(site.order, p.Path)
+	}
+
+	backlinks := map[string]map[string]bool{}
+	for _, note := range notes {
+		page := bySource[note.Path]
+		opts := markdown.Options{WikiLink: func(target string) (string, bool) {
+			for _, candidate := range markdown.WikiLinkCandidates(note.Path, target) {
+				if linked, ok := bySource[candidate]; ok {
+					if linked != page {
+						if backlinks[linked.Path] == nil {
+							backlinks[linked.Path] = map[string]bool{}
+						}
+						backlinks[linked.Path][page.Path] = true
+					}
+					return escapePath(linked.Path), true
+				}
+			}
+			return "", false
+		}}
+		if strings.EqualFold(path.Ext(note.Path), ".org") {
+			page.Body = markdown.RenderOrg(note.Content, opts)
+		} else {
+			page.Body = markdown.RenderWith(note.Content, opts)
+		}
+	}
+	for pagePath, from := range backlinks {
+		for source := range from {
+			site.Pages[pagePath].Backlinks = append(site.Pages[pagePath].Backlinks, source)
+		}
+		slices.Sort(site.Pages[pagePath].Backlinks)
+	}
+	return site
+}
+
+// Paths lists the site's page paths in order, the index first.
+func (s *Site) Paths() []string {
+	return append([]string{IndexPage}, s.order...)
+}
+
+// Render writes the complete HTML document of the page at p, which is a
+// path from Paths or "" for the index. It reports false for unknown
+// pages.
+func (s *Site) Render(w io.Writer, p string) bool {
+	if p == "" || p == IndexPage {
+		s.writeLayout(w, "", s.Title, s.indexBody())
+		return true
+	}
+	page, ok := s.Pages[p]
+	if !ok {
+		return false
+	}
+
+	var body strings.Builder
+	body.WriteString(`<nav class="crumbs"><a href="` + IndexPage + `">` + html.EscapeString(s.Title) + `</a>`)
+	for _, folder := range folders(page.Path) {
+		body.WriteString(" / " + html.EscapeString(path.Base(folder)))
+	}
+	body.WriteString("</nav>\n<article>\n" + page.Body + "</article>\n")
+	if len(page.Backlinks) > 0 {
+		body.WriteString("<aside class=\"backlinks\"><h2>Linked from</h2>\n<ul>\n")
+		for _, source := range page.Backlinks {
+			body.WriteString(s.pageLink(source))
+		}
+		body.WriteString("</ul></aside>\n")
+	}
+	if siblings := s.siblings(page); len(siblings) > 0 {
+		body.WriteString("<nav class=\"folder\"><h2>In this folder</h2>\n<ul>\n")
+		for _, sibling := range siblings {
+			body.WriteString(s.pageLink(sibling))
+		}
+		body.WriteString("</ul></nav>\n")
+	}
+	s.writeLayout(w, page.Path, page.Title+" · "+s.Title, body.String())
+	return true
+}
+
+// WriteZip writes the site as a zip archive that can be served by any
+// static file server or opened from disk.
+func (s *Site) WriteZip(w io.Writer) error {
+	archive := zip.NewWriter(w)
+	for _, p := range s.Paths() {
+		header := &zip.FileHeader{Name: p, Method: zip.Deflate}
+		if page, ok := s.Pages[p]; ok {
+			header.Modified = page.ModTime
+		}
+		f, err := archive.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", p, err)
+		}
+		s.Render(f, p)
+	}
+	return archive.Close()
+}
+
+// indexBody lists every page, nested by folder.
+func (s *Site) indexBody() string {
+	var b strings.Builder
+	b.WriteString("<h1>" + html.EscapeString(s.Title) + "</h1>\n<ul class=\"index\">\n")
+	var open []string
+	for _, p := range s.order {
+		dirs := folders(p)
+		common := 0
+		for common < len(open) && common < len(dirs) && open[common] == dirs[common] {
+			common++
+		}
+		for ; len(open) > common; open = open[:len(open)-1] {
+			b.WriteString("</ul></li>\n")
+		}
+		for _, dir := range dirs[common:] {
+			b.WriteString("<li>" + html.EscapeString(path.Base(dir)) + "\n<ul>\n")
+			open = append(open, dir)
+		}
+		b.WriteString(s.pageLink(p))
+	}
+	for range open {
+		b.WriteString("</ul></li>\n")
+	}
+	b.WriteString("</ul>\n")
+	return b.String()
+}
+
+// maxSiblings caps the folder list of a page; the index lists them all.
+const maxSiblings = 50
+
+func (s *Site) siblings(page *Page) []string {
+	dir := path.Dir(page.Path)
+	var siblings []string
+	for _, p := range s.order {
+		if len(siblings) == maxSiblings {
+			break
+		}
+		if p != page.Path && path.Dir(p) == dir {
+			siblings = append(siblings, p)
+		}
+	}
+	return siblings
+}
+
+func (s *Site) pageLink(p string) string {
+	return `<li><a href="` + escapePath(p) + `">` + html.EscapeString(s.Pages[p].Title) + "</a></li>\n"
+}
+
+// writeLayout wraps body in the site's layout. Links inside it are
+// relative to the site root, which the base element points at, so pages
+// work at any depth, under any prefix and from disk.
+func (s *Site) writeLayout(w io.Writer, pagePath, title, body string) {
+	base := strings.Repeat("../", strings.Count(pagePath, "/"))
+	if base == "" {
+		base = "./"
+	}
+	fmt.Fprintf(w, layout, html.EscapeString(base), html.EscapeString(title), body)
+}
+
+const layout = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<base href="%s">
+<title>%s</title>
+<style>body{max-width:46rem;margin:2rem auto;padding:0 1rem;font:16px/1.6 system-ui,sans-serif}pre{overflow-x:auto;background:#f5f5f5;padding:.75rem}nav.crumbs{font-size:.9rem;color:#666}aside,nav.folder{border-top:1px solid #ddd;margin-top:2rem;font-size:.9rem}.wikilink-missing{color:#888}</style>
+</head>
+<body>
+%s</body>
+</html>
+`
+
+// pagePath is where a note's page is served: its path with .html for
+// its extension.
+func pagePath(notePath string) string {
+	return strings.TrimSuffix(notePath, path.Ext(notePath)) + ".html"
+}
+
+// folders lists the folders above p, outermost first.
+func folders(p string) []string {
+	var dirs []string
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+	return dirs
+}
+
+func noteTitle(note Note) string {
+	var title string
+	if strings.EqualFold(path.Ext(note.Path), ".org") {
+		title = markdown.OrgKeywords(note.Content)["title"]
+	} else {
+		fields, _ := markdown.Frontmatter(note.Content)
+		title = fields["title"]
+	}
+	if title == "" {
+		title = strings.TrimSuffix(path.Base(note.Path), path.Ext(note.Path))
+	}
+	return title
+}
+
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
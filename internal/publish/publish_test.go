@@ -0,0 +1,92 @@
+package publish
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelection_Selected(t *testing.T) {
+	sel := Selection{IncludeFolders: []string{"blog/", "docs/"}, ExcludeFolders: []string{"blog/drafts/"}}
+	tests := []struct {
+		path, content string
+		want          bool
+	}{
+		{"blog/post.md", "# Post", true},
+		{"docs/guide.org", "* Guide", true},
+		{"blog/drafts/idea.md", "# Idea", false},
+		{"journal/today.md", "# Today", false},
+		{"blog/photo.png", "", false},
+		{"blog/private.md", "---\npublish: false\n---\n# Private", false},
+		{"blog/private.org", "#+PUBLISH: nil\n* Private", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, sel.Selected(Note{Path: tt.path, Content: []byte(tt.content)}), tt.path)
+	}
+
+	flagged := Selection{RequireFlag: true}
+	assert.False(t, flagged.Selected(Note{Path: "a.md", Content: []byte("# A")}))
+	assert.True(t, flagged.Selected(Note{Path: "a.md", Content: []byte("---\npublish: true\n---\n# A")}))
+	assert.True(t, flagged.Selected(Note{Path: "a.org", Content: []byte("#+publish: t\n* A")}))
+}
+
+func render(t *testing.T, site *Site, p string) string {
+	t.Helper()
+	var b strings.Builder
+	require.True(t, site.Render(&b, p), p)
+	return b.String()
+}
+
+func TestBuild(t *testing.T) {
+	site := Build("Garden", []Note{
+		{Path: "index.md", Content: []byte("# Home\n\nStart at [[guides/setup]] or [[private]].")},
+		{Path: "guides/setup.md", Content: []byte("---\ntitle: Setting up\n---\nBack [[/index|home]], see [[faq]].")},
+		{Path: "guides/faq.org", Content: []byte("#+TITLE: FAQ\n* Questions")},
+		{Path: "guides/faq.md", Content: []byte("Clashes with faq.org")},
+	})
+
+	// index.md yields the generated index's path, faq.org the one faq.md took.
+	assert.Equal(t, []string{"index.html", "guides/faq.html", "guides/faq.org.html", "guides/setup.html", "index.md.html"}, site.Paths())
+
+	home := render(t, site, "index.md.html")
+	assert.Contains(t, home, `<base href="./">`)
+	assert.Contains(t, home, `<a href="guides/setup.html" class="wikilink">guides/setup</a>`)
+	assert.Contains(t, home, `<span class="wikilink-missing">private</span>`)
+
+	setup := render(t, site, "guides/setup.html")
+	assert.Contains(t, setup, `<base href="../">`)
+	assert.Contains(t, setup, "<title>Setting up · Garden</title>")
+	assert.Contains(t, setup, `<a href="index.md.html" class="wikilink">home</a>`)
+	assert.Contains(t, setup, `<a href="guides/faq.html" class="wikilink">faq</a>`, "Markdown wins over Org for an extensionless target")
+	assert.Contains(t, setup, "<h2>Linked from</h2>\n<ul>\n<li><a href=\"index.md.html\">index</a></li>")
+
+	index := render(t, site, "")
+	assert.Contains(t, index, "<li>guides\n<ul>\n<li><a href=\"guides/faq.html\">faq</a></li>\n<li><a href=\"guides/faq.org.html\">FAQ</a></li>")
+
+	var b strings.Builder
+	assert.False(t, site.Render(&b, "guides/missing.html"))
+}
+
+func TestSite_WriteZip(t *testing.T) {
+	site := Build("Garden", []Note{{Path: "a b/c#1.md", Content: []byte("# C")}})
+	var buf bytes.Buffer
+	require.NoError(t, site.WriteZip(&buf))
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"index.html", "a b/c#1.html"}, names)
+
+	f, err := archive.File[0].Open()
+	require.NoError(t, err)
+	index, _ := io.ReadAll(f)
+	assert.Contains(t, string(index), `<a href="a%20b/c%231.html">c#1</a>`)
+}
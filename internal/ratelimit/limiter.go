@@ -0,0 +1,163 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// Group is a class of API routes with budgets of its own, such as uploads,
+// which cost far more than reads.
+type Group string
+
+const (
+	// GroupRead holds GET and HEAD routes not assigned another group.
+	GroupRead Group = "read"
+	// GroupWrite holds the routes of other methods not assigned another
+	// group.
+	GroupWrite Group = "write"
+	// GroupUpload holds the routes that store file content.
+	GroupUpload Group = "upload"
+)
+
+// GroupOf returns the group of a route with method that is not assigned
+// one.
+func GroupOf(method string) Group {
+	if method == http.MethodGet || method == http.MethodHead {
+		return GroupRead
+	}
+	return GroupWrite
+}
+
+// Limits are the budgets per kind of client. A tier without an entry is
+// not limited.
+type Limits struct {
+	// Anonymous applies per client IP to requests without a valid token,
+	// such as sign-in and device polling.
+	Anonymous Limit
+	Tiers     map[domain.UserTier]Limit
+	// Groups are budgets per token for the routes of a group, taken from
+	// in addition to the tier's. A group or tier without an entry is
+	// limited by the tier's budget alone.
+	Groups map[Group]map[domain.UserTier]Limit
+}
+
+// Limiter applies Limits to HTTP requests and reports the bucket in
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset (seconds
+// until the bucket is full). When a request takes from several buckets the
+// headers report the one with the fewest requests remaining, or the one
+// that refused it. Refused requests get 429 with Retry-After.
+//
+// If the store fails, requests are let through: an unreachable Redis must
+// not take the API down with it.
+type Limiter struct {
+	store             Store
+	limits            Limits
+	trustForwardedFor bool
+	log               *logger.Logger
+}
+
+// NewLimiter builds a limiter. With trustForwardedFor the client IP is
+// taken from the last X-Forwarded-For entry, which must then be set by a
+// reverse proxy in front of the server.
+func NewLimiter(store Store, limits Limits, trustForwardedFor bool) *Limiter {
+	return &Limiter{
+		store:             store,
+		limits:            limits,
+		trustForwardedFor: trustForwardedFor,
+		log:               logger.New(),
+	}
+}
+
+// Middleware limits requests to /auth, and requests to /api that carry no
+// bearer token, per client IP. Requests to /api with a token are limited
+// by AllowToken once the token has been checked, or by AllowIP if it
+// turns out to be invalid. /auth routes never check tokens, so a token
+// does not exempt them.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limited := strings.HasPrefix(r.URL.Path, "/auth/") ||
+			(strings.HasPrefix(r.URL.Path, "/api/") && !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if limited && !l.AllowIP(w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AllowIP takes from the bucket of the request's client IP. When it
+// returns false the 429 response has been written.
+func (l *Limiter) AllowIP(w http.ResponseWriter, r *http.Request) bool {
+	return l.allow(w, r, "ip:"+l.ClientIP(r), l.limits.Anonymous)
+}
+
+// AllowToken takes from the bucket of an API token, sized by the tier of
+// its user. When it returns false the 429 response has been written.
+func (l *Limiter) AllowToken(w http.ResponseWriter, r *http.Request, tokenID uuid.UUID, tier domain.UserTier) bool {
+	return l.allow(w, r, "token:"+tokenID.String(), l.limits.Tiers[tier])
+}
+
+// AllowGroup takes from the bucket an API token has for the routes of
+// group, sized by the tier of its user. When it returns false the 429
+// response has been written.
+func (l *Limiter) AllowGroup(w http.ResponseWriter, r *http.Request, group Group, tokenID uuid.UUID, tier domain.UserTier) bool {
+	return l.allow(w, r, "token:"+tokenID.String()+":"+string(group), l.limits.Groups[group][tier])
+}
+
+func (l *Limiter) allow(w http.ResponseWriter, r *http.Request, key string, limit Limit) bool {
+	if limit.Unlimited() {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second)
+	defer cancel()
+	result, err := l.store.Take(ctx, key, limit)
+	if err != nil {
+		l.log.WithError(err).Warn("Rate limiter unavailable, allowing request", "key", key)
+		return true
+	}
+
+	h := w.Header()
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil || !result.Allowed || result.Remaining < remaining {
+		h.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	}
+	if result.Allowed {
+		return true
+	}
+
+	h.Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(result.RetryAfter))))
+	http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+	return false
+}
+
+// ClientIP returns the IP address r came from, as the limiter counts it.
+func (l *Limiter) ClientIP(r *http.Request) string {
+	if l.trustForwardedFor {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,191 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/google/uuid"
+)
+
+func TestMemoryStore_Take(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{PerMinute: 60, Burst: 2}
+
+	for i, want := range []int{1, 0} {
+		result, _ := store.Take(context.Background(), "k", limit)
+		if !result.Allowed || result.Remaining != want {
+			t.Fatalf("request %d: got %+v, want allowed with %d remaining", i, result, want)
+		}
+	}
+
+	result, _ := store.Take(context.Background(), "k", limit)
+	if result.Allowed {
+		t.Fatal("expected the third request to be refused")
+	}
+	if result.RetryAfter != time.Second || result.Reset != 2*time.Second {
+		t.Errorf("got retry after %v and reset %v, want 1s and 2s", result.RetryAfter, result.Reset)
+	}
+
+	now = now.Add(time.Second)
+	if result, _ := store.Take(context.Background(), "k", limit); !result.Allowed {
+		t.Error("expected a token after one second")
+	}
+	if result, _ := store.Take(context.Background(), "other", limit); !result.Allowed {
+		t.Error("expected buckets to be separate per key")
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit) (Result, error) {
+	return Result{}, errors.New("connection refused")
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	limits := Limits{
+		Anonymous: Limit{PerMinute: 1},
+		Tiers:     map[domain.UserTier]Limit{domain.TierFree: {PerMinute: 1}},
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	request := func(l *Limiter, path, authorization, forwardedFor string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		if forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		l.Middleware(ok).ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("refuses over the limit", func(t *testing.T) {
+		l := NewLimiter(NewMemoryStore(), limits, false)
+		w := request(l, "/auth/login", "", "")
+		if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "1" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+			t.Fatalf("first request: got %d with headers %v", w.Code, w.Header())
+		}
+		w = request(l, "/auth/login", "", "")
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+			t.Errorf("second request: got %d with Retry-After %q, want 429 and 60", w.Code, w.Header().Get("Retry-After"))
+		}
+	})
+
+	t.Run("leaves bearer requests to the auth middleware", func(t *testing.T) {
+		l := NewLimiter(NewMemoryStore(), limits, false)
+		for i := 0; i < 3; i++ {
+			if w := request(l, "/api/workspaces", "Bearer abc", ""); w.Code != http.StatusOK {
+				t.Fatalf("request %d: got %d", i, w.Code)
+			}
+		}
+		request(l, "/auth/login", "Bearer abc", "")
+		if w := request(l, "/auth/login", "Bearer abc", ""); w.Code != http.StatusTooManyRequests {
+			t.Errorf("got %d, want a token not to exempt /auth", w.Code)
+		}
+	})
+
+	t.Run("ignores other paths", func(t *testing.T) {
+		l := NewLimiter(NewMemoryStore(), limits, false)
+		for i := 0; i < 3; i++ {
+			if w := request(l, "/s/token", "", ""); w.Code != http.StatusOK {
+				t.Fatalf("request %d: got %d", i, w.Code)
+			}
+		}
+	})
+
+	t.Run("forwarded for", func(t *testing.T) {
+		trusting := NewLimiter(NewMemoryStore(), limits, true)
+		request(trusting, "/auth/login", "", "203.0.113.9, 198.51.100.1")
+		if w := request(trusting, "/auth/login", "", "203.0.113.9, 198.51.100.2"); w.Code != http.StatusOK {
+			t.Errorf("got %d, want the last hop to pick the bucket", w.Code)
+		}
+
+		distrusting := NewLimiter(NewMemoryStore(), limits, false)
+		request(distrusting, "/auth/login", "", "198.51.100.1")
+		if w := request(distrusting, "/auth/login", "", "198.51.100.2"); w.Code != http.StatusTooManyRequests {
+			t.Errorf("got %d, want the header ignored", w.Code)
+		}
+	})
+
+	t.Run("fails open", func(t *testing.T) {
+		l := NewLimiter(failingStore{}, limits, false)
+		for i := 0; i < 3; i++ {
+			if w := request(l, "/auth/login", "", ""); w.Code != http.StatusOK {
+				t.Fatalf("request %d: got %d", i, w.Code)
+			}
+		}
+	})
+}
+
+func TestLimiter_AllowToken(t *testing.T) {
+	l := NewLimiter(NewMemoryStore(), Limits{
+		Tiers: map[domain.UserTier]Limit{domain.TierFree: {PerMinute: 1}},
+	}, false)
+	r := httptest.NewRequest("GET", "/api/workspaces", nil)
+	token := uuid.New()
+
+	if !l.AllowToken(httptest.NewRecorder(), r, token, domain.TierFree) {
+		t.Fatal("expected the first request to be allowed")
+	}
+	w := httptest.NewRecorder()
+	if l.AllowToken(w, r, token, domain.TierFree) || w.Code != http.StatusTooManyRequests {
+		t.Errorf("got %d, want 429 once the tier's budget is spent", w.Code)
+	}
+	if !l.AllowToken(httptest.NewRecorder(), r, uuid.New(), domain.TierFree) {
+		t.Error("expected another token to have its own bucket")
+	}
+	for i := 0; i < 3; i++ {
+		if !l.AllowToken(httptest.NewRecorder(), r, token, domain.TierEnterprise) {
+			t.Fatal("expected a tier without a limit to be let through")
+		}
+	}
+}
+
+func TestLimiter_AllowGroup(t *testing.T) {
+	l := NewLimiter(NewMemoryStore(), Limits{
+		Tiers: map[domain.UserTier]Limit{domain.TierFree: {PerMinute: 10, Burst: 10}},
+		Groups: map[Group]map[domain.UserTier]Limit{
+			GroupUpload: {domain.TierFree: {PerMinute: 2, Burst: 2}},
+		},
+	}, false)
+	r := httptest.NewRequest("POST", "/api/files/upload", nil)
+	token := uuid.New()
+	request := func(group Group) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		if l.AllowToken(w, r, token, domain.TierFree) {
+			l.AllowGroup(w, r, group, token, domain.TierFree)
+		}
+		return w
+	}
+
+	w := request(GroupUpload)
+	if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "2" || w.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Fatalf("got %d with headers %v, want the group's budget reported", w.Code, w.Header())
+	}
+	request(GroupUpload)
+	if w := request(GroupUpload); w.Code != http.StatusTooManyRequests || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("got %d with headers %v, want 429 once the group's budget is spent", w.Code, w.Header())
+	}
+
+	w = request(GroupRead)
+	if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "10" || w.Header().Get("X-RateLimit-Remaining") != "6" {
+		t.Errorf("got %d with headers %v, want a group without a limit to take from the tier alone", w.Code, w.Header())
+	}
+}
+
+func TestGroupOf(t *testing.T) {
+	for method, want := range map[string]Group{"GET": GroupRead, "HEAD": GroupRead, "POST": GroupWrite, "DELETE": GroupWrite} {
+		if got := GroupOf(method); got != want {
+			t.Errorf("GroupOf(%s) = %s, want %s", method, got, want)
+		}
+	}
+}
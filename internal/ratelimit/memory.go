@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxMemoryBuckets bounds the memory store, since keys include client
+// IPs. Full buckets carry no state and are dropped first when the limit is
+// reached.
+const maxMemoryBuckets = 100000
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// MemoryStore keeps buckets in this process. Limits then apply per
+// server, not per deployment.
+type MemoryStore struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		if len(s.buckets) >= maxMemoryBuckets {
+			s.sweep(now)
+		}
+		b = &bucket{tokens: limit.capacity(), updated: now}
+		s.buckets[key] = b
+	} else {
+		b.tokens = refill(limit, b.tokens, b.updated, now)
+		b.updated = now
+	}
+	b.limit = limit
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return result(limit, b.tokens, allowed), nil
+}
+
+// sweep forgets buckets that have refilled completely; a new bucket would
+// start out the same.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if refill(b.limit, b.tokens, b.updated, now) >= b.limit.capacity() {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Package ratelimit throttles API clients with token buckets. Each bucket
+// holds up to Limit.Burst requests and refills at Limit.PerMinute; a
+// request takes one token or is refused until the next one arrives.
+//
+// Buckets live in a Store: in memory for a single server, or in Redis so
+// several servers share one budget per client.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is the budget of one bucket.
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+// Unlimited reports whether the limit lets everything through.
+func (l Limit) Unlimited() bool {
+	return l.PerMinute <= 0
+}
+
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.PerMinute)
+}
+
+// perSecond is the refill rate.
+func (l Limit) perSecond() float64 {
+	return float64(l.PerMinute) / 60
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next token, when refused.
+	RetryAfter time.Duration
+}
+
+// Store keeps buckets by key.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// result derives the Result from the tokens left in a bucket after a
+// request was allowed or refused.
+func result(limit Limit, tokens float64, allowed bool) Result {
+	rate := limit.perSecond()
+	r := Result{
+		Allowed:   allowed,
+		Limit:     int(limit.capacity()),
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((limit.capacity() - tokens) / rate),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// refill returns the tokens of a bucket last touched at updated.
+func refill(limit Limit, tokens float64, updated, now time.Time) float64 {
+	if elapsed := now.Sub(updated).Seconds(); elapsed > 0 {
+		tokens += elapsed * limit.perSecond()
+	}
+	return math.Min(tokens, limit.capacity())
+}
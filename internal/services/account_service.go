@@ -0,0 +1,118 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// AccountService exports everything an account holds.
+type AccountService struct {
+	queries    *db.Queries
+	workspaces *WorkspaceService
+	devices    *DeviceService
+	identities *IdentityService
+	shares     *ShareService
+	log        *logger.Logger
+}
+
+func NewAccountService(queries *db.Queries, workspaces *WorkspaceService, devices *DeviceService, identities *IdentityService, shares *ShareService) *AccountService {
+	return &AccountService{
+		queries:    queries,
+		workspaces: workspaces,
+		devices:    devices,
+		identities: identities,
+		shares:     shares,
+		log:        logger.New(),
+	}
+}
+
+// ExportAccount writes a zip archive of userID's data to w: account.json
+// with the profile, preferences, linked identities, devices, workspaces and share
+// links, and for each workspace the user owns a folder named by its ID
+// with workspace.json and the workspace's files.
+func (s *AccountService) ExportAccount(ctx context.Context, userID uuid.UUID, w io.Writer) error {
+	row, err := s.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	export := domain.AccountExport{ExportedAt: time.Now().UTC(), User: *toDomainUser(row)}
+	if export.Preferences, err = loadPreferences(ctx, s.queries, userID); err != nil {
+		return err
+	}
+	if export.Identities, err = s.identities.ListIdentities(ctx, userID); err != nil {
+		return err
+	}
+	if export.Devices, err = s.devices.ListDevices(ctx, userID, nil); err != nil {
+		return err
+	}
+	if export.Workspaces, err = s.workspaces.GetWorkspacesByUser(ctx, userID); err != nil {
+		return err
+	}
+	if export.ShareLinks, err = s.shares.ListShares(ctx, userID); err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	if err := writeJSONEntry(zw, "account.json", export); err != nil {
+		return err
+	}
+	for _, workspace := range export.Workspaces {
+		if workspace.Role != domain.RoleOwner {
+			continue
+		}
+		prefix := "workspaces/" + workspace.ID.String() + "/"
+		files, err := s.workspaces.writeFiles(ctx, zw, prefix+"files/", workspace.ID)
+		if err != nil {
+			return err
+		}
+		manifest := domain.WorkspaceExport{Workspace: workspace, Files: make([]domain.ExportedFile, len(files))}
+		for i, file := range files {
+			manifest.Files[i] = domain.ExportedFile{
+				Path:         file.FilePath,
+				ContentHash:  file.ContentHash,
+				SizeBytes:    file.SizeBytes,
+				MimeType:     pgconv.PgToString(file.MimeType),
+				LastModified: pgconv.PgToTime(file.LastModified),
+				UpdatedAt:    pgconv.PgToTime(file.UpdatedAt),
+			}
+		}
+		if err := writeJSONEntry(zw, prefix+"workspace.json", manifest); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish export: %w", err)
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Exported account", "workspaces", len(export.Workspaces))
+	return nil
+}
+
+func writeJSONEntry(zw *zip.Writer, name string, v interface{}) error {
+	entry, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to add %s to export: %w", name, err)
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s to export: %w", name, err)
+	}
+	return nil
+}
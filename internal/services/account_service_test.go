@@ -0,0 +1,118 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountService_Export_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	queries := testDB.Queries()
+	blobs := storage.NewPostgresBackend(queries)
+	service := NewAccountService(queries, NewWorkspaceService(queries, blobs), NewDeviceService(queries),
+		NewIdentityService(queries, testDB.Conn(), NewUserService(queries)), NewShareService(queries, blobs, NewPolicyService(queries)))
+	fileService := NewFileServiceForTesting(queries, testDB.Conn())
+	ctx := context.Background()
+
+	_, err := fileService.UploadFile(ctx, domain.FileUploadRequest{
+		WorkspaceID:  testData.FreeWorkspaceID,
+		FilePath:     "notes/todo.md",
+		Content:      []byte("- [ ] export"),
+		LastModified: time.Now(),
+	}, testData.FreeUserID)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, service.ExportAccount(ctx, testData.FreeUserID, &buf))
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	read := func(name string) []byte {
+		f, err := archive.Open(name)
+		require.NoError(t, err, name)
+		defer f.Close()
+		content, err := io.ReadAll(f)
+		require.NoError(t, err)
+		return content
+	}
+
+	var account domain.AccountExport
+	require.NoError(t, json.Unmarshal(read("account.json"), &account))
+	assert.Equal(t, testData.FreeUserID, account.User.ID)
+	require.Len(t, account.Workspaces, 1)
+
+	prefix := "workspaces/" + testData.FreeWorkspaceID.String() + "/"
+	var workspace domain.WorkspaceExport
+	require.NoError(t, json.Unmarshal(read(prefix+"workspace.json"), &workspace))
+	require.Len(t, workspace.Files, 1)
+	assert.Equal(t, "notes/todo.md", workspace.Files[0].Path)
+	assert.Equal(t, "- [ ] export", string(read(prefix+"files/notes/todo.md")))
+}
+
+func TestUserService_DeleteAccount_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewUserService(testDB.Queries())
+	ctx := context.Background()
+	user, err := service.GetUser(ctx, testData.FreeUserID)
+	require.NoError(t, err)
+
+	t.Run("email must match", func(t *testing.T) {
+		_, err := service.DeleteAccount(ctx, testData.FreeUserID, "someone@example.com")
+		assert.ErrorContains(t, err, "invalid confirmation")
+	})
+
+	t.Run("deletion waits for the grace period", func(t *testing.T) {
+		deletion, err := service.DeleteAccount(ctx, testData.FreeUserID, user.Email)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(AccountDeletionGracePeriod), deletion.DeleteAfter, time.Minute)
+
+		purged, err := service.PurgeDeletedAccounts(ctx, AccountPurgeBatchSize)
+		require.NoError(t, err)
+		assert.Zero(t, purged)
+
+		_, err = testDB.Queries().GetTokenByHash(ctx, auth.HashToken(testData.FreeUserToken))
+		assert.Error(t, err, "tokens are revoked at once")
+	})
+
+	t.Run("restore cancels the deletion", func(t *testing.T) {
+		require.NoError(t, service.RestoreAccount(ctx, testData.FreeUserID))
+		assert.ErrorContains(t, service.RestoreAccount(ctx, testData.FreeUserID), "not scheduled")
+	})
+
+	t.Run("purge deletes the account and its workspaces", func(t *testing.T) {
+		_, err := service.DeleteAccount(ctx, testData.FreeUserID, user.Email)
+		require.NoError(t, err)
+		_, err = testDB.Queries().ScheduleUserDeletion(ctx, db.ScheduleUserDeletionParams{
+			ID:          pgconv.UUIDToPg(testData.FreeUserID),
+			DeleteAfter: pgconv.TimeToPg(time.Now().Add(-time.Minute)),
+		})
+		require.NoError(t, err)
+
+		purged, err := service.PurgeDeletedAccounts(ctx, AccountPurgeBatchSize)
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+
+		_, err = service.GetUser(ctx, testData.FreeUserID)
+		assert.Error(t, err)
+		_, err = testDB.Queries().GetWorkspaceByID(ctx, pgconv.UUIDToPg(testData.FreeWorkspaceID))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const MaxAdminUserPageSize = 200
+
+// AdminService lets operators look after accounts: their tier, storage
+// limit, admin role and whether they may sign in.
+type AdminService struct {
+	queries *db.Queries
+	conn    *pgx.Conn
+	log     *logger.Logger
+}
+
+func NewAdminService(queries *db.Queries, conn *pgx.Conn) *AdminService {
+	return &AdminService{
+		queries: queries,
+		conn:    conn,
+		log:     logger.New(),
+	}
+}
+
+// ListUsers pages through users by email, starting after afterEmail.
+// emailPrefix, when set, narrows the list to matching addresses.
+func (s *AdminService) ListUsers(ctx context.Context, afterEmail, emailPrefix string, limit int) (*domain.AdminUserPage, error) {
+	if limit <= 0 || limit > MaxAdminUserPageSize {
+		limit = MaxAdminUserPageSize
+	}
+
+	rows, err := retryRead(ctx, "list_admin_users", func() ([]db.ListAdminUsersRow, error) {
+		return s.queries.ListAdminUsers(ctx, db.ListAdminUsersParams{
+			AfterEmail:  afterEmail,
+			EmailPrefix: strings.ToLower(strings.TrimSpace(emailPrefix)),
+			PageSize:    int32(limit),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	page := &domain.AdminUserPage{Users: make([]domain.AdminUser, len(rows))}
+	for i, row := range rows {
+		page.Users[i] = toDomainAdminUser(db.GetAdminUserRow(row))
+	}
+	if len(rows) == limit {
+		page.NextAfter = rows[len(rows)-1].Email
+	}
+	return page, nil
+}
+
+func (s *AdminService) GetUser(ctx context.Context, userID uuid.UUID) (*domain.AdminUser, error) {
+	row, err := retryRead(ctx, "get_admin_user", func() (db.GetAdminUserRow, error) {
+		return s.queries.GetAdminUser(ctx, pgconv.UUIDToPg(userID))
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	user := toDomainAdminUser(row)
+	return &user, nil
+}
+
+// UpdateUser applies update to userID on behalf of adminID. A new tier or
+// storage limit applies to the user's existing workspaces at once. Admins
+// cannot disable themselves or give up their own admin role, so there is
+// always someone left to undo a change.
+func (s *AdminService) UpdateUser(ctx context.Context, adminID, userID uuid.UUID, update domain.AdminUserUpdate) (*domain.AdminUser, error) {
+	if err := update.Validate(); err != nil {
+		return nil, err
+	}
+	if adminID == userID && ((update.Disabled != nil && *update.Disabled) || (update.IsAdmin != nil && !*update.IsAdmin)) {
+		return nil, fmt.Errorf("invalid update: you cannot disable or demote yourself")
+	}
+
+	params := db.UpdateUserAdminParams{ID: pgconv.UUIDToPg(userID)}
+	if update.Tier != nil {
+		params.Tier = db.NullUserTier{UserTier: db.UserTier(*update.Tier), Valid: true}
+	}
+	if update.IsAdmin != nil {
+		params.IsAdmin = pgtype.Bool{Bool: *update.IsAdmin, Valid: true}
+	}
+	if update.StorageLimitBytes != nil {
+		params.SetStorageLimit = true
+		params.StorageLimitBytes = pgtype.Int8{Int64: *update.StorageLimitBytes, Valid: *update.StorageLimitBytes > 0}
+	}
+	if update.Disabled != nil {
+		params.Disabled = pgtype.Bool{Bool: *update.Disabled, Valid: true}
+	}
+
+	err := inTx(ctx, s.conn, s.queries, "update_user_admin", func(qtx *db.Queries) error {
+		user, err := qtx.UpdateUserAdmin(ctx, params)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("user not found")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+		if update.Tier == nil && update.StorageLimitBytes == nil {
+			return nil
+		}
+		err = qtx.SetWorkspaceStorageLimits(ctx, db.SetWorkspaceStorageLimitsParams{
+			UserID:            user.ID,
+			StorageLimitBytes: workspaceStorageLimit(domain.UserTier(user.Tier), user.StorageLimitBytes),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update workspace storage limits: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.log.WithContext(ctx).WithUser(adminID.String(), "").Info("Updated user",
+		"user_id", userID,
+		"tier", user.Tier,
+		"storage_limit_bytes", user.StorageLimitBytes,
+		"disabled", user.DisabledAt != nil,
+		"is_admin", user.IsAdmin)
+	return user, nil
+}
+
+// workspaceStorageLimit is the limit of each workspace a user owns: their
+// own when an admin set one, otherwise their tier's.
+func workspaceStorageLimit(tier domain.UserTier, custom pgtype.Int8) int64 {
+	if custom.Valid {
+		return custom.Int64
+	}
+	return tier.GetStorageLimit()
+}
+
+func toDomainAdminUser(row db.GetAdminUserRow) domain.AdminUser {
+	return domain.AdminUser{
+		ID:                 pgconv.PgToUUID(row.ID),
+		Email:              row.Email,
+		Tier:               domain.UserTier(row.Tier),
+		IsAdmin:            row.IsAdmin,
+		StorageLimitBytes:  workspaceStorageLimit(domain.UserTier(row.Tier), row.StorageLimitBytes),
+		CustomStorageLimit: row.StorageLimitBytes.Valid,
+		DisabledAt:         pgconv.PgToTimePtr(row.DisabledAt),
+		WorkspaceCount:     row.WorkspaceCount,
+		FileCount:          row.FileCount,
+		StorageUsedBytes:   row.StorageUsedBytes,
+		CreatedAt:          pgconv.PgToTime(row.CreatedAt),
+	}
+}
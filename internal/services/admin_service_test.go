@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminService_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewAdminService(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	t.Run("list pages by email with usage", func(t *testing.T) {
+		page, err := service.ListUsers(ctx, "", "", 1)
+		require.NoError(t, err)
+		require.Len(t, page.Users, 1)
+		assert.Equal(t, testData.FreeUserID, page.Users[0].ID, "free-… sorts first")
+		assert.Equal(t, int64(1), page.Users[0].WorkspaceCount)
+		assert.Equal(t, domain.TierFree.GetStorageLimit(), page.Users[0].StorageLimitBytes)
+		assert.NotEmpty(t, page.NextAfter)
+
+		page, err = service.ListUsers(ctx, page.NextAfter, "", 1)
+		require.NoError(t, err)
+		require.Len(t, page.Users, 1)
+		assert.Equal(t, testData.PremiumUserID, page.Users[0].ID)
+
+		page, err = service.ListUsers(ctx, "", "PREMIUM-", 0)
+		require.NoError(t, err)
+		require.Len(t, page.Users, 1)
+		assert.Empty(t, page.NextAfter)
+	})
+
+	t.Run("tier and storage limit apply to existing workspaces", func(t *testing.T) {
+		premium := domain.TierPremium
+		user, err := service.UpdateUser(ctx, testData.PremiumUserID, testData.FreeUserID, domain.AdminUserUpdate{Tier: &premium})
+		require.NoError(t, err)
+		assert.Equal(t, domain.TierPremium, user.Tier)
+		assertWorkspaceLimit(t, testDB, testData, domain.TierPremium.GetStorageLimit())
+
+		limit := int64(5 << 20)
+		user, err = service.UpdateUser(ctx, testData.PremiumUserID, testData.FreeUserID, domain.AdminUserUpdate{StorageLimitBytes: &limit})
+		require.NoError(t, err)
+		assert.True(t, user.CustomStorageLimit)
+		assertWorkspaceLimit(t, testDB, testData, limit)
+
+		reset := int64(0)
+		user, err = service.UpdateUser(ctx, testData.PremiumUserID, testData.FreeUserID, domain.AdminUserUpdate{StorageLimitBytes: &reset})
+		require.NoError(t, err)
+		assert.False(t, user.CustomStorageLimit)
+		assertWorkspaceLimit(t, testDB, testData, domain.TierPremium.GetStorageLimit())
+	})
+
+	t.Run("disabled users cannot use their tokens", func(t *testing.T) {
+		disabled := true
+		user, err := service.UpdateUser(ctx, testData.PremiumUserID, testData.FreeUserID, domain.AdminUserUpdate{Disabled: &disabled})
+		require.NoError(t, err)
+		assert.NotNil(t, user.DisabledAt)
+		_, err = testDB.Queries().GetTokenByHash(ctx, auth.HashToken(testData.FreeUserToken))
+		assert.Error(t, err)
+
+		disabled = false
+		_, err = service.UpdateUser(ctx, testData.PremiumUserID, testData.FreeUserID, domain.AdminUserUpdate{Disabled: &disabled})
+		require.NoError(t, err)
+		_, err = testDB.Queries().GetTokenByHash(ctx, auth.HashToken(testData.FreeUserToken))
+		assert.NoError(t, err)
+	})
+
+	t.Run("admins cannot lock themselves out", func(t *testing.T) {
+		demote := false
+		_, err := service.UpdateUser(ctx, testData.PremiumUserID, testData.PremiumUserID, domain.AdminUserUpdate{IsAdmin: &demote})
+
+		assert.ErrorContains(t, err, "invalid update")
+	})
+
+	t.Run("empty update", func(t *testing.T) {
+		_, err := service.UpdateUser(ctx, testData.PremiumUserID, testData.FreeUserID, domain.AdminUserUpdate{})
+
+		assert.ErrorContains(t, err, "no fields to change")
+	})
+}
+
+func assertWorkspaceLimit(t *testing.T, testDB *testutil.IsolatedTestDB, testData *testutil.SimpleTestData, want int64) {
+	t.Helper()
+	workspace, err := testDB.Queries().GetWorkspaceByID(context.Background(), pgconv.UUIDToPg(testData.FreeWorkspaceID))
+	require.NoError(t, err)
+	assert.Equal(t, want, workspace.StorageLimitBytes)
+}
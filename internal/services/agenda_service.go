@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/duckonomy/noture/pkg/tasks"
+	"github.com/google/uuid"
+)
+
+// AgendaService answers agenda queries from the dated items indexed when
+// notes are written, so clients need not download the notes.
+type AgendaService struct {
+	queries *db.Queries
+	now     func() time.Time
+}
+
+func NewAgendaService(queries *db.Queries) *AgendaService {
+	return &AgendaService{
+		queries: queries,
+		now:     time.Now,
+	}
+}
+
+// GetAgenda returns the workspace's items from from to to, YYYY-MM-DD and
+// inclusive. from defaults to today in the user's timezone and to to a
+// week later. Any member may read the agenda.
+func (s *AgendaService) GetAgenda(ctx context.Context, workspaceID, userID uuid.UUID, from, to string) (*domain.Agenda, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	fromDay, toDay, err := s.agendaRange(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := retryRead(ctx, "list_agenda_entries", func() ([]db.ListAgendaEntriesRow, error) {
+		return s.queries.ListAgendaEntries(db.PreferReplica(ctx), db.ListAgendaEntriesParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			FromDay:     pgconv.DateToPg(fromDay),
+			ToDay:       pgconv.DateToPg(toDay),
+			MaxEntries:  domain.MaxAgendaItems + 1,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agenda: %w", err)
+	}
+
+	agenda := &domain.Agenda{
+		From: fromDay.Format(time.DateOnly),
+		To:   toDay.Format(time.DateOnly),
+		Days: []domain.AgendaDay{},
+	}
+	if len(rows) > domain.MaxAgendaItems {
+		rows = rows[:domain.MaxAgendaItems]
+		agenda.Truncated = true
+	}
+	for _, row := range rows {
+		date := pgconv.PgToDate(row.Day).Format(time.DateOnly)
+		if n := len(agenda.Days); n == 0 || agenda.Days[n-1].Date != date {
+			agenda.Days = append(agenda.Days, domain.AgendaDay{Date: date})
+		}
+		day := &agenda.Days[len(agenda.Days)-1]
+		day.Items = append(day.Items, domain.AgendaItem{
+			Kind:     row.Kind,
+			Title:    row.Title,
+			Keyword:  row.Keyword,
+			Done:     row.Done,
+			Time:     row.TimeOfDay,
+			FilePath: row.FilePath,
+			Line:     row.Line,
+		})
+	}
+	return agenda, nil
+}
+
+func (s *AgendaService) agendaRange(ctx context.Context, userID uuid.UUID, from, to string) (time.Time, time.Time, error) {
+	var fromDay, toDay time.Time
+	var err error
+	if from == "" {
+		user, err := s.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("failed to get user: %w", err)
+		}
+		y, m, d := s.now().In(toDomainUser(user).Location()).Date()
+		fromDay = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	} else if fromDay, err = time.Parse(time.DateOnly, from); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %q (use YYYY-MM-DD)", from)
+	}
+
+	if to == "" {
+		toDay = fromDay.AddDate(0, 0, domain.DefaultAgendaDays-1)
+	} else if toDay, err = time.Parse(time.DateOnly, to); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %q (use YYYY-MM-DD)", to)
+	}
+
+	if toDay.Before(fromDay) {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid range: to is before from")
+	}
+	if toDay.After(fromDay.AddDate(0, 0, domain.MaxAgendaDays-1)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid range: more than %d days", domain.MaxAgendaDays)
+	}
+	return fromDay, toDay, nil
+}
+
+// syncFileAgenda replaces the agenda entries of file with those now in
+// its content. Formats without dated items leave it with none.
+func syncFileAgenda(ctx context.Context, qtx *db.Queries, file db.File, format domain.FileFormat, content []byte) error {
+	var found []tasks.Entry
+	switch format {
+	case domain.FormatOrgMode:
+		found = tasks.ParseOrgAgenda(content)
+	case domain.FormatMarkdown:
+		found = tasks.ParseMarkdownAgenda(content)
+	}
+
+	if err := qtx.DeleteFileAgendaEntries(ctx, file.ID); err != nil {
+		return fmt.Errorf("failed to clear agenda entries: %w", err)
+	}
+	for _, entry := range found {
+		err := qtx.InsertAgendaEntry(ctx, db.InsertAgendaEntryParams{
+			FileID:      file.ID,
+			WorkspaceID: file.WorkspaceID,
+			Line:        int32(entry.Line),
+			Kind:        entry.Kind,
+			Day:         pgconv.DateToPg(entry.Date),
+			TimeOfDay:   entry.Time,
+			Title:       entry.Title,
+			Keyword:     entry.Keyword,
+			Done:        entry.Done,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store agenda entry: %w", err)
+		}
+	}
+	return nil
+}
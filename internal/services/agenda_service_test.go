@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgendaService_GetAgenda_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	files := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	service := NewAgendaService(testDB.Queries())
+	service.now = func() time.Time { return time.Date(2026, time.October, 16, 12, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	upload := func(path, content string) {
+		_, err := files.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      []byte(content),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+	upload("work.org", "* TODO Write report\n  SCHEDULED: <2026-10-18 Sun 09:00>\n* Review <2026-10-18 Sun>\n* Retro <2026-11-30 Mon>\n")
+	upload("home.md", "- [ ] Pay rent 📅 2026-10-17\n")
+
+	t.Run("defaults to a week from today", func(t *testing.T) {
+		agenda, err := service.GetAgenda(ctx, testData.FreeWorkspaceID, testData.PremiumUserID, "", "")
+		require.NoError(t, err)
+		assert.Equal(t, "2026-10-16", agenda.From)
+		assert.Equal(t, "2026-10-22", agenda.To)
+
+		require.Len(t, agenda.Days, 2)
+		assert.Equal(t, "2026-10-17", agenda.Days[0].Date)
+		assert.Equal(t, "Pay rent", agenda.Days[0].Items[0].Title)
+
+		day := agenda.Days[1]
+		require.Len(t, day.Items, 2)
+		assert.Equal(t, domain.AgendaItem{Kind: "timestamp", Title: "Review", FilePath: "work.org", Line: 3}, day.Items[0])
+		assert.Equal(t, domain.AgendaItem{Kind: "scheduled", Title: "Write report", Keyword: "TODO", Time: "09:00", FilePath: "work.org", Line: 2}, day.Items[1])
+	})
+
+	t.Run("rewriting a note replaces its entries", func(t *testing.T) {
+		upload("work.org", "* DONE Write report\n  SCHEDULED: <2026-10-18 Sun 09:00>\n")
+		agenda, err := service.GetAgenda(ctx, testData.FreeWorkspaceID, testData.FreeUserID, "2026-10-18", "2026-12-31")
+		require.NoError(t, err)
+		require.Len(t, agenda.Days, 1)
+		require.Len(t, agenda.Days[0].Items, 1)
+		assert.True(t, agenda.Days[0].Items[0].Done)
+	})
+
+	t.Run("invalid ranges", func(t *testing.T) {
+		_, err := service.GetAgenda(ctx, testData.FreeWorkspaceID, testData.FreeUserID, "2026-10-18", "2026-10-01")
+		assert.ErrorContains(t, err, "invalid range")
+		_, err = service.GetAgenda(ctx, testData.FreeWorkspaceID, testData.FreeUserID, "2026-01-01", "2027-06-01")
+		assert.ErrorContains(t, err, "invalid range")
+		_, err = service.GetAgenda(ctx, testData.FreeWorkspaceID, testData.FreeUserID, "10/18", "")
+		assert.ErrorContains(t, err, "invalid from")
+	})
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var ErrAuthSessionNotFound = errors.New("auth session not found or expired")
+
+// ErrDeviceSessionNotPending is returned when approving a device session
+// someone already approved, or one that expired, so an approval cannot be
+// taken over by signing in again with the same user code.
+var ErrDeviceSessionNotPending = errors.New("device session already approved or expired")
+
+// AuthSessionStore keeps OAuth state and device authorizations outside the
+// process so they survive restarts and are shared between instances.
+type AuthSessionStore interface {
+	CreateOAuthState(ctx context.Context, state, provider, deviceCode string, pkce domain.PKCE, ttl time.Duration) error
+	// CreateLinkState stores a state whose callback links the provider
+	// account to userID.
+	CreateLinkState(ctx context.Context, state, provider string, userID uuid.UUID, pkce domain.PKCE, ttl time.Duration) error
+	// ConsumeOAuthState returns and deletes the state, so each state can
+	// complete exactly one callback.
+	ConsumeOAuthState(ctx context.Context, state, provider string) (*domain.AuthSession, error)
+	CreateDeviceSession(ctx context.Context, deviceCode, userCode, deviceName string, ttl time.Duration) error
+	GetDeviceSession(ctx context.Context, deviceCode string) (*domain.AuthSession, error)
+	GetDeviceSessionByUserCode(ctx context.Context, userCode string) (*domain.AuthSession, error)
+	// ApproveDeviceSession signs the pending device session in as userID,
+	// failing with ErrDeviceSessionNotPending once it is approved.
+	ApproveDeviceSession(ctx context.Context, deviceCode string, userID uuid.UUID) error
+	ConsumeDeviceSession(ctx context.Context, deviceCode string) (*domain.AuthSession, error)
+	// CreateAuthCode stores the authorization code a PKCE sign-in of
+	// userID ended with, to be redeemed with the verifier of
+	// codeChallenge.
+	CreateAuthCode(ctx context.Context, code string, userID uuid.UUID, codeChallenge string, ttl time.Duration) error
+	// ConsumeAuthCode returns and deletes the code, so it is redeemed at
+	// most once.
+	ConsumeAuthCode(ctx context.Context, code string) (*domain.AuthSession, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type PostgresAuthSessionStore struct {
+	queries *db.Queries
+	log     *logger.Logger
+}
+
+func NewPostgresAuthSessionStore(queries *db.Queries) *PostgresAuthSessionStore {
+	return &PostgresAuthSessionStore{
+		queries: queries,
+		log:     logger.New(),
+	}
+}
+
+func (s *PostgresAuthSessionStore) CreateOAuthState(ctx context.Context, state, provider, deviceCode string, pkce domain.PKCE, ttl time.Duration) error {
+	_, err := s.queries.CreateAuthSession(ctx, db.CreateAuthSessionParams{
+		Kind:          domain.AuthSessionOAuthState,
+		SessionKey:    state,
+		Provider:      optionalText(provider),
+		DeviceCode:    optionalText(deviceCode),
+		CodeVerifier:  optionalText(pkce.CodeVerifier),
+		CodeChallenge: optionalText(pkce.CodeChallenge),
+		ExpiresAt:     pgconv.TimeToPg(time.Now().Add(ttl)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store OAuth state: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresAuthSessionStore) CreateLinkState(ctx context.Context, state, provider string, userID uuid.UUID, pkce domain.PKCE, ttl time.Duration) error {
+	_, err := s.queries.CreateAuthSession(ctx, db.CreateAuthSessionParams{
+		Kind:          domain.AuthSessionOAuthState,
+		SessionKey:    state,
+		Provider:      optionalText(provider),
+		UserID:        pgconv.UUIDToPg(userID),
+		CodeVerifier:  optionalText(pkce.CodeVerifier),
+		CodeChallenge: optionalText(pkce.CodeChallenge),
+		ExpiresAt:     pgconv.TimeToPg(time.Now().Add(ttl)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store OAuth state: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresAuthSessionStore) ConsumeOAuthState(ctx context.Context, state, provider string) (*domain.AuthSession, error) {
+	session, err := s.consume(ctx, domain.AuthSessionOAuthState, state)
+	if err != nil {
+		return nil, err
+	}
+	if session.Provider != provider {
+		return nil, ErrAuthSessionNotFound
+	}
+	return session, nil
+}
+
+func (s *PostgresAuthSessionStore) CreateDeviceSession(ctx context.Context, deviceCode, userCode, deviceName string, ttl time.Duration) error {
+	_, err := s.queries.CreateAuthSession(ctx, db.CreateAuthSessionParams{
+		Kind:       domain.AuthSessionDevice,
+		SessionKey: deviceCode,
+		UserCode:   pgconv.StringToPg(userCode),
+		DeviceName: optionalText(deviceName),
+		ExpiresAt:  pgconv.TimeToPg(time.Now().Add(ttl)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store device session: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresAuthSessionStore) GetDeviceSession(ctx context.Context, deviceCode string) (*domain.AuthSession, error) {
+	session, err := s.queries.GetAuthSession(ctx, db.GetAuthSessionParams{
+		Kind:       domain.AuthSessionDevice,
+		SessionKey: deviceCode,
+	})
+	if err != nil {
+		return nil, wrapAuthSessionError(err)
+	}
+	return toDomainAuthSession(session), nil
+}
+
+func (s *PostgresAuthSessionStore) GetDeviceSessionByUserCode(ctx context.Context, userCode string) (*domain.AuthSession, error) {
+	session, err := s.queries.GetDeviceSessionByUserCode(ctx, pgconv.StringToPg(userCode))
+	if err != nil {
+		return nil, wrapAuthSessionError(err)
+	}
+	return toDomainAuthSession(session), nil
+}
+
+func (s *PostgresAuthSessionStore) ApproveDeviceSession(ctx context.Context, deviceCode string, userID uuid.UUID) error {
+	rows, err := s.queries.ApproveDeviceSession(ctx, db.ApproveDeviceSessionParams{
+		SessionKey: deviceCode,
+		UserID:     pgconv.UUIDToPg(userID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to approve device session: %w", err)
+	}
+	if rows == 0 {
+		return ErrDeviceSessionNotPending
+	}
+	return nil
+}
+
+func (s *PostgresAuthSessionStore) ConsumeDeviceSession(ctx context.Context, deviceCode string) (*domain.AuthSession, error) {
+	return s.consume(ctx, domain.AuthSessionDevice, deviceCode)
+}
+
+func (s *PostgresAuthSessionStore) CreateAuthCode(ctx context.Context, code string, userID uuid.UUID, codeChallenge string, ttl time.Duration) error {
+	_, err := s.queries.CreateAuthSession(ctx, db.CreateAuthSessionParams{
+		Kind:          domain.AuthSessionCode,
+		SessionKey:    code,
+		UserID:        pgconv.UUIDToPg(userID),
+		CodeChallenge: optionalText(codeChallenge),
+		ExpiresAt:     pgconv.TimeToPg(time.Now().Add(ttl)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store authorization code: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresAuthSessionStore) ConsumeAuthCode(ctx context.Context, code string) (*domain.AuthSession, error) {
+	return s.consume(ctx, domain.AuthSessionCode, code)
+}
+
+func (s *PostgresAuthSessionStore) DeleteExpired(ctx context.Context) (int64, error) {
+	deleted, err := s.queries.DeleteExpiredAuthSessions(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired auth sessions: %w", err)
+	}
+
+	if deleted > 0 {
+		s.log.Info("Deleted expired auth sessions", "count", deleted)
+	}
+	return deleted, nil
+}
+
+func (s *PostgresAuthSessionStore) consume(ctx context.Context, kind, key string) (*domain.AuthSession, error) {
+	session, err := s.queries.ConsumeAuthSession(ctx, db.ConsumeAuthSessionParams{
+		Kind:       kind,
+		SessionKey: key,
+	})
+	if err != nil {
+		return nil, wrapAuthSessionError(err)
+	}
+	return toDomainAuthSession(session), nil
+}
+
+func wrapAuthSessionError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAuthSessionNotFound
+	}
+	return fmt.Errorf("failed to load auth session: %w", err)
+}
+
+// optionalText stores empty strings as NULL so unset codes never collide
+// on their unique indexes.
+func optionalText(s string) pgtype.Text {
+	if s == "" {
+		return pgtype.Text{}
+	}
+	return pgconv.StringToPg(s)
+}
+
+func toDomainAuthSession(s db.AuthSession) *domain.AuthSession {
+	return &domain.AuthSession{
+		ID:         pgconv.PgToUUID(s.ID),
+		Kind:       s.Kind,
+		SessionKey: s.SessionKey,
+		UserCode:   pgconv.PgToString(s.UserCode),
+		Provider:   pgconv.PgToString(s.Provider),
+		DeviceCode: pgconv.PgToString(s.DeviceCode),
+		DeviceName: pgconv.PgToString(s.DeviceName),
+		UserID:     pgconv.PgToUUIDPtr(s.UserID),
+		PKCE: domain.PKCE{
+			CodeVerifier:  pgconv.PgToString(s.CodeVerifier),
+			CodeChallenge: pgconv.PgToString(s.CodeChallenge),
+		},
+		ExpiresAt: pgconv.PgToTime(s.ExpiresAt),
+		CreatedAt: pgconv.PgToTime(s.CreatedAt),
+	}
+}
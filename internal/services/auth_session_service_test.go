@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresAuthSessionStore_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	store := NewPostgresAuthSessionStore(testDB.Queries())
+	ctx := context.Background()
+
+	t.Run("state can only be consumed once by its provider", func(t *testing.T) {
+		require.NoError(t, store.CreateOAuthState(ctx, "state-1", "google", "", domain.PKCE{}, time.Minute))
+
+		_, err := store.ConsumeOAuthState(ctx, "state-1", "github")
+		assert.ErrorIs(t, err, ErrAuthSessionNotFound)
+
+		require.NoError(t, store.CreateOAuthState(ctx, "state-2", "google", "", domain.PKCE{}, time.Minute))
+		session, err := store.ConsumeOAuthState(ctx, "state-2", "google")
+		require.NoError(t, err)
+		assert.Equal(t, "google", session.Provider)
+
+		_, err = store.ConsumeOAuthState(ctx, "state-2", "google")
+		assert.ErrorIs(t, err, ErrAuthSessionNotFound)
+	})
+
+	t.Run("device session is approved through a linked state", func(t *testing.T) {
+		require.NoError(t, store.CreateDeviceSession(ctx, "device-1", "ABCD-1234", "laptop", time.Minute))
+
+		device, err := store.GetDeviceSessionByUserCode(ctx, "ABCD-1234")
+		require.NoError(t, err)
+		assert.Nil(t, device.UserID)
+
+		require.NoError(t, store.CreateOAuthState(ctx, "state-3", "github", device.SessionKey, domain.PKCE{}, time.Minute))
+		state, err := store.ConsumeOAuthState(ctx, "state-3", "github")
+		require.NoError(t, err)
+		require.NoError(t, store.ApproveDeviceSession(ctx, state.DeviceCode, testData.FreeUserID))
+
+		err = store.ApproveDeviceSession(ctx, state.DeviceCode, testData.PremiumUserID)
+		assert.ErrorIs(t, err, ErrDeviceSessionNotPending, "an approved session keeps its user")
+
+		device, err = store.ConsumeDeviceSession(ctx, "device-1")
+		require.NoError(t, err)
+		require.NotNil(t, device.UserID)
+		assert.Equal(t, testData.FreeUserID, *device.UserID)
+		assert.Equal(t, "laptop", device.DeviceName)
+
+		_, err = store.GetDeviceSession(ctx, "device-1")
+		assert.ErrorIs(t, err, ErrAuthSessionNotFound)
+	})
+
+	t.Run("PKCE sign-ins keep their keys and end with a code", func(t *testing.T) {
+		pkce := domain.PKCE{CodeVerifier: "server-verifier", CodeChallenge: "client-challenge"}
+		require.NoError(t, store.CreateOAuthState(ctx, "state-4", "google", "", pkce, time.Minute))
+		state, err := store.ConsumeOAuthState(ctx, "state-4", "google")
+		require.NoError(t, err)
+		assert.Equal(t, pkce, state.PKCE)
+
+		require.NoError(t, store.CreateAuthCode(ctx, "code-1", testData.FreeUserID, state.CodeChallenge, time.Minute))
+		code, err := store.ConsumeAuthCode(ctx, "code-1")
+		require.NoError(t, err)
+		assert.Equal(t, "client-challenge", code.CodeChallenge)
+		assert.Equal(t, testData.FreeUserID, *code.UserID)
+
+		_, err = store.ConsumeAuthCode(ctx, "code-1")
+		assert.ErrorIs(t, err, ErrAuthSessionNotFound)
+	})
+
+	t.Run("expired sessions are ignored and purged", func(t *testing.T) {
+		require.NoError(t, store.CreateOAuthState(ctx, "state-old", "google", "", domain.PKCE{}, -time.Minute))
+
+		_, err := store.ConsumeOAuthState(ctx, "state-old", "google")
+		assert.ErrorIs(t, err, ErrAuthSessionNotFound)
+
+		deleted, err := store.DeleteExpired(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+	})
+}
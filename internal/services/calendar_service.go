@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/ical"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/duckonomy/noture/pkg/tasks"
+	"github.com/google/uuid"
+)
+
+const calendarProdID = "-//noture//calendar feed//EN"
+
+// CalendarService manages calendar feeds: secret URLs serving the agenda
+// index of a workspace as iCalendar, so the dates in notes show up in
+// calendar apps.
+type CalendarService struct {
+	queries *db.Queries
+	now     func() time.Time
+	log     *logger.Logger
+}
+
+func NewCalendarService(queries *db.Queries) *CalendarService {
+	return &CalendarService{
+		queries: queries,
+		now:     time.Now,
+		log:     logger.New(),
+	}
+}
+
+// CreateFeed creates a feed of the workspace for the user. Anyone who can
+// read the agenda may subscribe to it.
+func (s *CalendarService) CreateFeed(ctx context.Context, workspaceID, userID uuid.UUID) (*domain.CalendarFeed, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate feed token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+
+	row, err := s.queries.CreateCalendarFeed(ctx, db.CreateCalendarFeedParams{
+		TokenHash:   hashFeedToken(token),
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		UserID:      pgconv.UUIDToPg(userID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create calendar feed: %w", err)
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "").
+		Info("Created calendar feed", "feed_id", pgconv.PgToUUID(row.ID))
+
+	feed := toDomainCalendarFeed(row)
+	feed.Token = token
+	return &feed, nil
+}
+
+// ListFeeds returns the user's own feeds of the workspace.
+func (s *CalendarService) ListFeeds(ctx context.Context, workspaceID, userID uuid.UUID) ([]domain.CalendarFeed, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.queries.ListCalendarFeeds(ctx, db.ListCalendarFeedsParams{
+		UserID:      pgconv.UUIDToPg(userID),
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list calendar feeds: %w", err)
+	}
+
+	feeds := make([]domain.CalendarFeed, len(rows))
+	for i, row := range rows {
+		feeds[i] = toDomainCalendarFeed(row)
+	}
+	return feeds, nil
+}
+
+// DeleteFeed revokes one of the user's feeds. It needs no membership, so
+// former members can still clean up after themselves.
+func (s *CalendarService) DeleteFeed(ctx context.Context, workspaceID, feedID, userID uuid.UUID) error {
+	deleted, err := s.queries.DeleteCalendarFeed(ctx, db.DeleteCalendarFeedParams{
+		ID:          pgconv.UUIDToPg(feedID),
+		UserID:      pgconv.UUIDToPg(userID),
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete calendar feed: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("calendar feed not found")
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "").
+		Info("Deleted calendar feed", "feed_id", feedID)
+	return nil
+}
+
+// Feed resolves a feed token to the calendar it serves: the open items of
+// the workspace from CalendarFeedPastDays before to CalendarFeedFutureDays
+// after the owner's today. A feed whose owner has lost access to the
+// workspace behaves like an unknown one.
+func (s *CalendarService) Feed(ctx context.Context, token string) (*ical.Calendar, error) {
+	feed, err := s.queries.GetCalendarFeedByToken(ctx, hashFeedToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("calendar feed not found")
+	}
+	workspaceID := pgconv.PgToUUID(feed.WorkspaceID)
+	userID := pgconv.PgToUUID(feed.UserID)
+
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
+	if err != nil {
+		return nil, fmt.Errorf("calendar feed not found")
+	}
+
+	user, err := s.queries.GetUserByID(ctx, feed.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	y, m, d := s.now().In(toDomainUser(user).Location()).Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	rows, err := retryRead(ctx, "list_agenda_entries", func() ([]db.ListAgendaEntriesRow, error) {
+		return s.queries.ListAgendaEntries(db.PreferReplica(ctx), db.ListAgendaEntriesParams{
+			WorkspaceID: feed.WorkspaceID,
+			FromDay:     pgconv.DateToPg(today.AddDate(0, 0, -domain.CalendarFeedPastDays)),
+			ToDay:       pgconv.DateToPg(today.AddDate(0, 0, domain.CalendarFeedFutureDays)),
+			MaxEntries:  domain.MaxAgendaItems,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agenda: %w", err)
+	}
+
+	cal := &ical.Calendar{
+		ProdID: calendarProdID,
+		Name:   workspace.Name,
+		Events: []ical.Event{},
+	}
+	for _, row := range rows {
+		if row.Done {
+			continue
+		}
+		cal.Events = append(cal.Events, calendarEvent(workspaceID, row))
+	}
+
+	// Knowing when a feed was last polled helps owners spot stale ones;
+	// failing to record it is no reason to fail the poll.
+	if err := s.queries.TouchCalendarFeed(ctx, feed.ID); err != nil {
+		s.log.WithContext(ctx).Warn("Failed to record calendar feed fetch", "feed_id", pgconv.PgToUUID(feed.ID), "error", err)
+	}
+	return cal, nil
+}
+
+// calendarEvent turns an agenda entry into an event. The UID is derived
+// from where the item is, so calendar apps update rather than duplicate
+// events across polls.
+func calendarEvent(workspaceID uuid.UUID, row db.ListAgendaEntriesRow) ical.Event {
+	day := pgconv.PgToDate(row.Day)
+	key := fmt.Sprintf("%s|%s|%d|%s|%s|%s", workspaceID, row.FilePath, row.Line, row.Kind, day.Format(time.DateOnly), row.TimeOfDay)
+	event := ical.Event{
+		UID:         fmt.Sprintf("%x", sha256.Sum256([]byte(key)))[:32] + "@noture",
+		Summary:     row.Title,
+		Description: fmt.Sprintf("%s:%d", row.FilePath, row.Line),
+		Start:       day,
+		AllDay:      true,
+	}
+	if row.Kind == tasks.KindDeadline {
+		event.Summary = "Deadline: " + row.Title
+	}
+	if clock, err := time.Parse("15:04", row.TimeOfDay); err == nil {
+		event.Start = day.Add(time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute)
+		event.AllDay = false
+	}
+	return event
+}
+
+func toDomainCalendarFeed(row db.CalendarFeed) domain.CalendarFeed {
+	return domain.CalendarFeed{
+		ID:            pgconv.PgToUUID(row.ID),
+		WorkspaceID:   pgconv.PgToUUID(row.WorkspaceID),
+		CreatedAt:     pgconv.PgToTime(row.CreatedAt),
+		LastFetchedAt: pgconv.PgToTimePtr(row.LastFetchedAt),
+	}
+}
+
+func hashFeedToken(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalendarService_Feed_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	files := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	service := NewCalendarService(testDB.Queries())
+	service.now = func() time.Time { return time.Date(2026, time.October, 16, 12, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	_, err := files.UploadFile(ctx, domain.FileUploadRequest{
+		WorkspaceID:  testData.FreeWorkspaceID,
+		FilePath:     "work.org",
+		Content:      []byte("* TODO Ship it\n  DEADLINE: <2026-10-20 Tue>\n* DONE Old <2026-10-18 Sun>\n* Call <2026-10-19 Mon 09:30>\n* Ancient <2025-01-01 Wed>\n"),
+		LastModified: time.Now(),
+	}, testData.FreeUserID)
+	require.NoError(t, err)
+
+	feed, err := service.CreateFeed(ctx, testData.FreeWorkspaceID, testData.PremiumUserID)
+	require.NoError(t, err)
+	require.NotEmpty(t, feed.Token)
+
+	t.Run("serves open items in the window", func(t *testing.T) {
+		cal, err := service.Feed(ctx, feed.Token)
+		require.NoError(t, err)
+		require.Len(t, cal.Events, 2)
+
+		call := cal.Events[0]
+		assert.Equal(t, "Call", call.Summary)
+		assert.False(t, call.AllDay)
+		assert.Equal(t, time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC), call.Start)
+		assert.Equal(t, "work.org:4", call.Description)
+
+		deadline := cal.Events[1]
+		assert.Equal(t, "Deadline: Ship it", deadline.Summary)
+		assert.True(t, deadline.AllDay)
+
+		again, err := service.Feed(ctx, feed.Token)
+		require.NoError(t, err)
+		assert.Equal(t, call.UID, again.Events[0].UID)
+
+		feeds, err := service.ListFeeds(ctx, testData.FreeWorkspaceID, testData.PremiumUserID)
+		require.NoError(t, err)
+		require.Len(t, feeds, 1)
+		assert.NotNil(t, feeds[0].LastFetchedAt)
+	})
+
+	t.Run("unknown tokens are not found", func(t *testing.T) {
+		_, err := service.Feed(ctx, "nope")
+		assert.EqualError(t, err, "calendar feed not found")
+	})
+
+	t.Run("feeds stop working when the owner leaves", func(t *testing.T) {
+		_, err := testDB.Queries().RemoveWorkspaceMember(ctx, db.RemoveWorkspaceMemberParams{
+			WorkspaceID: pgconv.UUIDToPg(testData.FreeWorkspaceID),
+			UserID:      pgconv.UUIDToPg(testData.PremiumUserID),
+		})
+		require.NoError(t, err)
+		_, err = service.Feed(ctx, feed.Token)
+		assert.EqualError(t, err, "calendar feed not found")
+
+		require.NoError(t, service.DeleteFeed(ctx, testData.FreeWorkspaceID, feed.ID, testData.PremiumUserID))
+		assert.EqualError(t, service.DeleteFeed(ctx, testData.FreeWorkspaceID, feed.ID, testData.PremiumUserID), "calendar feed not found")
+	})
+}
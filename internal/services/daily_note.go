@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/notetemplate"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// defaultDailyNote is the content of daily notes made without a template.
+const defaultDailyNote = "# {{date}}\n"
+
+// GetDailyNoteSettings returns where the workspace keeps daily notes, or
+// the defaults if it has not said.
+func (s *NoteTemplateService) GetDailyNoteSettings(ctx context.Context, workspaceID, userID uuid.UUID) (*domain.DailyNoteSettings, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.dailyNoteSettings(ctx, workspaceID)
+}
+
+// UpdateDailyNoteSettings changes where new daily notes go. Notes already
+// written under the old pattern stay where they are.
+func (s *NoteTemplateService) UpdateDailyNoteSettings(ctx context.Context, workspaceID, userID uuid.UUID, settings domain.DailyNoteSettings) (*domain.DailyNoteSettings, error) {
+	settings.PathPattern = strings.TrimSpace(settings.PathPattern)
+	settings.Template = strings.TrimSpace(settings.Template)
+	if settings.PathPattern == "" {
+		settings.PathPattern = domain.DefaultDailyNotePattern
+	}
+	if err := validateDailyNotePattern(settings.PathPattern); err != nil {
+		return nil, err
+	}
+
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleEditor); err != nil {
+		return nil, err
+	}
+	if settings.Template != "" {
+		if _, err := s.templateContent(ctx, workspaceID, settings.Template); err != nil {
+			return nil, err
+		}
+	}
+
+	row, err := s.queries.UpsertDailyNoteSettings(ctx, db.UpsertDailyNoteSettingsParams{
+		WorkspaceID:  pgconv.UUIDToPg(workspaceID),
+		PathPattern:  settings.PathPattern,
+		TemplateName: settings.Template,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save daily note settings: %w", err)
+	}
+	return &domain.DailyNoteSettings{PathPattern: row.PathPattern, Template: row.TemplateName}, nil
+}
+
+// GetDailyNote returns the note for date, YYYY-MM-DD, or for today in the
+// user's timezone if date is empty. It fails with "daily note not found"
+// when there is none.
+func (s *NoteTemplateService) GetDailyNote(ctx context.Context, workspaceID, userID uuid.UUID, date string) (*domain.DailyNote, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return nil, err
+	}
+	settings, err := s.dailyNoteSettings(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	day, err := s.dailyNoteDay(ctx, userID, date)
+	if err != nil {
+		return nil, err
+	}
+
+	note := &domain.DailyNote{
+		Date:     day.Format(time.DateOnly),
+		FilePath: dailyNotePath(settings.PathPattern, day),
+	}
+	note.File, err = s.files.GetFileContent(ctx, workspaceID, note.FilePath, userID)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "file not found") {
+			return nil, fmt.Errorf("daily note not found")
+		}
+		return nil, err
+	}
+	return note, nil
+}
+
+// CreateDailyNote returns the note for the requested day, creating it from
+// the template first if it does not exist yet.
+func (s *NoteTemplateService) CreateDailyNote(ctx context.Context, workspaceID, userID uuid.UUID, req domain.CreateDailyNoteRequest) (*domain.DailyNote, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleEditor); err != nil {
+		return nil, err
+	}
+	settings, err := s.dailyNoteSettings(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	day, err := s.dailyNoteDay(ctx, userID, req.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	note := &domain.DailyNote{
+		Date:     day.Format(time.DateOnly),
+		FilePath: dailyNotePath(settings.PathPattern, day),
+	}
+	if note.File, err = s.files.GetFileContent(ctx, workspaceID, note.FilePath, userID); err == nil {
+		return note, nil
+	}
+
+	name := req.Template
+	if name == "" {
+		name = settings.Template
+	}
+	content := defaultDailyNote
+	if name != "" {
+		if content, err = s.templateContent(ctx, workspaceID, name); err != nil {
+			return nil, err
+		}
+	}
+	base := path.Base(note.FilePath)
+	rendered := notetemplate.Render(content, notetemplate.Variables(day, strings.TrimSuffix(base, path.Ext(base))))
+
+	result, err := s.createFile(ctx, workspaceID, note.FilePath, rendered, day, userID)
+	if err != nil {
+		if err.Error() == "file already exists" {
+			// Another client made today's note first.
+			note.File, err = s.files.GetFileContent(ctx, workspaceID, note.FilePath, userID)
+			if err != nil {
+				return nil, err
+			}
+			return note, nil
+		}
+		return nil, err
+	}
+
+	note.Created = true
+	note.File = &domain.FileWithContent{FileInfo: result.FileInfo, Content: []byte(rendered)}
+	return note, nil
+}
+
+func (s *NoteTemplateService) dailyNoteSettings(ctx context.Context, workspaceID uuid.UUID) (*domain.DailyNoteSettings, error) {
+	row, err := retryRead(ctx, "get_daily_note_settings", func() (db.DailyNoteSetting, error) {
+		return s.queries.GetDailyNoteSettings(ctx, pgconv.UUIDToPg(workspaceID))
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &domain.DailyNoteSettings{PathPattern: domain.DefaultDailyNotePattern}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily note settings: %w", err)
+	}
+	return &domain.DailyNoteSettings{PathPattern: row.PathPattern, Template: row.TemplateName}, nil
+}
+
+// dailyNoteDay is the current time in the user's timezone, moved to date
+// if one is given.
+func (s *NoteTemplateService) dailyNoteDay(ctx context.Context, userID uuid.UUID, date string) (time.Time, error) {
+	now, err := s.userNow(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if date == "" {
+		return now, nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, date, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date: %q (use YYYY-MM-DD)", date)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), now.Hour(), now.Minute(), now.Second(), 0, now.Location()), nil
+}
+
+func dailyNotePath(pattern string, day time.Time) string {
+	return notetemplate.Render(pattern, notetemplate.Variables(day, ""))
+}
+
+// validateDailyNotePattern makes sure every day gets one path of its own
+// and that the pattern has no placeholders that cannot be filled in.
+func validateDailyNotePattern(pattern string) error {
+	if len(pattern) > 255 {
+		return fmt.Errorf("invalid path pattern: longer than 255 characters")
+	}
+	day := time.Date(2001, time.February, 3, 0, 0, 0, 0, time.UTC)
+	rendered := dailyNotePath(pattern, day)
+	if strings.Contains(rendered, "{{") {
+		return fmt.Errorf("invalid path pattern: unknown placeholder")
+	}
+	if dailyNotePath(pattern, day.Add(13*time.Hour+7*time.Minute)) != rendered {
+		return fmt.Errorf("invalid path pattern: must not contain the time of day")
+	}
+	for _, other := range []time.Time{day.AddDate(0, 0, 1), day.AddDate(0, 1, 0), day.AddDate(1, 0, 0)} {
+		if dailyNotePath(pattern, other) == rendered {
+			return fmt.Errorf("invalid path pattern: must contain the date")
+		}
+	}
+	return nil
+}
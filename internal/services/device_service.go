@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+)
+
+type DeviceService struct {
+	queries *db.Queries
+	log     *logger.Logger
+}
+
+func NewDeviceService(queries *db.Queries) *DeviceService {
+	return &DeviceService{
+		queries: queries,
+		log:     logger.New(),
+	}
+}
+
+// RegisterDevice records a newly signed-in client. Tokens issued for it
+// are revoked together with it.
+func (s *DeviceService) RegisterDevice(ctx context.Context, userID uuid.UUID, name string) (*domain.Device, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = domain.DefaultDeviceName
+	}
+	if utf8.RuneCountInString(name) > domain.MaxDeviceNameLength {
+		return nil, fmt.Errorf("invalid device name: longer than %d characters", domain.MaxDeviceNameLength)
+	}
+
+	device, err := s.queries.CreateDevice(ctx, db.CreateDeviceParams{
+		UserID: pgconv.UUIDToPg(userID),
+		Name:   name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register device: %w", err)
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Registered device",
+		"device_id", pgconv.PgToUUID(device.ID),
+		"device_name", name)
+
+	return &domain.Device{
+		ID:        pgconv.PgToUUID(device.ID),
+		Name:      device.Name,
+		CreatedAt: pgconv.PgToTime(device.CreatedAt),
+	}, nil
+}
+
+// ListDevices returns the user's devices, newest first. current, when
+// set, is the device of the requesting token.
+func (s *DeviceService) ListDevices(ctx context.Context, userID uuid.UUID, current *uuid.UUID) ([]domain.Device, error) {
+	rows, err := retryRead(ctx, "list_devices", func() ([]db.ListDevicesRow, error) {
+		return s.queries.ListDevices(ctx, pgconv.UUIDToPg(userID))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	devices := make([]domain.Device, len(rows))
+	for i, row := range rows {
+		id := pgconv.PgToUUID(row.ID)
+		devices[i] = domain.Device{
+			ID:            id,
+			Name:          row.Name,
+			LastSeenAt:    pgconv.PgToTimePtr(row.LastSeenAt),
+			TokenCount:    row.TokenCount,
+			ClientName:    row.ClientName.String,
+			ClientVersion: row.ClientVersion.String,
+			Current:       current != nil && *current == id,
+			CreatedAt:     pgconv.PgToTime(row.CreatedAt),
+		}
+	}
+	return devices, nil
+}
+
+// RevokeDevice forgets a device and deletes every token issued to it.
+func (s *DeviceService) RevokeDevice(ctx context.Context, userID, deviceID uuid.UUID) error {
+	deleted, err := s.queries.DeleteDevice(ctx, db.DeleteDeviceParams{
+		ID:     pgconv.UUIDToPg(deviceID),
+		UserID: pgconv.UUIDToPg(userID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke device: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("device not found")
+	}
+
+	s.log.WithContext(ctx).LogAuthEvent("device_revoked", userID.String(), "device")
+	s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Revoked device", "device_id", deviceID)
+	return nil
+}
+
+// ListSessions returns the user's live tokens, most recently used first.
+// current is the requesting token.
+func (s *DeviceService) ListSessions(ctx context.Context, userID, current uuid.UUID) ([]domain.Session, error) {
+	rows, err := retryRead(ctx, "list_sessions", func() ([]db.ListSessionsRow, error) {
+		return s.queries.ListSessions(ctx, pgconv.UUIDToPg(userID))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	sessions := make([]domain.Session, len(rows))
+	for i, row := range rows {
+		id := pgconv.PgToUUID(row.ID)
+		sessions[i] = domain.Session{
+			ID:            id,
+			Name:          row.Name,
+			DeviceID:      pgconv.PgToUUIDPtr(row.DeviceID),
+			DeviceName:    row.DeviceName.String,
+			ClientName:    row.ClientName.String,
+			ClientVersion: row.ClientVersion.String,
+			IPAddress:     row.LastIp.String,
+			UserAgent:     row.UserAgent.String,
+			Scopes:        row.Scopes,
+			Current:       id == current,
+			LastUsedAt:    pgconv.PgToTimePtr(row.LastUsedAt),
+			ExpiresAt:     pgconv.PgToTimePtr(row.ExpiresAt),
+			CreatedAt:     pgconv.PgToTime(row.CreatedAt),
+		}
+	}
+	return sessions, nil
+}
+
+// RevokeSession deletes one of the user's tokens, signing out whoever
+// holds it. The token's device stays registered.
+func (s *DeviceService) RevokeSession(ctx context.Context, userID, tokenID uuid.UUID) error {
+	deleted, err := s.queries.DeleteAPIToken(ctx, db.DeleteAPITokenParams{
+		ID:     pgconv.UUIDToPg(tokenID),
+		UserID: pgconv.UUIDToPg(userID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("session not found")
+	}
+
+	s.log.WithContext(ctx).LogAuthEvent("session_revoked", userID.String(), "token")
+	s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Revoked session", "token_id", tokenID)
+	return nil
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceService_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewDeviceService(testDB.Queries())
+	ctx := context.Background()
+
+	laptop, err := service.RegisterDevice(ctx, testData.FreeUserID, "  Work laptop ")
+	require.NoError(t, err)
+	assert.Equal(t, "Work laptop", laptop.Name)
+
+	phone, err := service.RegisterDevice(ctx, testData.FreeUserID, "")
+	require.NoError(t, err)
+	assert.Equal(t, domain.DefaultDeviceName, phone.Name)
+
+	for _, device := range []*domain.Device{laptop, phone} {
+		_, err := testDB.Queries().CreateAPIToken(ctx, db.CreateAPITokenParams{
+			UserID:    pgconv.UUIDToPg(testData.FreeUserID),
+			TokenHash: "hash-" + device.ID.String(),
+			Name:      "Device Token",
+			DeviceID:  pgconv.UUIDToPg(device.ID),
+		})
+		require.NoError(t, err)
+	}
+
+	t.Run("list marks the current device", func(t *testing.T) {
+		devices, err := service.ListDevices(ctx, testData.FreeUserID, &laptop.ID)
+
+		require.NoError(t, err)
+		require.Len(t, devices, 2)
+		assert.Equal(t, phone.ID, devices[0].ID, "newest first")
+		assert.False(t, devices[0].Current)
+		assert.True(t, devices[1].Current)
+		assert.Equal(t, int64(1), devices[1].TokenCount)
+	})
+
+	t.Run("other users cannot revoke", func(t *testing.T) {
+		err := service.RevokeDevice(ctx, testData.PremiumUserID, laptop.ID)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "device not found")
+	})
+
+	t.Run("revoke deletes the device's tokens", func(t *testing.T) {
+		require.NoError(t, service.RevokeDevice(ctx, testData.FreeUserID, laptop.ID))
+
+		var tokens int
+		err := testDB.Conn().QueryRow(ctx, "SELECT COUNT(*) FROM api_tokens WHERE user_id = $1", testData.FreeUserID).Scan(&tokens)
+		require.NoError(t, err)
+		assert.Equal(t, 1, tokens)
+
+		devices, err := service.ListDevices(ctx, testData.FreeUserID, nil)
+		require.NoError(t, err)
+		require.Len(t, devices, 1)
+		assert.Equal(t, phone.ID, devices[0].ID)
+	})
+
+	t.Run("long names are rejected", func(t *testing.T) {
+		_, err := service.RegisterDevice(ctx, testData.FreeUserID, strings.Repeat("x", domain.MaxDeviceNameLength+1))
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid device name")
+	})
+}
+
+func TestDeviceService_Sessions_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewDeviceService(testDB.Queries())
+	ctx := context.Background()
+
+	laptop, err := service.RegisterDevice(ctx, testData.FreeUserID, "Work laptop")
+	require.NoError(t, err)
+	token, err := testDB.Queries().CreateAPIToken(ctx, db.CreateAPITokenParams{
+		UserID:    pgconv.UUIDToPg(testData.FreeUserID),
+		TokenHash: "hash-laptop",
+		Name:      "Device Token",
+		DeviceID:  pgconv.UUIDToPg(laptop.ID),
+	})
+	require.NoError(t, err)
+	require.NoError(t, testDB.Queries().UpdateTokenLastUsed(ctx, db.UpdateTokenLastUsedParams{
+		ID:        token.ID,
+		LastIp:    pgconv.StringToPg("203.0.113.7"),
+		UserAgent: pgconv.StringToPg("noture-cli/1.4.2"),
+	}))
+	tokenID := pgconv.PgToUUID(token.ID)
+
+	sessions, err := service.ListSessions(ctx, testData.FreeUserID, tokenID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2, "the fixture token and the laptop's")
+	assert.Equal(t, tokenID, sessions[0].ID, "most recently used first")
+	assert.True(t, sessions[0].Current)
+	assert.Equal(t, "Work laptop", sessions[0].DeviceName)
+	assert.Equal(t, "203.0.113.7", sessions[0].IPAddress)
+	assert.Equal(t, "noture-cli/1.4.2", sessions[0].UserAgent)
+	assert.False(t, sessions[1].Current)
+
+	err = service.RevokeSession(ctx, testData.PremiumUserID, tokenID)
+	assert.ErrorContains(t, err, "session not found")
+
+	require.NoError(t, service.RevokeSession(ctx, testData.FreeUserID, tokenID))
+	sessions, err = service.ListSessions(ctx, testData.FreeUserID, tokenID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.NotEqual(t, tokenID, sessions[0].ID)
+
+	devices, err := service.ListDevices(ctx, testData.FreeUserID, nil)
+	require.NoError(t, err)
+	assert.Len(t, devices, 1, "the device stays registered")
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/duckonomy/noture/pkg/email"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// EmailVerificationExpiry is how long an email verification link works.
+const EmailVerificationExpiry = 48 * time.Hour
+
+const emailVerificationPurpose = "email-verification"
+
+// EmailVerifier emails signed verification links and marks addresses
+// verified when a link comes back. Links name both the account and the
+// address, so a link sent before the address changed verifies nothing.
+type EmailVerifier struct {
+	queries *db.Queries
+	mailer  email.Sender
+	signer  *auth.Signer
+	baseURL string
+	log     *logger.Logger
+}
+
+func NewEmailVerifier(queries *db.Queries, mailer email.Sender, signer *auth.Signer, baseURL string) *EmailVerifier {
+	return &EmailVerifier{
+		queries: queries,
+		mailer:  mailer,
+		signer:  signer,
+		baseURL: baseURL,
+		log:     logger.New(),
+	}
+}
+
+// Send emails user a link verifying their current address.
+func (v *EmailVerifier) Send(ctx context.Context, user db.User) error {
+	payload := pgconv.PgToUUID(user.ID).String() + "|" + user.Email
+	token := v.signer.Sign(emailVerificationPurpose, payload, time.Now().Add(EmailVerificationExpiry))
+
+	msg := email.Message{
+		To:      user.Email,
+		Subject: "Verify your Noture email address",
+		Body: fmt.Sprintf("Open this link to verify the email address of your Noture account:\n\n"+
+			"%s/auth/verify-email?token=%s\n\n"+
+			"The link expires in two days. Until you verify your address you cannot share or publish notes. "+
+			"If you did not sign up for Noture, you can ignore this email.\n",
+			v.baseURL, url.QueryEscape(token)),
+	}
+	if err := v.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+
+	v.log.WithContext(ctx).LogAuthEvent("email_verification_sent", pgconv.PgToUUID(user.ID).String(), "email")
+	return nil
+}
+
+// Verify marks the address in a link from Send verified and returns its
+// user. Following a link again is not an error.
+func (v *EmailVerifier) Verify(ctx context.Context, token string) (uuid.UUID, error) {
+	payload, err := v.signer.Verify(emailVerificationPurpose, token, time.Now())
+	if errors.Is(err, auth.ErrExpiredSignature) {
+		return uuid.Nil, fmt.Errorf("invalid verification link: expired")
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid verification link")
+	}
+	id, address, ok := strings.Cut(payload, "|")
+	userID, err := uuid.Parse(id)
+	if !ok || err != nil {
+		return uuid.Nil, fmt.Errorf("invalid verification link")
+	}
+
+	rows, err := v.queries.MarkEmailVerified(ctx, db.MarkEmailVerifiedParams{
+		ID:    pgconv.UUIDToPg(userID),
+		Email: address,
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to verify email: %w", err)
+	}
+	if rows == 0 {
+		// Either already verified, or the address has changed since.
+		user, err := v.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && user.Email != address) {
+			return uuid.Nil, fmt.Errorf("invalid verification link: address changed")
+		}
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		return userID, nil
+	}
+
+	v.log.WithContext(ctx).LogAuthEvent("email_verified", userID.String(), "email")
+	return userID, nil
+}
+
+// requireVerifiedEmail refuses actions that reach other people, such as
+// sharing and publishing, until the user has verified their address.
+func requireVerifiedEmail(ctx context.Context, queries *db.Queries, userID uuid.UUID) error {
+	user, err := queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.EmailVerifiedAt.Valid {
+		return fmt.Errorf("access denied: email not verified")
+	}
+	return nil
+}
+
+// WithEmailVerifier makes Register email new accounts a verification
+// link. Without it they are left unverified until a link is resent.
+func (s *UserService) WithEmailVerifier(verifier *EmailVerifier) *UserService {
+	s.verifier = verifier
+	return s
+}
+
+// sendVerification emails a new account its verification link. Failing
+// to send does not fail the sign-up, since the link can be resent.
+func (s *UserService) sendVerification(ctx context.Context, user db.User) {
+	if s.verifier == nil {
+		return
+	}
+	if err := s.verifier.Send(ctx, user); err != nil {
+		s.log.WithContext(ctx).WithError(err).Warn("Failed to send verification email")
+	}
+}
+
+// ResendVerification emails userID a new verification link.
+func (s *UserService) ResendVerification(ctx context.Context, userID uuid.UUID) error {
+	if s.verifier == nil {
+		return fmt.Errorf("email verification not configured")
+	}
+	user, err := s.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+	if user.EmailVerifiedAt.Valid {
+		return fmt.Errorf("invalid request: email already verified")
+	}
+	return s.verifier.Send(ctx, user)
+}
+
+// VerifyEmail marks an address verified with a link from the verifier.
+func (s *UserService) VerifyEmail(ctx context.Context, token string) error {
+	if s.verifier == nil {
+		return fmt.Errorf("email verification not configured")
+	}
+	_, err := s.verifier.Verify(ctx, token)
+	return err
+}
@@ -0,0 +1,534 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/netguard"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/duckonomy/noture/pkg/webhook"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// MaxEventPageSize caps one page of the event stream and one webhook
+	// delivery.
+	MaxEventPageSize = 100
+	// WebhookDeliveryBatchSize is how many webhooks one delivery run
+	// serves.
+	WebhookDeliveryBatchSize = 50
+	// ReplayPageSize is how many rows a replay reads from each table at a
+	// time.
+	ReplayPageSize = 500
+	// ReminderBatchSize is how many due tasks one reminder run claims.
+	ReminderBatchSize = 500
+	// maxWebhookBackoff caps the delay between attempts to a failing
+	// webhook.
+	maxWebhookBackoff = time.Hour
+)
+
+// WebhookDelivery is the JSON body posted to a workspace webhook. ID is
+// stable across retries of the same batch, so receivers can drop
+// duplicates.
+type WebhookDelivery struct {
+	ID        string                  `json:"id"`
+	WebhookID uuid.UUID               `json:"webhook_id"`
+	Events    []domain.WorkspaceEvent `json:"events"`
+}
+
+// EventService reads the workspace event outbox and fans it out to
+// webhooks. Events are written by the services that make the changes, in
+// the same transaction.
+type EventService struct {
+	queries   *db.Queries
+	conn      *pgx.Conn
+	client    *http.Client
+	checkHost func(ctx context.Context, host string) error
+	log       *logger.Logger
+	now       func() time.Time
+}
+
+// NewEventService delivers webhooks only to public addresses, so a
+// webhook cannot make the server reach its own network. Redirects are not
+// followed.
+func NewEventService(queries *db.Queries, conn *pgx.Conn) *EventService {
+	return &EventService{
+		queries: queries,
+		conn:    conn,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext:         netguard.Dialer(5 * time.Second).DialContext,
+				TLSHandshakeTimeout: 5 * time.Second,
+				MaxIdleConns:        10,
+				IdleConnTimeout:     30 * time.Second,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		checkHost: netguard.CheckHost,
+		log:       logger.New(),
+		now:       time.Now,
+	}
+}
+
+// ListEvents returns the workspace's events after afterID, oldest first.
+// types filters by event type and accepts "<category>.*" wildcards.
+func (s *EventService) ListEvents(ctx context.Context, workspaceID, userID uuid.UUID, afterID int64, types []string, limit int) (*domain.EventPage, error) {
+	if afterID < 0 {
+		return nil, fmt.Errorf("invalid after: must not be negative")
+	}
+	patterns := make([]string, 0, len(types))
+	for _, t := range types {
+		if !domain.ValidEventPattern(t) {
+			return nil, fmt.Errorf("invalid event type %q", t)
+		}
+		patterns = append(patterns, strings.Replace(t, ".*", ".%", 1))
+	}
+	if limit <= 0 || limit > MaxEventPageSize {
+		limit = MaxEventPageSize
+	}
+
+	_, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := retryRead(ctx, "list_workspace_events", func() ([]db.WorkspaceEvent, error) {
+		return s.queries.ListWorkspaceEvents(ctx, db.ListWorkspaceEventsParams{
+			WorkspaceID:  pgconv.UUIDToPg(workspaceID),
+			AfterID:      afterID,
+			TypePatterns: patterns,
+			PageSize:     int32(limit),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	page := &domain.EventPage{Events: make([]domain.WorkspaceEvent, len(rows)), LastID: afterID}
+	for i, row := range rows {
+		page.Events[i] = toDomainEvent(row)
+		page.LastID = row.ID
+	}
+	return page, nil
+}
+
+// ReplayEvents passes emit every event the workspace recorded in [from,
+// to), merged by time with the policy audit entries of that window, so
+// auditors see what was blocked next to what changed. Outbox entries come
+// in ID order. Only owners on the enterprise tier may replay; nothing is
+// emitted if they may not.
+func (s *EventService) ReplayEvents(ctx context.Context, workspaceID, userID uuid.UUID, tier domain.UserTier, from, to time.Time, emit func(domain.ReplayedEvent) error) error {
+	if !from.Before(to) {
+		return fmt.Errorf("invalid window: from must be before to")
+	}
+	if to.Sub(from) > domain.MaxReplayWindow {
+		return fmt.Errorf("invalid window: at most %d days", int(domain.MaxReplayWindow.Hours()/24))
+	}
+	if tier != domain.TierEnterprise {
+		return fmt.Errorf("access denied: event replay requires the enterprise tier")
+	}
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner)
+	if err != nil {
+		return err
+	}
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), workspace.Name).Info("Replaying events",
+		"from", from,
+		"to", to)
+
+	outbox := &replaySource{load: func(afterID int64) ([]domain.ReplayedEvent, error) {
+		rows, err := s.queries.ListWorkspaceEventsBetween(ctx, db.ListWorkspaceEventsBetweenParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			FromTime:    pgconv.TimeToPg(from),
+			ToTime:      pgconv.TimeToPg(to),
+			AfterID:     afterID,
+			PageSize:    ReplayPageSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load events: %w", err)
+		}
+		events := make([]domain.ReplayedEvent, len(rows))
+		for i, row := range rows {
+			events[i] = domain.ReplayedEvent{Source: domain.ReplaySourceOutbox, WorkspaceEvent: toDomainEvent(row)}
+		}
+		return events, nil
+	}}
+	audit := &replaySource{load: func(afterID int64) ([]domain.ReplayedEvent, error) {
+		rows, err := s.queries.ListWorkspaceViolationsBetween(ctx, db.ListWorkspaceViolationsBetweenParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			FromTime:    pgconv.TimeToPg(from),
+			ToTime:      pgconv.TimeToPg(to),
+			AfterID:     afterID,
+			PageSize:    ReplayPageSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load policy violations: %w", err)
+		}
+		events := make([]domain.ReplayedEvent, len(rows))
+		for i, row := range rows {
+			data, err := json.Marshal(domain.PolicyViolationEventData{
+				RuleID:     pgconv.PgToUUIDPtr(row.RuleID),
+				RuleName:   row.RuleName,
+				Action:     row.ActionType,
+				Blocked:    row.Blocked,
+				MatchCount: row.MatchCount,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode policy violation: %w", err)
+			}
+			events[i] = domain.ReplayedEvent{
+				Source: domain.ReplaySourceAudit,
+				WorkspaceEvent: domain.WorkspaceEvent{
+					ID:          row.ID,
+					WorkspaceID: workspaceID,
+					Type:        domain.EventPolicyViolation,
+					FilePath:    row.FilePath,
+					ActorID:     pgconv.PgToUUIDPtr(row.UserID),
+					Data:        data,
+					CreatedAt:   pgconv.PgToTime(row.CreatedAt),
+				},
+			}
+		}
+		return events, nil
+	}}
+
+	for {
+		nextEvent, err := outbox.peek()
+		if err != nil {
+			return err
+		}
+		nextAudit, err := audit.peek()
+		if err != nil {
+			return err
+		}
+
+		source := outbox
+		switch {
+		case nextEvent == nil && nextAudit == nil:
+			return nil
+		case nextEvent == nil, nextAudit != nil && nextAudit.CreatedAt.Before(nextEvent.CreatedAt):
+			source = audit
+		}
+		if err := emit(source.pop()); err != nil {
+			return err
+		}
+	}
+}
+
+// replaySource reads one table of a replay a page at a time.
+type replaySource struct {
+	load    func(afterID int64) ([]domain.ReplayedEvent, error)
+	page    []domain.ReplayedEvent
+	afterID int64
+	done    bool
+}
+
+// peek returns the next entry without consuming it, or nil at the end.
+func (r *replaySource) peek() (*domain.ReplayedEvent, error) {
+	if len(r.page) == 0 && !r.done {
+		page, err := r.load(r.afterID)
+		if err != nil {
+			return nil, err
+		}
+		r.page, r.done = page, len(page) < ReplayPageSize
+		if len(page) > 0 {
+			r.afterID = page[len(page)-1].ID
+		}
+	}
+	if len(r.page) == 0 {
+		return nil, nil
+	}
+	return &r.page[0], nil
+}
+
+func (r *replaySource) pop() domain.ReplayedEvent {
+	next := r.page[0]
+	r.page = r.page[1:]
+	return next
+}
+
+// CreateWebhook subscribes url to the workspace's events from now on. The
+// returned webhook carries the signing secret; it is not shown again. URLs
+// whose host resolves to a non-public address are refused.
+func (s *EventService) CreateWebhook(ctx context.Context, workspaceID, userID uuid.UUID, req domain.CreateWebhookRequest) (*domain.WorkspaceWebhook, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(req.URL)
+	if err := s.checkHost(ctx, u.Hostname()); err != nil {
+		return nil, fmt.Errorf("invalid webhook url: %w", err)
+	}
+
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	eventTypes := req.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	row, err := s.queries.CreateWorkspaceWebhook(ctx, db.CreateWorkspaceWebhookParams{
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		Url:         req.URL,
+		Secret:      hex.EncodeToString(secretBytes),
+		EventTypes:  eventTypes,
+		PathPrefix:  req.PathPrefix,
+		CreatedBy:   pgconv.UUIDToPg(userID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	hook := toDomainWebhook(row)
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), workspace.Name).Info("Created webhook",
+		"webhook_id", hook.ID,
+		"event_types", hook.EventTypes)
+
+	hook.Secret = row.Secret
+	return &hook, nil
+}
+
+// ListWebhooks returns the workspace's webhooks without their secrets.
+func (s *EventService) ListWebhooks(ctx context.Context, workspaceID, userID uuid.UUID) ([]domain.WorkspaceWebhook, error) {
+	_, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := retryRead(ctx, "list_workspace_webhooks", func() ([]db.WorkspaceWebhook, error) {
+		return s.queries.ListWorkspaceWebhooks(ctx, pgconv.UUIDToPg(workspaceID))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	hooks := make([]domain.WorkspaceWebhook, len(rows))
+	for i, row := range rows {
+		hooks[i] = toDomainWebhook(row)
+	}
+	return hooks, nil
+}
+
+func (s *EventService) DeleteWebhook(ctx context.Context, workspaceID, userID, webhookID uuid.UUID) error {
+	_, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.queries.DeleteWorkspaceWebhook(ctx, db.DeleteWorkspaceWebhookParams{
+		ID:          pgconv.UUIDToPg(webhookID),
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+	return nil
+}
+
+// DeliverWebhooks posts the next batch of events to every webhook that has
+// some waiting. Events a webhook filters out are skipped without a
+// request. A failed delivery is retried with exponential backoff, so
+// events reach each webhook at least once and in order. It returns the
+// number of batches delivered.
+func (s *EventService) DeliverWebhooks(ctx context.Context) (int, error) {
+	hooks, err := s.queries.ListPendingWebhooks(ctx, WebhookDeliveryBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pending webhooks: %w", err)
+	}
+
+	delivered := 0
+	for _, row := range hooks {
+		events, err := s.queries.ListWorkspaceEvents(ctx, db.ListWorkspaceEventsParams{
+			WorkspaceID:  row.WorkspaceID,
+			AfterID:      row.LastEventID,
+			TypePatterns: []string{},
+			PageSize:     MaxEventPageSize,
+		})
+		if err != nil {
+			return delivered, fmt.Errorf("failed to load events: %w", err)
+		}
+		if len(events) == 0 {
+			continue
+		}
+
+		hook := toDomainWebhook(row)
+		lastID := events[len(events)-1].ID
+		batch := WebhookDelivery{
+			ID:        fmt.Sprintf("%s-%d", hook.ID, lastID),
+			WebhookID: hook.ID,
+		}
+		for _, event := range events {
+			if e := toDomainEvent(event); hook.MatchesEvent(e) {
+				batch.Events = append(batch.Events, e)
+			}
+		}
+
+		if len(batch.Events) > 0 {
+			if err := s.post(ctx, row.Url, row.Secret, batch); err != nil {
+				backoff := min(time.Minute<<min(row.FailureCount, 10), maxWebhookBackoff)
+				s.log.Warn("Webhook delivery failed",
+					"webhook_id", hook.ID,
+					"workspace_id", hook.WorkspaceID,
+					"failures", row.FailureCount+1,
+					"error", err)
+				err = s.queries.RecordWebhookFailure(ctx, db.RecordWebhookFailureParams{
+					ID:            row.ID,
+					LastError:     optionalText(deliveryError(err)),
+					NextAttemptAt: pgconv.TimeToPg(s.now().Add(backoff)),
+				})
+				if err != nil {
+					return delivered, fmt.Errorf("failed to record webhook failure: %w", err)
+				}
+				continue
+			}
+			delivered++
+		}
+
+		err = s.queries.AdvanceWebhook(ctx, db.AdvanceWebhookParams{ID: row.ID, LastEventID: lastID})
+		if err != nil {
+			return delivered, fmt.Errorf("failed to advance webhook: %w", err)
+		}
+	}
+	return delivered, nil
+}
+
+func (s *EventService) post(ctx context.Context, url, secret string, batch WebhookDelivery) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Noture-Delivery", batch.ID)
+	req.Header.Set(webhook.DefaultHeader, webhook.NewVerifier("workspace", secret).Sign(body, s.now()))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return &webhookStatusError{code: resp.StatusCode}
+	}
+	return nil
+}
+
+type webhookStatusError struct {
+	code int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook returned status %d", e.code)
+}
+
+// deliveryError describes a failed delivery to the webhook's owner. The
+// status and network error are kept to their class: in full they would
+// tell which ports and paths of a host answer, making webhooks a scanner.
+func deliveryError(err error) string {
+	var statusErr *webhookStatusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("webhook returned a %dxx status", statusErr.code/100)
+	case errors.Is(err, netguard.ErrNonPublic):
+		return "host resolves to a non-public address"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "webhook timed out"
+	default:
+		return "webhook could not be reached"
+	}
+}
+
+// RemindDueTasks records a reminder.due event for every open task whose
+// deadline is within domain.ReminderLeadTime. Each task is reminded once
+// per deadline. It returns the number of reminders recorded.
+func (s *EventService) RemindDueTasks(ctx context.Context) (int, error) {
+	reminded := 0
+	err := inTx(ctx, s.conn, s.queries, "remind_due_tasks", func(qtx *db.Queries) error {
+		reminded = 0
+		due, err := qtx.ClaimDueTasks(ctx, db.ClaimDueTasksParams{
+			DueBefore: pgconv.TimeToPg(s.now().Add(domain.ReminderLeadTime)),
+			BatchSize: ReminderBatchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to claim due tasks: %w", err)
+		}
+		for _, task := range due {
+			err := recordEvent(ctx, qtx, pgconv.PgToUUID(task.WorkspaceID), domain.EventReminderDue, task.FilePath, nil, domain.TaskEventData{
+				TaskKey:  task.TaskKey,
+				Title:    task.Title,
+				Deadline: pgconv.PgToTimePtr(task.Deadline),
+			})
+			if err != nil {
+				return err
+			}
+			reminded++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if reminded > 0 {
+		s.log.Info("Recorded task reminders", "count", reminded)
+	}
+	return reminded, nil
+}
+
+func toDomainEvent(row db.WorkspaceEvent) domain.WorkspaceEvent {
+	return domain.WorkspaceEvent{
+		ID:          row.ID,
+		WorkspaceID: pgconv.PgToUUID(row.WorkspaceID),
+		Type:        row.EventType,
+		FilePath:    pgconv.PgToString(row.FilePath),
+		ActorID:     pgconv.PgToUUIDPtr(row.ActorID),
+		Data:        json.RawMessage(row.Payload),
+		CreatedAt:   pgconv.PgToTime(row.CreatedAt),
+	}
+}
+
+func toDomainWebhook(row db.WorkspaceWebhook) domain.WorkspaceWebhook {
+	return domain.WorkspaceWebhook{
+		ID:            pgconv.PgToUUID(row.ID),
+		WorkspaceID:   pgconv.PgToUUID(row.WorkspaceID),
+		URL:           row.Url,
+		EventTypes:    row.EventTypes,
+		PathPrefix:    row.PathPrefix,
+		LastEventID:   row.LastEventID,
+		FailureCount:  row.FailureCount,
+		LastError:     pgconv.PgToStringPtr(row.LastError),
+		NextAttemptAt: pgconv.PgToTimePtr(row.NextAttemptAt),
+		CreatedAt:     pgconv.PgToTime(row.CreatedAt),
+	}
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/netguard"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/duckonomy/noture/pkg/webhook"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventService_TaskEvents_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	files := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	service := NewEventService(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	upload := func(content string) {
+		_, err := files.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     "projects/todo.md",
+			Content:      []byte(content),
+			LastModified: time.Now(),
+			ClientID:     "test-client",
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+
+	var hookMu sync.Mutex
+	var received []WebhookDelivery
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.NotEmpty(t, r.Header.Get(webhook.DefaultHeader))
+		var delivery WebhookDelivery
+		assert.NoError(t, json.Unmarshal(body, &delivery))
+		hookMu.Lock()
+		received = append(received, delivery)
+		hookMu.Unlock()
+	}))
+	defer server.Close()
+
+	_, err := service.CreateWebhook(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.CreateWebhookRequest{URL: server.URL})
+	assert.ErrorContains(t, err, "invalid webhook url", "the test server is on loopback")
+
+	// The test server only counts as public here.
+	hookURL := strings.Replace(server.URL, "127.0.0.1", "hooks.example.com", 1)
+	service.client = &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}}}
+	service.checkHost = func(context.Context, string) error { return nil }
+
+	hook, err := service.CreateWebhook(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.CreateWebhookRequest{
+		URL:        hookURL,
+		EventTypes: []string{"task.*"},
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, hook.Secret)
+
+	tomorrow := time.Now().Add(12 * time.Hour).UTC().Format(time.DateOnly)
+	upload("- [ ] Write spec due:" + tomorrow + "\n- [ ] Ship it\n")
+	upload("- [x] Write spec due:" + tomorrow + "\n- [ ] Ship it\n")
+
+	t.Run("uploads record file and task events", func(t *testing.T) {
+		page, err := service.ListEvents(ctx, testData.FreeWorkspaceID, testData.FreeUserID, 0, nil, 0)
+		require.NoError(t, err)
+
+		var types []string
+		for _, event := range page.Events {
+			types = append(types, event.Type)
+		}
+		assert.Equal(t, []string{
+			domain.EventFileCreated, domain.EventTaskCreated, domain.EventTaskCreated,
+			domain.EventFileUpdated, domain.EventTaskCompleted,
+		}, types)
+	})
+
+	t.Run("list filters by type and resumes after an id", func(t *testing.T) {
+		page, err := service.ListEvents(ctx, testData.FreeWorkspaceID, testData.FreeUserID, 0, []string{"task.*"}, 1)
+		require.NoError(t, err)
+		require.Len(t, page.Events, 1)
+
+		next, err := service.ListEvents(ctx, testData.FreeWorkspaceID, testData.FreeUserID, page.LastID, []string{"task.*"}, 0)
+		require.NoError(t, err)
+		assert.Len(t, next.Events, 2)
+	})
+
+	t.Run("reminders fire once per deadline", func(t *testing.T) {
+		upload("- [ ] Write spec due:" + tomorrow + "\n- [ ] Ship it\n")
+
+		reminded, err := service.RemindDueTasks(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, reminded)
+
+		reminded, err = service.RemindDueTasks(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, reminded)
+	})
+
+	t.Run("webhooks receive only matching events", func(t *testing.T) {
+		delivered, err := service.DeliverWebhooks(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+
+		hookMu.Lock()
+		defer hookMu.Unlock()
+		require.Len(t, received, 1)
+		for _, event := range received[0].Events {
+			assert.Contains(t, []string{domain.EventTaskCreated, domain.EventTaskCompleted, domain.EventTaskReopened}, event.Type)
+		}
+	})
+
+	t.Run("viewers cannot manage webhooks", func(t *testing.T) {
+		_, err := service.ListWebhooks(ctx, testData.FreeWorkspaceID, testData.PremiumUserID)
+		assert.Error(t, err)
+	})
+}
+
+func TestDeliveryError(t *testing.T) {
+	assert.Equal(t, "webhook returned a 4xx status", deliveryError(&webhookStatusError{code: 404}))
+	assert.Equal(t, "host resolves to a non-public address", deliveryError(fmt.Errorf("dial: %w", netguard.ErrNonPublic)))
+	assert.Equal(t, "webhook could not be reached", deliveryError(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+}
+
+func TestEventService_ReplayEvents_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	files := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	service := NewEventService(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	from := time.Now().Add(-time.Minute)
+	_, err := files.UploadFile(ctx, domain.FileUploadRequest{
+		WorkspaceID:  testData.FreeWorkspaceID,
+		FilePath:     "notes/secret.md",
+		Content:      []byte("- [ ] Rotate keys\n"),
+		LastModified: time.Now(),
+		ClientID:     "test-client",
+	}, testData.FreeUserID)
+	require.NoError(t, err)
+	err = testDB.Queries().InsertPolicyViolation(ctx, db.InsertPolicyViolationParams{
+		RuleName:    "AWS keys",
+		WorkspaceID: pgconv.UUIDToPg(testData.FreeWorkspaceID),
+		UserID:      pgconv.UUIDToPg(testData.FreeUserID),
+		FilePath:    "notes/secret.md",
+		ActionType:  "share",
+		Blocked:     true,
+		MatchCount:  1,
+	})
+	require.NoError(t, err)
+	to := time.Now().Add(time.Minute)
+
+	replay := func(userID uuid.UUID, tier domain.UserTier, from, to time.Time) ([]domain.ReplayedEvent, error) {
+		var events []domain.ReplayedEvent
+		err := service.ReplayEvents(ctx, testData.FreeWorkspaceID, userID, tier, from, to, func(event domain.ReplayedEvent) error {
+			events = append(events, event)
+			return nil
+		})
+		return events, err
+	}
+
+	t.Run("merges the outbox with the audit trail", func(t *testing.T) {
+		events, err := replay(testData.FreeUserID, domain.TierEnterprise, from, to)
+		require.NoError(t, err)
+
+		var types []string
+		for _, event := range events {
+			types = append(types, event.Source+":"+event.Type)
+		}
+		assert.Equal(t, []string{"outbox:file.created", "outbox:task.created", "audit:policy.violation"}, types)
+	})
+
+	t.Run("window excludes other events", func(t *testing.T) {
+		events, err := replay(testData.FreeUserID, domain.TierEnterprise, to, to.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("requires the enterprise tier", func(t *testing.T) {
+		_, err := replay(testData.FreeUserID, domain.TierFree, from, to)
+		assert.ErrorContains(t, err, "access denied")
+	})
+
+	t.Run("rejects windows over the maximum", func(t *testing.T) {
+		_, err := replay(testData.FreeUserID, domain.TierEnterprise, from, from.Add(domain.MaxReplayWindow+time.Hour))
+		assert.ErrorContains(t, err, "invalid window")
+	})
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/duckonomy/noture/pkg/tasks"
+	"github.com/google/uuid"
+)
+
+// recordEvent appends an event to the workspace's outbox. Callers pass the
+// transaction's queries so the event commits or rolls back with the change
+// it describes.
+func recordEvent(ctx context.Context, qtx *db.Queries, workspaceID uuid.UUID, eventType, filePath string, actorID *uuid.UUID, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	_, err = qtx.InsertWorkspaceEvent(ctx, db.InsertWorkspaceEventParams{
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		EventType:   eventType,
+		FilePath:    optionalText(filePath),
+		ActorID:     pgconv.UUIDPtrToPg(actorID),
+		Payload:     payload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// parseTasks extracts the tasks of a note. Dates without a zone are read
+// as UTC. Formats without tasks return nil.
+func parseTasks(format domain.FileFormat, content []byte) []tasks.Task {
+	switch format {
+	case domain.FormatMarkdown:
+		return tasks.ParseMarkdown(content, time.UTC)
+	case domain.FormatOrgMode:
+		return tasks.ParseOrg(content, time.UTC)
+	}
+	return nil
+}
+
+// syncFileTasks stores the tasks now in file and records task events for
+// the ones that appeared, were completed or were reopened since the last
+// upload. A changed deadline re-arms its reminder.
+func syncFileTasks(ctx context.Context, qtx *db.Queries, file db.File, format domain.FileFormat, content []byte, actorID uuid.UUID) error {
+	found := parseTasks(format, content)
+
+	previous, err := qtx.ListFileTasks(ctx, file.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load tasks: %w", err)
+	}
+	if len(found) == 0 && len(previous) == 0 {
+		return nil
+	}
+	known := make(map[string]db.FileTask, len(previous))
+	for _, task := range previous {
+		known[task.TaskKey] = task
+	}
+
+	workspaceID := pgconv.PgToUUID(file.WorkspaceID)
+	keys := make([]string, 0, len(found))
+	for _, task := range found {
+		keys = append(keys, task.Key)
+
+		eventType := ""
+		old, existed := known[task.Key]
+		switch {
+		case !existed:
+			eventType = domain.EventTaskCreated
+		case task.Done && !old.Done:
+			eventType = domain.EventTaskCompleted
+		case !task.Done && old.Done:
+			eventType = domain.EventTaskReopened
+		}
+		if eventType != "" {
+			err := recordEvent(ctx, qtx, workspaceID, eventType, file.FilePath, &actorID, domain.TaskEventData{
+				TaskKey:  task.Key,
+				Title:    task.Title,
+				Done:     task.Done,
+				Deadline: task.Deadline,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		err := qtx.UpsertFileTask(ctx, db.UpsertFileTaskParams{
+			FileID:      file.ID,
+			TaskKey:     task.Key,
+			WorkspaceID: file.WorkspaceID,
+			Title:       task.Title,
+			Done:        task.Done,
+			Deadline:    pgconv.TimePtrToPg(task.Deadline),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store task: %w", err)
+		}
+	}
+
+	err = qtx.DeleteFileTasksExcept(ctx, db.DeleteFileTasksExceptParams{
+		FileID:   file.ID,
+		KeepKeys: keys,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove tasks: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// GetFileWithMeta is GetFile with the file's client metadata filled in.
+func (s *FileService) GetFileWithMeta(ctx context.Context, workspaceID uuid.UUID, filePath string, userID uuid.UUID) (*domain.FileInfo, error) {
+	info, err := s.GetFile(ctx, workspaceID, filePath, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := retryRead(ctx, "get_file_meta", func() (domain.FileMeta, error) {
+		return loadFileMeta(db.PreferReplica(ctx), s.queries, pgconv.UUIDToPg(info.ID))
+	})
+	if err != nil {
+		return nil, err
+	}
+	info.Meta = meta
+	return info, nil
+}
+
+// PatchFileMeta merges patch into the file's client metadata, as a JSON
+// merge patch on the top-level keys, and returns the file with the result.
+// Editors may change it; it is shared by everyone who reads the file.
+func (s *FileService) PatchFileMeta(ctx context.Context, workspaceID uuid.UUID, filePath string, userID uuid.UUID, patch domain.FileMeta) (*domain.FileInfo, error) {
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	if workspace.ArchivedAt.Valid {
+		return nil, fmt.Errorf("workspace is archived")
+	}
+
+	var info *domain.FileInfo
+	err = inTx(ctx, s.conn, s.queries, "patch_file_meta", func(qtx *db.Queries) error {
+		err := qtx.LockFilePath(ctx, db.LockFilePathParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			FilePath:    filePath,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to lock file: %w", err)
+		}
+		file, err := qtx.GetFile(ctx, db.GetFileParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			FilePath:    filePath,
+		})
+		if err != nil {
+			return fmt.Errorf("file not found: %w", err)
+		}
+
+		current, err := loadFileMeta(ctx, qtx, file.ID)
+		if err != nil {
+			return err
+		}
+		meta := current.Merge(patch)
+		if err := meta.Validate(); err != nil {
+			return err
+		}
+
+		if len(meta) == 0 {
+			err = qtx.DeleteFileMeta(ctx, file.ID)
+		} else {
+			var encoded []byte
+			if encoded, err = json.Marshal(meta); err != nil {
+				return fmt.Errorf("failed to encode meta: %w", err)
+			}
+			err = qtx.SetFileMeta(ctx, db.SetFileMetaParams{FileID: file.ID, Meta: encoded})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save meta: %w", err)
+		}
+
+		info = &domain.FileInfo{
+			ID:           pgconv.PgToUUID(file.ID),
+			WorkspaceID:  pgconv.PgToUUID(file.WorkspaceID),
+			FilePath:     file.FilePath,
+			ContentHash:  file.ContentHash,
+			SizeBytes:    file.SizeBytes,
+			MimeType:     pgconv.PgToString(file.MimeType),
+			LastModified: pgconv.PgToTime(file.LastModified),
+			UpdatedAt:    pgconv.PgToTime(file.UpdatedAt),
+			Meta:         meta,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// loadFileMeta returns a file's client metadata, empty when it has none.
+func loadFileMeta(ctx context.Context, queries *db.Queries, fileID pgtype.UUID) (domain.FileMeta, error) {
+	encoded, err := queries.GetFileMeta(ctx, fileID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.FileMeta{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get meta: %w", err)
+	}
+	var meta domain.FileMeta
+	if err := json.Unmarshal(encoded, &meta); err != nil {
+		return nil, fmt.Errorf("failed to decode meta: %w", err)
+	}
+	return meta, nil
+}
@@ -1,178 +1,315 @@
 package services
 
 import (
+	"bytes"
 	"context"
-	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"mime"
+	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/duckonomy/noture/internal/db"
 	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/events"
+	"github.com/duckonomy/noture/internal/storage"
 	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/noteid"
 	"github.com/duckonomy/noture/pkg/pgconv"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
+// MaxFileListPageSize caps a single page of ListFilesPage.
+const MaxFileListPageSize = 1000
+
+// MaxFileLookupPaths caps how many paths one bulk lookup may ask for.
+const MaxFileLookupPaths = 500
+
+// BlobCollectionGracePeriod is how long content must stay unreferenced
+// before the collector deletes it, so restores and in-flight uploads of the
+// same content can pick it up again cheaply.
+const BlobCollectionGracePeriod = time.Hour
+
+// BlobCollectionBatchSize caps how many blobs one collector run deletes.
+const BlobCollectionBatchSize = 500
+
 type FileService struct {
 	queries                     *db.Queries
 	conn                        *pgx.Conn
+	blobs                       storage.Backend
+	paths                       *keyedMutex
+	noteIDs                     noteid.Strategy
+	metadata                    *metadataParser
 	disableAsyncMetadataParsing bool
+	events                      *events.Bus
 	log                         *logger.Logger
 }
 
-func NewFileService(queries *db.Queries, conn *pgx.Conn) *FileService {
-	return &FileService{
+// NewFileService starts the workers parsing uploaded files; stop them
+// with Close.
+func NewFileService(queries *db.Queries, conn *pgx.Conn, blobs storage.Backend) *FileService {
+	s := &FileService{
 		queries:                     queries,
 		conn:                        conn,
+		blobs:                       blobs,
+		paths:                       newKeyedMutex(),
+		noteIDs:                     noteid.Frontmatter{},
 		disableAsyncMetadataParsing: false,
 		log:                         logger.New(),
 	}
+	s.metadata = newMetadataParser(s, MetadataParseWorkers, MetadataParseQueueSize)
+	return s
+}
+
+// Close waits for the files queued for metadata parsing, or until ctx is
+// done, and stops the parse workers. Uploads after Close leave parsing to
+// the backfill.
+func (s *FileService) Close(ctx context.Context) error {
+	if s.metadata == nil {
+		return nil
+	}
+	return s.metadata.close(ctx)
+}
+
+// WithEvents publishes FileUploaded and FileDeleted on bus once the
+// change has committed.
+func (s *FileService) WithEvents(bus *events.Bus) *FileService {
+	s.events = bus
+	return s
 }
 
 func NewFileServiceForTesting(queries *db.Queries, conn *pgx.Conn) *FileService {
 	return &FileService{
 		queries:                     queries,
 		conn:                        conn,
+		blobs:                       storage.NewPostgresBackend(queries),
+		paths:                       newKeyedMutex(),
+		noteIDs:                     noteid.Frontmatter{},
 		disableAsyncMetadataParsing: true,
 		log:                         logger.New(),
 	}
 }
 
-func (s *FileService) UploadFile(ctx context.Context, req domain.FileUploadRequest, userID uuid.UUID) (*domain.FileInfo, error) {
-	log := s.log.WithUser(userID.String(), "").WithWorkspace(req.WorkspaceID.String(), "")
+// UploadFile stores a new version of a file. Uploading the content the file
+// already has is a no-op that reports Unchanged, so clients can retry or
+// re-sync freely.
+func (s *FileService) UploadFile(ctx context.Context, req domain.FileUploadRequest, userID uuid.UUID) (*domain.FileUploadResult, error) {
+	log := s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(req.WorkspaceID.String(), "")
 	log.Info("Starting file upload", "file_path", req.FilePath, "size_bytes", len(req.Content))
 
-	workspace, err := s.queries.GetWorkspaceByID(ctx, pgconv.UUIDToPg(req.WorkspaceID))
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, req.WorkspaceID, userID, domain.RoleEditor)
 	if err != nil {
-		log.WithError(err).Error("Workspace not found", "workspace_id", req.WorkspaceID)
-		return nil, fmt.Errorf("workspace not found: %w", err)
+		log.WithError(err).Warn("Upload not permitted", "workspace_id", req.WorkspaceID)
+		return nil, err
 	}
 
-	if pgconv.PgToUUID(workspace.UserID) != userID {
-		log.Warn("Access denied: workspace belongs to different user",
-			"workspace_owner", pgconv.PgToUUID(workspace.UserID),
-			"requesting_user", userID)
-		return nil, fmt.Errorf("access denied: workspace belongs to different user")
+	if workspace.ArchivedAt.Valid {
+		log.Warn("Rejected upload to archived workspace")
+		return nil, fmt.Errorf("workspace is archived")
 	}
 
-	hash := sha256.Sum256(req.Content)
-	contentHash := fmt.Sprintf("%x", hash)
+	contentHash := storage.Hash(req.Content)
 
-	storageInfo, err := s.queries.GetWorkspaceStorageUsage(ctx, pgconv.UUIDToPg(req.WorkspaceID))
-	if err != nil {
-		return nil, fmt.Errorf("failed to get storage usage: %w", err)
-	}
-
-	var currentFileSize int64
-	existingFile, err := s.queries.GetFile(ctx, db.GetFileParams{
-		WorkspaceID: pgconv.UUIDToPg(req.WorkspaceID),
-		FilePath:    req.FilePath,
-	})
-	if err == nil {
-		currentFileSize = existingFile.SizeBytes
-	}
+	mimeType := s.detectMimeType(req.FilePath, req.Content)
 
-	newStorageUsage := pgconv.PgToInt64(storageInfo.StorageUsedBytes) - currentFileSize + int64(len(req.Content))
-	if newStorageUsage > storageInfo.StorageLimitBytes {
-		log.Warn("Storage limit exceeded",
-			"current_usage", pgconv.PgToInt64(storageInfo.StorageUsedBytes),
-			"needed_usage", newStorageUsage,
-			"limit", storageInfo.StorageLimitBytes)
-		return nil, fmt.Errorf("storage limit exceeded: need %d bytes, limit %d bytes",
-			newStorageUsage, storageInfo.StorageLimitBytes)
+	noteID, err := s.resolveNoteID(req.NoteID, req.FilePath, req.Content)
+	if err != nil {
+		return nil, err
 	}
 
-	mimeType := s.detectMimeType(req.FilePath, req.Content)
-
 	syncOp, err := s.queries.CreateSyncOperation(ctx, db.CreateSyncOperationParams{
 		WorkspaceID:   pgconv.UUIDToPg(req.WorkspaceID),
-		OperationType: "upload",
+		OperationType: domain.SyncOpUpload,
 		ClientID:      pgconv.StringToPg(req.ClientID),
-		Status:        "pending",
+		Status:        domain.SyncStatusPending,
+		FilePath:      pgconv.StringToPg(req.FilePath),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create sync operation: %w", err)
 	}
 
-	tx, err := s.conn.Begin(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to start transaction: %w", err)
-	}
-	defer tx.Rollback(ctx)
+	// Writers of the same path queue up here rather than on the row lock,
+	// so each sees the previous one's version and size.
+	unlock := s.paths.Lock(req.WorkspaceID.String() + "/" + req.FilePath)
+	defer unlock()
 
-	qtx := s.queries.WithTx(tx)
+	var file db.File
+	var result domain.FileUploadResult
+	var storageUsed, storageLimit int64
+	err = inTx(ctx, s.conn, s.queries, "upload_file", func(qtx *db.Queries) error {
+		result = domain.FileUploadResult{}
 
-	file, err := qtx.UpsertFile(ctx, db.UpsertFileParams{
-		WorkspaceID:  pgconv.UUIDToPg(req.WorkspaceID),
-		FilePath:     req.FilePath,
-		ContentHash:  contentHash,
-		Content:      req.Content,
-		SizeBytes:    int64(len(req.Content)),
-		MimeType:     pgconv.StringToPg(mimeType),
-		LastModified: pgconv.TimeToPg(req.LastModified),
-	})
-	if err != nil {
-		errStr := err.Error()
-		s.queries.UpdateSyncOperationStatus(ctx, db.UpdateSyncOperationStatusParams{
-			ID:           syncOp.ID,
-			Status:       "failed",
-			ErrorMessage: pgconv.StringPtrToPg(&errStr),
+		// Other server instances only see the advisory lock.
+		err := qtx.LockFilePath(ctx, db.LockFilePathParams{
+			WorkspaceID: pgconv.UUIDToPg(req.WorkspaceID),
+			FilePath:    req.FilePath,
 		})
-		return nil, fmt.Errorf("failed to upsert file: %w", err)
-	}
+		if err != nil {
+			return fmt.Errorf("failed to lock file: %w", err)
+		}
 
-	err = qtx.UpdateWorkspaceStorageUsed(ctx, db.UpdateWorkspaceStorageUsedParams{
-		ID:               pgconv.UUIDToPg(req.WorkspaceID),
-		StorageUsedBytes: pgconv.Int64ToPg(newStorageUsage),
-	})
-	if err != nil {
-		errStr := err.Error()
-		s.queries.UpdateSyncOperationStatus(ctx, db.UpdateSyncOperationStatusParams{
-			ID:           syncOp.ID,
-			Status:       "failed",
-			ErrorMessage: pgconv.StringPtrToPg(&errStr),
+		storageInfo, err := qtx.GetWorkspaceStorageUsage(ctx, pgconv.UUIDToPg(req.WorkspaceID))
+		if err != nil {
+			return fmt.Errorf("failed to get storage usage: %w", err)
+		}
+
+		var currentFileSize int64
+		var fileCountDelta int64 = 1
+		existingFile, err := qtx.GetFile(ctx, db.GetFileParams{
+			WorkspaceID: pgconv.UUIDToPg(req.WorkspaceID),
+			FilePath:    req.FilePath,
 		})
-		return nil, fmt.Errorf("failed to update storage usage: %w", err)
-	}
+		switch {
+		case err == nil:
+			currentFileSize = existingFile.SizeBytes
+			fileCountDelta = 0
+		case errors.Is(err, pgx.ErrNoRows):
+			result.Created = true
+		default:
+			return fmt.Errorf("failed to get file: %w", err)
+		}
 
-	err = qtx.CreateFileVersion(ctx, db.CreateFileVersionParams{
-		FileID:        file.ID,
-		VersionNumber: 1, // TODO: implement proper versioning
-		ContentHash:   contentHash,
-		Content:       req.Content,
+		if req.BaseHash != nil && *req.BaseHash != existingFile.ContentHash {
+			return fmt.Errorf("file changed")
+		}
+
+		if err == nil && existingFile.ContentHash == contentHash {
+			return s.unchangedUpload(ctx, qtx, existingFile, &file, &result)
+		}
+
+		result.Deduplicated, err = qtx.BlobRefExists(ctx, contentHash)
+		if err != nil {
+			return fmt.Errorf("failed to check stored content: %w", err)
+		}
+
+		limits := domain.UserTier(storageInfo.Tier).GetFileLimits()
+		if err := limits.Check(int64(len(req.Content)), storageInfo.FileCount, result.Created); err != nil {
+			log.WithError(err).Warn("File limit exceeded")
+			return err
+		}
+
+		newStorageUsage := storageInfo.StorageUsedBytes - currentFileSize + int64(len(req.Content))
+		if newStorageUsage > storageInfo.StorageLimitBytes {
+			log.Warn("Storage limit exceeded",
+				"current_usage", storageInfo.StorageUsedBytes,
+				"needed_usage", newStorageUsage,
+				"limit", storageInfo.StorageLimitBytes)
+			return fmt.Errorf("storage limit exceeded: need %d bytes, limit %d bytes",
+				newStorageUsage, storageInfo.StorageLimitBytes)
+		}
+		storageUsed, storageLimit = newStorageUsage, storageInfo.StorageLimitBytes
+
+		file, err = qtx.UpsertFile(ctx, db.UpsertFileParams{
+			WorkspaceID:  pgconv.UUIDToPg(req.WorkspaceID),
+			FilePath:     req.FilePath,
+			ContentHash:  contentHash,
+			SizeBytes:    int64(len(req.Content)),
+			MimeType:     pgconv.StringToPg(mimeType),
+			LastModified: pgconv.TimeToPg(req.LastModified),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upsert file: %w", err)
+		}
+
+		result.Revision, err = qtx.AdjustWorkspaceCounters(ctx, db.AdjustWorkspaceCountersParams{
+			ID:               pgconv.UUIDToPg(req.WorkspaceID),
+			FileCount:        fileCountDelta,
+			StorageUsedBytes: pgconv.Int64ToPg(int64(len(req.Content)) - currentFileSize),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update storage usage: %w", err)
+		}
+
+		result.VersionNumber, err = qtx.CreateFileVersion(ctx, db.CreateFileVersionParams{
+			FileID:      file.ID,
+			ContentHash: contentHash,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create file version: %w", err)
+		}
+
+		eventType := domain.EventFileUpdated
+		if result.Created {
+			eventType = domain.EventFileCreated
+		}
+		err = recordEvent(ctx, qtx, req.WorkspaceID, eventType, req.FilePath, &userID, map[string]any{
+			"version":      result.VersionNumber,
+			"content_hash": contentHash,
+			"size_bytes":   file.SizeBytes,
+		})
+		if err != nil {
+			return err
+		}
+		format := s.DetectFileFormat(req.FilePath, req.Content)
+		if err := syncFileTasks(ctx, qtx, file, format, req.Content, userID); err != nil {
+			return err
+		}
+		if err := syncFileAgenda(ctx, qtx, file, format, req.Content); err != nil {
+			return err
+		}
+		if err := syncFileLinks(ctx, qtx, file, format, req.Content); err != nil {
+			return err
+		}
+		if err := indexFileContent(ctx, qtx, file, req.Content); err != nil {
+			return err
+		}
+		if err := syncNoteID(ctx, qtx, file, noteID); err != nil {
+			return err
+		}
+
+		// The reference triggers have locked this hash's blob_refs row, so
+		// the collector cannot delete the blob between this write and the
+		// commit. Identical content is already stored and Put is a no-op
+		// for it.
+		if err := s.blobs.Put(ctx, contentHash, req.Content); err != nil {
+			return fmt.Errorf("failed to store file content: %w", err)
+		}
+		return nil
 	})
 	if err != nil {
-		// Don't fail the entire operation for versioning issues
-		// TODO: log this error
-	}
-
-	if err = tx.Commit(ctx); err != nil {
+		log.WithError(err).Error("Upload transaction failed")
 		errStr := err.Error()
 		s.queries.UpdateSyncOperationStatus(ctx, db.UpdateSyncOperationStatusParams{
 			ID:           syncOp.ID,
-			Status:       "failed",
+			Status:       domain.SyncStatusFailed,
 			ErrorMessage: pgconv.StringPtrToPg(&errStr),
 		})
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, err
 	}
 
 	err = s.queries.UpdateSyncOperationStatus(ctx, db.UpdateSyncOperationStatusParams{
 		ID:     syncOp.ID,
-		Status: "success",
+		Status: domain.SyncStatusSuccess,
 	})
 	if err != nil {
 		// Don't fail the entire operation for sync log issues
 		// TODO: log this error
 	}
 
-	if !s.disableAsyncMetadataParsing {
-		go s.parseFileMetadata(context.Background(), file)
+	if !result.Unchanged {
+		s.parseFileMetadata(ctx, file, req.Content)
+		s.events.Publish(ctx, events.FileUploaded{
+			WorkspaceID:       req.WorkspaceID,
+			ActorID:           userID,
+			FilePath:          req.FilePath,
+			SizeBytes:         file.SizeBytes,
+			Version:           result.VersionNumber,
+			Created:           result.Created,
+			StorageUsedBytes:  storageUsed,
+			StorageLimitBytes: storageLimit,
+		})
 	}
 
-	fileInfo := &domain.FileInfo{
+	result.FileInfo = domain.FileInfo{
 		ID:           pgconv.PgToUUID(file.ID),
 		WorkspaceID:  pgconv.PgToUUID(file.WorkspaceID),
 		FilePath:     file.FilePath,
@@ -184,24 +321,51 @@ func (s *FileService) UploadFile(ctx context.Context, req domain.FileUploadReque
 	}
 
 	log.LogFileOperation("upload", req.FilePath, file.SizeBytes)
-	log.Info("File upload completed successfully", "file_id", fileInfo.ID)
+	log.Info("File upload completed successfully",
+		"file_id", result.ID,
+		"version", result.VersionNumber,
+		"unchanged", result.Unchanged)
 
-	return fileInfo, nil
+	return &result, nil
 }
 
-func (s *FileService) GetFile(ctx context.Context, workspaceID uuid.UUID, filePath string, userID uuid.UUID) (*domain.FileInfo, error) {
-	workspace, err := s.queries.GetWorkspaceByID(ctx, pgconv.UUIDToPg(workspaceID))
+// unchangedUpload fills in the result for an upload whose content matches
+// the file's current version, without writing anything.
+func (s *FileService) unchangedUpload(ctx context.Context, qtx *db.Queries, existing db.File, file *db.File, result *domain.FileUploadResult) error {
+	versions, err := qtx.GetFileVersions(ctx, db.GetFileVersionsParams{
+		FileID: existing.ID,
+		Limit:  1,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("workspace not found: %w", err)
+		return fmt.Errorf("failed to get file versions: %w", err)
+	}
+	if len(versions) > 0 {
+		result.VersionNumber = versions[0].VersionNumber
 	}
 
-	if pgconv.PgToUUID(workspace.UserID) != userID {
-		return nil, fmt.Errorf("access denied: workspace belongs to different user")
+	workspace, err := qtx.GetWorkspaceByID(ctx, existing.WorkspaceID)
+	if err != nil {
+		return fmt.Errorf("workspace not found: %w", err)
 	}
 
-	file, err := s.queries.GetFile(ctx, db.GetFileParams{
-		WorkspaceID: pgconv.UUIDToPg(workspaceID),
-		FilePath:    filePath,
+	*file = existing
+	result.Revision = workspace.Revision
+	result.Unchanged = true
+	result.Deduplicated = true
+	return nil
+}
+
+func (s *FileService) GetFile(ctx context.Context, workspaceID uuid.UUID, filePath string, userID uuid.UUID) (*domain.FileInfo, error) {
+	_, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := retryRead(ctx, "get_file", func() (db.File, error) {
+		return s.queries.GetFile(db.PreferReplica(ctx), db.GetFileParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			FilePath:    filePath,
+		})
 	})
 	if err != nil {
 		return nil, fmt.Errorf("file not found: %w", err)
@@ -220,23 +384,26 @@ func (s *FileService) GetFile(ctx context.Context, workspaceID uuid.UUID, filePa
 }
 
 func (s *FileService) GetFileContent(ctx context.Context, workspaceID uuid.UUID, filePath string, userID uuid.UUID) (*domain.FileWithContent, error) {
-	workspace, err := s.queries.GetWorkspaceByID(ctx, pgconv.UUIDToPg(workspaceID))
+	_, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
 	if err != nil {
-		return nil, fmt.Errorf("workspace not found: %w", err)
+		return nil, err
 	}
 
-	if pgconv.PgToUUID(workspace.UserID) != userID {
-		return nil, fmt.Errorf("access denied: workspace belongs to different user")
-	}
-
-	file, err := s.queries.GetFile(ctx, db.GetFileParams{
-		WorkspaceID: pgconv.UUIDToPg(workspaceID),
-		FilePath:    filePath,
+	file, err := retryRead(ctx, "get_file", func() (db.File, error) {
+		return s.queries.GetFile(db.PreferReplica(ctx), db.GetFileParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			FilePath:    filePath,
+		})
 	})
 	if err != nil {
 		return nil, fmt.Errorf("file not found: %w", err)
 	}
 
+	content, err := s.blobs.Get(ctx, file.ContentHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file content: %w", err)
+	}
+
 	return &domain.FileWithContent{
 		FileInfo: domain.FileInfo{
 			ID:           pgconv.PgToUUID(file.ID),
@@ -248,21 +415,34 @@ func (s *FileService) GetFileContent(ctx context.Context, workspaceID uuid.UUID,
 			LastModified: pgconv.PgToTime(file.LastModified),
 			UpdatedAt:    pgconv.PgToTime(file.UpdatedAt),
 		},
-		Content: file.Content,
+		Content: content,
 	}, nil
 }
 
-func (s *FileService) ListFiles(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID) ([]domain.FileInfo, error) {
-	workspace, err := s.queries.GetWorkspaceByID(ctx, pgconv.UUIDToPg(workspaceID))
+// OpenFileContent returns the file's metadata and a seekable stream of its
+// content. The caller must close the reader.
+func (s *FileService) OpenFileContent(ctx context.Context, workspaceID uuid.UUID, filePath string, userID uuid.UUID) (*domain.FileInfo, io.ReadSeekCloser, error) {
+	file, err := s.GetFile(ctx, workspaceID, filePath, userID)
 	if err != nil {
-		return nil, fmt.Errorf("workspace not found: %w", err)
+		return nil, nil, err
 	}
 
-	if pgconv.PgToUUID(workspace.UserID) != userID {
-		return nil, fmt.Errorf("access denied: workspace belongs to different user")
+	content, err := s.blobs.Open(ctx, file.ContentHash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read file content: %w", err)
+	}
+	return file, content, nil
+}
+
+func (s *FileService) ListFiles(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID) ([]domain.FileInfo, error) {
+	_, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
+	if err != nil {
+		return nil, err
 	}
 
-	files, err := s.queries.ListFiles(ctx, pgconv.UUIDToPg(workspaceID))
+	files, err := retryRead(ctx, "list_files", func() ([]db.ListFilesRow, error) {
+		return s.queries.ListFiles(db.PreferReplica(ctx), pgconv.UUIDToPg(workspaceID))
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list files: %w", err)
 	}
@@ -284,50 +464,576 @@ func (s *FileService) ListFiles(ctx context.Context, workspaceID uuid.UUID, user
 	return result, nil
 }
 
-func (s *FileService) DeleteFile(ctx context.Context, workspaceID uuid.UUID, filePath string, userID uuid.UUID) error {
-	workspace, err := s.queries.GetWorkspaceByID(ctx, pgconv.UUIDToPg(workspaceID))
+// ListFilesPage returns one keyset page of the workspace's files, filtered
+// by path prefix and MIME type. Each sort has its own index, so every page
+// costs the same however deep into a large workspace it is.
+func (s *FileService) ListFilesPage(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, opts domain.FileListOptions) (*domain.FileListPage, error) {
+	_, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
 	if err != nil {
-		return fmt.Errorf("workspace not found: %w", err)
+		return nil, err
 	}
 
-	if pgconv.PgToUUID(workspace.UserID) != userID {
-		return fmt.Errorf("access denied: workspace belongs to different user")
+	limit := opts.Limit
+	if limit <= 0 || limit > MaxFileListPageSize {
+		limit = MaxFileListPageSize
 	}
 
-	file, err := s.queries.GetFile(ctx, db.GetFileParams{
-		WorkspaceID: pgconv.UUIDToPg(workspaceID),
-		FilePath:    filePath,
+	mimePatterns := make([]string, 0, 2*len(opts.MimeTypes))
+	for _, mimeType := range opts.MimeTypes {
+		mimePatterns = append(mimePatterns, mimeTypePatterns(mimeType)...)
+	}
+
+	// Fetch one extra row to learn whether another page follows.
+	var files []db.ListFilesPageRow
+	switch opts.Sort {
+	case domain.SortByPath, "":
+		files, err = retryRead(ctx, "list_files_page", func() ([]db.ListFilesPageRow, error) {
+			return s.queries.ListFilesPage(db.PreferReplica(ctx), db.ListFilesPageParams{
+				WorkspaceID:  pgconv.UUIDToPg(workspaceID),
+				AfterPath:    opts.Cursor,
+				PathPrefix:   opts.PathPrefix,
+				MimePatterns: mimePatterns,
+				PageSize:     int32(limit + 1),
+			})
+		})
+
+	case domain.SortByUpdatedAt:
+		after := time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+		afterPath := ""
+		if opts.Cursor != "" {
+			value, path, err := decodeListCursor(opts.Cursor)
+			if err != nil {
+				return nil, err
+			}
+			if after, err = time.Parse(time.RFC3339Nano, value); err != nil {
+				return nil, fmt.Errorf("invalid cursor")
+			}
+			afterPath = path
+		}
+		files, err = retryRead(ctx, "list_files_by_updated_at", func() ([]db.ListFilesPageRow, error) {
+			rows, err := s.queries.ListFilesByUpdatedAt(db.PreferReplica(ctx), db.ListFilesByUpdatedAtParams{
+				WorkspaceID:    pgconv.UUIDToPg(workspaceID),
+				AfterUpdatedAt: pgconv.TimeToPg(after),
+				AfterPath:      afterPath,
+				PathPrefix:     opts.PathPrefix,
+				MimePatterns:   mimePatterns,
+				PageSize:       int32(limit + 1),
+			})
+			page := make([]db.ListFilesPageRow, len(rows))
+			for i, row := range rows {
+				page[i] = db.ListFilesPageRow(row)
+			}
+			return page, err
+		})
+
+	case domain.SortBySize:
+		after := int64(math.MaxInt64)
+		afterPath := ""
+		if opts.Cursor != "" {
+			value, path, err := decodeListCursor(opts.Cursor)
+			if err != nil {
+				return nil, err
+			}
+			if after, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid cursor")
+			}
+			afterPath = path
+		}
+		files, err = retryRead(ctx, "list_files_by_size", func() ([]db.ListFilesPageRow, error) {
+			rows, err := s.queries.ListFilesBySize(db.PreferReplica(ctx), db.ListFilesBySizeParams{
+				WorkspaceID:  pgconv.UUIDToPg(workspaceID),
+				AfterSize:    after,
+				AfterPath:    afterPath,
+				PathPrefix:   opts.PathPrefix,
+				MimePatterns: mimePatterns,
+				PageSize:     int32(limit + 1),
+			})
+			page := make([]db.ListFilesPageRow, len(rows))
+			for i, row := range rows {
+				page[i] = db.ListFilesPageRow(row)
+			}
+			return page, err
+		})
+
+	default:
+		return nil, fmt.Errorf("invalid sort %q", opts.Sort)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	page := &domain.FileListPage{}
+	if len(files) > limit {
+		files = files[:limit]
+		last := files[limit-1]
+		switch opts.Sort {
+		case domain.SortByUpdatedAt:
+			page.NextCursor = encodeListCursor(pgconv.PgToTime(last.UpdatedAt).Format(time.RFC3339Nano), last.FilePath)
+		case domain.SortBySize:
+			page.NextCursor = encodeListCursor(strconv.FormatInt(last.SizeBytes, 10), last.FilePath)
+		default:
+			page.NextCursor = last.FilePath
+		}
+	}
+
+	page.Files = make([]domain.FileInfo, len(files))
+	for i, file := range files {
+		page.Files[i] = domain.FileInfo{
+			ID:           pgconv.PgToUUID(file.ID),
+			WorkspaceID:  pgconv.PgToUUID(file.WorkspaceID),
+			FilePath:     file.FilePath,
+			ContentHash:  file.ContentHash,
+			SizeBytes:    file.SizeBytes,
+			MimeType:     pgconv.PgToString(file.MimeType),
+			LastModified: pgconv.PgToTime(file.LastModified),
+			UpdatedAt:    pgconv.PgToTime(file.UpdatedAt),
+		}
+	}
+
+	return page, nil
+}
+
+// GetFileTree returns the folder hierarchy below folder ("" for the whole
+// workspace), reading the listing one page at a time.
+func (s *FileService) GetFileTree(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, folder string) (*domain.FileTreeNode, error) {
+	prefix := domain.FolderPrefix(folder)
+
+	var files []domain.FileInfo
+	opts := domain.FileListOptions{PathPrefix: prefix}
+	for {
+		page, err := s.ListFilesPage(ctx, workspaceID, userID, opts)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, page.Files...)
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+
+	return domain.BuildFileTree(prefix, files), nil
+}
+
+// GetManifest returns the sync state of every file below folder in one
+// read. With digestOnly, it returns the folder's digest and its direct
+// children instead of the files. The revision is read first, so the
+// manifest is at least as new as it.
+func (s *FileService) GetManifest(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, folder string, digestOnly bool) (*domain.Manifest, error) {
+	prefix := domain.FolderPrefix(folder)
+
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := retryRead(ctx, "list_file_manifest", func() ([]db.ListFileManifestRow, error) {
+		return s.queries.ListFileManifest(ctx, db.ListFileManifestParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			PathPrefix:  prefix,
+		})
 	})
 	if err != nil {
-		return fmt.Errorf("file not found: %w", err)
+		return nil, fmt.Errorf("failed to list files: %w", err)
 	}
 
-	tx, err := s.conn.Begin(ctx)
+	entries := make([]domain.ManifestEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = domain.ManifestEntry{
+			Path:        row.FilePath,
+			ContentHash: row.ContentHash,
+			Size:        row.SizeBytes,
+			MTime:       pgconv.PgToTime(row.LastModified),
+		}
+	}
+
+	manifest := &domain.Manifest{Folder: prefix, Revision: workspace.Revision}
+	manifest.Digest, manifest.Children = domain.ManifestDigest(prefix, entries)
+	if !digestOnly {
+		manifest.Files, manifest.Children = entries, nil
+	}
+	return manifest, nil
+}
+
+// DeleteFolder deletes every file below folder in one transaction and
+// releases their storage. Uploads into the folder wait for it, or run
+// after and are kept.
+func (s *FileService) DeleteFolder(ctx context.Context, workspaceID uuid.UUID, folder string, userID uuid.UUID) (*domain.FolderDeleteResult, error) {
+	prefix := domain.FolderPrefix(folder)
+	if prefix == "" {
+		return nil, fmt.Errorf("invalid folder: the workspace root cannot be deleted")
+	}
+
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleEditor)
 	if err != nil {
-		return fmt.Errorf("failed to start transaction: %w", err)
+		return nil, err
 	}
-	defer tx.Rollback(ctx)
 
-	qtx := s.queries.WithTx(tx)
+	if workspace.ArchivedAt.Valid {
+		return nil, fmt.Errorf("workspace is archived")
+	}
+
+	result := &domain.FolderDeleteResult{Folder: prefix}
+	err = inTx(ctx, s.conn, s.queries, "delete_folder", func(qtx *db.Queries) error {
+		// Same advisory locks as single-file writers, taken in path order
+		// so two overlapping folder deletes cannot deadlock.
+		err := qtx.LockFilesByPrefix(ctx, db.LockFilesByPrefixParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			PathPrefix:  prefix,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to lock files: %w", err)
+		}
+
+		deleted, err := qtx.DeleteFilesByPrefix(ctx, db.DeleteFilesByPrefixParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			PathPrefix:  prefix,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete files: %w", err)
+		}
+		if deleted.FileCount == 0 {
+			return fmt.Errorf("folder not found: %s", prefix)
+		}
+
+		result.Revision, err = qtx.AdjustWorkspaceCounters(ctx, db.AdjustWorkspaceCountersParams{
+			ID:               pgconv.UUIDToPg(workspaceID),
+			FileCount:        -deleted.FileCount,
+			StorageUsedBytes: pgconv.Int64ToPg(-deleted.SizeBytes),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update storage usage: %w", err)
+		}
+
+		result.DeletedFiles = deleted.FileCount
+		result.FreedBytes = deleted.SizeBytes
+		return recordEvent(ctx, qtx, workspaceID, domain.EventFolderDeleted, prefix, &userID, map[string]any{
+			"deleted_files": deleted.FileCount,
+			"freed_bytes":   deleted.SizeBytes,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), workspace.Name).
+		Info("Deleted folder", "folder", prefix, "files", result.DeletedFiles, "bytes", result.FreedBytes)
+	return result, nil
+}
+
+// ListSyncOperations returns a workspace's recorded sync operations,
+// newest first, so failed or conflicting syncs can be traced to a client.
+func (s *FileService) ListSyncOperations(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, filter domain.SyncOperationFilter) (*domain.SyncOperationPage, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	_, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > MaxFileListPageSize {
+		limit = MaxFileListPageSize
+	}
+
+	// The first page starts below (until, nil id), which excludes until
+	// itself, or above every row when there is no upper bound.
+	before, beforeID := time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC), uuid.Max
+	if filter.Until != nil {
+		before, beforeID = *filter.Until, uuid.Nil
+	}
+	if filter.Cursor != "" {
+		value, id, err := decodeListCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if before, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		if beforeID, err = uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+	}
+	since := time.Time{}
+	if filter.Since != nil {
+		since = *filter.Since
+	}
+
+	// Fetch one extra row to learn whether another page follows.
+	ops, err := retryRead(ctx, "list_sync_operations", func() ([]db.SyncOperation, error) {
+		return s.queries.ListSyncOperations(ctx, db.ListSyncOperationsParams{
+			WorkspaceID:     pgconv.UUIDToPg(workspaceID),
+			BeforeCreatedAt: pgconv.TimeToPg(before),
+			BeforeID:        pgconv.UUIDToPg(beforeID),
+			Since:           pgconv.TimeToPg(since),
+			Status:          optionalText(filter.Status),
+			OperationType:   optionalText(filter.OperationType),
+			ClientID:        optionalText(filter.ClientID),
+			PageSize:        int32(limit + 1),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sync operations: %w", err)
+	}
+
+	page := &domain.SyncOperationPage{}
+	if len(ops) > limit {
+		ops = ops[:limit]
+		last := ops[limit-1]
+		page.NextCursor = encodeListCursor(pgconv.PgToTime(last.CreatedAt).Format(time.RFC3339Nano), pgconv.PgToUUID(last.ID).String())
+	}
+
+	page.Operations = make([]domain.SyncOperation, len(ops))
+	for i, op := range ops {
+		page.Operations[i] = domain.SyncOperation{
+			ID:            pgconv.PgToUUID(op.ID),
+			WorkspaceID:   pgconv.PgToUUID(op.WorkspaceID),
+			FileID:        pgconv.PgToUUIDPtr(op.FileID),
+			FilePath:      pgconv.PgToString(op.FilePath),
+			OperationType: op.OperationType,
+			ClientID:      pgconv.PgToStringPtr(op.ClientID),
+			Status:        op.Status,
+			ErrorMessage:  pgconv.PgToStringPtr(op.ErrorMessage),
+			CreatedAt:     pgconv.PgToTime(op.CreatedAt),
+		}
+	}
+
+	return page, nil
+}
+
+// encodeListCursor packs the sort key and path of a page's last file. The
+// key never contains a newline, so the path may.
+func encodeListCursor(value, path string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(value + "\n" + path))
+}
+
+func decodeListCursor(cursor string) (value, path string, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid cursor")
+	}
+	value, path, ok := strings.Cut(string(raw), "\n")
+	if !ok {
+		return "", "", fmt.Errorf("invalid cursor")
+	}
+	return value, path, nil
+}
+
+// mimeTypePatterns turns a MIME type filter into LIKE patterns. Stored
+// types may carry parameters ("text/html; charset=utf-8"), which still
+// match their bare type.
+func mimeTypePatterns(mimeType string) []string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(mimeType))
+	if prefix, ok := strings.CutSuffix(escaped, "/*"); ok {
+		return []string{prefix + "/%"}
+	}
+	return []string{escaped, escaped + ";%"}
+}
+
+// LookupFiles returns one result per requested path, in request order, from
+// a single query. Metadata is included only when asked for and parsed.
+func (s *FileService) LookupFiles(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, req domain.FileLookupRequest) ([]domain.FileLookupResult, error) {
+	if len(req.Paths) == 0 {
+		return nil, fmt.Errorf("no paths given")
+	}
+	if len(req.Paths) > MaxFileLookupPaths {
+		return nil, fmt.Errorf("too many paths: at most %d per lookup", MaxFileLookupPaths)
+	}
+
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return nil, err
+	}
 
-	err = qtx.DeleteFile(ctx, db.DeleteFileParams{
+	rows, err := s.queries.LookupFiles(ctx, db.LookupFilesParams{
 		WorkspaceID: pgconv.UUIDToPg(workspaceID),
-		FilePath:    filePath,
+		FilePaths:   req.Paths,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to delete file: %w", err)
+		return nil, fmt.Errorf("failed to look up files: %w", err)
+	}
+
+	byPath := make(map[string]db.LookupFilesRow, len(rows))
+	for _, row := range rows {
+		byPath[row.FilePath] = row
+	}
+
+	results := make([]domain.FileLookupResult, len(req.Paths))
+	for i, path := range req.Paths {
+		results[i].FilePath = path
+
+		row, ok := byPath[path]
+		if !ok {
+			continue
+		}
+
+		results[i].Found = true
+		results[i].File = &domain.FileInfo{
+			ID:           pgconv.PgToUUID(row.ID),
+			WorkspaceID:  pgconv.PgToUUID(row.WorkspaceID),
+			FilePath:     row.FilePath,
+			ContentHash:  row.ContentHash,
+			SizeBytes:    row.SizeBytes,
+			MimeType:     pgconv.PgToString(row.MimeType),
+			LastModified: pgconv.PgToTime(row.LastModified),
+			UpdatedAt:    pgconv.PgToTime(row.UpdatedAt),
+		}
+
+		if req.IncludeMetadata && row.Format.Valid {
+			metadata := &domain.FileMetadata{
+				FileID:     pgconv.PgToUUID(row.ID),
+				Format:     domain.FileFormat(row.Format.String),
+				WordCount:  int(pgconv.PgToInt32(row.WordCount)),
+				LastParsed: pgconv.PgToTime(row.LastParsed),
+			}
+			if len(row.Properties) > 0 {
+				json.Unmarshal(row.Properties, &metadata.Properties)
+			}
+			results[i].Metadata = metadata
+		}
+	}
+
+	return results, nil
+}
+
+func (s *FileService) DeleteFile(ctx context.Context, workspaceID uuid.UUID, filePath string, userID uuid.UUID) error {
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleEditor)
+	if err != nil {
+		return err
 	}
 
-	newUsage := pgconv.PgToInt64(workspace.StorageUsedBytes) - file.SizeBytes
-	err = qtx.UpdateWorkspaceStorageUsed(ctx, db.UpdateWorkspaceStorageUsedParams{
-		ID:               pgconv.UUIDToPg(workspaceID),
-		StorageUsedBytes: pgconv.Int64ToPg(newUsage),
+	if workspace.ArchivedAt.Valid {
+		return fmt.Errorf("workspace is archived")
+	}
+
+	unlock := s.paths.Lock(workspaceID.String() + "/" + filePath)
+	defer unlock()
+
+	// Dropping the file and its versions decrements blob_refs; the content
+	// itself is removed later by CollectUnreferencedBlobs.
+	var deleted *db.File
+	err = inTx(ctx, s.conn, s.queries, "delete_file", func(qtx *db.Queries) error {
+		deleted = nil
+		err := qtx.LockFilePath(ctx, db.LockFilePathParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			FilePath:    filePath,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to lock file: %w", err)
+		}
+
+		file, err := qtx.GetFile(ctx, db.GetFileParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			FilePath:    filePath,
+		})
+		if err != nil {
+			return fmt.Errorf("file not found: %w", err)
+		}
+
+		// Deleting the old path of a note just created elsewhere finishes
+		// a rename by a client that does not know about moves.
+		copied, err := findRecreatedNote(ctx, qtx, file)
+		if err != nil {
+			return err
+		}
+		if copied != nil {
+			return s.mergeRenamedNote(ctx, qtx, userID, file, *copied)
+		}
+
+		err = qtx.DeleteFile(ctx, db.DeleteFileParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			FilePath:    filePath,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete file: %w", err)
+		}
+
+		_, err = qtx.AdjustWorkspaceCounters(ctx, db.AdjustWorkspaceCountersParams{
+			ID:               pgconv.UUIDToPg(workspaceID),
+			FileCount:        -1,
+			StorageUsedBytes: pgconv.Int64ToPg(-file.SizeBytes),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update storage usage: %w", err)
+		}
+		deleted = &file
+		return recordEvent(ctx, qtx, workspaceID, domain.EventFileDeleted, filePath, &userID, map[string]any{
+			"size_bytes": file.SizeBytes,
+		})
 	})
 	if err != nil {
-		return fmt.Errorf("failed to update storage usage: %w", err)
+		return err
 	}
 
-	return tx.Commit(ctx)
+	// A merged rename moved the note rather than deleting it.
+	if deleted != nil {
+		s.events.Publish(ctx, events.FileDeleted{
+			WorkspaceID: workspaceID,
+			ActorID:     userID,
+			FilePath:    filePath,
+			SizeBytes:   deleted.SizeBytes,
+		})
+	}
+	return nil
+}
+
+// CollectUnreferencedBlobs deletes stored content that no file or version
+// has referred to for at least gracePeriod. Each blob is removed in its own
+// transaction holding the blob_refs row lock, so an upload that starts
+// referencing the hash again either waits for the delete or is skipped.
+func (s *FileService) CollectUnreferencedBlobs(ctx context.Context, gracePeriod time.Duration, limit int32) (int, error) {
+	hashes, err := s.queries.ListUnreferencedBlobs(ctx, db.ListUnreferencedBlobsParams{
+		UpdatedAt: pgconv.TimeToPg(time.Now().Add(-gracePeriod)),
+		Limit:     limit,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list unreferenced blobs: %w", err)
+	}
+
+	deleted := 0
+	for _, hash := range hashes {
+		ok, err := s.collectBlob(ctx, hash)
+		if err != nil {
+			s.log.WithContext(ctx).WithError(err).Warn("Failed to collect unreferenced blob", "content_hash", hash)
+			continue
+		}
+		if ok {
+			deleted++
+		}
+	}
+
+	if deleted > 0 {
+		s.log.Info("Collected unreferenced blobs", "deleted", deleted)
+	}
+	return deleted, nil
+}
+
+func (s *FileService) collectBlob(ctx context.Context, hash string) (bool, error) {
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	// No row means the hash was referenced again or another collector
+	// holds it.
+	if _, err := qtx.LockUnreferencedBlob(ctx, hash); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if err := s.blobs.Delete(ctx, hash); err != nil {
+		return false, err
+	}
+	if err := qtx.DeleteBlobRef(ctx, hash); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
 }
 
 func (s *FileService) detectMimeType(filePath string, content []byte) string {
@@ -344,30 +1050,43 @@ func (s *FileService) detectMimeType(filePath string, content []byte) string {
 		if mimeType != "" {
 			return mimeType
 		}
+		if looksBinary(content) {
+			// Sniffing recognizes common images, archives and PDFs and
+			// falls back to application/octet-stream.
+			return http.DetectContentType(content)
+		}
 		return "text/plain"
 	}
 }
 
-func (s *FileService) parseFileMetadata(ctx context.Context, file db.File) {
-	format := s.DetectFileFormat(file.FilePath, file.Content)
-
-	// TODO: Implement actual parsing logic for different formats
-	var parsedBlocks []byte
-	var properties []byte
-	wordCount := len(strings.Fields(string(file.Content)))
+// binarySniffLen is how much of a file looksBinary reads.
+const binarySniffLen = 8000
 
-	err := s.queries.UpsertFileMetadata(ctx, db.UpsertFileMetadataParams{
-		FileID:       file.ID,
-		Format:       string(format),
-		ParsedBlocks: parsedBlocks,
-		Properties:   properties,
-		WordCount:    pgconv.Int32ToPg(int32(wordCount)),
-	})
+// looksBinary reports whether content is not text: it has a NUL byte or
+// is not UTF-8 near its start.
+func looksBinary(content []byte) bool {
+	sample := content[:min(len(content), binarySniffLen)]
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+	if len(sample) < len(content) {
+		// The sample may end inside a multi-byte rune.
+		for i := 0; i < utf8.UTFMax-1 && len(sample) > 0; i++ {
+			if r, _ := utf8.DecodeLastRune(sample); r != utf8.RuneError {
+				break
+			}
+			sample = sample[:len(sample)-1]
+		}
+	}
+	return !utf8.Valid(sample)
+}
 
-	if err != nil {
-		// TODO: log this error properly
-		fmt.Printf("Failed to store file metadata for %s: %v\n", file.FilePath, err)
+// parseFileMetadata queues a written file for metadata parsing.
+func (s *FileService) parseFileMetadata(ctx context.Context, file db.File, content []byte) {
+	if s.metadata == nil || s.disableAsyncMetadataParsing {
+		return
 	}
+	s.metadata.enqueue(ctx, file, content)
 }
 
 func (s *FileService) DetectFileFormat(filePath string, content []byte) domain.FileFormat {
@@ -378,6 +1097,9 @@ func (s *FileService) DetectFileFormat(filePath string, content []byte) domain.F
 	case ".org":
 		return domain.FormatOrgMode
 	default:
+		if looksBinary(content) {
+			return domain.FormatBinary
+		}
 		return domain.FormatPlainText
 	}
 }
@@ -1,12 +1,20 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"image"
+	"image/png"
 	"testing"
 	"time"
 
 	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/events"
+	"github.com/duckonomy/noture/internal/storage"
 	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -35,6 +43,69 @@ func TestFileService_UploadFile(t *testing.T) {
 		assert.Equal(t, req.FilePath, fileInfo.FilePath)
 		assert.Equal(t, int64(len(content)), fileInfo.SizeBytes)
 		assert.Equal(t, "text/markdown", fileInfo.MimeType)
+		assert.True(t, fileInfo.Created)
+		assert.Equal(t, int32(1), fileInfo.VersionNumber)
+		assert.False(t, fileInfo.Deduplicated)
+	})
+
+	t.Run("updates report the new version and revision", func(t *testing.T) {
+		upload := func(path, content string) *domain.FileUploadResult {
+			result, err := service.UploadFile(ctx, domain.FileUploadRequest{
+				WorkspaceID:  testData.FreeWorkspaceID,
+				FilePath:     path,
+				Content:      []byte(content),
+				LastModified: time.Now(),
+			}, testData.FreeUserID)
+			require.NoError(t, err)
+			return result
+		}
+
+		first := upload("sync.md", "v1")
+		second := upload("sync.md", "v2")
+		assert.False(t, second.Created)
+		assert.False(t, second.Unchanged)
+		assert.Equal(t, int32(2), second.VersionNumber)
+		assert.Equal(t, first.Revision+1, second.Revision)
+
+		again := upload("sync.md", "v2")
+		assert.True(t, again.Unchanged)
+		assert.Equal(t, int32(2), again.VersionNumber)
+		assert.Equal(t, second.Revision, again.Revision, "unchanged uploads write nothing")
+
+		copied := upload("copy.md", "v1")
+		assert.True(t, copied.Created)
+		assert.True(t, copied.Deduplicated)
+	})
+
+	t.Run("enforces the tier's file limits", func(t *testing.T) {
+		limits := domain.TierFree.GetFileLimits()
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     "large.bin",
+			Content:      make([]byte, limits.MaxFileSizeBytes+1),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		assert.ErrorContains(t, err, "file too large")
+
+		_, err = testDB.Conn().Exec(ctx,
+			"UPDATE workspaces SET file_count = $2 WHERE id = $1",
+			testData.FreeWorkspaceID, limits.MaxFiles)
+		require.NoError(t, err)
+		_, err = service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     "one-too-many.md",
+			Content:      []byte("x"),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		assert.ErrorContains(t, err, "file limit reached")
+
+		_, err = service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     "sync.md",
+			Content:      []byte("v3"),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		assert.NoError(t, err, "existing files may still change")
 	})
 }
 
@@ -72,6 +143,170 @@ func TestFileService_ListFiles_Simple(t *testing.T) {
 	})
 }
 
+func TestFileService_ListFilesPage_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	for _, path := range []string{"a.md", "b.md", "c.md"} {
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      []byte("content of " + path),
+			LastModified: time.Now(),
+			ClientID:     "test-client",
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+
+	t.Run("first page returns cursor", func(t *testing.T) {
+		page, err := service.ListFilesPage(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.FileListOptions{Limit: 2})
+
+		require.NoError(t, err)
+		require.Len(t, page.Files, 2)
+		assert.Equal(t, "a.md", page.Files[0].FilePath)
+		assert.Equal(t, "b.md", page.NextCursor)
+	})
+
+	t.Run("last page has no cursor", func(t *testing.T) {
+		page, err := service.ListFilesPage(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.FileListOptions{Cursor: "b.md", Limit: 2})
+
+		require.NoError(t, err)
+		require.Len(t, page.Files, 1)
+		assert.Equal(t, "c.md", page.Files[0].FilePath)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("access denied for different user", func(t *testing.T) {
+		_, err := service.ListFilesPage(ctx, testData.FreeWorkspaceID, testData.PremiumUserID, domain.FileListOptions{Limit: 2})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "access denied")
+	})
+}
+
+func TestFileService_ListFilesPage_SortAndFilter_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	uploads := []struct {
+		path    string
+		content string
+	}{
+		{"journal/2026-01-01.org", "short"},
+		{"journal/2026-01-02.org", "a little longer"},
+		{"notes/ideas.md", "the longest note of them all"},
+		{"notes/index.html", "<p>hi</p>"},
+	}
+	for _, u := range uploads {
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     u.path,
+			Content:      []byte(u.content),
+			LastModified: time.Now(),
+			ClientID:     "test-client",
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+
+	list := func(opts domain.FileListOptions) []string {
+		var paths []string
+		for {
+			page, err := service.ListFilesPage(ctx, testData.FreeWorkspaceID, testData.FreeUserID, opts)
+			require.NoError(t, err)
+			for _, f := range page.Files {
+				paths = append(paths, f.FilePath)
+			}
+			if page.NextCursor == "" {
+				return paths
+			}
+			opts.Cursor = page.NextCursor
+		}
+	}
+
+	t.Run("path prefix", func(t *testing.T) {
+		paths := list(domain.FileListOptions{PathPrefix: "journal/", Limit: 1})
+		assert.Equal(t, []string{"journal/2026-01-01.org", "journal/2026-01-02.org"}, paths)
+	})
+
+	t.Run("newest first", func(t *testing.T) {
+		paths := list(domain.FileListOptions{Sort: domain.SortByUpdatedAt, Limit: 1})
+		assert.Equal(t, []string{"notes/index.html", "notes/ideas.md", "journal/2026-01-02.org", "journal/2026-01-01.org"}, paths)
+	})
+
+	t.Run("largest first", func(t *testing.T) {
+		paths := list(domain.FileListOptions{Sort: domain.SortBySize, Limit: 3})
+		assert.Equal(t, []string{"notes/ideas.md", "journal/2026-01-02.org", "notes/index.html", "journal/2026-01-01.org"}, paths)
+	})
+
+	t.Run("mime types", func(t *testing.T) {
+		assert.Equal(t, []string{"notes/ideas.md"}, list(domain.FileListOptions{MimeTypes: []string{"text/markdown"}}))
+		assert.Equal(t, []string{"notes/index.html"}, list(domain.FileListOptions{MimeTypes: []string{"text/html"}}))
+		assert.Len(t, list(domain.FileListOptions{MimeTypes: []string{"text/*"}}), 4)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		_, err := service.ListFilesPage(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.FileListOptions{Sort: domain.SortBySize, Cursor: "not-a-cursor"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid cursor")
+	})
+}
+
+func TestFileService_LookupFiles_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	for _, path := range []string{"notes/a.md", "notes/b.md"} {
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      []byte("content of " + path),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+
+	t.Run("results follow request order with found flags", func(t *testing.T) {
+		results, err := service.LookupFiles(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.FileLookupRequest{
+			Paths: []string{"notes/b.md", "missing.md", "notes/a.md"},
+		})
+
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		assert.True(t, results[0].Found)
+		assert.Equal(t, "notes/b.md", results[0].File.FilePath)
+		assert.False(t, results[1].Found)
+		assert.Nil(t, results[1].File)
+		assert.True(t, results[2].Found)
+		assert.Nil(t, results[2].Metadata)
+	})
+
+	t.Run("too many paths", func(t *testing.T) {
+		paths := make([]string, MaxFileLookupPaths+1)
+		_, err := service.LookupFiles(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.FileLookupRequest{Paths: paths})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "too many paths")
+	})
+
+	t.Run("access denied for different user", func(t *testing.T) {
+		_, err := service.LookupFiles(ctx, testData.FreeWorkspaceID, testData.PremiumUserID, domain.FileLookupRequest{
+			Paths: []string{"notes/a.md"},
+		})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "access denied")
+	})
+}
+
 func TestFileService_GetFile_Simple(t *testing.T) {
 	testDB := testutil.NewIsolatedTestDB(t)
 	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
@@ -243,6 +478,720 @@ func TestFileService_DeleteFile_Simple(t *testing.T) {
 	})
 }
 
+func TestFileService_PublishesEvents_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	bus := events.NewBus()
+	var published []events.Event
+	bus.SubscribeAll(func(ctx context.Context, e events.Event) {
+		published = append(published, e)
+	})
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn()).WithEvents(bus)
+	ctx := context.Background()
+
+	upload := func(content string) {
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     "events.md",
+			Content:      []byte(content),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+	upload("first")
+	upload("first")
+	upload("second!")
+	require.NoError(t, service.DeleteFile(ctx, testData.FreeWorkspaceID, "events.md", testData.FreeUserID))
+
+	require.Len(t, published, 3, "unchanged uploads are not published")
+	created := published[0].(events.FileUploaded)
+	assert.True(t, created.Created)
+	assert.Equal(t, testData.FreeWorkspaceID, created.WorkspaceID)
+	assert.Equal(t, testData.FreeUserID, created.ActorID)
+	assert.Equal(t, int32(1), created.Version)
+	assert.Positive(t, created.StorageLimitBytes)
+
+	updated := published[1].(events.FileUploaded)
+	assert.False(t, updated.Created)
+	assert.Equal(t, int64(7), updated.SizeBytes)
+	assert.Equal(t, created.StorageUsedBytes+2, updated.StorageUsedBytes)
+
+	assert.Equal(t, events.FileDeleted{
+		WorkspaceID: testData.FreeWorkspaceID,
+		ActorID:     testData.FreeUserID,
+		FilePath:    "events.md",
+		SizeBytes:   7,
+	}, published[2])
+}
+
+func TestFileService_ApplySaveSet_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	upload := func(path, content string) *domain.FileUploadResult {
+		result, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      []byte(content),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+		return result
+	}
+	note := upload("ideas.md", "# Ideas")
+	index := upload("index.md", "[[ideas]]")
+	empty := ""
+
+	t.Run("conflicts are reported together and nothing is applied", func(t *testing.T) {
+		stale := storage.Hash([]byte("older"))
+		_, err := service.ApplySaveSet(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.SaveSetRequest{Operations: []domain.SaveSetOperation{
+			{Op: domain.SaveOpMove, FilePath: "ideas.md", NewPath: "index.md"},
+			{Op: domain.SaveOpWrite, FilePath: "todo.md", Content: []byte("- [ ] x"), BaseHash: &stale},
+			{Op: domain.SaveOpDelete, FilePath: "missing.md"},
+		}})
+
+		var conflict *domain.SaveSetConflictError
+		require.ErrorAs(t, err, &conflict)
+		var reasons []string
+		for _, c := range conflict.Conflicts {
+			reasons = append(reasons, c.Reason)
+		}
+		assert.Equal(t, []string{"target exists", "file changed", "file not found"}, reasons)
+
+		_, err = service.GetFile(ctx, testData.FreeWorkspaceID, "ideas.md", testData.FreeUserID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("rename with backlink rewrite shares one revision", func(t *testing.T) {
+		result, err := service.ApplySaveSet(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.SaveSetRequest{Operations: []domain.SaveSetOperation{
+			{Op: domain.SaveOpMove, FilePath: "ideas.md", NewPath: "projects/ideas.md", BaseHash: &note.ContentHash},
+			{Op: domain.SaveOpWrite, FilePath: "index.md", Content: []byte("[[projects/ideas]]"), BaseHash: &index.ContentHash},
+			{Op: domain.SaveOpWrite, FilePath: "new.md", Content: []byte("new"), BaseHash: &empty},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, index.Revision+1, result.Revision)
+		assert.Equal(t, "projects/ideas.md", result.Files[0].FilePath)
+		assert.Equal(t, int32(2), result.Files[1].VersionNumber)
+		assert.True(t, result.Files[2].Created)
+
+		moved, err := service.GetFile(ctx, testData.FreeWorkspaceID, "projects/ideas.md", testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, note.ID, moved.ID)
+
+		files, err := service.ListFiles(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Len(t, files, 3)
+	})
+
+	t.Run("viewers cannot apply save-sets", func(t *testing.T) {
+		_, err := service.ApplySaveSet(ctx, testData.FreeWorkspaceID, testData.PremiumUserID, domain.SaveSetRequest{Operations: []domain.SaveSetOperation{
+			{Op: domain.SaveOpDelete, FilePath: "new.md"},
+		}})
+		assert.ErrorContains(t, err, "access denied")
+	})
+}
+
+func TestFileService_ApplySaveSet_RewriteLinks_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	upload := func(path, content string) *domain.FileUploadResult {
+		result, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      []byte(content),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+		return result
+	}
+	upload("ideas.md", "# Ideas")
+	upload("index.md", "See [[ideas#Later|ideas]] and [[other]].")
+	upload("notes/review.md", "[the ideas](../ideas.md#later)")
+	upload("plan.org", "* Plan\n[[file:ideas.md::*Later][ideas]]")
+	upload("draft.md", "[[ideas]]")
+
+	result, err := service.ApplySaveSet(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.SaveSetRequest{
+		RewriteLinks: true,
+		Operations: []domain.SaveSetOperation{
+			{Op: domain.SaveOpMove, FilePath: "ideas.md", NewPath: "projects/ideas.md"},
+			{Op: domain.SaveOpWrite, FilePath: "draft.md", Content: []byte("[[ideas]] draft")},
+		},
+	})
+	require.NoError(t, err)
+
+	var rewritten []string
+	for _, file := range result.Rewritten {
+		rewritten = append(rewritten, file.FilePath)
+		assert.Equal(t, domain.SaveOpWrite, file.Op)
+		assert.Equal(t, int32(2), file.VersionNumber)
+	}
+	assert.Equal(t, []string{"index.md", "notes/review.md", "plan.org"}, rewritten)
+
+	content := func(path string) string {
+		file, err := service.GetFileContent(ctx, testData.FreeWorkspaceID, path, testData.FreeUserID)
+		require.NoError(t, err)
+		return string(file.Content)
+	}
+	assert.Equal(t, "See [[projects/ideas#Later|ideas]] and [[other]].", content("index.md"))
+	assert.Equal(t, "[the ideas](../projects/ideas.md#later)", content("notes/review.md"))
+	assert.Equal(t, "* Plan\n[[file:projects/ideas.md::*Later][ideas]]", content("plan.org"))
+	assert.Equal(t, "[[ideas]] draft", content("draft.md"), "notes in the save-set are left as sent")
+}
+
+func TestFileService_PatchFile_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	base, err := service.UploadFile(ctx, domain.FileUploadRequest{
+		WorkspaceID:  testData.FreeWorkspaceID,
+		FilePath:     "todo.md",
+		Content:      []byte("# Todo\n- [ ] one\n- [ ] two\n"),
+		LastModified: time.Now(),
+	}, testData.FreeUserID)
+	require.NoError(t, err)
+
+	patchReq := func(baseHash, diff string) domain.FilePatchRequest {
+		return domain.FilePatchRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     "todo.md",
+			BaseHash:     baseHash,
+			Patch:        []byte(diff),
+			LastModified: time.Now(),
+		}
+	}
+
+	t.Run("the diff is applied to the base", func(t *testing.T) {
+		result, err := service.PatchFile(ctx, patchReq(base.ContentHash, "@@ -2 +2 @@\n-- [ ] one\n+- [x] one\n"), testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), result.VersionNumber)
+
+		file, err := service.GetFileContent(ctx, testData.FreeWorkspaceID, "todo.md", testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, "# Todo\n- [x] one\n- [ ] two\n", string(file.Content))
+	})
+
+	t.Run("a stale base is refused", func(t *testing.T) {
+		_, err := service.PatchFile(ctx, patchReq(base.ContentHash, "@@ -3 +3 @@\n-- [ ] two\n+- [x] two\n"), testData.FreeUserID)
+		assert.EqualError(t, err, "file changed")
+	})
+
+	t.Run("a diff that does not match is invalid", func(t *testing.T) {
+		current, err := service.GetFile(ctx, testData.FreeWorkspaceID, "todo.md", testData.FreeUserID)
+		require.NoError(t, err)
+		_, err = service.PatchFile(ctx, patchReq(current.ContentHash, "@@ -3 +3 @@\n-- [ ] three\n+- [x] three\n"), testData.FreeUserID)
+		assert.ErrorContains(t, err, "invalid patch")
+	})
+
+	t.Run("viewers cannot patch", func(t *testing.T) {
+		current, err := service.GetFile(ctx, testData.FreeWorkspaceID, "todo.md", testData.FreeUserID)
+		require.NoError(t, err)
+		_, err = service.PatchFile(ctx, patchReq(current.ContentHash, "@@ -1 +1 @@\n-# Todo\n+# Done\n"), testData.PremiumUserID)
+		assert.ErrorContains(t, err, "access denied")
+	})
+}
+
+func TestFileService_RenderFile_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+	workspaceID, userID := testData.FreeWorkspaceID, testData.FreeUserID
+
+	for path, content := range map[string]string{
+		"projects/plan.md":   "# Plan\n\nSee [[tasks]], [[Inbox|the inbox]] and [[missing]].",
+		"projects/tasks.org": "* TODO Ship",
+		"Inbox.md":           "<script>alert(1)</script>",
+	} {
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  workspaceID,
+			FilePath:     path,
+			Content:      []byte(content),
+			LastModified: time.Now(),
+		}, userID)
+		require.NoError(t, err)
+	}
+
+	rendered, err := service.RenderFile(ctx, workspaceID, "projects/plan.md", userID, domain.RenderOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, domain.FormatMarkdown, rendered.Format)
+	assert.Contains(t, rendered.HTML, `<a href="/api/files/`+workspaceID.String()+`/projects/tasks.org/render" class="wikilink">tasks</a>`)
+	assert.Contains(t, rendered.HTML, `/Inbox.md/render" class="wikilink">the inbox</a>`)
+	assert.Contains(t, rendered.HTML, `<span class="wikilink-missing">missing</span>`)
+	assert.Equal(t, []string{"missing"}, rendered.Unresolved)
+
+	rendered, err = service.RenderFile(ctx, workspaceID, "Inbox.md", userID, domain.RenderOptions{LinkTemplate: "https://notes.example.com/{path}"})
+	require.NoError(t, err)
+	assert.Equal(t, "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n", rendered.HTML)
+
+	_, err = service.RenderFile(ctx, workspaceID, "Inbox.md", userID, domain.RenderOptions{LinkTemplate: "javascript:{path}"})
+	assert.ErrorContains(t, err, "invalid link_template")
+
+	_, err = service.RenderFile(ctx, workspaceID, "nope.md", userID, domain.RenderOptions{})
+	assert.ErrorContains(t, err, "file not found")
+}
+
+func TestFileService_RelatedNotes_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+	workspaceID, userID := testData.FreeWorkspaceID, testData.FreeUserID
+
+	for path, content := range map[string]string{
+		"plan.md":        "---\ntags: [work, q3]\n---\nSee [[roadmap]] and ![[chart.png]].",
+		"roadmap.org":    "#+FILETAGS: :work:\n* Goals",
+		"retro.md":       "Back to [[plan]]. Also [[roadmap]].",
+		"budget.md":      "Figures for #Q3 and #work.",
+		"chart.png":      "\x89PNG\r\n\x1a\n\x00\x00",
+		"unrelated.md":   "#home",
+		"notes/other.md": "[[roadmap]]",
+	} {
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  workspaceID,
+			FilePath:     path,
+			Content:      []byte(content),
+			LastModified: time.Now(),
+		}, userID)
+		require.NoError(t, err)
+	}
+
+	related, err := service.RelatedNotes(ctx, workspaceID, "plan.md", userID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.RelatedNote{
+		{FilePath: "roadmap.org", Score: 5, SharedTags: []string{"work"}, LinksTo: true},
+		{FilePath: "budget.md", Score: 4, SharedTags: []string{"q3", "work"}},
+		{FilePath: "retro.md", Score: 4, LinkedFrom: true, SharedLinks: 1},
+		{FilePath: "notes/other.md", Score: 1, SharedLinks: 1},
+	}, related)
+
+	related, err = service.RelatedNotes(ctx, workspaceID, "plan.md", userID, 1)
+	require.NoError(t, err)
+	require.Len(t, related, 1)
+	assert.Equal(t, "roadmap.org", related[0].FilePath)
+
+	_, err = service.RelatedNotes(ctx, workspaceID, "plan.md", userID, 51)
+	assert.ErrorContains(t, err, "invalid limit")
+	_, err = service.RelatedNotes(ctx, workspaceID, "nope.md", userID, 0)
+	assert.ErrorContains(t, err, "file not found")
+}
+
+func TestFileService_BrokenLinks_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+	workspaceID, userID := testData.FreeWorkspaceID, testData.FreeUserID
+
+	for path, content := range map[string]string{
+		"index.md":      "[[plan]] and [[gone]]\n[old](archive/old.md) and [site](https://example.com)",
+		"plan.md":       "# Plan\n\nSee ![[chart.png]].",
+		"notes/log.org": "[[file:../plan.md][plan]]\n[[file:missing.org]]",
+	} {
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  workspaceID,
+			FilePath:     path,
+			Content:      []byte(content),
+			LastModified: time.Now(),
+		}, userID)
+		require.NoError(t, err)
+	}
+
+	report, err := service.BrokenLinks(ctx, workspaceID, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.BrokenLink{
+		{FilePath: "index.md", Line: 1, Target: "gone"},
+		{FilePath: "index.md", Line: 2, Target: "archive/old.md"},
+		{FilePath: "notes/log.org", Line: 2, Target: "missing.org"},
+		{FilePath: "plan.md", Line: 3, Target: "chart.png"},
+	}, report.Links)
+	assert.Equal(t, 4, report.Count)
+	assert.False(t, report.Truncated)
+
+	_, err = service.BrokenLinks(ctx, uuid.New(), userID)
+	assert.Error(t, err)
+}
+
+func TestFileService_RecentFiles_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+	workspaceID, userID := testData.FreeWorkspaceID, testData.FreeUserID
+
+	for _, path := range []string{"a.md", "b.md", "c.md"} {
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  workspaceID,
+			FilePath:     path,
+			Content:      []byte(path),
+			LastModified: time.Now(),
+		}, userID)
+		require.NoError(t, err)
+	}
+	paths := func(files []domain.RecentFile) []string {
+		var paths []string
+		for _, file := range files {
+			paths = append(paths, file.FilePath)
+		}
+		return paths
+	}
+
+	modified, err := service.RecentFiles(ctx, workspaceID, userID, "", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c.md", "b.md"}, paths(modified))
+
+	for _, path := range []string{"a.md", "c.md", "a.md"} {
+		require.NoError(t, service.RecordFileOpen(ctx, workspaceID, userID, domain.FileOpenRequest{FilePath: path}))
+	}
+	_, err = service.ApplySaveSet(ctx, workspaceID, userID, domain.SaveSetRequest{Operations: []domain.SaveSetOperation{
+		{Op: domain.SaveOpMove, FilePath: "a.md", NewPath: "archive/a.md"},
+	}})
+	require.NoError(t, err)
+
+	opened, err := service.RecentFiles(ctx, workspaceID, userID, domain.RecentOpened, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"archive/a.md", "c.md"}, paths(opened))
+	assert.NotNil(t, opened[0].OpenedAt)
+
+	_, err = service.RecentFiles(ctx, workspaceID, testData.PremiumUserID, domain.RecentOpened, 0)
+	assert.ErrorContains(t, err, "access denied")
+
+	_, err = service.RecentFiles(ctx, workspaceID, userID, "size", 0)
+	assert.ErrorContains(t, err, "invalid by")
+	err = service.RecordFileOpen(ctx, workspaceID, userID, domain.FileOpenRequest{FilePath: "gone.md"})
+	assert.ErrorContains(t, err, "file not found")
+}
+
+func TestFileService_PinFile_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+	workspaceID, userID := testData.FreeWorkspaceID, testData.FreeUserID
+
+	for _, path := range []string{"a.md", "b.md"} {
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  workspaceID,
+			FilePath:     path,
+			Content:      []byte(path),
+			LastModified: time.Now(),
+		}, userID)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, service.PinFile(ctx, workspaceID, userID, "b.md"))
+	require.NoError(t, service.PinFile(ctx, workspaceID, userID, "a.md"))
+	require.NoError(t, service.PinFile(ctx, workspaceID, userID, "b.md"))
+	_, err := service.ApplySaveSet(ctx, workspaceID, userID, domain.SaveSetRequest{Operations: []domain.SaveSetOperation{
+		{Op: domain.SaveOpMove, FilePath: "a.md", NewPath: "archive/a.md"},
+	}})
+	require.NoError(t, err)
+
+	pinned, err := service.ListPinnedFiles(ctx, workspaceID, userID)
+	require.NoError(t, err)
+	require.Len(t, pinned, 2)
+	assert.Equal(t, "b.md", pinned[0].FilePath)
+	assert.Equal(t, "archive/a.md", pinned[1].FilePath)
+
+	require.NoError(t, service.UnpinFile(ctx, workspaceID, userID, "b.md"))
+	require.NoError(t, service.UnpinFile(ctx, workspaceID, userID, "b.md"))
+	pinned, err = service.ListPinnedFiles(ctx, workspaceID, userID)
+	require.NoError(t, err)
+	require.Len(t, pinned, 1)
+
+	err = service.PinFile(ctx, workspaceID, userID, "gone.md")
+	assert.ErrorContains(t, err, "file not found")
+	_, err = service.ListPinnedFiles(ctx, workspaceID, testData.PremiumUserID)
+	assert.ErrorContains(t, err, "access denied")
+}
+
+func TestFileService_PatchFileMeta_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+	workspaceID, userID := testData.FreeWorkspaceID, testData.FreeUserID
+
+	_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+		WorkspaceID:  workspaceID,
+		FilePath:     "a.md",
+		Content:      []byte("a"),
+		LastModified: time.Now(),
+	}, userID)
+	require.NoError(t, err)
+
+	info, err := service.GetFileWithMeta(ctx, workspaceID, "a.md", userID)
+	require.NoError(t, err)
+	assert.Empty(t, info.Meta)
+
+	info, err = service.PatchFileMeta(ctx, workspaceID, "a.md", userID, domain.FileMeta{
+		"color":  json.RawMessage(`"red"`),
+		"cursor": json.RawMessage(`{"line": 3}`),
+	})
+	require.NoError(t, err)
+	assert.Len(t, info.Meta, 2)
+
+	_, err = service.PatchFileMeta(ctx, workspaceID, "a.md", userID, domain.FileMeta{"color": json.RawMessage(`null`)})
+	require.NoError(t, err)
+	_, err = service.ApplySaveSet(ctx, workspaceID, userID, domain.SaveSetRequest{Operations: []domain.SaveSetOperation{
+		{Op: domain.SaveOpMove, FilePath: "a.md", NewPath: "archive/a.md"},
+	}})
+	require.NoError(t, err)
+
+	info, err = service.GetFileWithMeta(ctx, workspaceID, "archive/a.md", userID)
+	require.NoError(t, err)
+	require.Len(t, info.Meta, 1)
+	assert.JSONEq(t, `{"line": 3}`, string(info.Meta["cursor"]))
+
+	_, err = service.PatchFileMeta(ctx, workspaceID, "archive/a.md", userID, domain.FileMeta{"": json.RawMessage(`1`)})
+	assert.ErrorContains(t, err, "invalid meta key")
+	_, err = service.PatchFileMeta(ctx, workspaceID, "gone.md", userID, domain.FileMeta{"color": json.RawMessage(`"red"`)})
+	assert.ErrorContains(t, err, "file not found")
+	_, err = service.PatchFileMeta(ctx, workspaceID, "archive/a.md", testData.PremiumUserID, domain.FileMeta{"color": json.RawMessage(`"red"`)})
+	assert.ErrorContains(t, err, "access denied")
+}
+
+func TestFileService_RebuildMetadata_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+	workspaceID, userID := testData.FreeWorkspaceID, testData.FreeUserID
+
+	var index *domain.FileUploadResult
+	for _, path := range []string{"plan.md", "index.md"} {
+		result, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  workspaceID,
+			FilePath:     path,
+			Content:      []byte("[[gone]]"),
+			LastModified: time.Now(),
+		}, userID)
+		require.NoError(t, err)
+		index = result
+	}
+	// As if index.md was stored before links were indexed.
+	require.NoError(t, testDB.Queries().DeleteFileLinks(ctx, pgconv.UUIDToPg(index.ID)))
+
+	// Metadata is parsed asynchronously on upload, which the test service
+	// skips, so both files are waiting for the backfill.
+	status, err := service.MetadataStatus(ctx, workspaceID, userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), status.TotalFiles)
+	assert.False(t, status.Ready)
+
+	reparsed, err := service.BackfillMetadata(ctx, MetadataBackfillBatchSize)
+	require.NoError(t, err)
+	assert.Equal(t, 2, reparsed)
+
+	status, err = service.MetadataStatus(ctx, workspaceID, userID)
+	require.NoError(t, err)
+	assert.True(t, status.Ready)
+	report, err := service.BrokenLinks(ctx, workspaceID, userID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Count, "the reparse indexes links again")
+
+	status, err = service.RebuildMetadata(ctx, workspaceID, userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), status.ParsedFiles)
+	reparsed, err = service.BackfillMetadata(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reparsed)
+	status, err = service.MetadataStatus(ctx, workspaceID, userID)
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, status.Progress)
+
+	_, err = service.RebuildMetadata(ctx, workspaceID, testData.PremiumUserID)
+	assert.ErrorContains(t, err, "access denied")
+}
+
+func TestFileService_Attachments_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+	workspaceID, userID := testData.FreeWorkspaceID, testData.FreeUserID
+
+	var img bytes.Buffer
+	require.NoError(t, png.Encode(&img, image.NewGray(image.Rect(0, 0, 600, 300))))
+	upload := func(path string, content []byte) {
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  workspaceID,
+			FilePath:     path,
+			Content:      content,
+			LastModified: time.Now(),
+		}, userID)
+		require.NoError(t, err)
+	}
+	upload("images/diagram", img.Bytes())
+	upload("notes.md", []byte("# Notes"))
+
+	file, err := service.GetFile(ctx, workspaceID, "images/diagram", userID)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", file.MimeType, "content is sniffed when the extension says nothing")
+
+	thumb, err := service.Thumbnail(ctx, file, 128)
+	require.NoError(t, err)
+	config, _, err := image.DecodeConfig(bytes.NewReader(thumb.Content))
+	require.NoError(t, err)
+	assert.Equal(t, 128, config.Width)
+	assert.Equal(t, 64, config.Height)
+
+	_, err = service.Thumbnail(ctx, file, 4096)
+	assert.ErrorContains(t, err, "invalid size")
+
+	note, err := service.GetFile(ctx, workspaceID, "notes.md", userID)
+	require.NoError(t, err)
+	_, err = service.Thumbnail(ctx, note, 128)
+	assert.ErrorContains(t, err, "unsupported media type")
+
+	_, err = service.RenderFile(ctx, workspaceID, "images/diagram", userID, domain.RenderOptions{})
+	assert.ErrorContains(t, err, "unsupported media type")
+}
+
+func TestFileService_NoteIDs_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	upload := func(path, content, noteID string) *domain.FileUploadResult {
+		result, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      []byte(content),
+			LastModified: time.Now(),
+			NoteID:       noteID,
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+		return result
+	}
+
+	original := upload("ideas.md", "---\nid: ideas-1\n---\n# Ideas", "")
+
+	found, err := service.GetFileByNoteID(ctx, testData.FreeWorkspaceID, testData.FreeUserID, "ideas-1")
+	require.NoError(t, err)
+	assert.Equal(t, original.ID, found.ID)
+
+	t.Run("create then delete is a rename", func(t *testing.T) {
+		upload("projects/ideas.md", "---\nid: ideas-1\n---\n# Ideas, moved", "")
+		require.NoError(t, service.DeleteFile(ctx, testData.FreeWorkspaceID, "ideas.md", testData.FreeUserID))
+
+		moved, err := service.GetFile(ctx, testData.FreeWorkspaceID, "projects/ideas.md", testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, original.ID, moved.ID)
+		assert.Equal(t, storage.Hash([]byte("---\nid: ideas-1\n---\n# Ideas, moved")), moved.ContentHash)
+
+		next := upload("projects/ideas.md", "---\nid: ideas-1\n---\n# Ideas, edited", "")
+		assert.Equal(t, int32(3), next.VersionNumber)
+
+		files, err := service.ListFiles(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Len(t, files, 1)
+	})
+
+	t.Run("client IDs are validated", func(t *testing.T) {
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID: testData.FreeWorkspaceID,
+			FilePath:    "bad.md",
+			Content:     []byte("x"),
+			NoteID:      "two words",
+		}, testData.FreeUserID)
+		assert.ErrorContains(t, err, "invalid note_id")
+	})
+
+	t.Run("unknown IDs are not found", func(t *testing.T) {
+		_, err := service.GetFileByNoteID(ctx, testData.FreeWorkspaceID, testData.FreeUserID, "nope")
+		assert.EqualError(t, err, "note not found")
+	})
+}
+
+func TestFileService_BlobStorage_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	blobs := storage.NewMemoryBackend()
+	service := NewFileService(testDB.Queries(), testDB.Conn(), blobs)
+	service.disableAsyncMetadataParsing = true
+	ctx := context.Background()
+
+	upload := func(path string, content string) {
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      []byte(content),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+
+	t.Run("content is read from the backend", func(t *testing.T) {
+		upload("a.md", "same content")
+
+		file, err := service.GetFileContent(ctx, testData.FreeWorkspaceID, "a.md", testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("same content"), file.Content)
+		assert.Equal(t, 1, blobs.Len())
+	})
+
+	t.Run("identical content is stored once", func(t *testing.T) {
+		upload("b.md", "same content")
+		assert.Equal(t, 1, blobs.Len())
+	})
+
+	t.Run("versions keep replaced content referenced", func(t *testing.T) {
+		upload("c.md", "first draft")
+		upload("c.md", "second draft")
+		assert.Equal(t, 3, blobs.Len())
+
+		deleted, err := service.CollectUnreferencedBlobs(ctx, 0, 100)
+		require.NoError(t, err)
+		assert.Equal(t, 0, deleted)
+	})
+
+	t.Run("blob is kept while another file refers to it", func(t *testing.T) {
+		require.NoError(t, service.DeleteFile(ctx, testData.FreeWorkspaceID, "a.md", testData.FreeUserID))
+		deleted, err := service.CollectUnreferencedBlobs(ctx, 0, 100)
+		require.NoError(t, err)
+		assert.Equal(t, 0, deleted)
+
+		require.NoError(t, service.DeleteFile(ctx, testData.FreeWorkspaceID, "b.md", testData.FreeUserID))
+		assert.Equal(t, 3, blobs.Len(), "deletion only drops the reference")
+
+		deleted, err = service.CollectUnreferencedBlobs(ctx, 0, 100)
+		require.NoError(t, err)
+		assert.Equal(t, 1, deleted)
+		assert.Equal(t, 2, blobs.Len())
+	})
+
+	t.Run("grace period protects recently released blobs", func(t *testing.T) {
+		require.NoError(t, service.DeleteFile(ctx, testData.FreeWorkspaceID, "c.md", testData.FreeUserID))
+
+		deleted, err := service.CollectUnreferencedBlobs(ctx, time.Hour, 100)
+		require.NoError(t, err)
+		assert.Equal(t, 0, deleted)
+		assert.Equal(t, 2, blobs.Len())
+	})
+}
+
 func TestFileService_DetectFileFormat_Simple(t *testing.T) {
 	testDB := testutil.NewIsolatedTestDB(t)
 	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
@@ -295,6 +1244,18 @@ func TestFileService_DetectFileFormat_Simple(t *testing.T) {
 			content:  []byte("# Uppercase extension"),
 			expected: domain.FormatMarkdown,
 		},
+		{
+			name:     "image attachment",
+			filePath: "images/photo.png",
+			content:  []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"),
+			expected: domain.FormatBinary,
+		},
+		{
+			name:     "binary without extension",
+			filePath: "blob",
+			content:  []byte{0xff, 0xfe, 0x01, 0x02},
+			expected: domain.FormatBinary,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -304,3 +1265,186 @@ func TestFileService_DetectFileFormat_Simple(t *testing.T) {
 		})
 	}
 }
+
+func TestFileService_Folders_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	for _, path := range []string{"journal/2026/01.org", "journal/2026/02.org", "journal/index.org", "readme.md"} {
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      []byte("content of " + path),
+			LastModified: time.Now(),
+			ClientID:     "test-client",
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+
+	t.Run("tree nests folders", func(t *testing.T) {
+		tree, err := service.GetFileTree(ctx, testData.FreeWorkspaceID, testData.FreeUserID, "")
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), tree.FileCount)
+		require.Len(t, tree.Children, 2)
+		assert.Equal(t, "journal/", tree.Children[0].Path)
+		assert.Equal(t, int64(3), tree.Children[0].FileCount)
+		assert.Equal(t, "readme.md", tree.Children[1].Path)
+	})
+
+	t.Run("subtree of a folder", func(t *testing.T) {
+		tree, err := service.GetFileTree(ctx, testData.FreeWorkspaceID, testData.FreeUserID, "journal/2026")
+
+		require.NoError(t, err)
+		assert.Equal(t, "journal/2026/", tree.Path)
+		assert.Len(t, tree.Children, 2)
+	})
+
+	t.Run("delete folder removes everything below it", func(t *testing.T) {
+		before, err := service.GetFileTree(ctx, testData.FreeWorkspaceID, testData.FreeUserID, "")
+		require.NoError(t, err)
+
+		result, err := service.DeleteFolder(ctx, testData.FreeWorkspaceID, "/journal/", testData.FreeUserID)
+
+		require.NoError(t, err)
+		assert.Equal(t, "journal/", result.Folder)
+		assert.Equal(t, int64(3), result.DeletedFiles)
+
+		after, err := service.GetFileTree(ctx, testData.FreeWorkspaceID, testData.FreeUserID, "")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), after.FileCount)
+		assert.Equal(t, before.SizeBytes-result.FreedBytes, after.SizeBytes)
+
+		_, err = service.GetFile(ctx, testData.FreeWorkspaceID, "readme.md", testData.FreeUserID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("delete missing folder", func(t *testing.T) {
+		_, err := service.DeleteFolder(ctx, testData.FreeWorkspaceID, "journal", testData.FreeUserID)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "folder not found")
+	})
+
+	t.Run("workspace root cannot be deleted", func(t *testing.T) {
+		_, err := service.DeleteFolder(ctx, testData.FreeWorkspaceID, "/", testData.FreeUserID)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid folder")
+	})
+}
+
+func TestFileService_ListSyncOperations_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	for _, upload := range []struct{ path, client string }{
+		{"a.md", "laptop"},
+		{"b.md", "phone"},
+		{"c.md", "laptop"},
+	} {
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     upload.path,
+			Content:      []byte("content of " + upload.path),
+			LastModified: time.Now(),
+			ClientID:     upload.client,
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+
+	t.Run("filter by client", func(t *testing.T) {
+		page, err := service.ListSyncOperations(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.SyncOperationFilter{
+			ClientID: "laptop",
+			Status:   domain.SyncStatusSuccess,
+		})
+
+		require.NoError(t, err)
+		require.Len(t, page.Operations, 2)
+		assert.Equal(t, "c.md", page.Operations[0].FilePath, "newest first")
+		assert.Equal(t, "a.md", page.Operations[1].FilePath)
+		assert.Equal(t, domain.SyncOpUpload, page.Operations[0].OperationType)
+	})
+
+	t.Run("pages with a cursor", func(t *testing.T) {
+		var paths []string
+		filter := domain.SyncOperationFilter{Limit: 2}
+		for {
+			page, err := service.ListSyncOperations(ctx, testData.FreeWorkspaceID, testData.FreeUserID, filter)
+			require.NoError(t, err)
+			for _, op := range page.Operations {
+				paths = append(paths, op.FilePath)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			filter.Cursor = page.NextCursor
+		}
+		assert.Equal(t, []string{"c.md", "b.md", "a.md"}, paths)
+	})
+
+	t.Run("date range excludes older operations", func(t *testing.T) {
+		since := time.Now().Add(time.Hour)
+		page, err := service.ListSyncOperations(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.SyncOperationFilter{Since: &since})
+
+		require.NoError(t, err)
+		assert.Empty(t, page.Operations)
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		_, err := service.ListSyncOperations(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.SyncOperationFilter{Status: "done"})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid status")
+	})
+
+	t.Run("access denied for different user", func(t *testing.T) {
+		_, err := service.ListSyncOperations(ctx, testData.FreeWorkspaceID, testData.PremiumUserID, domain.SyncOperationFilter{})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "access denied")
+	})
+}
+
+func TestFileService_Manifest_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	for _, path := range []string{"journal/01.org", "journal/02.org", "readme.md"} {
+		_, err := service.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      []byte("content of " + path),
+			LastModified: time.Now(),
+			ClientID:     "test-client",
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+
+	manifest, err := service.GetManifest(ctx, testData.FreeWorkspaceID, testData.FreeUserID, "", false)
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 3)
+	assert.Equal(t, "journal/01.org", manifest.Files[0].Path)
+	assert.Equal(t, int64(len("content of journal/01.org")), manifest.Files[0].Size)
+	assert.Empty(t, manifest.Children)
+
+	digests, err := service.GetManifest(ctx, testData.FreeWorkspaceID, testData.FreeUserID, "", true)
+	require.NoError(t, err)
+	assert.Equal(t, manifest.Digest, digests.Digest)
+	assert.Empty(t, digests.Files)
+	require.Len(t, digests.Children, 2)
+
+	journal, err := service.GetManifest(ctx, testData.FreeWorkspaceID, testData.FreeUserID, "journal", false)
+	require.NoError(t, err)
+	assert.Len(t, journal.Files, 2)
+	assert.Equal(t, digests.Children[0].Digest, journal.Digest)
+}
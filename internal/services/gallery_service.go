@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/gallery"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// GalleryCloneOperation is the operation kind that copies a template's
+// files into a new workspace.
+const GalleryCloneOperation = "gallery_clone"
+
+// GalleryService creates workspaces from the starter templates of the
+// gallery, or as copies of existing workspaces.
+type GalleryService struct {
+	gallery    *gallery.Gallery
+	workspaces *WorkspaceService
+	files      *FileService
+	operations *OperationService
+	log        *logger.Logger
+}
+
+// NewGalleryService registers the clone operations with operations, so the
+// worker's service must be built with this constructor too.
+func NewGalleryService(templates *gallery.Gallery, workspaces *WorkspaceService, files *FileService, operations *OperationService) *GalleryService {
+	s := &GalleryService{
+		gallery:    templates,
+		workspaces: workspaces,
+		files:      files,
+		operations: operations,
+		log:        logger.New(),
+	}
+	operations.Register(GalleryCloneOperation, s.runClone)
+	operations.Register(WorkspaceCloneOperation, s.runWorkspaceClone)
+	return s
+}
+
+func (s *GalleryService) ListTemplates() []domain.GalleryTemplate {
+	return s.gallery.List()
+}
+
+type galleryCloneParams struct {
+	TemplateID string `json:"template_id"`
+}
+
+// CreateWorkspace creates a workspace named after the template unless req
+// names it, and queues an operation copying the template's files into it.
+// The workspace counts against the user's limits like any other.
+func (s *GalleryService) CreateWorkspace(ctx context.Context, templateID string, req domain.CreateWorkspaceRequest, userID uuid.UUID, userTier domain.UserTier) (*domain.Workspace, *domain.Operation, error) {
+	tmpl, err := s.gallery.Get(templateID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.Name = strings.TrimSpace(req.Name); req.Name == "" {
+		req.Name = tmpl.Name
+	}
+
+	workspace, err := s.workspaces.CreateWorkspace(ctx, req, userID, userTier)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	op, err := s.operations.Enqueue(ctx, GalleryCloneOperation, userID, &workspace.ID, galleryCloneParams{TemplateID: tmpl.ID}, int32(len(tmpl.Files)))
+	if err != nil {
+		if cleanupErr := s.workspaces.DeleteWorkspace(ctx, workspace.ID, userID); cleanupErr != nil {
+			s.log.WithContext(ctx).WithError(cleanupErr).Error("Failed to remove workspace without clone operation", "workspace_id", workspace.ID)
+		}
+		return nil, nil, err
+	}
+	return workspace, op, nil
+}
+
+// runClone copies the template files one per step, in template order. If a
+// file cannot be copied the half-filled workspace is deleted again.
+func (s *GalleryService) runClone(ctx context.Context, run *OperationRun) (any, error) {
+	var params galleryCloneParams
+	if err := json.Unmarshal(run.Params, &params); err != nil {
+		return nil, fmt.Errorf("invalid clone params: %w", err)
+	}
+	if run.WorkspaceID == nil {
+		return nil, fmt.Errorf("invalid clone operation: no workspace")
+	}
+	workspaceID := *run.WorkspaceID
+	log := s.log.WithContext(ctx).WithUser(run.UserID.String(), "").WithWorkspace(workspaceID.String(), "")
+
+	tmpl, err := s.gallery.Get(params.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	total := int32(len(tmpl.Files))
+	for i := run.StepsDone; i < total; i++ {
+		file := tmpl.Files[i]
+		_, err := s.files.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  workspaceID,
+			FilePath:     file.Path,
+			Content:      file.Content,
+			LastModified: now,
+		}, run.UserID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.WithError(err).Error("Failed to copy template file", "template", tmpl.ID, "file_path", file.Path)
+			if cleanupErr := s.workspaces.DeleteWorkspace(ctx, workspaceID, run.UserID); cleanupErr != nil {
+				log.WithError(cleanupErr).Error("Failed to remove partially created workspace")
+			}
+			return nil, fmt.Errorf("failed to copy template file %s: %w", file.Path, err)
+		}
+		if err := run.Checkpoint(ctx, nil, i+1, total); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Info("Workspace created from template", "template", tmpl.ID, "files", total)
+	return map[string]any{"workspace_id": workspaceID, "files": total}, nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/gallery"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGalleryService_CreateWorkspace_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	blobs := storage.NewPostgresBackend(testDB.Queries())
+	workspaces := NewWorkspaceService(testDB.Queries(), blobs)
+	files := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	templates := gallery.Builtin()
+	operations := NewOperationService(testDB.Queries(), testDB.Conn())
+	service := NewGalleryService(templates, workspaces, files, operations)
+	ctx := context.Background()
+
+	t.Run("clones every template file", func(t *testing.T) {
+		workspace, op, err := service.CreateWorkspace(ctx, "pkm-starter", domain.CreateWorkspaceRequest{}, testData.PremiumUserID, domain.TierPremium)
+		require.NoError(t, err)
+		assert.Equal(t, "PKM Starter", workspace.Name)
+		assert.Equal(t, domain.OperationPending, op.Status)
+
+		tmpl, err := templates.Get("pkm-starter")
+		require.NoError(t, err)
+		assert.Equal(t, int32(len(tmpl.Files)), op.StepsTotal)
+
+		_, err = operations.RunPending(ctx)
+		require.NoError(t, err)
+		op, err = operations.GetOperation(ctx, op.ID, testData.PremiumUserID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.OperationSucceeded, op.Status)
+		assert.Equal(t, op.StepsTotal, op.StepsDone)
+
+		for _, file := range tmpl.Files {
+			stored, err := files.GetFileContent(ctx, workspace.ID, file.Path, testData.PremiumUserID)
+			require.NoError(t, err, file.Path)
+			assert.Equal(t, file.Content, stored.Content)
+		}
+	})
+
+	t.Run("request name overrides the template's", func(t *testing.T) {
+		workspace, _, err := service.CreateWorkspace(ctx, "org-gtd", domain.CreateWorkspaceRequest{Name: "My GTD"}, testData.PremiumUserID, domain.TierPremium)
+		require.NoError(t, err)
+		assert.Equal(t, "My GTD", workspace.Name)
+	})
+
+	t.Run("unknown template", func(t *testing.T) {
+		_, _, err := service.CreateWorkspace(ctx, "missing", domain.CreateWorkspaceRequest{}, testData.PremiumUserID, domain.TierPremium)
+		assert.EqualError(t, err, "template not found")
+	})
+
+	t.Run("workspace limits apply", func(t *testing.T) {
+		_, _, err := service.CreateWorkspace(ctx, "pkm-starter", domain.CreateWorkspaceRequest{}, testData.FreeUserID, domain.TierFree)
+		assert.ErrorContains(t, err, "workspace limit reached")
+	})
+}
+
+func TestGalleryService_CloneWorkspace_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	blobs := storage.NewPostgresBackend(testDB.Queries())
+	workspaces := NewWorkspaceService(testDB.Queries(), blobs)
+	files := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	operations := NewOperationService(testDB.Queries(), testDB.Conn())
+	service := NewGalleryService(gallery.Builtin(), workspaces, files, operations)
+	ctx := context.Background()
+
+	for _, content := range []string{"# Ideas", "# Ideas\n- one"} {
+		_, err := files.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     "ideas.md",
+			Content:      []byte(content),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+	_, err := files.UploadFile(ctx, domain.FileUploadRequest{
+		WorkspaceID:  testData.FreeWorkspaceID,
+		FilePath:     "projects/todo.md",
+		Content:      []byte("- [ ] clone"),
+		LastModified: time.Now(),
+	}, testData.FreeUserID)
+	require.NoError(t, err)
+
+	clone := func(req domain.CloneWorkspaceRequest) *domain.Workspace {
+		workspace, op, err := service.CloneWorkspace(ctx, testData.FreeWorkspaceID, req, testData.PremiumUserID, domain.TierPremium)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), op.StepsTotal)
+
+		_, err = operations.RunPending(ctx)
+		require.NoError(t, err)
+		op, err = operations.GetOperation(ctx, op.ID, testData.PremiumUserID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.OperationSucceeded, op.Status)
+		return workspace
+	}
+
+	t.Run("members copy the current files", func(t *testing.T) {
+		workspace := clone(domain.CloneWorkspaceRequest{})
+		assert.Equal(t, testData.PremiumUserID, workspace.UserID)
+
+		stored, err := files.GetFileContent(ctx, workspace.ID, "ideas.md", testData.PremiumUserID)
+		require.NoError(t, err)
+		assert.Equal(t, "# Ideas\n- one", string(stored.Content))
+
+		copied, err := files.ListFiles(ctx, workspace.ID, testData.PremiumUserID)
+		require.NoError(t, err)
+		assert.Len(t, copied, 2)
+
+		file, err := testDB.Queries().GetFile(ctx, db.GetFileParams{WorkspaceID: pgconv.UUIDToPg(workspace.ID), FilePath: "ideas.md"})
+		require.NoError(t, err)
+		versions, err := testDB.Queries().GetFileVersions(ctx, db.GetFileVersionsParams{FileID: file.ID, Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+	})
+
+	t.Run("versions are copied on request", func(t *testing.T) {
+		workspace := clone(domain.CloneWorkspaceRequest{Name: "History", IncludeVersions: true})
+		assert.Equal(t, "History", workspace.Name)
+
+		file, err := testDB.Queries().GetFile(ctx, db.GetFileParams{WorkspaceID: pgconv.UUIDToPg(workspace.ID), FilePath: "ideas.md"})
+		require.NoError(t, err)
+		versions, err := testDB.Queries().GetFileVersions(ctx, db.GetFileVersionsParams{FileID: file.ID, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.Equal(t, storage.Hash([]byte("# Ideas")), versions[1].ContentHash)
+	})
+
+	t.Run("workspace limits apply", func(t *testing.T) {
+		_, _, err := service.CloneWorkspace(ctx, testData.FreeWorkspaceID, domain.CloneWorkspaceRequest{}, testData.FreeUserID, domain.TierFree)
+		assert.ErrorContains(t, err, "workspace limit reached")
+	})
+}
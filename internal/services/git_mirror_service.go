@@ -0,0 +1,466 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/gitmirror"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// GitMirrorBatchSize is how many mirrors one sync run claims.
+	GitMirrorBatchSize = 10
+	// gitMirrorLease is how long a claimed mirror is left to its sync
+	// before another run may take it over.
+	gitMirrorLease = 10 * time.Minute
+	// maxGitMirrorBackoff caps the delay between attempts to sync a
+	// failing mirror.
+	maxGitMirrorBackoff = time.Hour
+	// gitMirrorClientID marks the uploads of pulled files in the sync log.
+	gitMirrorClientID = "git-mirror"
+)
+
+// GitMirrorService mirrors workspaces to branches of Git repositories. A
+// sync fetches the branch, brings in the branch's changes when the mirror
+// pulls, then commits the workspace's files on top and pushes. Files that
+// only exist on the branch are left alone, so a repository can hold more
+// than the notes. Changes are found by comparing with the commit of the
+// last sync: a file changed on both sides since then keeps the
+// workspace's version, and the branch's stays in the Git history.
+type GitMirrorService struct {
+	queries *db.Queries
+	files   *FileService
+	dir     string
+	log     *logger.Logger
+	now     func() time.Time
+}
+
+// NewGitMirrorService keeps its local repositories under dir. Without a
+// dir, mirrors cannot be set up. Pulled files are written through files.
+func NewGitMirrorService(queries *db.Queries, files *FileService, dir string) *GitMirrorService {
+	return &GitMirrorService{
+		queries: queries,
+		files:   files,
+		dir:     dir,
+		log:     logger.New(),
+		now:     time.Now,
+	}
+}
+
+// GetMirror returns the workspace's mirror.
+func (s *GitMirrorService) GetMirror(ctx context.Context, workspaceID, userID uuid.UUID) (*domain.GitMirror, error) {
+	_, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	row, err := s.queries.GetGitMirror(ctx, pgconv.UUIDToPg(workspaceID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("git mirror not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git mirror: %w", err)
+	}
+	mirror := toDomainGitMirror(row)
+	return &mirror, nil
+}
+
+// ConfigureMirror sets up the workspace's mirror, or changes its remote,
+// branch or pulling. A new mirror gets a deploy key, which it keeps when
+// changed later; the returned mirror's public key is to be added to the
+// repository with write access. The mirror syncs soon after either.
+func (s *GitMirrorService) ConfigureMirror(ctx context.Context, workspaceID, userID uuid.UUID, req domain.ConfigureGitMirrorRequest) (*domain.GitMirror, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if s.dir == "" {
+		return nil, fmt.Errorf("git mirroring is not enabled on this server")
+	}
+
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	branch := req.Branch
+	if branch == "" {
+		branch = domain.DefaultGitMirrorBranch
+	}
+	// An existing mirror keeps its key, so this one is only stored for a
+	// new mirror.
+	privateKey, publicKey, err := gitmirror.GenerateKey("noture-" + workspaceID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create deploy key: %w", err)
+	}
+	row, err := s.queries.UpsertGitMirror(ctx, db.UpsertGitMirrorParams{
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		RemoteUrl:   req.RemoteURL,
+		Branch:      branch,
+		Pull:        req.Pull,
+		PrivateKey:  string(privateKey),
+		PublicKey:   publicKey,
+		CreatedBy:   pgconv.UUIDToPg(userID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save git mirror: %w", err)
+	}
+
+	mirror := toDomainGitMirror(row)
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), workspace.Name).Info("Configured git mirror",
+		"remote_url", mirror.RemoteURL,
+		"branch", mirror.Branch,
+		"pull", mirror.Pull)
+	return &mirror, nil
+}
+
+// DeleteMirror stops mirroring the workspace. The repository keeps what
+// was pushed.
+func (s *GitMirrorService) DeleteMirror(ctx context.Context, workspaceID, userID uuid.UUID) error {
+	_, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.queries.DeleteGitMirror(ctx, pgconv.UUIDToPg(workspaceID))
+	if err != nil {
+		return fmt.Errorf("failed to delete git mirror: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("git mirror not found")
+	}
+	if s.dir != "" {
+		if err := os.RemoveAll(s.repoDir(workspaceID)); err != nil {
+			s.log.WithContext(ctx).WithError(err).Warn("Failed to remove git mirror repository", "workspace_id", workspaceID)
+		}
+	}
+	return nil
+}
+
+// RequestSync has the mirror synced at the next run, even when nothing
+// changed, and retries a failing mirror without waiting out its backoff.
+func (s *GitMirrorService) RequestSync(ctx context.Context, workspaceID, userID uuid.UUID) (*domain.GitMirror, error) {
+	_, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	row, err := s.queries.RequestGitMirrorSync(ctx, pgconv.UUIDToPg(workspaceID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("git mirror not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to request git mirror sync: %w", err)
+	}
+	mirror := toDomainGitMirror(row)
+	return &mirror, nil
+}
+
+// NotifyPush has every mirror pulling from the pushed branch synced at
+// the next run, and returns how many there are.
+func (s *GitMirrorService) NotifyPush(ctx context.Context, push domain.GitPushNotification) (int64, error) {
+	if err := push.Validate(); err != nil {
+		return 0, err
+	}
+	requested, err := s.queries.RequestGitMirrorSyncsForRemote(ctx, db.RequestGitMirrorSyncsForRemoteParams{
+		RemoteUrl: push.RemoteURL,
+		Branch:    push.Branch,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to request git mirror syncs: %w", err)
+	}
+	s.log.WithContext(ctx).Info("Git push received", "remote_url", push.RemoteURL, "branch", push.Branch, "mirrors", requested)
+	return requested, nil
+}
+
+// SyncMirrors syncs the mirrors that are due: those never synced or asked
+// to sync, those whose workspace has events since their last sync, and
+// those that pull and have not checked their remote for
+// domain.GitMirrorPullInterval. A failed sync is retried with exponential
+// backoff. It returns the number of mirrors synced.
+func (s *GitMirrorService) SyncMirrors(ctx context.Context) (int, error) {
+	if s.dir == "" {
+		return 0, nil
+	}
+	now := s.now()
+	mirrors, err := s.queries.ClaimDueGitMirrors(ctx, db.ClaimDueGitMirrorsParams{
+		LeaseUntil: pgconv.TimeToPg(now.Add(gitMirrorLease)),
+		PullBefore: pgconv.TimeToPg(now.Add(-domain.GitMirrorPullInterval)),
+		BatchSize:  GitMirrorBatchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim git mirrors: %w", err)
+	}
+
+	synced := 0
+	for _, mirror := range mirrors {
+		log := s.log.WithContext(ctx).WithWorkspace(pgconv.PgToUUID(mirror.WorkspaceID).String(), "")
+		commit, lastEventID, err := s.syncMirror(ctx, mirror)
+		if err != nil {
+			backoff := min(time.Minute<<min(mirror.FailureCount, 10), maxGitMirrorBackoff)
+			log.WithError(err).Warn("Git mirror sync failed",
+				"remote_url", mirror.RemoteUrl,
+				"failures", mirror.FailureCount+1)
+			err = s.queries.RecordGitMirrorFailure(ctx, db.RecordGitMirrorFailureParams{
+				WorkspaceID:   mirror.WorkspaceID,
+				LastError:     optionalText(err.Error()),
+				NextAttemptAt: pgconv.TimeToPg(s.now().Add(backoff)),
+			})
+			if err != nil {
+				return synced, fmt.Errorf("failed to record git mirror failure: %w", err)
+			}
+			continue
+		}
+
+		err = s.queries.CompleteGitMirrorSync(ctx, db.CompleteGitMirrorSyncParams{
+			LastEventID: lastEventID,
+			LastCommit:  optionalText(commit),
+			WorkspaceID: mirror.WorkspaceID,
+			RemoteUrl:   mirror.RemoteUrl,
+			Branch:      mirror.Branch,
+		})
+		if err != nil {
+			return synced, fmt.Errorf("failed to record git mirror sync: %w", err)
+		}
+		synced++
+	}
+	return synced, nil
+}
+
+// syncMirror runs one sync and returns the commit the branch is at
+// afterwards, with the last workspace event the push includes.
+func (s *GitMirrorService) syncMirror(ctx context.Context, mirror db.ClaimDueGitMirrorsRow) (string, int64, error) {
+	workspaceID := pgconv.PgToUUID(mirror.WorkspaceID)
+	log := s.log.WithContext(ctx).WithWorkspace(workspaceID.String(), "")
+
+	repo, err := gitmirror.Open(ctx, s.repoDir(workspaceID))
+	if err != nil {
+		return "", 0, err
+	}
+	remote := gitmirror.Remote{URL: mirror.RemoteUrl, Branch: mirror.Branch, PrivateKey: []byte(mirror.PrivateKey)}
+	head, err := repo.Fetch(ctx, remote)
+	if err != nil {
+		return "", 0, err
+	}
+	theirs, err := repo.Tree(ctx, head)
+	if err != nil {
+		return "", 0, err
+	}
+	lastCommit := pgconv.PgToString(mirror.LastCommit)
+	last, err := repo.Tree(ctx, lastCommit)
+	if err != nil {
+		// The branch was rewritten without the last synced commit, and
+		// this instance never had it. Start over as if never synced.
+		log.WithError(err).Warn("Git mirror lost its last commit", "last_commit", lastCommit)
+		last = map[string]string{}
+	}
+
+	// Events recorded from here on may be missing from the files listed
+	// below, so they make the mirror due again.
+	lastEventID, err := s.queries.GetLatestWorkspaceEventID(ctx, mirror.WorkspaceID)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get latest event: %w", err)
+	}
+	ours, err := s.workspaceTree(ctx, repo, mirror.WorkspaceID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if mirror.Pull && !mirror.ArchivedAt.Valid {
+		if err := s.pull(ctx, repo, mirror, last, theirs, ours); err != nil {
+			return "", 0, err
+		}
+	}
+
+	// The branch as fetched, less what the workspace deleted since the
+	// last sync, with the workspace's files over it.
+	tree := make(map[string]string, len(theirs)+len(ours))
+	for path, id := range theirs {
+		if !hasPath(last, path) || hasPath(ours, path) {
+			tree[path] = id
+		}
+	}
+	for path, id := range ours {
+		tree[path] = id
+	}
+
+	author := gitmirror.Signature{Name: "Noture", Email: mirror.OwnerEmail, When: s.now()}
+	commit, err := repo.Commit(ctx, head, tree, gitMirrorCommitMessage(theirs, tree), author)
+	if err != nil {
+		return "", 0, err
+	}
+	if commit != head {
+		if err := repo.Push(ctx, remote, commit); err != nil {
+			return "", 0, err
+		}
+		log.Info("Pushed workspace to git mirror", "commit", commit, "files", len(tree))
+	}
+	if err := repo.Save(); err != nil {
+		log.WithError(err).Warn("Failed to save git mirror blob IDs")
+	}
+	return commit, lastEventID, nil
+}
+
+// workspaceTree writes the workspace's files to repo and returns their
+// paths with blob IDs. Content written by an earlier sync is not read.
+func (s *GitMirrorService) workspaceTree(ctx context.Context, repo *gitmirror.Repo, workspaceID pgtype.UUID) (map[string]string, error) {
+	files, err := s.queries.ListFiles(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	tree := make(map[string]string, len(files))
+	for _, file := range files {
+		id, ok := repo.BlobID(file.ContentHash)
+		if !ok {
+			content, err := s.files.blobs.Get(ctx, file.ContentHash)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", file.FilePath, err)
+			}
+			if id, err = repo.WriteBlob(ctx, file.ContentHash, content); err != nil {
+				return nil, err
+			}
+		}
+		tree[file.FilePath] = id
+	}
+	return tree, nil
+}
+
+// pull applies the branch's changes since the last sync to the workspace,
+// as its owner, and to ours, for the files the workspace left unchanged.
+// Files the workspace refuses, for its limits, are skipped and stay on
+// the branch only.
+func (s *GitMirrorService) pull(ctx context.Context, repo *gitmirror.Repo, mirror db.ClaimDueGitMirrorsRow, last, theirs, ours map[string]string) error {
+	workspaceID := pgconv.PgToUUID(mirror.WorkspaceID)
+	ownerID := pgconv.PgToUUID(mirror.OwnerID)
+	log := s.log.WithContext(ctx).WithWorkspace(workspaceID.String(), "")
+
+	paths := make(map[string]bool, len(last)+len(theirs))
+	for path := range last {
+		paths[path] = true
+	}
+	for path := range theirs {
+		paths[path] = true
+	}
+
+	pulled := 0
+	for path := range paths {
+		if sameEntry(last, theirs, path) || !sameEntry(last, ours, path) {
+			continue
+		}
+
+		id, ok := theirs[path]
+		if !ok {
+			err := s.files.DeleteFile(ctx, workspaceID, path, ownerID)
+			if err != nil && !strings.HasPrefix(err.Error(), "file not found") {
+				return fmt.Errorf("failed to delete pulled file %s: %w", path, err)
+			}
+			delete(ours, path)
+			pulled++
+			continue
+		}
+
+		content, err := repo.ReadBlob(ctx, id)
+		if err != nil {
+			return err
+		}
+		_, err = s.files.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  workspaceID,
+			FilePath:     path,
+			Content:      content,
+			LastModified: s.now(),
+			ClientID:     gitMirrorClientID,
+		}, ownerID)
+		if err != nil {
+			if rejectedUpload(err) {
+				log.WithError(err).Warn("Skipped pulled file", "file_path", path)
+				continue
+			}
+			return fmt.Errorf("failed to write pulled file %s: %w", path, err)
+		}
+		if _, err := repo.WriteBlob(ctx, storage.Hash(content), content); err != nil {
+			return err
+		}
+		ours[path] = id
+		pulled++
+	}
+
+	if pulled > 0 {
+		log.Info("Pulled changes from git mirror", "files", pulled)
+	}
+	return nil
+}
+
+func (s *GitMirrorService) repoDir(workspaceID uuid.UUID) string {
+	return filepath.Join(s.dir, workspaceID.String()+".git")
+}
+
+// sameEntry reports whether path has the same blob in both trees, or is
+// missing from both.
+func sameEntry(a, b map[string]string, path string) bool {
+	idA, okA := a[path]
+	idB, okB := b[path]
+	return okA == okB && idA == idB
+}
+
+func hasPath(tree map[string]string, path string) bool {
+	_, ok := tree[path]
+	return ok
+}
+
+// rejectedUpload reports whether err is the workspace refusing a file for
+// its limits, rather than a failure worth retrying.
+func rejectedUpload(err error) bool {
+	msg := err.Error()
+	return strings.HasPrefix(msg, "file too large") ||
+		strings.HasPrefix(msg, "file limit reached") ||
+		strings.HasPrefix(msg, "storage limit exceeded")
+}
+
+// gitMirrorCommitMessage summarizes how after differs from before.
+func gitMirrorCommitMessage(before, after map[string]string) string {
+	var changed []string
+	for path, id := range after {
+		if before[path] != id {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if !hasPath(after, path) {
+			changed = append(changed, path)
+		}
+	}
+	if len(changed) == 1 {
+		return "Update " + changed[0] + " from Noture"
+	}
+	return fmt.Sprintf("Update %d files from Noture", len(changed))
+}
+
+func toDomainGitMirror(row db.WorkspaceGitMirror) domain.GitMirror {
+	return domain.GitMirror{
+		WorkspaceID:   pgconv.PgToUUID(row.WorkspaceID),
+		RemoteURL:     row.RemoteUrl,
+		Branch:        row.Branch,
+		Pull:          row.Pull,
+		PublicKey:     row.PublicKey,
+		LastCommit:    pgconv.PgToStringPtr(row.LastCommit),
+		LastSyncedAt:  pgconv.PgToTimePtr(row.LastSyncedAt),
+		FailureCount:  row.FailureCount,
+		LastError:     pgconv.PgToStringPtr(row.LastError),
+		NextAttemptAt: pgconv.PgToTimePtr(row.NextAttemptAt),
+		CreatedAt:     pgconv.PgToTime(row.CreatedAt),
+		UpdatedAt:     pgconv.PgToTime(row.UpdatedAt),
+	}
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/gitmirror"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitMirrorService_ConfigureMirror_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	files := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	service := NewGitMirrorService(testDB.Queries(), files, t.TempDir())
+	ctx := context.Background()
+
+	_, err := service.GetMirror(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+	assert.EqualError(t, err, "git mirror not found")
+
+	mirror, err := service.ConfigureMirror(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.ConfigureGitMirrorRequest{
+		RemoteURL: "git@github.com:me/notes.git",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DefaultGitMirrorBranch, mirror.Branch)
+	assert.False(t, mirror.Pull)
+	assert.Contains(t, mirror.PublicKey, "ssh-ed25519 ")
+
+	changed, err := service.ConfigureMirror(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.ConfigureGitMirrorRequest{
+		RemoteURL: "git@github.com:me/other.git",
+		Branch:    "notes",
+		Pull:      true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, mirror.PublicKey, changed.PublicKey, "the deploy key is kept")
+	assert.Equal(t, "notes", changed.Branch)
+	assert.True(t, changed.Pull)
+
+	_, err = service.ConfigureMirror(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.ConfigureGitMirrorRequest{
+		RemoteURL: "/srv/notes.git",
+	})
+	assert.ErrorContains(t, err, "invalid remote_url")
+
+	_, err = service.GetMirror(ctx, testData.FreeWorkspaceID, testData.PremiumUserID)
+	assert.ErrorContains(t, err, "access denied")
+
+	disabled := NewGitMirrorService(testDB.Queries(), files, "")
+	_, err = disabled.ConfigureMirror(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.ConfigureGitMirrorRequest{
+		RemoteURL: "git@github.com:me/notes.git",
+	})
+	assert.ErrorContains(t, err, "not enabled")
+
+	requested, err := service.NotifyPush(ctx, domain.GitPushNotification{RemoteURL: "git@github.com:me/other.git", Branch: "notes"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), requested)
+	requested, err = service.NotifyPush(ctx, domain.GitPushNotification{RemoteURL: "git@github.com:me/other.git", Branch: "main"})
+	require.NoError(t, err)
+	assert.Zero(t, requested, "pushes to other branches are ignored")
+	_, err = service.NotifyPush(ctx, domain.GitPushNotification{RemoteURL: "/srv/notes.git", Branch: "main"})
+	assert.ErrorContains(t, err, "invalid remote_url")
+
+	require.NoError(t, service.DeleteMirror(ctx, testData.FreeWorkspaceID, testData.FreeUserID))
+	assert.EqualError(t, service.DeleteMirror(ctx, testData.FreeWorkspaceID, testData.FreeUserID), "git mirror not found")
+}
+
+func TestGitMirrorService_SyncMirrors_Simple(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	files := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	service := NewGitMirrorService(testDB.Queries(), files, t.TempDir())
+	ctx := context.Background()
+
+	// Configuring only accepts SSH remotes, so the mirror to a local
+	// repository is stored directly.
+	remoteDir := filepath.Join(t.TempDir(), "remote.git")
+	require.NoError(t, exec.Command("git", "init", "--quiet", "--bare", remoteDir).Run())
+	remote := gitmirror.Remote{URL: remoteDir, Branch: "main"}
+	privateKey, publicKey, err := gitmirror.GenerateKey("test")
+	require.NoError(t, err)
+	_, err = testDB.Queries().UpsertGitMirror(ctx, db.UpsertGitMirrorParams{
+		WorkspaceID: pgconv.UUIDToPg(testData.FreeWorkspaceID),
+		RemoteUrl:   remoteDir,
+		Branch:      "main",
+		Pull:        true,
+		PrivateKey:  string(privateKey),
+		PublicKey:   publicKey,
+		CreatedBy:   pgconv.UUIDToPg(testData.FreeUserID),
+	})
+	require.NoError(t, err)
+
+	upload := func(t *testing.T, path, content string) {
+		_, err := files.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      []byte(content),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+	clone, err := gitmirror.Open(ctx, filepath.Join(t.TempDir(), "clone.git"))
+	require.NoError(t, err)
+	remoteFiles := func(t *testing.T) map[string]string {
+		head, err := clone.Fetch(ctx, remote)
+		require.NoError(t, err)
+		tree, err := clone.Tree(ctx, head)
+		require.NoError(t, err)
+		contents := make(map[string]string, len(tree))
+		for path, id := range tree {
+			content, err := clone.ReadBlob(ctx, id)
+			require.NoError(t, err)
+			contents[path] = string(content)
+		}
+		return contents
+	}
+	author := gitmirror.Signature{Name: "Someone", Email: "someone@example.com", When: time.Now()}
+	pushFromClone := func(t *testing.T, change func(tree map[string]string)) {
+		head, err := clone.Fetch(ctx, remote)
+		require.NoError(t, err)
+		tree, err := clone.Tree(ctx, head)
+		require.NoError(t, err)
+		change(tree)
+		commit, err := clone.Commit(ctx, head, tree, "Edit on the remote", author)
+		require.NoError(t, err)
+		require.NoError(t, clone.Push(ctx, remote, commit))
+	}
+	syncAt := func(t *testing.T, at time.Time) int {
+		service.now = func() time.Time { return at }
+		synced, err := service.SyncMirrors(ctx)
+		require.NoError(t, err)
+		return synced
+	}
+
+	t.Run("first sync pushes the workspace", func(t *testing.T) {
+		upload(t, "a.md", "# A")
+		upload(t, "notes/b.md", "# B")
+
+		assert.Equal(t, 1, syncAt(t, time.Now()))
+		assert.Equal(t, map[string]string{"a.md": "# A", "notes/b.md": "# B"}, remoteFiles(t))
+
+		assert.Equal(t, 0, syncAt(t, time.Now()), "nothing changed")
+		mirror, err := service.GetMirror(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.NotNil(t, mirror.LastCommit)
+		assert.Zero(t, mirror.FailureCount)
+	})
+
+	t.Run("workspace changes are pushed", func(t *testing.T) {
+		upload(t, "a.md", "# A, edited")
+		require.NoError(t, files.DeleteFile(ctx, testData.FreeWorkspaceID, "notes/b.md", testData.FreeUserID))
+
+		assert.Equal(t, 1, syncAt(t, time.Now()))
+		assert.Equal(t, map[string]string{"a.md": "# A, edited"}, remoteFiles(t))
+	})
+
+	t.Run("remote changes are pulled", func(t *testing.T) {
+		pushFromClone(t, func(tree map[string]string) {
+			id, err := clone.WriteBlob(ctx, "c", []byte("# C"))
+			require.NoError(t, err)
+			tree["c.md"] = id
+		})
+
+		assert.Equal(t, 1, syncAt(t, time.Now().Add(domain.GitMirrorPullInterval+time.Minute)))
+		content, err := files.GetFileContent(ctx, testData.FreeWorkspaceID, "c.md", testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, "# C", string(content.Content))
+	})
+
+	t.Run("the workspace wins when both sides changed a file", func(t *testing.T) {
+		pushFromClone(t, func(tree map[string]string) {
+			id, err := clone.WriteBlob(ctx, "a-remote", []byte("# A, remote"))
+			require.NoError(t, err)
+			tree["a.md"] = id
+		})
+		upload(t, "a.md", "# A, workspace")
+
+		_, err := service.RequestSync(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, syncAt(t, time.Now().Add(2*domain.GitMirrorPullInterval)))
+		content, err := files.GetFileContent(ctx, testData.FreeWorkspaceID, "a.md", testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, "# A, workspace", string(content.Content))
+		assert.Equal(t, "# A, workspace", remoteFiles(t)["a.md"])
+	})
+
+	t.Run("a failing remote backs off", func(t *testing.T) {
+		_, err := testDB.Conn().Exec(ctx, "UPDATE workspace_git_mirrors SET remote_url = $2 WHERE workspace_id = $1",
+			testData.FreeWorkspaceID, filepath.Join(t.TempDir(), "missing.git"))
+		require.NoError(t, err)
+		_, err = service.RequestSync(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		require.NoError(t, err)
+
+		assert.Equal(t, 0, syncAt(t, time.Now()))
+		mirror, err := service.GetMirror(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), mirror.FailureCount)
+		assert.NotNil(t, mirror.LastError)
+		require.NotNil(t, mirror.NextAttemptAt)
+		assert.True(t, mirror.NextAttemptAt.After(time.Now()))
+	})
+}
+
+func TestGitMirrorCommitMessage(t *testing.T) {
+	before := map[string]string{"a.md": "1", "b.md": "2"}
+	assert.Equal(t, "Update b.md from Noture", gitMirrorCommitMessage(before, map[string]string{"a.md": "1", "b.md": "3"}))
+	assert.Equal(t, "Update a.md from Noture", gitMirrorCommitMessage(before, map[string]string{"b.md": "2"}))
+	assert.Equal(t, "Update 2 files from Noture", gitMirrorCommitMessage(before, map[string]string{"a.md": "1", "c.md": "4"}))
+}
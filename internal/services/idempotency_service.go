@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+)
+
+// IdempotencyKeyTTL is how long the response to a request sent with an
+// Idempotency-Key is replayed to retries.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// IdempotencyClaimTimeout is how long a request may hold its key. After
+// that a retry takes the key over, so a request cut short by a restart
+// does not block its key for a day.
+const IdempotencyClaimTimeout = 10 * time.Minute
+
+// IdempotencyService stores the responses of idempotent requests per user
+// and key, shared between server instances.
+type IdempotencyService struct {
+	queries *db.Queries
+	log     *logger.Logger
+}
+
+func NewIdempotencyService(queries *db.Queries) *IdempotencyService {
+	return &IdempotencyService{
+		queries: queries,
+		log:     logger.New(),
+	}
+}
+
+// Claim reserves key for a request of userID. It returns nil when the
+// caller now holds the key and must Complete or Release it, and otherwise
+// what is stored for the key: the earlier response, or a pending one while
+// the earlier request runs.
+func (s *IdempotencyService) Claim(ctx context.Context, userID uuid.UUID, key string) (*domain.IdempotentResponse, error) {
+	now := time.Now()
+	claimed, err := s.queries.ClaimIdempotencyKey(ctx, db.ClaimIdempotencyKeyParams{
+		UserID:         pgconv.UUIDToPg(userID),
+		IdempotencyKey: key,
+		StaleBefore:    pgconv.TimeToPg(now.Add(-IdempotencyClaimTimeout)),
+		ExpiredBefore:  pgconv.TimeToPg(now.Add(-IdempotencyKeyTTL)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+	if claimed > 0 {
+		return nil, nil
+	}
+
+	row, err := s.queries.GetIdempotencyKey(ctx, db.GetIdempotencyKeyParams{
+		UserID:         pgconv.UUIDToPg(userID),
+		IdempotencyKey: key,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	if !row.CompletedAt.Valid {
+		return &domain.IdempotentResponse{Pending: true}, nil
+	}
+
+	response := &domain.IdempotentResponse{
+		Fingerprint: pgconv.PgToString(row.Fingerprint),
+		StatusCode:  int(pgconv.PgToInt32(row.StatusCode)),
+		Body:        row.ResponseBody,
+	}
+	if len(row.ResponseHeaders) > 0 {
+		if err := json.Unmarshal(row.ResponseHeaders, &response.Header); err != nil {
+			return nil, fmt.Errorf("failed to decode stored headers: %w", err)
+		}
+	}
+	return response, nil
+}
+
+// Complete stores the response to the request holding key.
+func (s *IdempotencyService) Complete(ctx context.Context, userID uuid.UUID, key string, response domain.IdempotentResponse) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return fmt.Errorf("failed to encode headers: %w", err)
+	}
+	err = s.queries.CompleteIdempotencyKey(ctx, db.CompleteIdempotencyKeyParams{
+		UserID:          pgconv.UUIDToPg(userID),
+		IdempotencyKey:  key,
+		Fingerprint:     pgconv.StringToPg(response.Fingerprint),
+		StatusCode:      pgconv.Int32ToPg(int32(response.StatusCode)),
+		ResponseHeaders: header,
+		ResponseBody:    response.Body,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release gives up key without storing a response, so a retry runs the
+// request again.
+func (s *IdempotencyService) Release(ctx context.Context, userID uuid.UUID, key string) error {
+	err := s.queries.DeleteIdempotencyKey(ctx, db.DeleteIdempotencyKeyParams{
+		UserID:         pgconv.UUIDToPg(userID),
+		IdempotencyKey: key,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes keys older than IdempotencyKeyTTL.
+func (s *IdempotencyService) DeleteExpired(ctx context.Context) (int64, error) {
+	deleted, err := s.queries.DeleteExpiredIdempotencyKeys(ctx, pgconv.TimeToPg(time.Now().Add(-IdempotencyKeyTTL)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	if deleted > 0 {
+		s.log.WithContext(ctx).Info("Deleted expired idempotency keys", "count", deleted)
+	}
+	return deleted, nil
+}
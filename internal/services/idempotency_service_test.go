@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyService_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewIdempotencyService(testDB.Queries())
+	ctx := context.Background()
+
+	stored, err := service.Claim(ctx, testData.FreeUserID, "retry-1")
+	require.NoError(t, err)
+	assert.Nil(t, stored, "the first request claims the key")
+
+	stored, err = service.Claim(ctx, testData.FreeUserID, "retry-1")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.True(t, stored.Pending)
+
+	stored, err = service.Claim(ctx, testData.PremiumUserID, "retry-1")
+	require.NoError(t, err)
+	assert.Nil(t, stored, "keys are per user")
+
+	response := domain.IdempotentResponse{
+		Fingerprint: "abc",
+		StatusCode:  http.StatusCreated,
+		Header:      map[string]string{"Content-Type": "application/json"},
+		Body:        []byte(`{"created":true}`),
+	}
+	require.NoError(t, service.Complete(ctx, testData.FreeUserID, "retry-1", response))
+
+	stored, err = service.Claim(ctx, testData.FreeUserID, "retry-1")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, response, *stored)
+
+	require.NoError(t, service.Release(ctx, testData.PremiumUserID, "retry-1"))
+	stored, err = service.Claim(ctx, testData.PremiumUserID, "retry-1")
+	require.NoError(t, err)
+	assert.Nil(t, stored, "a released key can be claimed again")
+
+	deleted, err := service.DeleteExpired(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
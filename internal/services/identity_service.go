@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// IdentityService signs users in with OAuth provider accounts and keeps
+// the accounts linked to each user.
+type IdentityService struct {
+	queries  *db.Queries
+	conn     *pgx.Conn
+	users    *UserService
+	verifier *EmailVerifier
+	log      *logger.Logger
+}
+
+// NewIdentityService signs users out through users when an account is
+// unlinked.
+func NewIdentityService(queries *db.Queries, conn *pgx.Conn, users *UserService) *IdentityService {
+	return &IdentityService{
+		queries: queries,
+		conn:    conn,
+		users:   users,
+		log:     logger.New(),
+	}
+}
+
+// WithEmailVerifier lets SignIn create accounts for provider emails that
+// are not verified, emailing them a verification link. Without it such
+// sign-ins are refused.
+func (s *IdentityService) WithEmailVerifier(verifier *EmailVerifier) *IdentityService {
+	s.verifier = verifier
+	return s
+}
+
+// SignIn returns the user a provider account signs in as. A linked account
+// always reaches its user. An unlinked one is linked to the user with its
+// verified email, who is created if there is none, so accounts from
+// before linking existed keep working. An unverified email is never
+// linked to an existing user, but can create a new one that still has to
+// verify it. An existing user who never verified the email is claimed
+// first; see claimAccount.
+func (s *IdentityService) SignIn(ctx context.Context, identity domain.ExternalIdentity) (*domain.User, error) {
+	email, _ := normalizeEmail(identity.Email)
+
+	linked, err := s.queries.GetOAuthIdentity(ctx, db.GetOAuthIdentityParams{
+		Provider:       identity.Provider,
+		ProviderUserID: identity.ProviderUserID,
+	})
+	switch {
+	case err == nil:
+		if err := s.queries.TouchOAuthIdentity(ctx, db.TouchOAuthIdentityParams{ID: linked.ID, Email: optionalText(email)}); err != nil {
+			s.log.WithContext(ctx).WithError(err).Warn("Failed to record identity sign-in", "provider", identity.Provider)
+		}
+		user, err := s.queries.GetUserByID(ctx, linked.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load user: %w", err)
+		}
+		if user.DisabledAt.Valid {
+			return nil, fmt.Errorf("account disabled")
+		}
+		return toDomainUser(user), nil
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, fmt.Errorf("failed to look up identity: %w", err)
+	}
+
+	if email == "" {
+		return nil, fmt.Errorf("email address is required")
+	}
+	user, err := s.queries.GetUserByEmail(ctx, email)
+	if !identity.EmailVerified && (err == nil || s.verifier == nil) {
+		return nil, fmt.Errorf("email address must be verified")
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		s.log.WithContext(ctx).Info("Creating new user", "email", email, "provider", identity.Provider)
+		user, err = s.queries.CreateUser(ctx, db.CreateUserParams{
+			Email:        email,
+			PasswordHash: "",
+			Tier:         db.UserTierFree,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+		if identity.EmailVerified {
+			if _, err := s.queries.MarkEmailVerified(ctx, db.MarkEmailVerifiedParams{ID: user.ID, Email: user.Email}); err != nil {
+				return nil, fmt.Errorf("failed to verify email: %w", err)
+			}
+			user.EmailVerifiedAt = pgconv.TimeToPg(time.Now())
+		} else if err := s.verifier.Send(ctx, user); err != nil {
+			s.log.WithContext(ctx).WithError(err).Warn("Failed to send verification email")
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to look up user: %w", err)
+	} else if user.DisabledAt.Valid {
+		return nil, fmt.Errorf("account disabled")
+	} else if !user.EmailVerifiedAt.Valid {
+		if user, err = s.claimAccount(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := s.link(ctx, pgconv.PgToUUID(user.ID), identity, email); err != nil {
+		return nil, err
+	}
+	return toDomainUser(user), nil
+}
+
+// claimAccount hands an account whose email was never verified to the
+// person who just proved they own the address through a provider. Anyone
+// can register an address they do not own, so whatever signed in before
+// is thrown out: the password, other linked accounts, passkeys and every
+// token. The email is then verified.
+func (s *IdentityService) claimAccount(ctx context.Context, userID pgtype.UUID) (db.User, error) {
+	var user db.User
+	claimed := false
+	err := inTx(ctx, s.conn, s.queries, "claim_account", func(qtx *db.Queries) error {
+		var err error
+		user, err = qtx.LockUser(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to load user: %w", err)
+		}
+		claimed = !user.EmailVerifiedAt.Valid
+		if !claimed {
+			return nil
+		}
+		if err := qtx.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{ID: userID, PasswordHash: ""}); err != nil {
+			return fmt.Errorf("failed to clear password: %w", err)
+		}
+		if err := qtx.DeleteUserOAuthIdentities(ctx, userID); err != nil {
+			return fmt.Errorf("failed to unlink identities: %w", err)
+		}
+		if err := qtx.DeleteUserPasskeys(ctx, userID); err != nil {
+			return fmt.Errorf("failed to delete passkeys: %w", err)
+		}
+		if _, err := qtx.MarkEmailVerified(ctx, db.MarkEmailVerifiedParams{ID: userID, Email: user.Email}); err != nil {
+			return fmt.Errorf("failed to verify email: %w", err)
+		}
+		user.PasswordHash = ""
+		user.EmailVerifiedAt = pgconv.TimeToPg(time.Now())
+		return nil
+	})
+	if err != nil {
+		return db.User{}, err
+	}
+	if claimed {
+		s.log.WithContext(ctx).LogAuthEvent("account_claimed", pgconv.PgToUUID(userID).String(), "oauth")
+		if _, err := s.users.RevokeAllTokens(ctx, pgconv.PgToUUID(userID), nil, "account_claimed"); err != nil {
+			return db.User{}, err
+		}
+	}
+	return user, nil
+}
+
+// Link links a provider account to userID, who is signed in. Linking the
+// same account again is a no-op.
+func (s *IdentityService) Link(ctx context.Context, userID uuid.UUID, identity domain.ExternalIdentity) (*domain.OAuthIdentity, error) {
+	email, _ := normalizeEmail(identity.Email)
+
+	linked, err := s.queries.GetOAuthIdentity(ctx, db.GetOAuthIdentityParams{
+		Provider:       identity.Provider,
+		ProviderUserID: identity.ProviderUserID,
+	})
+	switch {
+	case err == nil && pgconv.PgToUUID(linked.UserID) == userID:
+		return toDomainOAuthIdentity(linked), nil
+	case err == nil:
+		return nil, fmt.Errorf("identity already linked to another account")
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, fmt.Errorf("failed to look up identity: %w", err)
+	}
+	return s.link(ctx, userID, identity, email)
+}
+
+func (s *IdentityService) link(ctx context.Context, userID uuid.UUID, identity domain.ExternalIdentity, email string) (*domain.OAuthIdentity, error) {
+	linked, err := s.queries.CreateOAuthIdentity(ctx, db.CreateOAuthIdentityParams{
+		UserID:         pgconv.UUIDToPg(userID),
+		Provider:       identity.Provider,
+		ProviderUserID: identity.ProviderUserID,
+		Email:          optionalText(email),
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			if pgErr.ConstraintName == "oauth_identities_user_id_provider_key" {
+				return nil, fmt.Errorf("provider already linked: unlink the other %s account first", identity.Provider)
+			}
+			return nil, fmt.Errorf("identity already linked to another account")
+		}
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	s.log.WithContext(ctx).LogAuthEvent("identity_linked", userID.String(), identity.Provider)
+	return toDomainOAuthIdentity(linked), nil
+}
+
+// ListIdentities returns the provider accounts linked to userID.
+func (s *IdentityService) ListIdentities(ctx context.Context, userID uuid.UUID) ([]domain.OAuthIdentity, error) {
+	rows, err := s.queries.ListOAuthIdentities(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+	identities := make([]domain.OAuthIdentity, len(rows))
+	for i, row := range rows {
+		identities[i] = *toDomainOAuthIdentity(row)
+	}
+	return identities, nil
+}
+
+// Unlink removes userID's account at provider and revokes every API
+// token but currentTokenID, since the others may have been issued through
+// that account. The last way to sign in, for users without a password or
+// passkey, cannot be removed; the user row is locked while that is
+// checked so concurrent unlinks cannot both pass it.
+func (s *IdentityService) Unlink(ctx context.Context, userID, currentTokenID uuid.UUID, provider string) error {
+	err := inTx(ctx, s.conn, s.queries, "unlink_identity", func(qtx *db.Queries) error {
+		user, err := qtx.LockUser(ctx, pgconv.UUIDToPg(userID))
+		if err != nil {
+			return fmt.Errorf("failed to load user: %w", err)
+		}
+		if user.PasswordHash == "" {
+			identities, err := qtx.ListOAuthIdentities(ctx, user.ID)
+			if err != nil {
+				return fmt.Errorf("failed to list identities: %w", err)
+			}
+			passkeys, err := qtx.CountPasskeys(ctx, user.ID)
+			if err != nil {
+				return fmt.Errorf("failed to count passkeys: %w", err)
+			}
+			if len(identities) == 1 && identities[0].Provider == provider && passkeys == 0 {
+				return fmt.Errorf("cannot unlink the only way to sign in: set a password first")
+			}
+		}
+
+		n, err := qtx.DeleteOAuthIdentity(ctx, db.DeleteOAuthIdentityParams{
+			UserID:   user.ID,
+			Provider: provider,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to unlink identity: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("identity not found")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.log.WithContext(ctx).LogAuthEvent("identity_unlinked", userID.String(), provider)
+	_, err = s.users.RevokeAllTokens(ctx, userID, &currentTokenID, "identity_unlinked")
+	return err
+}
+
+func toDomainOAuthIdentity(row db.OauthIdentity) *domain.OAuthIdentity {
+	return &domain.OAuthIdentity{
+		ID:             pgconv.PgToUUID(row.ID),
+		Provider:       row.Provider,
+		ProviderUserID: row.ProviderUserID,
+		Email:          pgconv.PgToString(row.Email),
+		CreatedAt:      pgconv.PgToTime(row.CreatedAt),
+		LastLoginAt:    pgconv.PgToTimePtr(row.LastLoginAt),
+	}
+}
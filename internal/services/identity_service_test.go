@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentityService_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewIdentityService(testDB.Queries(), testDB.Conn(), NewUserService(testDB.Queries()))
+	ctx := context.Background()
+
+	freeUser, err := testDB.Queries().GetUserByID(ctx, pgconv.UUIDToPg(testData.FreeUserID))
+	require.NoError(t, err)
+	github := domain.ExternalIdentity{
+		Provider:       domain.ProviderGitHub,
+		ProviderUserID: "4242",
+		Email:          strings.ToUpper(freeUser.Email),
+		EmailVerified:  true,
+	}
+
+	t.Run("first sign-in links the account with the same email", func(t *testing.T) {
+		user, err := service.SignIn(ctx, github)
+
+		require.NoError(t, err)
+		assert.Equal(t, testData.FreeUserID, user.ID)
+	})
+
+	t.Run("linked account signs in after its email changes", func(t *testing.T) {
+		changed := github
+		changed.Email = "someone-else@example.com"
+		changed.EmailVerified = false
+
+		user, err := service.SignIn(ctx, changed)
+
+		require.NoError(t, err)
+		assert.Equal(t, testData.FreeUserID, user.ID)
+	})
+
+	t.Run("unverified email is refused", func(t *testing.T) {
+		_, err := service.SignIn(ctx, domain.ExternalIdentity{
+			Provider:       domain.ProviderGoogle,
+			ProviderUserID: "g-1",
+			Email:          "someone@example.com",
+		})
+
+		assert.ErrorContains(t, err, "email address must be verified")
+	})
+
+	t.Run("unverified new email creates an unverified user with a verifier", func(t *testing.T) {
+		mailer := &recordingSender{}
+		verifier := NewEmailVerifier(testDB.Queries(), mailer, auth.NewSigner([]byte("test-signing-key-0123456789abcdef")), "https://noture.test")
+		unverified := domain.ExternalIdentity{
+			Provider:       domain.ProviderGitHub,
+			ProviderUserID: "5151",
+			Email:          "unverified@example.com",
+		}
+
+		user, err := NewIdentityService(testDB.Queries(), testDB.Conn(), NewUserService(testDB.Queries())).WithEmailVerifier(verifier).SignIn(ctx, unverified)
+		require.NoError(t, err)
+		assert.False(t, user.EmailVerified)
+		require.Len(t, mailer.sent, 1)
+		assert.Equal(t, "unverified@example.com", mailer.sent[0].To)
+
+		unverified.ProviderUserID = "5252"
+		unverified.Email = freeUser.Email
+		_, err = NewIdentityService(testDB.Queries(), testDB.Conn(), NewUserService(testDB.Queries())).WithEmailVerifier(verifier).SignIn(ctx, unverified)
+		assert.ErrorContains(t, err, "email address must be verified", "existing accounts need a verified email")
+	})
+
+	t.Run("new email creates a user without a password", func(t *testing.T) {
+		user, err := service.SignIn(ctx, domain.ExternalIdentity{
+			Provider:       domain.ProviderGoogle,
+			ProviderUserID: "g-2",
+			Email:          "new@example.com",
+			EmailVerified:  true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "new@example.com", user.Email)
+		assert.True(t, user.EmailVerified)
+
+		err = service.Unlink(ctx, user.ID, uuid.New(), domain.ProviderGoogle)
+		assert.ErrorContains(t, err, "cannot unlink the only way to sign in")
+	})
+
+	t.Run("an account links to one user only", func(t *testing.T) {
+		_, err := service.Link(ctx, testData.PremiumUserID, github)
+		assert.ErrorContains(t, err, "identity already linked to another account")
+
+		again, err := service.Link(ctx, testData.FreeUserID, github)
+		require.NoError(t, err)
+		assert.Equal(t, "4242", again.ProviderUserID)
+	})
+
+	t.Run("a user links one account per provider", func(t *testing.T) {
+		other := github
+		other.ProviderUserID = "9999"
+
+		_, err := service.Link(ctx, testData.FreeUserID, other)
+
+		assert.ErrorContains(t, err, "provider already linked")
+	})
+
+	t.Run("list and unlink", func(t *testing.T) {
+		identities, err := service.ListIdentities(ctx, testData.FreeUserID)
+		require.NoError(t, err)
+		require.Len(t, identities, 1)
+		assert.Equal(t, domain.ProviderGitHub, identities[0].Provider)
+		assert.NotNil(t, identities[0].LastLoginAt)
+
+		current, err := testDB.Queries().CreateAPIToken(ctx, db.CreateAPITokenParams{
+			UserID:    pgconv.UUIDToPg(testData.FreeUserID),
+			TokenHash: "hash-current",
+			Name:      "current",
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, service.Unlink(ctx, testData.FreeUserID, pgconv.PgToUUID(current.ID), domain.ProviderGitHub))
+		rows, err := testDB.Conn().Query(ctx, "SELECT name FROM api_tokens WHERE user_id = $1", pgconv.UUIDToPg(testData.FreeUserID))
+		require.NoError(t, err)
+		tokens, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		require.NoError(t, err)
+		assert.Equal(t, []string{"current"}, tokens, "other tokens are revoked")
+
+		err = service.Unlink(ctx, testData.FreeUserID, pgconv.PgToUUID(current.ID), domain.ProviderGitHub)
+		assert.ErrorContains(t, err, "identity not found")
+	})
+}
+
+func TestIdentityService_SignIn_ClaimsUnverifiedAccount(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	users := NewUserService(testDB.Queries())
+	service := NewIdentityService(testDB.Queries(), testDB.Conn(), users)
+	ctx := context.Background()
+
+	// Someone registers the address before its owner does, and links a
+	// provider account of their own.
+	squatter, err := users.Register(ctx, "victim@example.com", "correct horse battery")
+	require.NoError(t, err)
+	_, err = service.Link(ctx, squatter.ID, domain.ExternalIdentity{Provider: domain.ProviderGoogle, ProviderUserID: "squatter"})
+	require.NoError(t, err)
+	_, err = testDB.Queries().CreateAPIToken(ctx, db.CreateAPITokenParams{
+		UserID:    pgconv.UUIDToPg(squatter.ID),
+		TokenHash: auth.HashToken("squatter-token"),
+		Name:      "cli",
+	})
+	require.NoError(t, err)
+
+	user, err := service.SignIn(ctx, domain.ExternalIdentity{
+		Provider:       domain.ProviderGitHub,
+		ProviderUserID: "owner",
+		Email:          "victim@example.com",
+		EmailVerified:  true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, squatter.ID, user.ID)
+	assert.True(t, user.EmailVerified)
+
+	_, err = users.Authenticate(ctx, "victim@example.com", "correct horse battery")
+	assert.ErrorContains(t, err, "invalid email or password", "the password is cleared")
+	_, err = testDB.Queries().GetTokenByHash(ctx, auth.HashToken("squatter-token"))
+	assert.ErrorIs(t, err, pgx.ErrNoRows, "tokens are revoked")
+	identities, err := service.ListIdentities(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, identities, 1, "other linked accounts are removed")
+	assert.Equal(t, domain.ProviderGitHub, identities[0].Provider)
+}
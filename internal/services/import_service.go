@@ -0,0 +1,237 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/notion"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+)
+
+// notionImportClientID marks the uploads of an import in the sync log.
+const notionImportClientID = "notion-import"
+
+// NotionImportOperation is the operation kind that imports a Notion
+// export into a workspace.
+const NotionImportOperation = "notion_import"
+
+// ImportService brings notes exported from other apps into a workspace.
+type ImportService struct {
+	queries    *db.Queries
+	files      *FileService
+	operations *OperationService
+	fetch      notion.FetchFunc
+	log        *logger.Logger
+}
+
+// NewImportService writes imported files through files and registers the
+// import operation with operations, so the worker's service must be built
+// with this constructor too. Images pages embed from the web are left
+// remote until WithFetcher is called.
+func NewImportService(queries *db.Queries, files *FileService, operations *OperationService) *ImportService {
+	s := &ImportService{
+		queries:    queries,
+		files:      files,
+		operations: operations,
+		log:        logger.New(),
+	}
+	operations.Register(NotionImportOperation, s.runNotionImport)
+	return s
+}
+
+// WithFetcher makes imports download the images pages embed with fetch.
+func (s *ImportService) WithFetcher(fetch notion.FetchFunc) *ImportService {
+	s.fetch = fetch
+	return s
+}
+
+type notionImportParams struct {
+	Folder string `json:"folder"`
+}
+
+// notionImportState is how far an import got: the result so far, after
+// StepsDone files.
+type notionImportState struct {
+	Result *domain.NotionImportResult `json:"result"`
+}
+
+// ImportNotion queues an import of the Notion export zip in archive into
+// folder, DefaultNotionImportFolder when empty. The archive is checked
+// here, so mistakes are reported before the operation is queued; the
+// operation's result is the domain.NotionImportResult.
+func (s *ImportService) ImportNotion(ctx context.Context, workspaceID, userID uuid.UUID, archive io.ReaderAt, size int64, folder string) (*domain.Operation, error) {
+	prefix, err := domain.NotionImportFolder(folder)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkWorkspace(ctx, workspaceID, userID); err != nil {
+		return nil, err
+	}
+
+	content := make([]byte, size)
+	if _, err := archive.ReadAt(content, 0); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	if _, err := zip.NewReader(bytes.NewReader(content), size); err != nil {
+		return nil, fmt.Errorf("invalid archive: %w", err)
+	}
+
+	return s.operations.EnqueueWithUpload(ctx, NotionImportOperation, userID, &workspaceID, notionImportParams{Folder: prefix}, content, 0)
+}
+
+// checkWorkspace refuses imports by users who cannot edit the workspace,
+// and into archived workspaces.
+func (s *ImportService) checkWorkspace(ctx context.Context, workspaceID, userID uuid.UUID) error {
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleEditor)
+	if err != nil {
+		return err
+	}
+	if workspace.ArchivedAt.Valid {
+		return fmt.Errorf("workspace is archived")
+	}
+	return nil
+}
+
+// runNotionImport converts the export and writes its files one per step:
+// pages, then attachments, then downloaded images. Writing a file again
+// updates it, so a resumed import repeats at most the step it stopped in.
+func (s *ImportService) runNotionImport(ctx context.Context, run *OperationRun) (any, error) {
+	var params notionImportParams
+	if err := json.Unmarshal(run.Params, &params); err != nil {
+		return nil, fmt.Errorf("invalid import params: %w", err)
+	}
+	if run.WorkspaceID == nil {
+		return nil, fmt.Errorf("invalid import operation: no workspace")
+	}
+	workspaceID, userID := *run.WorkspaceID, run.UserID
+	log := s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "")
+
+	// Access may have changed while the operation was queued.
+	if err := s.checkWorkspace(ctx, workspaceID, userID); err != nil {
+		return nil, err
+	}
+
+	content, err := run.Upload(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %w", err)
+	}
+	export, err := notion.Convert(ctx, zr, notion.Options{
+		Folder:           params.Folder,
+		Fetch:            s.fetch,
+		MaxDownloadBytes: domain.MaxNotionDownloadBytes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	storageInfo, err := s.queries.GetWorkspaceStorageUsage(ctx, pgconv.UUIDToPg(workspaceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+	limits := domain.UserTier(storageInfo.Tier).GetFileLimits()
+
+	state := notionImportState{Result: &domain.NotionImportResult{
+		Folder:  strings.TrimSuffix(params.Folder, "/"),
+		Skipped: []domain.NotionImportIssue{},
+	}}
+	if run.StepsDone > 0 {
+		if err := json.Unmarshal(run.State, &state); err != nil {
+			return nil, fmt.Errorf("invalid import state: %w", err)
+		}
+	} else {
+		for _, issue := range export.Issues {
+			state.Result.Skipped = append(state.Result.Skipped, domain.NotionImportIssue{Path: issue.Path, Reason: issue.Reason})
+		}
+	}
+	result := state.Result
+
+	upload := func(path string, content []byte) (bool, error) {
+		_, err := s.files.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  workspaceID,
+			FilePath:     path,
+			Content:      content,
+			LastModified: time.Now(),
+			ClientID:     notionImportClientID,
+		}, userID)
+		if err == nil {
+			return true, nil
+		}
+		if rejectedUpload(err) {
+			result.Skipped = append(result.Skipped, domain.NotionImportIssue{Path: path, Reason: err.Error()})
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to import %s: %w", path, err)
+	}
+
+	// Each step is one file of the export, in this order.
+	var steps []func() error
+	for _, page := range export.Pages {
+		steps = append(steps, func() error {
+			ok, err := upload(page.Path, page.Content)
+			if ok {
+				result.Pages++
+			}
+			return err
+		})
+	}
+	for _, attachment := range export.Attachments {
+		steps = append(steps, func() error {
+			// Oversized attachments are refused before they are decompressed.
+			if err := limits.Check(attachment.Size(), 0, false); err != nil {
+				result.Skipped = append(result.Skipped, domain.NotionImportIssue{Path: attachment.Path, Reason: err.Error()})
+				return nil
+			}
+			content, err := attachment.Read()
+			if err != nil {
+				result.Skipped = append(result.Skipped, domain.NotionImportIssue{Path: attachment.Path, Reason: err.Error()})
+				return nil
+			}
+			ok, err := upload(attachment.Path, content)
+			if ok {
+				result.Attachments++
+			}
+			return err
+		})
+	}
+	for _, download := range export.Downloads {
+		steps = append(steps, func() error {
+			ok, err := upload(download.Path, download.Content)
+			if ok {
+				result.Downloaded++
+			}
+			return err
+		})
+	}
+
+	total := int32(len(steps))
+	for i := run.StepsDone; i < total; i++ {
+		if err := steps[i](); err != nil {
+			return nil, err
+		}
+		if err := run.Checkpoint(ctx, state, i+1, total); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Info("Imported Notion export",
+		"folder", result.Folder,
+		"pages", result.Pages,
+		"attachments", result.Attachments,
+		"downloaded", result.Downloaded,
+		"skipped", len(result.Skipped))
+	return result, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportService_ImportNotion(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	files := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	operations := NewOperationService(testDB.Queries(), testDB.Conn())
+	service := NewImportService(testDB.Queries(), files, operations).WithFetcher(func(context.Context, string) ([]byte, error) {
+		return []byte("GIF89a"), nil
+	})
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"Home 0123456789abcdef0123456789abcdef.md":                 "# Home\n\nTags: inbox\n\nSee [Plans](Home%200123456789abcdef0123456789abcdef/Plans.md)\n\n![logo](https://example.com/logo.gif)\n",
+		"Home 0123456789abcdef0123456789abcdef/Plans.md":           "# Plans\n",
+		"Home 0123456789abcdef0123456789abcdef/Video.mp4":          string(make([]byte, domain.TierFree.GetFileLimits().MaxFileSizeBytes+1)),
+		"Home 0123456789abcdef0123456789abcdef/Untitled Table.csv": "Name\n",
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	archive := bytes.NewReader(buf.Bytes())
+
+	op, err := service.ImportNotion(ctx, testData.FreeWorkspaceID, testData.FreeUserID, archive, archive.Size(), "")
+	require.NoError(t, err)
+	assert.Equal(t, NotionImportOperation, op.Kind)
+
+	finished, err := operations.RunPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, finished)
+
+	op, err = operations.GetOperation(ctx, op.ID, testData.FreeUserID)
+	require.NoError(t, err)
+	require.Equal(t, domain.OperationSucceeded, op.Status)
+	assert.Equal(t, int32(5), op.StepsTotal)
+	var result domain.NotionImportResult
+	require.NoError(t, json.Unmarshal(op.Result, &result))
+	assert.Equal(t, "Notion", result.Folder)
+	assert.Equal(t, 2, result.Pages)
+	assert.Equal(t, 1, result.Attachments)
+	assert.Equal(t, 1, result.Downloaded)
+	require.Len(t, result.Skipped, 1)
+	assert.Equal(t, "Notion/Home/Video.mp4", result.Skipped[0].Path)
+	assert.Contains(t, result.Skipped[0].Reason, "file too large")
+
+	home, err := files.GetFileContent(ctx, testData.FreeWorkspaceID, "Notion/Home.md", testData.FreeUserID)
+	require.NoError(t, err)
+	assert.Contains(t, string(home.Content), "tags:\n  - inbox\n")
+	assert.Contains(t, string(home.Content), "See [Plans](Home/Plans.md)")
+	assert.Contains(t, string(home.Content), "![logo](Home/logo.gif)")
+
+	_, err = files.GetFileContent(ctx, testData.FreeWorkspaceID, "Notion/Home/logo.gif", testData.FreeUserID)
+	assert.NoError(t, err)
+
+	var uploads int
+	require.NoError(t, testDB.Conn().QueryRow(ctx, "SELECT COUNT(*) FROM operation_uploads").Scan(&uploads))
+	assert.Zero(t, uploads, "the archive is deleted once the import finished")
+
+	t.Run("rejects archives that are not exports", func(t *testing.T) {
+		garbage := bytes.NewReader([]byte("not a zip"))
+		_, err := service.ImportNotion(ctx, testData.FreeWorkspaceID, testData.FreeUserID, garbage, garbage.Size(), "")
+		assert.ErrorContains(t, err, "invalid archive")
+	})
+
+	t.Run("viewers cannot import", func(t *testing.T) {
+		_, err := service.ImportNotion(ctx, testData.FreeWorkspaceID, testData.PremiumUserID, archive, archive.Size(), "")
+		assert.ErrorContains(t, err, "access denied")
+	})
+}
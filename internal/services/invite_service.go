@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/duckonomy/noture/pkg/email"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// InviteExpiry is how long an invitation link can be used.
+const InviteExpiry = 7 * 24 * time.Hour
+
+// InviteService invites people to workspaces by email. Unlike
+// MemberService.AddMember, the invited address need not have an account
+// yet: whoever follows the link signs in or registers, then accepts.
+type InviteService struct {
+	queries *db.Queries
+	conn    *pgx.Conn
+	mailer  email.Sender
+	baseURL string
+	log     *logger.Logger
+}
+
+// NewInviteService builds the invite service. baseURL is the server's
+// public URL, used for the links in invitation emails.
+func NewInviteService(queries *db.Queries, conn *pgx.Conn, mailer email.Sender, baseURL string) *InviteService {
+	return &InviteService{
+		queries: queries,
+		conn:    conn,
+		mailer:  mailer,
+		baseURL: baseURL,
+		log:     logger.New(),
+	}
+}
+
+// CreateInvite emails an invitation to req.Email. Only owners can invite.
+// Inviting an address that already has a pending invite replaces it, so
+// the earlier link stops working.
+func (s *InviteService) CreateInvite(ctx context.Context, workspaceID, userID uuid.UUID, req domain.CreateInviteRequest) (*domain.WorkspaceInvite, error) {
+	if !req.Role.Valid() {
+		return nil, fmt.Errorf("invalid role: %q", req.Role)
+	}
+	address, err := normalizeEmail(req.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireVerifiedEmail(ctx, s.queries, userID); err != nil {
+		return nil, err
+	}
+
+	if existing, err := s.queries.GetUserByEmail(ctx, address); err == nil {
+		_, err := s.queries.GetWorkspaceMember(ctx, db.GetWorkspaceMemberParams{
+			WorkspaceID: workspace.ID,
+			UserID:      existing.ID,
+		})
+		if err == nil {
+			return nil, fmt.Errorf("user is already a member")
+		}
+	}
+
+	inviter, err := s.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inviter: %w", err)
+	}
+
+	token, tokenHash, err := auth.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	row, err := s.queries.CreateWorkspaceInvite(ctx, db.CreateWorkspaceInviteParams{
+		WorkspaceID: workspace.ID,
+		Email:       address,
+		Role:        db.WorkspaceRole(req.Role),
+		TokenHash:   tokenHash,
+		InvitedBy:   inviter.ID,
+		ExpiresAt:   pgconv.TimeToPg(time.Now().Add(InviteExpiry)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invite: %w", err)
+	}
+
+	msg := email.Message{
+		To:      address,
+		Subject: fmt.Sprintf("%s invited you to %s on Noture", inviter.Email, workspace.Name),
+		Body: fmt.Sprintf("%s invited you to join the workspace %q as %s.\n\n"+
+			"Open this link to see the invitation, then sign in and accept it:\n\n%s/api/invites/%s\n\n"+
+			"The link expires on %s. If you did not expect it, you can ignore this email.\n",
+			inviter.Email, workspace.Name, req.Role, s.baseURL, token,
+			pgconv.PgToTime(row.ExpiresAt).UTC().Format("January 2, 2006")),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		// Nobody got the link, so don't list the invite as pending.
+		s.queries.DeleteWorkspaceInvite(ctx, db.DeleteWorkspaceInviteParams{WorkspaceID: row.WorkspaceID, ID: row.ID})
+		return nil, fmt.Errorf("failed to send invitation: %w", err)
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "").
+		Info("Sent workspace invite", "invite_id", pgconv.PgToUUID(row.ID), "role", req.Role)
+	return toDomainInvite(row), nil
+}
+
+// ListInvites lists a workspace's pending, unexpired invites. Only owners
+// can see them.
+func (s *InviteService) ListInvites(ctx context.Context, workspaceID, userID uuid.UUID) ([]domain.WorkspaceInvite, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.queries.ListWorkspaceInvites(ctx, pgconv.UUIDToPg(workspaceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invites: %w", err)
+	}
+
+	invites := make([]domain.WorkspaceInvite, len(rows))
+	for i, row := range rows {
+		invites[i] = *toDomainInvite(row)
+	}
+	return invites, nil
+}
+
+// RevokeInvite cancels a pending invite, so its link stops working.
+func (s *InviteService) RevokeInvite(ctx context.Context, workspaceID, userID, inviteID uuid.UUID) error {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner); err != nil {
+		return err
+	}
+
+	removed, err := s.queries.DeleteWorkspaceInvite(ctx, db.DeleteWorkspaceInviteParams{
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		ID:          pgconv.UUIDToPg(inviteID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke invite: %w", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("invite not found")
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "").
+		Info("Revoked workspace invite", "invite_id", inviteID)
+	return nil
+}
+
+// PreviewInvite describes the invite a token stands for, without
+// accepting it.
+func (s *InviteService) PreviewInvite(ctx context.Context, token string) (*domain.InvitePreview, error) {
+	row, err := s.queries.GetWorkspaceInviteByToken(ctx, auth.HashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("invite not found")
+	}
+
+	return &domain.InvitePreview{
+		WorkspaceID:    pgconv.PgToUUID(row.WorkspaceID),
+		WorkspaceName:  row.WorkspaceName,
+		Email:          row.Email,
+		Role:           domain.WorkspaceRole(row.Role),
+		InvitedByEmail: row.InvitedByEmail,
+		ExpiresAt:      pgconv.PgToTime(row.ExpiresAt),
+	}, nil
+}
+
+// AcceptInvite makes userID a member of the invite's workspace with the
+// invited role. The link is the proof of invitation, so the account
+// accepting it need not use the invited address.
+func (s *InviteService) AcceptInvite(ctx context.Context, token string, userID uuid.UUID) (*domain.WorkspaceMember, error) {
+	user, err := s.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	var member db.WorkspaceMember
+	err = inTx(ctx, s.conn, s.queries, "accept_invite", func(qtx *db.Queries) error {
+		invite, err := qtx.GetWorkspaceInviteByToken(ctx, auth.HashToken(token))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("invite not found")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get invite: %w", err)
+		}
+
+		accepted, err := qtx.AcceptWorkspaceInvite(ctx, db.AcceptWorkspaceInviteParams{ID: invite.ID, AcceptedBy: user.ID})
+		if err != nil {
+			return fmt.Errorf("failed to accept invite: %w", err)
+		}
+		if accepted == 0 {
+			return fmt.Errorf("invite not found")
+		}
+
+		member, err = qtx.AddWorkspaceMember(ctx, db.AddWorkspaceMemberParams{
+			WorkspaceID: invite.WorkspaceID,
+			UserID:      user.ID,
+			Role:        invite.Role,
+			InvitedBy:   invite.InvitedBy,
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return fmt.Errorf("user is already a member")
+			}
+			return fmt.Errorf("failed to add member: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceID := pgconv.PgToUUID(member.WorkspaceID)
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "").
+		Info("Accepted workspace invite", "role", member.Role)
+
+	return &domain.WorkspaceMember{
+		WorkspaceID: workspaceID,
+		UserID:      userID,
+		Email:       user.Email,
+		Role:        domain.WorkspaceRole(member.Role),
+		InvitedBy:   pgconv.PgToUUIDPtr(member.InvitedBy),
+		CreatedAt:   pgconv.PgToTime(member.CreatedAt),
+	}, nil
+}
+
+func toDomainInvite(row db.WorkspaceInvite) *domain.WorkspaceInvite {
+	return &domain.WorkspaceInvite{
+		ID:          pgconv.PgToUUID(row.ID),
+		WorkspaceID: pgconv.PgToUUID(row.WorkspaceID),
+		Email:       row.Email,
+		Role:        domain.WorkspaceRole(row.Role),
+		InvitedBy:   pgconv.PgToUUID(row.InvitedBy),
+		ExpiresAt:   pgconv.PgToTime(row.ExpiresAt),
+		CreatedAt:   pgconv.PgToTime(row.CreatedAt),
+	}
+}
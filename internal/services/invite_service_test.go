@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/email"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSender struct {
+	sent []email.Message
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg email.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+var inviteLink = regexp.MustCompile(`/api/invites/(\w+)`)
+
+func TestInviteService_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	mailer := &recordingSender{}
+	service := NewInviteService(testDB.Queries(), testDB.Conn(), mailer, "https://noture.test")
+	ctx := context.Background()
+
+	premiumUser, err := testDB.Queries().GetUserByID(ctx, pgconv.UUIDToPg(testData.PremiumUserID))
+	require.NoError(t, err)
+
+	var token string
+
+	t.Run("only owners invite", func(t *testing.T) {
+		_, err := service.CreateInvite(ctx, testData.FreeWorkspaceID, testData.PremiumUserID, domain.CreateInviteRequest{
+			Email: "someone@example.com",
+			Role:  domain.RoleViewer,
+		})
+		assert.ErrorContains(t, err, "access denied")
+	})
+
+	t.Run("invite emails a link", func(t *testing.T) {
+		invite, err := service.CreateInvite(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.CreateInviteRequest{
+			Email: premiumUser.Email,
+			Role:  domain.RoleEditor,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, premiumUser.Email, invite.Email)
+
+		require.Len(t, mailer.sent, 1)
+		assert.Equal(t, premiumUser.Email, mailer.sent[0].To)
+		match := inviteLink.FindStringSubmatch(mailer.sent[0].Body)
+		require.NotNil(t, match)
+		token = match[1]
+
+		invites, err := service.ListInvites(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Len(t, invites, 1)
+	})
+
+	t.Run("preview needs only the token", func(t *testing.T) {
+		preview, err := service.PreviewInvite(ctx, token)
+		require.NoError(t, err)
+		assert.Equal(t, testData.FreeWorkspaceID, preview.WorkspaceID)
+		assert.Equal(t, domain.RoleEditor, preview.Role)
+
+		_, err = service.PreviewInvite(ctx, "not-a-token")
+		assert.ErrorContains(t, err, "invite not found")
+	})
+
+	t.Run("accepting adds the member once", func(t *testing.T) {
+		member, err := service.AcceptInvite(ctx, token, testData.PremiumUserID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.RoleEditor, member.Role)
+		assert.Equal(t, testData.FreeUserID, *member.InvitedBy)
+
+		_, err = service.AcceptInvite(ctx, token, testData.PremiumUserID)
+		assert.ErrorContains(t, err, "invite not found")
+
+		_, err = service.CreateInvite(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.CreateInviteRequest{
+			Email: premiumUser.Email,
+			Role:  domain.RoleViewer,
+		})
+		assert.ErrorContains(t, err, "already a member")
+	})
+
+	t.Run("revoked links stop working", func(t *testing.T) {
+		invite, err := service.CreateInvite(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.CreateInviteRequest{
+			Email: "new@example.com",
+			Role:  domain.RoleViewer,
+		})
+		require.NoError(t, err)
+		match := inviteLink.FindStringSubmatch(mailer.sent[len(mailer.sent)-1].Body)
+		require.NotNil(t, match)
+
+		require.NoError(t, service.RevokeInvite(ctx, testData.FreeWorkspaceID, testData.FreeUserID, invite.ID))
+		_, err = service.PreviewInvite(ctx, match[1])
+		assert.ErrorContains(t, err, "invite not found")
+		assert.ErrorContains(t, service.RevokeInvite(ctx, testData.FreeWorkspaceID, testData.FreeUserID, invite.ID), "invite not found")
+	})
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyedMutex(t *testing.T) {
+	k := newKeyedMutex()
+
+	t.Run("serializes holders of one key", func(t *testing.T) {
+		var wg sync.WaitGroup
+		active, maxActive := 0, 0
+		var mu sync.Mutex
+
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				unlock := k.Lock("ws/notes.org")
+				defer unlock()
+
+				mu.Lock()
+				active++
+				maxActive = max(maxActive, active)
+				mu.Unlock()
+
+				mu.Lock()
+				active--
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 1, maxActive)
+		assert.Equal(t, 0, k.len(), "released keys are dropped")
+	})
+
+	t.Run("different keys do not block each other", func(t *testing.T) {
+		unlockA := k.Lock("ws/a.md")
+		unlockB := k.Lock("ws/b.md")
+		assert.Equal(t, 2, k.len())
+		unlockA()
+		unlockB()
+		assert.Equal(t, 0, k.len())
+	})
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/markdown"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// maxLinkRewrites bounds the links one save-set may rewrite.
+const maxLinkRewrites = 10000
+
+// movedLinks are the links naming files a save-set moves, with the path
+// each file moves to, and the notes holding them.
+type movedLinks struct {
+	links   map[movedLinkKey]string
+	sources []string
+}
+
+// movedLinkKey identifies a link as indexed, so it can be found again in
+// the note's content.
+type movedLinkKey struct {
+	source     string
+	line       int
+	target     string
+	candidates string
+}
+
+func newMovedLinkKey(source string, line int, target string, candidates []string) movedLinkKey {
+	return movedLinkKey{source: source, line: line, target: target, candidates: strings.Join(candidates, "\x00")}
+}
+
+// findMovedLinks finds the links naming the files the save-set moves, as
+// they resolve before the moves. Links in files the save-set itself
+// writes, moves or deletes are left to the client.
+func findMovedLinks(ctx context.Context, qtx *db.Queries, workspaceID uuid.UUID, req domain.SaveSetRequest) (*movedLinks, error) {
+	found := &movedLinks{links: map[movedLinkKey]string{}}
+	moves := map[string]string{}
+	var from []string
+	for _, op := range req.Operations {
+		if op.Op == domain.SaveOpMove {
+			moves[op.FilePath] = op.NewPath
+			from = append(from, op.FilePath)
+		}
+	}
+	if len(from) == 0 {
+		return found, nil
+	}
+
+	rows, err := qtx.ListLinksToPaths(ctx, db.ListLinksToPathsParams{
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		FilePaths:   from,
+		MaxLinks:    maxLinkRewrites + 1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links: %w", err)
+	}
+	if len(rows) > maxLinkRewrites {
+		return nil, fmt.Errorf("invalid save-set: more than %d links to rewrite; move without rewrite_links", maxLinkRewrites)
+	}
+	lists := make([][]string, len(rows))
+	for i, row := range rows {
+		lists[i] = row.Candidates
+	}
+	targets, err := resolveLinks(ctx, qtx, pgconv.UUIDToPg(workspaceID), lists)
+	if err != nil {
+		return nil, err
+	}
+
+	touched := map[string]bool{}
+	for _, path := range req.Paths() {
+		touched[path] = true
+	}
+	sources := map[string]bool{}
+	for i, row := range rows {
+		to, ok := moves[targets[i]]
+		if !ok || touched[row.FilePath] {
+			continue
+		}
+		found.links[newMovedLinkKey(row.FilePath, int(row.Line), row.Target, row.Candidates)] = to
+		sources[row.FilePath] = true
+	}
+	for source := range sources {
+		found.sources = append(found.sources, source)
+	}
+	sort.Strings(found.sources)
+	return found, nil
+}
+
+// rewriteMovedLinks points the links findMovedLinks found at the files'
+// new paths, once moved, and saves each note that changes as a new
+// version, the way a write of the save-set is. It returns the results for
+// those notes and by how much their size changed.
+func (s *FileService) rewriteMovedLinks(ctx context.Context, qtx *db.Queries, workspaceID, userID uuid.UUID, found *movedLinks) ([]domain.SaveSetFileResult, []writtenFile, int64, error) {
+	var results []domain.SaveSetFileResult
+	var written []writtenFile
+	var sizeDelta int64
+	for _, source := range found.sources {
+		err := qtx.LockFilePath(ctx, db.LockFilePathParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			FilePath:    source,
+		})
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("failed to lock file: %w", err)
+		}
+		file, err := qtx.GetFile(ctx, db.GetFileParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			FilePath:    source,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("failed to get file: %w", err)
+		}
+		content, err := s.blobs.Get(ctx, file.ContentHash)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("failed to load file content: %w", err)
+		}
+
+		rewritten, err := s.rewriteNoteLinks(ctx, qtx, file, content, found)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if bytes.Equal(rewritten, content) {
+			continue
+		}
+
+		result := domain.SaveSetFileResult{Op: domain.SaveOpWrite, FilePath: source}
+		op := domain.SaveSetOperation{Op: domain.SaveOpWrite, FilePath: source, Content: rewritten}
+		saved, err := s.saveSetWrite(ctx, qtx, workspaceID, userID, op, &file, &result)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		results = append(results, result)
+		written = append(written, writtenFile{saved, rewritten})
+		sizeDelta += int64(len(rewritten)) - file.SizeBytes
+	}
+	return results, written, sizeDelta, nil
+}
+
+// rewriteNoteLinks returns the content of file with its moved links
+// rewritten. A new target keeps the style of the old one where it can,
+// and is only used if it resolves to the moved file; the root-relative
+// path always does.
+func (s *FileService) rewriteNoteLinks(ctx context.Context, qtx *db.Queries, file db.File, content []byte, found *movedLinks) ([]byte, error) {
+	type edit struct {
+		noteLink
+		to      string
+		options []string
+	}
+	var edits []edit
+	var lists [][]string
+	format := s.DetectFileFormat(file.FilePath, content)
+	for _, link := range noteLinks(file.FilePath, format, content) {
+		to, ok := found.links[newMovedLinkKey(file.FilePath, link.Line, link.Target, link.candidates)]
+		if !ok {
+			continue
+		}
+		e := edit{noteLink: link, to: to, options: linkTargetOptions(link.kind, file.FilePath, link.Target, to)}
+		for _, option := range e.options {
+			candidates := markdown.WikiLinkCandidates
+			if link.kind == relativeLink {
+				candidates = markdown.RelativeLinkCandidates
+			}
+			lists = append(lists, candidates(file.FilePath, option))
+		}
+		edits = append(edits, e)
+	}
+	if len(edits) == 0 {
+		return content, nil
+	}
+
+	resolved, err := resolveLinks(ctx, qtx, file.WorkspaceID, lists)
+	if err != nil {
+		return nil, err
+	}
+	type editKey struct {
+		kind   noteLinkKind
+		line   int
+		target string
+	}
+	replacements := map[editKey]string{}
+	i := 0
+	for _, e := range edits {
+		key := editKey{e.kind, e.Line, e.Target}
+		for _, option := range e.options {
+			if _, done := replacements[key]; !done && resolved[i] == e.to {
+				replacements[key] = option
+			}
+			i++
+		}
+	}
+
+	replace := func(kind noteLinkKind) func(markdown.Link) (string, bool) {
+		return func(link markdown.Link) (string, bool) {
+			target, ok := replacements[editKey{kind, link.Line, link.Target}]
+			return target, ok
+		}
+	}
+	switch format {
+	case domain.FormatMarkdown:
+		content = markdown.RewriteWikiLinks(content, replace(wikiLink))
+		content = markdown.RewriteRelativeLinks(content, replace(relativeLink))
+	case domain.FormatOrgMode:
+		content = markdown.RewriteOrgLinks(content, replace(orgLink))
+	}
+	return content, nil
+}
+
+// linkTargetOptions lists targets naming to for a link in the note at
+// source that was written as target, preferred first. Wiki-style links
+// keep a heading, and leave out the extension if target did; relative
+// links keep their fragment.
+func linkTargetOptions(kind noteLinkKind, source, target, to string) []string {
+	dir := path.Dir(source)
+	if kind == relativeLink {
+		suffix := ""
+		if i := strings.IndexAny(target, "#?"); i >= 0 {
+			suffix = target[i:]
+		}
+		return []string{strings.ReplaceAll(relativePath(dir, to), " ", "%20") + suffix}
+	}
+
+	heading := ""
+	if kind == wikiLink {
+		if i := strings.Index(target, "#"); i >= 0 {
+			target, heading = target[:i], target[i:]
+		}
+	}
+	name := to
+	if ext := path.Ext(to); path.Ext(target) == "" && (ext == ".md" || ext == ".org") {
+		name = strings.TrimSuffix(to, ext)
+	}
+
+	var options []string
+	if !strings.Contains(target, "/") {
+		options = append(options, path.Base(name))
+	}
+	options = append(options, relativePath(dir, name), name, "/"+to)
+	seen := map[string]bool{}
+	var unique []string
+	for _, option := range options {
+		if !seen[option] {
+			seen[option] = true
+			unique = append(unique, option+heading)
+		}
+	}
+	return unique
+}
+
+// relativePath returns the path p, from the workspace root, relative to
+// the folder dir.
+func relativePath(dir, p string) string {
+	if dir == "." {
+		return p
+	}
+	from, to := strings.Split(dir, "/"), strings.Split(p, "/")
+	common := 0
+	for common < len(from) && common < len(to)-1 && from[common] == to[common] {
+		common++
+	}
+	return strings.Repeat("../", len(from)-common) + strings.Join(to[common:], "/")
+}
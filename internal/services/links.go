@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/markdown"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Weights of the connections between related notes.
+const (
+	relatedLinkWeight       = 3
+	relatedTagWeight        = 2
+	relatedSharedLinkWeight = 1
+)
+
+const (
+	// maxRelatedLinks bounds the links read to rank related notes, so a
+	// note everything links to stays cheap to ask about.
+	maxRelatedLinks = 5000
+	// maxRelatedTagMatches bounds the notes read that share tags.
+	maxRelatedTagMatches = 500
+	// maxTagLength is the longest tag indexed.
+	maxTagLength = 255
+)
+
+// Kinds of links between notes, which name their targets differently.
+type noteLinkKind int
+
+const (
+	wikiLink noteLinkKind = iota
+	relativeLink
+	orgLink
+)
+
+// noteLink is a link in a note with the paths its target may name, most
+// specific first.
+type noteLink struct {
+	markdown.Link
+	kind       noteLinkKind
+	candidates []string
+}
+
+// noteLinks returns the links in the content of the note at filePath that
+// may name a file. Formats without links have none.
+func noteLinks(filePath string, format domain.FileFormat, content []byte) []noteLink {
+	var links []noteLink
+	add := func(kind noteLinkKind, found []markdown.Link, candidates func(notePath, target string) []string) {
+		for _, link := range found {
+			if c := candidates(filePath, link.Target); len(c) > 0 {
+				links = append(links, noteLink{Link: link, kind: kind, candidates: c})
+			}
+		}
+	}
+	switch format {
+	case domain.FormatMarkdown:
+		add(wikiLink, markdown.WikiLinks(content), markdown.WikiLinkCandidates)
+		add(relativeLink, markdown.RelativeLinks(content), markdown.RelativeLinkCandidates)
+	case domain.FormatOrgMode:
+		add(orgLink, markdown.OrgLinks(content), markdown.WikiLinkCandidates)
+	}
+	return links
+}
+
+// syncFileLinks replaces the links and tags indexed for file with those
+// now in its content. Formats without links leave it with none.
+func syncFileLinks(ctx context.Context, qtx *db.Queries, file db.File, format domain.FileFormat, content []byte) error {
+	var tags []string
+	switch format {
+	case domain.FormatMarkdown:
+		tags = markdown.Tags(content)
+	case domain.FormatOrgMode:
+		tags = markdown.OrgTags(content)
+	}
+
+	if err := qtx.DeleteFileLinks(ctx, file.ID); err != nil {
+		return fmt.Errorf("failed to clear links: %w", err)
+	}
+	for _, link := range noteLinks(file.FilePath, format, content) {
+		err := qtx.InsertNoteLink(ctx, db.InsertNoteLinkParams{
+			FileID:      file.ID,
+			WorkspaceID: file.WorkspaceID,
+			Line:        int32(link.Line),
+			Target:      link.Target,
+			Candidates:  link.candidates,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store link: %w", err)
+		}
+	}
+
+	if err := qtx.DeleteFileTags(ctx, file.ID); err != nil {
+		return fmt.Errorf("failed to clear tags: %w", err)
+	}
+	for _, tag := range tags {
+		if len(tag) > maxTagLength {
+			continue
+		}
+		err := qtx.InsertNoteTag(ctx, db.InsertNoteTagParams{
+			FileID:      file.ID,
+			WorkspaceID: file.WorkspaceID,
+			Tag:         tag,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store tag: %w", err)
+		}
+	}
+	return nil
+}
+
+// resolveLinks returns the path each list of link candidates names: the
+// first that exists, or "" when none does.
+func resolveLinks(ctx context.Context, queries *db.Queries, workspaceID pgtype.UUID, lists [][]string) ([]string, error) {
+	seen := map[string]bool{}
+	var paths []string
+	for _, candidates := range lists {
+		for _, candidate := range candidates {
+			if !seen[candidate] {
+				seen[candidate] = true
+				paths = append(paths, candidate)
+			}
+		}
+	}
+
+	exists := map[string]bool{}
+	for start := 0; start < len(paths); start += MaxFileLookupPaths {
+		rows, err := queries.LookupFiles(ctx, db.LookupFilesParams{
+			WorkspaceID: workspaceID,
+			FilePaths:   paths[start:min(start+MaxFileLookupPaths, len(paths))],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve links: %w", err)
+		}
+		for _, row := range rows {
+			exists[row.FilePath] = true
+		}
+	}
+
+	resolved := make([]string, len(lists))
+	for i, candidates := range lists {
+		for _, candidate := range candidates {
+			if exists[candidate] {
+				resolved[i] = candidate
+				break
+			}
+		}
+	}
+	return resolved, nil
+}
+
+// BrokenLinks reports the links of the workspace's notes that resolve to
+// no file, so they can be fixed or removed. Links are as indexed when each
+// note was last written, and checked against the files as they are now.
+func (s *FileService) BrokenLinks(ctx context.Context, workspaceID, userID uuid.UUID) (*domain.BrokenLinkReport, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	rows, err := retryRead(ctx, "list_broken_links", func() ([]db.ListBrokenLinksRow, error) {
+		return s.queries.ListBrokenLinks(db.PreferReplica(ctx), db.ListBrokenLinksParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			MaxLinks:    domain.MaxBrokenLinks + 1,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list broken links: %w", err)
+	}
+
+	report := &domain.BrokenLinkReport{Links: []domain.BrokenLink{}}
+	if len(rows) > domain.MaxBrokenLinks {
+		rows = rows[:domain.MaxBrokenLinks]
+		report.Truncated = true
+	}
+	for _, row := range rows {
+		report.Links = append(report.Links, domain.BrokenLink{
+			FilePath: row.FilePath,
+			Line:     row.Line,
+			Target:   row.Target,
+		})
+	}
+	report.Count = len(report.Links)
+	return report, nil
+}
+
+// RelatedNotes ranks the notes connected to a note, for suggesting links
+// while writing: those it links to or that link to it, those sharing its
+// tags and those linking to the same notes. Links and tags are as indexed
+// when each note was last written.
+func (s *FileService) RelatedNotes(ctx context.Context, workspaceID uuid.UUID, filePath string, userID uuid.UUID, limit int) ([]domain.RelatedNote, error) {
+	if limit == 0 {
+		limit = domain.DefaultRelatedNotes
+	} else if limit < 0 || limit > domain.MaxRelatedNotes {
+		return nil, fmt.Errorf("invalid limit: use 1 to %d", domain.MaxRelatedNotes)
+	}
+
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	ctx = db.PreferReplica(ctx)
+	file, err := retryRead(ctx, "get_file", func() (db.File, error) {
+		return s.queries.GetFile(ctx, db.GetFileParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			FilePath:    filePath,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("file not found: %w", err)
+	}
+
+	related := map[string]*domain.RelatedNote{}
+	note := func(path string) *domain.RelatedNote {
+		if related[path] == nil {
+			related[path] = &domain.RelatedNote{FilePath: path}
+		}
+		return related[path]
+	}
+
+	tags, err := s.queries.ListFileTags(ctx, file.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	if len(tags) > 0 {
+		rows, err := s.queries.ListFilesSharingTags(ctx, db.ListFilesSharingTagsParams{
+			WorkspaceID: file.WorkspaceID,
+			FileID:      file.ID,
+			Tags:        tags,
+			MaxFiles:    maxRelatedTagMatches,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list notes sharing tags: %w", err)
+		}
+		for _, row := range rows {
+			note(row.FilePath).SharedTags = row.Tags
+		}
+	}
+
+	links, err := s.queries.ListFileLinks(ctx, file.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links: %w", err)
+	}
+	lists := make([][]string, len(links))
+	for i, link := range links {
+		lists[i] = link.Candidates
+	}
+	targets, err := resolveLinks(ctx, s.queries, file.WorkspaceID, lists)
+	if err != nil {
+		return nil, err
+	}
+	// Links to attachments, such as embedded images, connect no notes.
+	linked := map[string]bool{}
+	for _, target := range targets {
+		if target != "" && target != filePath && s.isNote(target) {
+			linked[target] = true
+			note(target).LinksTo = true
+		}
+	}
+
+	paths := []string{filePath}
+	for target := range linked {
+		paths = append(paths, target)
+	}
+	incoming, err := s.queries.ListLinksToPaths(ctx, db.ListLinksToPathsParams{
+		WorkspaceID: file.WorkspaceID,
+		FilePaths:   paths,
+		MaxLinks:    maxRelatedLinks,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links: %w", err)
+	}
+	lists = make([][]string, len(incoming))
+	for i, link := range incoming {
+		lists[i] = link.Candidates
+	}
+	targets, err = resolveLinks(ctx, s.queries, file.WorkspaceID, lists)
+	if err != nil {
+		return nil, err
+	}
+	shared := map[string]map[string]bool{}
+	for i, link := range incoming {
+		switch target := targets[i]; {
+		case link.FilePath == filePath:
+		case target == filePath:
+			note(link.FilePath).LinkedFrom = true
+		case linked[target]:
+			if shared[link.FilePath] == nil {
+				shared[link.FilePath] = map[string]bool{}
+			}
+			shared[link.FilePath][target] = true
+		}
+	}
+	for path, targets := range shared {
+		note(path).SharedLinks = len(targets)
+	}
+
+	notes := make([]domain.RelatedNote, 0, len(related))
+	for _, n := range related {
+		n.Score = relatedTagWeight*len(n.SharedTags) + relatedSharedLinkWeight*n.SharedLinks
+		if n.LinksTo {
+			n.Score += relatedLinkWeight
+		}
+		if n.LinkedFrom {
+			n.Score += relatedLinkWeight
+		}
+		notes = append(notes, *n)
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		if notes[i].Score != notes[j].Score {
+			return notes[i].Score > notes[j].Score
+		}
+		return notes[i].FilePath < notes[j].FilePath
+	})
+	if len(notes) > limit {
+		notes = notes[:limit]
+	}
+	return notes, nil
+}
+
+// isNote reports whether the file at path is a note, judging by its name.
+func (s *FileService) isNote(path string) bool {
+	format := s.DetectFileFormat(path, nil)
+	return format == domain.FormatMarkdown || format == domain.FormatOrgMode
+}
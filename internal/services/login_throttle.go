@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+)
+
+const (
+	accountThrottlePrefix = "account:"
+	ipThrottlePrefix      = "ip:"
+)
+
+// LoginThrottle slows down guessing of passwords and device codes by
+// making accounts and client IPs that keep failing wait before trying
+// again. Failures are kept in the database so that every server sees
+// them.
+type LoginThrottle struct {
+	queries *db.Queries
+	policy  domain.LoginThrottlePolicy
+	log     *logger.Logger
+}
+
+func NewLoginThrottle(queries *db.Queries, policy domain.LoginThrottlePolicy) *LoginThrottle {
+	return &LoginThrottle{
+		queries: queries,
+		policy:  policy,
+		log:     logger.New(),
+	}
+}
+
+// AccountThrottleKey counts failures against the account of email. The
+// address is hashed, as any string may be tried.
+func AccountThrottleKey(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return accountThrottlePrefix + hex.EncodeToString(sum[:])
+}
+
+// IPThrottleKey counts failures against a client IP. It returns "" for
+// anything that is not an IP address.
+func IPThrottleKey(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	return ipThrottlePrefix + parsed.String()
+}
+
+// Wait returns how long the caller must wait before another attempt
+// counted against keys, or zero when it may try now.
+func (t *LoginThrottle) Wait(ctx context.Context, keys ...string) (time.Duration, error) {
+	if !t.policy.Enabled() {
+		return 0, nil
+	}
+	locked, err := t.queries.GetAuthLockouts(ctx, compactKeys(keys))
+	if err != nil {
+		return 0, fmt.Errorf("failed to check sign-in lockouts: %w", err)
+	}
+	var wait time.Duration
+	for _, row := range locked {
+		wait = max(wait, time.Until(row.LockedUntil.Time))
+	}
+	return wait, nil
+}
+
+// Fail records a failed attempt by method against each of keys, locking
+// those that have run out of free attempts.
+func (t *LoginThrottle) Fail(ctx context.Context, method string, keys ...string) error {
+	if !t.policy.Enabled() {
+		return nil
+	}
+	resetBefore := time.Now().Add(-t.policy.ResetAfter)
+
+	for _, key := range compactKeys(keys) {
+		row, err := t.queries.RecordAuthFailure(ctx, db.RecordAuthFailureParams{
+			Key:         key,
+			ResetBefore: pgconv.TimeToPg(resetBefore),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record sign-in failure: %w", err)
+		}
+
+		free := t.policy.AccountFreeAttempts
+		if strings.HasPrefix(key, ipThrottlePrefix) {
+			free = t.policy.IPFreeAttempts
+		}
+		delay := t.policy.Delay(int(row.Failures), free)
+		if delay == 0 {
+			continue
+		}
+		if err := t.queries.LockAuthKey(ctx, db.LockAuthKeyParams{
+			Key:         key,
+			LockedUntil: pgconv.TimeToPg(time.Now().Add(delay)),
+		}); err != nil {
+			return fmt.Errorf("failed to lock sign-ins: %w", err)
+		}
+
+		event := "login_throttled"
+		if delay == t.policy.MaxLockout {
+			event = "login_locked_out"
+		}
+		t.log.WithContext(ctx).LogAuthEvent(event, "", method)
+		t.log.WithContext(ctx).Warn("Throttled sign-in attempts",
+			"key", key,
+			"failures", row.Failures,
+			"locked_for", delay.String())
+	}
+	return nil
+}
+
+// Succeed forgets the failures counted against key after a successful
+// sign-in. Callers pass the account's key only: clearing an IP's on
+// success would let an attacker reset it with an account of their own.
+func (t *LoginThrottle) Succeed(ctx context.Context, key string) error {
+	if !t.policy.Enabled() || key == "" {
+		return nil
+	}
+	if err := t.queries.ClearAuthFailures(ctx, key); err != nil {
+		return fmt.Errorf("failed to clear sign-in failures: %w", err)
+	}
+	return nil
+}
+
+// Purge deletes the failures that are no longer counted and whose
+// lockouts have ended, and returns how many it deleted.
+func (t *LoginThrottle) Purge(ctx context.Context) (int64, error) {
+	olderThan := max(t.policy.ResetAfter, t.policy.MaxLockout)
+	n, err := t.queries.DeleteStaleAuthFailures(ctx, pgconv.TimeToPg(time.Now().Add(-olderThan)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete stale sign-in failures: %w", err)
+	}
+	return n, nil
+}
+
+func compactKeys(keys []string) []string {
+	compact := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key != "" {
+			compact = append(compact, key)
+		}
+	}
+	return compact
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginThrottle_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+
+	throttle := NewLoginThrottle(testDB.Queries(), domain.LoginThrottlePolicy{
+		AccountFreeAttempts: 2,
+		IPFreeAttempts:      3,
+		BaseDelay:           time.Minute,
+		MaxLockout:          time.Hour,
+		ResetAfter:          time.Hour,
+	})
+	ctx := context.Background()
+	account := AccountThrottleKey(" Someone@Example.com")
+	ip := IPThrottleKey("203.0.113.7")
+
+	assert.Equal(t, AccountThrottleKey("someone@example.com"), account)
+	assert.Empty(t, IPThrottleKey("not-an-ip"))
+
+	for range 2 {
+		require.NoError(t, throttle.Fail(ctx, "password", account, ip))
+	}
+	wait, err := throttle.Wait(ctx, account, ip)
+	require.NoError(t, err)
+	assert.Zero(t, wait, "free attempts are not throttled")
+
+	require.NoError(t, throttle.Fail(ctx, "password", account, ip))
+	wait, err = throttle.Wait(ctx, account)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute.Seconds(), wait.Seconds(), 5)
+
+	wait, err = throttle.Wait(ctx, ip)
+	require.NoError(t, err)
+	assert.Zero(t, wait, "client IPs get more free attempts")
+
+	require.NoError(t, throttle.Succeed(ctx, account))
+	wait, err = throttle.Wait(ctx, account, "")
+	require.NoError(t, err)
+	assert.Zero(t, wait, "a successful login clears the account")
+
+	n, err := throttle.Purge(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n, "recent failures are kept")
+}
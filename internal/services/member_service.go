@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// authorizeWorkspace loads a workspace and checks that userID is a member
+// with at least the needed role.
+func authorizeWorkspace(ctx context.Context, queries *db.Queries, workspaceID, userID uuid.UUID, need domain.WorkspaceRole) (db.Workspace, domain.WorkspaceRole, error) {
+	workspace, err := retryRead(ctx, "get_workspace", func() (db.Workspace, error) {
+		return queries.GetWorkspaceByID(ctx, pgconv.UUIDToPg(workspaceID))
+	})
+	if err != nil {
+		return db.Workspace{}, "", fmt.Errorf("workspace not found: %w", err)
+	}
+
+	member, err := retryRead(ctx, "get_workspace_member", func() (db.WorkspaceMember, error) {
+		return queries.GetWorkspaceMember(ctx, db.GetWorkspaceMemberParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			UserID:      pgconv.UUIDToPg(userID),
+		})
+	})
+	if err != nil {
+		return db.Workspace{}, "", fmt.Errorf("access denied: not a member of this workspace")
+	}
+
+	role := domain.WorkspaceRole(member.Role)
+	if !role.Includes(need) {
+		return db.Workspace{}, "", fmt.Errorf("access denied: %s role required", need)
+	}
+
+	return workspace, role, nil
+}
+
+type MemberService struct {
+	queries *db.Queries
+	log     *logger.Logger
+}
+
+func NewMemberService(queries *db.Queries) *MemberService {
+	return &MemberService{
+		queries: queries,
+		log:     logger.New(),
+	}
+}
+
+func (s *MemberService) ListMembers(ctx context.Context, workspaceID, userID uuid.UUID) ([]domain.WorkspaceMember, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.queries.ListWorkspaceMembers(ctx, pgconv.UUIDToPg(workspaceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+
+	members := make([]domain.WorkspaceMember, len(rows))
+	for i, row := range rows {
+		members[i] = domain.WorkspaceMember{
+			WorkspaceID: pgconv.PgToUUID(row.WorkspaceID),
+			UserID:      pgconv.PgToUUID(row.UserID),
+			Email:       row.Email,
+			Role:        domain.WorkspaceRole(row.Role),
+			InvitedBy:   pgconv.PgToUUIDPtr(row.InvitedBy),
+			CreatedAt:   pgconv.PgToTime(row.CreatedAt),
+		}
+	}
+	return members, nil
+}
+
+// AddMember gives an existing user access to the workspace. Only owners
+// can add members.
+func (s *MemberService) AddMember(ctx context.Context, workspaceID, userID uuid.UUID, req domain.AddMemberRequest) (*domain.WorkspaceMember, error) {
+	log := s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "")
+
+	if !req.Role.Valid() {
+		return nil, fmt.Errorf("invalid role: %q", req.Role)
+	}
+
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner); err != nil {
+		return nil, err
+	}
+	if err := requireVerifiedEmail(ctx, s.queries, userID); err != nil {
+		return nil, err
+	}
+
+	user, err := s.queries.GetUserByEmail(ctx, strings.ToLower(strings.TrimSpace(req.Email)))
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %s", req.Email)
+	}
+
+	member, err := s.queries.AddWorkspaceMember(ctx, db.AddWorkspaceMemberParams{
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		UserID:      user.ID,
+		Role:        db.WorkspaceRole(req.Role),
+		InvitedBy:   pgconv.UUIDToPg(userID),
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("user is already a member")
+		}
+		return nil, fmt.Errorf("failed to add member: %w", err)
+	}
+
+	log.Info("Added workspace member", "member_id", pgconv.PgToUUID(user.ID), "role", req.Role)
+
+	return &domain.WorkspaceMember{
+		WorkspaceID: workspaceID,
+		UserID:      pgconv.PgToUUID(member.UserID),
+		Email:       user.Email,
+		Role:        domain.WorkspaceRole(member.Role),
+		InvitedBy:   pgconv.PgToUUIDPtr(member.InvitedBy),
+		CreatedAt:   pgconv.PgToTime(member.CreatedAt),
+	}, nil
+}
+
+func (s *MemberService) UpdateMemberRole(ctx context.Context, workspaceID, userID, memberID uuid.UUID, role domain.WorkspaceRole) error {
+	if !role.Valid() {
+		return fmt.Errorf("invalid role: %q", role)
+	}
+
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	if pgconv.PgToUUID(workspace.UserID) == memberID {
+		return fmt.Errorf("cannot change the role of the workspace owner")
+	}
+
+	_, err = s.queries.UpdateWorkspaceMemberRole(ctx, db.UpdateWorkspaceMemberRoleParams{
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		UserID:      pgconv.UUIDToPg(memberID),
+		Role:        db.WorkspaceRole(role),
+	})
+	if err != nil {
+		return fmt.Errorf("member not found: %w", err)
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "").
+		Info("Changed workspace member role", "member_id", memberID, "role", role)
+	return nil
+}
+
+// RemoveMember revokes a member's access. Owners can remove anyone but the
+// workspace owner; any member can remove themselves.
+func (s *MemberService) RemoveMember(ctx context.Context, workspaceID, userID, memberID uuid.UUID) error {
+	need := domain.RoleOwner
+	if memberID == userID {
+		need = domain.RoleViewer
+	}
+
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, need)
+	if err != nil {
+		return err
+	}
+
+	if pgconv.PgToUUID(workspace.UserID) == memberID {
+		return fmt.Errorf("cannot remove the workspace owner")
+	}
+
+	removed, err := s.queries.RemoveWorkspaceMember(ctx, db.RemoveWorkspaceMemberParams{
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		UserID:      pgconv.UUIDToPg(memberID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("member not found")
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "").
+		Info("Removed workspace member", "member_id", memberID)
+	return nil
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemberService_Roles_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewMemberService(testDB.Queries())
+	workspaceService := NewWorkspaceService(testDB.Queries(), storage.NewPostgresBackend(testDB.Queries()))
+	fileService := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	premiumUser, err := testDB.Queries().GetUserByID(ctx, pgconv.UUIDToPg(testData.PremiumUserID))
+	require.NoError(t, err)
+
+	upload := func() error {
+		_, err := fileService.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     "shared.md",
+			Content:      []byte("# shared"),
+			LastModified: time.Now(),
+		}, testData.PremiumUserID)
+		return err
+	}
+
+	t.Run("creator is the owner", func(t *testing.T) {
+		members, err := service.ListMembers(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		require.NoError(t, err)
+		require.Len(t, members, 1)
+		assert.Equal(t, testData.FreeUserID, members[0].UserID)
+		assert.Equal(t, domain.RoleOwner, members[0].Role)
+	})
+
+	t.Run("viewer can read but not write", func(t *testing.T) {
+		_, err := service.AddMember(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.AddMemberRequest{
+			Email: premiumUser.Email,
+			Role:  domain.RoleViewer,
+		})
+		require.NoError(t, err)
+
+		workspace, err := workspaceService.GetWorkspaceByID(ctx, testData.FreeWorkspaceID, testData.PremiumUserID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.RoleViewer, workspace.Role)
+
+		err = upload()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "access denied: editor role required")
+	})
+
+	t.Run("editor can write but not manage members", func(t *testing.T) {
+		require.NoError(t, service.UpdateMemberRole(ctx, testData.FreeWorkspaceID, testData.FreeUserID, testData.PremiumUserID, domain.RoleEditor))
+		require.NoError(t, upload())
+
+		_, err := service.AddMember(ctx, testData.FreeWorkspaceID, testData.PremiumUserID, domain.AddMemberRequest{
+			Email: premiumUser.Email,
+			Role:  domain.RoleOwner,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "owner role required")
+
+		err = workspaceService.DeleteWorkspace(ctx, testData.FreeWorkspaceID, testData.PremiumUserID)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "owner role required")
+	})
+
+	t.Run("shared workspace is listed for the member", func(t *testing.T) {
+		workspaces, err := workspaceService.GetWorkspacesByUser(ctx, testData.PremiumUserID)
+		require.NoError(t, err)
+		require.Len(t, workspaces, 1)
+		assert.Equal(t, testData.FreeWorkspaceID, workspaces[0].ID)
+		assert.Equal(t, domain.RoleEditor, workspaces[0].Role)
+	})
+
+	t.Run("workspace owner cannot be demoted or removed", func(t *testing.T) {
+		err := service.UpdateMemberRole(ctx, testData.FreeWorkspaceID, testData.FreeUserID, testData.FreeUserID, domain.RoleViewer)
+		assert.Error(t, err)
+
+		err = service.RemoveMember(ctx, testData.FreeWorkspaceID, testData.FreeUserID, testData.FreeUserID)
+		assert.Error(t, err)
+	})
+
+	t.Run("member can leave", func(t *testing.T) {
+		require.NoError(t, service.RemoveMember(ctx, testData.FreeWorkspaceID, testData.PremiumUserID, testData.PremiumUserID))
+
+		_, err := workspaceService.GetWorkspaceByID(ctx, testData.FreeWorkspaceID, testData.PremiumUserID)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "access denied")
+	})
+}
+
+func TestMemberService_RequiresVerifiedEmail_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewMemberService(testDB.Queries())
+	invites := NewInviteService(testDB.Queries(), testDB.Conn(), &recordingSender{}, "https://noture.test")
+	workspaceService := NewWorkspaceService(testDB.Queries(), storage.NewPostgresBackend(testDB.Queries()))
+	ctx := context.Background()
+
+	owner, err := NewUserService(testDB.Queries()).Register(ctx, "unverified@example.com", "correct horse 42")
+	require.NoError(t, err)
+	workspace, err := workspaceService.CreateWorkspace(ctx, domain.CreateWorkspaceRequest{Name: "Unverified"}, owner.ID, domain.TierFree)
+	require.NoError(t, err)
+
+	premiumUser, err := testDB.Queries().GetUserByID(ctx, pgconv.UUIDToPg(testData.PremiumUserID))
+	require.NoError(t, err)
+
+	_, err = service.AddMember(ctx, workspace.ID, owner.ID, domain.AddMemberRequest{Email: premiumUser.Email, Role: domain.RoleViewer})
+	assert.ErrorContains(t, err, "access denied: email not verified")
+	_, err = invites.CreateInvite(ctx, workspace.ID, owner.ID, domain.CreateInviteRequest{Email: "friend@example.com", Role: domain.RoleViewer})
+	assert.ErrorContains(t, err, "access denied: email not verified")
+}
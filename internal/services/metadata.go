@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// MetadataParserVersion is the version of what is parsed out of file
+	// content. Bump it when parsing changes and the background backfill
+	// reparses every stored file.
+	MetadataParserVersion int32 = 1
+	// MetadataBackfillBatchSize is how many files one backfill run
+	// reparses, which paces rebuilds across the server.
+	MetadataBackfillBatchSize = 200
+)
+
+// RebuildMetadata marks every file of the workspace for reparsing by the
+// background backfill and returns the workspace's status. Parsing is
+// derived from content alone, so a rebuild changes no files.
+func (s *FileService) RebuildMetadata(ctx context.Context, workspaceID, userID uuid.UUID) (*domain.MetadataStatus, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	marked, err := s.queries.MarkWorkspaceMetadataStale(ctx, pgconv.UUIDToPg(workspaceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark metadata for rebuild: %w", err)
+	}
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "").
+		Info("Metadata rebuild requested", "files", marked)
+
+	status, err := s.metadataStatus(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// MetadataStatus reports how far the workspace has been parsed by the
+// current parser.
+func (s *FileService) MetadataStatus(ctx context.Context, workspaceID, userID uuid.UUID) (*domain.MetadataStatus, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	status, err := s.metadataStatus(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (s *FileService) metadataStatus(ctx context.Context, workspaceID uuid.UUID) (domain.MetadataStatus, error) {
+	row, err := retryRead(ctx, "get_metadata_status", func() (db.GetMetadataStatusRow, error) {
+		return s.queries.GetMetadataStatus(ctx, db.GetMetadataStatusParams{
+			ParserVersion: MetadataParserVersion,
+			WorkspaceID:   pgconv.UUIDToPg(workspaceID),
+		})
+	})
+	if err != nil {
+		return domain.MetadataStatus{}, fmt.Errorf("failed to get metadata status: %w", err)
+	}
+	return domain.NewMetadataStatus(workspaceID, MetadataParserVersion, row.TotalFiles, row.ParsedFiles), nil
+}
+
+// BackfillMetadata reparses up to batchSize files without metadata from
+// the current parser, most recently modified first, and returns how many
+// it reparsed. Files whose content cannot be read are skipped and retried
+// on the next run.
+func (s *FileService) BackfillMetadata(ctx context.Context, batchSize int) (int, error) {
+	files, err := s.queries.ListStaleMetadataFiles(ctx, db.ListStaleMetadataFilesParams{
+		ParserVersion: MetadataParserVersion,
+		MaxFiles:      int32(batchSize),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list files to reparse: %w", err)
+	}
+
+	reparsed := 0
+	for _, file := range files {
+		err := s.reparseFile(ctx, file.WorkspaceID, file.FilePath)
+		if err != nil && strings.HasPrefix(err.Error(), "failed to load file content") {
+			s.log.Warn("Skipping file in metadata backfill",
+				"file_path", file.FilePath,
+				"workspace_id", pgconv.PgToUUID(file.WorkspaceID),
+				"error", err)
+			continue
+		}
+		if err != nil {
+			return reparsed, err
+		}
+		reparsed++
+	}
+
+	if reparsed > 0 {
+		s.log.Info("Backfilled file metadata", "files", reparsed)
+	}
+	return reparsed, nil
+}
+
+// reparseFile rebuilds what is parsed out of a file's content: its
+// metadata, agenda entries, links, tags and search entry. Tasks and note
+// IDs are left alone, as rebuilding them would report task events or
+// drop IDs that clients supplied. The path is locked, so an upload racing
+// the reparse waits for it and then writes its own.
+func (s *FileService) reparseFile(ctx context.Context, workspaceID pgtype.UUID, filePath string) error {
+	return inTx(ctx, s.conn, s.queries, "reparse_file", func(qtx *db.Queries) error {
+		err := qtx.LockFilePath(ctx, db.LockFilePathParams{
+			WorkspaceID: workspaceID,
+			FilePath:    filePath,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to lock file: %w", err)
+		}
+		file, err := qtx.GetFile(ctx, db.GetFileParams{
+			WorkspaceID: workspaceID,
+			FilePath:    filePath,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get file: %w", err)
+		}
+		content, err := s.blobs.Get(ctx, file.ContentHash)
+		if err != nil {
+			return fmt.Errorf("failed to load file content: %w", err)
+		}
+
+		format := s.DetectFileFormat(file.FilePath, content)
+		if err := syncFileAgenda(ctx, qtx, file, format, content); err != nil {
+			return err
+		}
+		if err := syncFileLinks(ctx, qtx, file, format, content); err != nil {
+			return err
+		}
+		if err := indexFileContent(ctx, qtx, file, content); err != nil {
+			return err
+		}
+		return upsertFileMetadata(ctx, qtx, file, format, content)
+	})
+}
+
+func upsertFileMetadata(ctx context.Context, queries *db.Queries, file db.File, format domain.FileFormat, content []byte) error {
+	// TODO: Implement actual parsing logic for different formats
+	var parsedBlocks []byte
+	var properties []byte
+	wordCount := 0
+	if format != domain.FormatBinary {
+		wordCount = len(strings.Fields(string(content)))
+	}
+
+	err := queries.UpsertFileMetadata(ctx, db.UpsertFileMetadataParams{
+		FileID:        file.ID,
+		Format:        string(format),
+		ParsedBlocks:  parsedBlocks,
+		Properties:    properties,
+		WordCount:     pgconv.Int32ToPg(int32(wordCount)),
+		ParserVersion: MetadataParserVersion,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store file metadata: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"sync"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+)
+
+const (
+	// MetadataParseWorkers is how many files a FileService parses at once
+	// after uploads.
+	MetadataParseWorkers = 4
+	// MetadataParseQueueSize bounds the files waiting for a parse worker.
+	// Uploads wait for room once it is full.
+	MetadataParseQueueSize = 256
+)
+
+type metadataJob struct {
+	requestID string
+	file      db.File
+	content   []byte
+}
+
+// metadataParser stores the metadata of uploaded files on a fixed set of
+// workers, off the request path. Parses are retried on transient database
+// errors; one that still fails is left to the backfill, as the file's
+// metadata stays behind MetadataParserVersion.
+type metadataParser struct {
+	service *FileService
+	jobs    chan metadataJob
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newMetadataParser(service *FileService, workers, queueSize int) *metadataParser {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &metadataParser{
+		service: service,
+		jobs:    make(chan metadataJob, queueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	p.wg.Add(workers)
+	for range workers {
+		go p.work()
+	}
+	return p
+}
+
+// enqueue queues a file for parsing, waiting while the queue is full. It
+// gives up when ctx is done or the parser has been closed.
+func (p *metadataParser) enqueue(ctx context.Context, file db.File, content []byte) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	log := p.service.log.WithContext(ctx).WithWorkspace(pgconv.PgToUUID(file.WorkspaceID).String(), "")
+	if p.closed {
+		log.Warn("Metadata parser closed; leaving file to the backfill", "file_path", file.FilePath)
+		return
+	}
+	select {
+	case p.jobs <- metadataJob{requestID: logger.RequestID(ctx), file: file, content: content}:
+	case <-ctx.Done():
+		log.Warn("Gave up queueing file for metadata parsing; leaving it to the backfill", "file_path", file.FilePath)
+	}
+}
+
+func (p *metadataParser) work() {
+	defer p.wg.Done()
+	for job := range p.jobs {
+		if p.ctx.Err() != nil {
+			continue
+		}
+		ctx := logger.ContextWithRequestID(p.ctx, job.requestID)
+		_, err := retry(ctx, DefaultRetryPolicy, "upsert_file_metadata", isTransient, func() (struct{}, error) {
+			format := p.service.DetectFileFormat(job.file.FilePath, job.content)
+			return struct{}{}, upsertFileMetadata(ctx, p.service.queries, job.file, format, job.content)
+		})
+		if err != nil {
+			p.service.log.WithContext(ctx).WithError(err).Error("Failed to store file metadata",
+				"workspace_id", pgconv.PgToUUID(job.file.WorkspaceID),
+				"file_path", job.file.FilePath)
+		}
+	}
+}
+
+// close stops taking files and waits for the queued ones to be parsed.
+// When ctx is done first, parses in flight are cancelled and what is
+// still queued is left to the backfill.
+func (p *metadataParser) close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metadataDB records the formats of the metadata upserts it is sent. The
+// first failures upserts fail as if the connection dropped, and with
+// release set each waits for it.
+type metadataDB struct {
+	mu       sync.Mutex
+	failures int
+	stored   []string
+	release  chan struct{}
+}
+
+func (d *metadataDB) Exec(ctx context.Context, _ string, args ...interface{}) (pgconn.CommandTag, error) {
+	if d.release != nil {
+		select {
+		case <-d.release:
+		case <-ctx.Done():
+			return pgconn.CommandTag{}, ctx.Err()
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.failures > 0 {
+		d.failures--
+		return pgconn.CommandTag{}, &pgconn.PgError{Code: "57P01"}
+	}
+	d.stored = append(d.stored, args[1].(string))
+	return pgconn.CommandTag{}, nil
+}
+
+func (d *metadataDB) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	panic("unexpected query")
+}
+
+func (d *metadataDB) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	panic("unexpected query")
+}
+
+func (d *metadataDB) formats() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.stored...)
+}
+
+func TestMetadataParser(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("close drains queued files and retries transient errors", func(t *testing.T) {
+		store := &metadataDB{failures: 2}
+		service := &FileService{queries: db.New(store), log: logger.New()}
+		parser := newMetadataParser(service, 2, 8)
+
+		for _, path := range []string{"a.md", "b.org", "c.txt"} {
+			parser.enqueue(ctx, db.File{FilePath: path}, []byte("hello"))
+		}
+		require.NoError(t, parser.close(ctx))
+		assert.ElementsMatch(t, []string{"markdown", "orgmode", "plaintext"}, store.formats())
+
+		// Files arriving after close are left to the backfill.
+		parser.enqueue(ctx, db.File{FilePath: "d.md"}, []byte("late"))
+		assert.Len(t, store.formats(), 3)
+	})
+
+	t.Run("close gives up when its context is done", func(t *testing.T) {
+		store := &metadataDB{release: make(chan struct{})}
+		service := &FileService{queries: db.New(store), log: logger.New()}
+		parser := newMetadataParser(service, 1, 8)
+		for _, path := range []string{"a.md", "b.md"} {
+			parser.enqueue(ctx, db.File{FilePath: path}, nil)
+		}
+
+		closeCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, parser.close(closeCtx), context.DeadlineExceeded)
+		assert.Empty(t, store.formats())
+	})
+
+	t.Run("a full queue makes uploads wait", func(t *testing.T) {
+		store := &metadataDB{release: make(chan struct{})}
+		service := &FileService{queries: db.New(store), log: logger.New()}
+		parser := newMetadataParser(service, 1, 1)
+		parser.enqueue(ctx, db.File{FilePath: "a.md"}, nil)
+		parser.enqueue(ctx, db.File{FilePath: "b.md"}, nil)
+
+		waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		parser.enqueue(waitCtx, db.File{FilePath: "c.md"}, nil)
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+		close(store.release)
+		require.NoError(t, parser.close(ctx))
+		assert.Equal(t, []string{"markdown", "markdown"}, store.formats())
+	})
+}
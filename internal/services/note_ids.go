@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/noteid"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// NoteRenameWindow is how recently a note must have appeared at a new path
+// for the deletion of its old path to be taken as a rename.
+const NoteRenameWindow = 10 * time.Minute
+
+// WithNoteIDs sets how note IDs are found in uploads that do not carry
+// one. The default reads them from frontmatter.
+func (s *FileService) WithNoteIDs(strategy noteid.Strategy) *FileService {
+	s.noteIDs = strategy
+	return s
+}
+
+// resolveNoteID returns the note ID to store for content uploaded to path:
+// the one the client sent, or else the one the strategy finds.
+func (s *FileService) resolveNoteID(supplied, path string, content []byte) (string, error) {
+	if supplied == "" {
+		return s.noteIDs.NoteID(path, content), nil
+	}
+	if !noteid.Valid(supplied) {
+		return "", fmt.Errorf("invalid note_id: at most %d characters without spaces", noteid.MaxLength)
+	}
+	return supplied, nil
+}
+
+// syncNoteID stores the note ID of file, or forgets it if noteID is empty.
+func syncNoteID(ctx context.Context, qtx *db.Queries, file db.File, noteID string) error {
+	var err error
+	if noteID == "" {
+		err = qtx.DeleteFileNoteID(ctx, file.ID)
+	} else {
+		err = qtx.SetFileNoteID(ctx, db.SetFileNoteIDParams{
+			FileID:      file.ID,
+			WorkspaceID: file.WorkspaceID,
+			NoteID:      noteID,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store note ID: %w", err)
+	}
+	return nil
+}
+
+// GetFileByNoteID returns the file holding a note ID. While several do,
+// the oldest wins.
+func (s *FileService) GetFileByNoteID(ctx context.Context, workspaceID, userID uuid.UUID, noteID string) (*domain.FileInfo, error) {
+	_, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := retryRead(ctx, "get_file_by_note_id", func() (db.File, error) {
+		return s.queries.GetFileByNoteID(ctx, db.GetFileByNoteIDParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			NoteID:      noteID,
+		})
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("note not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get note: %w", err)
+	}
+
+	return &domain.FileInfo{
+		ID:           pgconv.PgToUUID(file.ID),
+		WorkspaceID:  pgconv.PgToUUID(file.WorkspaceID),
+		FilePath:     file.FilePath,
+		ContentHash:  file.ContentHash,
+		SizeBytes:    file.SizeBytes,
+		MimeType:     pgconv.PgToString(file.MimeType),
+		LastModified: pgconv.PgToTime(file.LastModified),
+		UpdatedAt:    pgconv.PgToTime(file.UpdatedAt),
+	}, nil
+}
+
+// findRecreatedNote looks for a file that took over the note ID of file
+// within NoteRenameWindow: a client that renames by creating the new path
+// and then deleting the old one.
+func findRecreatedNote(ctx context.Context, qtx *db.Queries, file db.File) (*db.File, error) {
+	noteID, err := qtx.GetFileNoteID(ctx, file.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get note ID: %w", err)
+	}
+
+	recreated, err := qtx.FindRecreatedNote(ctx, db.FindRecreatedNoteParams{
+		WorkspaceID:  file.WorkspaceID,
+		NoteID:       noteID,
+		FileID:       file.ID,
+		CreatedAfter: pgconv.TimeToPg(time.Now().Add(-NoteRenameWindow)),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look for renamed note: %w", err)
+	}
+	return &recreated, nil
+}
+
+// mergeRenamedNote completes a rename done as create-then-delete: the
+// copy at the new path is dropped and the original file is moved there
+// with the copy's content as a new version, so the note keeps its ID,
+// history, tasks and share links.
+func (s *FileService) mergeRenamedNote(ctx context.Context, qtx *db.Queries, userID uuid.UUID, original, copied db.File) error {
+	workspaceID := pgconv.PgToUUID(original.WorkspaceID)
+
+	// The copy may have changed since it was found.
+	err := qtx.LockFilePath(ctx, db.LockFilePathParams{
+		WorkspaceID: original.WorkspaceID,
+		FilePath:    copied.FilePath,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to lock file: %w", err)
+	}
+	copied, err = qtx.GetFile(ctx, db.GetFileParams{
+		WorkspaceID: original.WorkspaceID,
+		FilePath:    copied.FilePath,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+
+	err = qtx.DeleteFile(ctx, db.DeleteFileParams{
+		WorkspaceID: copied.WorkspaceID,
+		FilePath:    copied.FilePath,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	file, err := qtx.MoveFile(ctx, db.MoveFileParams{
+		NewPath:     copied.FilePath,
+		WorkspaceID: original.WorkspaceID,
+		FilePath:    original.FilePath,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+
+	content, err := s.blobs.Get(ctx, copied.ContentHash)
+	if err != nil {
+		return fmt.Errorf("failed to load file content: %w", err)
+	}
+	if copied.ContentHash != original.ContentHash {
+		file, err = qtx.UpsertFile(ctx, db.UpsertFileParams{
+			WorkspaceID:  file.WorkspaceID,
+			FilePath:     file.FilePath,
+			ContentHash:  copied.ContentHash,
+			SizeBytes:    copied.SizeBytes,
+			MimeType:     copied.MimeType,
+			LastModified: copied.LastModified,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upsert file: %w", err)
+		}
+		_, err = qtx.CreateFileVersion(ctx, db.CreateFileVersionParams{
+			FileID:      file.ID,
+			ContentHash: file.ContentHash,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create file version: %w", err)
+		}
+		format := s.DetectFileFormat(file.FilePath, content)
+		if err := syncFileTasks(ctx, qtx, file, format, content, userID); err != nil {
+			return err
+		}
+		if err := syncFileAgenda(ctx, qtx, file, format, content); err != nil {
+			return err
+		}
+		if err := syncFileLinks(ctx, qtx, file, format, content); err != nil {
+			return err
+		}
+	}
+	if err := indexFileContent(ctx, qtx, file, content); err != nil {
+		return err
+	}
+
+	// The copy's size is already counted; the original's is freed.
+	_, err = qtx.AdjustWorkspaceCounters(ctx, db.AdjustWorkspaceCountersParams{
+		ID:               original.WorkspaceID,
+		FileCount:        -1,
+		StorageUsedBytes: pgconv.Int64ToPg(-original.SizeBytes),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update storage usage: %w", err)
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "").Info("Merged renamed note",
+		"from", original.FilePath,
+		"to", file.FilePath,
+		"file_id", pgconv.PgToUUID(file.ID))
+	return recordEvent(ctx, qtx, workspaceID, domain.EventFileMoved, file.FilePath, &userID, map[string]any{
+		"from":         original.FilePath,
+		"content_hash": file.ContentHash,
+	})
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/notetemplate"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// NoteTemplateService keeps the note templates of workspaces and creates
+// files from them.
+type NoteTemplateService struct {
+	queries *db.Queries
+	files   *FileService
+	now     func() time.Time
+	log     *logger.Logger
+}
+
+func NewNoteTemplateService(queries *db.Queries, files *FileService) *NoteTemplateService {
+	return &NoteTemplateService{
+		queries: queries,
+		files:   files,
+		now:     time.Now,
+		log:     logger.New(),
+	}
+}
+
+// ListTemplates returns the workspace's templates by name. Any member may
+// list them.
+func (s *NoteTemplateService) ListTemplates(ctx context.Context, workspaceID, userID uuid.UUID) ([]domain.NoteTemplate, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	rows, err := retryRead(ctx, "list_note_templates", func() ([]db.NoteTemplate, error) {
+		return s.queries.ListNoteTemplates(ctx, pgconv.UUIDToPg(workspaceID))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+
+	templates := make([]domain.NoteTemplate, len(rows))
+	for i, row := range rows {
+		templates[i] = toDomainNoteTemplate(row)
+	}
+	return templates, nil
+}
+
+// CreateTemplate adds a template to the workspace. Editors and owners may
+// add templates; names are unique within a workspace.
+func (s *NoteTemplateService) CreateTemplate(ctx context.Context, workspaceID, userID uuid.UUID, req domain.CreateNoteTemplateRequest) (*domain.NoteTemplate, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 255 {
+		return nil, fmt.Errorf("invalid template name: must be 1-255 characters")
+	}
+	if len(req.Content) > domain.MaxNoteTemplateSize {
+		return nil, fmt.Errorf("invalid template content: larger than %d bytes", domain.MaxNoteTemplateSize)
+	}
+	if !utf8.ValidString(req.Content) {
+		return nil, fmt.Errorf("invalid template content: not UTF-8 text")
+	}
+
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	row, err := s.queries.CreateNoteTemplate(ctx, db.CreateNoteTemplateParams{
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		Name:        req.Name,
+		Description: strings.TrimSpace(req.Description),
+		Content:     req.Content,
+		CreatedBy:   pgconv.UUIDToPg(userID),
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("template already exists: %s", req.Name)
+		}
+		return nil, fmt.Errorf("failed to create template: %w", err)
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "").
+		Info("Created note template", "template", req.Name)
+
+	template := toDomainNoteTemplate(row)
+	return &template, nil
+}
+
+func (s *NoteTemplateService) DeleteTemplate(ctx context.Context, workspaceID, userID, templateID uuid.UUID) error {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleEditor); err != nil {
+		return err
+	}
+
+	deleted, err := s.queries.DeleteNoteTemplate(ctx, db.DeleteNoteTemplateParams{
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		ID:          pgconv.UUIDToPg(templateID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("template not found")
+	}
+	return nil
+}
+
+// CreateFileFromTemplate renders a template into a new file. Dates are
+// those of the user's timezone. It never overwrites: if the path is taken
+// it fails with "file already exists".
+func (s *NoteTemplateService) CreateFileFromTemplate(ctx context.Context, req domain.CreateFileFromTemplateRequest, userID uuid.UUID) (*domain.FileUploadResult, error) {
+	if req.FilePath == "" {
+		return nil, fmt.Errorf("invalid file path: empty")
+	}
+	if _, _, err := authorizeWorkspace(ctx, s.queries, req.WorkspaceID, userID, domain.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	content, err := s.templateContent(ctx, req.WorkspaceID, req.Template)
+	if err != nil {
+		return nil, err
+	}
+	now, err := s.userNow(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	title := req.Title
+	if title == "" {
+		base := path.Base(req.FilePath)
+		title = strings.TrimSuffix(base, path.Ext(base))
+	}
+	vars := notetemplate.Variables(now, title)
+	for name, value := range req.Variables {
+		vars[name] = value
+	}
+
+	return s.createFile(ctx, req.WorkspaceID, req.FilePath, notetemplate.Render(content, vars), now, userID)
+}
+
+func (s *NoteTemplateService) templateContent(ctx context.Context, workspaceID uuid.UUID, name string) (string, error) {
+	row, err := s.queries.GetNoteTemplateByName(ctx, db.GetNoteTemplateByNameParams{
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		Name:        strings.TrimSpace(name),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("template not found")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get template: %w", err)
+	}
+	return row.Content, nil
+}
+
+// userNow is the current time in the user's timezone.
+func (s *NoteTemplateService) userNow(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	user, err := s.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	return s.now().In(toDomainUser(user).Location()), nil
+}
+
+// createFile uploads content to filePath unless a file is already there.
+func (s *NoteTemplateService) createFile(ctx context.Context, workspaceID uuid.UUID, filePath, content string, lastModified time.Time, userID uuid.UUID) (*domain.FileUploadResult, error) {
+	noFile := ""
+	result, err := s.files.UploadFile(ctx, domain.FileUploadRequest{
+		WorkspaceID:  workspaceID,
+		FilePath:     filePath,
+		Content:      []byte(content),
+		LastModified: lastModified,
+		BaseHash:     &noFile,
+	}, userID)
+	if err != nil {
+		if err.Error() == "file changed" {
+			return nil, fmt.Errorf("file already exists")
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+func toDomainNoteTemplate(row db.NoteTemplate) domain.NoteTemplate {
+	return domain.NoteTemplate{
+		ID:          pgconv.PgToUUID(row.ID),
+		WorkspaceID: pgconv.PgToUUID(row.WorkspaceID),
+		Name:        row.Name,
+		Description: row.Description,
+		Content:     row.Content,
+		CreatedBy:   pgconv.PgToUUIDPtr(row.CreatedBy),
+		CreatedAt:   pgconv.PgToTime(row.CreatedAt),
+	}
+}
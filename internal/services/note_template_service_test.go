@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNoteTemplateService_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	files := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	service := NewNoteTemplateService(testDB.Queries(), files)
+	service.now = func() time.Time { return time.Date(2026, time.March, 4, 23, 30, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	template, err := service.CreateTemplate(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.CreateNoteTemplateRequest{
+		Name:    "meeting",
+		Content: "# {{title}}\nDate: {{date}}\nWith: {{attendees}}\n{{agenda}}",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "meeting", template.Name)
+
+	t.Run("names are unique per workspace", func(t *testing.T) {
+		_, err := service.CreateTemplate(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.CreateNoteTemplateRequest{Name: "meeting"})
+		assert.ErrorContains(t, err, "template already exists")
+	})
+
+	t.Run("viewers list but do not add", func(t *testing.T) {
+		templates, err := service.ListTemplates(ctx, testData.FreeWorkspaceID, testData.PremiumUserID)
+		require.NoError(t, err)
+		assert.Len(t, templates, 1)
+
+		_, err = service.CreateTemplate(ctx, testData.FreeWorkspaceID, testData.PremiumUserID, domain.CreateNoteTemplateRequest{Name: "other"})
+		assert.ErrorContains(t, err, "access denied")
+	})
+
+	t.Run("creates a file with placeholders filled in", func(t *testing.T) {
+		result, err := service.CreateFileFromTemplate(ctx, domain.CreateFileFromTemplateRequest{
+			WorkspaceID: testData.FreeWorkspaceID,
+			Template:    "meeting",
+			FilePath:    "meetings/Kickoff.md",
+			Variables:   map[string]string{"attendees": "Ana, Bo"},
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.True(t, result.Created)
+
+		stored, err := files.GetFileContent(ctx, testData.FreeWorkspaceID, "meetings/Kickoff.md", testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, "# Kickoff\nDate: 2026-03-04\nWith: Ana, Bo\n{{agenda}}", string(stored.Content))
+	})
+
+	t.Run("dates follow the user's timezone", func(t *testing.T) {
+		tz := "Asia/Tokyo"
+		_, err := NewUserService(testDB.Queries()).UpdateProfile(ctx, testData.FreeUserID, domain.UpdateProfileRequest{Timezone: &tz})
+		require.NoError(t, err)
+
+		_, err = service.CreateFileFromTemplate(ctx, domain.CreateFileFromTemplateRequest{
+			WorkspaceID: testData.FreeWorkspaceID,
+			Template:    "meeting",
+			FilePath:    "meetings/late.md",
+			Title:       "Late",
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+
+		stored, err := files.GetFileContent(ctx, testData.FreeWorkspaceID, "meetings/late.md", testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Contains(t, string(stored.Content), "Date: 2026-03-05")
+	})
+
+	t.Run("existing files are not overwritten", func(t *testing.T) {
+		_, err := service.CreateFileFromTemplate(ctx, domain.CreateFileFromTemplateRequest{
+			WorkspaceID: testData.FreeWorkspaceID,
+			Template:    "meeting",
+			FilePath:    "meetings/Kickoff.md",
+		}, testData.FreeUserID)
+		assert.EqualError(t, err, "file already exists")
+	})
+
+	t.Run("unknown template", func(t *testing.T) {
+		_, err := service.CreateFileFromTemplate(ctx, domain.CreateFileFromTemplateRequest{
+			WorkspaceID: testData.FreeWorkspaceID,
+			Template:    "missing",
+			FilePath:    "x.md",
+		}, testData.FreeUserID)
+		assert.EqualError(t, err, "template not found")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, service.DeleteTemplate(ctx, testData.FreeWorkspaceID, testData.FreeUserID, template.ID))
+		assert.EqualError(t, service.DeleteTemplate(ctx, testData.FreeWorkspaceID, testData.FreeUserID, template.ID), "template not found")
+	})
+}
+
+func TestNoteTemplateService_DailyNotes_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	files := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	service := NewNoteTemplateService(testDB.Queries(), files)
+	service.now = func() time.Time { return time.Date(2026, time.March, 4, 9, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	t.Run("defaults apply until settings are saved", func(t *testing.T) {
+		settings, err := service.GetDailyNoteSettings(ctx, testData.FreeWorkspaceID, testData.PremiumUserID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.DefaultDailyNotePattern, settings.PathPattern)
+
+		_, err = service.GetDailyNote(ctx, testData.FreeWorkspaceID, testData.FreeUserID, "")
+		assert.EqualError(t, err, "daily note not found")
+
+		note, err := service.CreateDailyNote(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.CreateDailyNoteRequest{})
+		require.NoError(t, err)
+		assert.True(t, note.Created)
+		assert.Equal(t, "journal/2026-03-04.md", note.FilePath)
+		assert.Equal(t, "# 2026-03-04\n", string(note.File.Content))
+	})
+
+	t.Run("an existing note is returned", func(t *testing.T) {
+		note, err := service.CreateDailyNote(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.CreateDailyNoteRequest{})
+		require.NoError(t, err)
+		assert.False(t, note.Created)
+
+		note, err = service.GetDailyNote(ctx, testData.FreeWorkspaceID, testData.PremiumUserID, "2026-03-04")
+		require.NoError(t, err)
+		assert.Equal(t, "journal/2026-03-04.md", note.File.FilePath)
+	})
+
+	t.Run("pattern and template come from the settings", func(t *testing.T) {
+		_, err := service.CreateTemplate(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.CreateNoteTemplateRequest{
+			Name:    "daily",
+			Content: "# {{weekday}}, {{title}}\n## Tasks\n",
+		})
+		require.NoError(t, err)
+		_, err = service.UpdateDailyNoteSettings(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.DailyNoteSettings{
+			PathPattern: "daily/{{year}}/{{month}}/{{date}}.md",
+			Template:    "daily",
+		})
+		require.NoError(t, err)
+
+		note, err := service.CreateDailyNote(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.CreateDailyNoteRequest{Date: "2026-02-27"})
+		require.NoError(t, err)
+		assert.Equal(t, "daily/2026/02/2026-02-27.md", note.FilePath)
+		assert.Equal(t, "# Friday, 2026-02-27\n## Tasks\n", string(note.File.Content))
+	})
+
+	t.Run("patterns must name one file per day", func(t *testing.T) {
+		for _, pattern := range []string{"journal.md", "journal/{{month}}.md", "journal/{{datetime}}.md", "journal/{{date}}-{{author}}.md"} {
+			_, err := service.UpdateDailyNoteSettings(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.DailyNoteSettings{PathPattern: pattern})
+			assert.ErrorContains(t, err, "invalid path pattern", pattern)
+		}
+	})
+
+	t.Run("invalid date", func(t *testing.T) {
+		_, err := service.GetDailyNote(ctx, testData.FreeWorkspaceID, testData.FreeUserID, "03/04/2026")
+		assert.ErrorContains(t, err, "invalid date")
+	})
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/email"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// NotificationService keeps users' in-app notifications and raises the
+// ones the server decides on itself, such as storage quota warnings.
+type NotificationService struct {
+	queries *db.Queries
+	conn    *pgx.Conn
+	mailer  email.Sender
+	log     *logger.Logger
+}
+
+func NewNotificationService(queries *db.Queries, conn *pgx.Conn, mailer email.Sender) *NotificationService {
+	return &NotificationService{
+		queries: queries,
+		conn:    conn,
+		mailer:  mailer,
+		log:     logger.New(),
+	}
+}
+
+// ListNotifications returns the user's latest notifications, only the
+// unread ones when unreadOnly is set, with the number still unread.
+func (s *NotificationService) ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool) (*domain.NotificationList, error) {
+	rows, err := s.queries.ListNotifications(ctx, db.ListNotificationsParams{
+		UserID:     pgconv.UUIDToPg(userID),
+		UnreadOnly: unreadOnly,
+		MaxResults: domain.MaxNotifications,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	unread, err := s.queries.CountUnreadNotifications(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+
+	list := &domain.NotificationList{
+		Notifications: make([]domain.Notification, len(rows)),
+		UnreadCount:   unread,
+	}
+	for i, row := range rows {
+		list.Notifications[i] = toDomainNotification(row)
+	}
+	return list, nil
+}
+
+// MarkRead marks one of the user's notifications read. Marking it again
+// keeps the time it was first read.
+func (s *NotificationService) MarkRead(ctx context.Context, userID, notificationID uuid.UUID) error {
+	affected, err := s.queries.MarkNotificationRead(ctx, db.MarkNotificationReadParams{
+		ID:     pgconv.UUIDToPg(notificationID),
+		UserID: pgconv.UUIDToPg(userID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark notification read: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("notification not found")
+	}
+	return nil
+}
+
+// MarkAllRead marks every unread notification of the user read and returns
+// how many there were.
+func (s *NotificationService) MarkAllRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	marked, err := s.queries.MarkAllNotificationsRead(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+	return marked, nil
+}
+
+// CheckStorageAlerts warns the owners of workspaces whose storage crossed
+// one of domain.StorageAlertThresholds since the last check, with an
+// in-app notification, a storage.threshold event and an email. A workspace
+// that crosses several at once is warned about the highest. Each threshold
+// warns once; when usage drops below it, it is re-armed. It returns the
+// number of warnings raised.
+func (s *NotificationService) CheckStorageAlerts(ctx context.Context) (int, error) {
+	candidates, err := s.queries.ListStorageAlertCandidates(ctx, int64(domain.StorageAlertThresholds[0]))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list storage alert candidates: %w", err)
+	}
+
+	raised := 0
+	for _, candidate := range candidates {
+		threshold := domain.StorageThreshold(candidate.StorageUsedBytes, candidate.StorageLimitBytes)
+		switch {
+		case threshold > int(candidate.AlertedThreshold):
+			sent, err := s.raiseStorageAlert(ctx, candidate, threshold)
+			if err != nil {
+				return raised, err
+			}
+			if sent {
+				raised++
+			}
+		case threshold == 0:
+			if err := s.queries.DeleteStorageAlert(ctx, candidate.WorkspaceID); err != nil {
+				return raised, fmt.Errorf("failed to reset storage alert: %w", err)
+			}
+		case threshold < int(candidate.AlertedThreshold):
+			err := s.queries.LowerStorageAlert(ctx, db.LowerStorageAlertParams{
+				WorkspaceID: candidate.WorkspaceID,
+				Threshold:   int32(threshold),
+			})
+			if err != nil {
+				return raised, fmt.Errorf("failed to reset storage alert: %w", err)
+			}
+		}
+	}
+
+	if raised > 0 {
+		s.log.Info("Raised storage alerts", "count", raised)
+	}
+	return raised, nil
+}
+
+// raiseStorageAlert records the alert, notification and event together, so
+// a concurrent check that already raised the threshold makes this one a
+// no-op, then emails the owner. It reports whether it raised the alert.
+func (s *NotificationService) raiseStorageAlert(ctx context.Context, candidate db.ListStorageAlertCandidatesRow, threshold int) (bool, error) {
+	workspaceID := pgconv.PgToUUID(candidate.WorkspaceID)
+	data := domain.StorageThresholdEventData{
+		Threshold:         threshold,
+		StorageUsedBytes:  candidate.StorageUsedBytes,
+		StorageLimitBytes: candidate.StorageLimitBytes,
+	}
+	title, body := storageAlertMessage(candidate.WorkspaceName, threshold)
+
+	raised := false
+	err := inTx(ctx, s.conn, s.queries, "raise_storage_alert", func(qtx *db.Queries) error {
+		affected, err := qtx.RaiseStorageAlert(ctx, db.RaiseStorageAlertParams{
+			WorkspaceID: candidate.WorkspaceID,
+			Threshold:   int32(threshold),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record storage alert: %w", err)
+		}
+		if raised = affected > 0; !raised {
+			return nil
+		}
+
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("failed to encode notification: %w", err)
+		}
+		_, err = qtx.CreateNotification(ctx, db.CreateNotificationParams{
+			UserID:      candidate.OwnerID,
+			Kind:        domain.NotificationStorageQuota,
+			WorkspaceID: candidate.WorkspaceID,
+			Title:       title,
+			Body:        body,
+			Data:        payload,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create notification: %w", err)
+		}
+		return recordEvent(ctx, qtx, workspaceID, domain.EventStorageThreshold, "", nil, data)
+	})
+	if err != nil || !raised {
+		return false, err
+	}
+
+	log := s.log.WithContext(ctx).WithWorkspace(workspaceID.String(), candidate.WorkspaceName)
+	ownerID := pgconv.PgToUUID(candidate.OwnerID)
+	prefs, err := loadPreferences(ctx, s.queries, ownerID)
+	if err != nil {
+		log.WithError(err).Warn("Failed to load preferences for storage alert email")
+		return true, nil
+	}
+	if prefs.NotificationEnabled(domain.NotificationStorageQuota) {
+		// The notification and event are committed; a failed email is not
+		// retried, so the owner is not warned twice in the app.
+		msg := email.Message{To: candidate.OwnerEmail, Subject: title, Body: body + "\n"}
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			log.WithError(err).Warn("Failed to send storage alert email")
+		}
+	}
+
+	log.Info("Raised storage alert", "threshold", threshold, "owner_id", ownerID)
+	return true, nil
+}
+
+func storageAlertMessage(workspaceName string, threshold int) (title, body string) {
+	if threshold >= 100 {
+		return fmt.Sprintf("%s is out of storage", workspaceName),
+			fmt.Sprintf("The workspace %q has used all of its storage. Uploads will fail until you "+
+				"delete files or move to a plan with more storage.", workspaceName)
+	}
+	return fmt.Sprintf("%s has used %d%% of its storage", workspaceName, threshold),
+		fmt.Sprintf("The workspace %q has used %d%% of its storage. Once it is full, uploads will "+
+			"fail until you delete files or move to a plan with more storage.", workspaceName, threshold)
+}
+
+func toDomainNotification(row db.Notification) domain.Notification {
+	return domain.Notification{
+		ID:          pgconv.PgToUUID(row.ID),
+		Kind:        row.Kind,
+		WorkspaceID: pgconv.PgToUUIDPtr(row.WorkspaceID),
+		Title:       row.Title,
+		Body:        row.Body,
+		Data:        json.RawMessage(row.Data),
+		ReadAt:      pgconv.PgToTimePtr(row.ReadAt),
+		CreatedAt:   pgconv.PgToTime(row.CreatedAt),
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationService_CheckStorageAlerts(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	mailer := &recordingSender{}
+	service := NewNotificationService(testDB.Queries(), testDB.Conn(), mailer)
+	eventService := NewEventService(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	setUsage := func(t *testing.T, used int64) {
+		_, err := testDB.Conn().Exec(ctx,
+			"UPDATE workspaces SET storage_used_bytes = $2, storage_limit_bytes = 1000 WHERE id = $1",
+			testData.FreeWorkspaceID, used)
+		require.NoError(t, err)
+	}
+	thresholdEvents := func(t *testing.T) []domain.WorkspaceEvent {
+		page, err := eventService.ListEvents(ctx, testData.FreeWorkspaceID, testData.FreeUserID, 0,
+			[]string{domain.EventStorageThreshold}, 100)
+		require.NoError(t, err)
+		return page.Events
+	}
+
+	t.Run("below every threshold", func(t *testing.T) {
+		setUsage(t, 500)
+		raised, err := service.CheckStorageAlerts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, raised)
+	})
+
+	t.Run("crossing a threshold warns once", func(t *testing.T) {
+		setUsage(t, 850)
+		raised, err := service.CheckStorageAlerts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, raised)
+
+		raised, err = service.CheckStorageAlerts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, raised, "the same threshold warns once")
+
+		list, err := service.ListNotifications(ctx, testData.FreeUserID, true)
+		require.NoError(t, err)
+		require.Len(t, list.Notifications, 1)
+		assert.Equal(t, int64(1), list.UnreadCount)
+		assert.Equal(t, domain.NotificationStorageQuota, list.Notifications[0].Kind)
+		assert.Equal(t, testData.FreeWorkspaceID, *list.Notifications[0].WorkspaceID)
+		assert.JSONEq(t, `{"threshold":80,"storage_used_bytes":850,"storage_limit_bytes":1000}`, string(list.Notifications[0].Data))
+
+		require.Len(t, mailer.sent, 1)
+		assert.Contains(t, mailer.sent[0].Subject, "80%")
+
+		events := thresholdEvents(t)
+		require.Len(t, events, 1)
+		assert.Nil(t, events[0].ActorID)
+	})
+
+	t.Run("crossing several warns about the highest", func(t *testing.T) {
+		setUsage(t, 1000)
+		raised, err := service.CheckStorageAlerts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, raised)
+
+		events := thresholdEvents(t)
+		require.Len(t, events, 2)
+		assert.JSONEq(t, `{"threshold":100,"storage_used_bytes":1000,"storage_limit_bytes":1000}`, string(events[1].Data))
+	})
+
+	t.Run("dropping below re-arms the threshold", func(t *testing.T) {
+		setUsage(t, 900)
+		raised, err := service.CheckStorageAlerts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, raised, "80% was already reported")
+
+		setUsage(t, 1000)
+		raised, err = service.CheckStorageAlerts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, raised)
+
+		setUsage(t, 100)
+		_, err = service.CheckStorageAlerts(ctx)
+		require.NoError(t, err)
+		setUsage(t, 820)
+		raised, err = service.CheckStorageAlerts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, raised)
+		assert.Len(t, thresholdEvents(t), 4)
+	})
+
+	t.Run("emails follow the preference", func(t *testing.T) {
+		users := NewUserService(testDB.Queries())
+		_, err := users.UpdatePreferences(ctx, testData.FreeUserID, domain.PreferencesPatch{
+			Notifications: map[string]bool{domain.NotificationStorageQuota: false},
+		})
+		require.NoError(t, err)
+
+		sent := len(mailer.sent)
+		setUsage(t, 960)
+		raised, err := service.CheckStorageAlerts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, raised)
+		assert.Len(t, mailer.sent, sent, "no email")
+
+		list, err := service.ListNotifications(ctx, testData.FreeUserID, false)
+		require.NoError(t, err)
+		assert.Len(t, list.Notifications, 5, "the in-app notification is kept")
+	})
+
+	t.Run("marking read", func(t *testing.T) {
+		list, err := service.ListNotifications(ctx, testData.FreeUserID, false)
+		require.NoError(t, err)
+		first := list.Notifications[0]
+
+		require.NoError(t, service.MarkRead(ctx, testData.FreeUserID, first.ID))
+		err = service.MarkRead(ctx, testData.PremiumUserID, first.ID)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "notification not found")
+		err = service.MarkRead(ctx, testData.FreeUserID, uuid.New())
+		assert.Error(t, err)
+
+		marked, err := service.MarkAllRead(ctx, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), marked)
+
+		list, err = service.ListNotifications(ctx, testData.FreeUserID, true)
+		require.NoError(t, err)
+		assert.Empty(t, list.Notifications)
+		assert.Equal(t, int64(0), list.UnreadCount)
+	})
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// OperationLease is how long a worker owns an operation without
+	// checkpointing. Another worker resumes it once the lease runs out.
+	OperationLease = 2 * time.Minute
+	// MaxOperationAttempts bounds how often an operation is resumed after
+	// its worker stopped, so one that crashes the worker cannot loop.
+	MaxOperationAttempts = 5
+	// OperationRetention is how long finished operations stay pollable.
+	OperationRetention = 7 * 24 * time.Hour
+)
+
+// OperationHandler carries out one kind of operation. It reads its input
+// from run.Params and where it got to from run.State and run.StepsDone,
+// and must call run.Checkpoint after every step so a resumed run skips
+// the steps already done. Steps should be safe to repeat: a crash between
+// a step and its checkpoint runs that step again. The returned result is
+// stored as the operation's result.
+type OperationHandler func(ctx context.Context, run *OperationRun) (any, error)
+
+// OperationRun is one attempt at an operation.
+type OperationRun struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	WorkspaceID *uuid.UUID
+	Params      json.RawMessage
+	State       json.RawMessage
+	StepsDone   int32
+	StepsTotal  int32
+
+	attempt int32
+	queries *db.Queries
+	now     func() time.Time
+}
+
+// errOperationLost means another worker took the operation over.
+var errOperationLost = errors.New("operation lease lost")
+
+// Checkpoint records progress and renews the lease. It fails once another
+// worker has taken the operation over, and the handler must then stop.
+func (r *OperationRun) Checkpoint(ctx context.Context, state any, stepsDone, stepsTotal int32) error {
+	encoded, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode operation state: %w", err)
+	}
+	updated, err := r.queries.CheckpointOperation(ctx, db.CheckpointOperationParams{
+		ID:             pgconv.UUIDToPg(r.ID),
+		State:          encoded,
+		StepsDone:      stepsDone,
+		StepsTotal:     stepsTotal,
+		LeaseExpiresAt: pgconv.TimeToPg(r.now().Add(OperationLease)),
+		Attempts:       r.attempt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to checkpoint operation: %w", err)
+	}
+	if updated == 0 {
+		return errOperationLost
+	}
+	r.State, r.StepsDone, r.StepsTotal = encoded, stepsDone, stepsTotal
+	return nil
+}
+
+// Upload returns the file the operation was enqueued with by
+// EnqueueWithUpload.
+func (r *OperationRun) Upload(ctx context.Context) ([]byte, error) {
+	content, err := r.queries.GetOperationUpload(ctx, pgconv.UUIDToPg(r.ID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("operation upload missing")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read operation upload: %w", err)
+	}
+	return content, nil
+}
+
+// OperationService records long-running operations and runs them in the
+// background worker. Handlers are registered per kind at startup.
+type OperationService struct {
+	queries *db.Queries
+	conn    *pgx.Conn
+	log     *logger.Logger
+	now     func() time.Time
+
+	mu       sync.RWMutex
+	handlers map[string]OperationHandler
+}
+
+func NewOperationService(queries *db.Queries, conn *pgx.Conn) *OperationService {
+	return &OperationService{
+		queries:  queries,
+		conn:     conn,
+		log:      logger.New(),
+		now:      time.Now,
+		handlers: make(map[string]OperationHandler),
+	}
+}
+
+func (s *OperationService) Register(kind string, handler OperationHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[kind] = handler
+}
+
+// Enqueue records an operation for the worker. stepsTotal may be zero when
+// the handler only learns it once running.
+func (s *OperationService) Enqueue(ctx context.Context, kind string, userID uuid.UUID, workspaceID *uuid.UUID, params any, stepsTotal int32) (*domain.Operation, error) {
+	op, err := s.create(ctx, s.queries, kind, userID, workspaceID, params, stepsTotal)
+	if err != nil {
+		return nil, err
+	}
+	s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Operation queued", "operation_id", op.ID, "kind", kind)
+	return op, nil
+}
+
+// EnqueueWithUpload is Enqueue for operations that need a file from the
+// request, such as an archive to import, which the handler reads with
+// OperationRun.Upload. The upload is stored with the operation and
+// deleted once it finishes.
+func (s *OperationService) EnqueueWithUpload(ctx context.Context, kind string, userID uuid.UUID, workspaceID *uuid.UUID, params any, upload []byte, stepsTotal int32) (*domain.Operation, error) {
+	var op *domain.Operation
+	err := inTx(ctx, s.conn, s.queries, "enqueue_operation", func(qtx *db.Queries) error {
+		var err error
+		op, err = s.create(ctx, qtx, kind, userID, workspaceID, params, stepsTotal)
+		if err != nil {
+			return err
+		}
+		err = qtx.CreateOperationUpload(ctx, db.CreateOperationUploadParams{
+			OperationID: pgconv.UUIDToPg(op.ID),
+			Content:     upload,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store operation upload: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Operation queued", "operation_id", op.ID, "kind", kind, "upload_bytes", len(upload))
+	return op, nil
+}
+
+func (s *OperationService) create(ctx context.Context, queries *db.Queries, kind string, userID uuid.UUID, workspaceID *uuid.UUID, params any, stepsTotal int32) (*domain.Operation, error) {
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode operation params: %w", err)
+	}
+	op, err := queries.CreateOperation(ctx, db.CreateOperationParams{
+		Kind:        kind,
+		UserID:      pgconv.UUIDToPg(userID),
+		WorkspaceID: pgconv.UUIDPtrToPg(workspaceID),
+		Params:      encoded,
+		StepsTotal:  stepsTotal,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create operation: %w", err)
+	}
+	return toDomainOperation(op), nil
+}
+
+// GetOperation returns an operation started by userID. Other users' are
+// reported as not found.
+func (s *OperationService) GetOperation(ctx context.Context, operationID, userID uuid.UUID) (*domain.Operation, error) {
+	op, err := retryRead(ctx, "get_operation", func() (db.Operation, error) {
+		return s.queries.GetOperation(ctx, pgconv.UUIDToPg(operationID))
+	})
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && pgconv.PgToUUID(op.UserID) != userID) {
+		return nil, fmt.Errorf("operation not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get operation: %w", err)
+	}
+	return toDomainOperation(op), nil
+}
+
+// RunPending claims and runs operations one at a time until none are
+// runnable or ctx is done, and returns how many it finished.
+func (s *OperationService) RunPending(ctx context.Context) (int, error) {
+	finished := 0
+	for ctx.Err() == nil {
+		op, err := s.queries.ClaimOperation(ctx, pgconv.TimeToPg(s.now().Add(OperationLease)))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return finished, nil
+		}
+		if err != nil {
+			return finished, fmt.Errorf("failed to claim operation: %w", err)
+		}
+		done, err := s.run(ctx, op)
+		if err != nil {
+			return finished, err
+		}
+		if done {
+			finished++
+		}
+	}
+	return finished, ctx.Err()
+}
+
+// run carries out one claimed operation and records its outcome. It
+// reports false when the operation was left for another attempt.
+func (s *OperationService) run(ctx context.Context, op db.Operation) (bool, error) {
+	id := pgconv.PgToUUID(op.ID)
+	log := &logger.Logger{Logger: s.log.WithContext(ctx).WithUser(pgconv.PgToUUID(op.UserID).String(), "").With("operation_id", id, "kind", op.Kind, "attempt", op.Attempts)}
+
+	if op.Attempts > MaxOperationAttempts {
+		log.Error("Operation abandoned")
+		return true, s.finish(ctx, op, nil, fmt.Errorf("abandoned after %d attempts", MaxOperationAttempts))
+	}
+
+	s.mu.RLock()
+	handler, ok := s.handlers[op.Kind]
+	s.mu.RUnlock()
+	if !ok {
+		return true, s.finish(ctx, op, nil, fmt.Errorf("unknown operation kind %q", op.Kind))
+	}
+
+	run := &OperationRun{
+		ID:          id,
+		UserID:      pgconv.PgToUUID(op.UserID),
+		WorkspaceID: pgconv.PgToUUIDPtr(op.WorkspaceID),
+		Params:      op.Params,
+		State:       op.State,
+		StepsDone:   op.StepsDone,
+		StepsTotal:  op.StepsTotal,
+		attempt:     op.Attempts,
+		queries:     s.queries,
+		now:         s.now,
+	}
+	if op.Attempts > 1 {
+		log.Info("Resuming operation", "steps_done", op.StepsDone, "steps_total", op.StepsTotal)
+	}
+
+	result, err := handler(ctx, run)
+	switch {
+	case errors.Is(err, errOperationLost):
+		log.Warn("Operation taken over by another worker")
+		return false, nil
+	case ctx.Err() != nil:
+		// Shutting down: the lease runs out and the next worker resumes.
+		return false, nil
+	case err != nil:
+		log.WithError(err).Error("Operation failed", "steps_done", run.StepsDone)
+	default:
+		log.Info("Operation succeeded", "steps_total", run.StepsTotal)
+	}
+	return true, s.finish(ctx, op, result, err)
+}
+
+func (s *OperationService) finish(ctx context.Context, op db.Operation, result any, opErr error) error {
+	params := db.FinishOperationParams{
+		ID:       op.ID,
+		Status:   domain.OperationSucceeded,
+		Attempts: op.Attempts,
+	}
+	if opErr != nil {
+		params.Status = domain.OperationFailed
+		params.Error = pgconv.StringToPg(opErr.Error())
+	} else if result != nil {
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("failed to encode operation result: %w", err)
+		}
+		params.Result = encoded
+	}
+	if _, err := s.queries.FinishOperation(ctx, params); err != nil {
+		return fmt.Errorf("failed to finish operation: %w", err)
+	}
+	// Left behind, the upload goes with the operation after
+	// OperationRetention.
+	if err := s.queries.DeleteOperationUpload(ctx, op.ID); err != nil {
+		s.log.WithContext(ctx).WithError(err).Warn("Failed to delete operation upload", "operation_id", pgconv.PgToUUID(op.ID))
+	}
+	return nil
+}
+
+// DeleteFinished forgets operations finished longer than OperationRetention
+// ago.
+func (s *OperationService) DeleteFinished(ctx context.Context) (int64, error) {
+	deleted, err := s.queries.DeleteFinishedOperations(ctx, pgconv.TimeToPg(s.now().Add(-OperationRetention)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete finished operations: %w", err)
+	}
+	return deleted, nil
+}
+
+// Backlog counts operations waiting for or held by a worker.
+func (s *OperationService) Backlog(ctx context.Context) (int64, error) {
+	count, err := s.queries.CountRunnableOperations(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count operations: %w", err)
+	}
+	return count, nil
+}
+
+func toDomainOperation(op db.Operation) *domain.Operation {
+	result := &domain.Operation{
+		ID:          pgconv.PgToUUID(op.ID),
+		Kind:        op.Kind,
+		WorkspaceID: pgconv.PgToUUIDPtr(op.WorkspaceID),
+		Status:      op.Status,
+		StepsDone:   op.StepsDone,
+		StepsTotal:  op.StepsTotal,
+		Result:      op.Result,
+		Attempts:    op.Attempts,
+		CreatedAt:   pgconv.PgToTime(op.CreatedAt),
+		UpdatedAt:   pgconv.PgToTime(op.UpdatedAt),
+		FinishedAt:  pgconv.PgToTimePtr(op.FinishedAt),
+	}
+	if op.Error.Valid {
+		result.Error = &op.Error.String
+	}
+	switch {
+	case op.Status == domain.OperationSucceeded:
+		result.Progress = 1
+	case op.StepsTotal > 0:
+		result.Progress = float64(op.StepsDone) / float64(op.StepsTotal)
+	}
+	return result
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperationService_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewOperationService(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	type countParams struct {
+		To int32 `json:"to"`
+	}
+	var seen []int32
+	service.Register("count", func(ctx context.Context, run *OperationRun) (any, error) {
+		var params countParams
+		if err := json.Unmarshal(run.Params, &params); err != nil {
+			return nil, err
+		}
+		for i := run.StepsDone; i < params.To; i++ {
+			seen = append(seen, i)
+			if err := run.Checkpoint(ctx, map[string]int32{"last": i}, i+1, params.To); err != nil {
+				return nil, err
+			}
+		}
+		return map[string]int32{"counted": params.To}, nil
+	})
+	service.Register("broken", func(ctx context.Context, run *OperationRun) (any, error) {
+		return nil, errors.New("broken on purpose")
+	})
+
+	t.Run("runs to completion", func(t *testing.T) {
+		seen = nil
+		op, err := service.Enqueue(ctx, "count", testData.FreeUserID, &testData.FreeWorkspaceID, countParams{To: 3}, 3)
+		require.NoError(t, err)
+		assert.Equal(t, domain.OperationPending, op.Status)
+		assert.Zero(t, op.Progress)
+
+		finished, err := service.RunPending(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, finished)
+		assert.Equal(t, []int32{0, 1, 2}, seen)
+
+		op, err = service.GetOperation(ctx, op.ID, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.OperationSucceeded, op.Status)
+		assert.Equal(t, int32(3), op.StepsDone)
+		assert.Equal(t, 1.0, op.Progress)
+		assert.JSONEq(t, `{"counted":3}`, string(op.Result))
+		assert.NotNil(t, op.FinishedAt)
+		assert.True(t, op.Finished())
+	})
+
+	t.Run("handler errors fail the operation", func(t *testing.T) {
+		op, err := service.Enqueue(ctx, "broken", testData.FreeUserID, nil, nil, 0)
+		require.NoError(t, err)
+
+		_, err = service.RunPending(ctx)
+		require.NoError(t, err)
+
+		op, err = service.GetOperation(ctx, op.ID, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.OperationFailed, op.Status)
+		require.NotNil(t, op.Error)
+		assert.Equal(t, "broken on purpose", *op.Error)
+	})
+
+	t.Run("other users cannot see it", func(t *testing.T) {
+		op, err := service.Enqueue(ctx, "count", testData.FreeUserID, nil, countParams{To: 0}, 0)
+		require.NoError(t, err)
+
+		_, err = service.GetOperation(ctx, op.ID, testData.PremiumUserID)
+		assert.EqualError(t, err, "operation not found")
+	})
+}
+
+func TestOperationService_Resume_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewOperationService(testDB.Queries(), testDB.Conn())
+	// Leases taken an hour ago have already run out, so a stopped worker's
+	// operation can be claimed again straight away.
+	service.now = func() time.Time { return time.Now().Add(-time.Hour) }
+
+	var seen []int32
+	var lastState json.RawMessage
+	crashAfter := int32(2)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	service.Register("steps", func(ctx context.Context, run *OperationRun) (any, error) {
+		lastState = run.State
+		for i := run.StepsDone; i < 4; i++ {
+			seen = append(seen, i)
+			if err := run.Checkpoint(ctx, map[string]int32{"next": i + 1}, i+1, 4); err != nil {
+				return nil, err
+			}
+			if i+1 == crashAfter {
+				stop()
+				return nil, ctx.Err()
+			}
+		}
+		return nil, nil
+	})
+
+	op, err := service.Enqueue(context.Background(), "steps", testData.FreeUserID, nil, nil, 4)
+	require.NoError(t, err)
+
+	finished, err := service.RunPending(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, finished)
+
+	op, err = service.GetOperation(context.Background(), op.ID, testData.FreeUserID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OperationRunning, op.Status)
+	assert.Equal(t, int32(2), op.StepsDone)
+	assert.Equal(t, 0.5, op.Progress)
+
+	crashAfter = 0
+	finished, err = service.RunPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, finished)
+	assert.Equal(t, []int32{0, 1, 2, 3}, seen, "resumed after the last checkpoint")
+	assert.JSONEq(t, `{"next":2}`, string(lastState))
+
+	op, err = service.GetOperation(context.Background(), op.ID, testData.FreeUserID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OperationSucceeded, op.Status)
+	assert.Equal(t, int32(2), op.Attempts)
+
+	deleted, err := service.DeleteFinished(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, deleted, "finished operations are kept for a while")
+}
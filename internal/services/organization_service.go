@@ -0,0 +1,551 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// OrganizationService manages organizations, their members and the
+// workspaces they own. Workspace access itself stays with workspace
+// membership; organization roles govern the organization.
+type OrganizationService struct {
+	queries *db.Queries
+	conn    *pgx.Conn
+	log     *logger.Logger
+}
+
+func NewOrganizationService(queries *db.Queries, conn *pgx.Conn) *OrganizationService {
+	return &OrganizationService{
+		queries: queries,
+		conn:    conn,
+		log:     logger.New(),
+	}
+}
+
+// authorizeOrganization checks that userID is a member of organizationID
+// with at least the needed role.
+func authorizeOrganization(ctx context.Context, queries *db.Queries, organizationID, userID uuid.UUID, need domain.OrganizationRole) (domain.OrganizationRole, error) {
+	member, err := retryRead(ctx, "get_organization_member", func() (db.OrganizationMember, error) {
+		return queries.GetOrganizationMember(ctx, db.GetOrganizationMemberParams{
+			OrganizationID: pgconv.UUIDToPg(organizationID),
+			UserID:         pgconv.UUIDToPg(userID),
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("access denied: not a member of this organization")
+	}
+
+	role := domain.OrganizationRole(member.Role)
+	if !role.Includes(need) {
+		return "", fmt.Errorf("access denied: %s role required", need)
+	}
+	return role, nil
+}
+
+// CreateOrganization creates an organization on the tier of userID, who
+// becomes its owner. The billing email defaults to the owner's.
+func (s *OrganizationService) CreateOrganization(ctx context.Context, userID uuid.UUID, req domain.CreateOrganizationRequest) (*domain.Organization, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	user, err := s.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	billingEmail := strings.TrimSpace(req.BillingEmail)
+	if billingEmail == "" {
+		billingEmail = user.Email
+	}
+	tier := domain.UserTier(user.Tier)
+
+	var organizationID uuid.UUID
+	err = inTx(ctx, s.conn, s.queries, "create_organization", func(qtx *db.Queries) error {
+		organization, err := qtx.CreateOrganization(ctx, db.CreateOrganizationParams{
+			Name:             strings.TrimSpace(req.Name),
+			BillingEmail:     billingEmail,
+			Tier:             user.Tier,
+			SeatStorageBytes: tier.GetStorageLimit(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create organization: %w", err)
+		}
+		organizationID = pgconv.PgToUUID(organization.ID)
+
+		_, err = qtx.AddOrganizationMember(ctx, db.AddOrganizationMemberParams{
+			OrganizationID: organization.ID,
+			UserID:         user.ID,
+			Role:           db.OrganizationRoleOwner,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add organization owner: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Created organization",
+		"organization_id", organizationID, "tier", tier)
+	return s.GetOrganization(ctx, organizationID, userID)
+}
+
+// ListOrganizations lists the organizations userID belongs to, with their
+// role in each.
+func (s *OrganizationService) ListOrganizations(ctx context.Context, userID uuid.UUID) ([]domain.Organization, error) {
+	rows, err := s.queries.ListUserOrganizations(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	organizations := make([]domain.Organization, len(rows))
+	for i, row := range rows {
+		organizations[i] = toDomainOrganization(db.ListOrganizationsRow{
+			ID:                row.ID,
+			Name:              row.Name,
+			BillingEmail:      row.BillingEmail,
+			Tier:              row.Tier,
+			SeatStorageBytes:  row.SeatStorageBytes,
+			StorageLimitBytes: row.StorageLimitBytes,
+			CreatedAt:         row.CreatedAt,
+			UpdatedAt:         row.UpdatedAt,
+			MemberCount:       row.MemberCount,
+			WorkspaceCount:    row.WorkspaceCount,
+			StorageUsedBytes:  row.StorageUsedBytes,
+		})
+		organizations[i].Role = domain.OrganizationRole(row.Role)
+	}
+	return organizations, nil
+}
+
+// GetOrganization returns an organization with its storage pool and
+// counts, to its members.
+func (s *OrganizationService) GetOrganization(ctx context.Context, organizationID, userID uuid.UUID) (*domain.Organization, error) {
+	role, err := authorizeOrganization(ctx, s.queries, organizationID, userID, domain.OrgRoleMember)
+	if err != nil {
+		return nil, err
+	}
+
+	organization, err := s.getOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	organization.Role = role
+	return organization, nil
+}
+
+func (s *OrganizationService) getOrganization(ctx context.Context, organizationID uuid.UUID) (*domain.Organization, error) {
+	row, err := s.queries.GetOrganization(ctx, pgconv.UUIDToPg(organizationID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("organization not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	organization := toDomainOrganization(db.ListOrganizationsRow(row))
+	return &organization, nil
+}
+
+// UpdateOrganization renames an organization or changes where its bills
+// go. Only owners can change the billing email.
+func (s *OrganizationService) UpdateOrganization(ctx context.Context, organizationID, userID uuid.UUID, req domain.UpdateOrganizationRequest) (*domain.Organization, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	need := domain.OrgRoleAdmin
+	if req.BillingEmail != nil {
+		need = domain.OrgRoleOwner
+	}
+	if _, err := authorizeOrganization(ctx, s.queries, organizationID, userID, need); err != nil {
+		return nil, err
+	}
+
+	params := db.UpdateOrganizationParams{ID: pgconv.UUIDToPg(organizationID)}
+	if req.Name != nil {
+		params.Name = pgtype.Text{String: strings.TrimSpace(*req.Name), Valid: true}
+	}
+	if req.BillingEmail != nil {
+		params.BillingEmail = pgtype.Text{String: strings.TrimSpace(*req.BillingEmail), Valid: true}
+	}
+	if _, err := s.queries.UpdateOrganization(ctx, params); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("organization not found")
+		}
+		return nil, fmt.Errorf("failed to update organization: %w", err)
+	}
+
+	return s.GetOrganization(ctx, organizationID, userID)
+}
+
+// DeleteOrganization deletes an organization that no longer owns any
+// workspaces. Only owners can delete it.
+func (s *OrganizationService) DeleteOrganization(ctx context.Context, organizationID, userID uuid.UUID) error {
+	if _, err := authorizeOrganization(ctx, s.queries, organizationID, userID, domain.OrgRoleOwner); err != nil {
+		return err
+	}
+
+	deleted, err := s.queries.DeleteOrganization(ctx, pgconv.UUIDToPg(organizationID))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("cannot delete an organization that owns workspaces")
+		}
+		return fmt.Errorf("failed to delete organization: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("organization not found")
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Deleted organization", "organization_id", organizationID)
+	return nil
+}
+
+func (s *OrganizationService) ListMembers(ctx context.Context, organizationID, userID uuid.UUID) ([]domain.OrganizationMember, error) {
+	if _, err := authorizeOrganization(ctx, s.queries, organizationID, userID, domain.OrgRoleMember); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.queries.ListOrganizationMembers(ctx, pgconv.UUIDToPg(organizationID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+
+	members := make([]domain.OrganizationMember, len(rows))
+	for i, row := range rows {
+		members[i] = domain.OrganizationMember{
+			OrganizationID: pgconv.PgToUUID(row.OrganizationID),
+			UserID:         pgconv.PgToUUID(row.UserID),
+			Email:          row.Email,
+			Role:           domain.OrganizationRole(row.Role),
+			InvitedBy:      pgconv.PgToUUIDPtr(row.InvitedBy),
+			CreatedAt:      pgconv.PgToTime(row.CreatedAt),
+		}
+	}
+	return members, nil
+}
+
+// AddMember adds an existing user to the organization. Admins can add
+// members and admins; only owners can add owners. Each member adds a
+// seat's storage to the pool.
+func (s *OrganizationService) AddMember(ctx context.Context, organizationID, userID uuid.UUID, req domain.AddOrganizationMemberRequest) (*domain.OrganizationMember, error) {
+	if !req.Role.Valid() {
+		return nil, fmt.Errorf("invalid role: %q", req.Role)
+	}
+
+	role, err := authorizeOrganization(ctx, s.queries, organizationID, userID, domain.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if !role.Includes(req.Role) {
+		return nil, fmt.Errorf("access denied: %s role required", req.Role)
+	}
+
+	user, err := s.queries.GetUserByEmail(ctx, strings.ToLower(strings.TrimSpace(req.Email)))
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %s", req.Email)
+	}
+
+	member, err := s.queries.AddOrganizationMember(ctx, db.AddOrganizationMemberParams{
+		OrganizationID: pgconv.UUIDToPg(organizationID),
+		UserID:         user.ID,
+		Role:           db.OrganizationRole(req.Role),
+		InvitedBy:      pgconv.UUIDToPg(userID),
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("user is already a member")
+		}
+		return nil, fmt.Errorf("failed to add member: %w", err)
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Added organization member",
+		"organization_id", organizationID, "member_id", pgconv.PgToUUID(user.ID), "role", req.Role)
+
+	return &domain.OrganizationMember{
+		OrganizationID: organizationID,
+		UserID:         pgconv.PgToUUID(member.UserID),
+		Email:          user.Email,
+		Role:           domain.OrganizationRole(member.Role),
+		InvitedBy:      pgconv.PgToUUIDPtr(member.InvitedBy),
+		CreatedAt:      pgconv.PgToTime(member.CreatedAt),
+	}, nil
+}
+
+// UpdateMemberRole changes a member's role. Admins can change the roles of
+// members and admins; only owners can make or unmake owners, and the last
+// owner cannot step down.
+func (s *OrganizationService) UpdateMemberRole(ctx context.Context, organizationID, userID, memberID uuid.UUID, role domain.OrganizationRole) error {
+	if !role.Valid() {
+		return fmt.Errorf("invalid role: %q", role)
+	}
+
+	callerRole, err := authorizeOrganization(ctx, s.queries, organizationID, userID, domain.OrgRoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	return inTx(ctx, s.conn, s.queries, "update_organization_member", func(qtx *db.Queries) error {
+		current, err := qtx.GetOrganizationMember(ctx, db.GetOrganizationMemberParams{
+			OrganizationID: pgconv.UUIDToPg(organizationID),
+			UserID:         pgconv.UUIDToPg(memberID),
+		})
+		if err != nil {
+			return fmt.Errorf("member not found")
+		}
+		if !callerRole.Includes(domain.OrganizationRole(current.Role)) || !callerRole.Includes(role) {
+			return fmt.Errorf("access denied: %s role required", domain.OrgRoleOwner)
+		}
+		if current.Role == db.OrganizationRoleOwner && role != domain.OrgRoleOwner {
+			if err := ensureAnotherOwner(ctx, qtx, organizationID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := qtx.UpdateOrganizationMemberRole(ctx, db.UpdateOrganizationMemberRoleParams{
+			OrganizationID: pgconv.UUIDToPg(organizationID),
+			UserID:         pgconv.UUIDToPg(memberID),
+			Role:           db.OrganizationRole(role),
+		}); err != nil {
+			return fmt.Errorf("failed to update member: %w", err)
+		}
+
+		s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Changed organization member role",
+			"organization_id", organizationID, "member_id", memberID, "role", role)
+		return nil
+	})
+}
+
+// RemoveMember removes a member. Admins can remove members and admins,
+// owners anyone; any member can leave. The last owner cannot leave.
+func (s *OrganizationService) RemoveMember(ctx context.Context, organizationID, userID, memberID uuid.UUID) error {
+	need := domain.OrgRoleAdmin
+	if memberID == userID {
+		need = domain.OrgRoleMember
+	}
+	callerRole, err := authorizeOrganization(ctx, s.queries, organizationID, userID, need)
+	if err != nil {
+		return err
+	}
+
+	return inTx(ctx, s.conn, s.queries, "remove_organization_member", func(qtx *db.Queries) error {
+		current, err := qtx.GetOrganizationMember(ctx, db.GetOrganizationMemberParams{
+			OrganizationID: pgconv.UUIDToPg(organizationID),
+			UserID:         pgconv.UUIDToPg(memberID),
+		})
+		if err != nil {
+			return fmt.Errorf("member not found")
+		}
+		if memberID != userID && !callerRole.Includes(domain.OrganizationRole(current.Role)) {
+			return fmt.Errorf("access denied: %s role required", domain.OrgRoleOwner)
+		}
+		if current.Role == db.OrganizationRoleOwner {
+			if err := ensureAnotherOwner(ctx, qtx, organizationID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := qtx.RemoveOrganizationMember(ctx, db.RemoveOrganizationMemberParams{
+			OrganizationID: pgconv.UUIDToPg(organizationID),
+			UserID:         pgconv.UUIDToPg(memberID),
+		}); err != nil {
+			return fmt.Errorf("failed to remove member: %w", err)
+		}
+
+		s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Removed organization member",
+			"organization_id", organizationID, "member_id", memberID)
+		return nil
+	})
+}
+
+func ensureAnotherOwner(ctx context.Context, qtx *db.Queries, organizationID uuid.UUID) error {
+	owners, err := qtx.CountOrganizationOwners(ctx, pgconv.UUIDToPg(organizationID))
+	if err != nil {
+		return fmt.Errorf("failed to count owners: %w", err)
+	}
+	if owners <= 1 {
+		return fmt.Errorf("cannot remove the last owner of an organization")
+	}
+	return nil
+}
+
+func (s *OrganizationService) ListWorkspaces(ctx context.Context, organizationID, userID uuid.UUID) ([]domain.OrganizationWorkspace, error) {
+	if _, err := authorizeOrganization(ctx, s.queries, organizationID, userID, domain.OrgRoleMember); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.queries.ListOrganizationWorkspaces(ctx, pgconv.UUIDToPg(organizationID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+
+	workspaces := make([]domain.OrganizationWorkspace, len(rows))
+	for i, row := range rows {
+		workspaces[i] = domain.OrganizationWorkspace{
+			ID:               pgconv.PgToUUID(row.ID),
+			Name:             row.Name,
+			OwnerID:          pgconv.PgToUUID(row.UserID),
+			OwnerEmail:       row.OwnerEmail,
+			StorageUsedBytes: pgconv.PgToInt64(row.StorageUsedBytes),
+			FileCount:        row.FileCount,
+			AddedBy:          pgconv.PgToUUIDPtr(row.AddedBy),
+			AddedAt:          pgconv.PgToTime(row.AddedAt),
+		}
+	}
+	return workspaces, nil
+}
+
+// AddWorkspace moves a workspace userID owns into the organization, whose
+// pool must have room for it. The caller must be an organization admin.
+func (s *OrganizationService) AddWorkspace(ctx context.Context, organizationID, userID, workspaceID uuid.UUID) error {
+	if _, err := authorizeOrganization(ctx, s.queries, organizationID, userID, domain.OrgRoleAdmin); err != nil {
+		return err
+	}
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner)
+	if err != nil {
+		return err
+	}
+	if pgconv.PgToUUID(workspace.UserID) != userID {
+		return fmt.Errorf("access denied: only the workspace owner can move it")
+	}
+
+	return inTx(ctx, s.conn, s.queries, "add_organization_workspace", func(qtx *db.Queries) error {
+		organization, err := qtx.GetOrganization(ctx, pgconv.UUIDToPg(organizationID))
+		if err != nil {
+			return fmt.Errorf("organization not found")
+		}
+		pool := toDomainOrganization(db.ListOrganizationsRow(organization))
+		needed := pool.StorageUsedBytes + pgconv.PgToInt64(workspace.StorageUsedBytes)
+		if needed > pool.StorageLimitBytes {
+			return fmt.Errorf("storage limit exceeded: need %d bytes, limit %d bytes", needed, pool.StorageLimitBytes)
+		}
+
+		err = qtx.AddOrganizationWorkspace(ctx, db.AddOrganizationWorkspaceParams{
+			WorkspaceID:    workspace.ID,
+			OrganizationID: pgconv.UUIDToPg(organizationID),
+			AddedBy:        pgconv.UUIDToPg(userID),
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return fmt.Errorf("workspace already belongs to an organization")
+			}
+			return fmt.Errorf("failed to add workspace: %w", err)
+		}
+
+		s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "").
+			Info("Moved workspace into organization", "organization_id", organizationID)
+		return nil
+	})
+}
+
+// RemoveWorkspace returns a workspace to its owner, whose own storage
+// limit applies to it again. Organization admins and the workspace owner
+// can remove it.
+func (s *OrganizationService) RemoveWorkspace(ctx context.Context, organizationID, userID, workspaceID uuid.UUID) error {
+	need := domain.OrgRoleAdmin
+	workspace, err := s.queries.GetWorkspaceByID(ctx, pgconv.UUIDToPg(workspaceID))
+	if err == nil && pgconv.PgToUUID(workspace.UserID) == userID {
+		need = domain.OrgRoleMember
+	}
+	if _, err := authorizeOrganization(ctx, s.queries, organizationID, userID, need); err != nil {
+		return err
+	}
+
+	removed, err := s.queries.RemoveOrganizationWorkspace(ctx, db.RemoveOrganizationWorkspaceParams{
+		WorkspaceID:    pgconv.UUIDToPg(workspaceID),
+		OrganizationID: pgconv.UUIDToPg(organizationID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove workspace: %w", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("workspace not found")
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "").
+		Info("Removed workspace from organization", "organization_id", organizationID)
+	return nil
+}
+
+// AdminListOrganizations lists every organization, for operators.
+func (s *OrganizationService) AdminListOrganizations(ctx context.Context) ([]domain.Organization, error) {
+	rows, err := s.queries.ListOrganizations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	organizations := make([]domain.Organization, len(rows))
+	for i, row := range rows {
+		organizations[i] = toDomainOrganization(row)
+	}
+	return organizations, nil
+}
+
+// AdminUpdateOrganization changes an organization's tier or storage pool
+// on behalf of adminID. A new tier sets the storage each seat brings.
+func (s *OrganizationService) AdminUpdateOrganization(ctx context.Context, adminID, organizationID uuid.UUID, update domain.AdminOrganizationUpdate) (*domain.Organization, error) {
+	if err := update.Validate(); err != nil {
+		return nil, err
+	}
+
+	params := db.UpdateOrganizationAdminParams{ID: pgconv.UUIDToPg(organizationID)}
+	if update.Tier != nil {
+		params.Tier = db.NullUserTier{UserTier: db.UserTier(*update.Tier), Valid: true}
+		params.SeatStorageBytes = pgtype.Int8{Int64: update.Tier.GetStorageLimit(), Valid: true}
+	}
+	if update.StorageLimitBytes != nil {
+		params.SetStorageLimit = true
+		params.StorageLimitBytes = pgtype.Int8{Int64: *update.StorageLimitBytes, Valid: *update.StorageLimitBytes > 0}
+	}
+	if _, err := s.queries.UpdateOrganizationAdmin(ctx, params); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("organization not found")
+		}
+		return nil, fmt.Errorf("failed to update organization: %w", err)
+	}
+
+	organization, err := s.getOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	s.log.WithContext(ctx).WithUser(adminID.String(), "").Info("Updated organization",
+		"organization_id", organizationID,
+		"tier", organization.Tier,
+		"storage_limit_bytes", organization.StorageLimitBytes)
+	return organization, nil
+}
+
+func toDomainOrganization(row db.ListOrganizationsRow) domain.Organization {
+	var custom *int64
+	if row.StorageLimitBytes.Valid {
+		custom = &row.StorageLimitBytes.Int64
+	}
+	return domain.Organization{
+		ID:                 pgconv.PgToUUID(row.ID),
+		Name:               row.Name,
+		BillingEmail:       row.BillingEmail,
+		Tier:               domain.UserTier(row.Tier),
+		StorageLimitBytes:  domain.OrganizationStorageLimit(row.SeatStorageBytes, custom, row.MemberCount),
+		CustomStorageLimit: row.StorageLimitBytes.Valid,
+		StorageUsedBytes:   row.StorageUsedBytes,
+		MemberCount:        row.MemberCount,
+		WorkspaceCount:     row.WorkspaceCount,
+		CreatedAt:          pgconv.PgToTime(row.CreatedAt),
+		UpdatedAt:          pgconv.PgToTime(row.UpdatedAt),
+	}
+}
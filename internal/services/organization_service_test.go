@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrganizationService_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewOrganizationService(testDB.Queries(), testDB.Conn())
+	workspaceService := NewWorkspaceService(testDB.Queries(), storage.NewPostgresBackend(testDB.Queries()))
+	fileService := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	premiumUser, err := testDB.Queries().GetUserByID(ctx, pgconv.UUIDToPg(testData.PremiumUserID))
+	require.NoError(t, err)
+
+	organization, err := service.CreateOrganization(ctx, testData.FreeUserID, domain.CreateOrganizationRequest{Name: "Acme"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.OrgRoleOwner, organization.Role)
+	assert.Equal(t, domain.TierFree, organization.Tier)
+	assert.Equal(t, domain.TierFree.GetStorageLimit(), organization.StorageLimitBytes)
+	orgID := organization.ID
+
+	t.Run("members pool their seats", func(t *testing.T) {
+		_, err := service.AddMember(ctx, orgID, testData.FreeUserID, domain.AddOrganizationMemberRequest{
+			Email: premiumUser.Email,
+			Role:  domain.OrgRoleMember,
+		})
+		require.NoError(t, err)
+
+		organization, err := service.GetOrganization(ctx, orgID, testData.PremiumUserID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.OrgRoleMember, organization.Role)
+		assert.Equal(t, int64(2), organization.MemberCount)
+		assert.Equal(t, 2*domain.TierFree.GetStorageLimit(), organization.StorageLimitBytes)
+
+		_, err = service.AddMember(ctx, orgID, testData.PremiumUserID, domain.AddOrganizationMemberRequest{
+			Email: premiumUser.Email,
+			Role:  domain.OrgRoleMember,
+		})
+		assert.ErrorContains(t, err, "admin role required")
+	})
+
+	t.Run("workspaces use the pool", func(t *testing.T) {
+		require.NoError(t, service.AddWorkspace(ctx, orgID, testData.FreeUserID, testData.FreeWorkspaceID))
+		assert.ErrorContains(t, service.AddWorkspace(ctx, orgID, testData.FreeUserID, testData.FreeWorkspaceID), "already belongs")
+
+		info, err := workspaceService.GetWorkspaceStorageInfo(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		require.NoError(t, err)
+		require.NotNil(t, info.OrganizationID)
+		assert.Equal(t, orgID, *info.OrganizationID)
+		assert.Equal(t, 2*domain.TierFree.GetStorageLimit(), info.StorageLimitBytes)
+
+		tiny, reset := int64(4), int64(0)
+		_, err = service.AdminUpdateOrganization(ctx, testData.PremiumUserID, orgID, domain.AdminOrganizationUpdate{StorageLimitBytes: &tiny})
+		require.NoError(t, err)
+		_, err = fileService.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     "big.md",
+			Content:      []byte("too big for the pool"),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		assert.ErrorContains(t, err, "storage limit exceeded")
+
+		_, err = service.AdminUpdateOrganization(ctx, testData.PremiumUserID, orgID, domain.AdminOrganizationUpdate{StorageLimitBytes: &reset})
+		require.NoError(t, err)
+	})
+
+	t.Run("the last owner stays", func(t *testing.T) {
+		assert.ErrorContains(t, service.RemoveMember(ctx, orgID, testData.FreeUserID, testData.FreeUserID), "last owner")
+		assert.ErrorContains(t, service.UpdateMemberRole(ctx, orgID, testData.FreeUserID, testData.FreeUserID, domain.OrgRoleAdmin), "last owner")
+
+		require.NoError(t, service.UpdateMemberRole(ctx, orgID, testData.FreeUserID, testData.PremiumUserID, domain.OrgRoleOwner))
+		require.NoError(t, service.RemoveMember(ctx, orgID, testData.FreeUserID, testData.FreeUserID), "another owner remains")
+	})
+
+	t.Run("organizations with workspaces cannot be deleted", func(t *testing.T) {
+		assert.ErrorContains(t, service.DeleteOrganization(ctx, orgID, testData.PremiumUserID), "owns workspaces")
+
+		require.NoError(t, service.RemoveWorkspace(ctx, orgID, testData.PremiumUserID, testData.FreeWorkspaceID))
+		require.NoError(t, service.DeleteOrganization(ctx, orgID, testData.PremiumUserID))
+
+		organizations, err := service.ListOrganizations(ctx, testData.PremiumUserID)
+		require.NoError(t, err)
+		assert.Empty(t, organizations)
+	})
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/duckonomy/noture/pkg/webauthn"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Challenges are kept as auth sessions under these providers. A sign-in
+// challenge with a user is a second factor, which only that user's
+// passkeys may answer.
+const (
+	passkeyLoginProvider    = "passkey"
+	passkeyRegisterProvider = "passkey-register"
+)
+
+// PasskeyService registers passkeys and signs users in with them, either
+// on their own or after a password.
+type PasskeyService struct {
+	queries  *db.Queries
+	conn     *pgx.Conn
+	sessions AuthSessionStore
+	rp       *webauthn.RelyingParty
+	log      *logger.Logger
+}
+
+func NewPasskeyService(queries *db.Queries, conn *pgx.Conn, sessions AuthSessionStore, rp *webauthn.RelyingParty) *PasskeyService {
+	return &PasskeyService{
+		queries:  queries,
+		conn:     conn,
+		sessions: sessions,
+		rp:       rp,
+		log:      logger.New(),
+	}
+}
+
+// BeginRegistration issues the options for registering a new passkey to
+// userID.
+func (s *PasskeyService) BeginRegistration(ctx context.Context, userID uuid.UUID) (*domain.PasskeyCreationOptions, error) {
+	user, err := s.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	existing, err := s.queries.ListPasskeys(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+	if len(existing) >= domain.MaxPasskeysPerUser {
+		return nil, fmt.Errorf("invalid passkey: at most %d per account", domain.MaxPasskeysPerUser)
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessions.CreateLinkState(ctx, challenge, passkeyRegisterProvider, userID, domain.PKCE{}, domain.PasskeyCeremonyTimeout); err != nil {
+		return nil, err
+	}
+
+	options := &domain.PasskeyCreationOptions{
+		Challenge: challenge,
+		RP:        domain.PasskeyRelyingParty{ID: s.rp.ID, Name: s.rp.Name},
+		User: domain.PasskeyUser{
+			ID:          base64.RawURLEncoding.EncodeToString(userID[:]),
+			Name:        user.Email,
+			DisplayName: user.Email,
+		},
+		Timeout:            domain.PasskeyCeremonyTimeout.Milliseconds(),
+		ExcludeCredentials: passkeyDescriptors(existing),
+		AuthenticatorSelection: domain.PasskeySelection{
+			ResidentKey:      "required",
+			UserVerification: "required",
+		},
+		Attestation: "none",
+	}
+	for _, alg := range webauthn.SupportedAlgorithms {
+		options.PubKeyCredParams = append(options.PubKeyCredParams, domain.PasskeyCredentialType{Type: "public-key", Alg: alg})
+	}
+	return options, nil
+}
+
+// FinishRegistration stores the passkey an authenticator created for
+// options from BeginRegistration.
+func (s *PasskeyService) FinishRegistration(ctx context.Context, userID uuid.UUID, name string, att webauthn.Attestation) (*domain.Passkey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if utf8.RuneCountInString(name) > domain.MaxPasskeyNameLength {
+		return nil, fmt.Errorf("invalid passkey name: at most %d characters", domain.MaxPasskeyNameLength)
+	}
+
+	challenge, session, err := s.consumeChallenge(ctx, att.ClientDataJSON, passkeyRegisterProvider)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID == nil || *session.UserID != userID {
+		return nil, fmt.Errorf("invalid passkey: challenge expired")
+	}
+	cred, err := s.rp.VerifyRegistration(challenge, att)
+	if err != nil {
+		return nil, fmt.Errorf("invalid passkey: %w", err)
+	}
+
+	row, err := s.queries.CreatePasskey(ctx, db.CreatePasskeyParams{
+		UserID:       pgconv.UUIDToPg(userID),
+		CredentialID: cred.ID,
+		PublicKey:    cred.PublicKey,
+		SignCount:    int64(cred.SignCount),
+		Name:         name,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("passkey already registered")
+		}
+		return nil, fmt.Errorf("failed to store passkey: %w", err)
+	}
+
+	s.log.WithContext(ctx).LogAuthEvent("passkey_registered", userID.String(), "passkey")
+	return toDomainPasskey(row), nil
+}
+
+// BeginLogin issues the options for signing in with any passkey. A
+// userCode ties the sign-in to a pending device authorization, which it
+// then approves.
+func (s *PasskeyService) BeginLogin(ctx context.Context, userCode string) (*domain.PasskeyRequestOptions, error) {
+	deviceCode := ""
+	if userCode != "" {
+		session, err := s.sessions.GetDeviceSessionByUserCode(ctx, strings.ToUpper(userCode))
+		if err != nil {
+			return nil, fmt.Errorf("invalid or expired user code")
+		}
+		deviceCode = session.SessionKey
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessions.CreateOAuthState(ctx, challenge, passkeyLoginProvider, deviceCode, domain.PKCE{}, domain.PasskeyCeremonyTimeout); err != nil {
+		return nil, err
+	}
+	return s.requestOptions(challenge, nil), nil
+}
+
+// BeginSecondFactor issues the options for userID, who has just given
+// their password, to confirm the sign-in with one of their passkeys.
+func (s *PasskeyService) BeginSecondFactor(ctx context.Context, userID uuid.UUID) (*domain.PasskeyRequestOptions, error) {
+	passkeys, err := s.queries.ListPasskeys(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessions.CreateLinkState(ctx, challenge, passkeyLoginProvider, userID, domain.PKCE{}, domain.PasskeyCeremonyTimeout); err != nil {
+		return nil, err
+	}
+	return s.requestOptions(challenge, passkeys), nil
+}
+
+func (s *PasskeyService) requestOptions(challenge string, allowed []db.Passkey) *domain.PasskeyRequestOptions {
+	return &domain.PasskeyRequestOptions{
+		Challenge:        challenge,
+		RPID:             s.rp.ID,
+		Timeout:          domain.PasskeyCeremonyTimeout.Milliseconds(),
+		AllowCredentials: passkeyDescriptors(allowed),
+		UserVerification: "required",
+	}
+}
+
+// FinishLogin checks an assertion made for options from BeginLogin or
+// BeginSecondFactor and returns the user it signs in.
+func (s *PasskeyService) FinishLogin(ctx context.Context, assertion webauthn.Assertion) (*domain.PasskeyLogin, error) {
+	challenge, session, err := s.consumeChallenge(ctx, assertion.ClientDataJSON, passkeyLoginProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	passkey, err := s.queries.GetPasskeyByCredentialID(ctx, assertion.CredentialID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("invalid passkey: unknown credential")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get passkey: %w", err)
+	}
+	userID := pgconv.PgToUUID(passkey.UserID)
+	if session.UserID != nil && *session.UserID != userID {
+		return nil, fmt.Errorf("invalid passkey: unknown credential")
+	}
+
+	signCount, err := s.rp.VerifyAssertion(challenge, webauthn.Credential{
+		ID:        passkey.CredentialID,
+		PublicKey: passkey.PublicKey,
+		SignCount: uint32(passkey.SignCount),
+	}, assertion)
+	if errors.Is(err, webauthn.ErrSignCount) {
+		s.log.WithContext(ctx).Warn("Passkey signature counter went backwards, it may be cloned",
+			"user_id", userID, "passkey_id", pgconv.PgToUUID(passkey.ID))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid passkey: %w", err)
+	}
+	if err := s.queries.TouchPasskey(ctx, db.TouchPasskeyParams{ID: passkey.ID, SignCount: int64(signCount)}); err != nil {
+		return nil, fmt.Errorf("failed to update passkey: %w", err)
+	}
+
+	user, err := s.queries.GetUserByID(ctx, passkey.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	if user.DisabledAt.Valid {
+		return nil, fmt.Errorf("account disabled")
+	}
+
+	login := &domain.PasskeyLogin{User: toDomainUser(user)}
+	if session.DeviceCode != "" {
+		if err := s.sessions.ApproveDeviceSession(ctx, session.DeviceCode, userID); err != nil {
+			if errors.Is(err, ErrDeviceSessionNotPending) {
+				return nil, fmt.Errorf("device authorization already approved or expired")
+			}
+			return nil, err
+		}
+		login.DeviceApproved = true
+	}
+	s.log.WithContext(ctx).LogAuthEvent("passkey_login", userID.String(), "passkey")
+	return login, nil
+}
+
+// consumeChallenge finds the ceremony a response answers. Each challenge
+// is answered at most once.
+func (s *PasskeyService) consumeChallenge(ctx context.Context, clientDataJSON []byte, provider string) (string, *domain.AuthSession, error) {
+	challenge, err := webauthn.ChallengeOf(clientDataJSON)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid passkey: %w", err)
+	}
+	session, err := s.sessions.ConsumeOAuthState(ctx, challenge, provider)
+	if errors.Is(err, ErrAuthSessionNotFound) {
+		return "", nil, fmt.Errorf("invalid passkey: challenge expired")
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return challenge, session, nil
+}
+
+// HasPasskeys reports whether userID must confirm password sign-ins with
+// a passkey, which every account with one must.
+func (s *PasskeyService) HasPasskeys(ctx context.Context, userID uuid.UUID) (bool, error) {
+	n, err := s.queries.CountPasskeys(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return false, fmt.Errorf("failed to count passkeys: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (s *PasskeyService) ListPasskeys(ctx context.Context, userID uuid.UUID) ([]domain.Passkey, error) {
+	rows, err := s.queries.ListPasskeys(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+	passkeys := make([]domain.Passkey, len(rows))
+	for i, row := range rows {
+		passkeys[i] = *toDomainPasskey(row)
+	}
+	return passkeys, nil
+}
+
+// DeletePasskey removes one of userID's passkeys. The last way to sign
+// in cannot be removed; the user row is locked while that is checked, as
+// IdentityService.Unlink does.
+func (s *PasskeyService) DeletePasskey(ctx context.Context, userID, passkeyID uuid.UUID) error {
+	err := inTx(ctx, s.conn, s.queries, "delete_passkey", func(qtx *db.Queries) error {
+		user, err := qtx.LockUser(ctx, pgconv.UUIDToPg(userID))
+		if err != nil {
+			return fmt.Errorf("failed to load user: %w", err)
+		}
+		if user.PasswordHash == "" {
+			identities, err := qtx.ListOAuthIdentities(ctx, user.ID)
+			if err != nil {
+				return fmt.Errorf("failed to list identities: %w", err)
+			}
+			passkeys, err := qtx.CountPasskeys(ctx, user.ID)
+			if err != nil {
+				return fmt.Errorf("failed to count passkeys: %w", err)
+			}
+			if len(identities) == 0 && passkeys == 1 {
+				return fmt.Errorf("cannot delete the only way to sign in: set a password first")
+			}
+		}
+
+		n, err := qtx.DeletePasskey(ctx, db.DeletePasskeyParams{
+			ID:     pgconv.UUIDToPg(passkeyID),
+			UserID: user.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete passkey: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("passkey not found")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.log.WithContext(ctx).LogAuthEvent("passkey_deleted", userID.String(), "passkey")
+	return nil
+}
+
+func passkeyDescriptors(passkeys []db.Passkey) []domain.PasskeyDescriptor {
+	descriptors := make([]domain.PasskeyDescriptor, len(passkeys))
+	for i, p := range passkeys {
+		descriptors[i] = domain.PasskeyDescriptor{Type: "public-key", ID: base64.RawURLEncoding.EncodeToString(p.CredentialID)}
+	}
+	return descriptors
+}
+
+func toDomainPasskey(p db.Passkey) *domain.Passkey {
+	return &domain.Passkey{
+		ID:         pgconv.PgToUUID(p.ID),
+		Name:       p.Name,
+		CreatedAt:  pgconv.PgToTime(p.CreatedAt),
+		LastUsedAt: pgconv.PgToTimePtr(p.LastUsedAt),
+	}
+}
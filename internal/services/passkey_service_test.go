@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/webauthn"
+	"github.com/duckonomy/noture/pkg/webauthn/webauthntest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasskeyService_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	rp, err := webauthn.NewRelyingParty("https://noture.test", "Noture")
+	require.NoError(t, err)
+	sessions := NewPostgresAuthSessionStore(testDB.Queries())
+	service := NewPasskeyService(testDB.Queries(), testDB.Conn(), sessions, rp)
+	ctx := context.Background()
+	authenticator := webauthntest.NewAuthenticator("laptop-key")
+
+	options, err := service.BeginRegistration(ctx, testData.FreeUserID)
+	require.NoError(t, err)
+	assert.Equal(t, "noture.test", options.RP.ID)
+	assert.Empty(t, options.ExcludeCredentials)
+
+	t.Run("challenge is bound to its user", func(t *testing.T) {
+		_, err := service.FinishRegistration(ctx, testData.PremiumUserID, "", authenticator.Create(rp, options.Challenge))
+		assert.ErrorContains(t, err, "invalid passkey: challenge expired")
+	})
+
+	options, err = service.BeginRegistration(ctx, testData.FreeUserID)
+	require.NoError(t, err)
+	passkey, err := service.FinishRegistration(ctx, testData.FreeUserID, " Laptop ", authenticator.Create(rp, options.Challenge))
+	require.NoError(t, err)
+	assert.Equal(t, "Laptop", passkey.Name)
+
+	has, err := service.HasPasskeys(ctx, testData.FreeUserID)
+	require.NoError(t, err)
+	assert.True(t, has)
+
+	t.Run("sign in", func(t *testing.T) {
+		request, err := service.BeginLogin(ctx, "")
+		require.NoError(t, err)
+		assertion := authenticator.Get(rp, request.Challenge)
+
+		login, err := service.FinishLogin(ctx, assertion)
+		require.NoError(t, err)
+		assert.Equal(t, testData.FreeUserID, login.User.ID)
+		assert.False(t, login.DeviceApproved)
+
+		_, err = service.FinishLogin(ctx, assertion)
+		assert.ErrorContains(t, err, "challenge expired", "a challenge is answered once")
+	})
+
+	t.Run("second factor only takes the user's passkeys", func(t *testing.T) {
+		request, err := service.BeginSecondFactor(ctx, testData.PremiumUserID)
+		require.NoError(t, err)
+
+		_, err = service.FinishLogin(ctx, authenticator.Get(rp, request.Challenge))
+		assert.ErrorContains(t, err, "unknown credential")
+
+		request, err = service.BeginSecondFactor(ctx, testData.FreeUserID)
+		require.NoError(t, err)
+		require.Len(t, request.AllowCredentials, 1)
+		_, err = service.FinishLogin(ctx, authenticator.Get(rp, request.Challenge))
+		assert.NoError(t, err)
+	})
+
+	t.Run("device authorization", func(t *testing.T) {
+		require.NoError(t, sessions.CreateDeviceSession(ctx, "device-code", "ABCD-1234", "cli", domain.PasskeyCeremonyTimeout))
+		request, err := service.BeginLogin(ctx, "abcd-1234")
+		require.NoError(t, err)
+
+		login, err := service.FinishLogin(ctx, authenticator.Get(rp, request.Challenge))
+		require.NoError(t, err)
+		assert.True(t, login.DeviceApproved)
+		session, err := sessions.GetDeviceSession(ctx, "device-code")
+		require.NoError(t, err)
+		assert.Equal(t, testData.FreeUserID, *session.UserID)
+	})
+
+	t.Run("list and delete", func(t *testing.T) {
+		passkeys, err := service.ListPasskeys(ctx, testData.FreeUserID)
+		require.NoError(t, err)
+		require.Len(t, passkeys, 1)
+		assert.NotNil(t, passkeys[0].LastUsedAt)
+
+		assert.ErrorContains(t, service.DeletePasskey(ctx, testData.PremiumUserID, passkey.ID), "passkey not found")
+		require.NoError(t, service.DeletePasskey(ctx, testData.FreeUserID, passkey.ID))
+	})
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/duckonomy/noture/pkg/email"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// PasswordResetExpiry is how long a password reset code stays valid.
+const PasswordResetExpiry = time.Hour
+
+// RequestPasswordReset emails a reset code to the account registered
+// under address. Unknown and disabled accounts get nothing, but the call
+// succeeds all the same, so it does not reveal which addresses are
+// registered. Asking again replaces the earlier code.
+func (s *UserService) RequestPasswordReset(ctx context.Context, address string) error {
+	address, err := normalizeEmail(address)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.queries.GetUserByEmail(ctx, address)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.DisabledAt.Valid || user.DeleteAfter.Valid {
+		return nil
+	}
+
+	token, err := s.createPasswordCode(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	msg := email.Message{
+		To:      user.Email,
+		Subject: "Reset your Noture password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your Noture account on %s.\n\n"+
+			"Enter this code in the app to choose a new password:\n\n%s\n\n"+
+			"The code expires in an hour and signs you out on every device once used. "+
+			"If you did not ask for it, you can ignore this email.\n",
+			s.baseURL, token),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send password reset: %w", err)
+	}
+
+	s.log.WithContext(ctx).LogAuthEvent("password_reset_requested", pgconv.PgToUUID(user.ID).String(), "email")
+	return nil
+}
+
+// RequestPasswordCode emails a code to userID, who has no password yet,
+// with which ChangePassword sets a first one. Only someone who can read
+// the account's email gets to add a password, not whoever holds one of
+// its API tokens.
+func (s *UserService) RequestPasswordCode(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+	if user.PasswordHash != "" {
+		return fmt.Errorf("cannot send a code: the account has a password, change it with the current one")
+	}
+
+	token, err := s.createPasswordCode(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	msg := email.Message{
+		To:      user.Email,
+		Subject: "Set your Noture password",
+		Body: fmt.Sprintf("Someone signed in to your Noture account on %s asked to add a password to it.\n\n"+
+			"Enter this code in the app to choose the password:\n\n%s\n\n"+
+			"The code expires in an hour and signs you out on every other device once used. "+
+			"If you did not ask for it, sign out everywhere from the app.\n",
+			s.baseURL, token),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send password code: %w", err)
+	}
+
+	s.log.WithContext(ctx).LogAuthEvent("password_code_requested", userID.String(), "email")
+	return nil
+}
+
+// createPasswordCode stores a new code for userID, replacing any earlier
+// one, and returns it.
+func (s *UserService) createPasswordCode(ctx context.Context, userID pgtype.UUID) (string, error) {
+	token, tokenHash, err := auth.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	err = s.queries.CreatePasswordReset(ctx, db.CreatePasswordResetParams{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: pgconv.TimeToPg(time.Now().Add(PasswordResetExpiry)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create password reset: %w", err)
+	}
+	return token, nil
+}
+
+// ResetPassword sets a new password with a code from RequestPasswordReset
+// and revokes every API token of the account. A code works once.
+func (s *UserService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	if err := auth.ValidatePassword(newPassword); err != nil {
+		return err
+	}
+	hash, err := auth.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	userID, err := s.queries.ConsumePasswordReset(ctx, auth.HashToken(token))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("invalid or expired reset code")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check reset code: %w", err)
+	}
+
+	err = s.queries.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{
+		ID:           userID,
+		PasswordHash: hash,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	s.log.WithContext(ctx).LogAuthEvent("password_reset", pgconv.PgToUUID(userID).String(), "email")
+
+	_, err = s.RevokeAllTokens(ctx, pgconv.PgToUUID(userID), nil, "password_reset")
+	return err
+}
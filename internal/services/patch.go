@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/patch"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// PatchFile applies a unified diff to the file's current content and
+// stores the result as a new version. The file must still have BaseHash;
+// the check is repeated under the upload's path lock, so a write landing
+// between reading the base and storing the result is reported as "file
+// changed" rather than overwritten.
+func (s *FileService) PatchFile(ctx context.Context, req domain.FilePatchRequest, userID uuid.UUID) (*domain.FileUploadResult, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, req.WorkspaceID, userID, domain.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	// Read from the primary: a lagging replica would refuse fresh bases.
+	file, err := s.queries.GetFile(ctx, db.GetFileParams{
+		WorkspaceID: pgconv.UUIDToPg(req.WorkspaceID),
+		FilePath:    req.FilePath,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("file not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	if file.ContentHash != req.BaseHash {
+		return nil, fmt.Errorf("file changed")
+	}
+
+	base, err := s.blobs.Get(ctx, file.ContentHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file content: %w", err)
+	}
+	content, err := patch.Apply(base, req.Patch)
+	if errors.Is(err, patch.ErrMismatch) {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.UploadFile(ctx, domain.FileUploadRequest{
+		WorkspaceID:  req.WorkspaceID,
+		FilePath:     req.FilePath,
+		Content:      content,
+		LastModified: req.LastModified,
+		ClientID:     req.ClientID,
+		NoteID:       req.NoteID,
+		BaseHash:     &req.BaseHash,
+	}, userID)
+}
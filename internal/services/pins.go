@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+)
+
+// PinFile pins a file for the user, so their clients can show it among
+// their favorites. Pinning a pinned file does nothing. Pins follow a file
+// across moves and go with it when it is deleted.
+func (s *FileService) PinFile(ctx context.Context, workspaceID, userID uuid.UUID, filePath string) error {
+	file, err := s.pinnableFile(ctx, workspaceID, userID, filePath)
+	if err != nil {
+		return err
+	}
+
+	count, err := s.queries.CountFilePins(ctx, db.CountFilePinsParams{
+		UserID:      pgconv.UUIDToPg(userID),
+		WorkspaceID: file.WorkspaceID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count pinned files: %w", err)
+	}
+	if count >= domain.MaxPinnedFiles {
+		return fmt.Errorf("invalid pin: at most %d files can be pinned per workspace", domain.MaxPinnedFiles)
+	}
+
+	err = s.queries.PinFile(ctx, db.PinFileParams{
+		UserID:      pgconv.UUIDToPg(userID),
+		FileID:      file.ID,
+		WorkspaceID: file.WorkspaceID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to pin file: %w", err)
+	}
+	return nil
+}
+
+// UnpinFile removes the user's pin from a file. Unpinning a file that is
+// not pinned does nothing.
+func (s *FileService) UnpinFile(ctx context.Context, workspaceID, userID uuid.UUID, filePath string) error {
+	file, err := s.pinnableFile(ctx, workspaceID, userID, filePath)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.queries.UnpinFile(ctx, db.UnpinFileParams{
+		UserID: pgconv.UUIDToPg(userID),
+		FileID: file.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unpin file: %w", err)
+	}
+	return nil
+}
+
+// ListPinnedFiles lists the files the user pinned in the workspace, in the
+// order they were pinned.
+func (s *FileService) ListPinnedFiles(ctx context.Context, workspaceID, userID uuid.UUID) ([]domain.PinnedFile, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	rows, err := retryRead(ctx, "list_pinned_files", func() ([]db.ListPinnedFilesRow, error) {
+		return s.queries.ListPinnedFiles(db.PreferReplica(ctx), db.ListPinnedFilesParams{
+			UserID:      pgconv.UUIDToPg(userID),
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pinned files: %w", err)
+	}
+
+	files := make([]domain.PinnedFile, len(rows))
+	for i, row := range rows {
+		files[i] = domain.PinnedFile{
+			FileInfo: domain.FileInfo{
+				ID:           pgconv.PgToUUID(row.ID),
+				WorkspaceID:  pgconv.PgToUUID(row.WorkspaceID),
+				FilePath:     row.FilePath,
+				ContentHash:  row.ContentHash,
+				SizeBytes:    row.SizeBytes,
+				MimeType:     pgconv.PgToString(row.MimeType),
+				LastModified: pgconv.PgToTime(row.LastModified),
+				UpdatedAt:    pgconv.PgToTime(row.UpdatedAt),
+			},
+			PinnedAt: pgconv.PgToTime(row.PinnedAt),
+		}
+	}
+	return files, nil
+}
+
+// pinnableFile looks up a file the user may pin: any member, viewers
+// included, keeps their own favorites.
+func (s *FileService) pinnableFile(ctx context.Context, workspaceID, userID uuid.UUID, filePath string) (db.File, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return db.File{}, err
+	}
+
+	file, err := s.queries.GetFile(ctx, db.GetFileParams{
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		FilePath:    filePath,
+	})
+	if err != nil {
+		return db.File{}, fmt.Errorf("file not found: %w", err)
+	}
+	return file, nil
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/duckonomy/noture/pkg/policy"
+	"github.com/google/uuid"
+)
+
+const MaxPolicyViolationPageSize = 200
+
+// PolicyService manages content policy rules and enforces them on actions
+// that publish content.
+type PolicyService struct {
+	queries *db.Queries
+	log     *logger.Logger
+}
+
+func NewPolicyService(queries *db.Queries) *PolicyService {
+	return &PolicyService{
+		queries: queries,
+		log:     logger.New(),
+	}
+}
+
+func (s *PolicyService) CreateRule(ctx context.Context, adminID uuid.UUID, req domain.CreatePolicyRuleRequest) (*domain.PolicyRule, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	err := policy.Validate(policy.Rule{Name: req.Name, Detector: req.Detector, Pattern: req.Pattern, Action: req.Action})
+	if err != nil {
+		return nil, err
+	}
+
+	row, err := s.queries.CreatePolicyRule(ctx, db.CreatePolicyRuleParams{
+		Name:      req.Name,
+		Detector:  req.Detector,
+		Pattern:   req.Pattern,
+		Action:    req.Action,
+		CreatedBy: pgconv.UUIDToPg(adminID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create policy rule: %w", err)
+	}
+
+	rule := toDomainPolicyRule(row)
+	s.log.WithContext(ctx).WithUser(adminID.String(), "").Info("Created content policy rule",
+		"rule_id", rule.ID,
+		"detector", rule.Detector,
+		"action", rule.Action)
+	return &rule, nil
+}
+
+func (s *PolicyService) ListRules(ctx context.Context) ([]domain.PolicyRule, error) {
+	rows, err := retryRead(ctx, "list_policy_rules", func() ([]db.PolicyRule, error) {
+		return s.queries.ListPolicyRules(ctx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list policy rules: %w", err)
+	}
+
+	rules := make([]domain.PolicyRule, len(rows))
+	for i, row := range rows {
+		rules[i] = toDomainPolicyRule(row)
+	}
+	return rules, nil
+}
+
+func (s *PolicyService) DeleteRule(ctx context.Context, adminID, ruleID uuid.UUID) error {
+	deleted, err := s.queries.DeletePolicyRule(ctx, pgconv.UUIDToPg(ruleID))
+	if err != nil {
+		return fmt.Errorf("failed to delete policy rule: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("policy rule not found")
+	}
+
+	s.log.WithContext(ctx).WithUser(adminID.String(), "").Info("Deleted content policy rule", "rule_id", ruleID)
+	return nil
+}
+
+// ListViolations returns violations with IDs below beforeID, newest first.
+// A zero beforeID starts at the newest.
+func (s *PolicyService) ListViolations(ctx context.Context, beforeID int64, limit int) (*domain.PolicyViolationPage, error) {
+	if beforeID <= 0 {
+		beforeID = math.MaxInt64
+	}
+	if limit <= 0 || limit > MaxPolicyViolationPageSize {
+		limit = MaxPolicyViolationPageSize
+	}
+
+	rows, err := retryRead(ctx, "list_policy_violations", func() ([]db.PolicyViolation, error) {
+		return s.queries.ListPolicyViolations(ctx, db.ListPolicyViolationsParams{
+			BeforeID: beforeID,
+			PageSize: int32(limit),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list policy violations: %w", err)
+	}
+
+	page := &domain.PolicyViolationPage{Violations: make([]domain.PolicyViolation, len(rows))}
+	for i, row := range rows {
+		page.Violations[i] = domain.PolicyViolation{
+			ID:          row.ID,
+			RuleID:      pgconv.PgToUUIDPtr(row.RuleID),
+			RuleName:    row.RuleName,
+			WorkspaceID: pgconv.PgToUUIDPtr(row.WorkspaceID),
+			UserID:      pgconv.PgToUUIDPtr(row.UserID),
+			FilePath:    row.FilePath,
+			Action:      row.ActionType,
+			Blocked:     row.Blocked,
+			MatchCount:  row.MatchCount,
+			CreatedAt:   pgconv.PgToTime(row.CreatedAt),
+		}
+	}
+	if len(rows) == limit {
+		page.NextBefore = rows[len(rows)-1].ID
+	}
+	return page, nil
+}
+
+// Check evaluates every rule against content about to be published by
+// action, recording a violation for each match. It fails if any matching
+// rule blocks. Rules whose detector is no longer available are skipped.
+func (s *PolicyService) Check(ctx context.Context, action string, workspaceID, userID uuid.UUID, filePath string, content []byte) error {
+	rows, err := retryRead(ctx, "list_policy_rules", func() ([]db.PolicyRule, error) {
+		return s.queries.ListPolicyRules(ctx)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load content policy: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	rules := make([]policy.Rule, 0, len(rows))
+	for _, row := range rows {
+		rule := policy.Rule{
+			ID:       pgconv.PgToUUID(row.ID).String(),
+			Name:     row.Name,
+			Detector: row.Detector,
+			Pattern:  row.Pattern,
+			Action:   row.Action,
+		}
+		if err := policy.Validate(rule); err != nil {
+			s.log.Warn("Skipping content policy rule", "rule_id", rule.ID, "error", err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	engine, err := policy.Compile(rules)
+	if err != nil {
+		return fmt.Errorf("failed to compile content policy: %w", err)
+	}
+
+	matches := engine.Evaluate(content)
+	blocked := policy.Blocked(matches)
+	var blocking []string
+	for _, m := range matches {
+		err := s.queries.InsertPolicyViolation(ctx, db.InsertPolicyViolationParams{
+			RuleID:      pgconv.UUIDToPg(uuid.MustParse(m.Rule.ID)),
+			RuleName:    m.Rule.Name,
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			UserID:      pgconv.UUIDToPg(userID),
+			FilePath:    filePath,
+			ActionType:  action,
+			Blocked:     blocked,
+			MatchCount:  int32(m.Count),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record policy violation: %w", err)
+		}
+		if m.Rule.Action == policy.ActionBlock {
+			blocking = append(blocking, m.Rule.Name)
+		}
+
+		s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "").Warn("Content policy violation",
+			"rule", m.Rule.Name,
+			"action", action,
+			"file_path", filePath,
+			"matches", m.Count,
+			"blocked", blocked)
+	}
+
+	if blocked {
+		return fmt.Errorf("blocked by content policy: %s", strings.Join(blocking, ", "))
+	}
+	return nil
+}
+
+// policyBlocked reports whether err is Check refusing content, rather
+// than failing to check it.
+func policyBlocked(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "blocked by content policy")
+}
+
+func toDomainPolicyRule(row db.PolicyRule) domain.PolicyRule {
+	return domain.PolicyRule{
+		ID:        pgconv.PgToUUID(row.ID),
+		Name:      row.Name,
+		Detector:  row.Detector,
+		Pattern:   row.Pattern,
+		Action:    row.Action,
+		CreatedBy: pgconv.PgToUUIDPtr(row.CreatedBy),
+		CreatedAt: pgconv.PgToTime(row.CreatedAt),
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyService_Shares_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	policies := NewPolicyService(testDB.Queries())
+	shares := NewShareService(testDB.Queries(), storage.NewPostgresBackend(testDB.Queries()), policies)
+	files := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	for path, content := range map[string]string{
+		"billing.md":  "Card on file: 4111 1111 1111 1111",
+		"roadmap.md":  "Project Bluebird ships in March",
+		"shopping.md": "milk, eggs",
+	} {
+		_, err := files.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      []byte(content),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+
+	_, err := policies.CreateRule(ctx, testData.PremiumUserID, domain.CreatePolicyRuleRequest{
+		Name: "Card numbers", Detector: "credit_card", Action: "block",
+	})
+	require.NoError(t, err)
+	_, err = policies.CreateRule(ctx, testData.PremiumUserID, domain.CreatePolicyRuleRequest{
+		Name: "Codenames", Detector: "regex", Pattern: `(?i)bluebird`, Action: "audit",
+	})
+	require.NoError(t, err)
+
+	t.Run("invalid rules are rejected", func(t *testing.T) {
+		_, err := policies.CreateRule(ctx, testData.PremiumUserID, domain.CreatePolicyRuleRequest{
+			Name: "Broken", Detector: "regex", Pattern: "(", Action: "block",
+		})
+		assert.ErrorContains(t, err, "invalid pattern")
+	})
+
+	t.Run("blocking rules refuse the share", func(t *testing.T) {
+		_, err := shares.CreateShare(ctx, testData.FreeWorkspaceID, "billing.md", testData.FreeUserID, domain.CreateShareRequest{})
+		assert.ErrorContains(t, err, "blocked by content policy: Card numbers")
+	})
+
+	t.Run("audit rules only record", func(t *testing.T) {
+		_, err := shares.CreateShare(ctx, testData.FreeWorkspaceID, "roadmap.md", testData.FreeUserID, domain.CreateShareRequest{})
+		require.NoError(t, err)
+
+		_, err = shares.CreateShare(ctx, testData.FreeWorkspaceID, "shopping.md", testData.FreeUserID, domain.CreateShareRequest{})
+		require.NoError(t, err)
+	})
+
+	t.Run("violations are audited newest first", func(t *testing.T) {
+		page, err := policies.ListViolations(ctx, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, page.Violations, 2)
+		assert.Equal(t, "Codenames", page.Violations[0].RuleName)
+		assert.False(t, page.Violations[0].Blocked)
+		assert.Equal(t, "billing.md", page.Violations[1].FilePath)
+		assert.True(t, page.Violations[1].Blocked)
+		assert.Equal(t, domain.PolicyActionShare, page.Violations[1].Action)
+	})
+}
+
+func TestPolicyService_EditedContent_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	policies := NewPolicyService(testDB.Queries())
+	blobs := storage.NewPostgresBackend(testDB.Queries())
+	shares := NewShareService(testDB.Queries(), blobs, policies)
+	sites := NewPublishService(testDB.Queries(), blobs, policies)
+	files := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	write := func(path, content string) {
+		_, err := files.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      []byte(content),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+	write("notes/billing.md", "Invoices are due monthly.")
+	write("notes/home.md", "# Home")
+
+	_, err := policies.CreateRule(ctx, testData.PremiumUserID, domain.CreatePolicyRuleRequest{
+		Name: "Card numbers", Detector: "credit_card", Action: "block",
+	})
+	require.NoError(t, err)
+
+	link, err := shares.CreateShare(ctx, testData.FreeWorkspaceID, "notes/billing.md", testData.FreeUserID, domain.CreateShareRequest{})
+	require.NoError(t, err)
+	_, err = sites.Publish(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.TierPremium, domain.PublishRequest{Slug: "notes"})
+	require.NoError(t, err)
+
+	write("notes/billing.md", "Card on file: 4111 1111 1111 1111")
+
+	t.Run("shares stop serving blocked content", func(t *testing.T) {
+		_, err := shares.LookupShare(ctx, link.Token)
+		assert.ErrorContains(t, err, "share link not found")
+
+		write("notes/billing.md", "Invoices are due monthly, paid by card.")
+		file, err := shares.GetSharedFile(ctx, link.Token)
+		require.NoError(t, err)
+		assert.Contains(t, string(file.Content), "paid by card")
+
+		write("notes/billing.md", "Card on file: 4111 1111 1111 1111")
+	})
+
+	t.Run("sites leave blocked notes out", func(t *testing.T) {
+		public, err := sites.LookupSite(ctx, "notes")
+		require.NoError(t, err)
+		built, err := sites.LoadSite(ctx, public)
+		require.NoError(t, err)
+		assert.Contains(t, built.Pages, "notes/home.html")
+		assert.NotContains(t, built.Pages, "notes/billing.html")
+	})
+
+	t.Run("violations are recorded once per edit", func(t *testing.T) {
+		_, err := shares.LookupShare(ctx, link.Token)
+		assert.Error(t, err)
+
+		page, err := policies.ListViolations(ctx, 0, 0)
+		require.NoError(t, err)
+		var actions []string
+		for _, v := range page.Violations {
+			assert.Equal(t, "notes/billing.md", v.FilePath)
+			assert.True(t, v.Blocked)
+			actions = append(actions, v.Action)
+		}
+		assert.ElementsMatch(t, []string{domain.PolicyActionShare, domain.PolicyActionShare, domain.PolicyActionPublish}, actions)
+	})
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// GetPreferences returns the user's preferences, the defaults when the
+// user never set any.
+func (s *UserService) GetPreferences(ctx context.Context, userID uuid.UUID) (*domain.Preferences, error) {
+	return loadPreferences(ctx, s.queries, userID)
+}
+
+// UpdatePreferences applies the fields set in patch and returns the
+// result. The default workspace must be one the user may open.
+func (s *UserService) UpdatePreferences(ctx context.Context, userID uuid.UUID, patch domain.PreferencesPatch) (*domain.Preferences, error) {
+	if err := patch.Validate(); err != nil {
+		return nil, err
+	}
+
+	params := db.UpdateUserPreferencesParams{
+		UserID:        pgconv.UUIDToPg(userID),
+		Notifications: []byte("{}"),
+	}
+	if patch.Locale != nil {
+		params.Locale = pgtype.Text{String: *patch.Locale, Valid: true}
+	}
+	if patch.DefaultWorkspaceID != nil {
+		params.SetDefaultWorkspace = true
+		if *patch.DefaultWorkspaceID != "" {
+			workspaceID := uuid.MustParse(*patch.DefaultWorkspaceID)
+			if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+				return nil, err
+			}
+			params.DefaultWorkspaceID = pgconv.UUIDToPg(workspaceID)
+		}
+	}
+	if len(patch.Notifications) > 0 {
+		encoded, err := json.Marshal(patch.Notifications)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode notifications: %w", err)
+		}
+		params.Notifications = encoded
+	}
+	if patch.Editor != nil {
+		params.Editor = []byte("{}")
+		if editor := bytes.TrimSpace(patch.Editor); !bytes.Equal(editor, []byte("null")) {
+			params.Editor = editor
+		}
+	}
+
+	row, err := s.queries.UpdateUserPreferences(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update preferences: %w", err)
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Updated preferences")
+	return toDomainPreferences(row)
+}
+
+func loadPreferences(ctx context.Context, queries *db.Queries, userID uuid.UUID) (*domain.Preferences, error) {
+	row, err := queries.GetUserPreferences(ctx, pgconv.UUIDToPg(userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &domain.Preferences{
+			Notifications: map[string]bool{},
+			Editor:        json.RawMessage("{}"),
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	return toDomainPreferences(row)
+}
+
+func toDomainPreferences(row db.UserPreference) (*domain.Preferences, error) {
+	prefs := &domain.Preferences{
+		Locale:        row.Locale,
+		Notifications: map[string]bool{},
+		Editor:        json.RawMessage(row.Editor),
+	}
+	if row.DefaultWorkspaceID.Valid {
+		workspaceID := pgconv.PgToUUID(row.DefaultWorkspaceID)
+		prefs.DefaultWorkspaceID = &workspaceID
+	}
+	if err := json.Unmarshal(row.Notifications, &prefs.Notifications); err != nil {
+		return nil, fmt.Errorf("failed to decode notifications: %w", err)
+	}
+	if row.UpdatedAt.Valid {
+		updatedAt := pgconv.PgToTime(row.UpdatedAt)
+		prefs.UpdatedAt = &updatedAt
+	}
+	return prefs, nil
+}
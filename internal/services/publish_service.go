@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/publish"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// MaxPublishedNotes caps the notes considered for one site, which is
+// built in memory.
+const MaxPublishedNotes = 2000
+
+// maxCachedSites caps how many built sites are kept between requests.
+const maxCachedSites = 32
+
+// PublishService publishes workspaces as static sites. Sites are built
+// when first requested after a change and kept until the workspace or the
+// site's settings change again.
+type PublishService struct {
+	queries  *db.Queries
+	blobs    storage.Backend
+	policies *PolicyService
+	log      *logger.Logger
+
+	mu    sync.Mutex
+	sites map[uuid.UUID]*builtSite
+}
+
+// builtSite is a site as built for one revision of its workspace and
+// version of its settings. checked holds the content policy outcome for
+// each note's content, so rebuilds only check notes that changed.
+type builtSite struct {
+	revision  int64
+	updatedAt time.Time
+	site      *publish.Site
+	checked   map[string]policyCheck
+	lastUsed  time.Time
+}
+
+// policyCheck is the content policy outcome for one version of a note.
+type policyCheck struct {
+	contentHash string
+	blocked     bool
+}
+
+func NewPublishService(queries *db.Queries, blobs storage.Backend, policies *PolicyService) *PublishService {
+	return &PublishService{
+		queries:  queries,
+		blobs:    blobs,
+		policies: policies,
+		log:      logger.New(),
+		sites:    make(map[uuid.UUID]*builtSite),
+	}
+}
+
+func requirePublishingTier(tier domain.UserTier) error {
+	if tier != domain.TierPremium && tier != domain.TierEnterprise {
+		return fmt.Errorf("access denied: publishing requires the premium tier")
+	}
+	return nil
+}
+
+// Publish publishes the workspace at req.Slug, or changes the slug and
+// selection of its site. Only owners on the premium tier or above may
+// publish, and every selected note must pass the content policy.
+func (s *PublishService) Publish(ctx context.Context, workspaceID, userID uuid.UUID, tier domain.UserTier, req domain.PublishRequest) (*domain.PublishedSite, error) {
+	if err := req.Normalize(true); err != nil {
+		return nil, err
+	}
+	if err := requirePublishingTier(tier); err != nil {
+		return nil, err
+	}
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	if workspace.ArchivedAt.Valid {
+		return nil, fmt.Errorf("workspace is archived")
+	}
+	if err := requireVerifiedEmail(ctx, s.queries, userID); err != nil {
+		return nil, err
+	}
+
+	sel := publish.Selection{IncludeFolders: req.IncludeFolders, ExcludeFolders: req.ExcludeFolders, RequireFlag: req.RequireFlag}
+	notes, hashes, err := s.loadNotes(ctx, workspaceID, sel)
+	if err != nil {
+		return nil, err
+	}
+	checked := make(map[string]policyCheck, len(notes))
+	for _, note := range notes {
+		if err := s.policies.Check(ctx, domain.PolicyActionPublish, workspaceID, userID, note.Path, note.Content); err != nil {
+			return nil, fmt.Errorf("%w (in %s)", err, note.Path)
+		}
+		checked[note.Path] = policyCheck{contentHash: hashes[note.Path]}
+	}
+
+	row, err := s.queries.UpsertPublishedSite(ctx, db.UpsertPublishedSiteParams{
+		WorkspaceID:    pgconv.UUIDToPg(workspaceID),
+		Slug:           req.Slug,
+		IncludeFolders: req.IncludeFolders,
+		ExcludeFolders: req.ExcludeFolders,
+		RequireFlag:    req.RequireFlag,
+		PublishedBy:    pgconv.UUIDToPg(userID),
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("slug already taken")
+		}
+		return nil, fmt.Errorf("failed to publish workspace: %w", err)
+	}
+
+	// The revision was read before the notes, so a change made since only
+	// makes the next request rebuild.
+	site := publish.Build(workspace.Name, notes)
+	s.store(workspaceID, &builtSite{revision: workspace.Revision, updatedAt: pgconv.PgToTime(row.UpdatedAt), site: site, checked: checked})
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), workspace.Name).Info("Published workspace",
+		"slug", req.Slug,
+		"pages", len(notes))
+
+	published := toDomainPublishedSite(row)
+	published.PageCount = len(notes)
+	return published, nil
+}
+
+// GetSite returns the settings of the workspace's site.
+func (s *PublishService) GetSite(ctx context.Context, workspaceID, userID uuid.UUID) (*domain.PublishedSite, error) {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return nil, err
+	}
+	row, err := s.queries.GetPublishedSite(ctx, pgconv.UUIDToPg(workspaceID))
+	if err != nil {
+		return nil, fmt.Errorf("site not found: %w", err)
+	}
+	site := toDomainPublishedSite(row)
+
+	s.mu.Lock()
+	if built, ok := s.sites[workspaceID]; ok && built.updatedAt.Equal(site.UpdatedAt) {
+		site.PageCount = len(built.site.Pages)
+	}
+	s.mu.Unlock()
+	return site, nil
+}
+
+// Unpublish takes the workspace's site down.
+func (s *PublishService) Unpublish(ctx context.Context, workspaceID, userID uuid.UUID) error {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner); err != nil {
+		return err
+	}
+	n, err := s.queries.DeletePublishedSite(ctx, pgconv.UUIDToPg(workspaceID))
+	if err != nil {
+		return fmt.Errorf("failed to unpublish workspace: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("site not found")
+	}
+
+	s.mu.Lock()
+	delete(s.sites, workspaceID)
+	s.mu.Unlock()
+	return nil
+}
+
+// BuildBundle builds the site req selects without publishing it, for
+// exporting as a static bundle. It is a premium feature like Publish but
+// open to every member, as the notes could be exported anyway.
+func (s *PublishService) BuildBundle(ctx context.Context, workspaceID, userID uuid.UUID, tier domain.UserTier, req domain.PublishRequest) (*publish.Site, error) {
+	if err := req.Normalize(false); err != nil {
+		return nil, err
+	}
+	if err := requirePublishingTier(tier); err != nil {
+		return nil, err
+	}
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	sel := publish.Selection{IncludeFolders: req.IncludeFolders, ExcludeFolders: req.ExcludeFolders, RequireFlag: req.RequireFlag}
+	notes, _, err := s.loadNotes(ctx, workspaceID, sel)
+	if err != nil {
+		return nil, err
+	}
+	return publish.Build(workspace.Name, notes), nil
+}
+
+// LookupSite finds the published site at slug. It is cheap, so conditional
+// requests can be answered before the site is built.
+func (s *PublishService) LookupSite(ctx context.Context, slug string) (*domain.PublicSite, error) {
+	row, err := retryRead(ctx, "get_published_site", func() (db.GetPublishedSiteBySlugRow, error) {
+		return s.queries.GetPublishedSiteBySlug(ctx, slug)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("site not found: %w", err)
+	}
+	return &domain.PublicSite{
+		WorkspaceID:    pgconv.PgToUUID(row.WorkspaceID),
+		Title:          row.WorkspaceName,
+		IncludeFolders: row.IncludeFolders,
+		ExcludeFolders: row.ExcludeFolders,
+		RequireFlag:    row.RequireFlag,
+		Revision:       row.Revision,
+		UpdatedAt:      pgconv.PgToTime(row.UpdatedAt),
+		PublishedBy:    pgconv.PgToUUID(row.PublishedBy),
+	}, nil
+}
+
+// LoadSite returns the build of a site found by LookupSite, building it
+// if the workspace or its settings changed since the last build. Notes
+// edited since they were last checked go through the content policy
+// again, and those it blocks are left out of the site.
+func (s *PublishService) LoadSite(ctx context.Context, public *domain.PublicSite) (*publish.Site, error) {
+	s.mu.Lock()
+	built, ok := s.sites[public.WorkspaceID]
+	if ok && built.revision == public.Revision && built.updatedAt.Equal(public.UpdatedAt) {
+		built.lastUsed = time.Now()
+		s.mu.Unlock()
+		return built.site, nil
+	}
+	var previous map[string]policyCheck
+	if ok {
+		previous = built.checked
+	}
+	s.mu.Unlock()
+
+	sel := publish.Selection{IncludeFolders: public.IncludeFolders, ExcludeFolders: public.ExcludeFolders, RequireFlag: public.RequireFlag}
+	notes, hashes, err := s.loadNotes(ctx, public.WorkspaceID, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	checked := make(map[string]policyCheck, len(notes))
+	allowed := notes[:0]
+	for _, note := range notes {
+		check, ok := previous[note.Path]
+		if !ok || check.contentHash != hashes[note.Path] {
+			err := s.policies.Check(ctx, domain.PolicyActionPublish, public.WorkspaceID, public.PublishedBy, note.Path, note.Content)
+			check = policyCheck{contentHash: hashes[note.Path], blocked: policyBlocked(err)}
+			if err != nil && !check.blocked {
+				return nil, err
+			}
+			if check.blocked {
+				s.log.WithContext(ctx).WithWorkspace(public.WorkspaceID.String(), public.Title).Warn("Left note out of published site",
+					"file_path", note.Path,
+					"error", err)
+			}
+		}
+		checked[note.Path] = check
+		if !check.blocked {
+			allowed = append(allowed, note)
+		}
+	}
+
+	site := publish.Build(public.Title, allowed)
+	s.store(public.WorkspaceID, &builtSite{revision: public.Revision, updatedAt: public.UpdatedAt, site: site, checked: checked})
+	return site, nil
+}
+
+func (s *PublishService) store(workspaceID uuid.UUID, built *builtSite) {
+	built.lastUsed = time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sites[workspaceID]; !ok && len(s.sites) >= maxCachedSites {
+		var oldest uuid.UUID
+		for id, cached := range s.sites {
+			if oldest == uuid.Nil || cached.lastUsed.Before(s.sites[oldest].lastUsed) {
+				oldest = id
+			}
+		}
+		delete(s.sites, oldest)
+	}
+	s.sites[workspaceID] = built
+}
+
+// loadNotes reads the notes sel selects, and the hash of each note's
+// content by path. Content is only read for notes in the selected folders,
+// since flags can only be checked after.
+func (s *PublishService) loadNotes(ctx context.Context, workspaceID uuid.UUID, sel publish.Selection) ([]publish.Note, map[string]string, error) {
+	rows, err := retryRead(ctx, "list_files", func() ([]db.ListFilesRow, error) {
+		return s.queries.ListFiles(ctx, pgconv.UUIDToPg(workspaceID))
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	var notes []publish.Note
+	hashes := make(map[string]string)
+	considered := 0
+	for _, row := range rows {
+		if !publish.Publishable(row.FilePath) || !sel.InFolders(row.FilePath) {
+			continue
+		}
+		if considered++; considered > MaxPublishedNotes {
+			return nil, nil, fmt.Errorf("invalid selection: more than %d notes; narrow it with include_folders or exclude_folders", MaxPublishedNotes)
+		}
+		content, err := s.blobs.Get(ctx, row.ContentHash)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", row.FilePath, err)
+		}
+		note := publish.Note{Path: row.FilePath, Content: content, ModTime: pgconv.PgToTime(row.LastModified)}
+		if sel.Selected(note) {
+			notes = append(notes, note)
+			hashes[note.Path] = row.ContentHash
+		}
+	}
+	return notes, hashes, nil
+}
+
+func toDomainPublishedSite(row db.PublishedSite) *domain.PublishedSite {
+	site := &domain.PublishedSite{
+		WorkspaceID:    pgconv.PgToUUID(row.WorkspaceID),
+		Slug:           row.Slug,
+		IncludeFolders: row.IncludeFolders,
+		ExcludeFolders: row.ExcludeFolders,
+		RequireFlag:    row.RequireFlag,
+		CreatedAt:      pgconv.PgToTime(row.CreatedAt),
+		UpdatedAt:      pgconv.PgToTime(row.UpdatedAt),
+	}
+	if row.PublishedBy.Valid {
+		publishedBy := pgconv.PgToUUID(row.PublishedBy)
+		site.PublishedBy = &publishedBy
+	}
+	return site
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishService_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewPublishService(testDB.Queries(), storage.NewPostgresBackend(testDB.Queries()), NewPolicyService(testDB.Queries()))
+	fileService := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	for path, content := range map[string]string{
+		"notes/home.md":    "# Home\n\nSee [[ideas]] and [[drafts/secret]].",
+		"notes/ideas.md":   "---\ntitle: Ideas\n---\nBack to [[home]].",
+		"notes/hidden.md":  "---\npublish: false\n---\nNot on the site.",
+		"drafts/secret.md": "Private.",
+	} {
+		_, err := fileService.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      []byte(content),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+	req := domain.PublishRequest{Slug: "my-notes", ExcludeFolders: []string{"drafts"}}
+
+	t.Run("requires the premium tier", func(t *testing.T) {
+		_, err := service.Publish(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.TierFree, req)
+		assert.ErrorContains(t, err, "access denied")
+	})
+
+	t.Run("published site is served by slug", func(t *testing.T) {
+		site, err := service.Publish(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.TierPremium, req)
+		require.NoError(t, err)
+		assert.Equal(t, "my-notes", site.Slug)
+		assert.Equal(t, 2, site.PageCount)
+		assert.Equal(t, []string{"drafts/"}, site.ExcludeFolders)
+
+		public, err := service.LookupSite(ctx, "my-notes")
+		require.NoError(t, err)
+		built, err := service.LoadSite(ctx, public)
+		require.NoError(t, err)
+		require.Contains(t, built.Pages, "notes/home.html")
+		assert.NotContains(t, built.Pages, "notes/hidden.html")
+
+		home := built.Pages["notes/home.html"]
+		assert.Contains(t, home.Body, `href="notes/ideas.html"`)
+		assert.NotContains(t, home.Body, `href="drafts/`, "unpublished notes are not linked")
+		assert.Equal(t, []string{"notes/ideas.html"}, home.Backlinks)
+	})
+
+	t.Run("site follows the workspace", func(t *testing.T) {
+		before, err := service.LookupSite(ctx, "my-notes")
+		require.NoError(t, err)
+		_, err = fileService.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     "notes/new.md",
+			Content:      []byte("New."),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+
+		after, err := service.LookupSite(ctx, "my-notes")
+		require.NoError(t, err)
+		assert.Greater(t, after.Revision, before.Revision)
+		built, err := service.LoadSite(ctx, after)
+		require.NoError(t, err)
+		assert.Contains(t, built.Pages, "notes/new.html")
+	})
+
+	t.Run("bundle exports without publishing", func(t *testing.T) {
+		site, err := service.BuildBundle(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.TierPremium, domain.PublishRequest{IncludeFolders: []string{"drafts"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"index.html", "drafts/secret.html"}, site.Paths())
+
+		var zip bytes.Buffer
+		require.NoError(t, site.WriteZip(&zip))
+		assert.NotZero(t, zip.Len())
+	})
+
+	t.Run("unpublished site stops resolving", func(t *testing.T) {
+		err := service.Unpublish(ctx, testData.FreeWorkspaceID, testData.PremiumUserID)
+		assert.ErrorContains(t, err, "access denied")
+
+		require.NoError(t, service.Unpublish(ctx, testData.FreeWorkspaceID, testData.FreeUserID))
+		_, err = service.LookupSite(ctx, "my-notes")
+		assert.Error(t, err)
+		_, err = service.GetSite(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		assert.ErrorContains(t, err, "site not found")
+	})
+}
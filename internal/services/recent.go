@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+)
+
+// RecentFiles lists up to limit files of the workspace, most recent first:
+// by when anyone last changed them, or by when the user last opened them
+// as reported with RecordFileOpen. A limit of 0 means DefaultRecentFiles.
+func (s *FileService) RecentFiles(ctx context.Context, workspaceID, userID uuid.UUID, by string, limit int) ([]domain.RecentFile, error) {
+	if limit == 0 {
+		limit = domain.DefaultRecentFiles
+	} else if limit < 0 || limit > domain.MaxRecentFiles {
+		return nil, fmt.Errorf("invalid limit: use 1 to %d", domain.MaxRecentFiles)
+	}
+
+	switch by {
+	case domain.RecentModified, "":
+		page, err := s.ListFilesPage(ctx, workspaceID, userID, domain.FileListOptions{
+			Sort:  domain.SortByUpdatedAt,
+			Limit: limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		files := make([]domain.RecentFile, len(page.Files))
+		for i, file := range page.Files {
+			files[i] = domain.RecentFile{FileInfo: file}
+		}
+		return files, nil
+
+	case domain.RecentOpened:
+		if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+			return nil, err
+		}
+		rows, err := retryRead(ctx, "list_recent_file_opens", func() ([]db.ListRecentFileOpensRow, error) {
+			return s.queries.ListRecentFileOpens(db.PreferReplica(ctx), db.ListRecentFileOpensParams{
+				UserID:      pgconv.UUIDToPg(userID),
+				WorkspaceID: pgconv.UUIDToPg(workspaceID),
+				MaxFiles:    int32(limit),
+			})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list recent files: %w", err)
+		}
+		files := make([]domain.RecentFile, len(rows))
+		for i, row := range rows {
+			openedAt := pgconv.PgToTime(row.OpenedAt)
+			files[i] = domain.RecentFile{
+				FileInfo: domain.FileInfo{
+					ID:           pgconv.PgToUUID(row.ID),
+					WorkspaceID:  pgconv.PgToUUID(row.WorkspaceID),
+					FilePath:     row.FilePath,
+					ContentHash:  row.ContentHash,
+					SizeBytes:    row.SizeBytes,
+					MimeType:     pgconv.PgToString(row.MimeType),
+					LastModified: pgconv.PgToTime(row.LastModified),
+					UpdatedAt:    pgconv.PgToTime(row.UpdatedAt),
+				},
+				OpenedAt: &openedAt,
+			}
+		}
+		return files, nil
+
+	default:
+		return nil, fmt.Errorf("invalid by %q: use %s or %s", by, domain.RecentModified, domain.RecentOpened)
+	}
+}
+
+// RecordFileOpen notes that the user opened a file, for their recently
+// opened list. Only the user's last MaxRecentFiles opens per workspace are
+// kept. Opens follow a file across moves and go with it when it is
+// deleted.
+func (s *FileService) RecordFileOpen(ctx context.Context, workspaceID, userID uuid.UUID, req domain.FileOpenRequest) error {
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return err
+	}
+
+	file, err := s.queries.GetFile(ctx, db.GetFileParams{
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		FilePath:    req.FilePath,
+	})
+	if err != nil {
+		return fmt.Errorf("file not found: %w", err)
+	}
+
+	err = s.queries.RecordFileOpen(ctx, db.RecordFileOpenParams{
+		UserID:      pgconv.UUIDToPg(userID),
+		FileID:      file.ID,
+		WorkspaceID: file.WorkspaceID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record file open: %w", err)
+	}
+	err = s.queries.PruneFileOpens(ctx, db.PruneFileOpensParams{
+		UserID:      pgconv.UUIDToPg(userID),
+		WorkspaceID: file.WorkspaceID,
+		Keep:        domain.MaxRecentFiles,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prune file opens: %w", err)
+	}
+	return nil
+}
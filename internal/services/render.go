@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/markdown"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+)
+
+// RenderFile renders a note to sanitized HTML: Markdown and Org are
+// formatted, other text is shown preformatted and attachments are refused. Wiki-links, and Org links
+// to files, are resolved with one lookup. A target names a path relative
+// to the note's folder or, failing that, the workspace root, with or
+// without its .md or .org extension.
+func (s *FileService) RenderFile(ctx context.Context, workspaceID uuid.UUID, filePath string, userID uuid.UUID, opts domain.RenderOptions) (*domain.RenderedFile, error) {
+	if opts.LinkTemplate == "" {
+		opts.LinkTemplate = domain.DefaultRenderLinkTemplate
+	}
+	if err := validateLinkTemplate(opts.LinkTemplate); err != nil {
+		return nil, err
+	}
+
+	file, err := s.GetFileContent(ctx, workspaceID, filePath, userID)
+	if err != nil {
+		return nil, err
+	}
+	format := s.DetectFileFormat(file.FilePath, file.Content)
+	if format == domain.FormatBinary {
+		return nil, fmt.Errorf("unsupported media type: %s is an attachment", file.MimeType)
+	}
+	render := func(resolve func(string) (string, bool)) string {
+		opts := markdown.Options{WikiLink: resolve}
+		switch format {
+		case domain.FormatMarkdown:
+			return markdown.RenderWith(file.Content, opts)
+		case domain.FormatOrgMode:
+			return markdown.RenderOrg(file.Content, opts)
+		}
+		return "<pre>" + html.EscapeString(string(file.Content)) + "</pre>\n"
+	}
+
+	// The first pass only collects the targets to look up.
+	candidates := map[string][]string{}
+	var paths []string
+	render(func(target string) (string, bool) {
+		if _, ok := candidates[target]; !ok {
+			candidates[target] = markdown.WikiLinkCandidates(file.FilePath, target)
+			paths = append(paths, candidates[target]...)
+		}
+		return "", false
+	})
+
+	found := map[string]bool{}
+	if len(paths) > 0 {
+		rows, err := s.queries.LookupFiles(ctx, db.LookupFilesParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			FilePaths:   paths[:min(len(paths), MaxFileLookupPaths)],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve links: %w", err)
+		}
+		for _, row := range rows {
+			found[row.FilePath] = true
+		}
+	}
+
+	rendered := &domain.RenderedFile{FileInfo: file.FileInfo, Format: format}
+	unresolved := map[string]bool{}
+	rendered.HTML = render(func(target string) (string, bool) {
+		for _, candidate := range candidates[target] {
+			if found[candidate] {
+				return expandLinkTemplate(opts.LinkTemplate, workspaceID, candidate), true
+			}
+		}
+		if !unresolved[target] {
+			unresolved[target] = true
+			rendered.Unresolved = append(rendered.Unresolved, target)
+		}
+		return "", false
+	})
+	return rendered, nil
+}
+
+func validateLinkTemplate(template string) error {
+	if !strings.Contains(template, "{path}") {
+		return fmt.Errorf("invalid link_template: it must contain {path}")
+	}
+	lower := strings.ToLower(template)
+	if !strings.HasPrefix(lower, "/") && !strings.HasPrefix(lower, "https://") && !strings.HasPrefix(lower, "http://") {
+		return fmt.Errorf("invalid link_template: use an absolute path or an http(s) URL")
+	}
+	return nil
+}
+
+// expandLinkTemplate fills in a link template, escaping each segment of
+// filePath but keeping its slashes.
+func expandLinkTemplate(template string, workspaceID uuid.UUID, filePath string) string {
+	segments := strings.Split(filePath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.NewReplacer(
+		"{workspace_id}", workspaceID.String(),
+		"{path}", strings.Join(segments, "/"),
+	).Replace(template)
+}
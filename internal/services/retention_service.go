@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+)
+
+// SyncPruneBatchSize caps the rows one delete statement removes, so pruning
+// never holds locks on sync_operations for long.
+const SyncPruneBatchSize = 1000
+
+// VersionPruneBatchSize caps the file versions one delete statement
+// removes.
+const VersionPruneBatchSize = 1000
+
+type RetentionService struct {
+	queries *db.Queries
+	policy  domain.RetentionPolicy
+	log     *logger.Logger
+}
+
+func NewRetentionService(queries *db.Queries, policy domain.RetentionPolicy) *RetentionService {
+	return &RetentionService{
+		queries: queries,
+		policy:  policy,
+		log:     logger.New(),
+	}
+}
+
+// PruneSyncOperations deletes sync operations past their retention in
+// batches of batchSize until none are left. It returns the number deleted.
+func (s *RetentionService) PruneSyncOperations(ctx context.Context, batchSize int32) (int64, error) {
+	params := db.PruneSyncOperationsParams{
+		OperationTypes: make([]string, len(s.policy)),
+		Statuses:       make([]string, len(s.policy)),
+		MaxAgeSeconds:  make([]int64, len(s.policy)),
+		OlderThan:      pgconv.TimeToPg(time.Now().Add(-s.policy.Shortest())),
+		BatchSize:      batchSize,
+	}
+	for i, rule := range s.policy {
+		params.OperationTypes[i] = rule.OperationType
+		params.Statuses[i] = rule.Status
+		params.MaxAgeSeconds[i] = int64(rule.MaxAge / time.Second)
+	}
+
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		deleted, err := s.queries.PruneSyncOperations(ctx, params)
+		if err != nil {
+			return total, fmt.Errorf("failed to prune sync operations: %w", err)
+		}
+		total += deleted
+
+		if deleted < int64(batchSize) {
+			break
+		}
+	}
+
+	if total > 0 {
+		s.log.Info("Pruned sync operations", "deleted", total, "policy", s.policy.String())
+	}
+	return total, nil
+}
+
+// PruneFileVersions deletes file versions past the retention of their
+// workspace owner's tier, in batches of batchSize. It returns the number
+// deleted. The blobs they referenced are left to the unreferenced blob
+// collector.
+func (s *RetentionService) PruneFileVersions(ctx context.Context, batchSize int32) (int64, error) {
+	var total int64
+	for _, tier := range []domain.UserTier{domain.TierFree, domain.TierPremium, domain.TierEnterprise} {
+		retention := tier.GetVersionRetention()
+		if retention.Unlimited() {
+			continue
+		}
+
+		params := db.PruneFileVersionsParams{
+			Tier:        db.UserTier(tier),
+			MaxVersions: math.MaxInt32,
+			BatchSize:   batchSize,
+		}
+		if retention.MaxVersions >= 0 {
+			params.MaxVersions = int32(retention.MaxVersions)
+		}
+		if retention.MaxAgeDays >= 0 {
+			params.CreatedBefore = pgconv.TimeToPg(time.Now().AddDate(0, 0, -retention.MaxAgeDays))
+		}
+
+		for {
+			if err := ctx.Err(); err != nil {
+				return total, err
+			}
+
+			deleted, err := s.queries.PruneFileVersions(ctx, params)
+			if err != nil {
+				return total, fmt.Errorf("failed to prune %s file versions: %w", tier, err)
+			}
+			total += deleted
+
+			if deleted < int64(batchSize) {
+				break
+			}
+		}
+	}
+
+	if total > 0 {
+		s.log.Info("Pruned file versions", "deleted", total)
+	}
+	return total, nil
+}
+
+// TableGrowth reports table sizes and the sync operations currently
+// retained, for operators tuning the retention policy.
+func (s *RetentionService) TableGrowth(ctx context.Context) (*domain.TableGrowthReport, error) {
+	tables, err := s.queries.ListTableGrowth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table statistics: %w", err)
+	}
+
+	summary, err := s.queries.SummarizeSyncOperations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize sync operations: %w", err)
+	}
+
+	report := &domain.TableGrowthReport{
+		Tables:         make([]domain.TableGrowth, len(tables)),
+		SyncOperations: make([]domain.SyncOperationSummary, len(summary)),
+		SyncRetention:  s.policy.String(),
+	}
+	for i, row := range tables {
+		report.Tables[i] = domain.TableGrowth{
+			TableName:      row.TableName,
+			LiveRows:       row.LiveRows,
+			DeadRows:       row.DeadRows,
+			TotalBytes:     row.TotalBytes,
+			LastAutovacuum: pgconv.PgToTimePtr(row.LastAutovacuum),
+		}
+	}
+	for i, row := range summary {
+		report.SyncOperations[i] = domain.SyncOperationSummary{
+			OperationType: row.OperationType,
+			Status:        row.Status,
+			Count:         row.OperationCount,
+			Oldest:        pgconv.PgToTime(row.Oldest),
+		}
+	}
+
+	return report, nil
+}
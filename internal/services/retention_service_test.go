@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetentionService_PruneSyncOperations_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+	ctx := context.Background()
+
+	policy, err := domain.ParseRetentionPolicy("*/success=7d,upload/success=90d,*/*=30d")
+	require.NoError(t, err)
+	service := NewRetentionService(testDB.Queries(), policy)
+
+	insert := func(opType, status, age string, n int) {
+		for i := 0; i < n; i++ {
+			_, err := testDB.Conn().Exec(ctx,
+				"INSERT INTO sync_operations (workspace_id, operation_type, status, created_at) VALUES ($1, $2, $3, NOW() - $4::interval)",
+				testData.FreeWorkspaceID, opType, status, age)
+			require.NoError(t, err)
+		}
+	}
+
+	insert("download", "success", "10 days", 3) // past */success
+	insert("download", "success", "1 day", 1)   // kept
+	insert("upload", "success", "10 days", 2)   // kept by the more specific upload/success
+	insert("upload", "success", "100 days", 1)  // past upload/success
+	insert("upload", "failed", "10 days", 1)    // kept by */*
+	insert("delete", "failed", "40 days", 2)    // past */*
+
+	deleted, err := service.PruneSyncOperations(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), deleted)
+
+	report, err := service.TableGrowth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, policy.String(), report.SyncRetention)
+
+	counts := make(map[string]int64)
+	for _, s := range report.SyncOperations {
+		counts[s.OperationType+"/"+s.Status] = s.Count
+	}
+	assert.Equal(t, map[string]int64{
+		"download/success": 1,
+		"upload/success":   2,
+		"upload/failed":    1,
+	}, counts)
+}
+
+func TestRetentionService_PruneFileVersions_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+	ctx := context.Background()
+
+	fileService := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	var fileID uuid.UUID
+	for i := 1; i <= 7; i++ {
+		result, err := fileService.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     "notes/history.md",
+			Content:      []byte(fmt.Sprintf("revision %d", i)),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+		fileID = result.ID
+	}
+
+	// The free tier keeps five versions, none older than a week, but
+	// always the newest.
+	_, err := testDB.Conn().Exec(ctx,
+		"UPDATE file_versions SET created_at = NOW() - interval '10 days' WHERE file_id = $1 AND version_number IN (5, 7)",
+		fileID)
+	require.NoError(t, err)
+
+	service := NewRetentionService(testDB.Queries(), nil)
+	deleted, err := service.PruneFileVersions(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+
+	versions, err := testDB.Queries().GetFileVersions(ctx, db.GetFileVersionsParams{FileID: pgconv.UUIDToPg(fileID), Limit: 10})
+	require.NoError(t, err)
+	kept := make([]int32, len(versions))
+	for i, v := range versions {
+		kept[i] = v.VersionNumber
+	}
+	assert.Equal(t, []int32{7, 6, 4, 3}, kept)
+}
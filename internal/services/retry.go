@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/metrics"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// RetryPolicy bounds how often and how long a database operation is
+// retried. Delays grow exponentially from BaseDelay up to MaxDelay, with
+// full jitter so concurrent retries spread out.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   25 * time.Millisecond,
+	MaxDelay:    time.Second,
+}
+
+// DBRetries counts retries per operation. "<op>.exhausted" counts
+// operations that still failed after the last attempt.
+var DBRetries = metrics.NewCounters()
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return time.Duration(rand.Int64N(int64(d))) + 1
+}
+
+func retry[T any](ctx context.Context, policy RetryPolicy, op string, retryable func(error) bool, fn func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		v, err := fn()
+		if err == nil || !retryable(err) {
+			return v, err
+		}
+		if attempt >= policy.MaxAttempts {
+			DBRetries.Add(op+".exhausted", 1)
+			return v, err
+		}
+
+		DBRetries.Add(op, 1)
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return v, err
+		}
+	}
+}
+
+// retryRead retries an idempotent read on any transient error.
+func retryRead[T any](ctx context.Context, op string, fn func() (T, error)) (T, error) {
+	return retry(ctx, DefaultRetryPolicy, op, isTransient, fn)
+}
+
+// inTx runs fn in a transaction and commits it. The whole transaction is
+// retried only when Postgres rolled it back (serialization failure,
+// deadlock) or nothing reached the server, so a write is never applied
+// twice. fn must not have side effects outside the transaction that are
+// unsafe to repeat.
+func inTx(ctx context.Context, conn *pgx.Conn, queries *db.Queries, op string, fn func(qtx *db.Queries) error) error {
+	_, err := retry(ctx, DefaultRetryPolicy, op, isRolledBack, func() (struct{}, error) {
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return struct{}{}, fmt.Errorf("failed to start transaction: %w", err)
+		}
+		defer tx.Rollback(ctx)
+
+		if err := fn(queries.WithTx(tx)); err != nil {
+			return struct{}{}, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return struct{}{}, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return struct{}{}, nil
+	})
+	return err
+}
+
+// isRolledBack reports errors after which the transaction is known not to
+// have committed.
+func isRolledBack(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+	return pgconn.SafeToRetry(err)
+}
+
+// isTransient reports errors a read may succeed after: rolled back
+// transactions, server restarts, connection limits and network failures.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if isRolledBack(err) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "57P01", "57P02", "57P03", "53300":
+			return true
+		}
+		// Class 08: connection exceptions.
+		return strings.HasPrefix(pgErr.Code, "08")
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+var testRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	serialization := &pgconn.PgError{Code: "40001"}
+
+	t.Run("retries transient errors until success", func(t *testing.T) {
+		calls := 0
+		v, err := retry(ctx, testRetryPolicy, "test_success", isTransient, func() (int, error) {
+			calls++
+			if calls < 3 {
+				return 0, fmt.Errorf("failed to commit transaction: %w", serialization)
+			}
+			return 42, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 42, v)
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, int64(2), DBRetries.Snapshot()["test_success"])
+	})
+
+	t.Run("does not retry permanent errors", func(t *testing.T) {
+		calls := 0
+		_, err := retry(ctx, testRetryPolicy, "test_permanent", isTransient, func() (int, error) {
+			calls++
+			return 0, pgx.ErrNoRows
+		})
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+		assert.Equal(t, 1, calls)
+		assert.NotContains(t, DBRetries.Snapshot(), "test_permanent")
+	})
+
+	t.Run("gives up after MaxAttempts", func(t *testing.T) {
+		calls := 0
+		_, err := retry(ctx, testRetryPolicy, "test_exhausted", isTransient, func() (int, error) {
+			calls++
+			return 0, serialization
+		})
+		assert.ErrorIs(t, err, serialization)
+		assert.Equal(t, 3, calls)
+		snapshot := DBRetries.Snapshot()
+		assert.Equal(t, int64(2), snapshot["test_exhausted"])
+		assert.Equal(t, int64(1), snapshot["test_exhausted.exhausted"])
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		calls := 0
+		policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+		_, err := retry(ctx, policy, "test_cancelled", isTransient, func() (int, error) {
+			calls++
+			return 0, serialization
+		})
+		assert.ErrorIs(t, err, serialization)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		transient  bool
+		rolledBack bool
+	}{
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true, true},
+		{"deadlock", &pgconn.PgError{Code: "40P01"}, true, true},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, true, false},
+		{"too many connections", &pgconn.PgError{Code: "53300"}, true, false},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, true, false},
+		{"unexpected EOF", io.ErrUnexpectedEOF, true, false},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false, false},
+		{"no rows", pgx.ErrNoRows, false, false},
+		{"cancelled", context.Canceled, false, false},
+		{"other", errors.New("boom"), false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.transient, isTransient(tt.err))
+			assert.Equal(t, tt.rolledBack, isRolledBack(tt.err))
+		})
+	}
+}
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ApplySaveSet applies several writes, moves and deletes in one
+// transaction, so editors can rename a note and rewrite its backlinks
+// without other clients seeing half of it. Every operation is checked
+// against its base hash before anything is written, and all conflicts are
+// reported together in a *domain.SaveSetConflictError. The changes share
+// one new workspace revision. With RewriteLinks, links in other notes to
+// the files it moves are rewritten in the same transaction.
+func (s *FileService) ApplySaveSet(ctx context.Context, workspaceID, userID uuid.UUID, req domain.SaveSetRequest) (*domain.SaveSetResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	log := s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "")
+
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	if workspace.ArchivedAt.Valid {
+		return nil, fmt.Errorf("workspace is archived")
+	}
+
+	// Paths are sorted, so overlapping save-sets lock in the same order
+	// and cannot deadlock.
+	paths := req.Paths()
+	for _, path := range paths {
+		unlock := s.paths.Lock(workspaceID.String() + "/" + path)
+		defer unlock()
+	}
+
+	var result *domain.SaveSetResult
+	var written []writtenFile
+	err = inTx(ctx, s.conn, s.queries, "apply_save_set", func(qtx *db.Queries) error {
+		result = &domain.SaveSetResult{Files: make([]domain.SaveSetFileResult, len(req.Operations))}
+		written = nil
+
+		current := make(map[string]*db.File, len(paths))
+		for _, path := range paths {
+			err := qtx.LockFilePath(ctx, db.LockFilePathParams{
+				WorkspaceID: pgconv.UUIDToPg(workspaceID),
+				FilePath:    path,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to lock file: %w", err)
+			}
+			file, err := qtx.GetFile(ctx, db.GetFileParams{
+				WorkspaceID: pgconv.UUIDToPg(workspaceID),
+				FilePath:    path,
+			})
+			switch {
+			case err == nil:
+				current[path] = &file
+			case !errors.Is(err, pgx.ErrNoRows):
+				return fmt.Errorf("failed to get file: %w", err)
+			}
+		}
+
+		var conflicts []domain.SaveSetConflict
+		var fileCountDelta, sizeDelta int64
+		for i, op := range req.Operations {
+			if conflict := saveSetConflict(i, op, current); conflict != nil {
+				conflicts = append(conflicts, *conflict)
+				continue
+			}
+			existing := current[op.FilePath]
+			switch op.Op {
+			case domain.SaveOpWrite:
+				sizeDelta += int64(len(op.Content))
+				if existing == nil {
+					fileCountDelta++
+				} else {
+					sizeDelta -= existing.SizeBytes
+				}
+			case domain.SaveOpDelete:
+				sizeDelta -= existing.SizeBytes
+				fileCountDelta--
+			}
+		}
+		if len(conflicts) > 0 {
+			return &domain.SaveSetConflictError{Conflicts: conflicts}
+		}
+
+		// Links are found before the moves, while they still resolve.
+		var moved *movedLinks
+		if req.RewriteLinks {
+			var err error
+			if moved, err = findMovedLinks(ctx, qtx, workspaceID, req); err != nil {
+				return err
+			}
+		}
+
+		checkStorage := func(sizeDelta int64) error {
+			storageInfo, err := qtx.GetWorkspaceStorageUsage(ctx, pgconv.UUIDToPg(workspaceID))
+			if err != nil {
+				return fmt.Errorf("failed to get storage usage: %w", err)
+			}
+			newStorageUsage := storageInfo.StorageUsedBytes + sizeDelta
+			if newStorageUsage > storageInfo.StorageLimitBytes {
+				return fmt.Errorf("storage limit exceeded: need %d bytes, limit %d bytes",
+					newStorageUsage, storageInfo.StorageLimitBytes)
+			}
+			return nil
+		}
+		if err := checkSaveSetFileLimits(ctx, qtx, workspaceID, req, fileCountDelta); err != nil {
+			return err
+		}
+		if sizeDelta > 0 {
+			if err := checkStorage(sizeDelta); err != nil {
+				return err
+			}
+		}
+
+		changed := false
+		for i, op := range req.Operations {
+			existing := current[op.FilePath]
+			fileResult := &result.Files[i]
+			*fileResult = domain.SaveSetFileResult{Op: op.Op, FilePath: op.FilePath}
+
+			switch op.Op {
+			case domain.SaveOpWrite:
+				file, err := s.saveSetWrite(ctx, qtx, workspaceID, userID, op, existing, fileResult)
+				if err != nil {
+					return err
+				}
+				if !fileResult.Unchanged {
+					written = append(written, writtenFile{file, op.Content})
+					changed = true
+				}
+			case domain.SaveOpMove:
+				file, err := s.saveSetMove(ctx, qtx, workspaceID, userID, op)
+				if err != nil {
+					return err
+				}
+				fileResult.FilePath = file.FilePath
+				fileResult.ContentHash = file.ContentHash
+				changed = true
+			case domain.SaveOpDelete:
+				err := qtx.DeleteFile(ctx, db.DeleteFileParams{
+					WorkspaceID: pgconv.UUIDToPg(workspaceID),
+					FilePath:    op.FilePath,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to delete file: %w", err)
+				}
+				err = recordEvent(ctx, qtx, workspaceID, domain.EventFileDeleted, op.FilePath, &userID, map[string]any{
+					"size_bytes": existing.SizeBytes,
+				})
+				if err != nil {
+					return err
+				}
+				changed = true
+			}
+		}
+
+		if moved != nil {
+			rewritten, files, delta, err := s.rewriteMovedLinks(ctx, qtx, workspaceID, userID, moved)
+			if err != nil {
+				return err
+			}
+			if len(rewritten) > 0 {
+				result.Rewritten = rewritten
+				written = append(written, files...)
+				changed = true
+				sizeDelta += delta
+				if delta > 0 {
+					if err := checkStorage(sizeDelta); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if !changed {
+			latest, err := qtx.GetWorkspaceByID(ctx, pgconv.UUIDToPg(workspaceID))
+			if err != nil {
+				return fmt.Errorf("workspace not found: %w", err)
+			}
+			result.Revision = latest.Revision
+			return nil
+		}
+		var err error
+		result.Revision, err = qtx.AdjustWorkspaceCounters(ctx, db.AdjustWorkspaceCountersParams{
+			ID:               pgconv.UUIDToPg(workspaceID),
+			FileCount:        fileCountDelta,
+			StorageUsedBytes: pgconv.Int64ToPg(sizeDelta),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update storage usage: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		var conflict *domain.SaveSetConflictError
+		if errors.As(err, &conflict) {
+			log.Info("Save-set refused", "conflicts", len(conflict.Conflicts))
+		} else {
+			log.WithError(err).Error("Save-set failed")
+		}
+		return nil, err
+	}
+
+	for _, w := range written {
+		s.parseFileMetadata(ctx, w.file, w.content)
+	}
+
+	log.Info("Applied save-set", "operations", len(req.Operations), "revision", result.Revision)
+	return result, nil
+}
+
+// writtenFile is a file a save-set wrote, to have its metadata parsed once
+// committed.
+type writtenFile struct {
+	file    db.File
+	content []byte
+}
+
+// saveSetConflict checks one operation against the files the save-set
+// found, and returns nil if it may proceed.
+func saveSetConflict(index int, op domain.SaveSetOperation, current map[string]*db.File) *domain.SaveSetConflict {
+	conflict := &domain.SaveSetConflict{Index: index, FilePath: op.FilePath}
+	existing := current[op.FilePath]
+	if existing != nil {
+		conflict.CurrentHash = existing.ContentHash
+	}
+
+	switch {
+	case op.Op != domain.SaveOpWrite && existing == nil:
+		conflict.Reason = "file not found"
+	case op.BaseHash != nil && *op.BaseHash != conflict.CurrentHash:
+		conflict.Reason = "file changed"
+	case op.Op == domain.SaveOpMove && current[op.NewPath] != nil:
+		conflict.FilePath = op.NewPath
+		conflict.CurrentHash = current[op.NewPath].ContentHash
+		conflict.Reason = "target exists"
+	default:
+		return nil
+	}
+	return conflict
+}
+
+// saveSetWrite stores one write of a save-set the way UploadFile does,
+// minus the counters, which the save-set adjusts once for all operations.
+// checkSaveSetFileLimits checks the written files against the file
+// limits of the workspace's tier, and the files the save set adds to the
+// workspace, fileCountDelta, when it adds any.
+func checkSaveSetFileLimits(ctx context.Context, qtx *db.Queries, workspaceID uuid.UUID, req domain.SaveSetRequest, fileCountDelta int64) error {
+	storageInfo, err := qtx.GetWorkspaceStorageUsage(ctx, pgconv.UUIDToPg(workspaceID))
+	if err != nil {
+		return fmt.Errorf("failed to get storage usage: %w", err)
+	}
+	limits := domain.UserTier(storageInfo.Tier).GetFileLimits()
+	for _, op := range req.Operations {
+		if op.Op != domain.SaveOpWrite {
+			continue
+		}
+		if err := limits.Check(int64(len(op.Content)), 0, false); err != nil {
+			return fmt.Errorf("%w (%s)", err, op.FilePath)
+		}
+	}
+	if fileCountDelta > 0 {
+		// Check counts the one file being added; the others are added to
+		// the count already.
+		if err := limits.Check(0, storageInfo.FileCount+fileCountDelta-1, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileService) saveSetWrite(ctx context.Context, qtx *db.Queries, workspaceID, userID uuid.UUID, op domain.SaveSetOperation, existing *db.File, result *domain.SaveSetFileResult) (db.File, error) {
+	contentHash := storage.Hash(op.Content)
+	result.ContentHash = contentHash
+	if existing != nil && existing.ContentHash == contentHash {
+		result.Unchanged = true
+		return *existing, nil
+	}
+	result.Created = existing == nil
+
+	noteID, err := s.resolveNoteID(op.NoteID, op.FilePath, op.Content)
+	if err != nil {
+		return db.File{}, err
+	}
+
+	lastModified := op.LastModified
+	if lastModified.IsZero() {
+		lastModified = time.Now()
+	}
+	file, err := qtx.UpsertFile(ctx, db.UpsertFileParams{
+		WorkspaceID:  pgconv.UUIDToPg(workspaceID),
+		FilePath:     op.FilePath,
+		ContentHash:  contentHash,
+		SizeBytes:    int64(len(op.Content)),
+		MimeType:     pgconv.StringToPg(s.detectMimeType(op.FilePath, op.Content)),
+		LastModified: pgconv.TimeToPg(lastModified),
+	})
+	if err != nil {
+		return db.File{}, fmt.Errorf("failed to upsert file: %w", err)
+	}
+
+	result.VersionNumber, err = qtx.CreateFileVersion(ctx, db.CreateFileVersionParams{
+		FileID:      file.ID,
+		ContentHash: contentHash,
+	})
+	if err != nil {
+		return db.File{}, fmt.Errorf("failed to create file version: %w", err)
+	}
+
+	eventType := domain.EventFileUpdated
+	if result.Created {
+		eventType = domain.EventFileCreated
+	}
+	err = recordEvent(ctx, qtx, workspaceID, eventType, op.FilePath, &userID, map[string]any{
+		"version":      result.VersionNumber,
+		"content_hash": contentHash,
+		"size_bytes":   file.SizeBytes,
+	})
+	if err != nil {
+		return db.File{}, err
+	}
+	format := s.DetectFileFormat(op.FilePath, op.Content)
+	if err := syncFileTasks(ctx, qtx, file, format, op.Content, userID); err != nil {
+		return db.File{}, err
+	}
+	if err := syncFileAgenda(ctx, qtx, file, format, op.Content); err != nil {
+		return db.File{}, err
+	}
+	if err := syncFileLinks(ctx, qtx, file, format, op.Content); err != nil {
+		return db.File{}, err
+	}
+	if err := indexFileContent(ctx, qtx, file, op.Content); err != nil {
+		return db.File{}, err
+	}
+	if err := syncNoteID(ctx, qtx, file, noteID); err != nil {
+		return db.File{}, err
+	}
+	if err := s.blobs.Put(ctx, contentHash, op.Content); err != nil {
+		return db.File{}, fmt.Errorf("failed to store file content: %w", err)
+	}
+	return file, nil
+}
+
+// saveSetMove renames a file. It keeps its ID, versions, tasks and share
+// links; only the search index, which weighs the path, and the links,
+// which are resolved from it, are rebuilt.
+func (s *FileService) saveSetMove(ctx context.Context, qtx *db.Queries, workspaceID, userID uuid.UUID, op domain.SaveSetOperation) (db.File, error) {
+	file, err := qtx.MoveFile(ctx, db.MoveFileParams{
+		NewPath:     op.NewPath,
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		FilePath:    op.FilePath,
+	})
+	if err != nil {
+		return db.File{}, fmt.Errorf("failed to move file: %w", err)
+	}
+
+	content, err := s.blobs.Get(ctx, file.ContentHash)
+	if err != nil {
+		return db.File{}, fmt.Errorf("failed to load file content: %w", err)
+	}
+	if err := indexFileContent(ctx, qtx, file, content); err != nil {
+		return db.File{}, err
+	}
+	if err := syncFileLinks(ctx, qtx, file, s.DetectFileFormat(file.FilePath, content), content); err != nil {
+		return db.File{}, err
+	}
+
+	return file, recordEvent(ctx, qtx, workspaceID, domain.EventFileMoved, file.FilePath, &userID, map[string]any{
+		"from":         op.FilePath,
+		"content_hash": file.ContentHash,
+	})
+}
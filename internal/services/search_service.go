@@ -0,0 +1,310 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	DefaultSearchPageSize = 20
+	MaxSearchPageSize     = 100
+	// MaxIndexedContentBytes is how much of a file the search index
+	// reads. Postgres caps a tsvector at 1 MB.
+	MaxIndexedContentBytes = 256 << 10
+	// SearchBackfillBatchSize is how many files one backfill run indexes.
+	SearchBackfillBatchSize = 500
+	// MaxSearchIndexAttempts is how many times the backfill tries a file
+	// before giving up on its current content.
+	MaxSearchIndexAttempts = 5
+)
+
+type SearchService struct {
+	queries *db.Queries
+	blobs   storage.Backend
+	log     *logger.Logger
+}
+
+func NewSearchService(queries *db.Queries, blobs storage.Backend) *SearchService {
+	return &SearchService{
+		queries: queries,
+		blobs:   blobs,
+		log:     logger.New(),
+	}
+}
+
+// Search returns one page of the files matching req.Query. The first page
+// ranks every match, up to domain.MaxSearchResults, into a snapshot that
+// later pages read from; see domain.SearchPage.
+func (s *SearchService) Search(ctx context.Context, workspaceID, userID uuid.UUID, req domain.SearchRequest) (*domain.SearchPage, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultSearchPageSize
+	}
+	limit = min(limit, MaxSearchPageSize)
+
+	var page domain.SearchPage
+	var snapshot db.SearchSnapshot
+	offset, found := 0, false
+	if req.Cursor != "" {
+		var snapshotID uuid.UUID
+		snapshotID, offset, err = decodeSearchCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		snapshot, err = retryRead(ctx, "get_search_snapshot", func() (db.SearchSnapshot, error) {
+			return s.queries.GetSearchSnapshot(ctx, db.GetSearchSnapshotParams{
+				ID:          pgconv.UUIDToPg(snapshotID),
+				WorkspaceID: pgconv.UUIDToPg(workspaceID),
+				UserID:      pgconv.UUIDToPg(userID),
+			})
+		})
+		switch {
+		case err == nil:
+			if snapshot.Query != req.Query {
+				return nil, fmt.Errorf("invalid cursor: issued for a different query")
+			}
+			found = true
+		case errors.Is(err, pgx.ErrNoRows):
+			// Expired: rank again and carry on from the same position.
+			page.Requeried = true
+		default:
+			return nil, fmt.Errorf("failed to load search snapshot: %w", err)
+		}
+	}
+
+	if !found {
+		snapshot, err = s.createSnapshot(ctx, workspace, userID, req.Query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	total := len(snapshot.FileIds)
+	start, end := min(offset, total), min(offset+limit, total)
+	files, err := retryRead(ctx, "get_files_by_ids", func() ([]db.File, error) {
+		return s.queries.GetFilesByIDs(db.PreferReplica(ctx), snapshot.FileIds[start:end])
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load search results: %w", err)
+	}
+
+	page.Files = make([]domain.FileInfo, len(files))
+	for i, file := range files {
+		page.Files[i] = domain.FileInfo{
+			ID:           pgconv.PgToUUID(file.ID),
+			WorkspaceID:  pgconv.PgToUUID(file.WorkspaceID),
+			FilePath:     file.FilePath,
+			ContentHash:  file.ContentHash,
+			SizeBytes:    file.SizeBytes,
+			MimeType:     pgconv.PgToString(file.MimeType),
+			LastModified: pgconv.PgToTime(file.LastModified),
+			UpdatedAt:    pgconv.PgToTime(file.UpdatedAt),
+		}
+	}
+	status, err := s.indexStatus(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if !status.Ready {
+		page.Partial = true
+		page.IndexProgress = status.Progress
+	}
+
+	page.Total = total
+	page.Revision = snapshot.Revision
+	page.Stale = workspace.Revision > snapshot.Revision
+	page.ExpiresAt = pgconv.PgToTime(snapshot.ExpiresAt)
+	if end < total {
+		page.NextCursor = encodeListCursor(pgconv.PgToUUID(snapshot.ID).String(), strconv.Itoa(end))
+	}
+	return &page, nil
+}
+
+func (s *SearchService) createSnapshot(ctx context.Context, workspace db.Workspace, userID uuid.UUID, query string) (db.SearchSnapshot, error) {
+	ids, err := retryRead(ctx, "search_files", func() ([]pgtype.UUID, error) {
+		return s.queries.SearchFiles(db.PreferReplica(ctx), db.SearchFilesParams{
+			WorkspaceID: workspace.ID,
+			Query:       query,
+			MaxResults:  domain.MaxSearchResults,
+		})
+	})
+	if err != nil {
+		return db.SearchSnapshot{}, fmt.Errorf("failed to search: %w", err)
+	}
+	if ids == nil {
+		ids = []pgtype.UUID{}
+	}
+
+	snapshot, err := s.queries.CreateSearchSnapshot(ctx, db.CreateSearchSnapshotParams{
+		WorkspaceID: workspace.ID,
+		UserID:      pgconv.UUIDToPg(userID),
+		Query:       query,
+		Revision:    workspace.Revision,
+		FileIds:     ids,
+		ExpiresAt:   pgconv.TimeToPg(time.Now().Add(domain.SearchSnapshotTTL)),
+	})
+	if err != nil {
+		return db.SearchSnapshot{}, fmt.Errorf("failed to store search snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// IndexStatus reports how far the search index of a workspace has been
+// built.
+func (s *SearchService) IndexStatus(ctx context.Context, workspaceID, userID uuid.UUID) (*domain.IndexStatus, error) {
+	_, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := s.indexStatus(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (s *SearchService) indexStatus(ctx context.Context, workspaceID uuid.UUID) (domain.IndexStatus, error) {
+	row, err := retryRead(ctx, "get_search_index_status", func() (db.GetSearchIndexStatusRow, error) {
+		return s.queries.GetSearchIndexStatus(ctx, db.GetSearchIndexStatusParams{
+			MaxAttempts: MaxSearchIndexAttempts,
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		})
+	})
+	if err != nil {
+		return domain.IndexStatus{}, fmt.Errorf("failed to get index status: %w", err)
+	}
+	return domain.NewIndexStatus(workspaceID, row.TotalFiles, row.IndexedFiles, row.FailedFiles), nil
+}
+
+// BackfillIndex indexes up to batchSize files that have no index entry or
+// an outdated one, most recently modified first, so the notes people are
+// working on become searchable soonest. Files whose content cannot be read
+// are recorded as failed and retried after the files not yet tried, up to
+// MaxSearchIndexAttempts times, so they cannot hold up the rest. It
+// returns the number of files indexed.
+//
+// An upload racing the backfill can leave an entry built from the older
+// content; its hash no longer matches the file's, so the next run fixes it.
+func (s *SearchService) BackfillIndex(ctx context.Context, batchSize int) (int, error) {
+	files, err := s.queries.ListUnindexedFiles(ctx, db.ListUnindexedFilesParams{
+		MaxAttempts: MaxSearchIndexAttempts,
+		BatchSize:   int32(batchSize),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list unindexed files: %w", err)
+	}
+
+	indexed := 0
+	for _, file := range files {
+		content, err := s.blobs.Get(ctx, file.ContentHash)
+		if err != nil {
+			s.log.Warn("Skipping file in search backfill",
+				"file_path", file.FilePath,
+				"workspace_id", pgconv.PgToUUID(file.WorkspaceID),
+				"error", err)
+			err = s.queries.RecordFileSearchFailure(ctx, db.RecordFileSearchFailureParams{
+				FileID:      file.ID,
+				ContentHash: file.ContentHash,
+				LastError:   err.Error(),
+			})
+			if err != nil {
+				return indexed, fmt.Errorf("failed to record search index failure: %w", err)
+			}
+			continue
+		}
+		err = indexFileContent(ctx, s.queries, db.File{
+			ID:          file.ID,
+			WorkspaceID: file.WorkspaceID,
+			FilePath:    file.FilePath,
+			ContentHash: file.ContentHash,
+		}, content)
+		if err != nil {
+			return indexed, err
+		}
+		if err := s.queries.DeleteFileSearchFailure(ctx, file.ID); err != nil {
+			return indexed, fmt.Errorf("failed to clear search index failure: %w", err)
+		}
+		indexed++
+	}
+
+	if indexed > 0 {
+		s.log.Info("Backfilled search index", "files", indexed)
+	}
+	return indexed, nil
+}
+
+// DeleteExpiredSnapshots removes search snapshots whose cursors have
+// expired.
+func (s *SearchService) DeleteExpiredSnapshots(ctx context.Context) (int64, error) {
+	deleted, err := s.queries.DeleteExpiredSearchSnapshots(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired search snapshots: %w", err)
+	}
+	return deleted, nil
+}
+
+func decodeSearchCursor(cursor string) (uuid.UUID, int, error) {
+	id, position, err := decodeListCursor(cursor)
+	if err != nil {
+		return uuid.Nil, 0, err
+	}
+	snapshotID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, 0, fmt.Errorf("invalid cursor")
+	}
+	offset, err := strconv.Atoi(position)
+	if err != nil || offset < 0 {
+		return uuid.Nil, 0, fmt.Errorf("invalid cursor")
+	}
+	return snapshotID, offset, nil
+}
+
+// indexFileContent replaces a file's entry in the search index. Content
+// that is not UTF-8 text is not indexed; only the path is.
+func indexFileContent(ctx context.Context, qtx *db.Queries, file db.File, content []byte) error {
+	if looksBinary(content) || !utf8.Valid(content) {
+		content = nil
+	}
+	if len(content) > MaxIndexedContentBytes {
+		n := MaxIndexedContentBytes
+		for n > 0 && !utf8.RuneStart(content[n]) {
+			n--
+		}
+		content = content[:n]
+	}
+	err := qtx.UpsertFileSearch(ctx, db.UpsertFileSearchParams{
+		FileID:      file.ID,
+		WorkspaceID: file.WorkspaceID,
+		ContentHash: file.ContentHash,
+		FilePath:    file.FilePath,
+		Content:     string(bytes.ReplaceAll(content, []byte{0}, nil)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update search index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchService_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	files := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	service := NewSearchService(testDB.Queries(), storage.NewPostgresBackend(testDB.Queries()))
+	ctx := context.Background()
+
+	upload := func(path, content string) {
+		_, err := files.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      []byte(content),
+			LastModified: time.Now(),
+			ClientID:     "test-client",
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+	for _, path := range []string{"a.md", "b.md", "c.md", "d.md"} {
+		upload(path, "notes about gardening")
+	}
+	upload("other.md", "nothing relevant")
+
+	search := func(cursor string) *domain.SearchPage {
+		page, err := service.Search(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.SearchRequest{
+			Query:  "gardening",
+			Cursor: cursor,
+			Limit:  2,
+		})
+		require.NoError(t, err)
+		return page
+	}
+
+	t.Run("pages stay stable while the workspace changes", func(t *testing.T) {
+		first := search("")
+		assert.Equal(t, 4, first.Total)
+		require.Len(t, first.Files, 2)
+		require.NotEmpty(t, first.NextCursor)
+
+		upload("0-new.md", "gardening first")
+
+		second := search(first.NextCursor)
+		assert.True(t, second.Stale)
+		assert.False(t, second.Requeried)
+		assert.Equal(t, first.Revision, second.Revision)
+		require.Len(t, second.Files, 2)
+		assert.Empty(t, second.NextCursor)
+
+		seen := map[string]bool{}
+		for _, file := range append(first.Files, second.Files...) {
+			assert.False(t, seen[file.FilePath], "duplicate %s", file.FilePath)
+			seen[file.FilePath] = true
+		}
+		assert.False(t, seen["0-new.md"])
+	})
+
+	t.Run("cursor must match the query", func(t *testing.T) {
+		first := search("")
+		_, err := service.Search(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.SearchRequest{
+			Query:  "other",
+			Cursor: first.NextCursor,
+		})
+		assert.ErrorContains(t, err, "invalid cursor")
+	})
+
+	t.Run("expired cursors run the search again", func(t *testing.T) {
+		first := search("")
+		_, err := testDB.Conn().Exec(ctx, "UPDATE search_snapshots SET expires_at = NOW() - INTERVAL '1 minute'")
+		require.NoError(t, err)
+
+		second := search(first.NextCursor)
+		assert.True(t, second.Requeried)
+		assert.Equal(t, 5, second.Total)
+		assert.Len(t, second.Files, 2)
+	})
+
+	t.Run("backfill rebuilds a missing index", func(t *testing.T) {
+		_, err := testDB.Conn().Exec(ctx, "DELETE FROM file_search")
+		require.NoError(t, err)
+
+		status, err := service.IndexStatus(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.False(t, status.Ready)
+		assert.Equal(t, int64(0), status.IndexedFiles)
+
+		page := search("")
+		assert.True(t, page.Partial)
+		assert.Equal(t, 0, page.Total)
+
+		indexed, err := service.BackfillIndex(ctx, SearchBackfillBatchSize)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, indexed, int(status.TotalFiles))
+
+		status, err = service.IndexStatus(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.True(t, status.Ready)
+
+		page = search("")
+		assert.False(t, page.Partial)
+		assert.Equal(t, 5, page.Total)
+	})
+
+	t.Run("unreadable files do not stall the backfill", func(t *testing.T) {
+		_, err := testDB.Conn().Exec(ctx, "DELETE FROM file_search")
+		require.NoError(t, err)
+		_, err = testDB.Conn().Exec(ctx, "UPDATE files SET content_hash = 'missing', last_modified = NOW() + INTERVAL '1 day' WHERE file_path = 'other.md'")
+		require.NoError(t, err)
+
+		indexed, err := service.BackfillIndex(ctx, 1)
+		require.NoError(t, err)
+		assert.Zero(t, indexed, "the newest file cannot be read")
+		indexed, err = service.BackfillIndex(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, indexed, "files not yet tried come before retries")
+
+		for range MaxSearchIndexAttempts {
+			_, err = service.BackfillIndex(ctx, SearchBackfillBatchSize)
+			require.NoError(t, err)
+		}
+		var attempts int
+		require.NoError(t, testDB.Conn().QueryRow(ctx, "SELECT attempts FROM file_search_failures").Scan(&attempts))
+		assert.Equal(t, MaxSearchIndexAttempts, attempts)
+
+		indexed, err = service.BackfillIndex(ctx, SearchBackfillBatchSize)
+		require.NoError(t, err)
+		assert.Zero(t, indexed, "the file is given up on")
+
+		status, err := service.IndexStatus(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.True(t, status.Ready)
+		assert.Equal(t, int64(1), status.FailedFiles)
+	})
+}
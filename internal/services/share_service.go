@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+)
+
+type ShareService struct {
+	queries  *db.Queries
+	blobs    storage.Backend
+	policies *PolicyService
+	log      *logger.Logger
+}
+
+func NewShareService(queries *db.Queries, blobs storage.Backend, policies *PolicyService) *ShareService {
+	return &ShareService{
+		queries:  queries,
+		blobs:    blobs,
+		policies: policies,
+		log:      logger.New(),
+	}
+}
+
+// CreateShare creates a public link to a file. Publishing content is an
+// edit-level action, so viewers cannot share, and the content must pass
+// the content policy.
+func (s *ShareService) CreateShare(ctx context.Context, workspaceID uuid.UUID, filePath string, userID uuid.UUID, req domain.CreateShareRequest) (*domain.ShareLink, error) {
+	log := s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "")
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("expires_at must be in the future")
+	}
+
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleEditor); err != nil {
+		return nil, err
+	}
+	if err := requireVerifiedEmail(ctx, s.queries, userID); err != nil {
+		return nil, err
+	}
+
+	file, err := s.queries.GetFile(ctx, db.GetFileParams{
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		FilePath:    filePath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("file not found: %w", err)
+	}
+
+	content, err := s.blobs.Get(ctx, file.ContentHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file content: %w", err)
+	}
+	if err := s.policies.Check(ctx, domain.PolicyActionShare, workspaceID, userID, filePath, content); err != nil {
+		log.Warn("Share refused by content policy", "file_path", filePath, "error", err)
+		return nil, err
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate share token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+
+	link, err := s.queries.CreateShareLink(ctx, db.CreateShareLinkParams{
+		TokenHash:         hashShareToken(token),
+		FileID:            file.ID,
+		WorkspaceID:       pgconv.UUIDToPg(workspaceID),
+		CreatedBy:         pgconv.UUIDToPg(userID),
+		ExpiresAt:         pgconv.TimePtrToPg(req.ExpiresAt),
+		PolicyCheckedHash: pgconv.StringToPg(file.ContentHash),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create share link: %w", err)
+	}
+
+	log.Info("Created share link", "file_path", filePath, "share_id", pgconv.PgToUUID(link.ID))
+
+	return &domain.ShareLink{
+		ID:          pgconv.PgToUUID(link.ID),
+		WorkspaceID: workspaceID,
+		FilePath:    filePath,
+		Token:       token,
+		ExpiresAt:   pgconv.PgToTimePtr(link.ExpiresAt),
+		CreatedAt:   pgconv.PgToTime(link.CreatedAt),
+	}, nil
+}
+
+// LookupShare resolves a public token to the shared file's metadata,
+// without reading its content, so cached copies can be revalidated
+// cheaply. Revoked and expired links behave exactly like unknown ones, and
+// so do links whose file content the content policy blocks. Content is
+// checked, as the link's creator, the first time it is served after it
+// changed.
+func (s *ShareService) LookupShare(ctx context.Context, token string) (*domain.SharedFile, error) {
+	file, err := s.queries.GetSharedFile(ctx, hashShareToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("share link not found")
+	}
+	if pgconv.PgToString(file.PolicyCheckedHash) != file.ContentHash {
+		if file.PolicyBlocked, err = s.checkPolicy(ctx, file); err != nil {
+			return nil, err
+		}
+	}
+	if file.PolicyBlocked {
+		return nil, fmt.Errorf("share link not found")
+	}
+
+	return &domain.SharedFile{
+		FilePath:     file.FilePath,
+		ContentHash:  file.ContentHash,
+		MimeType:     pgconv.PgToString(file.MimeType),
+		LastModified: pgconv.PgToTime(file.LastModified),
+		ExpiresAt:    pgconv.PgToTimePtr(file.ExpiresAt),
+	}, nil
+}
+
+// checkPolicy checks the shared file's current content against the
+// content policy and records the outcome on the link. It reports whether
+// the content is blocked.
+func (s *ShareService) checkPolicy(ctx context.Context, file db.GetSharedFileRow) (bool, error) {
+	content, err := s.blobs.Get(ctx, file.ContentHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file content: %w", err)
+	}
+	workspaceID, userID := pgconv.PgToUUID(file.WorkspaceID), pgconv.PgToUUID(file.CreatedBy)
+	err = s.policies.Check(ctx, domain.PolicyActionShare, workspaceID, userID, file.FilePath, content)
+	blocked := policyBlocked(err)
+	if err != nil && !blocked {
+		return false, err
+	}
+	if blocked {
+		s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "").Warn("Shared file blocked by content policy",
+			"file_path", file.FilePath,
+			"share_id", pgconv.PgToUUID(file.ID),
+			"error", err)
+	}
+
+	err = s.queries.RecordSharePolicyCheck(ctx, db.RecordSharePolicyCheckParams{
+		ID:                file.ID,
+		PolicyCheckedHash: pgconv.StringToPg(file.ContentHash),
+		PolicyBlocked:     blocked,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to record content policy check: %w", err)
+	}
+	return blocked, nil
+}
+
+// LoadContent fills in the content of a file returned by LookupShare.
+func (s *ShareService) LoadContent(ctx context.Context, file *domain.SharedFile) error {
+	content, err := s.blobs.Get(ctx, file.ContentHash)
+	if err != nil {
+		return fmt.Errorf("failed to read file content: %w", err)
+	}
+	file.Content = content
+	return nil
+}
+
+// GetSharedFile resolves a public token and reads the file's content.
+func (s *ShareService) GetSharedFile(ctx context.Context, token string) (*domain.SharedFile, error) {
+	file, err := s.LookupShare(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.LoadContent(ctx, file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (s *ShareService) ListShares(ctx context.Context, userID uuid.UUID) ([]domain.ShareLink, error) {
+	rows, err := s.queries.ListShareLinksByUser(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list share links: %w", err)
+	}
+
+	links := make([]domain.ShareLink, len(rows))
+	for i, row := range rows {
+		links[i] = domain.ShareLink{
+			ID:          pgconv.PgToUUID(row.ID),
+			WorkspaceID: pgconv.PgToUUID(row.WorkspaceID),
+			FilePath:    row.FilePath,
+			ExpiresAt:   pgconv.PgToTimePtr(row.ExpiresAt),
+			CreatedAt:   pgconv.PgToTime(row.CreatedAt),
+		}
+	}
+	return links, nil
+}
+
+func (s *ShareService) RevokeShare(ctx context.Context, shareID, userID uuid.UUID) error {
+	revoked, err := s.queries.RevokeShareLink(ctx, db.RevokeShareLinkParams{
+		ID:        pgconv.UUIDToPg(shareID),
+		CreatedBy: pgconv.UUIDToPg(userID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke share link: %w", err)
+	}
+	if revoked == 0 {
+		return fmt.Errorf("share link not found")
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Revoked share link", "share_id", shareID)
+	return nil
+}
+
+func hashShareToken(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
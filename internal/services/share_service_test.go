@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShareService_Links_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewShareService(testDB.Queries(), storage.NewPostgresBackend(testDB.Queries()), NewPolicyService(testDB.Queries()))
+	fileService := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	_, err := fileService.UploadFile(ctx, domain.FileUploadRequest{
+		WorkspaceID:  testData.FreeWorkspaceID,
+		FilePath:     "notes/public.md",
+		Content:      []byte("# Public"),
+		LastModified: time.Now(),
+	}, testData.FreeUserID)
+	require.NoError(t, err)
+
+	t.Run("token resolves to the file", func(t *testing.T) {
+		link, err := service.CreateShare(ctx, testData.FreeWorkspaceID, "notes/public.md", testData.FreeUserID, domain.CreateShareRequest{})
+		require.NoError(t, err)
+		assert.NotEmpty(t, link.Token)
+
+		file, err := service.GetSharedFile(ctx, link.Token)
+		require.NoError(t, err)
+		assert.Equal(t, "notes/public.md", file.FilePath)
+		assert.Equal(t, []byte("# Public"), file.Content)
+		assert.Equal(t, "text/markdown", file.MimeType)
+		assert.NotEmpty(t, file.ContentHash)
+		assert.Nil(t, file.ExpiresAt)
+
+		info, err := service.LookupShare(ctx, link.Token)
+		require.NoError(t, err)
+		assert.Equal(t, file.ContentHash, info.ContentHash)
+		assert.Nil(t, info.Content, "lookup leaves the content unread")
+
+		_, err = service.GetSharedFile(ctx, link.Token+"x")
+		assert.Error(t, err)
+	})
+
+	t.Run("revoked link stops resolving", func(t *testing.T) {
+		link, err := service.CreateShare(ctx, testData.FreeWorkspaceID, "notes/public.md", testData.FreeUserID, domain.CreateShareRequest{})
+		require.NoError(t, err)
+
+		err = service.RevokeShare(ctx, link.ID, testData.PremiumUserID)
+		assert.Error(t, err, "only the creator can revoke")
+
+		require.NoError(t, service.RevokeShare(ctx, link.ID, testData.FreeUserID))
+
+		_, err = service.GetSharedFile(ctx, link.Token)
+		assert.Error(t, err)
+	})
+
+	t.Run("expiry must be in the future", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		_, err := service.CreateShare(ctx, testData.FreeWorkspaceID, "notes/public.md", testData.FreeUserID, domain.CreateShareRequest{ExpiresAt: &past})
+		assert.Error(t, err)
+	})
+
+	t.Run("non-members cannot share", func(t *testing.T) {
+		_, err := service.CreateShare(ctx, testData.FreeWorkspaceID, "notes/public.md", testData.PremiumUserID, domain.CreateShareRequest{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "access denied")
+	})
+
+	t.Run("active links are listed for the creator", func(t *testing.T) {
+		links, err := service.ListShares(ctx, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Len(t, links, 1)
+	})
+}
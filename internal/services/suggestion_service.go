@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+)
+
+// DefaultInactivityPeriod is how long a workspace may go without writes
+// before it is suggested for archival.
+const DefaultInactivityPeriod = 180 * 24 * time.Hour
+
+type SuggestionService struct {
+	queries *db.Queries
+	log     *logger.Logger
+}
+
+func NewSuggestionService(queries *db.Queries) *SuggestionService {
+	return &SuggestionService{
+		queries: queries,
+		log:     logger.New(),
+	}
+}
+
+// RefreshInactiveWorkspaces flags workspaces without writes for inactiveFor
+// and drops suggestions for workspaces that became active or were archived
+// since the last run. It returns the number of newly flagged workspaces.
+func (s *SuggestionService) RefreshInactiveWorkspaces(ctx context.Context, inactiveFor time.Duration) (int64, error) {
+	cutoff := pgconv.TimeToPg(time.Now().Add(-inactiveFor))
+
+	cleared, err := s.queries.ClearActiveWorkspaceSuggestions(ctx, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear stale suggestions: %w", err)
+	}
+
+	flagged, err := s.queries.FlagInactiveWorkspaces(ctx, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to flag inactive workspaces: %w", err)
+	}
+
+	s.log.Info("Refreshed inactive workspace suggestions",
+		"flagged", flagged,
+		"cleared", cleared,
+		"inactive_for", inactiveFor.String())
+
+	return flagged, nil
+}
+
+func (s *SuggestionService) ListSuggestions(ctx context.Context, userID uuid.UUID) ([]domain.WorkspaceSuggestion, error) {
+	rows, err := s.queries.ListSuggestionsByUser(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list suggestions: %w", err)
+	}
+
+	suggestions := make([]domain.WorkspaceSuggestion, len(rows))
+	for i, row := range rows {
+		suggestions[i] = domain.WorkspaceSuggestion{
+			ID:               pgconv.PgToUUID(row.ID),
+			WorkspaceID:      pgconv.PgToUUID(row.WorkspaceID),
+			WorkspaceName:    row.WorkspaceName,
+			Reason:           row.Reason,
+			LastActivityAt:   pgconv.PgToTime(row.LastActivityAt),
+			StorageUsedBytes: pgconv.PgToInt64(row.StorageUsedBytes),
+			FileCount:        row.FileCount,
+			Actions: []domain.SuggestionAction{
+				domain.SuggestionArchive,
+				domain.SuggestionExport,
+				domain.SuggestionDelete,
+				domain.SuggestionDismiss,
+			},
+			CreatedAt: pgconv.PgToTime(row.CreatedAt),
+		}
+	}
+
+	return suggestions, nil
+}
+
+// GetSuggestionWorkspace returns the workspace a suggestion refers to, after
+// checking the suggestion belongs to the user.
+func (s *SuggestionService) GetSuggestionWorkspace(ctx context.Context, suggestionID uuid.UUID, userID uuid.UUID) (uuid.UUID, error) {
+	suggestion, err := s.queries.GetSuggestion(ctx, db.GetSuggestionParams{
+		ID:     pgconv.UUIDToPg(suggestionID),
+		UserID: pgconv.UUIDToPg(userID),
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("suggestion not found: %w", err)
+	}
+
+	return pgconv.PgToUUID(suggestion.WorkspaceID), nil
+}
+
+func (s *SuggestionService) DismissSuggestion(ctx context.Context, suggestionID uuid.UUID, userID uuid.UUID) error {
+	err := s.queries.DismissSuggestion(ctx, db.DismissSuggestionParams{
+		ID:     pgconv.UUIDToPg(suggestionID),
+		UserID: pgconv.UUIDToPg(userID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to dismiss suggestion: %w", err)
+	}
+	return nil
+}
+
+// ResolveSuggestion removes a suggestion once its action has been taken.
+func (s *SuggestionService) ResolveSuggestion(ctx context.Context, suggestionID uuid.UUID) error {
+	if err := s.queries.DeleteSuggestion(ctx, pgconv.UUIDToPg(suggestionID)); err != nil {
+		return fmt.Errorf("failed to resolve suggestion: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestionService_RefreshInactiveWorkspaces_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewSuggestionService(testDB.Queries())
+	workspaceService := NewWorkspaceService(testDB.Queries(), storage.NewPostgresBackend(testDB.Queries()))
+	ctx := context.Background()
+
+	t.Run("active workspace is not flagged", func(t *testing.T) {
+		flagged, err := service.RefreshInactiveWorkspaces(ctx, 30*24*time.Hour)
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), flagged)
+	})
+
+	t.Run("untouched workspace is flagged", func(t *testing.T) {
+		_, err := testDB.Conn().Exec(ctx,
+			"UPDATE workspaces SET updated_at = NOW() - INTERVAL '400 days' WHERE id = $1",
+			testData.FreeWorkspaceID)
+		require.NoError(t, err)
+
+		flagged, err := service.RefreshInactiveWorkspaces(ctx, 30*24*time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), flagged)
+
+		suggestions, err := service.ListSuggestions(ctx, testData.FreeUserID)
+		require.NoError(t, err)
+		require.Len(t, suggestions, 1)
+		assert.Equal(t, testData.FreeWorkspaceID, suggestions[0].WorkspaceID)
+		assert.Equal(t, domain.SuggestionReasonInactive, suggestions[0].Reason)
+
+		others, err := service.ListSuggestions(ctx, testData.PremiumUserID)
+		require.NoError(t, err)
+		assert.Empty(t, others)
+	})
+
+	t.Run("archiving clears the suggestion and blocks uploads", func(t *testing.T) {
+		_, err := workspaceService.SetWorkspaceArchived(ctx, testData.FreeWorkspaceID, testData.FreeUserID, true)
+		require.NoError(t, err)
+
+		_, err = service.RefreshInactiveWorkspaces(ctx, 30*24*time.Hour)
+		require.NoError(t, err)
+
+		suggestions, err := service.ListSuggestions(ctx, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Empty(t, suggestions)
+
+		fileService := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+		_, err = fileService.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     "late.md",
+			Content:      []byte("too late"),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "workspace is archived")
+	})
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/thumbnail"
+)
+
+// Thumbnail sizes, in pixels along the longer side.
+const (
+	DefaultThumbnailSize = 256
+	MinThumbnailSize     = 16
+	MaxThumbnailSize     = 1024
+)
+
+// MaxThumbnailSourceBytes caps the attachments thumbnails are made of, as
+// they are decoded in memory.
+const MaxThumbnailSourceBytes = 32 << 20
+
+// Thumbnail scales an image attachment down to size. file comes from
+// GetFile, which checked access, so callers can answer conditional
+// requests before the content is read. Thumbnails are cheap to recompute
+// and are not stored.
+func (s *FileService) Thumbnail(ctx context.Context, file *domain.FileInfo, size int) (*thumbnail.Thumbnail, error) {
+	if size < MinThumbnailSize || size > MaxThumbnailSize {
+		return nil, fmt.Errorf("invalid size: must be between %d and %d", MinThumbnailSize, MaxThumbnailSize)
+	}
+	if file.SizeBytes > MaxThumbnailSourceBytes {
+		return nil, fmt.Errorf("unsupported media type: images over %d bytes have no thumbnail", MaxThumbnailSourceBytes)
+	}
+
+	content, err := s.blobs.Get(ctx, file.ContentHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file content: %w", err)
+	}
+	thumb, err := thumbnail.Generate(content, size)
+	if errors.Is(err, thumbnail.ErrUnsupported) {
+		return nil, fmt.Errorf("unsupported media type: thumbnails are made of PNG, JPEG and GIF images, not %s", file.MimeType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unsupported media type: %w", err)
+	}
+	return thumb, nil
+}
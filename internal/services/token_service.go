@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultTokenIdlePeriod is how long an API token may go unused before
+	// it is deleted.
+	DefaultTokenIdlePeriod = 180 * 24 * time.Hour
+	// ExpiredTokenRetention is how long expired tokens are kept, so that
+	// clients still presenting them learn they expired rather than that
+	// they are invalid.
+	ExpiredTokenRetention = 30 * 24 * time.Hour
+)
+
+// TokenService mints scoped API tokens for scripts and integrations.
+// Tokens from signing in are issued by the login handlers and have full
+// access.
+type TokenService struct {
+	queries *db.Queries
+	log     *logger.Logger
+}
+
+func NewTokenService(queries *db.Queries) *TokenService {
+	return &TokenService{
+		queries: queries,
+		log:     logger.New(),
+	}
+}
+
+// CreateToken mints a token for userID limited to req.Scopes. Workspace
+// scopes may only name workspaces the user belongs to.
+func (s *TokenService) CreateToken(ctx context.Context, userID uuid.UUID, req domain.CreateTokenRequest) (*domain.CreatedToken, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > domain.MaxTokenNameLength {
+		return nil, fmt.Errorf("invalid name: must be 1 to %d characters", domain.MaxTokenNameLength)
+	}
+	scopes, err := domain.ParseTokenScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("invalid expires_at: must be in the future")
+	}
+	for _, workspaceID := range scopes.Workspaces() {
+		if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+			return nil, err
+		}
+	}
+
+	tokenString, tokenHash, err := auth.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+	row, err := s.queries.CreateAPIToken(ctx, db.CreateAPITokenParams{
+		UserID:    pgconv.UUIDToPg(userID),
+		TokenHash: tokenHash,
+		Name:      name,
+		ExpiresAt: pgconv.TimePtrToPg(req.ExpiresAt),
+		Scopes:    scopes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store token: %w", err)
+	}
+
+	s.log.WithContext(ctx).LogAuthEvent("token_created", userID.String(), "token")
+	return &domain.CreatedToken{APIToken: toDomainAPIToken(row), Token: tokenString}, nil
+}
+
+// ListTokens returns the scoped tokens userID minted, newest first.
+func (s *TokenService) ListTokens(ctx context.Context, userID uuid.UUID) ([]domain.APIToken, error) {
+	rows, err := s.queries.ListScopedAPITokens(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	tokens := make([]domain.APIToken, len(rows))
+	for i, row := range rows {
+		tokens[i] = toDomainAPIToken(row)
+	}
+	return tokens, nil
+}
+
+// DeleteToken revokes one of the scoped tokens userID minted.
+func (s *TokenService) DeleteToken(ctx context.Context, userID, tokenID uuid.UUID) error {
+	n, err := s.queries.DeleteScopedAPIToken(ctx, db.DeleteScopedAPITokenParams{
+		ID:     pgconv.UUIDToPg(tokenID),
+		UserID: pgconv.UUIDToPg(userID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete token: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("token not found")
+	}
+	s.log.WithContext(ctx).LogAuthEvent("token_deleted", userID.String(), "token")
+	return nil
+}
+
+// PurgeStale deletes the tokens of every user that expired more than
+// ExpiredTokenRetention ago or went unused for idleFor, and returns how
+// many it deleted.
+func (s *TokenService) PurgeStale(ctx context.Context, idleFor time.Duration) (int64, error) {
+	now := time.Now()
+	n, err := s.queries.DeleteStaleAPITokens(ctx, db.DeleteStaleAPITokensParams{
+		ExpiredBefore: pgconv.TimeToPg(now.Add(-ExpiredTokenRetention)),
+		UnusedSince:   pgconv.TimeToPg(now.Add(-idleFor)),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete stale tokens: %w", err)
+	}
+	if n > 0 {
+		s.log.WithContext(ctx).Info("Deleted stale API tokens", "count", n)
+	}
+	return n, nil
+}
+
+func toDomainAPIToken(t db.ApiToken) domain.APIToken {
+	return domain.APIToken{
+		ID:         pgconv.PgToUUID(t.ID),
+		UserID:     pgconv.PgToUUID(t.UserID),
+		Name:       t.Name,
+		LastUsedAt: pgconv.PgToTimePtr(t.LastUsedAt),
+		ExpiresAt:  pgconv.PgToTimePtr(t.ExpiresAt),
+		CreatedAt:  pgconv.PgToTime(t.CreatedAt),
+		DeviceID:   pgconv.PgToUUIDPtr(t.DeviceID),
+		Scopes:     t.Scopes,
+	}
+}
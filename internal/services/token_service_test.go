@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenService_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewTokenService(testDB.Queries())
+	ctx := context.Background()
+	workspaceScope := domain.ScopeWorkspacePrefix + testData.FreeWorkspaceID.String()
+
+	created, err := service.CreateToken(ctx, testData.FreeUserID, domain.CreateTokenRequest{
+		Name:   " CI backup ",
+		Scopes: []string{"read", workspaceScope},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "CI backup", created.Name)
+	assert.Equal(t, domain.TokenScopes{"read", workspaceScope}, created.Scopes)
+	assert.NotEmpty(t, created.Token)
+
+	row, err := testDB.Queries().GetTokenByHash(ctx, auth.HashToken(created.Token))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read", workspaceScope}, row.Scopes)
+
+	t.Run("invalid requests", func(t *testing.T) {
+		_, err := service.CreateToken(ctx, testData.FreeUserID, domain.CreateTokenRequest{Name: "x", Scopes: []string{"admin"}})
+		assert.ErrorContains(t, err, "invalid scopes")
+
+		past := time.Now().Add(-time.Hour)
+		_, err = service.CreateToken(ctx, testData.FreeUserID, domain.CreateTokenRequest{Name: "x", Scopes: []string{"read"}, ExpiresAt: &past})
+		assert.ErrorContains(t, err, "invalid expires_at")
+
+		_, err = service.CreateToken(ctx, testData.PremiumUserID, domain.CreateTokenRequest{Name: "x", Scopes: []string{"write", workspaceScope}})
+		assert.ErrorContains(t, err, "access denied: not a member")
+	})
+
+	tokens, err := service.ListTokens(ctx, testData.FreeUserID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1, "tokens from signing in are not listed")
+	assert.Equal(t, created.ID, tokens[0].ID)
+
+	assert.ErrorContains(t, service.DeleteToken(ctx, testData.PremiumUserID, created.ID), "token not found")
+	assert.ErrorContains(t, service.DeleteToken(ctx, testData.FreeUserID, uuid.New()), "token not found")
+	require.NoError(t, service.DeleteToken(ctx, testData.FreeUserID, created.ID))
+
+	_, err = testDB.Queries().GetTokenByHash(ctx, auth.HashToken(created.Token))
+	assert.Error(t, err)
+}
+
+func TestTokenService_PurgeStale_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewTokenService(testDB.Queries())
+	ctx := context.Background()
+
+	mint := func(name string) *domain.CreatedToken {
+		created, err := service.CreateToken(ctx, testData.FreeUserID, domain.CreateTokenRequest{Name: name, Scopes: []string{"read"}})
+		require.NoError(t, err)
+		return created
+	}
+	fresh, expired, longExpired, idle := mint("fresh"), mint("expired"), mint("long expired"), mint("idle")
+
+	_, err := testDB.Conn().Exec(ctx, "UPDATE api_tokens SET expires_at = NOW() - INTERVAL '1 day' WHERE id = $1", expired.ID)
+	require.NoError(t, err)
+	_, err = testDB.Conn().Exec(ctx, "UPDATE api_tokens SET expires_at = NOW() - INTERVAL '60 days' WHERE id = $1", longExpired.ID)
+	require.NoError(t, err)
+	_, err = testDB.Conn().Exec(ctx, "UPDATE api_tokens SET created_at = NOW() - INTERVAL '200 days' WHERE id = $1", idle.ID)
+	require.NoError(t, err)
+
+	row, err := testDB.Queries().GetTokenByHash(ctx, auth.HashToken(expired.Token))
+	require.NoError(t, err, "expired tokens are still found")
+	assert.True(t, row.Expired)
+
+	deleted, err := service.PurgeStale(ctx, DefaultTokenIdlePeriod)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	tokens, err := service.ListTokens(ctx, testData.FreeUserID)
+	require.NoError(t, err)
+	var names []string
+	for _, token := range tokens {
+		names = append(names, token.Name)
+	}
+	assert.ElementsMatch(t, []string{fresh.Name, expired.Name}, names)
+}
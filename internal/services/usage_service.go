@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// UsageFlushInterval is how often a UsageMeter should be flushed.
+const UsageFlushInterval = time.Minute
+
+type usageKey struct {
+	userID uuid.UUID
+	month  time.Time
+}
+
+type usageCount struct {
+	requests      int64
+	uploadBytes   int64
+	downloadBytes int64
+}
+
+// UsageMeter counts API requests and their bytes per user in memory, so
+// that requests do not write to the database. Flush adds the counts to
+// the monthly totals.
+type UsageMeter struct {
+	queries *db.Queries
+	mu      sync.Mutex
+	counts  map[usageKey]*usageCount
+}
+
+func NewUsageMeter(queries *db.Queries) *UsageMeter {
+	return &UsageMeter{
+		queries: queries,
+		counts:  make(map[usageKey]*usageCount),
+	}
+}
+
+// Record counts one request of userID that read uploadBytes of request
+// body and wrote downloadBytes of response body.
+func (m *UsageMeter) Record(userID uuid.UUID, uploadBytes, downloadBytes int64) {
+	m.add(usageKey{userID, domain.UsageMonth(time.Now())}, usageCount{1, uploadBytes, downloadBytes})
+}
+
+func (m *UsageMeter) add(key usageKey, count usageCount) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.counts[key]
+	if !ok {
+		c = &usageCount{}
+		m.counts[key] = c
+	}
+	c.requests += count.requests
+	c.uploadBytes += count.uploadBytes
+	c.downloadBytes += count.downloadBytes
+}
+
+// Flush adds the counts so far to the monthly totals. Counts it fails to
+// store are kept for the next flush.
+func (m *UsageMeter) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	counts := m.counts
+	m.counts = make(map[usageKey]*usageCount)
+	m.mu.Unlock()
+
+	var errs []error
+	for key, count := range counts {
+		err := m.queries.AddUsage(ctx, db.AddUsageParams{
+			UserID:        pgconv.UUIDToPg(key.userID),
+			Month:         pgconv.DateToPg(key.month),
+			ApiRequests:   count.requests,
+			UploadBytes:   count.uploadBytes,
+			DownloadBytes: count.downloadBytes,
+		})
+		if err != nil {
+			m.add(key, *count)
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to store usage of %d users: %w", len(errs), errors.Join(errs...))
+	}
+	return nil
+}
+
+// UsageService reports metered usage.
+type UsageService struct {
+	queries *db.Queries
+	log     *logger.Logger
+}
+
+func NewUsageService(queries *db.Queries) *UsageService {
+	return &UsageService{
+		queries: queries,
+		log:     logger.New(),
+	}
+}
+
+// GetUsage reports userID's usage over the last months calendar months,
+// the current one included, against the allowance of the user's tier.
+func (s *UsageService) GetUsage(ctx context.Context, userID uuid.UUID, months int) (*domain.UsageReport, error) {
+	if months < 1 || months > domain.MaxUsageMonths {
+		return nil, fmt.Errorf("invalid months: must be 1 to %d", domain.MaxUsageMonths)
+	}
+
+	user, err := s.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	tier := domain.UserTier(user.Tier)
+	allowance := tier.GetMonthlyAllowance()
+
+	current := domain.UsageMonth(time.Now())
+	since := current.AddDate(0, 1-months, 0)
+	rows, err := s.queries.ListUserUsage(ctx, db.ListUserUsageParams{
+		UserID: pgconv.UUIDToPg(userID),
+		Since:  pgconv.DateToPg(since),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage: %w", err)
+	}
+	byMonth := make(map[time.Time]db.UsageMonthly, len(rows))
+	for _, row := range rows {
+		byMonth[domain.UsageMonth(pgconv.PgToDate(row.Month))] = row
+	}
+
+	report := &domain.UsageReport{Tier: tier, Months: make([]domain.MonthlyUsage, 0, months)}
+	for month := current; !month.Before(since); month = month.AddDate(0, -1, 0) {
+		row := byMonth[month]
+		report.Months = append(report.Months, domain.NewMonthlyUsage(month, row.ApiRequests, row.UploadBytes, row.DownloadBytes, allowance))
+	}
+	return report, nil
+}
+
+// ExportUsage writes every user's usage in month to w as CSV, with the
+// allowance and overage of the user's tier.
+func (s *UsageService) ExportUsage(ctx context.Context, month time.Time, w io.Writer) error {
+	month = domain.UsageMonth(month)
+	rows, err := s.queries.ListUsageByMonth(ctx, pgconv.DateToPg(month))
+	if err != nil {
+		return fmt.Errorf("failed to list usage: %w", err)
+	}
+
+	out := csv.NewWriter(w)
+	out.Write([]string{
+		"user_id", "email", "tier", "month",
+		"api_requests", "upload_bytes", "download_bytes",
+		"api_request_allowance", "transfer_allowance_bytes",
+		"api_request_overage", "transfer_overage_bytes",
+	})
+	for _, row := range rows {
+		tier := domain.UserTier(row.Tier)
+		usage := domain.NewMonthlyUsage(month, row.ApiRequests, row.UploadBytes, row.DownloadBytes, tier.GetMonthlyAllowance())
+		out.Write([]string{
+			pgconv.PgToUUID(row.UserID).String(), row.Email, string(tier), usage.Month,
+			itoa(usage.APIRequests), itoa(usage.UploadBytes), itoa(usage.DownloadBytes),
+			itoa(usage.Allowance.APIRequests), itoa(usage.Allowance.TransferBytes),
+			itoa(usage.Overage.APIRequests), itoa(usage.Overage.TransferBytes),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
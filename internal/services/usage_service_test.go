@@ -0,0 +1,54 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageService_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	data := testutil.CreateSimpleTestData(t, testDB.Queries())
+	ctx := context.Background()
+
+	meter := NewUsageMeter(testDB.Queries())
+	meter.Record(data.FreeUserID, 100, 2000)
+	meter.Record(data.FreeUserID, 0, 500)
+	require.NoError(t, meter.Flush(ctx))
+	meter.Record(data.FreeUserID, 10, 0)
+	require.NoError(t, meter.Flush(ctx))
+	require.NoError(t, meter.Flush(ctx), "flushing nothing is fine")
+
+	service := NewUsageService(testDB.Queries())
+	report, err := service.GetUsage(ctx, data.FreeUserID, 3)
+	require.NoError(t, err)
+	assert.Equal(t, domain.TierFree, report.Tier)
+	require.Len(t, report.Months, 3, "months without usage are reported")
+
+	current := report.Months[0]
+	assert.Equal(t, time.Now().UTC().Format(domain.UsageMonthLayout), current.Month)
+	assert.Equal(t, int64(3), current.APIRequests)
+	assert.Equal(t, int64(110), current.UploadBytes)
+	assert.Equal(t, int64(2500), current.DownloadBytes)
+	assert.Equal(t, domain.TierFree.GetMonthlyAllowance(), current.Allowance)
+	assert.Zero(t, report.Months[1].APIRequests)
+
+	_, err = service.GetUsage(ctx, data.FreeUserID, 0)
+	assert.ErrorContains(t, err, "invalid months")
+
+	var out bytes.Buffer
+	require.NoError(t, service.ExportUsage(ctx, time.Now(), &out))
+	rows, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2, "only users with usage are exported")
+	assert.Equal(t, "user_id", rows[0][0])
+	assert.Equal(t, data.FreeUserID.String(), rows[1][0])
+	assert.Equal(t, "3", rows[1][4])
+}
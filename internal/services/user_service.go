@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/duckonomy/noture/pkg/email"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// AccountDeletionGracePeriod is how long a deleted account can still be
+// restored before it is purged.
+const AccountDeletionGracePeriod = 30 * 24 * time.Hour
+
+// AccountPurgeBatchSize caps the accounts one purge run deletes.
+const AccountPurgeBatchSize = 100
+
+// dummyPasswordHash is verified against when an unknown email logs in, so
+// that response times do not reveal which emails are registered.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := auth.HashPassword("noture-unknown-user-0")
+	return hash
+})
+
+type UserService struct {
+	queries  *db.Queries
+	mailer   email.Sender
+	baseURL  string
+	verifier *EmailVerifier
+	log      *logger.Logger
+}
+
+func NewUserService(queries *db.Queries) *UserService {
+	return &UserService{
+		queries: queries,
+		mailer:  email.NewLogSender(),
+		log:     logger.New(),
+	}
+}
+
+// WithMailer sets how account emails, such as password resets, are sent.
+// baseURL is the server's public URL, named in those emails. Without it
+// they are only logged.
+func (s *UserService) WithMailer(mailer email.Sender, baseURL string) *UserService {
+	s.mailer = mailer
+	s.baseURL = baseURL
+	return s
+}
+
+// Register creates a free-tier user with an email and password.
+func (s *UserService) Register(ctx context.Context, email, password string) (*domain.User, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := auth.ValidatePassword(password); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.queries.GetUserByEmail(ctx, email); err == nil {
+		return nil, fmt.Errorf("email already registered")
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.queries.CreateUser(ctx, db.CreateUserParams{
+		Email:        email,
+		PasswordHash: hash,
+		Tier:         db.UserTierFree,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("email already registered")
+		}
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	s.log.WithContext(ctx).LogAuthEvent("register", pgconv.PgToUUID(user.ID).String(), "password")
+	s.sendVerification(ctx, user)
+
+	return toDomainUser(user), nil
+}
+
+// Authenticate checks an email and password. Accounts created through
+// OAuth have no password and can never log in this way, and disabled
+// accounts cannot log in at all.
+func (s *UserService) Authenticate(ctx context.Context, email, password string) (*domain.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	user, err := s.queries.GetUserByEmail(ctx, email)
+	if err != nil {
+		auth.VerifyPassword(password, dummyPasswordHash())
+		return nil, fmt.Errorf("invalid email or password")
+	}
+
+	if user.PasswordHash == "" || !auth.VerifyPassword(password, user.PasswordHash) {
+		return nil, fmt.Errorf("invalid email or password")
+	}
+	if user.DisabledAt.Valid {
+		return nil, fmt.Errorf("account disabled")
+	}
+
+	return toDomainUser(user), nil
+}
+
+func (s *UserService) GetUser(ctx context.Context, userID uuid.UUID) (*domain.User, error) {
+	user, err := s.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	return toDomainUser(user), nil
+}
+
+// UpdateProfile applies the fields set in req and returns the updated user.
+func (s *UserService) UpdateProfile(ctx context.Context, userID uuid.UUID, req domain.UpdateProfileRequest) (*domain.User, error) {
+	if req.Timezone == nil {
+		return s.GetUser(ctx, userID)
+	}
+
+	if err := domain.ValidateTimezone(*req.Timezone); err != nil {
+		return nil, err
+	}
+
+	user, err := s.queries.UpdateUserTimezone(ctx, db.UpdateUserTimezoneParams{
+		ID:       pgconv.UUIDToPg(userID),
+		Timezone: *req.Timezone,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update timezone: %w", err)
+	}
+
+	s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Updated timezone", "timezone", *req.Timezone)
+	return toDomainUser(user), nil
+}
+
+// ChangePassword replaces the user's password after checking the current
+// one, then revokes every other API token so a leaked credential stops
+// working everywhere. Accounts created through OAuth or with a passkey
+// have no password yet; they set one with a code from RequestPasswordCode
+// instead of currentPassword. It returns the number of tokens revoked.
+func (s *UserService) ChangePassword(ctx context.Context, userID, currentTokenID uuid.UUID, currentPassword, code, newPassword string) (int64, error) {
+	user, err := s.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return 0, fmt.Errorf("user not found: %w", err)
+	}
+
+	// Checked first, so a code is only used up on a password that is set.
+	if err := auth.ValidatePassword(newPassword); err != nil {
+		return 0, err
+	}
+
+	if user.PasswordHash == "" {
+		if code == "" {
+			return 0, fmt.Errorf("code required: the account has no password, confirm its email with a code first")
+		}
+		owner, err := s.queries.ConsumePasswordReset(ctx, auth.HashToken(code))
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && owner != user.ID) {
+			return 0, fmt.Errorf("invalid or expired code")
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to check code: %w", err)
+		}
+	} else if !auth.VerifyPassword(currentPassword, user.PasswordHash) {
+		return 0, fmt.Errorf("invalid current password")
+	}
+
+	hash, err := auth.HashPassword(newPassword)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.queries.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{
+		ID:           user.ID,
+		PasswordHash: hash,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to update password: %w", err)
+	}
+	s.log.WithContext(ctx).LogAuthEvent("password_change", userID.String(), "password")
+
+	return s.RevokeAllTokens(ctx, userID, &currentTokenID, "password_change")
+}
+
+// RevokeAllTokens deletes every API token of the user except keep, when
+// set, and records why in the auth log. It returns the number revoked.
+func (s *UserService) RevokeAllTokens(ctx context.Context, userID uuid.UUID, keep *uuid.UUID, reason string) (int64, error) {
+	params := db.RevokeUserTokensParams{UserID: pgconv.UUIDToPg(userID)}
+	if keep != nil {
+		params.KeepID = pgconv.UUIDToPg(*keep)
+	}
+
+	revoked, err := s.queries.RevokeUserTokens(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+
+	s.log.WithContext(ctx).LogAuthEvent("revoke_all_tokens", userID.String(), reason)
+	s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Revoked API tokens",
+		"reason", reason,
+		"revoked", revoked,
+		"kept_current", keep != nil)
+	return revoked, nil
+}
+
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", fmt.Errorf("invalid email address")
+	}
+	return email, nil
+}
+
+// DeleteAccount schedules userID's account for deletion after the grace
+// period and signs it out everywhere. confirmEmail must be the account's
+// email. Signing in again and calling RestoreAccount before then keeps
+// the account.
+func (s *UserService) DeleteAccount(ctx context.Context, userID uuid.UUID, confirmEmail string) (*domain.AccountDeletion, error) {
+	user, err := s.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if !strings.EqualFold(strings.TrimSpace(confirmEmail), user.Email) {
+		return nil, fmt.Errorf("invalid confirmation: email does not match the account")
+	}
+
+	deleteAfter := time.Now().Add(AccountDeletionGracePeriod)
+	if user.DeleteAfter.Valid {
+		deleteAfter = pgconv.PgToTime(user.DeleteAfter)
+	} else {
+		user, err = s.queries.ScheduleUserDeletion(ctx, db.ScheduleUserDeletionParams{
+			ID:          user.ID,
+			DeleteAfter: pgconv.TimeToPg(deleteAfter),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to schedule deletion: %w", err)
+		}
+	}
+
+	revoked, err := s.queries.RevokeUserTokens(ctx, db.RevokeUserTokensParams{UserID: user.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+
+	s.log.WithContext(ctx).LogAuthEvent("account_deletion_requested", userID.String(), "api")
+	s.log.WithContext(ctx).WithUser(userID.String(), "").Info("Scheduled account deletion",
+		"delete_after", deleteAfter,
+		"revoked_tokens", revoked)
+	return &domain.AccountDeletion{DeleteAfter: deleteAfter}, nil
+}
+
+// RestoreAccount cancels a scheduled deletion.
+func (s *UserService) RestoreAccount(ctx context.Context, userID uuid.UUID) error {
+	n, err := s.queries.CancelUserDeletion(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return fmt.Errorf("failed to restore account: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("account deletion not scheduled")
+	}
+
+	s.log.WithContext(ctx).LogAuthEvent("account_restored", userID.String(), "api")
+	return nil
+}
+
+// PurgeDeletedAccounts deletes up to limit accounts whose grace period has
+// passed, with their workspaces, files, tokens and identities. Stored
+// content is left to the unreferenced blob collector. It returns the
+// number of accounts deleted.
+func (s *UserService) PurgeDeletedAccounts(ctx context.Context, limit int32) (int, error) {
+	ids, err := s.queries.ListUsersDueForDeletion(ctx, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list accounts due for deletion: %w", err)
+	}
+
+	purged := 0
+	for _, id := range ids {
+		// A restore since the list was read leaves the account alone.
+		n, err := s.queries.DeleteScheduledUser(ctx, id)
+		if err != nil {
+			return purged, fmt.Errorf("failed to delete account: %w", err)
+		}
+		if n > 0 {
+			purged++
+			s.log.WithContext(ctx).LogAuthEvent("account_deleted", pgconv.PgToUUID(id).String(), "scheduled")
+		}
+	}
+	return purged, nil
+}
+
+func toDomainUser(u db.User) *domain.User {
+	return &domain.User{
+		ID:               pgconv.PgToUUID(u.ID),
+		Email:            u.Email,
+		Tier:             domain.UserTier(u.Tier),
+		StorageUsedBytes: pgconv.PgToInt64(u.StorageUsedBytes),
+		Timezone:         u.Timezone,
+		IsAdmin:          u.IsAdmin,
+		EmailVerified:    u.EmailVerifiedAt.Valid,
+		DeleteAfter:      pgconv.PgToTimePtr(u.DeleteAfter),
+		CreatedAt:        pgconv.PgToTime(u.CreatedAt),
+		UpdatedAt:        pgconv.PgToTime(u.UpdatedAt),
+	}
+}
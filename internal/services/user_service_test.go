@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/auth"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_PasswordAuth_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+
+	service := NewUserService(testDB.Queries())
+	ctx := context.Background()
+
+	t.Run("register and log in", func(t *testing.T) {
+		user, err := service.Register(ctx, "  New.User@Example.com ", "correct horse 42")
+		require.NoError(t, err)
+		assert.Equal(t, "new.user@example.com", user.Email)
+		assert.Equal(t, domain.TierFree, user.Tier)
+
+		loggedIn, err := service.Authenticate(ctx, "new.user@example.com", "correct horse 42")
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, loggedIn.ID)
+	})
+
+	t.Run("duplicate email is rejected", func(t *testing.T) {
+		_, err := service.Register(ctx, "NEW.USER@example.com", "another horse 42")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "email already registered")
+	})
+
+	t.Run("weak password is rejected", func(t *testing.T) {
+		_, err := service.Register(ctx, "weak@example.com", "short1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "password must")
+	})
+
+	t.Run("wrong password and unknown email fail alike", func(t *testing.T) {
+		_, err := service.Authenticate(ctx, "new.user@example.com", "wrong horse 42")
+		require.Error(t, err)
+		assert.Equal(t, "invalid email or password", err.Error())
+
+		_, err = service.Authenticate(ctx, "nobody@example.com", "correct horse 42")
+		require.Error(t, err)
+		assert.Equal(t, "invalid email or password", err.Error())
+	})
+}
+
+func TestUserService_UpdateProfile_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewUserService(testDB.Queries())
+	ctx := context.Background()
+
+	t.Run("timezone defaults to UTC", func(t *testing.T) {
+		user, err := service.GetUser(ctx, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.DefaultTimezone, user.Timezone)
+		assert.Equal(t, time.UTC, user.CreatedAt.Location())
+	})
+
+	t.Run("valid timezone is stored", func(t *testing.T) {
+		zone := "Europe/Berlin"
+		user, err := service.UpdateProfile(ctx, testData.FreeUserID, domain.UpdateProfileRequest{Timezone: &zone})
+		require.NoError(t, err)
+		assert.Equal(t, zone, user.Timezone)
+		assert.Equal(t, zone, user.Location().String())
+	})
+
+	t.Run("unknown timezone is rejected", func(t *testing.T) {
+		zone := "Nowhere/Special"
+		_, err := service.UpdateProfile(ctx, testData.FreeUserID, domain.UpdateProfileRequest{Timezone: &zone})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid timezone")
+	})
+}
+
+func TestUserService_Preferences_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewUserService(testDB.Queries())
+	ctx := context.Background()
+	userID := testData.FreeUserID
+
+	prefs, err := service.GetPreferences(ctx, userID)
+	require.NoError(t, err)
+	assert.Empty(t, prefs.Locale)
+	assert.Nil(t, prefs.DefaultWorkspaceID)
+
+	locale, workspaceID := "de-CH", testData.FreeWorkspaceID.String()
+	_, err = service.UpdatePreferences(ctx, userID, domain.PreferencesPatch{
+		Locale:             &locale,
+		DefaultWorkspaceID: &workspaceID,
+		Notifications:      map[string]bool{"invites": false},
+		Editor:             json.RawMessage(`{"vim": true}`),
+	})
+	require.NoError(t, err)
+
+	prefs, err = service.UpdatePreferences(ctx, userID, domain.PreferencesPatch{
+		Notifications: map[string]bool{"shares": false},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "de-CH", prefs.Locale)
+	require.NotNil(t, prefs.DefaultWorkspaceID)
+	assert.Equal(t, testData.FreeWorkspaceID, *prefs.DefaultWorkspaceID)
+	assert.Equal(t, map[string]bool{"invites": false, "shares": false}, prefs.Notifications)
+	assert.JSONEq(t, `{"vim": true}`, string(prefs.Editor))
+
+	cleared := ""
+	prefs, err = service.UpdatePreferences(ctx, userID, domain.PreferencesPatch{
+		DefaultWorkspaceID: &cleared,
+		Editor:             json.RawMessage(`null`),
+	})
+	require.NoError(t, err)
+	assert.Nil(t, prefs.DefaultWorkspaceID)
+	assert.JSONEq(t, `{}`, string(prefs.Editor))
+
+	other := uuid.New().String()
+	_, err = service.UpdatePreferences(ctx, testData.PremiumUserID, domain.PreferencesPatch{DefaultWorkspaceID: &workspaceID})
+	assert.ErrorContains(t, err, "access denied")
+	_, err = service.UpdatePreferences(ctx, userID, domain.PreferencesPatch{DefaultWorkspaceID: &other})
+	assert.ErrorContains(t, err, "workspace not found")
+}
+
+func TestUserService_ChangePassword_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+
+	service := NewUserService(testDB.Queries())
+	ctx := context.Background()
+
+	user, err := service.Register(ctx, "rotate@example.com", "correct horse 42")
+	require.NoError(t, err)
+
+	var tokenIDs []uuid.UUID
+	for _, name := range []string{"laptop", "phone", "tablet"} {
+		token, err := testDB.Queries().CreateAPIToken(ctx, db.CreateAPITokenParams{
+			UserID:    pgconv.UUIDToPg(user.ID),
+			TokenHash: "hash-" + name,
+			Name:      name,
+		})
+		require.NoError(t, err)
+		tokenIDs = append(tokenIDs, pgconv.PgToUUID(token.ID))
+	}
+
+	countTokens := func() int {
+		var n int
+		err := testDB.Conn().QueryRow(ctx, "SELECT COUNT(*) FROM api_tokens WHERE user_id = $1", user.ID).Scan(&n)
+		require.NoError(t, err)
+		return n
+	}
+
+	t.Run("wrong current password is rejected", func(t *testing.T) {
+		_, err := service.ChangePassword(ctx, user.ID, tokenIDs[0], "wrong horse 42", "", "battery staple 7")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid current password")
+		assert.Equal(t, 3, countTokens())
+	})
+
+	t.Run("change keeps only the current token", func(t *testing.T) {
+		revoked, err := service.ChangePassword(ctx, user.ID, tokenIDs[0], "correct horse 42", "", "battery staple 7")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), revoked)
+		assert.Equal(t, 1, countTokens())
+
+		_, err = service.Authenticate(ctx, "rotate@example.com", "battery staple 7")
+		assert.NoError(t, err)
+	})
+
+	t.Run("sign out everywhere revokes the rest", func(t *testing.T) {
+		revoked, err := service.RevokeAllTokens(ctx, user.ID, nil, "test")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), revoked)
+		assert.Equal(t, 0, countTokens())
+	})
+}
+
+func TestUserService_PasswordReset_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+
+	mailer := &recordingSender{}
+	service := NewUserService(testDB.Queries()).WithMailer(mailer, "https://noture.test")
+	ctx := context.Background()
+
+	user, err := service.Register(ctx, "forgot@example.com", "correct horse 42")
+	require.NoError(t, err)
+	_, err = testDB.Queries().CreateAPIToken(ctx, db.CreateAPITokenParams{
+		UserID:    pgconv.UUIDToPg(user.ID),
+		TokenHash: "hash-laptop",
+		Name:      "laptop",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, service.RequestPasswordReset(ctx, "nobody@example.com"))
+	assert.Empty(t, mailer.sent, "unknown addresses get no email")
+
+	resetCode := regexp.MustCompile(`(?m)^([0-9a-f]{64})$`)
+	require.NoError(t, service.RequestPasswordReset(ctx, "Forgot@Example.com"))
+	require.Len(t, mailer.sent, 1)
+	assert.Equal(t, "forgot@example.com", mailer.sent[0].To)
+	first := resetCode.FindStringSubmatch(mailer.sent[0].Body)
+	require.NotNil(t, first)
+
+	require.NoError(t, service.RequestPasswordReset(ctx, "forgot@example.com"))
+	code := resetCode.FindStringSubmatch(mailer.sent[1].Body)
+	require.NotNil(t, code)
+
+	assert.ErrorContains(t, service.ResetPassword(ctx, first[1], "battery staple 7"), "invalid or expired reset code")
+	assert.ErrorContains(t, service.ResetPassword(ctx, code[1], "short"), "password must")
+	require.NoError(t, service.ResetPassword(ctx, code[1], "battery staple 7"))
+	assert.ErrorContains(t, service.ResetPassword(ctx, code[1], "battery staple 8"), "invalid or expired reset code")
+
+	_, err = service.Authenticate(ctx, "forgot@example.com", "battery staple 7")
+	require.NoError(t, err)
+	var tokens int
+	require.NoError(t, testDB.Conn().QueryRow(ctx, "SELECT COUNT(*) FROM api_tokens WHERE user_id = $1", user.ID).Scan(&tokens))
+	assert.Zero(t, tokens)
+}
+
+func TestUserService_FirstPassword_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+
+	mailer := &recordingSender{}
+	service := NewUserService(testDB.Queries()).WithMailer(mailer, "https://noture.test")
+	ctx := context.Background()
+
+	row, err := testDB.Queries().CreateUser(ctx, db.CreateUserParams{
+		Email:        "oauth@example.com",
+		PasswordHash: "",
+		Tier:         db.UserTierFree,
+	})
+	require.NoError(t, err)
+	userID := pgconv.PgToUUID(row.ID)
+	token, err := testDB.Queries().CreateAPIToken(ctx, db.CreateAPITokenParams{
+		UserID:    row.ID,
+		TokenHash: "hash-laptop",
+		Name:      "laptop",
+	})
+	require.NoError(t, err)
+	current := pgconv.PgToUUID(token.ID)
+
+	_, err = service.ChangePassword(ctx, userID, current, "", "", "battery staple 7")
+	assert.ErrorContains(t, err, "code required", "a token alone cannot add a password")
+
+	require.NoError(t, service.RequestPasswordCode(ctx, userID))
+	require.Len(t, mailer.sent, 1)
+	assert.Equal(t, "oauth@example.com", mailer.sent[0].To)
+	code := regexp.MustCompile(`(?m)^([0-9a-f]{64})$`).FindStringSubmatch(mailer.sent[0].Body)
+	require.NotNil(t, code)
+
+	_, err = service.ChangePassword(ctx, userID, current, "", code[1]+"0", "battery staple 7")
+	assert.ErrorContains(t, err, "invalid or expired code")
+	_, err = service.ChangePassword(ctx, userID, current, "", code[1], "battery staple 7")
+	require.NoError(t, err)
+	_, err = service.Authenticate(ctx, "oauth@example.com", "battery staple 7")
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, service.RequestPasswordCode(ctx, userID), "cannot send a code")
+}
+
+func TestUserService_EmailVerification_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+
+	mailer := &recordingSender{}
+	verifier := NewEmailVerifier(testDB.Queries(), mailer, auth.NewSigner([]byte("test-signing-key-0123456789abcdef")), "https://noture.test")
+	service := NewUserService(testDB.Queries()).WithEmailVerifier(verifier)
+	ctx := context.Background()
+
+	user, err := service.Register(ctx, "new@example.com", "correct horse 42")
+	require.NoError(t, err)
+	assert.False(t, user.EmailVerified)
+	assert.ErrorContains(t, requireVerifiedEmail(ctx, testDB.Queries(), user.ID), "access denied: email not verified")
+
+	verifyLink := regexp.MustCompile(`/auth/verify-email\?token=(\S+)`)
+	require.Len(t, mailer.sent, 1)
+	assert.Equal(t, "new@example.com", mailer.sent[0].To)
+	require.NoError(t, service.ResendVerification(ctx, user.ID))
+	require.Len(t, mailer.sent, 2)
+	link := verifyLink.FindStringSubmatch(mailer.sent[1].Body)
+	require.NotNil(t, link)
+	token, err := url.QueryUnescape(link[1])
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, service.VerifyEmail(ctx, token+"x"), "invalid verification link")
+	require.NoError(t, service.VerifyEmail(ctx, token))
+	require.NoError(t, service.VerifyEmail(ctx, token), "following a link twice is fine")
+
+	verified, err := service.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, verified.EmailVerified)
+	assert.NoError(t, requireVerifiedEmail(ctx, testDB.Queries(), user.ID))
+	assert.ErrorContains(t, service.ResendVerification(ctx, user.ID), "email already verified")
+}
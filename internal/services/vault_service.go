@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+)
+
+// vaultEventPatterns are the events that change a vault's files.
+var vaultEventPatterns = []string{"file.%", "folder.%"}
+
+// VaultService is the sync surface of the Obsidian plugin, which treats a
+// workspace as a vault. A plugin reads the vault manifest once, then pulls
+// deltas from the workspace's event stream and pushes its own changes in
+// small batches. Pushes never overwrite an edit the plugin has not seen:
+// the conflicting version is kept as a conflict copy instead.
+type VaultService struct {
+	queries *db.Queries
+	files   *FileService
+	log     *logger.Logger
+}
+
+// NewVaultService reads and writes files through files.
+func NewVaultService(queries *db.Queries, files *FileService) *VaultService {
+	return &VaultService{
+		queries: queries,
+		files:   files,
+		log:     logger.New(),
+	}
+}
+
+// GetManifest returns every file of the workspace and the cursor to pull
+// later changes from.
+func (s *VaultService) GetManifest(ctx context.Context, workspaceID, userID uuid.UUID) (*domain.VaultManifest, error) {
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	// The cursor is read first, so changes made while the files are
+	// listed are pulled again rather than missed.
+	cursor, err := retryRead(ctx, "get_latest_workspace_event_id", func() (int64, error) {
+		return s.queries.GetLatestWorkspaceEventID(ctx, pgconv.UUIDToPg(workspaceID))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest event: %w", err)
+	}
+	rows, err := retryRead(ctx, "list_file_manifest", func() ([]db.ListFileManifestRow, error) {
+		return s.queries.ListFileManifest(ctx, db.ListFileManifestParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	manifest := &domain.VaultManifest{
+		Revision:     workspace.Revision,
+		Cursor:       cursor,
+		Files:        make([]domain.VaultFile, len(rows)),
+		Limits:       domain.DefaultVaultLimits,
+		ConflictCopy: domain.VaultConflictCopyPattern,
+	}
+	for i, row := range rows {
+		manifest.Files[i] = domain.VaultFile{
+			Path:        row.FilePath,
+			ContentHash: row.ContentHash,
+			Size:        row.SizeBytes,
+			MTime:       pgconv.PgToTime(row.LastModified),
+			Attachment:  domain.VaultAttachment(pgconv.PgToString(row.MimeType)),
+		}
+	}
+	return manifest, nil
+}
+
+// GetChanges returns the state now of every path changed by the events
+// after opts.Since, reading at most opts.Limit events. Notes are inlined
+// in path order while they fit opts.InlineBytes.
+func (s *VaultService) GetChanges(ctx context.Context, workspaceID, userID uuid.UUID, opts domain.VaultPullOptions) (*domain.VaultChanges, error) {
+	if err := opts.Normalize(); err != nil {
+		return nil, err
+	}
+
+	_, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := retryRead(ctx, "list_workspace_events", func() ([]db.WorkspaceEvent, error) {
+		return s.queries.ListWorkspaceEvents(ctx, db.ListWorkspaceEventsParams{
+			WorkspaceID:  pgconv.UUIDToPg(workspaceID),
+			AfterID:      opts.Since,
+			TypePatterns: vaultEventPatterns,
+			PageSize:     int32(opts.Limit),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	result := &domain.VaultChanges{
+		Cursor:  opts.Since,
+		HasMore: len(events) == opts.Limit,
+		Changes: []domain.VaultChange{},
+	}
+	changed := make(map[string]bool)
+	deletedFolders := make(map[string]bool)
+	for _, event := range events {
+		result.Cursor = event.ID
+		path := pgconv.PgToString(event.FilePath)
+		switch event.EventType {
+		case domain.EventFolderDeleted:
+			if !deletedFolders[path] {
+				deletedFolders[path] = true
+				result.DeletedFolders = append(result.DeletedFolders, path)
+			}
+			continue
+		case domain.EventFileMoved:
+			var data struct {
+				From string `json:"from"`
+			}
+			if json.Unmarshal(event.Payload, &data) == nil && data.From != "" {
+				changed[data.From] = true
+			}
+		}
+		if path != "" {
+			changed[path] = true
+		}
+	}
+	if len(changed) == 0 {
+		return result, nil
+	}
+
+	paths := make([]string, 0, len(changed))
+	for path := range changed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	rows, err := retryRead(ctx, "lookup_files", func() ([]db.LookupFilesRow, error) {
+		return s.queries.LookupFiles(ctx, db.LookupFilesParams{
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			FilePaths:   paths,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up files: %w", err)
+	}
+	byPath := make(map[string]db.LookupFilesRow, len(rows))
+	for _, row := range rows {
+		byPath[row.FilePath] = row
+	}
+
+	budget := opts.InlineBytes
+	for _, path := range paths {
+		row, ok := byPath[path]
+		if !ok {
+			result.Changes = append(result.Changes, domain.VaultChange{Path: path, Deleted: true})
+			continue
+		}
+
+		mtime := pgconv.PgToTime(row.LastModified)
+		change := domain.VaultChange{
+			Path:        path,
+			ContentHash: row.ContentHash,
+			Size:        row.SizeBytes,
+			MTime:       &mtime,
+			Attachment:  domain.VaultAttachment(pgconv.PgToString(row.MimeType)),
+		}
+		if !change.Attachment && row.SizeBytes > 0 && row.SizeBytes <= budget {
+			content, err := s.files.blobs.Get(ctx, row.ContentHash)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			change.Content = content
+			budget -= row.SizeBytes
+		}
+		result.Changes = append(result.Changes, change)
+	}
+	return result, nil
+}
+
+// Push applies each change of req on its own, in order, and reports what
+// became of it. Conflicts and changes the workspace's limits refuse are
+// reported rather than failing the push; any other error stops it.
+func (s *VaultService) Push(ctx context.Context, workspaceID, userID uuid.UUID, req domain.VaultPushRequest) (*domain.VaultPushResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	if workspace.ArchivedAt.Valid {
+		return nil, fmt.Errorf("workspace is archived")
+	}
+
+	response := &domain.VaultPushResponse{
+		Revision: workspace.Revision,
+		Results:  make([]domain.VaultPushResult, len(req.Changes)),
+	}
+	for i, change := range req.Changes {
+		result, revision, err := s.pushChange(ctx, workspaceID, userID, req.ClientID, change)
+		if err != nil {
+			return nil, err
+		}
+		response.Results[i] = result
+		response.Revision = max(response.Revision, revision)
+	}
+	return response, nil
+}
+
+// pushChange applies one change as a save-set of its own and returns its
+// result with the workspace revision it wrote, or 0 if it wrote nothing.
+func (s *VaultService) pushChange(ctx context.Context, workspaceID, userID uuid.UUID, clientID string, change domain.SaveSetOperation) (domain.VaultPushResult, int64, error) {
+	result := domain.VaultPushResult{Op: change.Op, FilePath: change.FilePath}
+
+	applied, err := s.files.ApplySaveSet(ctx, workspaceID, userID, domain.SaveSetRequest{
+		Operations: []domain.SaveSetOperation{change},
+		ClientID:   clientID,
+	})
+	var conflict *domain.SaveSetConflictError
+	switch {
+	case err == nil:
+		file := applied.Files[0]
+		result.Status = domain.VaultPushApplied
+		if file.Unchanged {
+			result.Status = domain.VaultPushUnchanged
+		}
+		result.FilePath = file.FilePath
+		result.ContentHash = file.ContentHash
+		return result, applied.Revision, nil
+	case errors.As(err, &conflict):
+		return s.resolveConflict(ctx, workspaceID, userID, clientID, change, conflict.Conflicts[0])
+	case rejectedUpload(err) || strings.HasPrefix(err.Error(), "invalid"):
+		result.Status = domain.VaultPushRejected
+		result.Error = err.Error()
+		return result, 0, nil
+	default:
+		return result, 0, err
+	}
+}
+
+// resolveConflict settles a change made on a version the workspace no
+// longer has. A retried change that already took effect is unchanged. A
+// write is saved as a conflict copy beside the workspace's version; a
+// delete or move is dropped.
+func (s *VaultService) resolveConflict(ctx context.Context, workspaceID, userID uuid.UUID, clientID string, change domain.SaveSetOperation, conflict domain.SaveSetConflict) (domain.VaultPushResult, int64, error) {
+	result := domain.VaultPushResult{
+		Op:          change.Op,
+		FilePath:    change.FilePath,
+		Status:      domain.VaultPushConflict,
+		CurrentHash: conflict.CurrentHash,
+	}
+
+	switch change.Op {
+	case domain.SaveOpWrite:
+		hash := storage.Hash(change.Content)
+		result.ContentHash = hash
+		if conflict.CurrentHash == hash {
+			result.Status = domain.VaultPushUnchanged
+			return result, 0, nil
+		}
+
+		copied := change
+		copied.FilePath = domain.ConflictCopyPath(change.FilePath, hash)
+		copied.BaseHash = nil
+		copied.NoteID = ""
+		applied, err := s.files.ApplySaveSet(ctx, workspaceID, userID, domain.SaveSetRequest{
+			Operations: []domain.SaveSetOperation{copied},
+			ClientID:   clientID,
+		})
+		if err != nil {
+			if rejectedUpload(err) {
+				result.Status = domain.VaultPushRejected
+				result.Error = err.Error()
+				return result, 0, nil
+			}
+			return result, 0, err
+		}
+
+		s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "").
+			Info("Saved conflicting vault push as a copy", "path", change.FilePath, "copy", copied.FilePath)
+		result.ConflictPath = copied.FilePath
+		return result, applied.Revision, nil
+	case domain.SaveOpDelete:
+		if conflict.CurrentHash == "" {
+			result.Status = domain.VaultPushUnchanged
+		}
+	}
+	return result, 0, nil
+}
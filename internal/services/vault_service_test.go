@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultService_Sync_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	files := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	service := NewVaultService(testDB.Queries(), files)
+	ctx := context.Background()
+
+	upload := func(path string, content []byte) {
+		_, err := files.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      content,
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+	upload("a.md", []byte("# A"))
+	upload("img.png", []byte("\x89PNG\r\n\x1a\n\x00\x00"))
+
+	manifest, err := service.GetManifest(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 2)
+	assert.Equal(t, "a.md", manifest.Files[0].Path)
+	assert.False(t, manifest.Files[0].Attachment)
+	assert.True(t, manifest.Files[1].Attachment)
+	assert.Positive(t, manifest.Cursor)
+	assert.Equal(t, domain.DefaultVaultLimits, manifest.Limits)
+
+	base := storage.Hash([]byte("# A"))
+	empty := ""
+
+	t.Run("changes are applied one by one", func(t *testing.T) {
+		response, err := service.Push(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.VaultPushRequest{
+			Changes: []domain.SaveSetOperation{
+				{Op: domain.SaveOpWrite, FilePath: "a.md", Content: []byte("# A, desktop"), BaseHash: &base},
+				{Op: domain.SaveOpWrite, FilePath: "b.md", Content: []byte("# B"), BaseHash: &empty},
+				{Op: domain.SaveOpDelete, FilePath: "missing.md"},
+			},
+		})
+		require.NoError(t, err)
+		statuses := []string{response.Results[0].Status, response.Results[1].Status, response.Results[2].Status}
+		assert.Equal(t, []string{domain.VaultPushApplied, domain.VaultPushApplied, domain.VaultPushUnchanged}, statuses)
+		assert.Greater(t, response.Revision, manifest.Revision)
+	})
+
+	t.Run("a conflicting write is saved as a copy", func(t *testing.T) {
+		response, err := service.Push(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.VaultPushRequest{
+			Changes: []domain.SaveSetOperation{
+				{Op: domain.SaveOpWrite, FilePath: "a.md", Content: []byte("# A, phone"), BaseHash: &base},
+				{Op: domain.SaveOpWrite, FilePath: "a.md.bak", Content: []byte("# A, desktop"), BaseHash: &empty},
+			},
+		})
+		require.NoError(t, err)
+		result := response.Results[0]
+		assert.Equal(t, domain.VaultPushConflict, result.Status)
+		assert.Equal(t, storage.Hash([]byte("# A, desktop")), result.CurrentHash)
+		assert.Equal(t, domain.ConflictCopyPath("a.md", storage.Hash([]byte("# A, phone"))), result.ConflictPath)
+		assert.Equal(t, domain.VaultPushApplied, response.Results[1].Status, "the rest of the batch is applied")
+
+		kept, err := files.GetFileContent(ctx, testData.FreeWorkspaceID, "a.md", testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, "# A, desktop", string(kept.Content))
+		copied, err := files.GetFileContent(ctx, testData.FreeWorkspaceID, result.ConflictPath, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, "# A, phone", string(copied.Content))
+
+		// The same edit pushed again already took effect.
+		response, err = service.Push(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.VaultPushRequest{
+			Changes: []domain.SaveSetOperation{
+				{Op: domain.SaveOpWrite, FilePath: "a.md", Content: []byte("# A, desktop"), BaseHash: &base},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, domain.VaultPushUnchanged, response.Results[0].Status)
+	})
+
+	t.Run("pulls return each changed path once, as it is now", func(t *testing.T) {
+		upload("archive/old.md", []byte("# Old"))
+		_, err := files.DeleteFolder(ctx, testData.FreeWorkspaceID, "archive", testData.FreeUserID)
+		require.NoError(t, err)
+
+		changes, err := service.GetChanges(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.VaultPullOptions{Since: manifest.Cursor})
+		require.NoError(t, err)
+		assert.False(t, changes.HasMore)
+		assert.Equal(t, []string{"archive/"}, changes.DeletedFolders)
+
+		byPath := make(map[string]domain.VaultChange)
+		for _, change := range changes.Changes {
+			byPath[change.Path] = change
+		}
+		assert.Len(t, byPath, 5)
+		assert.Equal(t, "# A, desktop", string(byPath["a.md"].Content))
+		assert.Equal(t, "# B", string(byPath["b.md"].Content))
+		assert.True(t, byPath["archive/old.md"].Deleted)
+		assert.Contains(t, byPath, domain.ConflictCopyPath("a.md", storage.Hash([]byte("# A, phone"))))
+
+		again, err := service.GetChanges(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.VaultPullOptions{Since: changes.Cursor})
+		require.NoError(t, err)
+		assert.Empty(t, again.Changes)
+		assert.Equal(t, changes.Cursor, again.Cursor)
+	})
+
+	t.Run("pulls page and respect the inline budget", func(t *testing.T) {
+		changes, err := service.GetChanges(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.VaultPullOptions{Limit: 1})
+		require.NoError(t, err)
+		assert.True(t, changes.HasMore)
+		require.Len(t, changes.Changes, 1)
+
+		changes, err = service.GetChanges(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.VaultPullOptions{InlineBytes: 1})
+		require.NoError(t, err)
+		for _, change := range changes.Changes {
+			assert.Nil(t, change.Content, change.Path)
+		}
+	})
+
+	t.Run("viewers cannot push", func(t *testing.T) {
+		_, err := service.Push(ctx, testData.FreeWorkspaceID, testData.PremiumUserID, domain.VaultPushRequest{
+			Changes: []domain.SaveSetOperation{{Op: domain.SaveOpDelete, FilePath: "b.md"}},
+		})
+		assert.ErrorContains(t, err, "access denied")
+	})
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// WorkspaceCloneOperation is the operation kind that copies the files of
+// one workspace into a new one.
+const WorkspaceCloneOperation = "workspace_clone"
+
+// workspaceCloneBatchSize is how many source files a clone lists at once.
+const workspaceCloneBatchSize = 100
+
+type workspaceCloneParams struct {
+	SourceID        uuid.UUID `json:"source_workspace_id"`
+	IncludeVersions bool      `json:"include_versions"`
+}
+
+// workspaceCloneState is the path of the last file copied, so a clone
+// taken over by another worker resumes after it.
+type workspaceCloneState struct {
+	LastPath string `json:"last_path"`
+}
+
+// CloneWorkspace creates a workspace for userID and queues an operation
+// copying the files of sourceID into it. Any member of the source may
+// clone it. The copy counts against the user's workspace limit, and the
+// source's current content must fit the new workspace's storage limit.
+func (s *GalleryService) CloneWorkspace(ctx context.Context, sourceID uuid.UUID, req domain.CloneWorkspaceRequest, userID uuid.UUID, userTier domain.UserTier) (*domain.Workspace, *domain.Operation, error) {
+	source, err := s.workspaces.GetWorkspaceByID(ctx, sourceID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.Name = strings.TrimSpace(req.Name); req.Name == "" {
+		req.Name = source.Name + " (copy)"
+	}
+
+	workspace, err := s.workspaces.CreateWorkspace(ctx, domain.CreateWorkspaceRequest{Name: req.Name}, userID, userTier)
+	if err != nil {
+		return nil, nil, err
+	}
+	discard := func() {
+		if cleanupErr := s.workspaces.DeleteWorkspace(ctx, workspace.ID, userID); cleanupErr != nil {
+			s.log.WithContext(ctx).WithError(cleanupErr).Error("Failed to remove workspace of abandoned clone", "workspace_id", workspace.ID)
+		}
+	}
+
+	if source.StorageUsedBytes > workspace.StorageLimitBytes {
+		discard()
+		return nil, nil, fmt.Errorf("storage limit exceeded: need %d bytes, limit %d bytes",
+			source.StorageUsedBytes, workspace.StorageLimitBytes)
+	}
+
+	params := workspaceCloneParams{SourceID: sourceID, IncludeVersions: req.IncludeVersions}
+	op, err := s.operations.Enqueue(ctx, WorkspaceCloneOperation, userID, &workspace.ID, params, int32(source.FileCount))
+	if err != nil {
+		discard()
+		return nil, nil, err
+	}
+	return workspace, op, nil
+}
+
+// runWorkspaceClone copies the source's files one per step, in path order.
+// Files added to the source while the clone runs are copied if their path
+// sorts after the last one copied. If a file cannot be copied the
+// half-filled workspace is deleted again.
+func (s *GalleryService) runWorkspaceClone(ctx context.Context, run *OperationRun) (any, error) {
+	var params workspaceCloneParams
+	if err := json.Unmarshal(run.Params, &params); err != nil {
+		return nil, fmt.Errorf("invalid clone params: %w", err)
+	}
+	if run.WorkspaceID == nil {
+		return nil, fmt.Errorf("invalid clone operation: no workspace")
+	}
+	var state workspaceCloneState
+	if len(run.State) > 0 {
+		if err := json.Unmarshal(run.State, &state); err != nil {
+			return nil, fmt.Errorf("invalid clone state: %w", err)
+		}
+	}
+	workspaceID := *run.WorkspaceID
+	log := s.log.WithContext(ctx).WithUser(run.UserID.String(), "").WithWorkspace(workspaceID.String(), "")
+
+	done, total := run.StepsDone, run.StepsTotal
+	for {
+		page, err := s.files.ListFilesPage(ctx, params.SourceID, run.UserID, domain.FileListOptions{
+			Sort:   domain.SortByPath,
+			Cursor: state.LastPath,
+			Limit:  workspaceCloneBatchSize,
+		})
+		if err != nil {
+			return nil, s.abandonClone(ctx, run, err)
+		}
+
+		for _, file := range page.Files {
+			if err := s.cloneFile(ctx, workspaceID, file, params.IncludeVersions, run.UserID); err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				log.WithError(err).Error("Failed to copy file", "source_workspace_id", params.SourceID, "file_path", file.FilePath)
+				return nil, s.abandonClone(ctx, run, fmt.Errorf("failed to copy file %s: %w", file.FilePath, err))
+			}
+			state.LastPath = file.FilePath
+			done++
+			total = max(total, done)
+			if err := run.Checkpoint(ctx, state, done, total); err != nil {
+				return nil, err
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+	}
+
+	log.Info("Workspace cloned", "source_workspace_id", params.SourceID, "files", done)
+	return map[string]any{"workspace_id": workspaceID, "files": done}, nil
+}
+
+// abandonClone deletes the clone's workspace and returns err.
+func (s *GalleryService) abandonClone(ctx context.Context, run *OperationRun, err error) error {
+	if cleanupErr := s.workspaces.DeleteWorkspace(ctx, *run.WorkspaceID, run.UserID); cleanupErr != nil {
+		s.log.WithContext(ctx).WithError(cleanupErr).Error("Failed to remove partially cloned workspace", "workspace_id", *run.WorkspaceID)
+	}
+	return err
+}
+
+// cloneFile uploads file to workspaceID: its current content or, with
+// versions, each version from the oldest. Versions a previous attempt
+// already copied are skipped, so a resumed clone does not repeat them.
+func (s *GalleryService) cloneFile(ctx context.Context, workspaceID uuid.UUID, file domain.FileInfo, includeVersions bool, userID uuid.UUID) error {
+	hashes := []string{file.ContentHash}
+	if includeVersions {
+		versions, err := s.files.queries.GetFileVersions(ctx, db.GetFileVersionsParams{
+			FileID: pgconv.UUIDToPg(file.ID),
+			Limit:  math.MaxInt32,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get file versions: %w", err)
+		}
+		copied, err := s.clonedVersions(ctx, workspaceID, file.FilePath)
+		if err != nil {
+			return err
+		}
+		hashes = hashes[:0]
+		for i := len(versions) - 1 - copied; i >= 0; i-- {
+			hashes = append(hashes, versions[i].ContentHash)
+		}
+		// The current content is the newest version; uploading it again
+		// is a no-op unless the history was pruned from under us.
+		hashes = append(hashes, file.ContentHash)
+	}
+
+	for _, hash := range hashes {
+		content, err := s.files.blobs.Get(ctx, hash)
+		if err != nil {
+			return fmt.Errorf("failed to read file content: %w", err)
+		}
+		_, err = s.files.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  workspaceID,
+			FilePath:     file.FilePath,
+			Content:      content,
+			LastModified: file.LastModified,
+		}, userID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clonedVersions counts the versions of filePath already in the clone.
+func (s *GalleryService) clonedVersions(ctx context.Context, workspaceID uuid.UUID, filePath string) (int, error) {
+	existing, err := s.files.queries.GetFile(ctx, db.GetFileParams{
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+		FilePath:    filePath,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get file: %w", err)
+	}
+	versions, err := s.files.queries.GetFileVersions(ctx, db.GetFileVersionsParams{
+		FileID: existing.ID,
+		Limit:  math.MaxInt32,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get file versions: %w", err)
+	}
+	return len(versions), nil
+}
@@ -1,31 +1,44 @@
 package services
 
 import (
+	"archive/zip"
 	"context"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/duckonomy/noture/internal/db"
 	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/events"
+	"github.com/duckonomy/noture/internal/storage"
 	"github.com/duckonomy/noture/pkg/logger"
 	"github.com/duckonomy/noture/pkg/pgconv"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type WorkspaceService struct {
 	queries *db.Queries
+	blobs   storage.Backend
+	events  *events.Bus
 	log     *logger.Logger
 }
 
-func NewWorkspaceService(queries *db.Queries) *WorkspaceService {
+func NewWorkspaceService(queries *db.Queries, blobs storage.Backend) *WorkspaceService {
 	return &WorkspaceService{
 		queries: queries,
+		blobs:   blobs,
 		log:     logger.New(),
 	}
 }
 
+// WithEvents publishes WorkspaceCreated on bus.
+func (s *WorkspaceService) WithEvents(bus *events.Bus) *WorkspaceService {
+	s.events = bus
+	return s
+}
+
 func (s *WorkspaceService) CreateWorkspace(ctx context.Context, req domain.CreateWorkspaceRequest, userID uuid.UUID, userTier domain.UserTier) (*domain.Workspace, error) {
-	log := s.log.WithUser(userID.String(), "")
+	log := s.log.WithContext(ctx).WithUser(userID.String(), "")
 	log.Info("Creating new workspace", "name", req.Name, "user_tier", userTier)
 
 	existingWorkspaces, err := s.queries.GetWorkspacesByUser(ctx, pgconv.UUIDToPg(userID))
@@ -43,7 +56,12 @@ func (s *WorkspaceService) CreateWorkspace(ctx context.Context, req domain.Creat
 		return nil, fmt.Errorf("workspace limit reached for %s tier: %d/%d", userTier, len(existingWorkspaces), maxWorkspaces)
 	}
 
-	storageLimit := userTier.GetStorageLimit()
+	user, err := s.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		log.WithError(err).Error("Failed to get user")
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	storageLimit := workspaceStorageLimit(userTier, user.StorageLimitBytes)
 
 	workspace, err := s.queries.CreateWorkspace(ctx, db.CreateWorkspaceParams{
 		UserID:            pgconv.UUIDToPg(userID),
@@ -55,15 +73,12 @@ func (s *WorkspaceService) CreateWorkspace(ctx context.Context, req domain.Creat
 		return nil, fmt.Errorf("failed to create workspace: %w", err)
 	}
 
-	workspaceResult := &domain.Workspace{
-		ID:                pgconv.PgToUUID(workspace.ID),
-		UserID:            pgconv.PgToUUID(workspace.UserID),
-		Name:              workspace.Name,
-		StorageLimitBytes: workspace.StorageLimitBytes,
-		StorageUsedBytes:  pgconv.PgToInt64(workspace.StorageUsedBytes),
-		CreatedAt:         pgconv.PgToTime(workspace.CreatedAt),
-		UpdatedAt:         pgconv.PgToTime(workspace.UpdatedAt),
-	}
+	workspaceResult := toDomainWorkspace(workspace)
+	s.events.Publish(ctx, events.WorkspaceCreated{
+		WorkspaceID:   workspaceResult.ID,
+		OwnerID:       userID,
+		WorkspaceName: workspaceResult.Name,
+	})
 
 	log.LogWorkspaceOperation("create", workspaceResult.ID.String(), workspaceResult.Name)
 	log.Info("Workspace created successfully",
@@ -74,26 +89,36 @@ func (s *WorkspaceService) CreateWorkspace(ctx context.Context, req domain.Creat
 }
 
 func (s *WorkspaceService) GetWorkspacesByUser(ctx context.Context, userID uuid.UUID) ([]domain.Workspace, error) {
-	log := s.log.WithUser(userID.String(), "")
+	log := s.log.WithContext(ctx).WithUser(userID.String(), "")
 	log.Debug("Fetching workspaces for user")
 
-	dbWorkspaces, err := s.queries.GetWorkspacesByUser(ctx, pgconv.UUIDToPg(userID))
+	rows, err := retryRead(ctx, "list_member_workspaces", func() ([]db.ListMemberWorkspacesRow, error) {
+		return s.queries.ListMemberWorkspaces(ctx, pgconv.UUIDToPg(userID))
+	})
 	if err != nil {
 		log.WithError(err).Error("Failed to fetch workspaces from database")
 		return nil, fmt.Errorf("failed to get workspaces: %w", err)
 	}
 
-	workspaces := make([]domain.Workspace, len(dbWorkspaces))
-	for i, ws := range dbWorkspaces {
-		workspaces[i] = domain.Workspace{
-			ID:                pgconv.PgToUUID(ws.ID),
-			UserID:            pgconv.PgToUUID(ws.UserID),
-			Name:              ws.Name,
-			StorageLimitBytes: ws.StorageLimitBytes,
-			StorageUsedBytes:  pgconv.PgToInt64(ws.StorageUsedBytes),
-			CreatedAt:         pgconv.PgToTime(ws.CreatedAt),
-			UpdatedAt:         pgconv.PgToTime(ws.UpdatedAt),
-		}
+	workspaces := make([]domain.Workspace, len(rows))
+	for i, row := range rows {
+		workspaces[i] = *toDomainWorkspace(db.Workspace{
+			ID:                row.ID,
+			UserID:            row.UserID,
+			Name:              row.Name,
+			StorageLimitBytes: row.StorageLimitBytes,
+			StorageUsedBytes:  row.StorageUsedBytes,
+			CreatedAt:         row.CreatedAt,
+			UpdatedAt:         row.UpdatedAt,
+			FileCount:         row.FileCount,
+			ArchivedAt:        row.ArchivedAt,
+			Icon:              row.Icon,
+			Color:             row.Color,
+			Description:       row.Description,
+			Revision:          row.Revision,
+		})
+		workspaces[i].Role = domain.WorkspaceRole(row.Role)
+		workspaces[i].SortOrder = row.SortOrder
 	}
 
 	log.Info("Successfully retrieved workspaces", "count", len(workspaces))
@@ -101,30 +126,23 @@ func (s *WorkspaceService) GetWorkspacesByUser(ctx context.Context, userID uuid.
 }
 
 func (s *WorkspaceService) GetWorkspaceByID(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID) (*domain.Workspace, error) {
-	log := s.log.WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "")
+	log := s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "")
 	log.Debug("Fetching workspace by ID")
 
-	workspace, err := s.queries.GetWorkspaceByID(ctx, pgconv.UUIDToPg(workspaceID))
+	workspace, role, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
 	if err != nil {
-		log.WithError(err).Error("Workspace not found", "workspace_id", workspaceID)
-		return nil, fmt.Errorf("workspace not found: %w", err)
+		log.WithError(err).Warn("Workspace not accessible", "workspace_id", workspaceID)
+		return nil, err
 	}
 
-	if pgconv.PgToUUID(workspace.UserID) != userID {
-		log.Warn("Access denied: workspace belongs to different user",
-			"workspace_owner", pgconv.PgToUUID(workspace.UserID),
-			"requesting_user", userID)
-		return nil, fmt.Errorf("access denied: workspace belongs to different user")
-	}
-
-	result := &domain.Workspace{
-		ID:                pgconv.PgToUUID(workspace.ID),
-		UserID:            pgconv.PgToUUID(workspace.UserID),
-		Name:              workspace.Name,
-		StorageLimitBytes: workspace.StorageLimitBytes,
-		StorageUsedBytes:  pgconv.PgToInt64(workspace.StorageUsedBytes),
-		CreatedAt:         pgconv.PgToTime(workspace.CreatedAt),
-		UpdatedAt:         pgconv.PgToTime(workspace.UpdatedAt),
+	result := toDomainWorkspace(workspace)
+	result.Role = role
+	result.SortOrder, err = s.queries.GetWorkspaceSortOrder(ctx, db.GetWorkspaceSortOrderParams{
+		UserID:      pgconv.UUIDToPg(userID),
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sort order: %w", err)
 	}
 
 	log.Debug("Successfully retrieved workspace", "workspace_name", result.Name)
@@ -132,38 +150,40 @@ func (s *WorkspaceService) GetWorkspaceByID(ctx context.Context, workspaceID uui
 }
 
 func (s *WorkspaceService) GetWorkspaceStorageInfo(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID) (*domain.WorkspaceStorageInfo, error) {
-	log := s.log.WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "")
+	log := s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "")
 	log.Debug("Fetching workspace storage information")
 
-	workspace, err := s.queries.GetWorkspaceByID(ctx, pgconv.UUIDToPg(workspaceID))
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
 	if err != nil {
-		log.WithError(err).Error("Workspace not found for storage info request")
-		return nil, fmt.Errorf("workspace not found: %w", err)
+		log.WithError(err).Warn("Storage info not accessible")
+		return nil, err
 	}
 
-	if pgconv.PgToUUID(workspace.UserID) != userID {
-		log.Warn("Access denied: storage info request for workspace belonging to different user",
-			"workspace_owner", pgconv.PgToUUID(workspace.UserID))
-		return nil, fmt.Errorf("access denied: workspace belongs to different user")
+	owner, err := retryRead(ctx, "get_user", func() (db.User, error) {
+		return s.queries.GetUserByID(ctx, workspace.UserID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspace owner: %w", err)
 	}
 
-	storageInfo, err := s.queries.GetWorkspaceStorageUsage(ctx, pgconv.UUIDToPg(workspaceID))
+	storageInfo, err := retryRead(ctx, "get_workspace_storage_usage", func() (db.GetWorkspaceStorageUsageRow, error) {
+		return s.queries.GetWorkspaceStorageUsage(ctx, pgconv.UUIDToPg(workspaceID))
+	})
 	if err != nil {
 		log.WithError(err).Error("Failed to get storage usage from database")
 		return nil, fmt.Errorf("failed to get storage usage: %w", err)
 	}
 
-	var actualUsed int64
-	if numeric, ok := storageInfo.ActualStorageUsed.(pgtype.Numeric); ok && numeric.Valid {
-		int64Val, _ := numeric.Int64Value()
-		actualUsed = int64Val.Int64
-	}
-
+	// The counters are maintained in the same transaction as every file
+	// write, so they are the actual usage without scanning the files table.
 	result := &domain.WorkspaceStorageInfo{
 		StorageLimitBytes: storageInfo.StorageLimitBytes,
-		StorageUsedBytes:  pgconv.PgToInt64(storageInfo.StorageUsedBytes),
+		StorageUsedBytes:  storageInfo.StorageUsedBytes,
 		FileCount:         storageInfo.FileCount,
-		ActualStorageUsed: actualUsed,
+		ActualStorageUsed: storageInfo.StorageUsedBytes,
+		VersionRetention:  domain.UserTier(owner.Tier).GetVersionRetention(),
+		FileLimits:        domain.UserTier(storageInfo.Tier).GetFileLimits(),
+		OrganizationID:    pgconv.PgToUUIDPtr(storageInfo.OrganizationID),
 	}
 
 	log.Info("Retrieved workspace storage information",
@@ -174,3 +194,182 @@ func (s *WorkspaceService) GetWorkspaceStorageInfo(ctx context.Context, workspac
 
 	return result, nil
 }
+
+// SetWorkspaceArchived archives or restores a workspace. Archived workspaces
+// stay readable but reject writes.
+func (s *WorkspaceService) SetWorkspaceArchived(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, archived bool) (*domain.Workspace, error) {
+	log := s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "")
+
+	var archivedAt *time.Time
+	if archived {
+		now := time.Now()
+		archivedAt = &now
+	}
+
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	workspace, err := s.queries.SetWorkspaceArchived(ctx, db.SetWorkspaceArchivedParams{
+		ID:         pgconv.UUIDToPg(workspaceID),
+		ArchivedAt: pgconv.TimePtrToPg(archivedAt),
+	})
+	if err != nil {
+		log.WithError(err).Error("Failed to update workspace archive state", "archived", archived)
+		return nil, fmt.Errorf("workspace not found: %w", err)
+	}
+
+	if archived {
+		log.LogWorkspaceOperation("archive", workspaceID.String(), workspace.Name)
+	} else {
+		log.LogWorkspaceOperation("unarchive", workspaceID.String(), workspace.Name)
+	}
+
+	return toDomainWorkspace(workspace), nil
+}
+
+// UpdateWorkspace changes display metadata. It does not touch updated_at,
+// which tracks content activity. Members other than the owner may only
+// change their own sort order.
+func (s *WorkspaceService) UpdateWorkspace(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, req domain.UpdateWorkspaceRequest) (*domain.Workspace, error) {
+	log := s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "")
+
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	minRole := domain.RoleViewer
+	if req.ChangesDisplay() {
+		minRole = domain.RoleOwner
+	}
+	workspace, role, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, minRole)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ChangesDisplay() {
+		workspace, err = s.queries.UpdateWorkspaceDisplay(ctx, db.UpdateWorkspaceDisplayParams{
+			ID:          pgconv.UUIDToPg(workspaceID),
+			Icon:        pgconv.StringPtrToPg(req.Icon),
+			Color:       pgconv.StringPtrToPg(req.Color),
+			Description: pgconv.StringPtrToPg(req.Description),
+		})
+		if err != nil {
+			log.WithError(err).Error("Failed to update workspace display metadata")
+			return nil, fmt.Errorf("workspace not found: %w", err)
+		}
+	}
+
+	if req.SortOrder != nil {
+		err = s.queries.SetWorkspaceSortOrder(ctx, db.SetWorkspaceSortOrderParams{
+			UserID:      pgconv.UUIDToPg(userID),
+			WorkspaceID: pgconv.UUIDToPg(workspaceID),
+			SortOrder:   *req.SortOrder,
+		})
+		if err != nil {
+			log.WithError(err).Error("Failed to update workspace sort order")
+			return nil, fmt.Errorf("failed to update sort order: %w", err)
+		}
+	}
+
+	log.LogWorkspaceOperation("update", workspaceID.String(), workspace.Name)
+
+	result := toDomainWorkspace(workspace)
+	result.Role = role
+	result.SortOrder, err = s.queries.GetWorkspaceSortOrder(ctx, db.GetWorkspaceSortOrderParams{
+		UserID:      pgconv.UUIDToPg(userID),
+		WorkspaceID: pgconv.UUIDToPg(workspaceID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sort order: %w", err)
+	}
+	return result, nil
+}
+
+func (s *WorkspaceService) DeleteWorkspace(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID) error {
+	log := s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "")
+
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleOwner)
+	if err != nil {
+		log.WithError(err).Warn("Delete not permitted")
+		return err
+	}
+
+	// Content is left to the unreferenced blob collector.
+	err = s.queries.DeleteWorkspace(ctx, pgconv.UUIDToPg(workspaceID))
+	if err != nil {
+		log.WithError(err).Error("Failed to delete workspace")
+		return fmt.Errorf("failed to delete workspace: %w", err)
+	}
+
+	log.LogWorkspaceOperation("delete", workspaceID.String(), workspace.Name)
+	return nil
+}
+
+// ExportWorkspace writes every file of the workspace to w as a zip archive,
+// keeping workspace-relative paths and modification times.
+func (s *WorkspaceService) ExportWorkspace(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, w io.Writer) error {
+	log := s.log.WithContext(ctx).WithUser(userID.String(), "").WithWorkspace(workspaceID.String(), "")
+
+	workspace, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	if _, err := s.writeFiles(ctx, zw, "", workspaceID); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish export: %w", err)
+	}
+
+	log.LogWorkspaceOperation("export", workspaceID.String(), workspace.Name)
+	return nil
+}
+
+// writeFiles adds every file of the workspace to zw under prefix and
+// returns the files written.
+func (s *WorkspaceService) writeFiles(ctx context.Context, zw *zip.Writer, prefix string, workspaceID uuid.UUID) ([]db.ListFilesRow, error) {
+	files, err := s.queries.ListFiles(ctx, pgconv.UUIDToPg(workspaceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	for _, file := range files {
+		entry, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     prefix + file.FilePath,
+			Method:   zip.Deflate,
+			Modified: pgconv.PgToTime(file.LastModified),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to export: %w", file.FilePath, err)
+		}
+		content, err := s.blobs.Get(ctx, file.ContentHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.FilePath, err)
+		}
+		if _, err := entry.Write(content); err != nil {
+			return nil, fmt.Errorf("failed to write %s to export: %w", file.FilePath, err)
+		}
+	}
+	return files, nil
+}
+
+func toDomainWorkspace(workspace db.Workspace) *domain.Workspace {
+	return &domain.Workspace{
+		ID:                pgconv.PgToUUID(workspace.ID),
+		UserID:            pgconv.PgToUUID(workspace.UserID),
+		Name:              workspace.Name,
+		StorageLimitBytes: workspace.StorageLimitBytes,
+		StorageUsedBytes:  pgconv.PgToInt64(workspace.StorageUsedBytes),
+		FileCount:         workspace.FileCount,
+		ArchivedAt:        pgconv.PgToTimePtr(workspace.ArchivedAt),
+		Icon:              workspace.Icon,
+		Color:             workspace.Color,
+		Description:       workspace.Description,
+		Revision:          workspace.Revision,
+		CreatedAt:         pgconv.PgToTime(workspace.CreatedAt),
+		UpdatedAt:         pgconv.PgToTime(workspace.UpdatedAt),
+	}
+}
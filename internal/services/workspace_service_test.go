@@ -3,9 +3,13 @@ package services
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/duckonomy/noture/internal/db"
 	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/storage"
 	"github.com/duckonomy/noture/internal/testutil"
+	"github.com/duckonomy/noture/pkg/pgconv"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -14,7 +18,7 @@ func TestWorkspaceService_GetWorkspacesByUser_Simple(t *testing.T) {
 	testDB := testutil.NewIsolatedTestDB(t)
 	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
 
-	service := NewWorkspaceService(testDB.Queries())
+	service := NewWorkspaceService(testDB.Queries(), storage.NewPostgresBackend(testDB.Queries()))
 	ctx := context.Background()
 
 	t.Run("get workspaces for user", func(t *testing.T) {
@@ -31,7 +35,7 @@ func TestWorkspaceService_GetWorkspaceByID_Simple(t *testing.T) {
 	testDB := testutil.NewIsolatedTestDB(t)
 	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
 
-	service := NewWorkspaceService(testDB.Queries())
+	service := NewWorkspaceService(testDB.Queries(), storage.NewPostgresBackend(testDB.Queries()))
 	ctx := context.Background()
 
 	t.Run("get existing workspace", func(t *testing.T) {
@@ -54,7 +58,7 @@ func TestWorkspaceService_CreateWorkspace_Simple(t *testing.T) {
 	testDB := testutil.NewIsolatedTestDB(t)
 	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
 
-	service := NewWorkspaceService(testDB.Queries())
+	service := NewWorkspaceService(testDB.Queries(), storage.NewPostgresBackend(testDB.Queries()))
 	ctx := context.Background()
 
 	t.Run("create workspace successfully", func(t *testing.T) {
@@ -101,7 +105,7 @@ func TestWorkspaceService_GetWorkspaceStorageInfo_Simple(t *testing.T) {
 	testDB := testutil.NewIsolatedTestDB(t)
 	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
 
-	service := NewWorkspaceService(testDB.Queries())
+	service := NewWorkspaceService(testDB.Queries(), storage.NewPostgresBackend(testDB.Queries()))
 	ctx := context.Background()
 
 	t.Run("get storage info for empty workspace", func(t *testing.T) {
@@ -113,6 +117,33 @@ func TestWorkspaceService_GetWorkspaceStorageInfo_Simple(t *testing.T) {
 		assert.Equal(t, int64(0), storageInfo.FileCount)
 	})
 
+	t.Run("counters track uploads and deletes", func(t *testing.T) {
+		fileService := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+
+		for _, content := range []string{"first version", "second, longer version"} {
+			_, err := fileService.UploadFile(ctx, domain.FileUploadRequest{
+				WorkspaceID:  testData.FreeWorkspaceID,
+				FilePath:     "counted.md",
+				Content:      []byte(content),
+				LastModified: time.Now(),
+			}, testData.FreeUserID)
+			require.NoError(t, err)
+		}
+
+		storageInfo, err := service.GetWorkspaceStorageInfo(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), storageInfo.FileCount)
+		assert.Equal(t, int64(len("second, longer version")), storageInfo.StorageUsedBytes)
+
+		err = fileService.DeleteFile(ctx, testData.FreeWorkspaceID, "counted.md", testData.FreeUserID)
+		require.NoError(t, err)
+
+		storageInfo, err = service.GetWorkspaceStorageInfo(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), storageInfo.FileCount)
+		assert.Equal(t, int64(0), storageInfo.StorageUsedBytes)
+	})
+
 	t.Run("access denied for different user", func(t *testing.T) {
 		_, err := service.GetWorkspaceStorageInfo(ctx, testData.FreeWorkspaceID, testData.PremiumUserID)
 
@@ -120,3 +151,124 @@ func TestWorkspaceService_GetWorkspaceStorageInfo_Simple(t *testing.T) {
 		assert.Contains(t, err.Error(), "access denied")
 	})
 }
+
+func TestWorkspaceService_GetWorkspaceStats_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewWorkspaceService(testDB.Queries(), storage.NewPostgresBackend(testDB.Queries()))
+	fileService := NewFileServiceForTesting(testDB.Queries(), testDB.Conn())
+	ctx := context.Background()
+
+	upload := func(path, content string) {
+		_, err := fileService.UploadFile(ctx, domain.FileUploadRequest{
+			WorkspaceID:  testData.FreeWorkspaceID,
+			FilePath:     path,
+			Content:      []byte(content),
+			LastModified: time.Now(),
+		}, testData.FreeUserID)
+		require.NoError(t, err)
+	}
+	upload("plan.md", "one two three")
+	upload("plan.md", "one two three four")
+	upload("notes.org", "* Heading")
+	_, err := fileService.BackfillMetadata(ctx, MetadataBackfillBatchSize)
+	require.NoError(t, err)
+	upload("todo.txt", "unparsed")
+
+	stats, err := service.GetWorkspaceStats(ctx, testData.FreeWorkspaceID, testData.FreeUserID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.TotalFiles)
+	assert.Equal(t, int64(6), stats.TotalWords)
+	assert.Equal(t, int64(len("one two three four")+len("* Heading")+len("unparsed")), stats.TotalBytes)
+	assert.Equal(t, []domain.FormatStats{
+		{Format: "markdown", Files: 1, SizeBytes: 18, Words: 4},
+		{Format: "orgmode", Files: 1, SizeBytes: 9, Words: 2},
+		{Format: "unparsed", Files: 1, SizeBytes: 8},
+	}, stats.Formats)
+	require.Len(t, stats.Growth, 1)
+	assert.Equal(t, int64(3), stats.Growth[0].FilesCreated)
+	assert.Equal(t, int64(4), stats.Growth[0].Versions)
+	require.Len(t, stats.LargestFiles, 3)
+	assert.Equal(t, "plan.md", stats.LargestFiles[0].FilePath)
+	assert.Equal(t, int32(4), *stats.LargestFiles[0].Words)
+	assert.Nil(t, stats.LargestFiles[2].Words)
+
+	_, err = service.GetWorkspaceStats(ctx, testData.FreeWorkspaceID, testData.FreeUserID, 367)
+	assert.ErrorContains(t, err, "invalid days")
+}
+
+func TestWorkspaceService_UpdateWorkspace_Simple(t *testing.T) {
+	testDB := testutil.NewIsolatedTestDB(t)
+	testData := testutil.CreateSimpleTestData(t, testDB.Queries())
+
+	service := NewWorkspaceService(testDB.Queries(), storage.NewPostgresBackend(testDB.Queries()))
+	ctx := context.Background()
+
+	icon, color, order := "📓", "#336699", int32(3)
+
+	t.Run("display metadata is saved and listed", func(t *testing.T) {
+		updated, err := service.UpdateWorkspace(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.UpdateWorkspaceRequest{
+			Icon:      &icon,
+			Color:     &color,
+			SortOrder: &order,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, icon, updated.Icon)
+		assert.Equal(t, color, updated.Color)
+		assert.Equal(t, order, updated.SortOrder)
+
+		workspaces, err := service.GetWorkspacesByUser(ctx, testData.FreeUserID)
+		require.NoError(t, err)
+		require.Len(t, workspaces, 1)
+		assert.Equal(t, color, workspaces[0].Color)
+	})
+
+	t.Run("omitted fields are kept", func(t *testing.T) {
+		description := "Work notes"
+		updated, err := service.UpdateWorkspace(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.UpdateWorkspaceRequest{
+			Description: &description,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, description, updated.Description)
+		assert.Equal(t, icon, updated.Icon)
+	})
+
+	t.Run("invalid color is rejected", func(t *testing.T) {
+		bad := "blue"
+		_, err := service.UpdateWorkspace(ctx, testData.FreeWorkspaceID, testData.FreeUserID, domain.UpdateWorkspaceRequest{Color: &bad})
+		assert.Error(t, err)
+	})
+
+	t.Run("non-members cannot update", func(t *testing.T) {
+		_, err := service.UpdateWorkspace(ctx, testData.FreeWorkspaceID, testData.PremiumUserID, domain.UpdateWorkspaceRequest{Icon: &icon})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "access denied")
+	})
+
+	t.Run("each member keeps their own sort order", func(t *testing.T) {
+		_, err := testDB.Queries().AddWorkspaceMember(ctx, db.AddWorkspaceMemberParams{
+			WorkspaceID: pgconv.UUIDToPg(testData.FreeWorkspaceID),
+			UserID:      pgconv.UUIDToPg(testData.PremiumUserID),
+			Role:        db.WorkspaceRoleViewer,
+		})
+		require.NoError(t, err)
+
+		mine := int32(-1)
+		updated, err := service.UpdateWorkspace(ctx, testData.FreeWorkspaceID, testData.PremiumUserID, domain.UpdateWorkspaceRequest{SortOrder: &mine})
+		require.NoError(t, err)
+		assert.Equal(t, mine, updated.SortOrder)
+
+		workspaces, err := service.GetWorkspacesByUser(ctx, testData.PremiumUserID)
+		require.NoError(t, err)
+		assert.Equal(t, testData.FreeWorkspaceID, workspaces[0].ID, "a negative order sorts first")
+		assert.Equal(t, mine, workspaces[0].SortOrder)
+
+		owners, err := service.GetWorkspaceByID(ctx, testData.FreeWorkspaceID, testData.FreeUserID)
+		require.NoError(t, err)
+		assert.Equal(t, order, owners.SortOrder, "the owner's order is unchanged")
+
+		_, err = service.UpdateWorkspace(ctx, testData.FreeWorkspaceID, testData.PremiumUserID, domain.UpdateWorkspaceRequest{Icon: &icon})
+		assert.ErrorContains(t, err, "access denied", "only the owner changes shared metadata")
+	})
+}
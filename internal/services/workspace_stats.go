@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// GetWorkspaceStats summarizes the workspace's files, with growth over the
+// last days days, today included, as days in the user's time zone that
+// saw any. Days of 0 mean DefaultStatsDays.
+func (s *WorkspaceService) GetWorkspaceStats(ctx context.Context, workspaceID, userID uuid.UUID, days int) (*domain.WorkspaceStats, error) {
+	if days == 0 {
+		days = domain.DefaultStatsDays
+	} else if days < 0 || days > domain.MaxStatsDays {
+		return nil, fmt.Errorf("invalid days: use 1 to %d", domain.MaxStatsDays)
+	}
+
+	if _, _, err := authorizeWorkspace(ctx, s.queries, workspaceID, userID, domain.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	user, err := s.queries.GetUserByID(ctx, pgconv.UUIDToPg(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	loc := toDomainUser(user).Location()
+	y, m, d := time.Now().In(loc).Date()
+	since := time.Date(y, m, d, 0, 0, 0, 0, loc).AddDate(0, 0, 1-days)
+
+	ctx = db.PreferReplica(ctx)
+	wsID := pgconv.UUIDToPg(workspaceID)
+	stats := &domain.WorkspaceStats{
+		WorkspaceID:  workspaceID,
+		Formats:      []domain.FormatStats{},
+		Growth:       []domain.StatsDay{},
+		LargestFiles: []domain.FileSizeStats{},
+	}
+
+	formats, err := retryRead(ctx, "list_workspace_format_stats", func() ([]db.ListWorkspaceFormatStatsRow, error) {
+		return s.queries.ListWorkspaceFormatStats(ctx, wsID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count files: %w", err)
+	}
+	for _, row := range formats {
+		stats.Formats = append(stats.Formats, domain.FormatStats{
+			Format:    row.Format,
+			Files:     row.Files,
+			SizeBytes: row.SizeBytes,
+			Words:     row.Words,
+		})
+		stats.TotalFiles += row.Files
+		stats.TotalBytes += row.SizeBytes
+		stats.TotalWords += row.Words
+	}
+
+	largest, err := retryRead(ctx, "list_largest_files", func() ([]db.ListLargestFilesRow, error) {
+		return s.queries.ListLargestFiles(ctx, db.ListLargestFilesParams{
+			WorkspaceID: wsID,
+			MaxFiles:    domain.StatsLargestFiles,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list largest files: %w", err)
+	}
+	for _, row := range largest {
+		file := domain.FileSizeStats{FilePath: row.FilePath, SizeBytes: row.SizeBytes}
+		if row.WordCount.Valid {
+			file.Words = &row.WordCount.Int32
+		}
+		stats.LargestFiles = append(stats.LargestFiles, file)
+	}
+
+	growth := map[string]*domain.StatsDay{}
+	day := func(date pgtype.Date) *domain.StatsDay {
+		key := pgconv.PgToDate(date).Format(time.DateOnly)
+		if growth[key] == nil {
+			growth[key] = &domain.StatsDay{Date: key}
+		}
+		return growth[key]
+	}
+	created, err := retryRead(ctx, "list_workspace_files_created_by_day", func() ([]db.ListWorkspaceFilesCreatedByDayRow, error) {
+		return s.queries.ListWorkspaceFilesCreatedByDay(ctx, db.ListWorkspaceFilesCreatedByDayParams{
+			TimeZone:    loc.String(),
+			WorkspaceID: wsID,
+			Since:       pgconv.TimeToPg(since),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count created files: %w", err)
+	}
+	for _, row := range created {
+		day(row.Day).FilesCreated = row.Files
+	}
+	versions, err := retryRead(ctx, "list_workspace_versions_by_day", func() ([]db.ListWorkspaceVersionsByDayRow, error) {
+		return s.queries.ListWorkspaceVersionsByDay(ctx, db.ListWorkspaceVersionsByDayParams{
+			TimeZone:    loc.String(),
+			WorkspaceID: wsID,
+			Since:       pgconv.TimeToPg(since),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count versions: %w", err)
+	}
+	for _, row := range versions {
+		day(row.Day).Versions = row.Versions
+	}
+	for _, d := range growth {
+		stats.Growth = append(stats.Growth, *d)
+	}
+	sort.Slice(stats.Growth, func(i, j int) bool {
+		return stats.Growth[i].Date < stats.Growth[j].Date
+	})
+	return stats, nil
+}
@@ -0,0 +1,230 @@
+// Package slo evaluates per-endpoint service level objectives against the
+// request metrics and alerts when an endpoint burns its error budget too
+// fast.
+//
+// A request is bad when it fails with a 5xx status or succeeds slower than
+// the latency threshold. Burn rate is the bad ratio divided by the budget
+// (1 - availability): at 1 the budget lasts exactly the SLO period, and an
+// alert fires when both the short and the long window burn faster than
+// AlertBurnRate, so brief spikes and long-recovered incidents stay quiet.
+package slo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/duckonomy/noture/internal/metrics"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/duckonomy/noture/pkg/webhook"
+)
+
+const (
+	ShortWindow = 5 * time.Minute
+	LongWindow  = time.Hour
+
+	// AlertBurnRate spends 2% of a 30 day budget in one hour.
+	AlertBurnRate = 14.4
+
+	// MinAlertRequests keeps rarely used endpoints from alerting on a
+	// single failure.
+	MinAlertRequests = 20
+
+	alertCooldown = time.Hour
+)
+
+type Objective struct {
+	Availability     float64       `json:"availability"`
+	LatencyThreshold time.Duration `json:"-"`
+}
+
+// DefaultObjective is 99.5% of requests succeeding within one second.
+var DefaultObjective = Objective{Availability: 0.995, LatencyThreshold: time.Second}
+
+// EndpointStatus reports one route over LongWindow.
+type EndpointStatus struct {
+	Route           string  `json:"route"`
+	Requests        int64   `json:"requests"`
+	ErrorRate       float64 `json:"error_rate"`
+	SlowRate        float64 `json:"slow_rate"`
+	P50Ms           int64   `json:"p50_ms"`
+	P95Ms           int64   `json:"p95_ms"`
+	P99Ms           int64   `json:"p99_ms"`
+	ShortBurnRate   float64 `json:"burn_rate_5m"`
+	LongBurnRate    float64 `json:"burn_rate_1h"`
+	BudgetRemaining float64 `json:"budget_remaining"`
+	Burning         bool    `json:"burning"`
+}
+
+type Report struct {
+	Availability       float64          `json:"availability"`
+	LatencyThresholdMs int64            `json:"latency_threshold_ms"`
+	Endpoints          []EndpointStatus `json:"endpoints"`
+}
+
+// Alert is the JSON body posted to the alert webhook.
+type Alert struct {
+	Route         string    `json:"route"`
+	ShortBurnRate float64   `json:"burn_rate_5m"`
+	LongBurnRate  float64   `json:"burn_rate_1h"`
+	Requests      int64     `json:"requests"`
+	ErrorRate     float64   `json:"error_rate"`
+	SlowRate      float64   `json:"slow_rate"`
+	FiredAt       time.Time `json:"fired_at"`
+}
+
+type Tracker struct {
+	recorder  *metrics.Recorder
+	objective Objective
+	alertURL  string
+	signer    *webhook.Verifier
+	client    *http.Client
+	mu        sync.Mutex
+	alertedAt map[string]time.Time
+	log       *logger.Logger
+	now       func() time.Time
+}
+
+func NewTracker(recorder *metrics.Recorder, objective Objective) *Tracker {
+	return &Tracker{
+		recorder:  recorder,
+		objective: objective,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		alertedAt: make(map[string]time.Time),
+		log:       logger.New(),
+		now:       time.Now,
+	}
+}
+
+// WithAlertWebhook posts an Alert to url whenever an endpoint starts
+// burning its budget. With a secret, the body is signed in the
+// X-Noture-Signature format that pkg/webhook verifies.
+func (t *Tracker) WithAlertWebhook(url, secret string) *Tracker {
+	t.alertURL = url
+	if secret != "" {
+		t.signer = webhook.NewVerifier("slo", secret)
+	}
+	return t
+}
+
+func (t *Tracker) Report() Report {
+	report := Report{
+		Availability:       t.objective.Availability,
+		LatencyThresholdMs: t.objective.LatencyThreshold.Milliseconds(),
+	}
+	for _, route := range t.recorder.Routes() {
+		if route == metrics.UnmatchedRoute {
+			continue
+		}
+		report.Endpoints = append(report.Endpoints, t.status(route))
+	}
+	return report
+}
+
+func (t *Tracker) status(route string) EndpointStatus {
+	long := t.recorder.Window(route, LongWindow)
+	short := t.recorder.Window(route, ShortWindow)
+
+	status := EndpointStatus{
+		Route:         route,
+		Requests:      long.Requests,
+		P50Ms:         long.Quantile(0.50).Milliseconds(),
+		P95Ms:         long.Quantile(0.95).Milliseconds(),
+		P99Ms:         long.Quantile(0.99).Milliseconds(),
+		ShortBurnRate: t.burnRate(short),
+		LongBurnRate:  t.burnRate(long),
+	}
+	if long.Requests > 0 {
+		status.ErrorRate = float64(long.Errors) / float64(long.Requests)
+		status.SlowRate = float64(long.SlowerThan(t.objective.LatencyThreshold)) / float64(long.Requests)
+	}
+	status.BudgetRemaining = 1 - status.LongBurnRate
+	status.Burning = long.Requests >= MinAlertRequests &&
+		status.ShortBurnRate >= AlertBurnRate &&
+		status.LongBurnRate >= AlertBurnRate
+	return status
+}
+
+func (t *Tracker) burnRate(stats metrics.Stats) float64 {
+	if stats.Requests == 0 {
+		return 0
+	}
+	bad := stats.Errors + stats.SlowerThan(t.objective.LatencyThreshold)
+	return float64(bad) / float64(stats.Requests) / (1 - t.objective.Availability)
+}
+
+// CheckBudgets alerts for every burning endpoint that has not alerted
+// within the cooldown. It returns the number of alerts sent.
+func (t *Tracker) CheckBudgets(ctx context.Context) (int, error) {
+	sent := 0
+	for _, status := range t.Report().Endpoints {
+		if !status.Burning || !t.shouldAlert(status.Route) {
+			continue
+		}
+
+		t.log.Warn("Error budget burning",
+			"route", status.Route,
+			"burn_rate_5m", status.ShortBurnRate,
+			"burn_rate_1h", status.LongBurnRate)
+
+		if t.alertURL == "" {
+			continue
+		}
+		if err := t.sendAlert(ctx, status); err != nil {
+			return sent, fmt.Errorf("failed to send SLO alert for %s: %w", status.Route, err)
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+func (t *Tracker) shouldAlert(route string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if last, ok := t.alertedAt[route]; ok && now.Sub(last) < alertCooldown {
+		return false
+	}
+	t.alertedAt[route] = now
+	return true
+}
+
+func (t *Tracker) sendAlert(ctx context.Context, status EndpointStatus) error {
+	body, err := json.Marshal(Alert{
+		Route:         status.Route,
+		ShortBurnRate: status.ShortBurnRate,
+		LongBurnRate:  status.LongBurnRate,
+		Requests:      status.Requests,
+		ErrorRate:     status.ErrorRate,
+		SlowRate:      status.SlowRate,
+		FiredAt:       t.now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.alertURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.signer != nil {
+		req.Header.Set(webhook.DefaultHeader, t.signer.Sign(body, t.now()))
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package slo
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/metrics"
+	"github.com/duckonomy/noture/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker_Report(t *testing.T) {
+	recorder := metrics.NewRecorder()
+	for i := 0; i < 96; i++ {
+		recorder.Observe("GET /ok", http.StatusOK, 20*time.Millisecond)
+	}
+	for i := 0; i < 2; i++ {
+		recorder.Observe("GET /ok", http.StatusOK, 3*time.Second)
+	}
+	for i := 0; i < 2; i++ {
+		recorder.Observe("GET /ok", http.StatusBadGateway, time.Millisecond)
+	}
+	recorder.Observe(metrics.UnmatchedRoute, http.StatusNotFound, time.Millisecond)
+
+	tracker := NewTracker(recorder, Objective{Availability: 0.99, LatencyThreshold: time.Second})
+	report := tracker.Report()
+
+	require.Len(t, report.Endpoints, 1, "unmatched requests are not an endpoint")
+	status := report.Endpoints[0]
+	assert.Equal(t, int64(100), status.Requests)
+	assert.InDelta(t, 0.02, status.ErrorRate, 1e-9)
+	assert.InDelta(t, 0.02, status.SlowRate, 1e-9)
+	assert.InDelta(t, 4.0, status.LongBurnRate, 1e-9, "4% bad against a 1% budget")
+	assert.InDelta(t, -3.0, status.BudgetRemaining, 1e-9)
+	assert.Equal(t, int64(25), status.P50Ms)
+	assert.False(t, status.Burning)
+}
+
+func TestTracker_CheckBudgets(t *testing.T) {
+	var received atomic.Int32
+	verifier := webhook.NewVerifier("slo", "secret").WithTolerance(3 * alertCooldown)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := verifier.Verify(r.Header.Get(webhook.DefaultHeader), body); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		var alert Alert
+		if err := json.Unmarshal(body, &alert); err != nil || alert.Route != "POST /api/files/upload" {
+			http.Error(w, "bad alert", http.StatusBadRequest)
+			return
+		}
+		received.Add(1)
+	}))
+	defer server.Close()
+
+	recorder := metrics.NewRecorder()
+	for i := 0; i < 50; i++ {
+		recorder.Observe("POST /api/files/upload", http.StatusInternalServerError, time.Millisecond)
+		recorder.Observe("GET /api/workspaces", http.StatusOK, time.Millisecond)
+	}
+
+	tracker := NewTracker(recorder, DefaultObjective).WithAlertWebhook(server.URL, "secret")
+	ctx := context.Background()
+
+	sent, err := tracker.CheckBudgets(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, int32(1), received.Load())
+
+	sent, err = tracker.CheckBudgets(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent, "alerts are not repeated within the cooldown")
+
+	tracker.now = func() time.Time { return time.Now().Add(2 * alertCooldown) }
+	sent, err = tracker.CheckBudgets(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FilesystemBackend stores each blob as a file under dir, sharded by the
+// first two hex digits of its hash.
+type FilesystemBackend struct {
+	dir string
+}
+
+func NewFilesystemBackend(dir string) (*FilesystemBackend, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &FilesystemBackend{dir: dir}, nil
+}
+
+func (b *FilesystemBackend) path(hash string) string {
+	return filepath.Join(b.dir, hash[:2], hash)
+}
+
+// Put writes through a temporary file so a crash never leaves a truncated
+// blob under its final name.
+func (b *FilesystemBackend) Put(ctx context.Context, hash string, content []byte) error {
+	if err := verifyHash(hash, content); err != nil {
+		return err
+	}
+
+	path := b.path(hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write blob %s: %w", hash, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write blob %s: %w", hash, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move blob %s into place: %w", hash, err)
+	}
+	return nil
+}
+
+func (b *FilesystemBackend) Get(ctx context.Context, hash string) ([]byte, error) {
+	if !validHash(hash) {
+		return nil, ErrNotFound
+	}
+	content, err := os.ReadFile(b.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+	return content, nil
+}
+
+func (b *FilesystemBackend) Open(ctx context.Context, hash string) (io.ReadSeekCloser, error) {
+	if !validHash(hash) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(b.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob %s: %w", hash, err)
+	}
+	return f, nil
+}
+
+func (b *FilesystemBackend) Delete(ctx context.Context, hash string) error {
+	if !validHash(hash) {
+		return nil
+	}
+	if err := os.Remove(b.path(hash)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete blob %s: %w", hash, err)
+	}
+	return nil
+}
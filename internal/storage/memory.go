@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+// MemoryBackend keeps blobs in a map. It is meant for tests.
+type MemoryBackend struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{blobs: make(map[string][]byte)}
+}
+
+func (b *MemoryBackend) Put(ctx context.Context, hash string, content []byte) error {
+	if err := verifyHash(hash, content); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.blobs[hash] = append([]byte(nil), content...)
+	return nil
+}
+
+func (b *MemoryBackend) Get(ctx context.Context, hash string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	content, ok := b.blobs[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return content, nil
+}
+
+func (b *MemoryBackend) Open(ctx context.Context, hash string) (io.ReadSeekCloser, error) {
+	content, err := b.Get(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return nopCloser{bytes.NewReader(content)}, nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
+
+func (b *MemoryBackend) Delete(ctx context.Context, hash string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.blobs, hash)
+	return nil
+}
+
+// Len reports how many blobs are stored.
+func (b *MemoryBackend) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.blobs)
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// BlobQueries is the subset of *db.Queries the Postgres backend uses.
+type BlobQueries interface {
+	PutBlob(ctx context.Context, arg db.PutBlobParams) error
+	GetBlob(ctx context.Context, contentHash string) ([]byte, error)
+	GetBlobSize(ctx context.Context, contentHash string) (int64, error)
+	GetBlobRange(ctx context.Context, arg db.GetBlobRangeParams) ([]byte, error)
+	DeleteBlob(ctx context.Context, contentHash string) error
+}
+
+// PostgresBackend keeps blobs in the blobs table. It is the simplest setup
+// and what existing installations start with.
+type PostgresBackend struct {
+	queries BlobQueries
+}
+
+func NewPostgresBackend(queries BlobQueries) *PostgresBackend {
+	return &PostgresBackend{queries: queries}
+}
+
+func (b *PostgresBackend) Put(ctx context.Context, hash string, content []byte) error {
+	if err := verifyHash(hash, content); err != nil {
+		return err
+	}
+	if err := b.queries.PutBlob(ctx, db.PutBlobParams{ContentHash: hash, Content: content}); err != nil {
+		return fmt.Errorf("failed to store blob %s: %w", hash, err)
+	}
+	return nil
+}
+
+func (b *PostgresBackend) Get(ctx context.Context, hash string) ([]byte, error) {
+	content, err := b.queries.GetBlob(ctx, hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+	return content, nil
+}
+
+// postgresChunkSize is how much of a blob one query reads while streaming.
+const postgresChunkSize = 256 << 10
+
+// maxPostgresOffset is the last offset substring() can start at: it takes
+// int4 positions. bytea values are at most 1 GB, so only a corrupt size or
+// a bug gets past it, and then reading fails instead of wrapping around.
+const maxPostgresOffset = math.MaxInt32 - 1
+
+// Open reads the blob in chunks with substring(), which only detoasts the
+// requested slice because the content column is stored uncompressed.
+func (b *PostgresBackend) Open(ctx context.Context, hash string) (io.ReadSeekCloser, error) {
+	size, err := b.queries.GetBlobSize(ctx, hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob %s: %w", hash, err)
+	}
+
+	return &chunkReader{
+		size: size,
+		fetch: func(offset int64) ([]byte, error) {
+			if offset < 0 || offset > maxPostgresOffset {
+				return nil, fmt.Errorf("failed to read blob %s: offset %d out of range", hash, offset)
+			}
+			chunk, err := b.queries.GetBlobRange(ctx, db.GetBlobRangeParams{
+				ContentHash: hash,
+				Start:       int32(offset + 1),
+				Length:      postgresChunkSize,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to read blob %s: %w", hash, err)
+			}
+			return chunk, nil
+		},
+	}, nil
+}
+
+func (b *PostgresBackend) Delete(ctx context.Context, hash string) error {
+	if err := b.queries.DeleteBlob(ctx, hash); err != nil {
+		return fmt.Errorf("failed to delete blob %s: %w", hash, err)
+	}
+	return nil
+}
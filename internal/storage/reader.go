@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+var errNegativeOffset = errors.New("seek to negative offset")
+
+// seekOffset resolves a Seek call for a reader of the given size.
+func seekOffset(current, size, offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = current + offset
+	case io.SeekEnd:
+		abs = size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errNegativeOffset
+	}
+	return abs, nil
+}
+
+// chunkReader streams a blob of known size by fetching one chunk at a time
+// from the given offset. Only the current chunk is held in memory.
+type chunkReader struct {
+	size   int64
+	offset int64
+	buf    []byte
+	bufOff int64
+	fetch  func(offset int64) ([]byte, error)
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.offset < r.bufOff || r.offset >= r.bufOff+int64(len(r.buf)) {
+		chunk, err := r.fetch(r.offset)
+		if err != nil {
+			return 0, err
+		}
+		if len(chunk) == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		r.buf, r.bufOff = chunk, r.offset
+	}
+
+	n := copy(p, r.buf[r.offset-r.bufOff:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *chunkReader) Seek(offset int64, whence int) (int64, error) {
+	abs, err := seekOffset(r.offset, r.size, offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	r.offset = abs
+	return abs, nil
+}
+
+func (r *chunkReader) Close() error {
+	r.buf = nil
+	return nil
+}
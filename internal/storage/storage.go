@@ -0,0 +1,110 @@
+// Package storage keeps file content apart from file metadata. Content is
+// addressed by its SHA-256 hash, so a blob is written once no matter how
+// many files or versions share it.
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Backend stores blobs by the hex SHA-256 of their content. Put must be
+// idempotent: storing a hash that already exists is not an error.
+//
+// Open streams a blob instead of loading it whole. It returns ErrNotFound
+// before any content is read, and the reader can seek, so HTTP range
+// requests only fetch the bytes asked for.
+type Backend interface {
+	Put(ctx context.Context, hash string, content []byte) error
+	Get(ctx context.Context, hash string) ([]byte, error)
+	Open(ctx context.Context, hash string) (io.ReadSeekCloser, error)
+	Delete(ctx context.Context, hash string) error
+}
+
+// Hash returns the key content is stored under.
+func Hash(content []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
+
+func verifyHash(hash string, content []byte) error {
+	if actual := Hash(content); actual != hash {
+		return fmt.Errorf("content hash mismatch: expected %s, got %s", hash, actual)
+	}
+	return nil
+}
+
+func validHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range hash {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Postgres is the default backend; Config.Queries must be set for it.
+const (
+	KindPostgres   = "postgres"
+	KindFilesystem = "filesystem"
+	KindS3         = "s3"
+)
+
+type Config struct {
+	Kind    string
+	Queries BlobQueries
+
+	// Dir is the root directory of the filesystem backend.
+	Dir string
+
+	S3 S3Config
+}
+
+// ConfigFromEnv reads STORAGE_BACKEND and the backend-specific variables.
+func ConfigFromEnv(queries BlobQueries) Config {
+	cfg := Config{
+		Kind:    os.Getenv("STORAGE_BACKEND"),
+		Queries: queries,
+		Dir:     os.Getenv("STORAGE_DIR"),
+		S3: S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Prefix:          os.Getenv("S3_PREFIX"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		},
+	}
+	if cfg.Kind == "" {
+		cfg.Kind = KindPostgres
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = "./data/blobs"
+	}
+	return cfg
+}
+
+// New builds the backend cfg selects.
+func New(cfg Config) (Backend, error) {
+	switch cfg.Kind {
+	case KindPostgres, "":
+		if cfg.Queries == nil {
+			return nil, fmt.Errorf("postgres storage needs a database")
+		}
+		return NewPostgresBackend(cfg.Queries), nil
+	case KindFilesystem:
+		return NewFilesystemBackend(cfg.Dir)
+	case KindS3:
+		return NewS3Backend(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Kind)
+	}
+}
@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBackend(t *testing.T, backend Backend) {
+	ctx := context.Background()
+	content := []byte("# hello")
+	hash := Hash(content)
+
+	_, err := backend.Get(ctx, hash)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, backend.Put(ctx, hash, content))
+	require.NoError(t, backend.Put(ctx, hash, content), "put is idempotent")
+
+	got, err := backend.Get(ctx, hash)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+
+	assert.Error(t, backend.Put(ctx, Hash([]byte("other")), content), "hash must match content")
+
+	testOpen(t, backend)
+
+	require.NoError(t, backend.Delete(ctx, hash))
+	require.NoError(t, backend.Delete(ctx, hash), "deleting a missing blob is fine")
+
+	_, err = backend.Get(ctx, hash)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// testOpen streams a blob spanning several Postgres chunks and reads a
+// range from the middle, as http.ServeContent would.
+func testOpen(t *testing.T, backend Backend) {
+	ctx := context.Background()
+	content := bytes.Repeat([]byte("0123456789"), postgresChunkSize/4)
+	hash := Hash(content)
+	require.NoError(t, backend.Put(ctx, hash, content))
+
+	_, err := backend.Open(ctx, Hash([]byte("missing")))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	r, err := backend.Open(ctx, hash)
+	require.NoError(t, err)
+	defer r.Close()
+
+	all, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, content, all)
+
+	size, err := r.Seek(0, io.SeekEnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), size)
+
+	_, err = r.Seek(postgresChunkSize-3, io.SeekStart)
+	require.NoError(t, err)
+	part := make([]byte, 6)
+	_, err = io.ReadFull(r, part)
+	require.NoError(t, err)
+	assert.Equal(t, content[postgresChunkSize-3:postgresChunkSize+3], part)
+
+	require.NoError(t, backend.Delete(ctx, hash))
+}
+
+// fakeBlobQueries mimics the blobs table, including substring()'s 1-based
+// offsets.
+type fakeBlobQueries struct {
+	blobs map[string][]byte
+}
+
+func (f *fakeBlobQueries) PutBlob(ctx context.Context, arg db.PutBlobParams) error {
+	if _, ok := f.blobs[arg.ContentHash]; !ok {
+		f.blobs[arg.ContentHash] = arg.Content
+	}
+	return nil
+}
+
+func (f *fakeBlobQueries) GetBlob(ctx context.Context, hash string) ([]byte, error) {
+	content, ok := f.blobs[hash]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return content, nil
+}
+
+func (f *fakeBlobQueries) GetBlobSize(ctx context.Context, hash string) (int64, error) {
+	content, ok := f.blobs[hash]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	return int64(len(content)), nil
+}
+
+func (f *fakeBlobQueries) GetBlobRange(ctx context.Context, arg db.GetBlobRangeParams) ([]byte, error) {
+	content, ok := f.blobs[arg.ContentHash]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	start := min(int(arg.Start)-1, len(content))
+	end := min(start+int(arg.Length), len(content))
+	return content[start:end], nil
+}
+
+func (f *fakeBlobQueries) DeleteBlob(ctx context.Context, hash string) error {
+	delete(f.blobs, hash)
+	return nil
+}
+
+func TestPostgresBackend(t *testing.T) {
+	testBackend(t, NewPostgresBackend(&fakeBlobQueries{blobs: make(map[string][]byte)}))
+
+	t.Run("offsets past int4 are refused", func(t *testing.T) {
+		content := []byte("# hello")
+		queries := &hugeBlobQueries{fakeBlobQueries: fakeBlobQueries{blobs: map[string][]byte{Hash(content): content}}}
+		r, err := NewPostgresBackend(queries).Open(context.Background(), Hash(content))
+		require.NoError(t, err)
+		defer r.Close()
+
+		_, err = r.Seek(3<<30, io.SeekStart)
+		require.NoError(t, err)
+		_, err = r.Read(make([]byte, 16))
+		assert.ErrorContains(t, err, "out of range")
+		assert.Empty(t, queries.starts, "no range is queried")
+	})
+}
+
+// hugeBlobQueries reports every blob as 4 GiB and records the ranges read.
+type hugeBlobQueries struct {
+	fakeBlobQueries
+	starts []int32
+}
+
+func (f *hugeBlobQueries) GetBlobSize(ctx context.Context, hash string) (int64, error) {
+	return 4 << 30, nil
+}
+
+func (f *hugeBlobQueries) GetBlobRange(ctx context.Context, arg db.GetBlobRangeParams) ([]byte, error) {
+	f.starts = append(f.starts, arg.Start)
+	return f.fakeBlobQueries.GetBlobRange(ctx, arg)
+}
+
+func TestMemoryBackend(t *testing.T) {
+	testBackend(t, NewMemoryBackend())
+}
+
+func TestFilesystemBackend(t *testing.T) {
+	backend, err := NewFilesystemBackend(t.TempDir())
+	require.NoError(t, err)
+	testBackend(t, backend)
+
+	_, err = backend.Get(context.Background(), "../../etc/passwd")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// fakeS3 is a bucket that only checks requests are signed for the right
+// credential scope.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request") {
+		http.Error(w, "bad signature", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Amz-Content-Sha256") != Hash(body) {
+			http.Error(w, "payload hash mismatch", http.StatusBadRequest)
+			return
+		}
+		f.objects[r.URL.Path] = body
+	case http.MethodGet, http.MethodHead:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Backend(t *testing.T) {
+	bucket := &fakeS3{objects: make(map[string][]byte)}
+	server := httptest.NewServer(bucket)
+	defer server.Close()
+
+	backend, err := NewS3Backend(S3Config{
+		Endpoint:        server.URL,
+		Region:          "eu-west-1",
+		Bucket:          "notes",
+		Prefix:          "blobs/",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+	})
+	require.NoError(t, err)
+	testBackend(t, backend)
+
+	content := []byte("stored")
+	require.NoError(t, backend.Put(context.Background(), Hash(content), content))
+	assert.Contains(t, bucket.objects, "/notes/blobs/"+Hash(content))
+}
+
+func TestSigningKey(t *testing.T) {
+	// Example from the AWS Signature Version 4 documentation.
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	assert.Equal(t, "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d", hex.EncodeToString(key))
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{Kind: "tape"})
+	assert.Error(t, err)
+
+	_, err = New(Config{Kind: KindPostgres})
+	assert.Error(t, err, "postgres needs queries")
+
+	backend, err := New(Config{Kind: KindFilesystem, Dir: t.TempDir()})
+	require.NoError(t, err)
+	assert.IsType(t, &FilesystemBackend{}, backend)
+}
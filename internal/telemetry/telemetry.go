@@ -0,0 +1,227 @@
+// Package telemetry reports coarse, anonymous usage of a self-hosted
+// instance to an endpoint the operator chooses. It is off unless enabled.
+//
+// Nothing identifying leaves the server: no IDs, names, emails, paths or
+// content, and no timestamps finer than the day. Each count gets Laplace
+// noise with scale 1/Epsilon, which makes a report epsilon-differentially
+// private for any one row, and is then reduced to an order-of-magnitude
+// bucket such as "10-99". The report that Send will post next can be
+// inspected beforehand with Pending.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/duckonomy/noture/internal/db"
+)
+
+// SchemaVersion changes whenever a field is added to or removed from
+// Report, so the collector can tell report formats apart.
+const SchemaVersion = 1
+
+// DefaultEpsilon is the privacy budget of one report. Smaller values add
+// more noise.
+const DefaultEpsilon = 1.0
+
+// Interval is how often an enabled instance reports.
+const Interval = 24 * time.Hour
+
+// Counts are the exact figures a report is derived from. They never leave
+// the server.
+type Counts struct {
+	Users              int64
+	Workspaces         int64
+	ArchivedWorkspaces int64
+	Files              int64
+	StorageUsedBytes   int64
+	SharedWorkspaces   int64
+	ShareLinks         int64
+	Devices            int64
+	Webhooks           int64
+	Tasks              int64
+	PolicyRules        int64
+}
+
+// Source reads the current counts.
+type Source func(ctx context.Context) (Counts, error)
+
+// DBSource counts rows in the database.
+func DBSource(queries *db.Queries) Source {
+	return func(ctx context.Context) (Counts, error) {
+		row, err := queries.GetTelemetryCounts(ctx)
+		if err != nil {
+			return Counts{}, fmt.Errorf("failed to count usage: %w", err)
+		}
+		return Counts(row), nil
+	}
+}
+
+// Report is the body posted to the telemetry endpoint. Instance buckets
+// describe the instance's size; Features buckets how much each optional
+// feature is used.
+type Report struct {
+	SchemaVersion int               `json:"schema_version"`
+	ServerVersion string            `json:"server_version"`
+	Day           string            `json:"day"`
+	Instance      map[string]string `json:"instance"`
+	Features      map[string]string `json:"features"`
+}
+
+type Options struct {
+	Enabled  bool
+	Endpoint string
+	Epsilon  float64
+	Version  string
+}
+
+// Reporter builds and sends reports. It keeps the next report once built,
+// so what Pending shows is exactly what Send posts.
+type Reporter struct {
+	source  Source
+	options Options
+	client  *http.Client
+	noise   func() float64
+	now     func() time.Time
+
+	mu      sync.Mutex
+	pending *Report
+}
+
+func NewReporter(source Source, options Options) *Reporter {
+	if options.Epsilon <= 0 {
+		options.Epsilon = DefaultEpsilon
+	}
+	return &Reporter{
+		source:  source,
+		options: options,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		noise:   rand.Float64,
+		now:     time.Now,
+	}
+}
+
+func (r *Reporter) Enabled() bool {
+	return r.options.Enabled
+}
+
+func (r *Reporter) Endpoint() string {
+	return r.options.Endpoint
+}
+
+// Pending returns the report Send will post next, building it if needed.
+func (r *Reporter) Pending(ctx context.Context) (*Report, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pendingLocked(ctx)
+}
+
+// pendingLocked rebuilds the report at most once a day, so previewing or
+// retrying cannot be used to average the noise away.
+func (r *Reporter) pendingLocked(ctx context.Context) (*Report, error) {
+	if r.pending != nil && r.pending.Day == r.now().UTC().Format(time.DateOnly) {
+		return r.pending, nil
+	}
+	counts, err := r.source(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.pending = r.build(counts)
+	return r.pending, nil
+}
+
+func (r *Reporter) build(c Counts) *Report {
+	return &Report{
+		SchemaVersion: SchemaVersion,
+		ServerVersion: r.options.Version,
+		Day:           r.now().UTC().Format(time.DateOnly),
+		Instance: map[string]string{
+			"users":      r.bucket(c.Users),
+			"workspaces": r.bucket(c.Workspaces),
+			"files":      r.bucket(c.Files),
+			"storage_mb": r.bucket(c.StorageUsedBytes >> 20),
+		},
+		Features: map[string]string{
+			"archived_workspaces": r.bucket(c.ArchivedWorkspaces),
+			"shared_workspaces":   r.bucket(c.SharedWorkspaces),
+			"share_links":         r.bucket(c.ShareLinks),
+			"devices":             r.bucket(c.Devices),
+			"webhooks":            r.bucket(c.Webhooks),
+			"tasks":               r.bucket(c.Tasks),
+			"policy_rules":        r.bucket(c.PolicyRules),
+		},
+	}
+}
+
+// bucket adds Laplace noise to n and names its order of magnitude.
+func (r *Reporter) bucket(n int64) string {
+	u := r.noise() - 0.5
+	if u == -0.5 {
+		u = 0
+	}
+	noise := -math.Copysign(1, u) * math.Log(1-2*math.Abs(u)) / r.options.Epsilon
+	return Bucket(int64(math.Round(float64(n) + noise)))
+}
+
+// Bucket names the order of magnitude of n: "0", "1-9", "10-99" and so on
+// up to "1000000+".
+func Bucket(n int64) string {
+	if n <= 0 {
+		return "0"
+	}
+	low := int64(1)
+	for low < 1000000 {
+		if n < low*10 {
+			return strconv.FormatInt(low, 10) + "-" + strconv.FormatInt(low*10-1, 10)
+		}
+		low *= 10
+	}
+	return "1000000+"
+}
+
+// Send posts the pending report when telemetry is enabled. The report is
+// discarded once the endpoint accepts it; on failure it is kept and sent
+// again on the next attempt that day.
+func (r *Reporter) Send(ctx context.Context) error {
+	if !r.options.Enabled {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report, err := r.pendingLocked(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.options.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint returned %s", resp.Status)
+	}
+	r.pending = nil
+	return nil
+}
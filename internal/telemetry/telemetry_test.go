@@ -0,0 +1,122 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket(t *testing.T) {
+	tests := map[int64]string{
+		-3:       "0",
+		0:        "0",
+		1:        "1-9",
+		9:        "1-9",
+		10:       "10-99",
+		4321:     "1000-9999",
+		999999:   "100000-999999",
+		1000000:  "1000000+",
+		52000000: "1000000+",
+	}
+	for n, want := range tests {
+		assert.Equal(t, want, Bucket(n), "Bucket(%d)", n)
+	}
+}
+
+func newTestReporter(t *testing.T, counts Counts, options Options) (*Reporter, *int32) {
+	t.Helper()
+	var reads int32
+	r := NewReporter(func(ctx context.Context) (Counts, error) {
+		atomic.AddInt32(&reads, 1)
+		return counts, nil
+	}, options)
+	r.noise = func() float64 { return 0.5 }
+	r.now = func() time.Time { return time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC) }
+	return r, &reads
+}
+
+func TestReporter_Pending(t *testing.T) {
+	r, reads := newTestReporter(t, Counts{Users: 42, Files: 12345, StorageUsedBytes: 300 << 20, Webhooks: 1}, Options{Version: "1.2.0"})
+	ctx := context.Background()
+
+	report, err := r.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-03-14", report.Day, "no time of day is reported")
+	assert.Equal(t, "1.2.0", report.ServerVersion)
+	assert.Equal(t, "10-99", report.Instance["users"])
+	assert.Equal(t, "10000-99999", report.Instance["files"])
+	assert.Equal(t, "100-999", report.Instance["storage_mb"])
+	assert.Equal(t, "1-9", report.Features["webhooks"])
+	assert.Equal(t, "0", report.Features["share_links"])
+
+	again, err := r.Pending(ctx)
+	require.NoError(t, err)
+	assert.Same(t, report, again, "the pending report is kept for the day")
+	assert.Equal(t, int32(1), *reads)
+
+	r.now = func() time.Time { return time.Date(2025, 3, 15, 0, 0, 1, 0, time.UTC) }
+	next, err := r.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-03-15", next.Day)
+	assert.Equal(t, int32(2), *reads)
+}
+
+func TestReporter_Noise(t *testing.T) {
+	r, _ := newTestReporter(t, Counts{}, Options{Epsilon: 1})
+
+	// u = 0.49 is far in the tail: about ln(50) ≈ 3.9 added.
+	r.noise = func() float64 { return 0.99 }
+	assert.Equal(t, "1-9", r.bucket(5))
+	assert.Equal(t, "10-99", r.bucket(6))
+
+	r.noise = func() float64 { return 0.01 }
+	assert.Equal(t, "0", r.bucket(3))
+
+	r.noise = func() float64 { return 0 }
+	assert.Equal(t, "1-9", r.bucket(5), "the lower edge of the noise source is finite")
+}
+
+func TestReporter_Send(t *testing.T) {
+	var received []Report
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var report Report
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&report))
+		received = append(received, report)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	t.Run("disabled reporters never send", func(t *testing.T) {
+		r, reads := newTestReporter(t, Counts{Users: 3}, Options{Endpoint: server.URL})
+		require.NoError(t, r.Send(ctx))
+		assert.Empty(t, received)
+		assert.Equal(t, int32(0), *reads)
+	})
+
+	t.Run("sends the previewed report", func(t *testing.T) {
+		r, _ := newTestReporter(t, Counts{Users: 3}, Options{Enabled: true, Endpoint: server.URL})
+		preview, err := r.Pending(ctx)
+		require.NoError(t, err)
+
+		status = http.StatusServiceUnavailable
+		assert.ErrorContains(t, r.Send(ctx), "503")
+		kept, err := r.Pending(ctx)
+		require.NoError(t, err)
+		assert.Same(t, preview, kept, "a failed send keeps the report")
+
+		status = http.StatusAccepted
+		require.NoError(t, r.Send(ctx))
+		require.Len(t, received, 2)
+		assert.Equal(t, *preview, received[1])
+		assert.Nil(t, r.pending)
+	})
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/duckonomy/noture/internal/db"
 	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/auth"
 	"github.com/duckonomy/noture/pkg/pgconv"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
@@ -62,6 +63,11 @@ func NewTestFixtures(t *testing.T, testDB *TestDB) *TestFixtures {
 	require.NoError(t, err)
 	fixtures.EnterpriseUser = enterpriseUser
 
+	for _, user := range []db.User{freeUser, premiumUser, enterpriseUser} {
+		_, err := queries.MarkEmailVerified(ctx, db.MarkEmailVerifiedParams{ID: user.ID, Email: user.Email})
+		require.NoError(t, err)
+	}
+
 	freeToken := "free-token-123"
 	freeHash := hashToken(freeToken)
 	freeUserToken, err := queries.CreateAPIToken(ctx, db.CreateAPITokenParams{
@@ -144,11 +150,16 @@ func (f *TestFixtures) CreateTestFile(t *testing.T, workspaceID uuid.UUID, fileP
 	hash := sha256.Sum256(content)
 	contentHash := fmt.Sprintf("%x", hash)
 
+	err := queries.PutBlob(ctx, db.PutBlobParams{
+		ContentHash: contentHash,
+		Content:     content,
+	})
+	require.NoError(t, err)
+
 	file, err := queries.UpsertFile(ctx, db.UpsertFileParams{
 		WorkspaceID:  pgconv.UUIDToPg(workspaceID),
 		FilePath:     filePath,
 		ContentHash:  contentHash,
-		Content:      content,
 		SizeBytes:    int64(len(content)),
 		MimeType:     pgconv.StringToPg("text/plain"),
 		LastModified: pgconv.TimeToPg(time.Now()),
@@ -159,6 +170,5 @@ func (f *TestFixtures) CreateTestFile(t *testing.T, workspaceID uuid.UUID, fileP
 }
 
 func hashToken(token string) string {
-	hash := sha256.Sum256([]byte(token))
-	return fmt.Sprintf("%x", hash)
+	return auth.HashToken(token)
 }
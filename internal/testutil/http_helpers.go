@@ -1,13 +1,13 @@
 package testutil
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/auth"
 	"github.com/stretchr/testify/require"
 )
 
@@ -25,9 +25,7 @@ func AuthenticatedRequest(t *testing.T, method, url string, authCtx *domain.Auth
 	t.Helper()
 
 	req := httptest.NewRequest(method, url, nil)
-	ctx := context.WithValue(req.Context(), "auth", authCtx)
-
-	return req.WithContext(ctx)
+	return req.WithContext(auth.NewContext(req.Context(), authCtx))
 }
 
 func AssertJSONResponse(t *testing.T, recorder *httptest.ResponseRecorder, expectedStatus int, target interface{}) {
@@ -2,12 +2,12 @@ package testutil
 
 import (
 	"context"
-	"crypto/sha256"
 	"fmt"
 	"testing"
 
 	"github.com/duckonomy/noture/internal/db"
 	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/pkg/auth"
 	"github.com/duckonomy/noture/pkg/pgconv"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
@@ -38,6 +38,13 @@ func CreateSimpleTestData(t *testing.T, queries *db.Queries) *SimpleTestData {
 	})
 	require.NoError(t, err)
 
+	// Fixture accounts have verified emails, as accounts that share and
+	// publish must.
+	for _, user := range []db.User{freeUser, premiumUser} {
+		_, err := queries.MarkEmailVerified(ctx, db.MarkEmailVerifiedParams{ID: user.ID, Email: user.Email})
+		require.NoError(t, err)
+	}
+
 	freeWorkspace, err := queries.CreateWorkspace(ctx, db.CreateWorkspaceParams{
 		UserID:            freeUser.ID,
 		Name:              "test-workspace",
@@ -46,7 +53,7 @@ func CreateSimpleTestData(t *testing.T, queries *db.Queries) *SimpleTestData {
 	require.NoError(t, err)
 
 	tokenString := fmt.Sprintf("test-token-%s", uuid.New().String()[:8])
-	tokenHash := fmt.Sprintf("%x", sha256.Sum256([]byte(tokenString)))
+	tokenHash := auth.HashToken(tokenString)
 
 	_, err = queries.CreateAPIToken(ctx, db.CreateAPITokenParams{
 		UserID:    freeUser.ID,
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/duckonomy/noture/internal/config"
+	"github.com/duckonomy/noture/internal/migrate"
+	"github.com/duckonomy/noture/migrations"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/jackc/pgx/v5"
+)
+
+const migrateUsage = `usage: noture-server migrate <command>
+
+commands:
+  up       apply every pending migration
+  down     roll back the latest migration
+  status   print the schema version and pending migrations
+
+Migrations are embedded in the server and recorded in goose_db_version,
+so databases migrated with the goose CLI are picked up where they are.
+The server applies pending migrations at startup unless auto_migrate is
+off, in which case it refuses to start until they have been applied.
+`
+
+func runMigrateCommand(log *logger.Logger, cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, migrateUsage) }
+
+	if len(args) == 0 {
+		fs.Usage()
+		return 2
+	}
+	command := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, cfg.DatabaseURL)
+	if err != nil {
+		log.Error("Failed to connect to database", "error", err)
+		return 1
+	}
+	defer conn.Close(ctx)
+
+	migrator, err := newMigrator(conn)
+	if err != nil {
+		log.Error("Failed to load migrations", "error", err)
+		return 1
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Error("Migration failed", "error", err, "applied", applied)
+			return 1
+		}
+		fmt.Printf("applied %d migrations, schema at version %d\n", applied, migrator.Latest())
+
+	case "down":
+		migration, err := migrator.Down(ctx)
+		if err != nil {
+			log.Error("Rollback failed", "error", err)
+			return 1
+		}
+		if migration == nil {
+			fmt.Println("no migration to roll back")
+			return 0
+		}
+		fmt.Printf("rolled back %03d_%s\n", migration.Version, migration.Name)
+
+	case "status":
+		state, err := migrator.State(ctx)
+		if err != nil {
+			log.Error("Failed to read schema version", "error", err)
+			return 1
+		}
+		fmt.Printf("schema version %d, server expects %d\n", state.Current, migrator.Latest())
+		for _, migration := range state.Pending {
+			fmt.Printf("pending  %03d_%s\n", migration.Version, migration.Name)
+		}
+
+	default:
+		fs.Usage()
+		return 2
+	}
+	return 0
+}
+
+// newMigrator loads the embedded migrations for conn.
+func newMigrator(conn *pgx.Conn) (*migrate.Migrator, error) {
+	loaded, err := migrate.Load(migrations.Files)
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(conn, loaded), nil
+}
@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE workspaces ADD COLUMN file_count BIGINT NOT NULL DEFAULT 0;
+
+UPDATE workspaces w
+SET file_count = agg.file_count,
+    storage_used_bytes = agg.size_bytes
+FROM (
+    SELECT workspace_id, COUNT(*) AS file_count, SUM(size_bytes) AS size_bytes
+    FROM files
+    GROUP BY workspace_id
+) agg
+WHERE w.id = agg.workspace_id;
+
+-- covering index so keyset listings are index-only scans
+DROP INDEX IF EXISTS idx_files_path;
+CREATE INDEX idx_files_listing ON files(workspace_id, file_path)
+    INCLUDE (id, content_hash, size_bytes, mime_type, last_modified, updated_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_files_listing;
+CREATE INDEX idx_files_path ON files(workspace_id, file_path);
+ALTER TABLE workspaces DROP COLUMN IF EXISTS file_count;
@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE workspaces ADD COLUMN archived_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE workspace_suggestions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason VARCHAR(20) NOT NULL, -- 'inactive'
+    last_activity_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    dismissed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(workspace_id, reason)
+);
+
+CREATE INDEX idx_workspace_suggestions_user_id ON workspace_suggestions(user_id);
+CREATE INDEX idx_workspaces_activity ON workspaces(updated_at) WHERE archived_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_workspaces_activity;
+DROP TABLE IF EXISTS workspace_suggestions;
+ALTER TABLE workspaces DROP COLUMN IF EXISTS archived_at;
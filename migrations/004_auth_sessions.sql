@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE auth_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind VARCHAR(20) NOT NULL, -- 'oauth_state', 'device'
+    session_key VARCHAR(255) NOT NULL, -- OAuth state or device code
+    user_code VARCHAR(20) UNIQUE, -- device flow only
+    provider VARCHAR(20), -- OAuth state only
+    device_code VARCHAR(255), -- device flow an OAuth state belongs to
+    device_name VARCHAR(100),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE, -- set once a device flow is approved
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(kind, session_key)
+);
+
+CREATE INDEX idx_auth_sessions_expires_at ON auth_sessions(expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS auth_sessions;
@@ -0,0 +1,39 @@
+-- +goose Up
+CREATE TYPE workspace_role AS ENUM ('owner', 'editor', 'viewer');
+
+-- workspaces.user_id stays the primary owner, whose tier sets the storage
+-- limit; access checks go through membership.
+CREATE TABLE workspace_members (
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role workspace_role NOT NULL,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX idx_workspace_members_user_id ON workspace_members(user_id);
+
+INSERT INTO workspace_members (workspace_id, user_id, role)
+SELECT id, user_id, 'owner' FROM workspaces;
+
+-- +goose StatementBegin
+CREATE FUNCTION add_workspace_owner() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO workspace_members (workspace_id, user_id, role)
+    VALUES (NEW.id, NEW.user_id, 'owner');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER workspaces_add_owner
+AFTER INSERT ON workspaces
+FOR EACH ROW EXECUTE FUNCTION add_workspace_owner();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS workspaces_add_owner ON workspaces;
+DROP FUNCTION IF EXISTS add_workspace_owner();
+DROP TABLE IF EXISTS workspace_members;
+DROP TYPE IF EXISTS workspace_role;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- IANA zone name used for day boundaries (daily notes, digests, stats).
+-- Stored timestamps stay TIMESTAMPTZ and the API always returns UTC.
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS timezone;
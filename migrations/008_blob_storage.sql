@@ -0,0 +1,35 @@
+-- +goose Up
+-- File content moves out of the metadata tables into a storage backend,
+-- keyed by content hash. This table backs the default "postgres" backend;
+-- existing content is copied into it so nothing changes until another
+-- backend is configured (see "noture-server storage migrate").
+CREATE TABLE blobs (
+    content_hash VARCHAR(64) PRIMARY KEY, -- SHA-256 of content
+    content BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+INSERT INTO blobs (content_hash, content)
+SELECT DISTINCT ON (content_hash) content_hash, content FROM files
+ON CONFLICT (content_hash) DO NOTHING;
+
+INSERT INTO blobs (content_hash, content)
+SELECT DISTINCT ON (content_hash) content_hash, content FROM file_versions
+ON CONFLICT (content_hash) DO NOTHING;
+
+ALTER TABLE files DROP COLUMN content;
+ALTER TABLE file_versions DROP COLUMN content;
+
+-- Blobs are deleted once neither a file nor a version refers to them.
+CREATE INDEX idx_file_versions_hash ON file_versions(content_hash);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_file_versions_hash;
+-- Only content held by the postgres backend can be restored.
+ALTER TABLE files ADD COLUMN content BYTEA;
+ALTER TABLE file_versions ADD COLUMN content BYTEA;
+
+UPDATE files f SET content = b.content FROM blobs b WHERE b.content_hash = f.content_hash;
+UPDATE file_versions v SET content = b.content FROM blobs b WHERE b.content_hash = v.content_hash;
+
+DROP TABLE IF EXISTS blobs;
@@ -0,0 +1,55 @@
+-- +goose Up
+-- Reference counts for content-addressed blobs. Every file and version row
+-- holds one reference to its content_hash; triggers keep the counts exact,
+-- including for cascaded deletes. Blobs whose count drops to zero are
+-- removed from the storage backend by a background job.
+CREATE TABLE blob_refs (
+    content_hash VARCHAR(64) PRIMARY KEY,
+    ref_count BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_blob_refs_unreferenced ON blob_refs(updated_at) WHERE ref_count <= 0;
+
+INSERT INTO blob_refs (content_hash, ref_count)
+SELECT content_hash, COUNT(*) FROM (
+    SELECT content_hash FROM files
+    UNION ALL
+    SELECT content_hash FROM file_versions
+) refs
+GROUP BY content_hash;
+
+-- +goose StatementBegin
+CREATE FUNCTION adjust_blob_refs() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO blob_refs (content_hash, ref_count)
+        VALUES (NEW.content_hash, 1)
+        ON CONFLICT (content_hash)
+        DO UPDATE SET ref_count = blob_refs.ref_count + 1, updated_at = NOW();
+    END IF;
+    IF TG_OP IN ('DELETE', 'UPDATE') THEN
+        UPDATE blob_refs SET ref_count = ref_count - 1, updated_at = NOW()
+        WHERE content_hash = OLD.content_hash;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER files_blob_refs
+AFTER INSERT OR DELETE OR UPDATE OF content_hash ON files
+FOR EACH ROW EXECUTE FUNCTION adjust_blob_refs();
+
+CREATE TRIGGER file_versions_blob_refs
+AFTER INSERT OR DELETE OR UPDATE OF content_hash ON file_versions
+FOR EACH ROW EXECUTE FUNCTION adjust_blob_refs();
+
+DROP INDEX IF EXISTS idx_file_versions_hash;
+
+-- +goose Down
+CREATE INDEX idx_file_versions_hash ON file_versions(content_hash);
+DROP TRIGGER IF EXISTS file_versions_blob_refs ON file_versions;
+DROP TRIGGER IF EXISTS files_blob_refs ON files;
+DROP FUNCTION IF EXISTS adjust_blob_refs();
+DROP TABLE IF EXISTS blob_refs;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Presentation metadata so workspace pickers look the same on every client.
+ALTER TABLE workspaces ADD COLUMN icon VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE workspaces ADD COLUMN color VARCHAR(7) NOT NULL DEFAULT '';
+ALTER TABLE workspaces ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE workspaces ADD COLUMN sort_order INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE workspaces DROP COLUMN IF EXISTS sort_order;
+ALTER TABLE workspaces DROP COLUMN IF EXISTS description;
+ALTER TABLE workspaces DROP COLUMN IF EXISTS color;
+ALTER TABLE workspaces DROP COLUMN IF EXISTS icon;
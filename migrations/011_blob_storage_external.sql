@@ -0,0 +1,8 @@
+-- +goose Up
+-- Keep blob content uncompressed out of line so substring() reads used for
+-- streaming and range requests only fetch the TOAST chunks they need.
+-- Existing rows keep their storage until rewritten.
+ALTER TABLE blobs ALTER COLUMN content SET STORAGE EXTERNAL;
+
+-- +goose Down
+ALTER TABLE blobs ALTER COLUMN content SET STORAGE EXTENDED;
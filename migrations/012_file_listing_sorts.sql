@@ -0,0 +1,13 @@
+-- +goose Up
+-- Keyset pagination compares (updated_at, file_path) rows, which never
+-- match a NULL.
+UPDATE files SET updated_at = COALESCE(created_at, last_modified) WHERE updated_at IS NULL;
+ALTER TABLE files ALTER COLUMN updated_at SET NOT NULL;
+
+CREATE INDEX idx_files_updated ON files(workspace_id, updated_at DESC, file_path DESC);
+CREATE INDEX idx_files_size ON files(workspace_id, size_bytes DESC, file_path DESC);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_files_size;
+DROP INDEX IF EXISTS idx_files_updated;
+ALTER TABLE files ALTER COLUMN updated_at DROP NOT NULL;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Bumped by every file change, so clients can tell whether they have seen
+-- the workspace's latest state.
+ALTER TABLE workspaces ADD COLUMN revision BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE workspaces DROP COLUMN IF EXISTS revision;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Failed uploads never get a file row, so the path is recorded on the
+-- operation itself.
+ALTER TABLE sync_operations ADD COLUMN file_path TEXT;
+UPDATE sync_operations s SET file_path = f.file_path FROM files f WHERE f.id = s.file_id;
+
+UPDATE sync_operations SET created_at = NOW() WHERE created_at IS NULL;
+ALTER TABLE sync_operations ALTER COLUMN created_at SET NOT NULL;
+
+CREATE INDEX idx_sync_operations_listing ON sync_operations(workspace_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_sync_operations_listing;
+ALTER TABLE sync_operations ALTER COLUMN created_at DROP NOT NULL;
+ALTER TABLE sync_operations DROP COLUMN IF EXISTS file_path;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- A device is one installation of a client. Its tokens go with it, so
+-- revoking a lost laptop signs out only that laptop.
+CREATE TABLE devices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_devices_user_id ON devices(user_id);
+
+ALTER TABLE api_tokens ADD COLUMN device_id UUID REFERENCES devices(id) ON DELETE CASCADE;
+CREATE INDEX idx_api_tokens_device_id ON api_tokens(device_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_api_tokens_device_id;
+ALTER TABLE api_tokens DROP COLUMN IF EXISTS device_id;
+DROP TABLE IF EXISTS devices;
@@ -0,0 +1,53 @@
+-- +goose Up
+-- workspace_events is the outbox: events are written in the transaction
+-- that causes them and delivered afterwards, in id order.
+CREATE TABLE workspace_events (
+    id BIGSERIAL PRIMARY KEY,
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    file_path TEXT,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_workspace_events_workspace ON workspace_events(workspace_id, id);
+
+-- Each webhook delivers its workspace's events in order; last_event_id is
+-- how far it got.
+CREATE TABLE workspace_webhooks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    path_prefix TEXT NOT NULL DEFAULT '',
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    last_event_id BIGINT NOT NULL DEFAULT 0,
+    failure_count INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_workspace_webhooks_workspace ON workspace_webhooks(workspace_id);
+
+-- The tasks last seen in each file, to tell which changed on upload and
+-- which deadlines still need a reminder.
+CREATE TABLE file_tasks (
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    task_key TEXT NOT NULL,
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    done BOOLEAN NOT NULL DEFAULT FALSE,
+    deadline TIMESTAMP WITH TIME ZONE,
+    reminded_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (file_id, task_key)
+);
+
+CREATE INDEX idx_file_tasks_due ON file_tasks(deadline) WHERE NOT done AND reminded_at IS NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS file_tasks;
+DROP TABLE IF EXISTS workspace_webhooks;
+DROP TABLE IF EXISTS workspace_events;
@@ -0,0 +1,31 @@
+-- +goose Up
+-- Full-text index of note content, written with each upload. Files stored
+-- before this migration are indexed when they are next uploaded.
+CREATE TABLE file_search (
+    file_id UUID PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    document TSVECTOR NOT NULL
+);
+
+CREATE INDEX idx_file_search_document ON file_search USING GIN(document);
+CREATE INDEX idx_file_search_workspace ON file_search(workspace_id);
+
+-- A snapshot pins the ranked results of one search at one workspace
+-- revision, so paging through them neither repeats nor skips files while
+-- the workspace changes underneath.
+CREATE TABLE search_snapshots (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    query TEXT NOT NULL,
+    revision BIGINT NOT NULL,
+    file_ids UUID[] NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_search_snapshots_expires ON search_snapshots(expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS search_snapshots;
+DROP TABLE IF EXISTS file_search;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- The hash of the content each entry was built from. Files with no entry,
+-- or one built from older content, are indexed in the background, most
+-- recently modified first.
+ALTER TABLE file_search ADD COLUMN content_hash VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_files_last_modified ON files(last_modified DESC);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_files_last_modified;
+ALTER TABLE file_search DROP COLUMN IF EXISTS content_hash;
@@ -0,0 +1,31 @@
+-- +goose Up
+-- Data loss prevention rules, managed by server admins and evaluated
+-- before content is shared publicly.
+CREATE TABLE policy_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    detector VARCHAR(50) NOT NULL,
+    pattern TEXT NOT NULL DEFAULT '',
+    action VARCHAR(20) NOT NULL CHECK (action IN ('block', 'audit')),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Audit trail of every rule match. Rows outlive the rule and the file, so
+-- names are copied rather than referenced.
+CREATE TABLE policy_violations (
+    id BIGSERIAL PRIMARY KEY,
+    rule_id UUID REFERENCES policy_rules(id) ON DELETE SET NULL,
+    rule_name VARCHAR(100) NOT NULL,
+    workspace_id UUID REFERENCES workspaces(id) ON DELETE SET NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    file_path VARCHAR(1000) NOT NULL,
+    action_type VARCHAR(20) NOT NULL,
+    blocked BOOLEAN NOT NULL,
+    match_count INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS policy_violations;
+DROP TABLE IF EXISTS policy_rules;
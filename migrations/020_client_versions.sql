@@ -0,0 +1,9 @@
+-- +goose Up
+-- The client name and version a token was last used with, from the
+-- X-Client-Name and X-Client-Version headers.
+ALTER TABLE api_tokens ADD COLUMN client_name VARCHAR(100);
+ALTER TABLE api_tokens ADD COLUMN client_version VARCHAR(50);
+
+-- +goose Down
+ALTER TABLE api_tokens DROP COLUMN IF EXISTS client_version;
+ALTER TABLE api_tokens DROP COLUMN IF EXISTS client_name;
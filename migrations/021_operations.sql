@@ -0,0 +1,29 @@
+-- +goose Up
+-- Write-ahead records of long-running, multi-step operations such as
+-- imports and restores. The worker checkpoints state after every step, so
+-- an operation interrupted by a crash or deploy resumes where it stopped
+-- once its lease expires.
+CREATE TABLE operations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind VARCHAR(50) NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    workspace_id UUID REFERENCES workspaces(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    params JSONB NOT NULL DEFAULT '{}',
+    state JSONB NOT NULL DEFAULT '{}',
+    steps_done INTEGER NOT NULL DEFAULT 0,
+    steps_total INTEGER NOT NULL DEFAULT 0,
+    result JSONB,
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    lease_expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_operations_runnable ON operations(created_at) WHERE status IN ('pending', 'running');
+CREATE INDEX idx_operations_finished ON operations(finished_at) WHERE finished_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS operations;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Replay reads a workspace's events and policy audit trail by time window.
+CREATE INDEX idx_workspace_events_created ON workspace_events(workspace_id, created_at);
+CREATE INDEX idx_policy_violations_workspace ON policy_violations(workspace_id, created_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_policy_violations_workspace;
+DROP INDEX IF EXISTS idx_workspace_events_created;
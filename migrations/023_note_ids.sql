@@ -0,0 +1,14 @@
+-- +goose Up
+-- Stable note IDs, from the client or the note's frontmatter. They let a
+-- rename done as create-then-delete keep the file's history. Several files
+-- may share an ID for a while, e.g. while a note is being copied.
+CREATE TABLE file_note_ids (
+    file_id UUID PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    note_id VARCHAR(200) NOT NULL
+);
+
+CREATE INDEX idx_file_note_ids_note ON file_note_ids(workspace_id, note_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS file_note_ids;
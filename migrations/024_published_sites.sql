@@ -0,0 +1,16 @@
+-- +goose Up
+-- Workspaces published as static sites at /p/{slug}/. Folders are path
+-- prefixes; with require_flag only notes marked publish: true are shown.
+CREATE TABLE published_sites (
+    workspace_id UUID PRIMARY KEY REFERENCES workspaces(id) ON DELETE CASCADE,
+    slug VARCHAR(64) UNIQUE NOT NULL,
+    include_folders TEXT[] NOT NULL DEFAULT '{}',
+    exclude_folders TEXT[] NOT NULL DEFAULT '{}',
+    require_flag BOOLEAN NOT NULL DEFAULT FALSE,
+    published_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS published_sites;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Tokens issued at login used to be stored hex-encoded rather than hashed,
+-- so the middleware, which looks tokens up by SHA-256, never matched them.
+-- The encoding is reversible, so each one is replaced by the SHA-256 of
+-- the token it encodes: 64-character tokens encode to 128 hex digits.
+UPDATE api_tokens
+SET token_hash = encode(sha256(decode(token_hash, 'hex')), 'hex')
+WHERE length(token_hash) = 128 AND token_hash ~ '^[0-9a-f]+$';
+
+-- +goose Down
+-- Hashes cannot be turned back into tokens; the rows stay valid for the
+-- middleware either way.
+SELECT 1;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- The provider accounts a user signs in with. Sign-in looks the provider's
+-- stable user ID up here first, so changing the email at a provider, or
+-- signing in with a provider whose email differs, reaches the same user.
+CREATE TABLE oauth_identities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL,
+    provider_user_id VARCHAR(255) NOT NULL,
+    email VARCHAR(255), -- as last reported by the provider
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    last_login_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE(provider, provider_user_id),
+    UNIQUE(user_id, provider)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS oauth_identities;
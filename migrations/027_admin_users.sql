@@ -0,0 +1,13 @@
+-- +goose Up
+-- Operator controls on accounts. Admins may use /api/admin alongside the
+-- configured admin_emails. storage_limit_bytes overrides the tier's limit
+-- for each of the user's workspaces, and a disabled user's tokens stop
+-- working until the account is enabled again.
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN storage_limit_bytes BIGINT;
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP WITH TIME ZONE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS disabled_at;
+ALTER TABLE users DROP COLUMN IF EXISTS storage_limit_bytes;
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
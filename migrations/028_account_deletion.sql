@@ -0,0 +1,11 @@
+-- +goose Up
+-- Accounts whose owner asked for deletion. The account and everything it
+-- owns is purged once delete_after passes; signing in again and restoring
+-- the account before then cancels it.
+ALTER TABLE users ADD COLUMN delete_after TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_users_delete_after ON users(delete_after) WHERE delete_after IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_delete_after;
+ALTER TABLE users DROP COLUMN IF EXISTS delete_after;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- Invitations to join a workspace, sent by email. Only the SHA-256 of the
+-- token in the link is stored. Each address has at most one pending
+-- invite per workspace; inviting it again replaces the token.
+CREATE TABLE workspace_invites (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role workspace_role NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    invited_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    accepted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_workspace_invites_pending ON workspace_invites(workspace_id, email) WHERE accepted_at IS NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS workspace_invites;
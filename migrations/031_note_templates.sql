@@ -0,0 +1,16 @@
+-- +goose Up
+-- Note templates of a workspace. Placeholders such as {{date}} and
+-- {{title}} are filled in when a note is created from one.
+CREATE TABLE note_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(workspace_id, name)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS note_templates;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Where a workspace keeps its daily notes and which template new ones
+-- start from. Workspaces without a row use the defaults.
+CREATE TABLE daily_note_settings (
+    workspace_id UUID PRIMARY KEY REFERENCES workspaces(id) ON DELETE CASCADE,
+    path_pattern VARCHAR(255) NOT NULL,
+    template_name VARCHAR(255) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS daily_note_settings;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- Dated items found in notes, for agendas. Dates are calendar days as
+-- written in the note, without a zone; time_of_day is empty for all-day
+-- items.
+CREATE TABLE agenda_entries (
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    line INTEGER NOT NULL,
+    kind VARCHAR(16) NOT NULL, -- 'scheduled', 'deadline', 'timestamp'
+    day DATE NOT NULL,
+    time_of_day VARCHAR(5) NOT NULL DEFAULT '',
+    title TEXT NOT NULL,
+    keyword VARCHAR(16) NOT NULL DEFAULT '',
+    done BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (file_id, line, kind, day, time_of_day)
+);
+
+CREATE INDEX idx_agenda_entries_day ON agenda_entries(workspace_id, day);
+
+-- +goose Down
+DROP TABLE IF EXISTS agenda_entries;
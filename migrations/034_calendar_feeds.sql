@@ -0,0 +1,16 @@
+-- +goose Up
+-- Subscribable calendar feeds of a workspace's agenda. A feed belongs to
+-- the member who created it and stops working once they leave.
+CREATE TABLE calendar_feeds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    token_hash VARCHAR(64) UNIQUE NOT NULL, -- SHA-256 of the feed token
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    last_fetched_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_calendar_feeds_user ON calendar_feeds(user_id, workspace_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS calendar_feeds;
@@ -0,0 +1,28 @@
+-- +goose Up
+-- Links between notes and the tags of notes, written with each upload.
+-- A link keeps its target as written and the paths it may name, most
+-- specific first; it points at the first of them that exists. Files
+-- stored before this migration are indexed when they are next uploaded.
+CREATE TABLE note_links (
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    line INTEGER NOT NULL,
+    target TEXT NOT NULL,
+    candidates TEXT[] NOT NULL,
+    PRIMARY KEY (file_id, line, target)
+);
+
+CREATE INDEX idx_note_links_candidates ON note_links USING GIN(candidates);
+
+CREATE TABLE note_tags (
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    tag VARCHAR(255) NOT NULL,
+    PRIMARY KEY (file_id, tag)
+);
+
+CREATE INDEX idx_note_tags_tag ON note_tags(workspace_id, tag);
+
+-- +goose Down
+DROP TABLE IF EXISTS note_tags;
+DROP TABLE IF EXISTS note_links;
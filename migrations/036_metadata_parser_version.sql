@@ -0,0 +1,13 @@
+-- +goose Up
+-- The parser version a file's metadata was built with. Files behind the
+-- server's version, or without metadata, are reparsed in the background,
+-- rebuilding their agenda entries, links, tags and search entry. Rows
+-- from before this migration start at 0, so every stored file is reparsed
+-- once.
+ALTER TABLE file_metadata ADD COLUMN parser_version INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_file_metadata_parser_version ON file_metadata(parser_version);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_file_metadata_parser_version;
+ALTER TABLE file_metadata DROP COLUMN IF EXISTS parser_version;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- When each member last opened each file, as reported by clients, for
+-- recent-file pickers. Only a member's most recent opens per workspace
+-- are kept.
+CREATE TABLE file_opens (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    opened_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, file_id)
+);
+
+CREATE INDEX idx_file_opens_recent ON file_opens(user_id, workspace_id, opened_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS file_opens;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Files each member pinned, for a favorites section that follows them
+-- across devices. Pins follow a file across moves and go with it when it
+-- is deleted.
+CREATE TABLE file_pins (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    pinned_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, file_id)
+);
+
+CREATE INDEX idx_file_pins_workspace ON file_pins(user_id, workspace_id, pinned_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS file_pins;
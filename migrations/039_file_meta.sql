@@ -0,0 +1,12 @@
+-- +goose Up
+-- Small key-value metadata that clients attach to files, such as color
+-- labels or where the editor left off. It follows a file across moves and
+-- goes with it when it is deleted.
+CREATE TABLE file_meta (
+    file_id UUID PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+    meta JSONB NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS file_meta;
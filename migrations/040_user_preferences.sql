@@ -0,0 +1,14 @@
+-- +goose Up
+-- Settings a user's clients share: locale, the workspace to open, which
+-- notifications to send and an opaque blob of editor settings.
+CREATE TABLE user_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    locale TEXT NOT NULL DEFAULT '',
+    default_workspace_id UUID REFERENCES workspaces(id) ON DELETE SET NULL,
+    notifications JSONB NOT NULL DEFAULT '{}',
+    editor JSONB NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_preferences;
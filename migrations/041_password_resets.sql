@@ -0,0 +1,13 @@
+-- +goose Up
+-- Password reset requests, sent by email. Only the SHA-256 of the token
+-- is stored. A user has at most one outstanding reset; asking again
+-- replaces it.
+CREATE TABLE password_resets (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS password_resets;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- When the user proved they own their email address. Accounts from before
+-- verification existed count as verified.
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+UPDATE users SET email_verified_at = COALESCE(created_at, NOW());
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- WebAuthn credentials. credential_id is the authenticator's ID of the
+-- credential and public_key its COSE key; sign_count is the last
+-- signature counter seen, used to spot cloned credentials.
+CREATE TABLE passkeys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id BYTEA UNIQUE NOT NULL,
+    public_key BYTEA NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_passkeys_user ON passkeys(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS passkeys;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- What a token may do, for tokens users mint for scripts and
+-- integrations. Tokens from signing in have none and full access.
+ALTER TABLE api_tokens ADD COLUMN scopes TEXT[];
+
+-- +goose Down
+ALTER TABLE api_tokens DROP COLUMN IF EXISTS scopes;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Where a token was last used from, recorded as requests authenticate so
+-- that users can recognize their sessions.
+ALTER TABLE api_tokens ADD COLUMN last_ip VARCHAR(64);
+ALTER TABLE api_tokens ADD COLUMN user_agent VARCHAR(255);
+
+-- +goose Down
+ALTER TABLE api_tokens DROP COLUMN IF EXISTS user_agent;
+ALTER TABLE api_tokens DROP COLUMN IF EXISTS last_ip;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Consecutive failed sign-ins per account or client IP, keyed like
+-- "account:<email>" or "ip:<address>". locked_until is when the next
+-- attempt is allowed.
+CREATE TABLE auth_failures (
+    key VARCHAR(300) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    last_failed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_auth_failures_last_failed ON auth_failures(last_failed_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS auth_failures;
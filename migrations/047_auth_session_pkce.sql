@@ -0,0 +1,12 @@
+-- +goose Up
+-- PKCE (RFC 7636) for OAuth sign-ins. code_verifier is the server's own,
+-- sent to the provider when exchanging its code; code_challenge is the
+-- client's, which the sign-in's authorization code must be redeemed with.
+ALTER TABLE auth_sessions
+    ADD COLUMN code_verifier VARCHAR(128),
+    ADD COLUMN code_challenge VARCHAR(128);
+
+-- +goose Down
+ALTER TABLE auth_sessions
+    DROP COLUMN IF EXISTS code_challenge,
+    DROP COLUMN IF EXISTS code_verifier;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- API requests and request and response body bytes per user and calendar
+-- month (UTC), for metering and overage reports. Servers count in memory
+-- and add their counts here every minute.
+CREATE TABLE usage_monthly (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    month DATE NOT NULL, -- the first day of the month
+    api_requests BIGINT NOT NULL DEFAULT 0,
+    upload_bytes BIGINT NOT NULL DEFAULT 0,
+    download_bytes BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, month)
+);
+
+CREATE INDEX idx_usage_monthly_month ON usage_monthly(month);
+
+-- +goose Down
+DROP TABLE IF EXISTS usage_monthly;
@@ -0,0 +1,44 @@
+-- +goose Up
+-- Organizations own workspaces and are billed together. Their workspaces
+-- share one storage pool: storage_limit_bytes when administrators set it,
+-- otherwise seat_storage_bytes (the storage of the tier) per member.
+CREATE TYPE organization_role AS ENUM ('owner', 'admin', 'member');
+
+CREATE TABLE organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    billing_email VARCHAR(255) NOT NULL,
+    tier user_tier NOT NULL DEFAULT 'free',
+    seat_storage_bytes BIGINT NOT NULL,
+    storage_limit_bytes BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE organization_members (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role organization_role NOT NULL,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX idx_organization_members_user_id ON organization_members(user_id);
+
+-- A workspace belongs to at most one organization. Organizations with
+-- workspaces cannot be deleted.
+CREATE TABLE organization_workspaces (
+    workspace_id UUID PRIMARY KEY REFERENCES workspaces(id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE RESTRICT,
+    added_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_organization_workspaces_organization_id ON organization_workspaces(organization_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS organization_workspaces;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
+DROP TYPE IF EXISTS organization_role;
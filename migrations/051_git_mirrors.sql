@@ -0,0 +1,28 @@
+-- +goose Up
+-- A workspace's mirror to a Git repository. The server pushes the
+-- workspace's files to the branch as it changes and, with pull, brings the
+-- branch's changes back. private_key is the deploy key the server
+-- authenticates with; users add public_key to the repository. last_commit
+-- is the commit both sides last agreed on, and last_event_id how far into
+-- the workspace's events that sync got.
+CREATE TABLE workspace_git_mirrors (
+    workspace_id UUID PRIMARY KEY REFERENCES workspaces(id) ON DELETE CASCADE,
+    remote_url TEXT NOT NULL,
+    branch TEXT NOT NULL,
+    pull BOOLEAN NOT NULL DEFAULT FALSE,
+    private_key TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    last_event_id BIGINT NOT NULL DEFAULT 0,
+    last_commit TEXT,
+    last_synced_at TIMESTAMP WITH TIME ZONE,
+    sync_requested_at TIMESTAMP WITH TIME ZONE,
+    failure_count INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS workspace_git_mirrors;
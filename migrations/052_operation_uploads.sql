@@ -0,0 +1,12 @@
+-- +goose Up
+-- Files a request hands to a background operation, such as the archive of
+-- an import, which are too large for the operation's params. An upload is
+-- deleted once its operation finishes, and with the operation otherwise.
+CREATE TABLE operation_uploads (
+    operation_id UUID PRIMARY KEY REFERENCES operations(id) ON DELETE CASCADE,
+    content BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS operation_uploads;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Where a workspace sits in the sidebar is each member's own choice, so it
+-- moves out of workspaces. Owners keep the order they had set.
+CREATE TABLE workspace_sort_orders (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    sort_order INTEGER NOT NULL,
+    PRIMARY KEY (user_id, workspace_id)
+);
+
+INSERT INTO workspace_sort_orders (user_id, workspace_id, sort_order)
+SELECT user_id, id, sort_order FROM workspaces WHERE sort_order <> 0;
+
+ALTER TABLE workspaces DROP COLUMN sort_order;
+
+-- +goose Down
+ALTER TABLE workspaces ADD COLUMN sort_order INTEGER NOT NULL DEFAULT 0;
+
+UPDATE workspaces w SET sort_order = o.sort_order
+FROM workspace_sort_orders o
+WHERE o.workspace_id = w.id AND o.user_id = w.user_id;
+
+DROP TABLE IF EXISTS workspace_sort_orders;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Files the search backfill could not index, such as those whose blob
+-- cannot be read. The backfill retries a file after the files it has not
+-- tried yet and gives up after a few attempts; new content, with a new
+-- hash, starts over.
+CREATE TABLE file_search_failures (
+    file_id UUID PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+    content_hash VARCHAR(64) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    last_error TEXT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS file_search_failures;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- The content a share link last passed the content policy with. Links
+-- serve a file's current content, so content with another hash is checked
+-- again before it is served; links whose content was blocked stay closed
+-- until the file changes. Existing links are checked on their next view.
+ALTER TABLE share_links ADD COLUMN policy_checked_hash VARCHAR(64);
+ALTER TABLE share_links ADD COLUMN policy_blocked BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE share_links DROP COLUMN IF EXISTS policy_blocked;
+ALTER TABLE share_links DROP COLUMN IF EXISTS policy_checked_hash;
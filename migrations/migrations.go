@@ -0,0 +1,10 @@
+// Package migrations embeds the database migrations, so the server can
+// bring its schema up to date without the files being deployed with it.
+package migrations
+
+import "embed"
+
+// Files holds every NNN_name.sql migration, in goose format.
+//
+//go:embed *.sql
+var Files embed.FS
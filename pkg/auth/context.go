@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/duckonomy/noture/internal/domain"
+)
+
+// contextKey is unexported so only this package can set or read the
+// caller's identity.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the authenticated caller.
+func NewContext(ctx context.Context, authCtx *domain.AuthContext) context.Context {
+	return context.WithValue(ctx, contextKey{}, authCtx)
+}
+
+// FromContext returns the caller stored by RequireAuth or OptionalAuth.
+// ok is false when the request was not authenticated.
+func FromContext(ctx context.Context) (authCtx *domain.AuthContext, ok bool) {
+	authCtx, ok = ctx.Value(contextKey{}).(*domain.AuthContext)
+	return authCtx, ok && authCtx != nil
+}
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	_, ok = FromContext(context.WithValue(context.Background(), "auth", &domain.AuthContext{}))
+	assert.False(t, ok, "only NewContext sets the caller")
+
+	_, ok = FromContext(NewContext(context.Background(), nil))
+	assert.False(t, ok)
+
+	userID := uuid.New()
+	authCtx, ok := FromContext(NewContext(context.Background(), &domain.AuthContext{UserID: userID}))
+	require.True(t, ok)
+	assert.Equal(t, userID, authCtx.UserID)
+}
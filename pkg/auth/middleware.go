@@ -2,26 +2,51 @@ package auth
 
 import (
 	"context"
-	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/duckonomy/noture/internal/db"
 	"github.com/duckonomy/noture/internal/domain"
+	"github.com/duckonomy/noture/internal/ratelimit"
 	"github.com/duckonomy/noture/pkg/pgconv"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type AuthMiddleware struct {
 	queries *db.Queries
+	admins  map[string]bool
+	limiter *ratelimit.Limiter
 }
 
-func NewAuthMiddleware(queries *db.Queries) *AuthMiddleware {
+// NewAuthMiddleware builds the middleware. adminEmails lists the accounts
+// allowed to use the operator endpoints under /api/admin.
+func NewAuthMiddleware(queries *db.Queries, adminEmails []string) *AuthMiddleware {
+	admins := make(map[string]bool)
+	for _, email := range adminEmails {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			admins[email] = true
+		}
+	}
+
 	return &AuthMiddleware{
 		queries: queries,
+		admins:  admins,
 	}
 }
 
+// WithRateLimiter limits authenticated requests per token by the user's
+// tier, and requests with an invalid token per client IP.
+func (a *AuthMiddleware) WithRateLimiter(limiter *ratelimit.Limiter) *AuthMiddleware {
+	a.limiter = limiter
+	return a
+}
+
 func (a *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
@@ -41,16 +66,23 @@ func (a *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		hash := sha256.Sum256([]byte(token))
-		tokenHash := fmt.Sprintf("%x", hash)
-
-		tokenInfo, err := a.queries.GetTokenByHash(r.Context(), tokenHash)
+		tokenInfo, err := a.lookupToken(r.Context(), token)
 		if err != nil {
+			if a.limiter != nil && !a.limiter.AllowIP(w, r) {
+				return
+			}
+			if errors.Is(err, ErrTokenExpired) {
+				writeTokenExpired(w)
+				return
+			}
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
+		if a.limiter != nil && !a.limiter.AllowToken(w, r, pgconv.PgToUUID(tokenInfo.ID), domain.UserTier(tokenInfo.Tier)) {
+			return
+		}
 
-		err = a.queries.UpdateTokenLastUsed(r.Context(), tokenInfo.ID)
+		err = a.queries.UpdateTokenLastUsed(r.Context(), a.lastUsedParams(r, tokenInfo.ID))
 		if err != nil {
 			// Don't fail the request for this, just log it
 			// TODO: add proper logging
@@ -62,6 +94,8 @@ func (a *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 				Email:            tokenInfo.Email,
 				Tier:             domain.UserTier(tokenInfo.Tier),
 				StorageUsedBytes: 0, // TODO: get from user table if needed
+				IsAdmin:          tokenInfo.IsAdmin,
+				EmailVerified:    tokenInfo.EmailVerified,
 			},
 			Token: domain.APIToken{
 				ID:         pgconv.PgToUUID(tokenInfo.ID),
@@ -70,14 +104,93 @@ func (a *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 				LastUsedAt: pgconv.PgToTimePtr(tokenInfo.LastUsedAt),
 				ExpiresAt:  pgconv.PgToTimePtr(tokenInfo.ExpiresAt),
 				CreatedAt:  pgconv.PgToTime(tokenInfo.CreatedAt),
+				DeviceID:   pgconv.PgToUUIDPtr(tokenInfo.DeviceID),
+				Scopes:     tokenInfo.Scopes,
 			},
 			UserID:    pgconv.PgToUUID(tokenInfo.UserID),
 			UserEmail: tokenInfo.Email,
 			UserTier:  domain.UserTier(tokenInfo.Tier),
+			IsAdmin:   tokenInfo.IsAdmin,
+		}
+
+		if reason := scopeDenial(r, authCtx.Token.Scopes); reason != "" {
+			http.Error(w, reason, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), authCtx)))
+	}
+}
+
+// workspaceNeutralRoutes name no workspace yet may be used by tokens
+// limited to workspaces. Their handlers filter what such tokens see.
+var workspaceNeutralRoutes = map[string]bool{
+	"GET /api/me":         true,
+	"GET /api/workspaces": true,
+}
+
+// scopeDenial returns why a token with scopes may not make request r, or
+// "" when it may. Tokens limited to workspaces may only use routes naming
+// one of them, besides workspaceNeutralRoutes.
+func scopeDenial(r *http.Request, scopes domain.TokenScopes) string {
+	if !scopes.Restricted() {
+		return ""
+	}
+	if !scopes.AllowsMethod(r.Method) {
+		return "Token scope does not allow writes"
+	}
+	if scopes.Workspaces() == nil {
+		return ""
+	}
+	workspaceID, named := requestWorkspace(r)
+	if !named {
+		if workspaceNeutralRoutes[r.Pattern] {
+			return ""
+		}
+		return "Token scope is limited to workspaces"
+	}
+	if !scopes.AllowsWorkspace(workspaceID) {
+		return "Token scope does not allow this workspace"
+	}
+	return ""
+}
+
+// requestWorkspace returns the workspace the route of r names, as
+// {workspace_id} or as the {id} of /api/workspaces/{id}. A malformed ID
+// is named but matches no workspace.
+func requestWorkspace(r *http.Request) (uuid.UUID, bool) {
+	value := r.PathValue("workspace_id")
+	if value == "" {
+		_, path, _ := strings.Cut(r.Pattern, " ")
+		if !strings.HasPrefix(path, "/api/workspaces/{id}") {
+			return uuid.Nil, false
+		}
+		value = r.PathValue("id")
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, true
+	}
+	return id, true
+}
+
+// RequireFullAccess refuses tokens with scopes, for routes that manage
+// the account itself: its credentials, devices, tokens and data. It must
+// wrap a handler that is already behind RequireAuth.
+func (a *AuthMiddleware) RequireFullAccess(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth, ok := FromContext(r.Context())
+		if !ok {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		if auth.Token.Scopes.Restricted() {
+			http.Error(w, "Full-access token required", http.StatusForbidden)
+			return
 		}
 
-		ctx := context.WithValue(r.Context(), "auth", authCtx)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r)
 	}
 }
 
@@ -95,44 +208,116 @@ func (a *AuthMiddleware) OptionalAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		hash := sha256.Sum256([]byte(token))
-		tokenHash := fmt.Sprintf("%x", hash)
-
-		tokenInfo, err := a.queries.GetTokenByHash(r.Context(), tokenHash)
+		tokenInfo, err := a.lookupToken(r.Context(), token)
 		if err != nil {
+			if a.limiter != nil && !a.limiter.AllowIP(w, r) {
+				return
+			}
 			next.ServeHTTP(w, r)
 			return
 		}
+		if a.limiter != nil && !a.limiter.AllowToken(w, r, pgconv.PgToUUID(tokenInfo.ID), domain.UserTier(tokenInfo.Tier)) {
+			return
+		}
 
-		a.queries.UpdateTokenLastUsed(r.Context(), tokenInfo.ID)
+		a.queries.UpdateTokenLastUsed(r.Context(), a.lastUsedParams(r, tokenInfo.ID))
 
 		authCtx := &domain.AuthContext{
 			User: domain.User{
-				ID:    pgconv.PgToUUID(tokenInfo.UserID),
-				Email: tokenInfo.Email,
-				Tier:  domain.UserTier(tokenInfo.Tier),
+				ID:            pgconv.PgToUUID(tokenInfo.UserID),
+				Email:         tokenInfo.Email,
+				Tier:          domain.UserTier(tokenInfo.Tier),
+				IsAdmin:       tokenInfo.IsAdmin,
+				EmailVerified: tokenInfo.EmailVerified,
 			},
 			UserID:    pgconv.PgToUUID(tokenInfo.UserID),
 			UserEmail: tokenInfo.Email,
 			UserTier:  domain.UserTier(tokenInfo.Tier),
+			IsAdmin:   tokenInfo.IsAdmin,
 		}
 
-		ctx := context.WithValue(r.Context(), "auth", authCtx)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), authCtx)))
+	}
+}
+
+// ErrTokenExpired means a request presented a token past its expiry.
+var ErrTokenExpired = errors.New("token expired")
+
+// lookupToken finds the live token a request presents. The index finds the
+// row by hash; the hash is compared again in constant time so the result
+// never depends on how much of a guess matched. Expiry is checked against
+// both the database's clock and ours, so neither's skew extends a token.
+func (a *AuthMiddleware) lookupToken(ctx context.Context, token string) (db.GetTokenByHashRow, error) {
+	tokenHash := HashToken(token)
+	tokenInfo, err := a.queries.GetTokenByHash(ctx, tokenHash)
+	if err != nil {
+		return db.GetTokenByHashRow{}, err
+	}
+	if !tokenHashMatches(tokenInfo.TokenHash, tokenHash) {
+		return db.GetTokenByHashRow{}, fmt.Errorf("invalid token")
+	}
+	if tokenInfo.Expired || (tokenInfo.ExpiresAt.Valid && !tokenInfo.ExpiresAt.Time.After(time.Now())) {
+		return db.GetTokenByHashRow{}, ErrTokenExpired
+	}
+	return tokenInfo, nil
+}
+
+// writeTokenExpired answers a request with an expired token. Unlike other
+// bad tokens it carries an error code, so clients can ask the user to
+// sign in again or mint a new token rather than report a failure.
+func writeTokenExpired(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token", error_description="token expired"`)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   "token_expired",
+		"message": "Token expired",
+	})
+}
+
+// lastUsedParams records where the request came from and the client it
+// identifies itself as, keeping the previous client when the headers are
+// absent or malformed.
+func (a *AuthMiddleware) lastUsedParams(r *http.Request, tokenID pgtype.UUID) db.UpdateTokenLastUsedParams {
+	params := db.UpdateTokenLastUsedParams{ID: tokenID}
+	if ip := net.ParseIP(a.clientIP(r)); ip != nil {
+		params.LastIp = pgconv.StringToPg(ip.String())
+	}
+	if userAgent := strings.ToValidUTF8(r.UserAgent(), ""); userAgent != "" {
+		if len(userAgent) > domain.MaxUserAgentLength {
+			userAgent = strings.ToValidUTF8(userAgent[:domain.MaxUserAgentLength], "")
+		}
+		params.UserAgent = pgconv.StringToPg(userAgent)
 	}
+	name := r.Header.Get(domain.ClientNameHeader)
+	version := r.Header.Get(domain.ClientVersionHeader)
+	if _, err := domain.ParseClientVersion(version); err == nil && domain.ValidClientName(name) {
+		params.ClientName = pgconv.StringToPg(name)
+		params.ClientVersion = pgconv.StringToPg(version)
+	}
+	return params
+}
+
+func (a *AuthMiddleware) clientIP(r *http.Request) string {
+	if a.limiter != nil {
+		return a.limiter.ClientIP(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
 
 func (a *AuthMiddleware) RequireTier(tier domain.UserTier) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			authCtx := r.Context().Value("auth")
-			if authCtx == nil {
+			auth, ok := FromContext(r.Context())
+			if !ok {
 				http.Error(w, "Authentication required", http.StatusUnauthorized)
 				return
 			}
 
-			auth := authCtx.(*domain.AuthContext)
-
 			userTierLevel := getTierLevel(auth.UserTier)
 			requiredTierLevel := getTierLevel(tier)
 
@@ -146,6 +331,38 @@ func (a *AuthMiddleware) RequireTier(tier domain.UserTier) func(http.HandlerFunc
 	}
 }
 
+// RequireAdmin lets through users with the admin role and the accounts in
+// adminEmails, unless their token's scopes lack admin. An adminEmails
+// account must have verified its email: anyone can register an address
+// that has not signed up yet. It must wrap a handler that is already
+// behind RequireAuth.
+func (a *AuthMiddleware) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth, ok := FromContext(r.Context())
+		if !ok {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		if !auth.IsAdmin {
+			if !a.admins[strings.ToLower(auth.UserEmail)] {
+				http.Error(w, "Admin access required", http.StatusForbidden)
+				return
+			}
+			if !auth.User.EmailVerified {
+				http.Error(w, "Admin access requires a verified email", http.StatusForbidden)
+				return
+			}
+		}
+		if !auth.Token.Scopes.AllowsAdmin() {
+			http.Error(w, "Token scope does not allow admin access", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
 func getTierLevel(tier domain.UserTier) int {
 	switch tier {
 	case domain.TierFree:
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/duckonomy/noture/internal/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopeDenial(t *testing.T) {
+	mine, other := uuid.New(), uuid.New()
+
+	// Route patterns are only known once a ServeMux matched the request.
+	denial := func(scopes domain.TokenScopes, method, path string) string {
+		var reason string
+		mux := http.NewServeMux()
+		record := func(w http.ResponseWriter, r *http.Request) { reason = scopeDenial(r, scopes) }
+		for _, pattern := range []string{
+			"/api/me",
+			"/api/workspaces",
+			"/api/workspaces/{id}",
+			"/api/workspaces/{workspace_id}/files",
+			"/api/shares",
+		} {
+			mux.HandleFunc("GET "+pattern, record)
+			mux.HandleFunc("POST "+pattern, record)
+		}
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+		return reason
+	}
+
+	assert.Empty(t, denial(nil, "POST", "/api/shares"), "unscoped tokens have full access")
+
+	read := domain.TokenScopes{domain.ScopeRead}
+	assert.Empty(t, denial(read, "GET", "/api/shares"))
+	assert.Contains(t, denial(read, "POST", "/api/shares"), "does not allow writes")
+
+	limited := domain.TokenScopes{domain.ScopeWorkspacePrefix + mine.String(), domain.ScopeWrite}
+	assert.Empty(t, denial(limited, "POST", "/api/workspaces/"+mine.String()+"/files"))
+	assert.Empty(t, denial(limited, "GET", "/api/workspaces/"+mine.String()))
+	assert.Contains(t, denial(limited, "GET", "/api/workspaces/"+other.String()+"/files"), "does not allow this workspace")
+	assert.Contains(t, denial(limited, "GET", "/api/workspaces/"+other.String()), "does not allow this workspace")
+	assert.Contains(t, denial(limited, "GET", "/api/workspaces/not-a-uuid"), "does not allow this workspace")
+	assert.Empty(t, denial(limited, "GET", "/api/workspaces"))
+	assert.Empty(t, denial(limited, "GET", "/api/me"))
+	assert.Contains(t, denial(limited, "POST", "/api/workspaces"), "limited to workspaces")
+	assert.Contains(t, denial(limited, "GET", "/api/shares"), "limited to workspaces")
+}
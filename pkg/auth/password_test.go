@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantErr  string
+	}{
+		{"valid", "correct horse 42", ""},
+		{"too short", "abc123", "at least"},
+		{"too long", strings.Repeat("a1", 65), "at most"},
+		{"no digit", "onlyletters", "one letter and one digit"},
+		{"no letter", "1234567890", "one letter and one digit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePassword(tt.password)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse 42")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$"))
+
+	other, err := HashPassword("correct horse 42")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "hashes are salted")
+
+	assert.True(t, VerifyPassword("correct horse 42", hash))
+	assert.False(t, VerifyPassword("wrong horse 42", hash))
+	assert.False(t, VerifyPassword("correct horse 42", ""))
+	assert.False(t, VerifyPassword("correct horse 42", "hashed_password"))
+}
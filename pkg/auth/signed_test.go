@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	signer := NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	token := signer.Sign("verify", "user|a@example.com", now.Add(time.Hour))
+	payload, err := signer.Verify("verify", token, now)
+	require.NoError(t, err)
+	assert.Equal(t, "user|a@example.com", payload)
+
+	_, err = signer.Verify("verify", token, now.Add(time.Hour))
+	assert.ErrorIs(t, err, ErrExpiredSignature)
+	_, err = signer.Verify("reset", token, now)
+	assert.ErrorIs(t, err, ErrInvalidSignature, "the purpose is signed")
+	_, err = NewSigner([]byte("another key")).Verify("verify", token, now)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = signer.Verify("verify", "x"+token, now)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = signer.Verify("verify", "garbage", now)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+)
+
+// tokenBytes is the entropy of an API token. Tokens are random rather
+// than chosen, so a fast hash is enough to keep the stored form useless
+// to someone who reads the database.
+const tokenBytes = 32
+
+// GenerateToken returns a new API token, as shown to its owner once, and
+// the hash it is stored and looked up by.
+func GenerateToken() (token, hash string, err error) {
+	raw := make([]byte, tokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token = hex.EncodeToString(raw)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex SHA-256 of an API token, the only form in
+// which tokens are stored.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenHashMatches compares a stored hash with the hash of a presented
+// token in constant time.
+func tokenHashMatches(stored, presented string) bool {
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(presented)) == 1
+}
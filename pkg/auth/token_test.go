@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateToken(t *testing.T) {
+	token, hash, err := GenerateToken()
+	require.NoError(t, err)
+	assert.Len(t, token, 64)
+	assert.Equal(t, HashToken(token), hash)
+	assert.Len(t, hash, 64)
+	assert.NotEqual(t, token, hash)
+
+	other, _, err := GenerateToken()
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func TestHashToken(t *testing.T) {
+	// The hash must stay SHA-256 hex: stored tokens are looked up by it.
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", HashToken("hello"))
+	assert.True(t, tokenHashMatches(HashToken("hello"), HashToken("hello")))
+	assert.False(t, tokenHashMatches(HashToken("hello"), HashToken("hellp")))
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ErrAuthorizationPending is returned by PollDeviceAuth while the user has
+// not signed in yet.
+var ErrAuthorizationPending = errors.New("noture: device authorization pending")
+
+// StartDeviceAuth begins signing in a device. deviceName labels it in the
+// user's device list.
+func (c *Client) StartDeviceAuth(ctx context.Context, deviceName string) (*DeviceAuth, error) {
+	body, err := jsonBody(map[string]string{"device_name": deviceName})
+	if err != nil {
+		return nil, err
+	}
+	var auth DeviceAuth
+	err = c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/auth/device",
+		body:        body,
+		contentType: "application/json",
+	}, &auth)
+	if err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+// PollDeviceAuth checks once whether the user has signed in. The token is
+// handed out only once; later polls fail.
+func (c *Client) PollDeviceAuth(ctx context.Context, deviceCode string) (*DeviceToken, error) {
+	var result struct {
+		Status string `json:"status"`
+		DeviceToken
+	}
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/auth/device/poll",
+		query:  url.Values{"device_code": {deviceCode}},
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status != "complete" {
+		return nil, ErrAuthorizationPending
+	}
+	return &result.DeviceToken, nil
+}
+
+// WaitForDeviceToken polls at the interval the server asked for until the
+// user signs in, the code expires or ctx is done.
+func (c *Client) WaitForDeviceToken(ctx context.Context, auth *DeviceAuth) (*DeviceToken, error) {
+	interval := time.Duration(max(auth.Interval, 1)) * time.Second
+	deadline := time.Now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		token, err := c.PollDeviceAuth(ctx, auth.DeviceCode)
+		if !errors.Is(err, ErrAuthorizationPending) {
+			return token, err
+		}
+		if auth.ExpiresIn > 0 && time.Now().After(deadline) {
+			return nil, fmt.Errorf("noture: device code expired before sign-in")
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Package client is the Go client of the Noture API. It covers what sync
+// tools need: device sign-in, workspaces, file upload and download, the
+// manifest and the change feed.
+//
+//	c, err := client.New("https://noture.example.com")
+//	auth, err := c.StartDeviceAuth(ctx, "laptop")
+//	// show auth.VerificationURL and auth.UserCode to the user
+//	token, err := c.WaitForDeviceToken(ctx, auth)
+//	c.WithToken(token.Token)
+//	workspaces, err := c.ListWorkspaces(ctx)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls one Noture server. Its methods are safe for concurrent use
+// once it is configured.
+type Client struct {
+	baseURL       string
+	token         string
+	httpClient    *http.Client
+	clientName    string
+	clientVersion string
+}
+
+// New returns a client for the server at baseURL, e.g.
+// "https://noture.example.com".
+func New(baseURL string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: must be an http or https URL", baseURL)
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// WithToken sets the API token sent with every request.
+func (c *Client) WithToken(token string) *Client {
+	c.token = token
+	return c
+}
+
+// WithHTTPClient replaces the HTTP client, e.g. to change timeouts.
+func (c *Client) WithHTTPClient(httpClient *http.Client) *Client {
+	c.httpClient = httpClient
+	return c
+}
+
+// WithClientVersion identifies the calling tool in X-Client-Name and
+// X-Client-Version, so the server can tell clients that are too old to
+// upgrade.
+func (c *Client) WithClientVersion(name, version string) *Client {
+	c.clientName = name
+	c.clientVersion = version
+	return c
+}
+
+// Error is a response with a 4xx or 5xx status.
+type Error struct {
+	StatusCode int
+	// Code is the machine-readable error of JSON error bodies, such as
+	// token_expired; plain-text errors have none.
+	Code    string
+	Message string
+	// RetryAfter is set on 429 and 503 responses that carry Retry-After.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("noture: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// StatusCode returns the HTTP status of an *Error in err's chain, or 0.
+func StatusCode(err error) int {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound reports whether err is a 404 response.
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsTokenExpired reports whether err is a response to a request whose
+// token has expired, which signing in again or a new token fixes.
+func IsTokenExpired(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized && apiErr.Code == "token_expired"
+}
+
+// request describes one API call. path is already escaped.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	header      http.Header
+	body        io.Reader
+	contentType string
+}
+
+// send performs req and returns the response if its status is below 400.
+// The caller closes the body.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, req.body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range req.header {
+		httpReq.Header[name] = values
+	}
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if c.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.clientName != "" {
+		httpReq.Header.Set("X-Client-Name", c.clientName)
+		httpReq.Header.Set("X-Client-Version", c.clientVersion)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// do performs req and decodes a JSON response into out, if not nil.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return decodeJSON(resp, out)
+}
+
+func decodeJSON(resp *http.Response, out any) error {
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %w", resp.Request.Method, resp.Request.URL.Path, err)
+	}
+	return nil
+}
+
+// jsonBody encodes v for a request body.
+func jsonBody(v any) (io.Reader, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	return bytes.NewReader(body), nil
+}
+
+func responseError(resp *http.Response) *Error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	apiErr := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+
+	// Most errors are plain text; some carry a JSON object.
+	var structured struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &structured) == nil {
+		apiErr.Code = structured.Error
+		if structured.Message != "" {
+			apiErr.Message = structured.Message
+		} else if structured.Error != "" {
+			apiErr.Message = structured.Error
+		}
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
@@ -0,0 +1,228 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, mux *http.ServeMux) *Client {
+	t.Helper()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	c, err := New(server.URL + "/")
+	require.NoError(t, err)
+	return c.WithToken("secret").WithClientVersion("noture-test", "1.0.0")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestNew_InvalidURL(t *testing.T) {
+	for _, raw := range []string{"", "noture.example.com", "ftp://noture.example.com"} {
+		_, err := New(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestDeviceAuth(t *testing.T) {
+	userID, deviceID := uuid.New(), uuid.New()
+	polls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /auth/device", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		assert.Equal(t, "laptop", req["device_name"])
+		writeJSON(w, http.StatusOK, DeviceAuth{DeviceCode: "dc", UserCode: "ABCD-1234", ExpiresIn: 600, Interval: 1})
+	})
+	mux.HandleFunc("GET /auth/device/poll", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "dc", r.URL.Query().Get("device_code"))
+		polls++
+		if polls == 1 {
+			writeJSON(w, http.StatusOK, map[string]string{"status": "pending"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "complete", "token": "nt_abc", "user_id": userID, "device_id": deviceID})
+	})
+	c := newTestClient(t, mux)
+	ctx := context.Background()
+
+	auth, err := c.StartDeviceAuth(ctx, "laptop")
+	require.NoError(t, err)
+	assert.Equal(t, "ABCD-1234", auth.UserCode)
+
+	_, err = c.PollDeviceAuth(ctx, auth.DeviceCode)
+	assert.ErrorIs(t, err, ErrAuthorizationPending)
+
+	token, err := c.WaitForDeviceToken(ctx, auth)
+	require.NoError(t, err)
+	assert.Equal(t, DeviceToken{Token: "nt_abc", UserID: userID, DeviceID: deviceID}, *token)
+}
+
+func TestFiles(t *testing.T) {
+	workspaceID := uuid.New()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/files/upload", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.Equal(t, "noture-test", r.Header.Get("X-Client-Name"))
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, workspaceID.String(), r.FormValue("workspace_id"))
+		assert.Equal(t, "2026-01-02T03:04:05Z", r.FormValue("last_modified"))
+		assert.Empty(t, r.FormValue("note_id"))
+		file, _, err := r.FormFile("file")
+		require.NoError(t, err)
+		content, _ := io.ReadAll(file)
+
+		result := UploadResult{VersionNumber: 1, Created: true}
+		result.FilePath = r.FormValue("file_path")
+		result.SizeBytes = int64(len(content))
+		writeJSON(w, http.StatusCreated, result)
+	})
+	mux.HandleFunc("GET /api/files/{workspace_id}/{file_path...}", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("download") == "true" {
+			io.WriteString(w, "# Ideas")
+			return
+		}
+		writeJSON(w, http.StatusOK, FileInfo{FilePath: r.PathValue("file_path")})
+	})
+	mux.HandleFunc("DELETE /api/files/{workspace_id}/{file_path...}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "file not found", http.StatusNotFound)
+	})
+	c := newTestClient(t, mux)
+	ctx := context.Background()
+
+	result, err := c.Upload(ctx, Upload{
+		WorkspaceID:  workspaceID,
+		FilePath:     "notes/ideas.md",
+		Content:      []byte("# Ideas"),
+		LastModified: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	assert.True(t, result.Created)
+	assert.Equal(t, "notes/ideas.md", result.FilePath)
+	assert.Equal(t, int64(7), result.SizeBytes)
+
+	info, err := c.GetFile(ctx, workspaceID, "notes/a b#1?.md")
+	require.NoError(t, err)
+	assert.Equal(t, "notes/a b#1?.md", info.FilePath)
+
+	body, err := c.Download(ctx, workspaceID, "notes/ideas.md")
+	require.NoError(t, err)
+	content, _ := io.ReadAll(body)
+	body.Close()
+	assert.Equal(t, "# Ideas", string(content))
+
+	err = c.DeleteFile(ctx, workspaceID, "missing.md")
+	assert.True(t, IsNotFound(err))
+	assert.EqualError(t, err, "noture: 404 Not Found: file not found")
+}
+
+func TestGetManifest_NotModified(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/workspaces/{workspace_id}/manifest", func(w http.ResponseWriter, r *http.Request) {
+		etag := `"abc"`
+		if r.URL.Query().Get("view") == "digest" {
+			etag = `"digest-abc"`
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		writeJSON(w, http.StatusOK, Manifest{Folder: r.URL.Query().Get("folder"), Digest: "abc"})
+	})
+	c := newTestClient(t, mux)
+	ctx := context.Background()
+
+	manifest, err := c.GetManifest(ctx, uuid.New(), ManifestOptions{Folder: "notes"})
+	require.NoError(t, err)
+	assert.Equal(t, "notes", manifest.Folder)
+
+	_, err = c.GetManifest(ctx, uuid.New(), ManifestOptions{IfNoneMatch: manifest.Digest})
+	assert.ErrorIs(t, err, ErrNotModified)
+	_, err = c.GetManifest(ctx, uuid.New(), ManifestOptions{DigestOnly: true, IfNoneMatch: manifest.Digest})
+	assert.ErrorIs(t, err, ErrNotModified)
+}
+
+func TestChanges(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/workspaces/{workspace_id}/events", func(w http.ResponseWriter, r *http.Request) {
+		after, _ := strconv.ParseInt(r.URL.Query().Get("after"), 10, 64)
+		assert.Equal(t, []string{"file.*"}, r.URL.Query()["type"])
+		page := EventPage{LastID: after}
+		for id := after + 1; id <= min(after+2, 5); id++ {
+			page.Events = append(page.Events, Event{ID: id, Type: "file.updated"})
+			page.LastID = id
+		}
+		writeJSON(w, http.StatusOK, page)
+	})
+	c := newTestClient(t, mux)
+
+	var seen []int64
+	last, err := c.Changes(context.Background(), uuid.New(), 1, EventOptions{Types: []string{"file.*"}, Limit: 2}, func(e Event) error {
+		seen = append(seen, e.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 3, 4, 5}, seen)
+	assert.Equal(t, int64(5), last)
+
+	stop := errors.New("stop")
+	last, err = c.Changes(context.Background(), uuid.New(), 0, EventOptions{Types: []string{"file.*"}}, func(e Event) error {
+		if e.ID == 2 {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, int64(1), last)
+}
+
+func TestError_RetryAfter(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/workspaces", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+	})
+	c := newTestClient(t, mux)
+
+	_, err := c.ListWorkspaces(context.Background())
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Equal(t, 30*time.Second, apiErr.RetryAfter)
+	assert.Equal(t, "Rate limit exceeded", apiErr.Message)
+}
+
+func TestIsTokenExpired(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/workspaces", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"token_expired","message":"Token expired"}`))
+	})
+	mux.HandleFunc("GET /api/me", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+	})
+	c := newTestClient(t, mux)
+
+	_, err := c.ListWorkspaces(context.Background())
+	assert.True(t, IsTokenExpired(err))
+	assert.EqualError(t, err, "noture: 401 Unauthorized: Token expired")
+
+	err = c.do(context.Background(), request{method: http.MethodGet, path: "/api/me"}, nil)
+	assert.False(t, IsTokenExpired(err))
+	assert.Equal(t, http.StatusUnauthorized, StatusCode(err))
+}
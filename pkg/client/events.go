@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// EventOptions filter the change feed. Types are event types such as
+// "file.updated"; none means all. Limit caps the page size, zero leaves it
+// to the server.
+type EventOptions struct {
+	Types []string
+	Limit int
+}
+
+// ListEvents returns the workspace's events after the event with ID after,
+// oldest first. Pass the page's LastID to get the next page.
+func (c *Client) ListEvents(ctx context.Context, workspaceID uuid.UUID, after int64, opts EventOptions) (*EventPage, error) {
+	query := url.Values{}
+	if after > 0 {
+		query.Set("after", strconv.FormatInt(after, 10))
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	for _, eventType := range opts.Types {
+		query.Add("type", eventType)
+	}
+
+	var page EventPage
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/workspaces/" + workspaceID.String() + "/events",
+		query:  query,
+	}, &page)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Changes calls fn for every event after the event with ID after, page by
+// page, until the feed is caught up or fn fails. It returns the ID of the
+// last event handled, to resume from next time.
+func (c *Client) Changes(ctx context.Context, workspaceID uuid.UUID, after int64, opts EventOptions, fn func(Event) error) (int64, error) {
+	for {
+		page, err := c.ListEvents(ctx, workspaceID, after, opts)
+		if err != nil {
+			return after, err
+		}
+		if len(page.Events) == 0 {
+			return after, nil
+		}
+		for _, event := range page.Events {
+			if err := fn(event); err != nil {
+				return after, err
+			}
+			after = event.ID
+		}
+	}
+}
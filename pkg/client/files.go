@@ -0,0 +1,162 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ContentHash returns the hash the server stores for content, for
+// comparing local files with FileInfo.ContentHash and manifest entries.
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// ErrNotModified is returned by GetManifest when the manifest still has
+// the digest passed as IfNoneMatch.
+var ErrNotModified = errors.New("noture: not modified")
+
+// Upload stores a file, creating it or adding a version.
+func (c *Client) Upload(ctx context.Context, upload Upload) (*UploadResult, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := map[string]string{
+		"workspace_id": upload.WorkspaceID.String(),
+		"file_path":    upload.FilePath,
+		"client_id":    upload.ClientID,
+		"note_id":      upload.NoteID,
+	}
+	if !upload.LastModified.IsZero() {
+		fields["last_modified"] = upload.LastModified.Format(time.RFC3339)
+	}
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := form.WriteField(name, value); err != nil {
+			return nil, fmt.Errorf("failed to encode upload: %w", err)
+		}
+	}
+	part, err := form.CreateFormFile("file", path.Base(upload.FilePath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode upload: %w", err)
+	}
+	part.Write(upload.Content)
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode upload: %w", err)
+	}
+
+	var result UploadResult
+	err = c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/files/upload",
+		body:        &body,
+		contentType: form.FormDataContentType(),
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetFile returns a file's metadata.
+func (c *Client) GetFile(ctx context.Context, workspaceID uuid.UUID, filePath string) (*FileInfo, error) {
+	var info FileInfo
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   fileRoute(workspaceID, filePath),
+		header: http.Header{"Accept": {"application/json"}},
+	}, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Download opens a file's content. The caller closes it.
+func (c *Client) Download(ctx context.Context, workspaceID uuid.UUID, filePath string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, request{
+		method: http.MethodGet,
+		path:   fileRoute(workspaceID, filePath),
+		query:  url.Values{"download": {"true"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// DeleteFile removes a file.
+func (c *Client) DeleteFile(ctx context.Context, workspaceID uuid.UUID, filePath string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: fileRoute(workspaceID, filePath)}, nil)
+}
+
+// ManifestOptions select part of a manifest. IfNoneMatch is the Digest of
+// a manifest fetched earlier with the same options; if it is still current
+// GetManifest returns ErrNotModified.
+type ManifestOptions struct {
+	Folder      string
+	DigestOnly  bool
+	IfNoneMatch string
+}
+
+// GetManifest returns the workspace's manifest, which sync tools compare
+// with their local tree to find what to upload or download.
+func (c *Client) GetManifest(ctx context.Context, workspaceID uuid.UUID, opts ManifestOptions) (*Manifest, error) {
+	query := url.Values{}
+	if opts.Folder != "" {
+		query.Set("folder", opts.Folder)
+	}
+	etag := opts.IfNoneMatch
+	if opts.DigestOnly {
+		query.Set("view", "digest")
+		etag = "digest-" + etag
+	}
+	header := http.Header{}
+	if opts.IfNoneMatch != "" {
+		header.Set("If-None-Match", `"`+etag+`"`)
+	}
+
+	resp, err := c.send(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/workspaces/" + workspaceID.String() + "/manifest",
+		query:  query,
+		header: header,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, ErrNotModified
+	}
+
+	var manifest Manifest
+	if err := decodeJSON(resp, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// fileRoute builds the path of a file, escaping each segment of filePath
+// but keeping its slashes.
+func fileRoute(workspaceID uuid.UUID, filePath string) string {
+	segments := strings.Split(strings.TrimPrefix(filePath, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return "/api/files/" + workspaceID.String() + "/" + strings.Join(segments, "/")
+}
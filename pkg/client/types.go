@@ -0,0 +1,124 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Workspace is a synced folder the user owns or is a member of.
+type Workspace struct {
+	ID                uuid.UUID  `json:"id"`
+	UserID            uuid.UUID  `json:"user_id"`
+	Name              string     `json:"name"`
+	StorageLimitBytes int64      `json:"storage_limit_bytes"`
+	StorageUsedBytes  int64      `json:"storage_used_bytes"`
+	FileCount         int64      `json:"file_count"`
+	ArchivedAt        *time.Time `json:"archived_at,omitempty"`
+	Icon              string     `json:"icon"`
+	Color             string     `json:"color"`
+	Description       string     `json:"description"`
+	SortOrder         int32      `json:"sort_order"`
+	Revision          int64      `json:"revision"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	// Role is the user's role: owner, editor or viewer.
+	Role string `json:"role,omitempty"`
+}
+
+// FileInfo describes a stored file without its content.
+type FileInfo struct {
+	ID           uuid.UUID `json:"id"`
+	WorkspaceID  uuid.UUID `json:"workspace_id"`
+	FilePath     string    `json:"file_path"`
+	ContentHash  string    `json:"content_hash"`
+	SizeBytes    int64     `json:"size_bytes"`
+	MimeType     string    `json:"mime_type"`
+	LastModified time.Time `json:"last_modified"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Upload is a file to store. LastModified defaults to now on the server.
+// ClientID is recorded with the sync operation; NoteID is the note's
+// stable ID, if the tool tracks one.
+type Upload struct {
+	WorkspaceID  uuid.UUID
+	FilePath     string
+	Content      []byte
+	LastModified time.Time
+	ClientID     string
+	NoteID       string
+}
+
+// UploadResult is a stored upload. Unchanged uploads had the content the
+// file already had and created no version.
+type UploadResult struct {
+	FileInfo
+	VersionNumber int32 `json:"version_number"`
+	Revision      int64 `json:"workspace_revision"`
+	Created       bool  `json:"created"`
+	Unchanged     bool  `json:"unchanged"`
+	Deduplicated  bool  `json:"deduplicated"`
+}
+
+// ManifestEntry is one file of a manifest.
+type ManifestEntry struct {
+	Path        string    `json:"path"`
+	ContentHash string    `json:"content_hash"`
+	Size        int64     `json:"size"`
+	MTime       time.Time `json:"mtime"`
+}
+
+// ManifestNode is a direct child of the manifest's folder in the digest
+// view. Type is "file" or "folder".
+type ManifestNode struct {
+	Path   string `json:"path"`
+	Type   string `json:"type"`
+	Digest string `json:"digest"`
+}
+
+// Manifest lists the files below Folder or, in the digest view, the
+// folder's children with their digests. Equal digests mean equal trees.
+type Manifest struct {
+	Folder   string          `json:"folder"`
+	Revision int64           `json:"workspace_revision"`
+	Digest   string          `json:"digest"`
+	Files    []ManifestEntry `json:"files,omitempty"`
+	Children []ManifestNode  `json:"children,omitempty"`
+}
+
+// Event is one entry of a workspace's change feed. Data depends on Type,
+// e.g. file.created carries the version and content hash.
+type Event struct {
+	ID          int64           `json:"id"`
+	WorkspaceID uuid.UUID       `json:"workspace_id"`
+	Type        string          `json:"type"`
+	FilePath    string          `json:"file_path,omitempty"`
+	ActorID     *uuid.UUID      `json:"actor_id,omitempty"`
+	Data        json.RawMessage `json:"data"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// EventPage is one page of the change feed. LastID resumes it.
+type EventPage struct {
+	Events []Event `json:"events"`
+	LastID int64   `json:"last_id"`
+}
+
+// DeviceAuth is a started device sign-in. The user opens VerificationURL,
+// or enters UserCode there, while the tool polls with DeviceCode.
+type DeviceAuth struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURL string `json:"verification_url"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+// DeviceToken is the API token issued to a signed-in device.
+type DeviceToken struct {
+	Token    string    `json:"token"`
+	UserID   uuid.UUID `json:"user_id"`
+	DeviceID uuid.UUID `json:"device_id"`
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// ListWorkspaces returns the workspaces the user owns or is a member of.
+func (c *Client) ListWorkspaces(ctx context.Context) ([]Workspace, error) {
+	var result struct {
+		Workspaces []Workspace `json:"workspaces"`
+	}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/workspaces"}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result.Workspaces, nil
+}
+
+// GetWorkspace returns one workspace.
+func (c *Client) GetWorkspace(ctx context.Context, workspaceID uuid.UUID) (*Workspace, error) {
+	var workspace Workspace
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/workspaces/" + workspaceID.String()}, &workspace)
+	if err != nil {
+		return nil, err
+	}
+	return &workspace, nil
+}
+
+// CreateWorkspace creates an empty workspace owned by the user.
+func (c *Client) CreateWorkspace(ctx context.Context, name string) (*Workspace, error) {
+	body, err := jsonBody(map[string]string{"name": name})
+	if err != nil {
+		return nil, err
+	}
+	var workspace Workspace
+	err = c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/workspaces",
+		body:        body,
+		contentType: "application/json",
+	}, &workspace)
+	if err != nil {
+		return nil, err
+	}
+	return &workspace, nil
+}
@@ -0,0 +1,56 @@
+// Package email sends the server's transactional mail, such as workspace
+// invitations. Messages go through a Sender: a mail server over SMTP,
+// Amazon SES, SendGrid, or the log for development.
+package email
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/duckonomy/noture/pkg/logger"
+)
+
+// Message is a plain-text email to one recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+func (m Message) Validate() error {
+	if strings.TrimSpace(m.To) == "" {
+		return fmt.Errorf("invalid message: missing recipient")
+	}
+	if strings.ContainsAny(m.To+m.Subject, "\r\n") {
+		return fmt.Errorf("invalid message: header contains a line break")
+	}
+	return nil
+}
+
+// Sender delivers messages.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogSender writes messages to the log instead of delivering them. It is
+// meant for development, where the links in a message can be copied from
+// the server's output.
+type LogSender struct {
+	log *logger.Logger
+}
+
+func NewLogSender() *LogSender {
+	return &LogSender{log: logger.New()}
+}
+
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+	s.log.WithContext(ctx).Info("Email not delivered: log-only sender",
+		"to", msg.To,
+		"subject", msg.Subject,
+		"body", msg.Body)
+	return nil
+}
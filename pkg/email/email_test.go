@@ -0,0 +1,121 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     Message
+		wantErr bool
+	}{
+		{"valid", Message{To: "a@example.com", Subject: "Hello", Body: "Hi\nthere"}, false},
+		{"missing recipient", Message{Subject: "Hello"}, true},
+		{"header injection in subject", Message{To: "a@example.com", Subject: "Hi\r\nBcc: b@example.com"}, true},
+		{"header injection in recipient", Message{To: "a@example.com\nBcc: b@example.com"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLogSender_Send(t *testing.T) {
+	sender := NewLogSender()
+
+	assert.NoError(t, sender.Send(context.Background(), Message{To: "a@example.com", Subject: "Hello"}))
+	assert.Error(t, sender.Send(context.Background(), Message{Subject: "Hello"}))
+}
+
+func TestMessage_Format(t *testing.T) {
+	msg := Message{To: "a@example.com", Subject: "Grüße", Body: "Hi\nthere"}
+
+	data, err := msg.format("Noture <mail@noture.test>", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	require.NoError(t, err)
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, "a@example.com", parsed.Header.Get("To"))
+	assert.Contains(t, parsed.Header.Get("From"), "<mail@noture.test>")
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Grüße", subject)
+	assert.True(t, strings.HasSuffix(parsed.Header.Get("Message-ID"), "@noture.test>"))
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	require.NoError(t, err)
+	assert.Equal(t, "Hi\r\nthere", string(body))
+
+	_, err = msg.format("not an address", time.Now())
+	assert.Error(t, err)
+}
+
+func TestSendGridSender_Send(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sender := NewSendGridSender("key", "mail@noture.test")
+	sender.endpoint = server.URL
+	require.NoError(t, sender.Send(context.Background(), Message{To: "a@example.com", Subject: "Hello", Body: "Hi"}))
+	assert.Equal(t, "Hello", got["subject"])
+	assert.Equal(t, map[string]interface{}{"email": "mail@noture.test"}, got["from"])
+
+	sender.endpoint = server.URL + "/missing"
+	assert.ErrorContains(t, sender.Send(context.Background(), Message{To: "a@example.com"}), "status 404")
+}
+
+func TestSESSender_Sign(t *testing.T) {
+	sender := NewSESSender(SESConfig{
+		Region:          "eu-west-1",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		From:            "mail@noture.test",
+	})
+	body := []byte(`{}`)
+	req := httptest.NewRequest(http.MethodPost, sender.endpoint, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	sender.sign(req, body, now)
+	assert.Equal(t, "20260102T030405Z", req.Header.Get("X-Amz-Date"))
+	authorization := req.Header.Get("Authorization")
+	assert.True(t, strings.HasPrefix(authorization,
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20260102/eu-west-1/ses/aws4_request, "+
+			"SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature="), authorization)
+
+	// The signature covers the body.
+	again := httptest.NewRequest(http.MethodPost, sender.endpoint, nil)
+	again.Header.Set("Content-Type", "application/json")
+	sender.sign(again, []byte(`{"x":1}`), now)
+	assert.NotEqual(t, authorization, again.Header.Get("Authorization"))
+}
@@ -0,0 +1,205 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// httpSender posts messages to an email provider's HTTP API.
+type httpSender struct {
+	provider string
+	client   *http.Client
+}
+
+func (s httpSender) post(req *http.Request) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.provider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("%s: status %d: %s", s.provider, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// SendGridSender delivers messages through SendGrid's v3 mail API.
+type SendGridSender struct {
+	httpSender
+	apiKey   string
+	from     string
+	endpoint string
+}
+
+func NewSendGridSender(apiKey, from string) *SendGridSender {
+	return &SendGridSender{
+		httpSender: httpSender{provider: "sendgrid", client: &http.Client{Timeout: 30 * time.Second}},
+		apiKey:     apiKey,
+		from:       from,
+		endpoint:   "https://api.sendgrid.com/v3/mail/send",
+	}
+}
+
+func (s *SendGridSender) Send(ctx context.Context, msg Message) error {
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+
+	type address struct {
+		Email string `json:"email"`
+	}
+	type content struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+	body, err := json.Marshal(struct {
+		Personalizations []map[string][]address `json:"personalizations"`
+		From             address                `json:"from"`
+		Subject          string                 `json:"subject"`
+		Content          []content              `json:"content"`
+	}{
+		Personalizations: []map[string][]address{{"to": {{Email: msg.To}}}},
+		From:             address{Email: s.from},
+		Subject:          msg.Subject,
+		Content:          []content{{Type: "text/plain", Value: msg.Body}},
+	})
+	if err != nil {
+		return fmt.Errorf("sendgrid: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("sendgrid: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	return s.post(req)
+}
+
+// SESConfig is an Amazon SES region and the access key to send with.
+type SESConfig struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	From            string
+}
+
+// SESSender delivers messages through the Amazon SES v2 API. Requests are
+// signed with AWS Signature Version 4.
+type SESSender struct {
+	httpSender
+	cfg      SESConfig
+	endpoint string
+	now      func() time.Time
+}
+
+func NewSESSender(cfg SESConfig) *SESSender {
+	return &SESSender{
+		httpSender: httpSender{provider: "ses", client: &http.Client{Timeout: 30 * time.Second}},
+		cfg:        cfg,
+		endpoint:   "https://email." + cfg.Region + ".amazonaws.com/v2/email/outbound-emails",
+		now:        time.Now,
+	}
+}
+
+func (s *SESSender) Send(ctx context.Context, msg Message) error {
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+
+	type text struct {
+		Data    string `json:"Data"`
+		Charset string `json:"Charset"`
+	}
+	var payload struct {
+		FromEmailAddress string `json:"FromEmailAddress"`
+		Destination      struct {
+			ToAddresses []string `json:"ToAddresses"`
+		} `json:"Destination"`
+		Content struct {
+			Simple struct {
+				Subject text `json:"Subject"`
+				Body    struct {
+					Text text `json:"Text"`
+				} `json:"Body"`
+			} `json:"Simple"`
+		} `json:"Content"`
+	}
+	payload.FromEmailAddress = s.cfg.From
+	payload.Destination.ToAddresses = []string{msg.To}
+	payload.Content.Simple.Subject = text{Data: msg.Subject, Charset: "UTF-8"}
+	payload.Content.Simple.Body.Text = text{Data: msg.Body, Charset: "UTF-8"}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("ses: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("ses: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	s.sign(req, body, s.now().UTC())
+	return s.post(req)
+}
+
+// sign adds AWS Signature Version 4 headers for the ses service.
+func (s *SESSender) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "content-type;host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"content-type:" + req.Header.Get("Content-Type"),
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/ses/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "ses")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
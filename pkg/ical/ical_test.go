@@ -0,0 +1,65 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	var b strings.Builder
+	err := Encode(&b, Calendar{
+		ProdID: "-//noture//calendar//EN",
+		Name:   "Work, mostly",
+		Events: []Event{
+			{UID: "a@noture", Summary: "Pay rent", Start: time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC), AllDay: true},
+			{UID: "b@noture", Summary: "Call; then write", Description: "work.org\nline 2", Start: time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)},
+		},
+	}, time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	want := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"PRODID:-//noture//calendar//EN\r\n" +
+		"CALSCALE:GREGORIAN\r\n" +
+		"X-WR-CALNAME:Work\\, mostly\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:a@noture\r\n" +
+		"DTSTAMP:20261016T120000Z\r\n" +
+		"DTSTART;VALUE=DATE:20261017\r\n" +
+		"DTEND;VALUE=DATE:20261018\r\n" +
+		"SUMMARY:Pay rent\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:b@noture\r\n" +
+		"DTSTAMP:20261016T120000Z\r\n" +
+		"DTSTART:20261018T093000\r\n" +
+		"SUMMARY:Call\\; then write\r\n" +
+		"DESCRIPTION:work.org\\nline 2\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	assert.Equal(t, want, b.String())
+}
+
+func TestEncode_FoldsLongLines(t *testing.T) {
+	var b strings.Builder
+	summary := strings.Repeat("é", 100)
+	err := Encode(&b, Calendar{Events: []Event{{UID: "x", Summary: summary, AllDay: true}}}, time.Now())
+	require.NoError(t, err)
+
+	var unfolded strings.Builder
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+		assert.True(t, utf8.ValidString(line), "folding must not split characters")
+		if strings.HasPrefix(line, " ") {
+			unfolded.WriteString(line[1:])
+		} else {
+			unfolded.WriteString("\n" + line)
+		}
+	}
+	assert.Contains(t, unfolded.String(), "\nSUMMARY:"+summary+"\n")
+}
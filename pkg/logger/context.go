@@ -0,0 +1,26 @@
+package logger
+
+import "context"
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the ID of the request
+// it serves.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Detach returns a background context carrying the request ID of ctx, for
+// work that outlives the request but should still be logged with it.
+func Detach(ctx context.Context) context.Context {
+	if id := RequestID(ctx); id != "" {
+		return ContextWithRequestID(context.Background(), id)
+	}
+	return context.Background()
+}
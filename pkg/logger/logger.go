@@ -1,19 +1,29 @@
 package logger
 
 import (
+	"context"
 	"log/slog"
 	"os"
+	"sync/atomic"
 )
 
 type Logger struct {
 	*slog.Logger
 }
 
+var production atomic.Bool
+
+// Configure sets the environment loggers created afterwards are built for:
+// JSON at info level in production, text at debug level otherwise. Call it
+// once at startup, before creating loggers.
+func Configure(environment string) {
+	production.Store(environment == "production")
+}
+
 func New() *Logger {
 	var handler slog.Handler
 
-	env := os.Getenv("ENVIRONMENT")
-	if env == "production" {
+	if production.Load() {
 		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 			Level: slog.LevelInfo,
 		})
@@ -56,6 +66,18 @@ func (l *Logger) WithRequest(method, path, userAgent string) *Logger {
 	}
 }
 
+// WithContext adds the request ID carried by ctx, if any, so every line
+// logged while serving a request can be correlated.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	id := RequestID(ctx)
+	if id == "" {
+		return l
+	}
+	return &Logger{
+		Logger: l.Logger.With("request_id", id),
+	}
+}
+
 func (l *Logger) WithError(err error) *Logger {
 	return &Logger{
 		Logger: l.Logger.With("error", err.Error()),
@@ -0,0 +1,304 @@
+package markdown
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	hashTagRe   = regexp.MustCompile(`(?:^|[\s(,])#([\p{L}\p{N}_/-]*\p{L}[\p{L}\p{N}_/-]*)`)
+	urlSchemeRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+)
+
+// Link is a link from a note to another note, with its target as written
+// and the 1-based line it is on.
+type Link struct {
+	Target string
+	Line   int
+}
+
+// WikiLinks returns the [[target]] and [[target|label]] links of a
+// Markdown note, embeds included, outside code. Targets are trimmed but
+// otherwise as written; resolve them with WikiLinkCandidates.
+func WikiLinks(src []byte) []Link {
+	return collectLinks(src, scanWikiLinks)
+}
+
+// RelativeLinks returns the [label](target) links of a Markdown note to
+// other files, images included, outside code. URLs and links within the
+// note are left out; resolve the rest with RelativeLinkCandidates.
+func RelativeLinks(src []byte) []Link {
+	return collectLinks(src, scanRelativeLinks)
+}
+
+// OrgLinks returns the links of an Org note to other files,
+// [[file:notes.org][notes]] and [[notes]], outside blocks. Targets lose
+// their file: prefix and ::search option; URLs, IDs and links within the
+// note are left out.
+func OrgLinks(src []byte) []Link {
+	return collectLinks(src, scanOrgLinks)
+}
+
+// RelativeLinkCandidates returns the path a relative link target in the
+// note at notePath names, without its fragment and unescaped. Targets
+// leaving the root name nothing.
+func RelativeLinkCandidates(notePath, target string) []string {
+	target, _, _ = strings.Cut(target, "#")
+	target, _, _ = strings.Cut(target, "?")
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+	if target == "" {
+		return nil
+	}
+	p := path.Join(path.Dir(notePath), target)
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return nil
+	}
+	return []string{p}
+}
+
+// RewriteWikiLinks replaces the targets of the links WikiLinks returns
+// with what replace gives for them, leaving links it declines and the
+// rest of the note as written.
+func RewriteWikiLinks(src []byte, replace func(Link) (string, bool)) []byte {
+	return rewriteLinks(src, scanWikiLinks, replace)
+}
+
+// RewriteRelativeLinks is RewriteWikiLinks for RelativeLinks.
+func RewriteRelativeLinks(src []byte, replace func(Link) (string, bool)) []byte {
+	return rewriteLinks(src, scanRelativeLinks, replace)
+}
+
+// RewriteOrgLinks is RewriteWikiLinks for OrgLinks. Only the path is
+// replaced; a file: prefix and ::search option stay.
+func RewriteOrgLinks(src []byte, replace func(Link) (string, bool)) []byte {
+	return rewriteLinks(src, scanOrgLinks, replace)
+}
+
+// A linkScanner calls fn with each link of a note and the byte offsets of
+// its target in the note.
+type linkScanner func(src []byte, fn func(link Link, start, end int))
+
+func collectLinks(src []byte, scan linkScanner) []Link {
+	var links []Link
+	scan(src, func(link Link, _, _ int) {
+		links = append(links, link)
+	})
+	return links
+}
+
+func rewriteLinks(src []byte, scan linkScanner, replace func(Link) (string, bool)) []byte {
+	var out []byte
+	last := 0
+	scan(src, func(link Link, start, end int) {
+		target, ok := replace(link)
+		if !ok {
+			return
+		}
+		out = append(out, src[last:start]...)
+		out = append(out, target...)
+		last = end
+	})
+	if out == nil {
+		return src
+	}
+	return append(out, src[last:]...)
+}
+
+func scanWikiLinks(src []byte, fn func(link Link, start, end int)) {
+	_, body := Frontmatter(src)
+	base := len(src) - len(body)
+	lines := lineCount(src[:base])
+	eachMarkdownLine(body, func(n, offset int, text string) {
+		for _, loc := range wikiLinkRe.FindAllStringSubmatchIndex(text, -1) {
+			raw := text[loc[2]:loc[3]]
+			target := strings.TrimSpace(raw)
+			if target == "" {
+				continue
+			}
+			start := base + offset + loc[2] + strings.Index(raw, target)
+			fn(Link{Target: target, Line: lines + n}, start, start+len(target))
+		}
+	})
+}
+
+func scanRelativeLinks(src []byte, fn func(link Link, start, end int)) {
+	_, body := Frontmatter(src)
+	base := len(src) - len(body)
+	lines := lineCount(src[:base])
+	eachMarkdownLine(body, func(n, offset int, text string) {
+		for _, loc := range linkRe.FindAllStringSubmatchIndex(text, -1) {
+			target := text[loc[4]:loc[5]]
+			if urlSchemeRe.MatchString(target) || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "/") {
+				continue
+			}
+			start := base + offset + loc[4]
+			fn(Link{Target: target, Line: lines + n}, start, start+len(target))
+		}
+	})
+}
+
+func scanOrgLinks(src []byte, fn func(link Link, start, end int)) {
+	eachOrgLine(src, func(n, offset int, text string) {
+		for _, loc := range orgLinkRe.FindAllStringSubmatchIndex(text, -1) {
+			raw := text[loc[2]:loc[3]]
+			target := strings.TrimSpace(raw)
+			start := loc[2] + strings.Index(raw, target)
+			if rest, ok := strings.CutPrefix(target, "file:"); ok {
+				target = rest
+				start += len("file:")
+			} else if urlSchemeRe.MatchString(target) {
+				continue
+			}
+			target, _, _ = strings.Cut(target, "::")
+			if target == "" || strings.HasPrefix(target, "*") || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "(") {
+				continue
+			}
+			fn(Link{Target: target, Line: n}, offset+start, offset+start+len(target))
+		}
+	})
+}
+
+// Tags returns the tags of a Markdown note, lowercased, sorted and without
+// their #: those listed under tags in the frontmatter, inline or as a
+// block list, and #tags in the text outside code. A tag needs a letter, so
+// #1 is not one.
+func Tags(src []byte) []string {
+	tags := map[string]bool{}
+	add := func(tag string) {
+		tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(unquote(strings.TrimSpace(tag))), "#"))
+		if tag != "" {
+			tags[tag] = true
+		}
+	}
+
+	fields, body := Frontmatter(src)
+	if fields != nil {
+		for _, key := range []string{"tags", "tag"} {
+			value := strings.Trim(fields[key], "[]")
+			for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+				add(tag)
+			}
+		}
+		for _, tag := range frontmatterList(src, "tags") {
+			add(tag)
+		}
+	}
+
+	eachMarkdownLine(body, func(_, _ int, text string) {
+		for _, m := range hashTagRe.FindAllStringSubmatch(text, -1) {
+			add(m[1])
+		}
+	})
+	return sortedKeys(tags)
+}
+
+// OrgTags returns the tags of an Org note, lowercased and sorted: its
+// #+FILETAGS and the tags of its headlines.
+func OrgTags(src []byte) []string {
+	tags := map[string]bool{}
+	add := func(list string) {
+		for _, tag := range strings.Split(list, ":") {
+			if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+				tags[tag] = true
+			}
+		}
+	}
+
+	add(OrgKeywords(src)["filetags"])
+	eachOrgLine(src, func(_, _ int, text string) {
+		if m := orgHeadingRe.FindStringSubmatch(text); m != nil {
+			add(m[4])
+		}
+	})
+	return sortedKeys(tags)
+}
+
+// eachMarkdownLine calls fn with the number, byte offset and text of each
+// line outside fenced code, with code spans blanked out.
+func eachMarkdownLine(src []byte, fn func(n, offset int, text string)) {
+	fenced := false
+	offset := 0
+	for i, line := range strings.SplitAfter(string(src), "\n") {
+		start := offset
+		offset += len(line)
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+		fn(i+1, start, codeSpanRe.ReplaceAllStringFunc(line, func(span string) string {
+			return strings.Repeat(" ", len(span))
+		}))
+	}
+}
+
+// eachOrgLine calls fn with the number, byte offset and text of each line
+// outside blocks.
+func eachOrgLine(src []byte, fn func(n, offset int, text string)) {
+	block := ""
+	offset := 0
+	for i, line := range strings.SplitAfter(string(src), "\n") {
+		start := offset
+		offset += len(line)
+		line = strings.TrimRight(line, "\r\n")
+		trimmed := strings.TrimSpace(line)
+		if block != "" {
+			if strings.EqualFold(trimmed, "#+end_"+block) {
+				block = ""
+			}
+			continue
+		}
+		if m := orgBlockRe.FindStringSubmatch(trimmed); m != nil {
+			block = strings.ToLower(m[1])
+			continue
+		}
+		fn(i+1, start, line)
+	}
+}
+
+// frontmatterList returns the items of a YAML block list under key in the
+// note's frontmatter, which Frontmatter skips.
+func frontmatterList(src []byte, key string) []string {
+	rest, ok := cutLine(src, "---")
+	if !ok {
+		return nil
+	}
+	var items []string
+	inList := false
+	for _, line := range strings.Split(string(rest), "\n") {
+		text := strings.TrimRight(line, "\r")
+		if trimmed := strings.TrimSpace(text); trimmed == "---" || trimmed == "..." {
+			break
+		}
+		if text != "" && text[0] != ' ' && text[0] != '\t' && text[0] != '-' {
+			name, value, _ := strings.Cut(text, ":")
+			inList = strings.EqualFold(strings.TrimSpace(name), key) && strings.TrimSpace(value) == ""
+			continue
+		}
+		if item, ok := strings.CutPrefix(strings.TrimSpace(text), "- "); ok && inList {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func lineCount(src []byte) int {
+	return strings.Count(string(src), "\n")
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
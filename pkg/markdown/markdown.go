@@ -0,0 +1,211 @@
+// Package markdown renders the common subset of Markdown, and of Org, used
+// in notes to HTML. All text is escaped, so the output is safe to serve to
+// browsers even when the source is untrusted.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	headingRe      = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	unorderedRe    = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedRe      = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	ruleRe         = regexp.MustCompile(`^\s*([-*_])(\s*([-*_])){2,}\s*$`)
+	codeSpanRe     = regexp.MustCompile("`([^`]+)`")
+	linkRe         = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	wikiLinkRe     = regexp.MustCompile(`!?\[\[([^\]|]+)(?:\|([^\]]+))?\]\]`)
+	placeholderRe  = regexp.MustCompile("\x00([0-9]+)\x00")
+	strongRe       = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	emphasisRe     = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	safeURLSchemes = []string{"http://", "https://", "mailto:", "/", "#"}
+)
+
+// Options change how links are rendered.
+type Options struct {
+	// WikiLink resolves the target of a [[target]] or [[target|label]]
+	// link, as written, to a URL. Links it cannot resolve are rendered as
+	// their label in a span of class wikilink-missing. Without it
+	// wiki-links are left as written.
+	WikiLink func(target string) (href string, ok bool)
+}
+
+// Render converts Markdown source to an HTML fragment. It supports
+// headings, paragraphs, block quotes, fenced code, lists, horizontal rules,
+// inline code, emphasis and links. Frontmatter is left out.
+func Render(src []byte) string {
+	return RenderWith(src, Options{})
+}
+
+// RenderWith is Render with options.
+func RenderWith(src []byte, opts Options) string {
+	inline := func(text string) string { return inline(text, opts) }
+	_, src = Frontmatter(src)
+	var b strings.Builder
+	var para []string
+	list := ""
+
+	flushPara := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>" + inline(strings.Join(para, " ")) + "</p>\n")
+			para = nil
+		}
+	}
+	closeList := func() {
+		if list != "" {
+			b.WriteString("</" + list + ">\n")
+			list = ""
+		}
+	}
+	openList := func(tag string) {
+		if list != tag {
+			closeList()
+			b.WriteString("<" + tag + ">\n")
+			list = tag
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") {
+			flushPara()
+			closeList()
+			lang := strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			if lang != "" {
+				b.WriteString(`<pre><code class="language-` + html.EscapeString(lang) + `">`)
+			} else {
+				b.WriteString("<pre><code>")
+			}
+			b.WriteString(html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			flushPara()
+			closeList()
+		case headingRe.MatchString(trimmed):
+			flushPara()
+			closeList()
+			m := headingRe.FindStringSubmatch(trimmed)
+			level := string(rune('0' + len(m[1])))
+			b.WriteString("<h" + level + ">" + inline(m[2]) + "</h" + level + ">\n")
+		case ruleRe.MatchString(trimmed):
+			flushPara()
+			closeList()
+			b.WriteString("<hr>\n")
+		case strings.HasPrefix(trimmed, ">"):
+			flushPara()
+			closeList()
+			b.WriteString("<blockquote><p>" + inline(strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))) + "</p></blockquote>\n")
+		case unorderedRe.MatchString(line):
+			flushPara()
+			openList("ul")
+			b.WriteString("<li>" + inline(unorderedRe.FindStringSubmatch(line)[1]) + "</li>\n")
+		case orderedRe.MatchString(line):
+			flushPara()
+			openList("ol")
+			b.WriteString("<li>" + inline(orderedRe.FindStringSubmatch(line)[1]) + "</li>\n")
+		default:
+			closeList()
+			para = append(para, trimmed)
+		}
+	}
+	flushPara()
+	closeList()
+
+	return b.String()
+}
+
+// inline escapes text and applies span-level formatting. Code spans are
+// cut out first so their contents are never formatted.
+func inline(text string, opts Options) string {
+	var out strings.Builder
+	last := 0
+	for _, loc := range codeSpanRe.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(formatSpans(text[last:loc[0]], opts))
+		out.WriteString("<code>" + html.EscapeString(text[loc[2]:loc[3]]) + "</code>")
+		last = loc[1]
+	}
+	out.WriteString(formatSpans(text[last:], opts))
+	return out.String()
+}
+
+// formatSpans renders links first and holds them aside while emphasis is
+// applied, so that underscores and asterisks in URLs stay as they are.
+func formatSpans(text string, opts Options) string {
+	var held placeholders
+	text = html.EscapeString(strings.ReplaceAll(text, "\x00", "\uFFFD"))
+	if opts.WikiLink != nil {
+		text = wikiLinkRe.ReplaceAllStringFunc(text, func(s string) string {
+			m := wikiLinkRe.FindStringSubmatch(s)
+			target, label := html.UnescapeString(strings.TrimSpace(m[1])), strings.TrimSpace(m[2])
+			if label == "" {
+				label = html.EscapeString(target)
+			}
+			openTag, closeTag := wikiLink(target, opts)
+			return held.hold(openTag) + label + held.hold(closeTag)
+		})
+	}
+	text = linkRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := linkRe.FindStringSubmatch(s)
+		if !safeURL(html.UnescapeString(m[2])) {
+			return m[1]
+		}
+		return held.hold(`<a href="`+m[2]+`" rel="nofollow noopener">`) + m[1] + held.hold(`</a>`)
+	})
+	text = strongRe.ReplaceAllString(text, "<strong>$1$2</strong>")
+	text = emphasisRe.ReplaceAllString(text, "<em>$1$2</em>")
+	return held.restore(text)
+}
+
+// wikiLink returns the tags around a wiki-link to target.
+func wikiLink(target string, opts Options) (openTag, closeTag string) {
+	href, ok := opts.WikiLink(target)
+	if !ok || !safeURL(href) {
+		return `<span class="wikilink-missing">`, `</span>`
+	}
+	return `<a href="` + html.EscapeString(href) + `" class="wikilink">`, `</a>`
+}
+
+// placeholders hold rendered HTML out of the text while later patterns
+// run over it. Input text has its NUL bytes replaced, so the markers
+// cannot be forged.
+type placeholders []string
+
+func (p *placeholders) hold(s string) string {
+	*p = append(*p, s)
+	return "\x00" + strconv.Itoa(len(*p)-1) + "\x00"
+}
+
+func (p placeholders) restore(text string) string {
+	if len(p) == 0 {
+		return text
+	}
+	return placeholderRe.ReplaceAllStringFunc(text, func(s string) string {
+		i, _ := strconv.Atoi(s[1 : len(s)-1])
+		return p[i]
+	})
+}
+
+// safeURL rejects javascript: and other schemes that could run script when
+// a shared note is opened.
+func safeURL(u string) bool {
+	lower := strings.ToLower(strings.TrimSpace(u))
+	for _, prefix := range safeURLSchemes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return !strings.Contains(lower, ":")
+}
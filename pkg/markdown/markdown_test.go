@@ -0,0 +1,186 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"heading", "## Title ##", "<h2>Title</h2>\n"},
+		{"paragraph joins lines", "one\ntwo", "<p>one two</p>\n"},
+		{"emphasis", "**bold** and *it*", "<p><strong>bold</strong> and <em>it</em></p>\n"},
+		{"code span is literal", "`**x**`", "<p><code>**x**</code></p>\n"},
+		{"list", "- a\n- b", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n"},
+		{"ordered list", "1. a\n2. b", "<ol>\n<li>a</li>\n<li>b</li>\n</ol>\n"},
+		{"fenced code", "```go\nx := <y>\n```", "<pre><code class=\"language-go\">x := &lt;y&gt;</code></pre>\n"},
+		{"html is escaped", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"link", "[site](https://example.com)", "<p><a href=\"https://example.com\" rel=\"nofollow noopener\">site</a></p>\n"},
+		{"unsafe link dropped", "[x](javascript:void)", "<p>x</p>\n"},
+		{"rule", "---", "<hr>\n"},
+		{"underscores in url", "[x](/a_b_c) _it_", "<p><a href=\"/a_b_c\" rel=\"nofollow noopener\">x</a> <em>it</em></p>\n"},
+		{"wiki-link left as written", "[[Note]]", "<p>[[Note]]</p>\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Render([]byte(tt.src)))
+		})
+	}
+}
+
+func TestRenderWith_WikiLinks(t *testing.T) {
+	opts := Options{WikiLink: func(target string) (string, bool) {
+		switch target {
+		case "Some_Note":
+			return "/notes/Some_Note.md", true
+		case "evil":
+			return "javascript:alert(1)", true
+		}
+		return "", false
+	}}
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"resolved", "see [[Some_Note]]", `<p>see <a href="/notes/Some_Note.md" class="wikilink">Some_Note</a></p>` + "\n"},
+		{"label", "[[Some_Note|the *note*]]", `<p><a href="/notes/Some_Note.md" class="wikilink">the <em>note</em></a></p>` + "\n"},
+		{"missing", "[[Gone & lost]]", `<p><span class="wikilink-missing">Gone &amp; lost</span></p>` + "\n"},
+		{"unsafe url", "[[evil]]", `<p><span class="wikilink-missing">evil</span></p>` + "\n"},
+		{"placeholder cannot be forged", "a\x000\x00b [[Some_Note]]", "<p>a\uFFFD0\uFFFDb " + `<a href="/notes/Some_Note.md" class="wikilink">Some_Note</a></p>` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, RenderWith([]byte(tt.src), opts))
+		})
+	}
+}
+
+func TestRenderOrg(t *testing.T) {
+	opts := Options{WikiLink: func(target string) (string, bool) {
+		return "/w/" + target, target == "notes.org"
+	}}
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"heading", "** TODO Call Bob :work:", "<h2><span class=\"todo\">TODO</span> Call Bob</h2>\n"},
+		{"keywords and drawers skipped", "#+TITLE: Notes\n* A\n:PROPERTIES:\n:ID: 1\n:END:\ntext", "<h1>A</h1>\n<p>text</p>\n"},
+		{"markup", "*bold* /it/ =a*b*= +gone+", "<p><strong>bold</strong> <em>it</em> <code>a*b*</code> <del>gone</del></p>\n"},
+		{"paths are not italic", "see /usr/bin and a/b/c", "<p>see /usr/bin and a/b/c</p>\n"},
+		{"list", "- a\n- b", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n"},
+		{"src block", "#+BEGIN_SRC go\nx := <y>\n#+END_SRC", "<pre><code class=\"language-go\">x := &lt;y&gt;</code></pre>\n"},
+		{"quote", "#+begin_quote\nsaid\nthis\n#+end_quote", "<blockquote><p>said this</p></blockquote>\n"},
+		{"external link", "[[https://example.com/a_b][site]]", "<p><a href=\"https://example.com/a_b\" rel=\"nofollow noopener\">site</a></p>\n"},
+		{"file link", "[[file:notes.org][my notes]] [[other.org]]", "<p><a href=\"/w/notes.org\" class=\"wikilink\">my notes</a> <span class=\"wikilink-missing\">other.org</span></p>\n"},
+		{"html is escaped", "<b>x</b>", "<p>&lt;b&gt;x&lt;/b&gt;</p>\n"},
+		{"rule", "-----", "<hr>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, RenderOrg([]byte(tt.src), opts))
+		})
+	}
+}
+
+func TestWikiLinkCandidates(t *testing.T) {
+	assert.Equal(t, []string{"a/b/Note.md", "a/b/Note.org", "a/b/Note", "Note.md", "Note.org", "Note"}, WikiLinkCandidates("a/b/plan.md", "Note#Heading"))
+	assert.Equal(t, []string{"x.png"}, WikiLinkCandidates("plan.md", "/x.png"))
+	assert.Equal(t, []string{"a/x.md", "x.md"}, WikiLinkCandidates("a/plan.md", "x.md"))
+	assert.Empty(t, WikiLinkCandidates("plan.md", "../../etc/passwd"))
+	assert.Empty(t, WikiLinkCandidates("plan.md", "#Heading"))
+}
+
+func TestFrontmatter(t *testing.T) {
+	fields, body := Frontmatter([]byte("---\ntitle: \"Plan: Q3\"\npublish: true\ntags:\n  - a\n---\n# Plan"))
+	assert.Equal(t, map[string]string{"title": "Plan: Q3", "publish": "true", "tags": ""}, fields)
+	assert.Equal(t, "# Plan", string(body))
+
+	fields, body = Frontmatter([]byte("---\nnot closed"))
+	assert.Nil(t, fields)
+	assert.Equal(t, "---\nnot closed", string(body))
+
+	assert.Equal(t, "<h1>Plan</h1>\n", Render([]byte("---\nid: x\n---\n# Plan")))
+}
+
+func TestOrgKeywords(t *testing.T) {
+	keywords := OrgKeywords([]byte("#+TITLE: Plan\n#+publish: nil\n* Heading\n#+AFTER: no"))
+	assert.Equal(t, map[string]string{"title": "Plan", "publish": "nil"}, keywords)
+}
+
+func TestWikiLinks(t *testing.T) {
+	src := "---\nid: x\n---\nSee [[Plan#Goals|the plan]] and ![[chart.png]].\n```\n[[not a link]]\n```\n`[[code]]` [[ spaced ]]"
+	assert.Equal(t, []Link{
+		{Target: "Plan#Goals", Line: 4},
+		{Target: "chart.png", Line: 4},
+		{Target: "spaced", Line: 8},
+	}, WikiLinks([]byte(src)))
+}
+
+func TestOrgLinks(t *testing.T) {
+	src := "* Links\n[[file:a/plan.org::*Goals][plan]] [[notes]] [[https://example.com][web]]\n[[id:1234]] [[*Heading]]\n#+begin_src\n[[file:skip.org]]\n#+end_src\n"
+	assert.Equal(t, []Link{
+		{Target: "a/plan.org", Line: 2},
+		{Target: "notes", Line: 2},
+	}, OrgLinks([]byte(src)))
+}
+
+func TestTags(t *testing.T) {
+	src := "---\ntags: [Work, \"plans\"]\naliases:\n  - nope\n---\n# Title\nNotes on #Roadmap and #2024, see https://x.org/#frag.\n`#code`\n"
+	assert.Equal(t, []string{"plans", "roadmap", "work"}, Tags([]byte(src)))
+
+	src = "---\ntags:\n  - alpha\n  - beta\n---\n#gamma/sub"
+	assert.Equal(t, []string{"alpha", "beta", "gamma/sub"}, Tags([]byte(src)))
+}
+
+func TestOrgTags(t *testing.T) {
+	src := "#+FILETAGS: :work:Plans:\n* TODO Ship :urgent:work:\n#+begin_example\n* Not :tagged:\n#+end_example\n"
+	assert.Equal(t, []string{"plans", "urgent", "work"}, OrgTags([]byte(src)))
+}
+
+func TestRelativeLinks(t *testing.T) {
+	src := "[plan](../plan.md#goals) ![chart](img/chart%20v2.png) [web](https://x.org) [top](#top) [abs](/a.md)"
+	links := RelativeLinks([]byte(src))
+	assert.Equal(t, []Link{
+		{Target: "../plan.md#goals", Line: 1},
+		{Target: "img/chart%20v2.png", Line: 1},
+	}, links)
+	assert.Equal(t, []string{"plan.md"}, RelativeLinkCandidates("notes/a.md", links[0].Target))
+	assert.Equal(t, []string{"notes/img/chart v2.png"}, RelativeLinkCandidates("notes/a.md", links[1].Target))
+	assert.Empty(t, RelativeLinkCandidates("a.md", "../../x.md"))
+}
+
+func TestRewriteLinks(t *testing.T) {
+	replace := func(link Link) (string, bool) {
+		switch link.Target {
+		case "old", "old.md", "old.org":
+			return "new", true
+		case "old#Goals":
+			return "new#Goals", true
+		}
+		return "", false
+	}
+
+	src := "---\nid: x\n---\r\nSee [[old]], [[ old#Goals |goals]], `[[old]]` and [[other]].\n```\n[[old]]\n```\n[link](old.md)"
+	assert.Equal(t,
+		"---\nid: x\n---\r\nSee [[new]], [[ new#Goals |goals]], `[[old]]` and [[other]].\n```\n[[old]]\n```\n[link](old.md)",
+		string(RewriteWikiLinks([]byte(src), replace)))
+	assert.Equal(t,
+		"---\nid: x\n---\r\nSee [[old]], [[ old#Goals |goals]], `[[old]]` and [[other]].\n```\n[[old]]\n```\n[link](new)",
+		string(RewriteRelativeLinks([]byte(src), replace)))
+
+	org := "* See [[file:old.org::*Goals][goals]] and [[old]]\n#+begin_src\n[[old]]\n#+end_src\n"
+	assert.Equal(t,
+		"* See [[file:new::*Goals][goals]] and [[new]]\n#+begin_src\n[[old]]\n#+end_src\n",
+		string(RewriteOrgLinks([]byte(org), replace)))
+
+	unchanged := []byte("no links")
+	assert.Equal(t, unchanged, RewriteWikiLinks(unchanged, replace))
+}
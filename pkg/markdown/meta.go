@@ -0,0 +1,101 @@
+package markdown
+
+import (
+	"bytes"
+	"path"
+	"strings"
+)
+
+// Frontmatter splits a YAML frontmatter block, which must open the note,
+// from the Markdown body. Only top-level "key: value" lines are read;
+// keys are lowercased and values unquoted. Notes without frontmatter
+// return no fields and src.
+func Frontmatter(src []byte) (map[string]string, []byte) {
+	rest, ok := cutLine(src, "---")
+	if !ok {
+		return nil, src
+	}
+	fields := map[string]string{}
+	for len(rest) > 0 {
+		var line []byte
+		line, rest, _ = bytes.Cut(rest, []byte("\n"))
+		text := strings.TrimRight(string(line), "\r")
+		if trimmed := strings.TrimSpace(text); trimmed == "---" || trimmed == "..." {
+			return fields, rest
+		}
+		if text == "" || text[0] == ' ' || text[0] == '\t' || text[0] == '#' {
+			continue
+		}
+		if key, value, ok := strings.Cut(text, ":"); ok {
+			fields[strings.ToLower(strings.TrimSpace(key))] = unquote(strings.TrimSpace(value))
+		}
+	}
+	// An unclosed block is not frontmatter.
+	return nil, src
+}
+
+// OrgKeywords returns the #+KEY: value keywords before an Org note's first
+// headline, keyed by lowercased name.
+func OrgKeywords(src []byte) map[string]string {
+	keywords := map[string]string{}
+	for _, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "*") {
+			break
+		}
+		if !orgKeywordRe.MatchString(line) {
+			continue
+		}
+		key, value, _ := strings.Cut(line[2:], ":")
+		keywords[strings.ToLower(key)] = strings.TrimSpace(value)
+	}
+	return keywords
+}
+
+// WikiLinkCandidates lists the paths a wiki-link target in the note at
+// notePath may name, most specific first: relative to the note's folder,
+// then to the root, each with a .md or .org extension when the target has
+// none. A heading after # is ignored, and targets leaving the root name
+// nothing.
+func WikiLinkCandidates(notePath, target string) []string {
+	target, _, _ = strings.Cut(target, "#")
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil
+	}
+
+	var bases []string
+	if !strings.HasPrefix(target, "/") {
+		if dir := path.Dir(notePath); dir != "." {
+			bases = append(bases, path.Join(dir, target))
+		}
+	}
+	bases = append(bases, path.Clean(strings.TrimPrefix(target, "/")))
+
+	var candidates []string
+	for _, base := range bases {
+		if base == ".." || strings.HasPrefix(base, "../") {
+			continue
+		}
+		if path.Ext(base) == "" {
+			candidates = append(candidates, base+".md", base+".org")
+		}
+		candidates = append(candidates, base)
+	}
+	return candidates
+}
+
+func cutLine(src []byte, want string) ([]byte, bool) {
+	line, rest, ok := bytes.Cut(src, []byte("\n"))
+	if !ok || strings.TrimSpace(string(line)) != want {
+		return nil, false
+	}
+	return rest, true
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,174 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	orgHeadingRe  = regexp.MustCompile(`^(\*+)\s+(?:(TODO|DONE)\s+)?(.*?)(?:\s+(:[[:alnum:]_@#%:]+:))?\s*$`)
+	orgBlockRe    = regexp.MustCompile(`(?i)^#\+begin_(\w+)\s*(\S*)`)
+	orgKeywordRe  = regexp.MustCompile(`^#\+\w+:`)
+	orgDrawerRe   = regexp.MustCompile(`^:[\w-]+:$`)
+	orgRuleRe     = regexp.MustCompile(`^-{5,}$`)
+	orgOrderedRe  = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	orgBulletRe   = regexp.MustCompile(`^\s*[-+]\s+(.*)$|^\s+\*\s+(.*)$`)
+	orgLinkRe     = regexp.MustCompile(`\[\[([^\]]+)\](?:\[([^\]]+)\])?\]`)
+	orgCodeRe     = regexp.MustCompile(`(^|[\s(\x00])[=~]([^\s=~](?:[^=~]*?[^\s=~])?)[=~]($|[\s.,;:!?)\x00])`)
+	orgStrongRe   = regexp.MustCompile(`(^|[\s(\x00])\*([^\s*](?:[^*]*?[^\s*])?)\*($|[\s.,;:!?)\x00])`)
+	orgEmphasisRe = regexp.MustCompile(`(^|[\s(\x00])/([^\s/](?:[^/]*?[^\s/])?)/($|[\s.,;:!?)\x00])`)
+	orgStrikeRe   = regexp.MustCompile(`(^|[\s(\x00])\+([^\s+](?:[^+]*?[^\s+])?)\+($|[\s.,;:!?)\x00])`)
+)
+
+// RenderOrg converts Org source to an HTML fragment. It supports headings,
+// with their TODO keyword and without tags, paragraphs, source, example
+// and quote blocks, lists, horizontal rules, verbatim, emphasis and links.
+// Keywords such as #+TITLE, comments and drawers are left out. Links to
+// files, [[file:notes.org][notes]] or [[notes]], are resolved with
+// opts.WikiLink like Markdown wiki-links.
+func RenderOrg(src []byte, opts Options) string {
+	var b strings.Builder
+	var para []string
+	list := ""
+
+	flushPara := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>" + orgInline(strings.Join(para, " "), opts) + "</p>\n")
+			para = nil
+		}
+	}
+	closeList := func() {
+		if list != "" {
+			b.WriteString("</" + list + ">\n")
+			list = ""
+		}
+	}
+	openList := func(tag string) {
+		if list != tag {
+			closeList()
+			b.WriteString("<" + tag + ">\n")
+			list = tag
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if m := orgBlockRe.FindStringSubmatch(trimmed); m != nil {
+			flushPara()
+			closeList()
+			kind := strings.ToLower(m[1])
+			var body []string
+			for i++; i < len(lines) && !strings.EqualFold(strings.TrimSpace(lines[i]), "#+end_"+kind); i++ {
+				body = append(body, lines[i])
+			}
+			switch {
+			case kind == "quote":
+				b.WriteString("<blockquote><p>" + orgInline(strings.Join(strings.Fields(strings.Join(body, " ")), " "), opts) + "</p></blockquote>\n")
+			case kind == "src" && m[2] != "":
+				b.WriteString(`<pre><code class="language-` + html.EscapeString(m[2]) + `">`)
+				b.WriteString(html.EscapeString(strings.Join(body, "\n")) + "</code></pre>\n")
+			default:
+				b.WriteString("<pre><code>" + html.EscapeString(strings.Join(body, "\n")) + "</code></pre>\n")
+			}
+			continue
+		}
+		if orgDrawerRe.MatchString(trimmed) && !strings.EqualFold(trimmed, ":END:") {
+			end := i + 1
+			for end < len(lines) && !strings.EqualFold(strings.TrimSpace(lines[end]), ":END:") {
+				end++
+			}
+			if end < len(lines) {
+				i = end
+				continue
+			}
+		}
+
+		switch {
+		case trimmed == "":
+			flushPara()
+			closeList()
+		case orgKeywordRe.MatchString(trimmed), trimmed == "#", strings.HasPrefix(trimmed, "# "):
+			// Keywords and comments are not part of the text.
+		case orgHeadingRe.MatchString(line):
+			flushPara()
+			closeList()
+			m := orgHeadingRe.FindStringSubmatch(line)
+			level := string(rune('0' + min(len(m[1]), 6)))
+			b.WriteString("<h" + level + ">")
+			if m[2] != "" {
+				b.WriteString(`<span class="` + strings.ToLower(m[2]) + `">` + m[2] + "</span> ")
+			}
+			b.WriteString(orgInline(m[3], opts) + "</h" + level + ">\n")
+		case orgRuleRe.MatchString(trimmed):
+			flushPara()
+			closeList()
+			b.WriteString("<hr>\n")
+		case orgBulletRe.MatchString(line):
+			flushPara()
+			openList("ul")
+			m := orgBulletRe.FindStringSubmatch(line)
+			b.WriteString("<li>" + orgInline(m[1]+m[2], opts) + "</li>\n")
+		case orgOrderedRe.MatchString(line):
+			flushPara()
+			openList("ol")
+			b.WriteString("<li>" + orgInline(orgOrderedRe.FindStringSubmatch(line)[1], opts) + "</li>\n")
+		default:
+			closeList()
+			para = append(para, trimmed)
+		}
+	}
+	flushPara()
+	closeList()
+
+	return b.String()
+}
+
+// orgInline escapes text and applies Org's span-level markup. Links and
+// verbatim text are held aside first so that markup never applies inside
+// them.
+func orgInline(text string, opts Options) string {
+	var held placeholders
+	text = html.EscapeString(strings.ReplaceAll(text, "\x00", "\uFFFD"))
+
+	text = orgLinkRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := orgLinkRe.FindStringSubmatch(s)
+		target := html.UnescapeString(m[1])
+		label := m[2]
+		if label == "" {
+			label = html.EscapeString(strings.TrimPrefix(target, "file:"))
+		}
+		if strings.Contains(target, "://") || strings.HasPrefix(target, "mailto:") {
+			if !safeURL(target) {
+				return label
+			}
+			return held.hold(`<a href="`+m[1]+`" rel="nofollow noopener">`) + label + held.hold(`</a>`)
+		}
+		if opts.WikiLink == nil {
+			return label
+		}
+		openTag, closeTag := wikiLink(strings.TrimPrefix(target, "file:"), opts)
+		return held.hold(openTag) + label + held.hold(closeTag)
+	})
+	wrap := func(re *regexp.Regexp, tag string) {
+		text = re.ReplaceAllStringFunc(text, func(s string) string {
+			m := re.FindStringSubmatch(s)
+			return m[1] + held.hold("<"+tag+">") + m[2] + held.hold("</"+tag+">") + m[3]
+		})
+	}
+	text = orgCodeRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := orgCodeRe.FindStringSubmatch(s)
+		return m[1] + held.hold("<code>"+m[2]+"</code>") + m[3]
+	})
+	// Each pattern consumes the character after its closing marker, so a
+	// second pass catches spans separated by a single space.
+	for range 2 {
+		wrap(orgStrongRe, "strong")
+		wrap(orgEmphasisRe, "em")
+		wrap(orgStrikeRe, "del")
+	}
+	return held.restore(text)
+}
@@ -0,0 +1,96 @@
+// Package netguard keeps the requests the server makes to URLs its users
+// choose, such as webhooks and images in imported notes, from reaching
+// the server's own network: loopback, private and link-local addresses,
+// which include cloud metadata endpoints.
+//
+// CheckHost refuses such hosts up front, so users learn of the mistake
+// when they enter the URL. Dialer enforces the rule on every connection,
+// after DNS resolution, so a name cannot be pointed at an internal
+// address once it has been checked.
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrNonPublic is returned, wrapped, for hosts and addresses that are not
+// reachable from the public internet.
+var ErrNonPublic = errors.New("non-public address")
+
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// PublicIP reports whether ip is a public unicast address.
+func PublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() &&
+		!sharedAddressSpace.Contains(ip)
+}
+
+// CheckHostname refuses, without a lookup, hosts that can only name
+// something inside the server's network: non-public IP literals,
+// localhost, single-label names that resolve through search domains, and
+// the .internal and .local names cloud metadata services and mDNS use.
+func CheckHostname(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+	if host == "" {
+		return fmt.Errorf("missing host")
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if !PublicIP(ip) {
+			return fmt.Errorf("%s is a %w", host, ErrNonPublic)
+		}
+		return nil
+	}
+	if !strings.Contains(host, ".") || host == "localhost" ||
+		strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") ||
+		strings.HasSuffix(host, ".local") {
+		return fmt.Errorf("%s is a %w", host, ErrNonPublic)
+	}
+	return nil
+}
+
+// CheckHost is CheckHostname followed by a lookup: every address host
+// resolves to must be public.
+func CheckHost(ctx context.Context, host string) error {
+	if err := CheckHostname(host); err != nil {
+		return err
+	}
+	host = strings.Trim(host, "[]")
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("cannot resolve %s", host)
+	}
+	for _, addr := range addrs {
+		if !PublicIP(addr.IP) {
+			return fmt.Errorf("%s resolves to a %w", host, ErrNonPublic)
+		}
+	}
+	return nil
+}
+
+// Dialer returns a dialer that only connects to public addresses.
+func Dialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+				return fmt.Errorf("refusing to connect to %s: %w", host, ErrNonPublic)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,41 @@
+package netguard
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckHostname(t *testing.T) {
+	for _, host := range []string{
+		"127.0.0.1", "[::1]", "10.1.2.3", "192.168.0.10", "169.254.169.254",
+		"100.64.0.1", "0.0.0.0", "localhost", "api.localhost",
+		"metadata.google.internal", "printer.local", "redis", "LOCALHOST.",
+	} {
+		assert.ErrorIs(t, CheckHostname(host), ErrNonPublic, host)
+	}
+	for _, host := range []string{"example.com", "93.184.215.14", "[2606:2800:21f:cb07:6820:80da:af6b:8b2c]"} {
+		assert.NoError(t, CheckHostname(host), host)
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	assert.ErrorIs(t, CheckHost(context.Background(), "169.254.169.254"), ErrNonPublic)
+	assert.NoError(t, CheckHost(context.Background(), "93.184.215.14"))
+}
+
+func TestDialer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{DialContext: Dialer(time.Second).DialContext}}
+	_, err := client.Get(server.URL)
+	assert.ErrorIs(t, err, ErrNonPublic)
+
+	assert.True(t, PublicIP(net.ParseIP("93.184.215.14")))
+}
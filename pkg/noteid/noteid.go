@@ -0,0 +1,120 @@
+// Package noteid finds the stable ID of a note, which survives renames
+// that change its path. IDs come from the client with the upload or, with
+// the frontmatter strategy, from the note itself: a Markdown frontmatter
+// "id:" field or an Org "#+ID:" keyword or top-level ":ID:" property.
+package noteid
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// MaxLength caps a note ID.
+const MaxLength = 200
+
+// Strategy finds the ID of a note from its path and content. It returns ""
+// for notes without one.
+type Strategy interface {
+	NoteID(path string, content []byte) string
+}
+
+// Strategy names accepted by Parse.
+const (
+	StrategyFrontmatter = "frontmatter"
+	StrategyClient      = "client"
+)
+
+// Parse returns the strategy with the given name.
+func Parse(name string) (Strategy, error) {
+	switch name {
+	case StrategyFrontmatter:
+		return Frontmatter{}, nil
+	case StrategyClient:
+		return ClientOnly{}, nil
+	}
+	return nil, fmt.Errorf("unknown note ID strategy %q: must be %s or %s", name, StrategyFrontmatter, StrategyClient)
+}
+
+// Valid reports whether id may be stored: non-empty, at most MaxLength
+// bytes, without whitespace or control characters.
+func Valid(id string) bool {
+	if id == "" || len(id) > MaxLength {
+		return false
+	}
+	return strings.IndexFunc(id, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}) < 0
+}
+
+// ClientOnly leaves IDs to clients.
+type ClientOnly struct{}
+
+func (ClientOnly) NoteID(path string, content []byte) string {
+	return ""
+}
+
+// Frontmatter reads IDs from the notes. Invalid IDs are ignored.
+type Frontmatter struct{}
+
+func (Frontmatter) NoteID(path string, content []byte) string {
+	var id string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		id = markdownID(content)
+	case ".org":
+		id = orgID(content)
+	}
+	if !Valid(id) {
+		return ""
+	}
+	return id
+}
+
+// markdownID reads the id field of a YAML frontmatter block, which must
+// open the file.
+func markdownID(content []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "---" {
+		return ""
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if trimmed := strings.TrimSpace(line); trimmed == "---" || trimmed == "..." {
+			return ""
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if ok && key == "id" {
+			return unquote(strings.TrimSpace(value))
+		}
+	}
+	return ""
+}
+
+// orgID reads an #+ID: keyword or an :ID: property from the lines before
+// the first headline.
+func orgID(content []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "*") {
+			return ""
+		}
+		for _, prefix := range []string{"#+id:", ":id:"} {
+			if len(line) > len(prefix) && strings.EqualFold(line[:len(prefix)], prefix) {
+				return strings.TrimSpace(line[len(prefix):])
+			}
+		}
+	}
+	return ""
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
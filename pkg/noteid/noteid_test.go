@@ -0,0 +1,43 @@
+package noteid
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFrontmatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		content string
+		want    string
+	}{
+		{"markdown", "a.md", "---\ntitle: A\nid: 20260101-ideas\n---\n# A", "20260101-ideas"},
+		{"quoted", "a.md", "---\nid: \"abc\"\n---\n", "abc"},
+		{"not at the start", "a.md", "# A\n---\nid: abc\n---\n", ""},
+		{"after frontmatter", "a.md", "---\ntitle: A\n---\nid: abc\n", ""},
+		{"nested key", "a.md", "---\nmeta:\n  id: abc\n---\n", ""},
+		{"with spaces", "a.md", "---\nid: two words\n---\n", ""},
+		{"too long", "a.md", "---\nid: " + strings.Repeat("x", MaxLength+1) + "\n---\n", ""},
+		{"org keyword", "a.org", "#+TITLE: A\n#+ID: abc\n* Heading", "abc"},
+		{"org property", "a.org", ":PROPERTIES:\n:ID:       5f3e\n:END:\n#+title: A\n", "5f3e"},
+		{"org headline property", "a.org", "* Heading\n:PROPERTIES:\n:ID: abc\n:END:\n", ""},
+		{"other formats", "a.txt", "---\nid: abc\n---\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Frontmatter{}).NoteID(tt.path, []byte(tt.content)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	if s, err := Parse(StrategyClient); err != nil || s.NoteID("a.md", []byte("---\nid: abc\n---\n")) != "" {
+		t.Errorf("expected the client strategy to find nothing, got %v", err)
+	}
+	if _, err := Parse("uuid"); err == nil {
+		t.Error("expected an unknown strategy to fail")
+	}
+}
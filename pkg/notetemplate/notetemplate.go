@@ -0,0 +1,48 @@
+// Package notetemplate fills in note templates. A template is plain text
+// with placeholders written {{name}}; the built-in ones describe when and
+// as what the note is created, and callers may add their own.
+package notetemplate
+
+import (
+	"regexp"
+	"time"
+)
+
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// Variables returns the built-in placeholders for a note titled title and
+// created at now, in now's zone:
+//
+//	{{date}}      2026-01-02
+//	{{time}}      15:04
+//	{{datetime}}  2026-01-02 15:04
+//	{{year}}      2026
+//	{{month}}     01
+//	{{day}}       02
+//	{{weekday}}   Friday
+//	{{title}}     the title
+func Variables(now time.Time, title string) map[string]string {
+	return map[string]string{
+		"date":     now.Format(time.DateOnly),
+		"time":     now.Format("15:04"),
+		"datetime": now.Format("2006-01-02 15:04"),
+		"year":     now.Format("2006"),
+		"month":    now.Format("01"),
+		"day":      now.Format("02"),
+		"weekday":  now.Weekday().String(),
+		"title":    title,
+	}
+}
+
+// Render replaces each placeholder in content with its value in vars.
+// Placeholders without a value are left as they are, so templates can
+// contain text for other tools that uses the same braces.
+func Render(content string, vars map[string]string) string {
+	return placeholder.ReplaceAllStringFunc(content, func(match string) string {
+		name := placeholder.FindStringSubmatch(match)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return match
+	})
+}
@@ -0,0 +1,32 @@
+package notetemplate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	now := time.Date(2026, time.January, 2, 15, 4, 0, 0, time.UTC)
+	vars := Variables(now, "Standup")
+	vars["project"] = "noture"
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"built-ins", "# {{title}}\n{{date}} {{time}}", "# Standup\n2026-01-02 15:04"},
+		{"date parts", "{{year}}/{{month}}/{{day}} {{weekday}}", "2026/01/02 Friday"},
+		{"spaces inside braces", "{{ datetime }}", "2026-01-02 15:04"},
+		{"custom variables", "project: {{project}}", "project: noture"},
+		{"unknown placeholders are kept", "{{author}} {{date}}", "{{author}} 2026-01-02"},
+		{"not a placeholder", "{{two words}} {single}", "{{two words}} {single}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.content, vars); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
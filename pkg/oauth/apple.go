@@ -0,0 +1,218 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/duckonomy/noture/pkg/logger"
+)
+
+// AppleOAuthConfig signs in with Apple. Instead of a fixed secret, Apple
+// takes a short-lived JWT signed with a private key from the developer
+// account, which ClientSecret generates. ClientID is the Services ID.
+type AppleOAuthConfig struct {
+	ClientID    string
+	TeamID      string
+	KeyID       string
+	PrivateKey  *ecdsa.PrivateKey
+	RedirectURL string
+	Log         *logger.Logger
+}
+
+// AppleUser is the user form field Apple posts to the callback on the
+// first sign-in only. It is the only place Apple reports the name.
+type AppleUser struct {
+	Name struct {
+		FirstName string `json:"firstName"`
+		LastName  string `json:"lastName"`
+	} `json:"name"`
+	Email string `json:"email"`
+}
+
+const (
+	AppleAuthURL  = "https://appleid.apple.com/auth/authorize"
+	AppleTokenURL = "https://appleid.apple.com/auth/token"
+	AppleIssuer   = "https://appleid.apple.com"
+	AppleScopes   = "name email"
+
+	// appleClientSecretTTL is how long a generated client secret is valid.
+	// Apple accepts up to six months; one is made per exchange.
+	appleClientSecretTTL = 5 * time.Minute
+)
+
+func NewAppleOAuthConfig(clientID, teamID, keyID string, privateKey *ecdsa.PrivateKey, redirectURL string, log *logger.Logger) *AppleOAuthConfig {
+	return &AppleOAuthConfig{
+		ClientID:    clientID,
+		TeamID:      teamID,
+		KeyID:       keyID,
+		PrivateKey:  privateKey,
+		RedirectURL: redirectURL,
+		Log:         log,
+	}
+}
+
+// ParseApplePrivateKey parses the PEM-encoded P-256 key (the .p8 file)
+// Apple issues for signing client secrets.
+func ParseApplePrivateKey(pemKey string) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, fmt.Errorf("invalid private key: not PEM")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || ecKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("invalid private key: must be a P-256 key")
+	}
+	return ecKey, nil
+}
+
+func (a *AppleOAuthConfig) Name() string {
+	return "apple"
+}
+
+// AuthURL returns the sign-in URL. Apple posts the result to the
+// callback as a form, as it does whenever name or email is requested.
+// Apple does not support PKCE, so codeChallenge is ignored; its client
+// secret is signed per exchange instead.
+func (a *AppleOAuthConfig) AuthURL(state, _ string) string {
+	params := url.Values{
+		"client_id":     {a.ClientID},
+		"redirect_uri":  {a.RedirectURL},
+		"response_type": {"code"},
+		"response_mode": {"form_post"},
+		"scope":         {AppleScopes},
+		"state":         {state},
+	}
+
+	return AppleAuthURL + "?" + params.Encode()
+}
+
+// ClientSecret generates the ES256-signed JWT Apple takes as the client
+// secret.
+func (a *AppleOAuthConfig) ClientSecret(now time.Time) (string, error) {
+	if a.PrivateKey == nil {
+		return "", fmt.Errorf("apple private key not configured")
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "ES256", "kid": a.KeyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss": a.TeamID,
+		"iat": now.Unix(),
+		"exp": now.Add(appleClientSecretTTL).Unix(),
+		"aud": AppleIssuer,
+		"sub": a.ClientID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, a.PrivateKey, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign client secret: %w", err)
+	}
+
+	// JWS takes the signature as the two 32-byte halves, not ASN.1.
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (a *AppleOAuthConfig) Exchange(ctx context.Context, code, _ string) (*TokenResponse, error) {
+	a.Log.Info("Exchanging Apple authorization code for token")
+
+	secret, err := a.ClientSecret(time.Now())
+	if err != nil {
+		a.Log.WithError(err).Error("Failed to generate Apple client secret")
+		return nil, err
+	}
+
+	tokenResponse, err := exchangeCode(ctx, AppleTokenURL, url.Values{
+		"client_id":     {a.ClientID},
+		"client_secret": {secret},
+		"code":          {code},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {a.RedirectURL},
+	})
+	if err != nil {
+		a.Log.WithError(err).Error("Failed to exchange code for Apple token")
+		return nil, err
+	}
+
+	a.Log.Info("Successfully exchanged code for Apple token", "token_type", tokenResponse.TokenType)
+	return tokenResponse, nil
+}
+
+// UserInfo reads the account from the ID token and its name from the
+// user parameter of the callback, if any.
+func (a *AppleOAuthConfig) UserInfo(_ context.Context, token *TokenResponse, callback url.Values) (*Identity, error) {
+	var claims struct {
+		Email          string    `json:"email"`
+		EmailVerified  appleBool `json:"email_verified"`
+		IsPrivateEmail appleBool `json:"is_private_email"`
+	}
+	standard, err := readIDToken(token.IDToken, &claims)
+	if err == nil {
+		err = standard.verify(AppleIssuer, a.ClientID)
+	}
+	if err != nil {
+		a.Log.WithError(err).Error("Rejected Apple ID token")
+		return nil, err
+	}
+
+	identity := &Identity{
+		ID:            standard.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+	}
+	if user := callback.Get("user"); user != "" {
+		var appleUser AppleUser
+		if err := json.Unmarshal([]byte(user), &appleUser); err != nil {
+			a.Log.WithError(err).Warn("Ignoring unreadable Apple user field")
+		} else {
+			identity.Name = strings.TrimSpace(appleUser.Name.FirstName + " " + appleUser.Name.LastName)
+		}
+	}
+
+	a.Log.Info("Successfully retrieved Apple user info",
+		"user_id", identity.ID,
+		"email", identity.Email,
+		"private_email", bool(claims.IsPrivateEmail))
+
+	return identity, nil
+}
+
+// appleBool reads Apple's boolean claims, which come as either JSON
+// booleans or the strings "true" and "false".
+type appleBool bool
+
+func (b *appleBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	case "false", "null":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean %s", data)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppleOAuthConfig_ClientSecret(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	parsed, err := ParseApplePrivateKey(string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})))
+	require.NoError(t, err)
+
+	apple := NewAppleOAuthConfig("com.example.web", "TEAM123", "KEY123", parsed, "https://example.com/auth/apple/callback", logger.New())
+	now := time.Unix(1_700_000_000, 0)
+	secret, err := apple.ClientSecret(now)
+	require.NoError(t, err)
+
+	parts := strings.Split(secret, ".")
+	require.Len(t, parts, 3)
+
+	var header map[string]string
+	decodeSegment(t, parts[0], &header)
+	assert.Equal(t, map[string]string{"alg": "ES256", "kid": "KEY123"}, header)
+
+	var claims map[string]interface{}
+	decodeSegment(t, parts[1], &claims)
+	assert.Equal(t, "TEAM123", claims["iss"])
+	assert.Equal(t, "com.example.web", claims["sub"])
+	assert.Equal(t, AppleIssuer, claims["aud"])
+	assert.Equal(t, float64(now.Add(appleClientSecretTTL).Unix()), claims["exp"])
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	require.Len(t, signature, 64)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	assert.True(t, ecdsa.Verify(&key.PublicKey, digest[:], r, s))
+
+	_, err = ParseApplePrivateKey("not a key")
+	assert.ErrorContains(t, err, "invalid private key")
+}
+
+func TestAppleOAuthConfig_UserInfo(t *testing.T) {
+	apple := NewAppleOAuthConfig("com.example.web", "TEAM123", "KEY123", nil, "", logger.New())
+	ctx := context.Background()
+	idToken := func(claims map[string]interface{}) *TokenResponse {
+		payload, err := json.Marshal(claims)
+		require.NoError(t, err)
+		return &TokenResponse{IDToken: "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"}
+	}
+	valid := map[string]interface{}{
+		"iss":              AppleIssuer,
+		"aud":              "com.example.web",
+		"sub":              "001234.abcd",
+		"exp":              time.Now().Add(time.Hour).Unix(),
+		"email":            "relay@privaterelay.appleid.com",
+		"email_verified":   "true",
+		"is_private_email": true,
+	}
+
+	identity, err := apple.UserInfo(ctx, idToken(valid), url.Values{"user": {`{"name":{"firstName":"Ada","lastName":"Lovelace"}}`}})
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{
+		ID:            "001234.abcd",
+		Email:         "relay@privaterelay.appleid.com",
+		EmailVerified: true,
+		Name:          "Ada Lovelace",
+	}, identity)
+
+	valid["aud"] = "com.example.other"
+	_, err = apple.UserInfo(ctx, idToken(valid), nil)
+	assert.ErrorContains(t, err, "issued to")
+
+	valid["aud"] = "com.example.web"
+	valid["exp"] = time.Now().Add(-time.Minute).Unix()
+	_, err = apple.UserInfo(ctx, idToken(valid), nil)
+	assert.ErrorContains(t, err, "expired")
+}
+
+func decodeSegment(t *testing.T, segment string, v interface{}) {
+	t.Helper()
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, v))
+}
@@ -0,0 +1,61 @@
+package oauth
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevProvider(t *testing.T) {
+	dev := NewDevProvider("http://localhost:8080/auth/dev/callback")
+	ctx := context.Background()
+
+	authURL, err := url.Parse(dev.AuthURL("some-state", ""))
+	require.NoError(t, err)
+	assert.Equal(t, "/auth/dev/callback", authURL.Path, "signing in leads straight to the callback")
+	callback := authURL.Query()
+	assert.Equal(t, "some-state", callback.Get("state"))
+
+	token, err := dev.Exchange(ctx, callback.Get("code"), "")
+	require.NoError(t, err)
+	_, err = dev.Exchange(ctx, "other", "")
+	assert.Error(t, err)
+
+	identity, err := dev.UserInfo(ctx, token, callback)
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{ID: DevEmail, Email: DevEmail, EmailVerified: true, Name: "dev"}, identity)
+
+	callback.Set("email", " Alice@Example.com ")
+	identity, err = dev.UserInfo(ctx, token, callback)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", identity.ID)
+
+	callback.Set("email", "not an address")
+	_, err = dev.UserInfo(ctx, token, callback)
+	assert.ErrorContains(t, err, "invalid email")
+}
+
+func TestDevProvider_PKCE(t *testing.T) {
+	dev := NewDevProvider("http://localhost:8080/auth/dev/callback")
+	ctx := context.Background()
+
+	verifier, err := GenerateCodeVerifier()
+	require.NoError(t, err)
+	assert.True(t, ValidCodeChallenge(CodeChallenge(verifier)))
+
+	authURL, err := url.Parse(dev.AuthURL("some-state", CodeChallenge(verifier)))
+	require.NoError(t, err)
+	code := authURL.Query().Get("code")
+
+	_, err = dev.Exchange(ctx, code, "")
+	assert.ErrorContains(t, err, "code verifier does not match")
+	other, err := GenerateCodeVerifier()
+	require.NoError(t, err)
+	_, err = dev.Exchange(ctx, code, other)
+	assert.ErrorContains(t, err, "code verifier does not match")
+	_, err = dev.Exchange(ctx, code, verifier)
+	assert.NoError(t, err)
+}
@@ -2,13 +2,10 @@ package oauth
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/duckonomy/noture/pkg/logger"
 )
@@ -35,11 +32,13 @@ type GitHubEmail struct {
 }
 
 const (
-	GitHubAuthURL     = "https://github.com/login/oauth/authorize"
-	GitHubTokenURL    = "https://github.com/login/oauth/access_token"
-	GitHubUserURL     = "https://api.github.com/user"
-	GitHubEmailURL    = "https://api.github.com/user/emails"
-	GitHubScopes      = "user:email"
+	GitHubAuthURL  = "https://github.com/login/oauth/authorize"
+	GitHubTokenURL = "https://github.com/login/oauth/access_token"
+	GitHubUserURL  = "https://api.github.com/user"
+	GitHubEmailURL = "https://api.github.com/user/emails"
+	GitHubScopes   = "user:email"
+
+	gitHubAccept = "application/vnd.github.v3+json"
 )
 
 func NewGitHubOAuthConfig(clientID, clientSecret, redirectURL string, log *logger.Logger) *GitHubOAuthConfig {
@@ -51,7 +50,11 @@ func NewGitHubOAuthConfig(clientID, clientSecret, redirectURL string, log *logge
 	}
 }
 
-func (g *GitHubOAuthConfig) GetAuthURL(state string) string {
+func (g *GitHubOAuthConfig) Name() string {
+	return "github"
+}
+
+func (g *GitHubOAuthConfig) AuthURL(state, codeChallenge string) string {
 	params := url.Values{
 		"client_id":    {g.ClientID},
 		"redirect_uri": {g.RedirectURL},
@@ -59,165 +62,83 @@ func (g *GitHubOAuthConfig) GetAuthURL(state string) string {
 		"state":        {state},
 	}
 
+	addPKCE(params, codeChallenge)
 	return GitHubAuthURL + "?" + params.Encode()
 }
 
-func (g *GitHubOAuthConfig) ExchangeCodeForToken(ctx context.Context, code string) (*TokenResponse, error) {
+func (g *GitHubOAuthConfig) Exchange(ctx context.Context, code, codeVerifier string) (*TokenResponse, error) {
 	g.Log.Info("Exchanging GitHub authorization code for token")
 
-	data := url.Values{
+	params := url.Values{
 		"client_id":     {g.ClientID},
 		"client_secret": {g.ClientSecret},
 		"code":          {code},
 		"redirect_uri":  {g.RedirectURL},
 	}
+	addVerifier(params, codeVerifier)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", GitHubTokenURL, strings.NewReader(data.Encode()))
-	if err != nil {
-		g.Log.WithError(err).Error("Failed to create GitHub token exchange request")
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Accept", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	tokenResponse, err := exchangeCode(ctx, GitHubTokenURL, params)
 	if err != nil {
 		g.Log.WithError(err).Error("Failed to exchange code for GitHub token")
-		return nil, fmt.Errorf("token exchange failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		g.Log.WithError(err).Error("Failed to read GitHub token response")
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		g.Log.Error("GitHub token exchange returned non-200 status",
-			"status_code", resp.StatusCode,
-			"response", string(body))
-		return nil, fmt.Errorf("token exchange failed with status %d: %s", resp.StatusCode, body)
-	}
-
-	var tokenResponse TokenResponse
-	if err := json.Unmarshal(body, &tokenResponse); err != nil {
-		g.Log.WithError(err).Error("Failed to parse GitHub token response")
-		return nil, fmt.Errorf("failed to parse token response: %w", err)
+		return nil, err
 	}
 
 	g.Log.Info("Successfully exchanged code for GitHub token", "token_type", tokenResponse.TokenType)
-	return &tokenResponse, nil
+	return tokenResponse, nil
 }
 
-func (g *GitHubOAuthConfig) GetUserInfo(ctx context.Context, accessToken string) (*GitHubUserInfo, error) {
+// UserInfo looks up the profile and, since profiles do not say whether
+// their email is verified, the account's emails.
+func (g *GitHubOAuthConfig) UserInfo(ctx context.Context, token *TokenResponse, _ url.Values) (*Identity, error) {
 	g.Log.Info("Fetching user information from GitHub")
 
-	userInfo, err := g.fetchUserInfo(ctx, accessToken)
-	if err != nil {
-		return nil, err
-	}
-
-	if userInfo.Email == "" {
-		email, err := g.fetchPrimaryEmail(ctx, accessToken)
-		if err != nil {
-			g.Log.WithError(err).Warn("Failed to fetch user email, proceeding without it")
-		} else {
-			userInfo.Email = email
-		}
-	}
-
-	g.Log.Info("Successfully retrieved GitHub user info",
-		"user_id", userInfo.ID,
-		"login", userInfo.Login,
-		"email", userInfo.Email)
-
-	return userInfo, nil
-}
-
-func (g *GitHubOAuthConfig) fetchUserInfo(ctx context.Context, accessToken string) (*GitHubUserInfo, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", GitHubUserURL, nil)
-	if err != nil {
-		g.Log.WithError(err).Error("Failed to create GitHub user info request")
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
+	var userInfo GitHubUserInfo
+	if err := getJSON(ctx, GitHubUserURL, token.AccessToken, gitHubAccept, &userInfo); err != nil {
 		g.Log.WithError(err).Error("Failed to fetch GitHub user info")
 		return nil, fmt.Errorf("user info request failed: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		g.Log.WithError(err).Error("Failed to read GitHub user info response")
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
 
-	if resp.StatusCode != http.StatusOK {
-		g.Log.Error("GitHub user info request returned non-200 status",
-			"status_code", resp.StatusCode,
-			"response", string(body))
-		return nil, fmt.Errorf("user info request failed with status %d: %s", resp.StatusCode, body)
+	var emails []GitHubEmail
+	if err := getJSON(ctx, GitHubEmailURL, token.AccessToken, gitHubAccept, &emails); err != nil {
+		g.Log.WithError(err).Warn("Failed to fetch user emails, treating the email as unverified")
 	}
 
-	var userInfo GitHubUserInfo
-	if err := json.Unmarshal(body, &userInfo); err != nil {
-		g.Log.WithError(err).Error("Failed to parse GitHub user info response")
-		return nil, fmt.Errorf("failed to parse user info: %w", err)
+	identity := &Identity{
+		ID:    strconv.Itoa(userInfo.ID),
+		Email: userInfo.Email,
+		Name:  userInfo.Name,
 	}
-
-	return &userInfo, nil
-}
-
-func (g *GitHubOAuthConfig) fetchPrimaryEmail(ctx context.Context, accessToken string) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", GitHubEmailURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+	if identity.Email == "" {
+		identity.Email = primaryEmail(emails)
 	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("email request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+	for _, email := range emails {
+		if email.Verified && strings.EqualFold(email.Email, identity.Email) {
+			identity.EmailVerified = true
+		}
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("email request failed with status %d: %s", resp.StatusCode, body)
-	}
+	g.Log.Info("Successfully retrieved GitHub user info",
+		"user_id", userInfo.ID,
+		"login", userInfo.Login,
+		"email", identity.Email)
 
-	var emails []GitHubEmail
-	if err := json.Unmarshal(body, &emails); err != nil {
-		return "", fmt.Errorf("failed to parse emails: %w", err)
-	}
+	return identity, nil
+}
 
+// primaryEmail picks the address to sign in with from a user's emails:
+// the primary one if verified, else any verified one.
+func primaryEmail(emails []GitHubEmail) string {
 	for _, email := range emails {
 		if email.Primary && email.Verified {
-			return email.Email, nil
+			return email.Email
 		}
 	}
 
 	for _, email := range emails {
 		if email.Verified {
-			return email.Email, nil
+			return email.Email
 		}
 	}
 
-	return "", fmt.Errorf("no verified email found")
+	return ""
 }
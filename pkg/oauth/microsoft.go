@@ -0,0 +1,118 @@
+package oauth
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/duckonomy/noture/pkg/logger"
+)
+
+// MicrosoftOAuthConfig signs in with Microsoft accounts, both work or
+// school accounts from any Azure AD tenant and personal accounts.
+type MicrosoftOAuthConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Log          *logger.Logger
+}
+
+const (
+	MicrosoftAuthURL  = "https://login.microsoftonline.com/common/oauth2/v2.0/authorize"
+	MicrosoftTokenURL = "https://login.microsoftonline.com/common/oauth2/v2.0/token"
+	MicrosoftScopes   = "openid email profile"
+
+	// MicrosoftConsumerTenant is the tenant of personal Microsoft accounts.
+	MicrosoftConsumerTenant = "9188040d-6c67-4c5b-b112-36a304b66dad"
+)
+
+func NewMicrosoftOAuthConfig(clientID, clientSecret, redirectURL string, log *logger.Logger) *MicrosoftOAuthConfig {
+	return &MicrosoftOAuthConfig{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Log:          log,
+	}
+}
+
+func (m *MicrosoftOAuthConfig) Name() string {
+	return "microsoft"
+}
+
+func (m *MicrosoftOAuthConfig) AuthURL(state, codeChallenge string) string {
+	params := url.Values{
+		"client_id":     {m.ClientID},
+		"redirect_uri":  {m.RedirectURL},
+		"response_type": {"code"},
+		"response_mode": {"query"},
+		"scope":         {MicrosoftScopes},
+		"state":         {state},
+		"prompt":        {"select_account"},
+	}
+
+	addPKCE(params, codeChallenge)
+	return MicrosoftAuthURL + "?" + params.Encode()
+}
+
+func (m *MicrosoftOAuthConfig) Exchange(ctx context.Context, code, codeVerifier string) (*TokenResponse, error) {
+	m.Log.Info("Exchanging Microsoft authorization code for token")
+
+	params := url.Values{
+		"client_id":     {m.ClientID},
+		"client_secret": {m.ClientSecret},
+		"code":          {code},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {m.RedirectURL},
+		"scope":         {MicrosoftScopes},
+	}
+	addVerifier(params, codeVerifier)
+
+	tokenResponse, err := exchangeCode(ctx, MicrosoftTokenURL, params)
+	if err != nil {
+		m.Log.WithError(err).Error("Failed to exchange code for Microsoft token")
+		return nil, err
+	}
+
+	m.Log.Info("Successfully exchanged code for Microsoft token", "token_type", tokenResponse.TokenType)
+	return tokenResponse, nil
+}
+
+// UserInfo reads the account from the ID token. Its ID is the token's
+// subject, which Microsoft keeps stable per application. Only personal
+// accounts have verified emails: Azure AD tenants may set any email on
+// their users.
+func (m *MicrosoftOAuthConfig) UserInfo(_ context.Context, token *TokenResponse, _ url.Values) (*Identity, error) {
+	var claims struct {
+		TenantID          string `json:"tid"`
+		Email             string `json:"email"`
+		PreferredUsername string `json:"preferred_username"`
+		Name              string `json:"name"`
+	}
+	standard, err := readIDToken(token.IDToken, &claims)
+	if err == nil {
+		// The common endpoint issues tokens from the account's own tenant.
+		err = standard.verify("https://login.microsoftonline.com/"+claims.TenantID+"/v2.0", m.ClientID)
+	}
+	if err != nil {
+		m.Log.WithError(err).Error("Rejected Microsoft ID token")
+		return nil, err
+	}
+
+	identity := &Identity{
+		ID:    standard.Subject,
+		Email: claims.Email,
+		Name:  claims.Name,
+	}
+	if claims.TenantID == MicrosoftConsumerTenant {
+		if identity.Email == "" {
+			identity.Email = claims.PreferredUsername
+		}
+		identity.EmailVerified = identity.Email != ""
+	}
+
+	m.Log.Info("Successfully retrieved Microsoft user info",
+		"user_id", identity.ID,
+		"tenant_id", claims.TenantID,
+		"email", identity.Email)
+
+	return identity, nil
+}
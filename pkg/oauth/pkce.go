@@ -0,0 +1,64 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// PKCEMethod is the only code challenge method accepted: the challenge is
+// the SHA-256 of the verifier. The plain method would let anyone who saw
+// the challenge redeem the code.
+const PKCEMethod = "S256"
+
+// pkceValue is the form both verifiers and S256 challenges take.
+var pkceValue = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+
+// GenerateCodeVerifier returns a fresh PKCE code verifier (RFC 7636).
+func GenerateCodeVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate code verifier: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge returns the S256 challenge of verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// ValidCodeChallenge reports whether challenge can be an S256 challenge.
+func ValidCodeChallenge(challenge string) bool {
+	return pkceValue.MatchString(challenge)
+}
+
+// VerifyCodeVerifier reports whether verifier is the one challenge was
+// made from.
+func VerifyCodeVerifier(verifier, challenge string) bool {
+	if !pkceValue.MatchString(verifier) || challenge == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(CodeChallenge(verifier)), []byte(challenge)) == 1
+}
+
+// addPKCE adds the parameters of challenge to an authorization request,
+// unless it is empty.
+func addPKCE(params url.Values, challenge string) {
+	if challenge != "" {
+		params.Set("code_challenge", challenge)
+		params.Set("code_challenge_method", PKCEMethod)
+	}
+}
+
+// addVerifier adds verifier to a token request, unless it is empty.
+func addVerifier(params url.Values, verifier string) {
+	if verifier != "" {
+		params.Set("code_verifier", verifier)
+	}
+}
@@ -0,0 +1,33 @@
+package oauth
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodeChallenge(t *testing.T) {
+	// The example of RFC 7636, appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", CodeChallenge(verifier))
+	assert.True(t, VerifyCodeVerifier(verifier, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"))
+	assert.False(t, VerifyCodeVerifier(verifier, CodeChallenge("another-verifier-that-is-long-enough-to-be-valid")))
+	assert.False(t, VerifyCodeVerifier("short", CodeChallenge("short")), "verifiers are at least 43 characters")
+	assert.False(t, VerifyCodeVerifier(verifier, ""))
+
+	assert.False(t, ValidCodeChallenge("not a challenge"))
+}
+
+func TestGoogleOAuthConfig_PKCE(t *testing.T) {
+	google := NewGoogleOAuthConfig("client", "secret", "https://example.com/auth/google/callback")
+
+	authURL, err := url.Parse(google.AuthURL("state", "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"))
+	assert.NoError(t, err)
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", authURL.Query().Get("code_challenge"))
+	assert.Equal(t, PKCEMethod, authURL.Query().Get("code_challenge_method"))
+
+	authURL, err = url.Parse(google.AuthURL("state", ""))
+	assert.NoError(t, err)
+	assert.False(t, authURL.Query().Has("code_challenge"))
+}
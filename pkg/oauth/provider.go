@@ -0,0 +1,121 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Provider is an OAuth 2.0 sign-in provider. Signing in opens AuthURL,
+// whose callback brings a code to Exchange for tokens, with which
+// UserInfo looks up the account. A sign-in started with a PKCE code
+// challenge exchanges its code with the verifier of that challenge.
+type Provider interface {
+	// Name identifies the provider in URLs and linked identities.
+	Name() string
+	// AuthURL returns the provider's sign-in URL, carrying state and,
+	// unless empty, the S256 codeChallenge.
+	AuthURL(state, codeChallenge string) string
+	// Exchange trades the code of a callback for tokens, proving the
+	// sign-in's challenge with codeVerifier unless empty.
+	Exchange(ctx context.Context, code, codeVerifier string) (*TokenResponse, error)
+	// UserInfo returns the account that token belongs to. callback holds
+	// every parameter of the callback, for providers that send more than
+	// the code.
+	UserInfo(ctx context.Context, token *TokenResponse, callback url.Values) (*Identity, error)
+}
+
+// Identity is an account at a provider, the same for every provider.
+type Identity struct {
+	// ID is the provider's stable ID for the account; the email may
+	// change.
+	ID            string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+func GenerateState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random state: %w", err)
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+// exchangeCode posts an authorization code grant to tokenURL.
+func exchangeCode(ctx context.Context, tokenURL string, data url.Values) (*TokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokenResponse TokenResponse
+	if err := doJSON(req, &tokenResponse); err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	// GitHub reports a bad code with 200 and an error body.
+	if tokenResponse.AccessToken == "" {
+		return nil, fmt.Errorf("token exchange failed: no access token")
+	}
+	return &tokenResponse, nil
+}
+
+// getJSON fetches endpoint with accessToken into v. accept overrides the
+// default Accept of application/json.
+func getJSON(ctx context.Context, endpoint, accessToken, accept string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if accept == "" {
+		accept = "application/json"
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", accept)
+
+	return doJSON(req, v)
+}
+
+func doJSON(req *http.Request, v interface{}) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, body)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// Package patch applies unified text diffs, as written by diff -u or git
+// diff, to the content they were made against. Hunks must apply exactly
+// where their headers say: the caller knows the base content by its hash,
+// so there is no fuzz and no searching for moved context.
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrMismatch is returned when the base does not match a hunk's context or
+// removed lines.
+var ErrMismatch = errors.New("patch does not apply")
+
+type hunk struct {
+	oldStart, oldLines int
+	newStart, newLines int
+	lines              []string
+}
+
+// Apply returns base with the unified diff applied. File headers ("---",
+// "+++", "diff", "index") are skipped, so the diff may name any file, but
+// it must change one file only.
+func Apply(base, diff []byte) ([]byte, error) {
+	hunks, err := parse(diff)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := splitLines(base)
+	var out bytes.Buffer
+	out.Grow(len(base))
+	pos := 0
+	for n, h := range hunks {
+		start := h.oldStart - 1
+		if h.oldLines == 0 {
+			// A pure insertion names the line it follows.
+			start = h.oldStart
+		}
+		if start < pos || start > len(lines) {
+			return nil, fmt.Errorf("%w: hunk %d starts at line %d", ErrMismatch, n+1, h.oldStart)
+		}
+		for _, line := range lines[pos:start] {
+			out.WriteString(line)
+		}
+		pos = start
+
+		for _, line := range h.lines {
+			text := line[1:]
+			if line[0] == '+' {
+				out.WriteString(text)
+				continue
+			}
+			if pos >= len(lines) || lines[pos] != text {
+				return nil, fmt.Errorf("%w: hunk %d differs at line %d", ErrMismatch, n+1, pos+1)
+			}
+			if line[0] == ' ' {
+				out.WriteString(text)
+			}
+			pos++
+		}
+	}
+	for _, line := range lines[pos:] {
+		out.WriteString(line)
+	}
+	return out.Bytes(), nil
+}
+
+// parse reads the hunks of diff. Each kept line starts with its ' ', '-'
+// or '+' marker and ends with the line's own newline, if any.
+func parse(diff []byte) ([]hunk, error) {
+	var hunks []hunk
+	var current *hunk
+	oldLeft, newLeft := 0, 0
+	headers := 0
+
+	for _, line := range splitLines(diff) {
+		if current != nil && (oldLeft > 0 || newLeft > 0) {
+			if strings.HasPrefix(line, `\`) && len(current.lines) > 0 {
+				trimLastNewline(current)
+				continue
+			}
+			if line == "\n" {
+				// Some editors strip the space from empty context lines.
+				line = " \n"
+			}
+			switch line[0] {
+			case ' ':
+				oldLeft--
+				newLeft--
+			case '-':
+				oldLeft--
+			case '+':
+				newLeft--
+			default:
+				return nil, fmt.Errorf("invalid patch: hunk %d is shorter than its header", len(hunks))
+			}
+			if oldLeft < 0 || newLeft < 0 {
+				return nil, fmt.Errorf("invalid patch: hunk %d is longer than its header", len(hunks))
+			}
+			current.lines = append(current.lines, line)
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			hunks = append(hunks, h)
+			current = &hunks[len(hunks)-1]
+			oldLeft, newLeft = h.oldLines, h.newLines
+		case strings.HasPrefix(line, `\`) && current != nil && len(current.lines) > 0:
+			trimLastNewline(current)
+		case strings.HasPrefix(line, "--- "):
+			if headers++; headers > 1 {
+				return nil, fmt.Errorf("invalid patch: only one file may be changed")
+			}
+		case current == nil || strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "index "):
+			// Headers and anything before the first hunk.
+		default:
+			return nil, fmt.Errorf("invalid patch: unexpected line %q", strings.TrimSpace(line))
+		}
+	}
+
+	if current != nil && (oldLeft > 0 || newLeft > 0) {
+		return nil, fmt.Errorf("invalid patch: hunk %d is shorter than its header", len(hunks))
+	}
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("invalid patch: no hunks")
+	}
+	return hunks, nil
+}
+
+// trimLastNewline applies a "\ No newline at end of file" marker, which
+// belongs to the line before it.
+func trimLastNewline(h *hunk) {
+	last := &h.lines[len(h.lines)-1]
+	*last = strings.TrimSuffix(*last, "\n")
+}
+
+// parseHunkHeader reads "@@ -l,s +l,s @@", where either count may be left
+// out when it is 1.
+func parseHunkHeader(line string) (hunk, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[3] != "@@" || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return hunk{}, fmt.Errorf("invalid patch: bad hunk header %q", strings.TrimSpace(line))
+	}
+	var h hunk
+	var err error
+	if h.oldStart, h.oldLines, err = parseRange(fields[1][1:]); err != nil {
+		return hunk{}, fmt.Errorf("invalid patch: bad hunk header %q", strings.TrimSpace(line))
+	}
+	if h.newStart, h.newLines, err = parseRange(fields[2][1:]); err != nil {
+		return hunk{}, fmt.Errorf("invalid patch: bad hunk header %q", strings.TrimSpace(line))
+	}
+	return h, nil
+}
+
+func parseRange(s string) (start, count int, err error) {
+	startStr, countStr, found := strings.Cut(s, ",")
+	if start, err = strconv.Atoi(startStr); err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("bad range %q", s)
+	}
+	count = 1
+	if found {
+		if count, err = strconv.Atoi(countStr); err != nil || count < 0 {
+			return 0, 0, fmt.Errorf("bad range %q", s)
+		}
+	}
+	return start, count, nil
+}
+
+// splitLines splits content after each newline, so joining the lines
+// gives back content exactly.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,101 @@
+package patch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	base := "# Ideas\n\none\ntwo\nthree\nfour\nfive\n"
+	tests := []struct {
+		name  string
+		base  string
+		patch string
+		want  string
+	}{
+		{
+			name: "change with headers",
+			base: base,
+			patch: "diff --git a/ideas.md b/ideas.md\nindex 1..2 100644\n--- a/ideas.md\n+++ b/ideas.md\n" +
+				"@@ -3,3 +3,3 @@\n one\n-two\n+TWO\n three\n",
+			want: "# Ideas\n\none\nTWO\nthree\nfour\nfive\n",
+		},
+		{
+			name:  "several hunks",
+			base:  base,
+			patch: "@@ -1,2 +1,3 @@\n # Ideas\n+intro\n \n@@ -7 +8 @@\n-five\n+5\n",
+			want:  "# Ideas\nintro\n\none\ntwo\nthree\nfour\n5\n",
+		},
+		{
+			name:  "empty context line without its space",
+			base:  base,
+			patch: "@@ -1,3 +1,3 @@\n-# Ideas\n+# Notes\n\n one\n",
+			want:  "# Notes\n\none\ntwo\nthree\nfour\nfive\n",
+		},
+		{
+			name:  "insert into an empty file",
+			base:  "",
+			patch: "--- /dev/null\n+++ b/new.md\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+			want:  "a\nb\n",
+		},
+		{
+			name:  "insert after a line",
+			base:  "a\nc\n",
+			patch: "@@ -1,0 +2 @@\n+b\n",
+			want:  "a\nb\nc\n",
+		},
+		{
+			name:  "add a final newline",
+			base:  "a\nb",
+			patch: "@@ -2 +2 @@\n-b\n\\ No newline at end of file\n+b\n",
+			want:  "a\nb\n",
+		},
+		{
+			name:  "drop the final newline",
+			base:  "a\nb\n",
+			patch: "@@ -2 +2 @@\n-b\n+b\n\\ No newline at end of file\n",
+			want:  "a\nb",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.base), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Apply = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply_Mismatch(t *testing.T) {
+	base := []byte("one\ntwo\nthree\n")
+	for _, patch := range []string{
+		"@@ -2 +2 @@\n-zwei\n+2\n",
+		"@@ -3,2 +3,2 @@\n three\n-four\n+4\n",
+		"@@ -3 +3 @@\n-three\n+3\n@@ -1 +1 @@\n-one\n+1\n",
+	} {
+		if _, err := Apply(base, []byte(patch)); !errors.Is(err, ErrMismatch) {
+			t.Errorf("Apply(%q) error = %v, want ErrMismatch", patch, err)
+		}
+	}
+}
+
+func TestApply_Invalid(t *testing.T) {
+	for _, patch := range []string{
+		"",
+		"just some text\n",
+		"@@ -1 +1\n-a\n+b\n",
+		"@@ -1,2 +1,2 @@\n-a\n+b\n",
+		"@@ -1 +1 @@\n-a\n+b\n+c\n",
+		"--- a/x\n+++ b/x\n@@ -1 +1 @@\n-a\n+b\n--- a/y\n+++ b/y\n@@ -1 +1 @@\n-a\n+b\n",
+	} {
+		_, err := Apply([]byte("a\nb\n"), []byte(patch))
+		if err == nil || !strings.HasPrefix(err.Error(), "invalid patch") {
+			t.Errorf("Apply(%q) error = %v, want an invalid patch error", patch, err)
+		}
+	}
+}
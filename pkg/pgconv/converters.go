@@ -39,9 +39,11 @@ func PgToUUIDPtr(pg pgtype.UUID) *uuid.UUID {
 	return &u
 }
 
+// TimeToPg and the other time converters normalize to UTC, so API responses
+// always carry a Z offset whatever the server's or the session's zone.
 func TimeToPg(t time.Time) pgtype.Timestamptz {
 	return pgtype.Timestamptz{
-		Time:  t,
+		Time:  t.UTC(),
 		Valid: true,
 	}
 }
@@ -50,7 +52,7 @@ func PgToTime(pg pgtype.Timestamptz) time.Time {
 	if !pg.Valid {
 		return time.Time{}
 	}
-	return pg.Time
+	return pg.Time.UTC()
 }
 
 func TimePtrToPg(t *time.Time) pgtype.Timestamptz {
@@ -58,7 +60,7 @@ func TimePtrToPg(t *time.Time) pgtype.Timestamptz {
 		return pgtype.Timestamptz{Valid: false}
 	}
 	return pgtype.Timestamptz{
-		Time:  *t,
+		Time:  t.UTC(),
 		Valid: true,
 	}
 }
@@ -67,7 +69,24 @@ func PgToTimePtr(pg pgtype.Timestamptz) *time.Time {
 	if !pg.Valid {
 		return nil
 	}
-	return &pg.Time
+	t := pg.Time.UTC()
+	return &t
+}
+
+// DateToPg keeps the calendar day of t in t's own zone; dates have none.
+func DateToPg(t time.Time) pgtype.Date {
+	y, m, d := t.Date()
+	return pgtype.Date{
+		Time:  time.Date(y, m, d, 0, 0, 0, 0, time.UTC),
+		Valid: true,
+	}
+}
+
+func PgToDate(pg pgtype.Date) time.Time {
+	if !pg.Valid {
+		return time.Time{}
+	}
+	return pg.Time
 }
 
 func StringToPg(s string) pgtype.Text {
@@ -128,3 +147,13 @@ func PgToInt32(pg pgtype.Int4) int32 {
 	}
 	return pg.Int32
 }
+
+func Int32PtrToPg(i *int32) pgtype.Int4 {
+	if i == nil {
+		return pgtype.Int4{Valid: false}
+	}
+	return pgtype.Int4{
+		Int32: *i,
+		Valid: true,
+	}
+}
@@ -83,6 +83,21 @@ func TestTimeConversions(t *testing.T) {
 		assert.True(t, original.Equal(*converted))
 	})
 
+	t.Run("times are normalized to UTC", func(t *testing.T) {
+		zone := time.FixedZone("UTC+9", 9*60*60)
+		original := time.Date(2024, 3, 1, 8, 30, 0, 0, zone)
+
+		pg := TimeToPg(original)
+		assert.Equal(t, time.UTC, pg.Time.Location())
+
+		converted := PgToTime(pgtype.Timestamptz{Time: original, Valid: true})
+		assert.Equal(t, time.UTC, converted.Location())
+		assert.Equal(t, "2024-02-29T23:30:00Z", converted.Format(time.RFC3339))
+
+		ptr := PgToTimePtr(pgtype.Timestamptz{Time: original, Valid: true})
+		assert.Equal(t, time.UTC, ptr.Location())
+	})
+
 	t.Run("PgToTimePtr with invalid time", func(t *testing.T) {
 		pg := pgtype.Timestamptz{Valid: false}
 		converted := PgToTimePtr(pg)
@@ -91,6 +106,20 @@ func TestTimeConversions(t *testing.T) {
 	})
 }
 
+func TestDateConversions(t *testing.T) {
+	t.Run("DateToPg keeps the local calendar day", func(t *testing.T) {
+		tokyo := time.FixedZone("JST", 9*60*60)
+		pg := DateToPg(time.Date(2026, 3, 5, 1, 0, 0, 0, tokyo))
+
+		assert.True(t, pg.Valid)
+		assert.Equal(t, time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC), PgToDate(pg))
+	})
+
+	t.Run("PgToDate with invalid date", func(t *testing.T) {
+		assert.True(t, PgToDate(pgtype.Date{Valid: false}).IsZero())
+	})
+}
+
 func TestStringConversions(t *testing.T) {
 	t.Run("StringToPg and PgToString roundtrip", func(t *testing.T) {
 		original := "hello world"
@@ -0,0 +1,194 @@
+// Package policy evaluates data loss prevention rules against note
+// content before it leaves the workspace, for example through a public
+// share link.
+//
+// A rule names a detector and, for detectors that need one, a pattern.
+// Detectors are pluggable: the built-in ones are "credit_card", which
+// finds card numbers that pass the Luhn check, and "regex", which finds
+// matches of the rule's pattern. RegisterDetector adds more.
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// Actions a rule can take when it matches.
+const (
+	// ActionBlock refuses the action and records a violation.
+	ActionBlock = "block"
+	// ActionAudit allows the action but records a violation.
+	ActionAudit = "audit"
+)
+
+// Detector counts the sensitive items in content.
+type Detector interface {
+	Count(content []byte) int
+}
+
+// Factory builds a detector from a rule's pattern.
+type Factory func(pattern string) (Detector, error)
+
+var (
+	detectorsMu sync.RWMutex
+	detectors   = map[string]Factory{
+		"credit_card": func(string) (Detector, error) { return creditCardDetector{}, nil },
+		"regex":       newRegexDetector,
+	}
+)
+
+// RegisterDetector makes a detector available to rules under name,
+// replacing any detector registered under it before.
+func RegisterDetector(name string, factory Factory) {
+	detectorsMu.Lock()
+	defer detectorsMu.Unlock()
+	detectors[name] = factory
+}
+
+// Detectors lists the registered detector names, sorted.
+func Detectors() []string {
+	detectorsMu.RLock()
+	defer detectorsMu.RUnlock()
+	names := make([]string, 0, len(detectors))
+	for name := range detectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type Rule struct {
+	ID       string
+	Name     string
+	Detector string
+	Pattern  string
+	Action   string
+}
+
+// Match is a rule that matched, with the number of items it found.
+type Match struct {
+	Rule  Rule
+	Count int
+}
+
+// Engine evaluates a fixed set of rules.
+type Engine struct {
+	rules     []Rule
+	detectors []Detector
+}
+
+// Compile builds an engine, failing on the first invalid rule.
+func Compile(rules []Rule) (*Engine, error) {
+	engine := &Engine{}
+	for _, rule := range rules {
+		detector, err := build(rule)
+		if err != nil {
+			return nil, err
+		}
+		engine.rules = append(engine.rules, rule)
+		engine.detectors = append(engine.detectors, detector)
+	}
+	return engine, nil
+}
+
+// Validate reports whether rule could be compiled.
+func Validate(rule Rule) error {
+	_, err := build(rule)
+	return err
+}
+
+func build(rule Rule) (Detector, error) {
+	if rule.Action != ActionBlock && rule.Action != ActionAudit {
+		return nil, fmt.Errorf("invalid action %q: must be %s or %s", rule.Action, ActionBlock, ActionAudit)
+	}
+	detectorsMu.RLock()
+	factory, ok := detectors[rule.Detector]
+	detectorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("invalid detector %q", rule.Detector)
+	}
+	detector, err := factory(rule.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern for rule %q: %w", rule.Name, err)
+	}
+	return detector, nil
+}
+
+// Evaluate returns the rules that match content, in rule order.
+func (e *Engine) Evaluate(content []byte) []Match {
+	var matches []Match
+	for i, detector := range e.detectors {
+		if n := detector.Count(content); n > 0 {
+			matches = append(matches, Match{Rule: e.rules[i], Count: n})
+		}
+	}
+	return matches
+}
+
+// Blocked reports whether any match blocks the action.
+func Blocked(matches []Match) bool {
+	for _, m := range matches {
+		if m.Rule.Action == ActionBlock {
+			return true
+		}
+	}
+	return false
+}
+
+type regexDetector struct {
+	re *regexp.Regexp
+}
+
+func newRegexDetector(pattern string) (Detector, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return regexDetector{re: re}, nil
+}
+
+func (d regexDetector) Count(content []byte) int {
+	return len(d.re.FindAllIndex(content, -1))
+}
+
+// cardCandidateRe finds 13 to 19 digit numbers written as one run, in
+// groups of four separated by spaces or dashes, or in the 4-6-5 grouping
+// of American Express.
+var cardCandidateRe = regexp.MustCompile(`\b(?:\d{13,19}|\d{4}(?: \d{4}){2} \d{1,7}|\d{4}(?:-\d{4}){2}-\d{1,7}|\d{4}[ -]\d{6}[ -]\d{5})\b`)
+
+type creditCardDetector struct{}
+
+func (creditCardDetector) Count(content []byte) int {
+	n := 0
+	for _, candidate := range cardCandidateRe.FindAll(content, -1) {
+		if luhnValid(candidate) {
+			n++
+		}
+	}
+	return n
+}
+
+func luhnValid(number []byte) bool {
+	sum, digits, double := 0, 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+		double = !double
+	}
+	return digits >= 13 && sum%10 == 0
+}
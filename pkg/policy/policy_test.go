@@ -0,0 +1,61 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine(t *testing.T) {
+	engine, err := Compile([]Rule{
+		{Name: "cards", Detector: "credit_card", Action: ActionBlock},
+		{Name: "codename", Detector: "regex", Pattern: `(?i)project\s+bluebird`, Action: ActionAudit},
+	})
+	require.NoError(t, err)
+
+	t.Run("card numbers must pass the Luhn check", func(t *testing.T) {
+		matches := engine.Evaluate([]byte("visa 4111 1111 1111 1111, typo 4111 1111 1111 1112"))
+		require.Len(t, matches, 1)
+		assert.Equal(t, "cards", matches[0].Rule.Name)
+		assert.Equal(t, 1, matches[0].Count)
+		assert.True(t, Blocked(matches))
+	})
+
+	t.Run("adjacent cards are counted separately", func(t *testing.T) {
+		matches := engine.Evaluate([]byte("4111 1111 1111 1111 5500-0000-0000-0004 378282246310005"))
+		require.Len(t, matches, 1)
+		assert.Equal(t, 3, matches[0].Count)
+	})
+
+	t.Run("longer numbers are not cards", func(t *testing.T) {
+		assert.Empty(t, engine.Evaluate([]byte("order 41111111111111110000")))
+	})
+
+	t.Run("audit rules do not block", func(t *testing.T) {
+		matches := engine.Evaluate([]byte("Notes on Project Bluebird"))
+		require.Len(t, matches, 1)
+		assert.False(t, Blocked(matches))
+	})
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(Rule{Name: "ok", Detector: "regex", Pattern: "secret", Action: ActionBlock}))
+	assert.Error(t, Validate(Rule{Name: "bad regex", Detector: "regex", Pattern: "(", Action: ActionBlock}))
+	assert.Error(t, Validate(Rule{Name: "no pattern", Detector: "regex", Action: ActionBlock}))
+	assert.Error(t, Validate(Rule{Name: "unknown", Detector: "ssn", Action: ActionBlock}))
+	assert.Error(t, Validate(Rule{Name: "bad action", Detector: "credit_card", Action: "warn"}))
+}
+
+func TestRegisterDetector(t *testing.T) {
+	RegisterDetector("always", func(string) (Detector, error) { return constDetector(2), nil })
+	assert.Contains(t, Detectors(), "always")
+
+	engine, err := Compile([]Rule{{Name: "always", Detector: "always", Action: ActionAudit}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, engine.Evaluate(nil)[0].Count)
+}
+
+type constDetector int
+
+func (d constDetector) Count([]byte) int { return int(d) }
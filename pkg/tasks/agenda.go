@@ -0,0 +1,138 @@
+package tasks
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Kinds of agenda entries.
+const (
+	KindScheduled = "scheduled"
+	KindDeadline  = "deadline"
+	KindTimestamp = "timestamp"
+)
+
+// Entry is a dated item for an agenda. Date is the calendar day, at
+// midnight UTC; Time is "15:04" or empty for all-day items. Timestamps in
+// notes carry no zone, so neither does an entry. Line is 1-based.
+type Entry struct {
+	Kind    string
+	Title   string
+	Keyword string
+	Done    bool
+	Date    time.Time
+	Time    string
+	Line    int
+}
+
+var (
+	orgAnyHeadlineRe = regexp.MustCompile(`^\*+\s+(.*?)\s*(:[\w@#%:]+:)?\s*$`)
+	orgTimestampRe   = regexp.MustCompile(`<(\d{4}-\d{2}-\d{2})(?:\s+[^\s>\d]+)?(?:\s+(\d{1,2}:\d{2}))?[^>]*>`)
+	orgPlanningRe    = regexp.MustCompile(`\b(SCHEDULED|DEADLINE|CLOSED):\s*([<\[][^>\]]*[>\]])`)
+)
+
+// ParseOrgAgenda returns the SCHEDULED and DEADLINE planning entries of an
+// Org document and its other active timestamps, <2026-01-31 Sat 10:00>,
+// each titled by its headline. Inactive [timestamps], CLOSED lines and
+// source blocks are ignored; repeaters are not expanded.
+func ParseOrgAgenda(content []byte) []Entry {
+	var found []Entry
+	var title, keyword string
+	var done bool
+	inBlock := false
+
+	lines := splitLines(content)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		lower := strings.ToLower(trimmed)
+		switch {
+		case strings.HasPrefix(lower, "#+begin_src"), strings.HasPrefix(lower, "#+begin_example"):
+			inBlock = true
+			continue
+		case strings.HasPrefix(lower, "#+end_src"), strings.HasPrefix(lower, "#+end_example"):
+			inBlock = false
+			continue
+		case inBlock:
+			continue
+		}
+
+		if m := orgAnyHeadlineRe.FindStringSubmatch(line); m != nil {
+			title, keyword, done = m[1], "", false
+			if word, rest, ok := strings.Cut(title, " "); ok && (orgOpenWords[word] || orgClosedWords[word]) {
+				title, keyword, done = rest, word, orgClosedWords[word]
+			}
+			title = strings.TrimSpace(orgPriorityRe.ReplaceAllString(title, ""))
+			if stripped := strings.Join(strings.Fields(orgTimestampRe.ReplaceAllString(title, "")), " "); stripped != "" {
+				title = stripped
+			}
+		}
+
+		entryTitle := title
+		if entryTitle == "" {
+			// Before the first headline the line itself says what it is.
+			entryTitle = strings.Join(strings.Fields(orgTimestampRe.ReplaceAllString(trimmed, "")), " ")
+		}
+		add := func(kind, timestamp string) {
+			m := orgTimestampRe.FindStringSubmatch(timestamp)
+			if m == nil {
+				return
+			}
+			date, err := time.Parse(time.DateOnly, m[1])
+			if err != nil {
+				return
+			}
+			found = append(found, Entry{
+				Kind:    kind,
+				Title:   entryTitle,
+				Keyword: keyword,
+				Done:    done,
+				Date:    date,
+				Time:    normalizeClock(m[2]),
+				Line:    i + 1,
+			})
+		}
+
+		if planning := orgPlanningRe.FindAllStringSubmatch(line, -1); planning != nil {
+			for _, p := range planning {
+				switch p[1] {
+				case "SCHEDULED":
+					add(KindScheduled, p[2])
+				case "DEADLINE":
+					add(KindDeadline, p[2])
+				}
+			}
+			continue
+		}
+		for _, timestamp := range orgTimestampRe.FindAllString(line, -1) {
+			add(KindTimestamp, timestamp)
+		}
+	}
+	return found
+}
+
+// ParseMarkdownAgenda returns the deadlines of a Markdown document's
+// checklist items, as ParseMarkdown finds them.
+func ParseMarkdownAgenda(content []byte) []Entry {
+	var found []Entry
+	for _, task := range ParseMarkdown(content, time.UTC) {
+		if task.Deadline == nil {
+			continue
+		}
+		found = append(found, Entry{
+			Kind:  KindDeadline,
+			Title: task.Title,
+			Done:  task.Done,
+			Date:  *task.Deadline,
+			Line:  task.Line,
+		})
+	}
+	return found
+}
+
+func normalizeClock(clock string) string {
+	if len(clock) == len("9:00") {
+		return "0" + clock
+	}
+	return clock
+}
@@ -0,0 +1,40 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOrgAgenda(t *testing.T) {
+	src := `Sprint review <2026-10-16 Fri>
+* TODO [#A] Write report :work:
+  SCHEDULED: <2026-10-18 Sun 9:00> DEADLINE: <2026-10-20 Tue 17:30>
+* Dentist <2026-10-19 Mon 14:00>
+  Bring the forms, and [2026-10-01 Thu] was the last visit.
+* DONE Call the bank
+  CLOSED: [2026-10-15 Thu 11:02] DEADLINE: <2026-10-15 Thu>
+#+begin_src org
+<2026-10-30 Fri>
+#+end_src
+`
+	found := ParseOrgAgenda([]byte(src))
+	require.Len(t, found, 5)
+
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC) }
+	assert.Equal(t, Entry{Kind: KindTimestamp, Title: "Sprint review", Date: day(16), Line: 1}, found[0])
+	assert.Equal(t, Entry{Kind: KindScheduled, Title: "Write report", Keyword: "TODO", Date: day(18), Time: "09:00", Line: 3}, found[1])
+	assert.Equal(t, Entry{Kind: KindDeadline, Title: "Write report", Keyword: "TODO", Date: day(20), Time: "17:30", Line: 3}, found[2])
+	assert.Equal(t, Entry{Kind: KindTimestamp, Title: "Dentist", Date: day(19), Time: "14:00", Line: 4}, found[3])
+	assert.Equal(t, Entry{Kind: KindDeadline, Title: "Call the bank", Keyword: "DONE", Done: true, Date: day(15), Line: 7}, found[4])
+}
+
+func TestParseMarkdownAgenda(t *testing.T) {
+	src := "- [ ] Buy milk 📅 2026-10-17\n- [x] Pay rent due:2026-10-01\n- [ ] Someday\n"
+	found := ParseMarkdownAgenda([]byte(src))
+	require.Len(t, found, 2)
+	assert.Equal(t, Entry{Kind: KindDeadline, Title: "Buy milk", Date: time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC), Line: 1}, found[0])
+	assert.True(t, found[1].Done)
+}
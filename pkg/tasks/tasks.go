@@ -0,0 +1,134 @@
+// Package tasks extracts tasks from notes: Org headlines with a TODO
+// keyword and Markdown checklist items. Deadlines come from Org DEADLINE
+// planning lines and from Markdown "📅 2026-01-31", "due:2026-01-31" or
+// "@due(2026-01-31)" markers.
+//
+// Tasks have no identity in the source text, so each is keyed by its
+// lower-cased title; repeated titles in one file get "#2", "#3" and so on.
+// Renaming a task therefore looks like removing one and adding another.
+//
+// ParseOrgAgenda and ParseMarkdownAgenda find the dated entries an agenda
+// shows: scheduled items, deadlines and Org's plain timestamps.
+package tasks
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Task is one task found in a file. Line is 1-based.
+type Task struct {
+	Key      string
+	Title    string
+	Done     bool
+	Deadline *time.Time
+	Line     int
+}
+
+var (
+	orgHeadlineRe  = regexp.MustCompile(`^\*+\s+([A-Z]+)\s+(.*?)\s*(:[\w@#%:]+:)?\s*$`)
+	orgDeadlineRe  = regexp.MustCompile(`DEADLINE:\s*<(\d{4}-\d{2}-\d{2})(?:\s+[^\s>\d]+)?(?:\s+(\d{1,2}:\d{2}))?[^>]*>`)
+	orgPriorityRe  = regexp.MustCompile(`^\[#[A-Z]\]\s*`)
+	mdCheckboxRe   = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+\[([ xX])\]\s+(.*)$`)
+	mdDueRe        = regexp.MustCompile(`(?:📅\s*|\bdue:|@due\()(\d{4}-\d{2}-\d{2})\)?`)
+	orgOpenWords   = map[string]bool{"TODO": true, "NEXT": true, "WAITING": true, "HOLD": true}
+	orgClosedWords = map[string]bool{"DONE": true, "CANCELLED": true, "CANCELED": true}
+)
+
+// ParseOrg returns the tasks of an Org document. Dates without a time are
+// read as midnight in loc.
+func ParseOrg(content []byte, loc *time.Location) []Task {
+	var found []Task
+	lines := splitLines(content)
+	for i, line := range lines {
+		m := orgHeadlineRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		keyword := m[1]
+		if !orgOpenWords[keyword] && !orgClosedWords[keyword] {
+			continue
+		}
+		title := strings.TrimSpace(orgPriorityRe.ReplaceAllString(m[2], ""))
+		if title == "" {
+			continue
+		}
+
+		task := Task{Title: title, Done: orgClosedWords[keyword], Line: i + 1}
+		// Planning lines directly follow their headline.
+		if i+1 < len(lines) && !strings.HasPrefix(lines[i+1], "*") {
+			if d := orgDeadlineRe.FindStringSubmatch(lines[i+1]); d != nil {
+				task.Deadline = parseDate(d[1], d[2], loc)
+			}
+		}
+		found = append(found, task)
+	}
+	return assignKeys(found)
+}
+
+// ParseMarkdown returns the checklist items of a Markdown document. Items
+// inside fenced code blocks are ignored.
+func ParseMarkdown(content []byte, loc *time.Location) []Task {
+	var found []Task
+	inFence := false
+	for i, line := range splitLines(content) {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		m := mdCheckboxRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		task := Task{Done: m[1] != " ", Line: i + 1}
+		title := m[2]
+		if d := mdDueRe.FindStringSubmatch(title); d != nil {
+			task.Deadline = parseDate(d[1], "", loc)
+			title = mdDueRe.ReplaceAllString(title, "")
+		}
+		task.Title = strings.Join(strings.Fields(title), " ")
+		if task.Title == "" {
+			continue
+		}
+		found = append(found, task)
+	}
+	return assignKeys(found)
+}
+
+func splitLines(content []byte) []string {
+	return strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+}
+
+func parseDate(date, clock string, loc *time.Location) *time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	layout, value := time.DateOnly, date
+	if clock != "" {
+		layout, value = time.DateOnly+" 15:04", date+" "+clock
+	}
+	t, err := time.ParseInLocation(layout, value, loc)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+func assignKeys(found []Task) []Task {
+	seen := make(map[string]int, len(found))
+	for i := range found {
+		key := strings.ToLower(found[i].Title)
+		seen[key]++
+		if n := seen[key]; n > 1 {
+			key += "#" + strconv.Itoa(n)
+		}
+		found[i].Key = key
+	}
+	return found
+}
@@ -0,0 +1,61 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOrg(t *testing.T) {
+	src := `#+TITLE: Week
+* TODO [#A] Write report :work:
+  DEADLINE: <2026-10-20 Tue 17:30>
+* Meeting notes
+** DONE Call the bank
+** TODO Write report
+* WAITING Reply from landlord
+  SCHEDULED: <2026-10-18 Sun> DEADLINE: <2026-10-21 Wed>
+`
+	found := ParseOrg([]byte(src), time.UTC)
+	require.Len(t, found, 4)
+
+	assert.Equal(t, Task{
+		Key:      "write report",
+		Title:    "Write report",
+		Deadline: ptr(time.Date(2026, 10, 20, 17, 30, 0, 0, time.UTC)),
+		Line:     2,
+	}, found[0])
+	assert.Equal(t, "call the bank", found[1].Key)
+	assert.True(t, found[1].Done)
+	assert.Equal(t, "write report#2", found[2].Key, "repeated titles are numbered")
+	assert.Nil(t, found[2].Deadline)
+	assert.Equal(t, time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC), *found[3].Deadline)
+}
+
+func TestParseMarkdown(t *testing.T) {
+	src := "# Groceries\n" +
+		"- [ ] Buy milk 📅 2026-10-17\n" +
+		"- [x] Buy bread\n" +
+		"* [ ] Return books due:2026-10-19\n" +
+		"1. [X] Pay rent\n" +
+		"```\n- [ ] not a task\n```\n" +
+		"- [] not a checkbox\n"
+
+	loc := time.FixedZone("KST", 9*60*60)
+	found := ParseMarkdown([]byte(src), loc)
+	require.Len(t, found, 4)
+
+	assert.Equal(t, "Buy milk", found[0].Title)
+	assert.Equal(t, time.Date(2026, 10, 17, 0, 0, 0, 0, loc), *found[0].Deadline)
+	assert.True(t, found[1].Done)
+	assert.Equal(t, "Return books", found[2].Title)
+	assert.NotNil(t, found[2].Deadline)
+	assert.Equal(t, "pay rent", found[3].Key)
+	assert.True(t, found[3].Done)
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,114 @@
+// Package thumbnail scales PNG, JPEG and GIF images down to thumbnails.
+// Only the standard library's decoders are used, so other formats, PDF
+// among them, are reported as unsupported.
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// MaxPixels caps the size of images that are decoded, so a small file
+// that claims huge dimensions cannot exhaust memory.
+const MaxPixels = 40_000_000
+
+// ErrUnsupported is returned for content that is not a supported image.
+var ErrUnsupported = errors.New("unsupported image format")
+
+// Thumbnail is a generated thumbnail and its MIME type.
+type Thumbnail struct {
+	Content  []byte
+	MimeType string
+}
+
+// Generate scales the image in content so that its longer side is at most
+// size pixels, keeping its aspect ratio; smaller images keep their size.
+// Opaque images are encoded as JPEG and the rest as PNG. Animated GIFs
+// use their first frame.
+func Generate(content []byte, size int) (*Thumbnail, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("thumbnail size must be positive")
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, fmt.Errorf("image too large: %dx%d", config.Width, config.Height)
+	}
+
+	var src image.Image
+	switch format {
+	case "png":
+		src, err = png.Decode(bytes.NewReader(content))
+	case "jpeg":
+		src, err = jpeg.Decode(bytes.NewReader(content))
+	case "gif":
+		src, err = gif.Decode(bytes.NewReader(content))
+	default:
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", format, err)
+	}
+
+	dst := scale(src, size)
+	var out bytes.Buffer
+	if opaque(src) {
+		if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: 80}); err != nil {
+			return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+		}
+		return &Thumbnail{Content: out.Bytes(), MimeType: "image/jpeg"}, nil
+	}
+	if err := png.Encode(&out, dst); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return &Thumbnail{Content: out.Bytes(), MimeType: "image/png"}, nil
+}
+
+// scale shrinks src to fit in a size by size square by averaging the
+// source pixels under each destination pixel.
+func scale(src image.Image, size int) *image.NRGBA {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if w > size || h > size {
+		if w >= h {
+			dw, dh = size, max(1, h*size/w)
+		} else {
+			dw, dh = max(1, w*size/h), size
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		y0, y1 := bounds.Min.Y+y*h/dh, bounds.Min.Y+max((y+1)*h/dh, y*h/dh+1)
+		for x := range dw {
+			x0, x1 := bounds.Min.X+x*w/dw, bounds.Min.X+max((x+1)*w/dw, x*w/dw+1)
+			// Colors are summed premultiplied so transparent pixels do not
+			// darken their neighbours.
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestGenerate(t *testing.T) {
+	t.Run("opaque image becomes a JPEG that fits the size", func(t *testing.T) {
+		src := image.NewRGBA(image.Rect(0, 0, 400, 100))
+		for i := range src.Pix {
+			src.Pix[i] = 0xff
+		}
+		thumb, err := Generate(encodePNG(t, src), 64)
+		require.NoError(t, err)
+		assert.Equal(t, "image/jpeg", thumb.MimeType)
+
+		config, format, err := image.DecodeConfig(bytes.NewReader(thumb.Content))
+		require.NoError(t, err)
+		assert.Equal(t, "jpeg", format)
+		assert.Equal(t, 64, config.Width)
+		assert.Equal(t, 16, config.Height)
+	})
+
+	t.Run("transparency is kept", func(t *testing.T) {
+		src := image.NewNRGBA(image.Rect(0, 0, 10, 20))
+		src.Set(0, 0, color.NRGBA{R: 255, A: 128})
+		thumb, err := Generate(encodePNG(t, src), 256)
+		require.NoError(t, err)
+		assert.Equal(t, "image/png", thumb.MimeType)
+
+		img, err := png.Decode(bytes.NewReader(thumb.Content))
+		require.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 10, 20), img.Bounds(), "small images are not enlarged")
+		_, _, _, a := img.At(0, 0).RGBA()
+		assert.NotZero(t, a)
+	})
+
+	t.Run("other content is unsupported", func(t *testing.T) {
+		_, err := Generate([]byte("%PDF-1.7\n"), 256)
+		assert.ErrorIs(t, err, ErrUnsupported)
+	})
+}
@@ -0,0 +1,191 @@
+// Package validate checks struct fields against rules in their validate
+// tags, such as `validate:"required,max=255"`. Fields are named by their
+// json tags, so errors speak the names clients send.
+//
+// The rules are:
+//
+//	required    the field is not its zero value
+//	min=N       strings have at least N characters, slices and maps N
+//	            elements, numbers a value of at least N
+//	max=N       the same, at most N
+//	oneof=a b   the value is one of those listed
+//	email       the string is an email address
+//
+// Rules other than required skip zero values and nil pointers, so optional
+// fields are checked only when set; a pointer to a zero value is set.
+// Nested structs are checked too, with their fields named parent.child.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError is one field that broke a rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// Errors are the fields of a struct that broke their rules, in field
+// order.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Error()
+	}
+	return "invalid request: " + strings.Join(messages, "; ")
+}
+
+// Struct checks the fields of v, a struct or a pointer to one. It returns
+// Errors when fields break their rules and nil otherwise. A malformed tag
+// is a programming error and panics.
+func Struct(v any) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs Errors
+	checkStruct(value, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func checkStruct(value reflect.Value, prefix string, errs *Errors) {
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := prefix + fieldName(field)
+		fv := value.Field(i)
+		if tag, ok := field.Tag.Lookup("validate"); ok {
+			if fe, broken := checkField(fv, tag, name); broken {
+				*errs = append(*errs, fe)
+				continue
+			}
+		}
+
+		nested := fv
+		if nested.Kind() == reflect.Pointer && !nested.IsNil() {
+			nested = nested.Elem()
+		}
+		if nested.Kind() == reflect.Struct {
+			checkStruct(nested, name+".", errs)
+		}
+	}
+}
+
+// fieldName is the json name of field, or its Go name without one.
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// checkField applies the rules of tag to value and returns the first one
+// it breaks.
+func checkField(value reflect.Value, tag, name string) (FieldError, bool) {
+	for _, rule := range strings.Split(tag, ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if rule == "" {
+			continue
+		}
+		if rule == "required" {
+			if value.IsZero() {
+				return FieldError{Field: name, Rule: rule, Message: "is required"}, true
+			}
+			continue
+		}
+
+		v := value
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				continue
+			}
+			v = v.Elem()
+		} else if v.IsZero() {
+			continue
+		}
+		if message, ok := checkRule(v, rule, param, name); !ok {
+			return FieldError{Field: name, Rule: rule, Message: message}, true
+		}
+	}
+	return FieldError{}, false
+}
+
+func checkRule(v reflect.Value, rule, param, name string) (string, bool) {
+	switch rule {
+	case "min", "max":
+		bound, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validate: %s of %s is not a number: %q", rule, name, param))
+		}
+		size, unit := measure(v, name)
+		if rule == "min" && size < bound {
+			return fmt.Sprintf("must be at least %s%s", param, unit), false
+		}
+		if rule == "max" && size > bound {
+			return fmt.Sprintf("must be at most %s%s", param, unit), false
+		}
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(param) {
+			if s == option {
+				return "", true
+			}
+		}
+		return "must be one of " + strings.Join(strings.Fields(param), ", "), false
+	case "email":
+		if v.Kind() != reflect.String {
+			panic(fmt.Sprintf("validate: email on %s, which is not a string", name))
+		}
+		if _, err := mail.ParseAddress(v.String()); err != nil {
+			return "must be an email address", false
+		}
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q on %s", rule, name))
+	}
+	return "", true
+}
+
+// measure returns what min and max compare for v, and the unit to name in
+// messages.
+func measure(v reflect.Value, name string) (float64, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), " elements"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	default:
+		panic(fmt.Sprintf("validate: min or max on %s, which has no size", name))
+	}
+}
@@ -0,0 +1,110 @@
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type request struct {
+	Name     string   `json:"name" validate:"required,min=2,max=5"`
+	Email    string   `json:"email,omitempty" validate:"email"`
+	Role     string   `json:"role" validate:"oneof=viewer editor"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	Count    *int     `json:"count,omitempty" validate:"min=1"`
+	Address  *address `json:"address,omitempty"`
+	NoJSON   string   `validate:"required"`
+	Ignored  string   `json:"ignored"`
+	internal string   `validate:"required"`
+}
+
+func TestStruct(t *testing.T) {
+	zero, three := 0, 3
+	valid := request{Name: "Ada", Email: "ada@example.com", Role: "editor", Count: &three, NoJSON: "x"}
+
+	tests := []struct {
+		name string
+		edit func(r *request)
+		want Errors
+	}{
+		{"valid", func(r *request) {}, nil},
+		{"optional fields unset", func(r *request) { r.Email, r.Role, r.Count = "", "", nil }, nil},
+		{"required", func(r *request) { r.Name, r.NoJSON = "", "" }, Errors{
+			{Field: "name", Rule: "required", Message: "is required"},
+			{Field: "NoJSON", Rule: "required", Message: "is required"},
+		}},
+		{"min characters", func(r *request) { r.Name = "é" }, Errors{
+			{Field: "name", Rule: "min", Message: "must be at least 2 characters"},
+		}},
+		{"max characters", func(r *request) { r.Name = "Adelaide" }, Errors{
+			{Field: "name", Rule: "max", Message: "must be at most 5 characters"},
+		}},
+		{"email", func(r *request) { r.Email = "nope" }, Errors{
+			{Field: "email", Rule: "email", Message: "must be an email address"},
+		}},
+		{"oneof", func(r *request) { r.Role = "owner" }, Errors{
+			{Field: "role", Rule: "oneof", Message: "must be one of viewer, editor"},
+		}},
+		{"max elements", func(r *request) { r.Tags = []string{"a", "b", "c"} }, Errors{
+			{Field: "tags", Rule: "max", Message: "must be at most 2 elements"},
+		}},
+		{"pointer to zero", func(r *request) { r.Count = &zero }, Errors{
+			{Field: "count", Rule: "min", Message: "must be at least 1"},
+		}},
+		{"nested", func(r *request) { r.Address = &address{} }, Errors{
+			{Field: "address.city", Rule: "required", Message: "is required"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid
+			tt.edit(&r)
+			err := Struct(&r)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("got %v, want no error", err)
+				}
+				return
+			}
+			var errs Errors
+			if !errors.As(err, &errs) || !reflect.DeepEqual(errs, tt.want) {
+				t.Errorf("got %#v, want %#v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStruct_NotAStruct(t *testing.T) {
+	var nilRequest *request
+	for _, v := range []any{nil, nilRequest, "text", map[string]int{}} {
+		if err := Struct(v); err != nil {
+			t.Errorf("Struct(%#v) = %v, want nil", v, err)
+		}
+	}
+}
+
+func TestErrors_Error(t *testing.T) {
+	err := Errors{
+		{Field: "name", Rule: "required", Message: "is required"},
+		{Field: "email", Rule: "email", Message: "must be an email address"},
+	}
+	want := "invalid request: name is required; email must be an email address"
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+}
+
+func TestStruct_UnknownRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	Struct(struct {
+		Name string `validate:"uppercase"`
+	}{Name: "x"})
+}
@@ -0,0 +1,134 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errTruncated = errors.New("cbor: unexpected end of data")
+
+// maxDepth bounds nesting, so hostile input cannot exhaust the stack.
+const maxDepth = 16
+
+// decodeCBOR decodes the first CBOR item in data and returns it with the
+// bytes that follow. It covers what authenticators send: integers become
+// int64, byte and text strings []byte and string, arrays []any and maps
+// map[any]any keyed by int64 or string. Floats and indefinite lengths are
+// refused, as WebAuthn structures use neither.
+func decodeCBOR(data []byte) (any, []byte, error) {
+	return decodeItem(data, 0)
+}
+
+func decodeItem(data []byte, depth int) (any, []byte, error) {
+	if depth > maxDepth {
+		return nil, nil, fmt.Errorf("cbor: nested too deeply")
+	}
+	if len(data) == 0 {
+		return nil, nil, errTruncated
+	}
+	major, info := data[0]>>5, data[0]&0x1f
+	if major == 7 {
+		return decodeSimple(data)
+	}
+	arg, rest, err := readArgument(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("cbor: integer overflows int64")
+		}
+		return int64(arg), rest, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("cbor: integer overflows int64")
+		}
+		return -1 - int64(arg), rest, nil
+	case 2, 3:
+		if arg > uint64(len(rest)) {
+			return nil, nil, errTruncated
+		}
+		if major == 2 {
+			return append([]byte(nil), rest[:arg]...), rest[arg:], nil
+		}
+		return string(rest[:arg]), rest[arg:], nil
+	case 4:
+		// Each item takes at least a byte, which bounds the allocation.
+		if arg > uint64(len(rest)) {
+			return nil, nil, errTruncated
+		}
+		items := make([]any, arg)
+		for i := range items {
+			if items[i], rest, err = decodeItem(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		return items, rest, nil
+	case 5:
+		if arg > uint64(len(rest))/2 {
+			return nil, nil, errTruncated
+		}
+		items := make(map[any]any, arg)
+		for range arg {
+			var key, value any
+			if key, rest, err = decodeItem(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("cbor: unsupported map key %T", key)
+			}
+			if value, rest, err = decodeItem(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items[key] = value
+		}
+		return items, rest, nil
+	case 6:
+		// Tags only annotate the item that follows.
+		return decodeItem(rest, depth+1)
+	}
+	return nil, nil, fmt.Errorf("cbor: unsupported item 0x%02x", major<<5|info)
+}
+
+// readArgument reads the length or value that follows an initial byte.
+func readArgument(data []byte) (uint64, []byte, error) {
+	info := data[0] & 0x1f
+	data = data[1:]
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24 && len(data) >= 1:
+		return uint64(data[0]), data[1:], nil
+	case info == 25 && len(data) >= 2:
+		return uint64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case info == 26 && len(data) >= 4:
+		return uint64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case info == 27 && len(data) >= 8:
+		return binary.BigEndian.Uint64(data), data[8:], nil
+	case info == 31:
+		return 0, nil, fmt.Errorf("cbor: indefinite lengths are not supported")
+	case info > 27:
+		return 0, nil, fmt.Errorf("cbor: invalid additional information %d", info)
+	}
+	return 0, nil, errTruncated
+}
+
+func decodeSimple(data []byte) (any, []byte, error) {
+	info := data[0] & 0x1f
+	data = data[1:]
+	switch info {
+	case 20:
+		return false, data, nil
+	case 21:
+		return true, data, nil
+	case 22, 23:
+		return nil, data, nil
+	}
+	return nil, nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+}
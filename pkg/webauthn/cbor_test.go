@@ -0,0 +1,28 @@
+package webauthn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	// -257, then a trailing byte.
+	item, rest, err := decodeCBOR([]byte{0x39, 0x01, 0x00, 0xff})
+	require.NoError(t, err)
+	assert.Equal(t, int64(-257), item)
+	assert.Equal(t, []byte{0xff}, rest)
+
+	// {1: h'00', "a": [true, null]}
+	item, _, err = decodeCBOR([]byte{0xa2, 0x01, 0x41, 0x00, 0x61, 'a', 0x82, 0xf5, 0xf6})
+	require.NoError(t, err)
+	assert.Equal(t, map[any]any{int64(1): []byte{0}, "a": []any{true, nil}}, item)
+
+	_, _, err = decodeCBOR([]byte{0x5f, 0x41, 0x00, 0xff})
+	assert.ErrorContains(t, err, "indefinite")
+
+	// An array claiming 1000 items.
+	_, _, err = decodeCBOR([]byte{0x99, 0x03, 0xe8})
+	assert.ErrorIs(t, err, errTruncated)
+}
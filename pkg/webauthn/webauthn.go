@@ -0,0 +1,216 @@
+// Package webauthn checks passkey registrations and sign-ins for a relying
+// party, as described by the W3C Web Authentication spec. It verifies
+// what the browser and authenticator sign, but not attestation
+// statements: any authenticator the user picks is accepted, as passkey
+// providers mostly send none.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+var (
+	// ErrSignCount means an authenticator reported a signature counter no
+	// higher than the last one seen, which happens when a credential has
+	// been cloned.
+	ErrSignCount = errors.New("webauthn: signature counter did not increase")
+)
+
+// Authenticator data flags.
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// RelyingParty is the site credentials are bound to.
+type RelyingParty struct {
+	// ID is the domain credentials are scoped to.
+	ID   string
+	Name string
+	// Origin is the only origin ceremonies may come from.
+	Origin string
+}
+
+// NewRelyingParty returns the relying party of a site served at baseURL.
+func NewRelyingParty(baseURL, name string) (*RelyingParty, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", baseURL)
+	}
+	return &RelyingParty{
+		ID:     u.Hostname(),
+		Name:   name,
+		Origin: u.Scheme + "://" + u.Host,
+	}, nil
+}
+
+// Credential is a registered passkey.
+type Credential struct {
+	ID []byte
+	// PublicKey is the credential's COSE key, as the authenticator sent it.
+	PublicKey []byte
+	SignCount uint32
+}
+
+// Attestation is the response of navigator.credentials.create.
+type Attestation struct {
+	ClientDataJSON    []byte
+	AttestationObject []byte
+}
+
+// Assertion is the response of navigator.credentials.get.
+type Assertion struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// NewChallenge returns a random challenge, encoded as browsers echo it
+// back in client data.
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ChallengeOf returns the challenge a response answers, so the ceremony
+// it belongs to can be looked up before the response is verified.
+func ChallengeOf(clientDataJSON []byte) (string, error) {
+	var cd clientData
+	if err := json.Unmarshal(clientDataJSON, &cd); err != nil || cd.Challenge == "" {
+		return "", fmt.Errorf("invalid client data")
+	}
+	return cd.Challenge, nil
+}
+
+func (rp *RelyingParty) checkClientData(clientDataJSON []byte, ceremony, challenge string) error {
+	var cd clientData
+	if err := json.Unmarshal(clientDataJSON, &cd); err != nil {
+		return fmt.Errorf("invalid client data")
+	}
+	switch {
+	case cd.Type != ceremony:
+		return fmt.Errorf("invalid client data: type %q, want %q", cd.Type, ceremony)
+	case cd.Challenge != challenge:
+		return fmt.Errorf("invalid client data: challenge mismatch")
+	case cd.Origin != rp.Origin:
+		return fmt.Errorf("invalid client data: origin %q not allowed", cd.Origin)
+	}
+	return nil
+}
+
+// checkAuthenticatorData checks the fixed part of authenticator data and
+// returns its flags and signature counter.
+func (rp *RelyingParty) checkAuthenticatorData(authData []byte) (byte, uint32, error) {
+	if len(authData) < 37 {
+		return 0, 0, fmt.Errorf("invalid authenticator data: too short")
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData[:32], rpIDHash[:]) {
+		return 0, 0, fmt.Errorf("invalid authenticator data: wrong relying party")
+	}
+	flags := authData[32]
+	if flags&flagUserPresent == 0 || flags&flagUserVerified == 0 {
+		return 0, 0, fmt.Errorf("invalid authenticator data: user not verified")
+	}
+	return flags, binary.BigEndian.Uint32(authData[33:37]), nil
+}
+
+// VerifyRegistration checks a response to a creation ceremony started
+// with challenge and returns the new credential.
+func (rp *RelyingParty) VerifyRegistration(challenge string, a Attestation) (*Credential, error) {
+	if err := rp.checkClientData(a.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	item, _, err := decodeCBOR(a.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attestation object: %w", err)
+	}
+	obj, _ := item.(map[any]any)
+	authData, _ := obj["authData"].([]byte)
+	flags, signCount, err := rp.checkAuthenticatorData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if flags&flagAttestedData == 0 {
+		return nil, fmt.Errorf("invalid authenticator data: no credential")
+	}
+
+	// Attested credential data: a 16-byte AAGUID, the credential ID with
+	// its length, then the COSE key, whose length only decoding reveals.
+	data := authData[37:]
+	if len(data) < 18 {
+		return nil, fmt.Errorf("invalid authenticator data: truncated credential")
+	}
+	idLen := int(binary.BigEndian.Uint16(data[16:18]))
+	data = data[18:]
+	if idLen == 0 || idLen > 1023 || len(data) < idLen {
+		return nil, fmt.Errorf("invalid authenticator data: bad credential ID")
+	}
+	id := data[:idLen]
+	_, rest, err := decodeCBOR(data[idLen:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid authenticator data: %w", err)
+	}
+	cose := data[idLen : len(data)-len(rest)]
+	if _, err := parsePublicKey(cose); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:        append([]byte(nil), id...),
+		PublicKey: append([]byte(nil), cose...),
+		SignCount: signCount,
+	}, nil
+}
+
+// VerifyAssertion checks a response to a sign-in ceremony started with
+// challenge against the stored credential and returns the new signature
+// counter to store.
+func (rp *RelyingParty) VerifyAssertion(challenge string, cred Credential, a Assertion) (uint32, error) {
+	if !bytes.Equal(a.CredentialID, cred.ID) {
+		return 0, fmt.Errorf("invalid assertion: wrong credential")
+	}
+	if err := rp.checkClientData(a.ClientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	_, signCount, err := rp.checkAuthenticatorData(a.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := parsePublicKey(cred.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(a.ClientDataJSON)
+	signed := append(append([]byte(nil), a.AuthenticatorData...), clientDataHash[:]...)
+	if !key.verify(signed, a.Signature) {
+		return 0, fmt.Errorf("invalid assertion: bad signature")
+	}
+
+	// Authenticators that do not count always report zero.
+	if (signCount != 0 || cred.SignCount != 0) && signCount <= cred.SignCount {
+		return 0, ErrSignCount
+	}
+	return signCount, nil
+}
@@ -0,0 +1,71 @@
+package webauthn_test
+
+import (
+	"testing"
+
+	"github.com/duckonomy/noture/pkg/webauthn"
+	"github.com/duckonomy/noture/pkg/webauthn/webauthntest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelyingParty(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("https://notes.example.com", "Noture")
+	require.NoError(t, err)
+	assert.Equal(t, "notes.example.com", rp.ID)
+	assert.Equal(t, "https://notes.example.com", rp.Origin)
+
+	authenticator := webauthntest.NewAuthenticator("credential-1")
+	challenge, err := webauthn.NewChallenge()
+	require.NoError(t, err)
+
+	att := authenticator.Create(rp, challenge)
+	got, err := webauthn.ChallengeOf(att.ClientDataJSON)
+	require.NoError(t, err)
+	assert.Equal(t, challenge, got)
+
+	_, err = rp.VerifyRegistration("other-challenge", att)
+	assert.ErrorContains(t, err, "challenge mismatch")
+	other := *rp
+	other.Origin = "https://evil.example"
+	_, err = other.VerifyRegistration(challenge, att)
+	assert.ErrorContains(t, err, "origin")
+
+	cred, err := rp.VerifyRegistration(challenge, att)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("credential-1"), cred.ID)
+	assert.Equal(t, authenticator.COSEKey(), cred.PublicKey)
+
+	t.Run("assertion", func(t *testing.T) {
+		assertion := authenticator.Get(rp, "login-challenge")
+
+		count, err := rp.VerifyAssertion("login-challenge", *cred, assertion)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(1), count)
+
+		_, err = rp.VerifyAssertion("login-challenge", webauthn.Credential{ID: cred.ID, PublicKey: cred.PublicKey, SignCount: 1}, assertion)
+		assert.ErrorIs(t, err, webauthn.ErrSignCount)
+
+		assertion.Signature[len(assertion.Signature)-1] ^= 1
+		_, err = rp.VerifyAssertion("login-challenge", *cred, assertion)
+		assert.ErrorContains(t, err, "bad signature")
+	})
+
+	t.Run("user verification is required", func(t *testing.T) {
+		assertion := authenticator.Get(rp, "login-challenge")
+		assertion.AuthenticatorData[32] = 0x01
+
+		_, err := rp.VerifyAssertion("login-challenge", *cred, assertion)
+		assert.ErrorContains(t, err, "user not verified")
+	})
+
+	t.Run("other relying party", func(t *testing.T) {
+		elsewhere, err := webauthn.NewRelyingParty("https://other.example.com", "Other")
+		require.NoError(t, err)
+		assertion := authenticator.Get(elsewhere, "login-challenge")
+		assertion.ClientDataJSON = authenticator.Get(rp, "login-challenge").ClientDataJSON
+
+		_, err = rp.VerifyAssertion("login-challenge", *cred, assertion)
+		assert.ErrorContains(t, err, "wrong relying party")
+	})
+}
@@ -0,0 +1,123 @@
+// Package webauthntest provides a software authenticator for testing
+// passkey registration and sign-in without a browser.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/duckonomy/noture/pkg/webauthn"
+)
+
+// Authenticator holds one user-verifying P-256 credential, as a platform
+// authenticator would.
+type Authenticator struct {
+	ID        []byte
+	SignCount uint32
+	key       *ecdsa.PrivateKey
+}
+
+func NewAuthenticator(id string) *Authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return &Authenticator{ID: []byte(id), key: key}
+}
+
+// Create answers a registration ceremony for rp started with challenge.
+func (a *Authenticator) Create(rp *webauthn.RelyingParty, challenge string) webauthn.Attestation {
+	obj := head(5, 3)
+	obj = append(obj, text("fmt")...)
+	obj = append(obj, text("none")...)
+	obj = append(obj, text("attStmt")...)
+	obj = append(obj, head(5, 0)...)
+	obj = append(obj, text("authData")...)
+	obj = append(obj, bytes(a.authData(rp.ID, 0x45, true))...)
+	return webauthn.Attestation{
+		ClientDataJSON:    clientData("webauthn.create", challenge, rp.Origin),
+		AttestationObject: obj,
+	}
+}
+
+// Get answers a sign-in ceremony for rp started with challenge, counting
+// one more signature.
+func (a *Authenticator) Get(rp *webauthn.RelyingParty, challenge string) webauthn.Assertion {
+	a.SignCount++
+	authData := a.authData(rp.ID, 0x05, false)
+	cd := clientData("webauthn.get", challenge, rp.Origin)
+	hash := sha256.Sum256(cd)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), hash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return webauthn.Assertion{
+		CredentialID:      a.ID,
+		ClientDataJSON:    cd,
+		AuthenticatorData: authData,
+		Signature:         sig,
+	}
+}
+
+// COSEKey returns the credential's public key as a COSE key.
+func (a *Authenticator) COSEKey() []byte {
+	x, y := make([]byte, 32), make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	key := head(5, 5)
+	key = append(key, integer(1)...) // kty: EC2
+	key = append(key, integer(2)...)
+	key = append(key, integer(3)...) // alg: ES256
+	key = append(key, integer(webauthn.AlgES256)...)
+	key = append(key, integer(-1)...) // crv: P-256
+	key = append(key, integer(1)...)
+	key = append(key, integer(-2)...)
+	key = append(key, bytes(x)...)
+	key = append(key, integer(-3)...)
+	key = append(key, bytes(y)...)
+	return key
+}
+
+func (a *Authenticator) authData(rpID string, flags byte, attested bool) []byte {
+	hash := sha256.Sum256([]byte(rpID))
+	data := append(hash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.SignCount)
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.ID)))
+		data = append(data, a.ID...)
+		data = append(data, a.COSEKey()...)
+	}
+	return data
+}
+
+func clientData(typ, challenge, origin string) []byte {
+	data, _ := json.Marshal(map[string]string{"type": typ, "challenge": challenge, "origin": origin})
+	return data
+}
+
+func head(major byte, n int) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 256:
+		return []byte{major<<5 | 24, byte(n)}
+	}
+	return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+}
+
+func integer(n int) []byte {
+	if n < 0 {
+		return head(1, -1-n)
+	}
+	return head(0, n)
+}
+
+func bytes(b []byte) []byte { return append(head(2, len(b)), b...) }
+
+func text(s string) []byte { return append(head(3, len(s)), s...) }
@@ -0,0 +1,205 @@
+// Package webhook verifies signed requests from inbound integrations such
+// as payment providers, email gateways and storage callbacks.
+//
+// Signatures follow the scheme popularised by Stripe: the sender puts
+//
+//	t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">
+//
+// in a header. Several v1 entries may be present while a secret is rotated.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duckonomy/noture/pkg/logger"
+)
+
+const (
+	DefaultTolerance = 5 * time.Minute
+	DefaultHeader    = "X-Noture-Signature"
+	maxBodyBytes     = 1 << 20
+)
+
+var (
+	ErrMissingSignature = errors.New("missing webhook signature")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrStaleTimestamp   = errors.New("webhook timestamp outside tolerance")
+	ErrReplayed         = errors.New("webhook already processed")
+)
+
+// ReplayCache remembers signatures until they expire. Seen reports whether
+// the signature was already recorded and records it otherwise.
+type ReplayCache interface {
+	Seen(signature string, expiresAt time.Time) bool
+}
+
+type Verifier struct {
+	provider  string
+	secret    []byte
+	header    string
+	tolerance time.Duration
+	replays   ReplayCache
+	log       *logger.Logger
+	now       func() time.Time
+}
+
+func NewVerifier(provider, secret string) *Verifier {
+	return &Verifier{
+		provider:  provider,
+		secret:    []byte(secret),
+		header:    DefaultHeader,
+		tolerance: DefaultTolerance,
+		replays:   NewMemoryReplayCache(),
+		log:       logger.New(),
+		now:       time.Now,
+	}
+}
+
+// WithHeader changes the header the signature is read from, e.g.
+// "Stripe-Signature".
+func (v *Verifier) WithHeader(header string) *Verifier {
+	v.header = header
+	return v
+}
+
+func (v *Verifier) WithTolerance(tolerance time.Duration) *Verifier {
+	v.tolerance = tolerance
+	return v
+}
+
+func (v *Verifier) WithReplayCache(cache ReplayCache) *Verifier {
+	v.replays = cache
+	return v
+}
+
+// Sign returns a header value for body at t, for tests and for outbound
+// callbacks that use the same scheme.
+func (v *Verifier) Sign(body []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(v.mac(ts, body)))
+}
+
+// Verify checks header against body. A valid signature is recorded in the
+// replay cache, so verifying the same delivery twice fails.
+func (v *Verifier) Verify(header string, body []byte) error {
+	if header == "" {
+		return ErrMissingSignature
+	}
+
+	var ts string
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			if sig, err := hex.DecodeString(value); err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+	if ts == "" || len(signatures) == 0 {
+		return ErrMissingSignature
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	sentAt := time.Unix(unix, 0)
+	if age := v.now().Sub(sentAt); age > v.tolerance || age < -v.tolerance {
+		return ErrStaleTimestamp
+	}
+
+	expected := v.mac(ts, body)
+	for _, sig := range signatures {
+		if hmac.Equal(sig, expected) {
+			if v.replays.Seen(hex.EncodeToString(sig), sentAt.Add(v.tolerance)) {
+				return ErrReplayed
+			}
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// Middleware rejects requests without a valid signature and hands the
+// verified body on to next.
+func (v *Verifier) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := v.Verify(r.Header.Get(v.header), body); err != nil {
+			v.log.Warn("Rejected webhook", "provider", v.provider, "error", err)
+			status := http.StatusUnauthorized
+			if errors.Is(err, ErrReplayed) {
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	}
+}
+
+func (v *Verifier) mac(ts string, body []byte) []byte {
+	h := hmac.New(sha256.New, v.secret)
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// MemoryReplayCache is a process-local ReplayCache. Expired entries are
+// pruned as new ones are added, so it only ever holds one tolerance window.
+type MemoryReplayCache struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	now     func() time.Time
+}
+
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{
+		entries: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+func (c *MemoryReplayCache) Seen(signature string, expiresAt time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for sig, exp := range c.entries {
+		if now.After(exp) {
+			delete(c.entries, sig)
+		}
+	}
+
+	if _, ok := c.entries[signature]; ok {
+		return true
+	}
+	c.entries[signature] = expiresAt
+	return false
+}
@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"type":"invoice.paid"}`)
+
+	newVerifier := func() *Verifier {
+		clock := func() time.Time { return now }
+		cache := NewMemoryReplayCache()
+		cache.now = clock
+		v := NewVerifier("stripe", "whsec_test").WithReplayCache(cache)
+		v.now = clock
+		return v
+	}
+
+	t.Run("valid signature", func(t *testing.T) {
+		v := newVerifier()
+		assert.NoError(t, v.Verify(v.Sign(body, now), body))
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		v := newVerifier()
+		err := v.Verify(v.Sign(body, now), []byte(`{"type":"invoice.void"}`))
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		v := newVerifier()
+		header := NewVerifier("stripe", "other").Sign(body, now)
+		assert.ErrorIs(t, v.Verify(header, body), ErrInvalidSignature)
+	})
+
+	t.Run("timestamp outside tolerance", func(t *testing.T) {
+		v := newVerifier()
+		assert.ErrorIs(t, v.Verify(v.Sign(body, now.Add(-10*time.Minute)), body), ErrStaleTimestamp)
+		assert.ErrorIs(t, v.Verify(v.Sign(body, now.Add(10*time.Minute)), body), ErrStaleTimestamp)
+	})
+
+	t.Run("replayed delivery", func(t *testing.T) {
+		v := newVerifier()
+		header := v.Sign(body, now)
+		require.NoError(t, v.Verify(header, body))
+		assert.ErrorIs(t, v.Verify(header, body), ErrReplayed)
+	})
+
+	t.Run("rotated secret", func(t *testing.T) {
+		v := newVerifier()
+		old := NewVerifier("stripe", "whsec_old").Sign(body, now)
+		header := old + "," + strings.Split(v.Sign(body, now), ",")[1]
+		assert.NoError(t, v.Verify(header, body))
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		v := newVerifier()
+		assert.ErrorIs(t, v.Verify("", body), ErrMissingSignature)
+		assert.ErrorIs(t, v.Verify("t=123", body), ErrMissingSignature)
+	})
+}
+
+func TestVerifier_Middleware(t *testing.T) {
+	v := NewVerifier("gateway", "secret").WithHeader("X-Gateway-Signature")
+	body := `{"event":"bounce"}`
+
+	var received string
+	handler := v.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received = string(data)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/gateway", strings.NewReader(body))
+	req.Header.Set("X-Gateway-Signature", v.Sign([]byte(body), time.Now()))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, body, received)
+
+	req = httptest.NewRequest(http.MethodPost, "/webhooks/gateway", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
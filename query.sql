@@ -9,30 +9,134 @@ SELECT * FROM users WHERE email = $1;
 -- name: GetUserByID :one
 SELECT * FROM users WHERE id = $1;
 
+-- name: LockUser :one
+SELECT * FROM users WHERE id = $1 FOR UPDATE;
+
 -- name: UpdateUserStorageUsed :exec
 UPDATE users SET storage_used_bytes = $2, updated_at = NOW() WHERE id = $1;
 
+-- name: UpdateUserPassword :exec
+UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1;
+
+-- name: MarkEmailVerified :execrows
+UPDATE users SET email_verified_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND email = $2 AND email_verified_at IS NULL;
+
+-- name: UpdateUserTimezone :one
+UPDATE users SET timezone = $2, updated_at = NOW() WHERE id = $1
+RETURNING *;
+
+-- name: CreatePasswordReset :exec
+INSERT INTO password_resets (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE
+SET token_hash = EXCLUDED.token_hash, expires_at = EXCLUDED.expires_at, created_at = NOW();
+
+-- name: ConsumePasswordReset :one
+DELETE FROM password_resets
+WHERE token_hash = $1 AND expires_at > NOW()
+RETURNING user_id;
+
+-- name: GetUserPreferences :one
+SELECT * FROM user_preferences WHERE user_id = $1;
+
+-- name: UpdateUserPreferences :one
+INSERT INTO user_preferences (user_id, locale, default_workspace_id, notifications, editor)
+VALUES (
+    sqlc.arg(user_id),
+    COALESCE(sqlc.narg(locale), ''),
+    sqlc.narg(default_workspace_id),
+    sqlc.arg(notifications),
+    COALESCE(sqlc.narg(editor), '{}')
+)
+ON CONFLICT (user_id) DO UPDATE SET
+    locale = COALESCE(sqlc.narg(locale), user_preferences.locale),
+    default_workspace_id = CASE
+        WHEN sqlc.arg(set_default_workspace)::boolean THEN sqlc.narg(default_workspace_id)
+        ELSE user_preferences.default_workspace_id
+    END,
+    notifications = user_preferences.notifications || sqlc.arg(notifications),
+    editor = COALESCE(sqlc.narg(editor), user_preferences.editor),
+    updated_at = NOW()
+RETURNING *;
+
 -- NOTE: Atomic updates
 -- -- name: UpdateUserStorageUsed :exec
 -- UPDATE users SET storage_used_bytes = storage_used_bytes + $2, updated_at = NOW() WHERE id = $1;
 
 -- name: CreateAPIToken :one
-INSERT INTO api_tokens (user_id, token_hash, name, expires_at)
-VALUES ($1, $2, $3, $4)
+INSERT INTO api_tokens (user_id, token_hash, name, expires_at, device_id, scopes)
+VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: GetTokenByHash :one
-SELECT t.*, u.id as user_id, u.email, u.tier
+-- Expired tokens are returned, flagged, so that callers can tell them
+-- apart from unknown ones.
+SELECT t.*, u.id as user_id, u.email, u.tier, u.is_admin,
+       (u.email_verified_at IS NOT NULL)::boolean AS email_verified,
+       (t.expires_at IS NOT NULL AND t.expires_at <= NOW())::boolean AS expired
 FROM api_tokens t
 JOIN users u ON t.user_id = u.id
-WHERE t.token_hash = $1 AND (t.expires_at IS NULL OR t.expires_at > NOW());
+WHERE t.token_hash = $1 AND u.disabled_at IS NULL;
 
 -- name: UpdateTokenLastUsed :exec
-UPDATE api_tokens SET last_used_at = NOW() WHERE id = $1;
+UPDATE api_tokens
+SET last_used_at = NOW(),
+    client_name = COALESCE(sqlc.narg(client_name), client_name),
+    client_version = COALESCE(sqlc.narg(client_version), client_version),
+    last_ip = COALESCE(sqlc.narg(last_ip), last_ip),
+    user_agent = COALESCE(sqlc.narg(user_agent), user_agent)
+WHERE id = sqlc.arg(id);
 
--- name: DeleteAPIToken :exec
+-- name: DeleteAPIToken :execrows
 DELETE FROM api_tokens WHERE id = $1 AND user_id = $2;
 
+-- name: ListSessions :many
+SELECT t.id, t.name, t.device_id, d.name AS device_name,
+       t.client_name, t.client_version, t.last_ip, t.user_agent, t.scopes,
+       t.last_used_at, t.expires_at, t.created_at
+FROM api_tokens t
+LEFT JOIN devices d ON d.id = t.device_id
+WHERE t.user_id = $1 AND (t.expires_at IS NULL OR t.expires_at > NOW())
+ORDER BY COALESCE(t.last_used_at, t.created_at) DESC;
+
+-- name: ListScopedAPITokens :many
+SELECT * FROM api_tokens
+WHERE user_id = $1 AND scopes IS NOT NULL
+ORDER BY created_at DESC;
+
+-- name: DeleteScopedAPIToken :execrows
+DELETE FROM api_tokens WHERE id = $1 AND user_id = $2 AND scopes IS NOT NULL;
+
+-- name: DeleteStaleAPITokens :execrows
+DELETE FROM api_tokens
+WHERE expires_at < sqlc.arg(expired_before)
+   OR COALESCE(last_used_at, created_at) < sqlc.arg(unused_since);
+
+-- name: RevokeUserTokens :execrows
+DELETE FROM api_tokens
+WHERE user_id = sqlc.arg(user_id) AND id IS DISTINCT FROM sqlc.narg(keep_id);
+
+-- name: CreateDevice :one
+INSERT INTO devices (user_id, name)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: ListDevices :many
+SELECT d.id, d.name, d.created_at,
+       MAX(t.last_used_at)::timestamptz AS last_seen_at,
+       COUNT(t.id) AS token_count,
+       (ARRAY_AGG(t.client_name ORDER BY t.last_used_at DESC NULLS LAST) FILTER (WHERE t.client_name IS NOT NULL))[1]::text AS client_name,
+       (ARRAY_AGG(t.client_version ORDER BY t.last_used_at DESC NULLS LAST) FILTER (WHERE t.client_version IS NOT NULL))[1]::text AS client_version
+FROM devices d
+LEFT JOIN api_tokens t ON t.device_id = d.id
+WHERE d.user_id = $1
+GROUP BY d.id
+ORDER BY d.created_at DESC;
+
+-- name: DeleteDevice :execrows
+DELETE FROM devices WHERE id = $1 AND user_id = $2;
+
 -- name: CreateWorkspace :one
 INSERT INTO workspaces (user_id, name, storage_limit_bytes)
 VALUES ($1, $2, $3)
@@ -41,23 +145,60 @@ RETURNING *;
 -- name: GetWorkspacesByUser :many
 SELECT * FROM workspaces WHERE user_id = $1 ORDER BY created_at DESC;
 
+-- name: ListAllWorkspaces :many
+SELECT * FROM workspaces ORDER BY created_at;
+
 -- name: GetWorkspaceByID :one
 SELECT * FROM workspaces WHERE id = $1;
 
--- name: UpdateWorkspaceStorageUsed :exec
-UPDATE workspaces SET storage_used_bytes = $2, updated_at = NOW() WHERE id = $1;
+-- name: ListMemberWorkspaces :many
+SELECT w.*, m.role, COALESCE(o.sort_order, 0)::INTEGER AS sort_order FROM workspaces w
+JOIN workspace_members m ON m.workspace_id = w.id
+LEFT JOIN workspace_sort_orders o ON o.workspace_id = w.id AND o.user_id = m.user_id
+WHERE m.user_id = $1
+ORDER BY COALESCE(o.sort_order, 0), w.created_at DESC;
 
--- NOTE: Atomic updates
--- -- name: UpdateUserStorageUsed :exec
--- UPDATE workspaces SET storage_used_bytes = storage_used_bytes + $2, updated_at = NOW() WHERE id = $1;
+-- name: GetWorkspaceSortOrder :one
+SELECT COALESCE((
+    SELECT sort_order FROM workspace_sort_orders WHERE user_id = $1 AND workspace_id = $2
+), 0)::INTEGER;
+
+-- name: SetWorkspaceSortOrder :exec
+INSERT INTO workspace_sort_orders (user_id, workspace_id, sort_order)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, workspace_id) DO UPDATE SET sort_order = EXCLUDED.sort_order;
+
+-- name: SetWorkspaceArchived :one
+UPDATE workspaces SET archived_at = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateWorkspaceDisplay :one
+UPDATE workspaces
+SET icon = COALESCE(sqlc.narg(icon), icon),
+    color = COALESCE(sqlc.narg(color), color),
+    description = COALESCE(sqlc.narg(description), description)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: DeleteWorkspace :exec
+DELETE FROM workspaces WHERE id = $1;
+
+-- name: AdjustWorkspaceCounters :one
+UPDATE workspaces
+SET file_count = file_count + $2,
+    storage_used_bytes = storage_used_bytes + $3,
+    revision = revision + 1,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING revision;
 
 -- name: UpsertFile :one
-INSERT INTO files (workspace_id, file_path, content_hash, content, size_bytes, mime_type, last_modified)
-VALUES ($1, $2, $3, $4, $5, $6, $7)
+INSERT INTO files (workspace_id, file_path, content_hash, size_bytes, mime_type, last_modified)
+VALUES ($1, $2, $3, $4, $5, $6)
 ON CONFLICT (workspace_id, file_path)
 DO UPDATE SET
     content_hash = EXCLUDED.content_hash,
-    content = EXCLUDED.content,
     size_bytes = EXCLUDED.size_bytes,
     mime_type = EXCLUDED.mime_type,
     last_modified = EXCLUDED.last_modified,
@@ -76,29 +217,238 @@ FROM files
 WHERE workspace_id = $1
 ORDER BY file_path;
 
+-- name: ListFilesPage :many
+SELECT id, workspace_id, file_path, content_hash, size_bytes, mime_type, last_modified, updated_at
+FROM files
+WHERE workspace_id = sqlc.arg(workspace_id)
+  AND file_path > sqlc.arg(after_path)
+  AND file_path >= sqlc.arg(path_prefix)
+  AND starts_with(file_path, sqlc.arg(path_prefix))
+  AND (cardinality(sqlc.arg(mime_patterns)::text[]) = 0 OR mime_type LIKE ANY(sqlc.arg(mime_patterns)::text[]))
+ORDER BY file_path
+LIMIT sqlc.arg(page_size);
+
+-- name: ListFilesByUpdatedAt :many
+SELECT id, workspace_id, file_path, content_hash, size_bytes, mime_type, last_modified, updated_at
+FROM files
+WHERE workspace_id = sqlc.arg(workspace_id)
+  AND (updated_at, file_path) < (sqlc.arg(after_updated_at)::timestamptz, sqlc.arg(after_path)::text)
+  AND starts_with(file_path, sqlc.arg(path_prefix))
+  AND (cardinality(sqlc.arg(mime_patterns)::text[]) = 0 OR mime_type LIKE ANY(sqlc.arg(mime_patterns)::text[]))
+ORDER BY updated_at DESC, file_path DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListFilesBySize :many
+SELECT id, workspace_id, file_path, content_hash, size_bytes, mime_type, last_modified, updated_at
+FROM files
+WHERE workspace_id = sqlc.arg(workspace_id)
+  AND (size_bytes, file_path) < (sqlc.arg(after_size)::bigint, sqlc.arg(after_path)::text)
+  AND starts_with(file_path, sqlc.arg(path_prefix))
+  AND (cardinality(sqlc.arg(mime_patterns)::text[]) = 0 OR mime_type LIKE ANY(sqlc.arg(mime_patterns)::text[]))
+ORDER BY size_bytes DESC, file_path DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListFileManifest :many
+SELECT file_path, content_hash, size_bytes, last_modified, mime_type
+FROM files
+WHERE workspace_id = sqlc.arg(workspace_id)
+  AND file_path >= sqlc.arg(path_prefix)
+  AND starts_with(file_path, sqlc.arg(path_prefix))
+ORDER BY file_path;
+
+-- name: LookupFiles :many
+SELECT f.id, f.workspace_id, f.file_path, f.content_hash, f.size_bytes, f.mime_type, f.last_modified, f.updated_at,
+       m.format, m.properties, m.word_count, m.last_parsed
+FROM files f
+LEFT JOIN file_metadata m ON m.file_id = f.id
+WHERE f.workspace_id = $1 AND f.file_path = ANY(sqlc.arg(file_paths)::text[]);
+
 -- name: DeleteFile :exec
 DELETE FROM files WHERE workspace_id = $1 AND file_path = $2;
 
--- name: GetFileContent :one
-SELECT content FROM files WHERE workspace_id = $1 AND file_path = $2;
+-- name: SetFileNoteID :exec
+INSERT INTO file_note_ids (file_id, workspace_id, note_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (file_id) DO UPDATE SET note_id = EXCLUDED.note_id;
+
+-- name: DeleteFileNoteID :exec
+DELETE FROM file_note_ids WHERE file_id = $1;
+
+-- name: GetFileNoteID :one
+SELECT note_id FROM file_note_ids WHERE file_id = $1;
+
+-- name: GetFileByNoteID :one
+SELECT f.* FROM files f
+JOIN file_note_ids n ON n.file_id = f.id
+WHERE n.workspace_id = $1 AND n.note_id = $2
+ORDER BY f.created_at
+LIMIT 1;
+
+-- name: FindRecreatedNote :one
+SELECT f.* FROM files f
+JOIN file_note_ids n ON n.file_id = f.id
+WHERE n.workspace_id = sqlc.arg(workspace_id)
+  AND n.note_id = sqlc.arg(note_id)
+  AND f.id <> sqlc.arg(file_id)
+  AND f.created_at >= sqlc.arg(created_after)
+ORDER BY f.created_at DESC
+LIMIT 1;
+
+-- name: MoveFile :one
+UPDATE files
+SET file_path = sqlc.arg(new_path), updated_at = NOW()
+WHERE workspace_id = sqlc.arg(workspace_id) AND file_path = sqlc.arg(file_path)
+RETURNING *;
+
+-- name: LockFilePath :exec
+SELECT pg_advisory_xact_lock(hashtextextended(sqlc.arg(workspace_id)::uuid::text || '/' || sqlc.arg(file_path)::text, 0));
+
+-- name: LockFilesByPrefix :exec
+SELECT pg_advisory_xact_lock(hashtextextended(workspace_id::text || '/' || file_path, 0))
+FROM files
+WHERE workspace_id = sqlc.arg(workspace_id) AND starts_with(file_path, sqlc.arg(path_prefix))
+ORDER BY file_path;
+
+-- name: DeleteFilesByPrefix :one
+WITH deleted AS (
+    DELETE FROM files
+    WHERE workspace_id = sqlc.arg(workspace_id) AND starts_with(file_path, sqlc.arg(path_prefix))
+    RETURNING size_bytes
+)
+SELECT COUNT(*)::bigint AS file_count, COALESCE(SUM(size_bytes), 0)::bigint AS size_bytes
+FROM deleted;
+
 
 -- name: UpsertFileMetadata :exec
-INSERT INTO file_metadata (file_id, format, parsed_blocks, properties, word_count)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO file_metadata (file_id, format, parsed_blocks, properties, word_count, parser_version)
+VALUES ($1, $2, $3, $4, $5, $6)
 ON CONFLICT (file_id)
 DO UPDATE SET
     format = EXCLUDED.format,
     parsed_blocks = EXCLUDED.parsed_blocks,
     properties = EXCLUDED.properties,
     word_count = EXCLUDED.word_count,
+    parser_version = EXCLUDED.parser_version,
     last_parsed = NOW();
 
 -- name: GetFileMetadata :one
 SELECT * FROM file_metadata WHERE file_id = $1;
 
+-- name: ListStaleMetadataFiles :many
+SELECT f.workspace_id, f.file_path
+FROM files f
+LEFT JOIN file_metadata m ON m.file_id = f.id
+WHERE m.file_id IS NULL OR m.parser_version < sqlc.arg(parser_version)
+ORDER BY f.last_modified DESC
+LIMIT sqlc.arg(max_files);
+
+-- name: GetMetadataStatus :one
+SELECT COUNT(*)::bigint AS total_files,
+       COUNT(m.file_id) FILTER (WHERE m.parser_version >= sqlc.arg(parser_version))::bigint AS parsed_files
+FROM files f
+LEFT JOIN file_metadata m ON m.file_id = f.id
+WHERE f.workspace_id = sqlc.arg(workspace_id);
+
+-- name: MarkWorkspaceMetadataStale :execrows
+UPDATE file_metadata m
+SET parser_version = 0
+FROM files f
+WHERE f.id = m.file_id AND f.workspace_id = $1 AND m.parser_version > 0;
+
+-- name: ListWorkspaceFormatStats :many
+SELECT COALESCE(m.format, 'unparsed')::text AS format,
+       COUNT(*)::bigint AS files,
+       COALESCE(SUM(f.size_bytes), 0)::bigint AS size_bytes,
+       COALESCE(SUM(m.word_count), 0)::bigint AS words
+FROM files f
+LEFT JOIN file_metadata m ON m.file_id = f.id
+WHERE f.workspace_id = $1
+GROUP BY 1
+ORDER BY files DESC, format;
+
+-- name: ListLargestFiles :many
+SELECT f.file_path, f.size_bytes, m.word_count
+FROM files f
+LEFT JOIN file_metadata m ON m.file_id = f.id
+WHERE f.workspace_id = sqlc.arg(workspace_id)
+ORDER BY f.size_bytes DESC, f.file_path
+LIMIT sqlc.arg(max_files);
+
+-- name: ListWorkspaceVersionsByDay :many
+SELECT (v.created_at AT TIME ZONE sqlc.arg(time_zone)::text)::date AS day,
+       COUNT(*)::bigint AS versions
+FROM file_versions v
+JOIN files f ON f.id = v.file_id
+WHERE f.workspace_id = sqlc.arg(workspace_id)
+  AND v.created_at >= sqlc.arg(since)
+GROUP BY 1
+ORDER BY 1;
+
+-- name: ListWorkspaceFilesCreatedByDay :many
+SELECT (created_at AT TIME ZONE sqlc.arg(time_zone)::text)::date AS day,
+       COUNT(*)::bigint AS files
+FROM files
+WHERE workspace_id = sqlc.arg(workspace_id)
+  AND created_at >= sqlc.arg(since)
+GROUP BY 1
+ORDER BY 1;
+
+-- name: RecordFileOpen :exec
+INSERT INTO file_opens (user_id, file_id, workspace_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, file_id) DO UPDATE SET opened_at = NOW();
+
+-- name: PruneFileOpens :exec
+DELETE FROM file_opens
+WHERE user_id = sqlc.arg(user_id)
+  AND workspace_id = sqlc.arg(workspace_id)
+  AND file_id NOT IN (
+    SELECT file_id FROM file_opens
+    WHERE user_id = sqlc.arg(user_id) AND workspace_id = sqlc.arg(workspace_id)
+    ORDER BY opened_at DESC
+    LIMIT sqlc.arg(keep)
+  );
+
+-- name: ListRecentFileOpens :many
+SELECT f.id, f.workspace_id, f.file_path, f.content_hash, f.size_bytes, f.mime_type, f.last_modified, f.updated_at, o.opened_at
+FROM file_opens o
+JOIN files f ON f.id = o.file_id
+WHERE o.user_id = sqlc.arg(user_id) AND o.workspace_id = sqlc.arg(workspace_id)
+ORDER BY o.opened_at DESC, f.file_path
+LIMIT sqlc.arg(max_files);
+
+-- name: PinFile :exec
+INSERT INTO file_pins (user_id, file_id, workspace_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, file_id) DO NOTHING;
+
+-- name: UnpinFile :execrows
+DELETE FROM file_pins WHERE user_id = $1 AND file_id = $2;
+
+-- name: CountFilePins :one
+SELECT COUNT(*) FROM file_pins WHERE user_id = $1 AND workspace_id = $2;
+
+-- name: ListPinnedFiles :many
+SELECT f.id, f.workspace_id, f.file_path, f.content_hash, f.size_bytes, f.mime_type, f.last_modified, f.updated_at, p.pinned_at
+FROM file_pins p
+JOIN files f ON f.id = p.file_id
+WHERE p.user_id = $1 AND p.workspace_id = $2
+ORDER BY p.pinned_at, f.file_path;
+
+-- name: GetFileMeta :one
+SELECT meta FROM file_meta WHERE file_id = $1;
+
+-- name: SetFileMeta :exec
+INSERT INTO file_meta (file_id, meta)
+VALUES ($1, $2)
+ON CONFLICT (file_id) DO UPDATE SET meta = EXCLUDED.meta, updated_at = NOW();
+
+-- name: DeleteFileMeta :exec
+DELETE FROM file_meta WHERE file_id = $1;
+
 -- name: CreateSyncOperation :one
-INSERT INTO sync_operations (workspace_id, file_id, operation_type, client_id, status)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO sync_operations (workspace_id, file_id, operation_type, client_id, status, file_path)
+VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: UpdateSyncOperationStatus :exec
@@ -106,15 +456,57 @@ UPDATE sync_operations
 SET status = $2, error_message = $3
 WHERE id = $1;
 
--- name: GetSyncOperations :many
+-- name: ListSyncOperations :many
 SELECT * FROM sync_operations
-WHERE workspace_id = $1
-ORDER BY created_at DESC
-LIMIT $2;
+WHERE workspace_id = sqlc.arg(workspace_id)
+  AND (created_at, id) < (sqlc.arg(before_created_at)::timestamptz, sqlc.arg(before_id)::uuid)
+  AND created_at >= sqlc.arg(since)::timestamptz
+  AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status))
+  AND (sqlc.narg(operation_type)::text IS NULL OR operation_type = sqlc.narg(operation_type))
+  AND (sqlc.narg(client_id)::text IS NULL OR client_id = sqlc.narg(client_id))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: PruneSyncOperations :execrows
+DELETE FROM sync_operations
+WHERE id IN (
+    SELECT s.id FROM sync_operations s
+    CROSS JOIN LATERAL (
+        SELECT r.max_age_seconds
+        FROM unnest(sqlc.arg(operation_types)::text[], sqlc.arg(statuses)::text[], sqlc.arg(max_age_seconds)::bigint[])
+            AS r(operation_type, status, max_age_seconds)
+        WHERE r.operation_type IN ('', s.operation_type)
+          AND r.status IN ('', s.status)
+        ORDER BY (r.status <> '')::int * 2 + (r.operation_type <> '')::int DESC
+        LIMIT 1
+    ) rule
+    WHERE s.created_at < sqlc.arg(older_than)
+      AND s.created_at < NOW() - make_interval(secs => rule.max_age_seconds)
+    ORDER BY s.created_at
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: SummarizeSyncOperations :many
+SELECT operation_type, status, COUNT(*) AS operation_count, MIN(created_at)::timestamptz AS oldest
+FROM sync_operations
+GROUP BY operation_type, status
+ORDER BY operation_type, status;
+
+-- name: ListTableGrowth :many
+SELECT relname::text AS table_name,
+       n_live_tup AS live_rows,
+       n_dead_tup AS dead_rows,
+       pg_total_relation_size(relid) AS total_bytes,
+       last_autovacuum
+FROM pg_stat_user_tables
+ORDER BY pg_total_relation_size(relid) DESC;
 
--- name: CreateFileVersion :exec
-INSERT INTO file_versions (file_id, version_number, content_hash, content)
-VALUES ($1, $2, $3, $4);
+-- name: CreateFileVersion :one
+INSERT INTO file_versions (file_id, version_number, content_hash)
+SELECT $1, COALESCE(MAX(version_number), 0) + 1, $2
+FROM file_versions
+WHERE file_id = $1
+RETURNING version_number;
 
 -- name: GetFileVersions :many
 SELECT * FROM file_versions
@@ -122,13 +514,1024 @@ WHERE file_id = $1
 ORDER BY version_number DESC
 LIMIT $2;
 
+-- name: PruneFileVersions :execrows
+DELETE FROM file_versions
+WHERE id IN (
+    SELECT ranked.id FROM (
+        SELECT v.id, v.created_at,
+               ROW_NUMBER() OVER (PARTITION BY v.file_id ORDER BY v.version_number DESC) AS position
+        FROM file_versions v
+        JOIN files f ON f.id = v.file_id
+        JOIN workspaces w ON w.id = f.workspace_id
+        JOIN users u ON u.id = w.user_id
+        WHERE u.tier = sqlc.arg(tier)
+    ) ranked
+    WHERE ranked.position > 1
+      AND (ranked.position > sqlc.arg(max_versions)::int OR ranked.created_at < sqlc.arg(created_before))
+    LIMIT sqlc.arg(batch_size)
+);
+
 -- name: GetWorkspaceStorageUsage :one
-SELECT
-    w.storage_limit_bytes,
-    w.storage_used_bytes,
-    COUNT(f.id) as file_count,
-    COALESCE(SUM(f.size_bytes), 0) as actual_storage_used
+-- The workspaces of an organization share its storage pool, so for them
+-- the limit, usage and tier are the organization's.
+SELECT ow.organization_id,
+       CASE WHEN ow.organization_id IS NULL THEN w.storage_limit_bytes
+            ELSE COALESCE(o.storage_limit_bytes, o.seat_storage_bytes *
+                (SELECT COUNT(*) FROM organization_members m WHERE m.organization_id = o.id))
+       END::bigint AS storage_limit_bytes,
+       CASE WHEN ow.organization_id IS NULL THEN COALESCE(w.storage_used_bytes, 0)
+            ELSE (SELECT COALESCE(SUM(pw.storage_used_bytes), 0)
+                  FROM organization_workspaces pow
+                  JOIN workspaces pw ON pw.id = pow.workspace_id
+                  WHERE pow.organization_id = o.id)
+       END::bigint AS storage_used_bytes,
+       w.file_count,
+       COALESCE(o.tier, u.tier)::user_tier AS tier
 FROM workspaces w
-LEFT JOIN files f ON w.id = f.workspace_id
-WHERE w.id = $1
-GROUP BY w.id, w.storage_limit_bytes, w.storage_used_bytes;
+JOIN users u ON u.id = w.user_id
+LEFT JOIN organization_workspaces ow ON ow.workspace_id = w.id
+LEFT JOIN organizations o ON o.id = ow.organization_id
+WHERE w.id = $1;
+
+-- name: FlagInactiveWorkspaces :execrows
+INSERT INTO workspace_suggestions (workspace_id, user_id, reason, last_activity_at)
+SELECT id, user_id, 'inactive', updated_at
+FROM workspaces
+WHERE archived_at IS NULL AND updated_at < $1
+ON CONFLICT (workspace_id, reason) DO NOTHING;
+
+-- name: ClearActiveWorkspaceSuggestions :execrows
+DELETE FROM workspace_suggestions s
+USING workspaces w
+WHERE s.workspace_id = w.id
+  AND s.reason = 'inactive'
+  AND (w.archived_at IS NOT NULL OR w.updated_at >= $1);
+
+-- name: ListSuggestionsByUser :many
+SELECT s.id, s.workspace_id, s.reason, s.last_activity_at, s.created_at,
+       w.name AS workspace_name, w.storage_used_bytes, w.file_count
+FROM workspace_suggestions s
+JOIN workspaces w ON w.id = s.workspace_id
+WHERE s.user_id = $1 AND s.dismissed_at IS NULL
+ORDER BY s.last_activity_at;
+
+-- name: GetSuggestion :one
+SELECT * FROM workspace_suggestions WHERE id = $1 AND user_id = $2;
+
+-- name: DismissSuggestion :exec
+UPDATE workspace_suggestions SET dismissed_at = NOW() WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteSuggestion :exec
+DELETE FROM workspace_suggestions WHERE id = $1;
+
+-- name: CreateAuthSession :one
+INSERT INTO auth_sessions (kind, session_key, user_code, provider, device_code, device_name, expires_at, user_id, code_verifier, code_challenge)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING *;
+
+-- name: GetAuthSession :one
+SELECT * FROM auth_sessions
+WHERE kind = $1 AND session_key = $2 AND expires_at > NOW();
+
+-- name: GetDeviceSessionByUserCode :one
+SELECT * FROM auth_sessions
+WHERE kind = 'device' AND user_code = $1 AND expires_at > NOW();
+
+-- name: ConsumeAuthSession :one
+DELETE FROM auth_sessions
+WHERE kind = $1 AND session_key = $2 AND expires_at > NOW()
+RETURNING *;
+
+-- name: ApproveDeviceSession :execrows
+UPDATE auth_sessions SET user_id = $2
+WHERE kind = 'device' AND session_key = $1 AND user_id IS NULL AND expires_at > NOW();
+
+-- name: DeleteExpiredAuthSessions :execrows
+DELETE FROM auth_sessions WHERE expires_at <= NOW();
+
+-- name: GetWorkspaceMember :one
+SELECT * FROM workspace_members WHERE workspace_id = $1 AND user_id = $2;
+
+-- name: ListWorkspaceMembers :many
+SELECT m.workspace_id, m.user_id, m.role, m.invited_by, m.created_at, u.email
+FROM workspace_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.workspace_id = $1
+ORDER BY m.created_at, u.email;
+
+-- name: AddWorkspaceMember :one
+INSERT INTO workspace_members (workspace_id, user_id, role, invited_by)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: UpdateWorkspaceMemberRole :one
+UPDATE workspace_members SET role = $3, updated_at = NOW()
+WHERE workspace_id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: RemoveWorkspaceMember :execrows
+DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2;
+
+-- name: CreateWorkspaceInvite :one
+INSERT INTO workspace_invites (workspace_id, email, role, token_hash, invited_by, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (workspace_id, email) WHERE accepted_at IS NULL DO UPDATE
+SET role = EXCLUDED.role, token_hash = EXCLUDED.token_hash, invited_by = EXCLUDED.invited_by,
+    expires_at = EXCLUDED.expires_at, created_at = NOW()
+RETURNING *;
+
+-- name: ListWorkspaceInvites :many
+SELECT * FROM workspace_invites
+WHERE workspace_id = $1 AND accepted_at IS NULL AND expires_at > NOW()
+ORDER BY created_at, email;
+
+-- name: GetWorkspaceInviteByToken :one
+SELECT i.id, i.workspace_id, i.email, i.role, i.invited_by, i.expires_at,
+       w.name AS workspace_name, u.email AS invited_by_email
+FROM workspace_invites i
+JOIN workspaces w ON w.id = i.workspace_id
+JOIN users u ON u.id = i.invited_by
+WHERE i.token_hash = $1 AND i.accepted_at IS NULL AND i.expires_at > NOW();
+
+-- name: AcceptWorkspaceInvite :execrows
+UPDATE workspace_invites SET accepted_at = NOW(), accepted_by = $2
+WHERE id = $1 AND accepted_at IS NULL AND expires_at > NOW();
+
+-- name: DeleteWorkspaceInvite :execrows
+DELETE FROM workspace_invites WHERE workspace_id = $1 AND id = $2 AND accepted_at IS NULL;
+
+-- name: CreateShareLink :one
+INSERT INTO share_links (token_hash, file_id, workspace_id, created_by, expires_at, policy_checked_hash)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetSharedFile :one
+SELECT f.file_path, f.content_hash, f.mime_type, f.last_modified, s.expires_at,
+       s.id, s.workspace_id, s.created_by, s.policy_checked_hash, s.policy_blocked
+FROM share_links s
+JOIN files f ON f.id = s.file_id
+WHERE s.token_hash = $1
+  AND s.revoked_at IS NULL
+  AND (s.expires_at IS NULL OR s.expires_at > NOW());
+
+-- name: ListShareLinksByUser :many
+SELECT s.id, s.workspace_id, f.file_path, s.expires_at, s.created_at
+FROM share_links s
+JOIN files f ON f.id = s.file_id
+WHERE s.created_by = $1 AND s.revoked_at IS NULL
+ORDER BY s.created_at DESC;
+
+-- name: RecordSharePolicyCheck :exec
+UPDATE share_links SET policy_checked_hash = $2, policy_blocked = $3 WHERE id = $1;
+
+-- name: RevokeShareLink :execrows
+UPDATE share_links SET revoked_at = NOW()
+WHERE id = $1 AND created_by = $2 AND revoked_at IS NULL;
+
+-- name: PutBlob :exec
+INSERT INTO blobs (content_hash, content)
+VALUES ($1, $2)
+ON CONFLICT (content_hash) DO NOTHING;
+
+-- name: GetBlob :one
+SELECT content FROM blobs WHERE content_hash = $1;
+
+-- name: GetBlobSize :one
+SELECT octet_length(content)::bigint AS size_bytes FROM blobs WHERE content_hash = $1;
+
+-- name: GetBlobRange :one
+SELECT substring(content FROM sqlc.arg(start)::int FOR sqlc.arg(length)::int) AS chunk
+FROM blobs WHERE content_hash = sqlc.arg(content_hash);
+
+-- name: DeleteBlob :exec
+DELETE FROM blobs WHERE content_hash = $1;
+
+-- name: ListAllContentHashes :many
+SELECT content_hash FROM blob_refs WHERE ref_count > 0;
+
+-- name: ListUnreferencedBlobs :many
+SELECT content_hash FROM blob_refs
+WHERE ref_count <= 0 AND updated_at < $1
+ORDER BY updated_at
+LIMIT $2;
+
+-- name: LockUnreferencedBlob :one
+SELECT content_hash FROM blob_refs
+WHERE content_hash = $1 AND ref_count <= 0
+FOR UPDATE SKIP LOCKED;
+
+-- name: DeleteBlobRef :exec
+DELETE FROM blob_refs WHERE content_hash = $1;
+
+-- name: BlobRefExists :one
+SELECT EXISTS(SELECT 1 FROM blob_refs WHERE content_hash = $1);
+
+-- name: InsertWorkspaceEvent :one
+INSERT INTO workspace_events (workspace_id, event_type, file_path, actor_id, payload)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id;
+
+-- name: ListWorkspaceEvents :many
+SELECT * FROM workspace_events
+WHERE workspace_id = sqlc.arg(workspace_id)
+  AND id > sqlc.arg(after_id)
+  AND (cardinality(sqlc.arg(type_patterns)::text[]) = 0 OR event_type LIKE ANY(sqlc.arg(type_patterns)::text[]))
+ORDER BY id
+LIMIT sqlc.arg(page_size);
+
+-- name: ListWorkspaceEventsBetween :many
+SELECT * FROM workspace_events
+WHERE workspace_id = sqlc.arg(workspace_id)
+  AND created_at >= sqlc.arg(from_time)
+  AND created_at < sqlc.arg(to_time)
+  AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(page_size);
+
+-- name: ListFileTasks :many
+SELECT * FROM file_tasks WHERE file_id = $1;
+
+-- name: UpsertFileTask :exec
+INSERT INTO file_tasks (file_id, task_key, workspace_id, title, done, deadline)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (file_id, task_key) DO UPDATE SET
+    title = EXCLUDED.title,
+    done = EXCLUDED.done,
+    deadline = EXCLUDED.deadline,
+    reminded_at = CASE WHEN file_tasks.deadline IS DISTINCT FROM EXCLUDED.deadline
+                       THEN NULL ELSE file_tasks.reminded_at END;
+
+-- name: DeleteFileTasksExcept :exec
+DELETE FROM file_tasks
+WHERE file_id = sqlc.arg(file_id) AND NOT (task_key = ANY(sqlc.arg(keep_keys)::text[]));
+
+-- name: ClaimDueTasks :many
+UPDATE file_tasks t SET reminded_at = NOW()
+FROM files f
+WHERE f.id = t.file_id
+  AND (t.file_id, t.task_key) IN (
+      SELECT d.file_id, d.task_key FROM file_tasks d
+      WHERE NOT d.done AND d.reminded_at IS NULL AND d.deadline <= sqlc.arg(due_before)
+      ORDER BY d.deadline
+      LIMIT sqlc.arg(batch_size)
+      FOR UPDATE SKIP LOCKED
+  )
+RETURNING t.file_id, t.task_key, t.workspace_id, t.title, t.deadline, f.file_path;
+
+-- name: CreateWorkspaceWebhook :one
+INSERT INTO workspace_webhooks (workspace_id, url, secret, event_types, path_prefix, created_by, last_event_id)
+VALUES (sqlc.arg(workspace_id), sqlc.arg(url), sqlc.arg(secret), sqlc.arg(event_types), sqlc.arg(path_prefix), sqlc.arg(created_by),
+        (SELECT COALESCE(MAX(id), 0)::bigint FROM workspace_events WHERE workspace_id = sqlc.arg(workspace_id)))
+RETURNING *;
+
+-- name: ListWorkspaceWebhooks :many
+SELECT * FROM workspace_webhooks WHERE workspace_id = $1 ORDER BY created_at;
+
+-- name: DeleteWorkspaceWebhook :execrows
+DELETE FROM workspace_webhooks WHERE id = $1 AND workspace_id = $2;
+
+-- name: ListPendingWebhooks :many
+SELECT w.* FROM workspace_webhooks w
+WHERE (w.next_attempt_at IS NULL OR w.next_attempt_at <= NOW())
+  AND EXISTS (SELECT 1 FROM workspace_events e WHERE e.workspace_id = w.workspace_id AND e.id > w.last_event_id)
+ORDER BY w.next_attempt_at NULLS FIRST, w.created_at
+LIMIT $1;
+
+-- name: AdvanceWebhook :exec
+UPDATE workspace_webhooks
+SET last_event_id = $2, failure_count = 0, last_error = NULL, next_attempt_at = NULL
+WHERE id = $1;
+
+-- name: RecordWebhookFailure :exec
+UPDATE workspace_webhooks
+SET failure_count = failure_count + 1, last_error = $2, next_attempt_at = $3
+WHERE id = $1;
+
+-- name: GetGitMirror :one
+SELECT * FROM workspace_git_mirrors WHERE workspace_id = $1;
+
+-- name: UpsertGitMirror :one
+-- Reconfiguring keeps the deploy key. Pointing the mirror elsewhere
+-- forgets the last commit, so the next sync starts over with that remote.
+INSERT INTO workspace_git_mirrors (workspace_id, remote_url, branch, pull, private_key, public_key, created_by)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (workspace_id) DO UPDATE SET
+    remote_url = EXCLUDED.remote_url,
+    branch = EXCLUDED.branch,
+    pull = EXCLUDED.pull,
+    last_commit = CASE WHEN workspace_git_mirrors.remote_url = EXCLUDED.remote_url AND workspace_git_mirrors.branch = EXCLUDED.branch
+                       THEN workspace_git_mirrors.last_commit END,
+    sync_requested_at = NOW(),
+    failure_count = 0,
+    last_error = NULL,
+    next_attempt_at = NULL,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteGitMirror :execrows
+DELETE FROM workspace_git_mirrors WHERE workspace_id = $1;
+
+-- name: RequestGitMirrorSync :one
+-- A mirror that is backing off after failures is retried at once.
+UPDATE workspace_git_mirrors
+SET sync_requested_at = NOW(),
+    next_attempt_at = CASE WHEN failure_count > 0 THEN NULL ELSE next_attempt_at END
+WHERE workspace_id = $1
+RETURNING *;
+
+-- name: RequestGitMirrorSyncsForRemote :execrows
+-- A push to a branch is news to the mirrors pulling from it.
+UPDATE workspace_git_mirrors
+SET sync_requested_at = NOW(),
+    next_attempt_at = CASE WHEN failure_count > 0 THEN NULL ELSE next_attempt_at END
+WHERE remote_url = $1 AND branch = $2 AND pull;
+
+-- name: ClaimDueGitMirrors :many
+-- Claiming a mirror leases it until lease_until, so that other instances
+-- skip it while it syncs; finishing the sync, or failing it, ends the lease.
+UPDATE workspace_git_mirrors m
+SET next_attempt_at = sqlc.arg(lease_until)
+FROM workspaces w, users u
+WHERE w.id = m.workspace_id AND u.id = w.user_id
+  AND m.workspace_id IN (
+    SELECT d.workspace_id FROM workspace_git_mirrors d
+    WHERE (d.next_attempt_at IS NULL OR d.next_attempt_at <= NOW())
+      AND (d.last_synced_at IS NULL
+           OR d.sync_requested_at IS NOT NULL
+           OR (d.pull AND d.last_synced_at <= sqlc.arg(pull_before))
+           OR EXISTS (SELECT 1 FROM workspace_events e WHERE e.workspace_id = d.workspace_id AND e.id > d.last_event_id))
+    ORDER BY d.next_attempt_at NULLS FIRST, d.created_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED)
+RETURNING m.*, w.user_id AS owner_id, u.email AS owner_email, w.archived_at;
+
+-- name: CompleteGitMirrorSync :exec
+-- The remote is matched so that a sync finishing after the mirror was
+-- pointed elsewhere does not record its commit for the new remote.
+UPDATE workspace_git_mirrors
+SET last_event_id = sqlc.arg(last_event_id),
+    last_commit = sqlc.arg(last_commit),
+    last_synced_at = NOW(),
+    sync_requested_at = NULL,
+    failure_count = 0,
+    last_error = NULL,
+    next_attempt_at = NULL
+WHERE workspace_id = sqlc.arg(workspace_id) AND remote_url = sqlc.arg(remote_url) AND branch = sqlc.arg(branch);
+
+-- name: RecordGitMirrorFailure :exec
+UPDATE workspace_git_mirrors
+SET failure_count = failure_count + 1, last_error = $2, next_attempt_at = $3
+WHERE workspace_id = $1;
+
+-- name: GetLatestWorkspaceEventID :one
+SELECT COALESCE(MAX(id), 0)::bigint FROM workspace_events WHERE workspace_id = $1;
+
+-- name: UpsertFileSearch :exec
+INSERT INTO file_search (file_id, workspace_id, content_hash, document)
+VALUES (sqlc.arg(file_id), sqlc.arg(workspace_id), sqlc.arg(content_hash),
+        setweight(to_tsvector('simple', translate(sqlc.arg(file_path)::text, '/._-', '    ')), 'A') ||
+        setweight(to_tsvector('simple', sqlc.arg(content)::text), 'B'))
+ON CONFLICT (file_id) DO UPDATE SET content_hash = EXCLUDED.content_hash, document = EXCLUDED.document;
+
+-- name: ListUnindexedFiles :many
+SELECT f.id, f.workspace_id, f.file_path, f.content_hash
+FROM files f
+LEFT JOIN file_search s ON s.file_id = f.id
+LEFT JOIN file_search_failures x ON x.file_id = f.id AND x.content_hash = f.content_hash
+WHERE (s.file_id IS NULL OR s.content_hash <> f.content_hash)
+  AND COALESCE(x.attempts, 0) < sqlc.arg(max_attempts)::integer
+ORDER BY COALESCE(x.attempts, 0), f.last_modified DESC
+LIMIT sqlc.arg(batch_size);
+
+-- name: RecordFileSearchFailure :exec
+INSERT INTO file_search_failures (file_id, content_hash, last_error)
+VALUES ($1, $2, $3)
+ON CONFLICT (file_id) DO UPDATE
+SET attempts = CASE WHEN file_search_failures.content_hash = EXCLUDED.content_hash
+                    THEN file_search_failures.attempts + 1 ELSE 1 END,
+    content_hash = EXCLUDED.content_hash,
+    last_error = EXCLUDED.last_error,
+    updated_at = NOW();
+
+-- name: DeleteFileSearchFailure :exec
+DELETE FROM file_search_failures WHERE file_id = $1;
+
+-- name: GetSearchIndexStatus :one
+SELECT COUNT(*)::bigint AS total_files,
+       COUNT(s.file_id) FILTER (WHERE s.content_hash = f.content_hash)::bigint AS indexed_files,
+       COUNT(x.file_id) FILTER (WHERE s.file_id IS NULL OR s.content_hash <> f.content_hash)::bigint AS failed_files
+FROM files f
+LEFT JOIN file_search s ON s.file_id = f.id
+LEFT JOIN file_search_failures x ON x.file_id = f.id AND x.content_hash = f.content_hash
+    AND x.attempts >= sqlc.arg(max_attempts)::integer
+WHERE f.workspace_id = sqlc.arg(workspace_id);
+
+-- name: SearchFiles :many
+SELECT s.file_id FROM file_search s
+JOIN files f ON f.id = s.file_id
+WHERE s.workspace_id = sqlc.arg(workspace_id)
+  AND s.document @@ websearch_to_tsquery('simple', sqlc.arg(query)::text)
+ORDER BY ts_rank(s.document, websearch_to_tsquery('simple', sqlc.arg(query)::text)) DESC, f.file_path
+LIMIT sqlc.arg(max_results);
+
+-- name: GetFilesByIDs :many
+SELECT f.* FROM unnest(sqlc.arg(file_ids)::uuid[]) WITH ORDINALITY AS r(id, position)
+JOIN files f ON f.id = r.id
+ORDER BY r.position;
+
+-- name: CreateSearchSnapshot :one
+INSERT INTO search_snapshots (workspace_id, user_id, query, revision, file_ids, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetSearchSnapshot :one
+SELECT * FROM search_snapshots
+WHERE id = $1 AND workspace_id = $2 AND user_id = $3 AND expires_at > NOW();
+
+-- name: DeleteExpiredSearchSnapshots :execrows
+DELETE FROM search_snapshots WHERE expires_at <= NOW();
+
+-- name: CreatePolicyRule :one
+INSERT INTO policy_rules (name, detector, pattern, action, created_by)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: ListPolicyRules :many
+SELECT * FROM policy_rules ORDER BY created_at, id;
+
+-- name: DeletePolicyRule :execrows
+DELETE FROM policy_rules WHERE id = $1;
+
+-- name: InsertPolicyViolation :exec
+INSERT INTO policy_violations (rule_id, rule_name, workspace_id, user_id, file_path, action_type, blocked, match_count)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: ListPolicyViolations :many
+SELECT * FROM policy_violations
+WHERE id < sqlc.arg(before_id)
+ORDER BY id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListWorkspaceViolationsBetween :many
+SELECT * FROM policy_violations
+WHERE workspace_id = sqlc.arg(workspace_id)
+  AND created_at >= sqlc.arg(from_time)
+  AND created_at < sqlc.arg(to_time)
+  AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(page_size);
+
+-- name: GetTelemetryCounts :one
+SELECT
+    (SELECT COUNT(*) FROM users) AS users,
+    (SELECT COUNT(*) FROM workspaces) AS workspaces,
+    (SELECT COUNT(*) FROM workspaces WHERE archived_at IS NOT NULL) AS archived_workspaces,
+    (SELECT COUNT(*) FROM files) AS files,
+    (SELECT COALESCE(SUM(storage_used_bytes), 0) FROM workspaces)::bigint AS storage_used_bytes,
+    (SELECT COUNT(DISTINCT workspace_id) FROM workspace_members WHERE role <> 'owner') AS shared_workspaces,
+    (SELECT COUNT(*) FROM share_links WHERE revoked_at IS NULL) AS share_links,
+    (SELECT COUNT(*) FROM devices) AS devices,
+    (SELECT COUNT(*) FROM workspace_webhooks) AS webhooks,
+    (SELECT COUNT(*) FROM file_tasks) AS tasks,
+    (SELECT COUNT(*) FROM policy_rules) AS policy_rules;
+
+-- name: CreateOperation :one
+INSERT INTO operations (kind, user_id, workspace_id, params, steps_total)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetOperation :one
+SELECT * FROM operations WHERE id = $1;
+
+-- name: ClaimOperation :one
+-- Takes the oldest operation that is pending or whose worker's lease ran
+-- out. attempts doubles as a fencing token for the checkpoints below.
+UPDATE operations
+SET status = 'running', attempts = attempts + 1,
+    lease_expires_at = sqlc.arg(lease_expires_at), updated_at = NOW()
+WHERE id = (
+    SELECT id FROM operations
+    WHERE status = 'pending' OR (status = 'running' AND lease_expires_at < NOW())
+    ORDER BY created_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CheckpointOperation :execrows
+UPDATE operations
+SET state = $2, steps_done = $3, steps_total = $4, lease_expires_at = $5, updated_at = NOW()
+WHERE id = $1 AND status = 'running' AND attempts = $6;
+
+-- name: FinishOperation :execrows
+UPDATE operations
+SET status = $2, result = $3, error = $4, lease_expires_at = NULL, finished_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status = 'running' AND attempts = $5;
+
+-- name: CountRunnableOperations :one
+SELECT COUNT(*) FROM operations WHERE status IN ('pending', 'running');
+
+-- name: DeleteFinishedOperations :execrows
+DELETE FROM operations WHERE finished_at < $1;
+
+-- name: CreateOperationUpload :exec
+INSERT INTO operation_uploads (operation_id, content) VALUES ($1, $2);
+
+-- name: GetOperationUpload :one
+SELECT content FROM operation_uploads WHERE operation_id = $1;
+
+-- name: DeleteOperationUpload :exec
+DELETE FROM operation_uploads WHERE operation_id = $1;
+
+-- name: UpsertPublishedSite :one
+INSERT INTO published_sites (workspace_id, slug, include_folders, exclude_folders, require_flag, published_by)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (workspace_id) DO UPDATE
+SET slug = EXCLUDED.slug,
+    include_folders = EXCLUDED.include_folders,
+    exclude_folders = EXCLUDED.exclude_folders,
+    require_flag = EXCLUDED.require_flag,
+    published_by = EXCLUDED.published_by,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: GetPublishedSite :one
+SELECT * FROM published_sites WHERE workspace_id = $1;
+
+-- name: GetPublishedSiteBySlug :one
+SELECT p.workspace_id, p.include_folders, p.exclude_folders, p.require_flag, p.updated_at,
+       w.name AS workspace_name, w.revision,
+       COALESCE(p.published_by, w.user_id)::uuid AS published_by
+FROM published_sites p
+JOIN workspaces w ON w.id = p.workspace_id
+WHERE p.slug = $1 AND w.archived_at IS NULL;
+
+-- name: DeletePublishedSite :execrows
+DELETE FROM published_sites WHERE workspace_id = $1;
+
+-- name: GetOAuthIdentity :one
+SELECT * FROM oauth_identities WHERE provider = $1 AND provider_user_id = $2;
+
+-- name: CreateOAuthIdentity :one
+INSERT INTO oauth_identities (user_id, provider, provider_user_id, email, last_login_at)
+VALUES ($1, $2, $3, $4, NOW())
+RETURNING *;
+
+-- name: TouchOAuthIdentity :exec
+UPDATE oauth_identities SET email = $2, last_login_at = NOW() WHERE id = $1;
+
+-- name: ListOAuthIdentities :many
+SELECT * FROM oauth_identities WHERE user_id = $1 ORDER BY provider;
+
+-- name: DeleteOAuthIdentity :execrows
+DELETE FROM oauth_identities WHERE user_id = $1 AND provider = $2;
+
+-- name: DeleteUserOAuthIdentities :exec
+DELETE FROM oauth_identities WHERE user_id = $1;
+
+-- name: CreatePasskey :one
+INSERT INTO passkeys (user_id, credential_id, public_key, sign_count, name)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetPasskeyByCredentialID :one
+SELECT * FROM passkeys WHERE credential_id = $1;
+
+-- name: ListPasskeys :many
+SELECT * FROM passkeys WHERE user_id = $1 ORDER BY created_at;
+
+-- name: CountPasskeys :one
+SELECT COUNT(*) FROM passkeys WHERE user_id = $1;
+
+-- name: TouchPasskey :exec
+UPDATE passkeys SET sign_count = $2, last_used_at = NOW() WHERE id = $1;
+
+-- name: DeletePasskey :execrows
+DELETE FROM passkeys WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteUserPasskeys :exec
+DELETE FROM passkeys WHERE user_id = $1;
+
+-- name: ListAdminUsers :many
+SELECT u.id, u.email, u.tier, u.is_admin, u.storage_limit_bytes, u.disabled_at, u.created_at,
+       COUNT(w.id) AS workspace_count,
+       COALESCE(SUM(w.file_count), 0)::bigint AS file_count,
+       COALESCE(SUM(w.storage_used_bytes), 0)::bigint AS storage_used_bytes
+FROM users u
+LEFT JOIN workspaces w ON w.user_id = u.id
+WHERE u.email > sqlc.arg(after_email)
+  AND starts_with(u.email, sqlc.arg(email_prefix))
+GROUP BY u.id
+ORDER BY u.email
+LIMIT sqlc.arg(page_size);
+
+-- name: GetAdminUser :one
+SELECT u.id, u.email, u.tier, u.is_admin, u.storage_limit_bytes, u.disabled_at, u.created_at,
+       COUNT(w.id) AS workspace_count,
+       COALESCE(SUM(w.file_count), 0)::bigint AS file_count,
+       COALESCE(SUM(w.storage_used_bytes), 0)::bigint AS storage_used_bytes
+FROM users u
+LEFT JOIN workspaces w ON w.user_id = u.id
+WHERE u.id = $1
+GROUP BY u.id;
+
+-- name: UpdateUserAdmin :one
+UPDATE users SET
+    tier = COALESCE(sqlc.narg(tier), tier),
+    is_admin = COALESCE(sqlc.narg(is_admin), is_admin),
+    storage_limit_bytes = CASE WHEN sqlc.arg(set_storage_limit)::boolean THEN sqlc.narg(storage_limit_bytes) ELSE storage_limit_bytes END,
+    disabled_at = CASE
+        WHEN sqlc.narg(disabled)::boolean IS NULL THEN disabled_at
+        WHEN sqlc.narg(disabled)::boolean THEN COALESCE(disabled_at, NOW())
+    END,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: SetWorkspaceStorageLimits :exec
+UPDATE workspaces SET storage_limit_bytes = $2 WHERE user_id = $1;
+
+-- name: ScheduleUserDeletion :one
+UPDATE users SET delete_after = $2, updated_at = NOW() WHERE id = $1
+RETURNING *;
+
+-- name: CancelUserDeletion :execrows
+UPDATE users SET delete_after = NULL, updated_at = NOW()
+WHERE id = $1 AND delete_after IS NOT NULL;
+
+-- name: ListUsersDueForDeletion :many
+SELECT id FROM users
+WHERE delete_after <= NOW()
+ORDER BY delete_after
+LIMIT $1;
+
+-- name: DeleteScheduledUser :execrows
+DELETE FROM users WHERE id = $1 AND delete_after <= NOW();
+
+-- name: ClaimIdempotencyKey :execrows
+INSERT INTO idempotency_keys (user_id, idempotency_key)
+VALUES (sqlc.arg(user_id), sqlc.arg(idempotency_key))
+ON CONFLICT (user_id, idempotency_key) DO UPDATE SET
+    fingerprint = NULL,
+    status_code = NULL,
+    response_headers = NULL,
+    response_body = NULL,
+    created_at = NOW(),
+    completed_at = NULL
+WHERE (idempotency_keys.completed_at IS NULL AND idempotency_keys.created_at < sqlc.arg(stale_before))
+   OR idempotency_keys.created_at < sqlc.arg(expired_before);
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2;
+
+-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys SET
+    fingerprint = $3,
+    status_code = $4,
+    response_headers = $5,
+    response_body = $6,
+    completed_at = NOW()
+WHERE user_id = $1 AND idempotency_key = $2;
+
+-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys WHERE created_at < $1;
+
+-- name: CreateNoteTemplate :one
+INSERT INTO note_templates (workspace_id, name, description, content, created_by)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: ListNoteTemplates :many
+SELECT * FROM note_templates WHERE workspace_id = $1 ORDER BY name;
+
+-- name: GetNoteTemplateByName :one
+SELECT * FROM note_templates WHERE workspace_id = $1 AND name = $2;
+
+-- name: DeleteNoteTemplate :execrows
+DELETE FROM note_templates WHERE workspace_id = $1 AND id = $2;
+
+-- name: GetDailyNoteSettings :one
+SELECT * FROM daily_note_settings WHERE workspace_id = $1;
+
+-- name: UpsertDailyNoteSettings :one
+INSERT INTO daily_note_settings (workspace_id, path_pattern, template_name)
+VALUES ($1, $2, $3)
+ON CONFLICT (workspace_id) DO UPDATE
+SET path_pattern = EXCLUDED.path_pattern,
+    template_name = EXCLUDED.template_name,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteFileAgendaEntries :exec
+DELETE FROM agenda_entries WHERE file_id = $1;
+
+-- name: InsertAgendaEntry :exec
+INSERT INTO agenda_entries (file_id, workspace_id, line, kind, day, time_of_day, title, keyword, done)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT DO NOTHING;
+
+-- name: ListAgendaEntries :many
+SELECT a.kind, a.day, a.time_of_day, a.title, a.keyword, a.done, a.line, f.file_path
+FROM agenda_entries a
+JOIN files f ON f.id = a.file_id
+WHERE a.workspace_id = sqlc.arg(workspace_id)
+  AND a.day BETWEEN sqlc.arg(from_day) AND sqlc.arg(to_day)
+ORDER BY a.day, a.time_of_day, f.file_path, a.line
+LIMIT sqlc.arg(max_entries);
+
+-- name: CreateCalendarFeed :one
+INSERT INTO calendar_feeds (token_hash, workspace_id, user_id)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: ListCalendarFeeds :many
+SELECT * FROM calendar_feeds
+WHERE user_id = $1 AND workspace_id = $2
+ORDER BY created_at;
+
+-- name: DeleteCalendarFeed :execrows
+DELETE FROM calendar_feeds
+WHERE id = $1 AND user_id = $2 AND workspace_id = $3;
+
+-- name: GetCalendarFeedByToken :one
+SELECT * FROM calendar_feeds WHERE token_hash = $1;
+
+-- name: TouchCalendarFeed :exec
+UPDATE calendar_feeds SET last_fetched_at = NOW() WHERE id = $1;
+
+-- name: DeleteFileLinks :exec
+DELETE FROM note_links WHERE file_id = $1;
+
+-- name: InsertNoteLink :exec
+INSERT INTO note_links (file_id, workspace_id, line, target, candidates)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT DO NOTHING;
+
+-- name: ListFileLinks :many
+SELECT line, target, candidates FROM note_links
+WHERE file_id = $1
+ORDER BY line, target;
+
+-- name: ListLinksToPaths :many
+SELECT f.file_path, l.line, l.target, l.candidates
+FROM note_links l
+JOIN files f ON f.id = l.file_id
+WHERE l.workspace_id = sqlc.arg(workspace_id)
+  AND l.candidates && sqlc.arg(file_paths)::text[]
+ORDER BY f.file_path, l.line
+LIMIT sqlc.arg(max_links);
+
+-- name: ListBrokenLinks :many
+SELECT f.file_path, l.line, l.target
+FROM note_links l
+JOIN files f ON f.id = l.file_id
+WHERE l.workspace_id = sqlc.arg(workspace_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM files t
+    WHERE t.workspace_id = l.workspace_id AND t.file_path = ANY(l.candidates)
+  )
+ORDER BY f.file_path, l.line, l.target
+LIMIT sqlc.arg(max_links);
+
+-- name: DeleteFileTags :exec
+DELETE FROM note_tags WHERE file_id = $1;
+
+-- name: InsertNoteTag :exec
+INSERT INTO note_tags (file_id, workspace_id, tag)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: ListFileTags :many
+SELECT tag FROM note_tags WHERE file_id = $1 ORDER BY tag;
+
+-- name: ListFilesSharingTags :many
+SELECT f.file_path, array_agg(t.tag ORDER BY t.tag)::text[] AS tags
+FROM note_tags t
+JOIN files f ON f.id = t.file_id
+WHERE t.workspace_id = sqlc.arg(workspace_id)
+  AND t.file_id <> sqlc.arg(file_id)
+  AND t.tag = ANY(sqlc.arg(tags)::text[])
+GROUP BY f.file_path
+ORDER BY COUNT(*) DESC, f.file_path
+LIMIT sqlc.arg(max_files);
+
+-- name: GetAuthLockouts :many
+SELECT * FROM auth_failures
+WHERE key = ANY(sqlc.arg(keys)::text[]) AND locked_until > NOW();
+
+-- name: RecordAuthFailure :one
+-- Failures count again from one once none happened since reset_before.
+INSERT INTO auth_failures (key, failures, last_failed_at)
+VALUES (sqlc.arg(key), 1, NOW())
+ON CONFLICT (key) DO UPDATE SET
+    failures = CASE
+        WHEN auth_failures.last_failed_at < sqlc.arg(reset_before) THEN 1
+        ELSE auth_failures.failures + 1
+    END,
+    last_failed_at = NOW()
+RETURNING *;
+
+-- name: LockAuthKey :exec
+UPDATE auth_failures SET locked_until = $2 WHERE key = $1;
+
+-- name: ClearAuthFailures :exec
+DELETE FROM auth_failures WHERE key = $1;
+
+-- name: DeleteStaleAuthFailures :execrows
+DELETE FROM auth_failures
+WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < NOW());
+
+-- name: AddUsage :exec
+-- Adds counts to a user's month. Counts of users deleted meanwhile are
+-- dropped.
+INSERT INTO usage_monthly (user_id, month, api_requests, upload_bytes, download_bytes)
+SELECT u.id, sqlc.arg(month)::date, sqlc.arg(api_requests)::bigint, sqlc.arg(upload_bytes)::bigint, sqlc.arg(download_bytes)::bigint
+FROM users u
+WHERE u.id = sqlc.arg(user_id)
+ON CONFLICT (user_id, month) DO UPDATE SET
+    api_requests = usage_monthly.api_requests + EXCLUDED.api_requests,
+    upload_bytes = usage_monthly.upload_bytes + EXCLUDED.upload_bytes,
+    download_bytes = usage_monthly.download_bytes + EXCLUDED.download_bytes,
+    updated_at = NOW();
+
+-- name: ListUserUsage :many
+SELECT * FROM usage_monthly
+WHERE user_id = $1 AND month >= sqlc.arg(since)
+ORDER BY month DESC;
+
+-- name: ListUsageByMonth :many
+SELECT u.id AS user_id, u.email, u.tier, m.api_requests, m.upload_bytes, m.download_bytes
+FROM usage_monthly m
+JOIN users u ON u.id = m.user_id
+WHERE m.month = $1
+ORDER BY u.email;
+
+-- name: CreateOrganization :one
+INSERT INTO organizations (name, billing_email, tier, seat_storage_bytes)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetOrganization :one
+SELECT o.id, o.name, o.billing_email, o.tier, o.seat_storage_bytes, o.storage_limit_bytes, o.created_at, o.updated_at,
+       (SELECT COUNT(*) FROM organization_members m WHERE m.organization_id = o.id) AS member_count,
+       (SELECT COUNT(*) FROM organization_workspaces ow WHERE ow.organization_id = o.id) AS workspace_count,
+       (SELECT COALESCE(SUM(w.storage_used_bytes), 0)
+        FROM organization_workspaces ow
+        JOIN workspaces w ON w.id = ow.workspace_id
+        WHERE ow.organization_id = o.id)::bigint AS storage_used_bytes
+FROM organizations o
+WHERE o.id = $1;
+
+-- name: ListOrganizations :many
+SELECT o.id, o.name, o.billing_email, o.tier, o.seat_storage_bytes, o.storage_limit_bytes, o.created_at, o.updated_at,
+       (SELECT COUNT(*) FROM organization_members m WHERE m.organization_id = o.id) AS member_count,
+       (SELECT COUNT(*) FROM organization_workspaces ow WHERE ow.organization_id = o.id) AS workspace_count,
+       (SELECT COALESCE(SUM(w.storage_used_bytes), 0)
+        FROM organization_workspaces ow
+        JOIN workspaces w ON w.id = ow.workspace_id
+        WHERE ow.organization_id = o.id)::bigint AS storage_used_bytes
+FROM organizations o
+ORDER BY o.name, o.id;
+
+-- name: ListUserOrganizations :many
+SELECT o.id, o.name, o.billing_email, o.tier, o.seat_storage_bytes, o.storage_limit_bytes, o.created_at, o.updated_at,
+       (SELECT COUNT(*) FROM organization_members om WHERE om.organization_id = o.id) AS member_count,
+       (SELECT COUNT(*) FROM organization_workspaces ow WHERE ow.organization_id = o.id) AS workspace_count,
+       (SELECT COALESCE(SUM(w.storage_used_bytes), 0)
+        FROM organization_workspaces ow
+        JOIN workspaces w ON w.id = ow.workspace_id
+        WHERE ow.organization_id = o.id)::bigint AS storage_used_bytes,
+       m.role
+FROM organizations o
+JOIN organization_members m ON m.organization_id = o.id
+WHERE m.user_id = $1
+ORDER BY o.name, o.id;
+
+-- name: UpdateOrganization :one
+UPDATE organizations SET
+    name = COALESCE(sqlc.narg(name), name),
+    billing_email = COALESCE(sqlc.narg(billing_email), billing_email),
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: UpdateOrganizationAdmin :one
+UPDATE organizations SET
+    tier = COALESCE(sqlc.narg(tier), tier),
+    seat_storage_bytes = COALESCE(sqlc.narg(seat_storage_bytes), seat_storage_bytes),
+    storage_limit_bytes = CASE WHEN sqlc.arg(set_storage_limit)::boolean THEN sqlc.narg(storage_limit_bytes) ELSE storage_limit_bytes END,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: DeleteOrganization :execrows
+DELETE FROM organizations WHERE id = $1;
+
+-- name: AddOrganizationMember :one
+INSERT INTO organization_members (organization_id, user_id, role, invited_by)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetOrganizationMember :one
+SELECT * FROM organization_members WHERE organization_id = $1 AND user_id = $2;
+
+-- name: ListOrganizationMembers :many
+SELECT m.organization_id, m.user_id, m.role, m.invited_by, m.created_at, u.email
+FROM organization_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.organization_id = $1
+ORDER BY m.created_at, u.email;
+
+-- name: UpdateOrganizationMemberRole :execrows
+UPDATE organization_members SET role = $3 WHERE organization_id = $1 AND user_id = $2;
+
+-- name: RemoveOrganizationMember :execrows
+DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2;
+
+-- name: CountOrganizationOwners :one
+SELECT COUNT(*) FROM organization_members WHERE organization_id = $1 AND role = 'owner';
+
+-- name: AddOrganizationWorkspace :exec
+INSERT INTO organization_workspaces (workspace_id, organization_id, added_by)
+VALUES ($1, $2, $3);
+
+-- name: RemoveOrganizationWorkspace :execrows
+DELETE FROM organization_workspaces WHERE workspace_id = $1 AND organization_id = $2;
+
+-- name: ListOrganizationWorkspaces :many
+SELECT w.id, w.user_id, u.email AS owner_email, w.name, w.storage_used_bytes, w.file_count, ow.added_by, ow.created_at AS added_at
+FROM organization_workspaces ow
+JOIN workspaces w ON w.id = ow.workspace_id
+JOIN users u ON u.id = w.user_id
+WHERE ow.organization_id = $1
+ORDER BY w.name, w.id;
+
+-- name: CreateNotification :one
+INSERT INTO notifications (user_id, kind, workspace_id, title, body, data)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: ListNotifications :many
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg(user_id)
+  AND (NOT sqlc.arg(unread_only)::boolean OR read_at IS NULL)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1 AND user_id = $2;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: ListStorageAlertCandidates :many
+-- Workspaces using min_percent of their limit or more, and those alerted
+-- before, measured as GetWorkspaceStorageUsage measures them.
+SELECT c.workspace_id, c.workspace_name, c.owner_id, c.owner_email,
+       c.storage_used_bytes, c.storage_limit_bytes,
+       COALESCE(a.threshold, 0)::int AS alerted_threshold
+FROM (
+    SELECT w.id AS workspace_id, w.name AS workspace_name,
+           w.user_id AS owner_id, u.email AS owner_email,
+           CASE WHEN ow.organization_id IS NULL THEN COALESCE(w.storage_used_bytes, 0)
+                ELSE (SELECT COALESCE(SUM(pw.storage_used_bytes), 0)
+                      FROM organization_workspaces pow
+                      JOIN workspaces pw ON pw.id = pow.workspace_id
+                      WHERE pow.organization_id = o.id)
+           END::bigint AS storage_used_bytes,
+           CASE WHEN ow.organization_id IS NULL THEN w.storage_limit_bytes
+                ELSE COALESCE(o.storage_limit_bytes, o.seat_storage_bytes *
+                    (SELECT COUNT(*) FROM organization_members m WHERE m.organization_id = o.id))
+           END::bigint AS storage_limit_bytes
+    FROM workspaces w
+    JOIN users u ON u.id = w.user_id
+    LEFT JOIN organization_workspaces ow ON ow.workspace_id = w.id
+    LEFT JOIN organizations o ON o.id = ow.organization_id
+) c
+LEFT JOIN workspace_storage_alerts a ON a.workspace_id = c.workspace_id
+WHERE a.workspace_id IS NOT NULL
+   OR c.storage_used_bytes * 100 >= c.storage_limit_bytes * sqlc.arg(min_percent)::bigint;
+
+-- name: RaiseStorageAlert :execrows
+-- Records that a workspace was alerted about threshold, unless it already
+-- was about that threshold or a higher one.
+INSERT INTO workspace_storage_alerts (workspace_id, threshold)
+VALUES ($1, $2)
+ON CONFLICT (workspace_id) DO UPDATE
+SET threshold = EXCLUDED.threshold, updated_at = NOW()
+WHERE workspace_storage_alerts.threshold < EXCLUDED.threshold;
+
+-- name: LowerStorageAlert :exec
+UPDATE workspace_storage_alerts SET threshold = $2, updated_at = NOW()
+WHERE workspace_id = $1 AND threshold > $2;
+
+-- name: DeleteStorageAlert :exec
+DELETE FROM workspace_storage_alerts WHERE workspace_id = $1;
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/duckonomy/noture/internal/config"
+	"github.com/duckonomy/noture/internal/db"
+	"github.com/duckonomy/noture/internal/storage"
+	"github.com/duckonomy/noture/pkg/logger"
+	"github.com/jackc/pgx/v5"
+)
+
+const storageUsage = `usage: noture-server storage <command> [flags]
+
+commands:
+  migrate   copy every referenced blob from one backend to another
+
+flags:
+  -from string  source backend (default postgres)
+  -to string    destination backend (default $STORAGE_BACKEND)
+
+Both backends are configured from the same STORAGE_* and S3_* variables
+the server uses. Run migrate before switching STORAGE_BACKEND.
+`
+
+func runStorageCommand(log *logger.Logger, cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("storage", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, storageUsage) }
+
+	from := fs.String("from", storage.KindPostgres, "source backend")
+	to := fs.String("to", os.Getenv("STORAGE_BACKEND"), "destination backend")
+
+	if len(args) == 0 || args[0] != "migrate" {
+		fs.Usage()
+		return 2
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *to == "" || *to == *from {
+		fmt.Fprintln(os.Stderr, "storage migrate: -to must name a backend other than -from")
+		return 2
+	}
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, cfg.DatabaseURL)
+	if err != nil {
+		log.Error("Failed to connect to database", "error", err)
+		return 1
+	}
+	defer conn.Close(ctx)
+	queries := db.New(conn)
+
+	storageConfig := storage.ConfigFromEnv(queries)
+	storageConfig.Kind = *from
+	source, err := storage.New(storageConfig)
+	if err != nil {
+		log.Error("Failed to open source storage", "error", err)
+		return 1
+	}
+	storageConfig.Kind = *to
+	dest, err := storage.New(storageConfig)
+	if err != nil {
+		log.Error("Failed to open destination storage", "error", err)
+		return 1
+	}
+
+	hashes, err := queries.ListAllContentHashes(ctx)
+	if err != nil {
+		log.Error("Failed to list content", "error", err)
+		return 1
+	}
+
+	var copied, missing int
+	for _, hash := range hashes {
+		if _, err := dest.Get(ctx, hash); err == nil {
+			continue
+		}
+		content, err := source.Get(ctx, hash)
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("Blob missing from source", "content_hash", hash)
+			missing++
+			continue
+		}
+		if err != nil {
+			log.Error("Failed to read blob", "content_hash", hash, "error", err)
+			return 1
+		}
+		if err := dest.Put(ctx, hash, content); err != nil {
+			log.Error("Failed to write blob", "content_hash", hash, "error", err)
+			return 1
+		}
+		copied++
+	}
+
+	fmt.Printf("copied %d of %d blobs from %s to %s", copied, len(hashes), *from, *to)
+	if missing > 0 {
+		fmt.Printf(" (%d missing from source)", missing)
+	}
+	fmt.Println()
+	if missing > 0 {
+		return 1
+	}
+	return 0
+}